@@ -0,0 +1,85 @@
+// Command migrate upgrades the etcd documents written by older builds to the current
+// schema version in place, run it before deploying a build that drops an upgrade step.
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/config"
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type Config struct {
+	App               config.App  `mapstructure:"app"`
+	Etcd              etcd.Config `mapstructure:"etcd"`
+	EtcdPrefixRooms   string      `mapstructure:"etcd_prefix_rooms"`
+	EtcdPrefixJanuses string      `mapstructure:"etcd_prefix_januses"`
+	EtcdPrefixMixers  string      `mapstructure:"etcd_prefix_mixers"`
+	DryRun            bool        `mapstructure:"dry_run"`
+}
+
+func loadConfig() (*Config, error) {
+	return config.Load(&Config{}, func(v *viper.Viper) {
+		v.SetDefault("etcd_prefix_rooms", "/rooms/")
+		v.SetDefault("etcd_prefix_januses", "/januses/")
+		v.SetDefault("etcd_prefix_mixers", "/mixers/")
+		v.SetDefault("dry_run", false)
+
+		config.Setup(v, "app")
+		etcd.Setup(v, "etcd")
+	})
+}
+
+func main() {
+	config, err := loadConfig()
+	if err != nil {
+		log.Fatal("Failed to load configuration", err)
+	}
+
+	logger, err := log.NewLogger(config.App.LogConfigFile)
+	if err != nil {
+		log.Fatal("Failed to create logger", err)
+	}
+	defer func() { _ = logger.Sync() }()
+
+	etcdClient, err := etcd.NewClient(&config.Etcd)
+	if err != nil {
+		logger.Fatal("Failed to create etcd client", log.Error(err))
+	}
+	defer etcdClient.Close()
+
+	m := &migrator{
+		kv:     etcdClient,
+		dryRun: config.DryRun,
+		logger: logger,
+	}
+
+	ctx := context.Background()
+	logger.Info("Upgrading etcd documents",
+		log.Int("schemaVersion", etcdstate.SchemaVersion),
+		log.Bool("dryRun", config.DryRun))
+
+	failed := false
+	for _, prefix := range []string{config.EtcdPrefixRooms, config.EtcdPrefixJanuses, config.EtcdPrefixMixers} {
+		res, err := m.migratePrefix(ctx, prefix)
+		if err != nil {
+			logger.Error("Failed to upgrade prefix", log.String("prefix", prefix), log.Error(err))
+			failed = true
+			continue
+		}
+		logger.Info("Upgraded prefix",
+			log.String("prefix", prefix),
+			log.Int("scanned", res.Scanned),
+			log.Int("upgraded", res.Upgraded),
+			log.Int("skipped", res.Skipped),
+			log.Int("failed", res.Failed))
+		failed = failed || res.Failed > 0
+	}
+	if failed {
+		logger.Fatal("Some documents were not upgraded")
+	}
+}
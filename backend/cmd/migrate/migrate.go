@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// etcdKV reads the keys to upgrade and writes them back in transactions
+type etcdKV interface {
+	etcd.KV
+	etcd.Tx
+}
+
+type result struct {
+	Scanned  int
+	Upgraded int
+	Skipped  int // changed concurrently, the writer stamps the version itself
+	Failed   int
+}
+
+type migrator struct {
+	kv     etcdKV
+	dryRun bool
+	logger *log.Logger
+}
+
+// migratePrefix upgrades the versioned documents under prefix in place, keys keep their lease
+// and are only written when unchanged since read
+func (m *migrator) migratePrefix(ctx context.Context, prefix string) (*result, error) {
+	resp, err := m.kv.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+
+	res := &result{}
+	for _, kv := range resp.Kvs {
+		key := string(kv.Key)
+		keyType := key[strings.LastIndex(key, "/")+1:]
+		if !etcdstate.IsVersioned(keyType) {
+			continue
+		}
+		res.Scanned++
+
+		upgraded, changed, err := etcdstate.Upgrade(keyType, kv.Value)
+		if err != nil {
+			m.logger.Warn("Cannot upgrade key", log.String("key", key), log.Error(err))
+			res.Failed++
+			continue
+		}
+		if !changed {
+			continue
+		}
+		if m.dryRun {
+			m.logger.Info("Would upgrade key", log.String("key", key), log.String("value", string(upgraded)))
+			res.Upgraded++
+			continue
+		}
+
+		txnResp, err := m.kv.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)).
+			Then(clientv3.OpPut(key, string(upgraded), clientv3.WithIgnoreLease())).
+			Commit()
+		if err != nil {
+			return res, fmt.Errorf("failed to upgrade %s: %w", key, err)
+		}
+		if !txnResp.Succeeded {
+			m.logger.Info("Key changed during upgrade, skipped", log.String("key", key))
+			res.Skipped++
+			continue
+		}
+		m.logger.Debug("Upgraded key", log.String("key", key))
+		res.Upgraded++
+	}
+	return res, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+)
+
+const apiTimeout = 10 * time.Second
+
+// apiClient calls the rooms, users and HLS token APIs the way an operator and a listener do
+type apiClient struct {
+	rooms *resty.Client
+	users *resty.Client
+	hls   *resty.Client
+}
+
+func newAPIClient(cfg *Config) *apiClient {
+	client := func(baseURL, token string) *resty.Client {
+		c := resty.New().
+			SetBaseURL(strings.TrimSuffix(baseURL, "/")).
+			SetTimeout(apiTimeout)
+		if token != "" {
+			c.SetAuthToken(token)
+		}
+		return c
+	}
+	return &apiClient{
+		rooms: client(cfg.RoomsURL, cfg.RoomsToken),
+		users: client(cfg.UsersURL, cfg.UsersToken),
+		hls:   client(cfg.HLSTokenURL, ""),
+	}
+}
+
+// createRoom creates the room, returning its PIN
+func (c *apiClient) createRoom(ctx context.Context, roomID string) (string, error) {
+	var result struct {
+		Room struct {
+			Pin string `json:"pin"`
+		} `json:"room"`
+	}
+	resp, err := c.rooms.R().
+		SetContext(ctx).
+		SetBody(map[string]any{"roomId": roomID}).
+		SetResult(&result).
+		Post("/api/rooms")
+	if err != nil {
+		return "", fmt.Errorf("failed to create room: %w", err)
+	}
+	if resp.IsError() {
+		return "", fmt.Errorf("failed to create room: %s", resp.Status())
+	}
+	return result.Room.Pin, nil
+}
+
+func (c *apiClient) deleteRoom(ctx context.Context, roomID string) error {
+	resp, err := c.rooms.R().
+		SetContext(ctx).
+		Delete("/api/rooms/" + url.PathEscape(roomID))
+	if err != nil {
+		return fmt.Errorf("failed to delete room: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("failed to delete room: %s", resp.Status())
+	}
+	return nil
+}
+
+// createAnchor creates an anchor of the room, returning its token
+func (c *apiClient) createAnchor(ctx context.Context, roomID string) (string, error) {
+	var result struct {
+		Token string `json:"token"`
+	}
+	resp, err := c.users.R().
+		SetContext(ctx).
+		SetBody(map[string]any{"role": constants.UserRoleAnchor}).
+		SetResult(&result).
+		Post("/api/rooms/" + url.PathEscape(roomID) + "/users")
+	if err != nil {
+		return "", fmt.Errorf("failed to create user: %w", err)
+	}
+	if resp.IsError() {
+		return "", fmt.Errorf("failed to create user: %s", resp.Status())
+	}
+	return result.Token, nil
+}
+
+// listenerToken returns a token of the room the HLS key server accepts
+func (c *apiClient) listenerToken(ctx context.Context, roomID string) (string, error) {
+	var result struct {
+		Token string `json:"token"`
+	}
+	resp, err := c.hls.R().
+		SetContext(ctx).
+		SetBody(map[string]any{"roomId": roomID}).
+		SetResult(&result).
+		Post("/api/token")
+	if err != nil {
+		return "", fmt.Errorf("failed to get listener token: %w", err)
+	}
+	if resp.IsError() {
+		return "", fmt.Errorf("failed to get listener token: %s", resp.Status())
+	}
+	return result.Token, nil
+}
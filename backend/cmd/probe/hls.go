@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	playlistName      = "stream.m3u8"
+	playlistPoll      = time.Second
+	tsPacketSize      = 188
+	tsSyncByte        = 0x47
+	hlsMethodAES128   = "AES-128"
+	hlsMethodNone     = "NONE"
+	hlsRequestTimeout = 10 * time.Second
+)
+
+var maxVolumeRe = regexp.MustCompile(`max_volume:\s*(-?[0-9.]+|-inf) dB`)
+
+// segmentRef is a segment listed in a playlist with the key it is encrypted with
+type segmentRef struct {
+	uri      string
+	sequence uint64
+	method   string
+	keyURI   string
+	iv       []byte // nil to derive from the sequence
+}
+
+// hlsChecker verifies the HLS output of a room the way a listener plays it
+type hlsChecker struct {
+	client    *resty.Client
+	baseURL   string
+	minVolume float64
+}
+
+func newHLSChecker(baseURL string, minVolume float64) *hlsChecker {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &hlsChecker{
+		client:    resty.New().SetTimeout(hlsRequestTimeout),
+		baseURL:   baseURL,
+		minVolume: minVolume,
+	}
+}
+
+// check fetches the latest segment of the room once listed, decrypts it with the key the
+// listener token gets and verifies it carries audio loud enough
+func (h *hlsChecker) check(ctx context.Context, roomID, token string) error {
+	playlistURL, err := url.Parse(h.baseURL + url.PathEscape(roomID) + "/" + playlistName)
+	if err != nil {
+		return fmt.Errorf("invalid playlist URL: %w", err)
+	}
+
+	var seg *segmentRef
+	for {
+		if seg, err = h.latestSegment(ctx, playlistURL.String()); err != nil {
+			return err
+		}
+		if seg != nil {
+			break
+		}
+		select {
+		case <-time.After(playlistPoll):
+		case <-ctx.Done():
+			return fmt.Errorf("no segment listed: %w", ctx.Err())
+		}
+	}
+
+	data, err := h.get(ctx, resolve(playlistURL, seg.uri), "")
+	if err != nil {
+		return fmt.Errorf("failed to fetch segment: %w", err)
+	}
+	if seg.method == hlsMethodAES128 {
+		key, err := h.get(ctx, resolve(playlistURL, seg.keyURI), token)
+		if err != nil {
+			return fmt.Errorf("failed to fetch key: %w", err)
+		}
+		if data, err = decryptSegment(data, key, seg.iv, seg.sequence); err != nil {
+			return err
+		}
+	}
+	if len(data) == 0 || len(data)%tsPacketSize != 0 || data[0] != tsSyncByte {
+		return errors.New("segment is not MPEG-TS, wrong key")
+	}
+
+	volume, err := maxVolume(ctx, data)
+	if err != nil {
+		return err
+	}
+	if volume < h.minVolume {
+		return fmt.Errorf("segment is silent, max volume %.1f dB", volume)
+	}
+	return nil
+}
+
+// latestSegment returns the last segment of the playlist, nil while none is listed
+func (h *hlsChecker) latestSegment(ctx context.Context, playlistURL string) (*segmentRef, error) {
+	resp, err := h.client.R().SetContext(ctx).Get(playlistURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch playlist: %w", err)
+	}
+	if resp.StatusCode() == http.StatusNotFound {
+		//nolint:nilnil
+		return nil, nil
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("failed to fetch playlist: %s", resp.Status())
+	}
+	return parsePlaylist(resp.Body())
+}
+
+func (h *hlsChecker) get(ctx context.Context, u, token string) ([]byte, error) {
+	req := h.client.R().SetContext(ctx)
+	if token != "" {
+		req.SetAuthToken(token)
+	}
+	resp, err := req.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, errors.New(resp.Status())
+	}
+	return resp.Body(), nil
+}
+
+// parsePlaylist returns the last segment of a media playlist, nil when it lists none
+func parsePlaylist(body []byte) (*segmentRef, error) {
+	var last *segmentRef
+	var sequence uint64
+	method, keyURI := hlsMethodNone, ""
+	var iv []byte
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			n, err := strconv.ParseUint(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid media sequence: %w", err)
+			}
+			sequence = n
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-KEY:"))
+			method, keyURI, iv = attrs["METHOD"], attrs["URI"], nil
+			if v := attrs["IV"]; v != "" {
+				b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(v, "0x"), "0X"))
+				if err != nil || len(b) != aes.BlockSize {
+					return nil, fmt.Errorf("invalid key IV %q", v)
+				}
+				iv = b
+			}
+		case strings.HasPrefix(line, "#"):
+		default:
+			last = &segmentRef{uri: line, sequence: sequence, method: method, keyURI: keyURI, iv: iv}
+			sequence++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read playlist: %w", err)
+	}
+	if last != nil && last.method != hlsMethodNone && last.method != hlsMethodAES128 {
+		return nil, fmt.Errorf("unsupported key method %s", last.method)
+	}
+	return last, nil
+}
+
+// parseAttributes parses an attribute list, quoted values may hold commas
+func parseAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for s != "" {
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+			rest = "," + rest
+		}
+		attrs[strings.TrimSpace(name)] = value
+		s = strings.TrimPrefix(rest, ",")
+	}
+	return attrs
+}
+
+// decryptSegment decrypts an AES-128 segment, without IV the IV is the media sequence number
+func decryptSegment(data, key, iv []byte, sequence uint64) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("segment is not a whole number of AES blocks")
+	}
+	if iv == nil {
+		iv = make([]byte, aes.BlockSize)
+		binary.BigEndian.PutUint64(iv[8:], sequence)
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(out) {
+		return nil, errors.New("invalid padding, wrong key")
+	}
+	return out[:len(out)-pad], nil
+}
+
+// maxVolume measures the max volume of the MPEG-TS segment in dB with ffmpeg
+func maxVolume(ctx context.Context, segment []byte) (float64, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats",
+		"-f", "mpegts", "-i", "pipe:0",
+		"-af", "volumedetect", "-f", "null", "-")
+	cmd.Stdin = bytes.NewReader(segment)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("failed to analyze segment: %w", err)
+	}
+	m := maxVolumeRe.FindSubmatch(stderr.Bytes())
+	if m == nil {
+		return 0, errors.New("segment has no audio")
+	}
+	if string(m[1]) == "-inf" {
+		return 0, errors.New("segment is silent")
+	}
+	return strconv.ParseFloat(string(m[1]), 64)
+}
+
+func resolve(base *url.URL, ref string) string {
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
@@ -0,0 +1,112 @@
+// Command probe checks the platform end to end: it periodically creates a room, joins it as a
+// synthetic WebRTC anchor publishing a tone, verifies the HLS segments of the room decrypt and
+// carry audio, then tears the room down, exporting the availability of its region.
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/config"
+	"github.com/imtaco/audio-rtc-exp/internal/httputil"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/otel"
+	"github.com/imtaco/audio-rtc-exp/internal/workflow"
+)
+
+type Config struct {
+	App  config.App  `mapstructure:"app"`
+	Otel otel.Config `mapstructure:"otel"`
+
+	// Region labels the availability metric, one probe runs per region
+	Region   string        `mapstructure:"region"`
+	Interval time.Duration `mapstructure:"interval"`
+	// Timeout bounds a whole probe run, teardown included
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	RoomsURL   string `mapstructure:"rooms_url"`
+	RoomsToken string `mapstructure:"rooms_token"` // needs the create and delete scopes
+	UsersURL   string `mapstructure:"users_url"`
+	UsersToken string `mapstructure:"users_token"`
+	WSURL      string `mapstructure:"ws_url"`
+	// HLSURL is the base URL of room playlists, {HLSURL}{roomId}/stream.m3u8
+	HLSURL      string   `mapstructure:"hls_url"`
+	HLSTokenURL string   `mapstructure:"hls_token_url"` // token server minting key tokens
+	ICEServers  []string `mapstructure:"ice_servers"`
+
+	// ToneDuration is how long the tone is published, long enough for mixers to write segments
+	ToneDuration time.Duration `mapstructure:"tone_duration"`
+	ToneFreq     int           `mapstructure:"tone_freq"`
+	// MinVolume is the max volume in dB a segment must reach to count as carrying audio
+	MinVolume float64 `mapstructure:"min_volume"`
+}
+
+func loadConfig() (*Config, error) {
+	return config.Load(&Config{}, func(v *viper.Viper) {
+		v.SetDefault("region", "default")
+		v.SetDefault("interval", time.Minute)
+		v.SetDefault("timeout", 45*time.Second)
+		v.SetDefault("rooms_url", "http://localhost:3000")
+		v.SetDefault("rooms_token", "")
+		v.SetDefault("users_url", "http://localhost:3001")
+		v.SetDefault("users_token", "")
+		v.SetDefault("ws_url", "ws://localhost:8081/ws")
+		v.SetDefault("hls_url", "http://localhost:8080/hls/")
+		v.SetDefault("hls_token_url", "http://localhost:3100")
+		v.SetDefault("ice_servers", []string{})
+		v.SetDefault("tone_duration", 20*time.Second)
+		v.SetDefault("tone_freq", 440)
+		v.SetDefault("min_volume", -40.0)
+
+		config.Setup(v, "app")
+		otel.Setup(v, "otel")
+	})
+}
+
+func main() {
+	config, err := loadConfig()
+	if err != nil {
+		log.Fatal("Failed to load configuration", err)
+	}
+
+	logger, err := log.NewLogger(config.App.LogConfigFile)
+	if err != nil {
+		log.Fatal("Failed to create logger", err)
+	}
+	defer func() { _ = logger.Sync() }()
+
+	ctx := context.Background()
+
+	otelShutdown, err := otel.Init(ctx, &config.Otel, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize OTEL provider", log.Error(err))
+	}
+
+	logger.Info("Starting probe",
+		log.String("region", config.Region),
+		log.Duration("interval", config.Interval),
+		log.String("roomsUrl", config.RoomsURL),
+		log.String("wsUrl", config.WSURL),
+		log.String("hlsUrl", config.HLSURL))
+
+	prober := newProber(config, logger.Module("Probe"))
+
+	lc := workflow.NewLifecycle(logger.Module("Lifecycle"))
+	lc.Add(workflow.Component{Name: "otel", Stop: otelShutdown})
+	lc.Add(workflow.Component{
+		Name:  "probe",
+		Start: prober.Start,
+		Stop:  workflow.Stopper(prober.Stop),
+	})
+	if adminServer := httputil.NewAdminServer(config.App.AdminAddr, "probe", logger); adminServer != nil {
+		lc.Add(adminServer.Component("admin", logger))
+	}
+
+	if err := lc.Start(ctx); err != nil {
+		logger.Fatal("Failed to start probe", log.Error(err))
+	}
+
+	lc.WaitGracefulShutdown(ctx, config.App.ShutdownTimeout)
+}
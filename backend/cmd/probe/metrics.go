@@ -0,0 +1,29 @@
+package main
+
+import (
+	"go.opentelemetry.io/otel/metric"
+
+	intotel "github.com/imtaco/audio-rtc-exp/internal/otel"
+)
+
+var (
+	probeRuns         metric.Int64Counter
+	probeAvailability metric.Int64Gauge
+	probeDuration     metric.Float64Histogram
+	probeStepDuration metric.Float64Histogram
+)
+
+func init() {
+	f := intotel.NewFactory("cmd.probe", intotel.PrefixProbe)
+
+	f.Int64Counter(&probeRuns, "runs",
+		metric.WithDescription("End-to-end probe runs by region, result and failed step"))
+	f.Int64Gauge(&probeAvailability, "availability",
+		metric.WithDescription("Result of the latest end-to-end probe of the region, 1 when it passed"))
+	f.Float64Histogram(&probeDuration, "duration",
+		metric.WithDescription("Duration of end-to-end probe runs, teardown excluded"),
+		metric.WithUnit("s"))
+	f.Float64Histogram(&probeStepDuration, "step.duration",
+		metric.WithDescription("Duration of the steps of end-to-end probe runs"),
+		metric.WithUnit("s"))
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/synthanchor"
+)
+
+const (
+	roomIDPrefix    = "probe-"
+	teardownTimeout = 10 * time.Second
+	// joinRetry spaces joins while the room is not on air yet, placement assigns it a Janus
+	// instance and a mixer shortly after creation
+	joinRetry = time.Second
+)
+
+// Steps of a probe run, the failed step labels failed runs
+const (
+	stepCreateRoom = "create_room"
+	stepCreateUser = "create_user"
+	stepConnect    = "connect"
+	stepJoin       = "join"
+	stepPublish    = "publish"
+	stepTone       = "tone"
+	stepHLS        = "hls"
+)
+
+// stepError is a failed step of a probe run
+type stepError struct {
+	step string
+	err  error
+}
+
+func (e *stepError) Error() string {
+	return e.step + ": " + e.err.Error()
+}
+
+func (e *stepError) Unwrap() error {
+	return e.err
+}
+
+// prober runs a probe every interval, one at a time
+type prober struct {
+	cfg    *Config
+	api    *apiClient
+	hls    *hlsChecker
+	region attribute.KeyValue
+	cancel context.CancelFunc
+	done   chan struct{}
+	logger *log.Logger
+}
+
+func newProber(cfg *Config, logger *log.Logger) *prober {
+	return &prober{
+		cfg:    cfg,
+		api:    newAPIClient(cfg),
+		hls:    newHLSChecker(cfg.HLSURL, cfg.MinVolume),
+		region: attribute.String("region", cfg.Region),
+		done:   make(chan struct{}),
+		logger: logger,
+	}
+}
+
+func (p *prober) Start(ctx context.Context) error {
+	ctx, p.cancel = context.WithCancel(ctx)
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			p.runOnce(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *prober) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+		<-p.done
+	}
+}
+
+// runOnce runs a probe and records its result
+func (p *prober) runOnce(ctx context.Context) {
+	roomID, err := newRoomID()
+	if err != nil {
+		p.logger.Error("Failed to generate room ID", log.Error(err))
+		return
+	}
+
+	start := time.Now()
+	err = p.run(ctx, roomID)
+	elapsed := time.Since(start)
+	if ctx.Err() != nil {
+		// stopped, not a failure of the platform
+		return
+	}
+
+	result, step := "success", ""
+	var available int64 = 1
+	if err != nil {
+		result, available = "failure", 0
+		var se *stepError
+		if errors.As(err, &se) {
+			step = se.step
+		}
+		p.logger.Warn("Probe failed",
+			log.String("roomId", roomID),
+			log.Duration("elapsed", elapsed),
+			log.Error(err))
+	} else {
+		p.logger.Info("Probe passed", log.String("roomId", roomID), log.Duration("elapsed", elapsed))
+	}
+	probeRuns.Add(ctx, 1, metric.WithAttributes(p.region,
+		attribute.String("result", result),
+		attribute.String("step", step)))
+	probeAvailability.Record(ctx, available, metric.WithAttributes(p.region))
+	probeDuration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(p.region, attribute.String("result", result)))
+}
+
+// run creates the room, publishes the tone as its anchor and checks the HLS output, the room
+// is deleted whatever the result
+func (p *prober) run(ctx context.Context, roomID string) error {
+	runCtx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	var pin string
+	if err := p.step(runCtx, stepCreateRoom, func(ctx context.Context) (err error) {
+		pin, err = p.api.createRoom(ctx, roomID)
+		return err
+	}); err != nil {
+		return err
+	}
+	defer p.teardownRoom(ctx, roomID)
+
+	var token string
+	if err := p.step(runCtx, stepCreateUser, func(ctx context.Context) (err error) {
+		token, err = p.api.createAnchor(ctx, roomID)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	var a *synthanchor.Anchor
+	if err := p.step(runCtx, stepConnect, func(ctx context.Context) (err error) {
+		a, err = synthanchor.Dial(ctx, p.cfg.WSURL, token, p.logger)
+		return err
+	}); err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), teardownTimeout)
+		defer cancel()
+		a.Close(ctx)
+	}()
+
+	if err := p.step(runCtx, stepJoin, func(ctx context.Context) error {
+		return p.join(ctx, a, pin)
+	}); err != nil {
+		return err
+	}
+	if err := p.step(runCtx, stepPublish, func(ctx context.Context) error {
+		return a.Publish(ctx, p.cfg.ICEServers)
+	}); err != nil {
+		return err
+	}
+	if err := p.step(runCtx, stepTone, func(ctx context.Context) error {
+		return a.PlayTone(ctx, p.cfg.ToneFreq, p.cfg.ToneDuration)
+	}); err != nil {
+		return err
+	}
+	return p.step(runCtx, stepHLS, func(ctx context.Context) error {
+		listenerToken, err := p.api.listenerToken(ctx, roomID)
+		if err != nil {
+			return err
+		}
+		return p.hls.check(ctx, roomID, listenerToken)
+	})
+}
+
+// join retries while the room is not on air yet, until the run times out
+func (p *prober) join(ctx context.Context, a *synthanchor.Anchor, pin string) error {
+	for {
+		err := a.Join(ctx, pin)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-time.After(joinRetry):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// step runs a step of the probe, timing it
+func (p *prober) step(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	err := fn(ctx)
+	probeStepDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(p.region, attribute.String("step", name)))
+	if err != nil {
+		return &stepError{step: name, err: err}
+	}
+	return nil
+}
+
+// teardownRoom deletes the room of the run, a room left behind is purged by rooms housekeeping
+func (p *prober) teardownRoom(ctx context.Context, roomID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), teardownTimeout)
+	defer cancel()
+	if err := p.api.deleteRoom(ctx, roomID); err != nil {
+		p.logger.Error("Failed to delete probe room", log.String("roomId", roomID), log.Error(err))
+	}
+}
+
+// newRoomID returns a room ID telling probe rooms apart from the others
+func newRoomID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to read random: %w", err)
+	}
+	return roomIDPrefix + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,118 @@
+// Command statebackup exports the room and module state of etcd to a snapshot file and imports
+// it into another cluster, for disaster recovery and DR drills.
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/config"
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const (
+	modeExport = "export"
+	modeImport = "import"
+)
+
+type Config struct {
+	App               config.App  `mapstructure:"app"`
+	Etcd              etcd.Config `mapstructure:"etcd"`
+	EtcdPrefixRooms   string      `mapstructure:"etcd_prefix_rooms"`
+	EtcdPrefixJanuses string      `mapstructure:"etcd_prefix_januses"`
+	EtcdPrefixMixers  string      `mapstructure:"etcd_prefix_mixers"`
+	Mode              string      `mapstructure:"mode"`
+	File              string      `mapstructure:"file"`
+	DryRun            bool        `mapstructure:"dry_run"`
+	Overwrite         bool        `mapstructure:"overwrite"`
+}
+
+func loadConfig() (*Config, error) {
+	return config.Load(&Config{}, func(v *viper.Viper) {
+		v.SetDefault("etcd_prefix_rooms", "/rooms/")
+		v.SetDefault("etcd_prefix_januses", "/januses/")
+		v.SetDefault("etcd_prefix_mixers", "/mixers/")
+		v.SetDefault("mode", modeExport)
+		v.SetDefault("file", "state-snapshot.json")
+		v.SetDefault("dry_run", false)
+		v.SetDefault("overwrite", false)
+
+		config.Setup(v, "app")
+		etcd.Setup(v, "etcd")
+	})
+}
+
+func main() {
+	config, err := loadConfig()
+	if err != nil {
+		log.Fatal("Failed to load configuration", err)
+	}
+
+	logger, err := log.NewLogger(config.App.LogConfigFile)
+	if err != nil {
+		log.Fatal("Failed to create logger", err)
+	}
+	defer func() { _ = logger.Sync() }()
+
+	etcdClient, err := etcd.NewClient(&config.Etcd)
+	if err != nil {
+		logger.Fatal("Failed to create etcd client", log.Error(err))
+	}
+	defer etcdClient.Close()
+
+	b := &backup{
+		kv:        etcdClient,
+		dryRun:    config.DryRun,
+		overwrite: config.Overwrite,
+		logger:    logger,
+	}
+
+	ctx := context.Background()
+	switch config.Mode {
+	case modeExport:
+		prefixes := []string{config.EtcdPrefixRooms, config.EtcdPrefixJanuses, config.EtcdPrefixMixers}
+		snap, err := b.export(ctx, prefixes)
+		if err != nil {
+			logger.Fatal("Failed to export state", log.Error(err))
+		}
+		if err := writeSnapshot(config.File, snap); err != nil {
+			logger.Fatal("Failed to save snapshot", log.Error(err))
+		}
+		logger.Info("Exported state",
+			log.String("file", config.File),
+			log.Int64("revision", snap.Revision),
+			log.Int("keys", len(snap.Kvs)))
+
+	case modeImport:
+		snap, err := readSnapshot(config.File)
+		if err != nil {
+			logger.Fatal("Failed to load snapshot", log.Error(err))
+		}
+		logger.Info("Importing state",
+			log.String("file", config.File),
+			log.Int64("revision", snap.Revision),
+			log.Time("createdAt", snap.CreatedAt),
+			log.Bool("dryRun", config.DryRun),
+			log.Bool("overwrite", config.Overwrite))
+
+		res, err := b.restore(ctx, snap)
+		if err != nil {
+			logger.Error("Failed to import state", log.Error(err))
+		}
+		logger.Info("Imported state",
+			log.Int("created", res.Created),
+			log.Int("updated", res.Updated),
+			log.Int("unchanged", res.Unchanged),
+			log.Int("conflicts", res.Conflicts),
+			log.Int("leased", res.Leased),
+			log.Int("skipped", res.Skipped))
+		if err != nil {
+			logger.Fatal("Import stopped before the end of the snapshot")
+		}
+
+	default:
+		logger.Fatal("Unknown mode", log.String("mode", config.Mode))
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// etcdKV reads the keys to export and writes the imported ones in transactions
+type etcdKV interface {
+	etcd.KV
+	etcd.Tx
+}
+
+// snapshot is the file format of an export, keys are ordered by the revision they were
+// last written at so an import replays them in the order watchers saw them
+type snapshot struct {
+	CreatedAt time.Time     `json:"createdAt"`
+	Revision  int64         `json:"revision"`
+	Prefixes  []string      `json:"prefixes"`
+	Kvs       []snapshotKey `json:"kvs"`
+}
+
+type snapshotKey struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	ModRevision int64  `json:"modRevision"`
+	// Leased keys are heartbeats of running modules, they come back with the modules
+	Leased bool `json:"leased,omitempty"`
+}
+
+type result struct {
+	Created   int
+	Updated   int
+	Unchanged int
+	Conflicts int // existing keys holding another value, kept unless overwriting
+	Leased    int // heartbeats not imported
+	Skipped   int // written concurrently during the import
+}
+
+type backup struct {
+	kv        etcdKV
+	dryRun    bool
+	overwrite bool
+	logger    *log.Logger
+}
+
+// export reads the prefixes at a single revision so the snapshot is consistent across them
+func (b *backup) export(ctx context.Context, prefixes []string) (*snapshot, error) {
+	snap := &snapshot{
+		CreatedAt: time.Now().UTC(),
+		Prefixes:  prefixes,
+		Kvs:       []snapshotKey{},
+	}
+	for _, prefix := range prefixes {
+		opts := []clientv3.OpOption{clientv3.WithPrefix()}
+		if snap.Revision != 0 {
+			opts = append(opts, clientv3.WithRev(snap.Revision))
+		}
+		resp, err := b.kv.Get(ctx, prefix, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		if snap.Revision == 0 {
+			snap.Revision = resp.Header.Revision
+		}
+		for _, kv := range resp.Kvs {
+			snap.Kvs = append(snap.Kvs, snapshotKey{
+				Key:         string(kv.Key),
+				Value:       string(kv.Value),
+				ModRevision: kv.ModRevision,
+				Leased:      kv.Lease != 0,
+			})
+		}
+	}
+	sort.SliceStable(snap.Kvs, func(i, j int) bool {
+		return snap.Kvs[i].ModRevision < snap.Kvs[j].ModRevision
+	})
+	return snap, nil
+}
+
+// restore writes the snapshot keys in revision order, keys missing in the cluster are created
+// and existing ones holding another value are only replaced when overwriting. A dry run logs
+// the diff without writing
+func (b *backup) restore(ctx context.Context, snap *snapshot) (*result, error) {
+	res := &result{}
+	for _, sk := range snap.Kvs {
+		if sk.Leased {
+			res.Leased++
+			continue
+		}
+
+		resp, err := b.kv.Get(ctx, sk.Key)
+		if err != nil {
+			return res, fmt.Errorf("failed to get %s: %w", sk.Key, err)
+		}
+
+		var cmp clientv3.Cmp
+		switch {
+		case len(resp.Kvs) == 0:
+			if b.dryRun {
+				b.logger.Info("Would create key", log.String("key", sk.Key), log.String("value", sk.Value))
+				res.Created++
+				continue
+			}
+			cmp = clientv3.Compare(clientv3.CreateRevision(sk.Key), "=", 0)
+
+		case bytes.Equal(resp.Kvs[0].Value, []byte(sk.Value)):
+			res.Unchanged++
+			continue
+
+		default:
+			if !b.overwrite {
+				b.logger.Warn("Key holds another value, kept",
+					log.String("key", sk.Key),
+					log.String("current", string(resp.Kvs[0].Value)),
+					log.String("snapshot", sk.Value))
+				res.Conflicts++
+				continue
+			}
+			if b.dryRun {
+				b.logger.Info("Would update key",
+					log.String("key", sk.Key),
+					log.String("current", string(resp.Kvs[0].Value)),
+					log.String("snapshot", sk.Value))
+				res.Updated++
+				continue
+			}
+			cmp = clientv3.Compare(clientv3.ModRevision(sk.Key), "=", resp.Kvs[0].ModRevision)
+		}
+
+		txnResp, err := b.kv.Txn(ctx).
+			If(cmp).
+			Then(clientv3.OpPut(sk.Key, sk.Value)).
+			Commit()
+		if err != nil {
+			return res, fmt.Errorf("failed to import %s: %w", sk.Key, err)
+		}
+		if !txnResp.Succeeded {
+			b.logger.Info("Key changed during import, skipped", log.String("key", sk.Key))
+			res.Skipped++
+			continue
+		}
+		if len(resp.Kvs) == 0 {
+			res.Created++
+		} else {
+			res.Updated++
+		}
+	}
+	return res, nil
+}
+
+func writeSnapshot(file string, snap *snapshot) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	// room pins and keys are in the snapshot
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+func readSnapshot(file string) (*snapshot, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+	sort.SliceStable(snap.Kvs, func(i, j int) bool {
+		return snap.Kvs[i].ModRevision < snap.Kvs[j].ModRevision
+	})
+	return &snap, nil
+}
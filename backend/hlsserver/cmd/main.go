@@ -2,19 +2,24 @@ package main
 
 import (
 	"context"
-	"errors"
-	"net/http"
 
+	goredis "github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 
 	"github.com/imtaco/audio-rtc-exp/hlsserver/transport"
 	"github.com/imtaco/audio-rtc-exp/hlsserver/watcher"
 	"github.com/imtaco/audio-rtc-exp/internal/config"
+	"github.com/imtaco/audio-rtc-exp/internal/config/secret"
 	"github.com/imtaco/audio-rtc-exp/internal/etcd"
 	"github.com/imtaco/audio-rtc-exp/internal/httputil"
 	"github.com/imtaco/audio-rtc-exp/internal/jwt"
+	"github.com/imtaco/audio-rtc-exp/internal/listeners"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	"github.com/imtaco/audio-rtc-exp/internal/otel"
+	"github.com/imtaco/audio-rtc-exp/internal/redis"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/urlsign"
+	"github.com/imtaco/audio-rtc-exp/internal/usage"
 	"github.com/imtaco/audio-rtc-exp/internal/workflow"
 )
 
@@ -28,8 +33,24 @@ type Config struct {
 	EnableTokenServer bool            `mapstructure:"enable_token_server"`
 	EnableKeyServer   bool            `mapstructure:"enable_key_server"`
 	EnableM3U8Server  bool            `mapstructure:"enable_m3u8_server"`
-	JWTSecret         string          `mapstructure:"jwt_secret"`
+	JWT               jwt.Config      `mapstructure:"jwt"`
+	Secrets           secret.Config   `mapstructure:"secrets"`
 	EtcdPrefixRooms   string          `mapstructure:"etcd_prefix_rooms"`
+	HLSDir            string          `mapstructure:"hls_dir"`
+	HLSSegmentBaseURL string          `mapstructure:"hls_segment_base_url"`
+	HLSURLSecret      string          `mapstructure:"hls_url_secret"`
+
+	RedisUserReqStream   string                 `mapstructure:"redis_user_req_stream"`
+	RedisUserReplyStream string                 `mapstructure:"redis_user_reply_stream"`
+	UserRPC              streamrpc.ClientConfig `mapstructure:"user_rpc"`
+
+	Entitlement transport.EntitlementConfig `mapstructure:"entitlement"`
+	Static      transport.StaticConfig      `mapstructure:"static"`
+	DeepLink    transport.DeepLinkConfig    `mapstructure:"deep_link"`
+	NowPlaying  transport.NowPlayingConfig  `mapstructure:"now_playing"`
+	Listeners   listeners.Config            `mapstructure:"listeners"`
+	Usage       usage.Config                `mapstructure:"usage"`
+	Redis       redis.Config                `mapstructure:"redis"`
 }
 
 func loadConfig() (*Config, error) {
@@ -37,15 +58,29 @@ func loadConfig() (*Config, error) {
 		v.SetDefault("enable_token_server", true)
 		v.SetDefault("enable_key_server", true)
 		v.SetDefault("enable_m3u8_server", false)
-		v.SetDefault("jwt_secret", "your-secret-key-change-in-production")
 		v.SetDefault("etcd_prefix_rooms", "/rooms/")
+		v.SetDefault("hls_dir", "/hls")
+		v.SetDefault("hls_segment_base_url", "http://localhost:8080/hls/")
+		v.SetDefault("hls_url_secret", "")        // must match rooms, empty disables signed URLs
+		v.SetDefault("redis_user_req_stream", "") // empty leaves speakers out of now playing
+		v.SetDefault("redis_user_reply_stream", "rtcus:user-status-reply-stream")
 
 		config.Setup(v, "app")
+		jwt.Setup(v, "jwt")
+		secret.Setup(v, "secrets")
 		etcd.Setup(v, "etcd")
 		otel.Setup(v, "otel")
 		httputil.Setup(v, "token_server_http")
 		httputil.Setup(v, "key_server_http")
 		httputil.Setup(v, "m3u8_server_http")
+		transport.SetupEntitlement(v, "entitlement")
+		transport.SetupStatic(v, "static")
+		transport.SetupDeepLink(v, "deep_link")
+		transport.SetupNowPlaying(v, "now_playing")
+		streamrpc.Setup(v, "user_rpc")
+		listeners.Setup(v, "listeners")
+		usage.Setup(v, "usage")
+		redis.Setup(v, "redis")
 
 		// override default addrs to ease testing
 		v.SetDefault("token_server_http.addr", "0.0.0.0:3100")
@@ -80,15 +115,40 @@ func main() {
 		log.Bool("m3u8ServerEnabled", config.EnableM3U8Server),
 		log.String("tokenServerAddr", config.TokenServerHTTP.Addr),
 		log.String("keyServerAddr", config.KeyServerHTTP.Addr),
-		log.String("m3u8ServerAddr", config.M3U8ServerHTTP.Addr))
+		log.String("m3u8ServerAddr", config.M3U8ServerHTTP.Addr),
+		log.Bool("hlsUrlSigning", config.HLSURLSecret != ""),
+		log.Bool("entitlementCheck", config.Entitlement.URL != ""),
+		log.Bool("deepLinks", config.DeepLink.ListenURL != ""),
+		log.Bool("listenerCounting", config.Listeners.Enabled),
+		log.Bool("usageAccounting", config.Usage.Enabled),
+		log.Bool("nowPlaying", config.NowPlaying.Enabled))
 
 	etcdClient, err := etcd.NewClient(&config.Etcd)
 	if err != nil {
 		logger.Fatal("Failed to create etcd client", log.Error(err))
 	}
-	defer etcdClient.Close()
+	if config.App.LogLevelsKey != "" {
+		go etcd.WatchLogLevels(ctx, etcdClient, config.App.LogLevelsKey, logger.Levels(), logger.Module("LogLevels"))
+	}
 
-	jwtAuth := jwt.NewAuth(config.JWTSecret)
+	// secrets are resolved from their references, kept out of the config
+	secrets := secret.NewResolver(&config.Secrets, logger.Module("Secrets"))
+	jwtSecret, err := secrets.Get(ctx, config.JWT.Secret)
+	if err != nil {
+		logger.Fatal("Failed to resolve JWT secret", log.Error(err))
+	}
+	jwtAuth := jwt.NewAuthWithSecret(&config.JWT, jwtSecret)
+
+	// verifies signatures, ttl is decided by the signer in rooms. Deep links sign with their own expiry
+	var urlSigner *urlsign.Signer
+	if config.HLSURLSecret != "" {
+		// playlists are the entry point of signed URLs, without the m3u8 server they would be
+		// served unchecked by the static HLS server
+		if !config.EnableM3U8Server {
+			logger.Fatal("Signed HLS URLs require the m3u8 server, set ENABLE_M3U8_SERVER")
+		}
+		urlSigner = urlsign.New(config.HLSURLSecret, 0)
+	}
 
 	roomWatcher := watcher.NewRoomWatcher(
 		etcdClient,
@@ -96,59 +156,134 @@ func main() {
 		logger.Module("RoomWatcher"),
 	)
 
-	if err := roomWatcher.Start(ctx); err != nil {
-		logger.Fatal("Failed to start room watcher", log.Error(err))
+	lc := workflow.NewLifecycle(logger.Module("Lifecycle"))
+	lc.Add(workflow.Component{Name: "otel", Stop: otelShutdown})
+	lc.Add(workflow.Component{
+		Name:  "secrets",
+		Start: secrets.Start,
+		Stop:  workflow.Stopper(secrets.Stop),
+	})
+	lc.Add(workflow.Component{
+		Name: "etcd",
+		Stop: workflow.Closer(etcdClient.Close),
+	})
+	lc.Add(workflow.Component{
+		Name:      "roomWatcher",
+		DependsOn: []string{"etcd"},
+		Start:     roomWatcher.Start,
+		Stop:      workflow.Closer(roomWatcher.Stop),
+	})
+
+	// Redis is only used for listener counts, usage and room users
+	var redisClient *goredis.Client
+	if config.Listeners.Enabled || config.Usage.Enabled || (config.NowPlaying.Enabled && config.RedisUserReqStream != "") {
+		redisClient = redis.NewClient(&config.Redis)
+		if err := redis.Ping(redisClient); err != nil {
+			logger.Fatal("Failed to connect to Redis", log.Error(err))
+		}
+		lc.Add(workflow.Component{Name: "redis", Stop: workflow.Closer(redisClient.Close)})
 	}
 
-	tokenRouter := transport.NewTokenRouter(roomWatcher, jwtAuth, logger.Module("TokenRouter"))
-	keyRouter := transport.NewKeyRouter(roomWatcher, jwtAuth, logger.Module("KeyRouter"))
+	// listeners are counted in Redis, shared with other hlsservers and read by rooms
+	var listenerTracker transport.ListenerTracker
+	var listenerEstimator transport.ListenerEstimator
+	if config.Listeners.Enabled {
+		tracker, err := listeners.NewTracker(redisClient, &config.Listeners, logger.Module("Listeners"))
+		if err != nil {
+			logger.Fatal("Failed to create listener tracker", log.Error(err))
+		}
+		lc.Add(workflow.Component{
+			Name:      "listeners",
+			DependsOn: []string{"redis"},
+			Start:     tracker.Start,
+			Stop:      workflow.Closer(tracker.Stop),
+		})
+		listenerTracker = tracker
+		listenerEstimator = listeners.NewCounter(redisClient, &config.Listeners)
+	}
 
-	var tokenServer *httputil.Server
-	var keyServer *httputil.Server
+	// bytes served are accounted per room and hour in Redis, read by rooms for billing
+	var usageRecorder transport.UsageRecorder
+	if config.Usage.Enabled {
+		recorder := usage.NewRecorder(redisClient, &config.Usage, logger.Module("Usage"))
+		lc.Add(workflow.Component{
+			Name:      "usage",
+			DependsOn: []string{"redis"},
+			Start:     recorder.Start,
+			Stop:      workflow.Closer(recorder.Stop),
+		})
+		usageRecorder = recorder
+	}
 
-	// Start servers based on configuration
-	if config.EnableTokenServer {
-		tokenServer = httputil.NewServer(&config.TokenServerHTTP, tokenRouter.Handler())
-		go func() {
-			logger.Info("Starting token server", log.String("addr", config.TokenServerHTTP.Addr))
-			if err := tokenServer.Listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-				logger.Fatal("Failed to start token server", log.Error(err))
-			}
-		}()
+	// Room users are read from the users controller for the speakers of now playing
+	var roomUsers transport.RoomUsersReader
+	if config.NowPlaying.Enabled && config.RedisUserReqStream != "" {
+		userRPC, err := streamrpc.NewClient(
+			redisClient,
+			config.RedisUserReqStream,
+			config.RedisUserReplyStream,
+			&config.UserRPC,
+			logger.Module("UserRPC"),
+		)
+		if err != nil {
+			logger.Fatal("Failed to create users RPC client", log.Error(err))
+		}
+		roomUsers = transport.NewRoomUsersClient(userRPC)
+		lc.Add(workflow.Component{
+			Name:      "userRPC",
+			DependsOn: []string{"redis"},
+			Start:     userRPC.Open,
+			Stop:      workflow.Closer(userRPC.Close),
+		})
 	}
 
+	tokenRouter := transport.NewTokenRouter(
+		roomWatcher,
+		jwtAuth,
+		transport.NewEntitlementChecker(&config.Entitlement),
+		config.Entitlement.FailOpen,
+		transport.NewDeepLinker(&config.DeepLink, jwtAuth, urlSigner),
+		transport.NewNowPlaying(
+			&config.NowPlaying,
+			roomWatcher,
+			roomUsers,
+			listenerEstimator,
+			logger.Module("NowPlaying"),
+		),
+		logger.Module("TokenRouter"),
+	)
+
+	keyRouter := transport.NewKeyRouter(roomWatcher, jwtAuth, urlSigner, listenerTracker, usageRecorder, logger.Module("KeyRouter"))
+	m3u8Router := transport.NewM3U8Router(
+		roomWatcher,
+		config.HLSDir,
+		config.HLSSegmentBaseURL,
+		urlSigner,
+		&config.Static,
+		listenerTracker,
+		usageRecorder,
+		logger.Module("M3U8Router"),
+	)
+
+	if config.EnableTokenServer {
+		tokenServer := httputil.NewServer(&config.TokenServerHTTP, tokenRouter.Handler())
+		lc.Add(tokenServer.Component("token", logger, "roomWatcher"))
+	}
 	if config.EnableKeyServer {
-		keyServer = httputil.NewServer(&config.KeyServerHTTP, keyRouter.Handler())
-		go func() {
-			logger.Info("Starting key server", log.String("addr", config.KeyServerHTTP.Addr))
-			if err := keyServer.Listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-				logger.Fatal("Failed to start key server", log.Error(err))
-			}
-		}()
+		keyServer := httputil.NewServer(&config.KeyServerHTTP, keyRouter.Handler())
+		lc.Add(keyServer.Component("key", logger, "roomWatcher"))
 	}
-
 	if config.EnableM3U8Server {
-		logger.Info("M3U8 server enabled but not yet implemented",
-			log.String("addr", config.M3U8ServerHTTP.Addr))
+		m3u8Server := httputil.NewServer(&config.M3U8ServerHTTP, m3u8Router.Handler())
+		lc.Add(m3u8Server.Component("m3u8", logger, "roomWatcher"))
+	}
+	if adminServer := httputil.NewAdminServer(config.App.AdminAddr, "hlsserver", logger); adminServer != nil {
+		lc.Add(adminServer.Component("admin", logger))
 	}
 
-	cleanup := func(ctx context.Context) {
-		if tokenServer != nil {
-			_ = tokenServer.Shutdown(ctx)
-		}
-		if keyServer != nil {
-			_ = keyServer.Shutdown(ctx)
-		}
-
-		if err := roomWatcher.Stop(); err != nil {
-			logger.Error("Error stopping room watcher", log.Error(err))
-		}
-		if err := etcdClient.Close(); err != nil {
-			logger.Error("Failed to close etcd client", log.Error(err))
-		}
-		if err := otelShutdown(ctx); err != nil {
-			logger.Error("Failed to shutdown OTEL", log.Error(err))
-		}
+	if err := lc.Start(ctx); err != nil {
+		logger.Fatal("Failed to start HLS servers", log.Error(err))
 	}
-	workflow.WaitGracefulShutdown(ctx, logger.Module("CleanUp"), cleanup, config.App.ShutdownTimeout)
+
+	lc.WaitGracefulShutdown(ctx, config.App.ShutdownTimeout)
 }
@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/jwt"
+	"github.com/imtaco/audio-rtc-exp/internal/urlsign"
+)
+
+// Query params of the listen page carrying the playback
+const (
+	linkParamRoom     = "room"
+	linkParamPlaylist = "m3u8"
+	linkParamToken    = "token"
+)
+
+type DeepLinkConfig struct {
+	// ListenURL is the listen page opened by the links, empty disables the endpoint
+	ListenURL string `mapstructure:"listen_url"`
+	// PlaylistBaseURL is where the m3u8 server serves /hls/, room playlists are under it
+	PlaylistBaseURL string `mapstructure:"playlist_base_url"`
+	// TTL is the default expiry of a link, requests may ask for up to MaxTTL
+	TTL    time.Duration `mapstructure:"ttl"`
+	MaxTTL time.Duration `mapstructure:"max_ttl"`
+	// QRSize is the width and height of QR codes in pixels
+	QRSize int `mapstructure:"qr_size"`
+}
+
+func SetupDeepLink(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("listen_url"), "")
+	v.SetDefault(p("playlist_base_url"), "http://localhost:3102/hls/")
+	v.SetDefault(p("ttl"), 24*time.Hour)
+	v.SetDefault(p("max_ttl"), 7*24*time.Hour)
+	v.SetDefault(p("qr_size"), 256)
+}
+
+// DeepLink opens the listen page of a room with its playlist and a playback token
+type DeepLink struct {
+	URL         string    `json:"url"`
+	PlaylistURL string    `json:"playlistUrl"`
+	Token       string    `json:"token"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	// QRPNG is the link encoded as a QR code, base64 in JSON, when asked for
+	QRPNG []byte `json:"qrPng,omitempty"`
+}
+
+// DeepLinker signs listen links broadcasters share from the operator console. The playback
+// token and, when HLS URL signing is enabled, the playlist URL expire with the link
+type DeepLinker struct {
+	cfg       *DeepLinkConfig
+	jwtAuth   jwt.Auth
+	urlSigner *urlsign.Signer // optional
+	now       func() time.Time
+}
+
+// NewDeepLinker returns nil when the listen URL is not set
+func NewDeepLinker(cfg *DeepLinkConfig, jwtAuth jwt.Auth, urlSigner *urlsign.Signer) *DeepLinker {
+	if cfg.ListenURL == "" {
+		return nil
+	}
+	return &DeepLinker{
+		cfg:       cfg,
+		jwtAuth:   jwtAuth,
+		urlSigner: urlSigner,
+		now:       time.Now,
+	}
+}
+
+// ttl bounds the expiry asked for, 0 takes the default
+func (d *DeepLinker) ttl(expiresIn time.Duration) time.Duration {
+	if expiresIn <= 0 {
+		return d.cfg.TTL
+	}
+	if d.cfg.MaxTTL > 0 && expiresIn > d.cfg.MaxTTL {
+		return d.cfg.MaxTTL
+	}
+	return expiresIn
+}
+
+// Create signs a link for userID to listen to the room, with its QR code when qr is set
+func (d *DeepLinker) Create(roomID, userID string, expiresIn time.Duration, qr bool) (*DeepLink, error) {
+	ttl := d.ttl(expiresIn)
+	token, err := d.jwtAuth.SignWithTTL(userID, roomID, constants.UserRoleGuest, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	playlistURL := strings.TrimRight(d.cfg.PlaylistBaseURL, "/") + "/" + url.PathEscape(roomID) + "/" + playlistName
+	if d.urlSigner != nil {
+		playlistURL = d.urlSigner.WithTTL(ttl).SignURL(playlistURL, roomID)
+	}
+
+	link, err := url.Parse(d.cfg.ListenURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen URL: %w", err)
+	}
+	query := link.Query()
+	query.Set(linkParamRoom, roomID)
+	query.Set(linkParamPlaylist, playlistURL)
+	query.Set(linkParamToken, token)
+	link.RawQuery = query.Encode()
+
+	dl := &DeepLink{
+		URL:         link.String(),
+		PlaylistURL: playlistURL,
+		Token:       token,
+		ExpiresAt:   d.now().Add(ttl).Truncate(time.Second),
+	}
+	if qr {
+		if dl.QRPNG, err = qrcode.Encode(dl.URL, qrcode.Medium, d.cfg.QRSize); err != nil {
+			return nil, fmt.Errorf("failed to encode QR code: %w", err)
+		}
+	}
+	return dl, nil
+}
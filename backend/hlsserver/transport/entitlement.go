@@ -0,0 +1,172 @@
+package transport
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/jonboulle/clockwork"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/breaker"
+)
+
+const entitlementCacheSize = 4096
+
+// ErrEntitlementUnavailable is returned when the entitlement service can not be asked, either
+// it failed or its circuit breaker is open
+var ErrEntitlementUnavailable = errors.New("entitlement service unavailable")
+
+// EntitlementConfig of the optional check asking an external service whether the caller may
+// listen to a room before a token is minted, the caller's Authorization and Cookie headers are
+// forwarded so paywalls stay in the service owning them
+type EntitlementConfig struct {
+	// URL receives POST {"roomId"} and answers {"allowed", "userId"}, empty disables the check
+	URL   string `mapstructure:"url"`
+	Token string `mapstructure:"token"` // bearer token of the entitlement service, optional
+	// Timeout of a single check
+	Timeout time.Duration `mapstructure:"timeout"`
+	// CacheTTL of decisions per room and caller credentials, 0 disables caching
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	// FailureThreshold is consecutive failures opening the circuit breaker
+	FailureThreshold int `mapstructure:"failure_threshold"`
+	// OpenDuration is how long an open breaker fails checks before letting one through
+	OpenDuration time.Duration `mapstructure:"open_duration"`
+	// FailOpen mints tokens when the service is unavailable instead of answering 503
+	FailOpen bool `mapstructure:"fail_open"`
+}
+
+func SetupEntitlement(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("url"), "")
+	v.SetDefault(p("token"), "")
+	v.SetDefault(p("timeout"), 2*time.Second)
+	v.SetDefault(p("cache_ttl"), 30*time.Second)
+	v.SetDefault(p("failure_threshold"), 5)
+	v.SetDefault(p("open_duration"), 30*time.Second)
+	v.SetDefault(p("fail_open"), false)
+}
+
+// Entitlement is the decision of the entitlement service
+type Entitlement struct {
+	Allowed bool `json:"allowed"`
+	// UserID identifies the listener in the minted token, a random ID is used when empty
+	UserID string `json:"userId,omitempty"`
+}
+
+// EntitlementChecker decides whether the caller of a token request may access a room
+type EntitlementChecker interface {
+	Check(ctx context.Context, roomID string, header http.Header) (*Entitlement, error)
+}
+
+// forwardedHeaders carry the caller credentials to the entitlement service
+var forwardedHeaders = []string{"Authorization", "Cookie"}
+
+type httpEntitlementChecker struct {
+	url     string
+	client  *resty.Client
+	cache   *expirable.LRU[string, *Entitlement]
+	breaker *breaker.Breaker
+}
+
+// NewEntitlementChecker returns the checker of cfg, nil when it is disabled
+func NewEntitlementChecker(cfg *EntitlementConfig) EntitlementChecker {
+	return newEntitlementChecker(cfg, clockwork.NewRealClock())
+}
+
+func newEntitlementChecker(cfg *EntitlementConfig, clock clockwork.Clock) EntitlementChecker {
+	if cfg.URL == "" {
+		return nil
+	}
+
+	client := resty.New().
+		SetTimeout(cfg.Timeout).
+		SetHeader("Content-Type", "application/json")
+	if cfg.Token != "" {
+		client.SetAuthToken(cfg.Token)
+	}
+
+	c := &httpEntitlementChecker{
+		url:     cfg.URL,
+		client:  client,
+		breaker: breaker.New(cfg.FailureThreshold, cfg.OpenDuration, clock),
+	}
+	if cfg.CacheTTL > 0 {
+		c.cache = expirable.NewLRU[string, *Entitlement](entitlementCacheSize, nil, cfg.CacheTTL)
+	}
+	return c
+}
+
+func (c *httpEntitlementChecker) Check(ctx context.Context, roomID string, header http.Header) (*Entitlement, error) {
+	key := cacheKey(roomID, header)
+	if c.cache != nil {
+		if e, ok := c.cache.Get(key); ok {
+			entitlementCacheHits.Add(ctx, 1)
+			return e, nil
+		}
+	}
+
+	if !c.breaker.Allow() {
+		entitlementUnavailable.Add(ctx, 1)
+		return nil, fmt.Errorf("%w: circuit open", ErrEntitlementUnavailable)
+	}
+
+	e, err := c.request(ctx, roomID, header)
+	c.breaker.Record(err == nil)
+	if err != nil {
+		entitlementUnavailable.Add(ctx, 1)
+		return nil, fmt.Errorf("%w: %w", ErrEntitlementUnavailable, err)
+	}
+
+	if c.cache != nil {
+		c.cache.Add(key, e)
+	}
+	return e, nil
+}
+
+func (c *httpEntitlementChecker) request(ctx context.Context, roomID string, header http.Header) (*Entitlement, error) {
+	req := c.client.R().
+		SetContext(ctx).
+		SetBody(map[string]string{"roomId": roomID})
+	for _, name := range forwardedHeaders {
+		if value := header.Get(name); value != "" {
+			req.SetHeader(name, value)
+		}
+	}
+
+	resp, err := req.Post(c.url)
+	if err != nil {
+		return nil, err
+	}
+	// 401 and 403 are a decision of the service, not a failure
+	if resp.StatusCode() == http.StatusUnauthorized || resp.StatusCode() == http.StatusForbidden {
+		return &Entitlement{Allowed: false}, nil
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status())
+	}
+	var e Entitlement
+	if err := json.Unmarshal(resp.Body(), &e); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &e, nil
+}
+
+// cacheKey identifies a decision by room and caller credentials, which are hashed to keep
+// them out of memory dumps
+func cacheKey(roomID string, header http.Header) string {
+	h := sha256.New()
+	for _, name := range forwardedHeaders {
+		h.Write([]byte(header.Get(name)))
+		h.Write([]byte{0})
+	}
+	return roomID + ":" + hex.EncodeToString(h.Sum(nil))
+}
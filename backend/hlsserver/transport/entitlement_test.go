@@ -0,0 +1,115 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEntitlementServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestEntitlementChecker_Disabled(t *testing.T) {
+	assert.Nil(t, NewEntitlementChecker(&EntitlementConfig{}))
+}
+
+func TestEntitlementChecker_Check(t *testing.T) {
+	srv, calls := newTestEntitlementServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("Authorization") != "Bearer paid" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Entitlement{Allowed: body["roomId"] == "room1", UserID: "user-1"})
+	})
+	checker := newEntitlementChecker(&EntitlementConfig{
+		URL:      srv.URL,
+		Timeout:  time.Second,
+		CacheTTL: time.Minute,
+	}, clockwork.NewFakeClock())
+	ctx := context.Background()
+
+	paid := http.Header{"Authorization": []string{"Bearer paid"}}
+	e, err := checker.Check(ctx, "room1", paid)
+	require.NoError(t, err)
+	assert.Equal(t, &Entitlement{Allowed: true, UserID: "user-1"}, e)
+
+	e, err = checker.Check(ctx, "room2", paid)
+	require.NoError(t, err)
+	assert.False(t, e.Allowed)
+
+	e, err = checker.Check(ctx, "room1", http.Header{})
+	require.NoError(t, err)
+	assert.False(t, e.Allowed)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// decisions are cached per room and credentials
+	e, err = checker.Check(ctx, "room1", paid)
+	require.NoError(t, err)
+	assert.True(t, e.Allowed)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestEntitlementChecker_CircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	srv, calls := newTestEntitlementServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Entitlement{Allowed: true})
+	})
+	clock := clockwork.NewFakeClock()
+	checker := newEntitlementChecker(&EntitlementConfig{
+		URL:              srv.URL,
+		Timeout:          time.Second,
+		FailureThreshold: 2,
+		OpenDuration:     time.Minute,
+	}, clock)
+	ctx := context.Background()
+
+	for range 2 {
+		_, err := checker.Check(ctx, "room1", http.Header{})
+		require.ErrorIs(t, err, ErrEntitlementUnavailable)
+	}
+	assert.Equal(t, int32(2), calls.Load())
+
+	// open, fails without calling the service
+	_, err := checker.Check(ctx, "room1", http.Header{})
+	require.ErrorIs(t, err, ErrEntitlementUnavailable)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// a failed trial reopens it
+	clock.Advance(time.Minute)
+	_, err = checker.Check(ctx, "room1", http.Header{})
+	require.ErrorIs(t, err, ErrEntitlementUnavailable)
+	_, err = checker.Check(ctx, "room1", http.Header{})
+	require.ErrorIs(t, err, ErrEntitlementUnavailable)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// a successful trial closes it
+	healthy.Store(true)
+	clock.Advance(time.Minute)
+	e, err := checker.Check(ctx, "room1", http.Header{})
+	require.NoError(t, err)
+	assert.True(t, e.Allowed)
+	_, err = checker.Check(ctx, "room1", http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), calls.Load())
+}
@@ -0,0 +1,33 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/imtaco/audio-rtc-exp/internal/urlsign"
+)
+
+// ListenerTracker counts the unique playback tokens fetching keys and playlists of rooms
+type ListenerTracker interface {
+	Track(roomID, token string)
+}
+
+// trackListener counts the token as a listener of the room, tokens are hashed so they are not
+// handed over to the tracker store
+func trackListener(tracker ListenerTracker, roomID, token string) {
+	if tracker == nil || token == "" {
+		return
+	}
+	sum := sha256.Sum256([]byte(token))
+	tracker.Track(roomID, hex.EncodeToString(sum[:16]))
+}
+
+// playbackToken returns the bearer token of the request, or its URL signature when signed
+func playbackToken(req *http.Request) string {
+	if token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return req.URL.Query().Get(urlsign.ParamSignature)
+}
@@ -0,0 +1,61 @@
+package transport_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/imtaco/audio-rtc-exp/hlsserver/transport"
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// fakeTracker records the tracked tokens per room
+type fakeTracker map[string][]string
+
+func (f fakeTracker) Track(roomID, token string) {
+	f[roomID] = append(f[roomID], token)
+}
+
+func (s *M3U8RouterSuite) TestGetPlaylist_TracksListeners() {
+	tracker := fakeTracker{}
+	router := transport.NewM3U8Router(s.mockWatcher, s.hlsDir, "", s.signer, nil, tracker, nil, log.NewTest(s.T()))
+	s.activeRoom("room123")
+	s.activeRoom("room123")
+
+	target := s.signer.SignURL("/hls/room123/stream.m3u8", "room123")
+	s.Require().Equal(http.StatusOK, s.get(router, target).Code)
+	s.Require().Equal(http.StatusOK, s.get(router, target).Code)
+	s.Require().Equal(http.StatusForbidden, s.get(router, "/hls/room123/stream.m3u8").Code)
+
+	// the same signature is the same listener, tokens are not handed over as is
+	s.Require().Len(tracker["room123"], 2)
+	s.Equal(tracker["room123"][0], tracker["room123"][1])
+	s.NotContains(target, tracker["room123"][0])
+}
+
+func (s *RouterSuite) TestKeyRouter_TracksListeners() {
+	tracker := fakeTracker{}
+	router := transport.NewKeyRouter(s.mockWatcher, s.jwtAuth, nil, tracker, nil, log.NewTest(s.T()))
+	roomID := "trackedRoom"
+	s.mockWatcher.EXPECT().GetActiveLiveMeta(roomID).Return(&etcdstate.LiveMeta{
+		Status: constants.RoomStatusOnAir,
+		Nonce:  "nonce123",
+	})
+
+	get := func(token string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hls/rooms/"+roomID+"/enc.key", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.Handler().ServeHTTP(w, req)
+		return w.Code
+	}
+	token1, _ := s.jwtAuth.Sign("user1", roomID, constants.UserRoleGuest)
+	token2, _ := s.jwtAuth.Sign("user2", roomID, constants.UserRoleGuest)
+	s.Equal(http.StatusOK, get(token1))
+	s.Equal(http.StatusOK, get(token2))
+	s.Equal(http.StatusForbidden, get("invalidtoken"))
+
+	s.Require().Len(tracker[roomID], 2)
+	s.NotEqual(tracker[roomID][0], tracker[roomID][1])
+}
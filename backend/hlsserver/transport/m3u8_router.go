@@ -0,0 +1,254 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+
+	"github.com/imtaco/audio-rtc-exp/hlsserver"
+	"github.com/imtaco/audio-rtc-exp/internal/apispec"
+	"github.com/imtaco/audio-rtc-exp/internal/httputil"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/urlsign"
+	"github.com/imtaco/audio-rtc-exp/internal/validation"
+)
+
+const playlistName = "stream.m3u8"
+
+// M3U8Router serves room playlists written by mixers, checking signed URLs when signing is enabled
+type M3U8Router struct {
+	roomWatcher    hlsserver.RoomWatcher
+	hlsDir         string
+	segmentBaseURL string
+	urlSigner      *urlsign.Signer
+	static         StaticConfig
+	listeners      ListenerTracker
+	engine         *gin.Engine
+	spec           *apispec.Spec
+	logger         *log.Logger
+}
+
+func NewM3U8Router(
+	roomWatcher hlsserver.RoomWatcher,
+	hlsDir string,
+	segmentBaseURL string,
+	urlSigner *urlsign.Signer,
+	static *StaticConfig,
+	listeners ListenerTracker,
+	usageRecorder UsageRecorder,
+	logger *log.Logger,
+) *M3U8Router {
+	engine := httputil.NewEngine("m3u8-server", logger)
+
+	engine.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"GET", "HEAD", "OPTIONS"},
+		AllowHeaders:     []string{"Content-Type", "Range"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Range"},
+		AllowCredentials: false,
+	}))
+	if usageRecorder != nil {
+		engine.Use(accountUsage(usageRecorder))
+	}
+
+	r := &M3U8Router{
+		roomWatcher:    roomWatcher,
+		hlsDir:         hlsDir,
+		segmentBaseURL: segmentBaseURL,
+		urlSigner:      urlSigner,
+		listeners:      listeners,
+		engine:         engine,
+		spec:           apispec.New("HLS Playlist Server API", "1.0.0"),
+		logger:         logger,
+	}
+
+	if static != nil {
+		r.static = *static
+	}
+	r.setupRoutes()
+	return r
+}
+
+func (r *M3U8Router) Handler() http.Handler {
+	return r.engine
+}
+
+func (r *M3U8Router) setupRoutes() {
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/hls/:roomId/" + playlistName,
+		Name:    "getPlaylist",
+		Summary: "Get the HLS playlist of a live room, signed when signing is enabled, start plays DVR rooms from then",
+		URI:     GetPlaylistRequest{},
+		Query:   GetPlaylistQuery{},
+		Responses: map[int]any{
+			// playlist is served as application/vnd.apple.mpegurl
+			http.StatusOK:         nil,
+			http.StatusBadRequest: apispec.ValidationErrorResponse,
+			http.StatusForbidden:  nil,
+			http.StatusNotFound:   nil,
+		},
+	}, r.getPlaylist)
+	if r.static.Enabled {
+		fileRoute := apispec.Route{
+			Method:  http.MethodGet,
+			Path:    "/hls/:roomId/:file",
+			Name:    "getFile",
+			Summary: "Get a segment or playlist file of a room, segments are cached as immutable, ranges are supported",
+			URI:     GetFileRequest{},
+			Responses: map[int]any{
+				// files are served with the content type of their extension
+				http.StatusOK:             nil,
+				http.StatusPartialContent: nil,
+				http.StatusBadRequest:     apispec.ValidationErrorResponse,
+				http.StatusNotFound:       nil,
+			},
+		}
+		r.handle(fileRoute, r.getFile)
+		r.engine.HEAD(fileRoute.Path, r.getFile)
+	}
+	r.engine.GET("/api/spec", gin.WrapH(r.spec))
+	r.engine.GET("/health", r.healthCheck)
+}
+
+// handle registers the route to gin and publishes it in the API spec
+func (r *M3U8Router) handle(route apispec.Route, handler gin.HandlerFunc) {
+	r.spec.Add(route)
+	r.engine.Handle(route.Method, route.Path, handler)
+}
+
+func (r *M3U8Router) getPlaylist(c *gin.Context) {
+	var req GetPlaylistRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	var shift GetPlaylistQuery
+	if err := c.ShouldBindQuery(&shift); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	roomID := req.RoomID
+	query := c.Request.URL.Query()
+
+	if r.urlSigner != nil {
+		if err := r.urlSigner.Verify(roomID, query); err != nil {
+			authFailures.Add(c.Request.Context(), 1)
+			r.logger.Warn("Invalid playlist signature",
+				log.String("roomId", roomID),
+				log.Error(err))
+			c.String(http.StatusForbidden, "Access denied")
+			return
+		}
+	}
+
+	if r.roomWatcher.GetActiveLiveMeta(roomID) == nil {
+		roomNotFound.Add(c.Request.Context(), 1)
+		c.String(http.StatusNotFound, "Room not found")
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Join(r.hlsDir, roomID, playlistName))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			r.logger.Error("Failed to read playlist", log.String("roomId", roomID), log.Error(err))
+		}
+		c.String(http.StatusNotFound, "Playlist not found")
+		return
+	}
+
+	if start := playlistStart(shift.Start); !start.IsZero() {
+		data = timeShift(data, start)
+	}
+
+	// the key server checks the same signature, so it is carried over to the key URI
+	var keyQuery string
+	if r.urlSigner != nil {
+		keyQuery = urlsign.Query(query).Encode()
+	}
+	var segmentBaseURL string
+	if r.segmentBaseURL != "" {
+		segmentBaseURL = r.segmentBaseURL + roomID + "/"
+	}
+
+	playlistsServed.Add(c.Request.Context(), 1)
+	trackListener(r.listeners, roomID, playbackToken(c.Request))
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	r.writePlaylist(c, rewritePlaylist(data, segmentBaseURL, keyQuery))
+}
+
+// playlistStart returns the time of the start query param, zero when not set
+func playlistStart(start int64) time.Time {
+	switch {
+	case start < 0:
+		return time.Now().Add(time.Duration(start) * time.Second)
+	case start > 0:
+		return time.Unix(start, 0)
+	default:
+		return time.Time{}
+	}
+}
+
+func (r *M3U8Router) healthCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+	})
+}
+
+// rewritePlaylist makes relative segment URIs absolute and appends keyQuery to the key URI
+func rewritePlaylist(data []byte, segmentBaseURL, keyQuery string) []byte {
+	var buf bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-KEY:") && keyQuery != "":
+			line = appendKeyURIQuery(line, keyQuery)
+		case line != "" && !strings.HasPrefix(line, "#") && segmentBaseURL != "" && !strings.Contains(line, "://"):
+			line = segmentBaseURL + line
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func appendKeyURIQuery(line, query string) string {
+	const attr = `URI="`
+	start := strings.Index(line, attr)
+	if start < 0 {
+		return line
+	}
+	start += len(attr)
+	end := strings.IndexByte(line[start:], '"')
+	if end < 0 {
+		return line
+	}
+	end += start
+
+	uri := line[start:end]
+	sep := "?"
+	if strings.Contains(uri, "?") {
+		sep = "&"
+	}
+	return line[:end] + sep + query + line[end:]
+}
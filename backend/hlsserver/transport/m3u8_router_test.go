@@ -0,0 +1,180 @@
+package transport_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/hlsserver/mocks"
+	"github.com/imtaco/audio-rtc-exp/hlsserver/transport"
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/urlsign"
+)
+
+const testPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:3
+#EXT-X-KEY:METHOD=AES-128,URI="http://localhost:3101/hls/rooms/room123/enc.key",IV=0x00000000000000000000000000000001
+#EXTINF:2.000000,
+segment_003.ts
+#EXTINF:2.000000,
+segment_004.ts
+`
+
+type M3U8RouterSuite struct {
+	suite.Suite
+	ctrl        *gomock.Controller
+	mockWatcher *mocks.MockRoomWatcher
+	hlsDir      string
+	signer      *urlsign.Signer
+}
+
+func TestM3U8RouterSuite(t *testing.T) {
+	suite.Run(t, new(M3U8RouterSuite))
+}
+
+func (s *M3U8RouterSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.mockWatcher = mocks.NewMockRoomWatcher(s.ctrl)
+	s.signer = urlsign.New("url-secret", time.Hour)
+	gin.SetMode(gin.TestMode)
+
+	s.hlsDir = s.T().TempDir()
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.hlsDir, "room123"), 0o755))
+	s.Require().NoError(os.WriteFile(
+		filepath.Join(s.hlsDir, "room123", "stream.m3u8"), []byte(testPlaylist), 0o600))
+}
+
+func (s *M3U8RouterSuite) TearDownTest() {
+	s.ctrl.Finish()
+}
+
+func (s *M3U8RouterSuite) get(router *transport.M3U8Router, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", target, nil)
+	router.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func (s *M3U8RouterSuite) activeRoom(roomID string) {
+	s.mockWatcher.EXPECT().GetActiveLiveMeta(roomID).Return(&etcdstate.LiveMeta{
+		Status: constants.RoomStatusOnAir,
+	})
+}
+
+func (s *M3U8RouterSuite) TestGetPlaylist_Unsigned() {
+	router := transport.NewM3U8Router(s.mockWatcher, s.hlsDir, "", nil, nil, nil, nil, log.NewTest(s.T()))
+	s.activeRoom("room123")
+
+	w := s.get(router, "/hls/room123/stream.m3u8")
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("application/vnd.apple.mpegurl", w.Header().Get("Content-Type"))
+	s.Equal(testPlaylist, w.Body.String())
+}
+
+func (s *M3U8RouterSuite) TestGetPlaylist_TimeShifted() {
+	dvr := `#EXTM3U
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:3
+#EXT-X-PROGRAM-DATE-TIME:2025-01-01T00:00:00.000+0000
+#EXTINF:2.000000,
+segment_003.ts
+#EXTINF:2.000000,
+segment_004.ts
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(s.hlsDir, "room123", "stream.m3u8"), []byte(dvr), 0o600))
+	router := transport.NewM3U8Router(s.mockWatcher, s.hlsDir, "", nil, nil, nil, nil, log.NewTest(s.T()))
+	s.activeRoom("room123")
+
+	start := time.Date(2025, 1, 1, 0, 0, 3, 0, time.UTC).Unix()
+	w := s.get(router, "/hls/room123/stream.m3u8?start="+strconv.FormatInt(start, 10))
+
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "#EXT-X-PLAYLIST-TYPE:EVENT\n")
+	s.Contains(body, "#EXT-X-MEDIA-SEQUENCE:4\n")
+	s.NotContains(body, "segment_003.ts")
+}
+
+func (s *M3U8RouterSuite) TestGetPlaylist_InvalidStart() {
+	router := transport.NewM3U8Router(s.mockWatcher, s.hlsDir, "", nil, nil, nil, nil, log.NewTest(s.T()))
+
+	w := s.get(router, "/hls/room123/stream.m3u8?start=yesterday")
+	s.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (s *M3U8RouterSuite) TestGetPlaylist_Signed() {
+	router := transport.NewM3U8Router(
+		s.mockWatcher, s.hlsDir, "http://cdn.example.com/hls/", s.signer, nil, nil, nil, log.NewTest(s.T()))
+	s.activeRoom("room123")
+
+	w := s.get(router, s.signer.SignURL("/hls/room123/stream.m3u8", "room123"))
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	s.Contains(body, "\nhttp://cdn.example.com/hls/room123/segment_003.ts\n")
+	s.Contains(body, "\nhttp://cdn.example.com/hls/room123/segment_004.ts\n")
+
+	// signature is carried over to the key URI
+	var keyURI string
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "#EXT-X-KEY:") {
+			start := strings.Index(line, `URI="`) + len(`URI="`)
+			keyURI = line[start : start+strings.IndexByte(line[start:], '"')]
+			s.True(strings.HasSuffix(line, ",IV=0x00000000000000000000000000000001"))
+		}
+	}
+	u, err := url.Parse(keyURI)
+	s.Require().NoError(err)
+	s.Equal("/hls/rooms/room123/enc.key", u.Path)
+	s.NoError(s.signer.Verify("room123", u.Query()))
+}
+
+func (s *M3U8RouterSuite) TestGetPlaylist_InvalidSignature() {
+	router := transport.NewM3U8Router(s.mockWatcher, s.hlsDir, "", s.signer, nil, nil, nil, log.NewTest(s.T()))
+
+	w := s.get(router, "/hls/room123/stream.m3u8")
+	s.Equal(http.StatusForbidden, w.Code)
+
+	w = s.get(router, s.signer.SignURL("/hls/room123/stream.m3u8", "other-room"))
+	s.Equal(http.StatusForbidden, w.Code)
+
+	expired := urlsign.New("url-secret", -time.Minute)
+	w = s.get(router, expired.SignURL("/hls/room123/stream.m3u8", "room123"))
+	s.Equal(http.StatusForbidden, w.Code)
+}
+
+func (s *M3U8RouterSuite) TestGetPlaylist_NotFound() {
+	router := transport.NewM3U8Router(s.mockWatcher, s.hlsDir, "", nil, nil, nil, nil, log.NewTest(s.T()))
+
+	// room not live
+	s.mockWatcher.EXPECT().GetActiveLiveMeta("room456").Return(nil)
+	w := s.get(router, "/hls/room456/stream.m3u8")
+	s.Equal(http.StatusNotFound, w.Code)
+
+	// live but no playlist yet
+	s.activeRoom("room789")
+	w = s.get(router, "/hls/room789/stream.m3u8")
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
+func (s *M3U8RouterSuite) TestGetPlaylist_InvalidRoomID() {
+	router := transport.NewM3U8Router(s.mockWatcher, s.hlsDir, "", nil, nil, nil, nil, log.NewTest(s.T()))
+
+	w := s.get(router, "/hls/invalid@room/stream.m3u8")
+	s.Equal(http.StatusBadRequest, w.Code)
+}
@@ -11,12 +11,23 @@ var (
 	tokensGenerated metric.Int64Counter
 	tokensFailed    metric.Int64Counter
 
+	// Entitlement metrics
+	tokensDenied           metric.Int64Counter
+	entitlementCacheHits   metric.Int64Counter
+	entitlementUnavailable metric.Int64Counter
+
 	// Key metrics
 	keysServed  metric.Int64Counter
 	cacheHits   metric.Int64Counter
 	cacheMisses metric.Int64Counter
 	activeRooms metric.Int64UpDownCounter
 
+	// Playlist metrics
+	playlistsServed metric.Int64Counter
+
+	// Static file metrics
+	filesServed metric.Int64Counter
+
 	// Error metrics
 	authFailures metric.Int64Counter
 	roomNotFound metric.Int64Counter
@@ -31,6 +42,15 @@ func init() {
 	f.Int64Counter(&tokensFailed, "tokens.failed",
 		metric.WithDescription("Failed token generation attempts"))
 
+	f.Int64Counter(&tokensDenied, "tokens.denied",
+		metric.WithDescription("Token requests denied by the entitlement service"))
+
+	f.Int64Counter(&entitlementCacheHits, "entitlement.cache_hits",
+		metric.WithDescription("Entitlement decisions served from cache"))
+
+	f.Int64Counter(&entitlementUnavailable, "entitlement.unavailable",
+		metric.WithDescription("Entitlement checks failed or rejected by the circuit breaker"))
+
 	f.Int64Counter(&keysServed, "keys.served",
 		metric.WithDescription("Total encryption keys served"))
 
@@ -43,6 +63,12 @@ func init() {
 	f.Int64UpDownCounter(&activeRooms, "rooms.active",
 		metric.WithDescription("Number of active rooms"))
 
+	f.Int64Counter(&playlistsServed, "playlists.served",
+		metric.WithDescription("Total HLS playlists served"))
+
+	f.Int64Counter(&filesServed, "files.served",
+		metric.WithDescription("Total segment and playlist files served from the HLS directory"))
+
 	f.Int64Counter(&authFailures, "auth.failures",
 		metric.WithDescription("Authorization failures"))
 
@@ -0,0 +1,189 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/spf13/viper"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/imtaco/audio-rtc-exp/hlsserver"
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+// nowPlayingCacheSize bounds the rooms kept assembled
+const nowPlayingCacheSize = 1000
+
+// titleTag is the room tag holding the title shown to listeners
+const titleTag = "title"
+
+type NowPlayingConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// CacheTTL is how long the metadata of a room is served before being assembled again, it is
+	// also the max-age players and CDNs may cache it for
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+}
+
+func SetupNowPlaying(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), false)
+	v.SetDefault(p("cache_ttl"), 5*time.Second)
+}
+
+// RoomUsersReader reads the active users of a room from the users service
+type RoomUsersReader interface {
+	GetActiveRoomUsers(ctx context.Context, roomID string) ([]*users.RoomUser, error)
+}
+
+// ListenerEstimator estimates the unique listeners of a room within the last minutes
+type ListenerEstimator interface {
+	Estimate(ctx context.Context, roomID string) (int64, error)
+}
+
+// RoomNowPlaying is the display metadata of a room for players
+type RoomNowPlaying struct {
+	RoomID string `json:"roomId"`
+	// Title is the title tag of the room
+	Title     string     `json:"title,omitempty"`
+	Live      bool       `json:"live"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	// Speakers are the user IDs on air, null without the users service
+	Speakers []string `json:"speakers"`
+	// Listeners is the estimate of unique listeners, omitted without listener counting
+	Listeners *int64 `json:"listeners,omitempty"`
+}
+
+// NowPlaying assembles the display metadata of rooms from etcd and users state, so player
+// UIs need not call the authenticated rooms API. Each room is assembled at most once per
+// cache TTL, whatever the number of players polling it
+type NowPlaying struct {
+	cfg         *NowPlayingConfig
+	roomWatcher hlsserver.RoomWatcher
+	users       RoomUsersReader   // optional, leaves speakers out
+	listeners   ListenerEstimator // optional, leaves listeners out
+	cache       *expirable.LRU[string, *RoomNowPlaying]
+	group       singleflight.Group
+	logger      *log.Logger
+}
+
+// NewNowPlaying returns nil when disabled
+func NewNowPlaying(
+	cfg *NowPlayingConfig,
+	roomWatcher hlsserver.RoomWatcher,
+	users RoomUsersReader,
+	listeners ListenerEstimator,
+	logger *log.Logger,
+) *NowPlaying {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &NowPlaying{
+		cfg:         cfg,
+		roomWatcher: roomWatcher,
+		users:       users,
+		listeners:   listeners,
+		cache:       expirable.NewLRU[string, *RoomNowPlaying](nowPlayingCacheSize, nil, cfg.CacheTTL),
+		logger:      logger,
+	}
+}
+
+// Get returns the metadata of the room, nil when the room does not exist
+func (n *NowPlaying) Get(ctx context.Context, roomID string) *RoomNowPlaying {
+	if np, ok := n.cache.Get(roomID); ok {
+		return np
+	}
+	v, _, _ := n.group.Do(roomID, func() (any, error) {
+		np := n.assemble(ctx, roomID)
+		if np != nil {
+			n.cache.Add(roomID, np)
+		}
+		return np, nil
+	})
+	return v.(*RoomNowPlaying)
+}
+
+func (n *NowPlaying) assemble(ctx context.Context, roomID string) *RoomNowPlaying {
+	state, _ := n.roomWatcher.GetCachedState(roomID)
+	meta := state.GetMeta()
+	if meta == nil {
+		return nil
+	}
+
+	np := &RoomNowPlaying{
+		RoomID: roomID,
+		Title:  meta.GetTags()[titleTag],
+	}
+	liveMeta := state.GetLiveMeta()
+	if liveMeta.GetStatus() != constants.RoomStatusOnAir {
+		return np
+	}
+	np.Live = true
+	startedAt := liveMeta.CreatedAt
+	np.StartedAt = &startedAt
+
+	if n.users != nil {
+		speakers, err := n.speakers(ctx, roomID, meta.GetPublishSlots() > 0)
+		if err != nil {
+			n.logger.Warn("Failed to read speakers", log.String("roomId", roomID), log.Error(err))
+		} else {
+			np.Speakers = speakers
+		}
+	}
+	if n.listeners != nil {
+		count, err := n.listeners.Estimate(ctx, roomID)
+		if err != nil {
+			n.logger.Warn("Failed to estimate listeners", log.String("roomId", roomID), log.Error(err))
+		} else {
+			np.Listeners = &count
+		}
+	}
+	return np
+}
+
+// speakers are the users on air, holding a publish slot in push-to-talk rooms
+func (n *NowPlaying) speakers(ctx context.Context, roomID string, pushToTalk bool) ([]string, error) {
+	roomUsers, err := n.users.GetActiveRoomUsers(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	speakers := []string{}
+	for _, u := range roomUsers {
+		if u.Status != constants.AnchorStatusOnAir || (pushToTalk && !u.Publish) {
+			continue
+		}
+		speakers = append(speakers, u.UserID)
+	}
+	return speakers, nil
+}
+
+// maxAge is the Cache-Control max-age of responses in seconds
+func (n *NowPlaying) maxAge() int {
+	return int(n.cfg.CacheTTL / time.Second)
+}
+
+// roomUsersClient reads room users from the users controller over its request stream
+type roomUsersClient struct {
+	rpcClient streamrpc.Client
+}
+
+func NewRoomUsersClient(rpcClient streamrpc.Client) RoomUsersReader {
+	return &roomUsersClient{rpcClient: rpcClient}
+}
+
+func (c *roomUsersClient) GetActiveRoomUsers(ctx context.Context, roomID string) ([]*users.RoomUser, error) {
+	request := &users.GetRoomUsersRequest{
+		RoomID: roomID,
+		TS:     time.Now(),
+	}
+	resp, err := users.MethodGetRoomUsers.Call(ctx, c.rpcClient, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room users: %w", err)
+	}
+	return resp.Users, nil
+}
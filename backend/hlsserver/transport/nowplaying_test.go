@@ -0,0 +1,162 @@
+package transport_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/hlsserver/transport"
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+// fakeRoomUsers answers room users and counts the reads
+type fakeRoomUsers struct {
+	users []*users.RoomUser
+	err   error
+	reads int
+}
+
+func (f *fakeRoomUsers) GetActiveRoomUsers(context.Context, string) ([]*users.RoomUser, error) {
+	f.reads++
+	return f.users, f.err
+}
+
+type fakeEstimator int64
+
+func (f fakeEstimator) Estimate(context.Context, string) (int64, error) {
+	return int64(f), nil
+}
+
+func (s *RouterSuite) nowPlayingRoom(roomID string, meta *etcdstate.Meta, status constants.RoomStatus, startedAt time.Time) {
+	s.mockWatcher.EXPECT().GetCachedState(roomID).Return(&etcdstate.RoomState{
+		Meta: meta,
+		LiveMeta: &etcdstate.LiveMeta{
+			Status:    status,
+			CreatedAt: startedAt,
+		},
+	}, true).AnyTimes()
+}
+
+func (s *RouterSuite) getNowPlaying(router *transport.TokenRouter, roomID string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/rooms/"+roomID+"/now-playing", nil)
+	router.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func (s *RouterSuite) TestNowPlaying() {
+	startedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	roomUsers := &fakeRoomUsers{users: []*users.RoomUser{
+		{UserID: "alice", Status: constants.AnchorStatusOnAir},
+		{UserID: "bob", Status: constants.AnchorStatusIdle},
+	}}
+	nowPlaying := transport.NewNowPlaying(
+		&transport.NowPlayingConfig{Enabled: true, CacheTTL: time.Minute},
+		s.mockWatcher,
+		roomUsers,
+		fakeEstimator(42),
+		log.NewTest(s.T()),
+	)
+	router := transport.NewTokenRouter(s.mockWatcher, s.jwtAuth, nil, false, nil, nowPlaying, log.NewTest(s.T()))
+	s.nowPlayingRoom("room123", &etcdstate.Meta{Tags: map[string]string{"title": "Morning Show"}},
+		constants.RoomStatusOnAir, startedAt)
+
+	w := s.getNowPlaying(router, "room123")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Equal("public, max-age=60", w.Header().Get("Cache-Control"))
+	s.Equal("*", w.Header().Get("Access-Control-Allow-Origin"))
+	s.JSONEq(`{
+		"roomId": "room123",
+		"title": "Morning Show",
+		"live": true,
+		"startedAt": "2026-01-02T03:04:05Z",
+		"speakers": ["alice"],
+		"listeners": 42
+	}`, w.Body.String())
+
+	// served from the cache until the TTL expires
+	s.Require().Equal(http.StatusOK, s.getNowPlaying(router, "room123").Code)
+	s.Equal(1, roomUsers.reads)
+}
+
+func (s *RouterSuite) TestNowPlaying_PushToTalk() {
+	roomUsers := &fakeRoomUsers{users: []*users.RoomUser{
+		{UserID: "alice", Status: constants.AnchorStatusOnAir, Publish: true},
+		{UserID: "bob", Status: constants.AnchorStatusOnAir},
+	}}
+	nowPlaying := transport.NewNowPlaying(
+		&transport.NowPlayingConfig{Enabled: true, CacheTTL: time.Minute},
+		s.mockWatcher,
+		roomUsers,
+		nil,
+		log.NewTest(s.T()),
+	)
+	s.nowPlayingRoom("room123", &etcdstate.Meta{PublishSlots: 1}, constants.RoomStatusOnAir, time.Now())
+
+	np := nowPlaying.Get(context.Background(), "room123")
+	s.Require().NotNil(np)
+	s.Equal([]string{"alice"}, np.Speakers)
+	s.Nil(np.Listeners)
+}
+
+func (s *RouterSuite) TestNowPlaying_UsersUnavailable() {
+	nowPlaying := transport.NewNowPlaying(
+		&transport.NowPlayingConfig{Enabled: true, CacheTTL: time.Minute},
+		s.mockWatcher,
+		&fakeRoomUsers{err: errors.New("timeout")},
+		nil,
+		log.NewTest(s.T()),
+	)
+	s.nowPlayingRoom("room123", &etcdstate.Meta{}, constants.RoomStatusOnAir, time.Now())
+
+	np := nowPlaying.Get(context.Background(), "room123")
+	s.Require().NotNil(np)
+	s.True(np.Live)
+	s.Nil(np.Speakers)
+}
+
+func (s *RouterSuite) TestNowPlaying_NotLive() {
+	nowPlaying := transport.NewNowPlaying(
+		&transport.NowPlayingConfig{Enabled: true, CacheTTL: time.Minute},
+		s.mockWatcher,
+		&fakeRoomUsers{},
+		fakeEstimator(3),
+		log.NewTest(s.T()),
+	)
+	router := transport.NewTokenRouter(s.mockWatcher, s.jwtAuth, nil, false, nil, nowPlaying, log.NewTest(s.T()))
+	s.nowPlayingRoom("room123", &etcdstate.Meta{Tags: map[string]string{"title": "Later"}},
+		constants.RoomStatusRemoving, time.Now())
+
+	w := s.getNowPlaying(router, "room123")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var np transport.RoomNowPlaying
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &np))
+	s.Equal(transport.RoomNowPlaying{RoomID: "room123", Title: "Later"}, np)
+}
+
+func (s *RouterSuite) TestNowPlaying_UnknownRoom() {
+	nowPlaying := transport.NewNowPlaying(
+		&transport.NowPlayingConfig{Enabled: true, CacheTTL: time.Minute},
+		s.mockWatcher,
+		nil,
+		nil,
+		log.NewTest(s.T()),
+	)
+	router := transport.NewTokenRouter(s.mockWatcher, s.jwtAuth, nil, false, nil, nowPlaying, log.NewTest(s.T()))
+	s.mockWatcher.EXPECT().GetCachedState("nope123").Return(nil, false)
+
+	s.Equal(http.StatusNotFound, s.getNowPlaying(router, "nope123").Code)
+}
+
+func (s *RouterSuite) TestNowPlaying_Disabled() {
+	s.Nil(transport.NewNowPlaying(&transport.NowPlayingConfig{}, s.mockWatcher, nil, nil, log.NewTest(s.T())))
+
+	router := transport.NewTokenRouter(s.mockWatcher, s.jwtAuth, nil, false, nil, nil, log.NewTest(s.T()))
+	s.Equal(http.StatusNotFound, s.getNowPlaying(router, "room123").Code)
+}
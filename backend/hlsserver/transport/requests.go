@@ -6,8 +6,44 @@ type GenerateTokenRequest struct {
 	RoomID string `json:"roomId" binding:"required,roomid"`
 }
 
+// CreateDeepLinkRequest represents the request to create a listen link
+type CreateDeepLinkRequest struct {
+	// RoomID: 3-32 characters (letters, numbers, hyphens, underscores) - required
+	RoomID string `json:"roomId" binding:"required,roomid"`
+	// ExpiresIn: link expiry in seconds, capped by the server - optional
+	ExpiresIn int64 `json:"expiresIn" binding:"omitempty,min=1"`
+	// QR: also return the link as a QR code PNG - optional
+	QR bool `json:"qr"`
+}
+
+// GetNowPlayingRequest represents the request to get the display metadata of a room (from URL param)
+type GetNowPlayingRequest struct {
+	// RoomID: 3-32 characters (letters, numbers, hyphens, underscores) - required
+	RoomID string `uri:"roomId" binding:"required,roomid"`
+}
+
 // GetEncryptionKeyRequest represents the request to get encryption key (from URL param)
 type GetEncryptionKeyRequest struct {
 	// RoomID: 3-32 characters (letters, numbers, hyphens, underscores) - required
 	RoomID string `uri:"roomId" binding:"required,roomid"`
 }
+
+// GetPlaylistRequest represents the request to get a room playlist (from URL param)
+type GetPlaylistRequest struct {
+	// RoomID: 3-32 characters (letters, numbers, hyphens, underscores) - required
+	RoomID string `uri:"roomId" binding:"required,roomid"`
+}
+
+// GetFileRequest represents the request to get a segment or playlist file of a room (from URL params)
+type GetFileRequest struct {
+	// RoomID: 3-32 characters (letters, numbers, hyphens, underscores) - required
+	RoomID string `uri:"roomId" binding:"required,roomid"`
+	// File: name of the file in the room directory - required
+	File string `uri:"file" binding:"required"`
+}
+
+// GetPlaylistQuery represents the optional catch-up start of a playlist (from query params)
+type GetPlaylistQuery struct {
+	// Start: unix seconds to play from, or seconds behind now when negative - optional
+	Start int64 `form:"start"`
+}
@@ -2,18 +2,23 @@ package transport
 
 import (
 	"crypto/subtle"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	lru "github.com/hashicorp/golang-lru/v2"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
 	"github.com/imtaco/audio-rtc-exp/hlsserver"
+	"github.com/imtaco/audio-rtc-exp/internal/apispec"
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/cryptoutil"
+	"github.com/imtaco/audio-rtc-exp/internal/httputil"
 	"github.com/imtaco/audio-rtc-exp/internal/jwt"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/urlsign"
 	"github.com/imtaco/audio-rtc-exp/internal/validation"
 )
 
@@ -33,20 +38,35 @@ func initKeyCache() {
 type TokenRouter struct {
 	roomWatcher hlsserver.RoomWatcher
 	jwtAuth     jwt.Auth
+	entitlement EntitlementChecker // optional, checks access to the room before signing
+	failOpen    bool               // sign tokens when the entitlement service is unavailable
+	links       *DeepLinker        // optional, serves listen links when set
+	nowPlaying  *NowPlaying        // optional, serves room metadata to players when set
 	engine      *gin.Engine
+	spec        *apispec.Spec
 	logger      *log.Logger
 }
 
-func NewTokenRouter(roomWatcher hlsserver.RoomWatcher, jwtAuth jwt.Auth, logger *log.Logger) *TokenRouter {
-	gin.SetMode(gin.ReleaseMode)
-	engine := gin.New()
-	engine.Use(gin.Recovery())
-	engine.Use(otelgin.Middleware("token-server"))
+func NewTokenRouter(
+	roomWatcher hlsserver.RoomWatcher,
+	jwtAuth jwt.Auth,
+	entitlement EntitlementChecker,
+	failOpen bool,
+	links *DeepLinker,
+	nowPlaying *NowPlaying,
+	logger *log.Logger,
+) *TokenRouter {
+	engine := httputil.NewEngine("token-server", logger)
 
 	r := &TokenRouter{
 		roomWatcher: roomWatcher,
 		jwtAuth:     jwtAuth,
+		entitlement: entitlement,
+		failOpen:    failOpen,
+		links:       links,
+		nowPlaying:  nowPlaying,
 		engine:      engine,
+		spec:        apispec.New("HLS Token Server API", "1.0.0"),
 		logger:      logger,
 	}
 
@@ -59,11 +79,61 @@ func (r *TokenRouter) Handler() http.Handler {
 }
 
 func (r *TokenRouter) setupRoutes() {
-	r.engine.Use(otelgin.Middleware("hls-token-server"))
-	r.engine.POST("/api/token", r.generateToken)
+	r.handle(apispec.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/token",
+		Name:    "generateToken",
+		Summary: "Sign a listener token for a room",
+		Body:    GenerateTokenRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"token": ""},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusForbidden:           apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+			http.StatusServiceUnavailable:  apispec.ErrorResponse,
+		},
+	}, r.generateToken)
+	if r.links != nil {
+		r.handle(apispec.Route{
+			Method:  http.MethodPost,
+			Path:    "/api/links",
+			Name:    "createDeepLink",
+			Summary: "Sign a listen link for a room, with an optional QR code",
+			Body:    CreateDeepLinkRequest{},
+			Responses: map[int]any{
+				http.StatusOK:                  DeepLink{},
+				http.StatusBadRequest:          apispec.ValidationErrorResponse,
+				http.StatusForbidden:           apispec.ErrorResponse,
+				http.StatusInternalServerError: apispec.ErrorResponse,
+				http.StatusServiceUnavailable:  apispec.ErrorResponse,
+			},
+		}, r.createDeepLink)
+	}
+	if r.nowPlaying != nil {
+		r.handle(apispec.Route{
+			Method: http.MethodGet,
+			Path:   "/api/rooms/:roomId/now-playing",
+			Name:   "getNowPlaying",
+			Summary: "Get the display metadata of a room for players: title, live, started at, speakers and " +
+				"listeners. Public and cacheable",
+			URI: GetNowPlayingRequest{},
+			Responses: map[int]any{
+				http.StatusOK:         RoomNowPlaying{},
+				http.StatusBadRequest: apispec.ValidationErrorResponse,
+				http.StatusNotFound:   apispec.ErrorResponse,
+			},
+		}, r.getNowPlaying)
+	}
+	r.engine.GET("/api/spec", gin.WrapH(r.spec))
 	r.engine.GET("/health", r.healthCheck)
 }
 
+// handle registers the route to gin and publishes it in the API spec
+func (r *TokenRouter) handle(route apispec.Route, handler gin.HandlerFunc) {
+	r.spec.Add(route)
+	r.engine.Handle(route.Method, route.Path, handler)
+}
+
 func (r *TokenRouter) generateToken(c *gin.Context) {
 	var req GenerateTokenRequest
 
@@ -76,8 +146,15 @@ func (r *TokenRouter) generateToken(c *gin.Context) {
 		return
 	}
 
-	userID := uuid.New().String()
-	token, err := r.jwtAuth.Sign(userID, req.RoomID)
+	userID, ok := r.checkEntitlement(c, req.RoomID)
+	if !ok {
+		return
+	}
+	if userID == "" {
+		userID = uuid.New().String()
+	}
+
+	token, err := r.jwtAuth.Sign(userID, req.RoomID, constants.UserRoleGuest)
 	if err != nil {
 		tokensFailed.Add(c.Request.Context(), 1)
 		r.logger.Error("Failed to sign token",
@@ -101,6 +178,114 @@ func (r *TokenRouter) generateToken(c *gin.Context) {
 	})
 }
 
+func (r *TokenRouter) createDeepLink(c *gin.Context) {
+	var req CreateDeepLinkRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	userID, ok := r.checkEntitlement(c, req.RoomID)
+	if !ok {
+		return
+	}
+	if userID == "" {
+		userID = uuid.New().String()
+	}
+
+	link, err := r.links.Create(req.RoomID, userID, time.Duration(req.ExpiresIn)*time.Second, req.QR)
+	if err != nil {
+		tokensFailed.Add(c.Request.Context(), 1)
+		r.logger.Error("Failed to create deep link",
+			log.String("userId", userID),
+			log.String("roomId", req.RoomID),
+			log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to generate link",
+		})
+		return
+	}
+
+	tokensGenerated.Add(c.Request.Context(), 1)
+	r.logger.Info("Deep link generated",
+		log.String("userId", userID),
+		log.String("roomId", req.RoomID),
+		log.Bool("qr", req.QR))
+
+	c.JSON(http.StatusOK, link)
+}
+
+func (r *TokenRouter) getNowPlaying(c *gin.Context) {
+	var req GetNowPlayingRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	// players fetch it from any origin, nothing in it depends on the caller
+	c.Header("Access-Control-Allow-Origin", "*")
+	np := r.nowPlaying.Get(c.Request.Context(), req.RoomID)
+	if np == nil {
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", r.nowPlaying.maxAge()))
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Room not found",
+		})
+		return
+	}
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", r.nowPlaying.maxAge()))
+	c.JSON(http.StatusOK, np)
+}
+
+// checkEntitlement asks the entitlement service, when configured, whether the caller may
+// access the room and returns the user ID it assigned. Responds and returns false otherwise
+func (r *TokenRouter) checkEntitlement(c *gin.Context, roomID string) (string, bool) {
+	if r.entitlement == nil {
+		return "", true
+	}
+
+	e, err := r.entitlement.Check(c.Request.Context(), roomID, c.Request.Header)
+	if err != nil {
+		if r.failOpen {
+			r.logger.Warn("Entitlement unavailable, failing open",
+				log.String("roomId", roomID),
+				log.Error(err))
+			return "", true
+		}
+		tokensFailed.Add(c.Request.Context(), 1)
+		r.logger.Error("Entitlement unavailable",
+			log.String("roomId", roomID),
+			log.Error(err))
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "Entitlement check unavailable",
+		})
+		return "", false
+	}
+
+	if !e.Allowed {
+		tokensDenied.Add(c.Request.Context(), 1)
+		r.logger.Info("Token denied by entitlement",
+			log.String("roomId", roomID))
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "Not entitled to the room",
+		})
+		return "", false
+	}
+	return e.UserID, true
+}
+
 func (r *TokenRouter) healthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status": "ok",
@@ -111,17 +296,24 @@ func (r *TokenRouter) healthCheck(c *gin.Context) {
 type KeyRouter struct {
 	roomWatcher hlsserver.RoomWatcher
 	jwtAuth     jwt.Auth
+	urlSigner   *urlsign.Signer // optional, requires signed key URLs when set
+	listeners   ListenerTracker // optional, counts the tokens fetching keys
 	engine      *gin.Engine
+	spec        *apispec.Spec
 	logger      *log.Logger
 }
 
-func NewKeyRouter(roomWatcher hlsserver.RoomWatcher, jwtAuth jwt.Auth, logger *log.Logger) *KeyRouter {
+func NewKeyRouter(
+	roomWatcher hlsserver.RoomWatcher,
+	jwtAuth jwt.Auth,
+	urlSigner *urlsign.Signer,
+	listeners ListenerTracker,
+	usageRecorder UsageRecorder,
+	logger *log.Logger,
+) *KeyRouter {
 	initKeyCache()
 
-	gin.SetMode(gin.ReleaseMode)
-	engine := gin.New()
-	engine.Use(gin.Recovery())
-	engine.Use(otelgin.Middleware("key-server"))
+	engine := httputil.NewEngine("key-server", logger)
 
 	// Configure CORS
 	engine.Use(cors.New(cors.Config{
@@ -131,11 +323,17 @@ func NewKeyRouter(roomWatcher hlsserver.RoomWatcher, jwtAuth jwt.Auth, logger *l
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: false,
 	}))
+	if usageRecorder != nil {
+		engine.Use(accountUsage(usageRecorder))
+	}
 
 	r := &KeyRouter{
 		roomWatcher: roomWatcher,
 		jwtAuth:     jwtAuth,
+		urlSigner:   urlSigner,
+		listeners:   listeners,
 		engine:      engine,
+		spec:        apispec.New("HLS Key Server API", "1.0.0"),
 		logger:      logger,
 	}
 
@@ -148,11 +346,30 @@ func (r *KeyRouter) Handler() http.Handler {
 }
 
 func (r *KeyRouter) setupRoutes() {
-	r.engine.Use(otelgin.Middleware("hls-key-server"))
-	r.engine.GET("/hls/rooms/:roomId/enc.key", r.getEncryptionKey)
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/hls/rooms/:roomId/enc.key",
+		Name:    "getEncryptionKey",
+		Summary: "Get the HLS AES-128 key of a live room, requires a bearer token of the room",
+		URI:     GetEncryptionKeyRequest{},
+		Responses: map[int]any{
+			// key is served as application/octet-stream
+			http.StatusOK:           nil,
+			http.StatusBadRequest:   apispec.ValidationErrorResponse,
+			http.StatusUnauthorized: nil,
+			http.StatusForbidden:    nil,
+		},
+	}, r.getEncryptionKey)
+	r.engine.GET("/api/spec", gin.WrapH(r.spec))
 	r.engine.GET("/health", r.healthCheck)
 }
 
+// handle registers the route to gin and publishes it in the API spec
+func (r *KeyRouter) handle(route apispec.Route, handler gin.HandlerFunc) {
+	r.spec.Add(route)
+	r.engine.Handle(route.Method, route.Path, handler)
+}
+
 func (r *KeyRouter) getEncryptionKey(c *gin.Context) {
 	// roomID := c.Param("roomId")
 	var req GetEncryptionKeyRequest
@@ -166,6 +383,19 @@ func (r *KeyRouter) getEncryptionKey(c *gin.Context) {
 	}
 
 	roomID := req.RoomID
+
+	// signature is carried over from the playlist URL by the m3u8 server
+	if r.urlSigner != nil {
+		if err := r.urlSigner.Verify(roomID, c.Request.URL.Query()); err != nil {
+			authFailures.Add(c.Request.Context(), 1)
+			r.logger.Warn("Invalid key URL signature",
+				log.String("roomId", roomID),
+				log.Error(err))
+			c.String(http.StatusForbidden, "Access denied 0")
+			return
+		}
+	}
+
 	authHeader := c.GetHeader("Authorization")
 
 	if authHeader == "" {
@@ -191,6 +421,15 @@ func (r *KeyRouter) getEncryptionKey(c *gin.Context) {
 		return
 	}
 
+	if !payload.HasRole(constants.UserRoleGuest, constants.UserRoleAnchor, constants.UserRoleHost) {
+		authFailures.Add(c.Request.Context(), 1)
+		r.logger.Warn("Token role not allowed",
+			log.String("roomId", roomID),
+			log.String("role", string(payload.Role)))
+		c.String(http.StatusForbidden, "Access denied 1")
+		return
+	}
+
 	if subtle.ConstantTimeCompare([]byte(roomID), []byte(payload.RoomID)) != 1 {
 		authFailures.Add(c.Request.Context(), 1)
 		r.logger.Warn("RoomId mismatch",
@@ -227,6 +466,7 @@ func (r *KeyRouter) getEncryptionKey(c *gin.Context) {
 	}
 
 	keysServed.Add(c.Request.Context(), 1)
+	trackListener(r.listeners, roomID, token)
 	c.Header("Content-Type", "application/octet-stream")
 	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
 	c.Header("Pragma", "no-cache")
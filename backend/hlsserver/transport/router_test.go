@@ -2,10 +2,13 @@ package transport_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/suite"
@@ -17,6 +20,7 @@ import (
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/jwt"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/urlsign"
 )
 
 type RouterSuite struct {
@@ -31,7 +35,7 @@ func (s *RouterSuite) SetupTest() {
 	s.ctrl = gomock.NewController(s.T())
 	s.mockWatcher = mocks.NewMockRoomWatcher(s.ctrl)
 	s.secret = "very-secret-key"
-	s.jwtAuth = jwt.NewAuth(s.secret)
+	s.jwtAuth = jwt.NewAuth(&jwt.Config{Secret: s.secret, ExpiresIn: time.Hour})
 	gin.SetMode(gin.TestMode)
 }
 
@@ -40,7 +44,7 @@ func (s *RouterSuite) TearDownTest() {
 }
 
 func (s *RouterSuite) TestTokenRouter_HealthCheck() {
-	router := transport.NewTokenRouter(s.mockWatcher, s.jwtAuth, log.NewTest(s.T()))
+	router := transport.NewTokenRouter(s.mockWatcher, s.jwtAuth, nil, false, nil, nil, log.NewTest(s.T()))
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/health", nil)
@@ -52,7 +56,7 @@ func (s *RouterSuite) TestTokenRouter_HealthCheck() {
 }
 
 func (s *RouterSuite) TestTokenRouter_GenerateToken() {
-	router := transport.NewTokenRouter(s.mockWatcher, s.jwtAuth, log.NewTest(s.T()))
+	router := transport.NewTokenRouter(s.mockWatcher, s.jwtAuth, nil, false, nil, nil, log.NewTest(s.T()))
 
 	// Test Success
 	body := map[string]string{"roomId": "room123"}
@@ -95,8 +99,140 @@ func (s *RouterSuite) TestTokenRouter_GenerateToken() {
 	s.Contains(w.Body.String(), "Validation failed")
 }
 
+type stubEntitlement struct {
+	entitlement *transport.Entitlement
+	err         error
+}
+
+func (e *stubEntitlement) Check(context.Context, string, http.Header) (*transport.Entitlement, error) {
+	return e.entitlement, e.err
+}
+
+func (s *RouterSuite) TestTokenRouter_GenerateToken_Entitlement() {
+	tests := []struct {
+		name     string
+		checker  *stubEntitlement
+		failOpen bool
+		code     int
+		userID   string
+	}{
+		{name: "allowed", checker: &stubEntitlement{entitlement: &transport.Entitlement{Allowed: true, UserID: "user-1"}}, code: http.StatusOK, userID: "user-1"},
+		{name: "denied", checker: &stubEntitlement{entitlement: &transport.Entitlement{}}, code: http.StatusForbidden},
+		{name: "unavailable", checker: &stubEntitlement{err: transport.ErrEntitlementUnavailable}, code: http.StatusServiceUnavailable},
+		{name: "unavailable fail open", checker: &stubEntitlement{err: transport.ErrEntitlementUnavailable}, failOpen: true, code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			router := transport.NewTokenRouter(s.mockWatcher, s.jwtAuth, tt.checker, tt.failOpen, nil, nil, log.NewTest(s.T()))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/token", bytes.NewBufferString(`{"roomId":"room123"}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.Handler().ServeHTTP(w, req)
+
+			s.Equal(tt.code, w.Code)
+			if tt.code != http.StatusOK {
+				return
+			}
+			var resp map[string]string
+			s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+			claims, err := s.jwtAuth.Verify(resp["token"])
+			s.Require().NoError(err)
+			if tt.userID != "" {
+				s.Equal(tt.userID, claims.UserID)
+			} else {
+				s.NotEmpty(claims.UserID)
+			}
+		})
+	}
+}
+
+func (s *RouterSuite) TestTokenRouter_DeepLink() {
+	signer := urlsign.New("url-secret", 0)
+	links := transport.NewDeepLinker(&transport.DeepLinkConfig{
+		ListenURL:       "https://listen.example.com/?lang=en",
+		PlaylistBaseURL: "https://cdn.example.com/hls/",
+		TTL:             time.Hour,
+		MaxTTL:          2 * time.Hour,
+		QRSize:          128,
+	}, s.jwtAuth, signer)
+	router := transport.NewTokenRouter(s.mockWatcher, s.jwtAuth, nil, false, links, nil, log.NewTest(s.T()))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/links", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	s.Run("link", func() {
+		w := post(`{"roomId":"room123"}`)
+		s.Require().Equal(http.StatusOK, w.Code)
+
+		var link transport.DeepLink
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &link))
+		s.Empty(link.QRPNG)
+		s.WithinDuration(time.Now().Add(time.Hour), link.ExpiresAt, 5*time.Second)
+
+		claims, err := s.jwtAuth.Verify(link.Token)
+		s.Require().NoError(err)
+		s.Equal("room123", claims.RoomID)
+		s.Equal(constants.UserRoleGuest, claims.Role)
+
+		playlist, err := url.Parse(link.PlaylistURL)
+		s.Require().NoError(err)
+		s.Equal("/hls/room123/stream.m3u8", playlist.Path)
+		s.NoError(signer.Verify("room123", playlist.Query()))
+
+		listen, err := url.Parse(link.URL)
+		s.Require().NoError(err)
+		s.Equal("listen.example.com", listen.Host)
+		s.Equal("en", listen.Query().Get("lang"))
+		s.Equal("room123", listen.Query().Get("room"))
+		s.Equal(link.PlaylistURL, listen.Query().Get("m3u8"))
+		s.Equal(link.Token, listen.Query().Get("token"))
+	})
+
+	s.Run("expiry is capped", func() {
+		w := post(`{"roomId":"room123","expiresIn":86400}`)
+		s.Require().Equal(http.StatusOK, w.Code)
+
+		var link transport.DeepLink
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &link))
+		s.WithinDuration(time.Now().Add(2*time.Hour), link.ExpiresAt, 5*time.Second)
+	})
+
+	s.Run("qr", func() {
+		w := post(`{"roomId":"room123","qr":true}`)
+		s.Require().Equal(http.StatusOK, w.Code)
+
+		var link transport.DeepLink
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &link))
+		s.Require().NotEmpty(link.QRPNG)
+		s.Equal([]byte("\x89PNG"), link.QRPNG[:4])
+	})
+
+	s.Run("invalid", func() {
+		s.Equal(http.StatusBadRequest, post(`{"roomId":"invalid@id"}`).Code)
+		s.Equal(http.StatusBadRequest, post(`{"roomId":"room123","expiresIn":-1}`).Code)
+	})
+}
+
+func (s *RouterSuite) TestTokenRouter_DeepLinkDisabled() {
+	links := transport.NewDeepLinker(&transport.DeepLinkConfig{}, s.jwtAuth, nil)
+	s.Nil(links)
+	router := transport.NewTokenRouter(s.mockWatcher, s.jwtAuth, nil, false, links, nil, log.NewTest(s.T()))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/links", bytes.NewBufferString(`{"roomId":"room123"}`))
+	router.Handler().ServeHTTP(w, req)
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
 func (s *RouterSuite) TestKeyRouter_HealthCheck() {
-	router := transport.NewKeyRouter(s.mockWatcher, s.jwtAuth, log.NewTest(s.T()))
+	router := transport.NewKeyRouter(s.mockWatcher, s.jwtAuth, nil, nil, nil, log.NewTest(s.T()))
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/health", nil)
@@ -106,11 +242,11 @@ func (s *RouterSuite) TestKeyRouter_HealthCheck() {
 }
 
 func (s *RouterSuite) TestKeyRouter_GetEncryptionKey() {
-	router := transport.NewKeyRouter(s.mockWatcher, s.jwtAuth, log.NewTest(s.T()))
+	router := transport.NewKeyRouter(s.mockWatcher, s.jwtAuth, nil, nil, nil, log.NewTest(s.T()))
 	roomID := "room123"
 
 	// Create valid token
-	token, _ := s.jwtAuth.Sign("user1", roomID)
+	token, _ := s.jwtAuth.Sign("user1", roomID, constants.UserRoleGuest)
 
 	// Case 1: Success (Not in cache, active room)
 	s.mockWatcher.EXPECT().GetActiveLiveMeta(roomID).Return(&etcdstate.LiveMeta{
@@ -146,7 +282,7 @@ func (s *RouterSuite) TestKeyRouter_GetEncryptionKey() {
 	s.Contains(w.Body.String(), "Access denied 1")
 
 	// Case 4: Room Mismatch
-	tokenOtherRoom, _ := s.jwtAuth.Sign("user1", "otherRoom")
+	tokenOtherRoom, _ := s.jwtAuth.Sign("user1", "otherRoom", constants.UserRoleGuest)
 	w = httptest.NewRecorder()
 	req, _ = http.NewRequest("GET", "/hls/rooms/"+roomID+"/enc.key", nil)
 	req.Header.Set("Authorization", "Bearer "+tokenOtherRoom)
@@ -156,7 +292,7 @@ func (s *RouterSuite) TestKeyRouter_GetEncryptionKey() {
 
 	// Case 5: Room Not Active (and not in cache)
 	roomInactive := "inactiveRoom"
-	tokenInactive, _ := s.jwtAuth.Sign("user1", roomInactive)
+	tokenInactive, _ := s.jwtAuth.Sign("user1", roomInactive, constants.UserRoleGuest)
 
 	s.mockWatcher.EXPECT().GetActiveLiveMeta(roomInactive).Return(nil).Times(1)
 
@@ -181,6 +317,40 @@ func (s *RouterSuite) TestKeyRouter_GetEncryptionKey() {
 	s.Equal(http.StatusBadRequest, w.Code)
 }
 
+func (s *RouterSuite) TestKeyRouter_SignedURL() {
+	signer := urlsign.New("url-secret", time.Hour)
+	router := transport.NewKeyRouter(s.mockWatcher, s.jwtAuth, signer, nil, nil, log.NewTest(s.T()))
+	roomID := "signedRoom"
+	token, _ := s.jwtAuth.Sign("user1", roomID, constants.UserRoleGuest)
+
+	// Case 1: Missing signature
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/hls/rooms/"+roomID+"/enc.key", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.Handler().ServeHTTP(w, req)
+	s.Equal(http.StatusForbidden, w.Code)
+	s.Contains(w.Body.String(), "Access denied 0")
+
+	// Case 2: Signature of another room
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", signer.SignURL("/hls/rooms/"+roomID+"/enc.key", "otherRoom"), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.Handler().ServeHTTP(w, req)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// Case 3: Signed
+	s.mockWatcher.EXPECT().GetActiveLiveMeta(roomID).Return(&etcdstate.LiveMeta{
+		Status: constants.RoomStatusOnAir,
+		Nonce:  "nonce123",
+	}).Times(1)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", signer.SignURL("/hls/rooms/"+roomID+"/enc.key", roomID), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.Handler().ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+}
+
 func TestRouterSuite(t *testing.T) {
 	suite.Run(t, new(RouterSuite))
 }
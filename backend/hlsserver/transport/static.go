@@ -0,0 +1,188 @@
+package transport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/validation"
+)
+
+const (
+	artifactSegment  = "segment"
+	artifactPlaylist = "playlist"
+)
+
+// StaticConfig enables serving segments written by mixers to the shared HLS volume
+type StaticConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// SegmentMaxAge is the Cache-Control max-age of segments, which never change once listed
+	SegmentMaxAge time.Duration `mapstructure:"segment_max_age"`
+	// PlaylistMaxAge is the Cache-Control max-age of playlists served as files, short as
+	// live playlists are rewritten every segment
+	PlaylistMaxAge time.Duration `mapstructure:"playlist_max_age"`
+	// Gzip compresses playlists for clients accepting it, segments are compressed already
+	Gzip bool `mapstructure:"gzip"`
+}
+
+func SetupStatic(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), false)
+	v.SetDefault(p("segment_max_age"), "8760h")
+	v.SetDefault(p("playlist_max_age"), "1s")
+	v.SetDefault(p("gzip"), true)
+}
+
+// artifacts maps the served file extensions to their artifact type and content type,
+// other files of room directories are not served
+var artifacts = map[string]struct {
+	kind        string
+	contentType string
+}{
+	".ts":   {artifactSegment, "video/mp2t"},
+	".m4s":  {artifactSegment, "video/iso.segment"},
+	".mp4":  {artifactSegment, "video/mp4"},
+	".aac":  {artifactSegment, "audio/aac"},
+	".m3u8": {artifactPlaylist, "application/vnd.apple.mpegurl"},
+}
+
+// getFile serves a segment or playlist of a room from the HLS directory, with ranges and
+// conditional requests, segments are sent with sendfile
+func (r *M3U8Router) getFile(c *gin.Context) {
+	var req GetFileRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	artifact, ok := artifacts[filepath.Ext(req.File)]
+	if !ok || strings.HasPrefix(req.File, ".") || strings.ContainsAny(req.File, `/\`) {
+		c.String(http.StatusNotFound, "File not found")
+		return
+	}
+
+	f, err := os.Open(filepath.Join(r.hlsDir, req.RoomID, req.File))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			r.logger.Error("Failed to open file",
+				log.String("roomId", req.RoomID),
+				log.String("file", req.File),
+				log.Error(err))
+		}
+		c.String(http.StatusNotFound, "File not found")
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		c.String(http.StatusNotFound, "File not found")
+		return
+	}
+
+	filesServed.Add(c.Request.Context(), 1, metric.WithAttributes(attribute.String("type", artifact.kind)))
+	c.Header("Content-Type", artifact.contentType)
+
+	if artifact.kind == artifactSegment {
+		c.Header("Cache-Control", cacheControl(r.static.SegmentMaxAge, true))
+		http.ServeContent(sendfileWriter{c.Writer}, c.Request, req.File, info.ModTime(), f)
+		return
+	}
+
+	c.Header("Cache-Control", cacheControl(r.static.PlaylistMaxAge, false))
+	if r.static.Gzip {
+		c.Header("Vary", "Accept-Encoding")
+	}
+	if !r.acceptsGzip(c.Request) {
+		http.ServeContent(sendfileWriter{c.Writer}, c.Request, req.File, info.ModTime(), f)
+		return
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		r.logger.Error("Failed to read playlist", log.String("roomId", req.RoomID), log.Error(err))
+		c.String(http.StatusNotFound, "File not found")
+		return
+	}
+	r.writePlaylist(c, data)
+}
+
+// writePlaylist writes a playlist, gzipped when enabled and accepted by the client
+func (r *M3U8Router) writePlaylist(c *gin.Context, data []byte) {
+	if r.static.Gzip {
+		c.Header("Vary", "Accept-Encoding")
+	}
+	if !r.acceptsGzip(c.Request) {
+		c.Data(http.StatusOK, "application/vnd.apple.mpegurl", data)
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil || zw.Close() != nil {
+		c.Data(http.StatusOK, "application/vnd.apple.mpegurl", data)
+		return
+	}
+	c.Header("Content-Encoding", "gzip")
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", buf.Bytes())
+}
+
+// acceptsGzip tells whether a playlist is gzipped for req, ranged requests are served
+// uncompressed as ranges address the file bytes
+func (r *M3U8Router) acceptsGzip(req *http.Request) bool {
+	if !r.static.Gzip || req.Header.Get("Range") != "" {
+		return false
+	}
+	for _, enc := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		name, q, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") && strings.ReplaceAll(q, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+func cacheControl(maxAge time.Duration, immutable bool) string {
+	if maxAge <= 0 {
+		return "no-cache"
+	}
+	value := fmt.Sprintf("public, max-age=%d", int64(maxAge/time.Second))
+	if immutable {
+		value += ", immutable"
+	}
+	return value
+}
+
+// sendfileWriter lets http.ServeContent reach the io.ReaderFrom of the net/http response,
+// which copies files to the connection with sendfile, gin's writer only implements Write
+type sendfileWriter struct {
+	gin.ResponseWriter
+}
+
+func (w sendfileWriter) ReadFrom(src io.Reader) (int64, error) {
+	w.WriteHeaderNow()
+	if u, ok := w.ResponseWriter.(interface{ Unwrap() http.ResponseWriter }); ok {
+		if rf, ok := u.Unwrap().(io.ReaderFrom); ok {
+			return rf.ReadFrom(src)
+		}
+	}
+	return io.Copy(w.ResponseWriter, src)
+}
@@ -0,0 +1,159 @@
+package transport_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/hlsserver/transport"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+var testSegment = bytes.Repeat([]byte("0123456789"), 100)
+
+func (s *M3U8RouterSuite) staticRouter(gzipped bool) *transport.M3U8Router {
+	s.Require().NoError(os.WriteFile(
+		filepath.Join(s.hlsDir, "room123", "segment_003.ts"), testSegment, 0o600))
+	s.Require().NoError(os.WriteFile(
+		filepath.Join(s.hlsDir, "room123", "index.m3u8"), []byte(testPlaylist), 0o600))
+	s.Require().NoError(os.WriteFile(
+		filepath.Join(s.hlsDir, "room123", ".hidden.ts"), testSegment, 0o600))
+
+	return transport.NewM3U8Router(s.mockWatcher, s.hlsDir, "", nil, &transport.StaticConfig{
+		Enabled:        true,
+		SegmentMaxAge:  365 * 24 * time.Hour,
+		PlaylistMaxAge: time.Second,
+		Gzip:           gzipped,
+	}, nil, nil, log.NewTest(s.T()))
+}
+
+func (s *M3U8RouterSuite) serve(router *transport.M3U8Router, method, target string, header http.Header) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	router.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func (s *M3U8RouterSuite) TestGetFile_Segment() {
+	router := s.staticRouter(false)
+
+	w := s.get(router, "/hls/room123/segment_003.ts")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Equal("video/mp2t", w.Header().Get("Content-Type"))
+	s.Equal("public, max-age=31536000, immutable", w.Header().Get("Cache-Control"))
+	s.Equal("bytes", w.Header().Get("Accept-Ranges"))
+	s.Equal(testSegment, w.Body.Bytes())
+
+	w = s.serve(router, http.MethodHead, "/hls/room123/segment_003.ts", nil)
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("1000", w.Header().Get("Content-Length"))
+	s.Zero(w.Body.Len())
+}
+
+func (s *M3U8RouterSuite) TestGetFile_Range() {
+	router := s.staticRouter(true)
+
+	w := s.serve(router, http.MethodGet, "/hls/room123/segment_003.ts", http.Header{"Range": {"bytes=10-19"}})
+	s.Require().Equal(http.StatusPartialContent, w.Code)
+	s.Equal("bytes 10-19/1000", w.Header().Get("Content-Range"))
+	s.Equal(testSegment[10:20], w.Body.Bytes())
+
+	// ranges address the file bytes, so ranged playlists are not gzipped
+	w = s.serve(router, http.MethodGet, "/hls/room123/index.m3u8", http.Header{
+		"Range":           {"bytes=0-6"},
+		"Accept-Encoding": {"gzip"},
+	})
+	s.Require().Equal(http.StatusPartialContent, w.Code)
+	s.Empty(w.Header().Get("Content-Encoding"))
+	s.Equal("#EXTM3U", w.Body.String())
+}
+
+func (s *M3U8RouterSuite) TestGetFile_NotModified() {
+	router := s.staticRouter(false)
+
+	w := s.get(router, "/hls/room123/segment_003.ts")
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	w = s.serve(router, http.MethodGet, "/hls/room123/segment_003.ts", http.Header{
+		"If-Modified-Since": {w.Header().Get("Last-Modified")},
+	})
+	s.Equal(http.StatusNotModified, w.Code)
+}
+
+func (s *M3U8RouterSuite) TestGetFile_Playlist() {
+	s.Run("plain", func() {
+		router := s.staticRouter(false)
+
+		w := s.serve(router, http.MethodGet, "/hls/room123/index.m3u8", http.Header{"Accept-Encoding": {"gzip"}})
+		s.Require().Equal(http.StatusOK, w.Code)
+		s.Equal("application/vnd.apple.mpegurl", w.Header().Get("Content-Type"))
+		s.Equal("public, max-age=1", w.Header().Get("Cache-Control"))
+		s.Empty(w.Header().Get("Content-Encoding"))
+		s.Equal(testPlaylist, w.Body.String())
+	})
+
+	s.Run("gzip", func() {
+		router := s.staticRouter(true)
+
+		w := s.serve(router, http.MethodGet, "/hls/room123/index.m3u8", http.Header{"Accept-Encoding": {"br, gzip"}})
+		s.Require().Equal(http.StatusOK, w.Code)
+		s.Equal("gzip", w.Header().Get("Content-Encoding"))
+		s.Equal("Accept-Encoding", w.Header().Get("Vary"))
+		zr, err := gzip.NewReader(w.Body)
+		s.Require().NoError(err)
+		data, err := io.ReadAll(zr)
+		s.Require().NoError(err)
+		s.Equal(testPlaylist, string(data))
+
+		// not accepted
+		w = s.get(router, "/hls/room123/index.m3u8")
+		s.Require().Equal(http.StatusOK, w.Code)
+		s.Empty(w.Header().Get("Content-Encoding"))
+		s.Equal(testPlaylist, w.Body.String())
+	})
+}
+
+func (s *M3U8RouterSuite) TestGetFile_NotServed() {
+	router := s.staticRouter(false)
+
+	for _, target := range []string{
+		"/hls/room123/.hidden.ts",
+		"/hls/room123/enc.key",
+		"/hls/room123/segment_999.ts",
+		"/hls/room123/..%2Froom123%2Fsegment_003.ts",
+		"/hls/room456/segment_003.ts",
+	} {
+		w := s.get(router, target)
+		s.Equal(http.StatusNotFound, w.Code, target)
+	}
+
+	w := s.get(router, "/hls/invalid@room/segment_003.ts")
+	s.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (s *M3U8RouterSuite) TestGetFile_Disabled() {
+	router := transport.NewM3U8Router(s.mockWatcher, s.hlsDir, "", nil, nil, nil, nil, log.NewTest(s.T()))
+	s.Require().NoError(os.WriteFile(
+		filepath.Join(s.hlsDir, "room123", "segment_003.ts"), testSegment, 0o600))
+
+	w := s.get(router, "/hls/room123/segment_003.ts")
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
+func (s *M3U8RouterSuite) TestGetPlaylist_Gzip() {
+	router := s.staticRouter(true)
+	s.activeRoom("room123")
+
+	w := s.serve(router, http.MethodGet, "/hls/room123/stream.m3u8", http.Header{"Accept-Encoding": {"gzip"}})
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Equal("gzip", w.Header().Get("Content-Encoding"))
+	s.Equal("no-cache, no-store, must-revalidate", w.Header().Get("Cache-Control"))
+}
@@ -0,0 +1,177 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tagMediaSequence  = "#EXT-X-MEDIA-SEQUENCE:"
+	tagDiscontSeq     = "#EXT-X-DISCONTINUITY-SEQUENCE:"
+	tagDiscontinuity  = "#EXT-X-DISCONTINUITY"
+	tagPlaylistType   = "#EXT-X-PLAYLIST-TYPE:"
+	tagProgramDate    = "#EXT-X-PROGRAM-DATE-TIME:"
+	tagKey            = "#EXT-X-KEY:"
+	tagInf            = "#EXTINF:"
+	tagEndList        = "#EXT-X-ENDLIST"
+	programDateLayout = "2006-01-02T15:04:05.999-0700" // written by FFmpeg
+)
+
+// headerTags apply to the whole playlist, other tags belong to the next segment
+var headerTags = []string{
+	"#EXTM3U",
+	"#EXT-X-VERSION:",
+	"#EXT-X-TARGETDURATION:",
+	tagMediaSequence,
+	tagDiscontSeq,
+	tagPlaylistType,
+	"#EXT-X-INDEPENDENT-SEGMENTS",
+	"#EXT-X-ALLOW-CACHE:",
+}
+
+type segment struct {
+	lines []string // tags and URI
+	at    time.Time
+	dur   time.Duration
+}
+
+// timeShift returns the playlist starting at the segment playing at start as an EVENT playlist,
+// so players begin there instead of at the live edge. Segments are located with the program
+// date time of DVR rooms, playlists without it are returned as is
+func timeShift(data []byte, start time.Time) []byte {
+	header, segments, tail := parsePlaylist(data)
+	if len(segments) == 0 || segments[0].at.IsZero() {
+		return data
+	}
+
+	// the segment playing at start, the live edge when start is ahead of it
+	first := len(segments) - 1
+	for i, seg := range segments {
+		if seg.at.Add(seg.dur).After(start) {
+			first = i
+			break
+		}
+	}
+
+	// tags of dropped segments still apply to the kept ones
+	var discontinuities int
+	var key string
+	for _, seg := range segments[:first] {
+		for _, line := range seg.lines {
+			switch {
+			case line == tagDiscontinuity:
+				discontinuities++
+			case strings.HasPrefix(line, tagKey):
+				key = line
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	writeLine := func(line string) {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	for _, line := range header {
+		switch {
+		case strings.HasPrefix(line, tagMediaSequence):
+			writeLine(tagMediaSequence + strconv.Itoa(tagInt(line)+first))
+			if discontinuities > 0 && !hasTag(header, tagDiscontSeq) {
+				writeLine(tagDiscontSeq + strconv.Itoa(discontinuities))
+			}
+		case strings.HasPrefix(line, tagDiscontSeq):
+			writeLine(tagDiscontSeq + strconv.Itoa(tagInt(line)+discontinuities))
+		case strings.HasPrefix(line, tagPlaylistType):
+			// replaced below
+		default:
+			writeLine(line)
+		}
+	}
+	writeLine(tagPlaylistType + "EVENT")
+	writeLine("#EXT-X-START:TIME-OFFSET=0,PRECISE=YES")
+
+	for i, seg := range segments[first:] {
+		if i == 0 && key != "" && !hasTag(seg.lines, tagKey) {
+			writeLine(key)
+		}
+		for _, line := range seg.lines {
+			writeLine(line)
+		}
+	}
+	for _, line := range tail {
+		writeLine(line)
+	}
+	return buf.Bytes()
+}
+
+// parsePlaylist splits a media playlist into header tags, segments and trailing tags,
+// segments without program date time follow the previous one
+func parsePlaylist(data []byte) (header []string, segments []*segment, tail []string) {
+	var pending []string
+	var at time.Time
+	var dur time.Duration
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case line == tagEndList:
+			tail = append(tail, line)
+		case segments == nil && pending == nil && isHeaderTag(line):
+			header = append(header, line)
+		case strings.HasPrefix(line, tagProgramDate):
+			if t, err := time.Parse(programDateLayout, strings.TrimPrefix(line, tagProgramDate)); err == nil {
+				at = t
+			} else if t, err := time.Parse(time.RFC3339Nano, strings.TrimPrefix(line, tagProgramDate)); err == nil {
+				at = t
+			}
+			pending = append(pending, line)
+		case strings.HasPrefix(line, tagInf):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, tagInf), ",")
+			if secs, err := strconv.ParseFloat(value, 64); err == nil {
+				dur = time.Duration(secs * float64(time.Second))
+			}
+			pending = append(pending, line)
+		case strings.HasPrefix(line, "#"):
+			pending = append(pending, line)
+		default:
+			if at.IsZero() && len(segments) > 0 {
+				if prev := segments[len(segments)-1]; !prev.at.IsZero() {
+					at = prev.at.Add(prev.dur)
+				}
+			}
+			segments = append(segments, &segment{lines: append(pending, line), at: at, dur: dur})
+			pending, at, dur = nil, time.Time{}, 0
+		}
+	}
+	return header, segments, tail
+}
+
+func isHeaderTag(line string) bool {
+	for _, tag := range headerTags {
+		if strings.HasPrefix(line, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTag(lines []string, tag string) bool {
+	for _, line := range lines {
+		if strings.HasPrefix(line, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func tagInt(line string) int {
+	_, value, _ := strings.Cut(line, ":")
+	n, _ := strconv.Atoi(value)
+	return n
+}
@@ -0,0 +1,83 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const dvrPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-KEY:METHOD=AES-128,URI="enc.key",IV=0x01
+#EXT-X-PROGRAM-DATE-TIME:2025-01-01T00:00:00.000+0000
+#EXTINF:2.000000,
+segment_010.ts
+#EXTINF:2.000000,
+segment_011.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-PROGRAM-DATE-TIME:2025-01-01T00:01:00.000+0000
+#EXTINF:2.000000,
+segment_012.ts
+#EXTINF:2.000000,
+segment_013.ts
+`
+
+func TestTimeShift(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("starts at segment playing at start", func(t *testing.T) {
+		got := timeShift([]byte(dvrPlaylist), base.Add(61*time.Second))
+
+		assert.Equal(t, `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:12
+#EXT-X-PLAYLIST-TYPE:EVENT
+#EXT-X-START:TIME-OFFSET=0,PRECISE=YES
+#EXT-X-KEY:METHOD=AES-128,URI="enc.key",IV=0x01
+#EXT-X-DISCONTINUITY
+#EXT-X-PROGRAM-DATE-TIME:2025-01-01T00:01:00.000+0000
+#EXTINF:2.000000,
+segment_012.ts
+#EXTINF:2.000000,
+segment_013.ts
+`, string(got))
+	})
+
+	t.Run("segments without date follow the previous one", func(t *testing.T) {
+		got := string(timeShift([]byte(dvrPlaylist), base.Add(3*time.Second)))
+
+		assert.Contains(t, got, "#EXT-X-MEDIA-SEQUENCE:11\n")
+		assert.NotContains(t, got, "segment_010.ts")
+		assert.Contains(t, got, "segment_011.ts")
+	})
+
+	t.Run("before window keeps all segments", func(t *testing.T) {
+		got := string(timeShift([]byte(dvrPlaylist), base.Add(-time.Hour)))
+
+		assert.Contains(t, got, "#EXT-X-MEDIA-SEQUENCE:10\n")
+		assert.Contains(t, got, "#EXT-X-PLAYLIST-TYPE:EVENT\n")
+		assert.Contains(t, got, "segment_010.ts")
+		assert.NotContains(t, got, "DISCONTINUITY-SEQUENCE")
+	})
+
+	t.Run("after live edge keeps last segment", func(t *testing.T) {
+		got := string(timeShift([]byte(dvrPlaylist), base.Add(time.Hour)))
+
+		assert.Contains(t, got, "#EXT-X-MEDIA-SEQUENCE:13\n")
+		// the dropped segment started a discontinuity
+		assert.Contains(t, got, "#EXT-X-DISCONTINUITY-SEQUENCE:1\n")
+		assert.Contains(t, got, "#EXT-X-KEY:")
+		assert.Contains(t, got, "segment_013.ts")
+		assert.NotContains(t, got, "segment_012.ts")
+	})
+
+	t.Run("playlist without program date time", func(t *testing.T) {
+		live := "#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:3\n#EXTINF:2.000000,\nsegment_003.ts\n"
+
+		assert.Equal(t, live, string(timeShift([]byte(live), base)))
+	})
+}
@@ -0,0 +1,28 @@
+package transport
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/imtaco/audio-rtc-exp/internal/usage"
+)
+
+// UsageRecorder accumulates the bytes served for rooms, for billing
+type UsageRecorder interface {
+	Add(roomID string, kind usage.Kind, bytes int64)
+}
+
+// accountUsage adds the body bytes of successful responses of room routes to the HLS usage of
+// the room
+func accountUsage(recorder UsageRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		roomID := c.Param("roomId")
+		if roomID == "" || c.Writer.Status() >= http.StatusBadRequest || c.Writer.Size() <= 0 {
+			return
+		}
+		recorder.Add(roomID, usage.KindHLS, int64(c.Writer.Size()))
+	}
+}
@@ -0,0 +1,61 @@
+package transport_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/imtaco/audio-rtc-exp/hlsserver/transport"
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/usage"
+)
+
+// fakeUsage sums the accounted bytes per room and kind
+type fakeUsage map[string]map[usage.Kind]int64
+
+func (f fakeUsage) Add(roomID string, kind usage.Kind, bytes int64) {
+	if f[roomID] == nil {
+		f[roomID] = map[usage.Kind]int64{}
+	}
+	f[roomID][kind] += bytes
+}
+
+func (s *M3U8RouterSuite) TestGetPlaylist_AccountsUsage() {
+	recorder := fakeUsage{}
+	router := transport.NewM3U8Router(s.mockWatcher, s.hlsDir, "", nil, nil, nil, recorder, log.NewTest(s.T()))
+	s.activeRoom("room123")
+	s.mockWatcher.EXPECT().GetActiveLiveMeta("room404").Return(nil)
+
+	w := s.get(router, "/hls/room123/stream.m3u8")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Require().Equal(http.StatusNotFound, s.get(router, "/hls/room404/stream.m3u8").Code)
+	s.Require().Equal(http.StatusNotFound, s.get(router, "/health/none").Code)
+
+	// failed requests are not billed
+	s.Equal(fakeUsage{"room123": {usage.KindHLS: int64(w.Body.Len())}}, recorder)
+}
+
+func (s *RouterSuite) TestKeyRouter_AccountsUsage() {
+	recorder := fakeUsage{}
+	router := transport.NewKeyRouter(s.mockWatcher, s.jwtAuth, nil, nil, recorder, log.NewTest(s.T()))
+	roomID := "usageRoom"
+	s.mockWatcher.EXPECT().GetActiveLiveMeta(roomID).Return(&etcdstate.LiveMeta{
+		Status: constants.RoomStatusOnAir,
+		Nonce:  "nonce123",
+	})
+
+	get := func(token string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hls/rooms/"+roomID+"/enc.key", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.Handler().ServeHTTP(w, req)
+		return w.Code
+	}
+	token, _ := s.jwtAuth.Sign("user1", roomID, constants.UserRoleGuest)
+	s.Equal(http.StatusOK, get(token))
+	s.Equal(http.StatusForbidden, get("invalidtoken"))
+
+	// AES-128 keys are 16 bytes
+	s.Equal(fakeUsage{roomID: {usage.KindHLS: 16}}, recorder)
+}
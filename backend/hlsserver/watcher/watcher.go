@@ -5,13 +5,13 @@ import (
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
-	etcdwatcher "github.com/imtaco/audio-rtc-exp/internal/reswatcher/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/roomstate"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 type roomWatcherImpl struct {
-	etcdwatcher.RoomWatcher
+	roomstate.Watcher
 }
 
 func NewRoomWatcher(
@@ -19,61 +19,16 @@ func NewRoomWatcher(
 	prefixRooms string,
 	logger *log.Logger,
 ) hlsserver.RoomWatcher {
-	// cache, _ := lru.New[string, http.Handler](123)
-
 	return &roomWatcherImpl{
-		RoomWatcher: etcdwatcher.NewRoomWatcher(
-			etcdClient,
-			prefixRooms,
-			[]string{constants.RoomKeyLiveMeta, constants.RoomKeyMixer},
-			nil, // use default processChange (do nothing)
-			logger,
-		),
-		// handlerCache: cache,
+		Watcher: roomstate.New(&roomstate.Config{
+			Client:   etcdClient,
+			Prefix:   prefixRooms,
+			KeyTypes: []string{constants.RoomKeyMeta, constants.RoomKeyLiveMeta, constants.RoomKeyMixer},
+			Logger:   logger,
+		}),
 	}
 }
 
 func (w *roomWatcherImpl) GetActiveLiveMeta(roomID string) *etcdstate.LiveMeta {
-	state, ok := w.GetCachedState(roomID)
-	if !ok || state == nil {
-		return nil
-	}
-
-	livemeta := state.LiveMeta
-	if livemeta != nil && livemeta.Status == constants.RoomStatusOnAir {
-		return livemeta
-	}
-
-	return nil
+	return roomstate.OnAirLiveMeta(w, roomID)
 }
-
-// func (w *roomWatcherImpl) GetMixer(roomID string) http.Handler {
-// 	state, ok := w.GetCachedState(roomID)
-// 	if !ok || state == nil {
-// 		return nil
-// 	}
-
-// 	// no longer existed
-// 	mixer := state.GetMixer()
-// 	if mixer != nil || mixer.GetIP() == "" {
-// 		return nil
-// 	}
-
-// 	result, _, _ := w.sfMixer.Do(roomID, func() (any, error) {
-// 		handler, ok := w.handlerMap.Load(roomID)
-// 		if ok {
-// 			return handler, nil
-// 		}
-
-// 		// TODO: fix port
-// 		addr := url.URL{
-// 			Scheme: "http",
-// 			Host:   fmt.Sprintf("%s:%d", mixer.GetIP(), mixer.GetPort()),
-// 		}
-// 		handler = httputil.NewSingleHostReverseProxy(&addr)
-// 		w.handlerMap.Store(roomID, handler)
-// 		return handler, nil
-// 	})
-
-// 	return result.(http.Handler)
-// }
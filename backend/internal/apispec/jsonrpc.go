@@ -0,0 +1,73 @@
+package apispec
+
+import "net/http"
+
+// RPCDocument describes the JSON-RPC 2.0 methods exposed over a websocket endpoint.
+// OpenAPI has no first class support for JSON-RPC, so methods are listed with plain
+// JSON schemas of their params and results instead.
+type RPCDocument struct {
+	JSONRPC       string           `json:"jsonrpc"`
+	Info          Info             `json:"info"`
+	Methods       []*RPCMethodSpec `json:"methods"`
+	Notifications []*RPCMethodSpec `json:"notifications,omitempty"`
+}
+
+type RPCMethodSpec struct {
+	Name    string  `json:"name"`
+	Summary string  `json:"summary,omitempty"`
+	Params  *Schema `json:"params"`
+	Result  *Schema `json:"result,omitempty"`
+}
+
+// RPCMethod describes a single JSON-RPC method, Params and Result are sample values
+type RPCMethod struct {
+	Name    string
+	Summary string
+	Params  any
+	Result  any
+}
+
+// RPCSpec collects JSON-RPC methods and serves them as an RPCDocument
+type RPCSpec struct {
+	doc *RPCDocument
+}
+
+func NewRPC(title, version string) *RPCSpec {
+	return &RPCSpec{
+		doc: &RPCDocument{
+			JSONRPC: "2.0",
+			Info:    Info{Title: title, Version: version},
+		},
+	}
+}
+
+// Method registers a client to server method
+func (s *RPCSpec) Method(m RPCMethod) {
+	s.doc.Methods = append(s.doc.Methods, methodSpec(m))
+}
+
+// Notification registers a server to client notification
+func (s *RPCSpec) Notification(m RPCMethod) {
+	s.doc.Notifications = append(s.doc.Notifications, methodSpec(m))
+}
+
+// Document returns the generated document
+func (s *RPCSpec) Document() *RPCDocument {
+	return s.doc
+}
+
+func (s *RPCSpec) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, s.doc)
+}
+
+func methodSpec(m RPCMethod) *RPCMethodSpec {
+	spec := &RPCMethodSpec{
+		Name:    m.Name,
+		Summary: m.Summary,
+		Params:  SchemaOf(m.Params),
+	}
+	if m.Result != nil {
+		spec.Result = SchemaOf(m.Result)
+	}
+	return spec
+}
@@ -0,0 +1,181 @@
+package apispec
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/imtaco/audio-rtc-exp/internal/validation"
+)
+
+const (
+	openAPIVersion = "3.0.3"
+	contentJSON    = "application/json"
+)
+
+// Document is the subset of the OpenAPI 3 document model needed to describe our REST APIs
+type Document struct {
+	OpenAPI string               `json:"openapi"`
+	Info    Info                 `json:"info"`
+	Paths   map[string]*PathItem `json:"paths"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem maps lower-cased HTTP methods to operations
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Route describes a single REST endpoint, it is registered next to the gin handler
+// so the published spec never drifts away from the actual routes.
+type Route struct {
+	Method  string
+	Path    string // gin style path, e.g. /api/rooms/:roomId
+	Name    string // operationId, used by client generators as method name
+	Summary string
+	URI     any // struct bound with ShouldBindUri
+	Query   any // struct bound with ShouldBindQuery
+	Body    any // struct bound with ShouldBindJSON
+	// Responses maps status codes to a sample value (e.g. gin.H envelope), nil means no body
+	Responses map[int]any
+}
+
+// Spec collects routes and serves them as an OpenAPI document
+type Spec struct {
+	doc *Document
+}
+
+func New(title, version string) *Spec {
+	return &Spec{
+		doc: &Document{
+			OpenAPI: openAPIVersion,
+			Info:    Info{Title: title, Version: version},
+			Paths:   make(map[string]*PathItem),
+		},
+	}
+}
+
+// Add registers a route into the document
+func (s *Spec) Add(r Route) {
+	path := openAPIPath(r.Path)
+	item, ok := s.doc.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		s.doc.Paths[path] = item
+	}
+
+	op := &Operation{
+		OperationID: r.Name,
+		Summary:     r.Summary,
+		Responses:   make(map[string]*Response),
+	}
+	op.Parameters = append(op.Parameters, parametersOf(r.URI, "path", "uri")...)
+	op.Parameters = append(op.Parameters, parametersOf(r.Query, "query", "form")...)
+
+	if r.Body != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{contentJSON: {Schema: SchemaOf(r.Body)}},
+		}
+	}
+
+	for code, sample := range r.Responses {
+		resp := &Response{Description: http.StatusText(code)}
+		if sample != nil {
+			resp.Content = map[string]*MediaType{contentJSON: {Schema: SchemaOf(sample)}}
+		}
+		op.Responses[strconv.Itoa(code)] = resp
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["default"] = &Response{Description: "Response"}
+	}
+
+	(*item)[strings.ToLower(r.Method)] = op
+}
+
+// Document returns the generated document
+func (s *Spec) Document() *Document {
+	return s.doc
+}
+
+func (s *Spec) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, s.doc)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", contentJSON)
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// openAPIPath converts gin path params (:roomId) to OpenAPI templates ({roomId})
+func openAPIPath(path string) string {
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			segs[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+func parametersOf(v any, in, tagName string) []*Parameter {
+	if v == nil {
+		return nil
+	}
+	var params []*Parameter
+	for _, f := range fieldsOf(v, tagName) {
+		params = append(params, &Parameter{
+			Name:     f.name,
+			In:       in,
+			Required: f.required || in == "path",
+			Schema:   f.schema,
+		})
+	}
+	return params
+}
+
+// Response envelopes shared by the routers
+var (
+	ErrorResponse = map[string]any{
+		"success": false,
+		"error":   "",
+	}
+	ValidationErrorResponse = map[string]any{
+		"success": false,
+		"error":   "",
+		"details": []validation.Error{},
+	}
+)
@@ -0,0 +1,150 @@
+package apispec
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/validation"
+)
+
+type testURI struct {
+	RoomID string `uri:"roomId" binding:"required,roomid"`
+}
+
+type testQuery struct {
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type testBody struct {
+	Name     string    `json:"name" binding:"required,min=3,max=32"`
+	Role     string    `json:"role,omitempty" binding:"omitempty,role"`
+	UserID   string    `json:"userId" binding:"userid"`
+	Port     *int      `json:"port,omitempty"`
+	Tags     []string  `json:"tags"`
+	At       time.Time `json:"at"`
+	Ignored  string    `json:"-"`
+	internal string
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	assert.Equal(t, "/api/rooms/{roomId}", openAPIPath("/api/rooms/:roomId"))
+	assert.Equal(t, "/api/modules/{moduleType}/{moduleId}/mark", openAPIPath("/api/modules/:moduleType/:moduleId/mark"))
+	assert.Equal(t, "/files/{path}", openAPIPath("/files/*path"))
+	assert.Equal(t, "/health", openAPIPath("/health"))
+}
+
+func TestSchemaOf(t *testing.T) {
+	t.Run("Struct", func(t *testing.T) {
+		s := SchemaOf(testBody{})
+
+		assert.Equal(t, "object", s.Type)
+		assert.Equal(t, []string{"name"}, s.Required)
+		assert.Len(t, s.Properties, 6)
+		assert.NotContains(t, s.Properties, "Ignored")
+		assert.NotContains(t, s.Properties, "internal")
+
+		name := s.Properties["name"]
+		assert.Equal(t, "string", name.Type)
+		assert.Equal(t, 3, *name.MinLength)
+		assert.Equal(t, 32, *name.MaxLength)
+
+		// custom alias expands to oneof
+		assert.Equal(t, []string{"host", "guest", "anchor"}, s.Properties["role"].Enum)
+		assert.Equal(t, "uuid", s.Properties["userId"].Format)
+
+		assert.Equal(t, "integer", s.Properties["port"].Type)
+		assert.True(t, s.Properties["port"].Nullable)
+		assert.Equal(t, "array", s.Properties["tags"].Type)
+		assert.Equal(t, "string", s.Properties["tags"].Items.Type)
+		assert.Equal(t, "date-time", s.Properties["at"].Format)
+	})
+
+	t.Run("Envelope", func(t *testing.T) {
+		s := SchemaOf(map[string]any{
+			"success": true,
+			"count":   0,
+			"body":    testBody{},
+		})
+
+		assert.Equal(t, "object", s.Type)
+		assert.Equal(t, "boolean", s.Properties["success"].Type)
+		assert.Equal(t, "integer", s.Properties["count"].Type)
+		assert.Equal(t, "object", s.Properties["body"].Type)
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		assert.Equal(t, &Schema{}, SchemaOf(nil))
+	})
+}
+
+func TestSpec(t *testing.T) {
+	spec := New("Test API", "1.0.0")
+	spec.Add(Route{
+		Method:  http.MethodPost,
+		Path:    "/api/rooms/:roomId/items",
+		Name:    "createItem",
+		Summary: "Create an item",
+		URI:     testURI{},
+		Query:   testQuery{},
+		Body:    testBody{},
+		Responses: map[int]any{
+			http.StatusOK:         map[string]any{"success": true},
+			http.StatusBadRequest: ValidationErrorResponse,
+			http.StatusNotFound:   nil,
+		},
+	})
+
+	item, ok := spec.Document().Paths["/api/rooms/{roomId}/items"]
+	require.True(t, ok)
+	op := (*item)["post"]
+	require.NotNil(t, op)
+
+	assert.Equal(t, "createItem", op.OperationID)
+	require.Len(t, op.Parameters, 2)
+	assert.Equal(t, "roomId", op.Parameters[0].Name)
+	assert.Equal(t, "path", op.Parameters[0].In)
+	assert.True(t, op.Parameters[0].Required)
+	assert.Equal(t, validation.RoomIDPattern, op.Parameters[0].Schema.Pattern)
+	assert.Equal(t, "limit", op.Parameters[1].Name)
+	assert.Equal(t, "query", op.Parameters[1].In)
+	assert.False(t, op.Parameters[1].Required)
+
+	require.NotNil(t, op.RequestBody)
+	assert.Contains(t, op.RequestBody.Content, contentJSON)
+	assert.Contains(t, op.Responses, "200")
+	assert.Nil(t, op.Responses["404"].Content)
+
+	w := httptest.NewRecorder()
+	spec.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/spec", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, openAPIVersion, doc["openapi"])
+}
+
+func TestRPCSpec(t *testing.T) {
+	spec := NewRPC("Test RPC", "1.0.0")
+	spec.Method(RPCMethod{
+		Name:   "join",
+		Params: testBody{},
+		Result: map[string]any{"ok": true},
+	})
+	spec.Method(RPCMethod{Name: "leave"})
+	spec.Notification(RPCMethod{Name: "status", Params: []string{}})
+
+	doc := spec.Document()
+	require.Len(t, doc.Methods, 2)
+	assert.Equal(t, "join", doc.Methods[0].Name)
+	assert.Equal(t, "object", doc.Methods[0].Params.Type)
+	assert.Equal(t, "boolean", doc.Methods[0].Result.Properties["ok"].Type)
+	assert.Nil(t, doc.Methods[1].Result)
+	require.Len(t, doc.Notifications, 1)
+	assert.Equal(t, "array", doc.Notifications[0].Params.Type)
+}
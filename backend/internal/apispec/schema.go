@@ -0,0 +1,236 @@
+package apispec
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/validation"
+)
+
+// Schema is the JSON schema subset shared by OpenAPI and the JSON-RPC description
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// SchemaOf builds a schema from a sample value.
+// Structs are described from their json and binding/validate tags, while maps with
+// string keys (like gin.H) are described from the dynamic type of each entry, which
+// allows documenting the ad-hoc response envelopes used by the routers.
+func SchemaOf(v any) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String && rv.Type().Elem().Kind() == reflect.Interface {
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for _, key := range rv.MapKeys() {
+			s.Properties[key.String()] = SchemaOf(rv.MapIndex(key).Interface())
+		}
+		return s
+	}
+	return schemaOfType(rv.Type(), map[reflect.Type]bool{})
+}
+
+func schemaOfType(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var s *Schema
+	switch {
+	case t == timeType:
+		s = &Schema{Type: "string", Format: "date-time"}
+	case t == durationType:
+		s = &Schema{Type: "integer", Format: "int64"}
+	case t == rawMessageType:
+		s = &Schema{}
+	default:
+		s = schemaOfKind(t, visiting)
+	}
+	s.Nullable = nullable
+	return s
+}
+
+func schemaOfKind(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: schemaOfType(t.Elem(), visiting)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOfType(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			// recursive type, stop here
+			return &Schema{Type: "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for _, f := range structFields(t, "json", visiting) {
+			s.Properties[f.name] = f.schema
+			if f.required {
+				s.Required = append(s.Required, f.name)
+			}
+		}
+		sort.Strings(s.Required)
+		return s
+	default:
+		// interface{} and anything else accepts any JSON value
+		return &Schema{}
+	}
+}
+
+type field struct {
+	name     string
+	required bool
+	schema   *Schema
+}
+
+func fieldsOf(v any, tagName string) []field {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return structFields(t, tagName, map[reflect.Type]bool{t: true})
+}
+
+func structFields(t reflect.Type, tagName string, visiting map[reflect.Type]bool) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, tagged := tagFieldName(sf.Tag.Get(tagName))
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && !tagged && sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, structFields(sf.Type, tagName, visiting)...)
+			continue
+		}
+		if name == "" {
+			if tagName != "json" {
+				// uri/form params must be tagged explicitly
+				continue
+			}
+			name = sf.Name
+		}
+
+		schema := schemaOfType(sf.Type, visiting)
+		rules := sf.Tag.Get("binding")
+		if rules == "" {
+			rules = sf.Tag.Get("validate")
+		}
+		required := applyRules(schema, rules)
+
+		fields = append(fields, field{name: name, required: required, schema: schema})
+	}
+	return fields
+}
+
+func tagFieldName(tag string) (string, bool) {
+	if tag == "" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name, true
+}
+
+// applyRules maps validator rules onto schema constraints, returns whether the field is required
+func applyRules(s *Schema, rules string) bool {
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		key, arg, _ := strings.Cut(rule, "=")
+		if alias, ok := validation.Aliases[key]; ok {
+			applyRules(s, alias)
+			continue
+		}
+
+		switch key {
+		case "required":
+			required = true
+		case "roomid":
+			s.Pattern = validation.RoomIDPattern
+		case "uuid4", "uuid":
+			s.Format = "uuid"
+		case "alphanum":
+			s.Pattern = "^[A-Za-z0-9]*$"
+		case "oneof":
+			s.Enum = strings.Fields(arg)
+		case "min", "max", "len":
+			applyBound(s, key, arg)
+		}
+	}
+	return required
+}
+
+func applyBound(s *Schema, key, arg string) {
+	n, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return
+	}
+
+	switch s.Type {
+	case "array", "object":
+		return
+	case "string":
+		l := int(n)
+		if key != "max" {
+			s.MinLength = &l
+		}
+		if key != "min" {
+			s.MaxLength = &l
+		}
+		return
+	}
+
+	if key != "max" {
+		s.Minimum = &n
+	}
+	if key != "min" {
+		s.Maximum = &n
+	}
+}
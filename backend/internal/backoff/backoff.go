@@ -0,0 +1,195 @@
+// Package backoff computes the delays between retries and retries operations with them
+package backoff
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy returns the delays between retries
+type Policy interface {
+	// Delay returns the wait before retry attempt, counting from 0 for the first retry
+	Delay(attempt int) time.Duration
+}
+
+// maxDelay caps the delays of policies without Max
+const maxDelay = 24 * time.Hour
+
+// random returns a number in [0, 1) (can be replaced for testing)
+var random = rand.Float64
+
+// Exponential multiplies the delay on each attempt from Initial up to Max
+type Exponential struct {
+	Initial time.Duration
+	// Max caps the delay, a day when unset
+	Max time.Duration
+	// Multiplier grows the delay on each attempt, 2 when unset
+	Multiplier float64
+	// Jitter spreads the delay by up to this fraction either way, e.g. 0.2 for ±20%
+	Jitter float64
+}
+
+func (e Exponential) Delay(attempt int) time.Duration {
+	multiplier := e.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	limit := capOf(e.Max)
+	delay := float64(e.Initial)
+	for range max(attempt, 0) {
+		delay *= multiplier
+		// stop growing once capped, also keeps large attempts from overflowing
+		if delay >= float64(limit) {
+			return jitter(limit, e.Jitter)
+		}
+	}
+	return jitter(time.Duration(delay), e.Jitter)
+}
+
+// Constant waits the same delay before every attempt
+type Constant struct {
+	Interval time.Duration
+	// Jitter spreads the delay by up to this fraction either way
+	Jitter float64
+}
+
+func (c Constant) Delay(int) time.Duration {
+	return jitter(c.Interval, c.Jitter)
+}
+
+// Fibonacci grows the delay as Initial times the Fibonacci sequence up to Max, slower than
+// Exponential for the first attempts
+type Fibonacci struct {
+	Initial time.Duration
+	// Max caps the delay, a day when unset
+	Max time.Duration
+	// Jitter spreads the delay by up to this fraction either way
+	Jitter float64
+}
+
+func (f Fibonacci) Delay(attempt int) time.Duration {
+	limit := capOf(f.Max)
+	prev, cur := time.Duration(0), f.Initial
+	for range max(attempt, 0) {
+		prev, cur = cur, prev+cur
+		if cur >= limit {
+			return jitter(limit, f.Jitter)
+		}
+	}
+	return jitter(min(cur, limit), f.Jitter)
+}
+
+func capOf(limit time.Duration) time.Duration {
+	if limit <= 0 {
+		return maxDelay
+	}
+	return limit
+}
+
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || d <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + fraction*(2*random()-1)))
+}
+
+// Sleep waits d unless ctx is done first, in which case the context error is returned
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// permanentError stops Retry
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, Retry returns err right away
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+type options struct {
+	maxAttempts int
+	maxElapsed  time.Duration
+	onRetry     func(attempt int, err error, delay time.Duration)
+}
+
+// Option shapes Retry
+type Option func(*options)
+
+// WithMaxAttempts bounds the calls of the operation, the first one included. Unbounded by default
+func WithMaxAttempts(attempts int) Option {
+	return func(o *options) {
+		o.maxAttempts = attempts
+	}
+}
+
+// WithMaxElapsed gives up once the next retry would start more than d after the first call.
+// Unbounded by default
+func WithMaxElapsed(d time.Duration) Option {
+	return func(o *options) {
+		o.maxElapsed = d
+	}
+}
+
+// WithOnRetry calls fn after a failed call, with the retry attempt counting from 0, the error
+// and the delay before the retry, e.g. to log or count retries
+func WithOnRetry(fn func(attempt int, err error, delay time.Duration)) Option {
+	return func(o *options) {
+		o.onRetry = fn
+	}
+}
+
+// Retry calls operation until it succeeds, waiting the delays of policy between calls. It
+// gives up on a Permanent error, once the attempts or the elapsed time are exhausted or when
+// ctx is done, returning the last error of operation or the context error
+func Retry(ctx context.Context, policy Policy, operation func(ctx context.Context) error, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	start := time.Now()
+
+	for attempt := 0; ; attempt++ {
+		err := operation(ctx)
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if o.maxAttempts > 0 && attempt+1 >= o.maxAttempts {
+			return err
+		}
+
+		delay := policy.Delay(attempt)
+		if o.maxElapsed > 0 && time.Since(start)+delay > o.maxElapsed {
+			return err
+		}
+		if o.onRetry != nil {
+			o.onRetry(attempt, err, delay)
+		}
+		if err := Sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
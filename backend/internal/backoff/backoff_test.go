@@ -0,0 +1,140 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixRandom makes jitter pick r
+func fixRandom(t *testing.T, r float64) {
+	orig := random
+	t.Cleanup(func() { random = orig })
+	random = func() float64 { return r }
+}
+
+func TestExponential(t *testing.T) {
+	policy := Exponential{Initial: 100 * time.Millisecond, Max: 10 * time.Second}
+
+	assert.Equal(t, 100*time.Millisecond, policy.Delay(0))
+	assert.Equal(t, 200*time.Millisecond, policy.Delay(1))
+	assert.Equal(t, 800*time.Millisecond, policy.Delay(3))
+	assert.Equal(t, 10*time.Second, policy.Delay(7))
+	assert.Equal(t, 10*time.Second, policy.Delay(10000))
+
+	policy.Multiplier = 3
+	assert.Equal(t, 900*time.Millisecond, policy.Delay(2))
+}
+
+func TestConstant(t *testing.T) {
+	policy := Constant{Interval: time.Second}
+
+	assert.Equal(t, time.Second, policy.Delay(0))
+	assert.Equal(t, time.Second, policy.Delay(50))
+}
+
+func TestFibonacci(t *testing.T) {
+	policy := Fibonacci{Initial: time.Second, Max: 10 * time.Second}
+
+	var delays []time.Duration
+	for attempt := range 7 {
+		delays = append(delays, policy.Delay(attempt))
+	}
+	assert.Equal(t, []time.Duration{
+		time.Second, time.Second, 2 * time.Second, 3 * time.Second, 5 * time.Second, 8 * time.Second, 10 * time.Second,
+	}, delays)
+	assert.Equal(t, 10*time.Second, policy.Delay(10000))
+}
+
+func TestJitter(t *testing.T) {
+	policy := Exponential{Initial: time.Second, Max: 4 * time.Second, Jitter: 0.5}
+
+	fixRandom(t, 0)
+	assert.Equal(t, 500*time.Millisecond, policy.Delay(0))
+	assert.Equal(t, 2*time.Second, policy.Delay(5))
+
+	fixRandom(t, 0.75)
+	assert.Equal(t, 1250*time.Millisecond, policy.Delay(0))
+	assert.Equal(t, 1250*time.Millisecond, Constant{Interval: time.Second, Jitter: 0.5}.Delay(3))
+}
+
+func TestRetry(t *testing.T) {
+	policy := Constant{Interval: time.Millisecond}
+
+	t.Run("retries until success", func(t *testing.T) {
+		calls := 0
+		var retries []int
+		err := Retry(context.Background(), policy, func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return errors.New("not yet")
+			}
+			return nil
+		}, WithOnRetry(func(attempt int, err error, delay time.Duration) {
+			retries = append(retries, attempt)
+			assert.EqualError(t, err, "not yet")
+			assert.Equal(t, time.Millisecond, delay)
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, []int{0, 1}, retries)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		calls := 0
+		err := Retry(context.Background(), policy, func(context.Context) error {
+			calls++
+			return errors.New("down")
+		}, WithMaxAttempts(3))
+		require.EqualError(t, err, "down")
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("gives up after max elapsed", func(t *testing.T) {
+		calls := 0
+		err := Retry(context.Background(), Constant{Interval: time.Hour}, func(context.Context) error {
+			calls++
+			return errors.New("down")
+		}, WithMaxElapsed(time.Minute))
+		require.EqualError(t, err, "down")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("stops on permanent errors", func(t *testing.T) {
+		errBad := errors.New("bad request")
+		calls := 0
+		err := Retry(context.Background(), policy, func(context.Context) error {
+			calls++
+			return Permanent(errBad)
+		})
+		require.ErrorIs(t, err, errBad)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := Retry(ctx, Constant{Interval: time.Hour}, func(context.Context) error {
+			cancel()
+			return errors.New("down")
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestSleep(t *testing.T) {
+	require.NoError(t, Sleep(context.Background(), time.Millisecond))
+	require.NoError(t, Sleep(context.Background(), 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, Sleep(ctx, time.Hour), context.Canceled)
+}
+
+func TestUncapped(t *testing.T) {
+	assert.Equal(t, maxDelay, Exponential{Initial: time.Second}.Delay(1000))
+	assert.Equal(t, maxDelay, Fibonacci{Initial: time.Second}.Delay(1000))
+}
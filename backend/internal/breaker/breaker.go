@@ -0,0 +1,91 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// Breaker fails fast after threshold consecutive failures, once openFor elapsed a single
+// trial call is let through and its outcome closes or reopens it.
+// A threshold of 0 or less never opens
+type Breaker struct {
+	threshold int
+	openFor   time.Duration
+	clock     clockwork.Clock
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func New(threshold int, openFor time.Duration, clock clockwork.Clock) *Breaker {
+	return &Breaker{threshold: threshold, openFor: openFor, clock: clock}
+}
+
+// Allow reports whether a call may be made, callers allowed must report its outcome
+// through Record or Forget
+func (b *Breaker) Allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.trial || b.clock.Now().Before(b.openUntil) {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// Record reports the outcome of an allowed call, returns true when it opened the breaker,
+// including reopening after a failed trial
+func (b *Breaker) Record(ok bool) bool {
+	if b.threshold <= 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if ok {
+		b.failures = 0
+		return false
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.clock.Now().Add(b.openFor)
+		return true
+	}
+	return false
+}
+
+// Forget reports an allowed call without outcome, e.g. canceled by its caller
+func (b *Breaker) Forget() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// IsOpen reports whether calls currently fail fast
+func (b *Breaker) IsOpen() bool {
+	if b.threshold <= 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.threshold
+}
@@ -0,0 +1,72 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Run("opens after threshold failures", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		b := New(2, time.Minute, clock)
+
+		assert.True(t, b.Allow())
+		assert.False(t, b.Record(false))
+		assert.True(t, b.Allow())
+		assert.True(t, b.Record(false))
+		assert.True(t, b.IsOpen())
+		assert.False(t, b.Allow())
+	})
+
+	t.Run("success resets failures", func(t *testing.T) {
+		b := New(2, time.Minute, clockwork.NewFakeClock())
+
+		b.Record(false)
+		b.Record(true)
+		assert.False(t, b.Record(false))
+		assert.True(t, b.Allow())
+	})
+
+	t.Run("half open lets a single trial through", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		b := New(1, time.Minute, clock)
+		b.Record(false)
+
+		clock.Advance(time.Minute)
+		assert.True(t, b.Allow())
+		assert.False(t, b.Allow(), "trial in flight")
+
+		// failed trial reopens
+		assert.True(t, b.Record(false))
+		assert.False(t, b.Allow())
+
+		clock.Advance(time.Minute)
+		assert.True(t, b.Allow())
+		assert.False(t, b.Record(true))
+		assert.False(t, b.IsOpen())
+		assert.True(t, b.Allow())
+	})
+
+	t.Run("forget releases the trial", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		b := New(1, time.Minute, clock)
+		b.Record(false)
+
+		clock.Advance(time.Minute)
+		assert.True(t, b.Allow())
+		b.Forget()
+		assert.True(t, b.Allow())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		b := New(0, time.Minute, clockwork.NewFakeClock())
+		for range 5 {
+			assert.False(t, b.Record(false))
+		}
+		assert.True(t, b.Allow())
+		assert.False(t, b.IsOpen())
+	})
+}
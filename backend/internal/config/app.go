@@ -9,6 +9,10 @@ import (
 type App struct {
 	LogConfigFile   string        `mapstructure:"log_config_file"`
 	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// AdminAddr serves internal endpoints like the log levels, empty disables it
+	AdminAddr string `mapstructure:"admin_addr"`
+	// LogLevelsKey is the etcd key of log level overrides, empty disables watching it
+	LogLevelsKey string `mapstructure:"log_levels_key"`
 }
 
 func Setup(v *viper.Viper, prefix string) {
@@ -16,4 +20,6 @@ func Setup(v *viper.Viper, prefix string) {
 
 	v.SetDefault(p("log_config_file"), "") // empty means use default config
 	v.SetDefault(p("shutdown_timeout"), "10s")
+	v.SetDefault(p("admin_addr"), "")
+	v.SetDefault(p("log_levels_key"), "")
 }
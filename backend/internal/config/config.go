@@ -1,6 +1,12 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -16,9 +22,171 @@ func NewViper() *viper.Viper {
 	return v
 }
 
+// Load resolves the config of a service from, lowest to highest precedence: the defaults set
+// by configure, the base file (--config or CONFIG_FILE), its profile overlay (--profile or
+// CONFIG_PROFILE, "config.yaml" + "prod" reads "config.prod.yaml"), environment variables and
+// --set key=value flags. "<service> config dump [--resolved]" prints the file layers, or
+// everything resolved, with secrets redacted and exits.
 func Load[T any](c *T, configure func(v *viper.Viper)) (*T, error) {
-	v := NewViper()
+	dumped, err := load(os.Args[1:], os.Stdout, c, configure)
+	if err != nil {
+		return nil, err
+	}
+	if dumped {
+		os.Exit(0)
+	}
+	return c, nil
+}
+
+// options are the config related command line arguments
+type options struct {
+	file     string
+	profile  string
+	sets     []string
+	dump     bool
+	resolved bool
+}
+
+func parseArgs(args []string) (*options, error) {
+	opts := &options{
+		file:    os.Getenv("CONFIG_FILE"),
+		profile: os.Getenv("CONFIG_PROFILE"),
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		takeValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("missing value of %s", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		var err error
+		switch {
+		case name == "--config":
+			opts.file, err = takeValue()
+		case name == "--profile":
+			opts.profile, err = takeValue()
+		case name == "--set":
+			var set string
+			if set, err = takeValue(); err == nil {
+				opts.sets = append(opts.sets, set)
+			}
+		case arg == "--resolved":
+			opts.resolved = true
+		case arg == "config" && i+1 < len(args) && args[i+1] == "dump":
+			opts.dump = true
+			i++
+		default:
+			return nil, fmt.Errorf("unknown argument %s", arg)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
 
+	if opts.profile != "" && opts.file == "" {
+		return nil, fmt.Errorf("profile %s needs a base config file", opts.profile)
+	}
+	if opts.resolved && !opts.dump {
+		return nil, fmt.Errorf("--resolved is only valid with config dump")
+	}
+	return opts, nil
+}
+
+func load[T any](args []string, out io.Writer, c *T, configure func(v *viper.Viper)) (bool, error) {
+	opts, err := parseArgs(args)
+	if err != nil {
+		return false, err
+	}
+
+	v := NewViper()
 	configure(v)
-	return c, v.Unmarshal(c)
+	if err := readFiles(v, opts); err != nil {
+		return false, err
+	}
+
+	if opts.dump && !opts.resolved {
+		files := viper.New()
+		if err := readFiles(files, opts); err != nil {
+			return false, err
+		}
+		return true, dump(out, files.AllSettings())
+	}
+
+	for _, set := range opts.sets {
+		key, value, ok := strings.Cut(set, "=")
+		if !ok || key == "" {
+			return false, fmt.Errorf("invalid --set %s, want key=value", set)
+		}
+		v.Set(key, value)
+	}
+
+	if opts.dump {
+		return true, dump(out, v.AllSettings())
+	}
+	return false, v.Unmarshal(c)
+}
+
+// readFiles reads the base file and merges the profile overlay over it
+func readFiles(v *viper.Viper, opts *options) error {
+	if opts.file == "" {
+		return nil
+	}
+	v.SetConfigFile(opts.file)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read config %s: %w", opts.file, err)
+	}
+	if opts.profile == "" {
+		return nil
+	}
+
+	overlay := overlayPath(opts.file, opts.profile)
+	v.SetConfigFile(overlay)
+	if err := v.MergeInConfig(); err != nil {
+		return fmt.Errorf("failed to read config overlay %s: %w", overlay, err)
+	}
+	return nil
+}
+
+// overlayPath returns the overlay of profile next to the base file, "config.yaml" -> "config.<profile>.yaml"
+func overlayPath(file, profile string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + profile + ext
+}
+
+// sensitiveKeyParts mark config values redacted from dumps, as do keys ending with "key"
+var sensitiveKeyParts = []string{"secret", "password", "token"}
+
+func isSensitiveKey(key string) bool {
+	return key == "key" || strings.HasSuffix(key, "_key") ||
+		slices.ContainsFunc(sensitiveKeyParts, func(part string) bool { return strings.Contains(key, part) })
+}
+
+func dump(out io.Writer, settings map[string]any) error {
+	redact(settings)
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(settings)
+}
+
+func redact(settings map[string]any) {
+	for key, value := range settings {
+		if nested, ok := value.(map[string]any); ok {
+			redact(nested)
+			continue
+		}
+		if value == "" || value == nil {
+			continue
+		}
+		if isSensitiveKey(key) {
+			settings[key] = "[REDACTED]"
+		}
+	}
 }
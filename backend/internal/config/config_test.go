@@ -0,0 +1,110 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	App         App           `mapstructure:"app"`
+	Addr        string        `mapstructure:"addr"`
+	Capacity    int           `mapstructure:"capacity"`
+	Interval    time.Duration `mapstructure:"interval"`
+	AdminSecret string        `mapstructure:"admin_secret"`
+}
+
+func configureTest(v *viper.Viper) {
+	v.SetDefault("addr", ":8080")
+	v.SetDefault("capacity", 10)
+	v.SetDefault("interval", time.Second)
+	v.SetDefault("admin_secret", "")
+	Setup(v, "app")
+}
+
+func writeFiles(t *testing.T) string {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(base, []byte("addr: \":9000\"\ncapacity: 20\napp:\n  shutdown_timeout: 30s\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.prod.yaml"), []byte("capacity: 50\nadmin_secret: s3cret\n"), 0o600))
+	return base
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	base := writeFiles(t)
+	t.Setenv("ADDR", ":9100")
+
+	var c testConfig
+	dumped, err := load([]string{"--config", base, "--profile=prod", "--set", "interval=5s"}, nil, &c, configureTest)
+	require.NoError(t, err)
+	assert.False(t, dumped)
+
+	assert.Equal(t, ":9100", c.Addr)           // env over files
+	assert.Equal(t, 50, c.Capacity)            // overlay over base
+	assert.Equal(t, 5*time.Second, c.Interval) // flag over defaults
+	assert.Equal(t, 30*time.Second, c.App.ShutdownTimeout)
+	assert.Equal(t, "s3cret", c.AdminSecret)
+}
+
+func TestLoad_DefaultsOnly(t *testing.T) {
+	var c testConfig
+	_, err := load(nil, nil, &c, configureTest)
+	require.NoError(t, err)
+	assert.Equal(t, ":8080", c.Addr)
+	assert.Equal(t, 10, c.Capacity)
+}
+
+func TestLoad_Dump(t *testing.T) {
+	base := writeFiles(t)
+	t.Setenv("ADDR", ":9100")
+
+	var out bytes.Buffer
+	dumped, err := load([]string{"--config", base, "--profile", "prod", "config", "dump"}, &out, &testConfig{}, configureTest)
+	require.NoError(t, err)
+	assert.True(t, dumped)
+
+	var files map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &files))
+	assert.Equal(t, ":9000", files["addr"])
+	assert.InDelta(t, 50, files["capacity"], 0)
+	assert.Equal(t, "[REDACTED]", files["admin_secret"])
+	assert.NotContains(t, files, "interval")
+
+	out.Reset()
+	dumped, err = load([]string{"--config", base, "--profile", "prod", "config", "dump", "--resolved"}, &out, &testConfig{}, configureTest)
+	require.NoError(t, err)
+	assert.True(t, dumped)
+
+	var resolved map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &resolved))
+	assert.Equal(t, ":9100", resolved["addr"])
+	assert.Equal(t, "[REDACTED]", resolved["admin_secret"])
+	assert.Contains(t, resolved, "interval")
+}
+
+func TestLoad_InvalidArgs(t *testing.T) {
+	tests := [][]string{
+		{"--unknown"},
+		{"--config"},
+		{"--profile", "prod"},
+		{"--resolved"},
+		{"--set", "novalue"},
+		{"--config", "/does/not/exist.yaml"},
+	}
+	for _, args := range tests {
+		_, err := load(args, nil, &testConfig{}, configureTest)
+		assert.Error(t, err, "args %v", args)
+	}
+}
+
+func TestOverlayPath(t *testing.T) {
+	assert.Equal(t, "/etc/rtc/config.prod.yaml", overlayPath("/etc/rtc/config.yaml", "prod"))
+	assert.Equal(t, "config.dev", overlayPath("config", "dev"))
+}
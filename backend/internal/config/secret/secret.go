@@ -0,0 +1,303 @@
+// Package secret resolves secret references of the config, so secrets are fetched when a
+// service starts instead of sitting in config files or maps. A reference is one of:
+//
+//   - env:NAME reads the environment variable NAME
+//   - file:/path reads a file, trailing newlines trimmed, e.g. a mounted Kubernetes secret
+//   - vault:path#field reads field of the Vault KV secret at path, e.g. vault:secret/data/rtc#jwt
+//
+// Any other value is the secret itself, so plain values of existing configs keep working.
+// Resolved secrets are re-read every refresh interval and their consumers told on rotation.
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const (
+	schemeEnv   = "env"
+	schemeFile  = "file"
+	schemeVault = "vault"
+)
+
+type Config struct {
+	// VaultAddr is the address of Vault, e.g. https://vault:8200, needed by vault: references
+	VaultAddr string `mapstructure:"vault_addr"`
+	// VaultToken is a reference to the Vault token, env: or file: only
+	VaultToken string        `mapstructure:"vault_token"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+	// RefreshInterval is how often secrets are re-read for rotation, 0 reads them once
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("vault_addr"), "")
+	v.SetDefault(p("vault_token"), "env:VAULT_TOKEN")
+	v.SetDefault(p("timeout"), 5*time.Second)
+	v.SetDefault(p("refresh_interval"), 5*time.Minute)
+}
+
+// Secret is the current value of a reference, consumers register for its rotations
+type Secret struct {
+	ref   string
+	value atomic.Pointer[string]
+
+	mu       sync.Mutex
+	onRotate []func(value string) error
+}
+
+// Static returns a secret of a fixed value, never rotated
+func Static(value string) *Secret {
+	s := &Secret{}
+	s.value.Store(&value)
+	return s
+}
+
+// Value returns the current value of the secret
+func (s *Secret) Value() string {
+	return *s.value.Load()
+}
+
+// OnRotate registers fn to be called with the new value when the secret rotates. An error
+// rejects the value for that consumer, it is logged and the consumer keeps the previous one
+func (s *Secret) OnRotate(fn func(value string) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onRotate = append(s.onRotate, fn)
+}
+
+// rotate swaps in value, returning the errors of consumers rejecting it
+func (s *Secret) rotate(value string) []error {
+	s.value.Store(&value)
+
+	s.mu.Lock()
+	callbacks := append([]func(string) error(nil), s.onRotate...)
+	s.mu.Unlock()
+
+	var errs []error
+	for _, fn := range callbacks {
+		if err := fn(value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Resolver resolves references and caches their secrets, one per reference
+type Resolver struct {
+	cfg    *Config
+	client *resty.Client
+
+	mu      sync.Mutex
+	secrets map[string]*Secret
+
+	cancel  context.CancelFunc
+	stopped chan struct{}
+	logger  *log.Logger
+}
+
+func NewResolver(cfg *Config, logger *log.Logger) *Resolver {
+	return &Resolver{
+		cfg:     cfg,
+		client:  resty.New().SetTimeout(cfg.Timeout),
+		secrets: make(map[string]*Secret),
+		stopped: make(chan struct{}),
+		logger:  logger,
+	}
+}
+
+// Get resolves ref, the secret of a reference resolved before is shared
+func (r *Resolver) Get(ctx context.Context, ref string) (*Secret, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.secrets[ref]; ok {
+		return s, nil
+	}
+
+	value, err := r.resolve(ctx, ref, vaultCache{})
+	if err != nil {
+		return nil, err
+	}
+	s := &Secret{ref: ref}
+	s.value.Store(&value)
+	r.secrets[ref] = s
+	return s, nil
+}
+
+// Start re-reads the secrets every refresh interval until stopped
+func (r *Resolver) Start(ctx context.Context) error {
+	if r.cfg.RefreshInterval <= 0 {
+		return nil
+	}
+	ctx, r.cancel = context.WithCancel(ctx)
+	go r.loop(ctx)
+	return nil
+}
+
+// Stop stops re-reading the secrets
+func (r *Resolver) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.stopped
+	}
+}
+
+func (r *Resolver) loop(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.RefreshInterval)
+	defer close(r.stopped)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.refresh(ctx)
+		}
+	}
+}
+
+// refresh re-reads every secret and rotates those changed, a secret failing to resolve keeps
+// its value
+func (r *Resolver) refresh(ctx context.Context) {
+	r.mu.Lock()
+	secrets := make([]*Secret, 0, len(r.secrets))
+	for _, s := range r.secrets {
+		secrets = append(secrets, s)
+	}
+	r.mu.Unlock()
+
+	cache := vaultCache{}
+	for _, s := range secrets {
+		value, err := r.resolve(ctx, s.ref, cache)
+		if err != nil {
+			r.logger.Warn("Failed to refresh secret",
+				log.String("scheme", scheme(s.ref)),
+				log.Error(err))
+			continue
+		}
+		if value == s.Value() {
+			continue
+		}
+		r.logger.Info("Rotating secret", log.String("scheme", scheme(s.ref)))
+		for _, err := range s.rotate(value) {
+			r.logger.Error("Secret rotation rejected",
+				log.String("scheme", scheme(s.ref)),
+				log.Error(err))
+		}
+	}
+}
+
+func (r *Resolver) resolve(ctx context.Context, ref string, cache vaultCache) (string, error) {
+	kind, target, _ := strings.Cut(ref, ":")
+	switch kind {
+	case schemeEnv:
+		return readEnv(target)
+	case schemeFile:
+		return readFile(target)
+	case schemeVault:
+		return r.readVault(ctx, target, cache)
+	default:
+		return ref, nil
+	}
+}
+
+func readEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+func readFile(path string) (string, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- the path comes from the service config
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// vaultCache holds the Vault secrets read during one pass, references to several fields of
+// a secret read it once
+type vaultCache map[string]map[string]any
+
+func (r *Resolver) readVault(ctx context.Context, target string, cache vaultCache) (string, error) {
+	path, field, ok := strings.Cut(target, "#")
+	if !ok || path == "" || field == "" {
+		return "", errors.New("vault reference must be vault:<path>#<field>")
+	}
+
+	data, ok := cache[path]
+	if !ok {
+		var err error
+		if data, err = r.fetchVault(ctx, path); err != nil {
+			return "", err
+		}
+		cache[path] = data
+	}
+
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no field %s", path, field)
+	}
+	return value, nil
+}
+
+func (r *Resolver) fetchVault(ctx context.Context, path string) (map[string]any, error) {
+	if r.cfg.VaultAddr == "" {
+		return nil, errors.New("vault_addr is not configured")
+	}
+	if kind, _, _ := strings.Cut(r.cfg.VaultToken, ":"); kind == schemeVault {
+		return nil, errors.New("vault token cannot be read from vault")
+	}
+	token, err := r.resolve(ctx, r.cfg.VaultToken, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault token: %w", err)
+	}
+
+	var result struct {
+		Data map[string]any `json:"data"`
+	}
+	resp, err := r.client.R().
+		SetContext(ctx).
+		SetHeader("X-Vault-Token", token).
+		SetResult(&result).
+		Get(strings.TrimSuffix(r.cfg.VaultAddr, "/") + "/v1/" + strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault secret: %w", err)
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("failed to read vault secret %s: status %d", path, resp.StatusCode())
+	}
+
+	// KV version 2 nests the fields under data.data
+	if nested, ok := result.Data["data"].(map[string]any); ok {
+		return nested, nil
+	}
+	return result.Data, nil
+}
+
+// scheme returns the scheme of a reference for logs, never the secret itself
+func scheme(ref string) string {
+	if kind, _, ok := strings.Cut(ref, ":"); ok {
+		switch kind {
+		case schemeEnv, schemeFile, schemeVault:
+			return kind
+		}
+	}
+	return "plain"
+}
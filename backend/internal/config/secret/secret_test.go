@@ -0,0 +1,172 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type SecretTestSuite struct {
+	suite.Suite
+	ctx   context.Context
+	vault *httptest.Server
+	reads atomic.Int32
+	cfg   *Config
+}
+
+func TestSecretSuite(t *testing.T) {
+	suite.Run(t, new(SecretTestSuite))
+}
+
+func (s *SecretTestSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.reads.Store(0)
+	s.vault = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		s.reads.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/secret/data/rtc":
+			_, _ = w.Write([]byte(`{"data":{"data":{"jwt":"jwt-secret","janus":"janus-key"},"metadata":{"version":3}}}`))
+		case "/v1/kv/rtc":
+			_, _ = w.Write([]byte(`{"data":{"jwt":"v1-secret"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	s.T().Setenv("TEST_VAULT_TOKEN", "vault-token")
+	s.cfg = &Config{
+		VaultAddr:  s.vault.URL,
+		VaultToken: "env:TEST_VAULT_TOKEN",
+		Timeout:    time.Second,
+	}
+}
+
+func (s *SecretTestSuite) TearDownTest() {
+	s.vault.Close()
+}
+
+func (s *SecretTestSuite) TestPlainValue() {
+	r := NewResolver(s.cfg, log.NewNop())
+	sec, err := r.Get(s.ctx, "plain:value")
+	s.Require().NoError(err)
+	s.Equal("plain:value", sec.Value())
+}
+
+func (s *SecretTestSuite) TestEnv() {
+	s.T().Setenv("TEST_SECRET", "from-env")
+	r := NewResolver(s.cfg, log.NewNop())
+
+	sec, err := r.Get(s.ctx, "env:TEST_SECRET")
+	s.Require().NoError(err)
+	s.Equal("from-env", sec.Value())
+
+	_, err = r.Get(s.ctx, "env:TEST_SECRET_MISSING")
+	s.Error(err)
+}
+
+func (s *SecretTestSuite) TestFileRotation() {
+	path := filepath.Join(s.T().TempDir(), "secret")
+	s.Require().NoError(os.WriteFile(path, []byte("first\n"), 0o600))
+	r := NewResolver(s.cfg, log.NewNop())
+
+	sec, err := r.Get(s.ctx, "file:"+path)
+	s.Require().NoError(err)
+	s.Equal("first", sec.Value())
+
+	again, err := r.Get(s.ctx, "file:"+path)
+	s.Require().NoError(err)
+	s.Same(sec, again)
+
+	var rotated []string
+	sec.OnRotate(func(value string) error {
+		rotated = append(rotated, value)
+		return nil
+	})
+	sec.OnRotate(func(string) error { return errors.New("rejected") })
+
+	r.refresh(s.ctx)
+	s.Empty(rotated)
+
+	s.Require().NoError(os.WriteFile(path, []byte("second\n"), 0o600))
+	r.refresh(s.ctx)
+	s.Equal([]string{"second"}, rotated)
+	s.Equal("second", sec.Value())
+
+	// a secret failing to resolve keeps its value
+	s.Require().NoError(os.Remove(path))
+	r.refresh(s.ctx)
+	s.Equal("second", sec.Value())
+}
+
+func (s *SecretTestSuite) TestVault() {
+	r := NewResolver(s.cfg, log.NewNop())
+
+	jwtSecret, err := r.Get(s.ctx, "vault:secret/data/rtc#jwt")
+	s.Require().NoError(err)
+	s.Equal("jwt-secret", jwtSecret.Value())
+	janusKey, err := r.Get(s.ctx, "vault:secret/data/rtc#janus")
+	s.Require().NoError(err)
+	s.Equal("janus-key", janusKey.Value())
+
+	v1, err := r.Get(s.ctx, "vault:kv/rtc#jwt")
+	s.Require().NoError(err)
+	s.Equal("v1-secret", v1.Value())
+
+	// one read per secret path in a refresh
+	s.reads.Store(0)
+	r.refresh(s.ctx)
+	s.Equal(int32(2), s.reads.Load())
+}
+
+func (s *SecretTestSuite) TestVaultErrors() {
+	r := NewResolver(s.cfg, log.NewNop())
+
+	_, err := r.Get(s.ctx, "vault:secret/data/rtc")
+	s.Error(err)
+	_, err = r.Get(s.ctx, "vault:secret/data/rtc#missing")
+	s.Error(err)
+	_, err = r.Get(s.ctx, "vault:secret/data/unknown#jwt")
+	s.Error(err)
+
+	s.cfg.VaultToken = "wrong-token"
+	_, err = r.Get(s.ctx, "vault:secret/data/rtc#jwt")
+	s.Error(err)
+
+	s.cfg.VaultAddr = ""
+	_, err = r.Get(s.ctx, "vault:secret/data/rtc#jwt")
+	s.Error(err)
+}
+
+func (s *SecretTestSuite) TestStartStop() {
+	path := filepath.Join(s.T().TempDir(), "secret")
+	s.Require().NoError(os.WriteFile(path, []byte("first"), 0o600))
+	s.cfg.RefreshInterval = 20 * time.Millisecond
+	r := NewResolver(s.cfg, log.NewNop())
+
+	sec, err := r.Get(s.ctx, "file:"+path)
+	s.Require().NoError(err)
+	s.Require().NoError(r.Start(s.ctx))
+	defer r.Stop()
+
+	s.Require().NoError(os.WriteFile(path, []byte("second"), 0o600))
+	s.Eventually(func() bool { return sec.Value() == "second" }, time.Second, 10*time.Millisecond)
+}
+
+func (s *SecretTestSuite) TestStatic() {
+	sec := Static("value")
+	s.Equal("value", sec.Value())
+}
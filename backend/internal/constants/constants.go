@@ -4,6 +4,7 @@ type RoomStatus string
 type MarkLabel string
 type AnchorStatus string
 type UserRole string
+type EndStage string
 
 const (
 	// Room status
@@ -11,20 +12,61 @@ const (
 	RoomStatusRemoving RoomStatus = "removing"
 )
 
+const (
+	// Stages of ending a room, in order, the teardown of each stage waits for the previous one
+	EndStageNotifying         EndStage = "notifying"
+	EndStageStoppingUsers     EndStage = "stopping_users"
+	EndStageStoppingForwarder EndStage = "stopping_forwarder"
+	EndStageStoppingLive      EndStage = "stopping_live"
+	EndStageEnded             EndStage = "ended"
+)
+
+var endStageOrder = map[EndStage]int{
+	EndStageNotifying:         1,
+	EndStageStoppingUsers:     2,
+	EndStageStoppingForwarder: 3,
+	EndStageStoppingLive:      4,
+	EndStageEnded:             5,
+}
+
+// Reached reports whether ending got to stage, false when the room is not ending
+func (s EndStage) Reached(stage EndStage) bool {
+	order, ok := endStageOrder[s]
+	return ok && order >= endStageOrder[stage]
+}
+
 const (
 	RoomKeyMeta     = "meta"
 	RoomKeyLiveMeta = "livemeta"
 	RoomKeyJanus    = "janus"
 	RoomKeyMixer    = "mixer"
+	RoomKeyLink     = "link"
+	RoomKeyLinkedBy = "linkedby"
+	RoomKeyQuality  = "quality"
+	RoomKeyLatency  = "latency"
+	RoomKeyAnchors  = "anchors"
+	RoomKeyTimeline = "timeline"
 )
 
 const (
 	ModuleKeyHeartbeat = "heartbeat"
 	ModuleKeyMark      = "mark"
+	// ModuleKeyDegraded is reported by clients of a module seeing it fail, it expires on its own
+	ModuleKeyDegraded = "degraded"
 )
 
 const (
 	ModuleStatusHealthy = "healthy"
+	// ModuleStatusSaturated is a healthy module out of resources for more rooms, it keeps
+	// serving its rooms but is not picked for new ones
+	ModuleStatusSaturated = "saturated"
+)
+
+const (
+	// Janus status of a room, as written by the Janus manager
+	JanusStatusRoomCreated   = "room_created"
+	JanusStatusForwarding    = "forwarding"
+	JanusStatusNotForwarding = "not_forwarding"
 )
 
 const (
@@ -20,3 +20,32 @@ func (f *EtcdKV) Put(_ context.Context, _, _ string, _ ...clientv3.OpOption) (*c
 func (f *EtcdKV) Delete(_ context.Context, _ string, _ ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
 	return &clientv3.DeleteResponse{}, nil
 }
+
+func (f *EtcdKV) Txn(_ context.Context) clientv3.Txn {
+	return &Txn{}
+}
+
+// Txn is a fake transaction recording its compares and operations, it succeeds unless Failed is set
+type Txn struct {
+	Cmps   []clientv3.Cmp
+	Ops    []clientv3.Op
+	Failed bool
+}
+
+func (t *Txn) If(cmps ...clientv3.Cmp) clientv3.Txn {
+	t.Cmps = append(t.Cmps, cmps...)
+	return t
+}
+
+func (t *Txn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.Ops = append(t.Ops, ops...)
+	return t
+}
+
+func (t *Txn) Else(_ ...clientv3.Op) clientv3.Txn {
+	return t
+}
+
+func (t *Txn) Commit() (*clientv3.TxnResponse, error) {
+	return &clientv3.TxnResponse{Succeeded: !t.Failed}, nil
+}
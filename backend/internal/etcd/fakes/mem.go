@@ -0,0 +1,97 @@
+package fakes
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// MemKV is an in-memory KV for tests reading back what they seeded. Gets and deletes honor
+// key ranges such as WithPrefix, and count and keys only gets
+type MemKV struct {
+	mu   sync.Mutex
+	rev  int64
+	data map[string]*mvccpb.KeyValue
+}
+
+// NewMemKV creates an empty MemKV
+func NewMemKV() *MemKV {
+	return &MemKV{data: map[string]*mvccpb.KeyValue{}}
+}
+
+func (m *MemKV) Get(_ context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	op := clientv3.OpGet(key, opts...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kvs := m.matching(op)
+	resp := &clientv3.GetResponse{Count: int64(len(kvs))}
+	if op.IsCountOnly() {
+		return resp, nil
+	}
+	for _, kv := range kvs {
+		c := *kv
+		if op.IsKeysOnly() {
+			c.Value = nil
+		}
+		resp.Kvs = append(resp.Kvs, &c)
+	}
+	return resp, nil
+}
+
+func (m *MemKV) Put(_ context.Context, key, val string, _ ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rev++
+	kv := &mvccpb.KeyValue{Key: []byte(key), Value: []byte(val), CreateRevision: m.rev, ModRevision: m.rev, Version: 1}
+	if prev, ok := m.data[key]; ok {
+		kv.CreateRevision = prev.CreateRevision
+		kv.Version = prev.Version + 1
+	}
+	m.data[key] = kv
+	return &clientv3.PutResponse{}, nil
+}
+
+func (m *MemKV) Delete(_ context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	op := clientv3.OpDelete(key, opts...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kvs := m.matching(op)
+	for _, kv := range kvs {
+		delete(m.data, string(kv.Key))
+	}
+	if len(kvs) > 0 {
+		m.rev++
+	}
+	return &clientv3.DeleteResponse{Deleted: int64(len(kvs))}, nil
+}
+
+// matching returns the stored keys of the op range sorted by key, the caller holds the lock
+func (m *MemKV) matching(op clientv3.Op) []*mvccpb.KeyValue {
+	key, end := op.KeyBytes(), op.RangeBytes()
+
+	var kvs []*mvccpb.KeyValue
+	for k, kv := range m.data {
+		b := []byte(k)
+		switch {
+		case len(end) == 0:
+			if !bytes.Equal(b, key) {
+				continue
+			}
+		// "\x00" as range end means all keys from key on
+		case bytes.Compare(b, key) < 0 || (!bytes.Equal(end, []byte{0}) && bytes.Compare(b, end) >= 0):
+			continue
+		}
+		kvs = append(kvs, kv)
+	}
+	sort.Slice(kvs, func(i, j int) bool { return bytes.Compare(kvs[i].Key, kvs[j].Key) < 0 })
+	return kvs
+}
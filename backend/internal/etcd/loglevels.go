@@ -0,0 +1,80 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const logLevelsRetryDelay = 5 * time.Second
+
+// WatchLogLevels applies the log level overrides stored in key to levels until ctx is done,
+// the value maps modules to levels, e.g. {"": "info", "SignalServer": "debug"}, and
+// replaces all overrides, deleting the key drops them
+func WatchLogLevels(ctx context.Context, client Watcher, key string, levels *log.Levels, logger *log.Logger) {
+	for {
+		if rev, err := loadLogLevels(ctx, client, key, levels, logger); err != nil {
+			logger.Error("Failed to load log levels", log.String("key", key), log.Error(err))
+		} else {
+			watchLogLevels(ctx, client, key, rev, levels, logger)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(logLevelsRetryDelay):
+		}
+	}
+}
+
+func loadLogLevels(ctx context.Context, client Watcher, key string, levels *log.Levels, logger *log.Logger) (int64, error) {
+	resp, err := client.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	var value []byte
+	if len(resp.Kvs) > 0 {
+		value = resp.Kvs[0].Value
+	}
+	applyLogLevels(key, value, levels, logger)
+	return resp.Header.Revision, nil
+}
+
+func watchLogLevels(ctx context.Context, client Watcher, key string, rev int64, levels *log.Levels, logger *log.Logger) {
+	watchCtx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+
+	for resp := range client.Watch(watchCtx, key, clientv3.WithRev(rev+1)) {
+		if err := resp.Err(); err != nil {
+			logger.Warn("Log levels watch failed", log.String("key", key), log.Error(err))
+			return
+		}
+		for _, ev := range resp.Events {
+			var value []byte
+			if ev.Type == clientv3.EventTypePut {
+				value = ev.Kv.Value
+			}
+			applyLogLevels(key, value, levels, logger)
+		}
+	}
+}
+
+// applyLogLevels replaces the overrides by value, invalid values keep the current overrides
+func applyLogLevels(key string, value []byte, levels *log.Levels, logger *log.Logger) {
+	overrides := map[string]string{}
+	if len(value) > 0 {
+		if err := json.Unmarshal(value, &overrides); err != nil {
+			logger.Error("Invalid log levels", log.String("key", key), log.Error(err))
+			return
+		}
+	}
+	if err := levels.Replace(overrides); err != nil {
+		logger.Error("Invalid log levels", log.String("key", key), log.Error(err))
+		return
+	}
+	logger.Info("Log levels applied", log.String("key", key), log.Any("overrides", overrides))
+}
@@ -0,0 +1,71 @@
+package etcd_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/etcd/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+func TestWatchLogLevels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mocks.NewMockWatcher(ctrl)
+	logger := log.NewNop()
+	levels := logger.Levels()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client.EXPECT().Get(gomock.Any(), "/loglevels/wsgateway").Return(&clientv3.GetResponse{
+		Header: &etcdserverpb.ResponseHeader{Revision: 7},
+		Kvs:    []*mvccpb.KeyValue{{Value: []byte(`{"SignalServer":"debug"}`)}},
+	}, nil)
+	wch := make(chan clientv3.WatchResponse)
+	client.EXPECT().Watch(gomock.Any(), "/loglevels/wsgateway", gomock.Any()).Return(clientv3.WatchChan(wch))
+
+	done := make(chan struct{})
+	go func() {
+		etcd.WatchLogLevels(ctx, client, "/loglevels/wsgateway", levels, logger)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		return levels.State().Overrides["SignalServer"] == "debug"
+	}, time.Second, 10*time.Millisecond)
+
+	wch <- clientv3.WatchResponse{Events: []*clientv3.Event{{
+		Type: clientv3.EventTypePut,
+		Kv:   &mvccpb.KeyValue{Value: []byte(`{"":"warn"}`)},
+	}}}
+	require.Eventually(t, func() bool {
+		return levels.State().Overrides[""] == "warn"
+	}, time.Second, 10*time.Millisecond)
+	assert.NotContains(t, levels.State().Overrides, "SignalServer")
+
+	// invalid values keep the overrides
+	wch <- clientv3.WatchResponse{Events: []*clientv3.Event{{
+		Type: clientv3.EventTypePut,
+		Kv:   &mvccpb.KeyValue{Value: []byte(`{"":"loud"}`)},
+	}}}
+	wch <- clientv3.WatchResponse{Events: []*clientv3.Event{{
+		Type: clientv3.EventTypeDelete,
+		Kv:   &mvccpb.KeyValue{},
+	}}}
+	require.Eventually(t, func() bool {
+		return len(levels.State().Overrides) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	close(wch)
+	<-done
+}
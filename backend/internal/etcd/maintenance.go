@@ -0,0 +1,176 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const maintenanceTimeout = time.Minute
+
+// MaintenanceConfig configures the compaction and defragmentation loop, uncompacted history
+// grows the etcd DB until its quota is hit and watchers resuming far behind hit compaction storms
+type MaintenanceConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+	// Retention is how many revisions of history are kept, watchers resuming from an older
+	// revision rebuild from a snapshot
+	Retention int64 `mapstructure:"retention"`
+	// Defrag defragments members whose free share of the DB reaches DefragFreeRatio, one member
+	// at a time as a member blocks requests while defragmenting
+	Defrag          bool    `mapstructure:"defrag"`
+	DefragFreeRatio float64 `mapstructure:"defrag_free_ratio"`
+}
+
+func SetupMaintenance(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), false)
+	v.SetDefault(p("interval"), 5*time.Minute)
+	v.SetDefault(p("retention"), 10000)
+	v.SetDefault(p("defrag"), false)
+	v.SetDefault(p("defrag_free_ratio"), 0.5)
+}
+
+// Maintainer periodically compacts etcd history, defragments members when configured and
+// reports the DB size and revision of every member. Running it on several replicas is safe,
+// compacting a revision already compacted is a no-op
+type Maintainer struct {
+	client Maintenance
+	cfg    *MaintenanceConfig
+	cancel context.CancelFunc
+	done   chan struct{}
+	logger *log.Logger
+}
+
+func NewMaintainer(client Maintenance, cfg *MaintenanceConfig, logger *log.Logger) *Maintainer {
+	return &Maintainer{
+		client: client,
+		cfg:    cfg,
+		done:   make(chan struct{}),
+		logger: logger,
+	}
+}
+
+func (m *Maintainer) Start(ctx context.Context) error {
+	ctx, m.cancel = context.WithCancel(ctx)
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			m.RunOnce(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (m *Maintainer) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+		<-m.done
+	}
+}
+
+// RunOnce reports the status of the members, compacts the history past the retention and
+// defragments members with enough free space
+func (m *Maintainer) RunOnce(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, maintenanceTimeout)
+	defer cancel()
+
+	statuses := m.collectStatus(ctx)
+	if len(statuses) == 0 {
+		return
+	}
+
+	var revision int64
+	for _, st := range statuses {
+		revision = max(revision, st.Header.Revision)
+	}
+	if err := m.compact(ctx, revision); err != nil {
+		m.logger.Error("Failed to compact etcd", log.Int64("revision", revision), log.Error(err))
+	}
+
+	if m.cfg.Defrag {
+		for endpoint, st := range statuses {
+			if !needsDefrag(st, m.cfg.DefragFreeRatio) {
+				continue
+			}
+			if err := m.defrag(ctx, endpoint, st); err != nil {
+				m.logger.Error("Failed to defragment etcd member",
+					log.String("endpoint", endpoint),
+					log.Error(err))
+			}
+		}
+	}
+}
+
+func (m *Maintainer) collectStatus(ctx context.Context) map[string]*clientv3.StatusResponse {
+	statuses := make(map[string]*clientv3.StatusResponse)
+	for _, endpoint := range m.client.Endpoints() {
+		st, err := m.client.Status(ctx, endpoint)
+		if err != nil {
+			m.logger.Warn("Failed to get etcd member status", log.String("endpoint", endpoint), log.Error(err))
+			continue
+		}
+		attrs := metric.WithAttributes(attribute.String("endpoint", endpoint))
+		dbSize.Record(ctx, st.DbSize, attrs)
+		dbSizeInUse.Record(ctx, st.DbSizeInUse, attrs)
+		currentRevision.Record(ctx, st.Header.Revision, attrs)
+		statuses[endpoint] = st
+	}
+	return statuses
+}
+
+func (m *Maintainer) compact(ctx context.Context, revision int64) error {
+	target := revision - m.cfg.Retention
+	if target <= 0 {
+		return nil
+	}
+	_, err := m.client.Compact(ctx, target)
+	if errors.Is(err, rpctypes.ErrCompacted) {
+		// compacted past the target already, by another replica or an earlier run
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	compactions.Add(ctx, 1)
+	m.logger.Info("Compacted etcd", log.Int64("revision", target))
+	return nil
+}
+
+func (m *Maintainer) defrag(ctx context.Context, endpoint string, st *clientv3.StatusResponse) error {
+	m.logger.Info("Defragmenting etcd member",
+		log.String("endpoint", endpoint),
+		log.Int64("dbSize", st.DbSize),
+		log.Int64("dbSizeInUse", st.DbSizeInUse))
+	if _, err := m.client.Defragment(ctx, endpoint); err != nil {
+		return fmt.Errorf("failed to defragment: %w", err)
+	}
+	defrags.Add(ctx, 1, metric.WithAttributes(attribute.String("endpoint", endpoint)))
+	return nil
+}
+
+// needsDefrag reports whether the free share of the DB of the member reaches ratio
+func needsDefrag(st *clientv3.StatusResponse, ratio float64) bool {
+	if st.DbSize <= 0 {
+		return false
+	}
+	return float64(st.DbSize-st.DbSizeInUse)/float64(st.DbSize) >= ratio
+}
@@ -0,0 +1,89 @@
+package etcd_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/etcd/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+func status(revision, dbSize, dbSizeInUse int64) *clientv3.StatusResponse {
+	return &clientv3.StatusResponse{
+		Header:      &etcdserverpb.ResponseHeader{Revision: revision},
+		DbSize:      dbSize,
+		DbSizeInUse: dbSizeInUse,
+	}
+}
+
+func TestMaintainer(t *testing.T) {
+	ctx := context.Background()
+	cfg := &etcd.MaintenanceConfig{Retention: 1000, Defrag: true, DefragFreeRatio: 0.5}
+	endpoints := []string{"etcd-0:2379", "etcd-1:2379"}
+
+	t.Run("compacts past retention and defragments fragmented members", func(t *testing.T) {
+		client := mocks.NewMockMaintenance(gomock.NewController(t))
+		client.EXPECT().Endpoints().Return(endpoints)
+		client.EXPECT().Status(gomock.Any(), "etcd-0:2379").Return(status(5000, 100, 90), nil)
+		client.EXPECT().Status(gomock.Any(), "etcd-1:2379").Return(status(5002, 100, 40), nil)
+		// the latest revision of the members
+		client.EXPECT().Compact(gomock.Any(), int64(4002)).Return(&clientv3.CompactResponse{}, nil)
+		client.EXPECT().Defragment(gomock.Any(), "etcd-1:2379").Return(&clientv3.DefragmentResponse{}, nil)
+
+		etcd.NewMaintainer(client, cfg, log.NewTest(t)).RunOnce(ctx)
+	})
+
+	t.Run("nothing to compact yet", func(t *testing.T) {
+		client := mocks.NewMockMaintenance(gomock.NewController(t))
+		client.EXPECT().Endpoints().Return(endpoints[:1])
+		client.EXPECT().Status(gomock.Any(), "etcd-0:2379").Return(status(800, 100, 100), nil)
+
+		etcd.NewMaintainer(client, cfg, log.NewTest(t)).RunOnce(ctx)
+	})
+
+	t.Run("compacted already by another replica", func(t *testing.T) {
+		client := mocks.NewMockMaintenance(gomock.NewController(t))
+		client.EXPECT().Endpoints().Return(endpoints[:1])
+		client.EXPECT().Status(gomock.Any(), "etcd-0:2379").Return(status(5000, 100, 100), nil)
+		client.EXPECT().Compact(gomock.Any(), int64(4000)).Return(nil, rpctypes.ErrCompacted)
+
+		etcd.NewMaintainer(client, cfg, log.NewTest(t)).RunOnce(ctx)
+	})
+
+	t.Run("unreachable member is skipped", func(t *testing.T) {
+		client := mocks.NewMockMaintenance(gomock.NewController(t))
+		client.EXPECT().Endpoints().Return(endpoints)
+		client.EXPECT().Status(gomock.Any(), "etcd-0:2379").Return(nil, errors.New("connection refused"))
+		client.EXPECT().Status(gomock.Any(), "etcd-1:2379").Return(status(5000, 100, 10), nil)
+		client.EXPECT().Compact(gomock.Any(), int64(4000)).Return(&clientv3.CompactResponse{}, nil)
+		client.EXPECT().Defragment(gomock.Any(), "etcd-1:2379").Return(&clientv3.DefragmentResponse{}, nil)
+
+		etcd.NewMaintainer(client, cfg, log.NewTest(t)).RunOnce(ctx)
+	})
+
+	t.Run("no defrag unless enabled", func(t *testing.T) {
+		client := mocks.NewMockMaintenance(gomock.NewController(t))
+		client.EXPECT().Endpoints().Return(endpoints[:1])
+		client.EXPECT().Status(gomock.Any(), "etcd-0:2379").Return(status(5000, 100, 10), nil)
+		client.EXPECT().Compact(gomock.Any(), int64(4000)).Return(&clientv3.CompactResponse{}, nil)
+
+		noDefrag := *cfg
+		noDefrag.Defrag = false
+		etcd.NewMaintainer(client, &noDefrag, log.NewTest(t)).RunOnce(ctx)
+	})
+
+	t.Run("no member reachable", func(t *testing.T) {
+		client := mocks.NewMockMaintenance(gomock.NewController(t))
+		client.EXPECT().Endpoints().Return(endpoints[:1])
+		client.EXPECT().Status(gomock.Any(), "etcd-0:2379").Return(nil, errors.New("connection refused"))
+
+		etcd.NewMaintainer(client, cfg, log.NewTest(t)).RunOnce(ctx)
+	})
+}
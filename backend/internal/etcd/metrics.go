@@ -0,0 +1,32 @@
+package etcd
+
+import (
+	"go.opentelemetry.io/otel/metric"
+
+	intotel "github.com/imtaco/audio-rtc-exp/internal/otel"
+)
+
+var (
+	dbSize          metric.Int64Gauge
+	dbSizeInUse     metric.Int64Gauge
+	currentRevision metric.Int64Gauge
+	compactions     metric.Int64Counter
+	defrags         metric.Int64Counter
+)
+
+func init() {
+	f := intotel.NewFactory("internal.etcd", intotel.PrefixEtcd)
+
+	f.Int64Gauge(&dbSize, "db.size",
+		metric.WithDescription("Size of the etcd DB of a member, including free pages"),
+		metric.WithUnit("By"))
+	f.Int64Gauge(&dbSizeInUse, "db.size_in_use",
+		metric.WithDescription("Size of the etcd DB of a member in use, the rest is reclaimed by defragmentation"),
+		metric.WithUnit("By"))
+	f.Int64Gauge(&currentRevision, "revision",
+		metric.WithDescription("Current revision of an etcd member"))
+	f.Int64Counter(&compactions, "compactions",
+		metric.WithDescription("etcd history compactions"))
+	f.Int64Counter(&defrags, "defrags",
+		metric.WithDescription("etcd member defragmentations"))
+}
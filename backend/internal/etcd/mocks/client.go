@@ -116,6 +116,20 @@ func (mr *MockClientMockRecorder) Put(ctx, key, val any, opts ...any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Put", reflect.TypeOf((*MockClient)(nil).Put), varargs...)
 }
 
+// Txn mocks base method.
+func (m *MockClient) Txn(ctx context.Context) clientv3.Txn {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Txn", ctx)
+	ret0, _ := ret[0].(clientv3.Txn)
+	return ret0
+}
+
+// Txn indicates an expected call of Txn.
+func (mr *MockClientMockRecorder) Txn(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Txn", reflect.TypeOf((*MockClient)(nil).Txn), ctx)
+}
+
 // Watch mocks base method.
 func (m *MockClient) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
 	m.ctrl.T.Helper()
@@ -10,6 +10,7 @@ type Client interface {
 	KV
 	Watcher
 	Lease
+	Tx
 }
 
 // KV is the interface for etcd operations needed by RoomWatcher
@@ -29,3 +30,16 @@ type Watcher interface {
 type Lease interface {
 	Grant(ctx context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error)
 }
+
+// Tx is the interface for etcd transactions
+type Tx interface {
+	Txn(ctx context.Context) clientv3.Txn
+}
+
+// Maintenance is the interface for etcd compaction and member maintenance
+type Maintenance interface {
+	Compact(ctx context.Context, rev int64, opts ...clientv3.CompactOption) (*clientv3.CompactResponse, error)
+	Status(ctx context.Context, endpoint string) (*clientv3.StatusResponse, error)
+	Defragment(ctx context.Context, endpoint string) (*clientv3.DefragmentResponse, error)
+	Endpoints() []string
+}
@@ -0,0 +1,293 @@
+// Package fixtures builds valid etcd state documents for tests. Every builder starts from
+// defaults describing a healthy on-air room served by mixer-1 and janus-1, options override
+// single fields
+package fixtures
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+)
+
+// Defaults of the built documents
+const (
+	DefaultPin        = "1234"
+	DefaultMaxAnchors = 5
+	DefaultMixerID    = "mixer-1"
+	DefaultMixerIP    = "10.0.0.1"
+	DefaultMixerPort  = 5004
+	DefaultJanusID    = "janus-1"
+	DefaultNonce      = "abc123"
+	DefaultHost       = "host-1"
+	DefaultCapacity   = 10
+)
+
+// Now is the creation time of the built documents, fixed so expectations can compare them
+var Now = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// MetaOption overrides a field of Meta
+type MetaOption func(*etcdstate.Meta)
+
+// Meta builds the meta of a room
+func Meta(opts ...MetaOption) *etcdstate.Meta {
+	m := &etcdstate.Meta{
+		Pin:        DefaultPin,
+		MaxAnchors: DefaultMaxAnchors,
+		CreatedAt:  Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func MetaPin(pin string) MetaOption {
+	return func(m *etcdstate.Meta) { m.Pin = pin }
+}
+
+func MetaHLSPath(path string) MetaOption {
+	return func(m *etcdstate.Meta) { m.HLSPath = path }
+}
+
+func MetaMaxAnchors(n int) MetaOption {
+	return func(m *etcdstate.Meta) { m.MaxAnchors = n }
+}
+
+func MetaDVRWindow(seconds int) MetaOption {
+	return func(m *etcdstate.Meta) { m.DVRWindow = seconds }
+}
+
+func MetaMaxDuration(seconds int) MetaOption {
+	return func(m *etcdstate.Meta) { m.MaxDuration = seconds }
+}
+
+func MetaCreatedAt(t time.Time) MetaOption {
+	return func(m *etcdstate.Meta) { m.CreatedAt = t }
+}
+
+func MetaHLS(hls *etcdstate.HLSParams) MetaOption {
+	return func(m *etcdstate.Meta) { m.HLS = hls }
+}
+
+func MetaAudio(audio *etcdstate.AudioParams) MetaOption {
+	return func(m *etcdstate.Meta) { m.Audio = audio }
+}
+
+// MetaEndStage marks the room being ended at the stage since Now
+func MetaEndStage(stage constants.EndStage) MetaOption {
+	return func(m *etcdstate.Meta) {
+		m.Ending = &etcdstate.Ending{Stage: stage, StartedAt: Now, UpdatedAt: Now}
+	}
+}
+
+// LiveMetaOption overrides a field of LiveMeta
+type LiveMetaOption func(*etcdstate.LiveMeta)
+
+// LiveMeta builds the livemeta of an on-air room
+func LiveMeta(opts ...LiveMetaOption) *etcdstate.LiveMeta {
+	m := &etcdstate.LiveMeta{
+		Status:    constants.RoomStatusOnAir,
+		MixerID:   DefaultMixerID,
+		JanusID:   DefaultJanusID,
+		CreatedAt: Now,
+		Nonce:     DefaultNonce,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func LiveStatus(status constants.RoomStatus) LiveMetaOption {
+	return func(m *etcdstate.LiveMeta) { m.Status = status }
+}
+
+func LiveMixer(mixerID string) LiveMetaOption {
+	return func(m *etcdstate.LiveMeta) { m.MixerID = mixerID }
+}
+
+func LiveJanus(janusID string) LiveMetaOption {
+	return func(m *etcdstate.LiveMeta) { m.JanusID = janusID }
+}
+
+func LiveNonce(nonce string) LiveMetaOption {
+	return func(m *etcdstate.LiveMeta) { m.Nonce = nonce }
+}
+
+func LiveCreatedAt(t time.Time) LiveMetaOption {
+	return func(m *etcdstate.LiveMeta) { m.CreatedAt = t }
+}
+
+// LiveRemoving marks the live being removed, discarding it at t
+func LiveRemoving(t time.Time) LiveMetaOption {
+	return func(m *etcdstate.LiveMeta) {
+		m.Status = constants.RoomStatusRemoving
+		m.DiscardAt = &t
+	}
+}
+
+// MixerOption overrides a field of Mixer
+type MixerOption func(*etcdstate.Mixer)
+
+// Mixer builds the mixer data of a room
+func Mixer(opts ...MixerOption) *etcdstate.Mixer {
+	m := &etcdstate.Mixer{
+		ID:   DefaultMixerID,
+		IP:   DefaultMixerIP,
+		Port: DefaultMixerPort,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func MixerID(id string) MixerOption {
+	return func(m *etcdstate.Mixer) { m.ID = id }
+}
+
+func MixerAddr(ip string, port int) MixerOption {
+	return func(m *etcdstate.Mixer) {
+		m.IP = ip
+		m.Port = port
+	}
+}
+
+func MixerLinkPort(port int) MixerOption {
+	return func(m *etcdstate.Mixer) { m.LinkPort = port }
+}
+
+func MixerMarkerPort(port int) MixerOption {
+	return func(m *etcdstate.Mixer) { m.MarkerPort = port }
+}
+
+func MixerDegraded() MixerOption {
+	return func(m *etcdstate.Mixer) { m.Degraded = true }
+}
+
+func MixerSRTP(srtp *etcdstate.SRTP) MixerOption {
+	return func(m *etcdstate.Mixer) { m.SRTP = srtp }
+}
+
+// HeartbeatOption overrides a field of HeartbeatData
+type HeartbeatOption func(*etcdstate.HeartbeatData)
+
+// Heartbeat builds the heartbeat of a healthy module
+func Heartbeat(opts ...HeartbeatOption) *etcdstate.HeartbeatData {
+	h := &etcdstate.HeartbeatData{
+		Status:    constants.ModuleStatusHealthy,
+		Host:      DefaultHost,
+		Capacity:  DefaultCapacity,
+		StartedAt: Now,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func HeartbeatStatus(status string) HeartbeatOption {
+	return func(h *etcdstate.HeartbeatData) { h.Status = status }
+}
+
+func HeartbeatHost(host string) HeartbeatOption {
+	return func(h *etcdstate.HeartbeatData) { h.Host = host }
+}
+
+func HeartbeatCapacity(capacity int) HeartbeatOption {
+	return func(h *etcdstate.HeartbeatData) { h.Capacity = capacity }
+}
+
+func HeartbeatStartedAt(t time.Time) HeartbeatOption {
+	return func(h *etcdstate.HeartbeatData) { h.StartedAt = t }
+}
+
+// Mark builds the mark of a module
+func Mark(label constants.MarkLabel) *etcdstate.MarkData {
+	return &etcdstate.MarkData{Label: label}
+}
+
+// Room holds the documents of a room, nil documents are left out
+type Room struct {
+	ID       string
+	Meta     *etcdstate.Meta
+	LiveMeta *etcdstate.LiveMeta
+	Mixer    *etcdstate.Mixer
+	Janus    *etcdstate.Janus
+}
+
+// State returns the documents as the watchers cache them
+func (r Room) State() *etcdstate.RoomState {
+	return &etcdstate.RoomState{
+		Meta:     r.Meta,
+		LiveMeta: r.LiveMeta,
+		Mixer:    r.Mixer,
+		Janus:    r.Janus,
+	}
+}
+
+// KVs returns the documents as stored under the rooms prefix
+func (r Room) KVs(prefixRooms string) []*mvccpb.KeyValue {
+	key := func(keyType string) string { return prefixRooms + r.ID + "/" + keyType }
+
+	var kvs []*mvccpb.KeyValue
+	if r.Meta != nil {
+		kvs = append(kvs, KV(key(constants.RoomKeyMeta), r.Meta))
+	}
+	if r.LiveMeta != nil {
+		kvs = append(kvs, KV(key(constants.RoomKeyLiveMeta), r.LiveMeta))
+	}
+	if r.Mixer != nil {
+		kvs = append(kvs, KV(key(constants.RoomKeyMixer), r.Mixer))
+	}
+	if r.Janus != nil {
+		kvs = append(kvs, KV(key(constants.RoomKeyJanus), r.Janus))
+	}
+	return kvs
+}
+
+// Module holds the documents of a mixer or janus module, nil documents are left out
+type Module struct {
+	ID        string
+	Heartbeat *etcdstate.HeartbeatData
+	Mark      *etcdstate.MarkData
+}
+
+// KVs returns the documents as stored under the modules prefix
+func (m Module) KVs(prefix string) []*mvccpb.KeyValue {
+	var kvs []*mvccpb.KeyValue
+	if m.Heartbeat != nil {
+		kvs = append(kvs, KV(prefix+m.ID+"/"+constants.ModuleKeyHeartbeat, m.Heartbeat))
+	}
+	if m.Mark != nil {
+		kvs = append(kvs, KV(prefix+m.ID+"/"+constants.ModuleKeyMark, m.Mark))
+	}
+	return kvs
+}
+
+// KV marshals the document into a key value as returned by etcd gets, it panics when the
+// document does not marshal as that is a bug of the test
+func KV(key string, doc any) *mvccpb.KeyValue {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		panic(fmt.Sprintf("fixtures: failed to marshal %s: %v", key, err))
+	}
+	return &mvccpb.KeyValue{Key: []byte(key), Value: data}
+}
+
+// Seed puts the key values into a fake or real etcd
+func Seed(ctx context.Context, kv etcd.KV, kvs ...*mvccpb.KeyValue) error {
+	for _, item := range kvs {
+		if _, err := kv.Put(ctx, string(item.Key), string(item.Value)); err != nil {
+			return fmt.Errorf("failed to seed %s: %w", item.Key, err)
+		}
+	}
+	return nil
+}
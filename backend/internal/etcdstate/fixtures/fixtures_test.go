@@ -0,0 +1,100 @@
+package fixtures
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	etcdfakes "github.com/imtaco/audio-rtc-exp/internal/etcd/fakes"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+)
+
+type FixturesTestSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func TestFixturesSuite(t *testing.T) {
+	suite.Run(t, new(FixturesTestSuite))
+}
+
+func (s *FixturesTestSuite) SetupTest() {
+	s.ctx = context.Background()
+}
+
+func (s *FixturesTestSuite) TestDefaults() {
+	s.Equal(&etcdstate.Meta{Pin: DefaultPin, MaxAnchors: DefaultMaxAnchors, CreatedAt: Now}, Meta())
+	s.Equal(&etcdstate.LiveMeta{
+		Status:    constants.RoomStatusOnAir,
+		MixerID:   DefaultMixerID,
+		JanusID:   DefaultJanusID,
+		CreatedAt: Now,
+		Nonce:     DefaultNonce,
+	}, LiveMeta())
+	s.Equal(&etcdstate.Mixer{ID: DefaultMixerID, IP: DefaultMixerIP, Port: DefaultMixerPort}, Mixer())
+	s.Equal(constants.ModuleStatusHealthy, Heartbeat().Status)
+	s.Equal(constants.MarkLabelReady, Mark(constants.MarkLabelReady).Label)
+}
+
+func (s *FixturesTestSuite) TestOptions() {
+	meta := Meta(MetaPin("9999"), MetaDVRWindow(1800), MetaEndStage(constants.EndStageEnded))
+	s.Equal("9999", meta.Pin)
+	s.Equal(1800, meta.DVRWindow)
+	s.Equal(constants.EndStageEnded, meta.GetEndStage())
+
+	discardAt := Now.Add(time.Minute)
+	live := LiveMeta(LiveMixer("mixer-2"), LiveRemoving(discardAt))
+	s.Equal("mixer-2", live.MixerID)
+	s.Equal(constants.RoomStatusRemoving, live.Status)
+	s.Equal(&discardAt, live.DiscardAt)
+
+	mixer := Mixer(MixerID("mixer-2"), MixerAddr("10.0.0.2", 5006), MixerDegraded())
+	s.Equal(&etcdstate.Mixer{ID: "mixer-2", IP: "10.0.0.2", Port: 5006, Degraded: true}, mixer)
+
+	s.Equal(3, Heartbeat(HeartbeatCapacity(3)).Capacity)
+}
+
+func (s *FixturesTestSuite) TestSeed_Room() {
+	kv := etcdfakes.NewMemKV()
+	room := Room{ID: "room1", Meta: Meta(), LiveMeta: LiveMeta()}
+
+	s.Require().NoError(Seed(s.ctx, kv, room.KVs("/rooms/")...))
+
+	resp, err := kv.Get(s.ctx, "/rooms/room1/", clientv3.WithPrefix())
+	s.Require().NoError(err)
+	s.Require().Len(resp.Kvs, 2)
+	s.Equal("/rooms/room1/livemeta", string(resp.Kvs[0].Key))
+	s.Equal("/rooms/room1/meta", string(resp.Kvs[1].Key))
+
+	var meta etcdstate.Meta
+	s.Require().NoError(json.Unmarshal(resp.Kvs[1].Value, &meta))
+	s.Equal(DefaultPin, meta.Pin)
+	s.Equal(etcdstate.SchemaVersion, meta.SchemaVersion)
+}
+
+func (s *FixturesTestSuite) TestSeed_Module() {
+	kv := etcdfakes.NewMemKV()
+	module := Module{ID: "mixer-1", Heartbeat: Heartbeat(), Mark: Mark(constants.MarkLabelCordon)}
+
+	s.Require().NoError(Seed(s.ctx, kv, module.KVs("/mixers/")...))
+
+	resp, err := kv.Get(s.ctx, "/mixers/", clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.Require().NoError(err)
+	s.Equal(int64(2), resp.Count)
+	s.Empty(resp.Kvs)
+}
+
+func (s *FixturesTestSuite) TestRoom_State() {
+	room := Room{ID: "room1", LiveMeta: LiveMeta(), Mixer: Mixer()}
+
+	state := room.State()
+	s.Nil(state.GetMeta())
+	s.Equal(DefaultMixerID, state.GetLiveMeta().GetMixerID())
+	s.Equal(DefaultMixerPort, state.GetMixer().GetPort())
+	s.Len(room.KVs("/rooms/"), 2)
+}
@@ -1,10 +1,36 @@
 package etcdstate
 
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
 // Mixer represents the mixer data in etcd
 type Mixer struct {
 	ID   string `json:"id"`
 	IP   string `json:"ip"`
 	Port int    `json:"port"`
+	// LinkPort receives the RTP forward of a linked source room, 0 when not linked
+	LinkPort int `json:"linkPort,omitempty"`
+	// MarkerPort receives latency markers of the room over UDP, 0 when not measured
+	MarkerPort int `json:"markerPort,omitempty"`
+	// Degraded is set while FFmpeg of the room stopped writing segments and is being restarted
+	Degraded bool `json:"degraded,omitempty"`
+	// Logs is the last FFmpeg stderr lines when the room was flagged Degraded
+	Logs []string `json:"logs,omitempty"`
+	// SRTP protects the RTP forwards to Port and LinkPort, nil when they are plaintext
+	SRTP *SRTP `json:"srtp,omitempty"`
+	// SchemaVersion is stamped on write, see SchemaVersion
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+}
+
+// MarshalJSON stamps the current schema version
+func (m Mixer) MarshalJSON() ([]byte, error) {
+	type mixer Mixer
+	m.SchemaVersion = SchemaVersion
+	return json.Marshal(mixer(m))
 }
 
 func (m *Mixer) GetID() string {
@@ -27,3 +53,117 @@ func (m *Mixer) GetPort() int {
 	}
 	return m.Port
 }
+
+func (m *Mixer) GetLinkPort() int {
+	if m == nil {
+		return 0
+	}
+	return m.LinkPort
+}
+
+func (m *Mixer) GetMarkerPort() int {
+	if m == nil {
+		return 0
+	}
+	return m.MarkerPort
+}
+
+func (m *Mixer) GetSRTP() *SRTP {
+	if m == nil {
+		return nil
+	}
+	return m.SRTP
+}
+
+// SRTP crypto suites, as named in SDP crypto attributes (RFC 4568)
+const (
+	SRTPSuiteHMAC80 = "AES_CM_128_HMAC_SHA1_80"
+	SRTPSuiteHMAC32 = "AES_CM_128_HMAC_SHA1_32"
+)
+
+// srtpKeyLen is the length of the AES-128 master key and 112 bit salt
+const srtpKeyLen = 30
+
+// SRTP is the crypto of the RTP forwards of a room, a key is generated for every FFmpeg run
+type SRTP struct {
+	Suite string `json:"suite"`
+	// Key is the base64 master key and salt
+	Key string `json:"key"`
+}
+
+// NewSRTP generates a key for the suite
+func NewSRTP(suite string) (*SRTP, error) {
+	if SRTPTagLength(suite) == 0 {
+		return nil, fmt.Errorf("unsupported SRTP suite %q", suite)
+	}
+	b := make([]byte, srtpKeyLen)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate SRTP key: %w", err)
+	}
+	return &SRTP{Suite: suite, Key: base64.StdEncoding.EncodeToString(b)}, nil
+}
+
+// SRTPTagLength returns the authentication tag bits of the suite, 0 when unsupported
+func SRTPTagLength(suite string) int {
+	switch suite {
+	case SRTPSuiteHMAC80:
+		return 80
+	case SRTPSuiteHMAC32:
+		return 32
+	}
+	return 0
+}
+
+func (s *SRTP) GetKey() string {
+	if s == nil {
+		return ""
+	}
+	return s.Key
+}
+
+// HLSParams tunes the HLS output of mixers, used for the global defaults key and per room
+// in Meta. Zero fields fall back to the next level
+type HLSParams struct {
+	KeyBaseURL      string `json:"keyBaseUrl,omitempty"`
+	SegmentDuration int    `json:"segmentDuration,omitempty"` // seconds
+	PlaylistSize    int    `json:"playlistSize,omitempty"`    // segments of live playlists
+}
+
+func (p *HLSParams) GetKeyBaseURL() string {
+	if p == nil {
+		return ""
+	}
+	return p.KeyBaseURL
+}
+
+func (p *HLSParams) GetSegmentDuration() int {
+	if p == nil {
+		return 0
+	}
+	return p.SegmentDuration
+}
+
+func (p *HLSParams) GetPlaylistSize() int {
+	if p == nil {
+		return 0
+	}
+	return p.PlaylistSize
+}
+
+// Merge returns p with the non-zero fields of override applied
+func (p *HLSParams) Merge(override *HLSParams) HLSParams {
+	var merged HLSParams
+	if p != nil {
+		merged = *p
+	}
+	if v := override.GetKeyBaseURL(); v != "" {
+		merged.KeyBaseURL = v
+	}
+	if v := override.GetSegmentDuration(); v > 0 {
+		merged.SegmentDuration = v
+	}
+	if v := override.GetPlaylistSize(); v > 0 {
+		merged.PlaylistSize = v
+	}
+	return merged
+}
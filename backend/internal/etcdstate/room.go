@@ -1,6 +1,8 @@
 package etcdstate
 
 import (
+	"encoding/json"
+	"sort"
 	"time"
 
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
@@ -12,11 +14,16 @@ type RoomState struct {
 	LiveMeta *LiveMeta
 	Mixer    *Mixer
 	Janus    *Janus
+	Link     *Link
+	LinkedBy *LinkedBy
+	Quality  *Quality
+	Latency  *Latency
+	Anchors  *Anchors
 }
 
 // IsEmpty checks if the room state is empty
 func (rs *RoomState) IsEmpty() bool {
-	return rs == nil || (rs.Meta == nil && rs.LiveMeta == nil && rs.Mixer == nil && rs.Janus == nil)
+	return rs == nil || (rs.Meta == nil && rs.LiveMeta == nil && rs.Mixer == nil && rs.Janus == nil && rs.Link == nil && rs.LinkedBy == nil && rs.Quality == nil && rs.Latency == nil && rs.Anchors == nil)
 }
 
 // GetMeta gets the meta for the room
@@ -51,6 +58,38 @@ func (rs *RoomState) GetJanus() *Janus {
 	return rs.Janus
 }
 
+// GetLink gets the cross-room link for the room
+func (rs *RoomState) GetLink() *Link {
+	if rs == nil {
+		return nil
+	}
+	return rs.Link
+}
+
+// GetQuality gets the anchor network quality for the room
+func (rs *RoomState) GetQuality() *Quality {
+	if rs == nil {
+		return nil
+	}
+	return rs.Quality
+}
+
+// GetLatency gets the publish to HLS segment latency for the room
+func (rs *RoomState) GetLatency() *Latency {
+	if rs == nil {
+		return nil
+	}
+	return rs.Latency
+}
+
+// GetAnchors gets the anchors joined the room
+func (rs *RoomState) GetAnchors() *Anchors {
+	if rs == nil {
+		return nil
+	}
+	return rs.Anchors
+}
+
 // SetMeta sets the meta for the room
 func (rs *RoomState) SetMeta(m *Meta) {
 	if rs == nil {
@@ -83,6 +122,54 @@ func (rs *RoomState) SetJanus(j *Janus) {
 	rs.Janus = j
 }
 
+// SetLink sets the cross-room link for the room
+func (rs *RoomState) SetLink(l *Link) {
+	if rs == nil {
+		return
+	}
+	rs.Link = l
+}
+
+// GetLinkedBy gets the links forwarding the room into other rooms
+func (rs *RoomState) GetLinkedBy() *LinkedBy {
+	if rs == nil {
+		return nil
+	}
+	return rs.LinkedBy
+}
+
+// SetLinkedBy sets the links forwarding the room into other rooms
+func (rs *RoomState) SetLinkedBy(lb *LinkedBy) {
+	if rs == nil {
+		return
+	}
+	rs.LinkedBy = lb
+}
+
+// SetQuality sets the anchor network quality for the room
+func (rs *RoomState) SetQuality(q *Quality) {
+	if rs == nil {
+		return
+	}
+	rs.Quality = q
+}
+
+// SetLatency sets the publish to HLS segment latency for the room
+func (rs *RoomState) SetLatency(l *Latency) {
+	if rs == nil {
+		return
+	}
+	rs.Latency = l
+}
+
+// SetAnchors sets the anchors joined the room
+func (rs *RoomState) SetAnchors(a *Anchors) {
+	if rs == nil {
+		return
+	}
+	rs.Anchors = a
+}
+
 // LiveMeta represents the livemeta data from etcd
 type LiveMeta struct {
 	Status    constants.RoomStatus `json:"status"`
@@ -91,6 +178,15 @@ type LiveMeta struct {
 	CreatedAt time.Time            `json:"createdAt"`
 	DiscardAt *time.Time           `json:"discardAt,omitempty"`
 	Nonce     string               `json:"nonce"`
+	// SchemaVersion is stamped on write, see SchemaVersion
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+}
+
+// MarshalJSON stamps the current schema version
+func (m LiveMeta) MarshalJSON() ([]byte, error) {
+	type liveMeta LiveMeta
+	m.SchemaVersion = SchemaVersion
+	return json.Marshal(liveMeta(m))
 }
 
 func (m *LiveMeta) GetStatus() constants.RoomStatus {
@@ -134,10 +230,61 @@ func (m *LiveMeta) GetDiscardAt() *time.Time {
 
 // MetaData contains metadata about a room
 type Meta struct {
-	Pin        string    `json:"pin"`
-	HLSPath    string    `json:"hlsPath"`
-	MaxAnchors int       `json:"maxAnchors"`
-	CreatedAt  time.Time `json:"createdAt,omitempty"`
+	Pin         string    `json:"pin"`
+	HLSPath     string    `json:"hlsPath"`
+	MaxAnchors  int       `json:"maxAnchors"`
+	MaxBitrate  int       `json:"maxBitrate,omitempty"`  // per publisher Opus bitrate cap in bps, 0 means no cap
+	DVRWindow   int       `json:"dvrWindow,omitempty"`   // seconds of segments kept for catch-up playback, 0 means live only
+	MaxDuration int       `json:"maxDuration,omitempty"` // seconds a live may last before housekeeping stops it, 0 means unlimited
+	CreatedAt   time.Time `json:"createdAt,omitempty"`
+	// ExternalID is the upstream identifier, e.g. of a CMS, the room is indexed by
+	ExternalID string `json:"externalId,omitempty"`
+	// Tenant owns the room, its rooms count against its quota
+	Tenant string `json:"tenant,omitempty"`
+	// Tags are labels operators group rooms by, e.g. show: morning, each indexed under the
+	// room tags prefix
+	Tags map[string]string `json:"tags,omitempty"`
+	// Recording flags the lives of the room to be recorded
+	Recording bool `json:"recording,omitempty"`
+	// MixProfile references a named mix profile of the mixer, empty uses the default mix
+	MixProfile string `json:"mixProfile,omitempty"`
+	// ScheduledAt is the planned start of the live, informational only
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty"`
+	// Locale is the BCP 47 language of the room, e.g. zh-TW, notifications of the gateways are
+	// localized to it unless the user token has its own
+	Locale string `json:"locale,omitempty"`
+	// HLS overrides the mixer HLS defaults for this room, applied when FFmpeg (re)starts
+	HLS *HLSParams `json:"hls,omitempty"`
+	// Audio tunes Opus of the room, applied to the Janus room and the SDP answers of joins
+	Audio *AudioParams `json:"audio,omitempty"`
+	// Ending tracks the ordered teardown of the room once ended, nil while the room runs
+	Ending *Ending `json:"ending,omitempty"`
+	// FrozenAt is when an operator froze the room, nil unless frozen. A frozen room takes no
+	// new joins and its anchors are muted, the live and HLS go on
+	FrozenAt *time.Time `json:"frozenAt,omitempty"`
+	// PublishSlots turns the room push-to-talk: anchors are muted until a host grants them one
+	// of this many publish slots. 0 lets every anchor publish
+	PublishSlots int `json:"publishSlots,omitempty"`
+	// JoinQueue is how many users may wait for a free anchor slot once the room is at its max
+	// anchors, they are admitted in turn. 0 rejects joins of a full room
+	JoinQueue int `json:"joinQueue,omitempty"`
+	// SchemaVersion is stamped on write, see SchemaVersion
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+}
+
+// Ending is the progress of ending a room
+type Ending struct {
+	Stage     constants.EndStage `json:"stage"`
+	StartedAt time.Time          `json:"startedAt"`
+	// UpdatedAt is when the current stage was entered
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// MarshalJSON stamps the current schema version
+func (m Meta) MarshalJSON() ([]byte, error) {
+	type meta Meta
+	m.SchemaVersion = SchemaVersion
+	return json.Marshal(meta(m))
 }
 
 func (m *Meta) GetPin() string {
@@ -161,9 +308,291 @@ func (m *Meta) GetMaxAnchors() int {
 	return m.MaxAnchors
 }
 
+func (m *Meta) GetMaxBitrate() int {
+	if m == nil {
+		return 0
+	}
+	return m.MaxBitrate
+}
+
+func (m *Meta) GetDVRWindow() int {
+	if m == nil {
+		return 0
+	}
+	return m.DVRWindow
+}
+
+// GetEndStage returns the stage of ending the room, empty while the room runs
+func (m *Meta) GetEndStage() constants.EndStage {
+	if m == nil || m.Ending == nil {
+		return ""
+	}
+	return m.Ending.Stage
+}
+
+// GetPublishSlots returns the publish slots of a push-to-talk room, 0 when not push-to-talk
+func (m *Meta) GetPublishSlots() int {
+	if m == nil {
+		return 0
+	}
+	return m.PublishSlots
+}
+
+// GetJoinQueue returns how many users may wait for a free anchor slot, 0 when joins of a full
+// room are rejected
+func (m *Meta) GetJoinQueue() int {
+	if m == nil {
+		return 0
+	}
+	return m.JoinQueue
+}
+
+// IsFrozen reports whether an operator froze the room
+func (m *Meta) IsFrozen() bool {
+	return m != nil && m.FrozenAt != nil
+}
+
+func (m *Meta) GetMaxDuration() time.Duration {
+	if m == nil {
+		return 0
+	}
+	return time.Duration(m.MaxDuration) * time.Second
+}
+
 func (m *Meta) GetCreatedAt() time.Time {
 	if m == nil {
 		return time.Time{}
 	}
 	return m.CreatedAt
 }
+
+func (m *Meta) GetExternalID() string {
+	if m == nil {
+		return ""
+	}
+	return m.ExternalID
+}
+
+func (m *Meta) GetTenant() string {
+	if m == nil {
+		return ""
+	}
+	return m.Tenant
+}
+
+func (m *Meta) GetTags() map[string]string {
+	if m == nil {
+		return nil
+	}
+	return m.Tags
+}
+
+func (m *Meta) GetRecording() bool {
+	if m == nil {
+		return false
+	}
+	return m.Recording
+}
+
+func (m *Meta) GetMixProfile() string {
+	if m == nil {
+		return ""
+	}
+	return m.MixProfile
+}
+
+func (m *Meta) GetLocale() string {
+	if m == nil {
+		return ""
+	}
+	return m.Locale
+}
+
+func (m *Meta) GetHLS() *HLSParams {
+	if m == nil {
+		return nil
+	}
+	return m.HLS
+}
+
+func (m *Meta) GetAudio() *AudioParams {
+	if m == nil {
+		return nil
+	}
+	return m.Audio
+}
+
+// AudioParams tunes Opus of a room, unset fields keep what Janus and the clients negotiate
+type AudioParams struct {
+	// FEC turns Opus in-band forward error correction on or off, both for what anchors send
+	// and what Janus sends them
+	FEC *bool `json:"fec,omitempty"`
+	// DTX lets anchors stop sending during silence
+	DTX *bool `json:"dtx,omitempty"`
+	// MaxAverageBitrate caps the average bitrate anchors encode at, in bps
+	MaxAverageBitrate int `json:"maxAverageBitrate,omitempty"`
+	// Stereo mixes the room in stereo and lets anchors send stereo
+	Stereo *bool `json:"stereo,omitempty"`
+}
+
+func (a *AudioParams) GetFEC() *bool {
+	if a == nil {
+		return nil
+	}
+	return a.FEC
+}
+
+func (a *AudioParams) GetDTX() *bool {
+	if a == nil {
+		return nil
+	}
+	return a.DTX
+}
+
+func (a *AudioParams) GetMaxAverageBitrate() int {
+	if a == nil {
+		return 0
+	}
+	return a.MaxAverageBitrate
+}
+
+func (a *AudioParams) GetStereo() *bool {
+	if a == nil {
+		return nil
+	}
+	return a.Stereo
+}
+
+// Link represents a cross-room link stored under the target room, the source room's
+// anchors are forwarded into the target room's mix (co-hosting). With AnchorID set only that
+// anchor of the source room is forwarded.
+type Link struct {
+	SourceRoomID string    `json:"sourceRoomId"`
+	AnchorID     string    `json:"anchorId,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func (l *Link) GetSourceRoomID() string {
+	if l == nil {
+		return ""
+	}
+	return l.SourceRoomID
+}
+
+func (l *Link) GetAnchorID() string {
+	if l == nil {
+		return ""
+	}
+	return l.AnchorID
+}
+
+// LinkedBy indexes the links forwarding a room into other rooms, stored under the source room
+// next to the links themselves so both go away together
+type LinkedBy struct {
+	// Targets maps target room ID to the forwarded anchor, empty when the whole room is forwarded
+	Targets map[string]string `json:"targets"`
+}
+
+// TargetIDs returns the linked target rooms in order
+func (lb *LinkedBy) TargetIDs() []string {
+	if lb == nil {
+		return nil
+	}
+	ids := make([]string, 0, len(lb.Targets))
+	for id := range lb.Targets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// HasAnchor tells whether the anchor is forwarded on its own by any link
+func (lb *LinkedBy) HasAnchor(anchorID string) bool {
+	if lb == nil || anchorID == "" {
+		return false
+	}
+	for _, id := range lb.Targets {
+		if id == anchorID {
+			return true
+		}
+	}
+	return false
+}
+
+// Quality is the network quality of the anchors of a room aggregated by the users controller,
+// scores range from 1 (unusable) to 100
+type Quality struct {
+	Score     int       `json:"score"`              // average score of the reporting anchors
+	Degraded  []string  `json:"degraded,omitempty"` // anchors scoring below users.DegradedQuality
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (q *Quality) GetScore() int {
+	if q == nil {
+		return 0
+	}
+	return q.Score
+}
+
+func (q *Quality) GetDegraded() []string {
+	if q == nil {
+		return nil
+	}
+	return q.Degraded
+}
+
+// Latency is the delay from audio published at Janus to the HLS segment holding it being written,
+// measured by the mixer of the room
+type Latency struct {
+	CurrentMs int64     `json:"currentMs"` // latency of the last completed segment
+	AvgMs     int64     `json:"avgMs"`     // moving average over recent segments
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (l *Latency) GetCurrentMs() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.CurrentMs
+}
+
+func (l *Latency) GetAvgMs() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.AvgMs
+}
+
+// Anchors lists the users ever joined the room as anchor, in join order, recorded by the
+// users controller for the room archive
+type Anchors struct {
+	UserIDs   []string  `json:"userIds"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (a *Anchors) GetUserIDs() []string {
+	if a == nil {
+		return nil
+	}
+	return a.UserIDs
+}
+
+// Timeline is the append-only log of significant transitions of a room, written by the
+// services causing them and bounded to its most recent events
+type Timeline struct {
+	Events []TimelineEvent `json:"events"`
+}
+
+type TimelineEvent struct {
+	Type   string    `json:"type"`
+	At     time.Time `json:"at"`
+	Source string    `json:"source"` // service writing the event, e.g. mixer:mixer-1
+	Detail string    `json:"detail,omitempty"`
+}
+
+func (t *Timeline) GetEvents() []TimelineEvent {
+	if t == nil {
+		return nil
+	}
+	return t.Events
+}
@@ -0,0 +1,108 @@
+package etcdstate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+)
+
+// SchemaVersion is the version of the versioned documents written by this build, documents
+// written before versioning carry none and are version 0.
+// Bump it together with an upgrade step in upgrades when a field changes meaning or shape.
+const SchemaVersion = 1
+
+// upgradeStep rewrites a decoded document of version v into version v+1
+type upgradeStep func(doc map[string]any) error
+
+// upgrades holds the steps of each versioned key type, upgrades[keyType][v] upgrades version v
+var upgrades = map[string][]upgradeStep{
+	constants.RoomKeyMeta:        {stampOnly},
+	constants.RoomKeyLiveMeta:    {stampOnly},
+	constants.RoomKeyMixer:       {stampOnly},
+	constants.ModuleKeyHeartbeat: {stampOnly},
+	constants.ModuleKeyMark:      {stampOnly},
+}
+
+// stampOnly upgrades documents whose fields did not change, only the version is set
+func stampOnly(map[string]any) error {
+	return nil
+}
+
+// IsVersioned tells whether documents of the key type carry a schema version
+func IsVersioned(keyType string) bool {
+	_, ok := upgrades[keyType]
+	return ok
+}
+
+// Upgrade brings a document of keyType written by an older build to SchemaVersion, returns
+// whether it changed. Unversioned key types, current and newer documents are returned as is
+func Upgrade(keyType string, data []byte) ([]byte, bool, error) {
+	steps, ok := upgrades[keyType]
+	if !ok || len(data) == 0 {
+		return data, false, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false, fmt.Errorf("failed to decode %s: %w", keyType, err)
+	}
+
+	version, err := docVersion(doc)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid %s: %w", keyType, err)
+	}
+	if version >= SchemaVersion {
+		return data, false, nil
+	}
+
+	for v := version; v < SchemaVersion; v++ {
+		if err := steps[v](doc); err != nil {
+			return nil, false, fmt.Errorf("failed to upgrade %s from version %d: %w", keyType, v, err)
+		}
+	}
+	doc["schemaVersion"] = SchemaVersion
+
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode %s: %w", keyType, err)
+	}
+	return upgraded, true, nil
+}
+
+func docVersion(doc map[string]any) (int, error) {
+	raw, ok := doc["schemaVersion"]
+	if !ok {
+		return 0, nil
+	}
+	num, ok := raw.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("schemaVersion is not a number")
+	}
+	version, err := num.Int64()
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid schemaVersion %s", num)
+	}
+	return int(version), nil
+}
+
+// Decode parses the document of keyType, older documents are upgraded first and newer ones
+// decoded as far as this build knows them. Returns nil for empty data
+func Decode[T any](keyType string, data []byte) (*T, error) {
+	if len(data) == 0 {
+		//nolint:nilnil
+		return nil, nil
+	}
+	data, _, err := Upgrade(keyType, data)
+	if err != nil {
+		return nil, err
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", keyType, err)
+	}
+	return &value, nil
+}
@@ -0,0 +1,68 @@
+package etcdstate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+)
+
+func TestUpgrade(t *testing.T) {
+	t.Run("stamps legacy documents", func(t *testing.T) {
+		data, changed, err := Upgrade(constants.RoomKeyMeta, []byte(`{"pin":"1234","maxAnchors":3}`))
+		require.NoError(t, err)
+		assert.True(t, changed)
+		assert.JSONEq(t, `{"pin":"1234","maxAnchors":3,"schemaVersion":1}`, string(data))
+	})
+
+	t.Run("keeps current and newer documents", func(t *testing.T) {
+		for _, doc := range []string{`{"id":"m1","schemaVersion":1}`, `{"id":"m1","schemaVersion":7,"extra":true}`} {
+			data, changed, err := Upgrade(constants.RoomKeyMixer, []byte(doc))
+			require.NoError(t, err)
+			assert.False(t, changed)
+			assert.Equal(t, doc, string(data))
+		}
+	})
+
+	t.Run("ignores unversioned key types", func(t *testing.T) {
+		data, changed, err := Upgrade(constants.RoomKeyJanus, []byte(`not json`))
+		require.NoError(t, err)
+		assert.False(t, changed)
+		assert.Equal(t, "not json", string(data))
+	})
+
+	t.Run("rejects invalid versions", func(t *testing.T) {
+		_, _, err := Upgrade(constants.ModuleKeyMark, []byte(`{"label":"ready","schemaVersion":"x"}`))
+		require.Error(t, err)
+	})
+}
+
+func TestDecode(t *testing.T) {
+	meta, err := Decode[Meta](constants.RoomKeyMeta, []byte(`{"pin":"1234","schemaVersion":9,"unknown":1}`))
+	require.NoError(t, err)
+	assert.Equal(t, "1234", meta.GetPin())
+	assert.Equal(t, 9, meta.SchemaVersion)
+
+	empty, err := Decode[Meta](constants.RoomKeyMeta, nil)
+	require.NoError(t, err)
+	assert.Nil(t, empty)
+
+	_, err = Decode[Meta](constants.RoomKeyMeta, []byte(`{`))
+	require.Error(t, err)
+}
+
+func TestMarshalStampsVersion(t *testing.T) {
+	for _, doc := range []any{&Meta{}, LiveMeta{}, &Mixer{}, HeartbeatData{}, &MarkData{}} {
+		data, err := json.Marshal(doc)
+		require.NoError(t, err)
+
+		var decoded struct {
+			SchemaVersion int `json:"schemaVersion"`
+		}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, SchemaVersion, decoded.SchemaVersion, "%T", doc)
+	}
+}
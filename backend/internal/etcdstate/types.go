@@ -1,6 +1,7 @@
 package etcdstate
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
@@ -12,6 +13,17 @@ type HeartbeatData struct {
 	Host      string    `json:"host"`
 	Capacity  int       `json:"capacity"`
 	StartedAt time.Time `json:"startedAt"` // StartedAt is the timestamp when the module started
+	// Capabilities are the features the module supports, e.g. the FFmpeg features of mixers
+	Capabilities []string `json:"capabilities,omitempty"`
+	// SchemaVersion is stamped on write, see SchemaVersion
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+}
+
+// MarshalJSON stamps the current schema version
+func (h HeartbeatData) MarshalJSON() ([]byte, error) {
+	type heartbeatData HeartbeatData
+	h.SchemaVersion = SchemaVersion
+	return json.Marshal(heartbeatData(h))
 }
 
 func (h *HeartbeatData) GetStatus() string {
@@ -45,6 +57,15 @@ func (h *HeartbeatData) GetStartedAt() time.Time {
 // MarkData represents the mark data structure
 type MarkData struct {
 	Label constants.MarkLabel `json:"label"`
+	// SchemaVersion is stamped on write, see SchemaVersion
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+}
+
+// MarshalJSON stamps the current schema version
+func (m MarkData) MarshalJSON() ([]byte, error) {
+	type markData MarkData
+	m.SchemaVersion = SchemaVersion
+	return json.Marshal(markData(m))
 }
 
 func (m *MarkData) GetLabel() constants.MarkLabel {
@@ -54,16 +75,25 @@ func (m *MarkData) GetLabel() constants.MarkLabel {
 	return ""
 }
 
+// DegradedData is reported by a client of a module failing its calls, placement prefers other
+// modules while it is set
+type DegradedData struct {
+	Reason   string    `json:"reason,omitempty"`
+	Reporter string    `json:"reporter,omitempty"`
+	Since    time.Time `json:"since"`
+}
+
 // ModuleState represents the complete state data for a module
 type ModuleState struct {
 	Heartbeat *HeartbeatData `json:"heartbeat,omitempty"`
 	Mark      *MarkData      `json:"mark,omitempty"`
+	Degraded  *DegradedData  `json:"degraded,omitempty"`
 }
 
 // Getter methods with nil-safe access (protobuf-style)
 
 func (m *ModuleState) IsEmpty() bool {
-	return m == nil || (m.Heartbeat == nil && m.Mark == nil)
+	return m == nil || (m.Heartbeat == nil && m.Mark == nil && m.Degraded == nil)
 }
 
 func (m *ModuleState) GetHeartbeat() *HeartbeatData {
@@ -92,8 +122,28 @@ func (m *ModuleState) SetMark(mk *MarkData) {
 	}
 }
 
+func (m *ModuleState) GetDegraded() *DegradedData {
+	if m != nil {
+		return m.Degraded
+	}
+	return nil
+}
+
+func (m *ModuleState) SetDegraded(d *DegradedData) {
+	if m != nil {
+		m.Degraded = d
+	}
+}
+
+// IsDegraded reports whether a client reported the module failing, it may still be picked
+func (m *ModuleState) IsDegraded() bool {
+	return m.GetDegraded() != nil
+}
+
+// IsHealthy checks if a module serves its rooms, saturated modules included
 func (m *ModuleState) IsHealthy() bool {
-	return m.GetHeartbeat().GetStatus() == constants.ModuleStatusHealthy
+	status := m.GetHeartbeat().GetStatus()
+	return status == constants.ModuleStatusHealthy || status == constants.ModuleStatusSaturated
 }
 
 // IsPickableModule checks if a module is healthy and ready (can be picked for new rooms)
@@ -106,7 +156,7 @@ func (m *ModuleState) IsPickable() bool {
 	if label == "" {
 		label = constants.MarkLabelReady
 	}
-	// Only ready and healthy is pickable
+	// Only ready and healthy is pickable, saturated modules take no more rooms
 	return m.GetHeartbeat().GetStatus() == constants.ModuleStatusHealthy &&
 		label == constants.MarkLabelReady
 }
@@ -121,6 +171,6 @@ func (m *ModuleState) IsStable() bool {
 		label = constants.MarkLabelReady
 	}
 	// Healthy and either ready or cordoned
-	return m.GetHeartbeat().GetStatus() == constants.ModuleStatusHealthy &&
+	return m.IsHealthy() &&
 		(label == constants.MarkLabelReady || label == constants.MarkLabelCordon)
 }
@@ -3,17 +3,20 @@ package etcd
 import (
 	"context"
 	"encoding/json"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/cenkalti/backoff/v4"
-
+	"github.com/imtaco/audio-rtc-exp/internal/backoff"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
-	"github.com/imtaco/audio-rtc-exp/internal/retry"
 
 	"github.com/pkg/errors"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
+// ErrKeyHeld is returned by an exclusive heartbeat when the key is held by another process
+var ErrKeyHeld = errors.New("heartbeat key held by another process")
+
 // Heartbeat maintains service presence in etcd by automatically renewing a lease-backed key.
 // It stores arbitrary data at a specified key and keeps the key alive by periodically refreshing
 // the lease. If the lease expires (e.g., due to network issues), it automatically recreates the
@@ -34,11 +37,19 @@ import (
 //
 //	// The key will remain in etcd as long as the heartbeat is running
 //	// If this process dies, the key will be removed after TTL expires
+//
+// An exclusive heartbeat only puts the key when it does not exist, so the key doubles as an
+// ownership lock of the module ID, held as long as the lease is renewed. When the lease is
+// lost it waits for the key to be released before putting it again.
 type Heartbeat[T any] struct {
 	client      *clientv3.Client
 	key         string
+	dataMu      sync.Mutex
 	data        T
 	ttl         time.Duration
+	exclusive   bool
+	held        atomic.Bool
+	heldHandler func(held bool)
 	leaseID     clientv3.LeaseID
 	keepAliveCh <-chan *clientv3.LeaseKeepAliveResponse
 	cancel      context.CancelFunc
@@ -58,10 +69,73 @@ func New[T any](client *clientv3.Client, key string, data T, ttl time.Duration,
 	}
 }
 
+// NewExclusive creates a heartbeat failing to start with ErrKeyHeld while another process holds the key
+func NewExclusive[T any](client *clientv3.Client, key string, data T, ttl time.Duration, logger *log.Logger) *Heartbeat[T] {
+	h := New(client, key, data, ttl, logger)
+	h.exclusive = true
+	return h
+}
+
+// Held reports whether the key is currently put under our lease
+func (h *Heartbeat[T]) Held() bool {
+	return h.held.Load()
+}
+
+// SetHeldHandler sets a handler called when the key is put under our lease or the lease is
+// lost, it must be set before Start and is called from the keep-alive goroutine. It must not
+// call Update
+func (h *Heartbeat[T]) SetHeldHandler(handler func(held bool)) {
+	h.heldHandler = handler
+}
+
+func (h *Heartbeat[T]) setHeld(held bool) {
+	if h.held.Swap(held) == held {
+		return
+	}
+	if h.heldHandler != nil {
+		h.heldHandler(held)
+	}
+}
+
+// WaitReleased blocks until the key does not exist, for an exclusive heartbeat to retry
+// Start once the holder is gone
+func (h *Heartbeat[T]) WaitReleased(ctx context.Context) error {
+	resp, err := h.client.Get(ctx, h.key)
+	if err != nil {
+		return errors.Wrapf(err, "fail to get key: %s", h.key)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watchCh := h.client.Watch(ctx, h.key, clientv3.WithRev(resp.Header.Revision+1))
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case wresp, ok := <-watchCh:
+			if !ok {
+				return errors.Errorf("watch closed for key: %s", h.key)
+			}
+			if err := wresp.Err(); err != nil {
+				return errors.Wrapf(err, "fail to watch key: %s", h.key)
+			}
+			for _, ev := range wresp.Events {
+				if ev.Type == clientv3.EventTypeDelete {
+					return nil
+				}
+			}
+		}
+	}
+}
+
 func (h *Heartbeat[T]) Start(ctx context.Context) error {
 	ctx, h.cancel = context.WithCancel(ctx)
 
 	if err := h.setup(ctx); err != nil {
+		h.cancel()
 		return err
 	}
 	h.logger.Info("Heartbeat started",
@@ -76,6 +150,7 @@ func (h *Heartbeat[T]) Stop(ctx context.Context) error {
 	if h.cancel != nil {
 		h.cancel()
 	}
+	h.held.Store(false)
 	if h.leaseID == 0 {
 		return nil
 	}
@@ -93,6 +168,24 @@ func (h *Heartbeat[T]) Stop(ctx context.Context) error {
 	return nil
 }
 
+// Update replaces the data of the key, e.g. a status change. It is put right away while the
+// key is held and used when the lease is recreated
+func (h *Heartbeat[T]) Update(ctx context.Context, data T) error {
+	h.dataMu.Lock()
+	defer h.dataMu.Unlock()
+
+	h.data = data
+	if !h.Held() {
+		return nil
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrap(err, "fail to marshal data")
+	}
+	_, err = h.client.Put(ctx, h.key, string(jsonData), clientv3.WithLease(h.leaseID))
+	return errors.Wrapf(err, "fail to put key: %s", h.key)
+}
+
 func (h *Heartbeat[T]) setup(ctx context.Context) error {
 	h.logger.Debug("Creating heartbeat lease")
 
@@ -102,15 +195,23 @@ func (h *Heartbeat[T]) setup(ctx context.Context) error {
 	}
 	h.leaseID = leaseResp.ID
 
+	// updates wait for the data to be put, not to be overwritten by the data read here
+	h.dataMu.Lock()
 	jsonData, err := json.Marshal(h.data)
 	if err != nil {
+		h.dataMu.Unlock()
 		return errors.Wrap(err, "fail to marshal data")
 	}
 
-	_, err = h.client.Put(ctx, h.key, string(jsonData), clientv3.WithLease(h.leaseID))
-	if err != nil {
-		return errors.Wrapf(err, "fail to put key: %s", h.key)
+	if err := h.put(ctx, string(jsonData)); err != nil {
+		h.dataMu.Unlock()
+		// do not leave the lease behind, Stop would revoke it while another process holds the key
+		_, _ = h.client.Revoke(ctx, h.leaseID)
+		h.leaseID = 0
+		return err
 	}
+	h.setHeld(true)
+	h.dataMu.Unlock()
 
 	// Start automatic keep-alive
 	keepAliveCh, err := h.client.KeepAlive(ctx, h.leaseID)
@@ -121,6 +222,25 @@ func (h *Heartbeat[T]) setup(ctx context.Context) error {
 	return nil
 }
 
+func (h *Heartbeat[T]) put(ctx context.Context, value string) error {
+	if !h.exclusive {
+		_, err := h.client.Put(ctx, h.key, value, clientv3.WithLease(h.leaseID))
+		return errors.Wrapf(err, "fail to put key: %s", h.key)
+	}
+
+	resp, err := h.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(h.key), "=", 0)).
+		Then(clientv3.OpPut(h.key, value, clientv3.WithLease(h.leaseID))).
+		Commit()
+	if err != nil {
+		return errors.Wrapf(err, "fail to put key: %s", h.key)
+	}
+	if !resp.Succeeded {
+		return errors.Wrapf(ErrKeyHeld, "key: %s", h.key)
+	}
+	return nil
+}
+
 func (h *Heartbeat[T]) monitorKeepAlive(ctx context.Context) {
 	for {
 		select {
@@ -130,6 +250,7 @@ func (h *Heartbeat[T]) monitorKeepAlive(ctx context.Context) {
 			if !ok || resp == nil {
 				h.logger.Warn("Keep-alive channel closed or response is nil, lease may have expired",
 					log.String("key", h.key))
+				h.setHeld(false)
 				// Channel closed, need to recreate lease
 				_ = h.recreateLease(ctx)
 				continue
@@ -142,13 +263,7 @@ func (h *Heartbeat[T]) monitorKeepAlive(ctx context.Context) {
 }
 
 func (h *Heartbeat[T]) recreateLease(ctx context.Context) error {
-	operation := func() error {
-		select {
-		case <-ctx.Done():
-			return backoff.Permanent(ctx.Err())
-		default:
-		}
-
+	operation := func(ctx context.Context) error {
 		h.logger.Info("Attempting to recreate lease", log.String("key", h.key))
 
 		if err := h.setup(ctx); err != nil {
@@ -159,6 +274,11 @@ func (h *Heartbeat[T]) recreateLease(ctx context.Context) error {
 		return nil
 	}
 
-	b := retry.New(h.logger, 100*time.Millisecond, 10*time.Second, 0)
-	return b.Do(ctx, operation)
+	policy := backoff.Exponential{Initial: 100 * time.Millisecond, Max: 10 * time.Second, Jitter: 0.2}
+	return backoff.Retry(ctx, policy, operation,
+		backoff.WithOnRetry(func(attempt int, err error, _ time.Duration) {
+			h.logger.Warn("Retry attempt failed",
+				log.Int("attempt", attempt+1),
+				log.Error(err))
+		}))
 }
@@ -0,0 +1,24 @@
+package httputil
+
+import (
+	"net/http"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// NewAdminMux returns the mux of the internal endpoints every service serves,
+// it must not be exposed publicly
+func NewAdminMux(logger *log.Logger) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/log/levels", logger.Levels())
+	return mux
+}
+
+// NewAdminServer serves NewAdminMux of service on addr behind the standard middleware, nil
+// when addr is empty
+func NewAdminServer(addr, service string, logger *log.Logger) *Server {
+	if addr == "" {
+		return nil
+	}
+	return NewServer(&Config{Addr: addr}, Wrap(service, logger, NewAdminMux(logger)))
+}
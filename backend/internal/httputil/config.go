@@ -1,57 +1,157 @@
 package httputil
 
 import (
+	"context"
+	"crypto/tls"
+	stderrors "errors"
+	"io/fs"
 	"net/http"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/tlsid"
+	"github.com/imtaco/audio-rtc-exp/internal/workflow"
 )
 
+// defaultReadHeaderTimeout bounds header reads of servers configured without Setup
+const defaultReadHeaderTimeout = 10 * time.Second
+
 type TLSConfig struct {
 	Enabled  bool   `mapstructure:"enabled"`
 	CertFile string `mapstructure:"cert_file"`
 	KeyFile  string `mapstructure:"key_file"`
+	// ReloadInterval is how often the cert and key files are checked for changes, renewed
+	// certificates are served without restart. 0 loads them once
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
 }
 
 type Config struct {
-	Addr string    `mapstructure:"addr"`
-	TLS  TLSConfig `mapstructure:"tls"`
+	// Addr is a TCP address, unix:<path> for a unix socket, or systemd[:<name>] for a socket
+	// passed by systemd socket activation
+	Addr string `mapstructure:"addr"`
+	// SocketMode is the file mode of a unix socket, 0 is 0660
+	SocketMode uint32    `mapstructure:"socket_mode"`
+	TLS        TLSConfig `mapstructure:"tls"`
+	// HTTP2 offers HTTP/2 through ALPN on TLS listeners
+	HTTP2             bool          `mapstructure:"http2"`
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
 }
 
 type Server struct {
 	*http.Server
-	cfg *Config
+	cfg      *Config
+	identity *tlsid.Identity // nil serves without mTLS
 }
 
 func Setup(v *viper.Viper, prefix string) {
 	p := func(key string) string { return prefix + "." + key }
 
 	v.SetDefault(p("addr"), ":8080")
+	v.SetDefault(p("socket_mode"), defaultSocketMode)
 	v.SetDefault(p("tls.enabled"), false)
 	v.SetDefault(p("tls.cert_file"), "")
 	v.SetDefault(p("tls.key_file"), "")
+	v.SetDefault(p("tls.reload_interval"), time.Minute)
+	v.SetDefault(p("http2"), true)
+	// long polls, HLS downloads and websockets outlive request timeouts, leave them unbounded
+	v.SetDefault(p("read_timeout"), 0)
+	v.SetDefault(p("read_header_timeout"), defaultReadHeaderTimeout)
+	v.SetDefault(p("write_timeout"), 0)
+	v.SetDefault(p("idle_timeout"), 2*time.Minute)
 }
 
 func NewServer(cfg *Config, handler http.Handler) *Server {
+	readHeaderTimeout := cfg.ReadHeaderTimeout
+	if readHeaderTimeout <= 0 {
+		readHeaderTimeout = defaultReadHeaderTimeout
+	}
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: readHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	if !cfg.HTTP2 {
+		// a non-nil map keeps net/http from configuring HTTP/2
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
 	return &Server{
-		Server: &http.Server{
-			Addr:              cfg.Addr,
-			Handler:           handler,
-			ReadHeaderTimeout: 10 * time.Second,
-		},
-		cfg: cfg,
+		Server: srv,
+		cfg:    cfg,
 	}
 }
 
+// SetIdentity serves mutual TLS with the identity of the service, in place of the TLS config.
+// A nil identity is ignored
+func (s *Server) SetIdentity(identity *tlsid.Identity) {
+	s.identity = identity
+}
+
 func (s *Server) Listen() error {
 	cfg := s.cfg
+	if s.identity != nil {
+		s.TLSConfig = s.identity.ServerConfig()
+		if cfg.HTTP2 {
+			s.TLSConfig.NextProtos = []string{"h2", "http/1.1"}
+		}
+		return s.serve(true)
+	}
 	if !cfg.TLS.Enabled {
-		return s.ListenAndServe()
+		return s.serve(false)
 	}
 
 	if cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "" {
 		return errors.New("TLS is enabled but cert_file or key_file is not set")
 	}
-	return s.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	certs, err := newCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.ReloadInterval)
+	if err != nil {
+		return err
+	}
+	s.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+	}
+	if cfg.HTTP2 {
+		s.TLSConfig.NextProtos = []string{"h2", "http/1.1"}
+	}
+	return s.serve(true)
+}
+
+// serve serves on the listener of the configured address, with the TLS config when withTLS
+func (s *Server) serve(withTLS bool) error {
+	ln, err := listen(s.cfg.Addr, fs.FileMode(s.cfg.SocketMode))
+	if err != nil {
+		return errors.Wrap(err, "failed to listen")
+	}
+	if withTLS {
+		return s.ServeTLS(ln, "", "")
+	}
+	return s.Serve(ln)
+}
+
+// Component serves in the background from the start of the component until it is stopped,
+// failing to listen is fatal
+func (s *Server) Component(name string, logger *log.Logger, dependsOn ...string) workflow.Component {
+	return workflow.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(context.Context) error {
+			go func() {
+				logger.Info("Starting server", log.String("server", name), log.String("addr", s.Addr))
+				if err := s.Listen(); err != nil && !stderrors.Is(err, http.ErrServerClosed) {
+					logger.Fatal("Failed to start server", log.String("server", name), log.Error(err))
+				}
+			}()
+			return nil
+		},
+		Stop: s.Shutdown,
+	}
 }
@@ -0,0 +1,102 @@
+package httputil
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// unixAddrPrefix listens on a unix domain socket, e.g. unix:/run/mixers/admin.sock
+	unixAddrPrefix = "unix:"
+	// systemdAddr inherits the listener passed by systemd socket activation, systemd:<name>
+	// picks it by its FileDescriptorName when several are passed
+	systemdAddr = "systemd"
+
+	defaultSocketMode = 0o660
+	// listenFDsStart is the first file descriptor passed by socket activation
+	listenFDsStart = 3
+)
+
+// listen returns the listener of addr, a TCP address, a unix socket or a socket passed by
+// systemd, so sidecar listeners need not be exposed on TCP at all
+func listen(addr string, socketMode fs.FileMode) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, unixAddrPrefix):
+		return listenUnix(strings.TrimPrefix(addr, unixAddrPrefix), socketMode)
+	case addr == systemdAddr || strings.HasPrefix(addr, systemdAddr+":"):
+		return listenSystemd(strings.TrimPrefix(strings.TrimPrefix(addr, systemdAddr), ":"))
+	default:
+		if addr == "" {
+			addr = ":http"
+		}
+		return net.Listen("tcp", addr)
+	}
+}
+
+func listenUnix(path string, mode fs.FileMode) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("unix socket path is empty")
+	}
+	// a socket left behind by a previous run would fail the bind
+	if info, err := os.Stat(path); err == nil && info.Mode().Type() == fs.ModeSocket {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale unix socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode == 0 {
+		mode = defaultSocketMode
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("failed to set unix socket mode: %w", err)
+	}
+	return ln, nil
+}
+
+func listenSystemd(name string) (net.Listener, error) {
+	fd, err := systemdFD(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES"), name)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), "systemd:"+name)
+	defer f.Close()
+
+	// the listener holds a duplicate of the descriptor
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inherit systemd socket: %w", err)
+	}
+	return ln, nil
+}
+
+// systemdFD returns the descriptor of the socket named name among those passed by socket
+// activation, the first one when name is empty
+func systemdFD(pid, fds, names, name string) (int, error) {
+	if pid != strconv.Itoa(os.Getpid()) {
+		return 0, errors.New("no sockets passed by systemd to this process")
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("no sockets passed by systemd: LISTEN_FDS=%q", fds)
+	}
+	if name == "" {
+		return listenFDsStart, nil
+	}
+
+	for i, fdName := range strings.Split(names, ":") {
+		if fdName == name && i < n {
+			return listenFDsStart + i, nil
+		}
+	}
+	return 0, fmt.Errorf("no socket named %s passed by systemd", name)
+}
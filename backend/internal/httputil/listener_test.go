@@ -0,0 +1,101 @@
+package httputil
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeUnixSocket(t *testing.T) {
+	// unix socket paths are limited to ~100 bytes, keep clear of long temp dirs
+	dir, err := os.MkdirTemp("", "httputil")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "admin.sock")
+
+	// a stale socket of a previous run
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	srv := NewServer(&Config{Addr: "unix:" + path, SocketMode: 0o600}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	done := make(chan error, 1)
+	go func() { done <- srv.Listen() }()
+	defer func() {
+		require.NoError(t, srv.Shutdown(context.Background()))
+		require.ErrorIs(t, <-done, http.ErrServerClosed)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	require.Eventually(t, func() bool {
+		resp, err := client.Get("http://admin/")
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body) == "ok"
+	}, time.Second, 10*time.Millisecond)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestSystemdFD(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+
+	tests := []struct {
+		name    string
+		pid     string
+		fds     string
+		names   string
+		want    string
+		wantFD  int
+		wantErr bool
+	}{
+		{name: "first socket", pid: pid, fds: "2", wantFD: 3},
+		{name: "named socket", pid: pid, fds: "2", names: "http:admin", want: "admin", wantFD: 4},
+		{name: "unknown name", pid: pid, fds: "2", names: "http:admin", want: "grpc", wantErr: true},
+		{name: "name beyond count", pid: pid, fds: "1", names: "http:admin", want: "admin", wantErr: true},
+		{name: "other process", pid: "1", fds: "1", wantErr: true},
+		{name: "no sockets", pid: pid, fds: "0", wantErr: true},
+		{name: "not activated", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fd, err := systemdFD(tt.pid, tt.fds, tt.names, tt.want)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFD, fd)
+		})
+	}
+}
+
+func TestListenErrors(t *testing.T) {
+	_, err := listen("unix:", 0)
+	assert.Error(t, err)
+
+	t.Setenv("LISTEN_PID", "")
+	_, err = listen("systemd", 0)
+	assert.Error(t, err)
+}
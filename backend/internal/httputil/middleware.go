@@ -0,0 +1,170 @@
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// RequestIDHeader carries the request ID in both directions, an ID given by the caller, e.g. a
+// load balancer, is kept so logs of both sides can be joined
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds request IDs taken from callers
+const maxRequestIDLen = 64
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID of the request context, empty outside requests
+// served through RequestID
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewEngine creates a gin engine with the standard middleware of the service: request IDs,
+// tracing, access logs and panic recovery
+func NewEngine(service string, logger *log.Logger) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	engine.Use(Middleware(service, logger)...)
+	return engine
+}
+
+// Middleware returns the standard middleware in the order they must run, for engines which
+// are not created by NewEngine
+func Middleware(service string, logger *log.Logger) []gin.HandlerFunc {
+	logger = logger.Module("http")
+	return []gin.HandlerFunc{
+		RequestID(),
+		otelgin.Middleware(service),
+		AccessLog(logger),
+		Recovery(logger),
+	}
+}
+
+// RequestID takes the request ID of the caller or generates one, and echoes it in the response
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIDKey{}, id))
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLog logs every served request but health checks, along with its request and trace
+// IDs. The query is left out as it may carry tokens
+func AccessLog(logger *log.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		var extra []log.Field
+		if len(c.Errors) > 0 {
+			extra = append(extra, log.String("errors", c.Errors.String()))
+		}
+		logRequest(logger, c.Request, c.FullPath(), c.Writer.Status(), c.Writer.Size(), start, c.ClientIP(), extra...)
+	}
+}
+
+// logRequest logs a served request, see AccessLog
+func logRequest(
+	logger *log.Logger,
+	r *http.Request,
+	route string,
+	status, size int,
+	start time.Time,
+	clientIP string,
+	extra ...log.Field,
+) {
+	if r.URL.Path == "/health" {
+		return
+	}
+	ctx := r.Context()
+	requestID := RequestIDFromContext(ctx)
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("http.request_id", requestID))
+
+	fields := []log.Field{
+		log.String("method", r.Method),
+		log.String("path", r.URL.Path),
+		log.String("route", route),
+		log.Int("status", status),
+		log.Duration("latency", time.Since(start)),
+		log.Int("bytes", size),
+		log.String("clientIp", clientIP),
+		log.String("requestId", requestID),
+	}
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		fields = append(fields, log.String("traceId", sc.TraceID().String()))
+	}
+	fields = append(fields, extra...)
+
+	if status >= http.StatusInternalServerError {
+		logger.Error("Request served", fields...)
+		return
+	}
+	logger.Info("Request served", fields...)
+}
+
+// Recovery turns panics of handlers into a JSON 500 instead of dropping the connection
+func Recovery(logger *log.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler { //nolint:errorlint // compared as net/http does
+				// the handler aborted the response on purpose
+				panic(rec)
+			}
+			logPanic(logger, c.Request, rec)
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Internal server error",
+			})
+		}()
+		c.Next()
+	}
+}
+
+// logPanic logs the panic of the handler of r with its stack, see Recovery
+func logPanic(logger *log.Logger, r *http.Request, rec any) {
+	logger.Error("Handler panicked",
+		log.String("method", r.Method),
+		log.String("path", r.URL.Path),
+		log.String("requestId", RequestIDFromContext(r.Context())),
+		log.Any("panic", rec),
+		log.String("stack", string(debug.Stack())))
+}
@@ -0,0 +1,85 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+func newTestEngine(t *testing.T) *gin.Engine {
+	t.Helper()
+	engine := NewEngine("test-service", log.NewTest(t))
+	engine.GET("/ok", func(c *gin.Context) {
+		c.String(http.StatusOK, RequestIDFromContext(c.Request.Context()))
+	})
+	engine.GET("/panic", func(*gin.Context) {
+		panic("boom")
+	})
+	engine.GET("/abort", func(*gin.Context) {
+		panic(http.ErrAbortHandler)
+	})
+	return engine
+}
+
+func serve(engine *gin.Engine, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k := range header {
+		req.Header.Set(k, header.Get(k))
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestID(t *testing.T) {
+	engine := newTestEngine(t)
+
+	t.Run("generates an ID", func(t *testing.T) {
+		w := serve(engine, "/ok", nil)
+		id := w.Header().Get(RequestIDHeader)
+		require.NotEmpty(t, id)
+		assert.Equal(t, id, w.Body.String())
+	})
+
+	t.Run("keeps the ID of the caller", func(t *testing.T) {
+		w := serve(engine, "/ok", http.Header{"X-Request-Id": {"lb-123"}})
+		assert.Equal(t, "lb-123", w.Header().Get(RequestIDHeader))
+		assert.Equal(t, "lb-123", w.Body.String())
+	})
+
+	t.Run("replaces an invalid ID", func(t *testing.T) {
+		for _, id := range []string{strings.Repeat("a", maxRequestIDLen+1), "with space", "bad\x00id"} {
+			w := serve(engine, "/ok", http.Header{"X-Request-Id": {id}})
+			assert.NotEqual(t, id, w.Header().Get(RequestIDHeader))
+			assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+		}
+	})
+}
+
+func TestRecovery(t *testing.T) {
+	engine := newTestEngine(t)
+
+	t.Run("panic returns JSON 500", func(t *testing.T) {
+		w := serve(engine, "/panic", nil)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"success":false,"error":"Internal server error"}`, w.Body.String())
+		assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+	})
+
+	t.Run("abort handler panic propagates", func(t *testing.T) {
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			serve(engine, "/abort", nil)
+		})
+	})
+}
+
+func TestRequestIDFromContext_Outside(t *testing.T) {
+	assert.Empty(t, RequestIDFromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()))
+}
@@ -0,0 +1,88 @@
+package httputil
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader serves the certificate of the cert and key files, reloading them once changed
+// so renewed certificates apply without restart. A failed reload keeps the loaded certificate
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time // latest mod time of the files the certificate was loaded from
+	checkedAt time.Time
+}
+
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+		now:      time.Now,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.interval > 0 && r.now().Sub(r.checkedAt) >= r.interval {
+		// keep serving the loaded certificate when the files are being replaced
+		_ = r.reloadIfChanged()
+	}
+	return r.cert, nil
+}
+
+func (r *certReloader) reloadIfChanged() error {
+	r.checkedAt = r.now()
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+	if !modTime.After(r.modTime) {
+		return nil
+	}
+	return r.load()
+}
+
+func (r *certReloader) load() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	r.checkedAt = r.now()
+	return nil
+}
+
+func (r *certReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to stat %s: %w", file, err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,101 @@
+package httputil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes a self-signed certificate with the serial to the cert and key files
+func writeCert(t *testing.T, certFile, keyFile string, serial int64, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+func servedSerial(t *testing.T, r *certReloader) int64 {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.SerialNumber.Int64()
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Hour)
+	writeCert(t, certFile, keyFile, 1, start)
+
+	r, err := newCertReloader(certFile, keyFile, time.Minute)
+	require.NoError(t, err)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	assert.Equal(t, int64(1), servedSerial(t, r))
+
+	// renewed files are picked up once the interval passed
+	writeCert(t, certFile, keyFile, 2, start.Add(time.Minute))
+	assert.Equal(t, int64(1), servedSerial(t, r))
+	now = now.Add(time.Minute)
+	assert.Equal(t, int64(2), servedSerial(t, r))
+
+	// a broken key pair keeps the loaded certificate
+	require.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0o600))
+	require.NoError(t, os.Chtimes(keyFile, start.Add(2*time.Minute), start.Add(2*time.Minute)))
+	now = now.Add(time.Minute)
+	assert.Equal(t, int64(2), servedSerial(t, r))
+
+	_, err = newCertReloader(certFile, keyFile, time.Minute)
+	assert.Error(t, err)
+}
+
+func TestNewServer(t *testing.T) {
+	t.Run("applies timeouts", func(t *testing.T) {
+		srv := NewServer(&Config{
+			ReadTimeout:  time.Second,
+			WriteTimeout: 2 * time.Second,
+			IdleTimeout:  3 * time.Second,
+			HTTP2:        true,
+		}, http.NotFoundHandler())
+		assert.Equal(t, time.Second, srv.ReadTimeout)
+		assert.Equal(t, 2*time.Second, srv.WriteTimeout)
+		assert.Equal(t, 3*time.Second, srv.IdleTimeout)
+		assert.Equal(t, defaultReadHeaderTimeout, srv.ReadHeaderTimeout)
+		assert.Nil(t, srv.TLSNextProto)
+	})
+
+	t.Run("disables HTTP/2", func(t *testing.T) {
+		srv := NewServer(&Config{}, http.NotFoundHandler())
+		assert.NotNil(t, srv.TLSNextProto)
+		assert.Empty(t, srv.TLSNextProto)
+	})
+}
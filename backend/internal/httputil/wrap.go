@@ -0,0 +1,127 @@
+package httputil
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// Wrap serves a plain net/http handler, e.g. a ServeMux, behind the standard middleware:
+// request IDs, tracing, access logs and panic recovery. It does not go through gin, whose
+// writer refuses to be hijacked once a WebSocket upgrade response is written
+func Wrap(service string, logger *log.Logger, handler http.Handler) http.Handler {
+	logger = logger.Module("http")
+	tracer := otel.Tracer(service)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			))
+		defer span.End()
+		r = r.WithContext(context.WithValue(ctx, requestIDKey{}, id))
+
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler { //nolint:errorlint // compared as net/http does
+					panic(rec)
+				}
+				logPanic(logger, r, rec)
+				if sw.status == 0 {
+					sw.Header().Set("Content-Type", "application/json; charset=utf-8")
+					sw.WriteHeader(http.StatusInternalServerError)
+					_ = json.NewEncoder(sw).Encode(map[string]any{
+						"success": false,
+						"error":   "Internal server error",
+					})
+				}
+			}
+
+			status := sw.Status()
+			// ServeMux sets the pattern it matched on the request
+			if r.Pattern != "" {
+				span.SetName(r.Pattern)
+			}
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			logRequest(logger, r, r.Pattern, status, sw.size, start, clientIP(r))
+		}()
+
+		handler.ServeHTTP(sw, r)
+	})
+}
+
+// statusWriter records the status and body size of a response. Unwrap lets WebSocket upgrades
+// and http.ResponseController reach the hijacker and flusher of the server
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the status of the response, 200 when the handler wrote nothing
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// clientIP returns the first address of X-Forwarded-For, set by load balancers, or the peer
+// address, as gin engines do by default
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(first)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,94 @@
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+func newTestWrap(t *testing.T) http.Handler {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(RequestIDFromContext(r.Context())))
+	})
+	mux.HandleFunc("/panic", func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = conn.Write(r.Context(), websocket.MessageText, []byte("hello"))
+		_ = conn.Close(websocket.StatusNormalClosure, "")
+	})
+	return Wrap("test-service", log.NewTest(t), mux)
+}
+
+func TestWrap(t *testing.T) {
+	handler := newTestWrap(t)
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k := range header {
+			req.Header.Set(k, header.Get(k))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("generates an ID", func(t *testing.T) {
+		w := get("/ok", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		require.NotEmpty(t, w.Header().Get(RequestIDHeader))
+		assert.Equal(t, w.Header().Get(RequestIDHeader), w.Body.String())
+	})
+
+	t.Run("keeps the ID of the caller", func(t *testing.T) {
+		w := get("/ok", http.Header{"X-Request-Id": {"lb-123"}})
+		assert.Equal(t, "lb-123", w.Body.String())
+	})
+
+	t.Run("panic returns JSON 500", func(t *testing.T) {
+		w := get("/panic", nil)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"success":false,"error":"Internal server error"}`, w.Body.String())
+	})
+
+	t.Run("keeps the status of the handler", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/missing", nil).Code)
+	})
+}
+
+func TestWrap_WebSocket(t *testing.T) {
+	server := httptest.NewServer(newTestWrap(t))
+	defer server.Close()
+
+	ctx := context.Background()
+	conn, resp, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.CloseNow()
+	assert.NotEmpty(t, resp.Header.Get(RequestIDHeader))
+
+	_, msg, err := conn.Read(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(msg))
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	assert.Equal(t, "10.0.0.1", clientIP(req))
+
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.2")
+	assert.Equal(t, "203.0.113.7", clientIP(req))
+}
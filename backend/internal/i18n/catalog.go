@@ -0,0 +1,149 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// Config locates the message catalog, a JSON file of templates by locale and message code:
+//
+//	{"en": {"room_ending": "The room is ending"}, "zh-TW": {"room_ending": "房間即將結束"}}
+//
+// Templates hold {name} placeholders filled with the args of the message
+type Config struct {
+	// File of the catalog, empty sends machine codes only
+	File string `mapstructure:"file"`
+	// DefaultLocale is used when the catalog has no message in the requested locale
+	DefaultLocale string `mapstructure:"default_locale"`
+	// ReloadInterval is how often the file is checked for changes, 0 loads it once
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("file"), "")
+	v.SetDefault(p("default_locale"), "en")
+	v.SetDefault(p("reload_interval"), 30*time.Second)
+}
+
+// Catalog resolves localized messages by code, reloading the file once changed so messages can
+// be fixed without restart. A failed reload keeps the loaded messages. A nil Catalog resolves
+// every message to ""
+type Catalog struct {
+	file          string
+	defaultLocale string
+	interval      time.Duration
+	now           func() time.Time
+	logger        *log.Logger
+
+	mu        sync.Mutex
+	messages  map[string]map[string]string // lower case locale -> code -> template
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// New loads the catalog, nil when no file is configured
+func New(cfg *Config, logger *log.Logger) (*Catalog, error) {
+	if cfg.File == "" {
+		//nolint:nilnil
+		return nil, nil
+	}
+	c := &Catalog{
+		file:          cfg.File,
+		defaultLocale: cfg.DefaultLocale,
+		interval:      cfg.ReloadInterval,
+		now:           time.Now,
+		logger:        logger,
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	logger.Info("Message catalog loaded",
+		log.String("file", cfg.File),
+		log.Int("locales", len(c.messages)))
+	return c, nil
+}
+
+// Message returns the template of the code in the locale with its placeholders filled, falling
+// back to the base language of the locale, then to the default locale. "" when none has it
+func (c *Catalog) Message(locale, code string, args map[string]string) string {
+	if c == nil {
+		return ""
+	}
+	tmpl, ok := c.lookup(locale, code)
+	if !ok {
+		return ""
+	}
+	if len(args) == 0 {
+		return tmpl
+	}
+	oldnew := make([]string, 0, 2*len(args))
+	for name, value := range args {
+		oldnew = append(oldnew, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(oldnew...).Replace(tmpl)
+}
+
+func (c *Catalog) lookup(locale, code string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.interval > 0 && c.now().Sub(c.checkedAt) >= c.interval {
+		if err := c.reloadIfChanged(); err != nil {
+			c.logger.Warn("Failed to reload message catalog", log.String("file", c.file), log.Error(err))
+		}
+	}
+
+	locale = strings.ToLower(locale)
+	base, _, _ := strings.Cut(locale, "-")
+	for _, l := range []string{locale, base, strings.ToLower(c.defaultLocale)} {
+		if tmpl, ok := c.messages[l][code]; ok {
+			return tmpl, true
+		}
+	}
+	return "", false
+}
+
+func (c *Catalog) reloadIfChanged() error {
+	c.checkedAt = c.now()
+	info, err := os.Stat(c.file)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", c.file, err)
+	}
+	if !info.ModTime().After(c.modTime) {
+		return nil
+	}
+	return c.load()
+}
+
+func (c *Catalog) load() error {
+	info, err := os.Stat(c.file)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", c.file, err)
+	}
+	data, err := os.ReadFile(c.file)
+	if err != nil {
+		return fmt.Errorf("failed to read message catalog: %w", err)
+	}
+	var raw map[string]map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to parse message catalog: %w", err)
+	}
+	messages := make(map[string]map[string]string, len(raw))
+	for locale, codes := range raw {
+		messages[strings.ToLower(locale)] = codes
+	}
+	c.messages = messages
+	c.modTime = info.ModTime()
+	c.checkedAt = c.now()
+	return nil
+}
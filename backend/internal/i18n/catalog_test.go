@@ -0,0 +1,98 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+func writeCatalog(t *testing.T, file, content string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(file, modTime, modTime))
+}
+
+func TestCatalog(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "messages.json")
+	writeCatalog(t, file, `{
+		"en": {"room_ending": "The room is ending", "live_ending_soon": "Live ends in {minutes} min"},
+		"zh": {"room_ending": "房間即將結束"},
+		"zh-TW": {"live_ending_soon": "直播將在 {minutes} 分鐘後結束"}
+	}`, time.Now().Add(-time.Hour))
+
+	c, err := New(&Config{File: file, DefaultLocale: "en"}, log.NewTest(t))
+	require.NoError(t, err)
+
+	t.Run("exact locale", func(t *testing.T) {
+		assert.Equal(t, "直播將在 5 分鐘後結束", c.Message("zh-TW", "live_ending_soon", map[string]string{"minutes": "5"}))
+		assert.Equal(t, "直播將在 5 分鐘後結束", c.Message("zh-tw", "live_ending_soon", map[string]string{"minutes": "5"}))
+	})
+
+	t.Run("base language", func(t *testing.T) {
+		assert.Equal(t, "房間即將結束", c.Message("zh-TW", "room_ending", nil))
+		assert.Equal(t, "房間即將結束", c.Message("zh-HK", "room_ending", nil))
+	})
+
+	t.Run("default locale", func(t *testing.T) {
+		assert.Equal(t, "The room is ending", c.Message("", "room_ending", nil))
+		assert.Equal(t, "Live ends in 1 min", c.Message("ja", "live_ending_soon", map[string]string{"minutes": "1"}))
+	})
+
+	t.Run("unknown code", func(t *testing.T) {
+		assert.Empty(t, c.Message("en", "user_evicted", nil))
+	})
+
+	t.Run("nil catalog", func(t *testing.T) {
+		var nilCatalog *Catalog
+		assert.Empty(t, nilCatalog.Message("en", "room_ending", nil))
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("no file", func(t *testing.T) {
+		c, err := New(&Config{}, log.NewTest(t))
+		require.NoError(t, err)
+		assert.Nil(t, c)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := New(&Config{File: filepath.Join(t.TempDir(), "missing.json")}, log.NewTest(t))
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "messages.json")
+		writeCatalog(t, file, `{"en": ["room_ending"]}`, time.Now())
+		_, err := New(&Config{File: file}, log.NewTest(t))
+		assert.Error(t, err)
+	})
+}
+
+func TestReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "messages.json")
+	start := time.Now().Add(-time.Hour)
+	writeCatalog(t, file, `{"en": {"room_ending": "Ending"}}`, start)
+
+	c, err := New(&Config{File: file, DefaultLocale: "en", ReloadInterval: time.Minute}, log.NewTest(t))
+	require.NoError(t, err)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	// not checked again before the interval
+	writeCatalog(t, file, `{"en": {"room_ending": "The room is ending"}}`, start.Add(time.Minute))
+	assert.Equal(t, "Ending", c.Message("en", "room_ending", nil))
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, "The room is ending", c.Message("en", "room_ending", nil))
+
+	// a broken file keeps the loaded messages
+	writeCatalog(t, file, `{"en":`, start.Add(2*time.Minute))
+	now = now.Add(time.Minute)
+	assert.Equal(t, "The room is ending", c.Message("en", "room_ending", nil))
+}
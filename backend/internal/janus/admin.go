@@ -21,13 +21,21 @@ func newAdminInstance(api *apiImpl, sessionID int64, handleID int64, adminKey st
 }
 
 // CreateRTPForwarder configures Janus to forward RTP to the destination host/port and returns the stream ID.
+// A non-empty group forwards the mix of that participant group only, a non-nil srtp encrypts it.
 func (a *adminInst) CreateRTPForwarder(
 	ctx context.Context,
 	roomID int64,
 	host string,
 	port int,
+	group string,
+	srtp *SRTP,
 ) (int64, error) {
-	a.api.logger.Info("creating janus RTP forwarder", log.Int64("room", roomID), log.String("host", host), log.Int("port", port))
+	a.api.logger.Info("creating janus RTP forwarder",
+		log.Int64("room", roomID),
+		log.String("host", host),
+		log.Int("port", port),
+		log.String("group", group),
+		log.Bool("srtp", srtp != nil))
 
 	req := RTPForwardRequest{
 		Request:  "rtp_forward",
@@ -35,8 +43,13 @@ func (a *adminInst) CreateRTPForwarder(
 		Host:     host,
 		Port:     port,
 		Codec:    "opus",
+		Group:    group,
 		AdminKey: a.adminKey,
 	}
+	if srtp != nil {
+		req.SRTPSuite = srtp.Suite
+		req.SRTPCrypto = srtp.Crypto
+	}
 
 	resp, err := a.postMessage(ctx, "message", req)
 	if err != nil {
@@ -102,16 +115,26 @@ func (a *adminInst) GetRoom(ctx context.Context, roomID int64) (bool, error) {
 }
 
 // CreateRoom provisions a new AudioBridge room.
-func (a *adminInst) CreateRoom(ctx context.Context, roomID int64, description, pin string) error {
+// A positive bitrate caps the Opus bitrate of every participant by default, 0 lets libopus decide.
+// Stereo rooms are mixed with spatial audio, which AudioBridge mixes in stereo for.
+// Talking events rely on the audio level detection defaults of Janus.
+func (a *adminInst) CreateRoom(ctx context.Context, roomID int64, description, pin string, bitrate int, audio *RoomAudio) error {
 	req := CreateRoomRequest{
-		Request:      "create",
-		Room:         roomID,
-		Description:  description,
-		SamplingRate: 16000,
-		SpatialAudio: false,
-		Record:       false,
-		Pin:          pin,
-		AdminKey:     a.adminKey,
+		Request:        "create",
+		Room:           roomID,
+		Description:    description,
+		SamplingRate:   16000,
+		SpatialAudio:   false,
+		Record:         false,
+		Pin:            pin,
+		DefaultBitrate: bitrate,
+		Groups:         []string{GroupRoom, GroupLink},
+		AdminKey:       a.adminKey,
+	}
+	if audio != nil {
+		req.ExpectedLoss = audio.ExpectedLoss
+		req.SpatialAudio = audio.Stereo
+		req.AudioLevelEvent = audio.TalkingEvents
 	}
 
 	resp, err := a.postMessage(ctx, "message", req)
@@ -18,11 +18,15 @@ func newAnchorInstance(
 }
 
 // Join instructs the Janus AudioBridge plugin to join a room.
+// A positive bitrate overrides the room default for this participant.
+// group is one of the groups the room was created with.
 func (a *anchorInstance) Join(
 	ctx context.Context,
 	roomID int64,
 	pin string,
 	displayName string,
+	bitrate int,
+	group string,
 	jsep *JSEP) (*Response, error) {
 	req := JoinRequest{
 		Request: "join",
@@ -30,6 +34,8 @@ func (a *anchorInstance) Join(
 		Display: displayName,
 		Muted:   false,
 		Pin:     pin,
+		Bitrate: bitrate,
+		Group:   group,
 	}
 	return a.postMessageWithJSEP(ctx, req, jsep)
 }
@@ -42,11 +48,48 @@ func (a *anchorInstance) Leave(ctx context.Context) (*Response, error) {
 	return a.postMessage(ctx, "message", req)
 }
 
+// SetMuted configures the mute state of the participant in the joined room.
+func (a *anchorInstance) SetMuted(ctx context.Context, muted bool) error {
+	req := ConfigureRequest{
+		Request: "configure",
+		Muted:   &muted,
+	}
+	resp, err := a.postMessage(ctx, "message", req)
+	if err != nil {
+		return err
+	}
+	return checkSuccess(resp)
+}
+
+// SetGroup moves the participant to another group of the joined room.
+func (a *anchorInstance) SetGroup(ctx context.Context, group string) error {
+	req := ConfigureRequest{
+		Request: "configure",
+		Group:   group,
+	}
+	resp, err := a.postMessage(ctx, "message", req)
+	if err != nil {
+		return err
+	}
+	return checkSuccess(resp)
+}
+
 // IceCandidate forwards an ICE candidate (or completion message) to Janus.
 func (a *anchorInstance) IceCandidate(ctx context.Context, candidate ICECandidate) (*Response, error) {
 	return a.postTrickle(ctx, candidate)
 }
 
+// IceRestart sends an ICE restart offer for the PeerConnection of the joined participant,
+// keeping the handle and room membership. The answer arrives as an event like for Join.
+func (a *anchorInstance) IceRestart(ctx context.Context, jsep *JSEP) (*Response, error) {
+	req := ConfigureRequest{
+		Request: "configure",
+	}
+	offer := *jsep
+	offer.Update = true
+	return a.postMessageWithJSEP(ctx, req, &offer)
+}
+
 // Check verifies the session is still alive via a lightweight exists call.
 func (a *anchorInstance) Check(ctx context.Context) (bool, error) {
 	req := ExistsRequest{
@@ -14,6 +14,8 @@ import (
 )
 
 const (
+	// janusPluginAudioBridge is the plugin every handle attaches to. Rooms are AudioBridge rooms
+	// mixed by Janus, the requests of Admin and Anchor are AudioBridge requests
 	janusPluginAudioBridge = "janus.plugin.audiobridge"
 	janusAPITimeout        = 10 * time.Second
 )
@@ -26,8 +28,9 @@ var (
 
 // API manages Janus sessions and handles.
 type apiImpl struct {
-	baseURL string
-	logger  *log.Logger
+	baseURL    string
+	keepalives *keepaliveScheduler
+	logger     *log.Logger
 }
 
 // New creates a Janus API helper backed by go-resty.
@@ -36,10 +39,12 @@ func New(baseURL string, logger *log.Logger) API {
 		panic("logger is required")
 	}
 	// TODO: timeout configurable ?
-	return &apiImpl{
+	api := &apiImpl{
 		baseURL: strings.TrimRight(baseURL, "/"),
 		logger:  logger,
 	}
+	api.keepalives = newKeepaliveScheduler(keepaliveInterval, keepaliveSlots, api.KeepAliveSession, logger.Module("Keepalive"))
+	return api
 }
 
 // CreateAnchorInstance returns an anchor handle, creating session/handle IDs when needed.
@@ -78,6 +83,26 @@ func (api *apiImpl) CreateAdminInstance(ctx context.Context, adminKey string) (A
 	return newAdminInstance(api, sessionID, handleID, adminKey), nil
 }
 
+func (api *apiImpl) CreateSession(ctx context.Context) (int64, error) {
+	return api.createSession(ctx)
+}
+
+func (api *apiImpl) KeepAliveSession(ctx context.Context, sessionID int64) error {
+	body := map[string]any{
+		"janus": "keepalive",
+	}
+	_, err := api.post(ctx, fmt.Sprintf("/janus/%d", sessionID), body)
+	return err
+}
+
+func (api *apiImpl) DestroySession(ctx context.Context, sessionID int64) error {
+	body := map[string]any{
+		"janus": "destroy",
+	}
+	_, err := api.post(ctx, fmt.Sprintf("/janus/%d", sessionID), body)
+	return err
+}
+
 func (api *apiImpl) createSession(ctx context.Context) (int64, error) {
 	body := map[string]any{
 		"janus": "create",
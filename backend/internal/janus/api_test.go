@@ -17,6 +17,8 @@ type JanusAPITestSuite struct {
 	server *httptest.Server
 	api    *apiImpl
 	logger *log.Logger
+	// lastReq is the last request received by the fake Janus
+	lastReq map[string]any
 }
 
 func (s *JanusAPITestSuite) SetupTest() {
@@ -37,6 +39,7 @@ func (s *JanusAPITestSuite) handleJanusRequest(w http.ResponseWriter, r *http.Re
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	s.lastReq = req
 
 	janusType, _ := req["janus"].(string)
 
@@ -114,7 +117,7 @@ func (s *JanusAPITestSuite) TestAnchorMethods() {
 	anchor, _ := s.api.CreateAnchorInstance(ctx, "client-1", 1234, 5678)
 
 	s.Run("Join", func() {
-		resp, err := anchor.Join(ctx, 123, "pin", "display", nil)
+		resp, err := anchor.Join(ctx, 123, "pin", "display", 32000, GroupRoom, nil)
 		s.Require().NoError(err)
 		s.Equal("success", resp.Janus)
 	})
@@ -131,11 +134,29 @@ func (s *JanusAPITestSuite) TestAnchorMethods() {
 		s.Equal("success", resp.Janus)
 	})
 
+	s.Run("IceRestart", func() {
+		offer := &JSEP{Type: "offer", SDP: "v=0"}
+		resp, err := anchor.IceRestart(ctx, offer)
+		s.Require().NoError(err)
+		s.Equal("success", resp.Janus)
+		s.Equal(map[string]any{"request": "configure"}, s.lastReq["body"])
+		s.Equal(map[string]any{"type": "offer", "sdp": "v=0", "update": true}, s.lastReq["jsep"])
+		s.False(offer.Update, "offer of the caller left untouched")
+	})
+
 	s.Run("Check", func() {
 		ok, err := anchor.Check(ctx)
 		s.Require().NoError(err)
 		s.True(ok)
 	})
+
+	s.Run("SetMuted", func() {
+		s.Require().NoError(anchor.SetMuted(ctx, false))
+	})
+
+	s.Run("SetGroup", func() {
+		s.Require().NoError(anchor.SetGroup(ctx, GroupLink))
+	})
 }
 
 func (s *JanusAPITestSuite) TestAdminMethods() {
@@ -143,8 +164,20 @@ func (s *JanusAPITestSuite) TestAdminMethods() {
 	admin, _ := s.api.CreateAdminInstance(ctx, "admin-key")
 
 	s.Run("CreateRoom", func() {
-		err := admin.CreateRoom(ctx, 123, "desc", "pin")
+		err := admin.CreateRoom(ctx, 123, "desc", "pin", 32000, nil)
+		s.Require().NoError(err)
+		body, _ := s.lastReq["body"].(map[string]any)
+		s.NotContains(body, "default_expectedloss")
+		s.NotContains(body, "spatial_audio")
+	})
+
+	s.Run("CreateRoom with audio", func() {
+		err := admin.CreateRoom(ctx, 123, "desc", "pin", 32000, &RoomAudio{ExpectedLoss: 10, Stereo: true, TalkingEvents: true})
 		s.Require().NoError(err)
+		body, _ := s.lastReq["body"].(map[string]any)
+		s.InDelta(10, body["default_expectedloss"], 0)
+		s.Equal(true, body["spatial_audio"])
+		s.Equal(true, body["audio_level_event"])
 	})
 
 	s.Run("GetRoom", func() {
@@ -154,9 +187,20 @@ func (s *JanusAPITestSuite) TestAdminMethods() {
 	})
 
 	s.Run("CreateRTPForwarder", func() {
-		streamID, err := admin.CreateRTPForwarder(ctx, 123, "localhost", 5000)
+		streamID, err := admin.CreateRTPForwarder(ctx, 123, "localhost", 5000, "", nil)
+		s.Require().NoError(err)
+		s.Equal(int64(999), streamID)
+		body, _ := s.lastReq["body"].(map[string]any)
+		s.NotContains(body, "srtp_suite")
+	})
+
+	s.Run("CreateRTPForwarder with SRTP", func() {
+		streamID, err := admin.CreateRTPForwarder(ctx, 123, "localhost", 5000, "", &SRTP{Suite: 80, Crypto: "c2VjcmV0"})
 		s.Require().NoError(err)
 		s.Equal(int64(999), streamID)
+		body, _ := s.lastReq["body"].(map[string]any)
+		s.Equal(float64(80), body["srtp_suite"])
+		s.Equal("c2VjcmV0", body["srtp_crypto"])
 	})
 
 	s.Run("ListRooms", func() {
@@ -177,12 +221,29 @@ func (s *JanusAPITestSuite) TestKeepAlive() {
 	})
 
 	s.Run("BackgroundKeepAlive", func() {
-		// This is hard to test because of the 15s ticker, but we can check if it starts/stops without panic
+		// keepalives run on the shared scheduler, see TestKeepaliveScheduler
 		anchor.StartKeepalive()
 		anchor.StopKeepalive()
 	})
 }
 
+func (s *JanusAPITestSuite) TestSessionMethods() {
+	ctx := context.Background()
+
+	sessionID, err := s.api.CreateSession(ctx)
+	s.Require().NoError(err)
+	s.Equal(int64(1234), sessionID)
+
+	s.Require().NoError(s.api.KeepAliveSession(ctx, sessionID))
+	s.Require().NoError(s.api.DestroySession(ctx, sessionID))
+
+	// a pre-created session only needs the handle attached
+	anchor, err := s.api.CreateAnchorInstance(ctx, "client-1", sessionID, 0)
+	s.Require().NoError(err)
+	s.Equal(sessionID, anchor.GetSessionID())
+	s.Equal(int64(5678), anchor.GetHandleID())
+}
+
 func (s *JanusAPITestSuite) TestErrorHandling() {
 	ctx := context.Background()
 
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strconv"
 	"sync"
-	"time"
 
 	"github.com/imtaco/audio-rtc-exp/internal/errors"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
@@ -17,8 +16,8 @@ type baseInstance struct {
 	sessionID int64
 	handleID  int64
 
-	keepaliveMu     sync.Mutex
-	keepaliveCancel context.CancelFunc
+	keepaliveMu sync.Mutex
+	keepaliveOn bool
 }
 
 func newBaseInstance(api *apiImpl, clientID string, sessionID int64, handleID int64) *baseInstance {
@@ -114,38 +113,23 @@ func (b *baseInstance) KeepAlive(ctx context.Context) error {
 	return err
 }
 
+// StartKeepalive schedules keepalives of the session on the shared scheduler of the Janus instance
 func (b *baseInstance) StartKeepalive() {
 	b.keepaliveMu.Lock()
 	defer b.keepaliveMu.Unlock()
-	if b.keepaliveCancel != nil {
+	if b.keepaliveOn {
 		return
 	}
-	ctx, cancel := context.WithCancel(context.Background())
-	b.keepaliveCancel = cancel
-	go b.runKeepalive(ctx)
+	b.keepaliveOn = true
+	b.api.keepalives.add(b.sessionID)
 }
 
 func (b *baseInstance) StopKeepalive() {
 	b.keepaliveMu.Lock()
 	defer b.keepaliveMu.Unlock()
-	if b.keepaliveCancel != nil {
-		b.keepaliveCancel()
-		b.keepaliveCancel = nil
-	}
-}
-
-func (b *baseInstance) runKeepalive(ctx context.Context) {
-	ticker := time.NewTicker(15 * time.Second)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			if err := b.KeepAlive(ctx); err != nil {
-				b.api.logger.Warn("janus keepalive failed", log.Error(err))
-			}
-		case <-ctx.Done():
-			return
-		}
+	if b.keepaliveOn {
+		b.keepaliveOn = false
+		b.api.keepalives.remove(b.sessionID)
 	}
 }
 
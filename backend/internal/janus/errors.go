@@ -9,6 +9,8 @@ const (
 	ErrNoneSuccessResponse errors.Code = "none success response"
 	ErrNotFound            errors.Code = "not found"
 	ErrAlreadyExisted      errors.Code = "already existed"
+	// ErrCircuitOpen fails calls fast while the instance keeps failing them
+	ErrCircuitOpen errors.Code = "circuit open"
 )
 
 // // JanusError indicates Janus responded with a failure payload.
@@ -0,0 +1,101 @@
+package janus
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// EventTypePlugin is the type of events originated by plugins in the Janus event handlers
+const EventTypePlugin = 64
+
+// AudioBridge participant events sent to the Janus event handlers
+const (
+	AudioBridgeJoined     = "joined"
+	AudioBridgeLeft       = "left"
+	AudioBridgeConfigured = "configured"
+	// talking events are sent by rooms created with talking events only
+	AudioBridgeTalking        = "talking"
+	AudioBridgeStoppedTalking = "stopped-talking"
+)
+
+// displayPrefix prefixes the user ID in the display name of participants
+const displayPrefix = "user-"
+
+// DisplayName returns the display name participants of userID join with
+func DisplayName(userID string) string {
+	return displayPrefix + userID
+}
+
+// UserIDOf returns the user ID of a participant display name
+func UserIDOf(display string) (string, bool) {
+	userID, ok := strings.CutPrefix(display, displayPrefix)
+	return userID, ok && userID != ""
+}
+
+// HandlerEvent is an event posted by the Janus HTTP event handler
+type HandlerEvent struct {
+	Type      int             `json:"type"`
+	Timestamp int64           `json:"timestamp"`
+	SessionID int64           `json:"session_id,omitempty"`
+	HandleID  int64           `json:"handle_id,omitempty"`
+	Event     json.RawMessage `json:"event"`
+}
+
+// PluginEvent is the event of a HandlerEvent of type EventTypePlugin
+type PluginEvent struct {
+	Plugin string          `json:"plugin"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// AudioBridgeEvent is the data of an AudioBridge plugin event
+type AudioBridgeEvent struct {
+	Event   string `json:"event"`
+	Room    int64  `json:"room"`
+	ID      int64  `json:"id"`
+	Display string `json:"display,omitempty"`
+	Muted   *bool  `json:"muted,omitempty"`
+	// AudioBridge names the event of talking events instead of Event
+	AudioBridge string `json:"audiobridge,omitempty"`
+}
+
+// ParseHandlerEvents parses a body posted by the Janus HTTP event handler, a single event or
+// an array of events when grouping is enabled
+func ParseHandlerEvents(body []byte) ([]HandlerEvent, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var events []HandlerEvent
+		if err := json.Unmarshal(body, &events); err != nil {
+			return nil, err
+		}
+		return events, nil
+	}
+
+	var event HandlerEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	return []HandlerEvent{event}, nil
+}
+
+// AudioBridgeEvent returns the AudioBridge event carried by e, false for other events
+func (e *HandlerEvent) AudioBridgeEvent() (*AudioBridgeEvent, bool) {
+	if e.Type != EventTypePlugin {
+		return nil, false
+	}
+	var plugin PluginEvent
+	if err := json.Unmarshal(e.Event, &plugin); err != nil || plugin.Plugin != janusPluginAudioBridge {
+		return nil, false
+	}
+	var event AudioBridgeEvent
+	if err := json.Unmarshal(plugin.Data, &event); err != nil {
+		return nil, false
+	}
+	if event.Event == "" {
+		event.Event = event.AudioBridge
+	}
+	if event.Event == "" {
+		return nil, false
+	}
+	return &event, true
+}
@@ -0,0 +1,72 @@
+package janus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerEvents(t *testing.T) {
+	t.Run("single and grouped bodies", func(t *testing.T) {
+		events, err := ParseHandlerEvents([]byte(`{"type":1,"timestamp":1,"event":{"name":"created"}}`))
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+
+		events, err = ParseHandlerEvents([]byte(` [{"type":1,"event":{}},{"type":64,"event":{}}]`))
+		require.NoError(t, err)
+		assert.Len(t, events, 2)
+
+		_, err = ParseHandlerEvents([]byte(`not json`))
+		assert.Error(t, err)
+	})
+
+	t.Run("audiobridge events", func(t *testing.T) {
+		events, err := ParseHandlerEvents([]byte(`[
+			{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"event":"configured","room":100001,"id":7,"display":"user-u1","muted":true}}},
+			{"type":64,"event":{"plugin":"janus.plugin.videoroom","data":{"event":"joined","room":1}}},
+			{"type":32,"event":{"media":"audio"}}
+		]`))
+		require.NoError(t, err)
+
+		event, ok := events[0].AudioBridgeEvent()
+		require.True(t, ok)
+		assert.Equal(t, AudioBridgeConfigured, event.Event)
+		assert.Equal(t, int64(100001), event.Room)
+		assert.Equal(t, "user-u1", event.Display)
+		require.NotNil(t, event.Muted)
+		assert.True(t, *event.Muted)
+
+		_, ok = events[1].AudioBridgeEvent()
+		assert.False(t, ok, "other plugin")
+		_, ok = events[2].AudioBridgeEvent()
+		assert.False(t, ok, "not a plugin event")
+	})
+
+	t.Run("talking events", func(t *testing.T) {
+		events, err := ParseHandlerEvents([]byte(`[
+			{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"audiobridge":"talking","room":100001,"id":7}}},
+			{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"room":100001,"id":7}}}
+		]`))
+		require.NoError(t, err)
+
+		event, ok := events[0].AudioBridgeEvent()
+		require.True(t, ok)
+		assert.Equal(t, AudioBridgeTalking, event.Event)
+		assert.Equal(t, int64(7), event.ID)
+
+		_, ok = events[1].AudioBridgeEvent()
+		assert.False(t, ok, "no event name")
+	})
+
+	t.Run("display names", func(t *testing.T) {
+		userID, ok := UserIDOf(DisplayName("u1"))
+		assert.True(t, ok)
+		assert.Equal(t, "u1", userID)
+
+		_, ok = UserIDOf("user-")
+		assert.False(t, ok)
+		_, ok = UserIDOf("canary")
+		assert.False(t, ok)
+	})
+}
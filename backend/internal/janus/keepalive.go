@@ -0,0 +1,157 @@
+package janus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const (
+	keepaliveInterval = 15 * time.Second
+	// keepaliveSlots spreads the sessions over the interval, one slot fired per tick
+	keepaliveSlots = 15
+	// keepaliveConcurrency bounds the keepalive requests in flight to one Janus
+	keepaliveConcurrency = 8
+)
+
+type keepaliveEntry struct {
+	slot int
+	refs int
+}
+
+// keepaliveScheduler keeps the sessions of one Janus instance alive from a single goroutine.
+// Sessions are placed on a timer wheel in the slot current at registration, so each one is
+// sent a keepalive once per interval while a gateway hosting thousands of anchors spreads the
+// requests over the interval instead of firing them together. Instances sharing a session
+// share its keepalive
+type keepaliveScheduler struct {
+	tick  time.Duration
+	send  func(ctx context.Context, sessionID int64) error
+	clock clockwork.Clock
+
+	mu       sync.Mutex
+	slots    []map[int64]struct{}
+	sessions map[int64]*keepaliveEntry
+	cursor   int
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	logger *log.Logger
+}
+
+func newKeepaliveScheduler(
+	interval time.Duration,
+	slots int,
+	send func(ctx context.Context, sessionID int64) error,
+	logger *log.Logger,
+) *keepaliveScheduler {
+	k := &keepaliveScheduler{
+		tick:     interval / time.Duration(slots),
+		send:     send,
+		clock:    clockwork.NewRealClock(),
+		slots:    make([]map[int64]struct{}, slots),
+		sessions: make(map[int64]*keepaliveEntry),
+		logger:   logger,
+	}
+	for i := range k.slots {
+		k.slots[i] = make(map[int64]struct{})
+	}
+	return k
+}
+
+// add schedules keepalives of the session, the loop starts with the first session
+func (k *keepaliveScheduler) add(sessionID int64) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if entry, ok := k.sessions[sessionID]; ok {
+		entry.refs++
+		return
+	}
+	k.sessions[sessionID] = &keepaliveEntry{slot: k.cursor, refs: 1}
+	k.slots[k.cursor][sessionID] = struct{}{}
+
+	if k.cancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		k.cancel = cancel
+		k.done = make(chan struct{})
+		go k.run(ctx, k.clock.NewTicker(k.tick), k.done)
+	}
+}
+
+// remove drops one reference of the session, the loop stops with the last session
+func (k *keepaliveScheduler) remove(sessionID int64) {
+	k.mu.Lock()
+	entry, ok := k.sessions[sessionID]
+	if !ok {
+		k.mu.Unlock()
+		return
+	}
+	if entry.refs--; entry.refs > 0 {
+		k.mu.Unlock()
+		return
+	}
+	delete(k.sessions, sessionID)
+	delete(k.slots[entry.slot], sessionID)
+
+	var done chan struct{}
+	if len(k.sessions) == 0 && k.cancel != nil {
+		k.cancel()
+		k.cancel = nil
+		done = k.done
+	}
+	k.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+func (k *keepaliveScheduler) run(ctx context.Context, ticker clockwork.Ticker, done chan struct{}) {
+	defer close(done)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.Chan():
+			k.fire(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fire advances the wheel and sends keepalives to the sessions of the slot
+func (k *keepaliveScheduler) fire(ctx context.Context) {
+	k.mu.Lock()
+	k.cursor = (k.cursor + 1) % len(k.slots)
+	sessionIDs := make([]int64, 0, len(k.slots[k.cursor]))
+	for sessionID := range k.slots[k.cursor] {
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	k.mu.Unlock()
+
+	sem := make(chan struct{}, keepaliveConcurrency)
+	var wg sync.WaitGroup
+	for _, sessionID := range sessionIDs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := k.send(ctx, sessionID); err != nil && ctx.Err() == nil {
+				k.logger.Warn("janus keepalive failed", log.Int64("sessionId", sessionID), log.Error(err))
+			}
+		}()
+	}
+	wg.Wait()
+}
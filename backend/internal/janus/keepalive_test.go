@@ -0,0 +1,96 @@
+package janus
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type keepaliveRecorder struct {
+	mu   sync.Mutex
+	sent map[int64]int
+}
+
+func (r *keepaliveRecorder) send(_ context.Context, sessionID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent[sessionID]++
+	return nil
+}
+
+func (r *keepaliveRecorder) count(sessionID int64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sent[sessionID]
+}
+
+func TestKeepaliveScheduler(t *testing.T) {
+	newScheduler := func() (*keepaliveScheduler, *keepaliveRecorder, *clockwork.FakeClock) {
+		rec := &keepaliveRecorder{sent: make(map[int64]int)}
+		clock := clockwork.NewFakeClock()
+		k := newKeepaliveScheduler(3*time.Second, 3, rec.send, log.NewNop())
+		k.clock = clock
+		return k, rec, clock
+	}
+	// advance moves the wheel one slot and waits for its keepalives
+	advance := func(t *testing.T, k *keepaliveScheduler, clock *clockwork.FakeClock) {
+		t.Helper()
+		k.mu.Lock()
+		cursor := (k.cursor + 1) % len(k.slots)
+		k.mu.Unlock()
+		require.NoError(t, clock.BlockUntilContext(context.Background(), 1))
+		clock.Advance(time.Second)
+		require.Eventually(t, func() bool {
+			k.mu.Lock()
+			defer k.mu.Unlock()
+			return k.cursor == cursor
+		}, time.Second, time.Millisecond)
+	}
+
+	t.Run("spreads sessions over the interval", func(t *testing.T) {
+		k, rec, clock := newScheduler()
+		k.add(1)
+		advance(t, k, clock)
+		k.add(2)
+
+		advance(t, k, clock)
+		advance(t, k, clock)
+		assert.Eventually(t, func() bool { return rec.count(1) == 1 }, time.Second, time.Millisecond)
+		assert.Equal(t, 0, rec.count(2))
+
+		advance(t, k, clock)
+		assert.Eventually(t, func() bool { return rec.count(2) == 1 }, time.Second, time.Millisecond)
+		assert.Equal(t, 1, rec.count(1))
+
+		k.remove(1)
+		k.remove(2)
+	})
+
+	t.Run("shares sessions between instances", func(t *testing.T) {
+		k, rec, clock := newScheduler()
+		k.add(1)
+		k.add(1)
+		k.remove(1)
+		for range 3 {
+			advance(t, k, clock)
+		}
+		assert.Eventually(t, func() bool { return rec.count(1) == 1 }, time.Second, time.Millisecond)
+
+		// the loop stops with the last session
+		k.remove(1)
+		assert.Nil(t, k.cancel)
+		assert.Empty(t, k.sessions)
+
+		// and starts again
+		k.add(3)
+		assert.NotNil(t, k.cancel)
+		k.remove(3)
+	})
+}
@@ -13,9 +13,8 @@ import (
 	context "context"
 	reflect "reflect"
 
-	gomock "go.uber.org/mock/gomock"
-
 	janus "github.com/imtaco/audio-rtc-exp/internal/janus"
+	gomock "go.uber.org/mock/gomock"
 )
 
 // MockAdmin is a mock of Admin interface.
@@ -55,32 +54,32 @@ func (mr *MockAdminMockRecorder) Close() *gomock.Call {
 }
 
 // CreateRTPForwarder mocks base method.
-func (m *MockAdmin) CreateRTPForwarder(ctx context.Context, roomID int64, host string, port int) (int64, error) {
+func (m *MockAdmin) CreateRTPForwarder(ctx context.Context, roomID int64, host string, port int, group string, srtp *janus.SRTP) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateRTPForwarder", ctx, roomID, host, port)
+	ret := m.ctrl.Call(m, "CreateRTPForwarder", ctx, roomID, host, port, group, srtp)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateRTPForwarder indicates an expected call of CreateRTPForwarder.
-func (mr *MockAdminMockRecorder) CreateRTPForwarder(ctx, roomID, host, port any) *gomock.Call {
+func (mr *MockAdminMockRecorder) CreateRTPForwarder(ctx, roomID, host, port, group, srtp any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRTPForwarder", reflect.TypeOf((*MockAdmin)(nil).CreateRTPForwarder), ctx, roomID, host, port)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRTPForwarder", reflect.TypeOf((*MockAdmin)(nil).CreateRTPForwarder), ctx, roomID, host, port, group, srtp)
 }
 
 // CreateRoom mocks base method.
-func (m *MockAdmin) CreateRoom(ctx context.Context, roomID int64, description, pin string) error {
+func (m *MockAdmin) CreateRoom(ctx context.Context, roomID int64, description, pin string, bitrate int, audio *janus.RoomAudio) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateRoom", ctx, roomID, description, pin)
+	ret := m.ctrl.Call(m, "CreateRoom", ctx, roomID, description, pin, bitrate, audio)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateRoom indicates an expected call of CreateRoom.
-func (mr *MockAdminMockRecorder) CreateRoom(ctx, roomID, description, pin any) *gomock.Call {
+func (mr *MockAdminMockRecorder) CreateRoom(ctx, roomID, description, pin, bitrate, audio any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRoom", reflect.TypeOf((*MockAdmin)(nil).CreateRoom), ctx, roomID, description, pin)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRoom", reflect.TypeOf((*MockAdmin)(nil).CreateRoom), ctx, roomID, description, pin, bitrate, audio)
 }
 
 // Destroy mocks base method.
@@ -141,19 +141,34 @@ func (mr *MockAnchorMockRecorder) IceCandidate(ctx, candidate any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IceCandidate", reflect.TypeOf((*MockAnchor)(nil).IceCandidate), ctx, candidate)
 }
 
+// IceRestart mocks base method.
+func (m *MockAnchor) IceRestart(ctx context.Context, jsep *janus.JSEP) (*janus.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IceRestart", ctx, jsep)
+	ret0, _ := ret[0].(*janus.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IceRestart indicates an expected call of IceRestart.
+func (mr *MockAnchorMockRecorder) IceRestart(ctx, jsep any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IceRestart", reflect.TypeOf((*MockAnchor)(nil).IceRestart), ctx, jsep)
+}
+
 // Join mocks base method.
-func (m *MockAnchor) Join(ctx context.Context, roomID int64, pin, displayName string, jsep *janus.JSEP) (*janus.Response, error) {
+func (m *MockAnchor) Join(ctx context.Context, roomID int64, pin, displayName string, bitrate int, group string, jsep *janus.JSEP) (*janus.Response, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Join", ctx, roomID, pin, displayName, jsep)
+	ret := m.ctrl.Call(m, "Join", ctx, roomID, pin, displayName, bitrate, group, jsep)
 	ret0, _ := ret[0].(*janus.Response)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Join indicates an expected call of Join.
-func (mr *MockAnchorMockRecorder) Join(ctx, roomID, pin, displayName, jsep any) *gomock.Call {
+func (mr *MockAnchorMockRecorder) Join(ctx, roomID, pin, displayName, bitrate, group, jsep any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Join", reflect.TypeOf((*MockAnchor)(nil).Join), ctx, roomID, pin, displayName, jsep)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Join", reflect.TypeOf((*MockAnchor)(nil).Join), ctx, roomID, pin, displayName, bitrate, group, jsep)
 }
 
 // KeepAlive mocks base method.
@@ -185,6 +200,34 @@ func (mr *MockAnchorMockRecorder) Leave(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Leave", reflect.TypeOf((*MockAnchor)(nil).Leave), ctx)
 }
 
+// SetGroup mocks base method.
+func (m *MockAnchor) SetGroup(ctx context.Context, group string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetGroup", ctx, group)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetGroup indicates an expected call of SetGroup.
+func (mr *MockAnchorMockRecorder) SetGroup(ctx, group any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetGroup", reflect.TypeOf((*MockAnchor)(nil).SetGroup), ctx, group)
+}
+
+// SetMuted mocks base method.
+func (m *MockAnchor) SetMuted(ctx context.Context, muted bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMuted", ctx, muted)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMuted indicates an expected call of SetMuted.
+func (mr *MockAnchorMockRecorder) SetMuted(ctx, muted any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMuted", reflect.TypeOf((*MockAnchor)(nil).SetMuted), ctx, muted)
+}
+
 // StartKeepalive mocks base method.
 func (m *MockAnchor) StartKeepalive() {
 	m.ctrl.T.Helper()
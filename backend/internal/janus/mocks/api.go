@@ -71,3 +71,46 @@ func (mr *MockAPIMockRecorder) CreateAnchorInstance(ctx, clientID, sessionID, ha
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAnchorInstance", reflect.TypeOf((*MockAPI)(nil).CreateAnchorInstance), ctx, clientID, sessionID, handleID)
 }
+
+// CreateSession mocks base method.
+func (m *MockAPI) CreateSession(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockAPIMockRecorder) CreateSession(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockAPI)(nil).CreateSession), ctx)
+}
+
+// DestroySession mocks base method.
+func (m *MockAPI) DestroySession(ctx context.Context, sessionID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DestroySession", ctx, sessionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DestroySession indicates an expected call of DestroySession.
+func (mr *MockAPIMockRecorder) DestroySession(ctx, sessionID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DestroySession", reflect.TypeOf((*MockAPI)(nil).DestroySession), ctx, sessionID)
+}
+
+// KeepAliveSession mocks base method.
+func (m *MockAPI) KeepAliveSession(ctx context.Context, sessionID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "KeepAliveSession", ctx, sessionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// KeepAliveSession indicates an expected call of KeepAliveSession.
+func (mr *MockAPIMockRecorder) KeepAliveSession(ctx, sessionID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KeepAliveSession", reflect.TypeOf((*MockAPI)(nil).KeepAliveSession), ctx, sessionID)
+}
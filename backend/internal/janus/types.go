@@ -7,6 +7,13 @@ import (
 	"github.com/imtaco/audio-rtc-exp/internal/errors"
 )
 
+// AudioBridge participant groups every room is created with. Forwarders of linked rooms
+// forward the link group only, so a single participant can be relayed to another room.
+const (
+	GroupRoom = "room"
+	GroupLink = "link"
+)
+
 type API interface {
 	CreateAnchorInstance(
 		ctx context.Context,
@@ -15,26 +22,49 @@ type API interface {
 		handleID int64,
 	) (Anchor, error)
 	CreateAdminInstance(ctx context.Context, adminKey string) (Admin, error)
+	// CreateSession creates a session without plugin handle, attached later by CreateAnchorInstance
+	CreateSession(ctx context.Context) (int64, error)
+	KeepAliveSession(ctx context.Context, sessionID int64) error
+	DestroySession(ctx context.Context, sessionID int64) error
 }
 
 // Admin defines the interface for Janus administrative operations
 type Admin interface {
 	Base
-	CreateRoom(ctx context.Context, roomID int64, description, pin string) error
+	// CreateRoom creates an AudioBridge room, a nil audio keeps the Opus defaults of Janus
+	CreateRoom(ctx context.Context, roomID int64, description, pin string, bitrate int, audio *RoomAudio) error
 	DestroyRoom(ctx context.Context, roomID int64) error
 	GetRoom(ctx context.Context, roomID int64) (bool, error)
-	CreateRTPForwarder(ctx context.Context, roomID int64, host string, port int) (int64, error)
+	// CreateRTPForwarder forwards the mix of the given participant group, an empty group forwards the whole room.
+	// A non-nil srtp encrypts the forward
+	CreateRTPForwarder(ctx context.Context, roomID int64, host string, port int, group string, srtp *SRTP) (int64, error)
 	StopRTPForwarder(ctx context.Context, roomID, streamID int64) error
 	ListRTPForwarders(ctx context.Context, roomID int64) ([]RTPForwarderInfo, error)
 	ListRooms(ctx context.Context) ([]RoomInfo, error)
 }
 
+// RoomAudio tunes the Opus encoders of an AudioBridge room towards its participants
+type RoomAudio struct {
+	// ExpectedLoss is the packet loss in percent in-band FEC is sized for, 0 disables FEC
+	ExpectedLoss int
+	// Stereo mixes the room in stereo, participants are placed in the center
+	Stereo bool
+	// TalkingEvents reports participants starting and stopping to talk to the event handlers
+	TalkingEvents bool
+}
+
 type Anchor interface {
 	Base
-	Join(ctx context.Context, roomID int64, pin string, displayName string, jsep *JSEP) (*Response, error)
+	Join(ctx context.Context, roomID int64, pin string, displayName string, bitrate int, group string, jsep *JSEP) (*Response, error)
 	Leave(ctx context.Context) (*Response, error)
 	IceCandidate(ctx context.Context, candidate ICECandidate) (*Response, error)
+	// IceRestart renegotiates the joined participant with an ICE restart offer, answered in events
+	IceRestart(ctx context.Context, jsep *JSEP) (*Response, error)
 	Check(ctx context.Context) (bool, error)
+	// SetMuted mutes or unmutes the participant in the AudioBridge room
+	SetMuted(ctx context.Context, muted bool) error
+	// SetGroup moves the participant to another group of the AudioBridge room
+	SetGroup(ctx context.Context, group string) error
 }
 
 type Base interface {
@@ -109,6 +139,8 @@ func pluginErrorCode(resp *Response) (int, bool) {
 type JSEP struct {
 	Type string `json:"type"`
 	SDP  string `json:"sdp"`
+	// Update marks an offer renegotiating an established PeerConnection, e.g. an ICE restart
+	Update bool `json:"update,omitempty"`
 }
 
 // ICECandidate models the Janus trickle candidate payload.
@@ -128,6 +160,15 @@ type JoinRequest struct {
 	Display string `json:"display"`
 	Muted   bool   `json:"muted"`
 	Pin     string `json:"pin,omitempty"`
+	Bitrate int    `json:"bitrate,omitempty"`
+	Group   string `json:"group,omitempty"`
+}
+
+// ConfigureRequest represents an AudioBridge configure request of the participant.
+type ConfigureRequest struct {
+	Request string `json:"request"`
+	Muted   *bool  `json:"muted,omitempty"`
+	Group   string `json:"group,omitempty"`
 }
 
 // LeaveRequest represents an AudioBridge leave request.
@@ -144,14 +185,19 @@ type ExistsRequest struct {
 
 // CreateRoomRequest represents a room creation request.
 type CreateRoomRequest struct {
-	Request      string `json:"request"`
-	Room         int64  `json:"room"`
-	Description  string `json:"description,omitempty"`
-	SamplingRate int    `json:"sampling_rate,omitempty"`
-	SpatialAudio bool   `json:"spatial_audio,omitempty"`
-	Record       bool   `json:"record,omitempty"`
-	Pin          string `json:"pin,omitempty"`
-	AdminKey     string `json:"admin_key,omitempty"`
+	Request        string `json:"request"`
+	Room           int64  `json:"room"`
+	Description    string `json:"description,omitempty"`
+	SamplingRate   int    `json:"sampling_rate,omitempty"`
+	SpatialAudio   bool   `json:"spatial_audio,omitempty"`
+	Record         bool   `json:"record,omitempty"`
+	Pin            string `json:"pin,omitempty"`
+	DefaultBitrate int    `json:"default_bitrate,omitempty"`
+	ExpectedLoss   int    `json:"default_expectedloss,omitempty"`
+	// AudioLevelEvent emits talking and stopped-talking events of the participants
+	AudioLevelEvent bool     `json:"audio_level_event,omitempty"`
+	Groups          []string `json:"groups,omitempty"`
+	AdminKey        string   `json:"admin_key,omitempty"`
 }
 
 // DestroyRoomRequest represents a room destruction request.
@@ -168,7 +214,19 @@ type RTPForwardRequest struct {
 	Host     string `json:"host"`
 	Port     int    `json:"port"`
 	Codec    string `json:"codec,omitempty"`
+	Group    string `json:"group,omitempty"`
 	AdminKey string `json:"admin_key,omitempty"`
+	// SRTPSuite is the authentication tag length, 32 or 80, set with SRTPCrypto
+	SRTPSuite  int    `json:"srtp_suite,omitempty"`
+	SRTPCrypto string `json:"srtp_crypto,omitempty"`
+}
+
+// SRTP encrypts an RTP forwarder
+type SRTP struct {
+	// Suite is the authentication tag length, 32 or 80
+	Suite int
+	// Crypto is the base64 master key and salt
+	Crypto string
 }
 
 // StopRTPForwardRequest represents an RTP forwarder stop request.
@@ -210,6 +268,8 @@ type RTPForwarderInfo struct {
 	Host     string `json:"ip,omitempty"`
 	Port     int    `json:"port,omitempty"`
 	Codec    string `json:"codec,omitempty"`
+	Group    string `json:"group,omitempty"`
+	SRTP     bool   `json:"srtp,omitempty"`
 }
 
 // ExistsResponse represents the response to an exists check.
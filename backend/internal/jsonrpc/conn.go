@@ -18,13 +18,23 @@ import (
 
 type handlerFunc[T any] func(context.Context, *connImpl[T], *Request)
 
+const (
+	// localQueueSize bounds notifications dispatched to a connection while it handles a request
+	localQueueSize = 16
+	// maxBatchSize bounds the messages of a batch frame, larger batches are rejected as a whole
+	maxBatchSize = 32
+)
+
 type connImpl[T any] struct {
 	stream   ObjectStream
 	mctx     MethodContext[T]
 	handler  handlerFunc[T]
 	sendLock sync.Mutex
 	closed   atomic.Bool
-	pendings sync.Map // map[ID]*call
+	done     chan struct{} // closed on close
+	local    chan *Request // dispatched by the server, handled between stream messages
+	pendings sync.Map      // map[ID]*call
+	replay   *replayCache  // nil without request deduplication
 	logger   *log.Logger
 }
 
@@ -37,6 +47,8 @@ func newConn[T any](
 	c := &connImpl[T]{
 		stream:   stream,
 		closed:   atomic.Bool{},
+		done:     make(chan struct{}),
+		local:    make(chan *Request, localQueueSize),
 		pendings: sync.Map{},
 		handler:  handler,
 		logger:   logger,
@@ -83,31 +95,68 @@ func (c *connImpl[T]) Notify(ctx context.Context, method string, params any) err
 	return err
 }
 
+// Dispatch queues a notification to the method handlers of this connection, handled on the same
+// goroutine as requests from the peer so handlers never run concurrently for a connection
+func (c *connImpl[T]) Dispatch(ctx context.Context, method string, params any) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	bs, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	raw := json.RawMessage(bs)
+	req := &Request{Method: method, Params: &raw, local: true}
+
+	select {
+	case c.local <- req:
+		return nil
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // reply sends a successful response with a result.
-func (c *connImpl[T]) reply(ctx context.Context, id *ID, result any) error {
-	if id == nil {
+func (c *connImpl[T]) reply(ctx context.Context, req *Request, result any) error {
+	if req.ID == nil {
 		return nil
 	}
-	resp, err := newResponseMessage(*id, result, nil)
+	resp, err := newResponseMessage(*req.ID, result, nil)
 	if err != nil {
 		return err
 	}
-	_, err = c.send(ctx, resp)
-	return err
+	return c.respond(ctx, req, resp)
 }
 
 // ReplyWithError sends a response with an error.
-func (c *connImpl[T]) replyError(ctx context.Context, id *ID, respErr *Error) error {
-	if id == nil {
+func (c *connImpl[T]) replyError(ctx context.Context, req *Request, respErr *Error) error {
+	if req.ID == nil {
 		return nil
 	}
 
-	resp, err := newResponseMessage(*id, nil, respErr)
+	resp, err := newResponseMessage(*req.ID, nil, respErr)
 	if err != nil {
 		return err
 	}
-	_, err = c.send(ctx, resp)
-	return err
+	return c.respond(ctx, req, resp)
+}
+
+// respond sends the response of a single request, responses to a batch are sent together
+// once all its requests replied
+func (c *connImpl[T]) respond(ctx context.Context, req *Request, resp *message) error {
+	if c.replay != nil && !req.local {
+		c.replay.finish(*req.ID, resp)
+	}
+	if req.batch == nil {
+		_, err := c.send(ctx, resp)
+		return err
+	}
+	if resps, complete := req.batch.done(resp); complete {
+		return c.sendBatch(ctx, resps)
+	}
+	return nil
 }
 
 func (c *connImpl[T]) close(err error) error {
@@ -115,6 +164,7 @@ func (c *connImpl[T]) close(err error) error {
 		// already closed
 		return ErrClosed
 	}
+	close(c.done)
 
 	// to avoid race condition, first collect all pending keys
 	// then delete them with popPending
@@ -140,56 +190,169 @@ func (c *connImpl[T]) close(err error) error {
 }
 
 func (c *connImpl[T]) readLoop(ctx context.Context) {
+	frames := make(chan *frame)
+	readErr := make(chan error, 1)
+	go c.readStream(ctx, frames, readErr)
+
 	for {
-		var m message
-		err := c.stream.Read(ctx, &m)
-		// TODO: deal with JSON unmarshal errors ?
-		if err != nil {
+		select {
+		case f := <-frames:
+			c.handleFrame(ctx, f)
+		case err := <-readErr:
+			// messages read and notifications dispatched before the error are handled,
+			// pending calls fail from here
 			c.logger.Error("jsonrpc read loop error", log.Error(err))
+			c.handleLocal(ctx)
 			c.close(err)
 			return
+		case req := <-c.local:
+			c.handleDispatched(ctx, req)
 		}
+	}
+}
 
-		if m.Result == nil {
-			c.logger.Debug("m.Result is nil")
-		} else {
-			c.logger.Debug("m.Result is", log.Any("v", *m.Result))
-		}
-
-		// validation failure -> UnknownType
-		m.validate()
-
-		switch m.msgType {
-		case typeRequst, typeNotification:
-			c.logger.Debug("jsonrpc handle message", log.Any("msgType", m.msgType))
-			req := &Request{
-				ID:     m.ID,
-				Method: *m.Method,
-				Params: m.Params,
-			}
-			c.logger.Info("jsonrpc handle request", log.Any("req", req))
-			c.handler(ctx, c, req)
-
-		case typeResponse:
-			if !m.ID.IsSet() {
-				c.logger.Debug("ignore response without id")
-				continue
-			}
-
-			done := c.popPending(*m.ID)
-			if done == nil {
-				c.logger.Debug("ignore response with unmatched id", log.Any("id", m.ID))
-				continue
-			}
-			done <- &m
-			close(done)
-
+// handleLocal handles the queued dispatched notifications
+func (c *connImpl[T]) handleLocal(ctx context.Context) {
+	for {
+		select {
+		case req := <-c.local:
+			c.handleDispatched(ctx, req)
 		default:
-			c.logger.Warn("ignore invalid message: neither request nor response is set")
+			return
+		}
+	}
+}
+
+func (c *connImpl[T]) handleDispatched(ctx context.Context, req *Request) {
+	c.logger.Debug("jsonrpc handle dispatched notification", log.String("method", req.Method))
+	c.handler(ctx, c, req)
+}
+
+// readStream passes messages to readLoop until the stream fails
+func (c *connImpl[T]) readStream(ctx context.Context, frames chan<- *frame, readErr chan<- error) {
+	for {
+		var raw json.RawMessage
+		// TODO: deal with JSON unmarshal errors ?
+		if err := c.stream.Read(ctx, &raw); err != nil {
+			readErr <- err
+			return
+		}
+		f, err := decodeFrame(raw)
+		if err != nil {
+			readErr <- err
+			return
+		}
+		if f != nil {
+			frames <- f
+		}
+	}
+}
+
+// handleFrame handles the messages of a frame in order, the responses to the requests of a
+// batch are sent as one array
+func (c *connImpl[T]) handleFrame(ctx context.Context, f *frame) {
+	if !f.batch {
+		c.handleMessage(ctx, f.msgs[0], nil)
+		return
+	}
+
+	if len(f.msgs) == 0 || len(f.msgs) > maxBatchSize {
+		c.logger.Warn("reject batch", log.Int("size", len(f.msgs)))
+		_, _ = c.send(ctx, newErrorMessage(ErrInvalidRequest("invalid batch size")))
+		return
+	}
+
+	b := &batchReply{}
+	for _, m := range f.msgs {
+		if m == nil {
+			continue
 		}
+		c.handleMessage(ctx, m, b)
+	}
+	if resps, complete := b.seal(); complete {
+		if err := c.sendBatch(ctx, resps); err != nil {
+			c.logger.Error("Failed to send batch responses", log.Error(err))
+		}
+	}
+}
+
+func (c *connImpl[T]) handleMessage(ctx context.Context, m *message, b *batchReply) {
+	if m.Result == nil {
+		c.logger.Debug("m.Result is nil")
+	} else {
+		c.logger.Debug("m.Result is", log.Any("v", *m.Result))
+	}
+
+	// validation failure -> UnknownType
+	m.validate()
+
+	switch m.msgType {
+	case typeRequst, typeNotification:
+		c.logger.Debug("jsonrpc handle message", log.Any("msgType", m.msgType))
+		req := &Request{
+			ID:     m.ID,
+			Method: *m.Method,
+			Params: m.Params,
+		}
+		if m.msgType == typeRequst && c.replayed(ctx, req, b) {
+			return
+		}
+		if b != nil && m.msgType == typeRequst {
+			req.batch = b
+			b.add()
+		}
+		c.logger.Info("jsonrpc handle request", log.Any("req", req))
+		c.handler(ctx, c, req)
+
+	case typeResponse:
+		if !m.ID.IsSet() {
+			c.logger.Debug("ignore response without id")
+			return
+		}
+
+		done := c.popPending(*m.ID)
+		if done == nil {
+			c.logger.Debug("ignore response with unmatched id", log.Any("id", m.ID))
+			return
+		}
+		done <- m
+		close(done)
+
+	default:
+		c.logger.Warn("ignore invalid message: neither request nor response is set")
 	}
 }
 
+// replayed answers a request whose ID was seen within the replay window with the response to
+// the first one, or drops it while the first one is still handled as that one is answered
+func (c *connImpl[T]) replayed(ctx context.Context, req *Request, b *batchReply) bool {
+	if c.replay == nil {
+		return false
+	}
+	resp, seen := c.replay.begin(*req.ID)
+	if !seen {
+		return false
+	}
+	if resp == nil {
+		c.logger.Info("jsonrpc drop request in progress",
+			log.String("method", req.Method),
+			log.Any("id", req.ID))
+		return true
+	}
+
+	c.logger.Info("jsonrpc replay response",
+		log.String("method", req.Method),
+		log.Any("id", req.ID))
+	if b != nil {
+		req.batch = b
+		b.add()
+	}
+	if err := c.respond(ctx, req, resp); err != nil {
+		c.logger.Error("Failed to replay response", log.Any("id", req.ID), log.Error(err))
+	}
+	return true
+}
+
 func (c *connImpl[T]) send(ctx context.Context, m *message) (doneChan, error) {
 	// not allow concurrent sends
 	c.sendLock.Lock()
@@ -216,6 +379,17 @@ func (c *connImpl[T]) send(ctx context.Context, m *message) (doneChan, error) {
 	return done, nil
 }
 
+// sendBatch writes the responses to a batch as one array
+func (c *connImpl[T]) sendBatch(ctx context.Context, resps []*message) error {
+	c.sendLock.Lock()
+	defer c.sendLock.Unlock()
+
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	return c.stream.Write(ctx, resps)
+}
+
 func (c *connImpl[T]) wait(ctx context.Context, id *ID, done doneChan, result any) error {
 	select {
 	case <-ctx.Done():
@@ -248,3 +422,35 @@ func (c *connImpl[T]) popPending(id ID) doneChan {
 }
 
 type doneChan chan *message
+
+// batchReply collects the responses to the requests of a batch, complete once the batch was
+// handled and every request replied
+type batchReply struct {
+	mu      sync.Mutex
+	pending int
+	sealed  bool
+	resps   []*message
+}
+
+// add counts a request of the batch expecting a response
+func (b *batchReply) add() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending++
+}
+
+func (b *batchReply) done(resp *message) ([]*message, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.resps = append(b.resps, resp)
+	b.pending--
+	return b.resps, b.sealed && b.pending == 0
+}
+
+// seal marks all messages of the batch handled, a batch of notifications only completes empty
+func (b *batchReply) seal() ([]*message, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sealed = true
+	return b.resps, b.pending == 0 && len(b.resps) > 0
+}
@@ -8,10 +8,18 @@ import (
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 )
 
+// methodFunc serves a request, replying once done
+type methodFunc[T any] func(mctx MethodContext[T], info *RequestInfo, params *json.RawMessage, reply Reply)
+
 // Server manages JSON-RPC method handlers
 type handlerImpl[T any] struct {
-	methods map[string]AsyncMethodHandler[T]
-	logger  *log.Logger
+	methods map[string]methodFunc[T]
+	local   map[string]bool // methods served for dispatched notifications only
+	replay  *ReplayConfig   // nil without request deduplication
+	withID  map[string]bool // methods rejecting notifications
+	// interceptors run around Def and DefLocal handlers, DefAsync ones reply on their own
+	interceptors []Interceptor[T]
+	logger       *log.Logger
 }
 
 type peerImpl[T any] struct {
@@ -36,7 +44,9 @@ func NewHandler[T any](logger *log.Logger) Handler[T] {
 		panic("logger cannot be nil")
 	}
 	return &handlerImpl[T]{
-		methods: make(map[string]AsyncMethodHandler[T]),
+		methods: make(map[string]methodFunc[T]),
+		local:   make(map[string]bool),
+		withID:  make(map[string]bool),
 		logger:  logger,
 	}
 }
@@ -46,24 +56,71 @@ func (s *handlerImpl[T]) Def(method string, handler MethodHandler[T]) {
 	if _, ok := s.methods[method]; ok {
 		panic("method already defined: " + method)
 	}
-	s.methods[method] = func(mctx MethodContext[T], params *json.RawMessage, replier Reply) {
-		replier(handler(mctx, params))
+	s.methods[method] = func(mctx MethodContext[T], info *RequestInfo, params *json.RawMessage, replier Reply) {
+		replier(s.intercept(mctx, info, params, handler, 0))
 	}
 }
 
+// DefLocal registers a method handler only served for notifications dispatched by the server
+func (s *handlerImpl[T]) DefLocal(method string, handler MethodHandler[T]) {
+	s.Def(method, handler)
+	s.local[method] = true
+}
+
 func (s *handlerImpl[T]) DefAsync(method string, handler AsyncMethodHandler[T]) {
 	if _, ok := s.methods[method]; ok {
 		panic("method already defined: " + method)
 	}
 	// run with goroutine, so that handler is non-blocking
 	// TODO: limit max concurrent goroutines ?
-	s.methods[method] = func(mctx MethodContext[T], params *json.RawMessage, replier Reply) {
+	s.methods[method] = func(mctx MethodContext[T], _ *RequestInfo, params *json.RawMessage, replier Reply) {
 		go handler(mctx, params, replier)
 	}
 }
 
+func (s *handlerImpl[T]) Use(interceptors ...Interceptor[T]) {
+	for _, interceptor := range interceptors {
+		// nil interceptors are skipped, e.g. those of disabled features
+		if interceptor != nil {
+			s.interceptors = append(s.interceptors, interceptor)
+		}
+	}
+}
+
+// intercept runs the interceptors from i on, then the handler
+func (s *handlerImpl[T]) intercept(
+	mctx MethodContext[T],
+	info *RequestInfo,
+	params *json.RawMessage,
+	handler MethodHandler[T],
+	i int,
+) (any, error) {
+	if i == len(s.interceptors) {
+		return handler(mctx, params)
+	}
+	return s.interceptors[i](mctx, info, params, func(mctx MethodContext[T], params *json.RawMessage) (any, error) {
+		return s.intercept(mctx, info, params, handler, i+1)
+	})
+}
+
+// EnableReplay deduplicates requests by ID on connections created from now on, and makes the
+// methods listed in requireID ignore notifications as those cannot be deduplicated
+func (s *handlerImpl[T]) EnableReplay(cfg *ReplayConfig, requireID ...string) {
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	s.replay = cfg
+	for _, method := range requireID {
+		s.withID[method] = true
+	}
+}
+
 func (s *handlerImpl[T]) NewConn(stream ObjectStream, v *T) Conn[T] {
-	return newConn(stream, v, s.handle, s.logger)
+	conn := newConn(stream, v, s.handle, s.logger)
+	if s.replay != nil {
+		conn.replay = newReplayCache(s.replay)
+	}
+	return conn
 }
 
 func (s *handlerImpl[T]) handle(ctx context.Context, conn *connImpl[T], req *Request) {
@@ -73,13 +130,21 @@ func (s *handlerImpl[T]) handle(ctx context.Context, conn *connImpl[T], req *Req
 		log.Any("id", req.ID))
 
 	handler, ok := s.methods[req.Method]
+	if ok && s.local[req.Method] && !req.local {
+		ok = false
+	}
 	if !ok {
 		s.logger.Warn("Method not found",
 			log.Int("len", len(s.methods)),
 			log.String("method", req.Method),
 			log.Any("id", req.ID))
 
-		_ = conn.replyError(ctx, req.ID, ErrMethodNotFound(req.Method))
+		_ = conn.replyError(ctx, req, ErrMethodNotFound(req.Method))
+		return
+	}
+	if req.ID == nil && !req.local && s.withID[req.Method] {
+		s.logger.Warn("Ignore notification of method requiring a request ID",
+			log.String("method", req.Method))
 		return
 	}
 
@@ -91,7 +156,7 @@ func (s *handlerImpl[T]) handle(ctx context.Context, conn *connImpl[T], req *Req
 				log.Error(err))
 		}
 	}
-	handler(conn.mctx, req.Params, reply)
+	handler(conn.mctx, &RequestInfo{Method: req.Method, ID: req.ID, Local: req.local}, req.Params, reply)
 }
 
 func (s *handlerImpl[T]) reply(
@@ -105,7 +170,7 @@ func (s *handlerImpl[T]) reply(
 	if err == nil {
 		s.logger.Debug("RPC request completed",
 			log.Any("id", req.ID))
-		return conn.reply(ctx, req.ID, result)
+		return conn.reply(ctx, req, result)
 	}
 
 	if rpcErr, ok := errors.As[*Error](err); ok {
@@ -114,7 +179,7 @@ func (s *handlerImpl[T]) reply(
 			log.Any("id", req.ID),
 			log.Int64("error_code", rpcErr.Code),
 			log.String("error_message", rpcErr.Message))
-		return conn.replyError(ctx, req.ID, rpcErr)
+		return conn.replyError(ctx, req, rpcErr)
 	}
 	s.logger.Error("RPC handler returned unexpected error",
 		log.String("method", req.Method),
@@ -122,5 +187,5 @@ func (s *handlerImpl[T]) reply(
 		log.Error(err))
 
 	// do not disclose internal error details to client
-	return conn.replyError(ctx, req.ID, ErrInternal("unknown error"))
+	return conn.replyError(ctx, req, ErrInternal("unknown error"))
 }
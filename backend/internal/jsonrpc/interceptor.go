@@ -0,0 +1,52 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"runtime/debug"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// Recover turns panics of handlers into an internal error reply instead of crashing the
+// connection's read loop
+func Recover[T any](logger *log.Logger) Interceptor[T] {
+	return func(
+		mctx MethodContext[T],
+		info *RequestInfo,
+		params *json.RawMessage,
+		next MethodHandler[T],
+	) (result any, err error) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			logger.Error("Handler panicked",
+				log.String("method", info.Method),
+				log.Any("id", info.ID),
+				log.Any("panic", rec),
+				log.String("stack", string(debug.Stack())))
+			result, err = nil, ErrInternal("unknown error")
+		}()
+		return next(mctx, params)
+	}
+}
+
+// Interceptor returns the request log as an interceptor of requests from the peer, nil when
+// the request log is disabled
+func (l *RequestLogger[T]) Interceptor() Interceptor[T] {
+	if l == nil {
+		return nil
+	}
+	return func(
+		mctx MethodContext[T],
+		info *RequestInfo,
+		params *json.RawMessage,
+		next MethodHandler[T],
+	) (any, error) {
+		if info.Local {
+			return next(mctx, params)
+		}
+		return l.Wrap(info.Method, next)(mctx, params)
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -83,6 +86,71 @@ func (s *JSONRPCSuite) TestHandleDispatchesRegisteredHandler() {
 	s.Equal("ok", out["status"])
 }
 
+func (s *JSONRPCSuite) TestUseRunsInterceptorsInOrder() {
+	core := s.newHandler()
+	var calls []string
+	trace := func(name string) Interceptor[map[string]string] {
+		return func(
+			mctx MethodContext[map[string]string],
+			info *RequestInfo,
+			params *json.RawMessage,
+			next MethodHandler[map[string]string],
+		) (any, error) {
+			calls = append(calls, name+" "+info.Method)
+			return next(mctx, params)
+		}
+	}
+	core.Def("echo", func(MethodContext[map[string]string], *json.RawMessage) (any, error) {
+		calls = append(calls, "handler")
+		return "ok", nil
+	})
+	// defined after the method and nil skipped
+	core.Use(trace("outer"), nil, trace("inner"))
+
+	conn, stream := s.newConnWithHandler(nil)
+	core.handle(context.Background(), conn, &Request{ID: newStringID("1"), Method: "echo"})
+	s.Equal([]string{"outer echo", "inner echo", "handler"}, calls)
+	s.Require().Len(stream.writes, 1)
+	s.Nil(stream.writes[0].Error)
+}
+
+func (s *JSONRPCSuite) TestUseInterceptorRejects() {
+	core := s.newHandler()
+	var info *RequestInfo
+	core.Use(func(
+		_ MethodContext[map[string]string],
+		i *RequestInfo,
+		_ *json.RawMessage,
+		_ MethodHandler[map[string]string],
+	) (any, error) {
+		info = i
+		return nil, ErrInvalidRequest("not allowed")
+	})
+	core.DefLocal("local", func(MethodContext[map[string]string], *json.RawMessage) (any, error) {
+		s.Fail("handler must not run")
+		return nil, nil
+	})
+
+	conn, stream := s.newConnWithHandler(nil)
+	core.handle(context.Background(), conn, &Request{Method: "local", local: true})
+	s.Equal(&RequestInfo{Method: "local", Local: true}, info)
+	s.Empty(stream.writes)
+}
+
+func (s *JSONRPCSuite) TestRecoverRepliesInternalError() {
+	core := s.newHandler()
+	core.Use(Recover[map[string]string](log.NewTest(s.T())))
+	core.Def("boom", func(MethodContext[map[string]string], *json.RawMessage) (any, error) {
+		panic("boom")
+	})
+
+	conn, stream := s.newConnWithHandler(nil)
+	core.handle(context.Background(), conn, &Request{ID: newStringID("1"), Method: "boom"})
+	s.Require().Len(stream.writes, 1)
+	s.Require().NotNil(stream.writes[0].Error)
+	s.EqualValues(CodeInternalError, stream.writes[0].Error.Code)
+}
+
 func (s *JSONRPCSuite) TestDefAsyncRunsHandler() {
 	core := s.newHandler()
 	done := make(chan struct{})
@@ -379,13 +447,253 @@ func (s *JSONRPCSuite) TestReadLoopIgnoresResponseWithUnmatchedID() {
 	}
 }
 
+func (s *JSONRPCSuite) TestDispatchHandledByReadLoop() {
+	reqCh := make(chan *Request, 2)
+	handler := func(_ context.Context, _ *connImpl[map[string]string], req *Request) {
+		reqCh <- req
+	}
+	conn, stream := s.newConnWithHandler(handler)
+	s.Require().NoError(conn.Dispatch(context.Background(), "local", map[string]string{"k": "v"}))
+
+	method := "hello"
+	stream.enqueueRead(&message{ID: newStringID("req"), Method: &method})
+	conn.readLoop(context.Background())
+
+	methods := []string{}
+	for range 2 {
+		req := <-reqCh
+		methods = append(methods, req.Method)
+		if req.Method == "local" {
+			s.True(req.local)
+			s.Nil(req.ID)
+			s.JSONEq(`{"k":"v"}`, string(*req.Params))
+		}
+	}
+	s.ElementsMatch([]string{"local", "hello"}, methods)
+}
+
+func (s *JSONRPCSuite) TestDispatchRejectsClosedConn() {
+	conn, _ := s.newConnWithHandler(nil)
+	s.Require().NoError(conn.Close())
+	s.ErrorIs(conn.Dispatch(context.Background(), "local", nil), ErrClosed)
+}
+
+func (s *JSONRPCSuite) TestDefLocalOnlyServesDispatched() {
+	core := s.newHandler()
+	core.DefLocal("local", func(MethodContext[map[string]string], *json.RawMessage) (any, error) {
+		return map[string]string{"status": "ok"}, nil
+	})
+	conn, stream := s.newConnWithHandler(nil)
+
+	core.handle(context.Background(), conn, &Request{ID: newStringID("1"), Method: "local"})
+	s.Require().Len(stream.writes, 1)
+	s.EqualValues(CodeMethodNotFound, stream.writes[0].Error.Code)
+
+	// dispatched notifications have no ID, nothing is replied
+	called := false
+	core.methods["local"] = func(MethodContext[map[string]string], *RequestInfo, *json.RawMessage, Reply) { called = true }
+	core.handle(context.Background(), conn, &Request{Method: "local", local: true})
+	s.True(called)
+	s.Len(stream.writes, 1)
+}
+
+func (s *JSONRPCSuite) TestReadLoopHandlesBatchInOrder() {
+	core := s.newHandler()
+	calls := []string{}
+	core.Def("join", func(MethodContext[map[string]string], *json.RawMessage) (any, error) {
+		calls = append(calls, "join")
+		return map[string]string{"status": "ok"}, nil
+	})
+	core.Def("status", func(MethodContext[map[string]string], *json.RawMessage) (any, error) {
+		calls = append(calls, "status")
+		return nil, ErrInvalidParams("bad status")
+	})
+	core.DefAsync("ice", func(_ MethodContext[map[string]string], _ *json.RawMessage, reply Reply) {
+		reply(nil, nil)
+	})
+	conn, stream := s.newConnWithHandler(core.handle)
+
+	stream.enqueueRaw(`[
+		{"jsonrpc":"2.0","id":1,"method":"join"},
+		{"jsonrpc":"2.0","method":"status"},
+		{"jsonrpc":"2.0","id":"2","method":"status"},
+		{"jsonrpc":"2.0","id":3,"method":"ice"},
+		{"jsonrpc":"2.0","id":4,"method":"missing"}
+	]`)
+	stream.readBlock = make(chan struct{})
+	go conn.readLoop(context.Background())
+
+	// the async reply completes the batch
+	s.Eventually(func() bool {
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+		return len(stream.batches) == 1
+	}, time.Second, 5*time.Millisecond)
+	close(stream.readBlock)
+
+	s.Equal([]string{"join", "status", "status"}, calls)
+	s.Empty(stream.writes)
+	s.Require().Len(stream.batches, 1)
+
+	byID := map[string]*message{}
+	for _, resp := range stream.batches[0] {
+		byID[resp.ID.String()] = resp
+	}
+	s.Require().Len(byID, 4)
+	s.JSONEq(`{"status":"ok"}`, string(*byID["1"].Result))
+	s.EqualValues(CodeInvalidParams, byID[`"2"`].Error.Code)
+	s.Nil(byID["3"].Error)
+	s.EqualValues(CodeMethodNotFound, byID["4"].Error.Code)
+}
+
+func (s *JSONRPCSuite) TestReadLoopBatchOfNotificationsHasNoResponse() {
+	core := s.newHandler()
+	called := 0
+	core.Def("ice", func(MethodContext[map[string]string], *json.RawMessage) (any, error) {
+		called++
+		//nolint:nilnil
+		return nil, nil
+	})
+	conn, stream := s.newConnWithHandler(core.handle)
+
+	stream.enqueueRaw(`[{"jsonrpc":"2.0","method":"ice"},{"jsonrpc":"2.0","method":"ice"}]`)
+	conn.readLoop(context.Background())
+
+	s.Equal(2, called)
+	s.Empty(stream.writes)
+	s.Empty(stream.batches)
+}
+
+func (s *JSONRPCSuite) TestReadLoopRejectsInvalidBatchSize() {
+	conn, stream := s.newConnWithHandler(func(context.Context, *connImpl[map[string]string], *Request) {
+		s.Fail("unexpected request")
+	})
+
+	stream.enqueueRaw(`[]`)
+	conn.readLoop(context.Background())
+
+	s.Require().Len(stream.writes, 1)
+	s.EqualValues(CodeInvalidRequest, stream.writes[0].Error.Code)
+}
+
+func (s *JSONRPCSuite) newReplayConn(core *handlerImpl[map[string]string]) (*connImpl[map[string]string], *stubStream) {
+	core.EnableReplay(&ReplayConfig{Enabled: true, Window: time.Minute, Size: 2}, "join")
+	stream := newStubStream()
+	conn := core.NewConn(stream, nil).(*connImpl[map[string]string])
+	return conn, stream
+}
+
+func (s *JSONRPCSuite) TestReplayResentRequest() {
+	core := s.newHandler()
+	joins := 0
+	core.Def("join", func(MethodContext[map[string]string], *json.RawMessage) (any, error) {
+		joins++
+		return map[string]int{"joins": joins}, nil
+	})
+	conn, stream := s.newReplayConn(core)
+
+	stream.enqueueRaw(`{"jsonrpc":"2.0","id":1,"method":"join"}`)
+	stream.enqueueRaw(`{"jsonrpc":"2.0","id":1,"method":"join"}`)
+	stream.enqueueRaw(`{"jsonrpc":"2.0","id":2,"method":"join"}`)
+	// notifications of methods requiring an ID are ignored
+	stream.enqueueRaw(`{"jsonrpc":"2.0","method":"join"}`)
+	conn.readLoop(context.Background())
+
+	s.Equal(2, joins)
+	s.Require().Len(stream.writes, 3)
+	s.JSONEq(`{"joins":1}`, string(*stream.writes[0].Result))
+	s.JSONEq(`{"joins":1}`, string(*stream.writes[1].Result))
+	s.Equal("1", stream.writes[1].ID.String())
+	s.JSONEq(`{"joins":2}`, string(*stream.writes[2].Result))
+}
+
+func (s *JSONRPCSuite) TestReplayDropsRequestInProgress() {
+	core := s.newHandler()
+	replies := make(chan Reply, 2)
+	core.DefAsync("offer", func(_ MethodContext[map[string]string], _ *json.RawMessage, reply Reply) {
+		replies <- reply
+	})
+	conn, stream := s.newReplayConn(core)
+
+	f, err := decodeFrame(json.RawMessage(`{"jsonrpc":"2.0","id":"a","method":"offer"}`))
+	s.Require().NoError(err)
+	ctx := context.Background()
+	conn.handleFrame(ctx, f)
+	conn.handleFrame(ctx, f)
+
+	reply := <-replies
+	reply(map[string]string{"sdp": "answer"}, nil)
+	s.Empty(replies)
+	s.Require().Len(stream.writes, 1)
+	s.Equal(`"a"`, stream.writes[0].ID.String())
+}
+
+func (s *JSONRPCSuite) TestReplayInBatch() {
+	core := s.newHandler()
+	joins := 0
+	core.Def("join", func(MethodContext[map[string]string], *json.RawMessage) (any, error) {
+		joins++
+		return nil, ErrInvalidParams("bad pin")
+	})
+	conn, stream := s.newReplayConn(core)
+
+	stream.enqueueRaw(`{"jsonrpc":"2.0","id":1,"method":"join"}`)
+	stream.enqueueRaw(`[{"jsonrpc":"2.0","id":1,"method":"join"},{"jsonrpc":"2.0","id":2,"method":"join"}]`)
+	conn.readLoop(context.Background())
+
+	s.Equal(2, joins)
+	s.Require().Len(stream.writes, 1)
+	s.Require().Len(stream.batches, 1)
+	s.Require().Len(stream.batches[0], 2)
+	// errors are replayed too
+	s.EqualValues(CodeInvalidParams, stream.batches[0][0].Error.Code)
+	s.Equal("1", stream.batches[0][0].ID.String())
+}
+
+func TestReplayCache(t *testing.T) {
+	now := time.Now()
+	cache := newReplayCache(&ReplayConfig{Window: time.Minute, Size: 2})
+	cache.now = func() time.Time { return now }
+	resp := func(id ID) *message { return &message{ID: &id} }
+	id := func(n uint64) ID { return ID{Num: n} }
+
+	_, seen := cache.begin(id(1))
+	assert.False(t, seen)
+	cache.finish(id(1), resp(id(1)))
+	r, seen := cache.begin(id(1))
+	assert.True(t, seen)
+	assert.Equal(t, id(1), *r.ID)
+
+	// expired past the window
+	now = now.Add(time.Minute)
+	_, seen = cache.begin(id(1))
+	assert.False(t, seen)
+
+	// in progress requests do not expire
+	now = now.Add(time.Hour)
+	r, seen = cache.begin(id(1))
+	assert.True(t, seen)
+	assert.Nil(t, r)
+
+	// the oldest are evicted past the size
+	cache.begin(id(2))
+	cache.begin(id(3))
+	_, seen = cache.begin(id(1))
+	assert.False(t, seen)
+	_, seen = cache.begin(id(3))
+	assert.True(t, seen)
+}
+
 type stubStream struct {
 	writes    []*message
+	batches   [][]*message
 	writeErr  error
 	readErr   error
 	closed    bool
-	readQueue []*message
+	readQueue []json.RawMessage
+	readBlock chan struct{} // delays EOF once the queue is drained
 	writeHook func(*message)
+	mu        sync.Mutex
 }
 
 func newStubStream() *stubStream {
@@ -393,7 +701,15 @@ func newStubStream() *stubStream {
 }
 
 func (s *stubStream) enqueueRead(msg *message) {
-	s.readQueue = append(s.readQueue, msg)
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	s.readQueue = append(s.readQueue, raw)
+}
+
+func (s *stubStream) enqueueRaw(raw string) {
+	s.readQueue = append(s.readQueue, json.RawMessage(raw))
 }
 
 func (s *stubStream) Open(context.Context) error {
@@ -405,12 +721,15 @@ func (s *stubStream) Read(_ context.Context, dst any) error {
 		return s.readErr
 	}
 	if len(s.readQueue) == 0 {
+		if s.readBlock != nil {
+			<-s.readBlock
+		}
 		return io.EOF
 	}
-	msg := s.readQueue[0]
+	raw := s.readQueue[0]
 	s.readQueue = s.readQueue[1:]
-	out := dst.(*message)
-	*out = *msg
+	out := dst.(*json.RawMessage)
+	*out = raw
 	return nil
 }
 
@@ -418,6 +737,12 @@ func (s *stubStream) Write(_ context.Context, obj any) error {
 	if s.writeErr != nil {
 		return s.writeErr
 	}
+	if batch, ok := obj.([]*message); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.batches = append(s.batches, batch)
+		return nil
+	}
 	msg := obj.(*message)
 	s.writes = append(s.writes, msg)
 	if s.writeHook != nil {
@@ -0,0 +1,344 @@
+// Package longpoll serves JSON-RPC over HTTP long polling, a fallback for clients behind
+// proxies blocking WebSockets. Sessions share the method handlers and connection hooks of the
+// WebSocket server, so methods see the same connection context whatever the transport.
+//
+// A client opens a session with POST {path}, verified by the hooks like a WebSocket upgrade, and
+// gets {"sessionId": "..."}. It posts JSON-RPC messages or batches with POST {path}/{id}, polls
+// what the server sends with GET {path}/{id}, a JSON array empty when the poll timed out, and
+// closes with DELETE {path}/{id}. Polls of a closed session return what was queued before, then
+// 410 Gone.
+package longpoll
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc/websocket"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const (
+	maxFrameBytes = 1 << 20
+	// disconnectCode is passed to OnDisconnect, the abnormal closure code of WebSockets
+	disconnectCode = 1006
+)
+
+// Config configures the long-poll transport
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	// PollTimeout is how long a poll waits for messages, keep it below proxy idle timeouts
+	PollTimeout time.Duration `mapstructure:"poll_timeout"`
+	// IdleTimeout closes sessions neither polled nor posted to for this long
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+	// MaxQueued bounds the messages waiting for a poll, the session is closed beyond
+	MaxQueued int `mapstructure:"max_queued"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), false)
+	v.SetDefault(p("poll_timeout"), 25*time.Second)
+	v.SetDefault(p("idle_timeout"), time.Minute)
+	v.SetDefault(p("max_queued"), 256)
+}
+
+// Server manages the long-poll sessions
+type Server[T any] struct {
+	handler        jsonrpc.Handler[T]
+	hooks          websocket.ConnectionHooks[T]
+	allowedOrigins []string
+	cfg            Config
+
+	mu       sync.Mutex
+	sessions map[string]*session
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	logger *log.Logger
+}
+
+// NewServer creates a server handling sessions with the method handlers of handler, origins
+// are allowed as by the WebSocket server
+func NewServer[T any](
+	handler jsonrpc.Handler[T],
+	hooks websocket.ConnectionHooks[T],
+	allowedOrigins []string,
+	cfg *Config,
+	logger *log.Logger,
+) *Server[T] {
+	return &Server[T]{
+		handler:        handler,
+		hooks:          hooks,
+		allowedOrigins: allowedOrigins,
+		cfg:            *cfg,
+		sessions:       make(map[string]*session),
+		done:           make(chan struct{}),
+		logger:         logger,
+	}
+}
+
+// Register adds the routes of the server under path to mux
+func (s *Server[T]) Register(mux *http.ServeMux, path string) {
+	mux.HandleFunc(path, s.withOrigin(s.handleOpen, http.MethodPost))
+	mux.HandleFunc(path+"/{id}", s.withOrigin(s.handleSession, http.MethodGet, http.MethodPost, http.MethodDelete))
+}
+
+// Start runs the cleanup of idle sessions, sessions live until Stop
+func (s *Server[T]) Start(ctx context.Context) error {
+	s.ctx, s.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	go s.cleanupLoop()
+	return nil
+}
+
+// Stop closes the sessions
+func (s *Server[T]) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+
+	s.mu.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+	for _, sess := range sessions {
+		sess.close()
+	}
+}
+
+func (s *Server[T]) handleOpen(w http.ResponseWriter, r *http.Request) {
+	if s.ctx == nil {
+		http.Error(w, "not started", http.StatusServiceUnavailable)
+		return
+	}
+	id, err := newSessionID()
+	if err != nil {
+		s.logger.Error("Failed to generate session ID", log.Error(err))
+		http.Error(w, "fail to open", http.StatusInternalServerError)
+		return
+	}
+	sess := newSession(s.ctx, id, s.cfg.MaxQueued)
+
+	// the request context of hooks lives as long as the session, as for a WebSocket
+	initValue, passed, err := s.hooks.OnVerify(r.WithContext(sess.ctx))
+	if err != nil {
+		sess.cancel()
+		s.logger.Warn("Connection verification error",
+			log.String("remote_addr", r.RemoteAddr),
+			log.Error(err))
+		http.Error(w, "fail to verify", http.StatusInternalServerError)
+		return
+	} else if !passed {
+		sess.cancel()
+		s.logger.Info("Connection verification failed",
+			log.String("remote_addr", r.RemoteAddr))
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rpcConn := s.handler.NewConn(sess, initValue)
+	sess.onClose = func() {
+		s.hooks.OnDisconnect(rpcConn.Context(), disconnectCode)
+	}
+	s.mu.Lock()
+	s.sessions[id] = sess
+	s.mu.Unlock()
+
+	s.logger.Info("Long-poll session opened",
+		log.String("session", id),
+		log.String("remote_addr", r.RemoteAddr),
+		log.String("user_agent", r.UserAgent()))
+
+	s.hooks.OnConnect(rpcConn.Context())
+	if err := rpcConn.Open(sess.ctx); err != nil {
+		s.logger.Error("Failed to open RPC connection",
+			log.String("remote_addr", r.RemoteAddr),
+			log.Error(err))
+		sess.close()
+		http.Error(w, "fail to open", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"sessionId": id})
+}
+
+func (s *Server[T]) handleSession(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	sess, ok := s.sessions[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "session not found", http.StatusGone)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.handlePoll(w, r, sess)
+	case http.MethodPost:
+		s.handlePost(w, r, sess)
+	case http.MethodDelete:
+		sess.close()
+		s.remove(sess)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server[T]) handlePoll(w http.ResponseWriter, r *http.Request, sess *session) {
+	msgs, err := sess.poll(r.Context(), s.cfg.PollTimeout)
+	switch {
+	case errors.Is(err, net.ErrClosed):
+		s.remove(sess)
+		http.Error(w, "session closed", http.StatusGone)
+		return
+	case err != nil:
+		// the client went away, queued messages are left for its next poll
+		return
+	}
+	if msgs == nil {
+		msgs = []json.RawMessage{}
+	}
+	writeJSON(w, http.StatusOK, msgs)
+}
+
+func (s *Server[T]) handlePost(w http.ResponseWriter, r *http.Request, sess *session) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxFrameBytes))
+	if err != nil {
+		http.Error(w, "fail to read", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !json.Valid(body) {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := sess.post(r.Context(), body); err != nil {
+		if errors.Is(err, net.ErrClosed) {
+			http.Error(w, "session closed", http.StatusGone)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server[T]) remove(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions[sess.id] == sess {
+		delete(s.sessions, sess.id)
+	}
+}
+
+// cleanupLoop closes sessions the client stopped polling, and drops closed sessions it never
+// polled again
+func (s *Server[T]) cleanupLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.IdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deadline := time.Now().Add(-s.cfg.IdleTimeout)
+		var idle []*session
+		s.mu.Lock()
+		for id, sess := range s.sessions {
+			if sess.idleSince(deadline) {
+				idle = append(idle, sess)
+				delete(s.sessions, id)
+			}
+		}
+		s.mu.Unlock()
+
+		for _, sess := range idle {
+			s.logger.Info("Closing idle long-poll session", log.String("session", sess.id))
+			sess.close()
+		}
+	}
+}
+
+// withOrigin checks the origin of browser requests against the allowed origins and answers
+// CORS preflights
+func (s *Server[T]) withOrigin(next http.HandlerFunc, methods ...string) http.HandlerFunc {
+	allowMethods := strings.Join(methods, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" {
+			if !s.originAllowed(r, origin) {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		for _, m := range methods {
+			if r.Method == m {
+				next(w, r)
+				return
+			}
+		}
+		w.Header().Set("Allow", allowMethods)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// originAllowed matches the host of the origin against the allowed patterns the way the
+// WebSocket server does, same host origins are always allowed
+func (s *Server[T]) originAllowed(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, pattern := range s.allowedOrigins {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(u.Host)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
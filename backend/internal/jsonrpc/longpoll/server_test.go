@@ -0,0 +1,220 @@
+package longpoll
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type testValue struct {
+	user string
+}
+
+type testHooks struct {
+	mu           sync.Mutex
+	conns        []jsonrpc.Conn[testValue]
+	disconnected int
+	reqCtx       context.Context
+}
+
+func (h *testHooks) OnVerify(r *http.Request) (*testValue, bool, error) {
+	user := r.URL.Query().Get("token")
+	if user == "" {
+		return nil, false, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.reqCtx = r.Context()
+	return &testValue{user: user}, true, nil
+}
+
+func (h *testHooks) OnConnect(mctx jsonrpc.MethodContext[testValue]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.conns = append(h.conns, mctx.Peer())
+}
+
+func (h *testHooks) OnDisconnect(jsonrpc.MethodContext[testValue], int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.disconnected++
+}
+
+func (h *testHooks) state() ([]jsonrpc.Conn[testValue], int, context.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.conns, h.disconnected, h.reqCtx
+}
+
+type ServerTestSuite struct {
+	suite.Suite
+	hooks  *testHooks
+	server *Server[testValue]
+	ts     *httptest.Server
+}
+
+func TestServerSuite(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
+
+func (s *ServerTestSuite) SetupTest() {
+	handler := jsonrpc.NewHandler[testValue](log.NewNop())
+	handler.Def("whoami", func(mctx jsonrpc.MethodContext[testValue], _ *json.RawMessage) (any, error) {
+		return mctx.Get().user, nil
+	})
+
+	s.hooks = &testHooks{}
+	s.server = NewServer[testValue](handler, s.hooks, []string{"app.example.com"}, &Config{
+		PollTimeout: 200 * time.Millisecond,
+		IdleTimeout: 400 * time.Millisecond,
+		MaxQueued:   4,
+	}, log.NewNop())
+	s.Require().NoError(s.server.Start(context.Background()))
+
+	mux := http.NewServeMux()
+	s.server.Register(mux, "/poll")
+	s.ts = httptest.NewServer(mux)
+}
+
+func (s *ServerTestSuite) TearDownTest() {
+	s.server.Stop()
+	s.ts.Close()
+}
+
+func (s *ServerTestSuite) do(method, url, body string, header http.Header) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, s.ts.URL+url, strings.NewReader(body))
+	s.Require().NoError(err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(resp.Body)
+	s.Require().NoError(err)
+	return resp, buf.Bytes()
+}
+
+func (s *ServerTestSuite) open(token string) string {
+	resp, body := s.do(http.MethodPost, "/poll?token="+token, "", nil)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	var result struct {
+		SessionID string `json:"sessionId"`
+	}
+	s.Require().NoError(json.Unmarshal(body, &result))
+	s.Require().NotEmpty(result.SessionID)
+	return result.SessionID
+}
+
+func (s *ServerTestSuite) poll(id string) []map[string]any {
+	resp, body := s.do(http.MethodGet, "/poll/"+id, "", nil)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	var msgs []map[string]any
+	s.Require().NoError(json.Unmarshal(body, &msgs))
+	return msgs
+}
+
+func (s *ServerTestSuite) TestOpenRejected() {
+	resp, _ := s.do(http.MethodPost, "/poll", "", nil)
+	s.Equal(http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = s.do(http.MethodGet, "/poll/unknown", "", nil)
+	s.Equal(http.StatusGone, resp.StatusCode)
+}
+
+func (s *ServerTestSuite) TestCallAndNotify() {
+	id := s.open("alice")
+
+	resp, _ := s.do(http.MethodPost, "/poll/"+id, `{"jsonrpc":"2.0","id":1,"method":"whoami"}`, nil)
+	s.Equal(http.StatusAccepted, resp.StatusCode)
+	msgs := s.poll(id)
+	s.Require().Len(msgs, 1)
+	s.Equal("alice", msgs[0]["result"])
+
+	// nothing queued, the poll times out empty
+	s.Empty(s.poll(id))
+
+	// notifications of the server are queued until polled
+	conns, _, reqCtx := s.hooks.state()
+	s.Require().Len(conns, 1)
+	s.Require().NoError(conns[0].Notify(context.Background(), "ping", map[string]int{"n": 1}))
+	s.Require().NoError(conns[0].Notify(context.Background(), "ping", map[string]int{"n": 2}))
+	msgs = s.poll(id)
+	s.Require().Len(msgs, 2)
+	s.Equal("ping", msgs[1]["method"])
+
+	// the request context of hooks lives until the session closes
+	s.NoError(reqCtx.Err())
+	resp, _ = s.do(http.MethodDelete, "/poll/"+id, "", nil)
+	s.Equal(http.StatusNoContent, resp.StatusCode)
+	s.Error(reqCtx.Err())
+	_, disconnected, _ := s.hooks.state()
+	s.Equal(1, disconnected)
+
+	resp, _ = s.do(http.MethodPost, "/poll/"+id, `{"jsonrpc":"2.0","id":2,"method":"whoami"}`, nil)
+	s.Equal(http.StatusGone, resp.StatusCode)
+}
+
+func (s *ServerTestSuite) TestServerCloseDrainsQueued() {
+	id := s.open("bob")
+	conns, _, _ := s.hooks.state()
+	s.Require().NoError(conns[0].Notify(context.Background(), "closing", nil))
+	s.Require().NoError(conns[0].Close())
+
+	msgs := s.poll(id)
+	s.Require().Len(msgs, 1)
+	s.Equal("closing", msgs[0]["method"])
+
+	resp, _ := s.do(http.MethodGet, "/poll/"+id, "", nil)
+	s.Equal(http.StatusGone, resp.StatusCode)
+}
+
+func (s *ServerTestSuite) TestQueueOverflowCloses() {
+	id := s.open("carol")
+	conns, _, _ := s.hooks.state()
+	for range 4 {
+		s.Require().NoError(conns[0].Notify(context.Background(), "ping", nil))
+	}
+	s.Error(conns[0].Notify(context.Background(), "ping", nil))
+
+	s.Len(s.poll(id), 4)
+	resp, _ := s.do(http.MethodGet, "/poll/"+id, "", nil)
+	s.Equal(http.StatusGone, resp.StatusCode)
+}
+
+func (s *ServerTestSuite) TestIdleSessionClosed() {
+	id := s.open("dave")
+
+	s.Eventually(func() bool {
+		_, disconnected, _ := s.hooks.state()
+		return disconnected == 1
+	}, 3*time.Second, 50*time.Millisecond)
+
+	resp, _ := s.do(http.MethodGet, "/poll/"+id, "", nil)
+	s.Equal(http.StatusGone, resp.StatusCode)
+}
+
+func (s *ServerTestSuite) TestOrigin() {
+	resp, _ := s.do(http.MethodPost, "/poll?token=eve", "", http.Header{"Origin": {"https://evil.example.com"}})
+	s.Equal(http.StatusForbidden, resp.StatusCode)
+
+	resp, _ = s.do(http.MethodOptions, "/poll", "", http.Header{"Origin": {"https://app.example.com"}})
+	s.Equal(http.StatusNoContent, resp.StatusCode)
+	s.Equal("https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	s.Contains(resp.Header.Get("Access-Control-Allow-Headers"), "Authorization")
+
+	resp, _ = s.do(http.MethodPut, "/poll", "", nil)
+	s.Equal(http.StatusMethodNotAllowed, resp.StatusCode)
+}
@@ -0,0 +1,182 @@
+package longpoll
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+)
+
+const ErrBufferFull errors.Code = "buffer_full"
+
+// inboundMessages bounds the frames posted by the client not read by the connection yet, posts
+// block meanwhile
+const inboundMessages = 16
+
+// session is the stream of a long-poll connection, frames posted by the client are read by the
+// connection and messages it writes are queued until the client polls them
+type session struct {
+	id        string
+	inbound   chan json.RawMessage
+	maxQueued int
+
+	mu       sync.Mutex
+	queued   []json.RawMessage
+	ready    chan struct{} // signaled when messages are queued or the session closes
+	polls    int           // polls in flight
+	lastSeen time.Time
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+	onClose   func()
+}
+
+func newSession(ctx context.Context, id string, maxQueued int) *session {
+	ctx, cancel := context.WithCancel(ctx)
+	return &session{
+		id:        id,
+		inbound:   make(chan json.RawMessage, inboundMessages),
+		maxQueued: maxQueued,
+		ready:     make(chan struct{}, 1),
+		lastSeen:  time.Now(),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+func (s *session) Open(context.Context) error {
+	return nil
+}
+
+func (s *session) Read(ctx context.Context, v any) error {
+	select {
+	case raw := <-s.inbound:
+		return json.Unmarshal(raw, v)
+	case <-s.ctx.Done():
+		return io.EOF
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Write queues a message for the next poll, the session is closed when the client does not
+// poll them fast enough
+func (s *session) Write(_ context.Context, obj any) error {
+	bs, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.closed() {
+		s.mu.Unlock()
+		return net.ErrClosed
+	}
+	if len(s.queued) >= s.maxQueued {
+		s.mu.Unlock()
+		s.close()
+		return ErrBufferFull
+	}
+	s.queued = append(s.queued, bs)
+	s.mu.Unlock()
+
+	s.signal()
+	return nil
+}
+
+func (s *session) Close() error {
+	s.close()
+	return nil
+}
+
+// close ends the session, messages queued before are still served to the next poll
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		s.signal()
+		if s.onClose != nil {
+			s.onClose()
+		}
+	})
+}
+
+func (s *session) closed() bool {
+	return s.ctx.Err() != nil
+}
+
+func (s *session) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// post passes a frame of the client to the connection
+func (s *session) post(ctx context.Context, raw json.RawMessage) error {
+	s.touch()
+	select {
+	case s.inbound <- raw:
+		return nil
+	case <-s.ctx.Done():
+		return net.ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// poll waits up to timeout for queued messages and takes them, net.ErrClosed once the session
+// is closed and drained
+func (s *session) poll(ctx context.Context, timeout time.Duration) ([]json.RawMessage, error) {
+	s.mu.Lock()
+	s.polls++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.polls--
+		s.lastSeen = time.Now()
+		s.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		msgs := s.queued
+		s.queued = nil
+		closed := s.closed()
+		s.mu.Unlock()
+
+		switch {
+		case len(msgs) > 0:
+			return msgs, nil
+		case closed:
+			return nil, net.ErrClosed
+		}
+
+		select {
+		case <-s.ready:
+		case <-timer.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *session) touch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastSeen = time.Now()
+}
+
+// idleSince reports whether the client neither polled nor posted since t
+func (s *session) idleSince(t time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.polls == 0 && s.lastSeen.Before(t)
+}
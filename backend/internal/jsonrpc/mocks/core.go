@@ -1,9 +1,9 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/imtaco/audio-rtc-exp/internal/jsonrpc (interfaces: Core)
+// Source: github.com/imtaco/audio-rtc-exp/internal/jsonrpc (interfaces: Handler)
 //
 // Generated by this command:
 //
-//	mockgen -destination=pkg/jsonrpc/mocks/core.go -package=mocks github.com/imtaco/audio-rtc-exp/internal/jsonrpc Core
+//	mockgen -destination=mocks/core.go -package=mocks -mock_names=Handler=MockCore github.com/imtaco/audio-rtc-exp/internal/jsonrpc Handler
 //
 
 // Package mocks is a generated GoMock package.
@@ -12,12 +12,11 @@ package mocks
 import (
 	reflect "reflect"
 
-	gomock "go.uber.org/mock/gomock"
-
 	jsonrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	gomock "go.uber.org/mock/gomock"
 )
 
-// MockCore is a mock of Core interface.
+// MockCore is a mock of Handler interface.
 type MockCore[T any] struct {
 	ctrl     *gomock.Controller
 	recorder *MockCoreMockRecorder[T]
@@ -65,6 +64,35 @@ func (mr *MockCoreMockRecorder[T]) DefAsync(method, handler any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DefAsync", reflect.TypeOf((*MockCore[T])(nil).DefAsync), method, handler)
 }
 
+// DefLocal mocks base method.
+func (m *MockCore[T]) DefLocal(method string, handler jsonrpc.MethodHandler[T]) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "DefLocal", method, handler)
+}
+
+// DefLocal indicates an expected call of DefLocal.
+func (mr *MockCoreMockRecorder[T]) DefLocal(method, handler any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DefLocal", reflect.TypeOf((*MockCore[T])(nil).DefLocal), method, handler)
+}
+
+// EnableReplay mocks base method.
+func (m *MockCore[T]) EnableReplay(cfg *jsonrpc.ReplayConfig, requireID ...string) {
+	m.ctrl.T.Helper()
+	varargs := []any{cfg}
+	for _, a := range requireID {
+		varargs = append(varargs, a)
+	}
+	m.ctrl.Call(m, "EnableReplay", varargs...)
+}
+
+// EnableReplay indicates an expected call of EnableReplay.
+func (mr *MockCoreMockRecorder[T]) EnableReplay(cfg any, requireID ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{cfg}, requireID...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableReplay", reflect.TypeOf((*MockCore[T])(nil).EnableReplay), varargs...)
+}
+
 // NewConn mocks base method.
 func (m *MockCore[T]) NewConn(stream jsonrpc.ObjectStream, v *T) jsonrpc.Conn[T] {
 	m.ctrl.T.Helper()
@@ -78,3 +106,19 @@ func (mr *MockCoreMockRecorder[T]) NewConn(stream, v any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewConn", reflect.TypeOf((*MockCore[T])(nil).NewConn), stream, v)
 }
+
+// Use mocks base method.
+func (m *MockCore[T]) Use(interceptors ...jsonrpc.Interceptor[T]) {
+	m.ctrl.T.Helper()
+	varargs := []any{}
+	for _, a := range interceptors {
+		varargs = append(varargs, a)
+	}
+	m.ctrl.Call(m, "Use", varargs...)
+}
+
+// Use indicates an expected call of Use.
+func (mr *MockCoreMockRecorder[T]) Use(interceptors ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Use", reflect.TypeOf((*MockCore[T])(nil).Use), interceptors...)
+}
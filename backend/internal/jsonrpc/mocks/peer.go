@@ -1,9 +1,9 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/imtaco/audio-rtc-exp/common/jsonrpc/redis (interfaces: Peer)
+// Source: github.com/imtaco/audio-rtc-exp/internal/jsonrpc (interfaces: Peer)
 //
 // Generated by this command:
 //
-//	mockgen -destination=mocks/peer.go -package=mocks github.com/imtaco/audio-rtc-exp/common/jsonrpc Peer
+//	mockgen -destination=mocks/peer.go -package=mocks github.com/imtaco/audio-rtc-exp/internal/jsonrpc Peer
 //
 
 // Package mocks is a generated GoMock package.
@@ -13,9 +13,8 @@ import (
 	context "context"
 	reflect "reflect"
 
-	gomock "go.uber.org/mock/gomock"
-
 	jsonrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	gomock "go.uber.org/mock/gomock"
 )
 
 // MockPeer is a mock of Peer interface.
@@ -108,6 +107,20 @@ func (mr *MockPeerMockRecorder[T]) DefAsync(method, handler any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DefAsync", reflect.TypeOf((*MockPeer[T])(nil).DefAsync), method, handler)
 }
 
+// Dispatch mocks base method.
+func (m *MockPeer[T]) Dispatch(ctx context.Context, method string, params any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Dispatch", ctx, method, params)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Dispatch indicates an expected call of Dispatch.
+func (mr *MockPeerMockRecorder[T]) Dispatch(ctx, method, params any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dispatch", reflect.TypeOf((*MockPeer[T])(nil).Dispatch), ctx, method, params)
+}
+
 // Notify mocks base method.
 func (m *MockPeer[T]) Notify(ctx context.Context, method string, params any) error {
 	m.ctrl.T.Helper()
@@ -135,3 +148,19 @@ func (mr *MockPeerMockRecorder[T]) Open(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Open", reflect.TypeOf((*MockPeer[T])(nil).Open), ctx)
 }
+
+// Use mocks base method.
+func (m *MockPeer[T]) Use(interceptors ...jsonrpc.Interceptor[T]) {
+	m.ctrl.T.Helper()
+	varargs := []any{}
+	for _, a := range interceptors {
+		varargs = append(varargs, a)
+	}
+	m.ctrl.Call(m, "Use", varargs...)
+}
+
+// Use indicates an expected call of Use.
+func (mr *MockPeerMockRecorder[T]) Use(interceptors ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Use", reflect.TypeOf((*MockPeer[T])(nil).Use), interceptors...)
+}
@@ -1,6 +1,7 @@
 package jsonrpc
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -25,6 +26,36 @@ type Request struct {
 	ID     *ID              `json:"id"`
 	Method string           `json:"method"`
 	Params *json.RawMessage `json:"params,omitempty"`
+	local  bool             // dispatched by the server, not read from the peer
+	batch  *batchReply      // collects the response when read in a batch
+}
+
+// frame is a message or a batch of messages read from the stream
+type frame struct {
+	msgs  []*message
+	batch bool
+}
+
+// decodeFrame decodes a single message or a JSON-RPC 2.0 batch, nil when empty
+func decodeFrame(raw json.RawMessage) (*frame, error) {
+	raw = bytes.TrimLeft(raw, " \t\r\n")
+	if len(raw) == 0 {
+		//nolint:nilnil
+		return nil, nil
+	}
+	if raw[0] != '[' {
+		var m message
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, err
+		}
+		return &frame{msgs: []*message{&m}}, nil
+	}
+
+	var msgs []*message
+	if err := json.Unmarshal(raw, &msgs); err != nil {
+		return nil, err
+	}
+	return &frame{msgs: msgs, batch: true}, nil
 }
 
 type message struct {
@@ -96,6 +127,15 @@ func newNotificationMessage(method string, params any) (*message, error) {
 	}, nil
 }
 
+// newErrorMessage is an error response not tied to a request, e.g. to a rejected batch
+func newErrorMessage(err *Error) *message {
+	return &message{
+		JSONRPC: jsonRPCVersion,
+		Error:   err,
+		msgType: typeResponse,
+	}
+}
+
 func newResponseMessage(id ID, result any, err *Error) (*message, error) {
 	var resultRaw *json.RawMessage
 	if err == nil {
@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+)
+
+// Notifier sends notifications about a key, e.g. a room, to a stream
+type Notifier interface {
+	Open(ctx context.Context) error
+	Close() error
+	Notify(ctx context.Context, key, method string, params any) error
+}
+
+type notifierImpl struct {
+	peers []jsonrpc.Peer[any]
+}
+
+// NewNotifier creates a notifier writing to stream, or to the partition stream of the key when
+// partitions > 0 so notifications of a key stay in order on a single partition. Notifications
+// are buffered when buffer is enabled, Close flushes them
+func NewNotifier(
+	redisClient *redis.Client,
+	stream string,
+	partitions int,
+	buffer *redisstream.BufferConfig,
+	logger *log.Logger,
+) (Notifier, error) {
+	streams := []string{stream}
+	if partitions > 0 {
+		streams = redisstream.PartitionStreams(stream, partitions)
+	}
+
+	n := &notifierImpl{}
+	for _, s := range streams {
+		peer, err := NewBufferedPeer[any](redisClient, s, "", "", buffer, logger)
+		if err != nil {
+			return nil, err
+		}
+		n.peers = append(n.peers, peer)
+	}
+	return n, nil
+}
+
+func (n *notifierImpl) Open(ctx context.Context) error {
+	for _, peer := range n.peers {
+		if err := peer.Open(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *notifierImpl) Close() error {
+	var errs []error
+	for _, peer := range n.peers {
+		errs = append(errs, peer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (n *notifierImpl) Notify(ctx context.Context, key, method string, params any) error {
+	peer := n.peers[0]
+	if len(n.peers) > 1 {
+		peer = n.peers[redisstream.PartitionOf(key, len(n.peers))]
+	}
+	return peer.Notify(ctx, method, params)
+}
@@ -0,0 +1,128 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+)
+
+func TestNotifier(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	ctx := context.Background()
+	logger := log.NewNop()
+
+	t.Run("single stream", func(t *testing.T) {
+		n, err := NewNotifier(client, "single", 0, nil, logger)
+		require.NoError(t, err)
+		require.NoError(t, n.Open(ctx))
+		defer n.Close()
+
+		require.NoError(t, n.Notify(ctx, "room1", "ping", map[string]string{"roomId": "room1"}))
+		require.NoError(t, n.Notify(ctx, "room2", "ping", map[string]string{"roomId": "room2"}))
+		assert.Equal(t, int64(2), client.XLen(ctx, "single").Val())
+	})
+
+	t.Run("partition of the key", func(t *testing.T) {
+		n, err := NewNotifier(client, "parts", 4, nil, logger)
+		require.NoError(t, err)
+		require.NoError(t, n.Open(ctx))
+		defer n.Close()
+
+		for _, roomID := range []string{"room1", "room1", "room2"} {
+			require.NoError(t, n.Notify(ctx, roomID, "ping", map[string]string{"roomId": roomID}))
+		}
+
+		var total int64
+		for _, stream := range redisstream.PartitionStreams("parts", 4) {
+			total += client.XLen(ctx, stream).Val()
+		}
+		assert.Equal(t, int64(3), total)
+		assert.Zero(t, client.Exists(ctx, "parts").Val(), "unpartitioned stream unused")
+
+		room1 := redisstream.PartitionStream("parts", redisstream.PartitionOf("room1", 4))
+		assert.GreaterOrEqual(t, client.XLen(ctx, room1).Val(), int64(2))
+	})
+}
+
+func TestGroupPeer_AcksReadEntries(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	ctx := context.Background()
+	logger := log.NewNop()
+
+	peer, err := NewGroupPeer[any](client, "", "grouped", "group", "consumer-1", logger)
+	require.NoError(t, err)
+
+	got := make(chan string, 1)
+	peer.Def("ping", func(_ jsonrpc.MethodContext[any], params *json.RawMessage) (any, error) {
+		var req struct {
+			RoomID string `json:"roomId"`
+		}
+		if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+			return nil, err
+		}
+		got <- req.RoomID
+		//nolint:nilnil
+		return nil, nil
+	})
+	require.NoError(t, peer.Open(ctx))
+	defer peer.Close()
+
+	n, err := NewNotifier(client, "grouped", 0, nil, logger)
+	require.NoError(t, err)
+	require.NoError(t, n.Notify(ctx, "room1", "ping", map[string]string{"roomId": "room1"}))
+
+	select {
+	case roomID := <-got:
+		assert.Equal(t, "room1", roomID)
+	case <-time.After(3 * time.Second):
+		t.Fatal("notification not handled")
+	}
+
+	assert.Eventually(t, func() bool {
+		pending, err := client.XPending(ctx, "grouped", "group").Result()
+		return err == nil && pending.Count == 0
+	}, 3*time.Second, 10*time.Millisecond)
+}
+
+func TestNotifier_Buffered(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	ctx := context.Background()
+
+	n, err := NewNotifier(client, "buffered", 0, &redisstream.BufferConfig{
+		Size:          10,
+		BatchSize:     100,
+		FlushInterval: time.Hour,
+	}, log.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, n.Open(ctx))
+
+	require.NoError(t, n.Notify(ctx, "room1", "ping", map[string]string{"roomId": "room1"}))
+	require.NoError(t, n.Notify(ctx, "room1", "ping", map[string]string{"roomId": "room1"}))
+	assert.Zero(t, client.XLen(ctx, "buffered").Val(), "waiting for the batch")
+
+	require.NoError(t, n.Close())
+	assert.Equal(t, int64(2), client.XLen(ctx, "buffered").Val(), "flushed on close")
+}
+
+func TestValidatePayload(t *testing.T) {
+	assert.NoError(t, validatePayload(map[string]any{"data": []byte(`{"jsonrpc":"2.0","method":"ping"}`)}))
+	assert.Error(t, validatePayload(map[string]any{"other": "x"}))
+	assert.Error(t, validatePayload(map[string]any{"data": "not json"}))
+	assert.Error(t, validatePayload(map[string]any{"data": `{"jsonrpc":"1.0"}`}))
+}
@@ -24,6 +24,7 @@ func NewPeer[T any](
 		streamOut,
 		streamIn,
 		consumerGroupName,
+		uuid.NewString(),
 		logger,
 	)
 	if err != nil {
@@ -33,6 +34,62 @@ func NewPeer[T any](
 	return jsonrpc.NewPeer(stream, new(T), logger), nil
 }
 
+// NewGroupPeer creates a peer reading streamIn as consumerName of consumerGroupName, entries are
+// acked once read so each is handled by a single consumer of the group
+func NewGroupPeer[T any](
+	redisClient *redis.Client,
+	streamOut string,
+	streamIn string,
+	consumerGroupName string,
+	consumerName string,
+	logger *log.Logger,
+) (jsonrpc.Peer[T], error) {
+	stream, err := newStream[T](
+		redisClient,
+		streamOut,
+		streamIn,
+		consumerGroupName,
+		consumerName,
+		logger,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc.NewPeer(stream, new(T), logger), nil
+}
+
+// NewBufferedPeer creates a peer like NewPeer whose writes are buffered and added in the
+// background, the buffer is flushed when the peer is closed. A disabled buffer writes directly
+func NewBufferedPeer[T any](
+	redisClient *redis.Client,
+	streamOut string,
+	streamIn string,
+	consumerGroupName string,
+	buffer *redisstream.BufferConfig,
+	logger *log.Logger,
+) (jsonrpc.Peer[T], error) {
+	if !buffer.Enabled() || streamOut == "" {
+		return NewPeer[T](redisClient, streamOut, streamIn, consumerGroupName, logger)
+	}
+
+	stream, err := newStream[T](
+		redisClient,
+		"",
+		streamIn,
+		consumerGroupName,
+		uuid.NewString(),
+		logger,
+	)
+	if err != nil {
+		return nil, err
+	}
+	stream.producer, err = redisstream.NewBufferedProducer(redisClient, streamOut, buffer, validatePayload, logger)
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc.NewPeer(stream, new(T), logger), nil
+}
+
 func NewConn[T any](
 	handler jsonrpc.Handler[T],
 	redisClient *redis.Client,
@@ -46,6 +103,7 @@ func NewConn[T any](
 		streamOut,
 		streamIn,
 		consumerGroupName,
+		uuid.NewString(),
 		logger,
 	)
 	if err != nil {
@@ -60,8 +118,9 @@ func newStream[T any](
 	streamOut string,
 	streamIn string,
 	consumerGroupName string,
+	consumerName string,
 	logger *log.Logger,
-) (jsonrpc.ObjectStream, error) {
+) (*rsStream, error) {
 	if logger == nil {
 		panic("logger cannot be nil")
 	}
@@ -82,12 +141,11 @@ func newStream[T any](
 		}
 	}
 	if streamIn != "" {
-		consumerID := uuid.NewString()
 		consumer, err = redisstream.NewConsumer(
 			redisClient,
 			streamIn,
 			consumerGroupName,
-			consumerID,
+			consumerName,
 			time.Second, // default block time
 			logger,
 		)
@@ -50,6 +50,10 @@ func (rs *rsStream) Read(ctx context.Context, v any) error {
 			return jsonrpc.ErrClosed
 		}
 		value = msg.Values
+		// no-op without a consumer group, entries are not redelivered once read
+		if err := msg.Ack(); err != nil {
+			rs.logger.Warn("failed to ack redis stream entry", log.String("id", msg.ID), log.Error(err))
+		}
 	}
 
 	raw, ok := extractDataField(value)
@@ -75,6 +79,11 @@ func (rs *rsStream) Disconnected() <-chan struct{} {
 }
 
 func (rs *rsStream) Open(ctx context.Context) error {
+	if bp, ok := rs.producer.(redisstream.BufferedProducer); ok {
+		if err := bp.Open(ctx); err != nil {
+			return err
+		}
+	}
 	if rs.consumer == nil {
 		return nil
 	}
@@ -82,10 +91,32 @@ func (rs *rsStream) Open(ctx context.Context) error {
 }
 
 func (rs *rsStream) Close() error {
-	if rs.consumer == nil {
-		return nil
+	if rs.consumer != nil {
+		rs.consumer.Close()
+	}
+	// flush writes buffered before closing
+	if bp, ok := rs.producer.(redisstream.BufferedProducer); ok {
+		return bp.Close()
+	}
+	return nil
+}
+
+// validatePayload checks an entry holds a JSON-RPC message before it is buffered, as a buffered
+// write fails long after the caller returned
+func validatePayload(values map[string]any) error {
+	raw, ok := extractDataField(values)
+	if !ok {
+		return errors.New("message missing data field")
+	}
+	var msg struct {
+		Version string `json:"jsonrpc"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return errors.Wrap(err, "invalid message")
+	}
+	if msg.Version != "2.0" {
+		return errors.Errorf("invalid message version %q", msg.Version)
 	}
-	rs.consumer.Close()
 	return nil
 }
 
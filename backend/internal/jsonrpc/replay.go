@@ -0,0 +1,96 @@
+package jsonrpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ReplayConfig configures deduplication of requests by ID per connection, a client resending
+// a request, e.g. on a flaky mobile network, gets the response of the first one replayed
+// instead of running the method twice
+type ReplayConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Window is how long the response of a request is kept for replay
+	Window time.Duration `mapstructure:"window"`
+	// Size bounds the responses kept per connection, the oldest are dropped first
+	Size int `mapstructure:"size"`
+}
+
+func SetupReplay(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), true)
+	v.SetDefault(p("window"), 30*time.Second)
+	v.SetDefault(p("size"), 64)
+}
+
+// replayEntry is a request seen on the connection, resp is nil while it is handled
+type replayEntry struct {
+	resp *message
+	at   time.Time
+}
+
+// replayCache keeps the responses to the recent requests of a connection by request ID
+type replayCache struct {
+	window  time.Duration
+	size    int
+	mu      sync.Mutex
+	entries map[ID]*replayEntry
+	order   []ID // in the order requests were seen
+	now     func() time.Time
+}
+
+func newReplayCache(cfg *ReplayConfig) *replayCache {
+	return &replayCache{
+		window:  cfg.Window,
+		size:    max(cfg.Size, 1),
+		entries: make(map[ID]*replayEntry),
+		now:     time.Now,
+	}
+}
+
+// begin records a request, seen reports whether its ID was seen within the window, with the
+// response to replay or nil while the first request is still handled
+func (r *replayCache) begin(id ID) (resp *message, seen bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.expire(now)
+	if e, ok := r.entries[id]; ok {
+		return e.resp, true
+	}
+
+	r.entries[id] = &replayEntry{at: now}
+	r.order = append(r.order, id)
+	for len(r.order) > r.size {
+		delete(r.entries, r.order[0])
+		r.order = r.order[1:]
+	}
+	return nil, false
+}
+
+// finish keeps the response to the request for replay, replayed responses keep their time
+func (r *replayCache) finish(id ID, resp *message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, ok := r.entries[id]; ok && e.resp == nil {
+		e.resp = resp
+		e.at = r.now()
+	}
+}
+
+// expire drops the oldest responses past the window, requests still handled are kept
+func (r *replayCache) expire(now time.Time) {
+	for len(r.order) > 0 {
+		e := r.entries[r.order[0]]
+		if e != nil && (e.resp == nil || now.Sub(e.at) < r.window) {
+			return
+		}
+		delete(r.entries, r.order[0])
+		r.order = r.order[1:]
+	}
+}
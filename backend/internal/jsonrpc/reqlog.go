@@ -0,0 +1,170 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const redacted = "[redacted]"
+
+// sensitiveKeyParts redact matching keys regardless of configuration, credentials such as
+// pin, jtoken or accessToken never reach the log
+var sensitiveKeyParts = []string{"token", "secret", "password", "authorization"}
+
+func isSensitiveKey(key string) bool {
+	if key == "pin" {
+		return true
+	}
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestLogConfig configures the structured request log, failed requests are always
+// logged and successful ones are sampled
+type RequestLogConfig struct {
+	Enabled    bool    `mapstructure:"enabled"`
+	SampleRate float64 `mapstructure:"sample_rate"`
+	Params     bool    `mapstructure:"params"`
+	Result     bool    `mapstructure:"result"`
+	// Redact lists keys redacted on top of pin and token-like keys
+	Redact []string `mapstructure:"redact"`
+}
+
+func SetupRequestLog(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), false)
+	v.SetDefault(p("sample_rate"), 0.01)
+	v.SetDefault(p("params"), false)
+	v.SetDefault(p("result"), false)
+	v.SetDefault(p("redact"), []string{"sdp"})
+}
+
+// RequestLogger wraps method handlers to log method, latency and the result or error code of
+// each request, along with the fields of the connection context
+type RequestLogger[T any] struct {
+	cfg    *RequestLogConfig
+	redact map[string]struct{}
+	fields func(v *T) []log.Field
+	sample func() float64
+	logger *log.Logger
+}
+
+// NewRequestLogger returns nil when the request log is disabled, Wrap is a no-op on nil
+func NewRequestLogger[T any](
+	cfg *RequestLogConfig,
+	fields func(v *T) []log.Field,
+	logger *log.Logger,
+) *RequestLogger[T] {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	redact := make(map[string]struct{}, len(cfg.Redact))
+	for _, key := range cfg.Redact {
+		redact[strings.ToLower(key)] = struct{}{}
+	}
+	return &RequestLogger[T]{
+		cfg:    cfg,
+		redact: redact,
+		fields: fields,
+		sample: rand.Float64,
+		logger: logger,
+	}
+}
+
+// Wrap returns the handler logging each call of method
+func (l *RequestLogger[T]) Wrap(method string, handler MethodHandler[T]) MethodHandler[T] {
+	if l == nil {
+		return handler
+	}
+	return func(mctx MethodContext[T], params *json.RawMessage) (any, error) {
+		start := time.Now()
+		result, err := handler(mctx, params)
+		latency := time.Since(start)
+
+		if err == nil && l.sample() >= l.cfg.SampleRate {
+			return result, err
+		}
+
+		// fields are read after the handler, so e.g. roomId set by join is included
+		fields := []log.Field{
+			log.String("method", method),
+			log.Duration("latency", latency),
+		}
+		if l.fields != nil {
+			fields = append(fields, l.fields(mctx.Get())...)
+		}
+		if l.cfg.Params && params != nil {
+			fields = append(fields, log.String("params", l.redactParams(*params)))
+		}
+
+		if err == nil {
+			if l.cfg.Result && result != nil {
+				fields = append(fields, log.String("result", l.redactResult(result)))
+			}
+			l.logger.Info("RPC request", fields...)
+			return result, err
+		}
+
+		code := int64(CodeInternalError)
+		if rpcErr, ok := errors.As[*Error](err); ok {
+			code = rpcErr.Code
+		}
+		fields = append(fields, log.Int64("error_code", code), log.Error(err))
+		l.logger.Warn("RPC request failed", fields...)
+		return result, err
+	}
+}
+
+// redactResult encodes the handler result with the same redaction as params
+func (l *RequestLogger[T]) redactResult(result any) string {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return redacted
+	}
+	return l.redactParams(data)
+}
+
+// redactParams replaces the values of redacted and sensitive keys at any depth, e.g. SDP bodies
+func (l *RequestLogger[T]) redactParams(params json.RawMessage) string {
+	var v any
+	if err := json.Unmarshal(params, &v); err != nil {
+		// do not risk logging a body that cannot be redacted
+		return redacted
+	}
+	data, err := json.Marshal(l.redactValue(v))
+	if err != nil {
+		return redacted
+	}
+	return string(data)
+}
+
+func (l *RequestLogger[T]) redactValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for key, item := range val {
+			lower := strings.ToLower(key)
+			if _, ok := l.redact[lower]; ok || isSensitiveKey(lower) {
+				val[key] = redacted
+				continue
+			}
+			val[key] = l.redactValue(item)
+		}
+	case []any:
+		for i, item := range val {
+			val[i] = l.redactValue(item)
+		}
+	}
+	return v
+}
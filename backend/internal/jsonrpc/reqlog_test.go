@@ -0,0 +1,205 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type reqLogCtx struct {
+	roomID string
+}
+
+type RequestLoggerSuite struct {
+	suite.Suite
+	logs *observer.ObservedLogs
+	cfg  *RequestLogConfig
+	mctx MethodContext[reqLogCtx]
+}
+
+func TestRequestLoggerSuite(t *testing.T) {
+	suite.Run(t, new(RequestLoggerSuite))
+}
+
+func (s *RequestLoggerSuite) SetupTest() {
+	s.cfg = &RequestLogConfig{
+		Enabled:    true,
+		SampleRate: 1,
+		Params:     true,
+		Redact:     []string{"sdp"},
+	}
+	s.mctx = NewContext[reqLogCtx](nil, &reqLogCtx{})
+}
+
+func (s *RequestLoggerSuite) newLogger(sample float64) *RequestLogger[reqLogCtx] {
+	core, logs := observer.New(zapcore.InfoLevel)
+	s.logs = logs
+	l := NewRequestLogger(s.cfg, func(v *reqLogCtx) []log.Field {
+		return []log.Field{log.String("roomId", v.roomID)}
+	}, &log.Logger{Logger: zap.New(core)})
+	l.sample = func() float64 { return sample }
+	return l
+}
+
+func (s *RequestLoggerSuite) TestDisabled() {
+	s.cfg.Enabled = false
+	l := NewRequestLogger[reqLogCtx](s.cfg, nil, log.NewNop())
+	s.Nil(l)
+
+	handler := func(MethodContext[reqLogCtx], *json.RawMessage) (any, error) { return "ok", nil }
+	result, err := l.Wrap("join", handler)(s.mctx, nil)
+	s.Require().NoError(err)
+	s.Equal("ok", result)
+	s.Nil(l.Interceptor())
+}
+
+func (s *RequestLoggerSuite) TestInterceptorSkipsLocal() {
+	l := s.newLogger(0)
+	handler := func(MethodContext[reqLogCtx], *json.RawMessage) (any, error) { return "ok", nil }
+
+	_, err := l.Interceptor()(s.mctx, &RequestInfo{Method: "room.ending", Local: true}, nil, handler)
+	s.Require().NoError(err)
+	s.Equal(0, s.logs.Len())
+
+	_, err = l.Interceptor()(s.mctx, &RequestInfo{Method: "join"}, nil, handler)
+	s.Require().NoError(err)
+	s.Require().Equal(1, s.logs.Len())
+	s.Equal("join", s.logs.All()[0].ContextMap()["method"])
+}
+
+func (s *RequestLoggerSuite) TestLogsContextAfterHandler() {
+	l := s.newLogger(0)
+	handler := l.Wrap("join", func(mctx MethodContext[reqLogCtx], _ *json.RawMessage) (any, error) {
+		mctx.Get().roomID = "room-1"
+		return "ok", nil
+	})
+
+	result, err := handler(s.mctx, nil)
+	s.Require().NoError(err)
+	s.Equal("ok", result)
+
+	entries := s.logs.All()
+	s.Require().Len(entries, 1)
+	fields := entries[0].ContextMap()
+	s.Equal("join", fields["method"])
+	s.Equal("room-1", fields["roomId"])
+	s.Contains(fields, "latency")
+	s.NotContains(fields, "error_code")
+}
+
+func (s *RequestLoggerSuite) TestSamplesSuccess() {
+	s.cfg.SampleRate = 0.1
+	l := s.newLogger(0.5)
+	handler := l.Wrap("keepalive", func(MethodContext[reqLogCtx], *json.RawMessage) (any, error) {
+		return nil, nil
+	})
+
+	_, err := handler(s.mctx, nil)
+	s.Require().NoError(err)
+	s.Empty(s.logs.All())
+}
+
+func (s *RequestLoggerSuite) TestAlwaysLogsErrors() {
+	s.cfg.SampleRate = 0
+	l := s.newLogger(0.5)
+
+	_, err := l.Wrap("offer", func(MethodContext[reqLogCtx], *json.RawMessage) (any, error) {
+		return nil, ErrInvalidParams("bad sdp")
+	})(s.mctx, nil)
+	s.Require().Error(err)
+
+	_, err = l.Wrap("offer", func(MethodContext[reqLogCtx], *json.RawMessage) (any, error) {
+		return nil, errors.New("janus down")
+	})(s.mctx, nil)
+	s.Require().Error(err)
+
+	entries := s.logs.All()
+	s.Require().Len(entries, 2)
+	s.Equal(zapcore.WarnLevel, entries[0].Level)
+	s.Equal(int64(CodeInvalidParams), entries[0].ContextMap()["error_code"])
+	s.Equal(int64(CodeInternalError), entries[1].ContextMap()["error_code"])
+}
+
+func (s *RequestLoggerSuite) TestRedactsParams() {
+	l := s.newLogger(0)
+	handler := l.Wrap("offer", func(MethodContext[reqLogCtx], *json.RawMessage) (any, error) {
+		return nil, nil
+	})
+
+	params := json.RawMessage(`{"sdp":{"type":"offer","SDP":"v=0..."},"list":[{"sdp":"v=0"}],"room":"r1"}`)
+	_, err := handler(s.mctx, &params)
+	s.Require().NoError(err)
+
+	entries := s.logs.All()
+	s.Require().Len(entries, 1)
+	s.JSONEq(
+		`{"sdp":"[redacted]","list":[{"sdp":"[redacted]"}],"room":"r1"}`,
+		entries[0].ContextMap()["params"].(string),
+	)
+}
+
+func (s *RequestLoggerSuite) TestRedactsCredentialsByDefault() {
+	s.cfg.Redact = nil
+	l := s.newLogger(0)
+	handler := l.Wrap("join", func(MethodContext[reqLogCtx], *json.RawMessage) (any, error) {
+		return nil, nil
+	})
+
+	params := json.RawMessage(`{"pin":"1234","jtoken":"abc","auth":{"accessToken":"x","refresh_token":"y"},"clientId":"c1"}`)
+	_, err := handler(s.mctx, &params)
+	s.Require().NoError(err)
+
+	s.JSONEq(
+		`{"pin":"[redacted]","jtoken":"[redacted]","auth":{"accessToken":"[redacted]","refresh_token":"[redacted]"},"clientId":"c1"}`,
+		s.logs.All()[0].ContextMap()["params"].(string),
+	)
+}
+
+func (s *RequestLoggerSuite) TestLogsRedactedResult() {
+	s.cfg.Result = true
+	l := s.newLogger(0)
+	handler := l.Wrap("join", func(MethodContext[reqLogCtx], *json.RawMessage) (any, error) {
+		return map[string]any{"jtoken": "abc", "resume": true}, nil
+	})
+
+	_, err := handler(s.mctx, nil)
+	s.Require().NoError(err)
+
+	s.JSONEq(`{"jtoken":"[redacted]","resume":true}`, s.logs.All()[0].ContextMap()["result"].(string))
+}
+
+func (s *RequestLoggerSuite) TestParamsNotLoggedByDefault() {
+	s.cfg.Params = false
+	l := s.newLogger(0)
+	handler := l.Wrap("offer", func(MethodContext[reqLogCtx], *json.RawMessage) (any, error) {
+		return nil, nil
+	})
+
+	params := json.RawMessage(`{"sdp":"v=0"}`)
+	_, err := handler(s.mctx, &params)
+	s.Require().NoError(err)
+
+	entries := s.logs.All()
+	s.Require().Len(entries, 1)
+	s.NotContains(entries[0].ContextMap(), "params")
+}
+
+func (s *RequestLoggerSuite) TestInvalidParamsRedactedEntirely() {
+	l := s.newLogger(0)
+	handler := l.Wrap("offer", func(MethodContext[reqLogCtx], *json.RawMessage) (any, error) {
+		return nil, nil
+	})
+
+	params := json.RawMessage(`{"sdp":`)
+	_, err := handler(s.mctx, &params)
+	s.Require().NoError(err)
+
+	s.Equal(redacted, s.logs.All()[0].ContextMap()["params"])
+}
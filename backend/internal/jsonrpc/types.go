@@ -8,6 +8,12 @@ import (
 
 type Handler[T any] interface {
 	pureHandler[T]
+	// DefLocal registers a method only served for notifications dispatched with Conn.Dispatch,
+	// peers calling it get method not found
+	DefLocal(method string, handler MethodHandler[T])
+	// EnableReplay deduplicates requests by ID per connection, answering resent requests with
+	// the response to the first one; notifications of the methods in requireID are ignored
+	EnableReplay(cfg *ReplayConfig, requireID ...string)
 	// all connections created by this handler will share the same method handlers (Def & DefAsync)
 	NewConn(stream ObjectStream, v *T) Conn[T]
 }
@@ -28,11 +34,17 @@ type Conn[T any] interface {
 	Client[T]
 	Open(ctx context.Context) error
 	Context() MethodContext[T]
+	// Dispatch handles a notification with the connection's own method handlers, serialized
+	// with requests from the peer
+	Dispatch(ctx context.Context, method string, params any) error
 }
 
 type pureHandler[T any] interface {
 	Def(method string, handler MethodHandler[T])
 	DefAsync(method string, handler AsyncMethodHandler[T])
+	// Use appends interceptors run around every Def and DefLocal handler, the first one outermost.
+	// Call it before serving, it applies to methods defined before and after
+	Use(interceptors ...Interceptor[T])
 }
 
 // MethodHandler is a function that handles a JSON-RPC method
@@ -44,6 +56,23 @@ type AsyncMethodHandler[T any] func(mctx MethodContext[T], params *json.RawMessa
 
 type Reply func(result any, err error)
 
+// RequestInfo describes the request an interceptor runs for
+type RequestInfo struct {
+	Method string
+	ID     *ID  // nil for notifications
+	Local  bool // dispatched with Conn.Dispatch, not sent by the peer
+}
+
+// Interceptor runs around a method handler, e.g. for auth checks, rate limiting, metrics or
+// logging. It calls next to go on with the chain, or returns without calling it to reject the
+// request
+type Interceptor[T any] func(
+	mctx MethodContext[T],
+	info *RequestInfo,
+	params *json.RawMessage,
+	next MethodHandler[T],
+) (any, error)
+
 type ObjectStream interface {
 	Open(ctx context.Context) error
 	Read(ctx context.Context, v any) error
@@ -0,0 +1,24 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/coder/websocket"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// Dial connects to a JSON-RPC WebSocket server as a client, e.g. a synthetic anchor of the
+// gateway. Notifications of the server are served by the methods defined on the returned
+// peer, define them before Open
+func Dial[T any](ctx context.Context, url string, header http.Header, logger *log.Logger) (jsonrpc.Peer[T], error) {
+	//nolint:bodyclose // the response body is closed by the websocket library
+	wsConn, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{HTTPHeader: header})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial websocket: %w", err)
+	}
+	return jsonrpc.NewPeer[T](newStream(wsConn, logger), nil, logger), nil
+}
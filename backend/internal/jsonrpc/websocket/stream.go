@@ -106,6 +106,8 @@ func (ws *wsStream) close(err error) {
 		case err == nil:
 			ws.logger.Error("connect closed normally")
 			code = websocket.StatusNormalClosure
+			// e.g. a notification telling the client why it is closed
+			ws.flush()
 		case func() bool { closeErr, ok := errors.As[*websocket.CloseError](err); return ok && closeErr != nil }():
 			closeErr, _ := errors.As[websocket.CloseError](err)
 			ws.logger.Error("connect closed", log.Any("code", closeErr.Code))
@@ -130,6 +132,20 @@ func (ws *wsStream) close(err error) {
 	})
 }
 
+// flush writes the buffered messages, stops at the first failure
+func (ws *wsStream) flush() {
+	for {
+		select {
+		case action := <-ws.chBuf:
+			if err := action(); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
 func (ws *wsStream) wait() {
 	<-ws.connCtx.Done()
 }
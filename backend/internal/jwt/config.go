@@ -0,0 +1,33 @@
+package jwt
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// maxClockSkew bounds the configured leeway, a larger one would keep expired tokens usable
+const maxClockSkew = 5 * time.Minute
+
+// Config of token signing and validation, issuer and audience are enforced when set
+type Config struct {
+	Secret    string        `mapstructure:"secret"`
+	Issuer    string        `mapstructure:"issuer"`
+	Audience  string        `mapstructure:"audience"`
+	ExpiresIn time.Duration `mapstructure:"expires_in"`
+	ClockSkew time.Duration `mapstructure:"clock_skew"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("secret"), "MY-secret-key-change-in-production")
+	v.SetDefault(p("issuer"), "audio-rtc")
+	v.SetDefault(p("audience"), "audio-rtc")
+	v.SetDefault(p("expires_in"), "1h")
+	v.SetDefault(p("clock_skew"), "30s")
+}
+
+func (c *Config) clockSkew() time.Duration {
+	return min(max(c.ClockSkew, 0), maxClockSkew)
+}
@@ -1,57 +1,133 @@
 package jwt
 
 import (
+	"sync/atomic"
+	"time"
+
 	"github.com/golang-jwt/jwt/v5"
 
+	"github.com/imtaco/audio-rtc-exp/internal/config/secret"
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/errors"
 )
 
 // NewAuth creates a new JWT authenticator with HS256 algorithm (default)
-func NewAuth(secret string) Auth {
-	return NewAuthWithAlgorithm(secret, jwt.SigningMethodHS256)
+func NewAuth(cfg *Config) Auth {
+	return NewAuthWithAlgorithm(cfg, jwt.SigningMethodHS256)
+}
+
+// NewAuthWithSecret creates a JWT authenticator with HS256 algorithm signing with a resolved
+// secret, cfg.Secret is ignored. Once the secret rotates tokens are signed with the new one,
+// tokens signed with the previous one still verify until the next rotation
+func NewAuthWithSecret(cfg *Config, sec *secret.Secret) Auth {
+	j := newAuth(cfg, jwt.SigningMethodHS256, []byte(sec.Value()))
+	sec.OnRotate(func(value string) error {
+		j.keys.Store(&signingKeys{current: []byte(value), previous: j.keys.Load().current})
+		return nil
+	})
+	return j
 }
 
 // NewAuthWithAlgorithm creates a new JWT authenticator with specified algorithm
 // Supported algorithms: HS256, HS384, HS512
-func NewAuthWithAlgorithm(secret string, method jwt.SigningMethod) Auth {
+func NewAuthWithAlgorithm(cfg *Config, method jwt.SigningMethod) Auth {
+	return newAuth(cfg, method, []byte(cfg.Secret))
+}
+
+func newAuth(cfg *Config, method jwt.SigningMethod, key []byte) *jwtAuthImpl {
 	allowedMethods := map[string]bool{
 		method.Alg(): true,
 	}
-	return &jwtAuthImpl{
-		secret:         []byte(secret),
+
+	opts := []jwt.ParserOption{
+		jwt.WithLeeway(cfg.clockSkew()),
+		jwt.WithIssuedAt(),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	if cfg.ExpiresIn > 0 {
+		opts = append(opts, jwt.WithExpirationRequired())
+	}
+
+	j := &jwtAuthImpl{
+		cfg:            cfg,
 		signingMethod:  method,
 		allowedMethods: allowedMethods,
+		parser:         jwt.NewParser(opts...),
+		now:            time.Now,
 	}
+	j.keys.Store(&signingKeys{current: key})
+	return j
+}
+
+// signingKeys are the key tokens are signed with and the one before its rotation, if any
+type signingKeys struct {
+	current  []byte
+	previous []byte
 }
 
 type jwtAuthImpl struct {
-	secret         []byte
+	cfg            *Config
+	keys           atomic.Pointer[signingKeys]
 	signingMethod  jwt.SigningMethod
 	allowedMethods map[string]bool
+	parser         *jwt.Parser
+	now            func() time.Time
 }
 
 // Sign creates a JWT token for the given user and room
-func (j *jwtAuthImpl) Sign(userID, roomID string) (string, error) {
+func (j *jwtAuthImpl) Sign(userID, roomID string, role constants.UserRole) (string, error) {
+	return j.SignWithTTL(userID, roomID, role, j.cfg.ExpiresIn)
+}
+
+func (j *jwtAuthImpl) SignWithTTL(userID, roomID string, role constants.UserRole, ttl time.Duration) (string, error) {
+	return j.sign(userID, roomID, role, "", ttl)
+}
+
+func (j *jwtAuthImpl) SignWithLocale(userID, roomID string, role constants.UserRole, locale string) (string, error) {
+	return j.sign(userID, roomID, role, locale, j.cfg.ExpiresIn)
+}
+
+func (j *jwtAuthImpl) sign(userID, roomID string, role constants.UserRole, locale string, ttl time.Duration) (string, error) {
 	if userID == "" || roomID == "" {
 		return "", errors.New(ErrInvalidRequest, "userID and roomID are required")
 	}
 
+	now := j.now()
 	claims := &Payload{
 		UserID: userID,
 		RoomID: roomID,
+		Role:   role,
+		Locale: locale,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    j.cfg.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	if j.cfg.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{j.cfg.Audience}
+	}
+	if ttl > 0 {
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
 	}
 
 	token := jwt.NewWithClaims(j.signingMethod, claims)
-	return token.SignedString(j.secret)
+	return token.SignedString(j.keys.Load().current)
 }
 
-// Verify verifies a JWT token with strict algorithm validation
+// Verify verifies a JWT token with strict algorithm validation, then checks issuer, audience,
+// not-before and expiry allowing the configured clock skew
 func (j *jwtAuthImpl) Verify(tokenString string) (*Payload, error) {
 	if tokenString == "" {
 		return nil, ErrNoToken
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &Payload{}, func(token *jwt.Token) (any, error) {
+	token, err := j.parser.ParseWithClaims(tokenString, &Payload{}, func(token *jwt.Token) (any, error) {
 		// Strictly validate the algorithm matches what we expect
 		alg := token.Method.Alg()
 		if !j.allowedMethods[alg] {
@@ -61,11 +137,15 @@ func (j *jwtAuthImpl) Verify(tokenString string) (*Payload, error) {
 				alg, j.signingMethod.Alg(),
 			)
 		}
-		return j.secret, nil
+		keys := j.keys.Load()
+		if keys.previous == nil {
+			return keys.current, nil
+		}
+		return jwt.VerificationKeySet{Keys: []jwt.VerificationKey{keys.current, keys.previous}}, nil
 	})
 
 	if err != nil {
-		return nil, errors.Wrap(ErrInvalidToken, err, "missing required fields in token")
+		return nil, errors.Wrap(ErrInvalidToken, err, "failed to validate token")
 	}
 
 	if claims, ok := token.Claims.(*Payload); ok && token.Valid {
@@ -1,16 +1,25 @@
 package jwt
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/config/secret"
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
 )
 
 type JWTTestSuite struct {
 	suite.Suite
 	auth   Auth
+	cfg    *Config
 	secret string
 	userID string
 	roomID string
@@ -24,11 +33,41 @@ func (s *JWTTestSuite) SetupTest() {
 	s.secret = "test-secret"
 	s.userID = "user123"
 	s.roomID = "room456"
-	s.auth = NewAuth(s.secret)
+	s.cfg = &Config{
+		Secret:    s.secret,
+		Issuer:    "test-issuer",
+		Audience:  "test-audience",
+		ExpiresIn: time.Hour,
+		ClockSkew: 30 * time.Second,
+	}
+	s.auth = NewAuth(s.cfg)
+}
+
+// signClaims signs claims valid for the suite config unless overridden by tweak
+func (s *JWTTestSuite) signClaims(tweak func(*Payload)) string {
+	now := time.Now()
+	claims := &Payload{
+		UserID: s.userID,
+		RoomID: s.roomID,
+		Role:   constants.UserRoleAnchor,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.cfg.Issuer,
+			Audience:  jwt.ClaimStrings{s.cfg.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+	if tweak != nil {
+		tweak(claims)
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.secret))
+	s.Require().NoError(err)
+	return token
 }
 
 func (s *JWTTestSuite) TestNewAuth() {
-	auth := NewAuth(s.secret).(*jwtAuthImpl)
+	auth := NewAuth(s.cfg).(*jwtAuthImpl)
 	s.NotNil(auth)
 	s.Equal(jwt.SigningMethodHS256, auth.signingMethod)
 	s.True(auth.allowedMethods["HS256"])
@@ -47,7 +86,7 @@ func (s *JWTTestSuite) TestNewAuthWithAlgorithm() {
 
 	for _, tc := range testCases {
 		s.Run(tc.name, func() {
-			auth := NewAuthWithAlgorithm(s.secret, tc.method).(*jwtAuthImpl)
+			auth := NewAuthWithAlgorithm(s.cfg, tc.method).(*jwtAuthImpl)
 			s.NotNil(auth)
 			s.Equal(tc.method, auth.signingMethod)
 			s.True(auth.allowedMethods[tc.alg])
@@ -57,34 +96,44 @@ func (s *JWTTestSuite) TestNewAuthWithAlgorithm() {
 }
 
 func (s *JWTTestSuite) TestSign_Successful() {
-	token, err := s.auth.Sign(s.userID, s.roomID)
+	token, err := s.auth.Sign(s.userID, s.roomID, constants.UserRoleAnchor)
 	s.Require().NoError(err)
 	s.NotEmpty(token)
 	s.True(strings.HasPrefix(token, "eyJ"))
 }
 
+func (s *JWTTestSuite) TestSignWithTTL() {
+	token, err := s.auth.SignWithTTL(s.userID, s.roomID, constants.UserRoleGuest, 24*time.Hour)
+	s.Require().NoError(err)
+
+	payload, err := s.auth.Verify(token)
+	s.Require().NoError(err)
+	s.Equal(constants.UserRoleGuest, payload.Role)
+	s.WithinDuration(time.Now().Add(24*time.Hour), payload.ExpiresAt.Time, time.Minute)
+}
+
 func (s *JWTTestSuite) TestSign_EmptyUserID() {
-	token, err := s.auth.Sign("", s.roomID)
+	token, err := s.auth.Sign("", s.roomID, constants.UserRoleAnchor)
 	s.Require().ErrorIs(err, ErrInvalidRequest)
 	s.Empty(token)
 	s.Contains(err.Error(), "required")
 }
 
 func (s *JWTTestSuite) TestSign_EmptyRoomID() {
-	token, err := s.auth.Sign(s.userID, "")
+	token, err := s.auth.Sign(s.userID, "", constants.UserRoleAnchor)
 	s.Require().ErrorIs(err, ErrInvalidRequest)
 	s.Empty(token)
 	s.Contains(err.Error(), "required")
 }
 
 func (s *JWTTestSuite) TestSign_BothEmpty() {
-	token, err := s.auth.Sign("", "")
+	token, err := s.auth.Sign("", "", constants.UserRoleAnchor)
 	s.Require().ErrorIs(err, ErrInvalidRequest)
 	s.Empty(token)
 }
 
 func (s *JWTTestSuite) TestVerify_ValidToken() {
-	token, err := s.auth.Sign(s.userID, s.roomID)
+	token, err := s.auth.Sign(s.userID, s.roomID, constants.UserRoleAnchor)
 	s.Require().NoError(err)
 
 	claims, err := s.auth.Verify(token)
@@ -113,10 +162,10 @@ func (s *JWTTestSuite) TestVerify_MalformedToken() {
 }
 
 func (s *JWTTestSuite) TestVerify_WrongSecret() {
-	token, err := s.auth.Sign(s.userID, s.roomID)
+	token, err := s.auth.Sign(s.userID, s.roomID, constants.UserRoleAnchor)
 	s.Require().NoError(err)
 
-	wrongAuth := NewAuth("wrong-secret")
+	wrongAuth := NewAuth(&Config{Secret: "wrong-secret"})
 	claims, err := wrongAuth.Verify(token)
 	s.Require().ErrorIs(err, ErrInvalidToken)
 	s.Nil(claims)
@@ -124,8 +173,8 @@ func (s *JWTTestSuite) TestVerify_WrongSecret() {
 
 func (s *JWTTestSuite) TestAlgorithmMismatch_RejectHS384() {
 	// Create a token with HS384
-	authHS384 := NewAuthWithAlgorithm(s.secret, jwt.SigningMethodHS384)
-	token, err := authHS384.Sign(s.userID, s.roomID)
+	authHS384 := NewAuthWithAlgorithm(s.cfg, jwt.SigningMethodHS384)
+	token, err := authHS384.Sign(s.userID, s.roomID, constants.UserRoleAnchor)
 	s.Require().NoError(err)
 
 	// Try to verify with HS256 auth (should fail)
@@ -138,8 +187,8 @@ func (s *JWTTestSuite) TestAlgorithmMismatch_RejectHS384() {
 
 func (s *JWTTestSuite) TestAlgorithmMismatch_RejectHS512() {
 	// Create a token with HS512
-	authHS512 := NewAuthWithAlgorithm(s.secret, jwt.SigningMethodHS512)
-	token, err := authHS512.Sign(s.userID, s.roomID)
+	authHS512 := NewAuthWithAlgorithm(s.cfg, jwt.SigningMethodHS512)
+	token, err := authHS512.Sign(s.userID, s.roomID, constants.UserRoleAnchor)
 	s.Require().NoError(err)
 
 	// Try to verify with HS256 auth (should fail)
@@ -151,8 +200,8 @@ func (s *JWTTestSuite) TestAlgorithmMismatch_RejectHS512() {
 }
 
 func (s *JWTTestSuite) TestAlgorithmMismatch_AcceptMatching() {
-	authHS384 := NewAuthWithAlgorithm(s.secret, jwt.SigningMethodHS384)
-	token, err := authHS384.Sign(s.userID, s.roomID)
+	authHS384 := NewAuthWithAlgorithm(s.cfg, jwt.SigningMethodHS384)
+	token, err := authHS384.Sign(s.userID, s.roomID, constants.UserRoleAnchor)
 	s.Require().NoError(err)
 
 	// Verify with same algorithm should succeed
@@ -164,13 +213,7 @@ func (s *JWTTestSuite) TestAlgorithmMismatch_AcceptMatching() {
 
 func (s *JWTTestSuite) TestTokenMissingFields_UserID() {
 	// Manually create a token without userID
-	claims := &Payload{
-		UserID: "",
-		RoomID: s.roomID,
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.secret))
-	s.Require().NoError(err)
+	tokenString := s.signClaims(func(p *Payload) { p.UserID = "" })
 
 	// Should fail verification
 	verifiedClaims, err := s.auth.Verify(tokenString)
@@ -181,13 +224,7 @@ func (s *JWTTestSuite) TestTokenMissingFields_UserID() {
 
 func (s *JWTTestSuite) TestTokenMissingFields_RoomID() {
 	// Manually create a token without roomID
-	claims := &Payload{
-		UserID: s.userID,
-		RoomID: "",
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.secret))
-	s.Require().NoError(err)
+	tokenString := s.signClaims(func(p *Payload) { p.RoomID = "" })
 
 	// Should fail verification
 	verifiedClaims, err := s.auth.Verify(tokenString)
@@ -198,13 +235,10 @@ func (s *JWTTestSuite) TestTokenMissingFields_RoomID() {
 
 func (s *JWTTestSuite) TestTokenMissingFields_BothFields() {
 	// Manually create a token without any fields
-	claims := &Payload{
-		UserID: "",
-		RoomID: "",
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.secret))
-	s.Require().NoError(err)
+	tokenString := s.signClaims(func(p *Payload) {
+		p.UserID = ""
+		p.RoomID = ""
+	})
 
 	// Should fail verification
 	verifiedClaims, err := s.auth.Verify(tokenString)
@@ -225,10 +259,10 @@ func (s *JWTTestSuite) TestSignAndVerifyRoundTrip() {
 
 	for _, alg := range algorithms {
 		s.Run(alg.name, func() {
-			auth := NewAuthWithAlgorithm(s.secret, alg.method)
+			auth := NewAuthWithAlgorithm(s.cfg, alg.method)
 
 			// Sign
-			token, err := auth.Sign(s.userID, s.roomID)
+			token, err := auth.Sign(s.userID, s.roomID, constants.UserRoleAnchor)
 			s.Require().NoError(err)
 			s.NotEmpty(token)
 
@@ -250,7 +284,7 @@ func (s *JWTTestSuite) TestConcurrentSignAndVerify() {
 	// Concurrent signing
 	for i := 0; i < concurrency; i++ {
 		go func(_ int) {
-			token, err := s.auth.Sign(s.userID, s.roomID)
+			token, err := s.auth.Sign(s.userID, s.roomID, constants.UserRoleAnchor)
 			if err != nil {
 				errChan <- err
 			} else {
@@ -310,3 +344,122 @@ func (s *JWTTestSuite) TestConcurrentSignAndVerify() {
 		s.Equal(s.roomID, claims.RoomID)
 	}
 }
+
+func (s *JWTTestSuite) TestSign_RegisteredClaims() {
+	token, err := s.auth.Sign(s.userID, s.roomID, constants.UserRoleGuest)
+	s.Require().NoError(err)
+
+	claims, err := s.auth.Verify(token)
+	s.Require().NoError(err)
+	s.Equal(constants.UserRoleGuest, claims.Role)
+	s.Equal("test-issuer", claims.Issuer)
+	s.Equal(jwt.ClaimStrings{"test-audience"}, claims.Audience)
+	s.WithinDuration(time.Now().Add(time.Hour), claims.ExpiresAt.Time, 5*time.Second)
+}
+
+func (s *JWTTestSuite) TestVerify_RegisteredClaims() {
+	testCases := []struct {
+		name  string
+		tweak func(*Payload)
+		valid bool
+	}{
+		{"wrong issuer", func(p *Payload) { p.Issuer = "other" }, false},
+		{"missing issuer", func(p *Payload) { p.Issuer = "" }, false},
+		{"wrong audience", func(p *Payload) { p.Audience = jwt.ClaimStrings{"other"} }, false},
+		{"one of audiences", func(p *Payload) { p.Audience = jwt.ClaimStrings{"other", "test-audience"} }, true},
+		{"missing expiry", func(p *Payload) { p.ExpiresAt = nil }, false},
+		{"expired", func(p *Payload) {
+			p.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}, false},
+		{"expired within skew", func(p *Payload) {
+			p.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-10 * time.Second))
+		}, true},
+		{"not yet valid", func(p *Payload) {
+			p.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+		}, false},
+		{"not yet valid within skew", func(p *Payload) {
+			p.NotBefore = jwt.NewNumericDate(time.Now().Add(10 * time.Second))
+		}, true},
+		{"issued in the future", func(p *Payload) {
+			p.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
+		}, false},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			claims, err := s.auth.Verify(s.signClaims(tc.tweak))
+			if tc.valid {
+				s.Require().NoError(err)
+				s.Equal(s.userID, claims.UserID)
+				return
+			}
+			s.Require().ErrorIs(err, ErrInvalidToken)
+			s.Nil(claims)
+		})
+	}
+}
+
+func (s *JWTTestSuite) TestVerify_NoIssuerAudienceConfigured() {
+	auth := NewAuth(&Config{Secret: s.secret})
+
+	token, err := auth.Sign(s.userID, s.roomID, constants.UserRoleAnchor)
+	s.Require().NoError(err)
+
+	claims, err := auth.Verify(token)
+	s.Require().NoError(err)
+	s.Nil(claims.ExpiresAt)
+
+	// tokens of other issuers are accepted when not enforced
+	claims, err = auth.Verify(s.signClaims(nil))
+	s.Require().NoError(err)
+	s.Equal(s.roomID, claims.RoomID)
+}
+
+func (s *JWTTestSuite) TestClockSkewBounded() {
+	cfg := &Config{ClockSkew: time.Hour}
+	s.Equal(maxClockSkew, cfg.clockSkew())
+
+	cfg.ClockSkew = -time.Second
+	s.Equal(time.Duration(0), cfg.clockSkew())
+}
+
+func (s *JWTTestSuite) TestPayloadHasRole() {
+	p := &Payload{Role: constants.UserRoleAnchor}
+	s.True(p.HasRole(constants.UserRoleAnchor))
+	s.True(p.HasRole(constants.UserRoleGuest, constants.UserRoleAnchor))
+	s.False(p.HasRole(constants.UserRoleGuest))
+	s.False((&Payload{}).HasRole(constants.UserRoleAnchor))
+}
+
+func (s *JWTTestSuite) TestSecretRotation() {
+	path := filepath.Join(s.T().TempDir(), "jwt")
+	s.Require().NoError(os.WriteFile(path, []byte("first-secret"), 0o600))
+	resolver := secret.NewResolver(&secret.Config{RefreshInterval: 10 * time.Millisecond}, log.NewNop())
+	sec, err := resolver.Get(context.Background(), "file:"+path)
+	s.Require().NoError(err)
+	s.Require().NoError(resolver.Start(context.Background()))
+	defer resolver.Stop()
+
+	auth := NewAuthWithSecret(s.cfg, sec)
+	before, err := auth.Sign(s.userID, s.roomID, constants.UserRoleAnchor)
+	s.Require().NoError(err)
+
+	s.Require().NoError(os.WriteFile(path, []byte("second-secret"), 0o600))
+	s.Require().Eventually(func() bool { return sec.Value() == "second-secret" }, time.Second, 10*time.Millisecond)
+
+	// signed with the new secret, tokens of the previous one still verify
+	after, err := auth.Sign(s.userID, s.roomID, constants.UserRoleAnchor)
+	s.Require().NoError(err)
+	_, err = NewAuth(&Config{Secret: "second-secret", Issuer: s.cfg.Issuer, Audience: s.cfg.Audience}).Verify(after)
+	s.Require().NoError(err)
+	_, err = auth.Verify(before)
+	s.Require().NoError(err)
+	_, err = auth.Verify(after)
+	s.Require().NoError(err)
+
+	// until the next rotation
+	s.Require().NoError(os.WriteFile(path, []byte("third-secret"), 0o600))
+	s.Require().Eventually(func() bool { return sec.Value() == "third-secret" }, time.Second, 10*time.Millisecond)
+	_, err = auth.Verify(before)
+	s.Require().ErrorIs(err, ErrInvalidToken)
+}
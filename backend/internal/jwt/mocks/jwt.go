@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/imtaco/audio-rtc-exp/internal/jwt (interfaces: JWTAuth)
+// Source: github.com/imtaco/audio-rtc-exp/internal/jwt (interfaces: Auth)
 //
 // Generated by this command:
 //
@@ -11,10 +11,11 @@ package mocks
 
 import (
 	reflect "reflect"
+	time "time"
 
-	gomock "go.uber.org/mock/gomock"
-
+	constants "github.com/imtaco/audio-rtc-exp/internal/constants"
 	jwt "github.com/imtaco/audio-rtc-exp/internal/jwt"
+	gomock "go.uber.org/mock/gomock"
 )
 
 // MockAuth is a mock of Auth interface.
@@ -42,18 +43,48 @@ func (m *MockAuth) EXPECT() *MockAuthMockRecorder {
 }
 
 // Sign mocks base method.
-func (m *MockAuth) Sign(userID, roomID string) (string, error) {
+func (m *MockAuth) Sign(userID, roomID string, role constants.UserRole) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Sign", userID, roomID)
+	ret := m.ctrl.Call(m, "Sign", userID, roomID, role)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Sign indicates an expected call of Sign.
-func (mr *MockAuthMockRecorder) Sign(userID, roomID any) *gomock.Call {
+func (mr *MockAuthMockRecorder) Sign(userID, roomID, role any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sign", reflect.TypeOf((*MockAuth)(nil).Sign), userID, roomID, role)
+}
+
+// SignWithLocale mocks base method.
+func (m *MockAuth) SignWithLocale(userID, roomID string, role constants.UserRole, locale string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SignWithLocale", userID, roomID, role, locale)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SignWithLocale indicates an expected call of SignWithLocale.
+func (mr *MockAuthMockRecorder) SignWithLocale(userID, roomID, role, locale any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignWithLocale", reflect.TypeOf((*MockAuth)(nil).SignWithLocale), userID, roomID, role, locale)
+}
+
+// SignWithTTL mocks base method.
+func (m *MockAuth) SignWithTTL(userID, roomID string, role constants.UserRole, ttl time.Duration) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SignWithTTL", userID, roomID, role, ttl)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SignWithTTL indicates an expected call of SignWithTTL.
+func (mr *MockAuthMockRecorder) SignWithTTL(userID, roomID, role, ttl any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sign", reflect.TypeOf((*MockAuth)(nil).Sign), userID, roomID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignWithTTL", reflect.TypeOf((*MockAuth)(nil).SignWithTTL), userID, roomID, role, ttl)
 }
 
 // Verify mocks base method.
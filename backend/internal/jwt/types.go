@@ -1,18 +1,38 @@
 package jwt
 
 import (
+	"time"
+
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
 )
 
 // Auth handles JWT authentication
 type Auth interface {
-	Sign(userID, roomID string) (string, error)
+	Sign(userID, roomID string, role constants.UserRole) (string, error)
+	// SignWithTTL signs a token expiring after ttl instead of the configured expiry
+	SignWithTTL(userID, roomID string, role constants.UserRole, ttl time.Duration) (string, error)
+	// SignWithLocale signs a token carrying the BCP 47 locale the user prefers, empty for none
+	SignWithLocale(userID, roomID string, role constants.UserRole, locale string) (string, error)
 	Verify(tokenString string) (*Payload, error)
 }
 
 // Payload represents the JWT token payload
 type Payload struct {
-	UserID string `json:"userId"`
-	RoomID string `json:"roomId"`
+	UserID string             `json:"userId"`
+	RoomID string             `json:"roomId"`
+	Role   constants.UserRole `json:"role,omitempty"`
+	Locale string             `json:"locale,omitempty"`
 	jwt.RegisteredClaims
 }
+
+// HasRole reports whether the token was issued to one of roles
+func (p *Payload) HasRole(roles ...constants.UserRole) bool {
+	for _, role := range roles {
+		if p.Role == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package listeners
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+)
+
+// Listeners are HLS viewers of a room, counted by their unique playback tokens in one Redis
+// HyperLogLog per room and minute:
+//
+//	<prefix><roomId>:<minute>            tokens seen in the minute
+//	<prefix><roomId>:<minute>:reported   set by the hlsserver reporting the minute
+const bucket = time.Minute
+
+// EventRoomListeners is published to the analytics stream once per room and minute
+const EventRoomListeners = "roomListeners"
+
+// RoomListeners is the count of unique listeners of a room within a minute
+type RoomListeners struct {
+	RoomID    string    `json:"roomId"`
+	Minute    time.Time `json:"minute"`
+	Listeners int64     `json:"listeners"`
+}
+
+type Config struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	KeyPrefix string `mapstructure:"key_prefix"`
+	// Window is the period estimates count listeners over, rounded up to minutes
+	Window time.Duration `mapstructure:"window"`
+	// Retention bounds the lifetime of minute buckets
+	Retention time.Duration `mapstructure:"retention"`
+	// FlushInterval is the period tokens are buffered by the hlsserver before being counted
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	// Stream is the analytics stream minute counts are published to, empty disables it
+	Stream     string                 `mapstructure:"stream"`
+	StreamTrim redisstream.TrimPolicy `mapstructure:"stream_trim"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), false)
+	v.SetDefault(p("key_prefix"), "listeners:")
+	v.SetDefault(p("window"), 2*time.Minute)
+	v.SetDefault(p("retention"), time.Hour)
+	v.SetDefault(p("flush_interval"), 5*time.Second)
+	v.SetDefault(p("stream"), "")
+	v.SetDefault(p("stream_trim.max_len"), 0)
+	v.SetDefault(p("stream_trim.max_age"), 24*time.Hour)
+}
+
+// Counter reads listener counts
+type Counter struct {
+	client    redis.Cmdable
+	keyPrefix string
+	window    time.Duration
+	clock     clockwork.Clock
+}
+
+func NewCounter(client redis.Cmdable, cfg *Config) *Counter {
+	return &Counter{
+		client:    client,
+		keyPrefix: cfg.KeyPrefix,
+		window:    cfg.Window,
+		clock:     clockwork.NewRealClock(),
+	}
+}
+
+func (c *Counter) key(roomID string, minute time.Time) string {
+	return fmt.Sprintf("%s%s:%d", c.keyPrefix, roomID, minute.Unix()/int64(bucket/time.Second))
+}
+
+// Estimate returns the unique listeners of the room within the window, up to now
+func (c *Counter) Estimate(ctx context.Context, roomID string) (int64, error) {
+	now := c.clock.Now().Truncate(bucket)
+	n := max(int((c.window+bucket-1)/bucket), 1)
+
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = c.key(roomID, now.Add(-time.Duration(i)*bucket))
+	}
+	count, err := c.client.PFCount(ctx, keys...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count listeners: %w", err)
+	}
+	return count, nil
+}
+
+// countMinute returns the unique listeners of the room within the minute
+func (c *Counter) countMinute(ctx context.Context, roomID string, minute time.Time) (int64, error) {
+	count, err := c.client.PFCount(ctx, c.key(roomID, minute)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count listeners: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,213 @@
+package listeners
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	redisrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/redis"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+)
+
+const flushTimeout = 5 * time.Second
+
+// Tracker counts the playback tokens fetching playlists and keys of rooms. Tokens are buffered
+// and added to the minute buckets every flush interval, once a minute is over its count is
+// published to the analytics stream by the first hlsserver claiming it
+type Tracker struct {
+	*Counter
+	client        *redis.Client
+	retention     time.Duration
+	flushInterval time.Duration
+	peer          jsonrpc.Peer[any] // nil when the analytics stream is disabled
+	trimer        redisstream.Trimer
+	trimPolicy    *redisstream.TrimPolicy
+
+	mu      sync.Mutex
+	pending map[string]map[string]struct{}    // bucket key -> tokens
+	seen    map[time.Time]map[string]struct{} // minute -> rooms, until reported
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	logger *log.Logger
+}
+
+func NewTracker(client *redis.Client, cfg *Config, logger *log.Logger) (*Tracker, error) {
+	t := &Tracker{
+		Counter:       NewCounter(client, cfg),
+		client:        client,
+		retention:     cfg.Retention,
+		flushInterval: cfg.FlushInterval,
+		trimPolicy:    &cfg.StreamTrim,
+		pending:       make(map[string]map[string]struct{}),
+		seen:          make(map[time.Time]map[string]struct{}),
+		logger:        logger,
+	}
+	if cfg.Stream != "" {
+		peer, err := redisrpc.NewPeer[any](client, cfg.Stream, "", "", logger.Module("Peer"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create analytics peer: %w", err)
+		}
+		t.peer = peer
+		t.trimer = redisstream.NewTrimer(client, cfg.Stream, logger.Module("Trimer"))
+	}
+	return t, nil
+}
+
+// Track counts the token as a listener of the room in the current minute
+func (t *Tracker) Track(roomID, token string) {
+	minute := t.clock.Now().Truncate(bucket)
+	key := t.key(roomID, minute)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tokens, ok := t.pending[key]
+	if !ok {
+		tokens = make(map[string]struct{})
+		t.pending[key] = tokens
+	}
+	tokens[token] = struct{}{}
+
+	rooms, ok := t.seen[minute]
+	if !ok {
+		rooms = make(map[string]struct{})
+		t.seen[minute] = rooms
+	}
+	rooms[roomID] = struct{}{}
+}
+
+func (t *Tracker) Start(ctx context.Context) error {
+	if t.peer != nil {
+		if err := t.peer.Open(ctx); err != nil {
+			return fmt.Errorf("failed to open analytics peer: %w", err)
+		}
+	}
+
+	ctx, t.cancel = context.WithCancel(ctx)
+	t.done = make(chan struct{})
+	go func() {
+		defer close(t.done)
+		ticker := t.clock.NewTicker(t.flushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.Chan():
+				t.flush(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop counts the buffered tokens, minutes not reported yet are left to other hlsservers
+func (t *Tracker) Stop() error {
+	if t.cancel != nil {
+		t.cancel()
+		<-t.done
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	if err := t.add(ctx); err != nil {
+		t.logger.Error("Failed to count listeners", log.Error(err))
+	}
+
+	if t.peer != nil {
+		return t.peer.Close()
+	}
+	return nil
+}
+
+func (t *Tracker) flush(ctx context.Context) {
+	if err := t.add(ctx); err != nil {
+		t.logger.Error("Failed to count listeners", log.Error(err))
+	}
+	t.report(ctx)
+}
+
+// add adds the buffered tokens to their minute buckets
+func (t *Tracker) add(ctx context.Context) error {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[string]map[string]struct{})
+	t.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	pipe := t.client.Pipeline()
+	for key, tokens := range pending {
+		members := make([]any, 0, len(tokens))
+		for token := range tokens {
+			members = append(members, token)
+		}
+		pipe.PFAdd(ctx, key, members...)
+		pipe.Expire(ctx, key, t.retention)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// report publishes the counts of minutes over for a flush interval, so the tokens buffered by
+// other hlsservers within the minute are counted as well
+func (t *Tracker) report(ctx context.Context) {
+	last := t.clock.Now().Add(-t.flushInterval).Truncate(bucket).Add(-bucket)
+
+	t.mu.Lock()
+	due := make(map[time.Time]map[string]struct{})
+	for minute, rooms := range t.seen {
+		if !minute.After(last) {
+			due[minute] = rooms
+			delete(t.seen, minute)
+		}
+	}
+	t.mu.Unlock()
+
+	if t.peer == nil {
+		return
+	}
+	for minute, rooms := range due {
+		for roomID := range rooms {
+			if err := t.reportRoom(ctx, roomID, minute); err != nil {
+				t.logger.Error("Failed to report listeners",
+					log.String("roomId", roomID),
+					log.Time("minute", minute),
+					log.Error(err))
+			}
+		}
+	}
+	if len(due) > 0 {
+		if _, err := t.trimer.Trim(ctx, t.trimPolicy); err != nil {
+			t.logger.Error("Failed to trim analytics stream", log.Error(err))
+		}
+	}
+}
+
+func (t *Tracker) reportRoom(ctx context.Context, roomID string, minute time.Time) error {
+	claimed, err := t.client.SetNX(ctx, t.key(roomID, minute)+":reported", "", t.retention).Result()
+	if err != nil {
+		return fmt.Errorf("failed to claim report: %w", err)
+	}
+	if !claimed {
+		return nil
+	}
+
+	count, err := t.countMinute(ctx, roomID, minute)
+	if err != nil {
+		return err
+	}
+	return t.peer.Notify(ctx, EventRoomListeners, &RoomListeners{
+		RoomID:    roomID,
+		Minute:    minute,
+		Listeners: count,
+	})
+}
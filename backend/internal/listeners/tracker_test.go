@@ -0,0 +1,124 @@
+package listeners
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jonboulle/clockwork"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type TrackerTestSuite struct {
+	suite.Suite
+	miniRedis *miniredis.Miniredis
+	client    *redis.Client
+	clock     *clockwork.FakeClock
+	cfg       *Config
+	ctx       context.Context
+}
+
+func TestTrackerSuite(t *testing.T) {
+	suite.Run(t, new(TrackerTestSuite))
+}
+
+func (s *TrackerTestSuite) SetupTest() {
+	mr, err := miniredis.Run()
+	s.Require().NoError(err)
+	s.miniRedis = mr
+	s.client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	s.clock = clockwork.NewFakeClockAt(time.Date(2026, 1, 1, 12, 0, 10, 0, time.UTC))
+	s.cfg = &Config{
+		KeyPrefix:     "listeners:",
+		Window:        2 * time.Minute,
+		Retention:     time.Hour,
+		FlushInterval: 5 * time.Second,
+		Stream:        "analytics",
+	}
+	s.ctx = context.Background()
+}
+
+func (s *TrackerTestSuite) TearDownTest() {
+	s.client.Close()
+	s.miniRedis.Close()
+}
+
+func (s *TrackerTestSuite) newTracker() *Tracker {
+	t, err := NewTracker(s.client, s.cfg, log.NewNop())
+	s.Require().NoError(err)
+	t.clock = s.clock
+	return t
+}
+
+func (s *TrackerTestSuite) TestEstimate_CountsUniqueTokensWithinWindow() {
+	t := s.newTracker()
+	t.Track("room-1", "token-a")
+	t.Track("room-1", "token-a")
+	t.Track("room-1", "token-b")
+	t.Track("room-2", "token-c")
+	s.Require().NoError(t.add(s.ctx))
+
+	s.clock.Advance(time.Minute)
+	t.Track("room-1", "token-d")
+	t.Track("room-1", "token-e")
+	s.Require().NoError(t.add(s.ctx))
+
+	count, err := t.Estimate(s.ctx, "room-1")
+	s.Require().NoError(err)
+	s.Equal(int64(4), count)
+
+	// the first minute left the window
+	s.clock.Advance(time.Minute)
+	count, err = t.Estimate(s.ctx, "room-1")
+	s.Require().NoError(err)
+	s.Equal(int64(2), count)
+
+	count, err = t.Estimate(s.ctx, "room-3")
+	s.Require().NoError(err)
+	s.Zero(count)
+}
+
+func (s *TrackerTestSuite) TestFlush_ReportsMinuteOnce() {
+	t := s.newTracker()
+	other := s.newTracker()
+	s.Require().NoError(t.peer.Open(s.ctx))
+	s.Require().NoError(other.peer.Open(s.ctx))
+
+	t.Track("room-1", "token-a")
+	other.Track("room-1", "token-b")
+	t.flush(s.ctx)
+	other.flush(s.ctx)
+	s.Equal(int64(0), s.client.XLen(s.ctx, "analytics").Val())
+
+	// the minute is reported once over for a flush interval, by a single tracker
+	s.clock.Advance(55 * time.Second)
+	t.flush(s.ctx)
+	other.flush(s.ctx)
+	entries, err := s.client.XRange(s.ctx, "analytics", "-", "+").Result()
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Contains(entries[0].Values["data"], `"method":"roomListeners"`)
+	s.Contains(entries[0].Values["data"], `"roomId":"room-1"`)
+	s.Contains(entries[0].Values["data"], `"listeners":2`)
+
+	s.clock.Advance(time.Minute)
+	t.flush(s.ctx)
+	s.Equal(int64(1), s.client.XLen(s.ctx, "analytics").Val())
+}
+
+func (s *TrackerTestSuite) TestStop_CountsBufferedTokens() {
+	s.cfg.Stream = ""
+	t := s.newTracker()
+	s.Require().NoError(t.Start(s.ctx))
+
+	t.Track("room-1", "token-a")
+	s.Require().NoError(t.Stop())
+
+	count, err := t.Estimate(s.ctx, "room-1")
+	s.Require().NoError(err)
+	s.Equal(int64(1), count)
+}
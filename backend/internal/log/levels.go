@@ -0,0 +1,168 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Levels holds the levels of a logger and its modules, modules are named by their dot
+// joined names as Module builds them, the empty name being the main logger.
+// Levels start from the environment or the config file and are overridden at runtime,
+// the override of a module also applies to its submodules without one.
+type Levels struct {
+	mu           sync.Mutex
+	defaultLevel func(names []string) zapcore.Level
+	atomics      map[string]zap.AtomicLevel
+	overrides    map[string]zapcore.Level
+}
+
+func newLevels(defaultLevel func(names []string) zapcore.Level) *Levels {
+	return &Levels{
+		defaultLevel: defaultLevel,
+		atomics:      make(map[string]zap.AtomicLevel),
+		overrides:    make(map[string]zapcore.Level),
+	}
+}
+
+// atomic returns the level shared by the loggers of a module, created at its current level
+func (l *Levels) atomic(names []string) zap.AtomicLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	module := strings.Join(names, ".")
+	if lv, ok := l.atomics[module]; ok {
+		return lv
+	}
+	lv := zap.NewAtomicLevelAt(l.levelOf(module))
+	l.atomics[module] = lv
+	return lv
+}
+
+// levelOf returns the override of the module or its closest parent, else its default level
+func (l *Levels) levelOf(module string) zapcore.Level {
+	for name := module; ; {
+		if lv, ok := l.overrides[name]; ok {
+			return lv
+		}
+		if name == "" {
+			break
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			name = ""
+		} else {
+			name = name[:i]
+		}
+	}
+	if module == "" {
+		return l.defaultLevel(nil)
+	}
+	return l.defaultLevel(strings.Split(module, "."))
+}
+
+// Set overrides the level of a module and its submodules, an empty level drops the override
+func (l *Levels) Set(module, level string) error {
+	return l.update(func(overrides map[string]zapcore.Level) error {
+		if level == "" {
+			delete(overrides, module)
+			return nil
+		}
+		lv, ok := parseLevel(level)
+		if !ok {
+			return fmt.Errorf("invalid level %q", level)
+		}
+		overrides[module] = lv
+		return nil
+	})
+}
+
+// Replace replaces all overrides by levels, keyed by module
+func (l *Levels) Replace(levels map[string]string) error {
+	return l.update(func(overrides map[string]zapcore.Level) error {
+		parsed := make(map[string]zapcore.Level, len(levels))
+		for module, level := range levels {
+			lv, ok := parseLevel(level)
+			if !ok {
+				return fmt.Errorf("invalid level %q of module %q", level, module)
+			}
+			parsed[module] = lv
+		}
+		clear(overrides)
+		for module, lv := range parsed {
+			overrides[module] = lv
+		}
+		return nil
+	})
+}
+
+func (l *Levels) update(fn func(overrides map[string]zapcore.Level) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := fn(l.overrides); err != nil {
+		return err
+	}
+	for module, lv := range l.atomics {
+		lv.SetLevel(l.levelOf(module))
+	}
+	return nil
+}
+
+// LevelsState is the current levels of the modules and the runtime overrides
+type LevelsState struct {
+	Modules   map[string]string `json:"modules"`
+	Overrides map[string]string `json:"overrides"`
+}
+
+func (l *Levels) State() *LevelsState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state := &LevelsState{
+		Modules:   make(map[string]string, len(l.atomics)),
+		Overrides: make(map[string]string, len(l.overrides)),
+	}
+	for module, lv := range l.atomics {
+		state.Modules[module] = lv.Level().String()
+	}
+	for module, lv := range l.overrides {
+		state.Overrides[module] = lv.String()
+	}
+	return state
+}
+
+// SetLevelRequest overrides the level of a module, an empty level drops the override
+type SetLevelRequest struct {
+	Module string `json:"module"`
+	Level  string `json:"level"`
+}
+
+// ServeHTTP returns the levels on GET and sets the level of a module on PUT
+func (l *Levels) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req SetLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := l.Set(req.Module, req.Level); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(l.State())
+}
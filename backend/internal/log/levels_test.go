@@ -0,0 +1,148 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap/zapcore"
+)
+
+type LevelsTestSuite struct {
+	suite.Suite
+	levels *Levels
+}
+
+func TestLevelsSuite(t *testing.T) {
+	suite.Run(t, new(LevelsTestSuite))
+}
+
+func (s *LevelsTestSuite) SetupTest() {
+	s.levels = newLevels(func(names []string) zapcore.Level {
+		// a module with a level of its own in the environment
+		if strings.Join(names, ".") == "Env" {
+			return zapcore.WarnLevel
+		}
+		return zapcore.InfoLevel
+	})
+}
+
+func (s *LevelsTestSuite) TestDefaults() {
+	s.Equal(zapcore.InfoLevel, s.levels.atomic(nil).Level())
+	s.Equal(zapcore.InfoLevel, s.levels.atomic([]string{"RoomSvc"}).Level())
+	s.Equal(zapcore.WarnLevel, s.levels.atomic([]string{"Env"}).Level())
+}
+
+func (s *LevelsTestSuite) TestSet_AppliesToSubmodules() {
+	parent := s.levels.atomic([]string{"ResMgr"})
+	child := s.levels.atomic([]string{"ResMgr", "JanusWorker"})
+	other := s.levels.atomic([]string{"RoomSvc"})
+
+	s.Require().NoError(s.levels.Set("ResMgr", "debug"))
+	s.Equal(zapcore.DebugLevel, parent.Level())
+	s.Equal(zapcore.DebugLevel, child.Level())
+	s.Equal(zapcore.InfoLevel, other.Level())
+
+	// loggers of a module created later get the override
+	s.Equal(zapcore.DebugLevel, s.levels.atomic([]string{"ResMgr", "MixerWorker"}).Level())
+}
+
+func (s *LevelsTestSuite) TestSet_MostSpecificWins() {
+	main := s.levels.atomic(nil)
+	child := s.levels.atomic([]string{"ResMgr", "JanusWorker"})
+	env := s.levels.atomic([]string{"Env"})
+
+	s.Require().NoError(s.levels.Set("", "error"))
+	s.Require().NoError(s.levels.Set("ResMgr.JanusWorker", "debug"))
+	s.Equal(zapcore.ErrorLevel, main.Level())
+	s.Equal(zapcore.DebugLevel, child.Level())
+	s.Equal(zapcore.ErrorLevel, env.Level())
+
+	// dropping overrides restores the defaults
+	s.Require().NoError(s.levels.Set("", ""))
+	s.Require().NoError(s.levels.Set("ResMgr.JanusWorker", ""))
+	s.Equal(zapcore.InfoLevel, main.Level())
+	s.Equal(zapcore.InfoLevel, child.Level())
+	s.Equal(zapcore.WarnLevel, env.Level())
+}
+
+func (s *LevelsTestSuite) TestSet_InvalidLevel() {
+	lv := s.levels.atomic([]string{"RoomSvc"})
+
+	s.Error(s.levels.Set("RoomSvc", "verbose"))
+	s.Equal(zapcore.InfoLevel, lv.Level())
+}
+
+func (s *LevelsTestSuite) TestReplace() {
+	a := s.levels.atomic([]string{"A"})
+	b := s.levels.atomic([]string{"B"})
+	s.Require().NoError(s.levels.Set("A", "debug"))
+
+	s.Require().NoError(s.levels.Replace(map[string]string{"B": "error"}))
+	s.Equal(zapcore.InfoLevel, a.Level())
+	s.Equal(zapcore.ErrorLevel, b.Level())
+
+	// invalid overrides keep the current ones
+	s.Error(s.levels.Replace(map[string]string{"A": "debug", "B": "nope"}))
+	s.Equal(zapcore.InfoLevel, a.Level())
+	s.Equal(zapcore.ErrorLevel, b.Level())
+
+	s.Require().NoError(s.levels.Replace(nil))
+	s.Equal(zapcore.InfoLevel, b.Level())
+}
+
+func (s *LevelsTestSuite) serve(method, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, "/log/levels", strings.NewReader(body))
+	s.levels.ServeHTTP(w, req)
+	return w
+}
+
+func (s *LevelsTestSuite) TestServeHTTP() {
+	s.levels.atomic([]string{"RoomSvc"})
+
+	w := s.serve(http.MethodPut, `{"module":"RoomSvc","level":"debug"}`)
+	s.Require().Equal(http.StatusOK, w.Code)
+	var state LevelsState
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &state))
+	s.Equal(map[string]string{"RoomSvc": "debug"}, state.Modules)
+	s.Equal(map[string]string{"RoomSvc": "debug"}, state.Overrides)
+
+	w = s.serve(http.MethodGet, "")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), `"RoomSvc":"debug"`)
+
+	w = s.serve(http.MethodPut, `{"module":"RoomSvc","level":"loud"}`)
+	s.Equal(http.StatusBadRequest, w.Code)
+
+	w = s.serve(http.MethodPut, `not json`)
+	s.Equal(http.StatusBadRequest, w.Code)
+
+	w = s.serve(http.MethodDelete, "")
+	s.Equal(http.StatusMethodNotAllowed, w.Code)
+}
+
+func (s *LevelsTestSuite) TestFileLogger() {
+	path := filepath.Join(s.T().TempDir(), "log.json")
+	s.Require().NoError(os.WriteFile(path, []byte(`{
+		"level": "warn",
+		"encoding": "json",
+		"outputPaths": ["stdout"],
+		"encoderConfig": {"messageKey": "msg"}
+	}`), 0o600))
+
+	logger, err := NewLogger(path)
+	s.Require().NoError(err)
+	module := logger.Module("RoomSvc")
+	s.False(module.Core().Enabled(zapcore.InfoLevel))
+	s.True(module.Core().Enabled(zapcore.WarnLevel))
+
+	s.Require().NoError(logger.Levels().Set("RoomSvc", "debug"))
+	s.True(module.Core().Enabled(zapcore.DebugLevel))
+	s.False(logger.Core().Enabled(zapcore.InfoLevel))
+}
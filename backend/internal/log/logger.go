@@ -22,6 +22,7 @@ type Logger struct {
 	*zap.Logger
 	names      []string
 	moduleFunc func(names []string) *zap.Logger
+	levels     *Levels
 }
 
 func (l *Logger) Module(name string) *Logger {
@@ -33,9 +34,15 @@ func (l *Logger) Module(name string) *Logger {
 		names:      names,
 		Logger:     l.moduleFunc(names),
 		moduleFunc: l.moduleFunc,
+		levels:     l.levels,
 	}
 }
 
+// Levels returns the levels of the logger and its modules, adjustable at runtime
+func (l *Logger) Levels() *Levels {
+	return l.levels
+}
+
 func NewLogger(configFile string) (*Logger, error) {
 	if configFile == "" {
 		return newDefaultLogger(), nil
@@ -54,18 +61,34 @@ func loadLoggerFromFile(configFile string) (*Logger, error) {
 		return nil, err
 	}
 
+	// the configured level becomes the level of every module, filtered by levelCore,
+	// so the level of the built cores must let all entries through
+	fileLevel := zapcore.InfoLevel
+	if cfg.Level != (zap.AtomicLevel{}) {
+		fileLevel = cfg.Level.Level()
+	}
+	cfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+
 	zapLogger, err := cfg.Build()
 	if err != nil {
 		return nil, err
 	}
 
+	levels := newLevels(func(_ []string) zapcore.Level { return fileLevel })
+	leveled := func(names []string) *zap.Logger {
+		lv := levels.atomic(names)
+		return zapLogger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return &levelCore{Core: core, level: lv}
+		}))
+	}
 	moduleFunc := func(names []string) *zap.Logger {
-		return zapLogger.Named(strings.Join(names, "."))
+		return leveled(names).Named(strings.Join(names, "."))
 	}
 
 	return &Logger{
 		moduleFunc: moduleFunc,
-		Logger:     zapLogger.Named("main"),
+		Logger:     leveled(nil).Named("main"),
+		levels:     levels,
 	}, nil
 }
 
@@ -90,15 +113,13 @@ func newDefaultLogger() *Logger {
 
 	encoder := zapcore.NewConsoleEncoder(encCfg)
 	writer := zapcore.AddSync(os.Stdout)
-	level := zapcore.InfoLevel
-	if lv, ok := parseLevelFromEnv("LOG_LEVEL"); ok {
-		level = lv
-	}
+	// the main logger has no names, so it gets LOG_LEVEL
+	levels := newLevels(moduleLevel)
 
 	core := zapcore.NewCore(
 		encoder,
 		writer,
-		zap.NewAtomicLevelAt(level),
+		levels.atomic(nil),
 	)
 	baseLogger := zap.New(
 		core,
@@ -106,24 +127,25 @@ func newDefaultLogger() *Logger {
 	)
 
 	moduleFunc := func(names []string) *zap.Logger {
-		lv := moduleLevel(names)
+		lv := levels.atomic(names)
 		core := zapcore.NewCore(
 			encoder,
 			writer,
-			zap.NewAtomicLevelAt(lv),
+			lv,
 		)
 		logger := zap.New(
 			core,
 			zap.AddStacktrace(zapcore.FatalLevel),
 		).Named(strings.Join(names, "."))
 
-		logger.Info("use module log", zap.Any("level", lv))
+		logger.Info("use module log", zap.Any("level", lv.Level()))
 		return logger
 	}
 
 	return &Logger{
 		moduleFunc: moduleFunc,
 		Logger:     baseLogger.Named("main"),
+		levels:     levels,
 	}
 }
 
@@ -134,6 +156,8 @@ func NewTest(t *testing.T) *Logger {
 		moduleFunc: func(names []string) *zap.Logger {
 			return logger.Named(strings.Join(names, "."))
 		},
+		// test loggers log at every level whatever the levels
+		levels: newLevels(func(_ []string) zapcore.Level { return zapcore.DebugLevel }),
 	}
 }
 
@@ -144,5 +168,31 @@ func NewNop() *Logger {
 		moduleFunc: func(_ []string) *zap.Logger {
 			return logger
 		},
+		levels: newLevels(func(_ []string) zapcore.Level { return zapcore.InfoLevel }),
+	}
+}
+
+// levelCore filters the entries of a core by a level adjustable at runtime
+type levelCore struct {
+	zapcore.Core
+	level zap.AtomicLevel
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	return c.level.Enabled(lvl) && c.Core.Enabled(lvl)
+}
+
+func (c *levelCore) Level() zapcore.Level {
+	return c.level.Level()
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(ent.Level) {
+		return ce
 	}
+	return c.Core.Check(ent, ce)
 }
@@ -0,0 +1,32 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// LatencyMarker is a datagram Janus hosts send to a mixer next to the RTP forward of a room.
+// The mixer attributes it to the HLS segment being written when it arrives, so the segment
+// completion minus SentAt is the publish to HLS latency of the room.
+type LatencyMarker struct {
+	// Kind is empty for latency markers, see DatagramKind
+	Kind   string    `json:"kind,omitempty"`
+	RoomID string    `json:"roomId"`
+	SentAt time.Time `json:"sentAt"`
+}
+
+func EncodeLatencyMarker(m LatencyMarker) ([]byte, error) {
+	return json.Marshal(m)
+}
+
+func DecodeLatencyMarker(data []byte) (LatencyMarker, error) {
+	var m LatencyMarker
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, err
+	}
+	if m.Kind != "" || m.RoomID == "" || m.SentAt.IsZero() {
+		return m, errors.New("incomplete latency marker")
+	}
+	return m, nil
+}
@@ -0,0 +1,57 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyMarker(t *testing.T) {
+	sentAt := time.Date(2025, 1, 1, 0, 0, 0, 123000000, time.UTC)
+
+	data, err := EncodeLatencyMarker(LatencyMarker{RoomID: "room-1", SentAt: sentAt})
+	require.NoError(t, err)
+
+	m, err := DecodeLatencyMarker(data)
+	require.NoError(t, err)
+	assert.Equal(t, "room-1", m.RoomID)
+	assert.True(t, sentAt.Equal(m.SentAt))
+
+	_, err = DecodeLatencyMarker([]byte(`{"roomId":"room-1"}`))
+	assert.Error(t, err)
+
+	_, err = DecodeLatencyMarker([]byte("garbage"))
+	assert.Error(t, err)
+}
+
+func TestActiveSpeaker(t *testing.T) {
+	sentAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	data, err := EncodeActiveSpeaker(ActiveSpeaker{RoomID: "room-1", UserID: "u1", SentAt: sentAt})
+	require.NoError(t, err)
+	assert.Equal(t, KindActiveSpeaker, DatagramKind(data))
+
+	s, err := DecodeActiveSpeaker(data)
+	require.NoError(t, err)
+	assert.Equal(t, "room-1", s.RoomID)
+	assert.Equal(t, "u1", s.UserID)
+	assert.True(t, sentAt.Equal(s.SentAt))
+
+	// speaker datagrams are no latency markers, and the other way around
+	_, err = DecodeLatencyMarker(data)
+	assert.Error(t, err)
+	marker, err := EncodeLatencyMarker(LatencyMarker{RoomID: "room-1", SentAt: sentAt})
+	require.NoError(t, err)
+	assert.Empty(t, DatagramKind(marker))
+	_, err = DecodeActiveSpeaker(marker)
+	assert.Error(t, err)
+
+	// nobody talks
+	data, err = EncodeActiveSpeaker(ActiveSpeaker{RoomID: "room-1", SentAt: sentAt})
+	require.NoError(t, err)
+	s, err = DecodeActiveSpeaker(data)
+	require.NoError(t, err)
+	assert.Empty(t, s.UserID)
+}
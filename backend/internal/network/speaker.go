@@ -0,0 +1,46 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// KindActiveSpeaker is the kind of ActiveSpeaker datagrams
+const KindActiveSpeaker = "speaker"
+
+// ActiveSpeaker is a datagram Janus hosts send to the marker port of the mixer of a room when
+// the active speaker of the room changes, and again with every latency marker as datagrams may
+// be lost. UserID is empty while nobody talks, SentAt orders datagrams arriving out of order.
+type ActiveSpeaker struct {
+	Kind   string    `json:"kind"`
+	RoomID string    `json:"roomId"`
+	UserID string    `json:"userId,omitempty"`
+	SentAt time.Time `json:"sentAt"`
+}
+
+func EncodeActiveSpeaker(s ActiveSpeaker) ([]byte, error) {
+	s.Kind = KindActiveSpeaker
+	return json.Marshal(s)
+}
+
+func DecodeActiveSpeaker(data []byte) (ActiveSpeaker, error) {
+	var s ActiveSpeaker
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, err
+	}
+	if s.Kind != KindActiveSpeaker || s.RoomID == "" || s.SentAt.IsZero() {
+		return s, errors.New("incomplete active speaker")
+	}
+	return s, nil
+}
+
+// DatagramKind returns the kind of a datagram sent to the marker port, empty for latency
+// markers and undecodable datagrams
+func DatagramKind(data []byte) string {
+	var d struct {
+		Kind string `json:"kind"`
+	}
+	_ = json.Unmarshal(data, &d)
+	return d.Kind
+}
@@ -91,3 +91,13 @@ func (f *MetricFactory) Float64UpDownCounter(target *metric.Float64UpDownCounter
 	}
 	*target = counter
 }
+
+// Int64Gauge registers a gauge to be created later
+func (f *MetricFactory) Int64Gauge(target *metric.Int64Gauge, name string, options ...metric.Int64GaugeOption) {
+	fullName := f.name(name)
+	gauge, err := f.meter.Int64Gauge(fullName, options...)
+	if err != nil {
+		panic(fmt.Sprintf("failed to create gauge %s: %v", fullName, err))
+	}
+	*target = gauge
+}
@@ -9,4 +9,7 @@ const (
 	PrefixWSGateway   = "wsgateway"
 	PrefixUserService = "user_service"
 	PrefixHLSServer   = "hls_server"
+	PrefixWatcher     = "watcher"
+	PrefixEtcd        = "etcd"
+	PrefixProbe       = "probe"
 )
@@ -0,0 +1,185 @@
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const defaultRelayInterval = 5 * time.Second
+
+// Writer stages notifications, it is the only part business code should depend on
+type Writer interface {
+	// Commit applies ops and stores a pending notification in a single etcd transaction
+	Commit(ctx context.Context, method string, params any, ops ...clientv3.Op) error
+}
+
+// Publisher delivers relayed notifications, jsonrpc peers satisfy it
+type Publisher interface {
+	Notify(ctx context.Context, method string, params any) error
+}
+
+// Message is a pending notification kept in etcd until it is published
+type Message struct {
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// Outbox makes "write etcd then notify via Redis" flows crash safe.
+// The notification is written next to the business keys in one transaction, then a relay
+// loop publishes pending notifications in order and deletes them once delivered.
+// Delivery is at-least-once, so consumers must tolerate duplicates.
+type Outbox struct {
+	client    etcd.Client
+	prefix    string
+	publisher Publisher
+	interval  time.Duration
+	kick      chan struct{}
+	cancel    context.CancelFunc
+	stopped   chan struct{}
+	logger    *log.Logger
+}
+
+func New(
+	client etcd.Client,
+	prefix string,
+	publisher Publisher,
+	logger *log.Logger,
+) *Outbox {
+	return &Outbox{
+		client:    client,
+		prefix:    prefix,
+		publisher: publisher,
+		interval:  defaultRelayInterval,
+		kick:      make(chan struct{}, 1),
+		stopped:   make(chan struct{}),
+		logger:    logger,
+	}
+}
+
+func (o *Outbox) Commit(ctx context.Context, method string, params any, ops ...clientv3.Op) error {
+	op, err := o.messageOp(method, params)
+	if err != nil {
+		return err
+	}
+
+	resp, err := o.client.Txn(ctx).Then(append(ops, op)...).Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit outbox txn: %w", err)
+	}
+	if !resp.Succeeded {
+		return fmt.Errorf("outbox txn for %s not applied", method)
+	}
+
+	// wake up relay, a pending kick is good enough
+	select {
+	case o.kick <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (o *Outbox) messageOp(method string, params any) (clientv3.Op, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return clientv3.Op{}, fmt.Errorf("failed to marshal outbox params: %w", err)
+	}
+	msg, err := json.Marshal(&Message{
+		Method:    method,
+		Params:    data,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return clientv3.Op{}, fmt.Errorf("failed to marshal outbox message: %w", err)
+	}
+
+	key, err := o.newKey()
+	if err != nil {
+		return clientv3.Op{}, err
+	}
+	return clientv3.OpPut(key, string(msg)), nil
+}
+
+// newKey returns a key sorted by creation time, the random suffix avoids collisions between writers
+func (o *Outbox) newKey() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate outbox key: %w", err)
+	}
+	return fmt.Sprintf("%s%020d-%s", o.prefix, time.Now().UnixNano(), hex.EncodeToString(b)), nil
+}
+
+// Start starts the relay loop, messages left by a previous run are relayed first
+func (o *Outbox) Start(ctx context.Context) error {
+	o.logger.Info("Starting outbox relay",
+		log.String("prefix", o.prefix),
+		log.Duration("interval", o.interval))
+
+	ctx, o.cancel = context.WithCancel(ctx)
+	go o.loop(ctx)
+	return nil
+}
+
+// Stop stops the relay loop, pending messages stay in etcd for the next run
+func (o *Outbox) Stop() {
+	if o.cancel != nil {
+		o.cancel()
+		<-o.stopped
+	}
+	o.logger.Info("Stopped outbox relay")
+}
+
+func (o *Outbox) loop(ctx context.Context) {
+	ticker := time.NewTicker(o.interval)
+	defer close(o.stopped)
+	defer ticker.Stop()
+
+	for {
+		if err := o.relay(ctx); err != nil && ctx.Err() == nil {
+			o.logger.Error("Failed to relay outbox messages", log.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.kick:
+		}
+	}
+}
+
+// relay publishes pending messages in key order, it stops at the first failure to keep ordering
+func (o *Outbox) relay(ctx context.Context) error {
+	resp, err := o.client.Get(ctx, o.prefix,
+		clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return fmt.Errorf("failed to list outbox messages: %w", err)
+	}
+
+	for _, kv := range resp.Kvs {
+		key := string(kv.Key)
+
+		var msg Message
+		if err := json.Unmarshal(kv.Value, &msg); err != nil {
+			// poison message, would block the outbox forever
+			o.logger.Error("Dropping malformed outbox message", log.String("key", key), log.Error(err))
+		} else if err := o.publisher.Notify(ctx, msg.Method, msg.Params); err != nil {
+			return fmt.Errorf("failed to publish %s: %w", msg.Method, err)
+		}
+
+		if _, err := o.client.Delete(ctx, key); err != nil {
+			return fmt.Errorf("failed to delete outbox message: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/mock/gomock"
+
+	etcdmocks "github.com/imtaco/audio-rtc-exp/internal/etcd/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// fakeTxn records ops passed to Then
+type fakeTxn struct {
+	ops  []clientv3.Op
+	resp *clientv3.TxnResponse
+	err  error
+}
+
+func (t *fakeTxn) If(_ ...clientv3.Cmp) clientv3.Txn { return t }
+func (t *fakeTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.ops = append(t.ops, ops...)
+	return t
+}
+func (t *fakeTxn) Else(_ ...clientv3.Op) clientv3.Txn     { return t }
+func (t *fakeTxn) Commit() (*clientv3.TxnResponse, error) { return t.resp, t.err }
+
+type notification struct {
+	method string
+	params string
+}
+
+type fakePublisher struct {
+	mu   sync.Mutex
+	sent []notification
+	err  error
+}
+
+func (p *fakePublisher) Notify(_ context.Context, method string, params any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	data, _ := json.Marshal(params)
+	p.sent = append(p.sent, notification{method: method, params: string(data)})
+	return nil
+}
+
+func (p *fakePublisher) get() []notification {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]notification(nil), p.sent...)
+}
+
+type OutboxTestSuite struct {
+	suite.Suite
+	ctrl      *gomock.Controller
+	mockEtcd  *etcdmocks.MockClient
+	publisher *fakePublisher
+	outbox    *Outbox
+	ctx       context.Context
+}
+
+func TestOutboxSuite(t *testing.T) {
+	suite.Run(t, new(OutboxTestSuite))
+}
+
+func (s *OutboxTestSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.mockEtcd = etcdmocks.NewMockClient(s.ctrl)
+	s.publisher = &fakePublisher{}
+	s.outbox = New(s.mockEtcd, "/outbox/test/", s.publisher, log.NewTest(s.T()))
+	s.ctx = context.Background()
+}
+
+func (s *OutboxTestSuite) TearDownTest() {
+	s.ctrl.Finish()
+}
+
+func (s *OutboxTestSuite) kv(key string, msg *Message) *mvccpb.KeyValue {
+	data, err := json.Marshal(msg)
+	s.Require().NoError(err)
+	return &mvccpb.KeyValue{Key: []byte(key), Value: data}
+}
+
+func (s *OutboxTestSuite) TestCommit_WritesOpsAndMessageAtomically() {
+	txn := &fakeTxn{resp: &clientv3.TxnResponse{Succeeded: true}}
+	s.mockEtcd.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	err := s.outbox.Commit(s.ctx, "roomLive", map[string]string{"roomId": "room-1"},
+		clientv3.OpPut("/rooms/room-1/livemeta", "{}"))
+	s.Require().NoError(err)
+
+	s.Require().Len(txn.ops, 2)
+	s.Equal("/rooms/room-1/livemeta", string(txn.ops[0].KeyBytes()))
+
+	msgOp := txn.ops[1]
+	s.True(msgOp.IsPut())
+	s.True(strings.HasPrefix(string(msgOp.KeyBytes()), "/outbox/test/"))
+
+	var msg Message
+	s.Require().NoError(json.Unmarshal(msgOp.ValueBytes(), &msg))
+	s.Equal("roomLive", msg.Method)
+	s.JSONEq(`{"roomId":"room-1"}`, string(msg.Params))
+
+	// relay is kicked
+	s.Len(s.outbox.kick, 1)
+}
+
+func (s *OutboxTestSuite) TestCommit_TxnError() {
+	s.mockEtcd.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{err: errors.New("etcd down")})
+
+	err := s.outbox.Commit(s.ctx, "roomLive", nil)
+	s.Require().Error(err)
+	s.Empty(s.outbox.kick)
+}
+
+func (s *OutboxTestSuite) TestCommit_KeysAreOrdered() {
+	first, err := s.outbox.newKey()
+	s.Require().NoError(err)
+	time.Sleep(time.Millisecond)
+	second, err := s.outbox.newKey()
+	s.Require().NoError(err)
+
+	s.Less(first, second)
+}
+
+func (s *OutboxTestSuite) TestRelay_PublishesInOrderAndDeletes() {
+	s.mockEtcd.EXPECT().Get(gomock.Any(), "/outbox/test/", gomock.Any(), gomock.Any()).
+		Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{
+			s.kv("/outbox/test/1", &Message{Method: "a", Params: json.RawMessage(`{"n":1}`)}),
+			s.kv("/outbox/test/2", &Message{Method: "b", Params: json.RawMessage(`{"n":2}`)}),
+		}}, nil)
+	gomock.InOrder(
+		s.mockEtcd.EXPECT().Delete(gomock.Any(), "/outbox/test/1").Return(&clientv3.DeleteResponse{}, nil),
+		s.mockEtcd.EXPECT().Delete(gomock.Any(), "/outbox/test/2").Return(&clientv3.DeleteResponse{}, nil),
+	)
+
+	s.Require().NoError(s.outbox.relay(s.ctx))
+	s.Equal([]notification{
+		{method: "a", params: `{"n":1}`},
+		{method: "b", params: `{"n":2}`},
+	}, s.publisher.get())
+}
+
+func (s *OutboxTestSuite) TestRelay_PublishErrorKeepsMessage() {
+	s.publisher.err = errors.New("redis down")
+	s.mockEtcd.EXPECT().Get(gomock.Any(), "/outbox/test/", gomock.Any(), gomock.Any()).
+		Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{
+			s.kv("/outbox/test/1", &Message{Method: "a"}),
+			s.kv("/outbox/test/2", &Message{Method: "b"}),
+		}}, nil)
+	// no Delete expected
+
+	s.Error(s.outbox.relay(s.ctx))
+}
+
+func (s *OutboxTestSuite) TestRelay_DropsMalformedMessage() {
+	s.mockEtcd.EXPECT().Get(gomock.Any(), "/outbox/test/", gomock.Any(), gomock.Any()).
+		Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{
+			{Key: []byte("/outbox/test/1"), Value: []byte("not json")},
+		}}, nil)
+	s.mockEtcd.EXPECT().Delete(gomock.Any(), "/outbox/test/1").Return(&clientv3.DeleteResponse{}, nil)
+
+	s.Require().NoError(s.outbox.relay(s.ctx))
+	s.Empty(s.publisher.get())
+}
+
+func (s *OutboxTestSuite) TestStartRelaysPendingAndStop() {
+	s.mockEtcd.EXPECT().Get(gomock.Any(), "/outbox/test/", gomock.Any(), gomock.Any()).
+		Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{
+			s.kv("/outbox/test/1", &Message{Method: "left-over"}),
+		}}, nil)
+	s.mockEtcd.EXPECT().Delete(gomock.Any(), "/outbox/test/1").Return(&clientv3.DeleteResponse{}, nil)
+	s.mockEtcd.EXPECT().Get(gomock.Any(), "/outbox/test/", gomock.Any(), gomock.Any()).
+		Return(&clientv3.GetResponse{}, nil).AnyTimes()
+
+	s.outbox.interval = 10 * time.Millisecond
+	s.Require().NoError(s.outbox.Start(s.ctx))
+
+	s.Eventually(func() bool { return len(s.publisher.get()) == 1 }, time.Second, 5*time.Millisecond)
+	s.outbox.Stop()
+	s.Equal("left-over", s.publisher.get()[0].method)
+}
@@ -0,0 +1,110 @@
+package pin
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"math/big"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+)
+
+const ErrInvalidPin errors.Code = "invalid pin"
+
+// Format is the character set of room PINs
+type Format string
+
+const (
+	FormatHex          Format = "hex"
+	FormatNumeric      Format = "numeric"
+	FormatAlphanumeric Format = "alphanumeric"
+)
+
+const (
+	hexChars     = "0123456789abcdef"
+	numericChars = "0123456789"
+	// generated alphanumeric PINs avoid 0/O and 1/I, validation accepts any letter case
+	alphanumericChars = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+)
+
+// Policy describes the PINs rooms are created with
+type Policy struct {
+	Format Format `mapstructure:"format"`
+	Length int    `mapstructure:"length"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("format"), string(FormatHex))
+	v.SetDefault(p("length"), 6)
+}
+
+// Generate returns a random PIN of the policy
+func (p *Policy) Generate() (string, error) {
+	chars, err := p.chars()
+	if err != nil {
+		return "", err
+	}
+
+	size := big.NewInt(int64(len(chars)))
+	buf := make([]byte, p.Length)
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = chars[n.Int64()]
+	}
+	return string(buf), nil
+}
+
+// Validate checks a PIN given on room creation against the policy
+func (p *Policy) Validate(pin string) error {
+	if len(pin) != p.Length {
+		return errors.Newf(ErrInvalidPin, "pin must be %d characters", p.Length)
+	}
+
+	var valid func(r rune) bool
+	switch p.Format {
+	case FormatHex:
+		valid = func(r rune) bool { return strings.ContainsRune(hexChars, r) }
+	case FormatNumeric:
+		valid = func(r rune) bool { return r >= '0' && r <= '9' }
+	case FormatAlphanumeric:
+		valid = func(r rune) bool {
+			return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		}
+	default:
+		return errors.Newf(ErrInvalidPin, "unknown pin format: %s", p.Format)
+	}
+
+	for _, r := range pin {
+		if !valid(r) {
+			return errors.Newf(ErrInvalidPin, "pin must be %s", p.Format)
+		}
+	}
+	return nil
+}
+
+func (p *Policy) chars() (string, error) {
+	if p.Length <= 0 {
+		return "", errors.Newf(ErrInvalidPin, "invalid pin length: %d", p.Length)
+	}
+	switch p.Format {
+	case FormatHex:
+		return hexChars, nil
+	case FormatNumeric:
+		return numericChars, nil
+	case FormatAlphanumeric:
+		return alphanumericChars, nil
+	}
+	return "", errors.Newf(ErrInvalidPin, "unknown pin format: %s", p.Format)
+}
+
+// Equal compares a PIN given on join with the room PIN in constant time
+func Equal(given, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
@@ -0,0 +1,75 @@
+package pin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	testCases := []struct {
+		format Format
+		length int
+	}{
+		{FormatHex, 6},
+		{FormatNumeric, 4},
+		{FormatNumeric, 8},
+		{FormatAlphanumeric, 6},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.format), func(t *testing.T) {
+			p := &Policy{Format: tc.format, Length: tc.length}
+			for range 20 {
+				pin, err := p.Generate()
+				require.NoError(t, err)
+				assert.Len(t, pin, tc.length)
+				assert.NoError(t, p.Validate(pin))
+			}
+		})
+	}
+}
+
+func TestGenerate_InvalidPolicy(t *testing.T) {
+	_, err := (&Policy{Format: "emoji", Length: 6}).Generate()
+	require.ErrorIs(t, err, ErrInvalidPin)
+
+	_, err = (&Policy{Format: FormatHex, Length: 0}).Generate()
+	require.ErrorIs(t, err, ErrInvalidPin)
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy Policy
+		pin    string
+		valid  bool
+	}{
+		{"hex", Policy{FormatHex, 6}, "a1b2c3", true},
+		{"hex uppercase", Policy{FormatHex, 6}, "A1B2C3", false},
+		{"hex too short", Policy{FormatHex, 6}, "a1b2c", false},
+		{"numeric", Policy{FormatNumeric, 4}, "0042", true},
+		{"numeric with letter", Policy{FormatNumeric, 4}, "004a", false},
+		{"alphanumeric mixed case", Policy{FormatAlphanumeric, 6}, "Ab12Cd", true},
+		{"alphanumeric symbol", Policy{FormatAlphanumeric, 6}, "Ab12C!", false},
+		{"unknown format", Policy{"emoji", 6}, "123456", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.Validate(tc.pin)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidPin)
+			}
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	assert.True(t, Equal("1234", "1234"))
+	assert.False(t, Equal("1235", "1234"))
+	assert.False(t, Equal("123", "1234"))
+}
@@ -4,9 +4,9 @@ import (
 	"context"
 	"time"
 
-	"github.com/cenkalti/backoff/v4"
 	"github.com/redis/go-redis/v9"
 
+	"github.com/imtaco/audio-rtc-exp/internal/backoff"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 )
 
@@ -21,13 +21,15 @@ type Forever interface {
 	HGetAll(ctx context.Context, key string) (map[string]string, error)
 	HDel(ctx context.Context, key string, fields ...string) error
 	EvalSha(ctx context.Context, sha1 string, keys []string, args ...any) (any, error)
+	// Pipelined sends the commands queued by fn in one round trip, all of them are sent again
+	// on failure so they must be idempotent
+	Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) error
 }
 
 type redisForeverImpl struct {
-	client          redis.UniversalClient
-	logger          *log.Logger
-	initialInterval time.Duration
-	maxInterval     time.Duration
+	client redis.UniversalClient
+	logger *log.Logger
+	policy backoff.Policy
 }
 
 // NewForever creates a new Redis utility with forever backoff retry logic.
@@ -53,23 +55,13 @@ func NewForever(
 	}
 
 	return &redisForeverImpl{
-		client:          client,
-		logger:          logger,
-		initialInterval: initialInterval,
-		maxInterval:     maxInterval,
+		client: client,
+		logger: logger,
+		policy: backoff.Exponential{Initial: initialInterval, Max: maxInterval, Jitter: 0.2},
 	}
 }
 
-// newForeverBackoff creates a new exponential backoff that retries forever.
-func (r *redisForeverImpl) newForeverBackoff() backoff.BackOff {
-	b := backoff.NewExponentialBackOff()
-	b.InitialInterval = r.initialInterval
-	b.MaxInterval = r.maxInterval
-	b.MaxElapsedTime = 0 // 0 means forever
-	return b
-}
-
-// retryWithBackoff tries operation once first, only creates backoff object if first attempt fails.
+// retryWithBackoff tries operation once first, only enters the retry loop if first attempt fails.
 // This optimizes for the common case where Redis operations succeed on first try.
 func (r *redisForeverImpl) retryWithBackoff(ctx context.Context, operation func() error, operationName string) error {
 	// Fast path: try once without backoff overhead
@@ -83,16 +75,9 @@ func (r *redisForeverImpl) retryWithBackoff(ctx context.Context, operation func(
 		log.String("operation", operationName),
 		log.Error(err))
 
-	b := r.newForeverBackoff()
 	attempt := 1 // First attempt already done
 
-	return backoff.Retry(func() error {
-		select {
-		case <-ctx.Done():
-			return backoff.Permanent(ctx.Err())
-		default:
-		}
-
+	return backoff.Retry(ctx, r.policy, func(context.Context) error {
 		attempt++
 		err := operation()
 		if err != nil {
@@ -107,7 +92,7 @@ func (r *redisForeverImpl) retryWithBackoff(ctx context.Context, operation func(
 			log.String("operation", operationName),
 			log.Int("total_attempts", attempt))
 		return nil
-	}, backoff.WithContext(b, ctx))
+	})
 }
 
 func (r *redisForeverImpl) Get(ctx context.Context, key string) (string, error) {
@@ -185,3 +170,10 @@ func (r *redisForeverImpl) EvalSha(ctx context.Context, sha1 string, keys []stri
 	}, "EvalSha")
 	return result, err
 }
+
+func (r *redisForeverImpl) Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) error {
+	return r.retryWithBackoff(ctx, func() error {
+		_, err := r.client.Pipelined(ctx, fn)
+		return err
+	}, "Pipelined")
+}
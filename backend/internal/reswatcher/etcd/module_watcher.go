@@ -55,7 +55,7 @@ func NewHealthyModuleWatcher(
 	cfg := etcdwatcher.Config[etcdstate.ModuleState]{
 		Client:           etcdClient,
 		PrefixToWatch:    prefix,
-		AllowedKeyTypes:  []string{constants.ModuleKeyHeartbeat, constants.ModuleKeyMark},
+		AllowedKeyTypes:  []string{constants.ModuleKeyHeartbeat, constants.ModuleKeyMark, constants.ModuleKeyDegraded},
 		Logger:           logger,
 		ProcessChange:    w.processChange,
 		StateTransformer: w,
@@ -138,12 +138,19 @@ func (w *healthModuleWatcherImpl) NewState(
 		curState = &etcdstate.ModuleState{}
 	}
 
+	var err error
 	switch keyType {
 	case constants.ModuleKeyHeartbeat:
-		curState.SetHeartbeat(etcdwatcher.ParseValue[etcdstate.HeartbeatData](data))
+		err = decodeInto(keyType, data, curState.SetHeartbeat)
 
 	case constants.ModuleKeyMark:
-		curState.SetMark(etcdwatcher.ParseValue[etcdstate.MarkData](data))
+		err = decodeInto(keyType, data, curState.SetMark)
+
+	case constants.ModuleKeyDegraded:
+		err = decodeInto(keyType, data, curState.SetDegraded)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	if curState.IsEmpty() {
@@ -152,3 +159,13 @@ func (w *healthModuleWatcherImpl) NewState(
 	}
 	return curState, nil
 }
+
+// decodeInto decodes the value of keyType and passes it to set, nil for a deleted key
+func decodeInto[T any](keyType string, data []byte, set func(*T)) error {
+	value, err := etcdstate.Decode[T](keyType, data)
+	if err != nil {
+		return err
+	}
+	set(value)
+	return nil
+}
@@ -11,7 +11,3 @@ type HealthyModuleWatcher interface {
 	Get(id string) (etcdstate.ModuleState, bool)
 	GetAllHealthy() []string
 }
-
-type RoomWatcher interface {
-	watcher.Watcher[etcdstate.RoomState]
-}
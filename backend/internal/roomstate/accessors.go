@@ -0,0 +1,52 @@
+package roomstate
+
+import (
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+)
+
+// Cache is what the accessors read from, a Watcher or a consumer embedding one
+type Cache interface {
+	GetCachedState(roomID string) (*etcdstate.RoomState, bool)
+}
+
+// Get returns the cached state of the room, nil when unknown
+func Get(c Cache, roomID string) *etcdstate.RoomState {
+	state, _ := c.GetCachedState(roomID)
+	return state
+}
+
+func Meta(c Cache, roomID string) *etcdstate.Meta {
+	return Get(c, roomID).GetMeta()
+}
+
+func LiveMeta(c Cache, roomID string) *etcdstate.LiveMeta {
+	return Get(c, roomID).GetLiveMeta()
+}
+
+// OnAirLiveMeta returns the livemeta of the room while it is on air, nil otherwise
+func OnAirLiveMeta(c Cache, roomID string) *etcdstate.LiveMeta {
+	liveMeta := LiveMeta(c, roomID)
+	if liveMeta == nil || liveMeta.Status != constants.RoomStatusOnAir {
+		return nil
+	}
+	return liveMeta
+}
+
+func Janus(c Cache, roomID string) *etcdstate.Janus {
+	return Get(c, roomID).GetJanus()
+}
+
+func Mixer(c Cache, roomID string) *etcdstate.Mixer {
+	return Get(c, roomID).GetMixer()
+}
+
+func LinkedBy(c Cache, roomID string) *etcdstate.LinkedBy {
+	return Get(c, roomID).GetLinkedBy()
+}
+
+// Modules returns the Janus and mixer the room is live on, empty when it is not live
+func Modules(c Cache, roomID string) (janusID, mixerID string) {
+	liveMeta := LiveMeta(c, roomID)
+	return liveMeta.GetJanusID(), liveMeta.GetMixerID()
+}
@@ -1,9 +1,9 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/imtaco/audio-rtc-exp/internal/reswatcher/etcd (interfaces: RoomWatcher)
+// Source: github.com/imtaco/audio-rtc-exp/internal/roomstate (interfaces: Watcher)
 //
 // Generated by this command:
 //
-//	mockgen -destination=mocks/room_watcher.go -package=mocks github.com/imtaco/audio-rtc-exp/internal/reswatcher/etcd RoomWatcher
+//	mockgen -destination=mocks/watcher.go -package=mocks github.com/imtaco/audio-rtc-exp/internal/roomstate Watcher
 //
 
 // Package mocks is a generated GoMock package.
@@ -13,37 +13,36 @@ import (
 	context "context"
 	reflect "reflect"
 
-	gomock "go.uber.org/mock/gomock"
-
 	etcdstate "github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	gomock "go.uber.org/mock/gomock"
 )
 
-// MockRoomWatcher is a mock of RoomWatcher interface.
-type MockRoomWatcher struct {
+// MockWatcher is a mock of Watcher interface.
+type MockWatcher struct {
 	ctrl     *gomock.Controller
-	recorder *MockRoomWatcherMockRecorder
+	recorder *MockWatcherMockRecorder
 	isgomock struct{}
 }
 
-// MockRoomWatcherMockRecorder is the mock recorder for MockRoomWatcher.
-type MockRoomWatcherMockRecorder struct {
-	mock *MockRoomWatcher
+// MockWatcherMockRecorder is the mock recorder for MockWatcher.
+type MockWatcherMockRecorder struct {
+	mock *MockWatcher
 }
 
-// NewMockRoomWatcher creates a new mock instance.
-func NewMockRoomWatcher(ctrl *gomock.Controller) *MockRoomWatcher {
-	mock := &MockRoomWatcher{ctrl: ctrl}
-	mock.recorder = &MockRoomWatcherMockRecorder{mock}
+// NewMockWatcher creates a new mock instance.
+func NewMockWatcher(ctrl *gomock.Controller) *MockWatcher {
+	mock := &MockWatcher{ctrl: ctrl}
+	mock.recorder = &MockWatcherMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MockRoomWatcher) EXPECT() *MockRoomWatcherMockRecorder {
+func (m *MockWatcher) EXPECT() *MockWatcherMockRecorder {
 	return m.recorder
 }
 
 // GetCachedState mocks base method.
-func (m *MockRoomWatcher) GetCachedState(id string) (*etcdstate.RoomState, bool) {
+func (m *MockWatcher) GetCachedState(id string) (*etcdstate.RoomState, bool) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetCachedState", id)
 	ret0, _ := ret[0].(*etcdstate.RoomState)
@@ -52,25 +51,25 @@ func (m *MockRoomWatcher) GetCachedState(id string) (*etcdstate.RoomState, bool)
 }
 
 // GetCachedState indicates an expected call of GetCachedState.
-func (mr *MockRoomWatcherMockRecorder) GetCachedState(id any) *gomock.Call {
+func (mr *MockWatcherMockRecorder) GetCachedState(id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCachedState", reflect.TypeOf((*MockRoomWatcher)(nil).GetCachedState), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCachedState", reflect.TypeOf((*MockWatcher)(nil).GetCachedState), id)
 }
 
 // Restart mocks base method.
-func (m *MockRoomWatcher) Restart() {
+func (m *MockWatcher) Restart() {
 	m.ctrl.T.Helper()
 	m.ctrl.Call(m, "Restart")
 }
 
 // Restart indicates an expected call of Restart.
-func (mr *MockRoomWatcherMockRecorder) Restart() *gomock.Call {
+func (mr *MockWatcherMockRecorder) Restart() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restart", reflect.TypeOf((*MockRoomWatcher)(nil).Restart))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restart", reflect.TypeOf((*MockWatcher)(nil).Restart))
 }
 
 // Start mocks base method.
-func (m *MockRoomWatcher) Start(ctx context.Context) error {
+func (m *MockWatcher) Start(ctx context.Context) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Start", ctx)
 	ret0, _ := ret[0].(error)
@@ -78,13 +77,13 @@ func (m *MockRoomWatcher) Start(ctx context.Context) error {
 }
 
 // Start indicates an expected call of Start.
-func (mr *MockRoomWatcherMockRecorder) Start(ctx any) *gomock.Call {
+func (mr *MockWatcherMockRecorder) Start(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockRoomWatcher)(nil).Start), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockWatcher)(nil).Start), ctx)
 }
 
 // Stop mocks base method.
-func (m *MockRoomWatcher) Stop() error {
+func (m *MockWatcher) Stop() error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Stop")
 	ret0, _ := ret[0].(error)
@@ -92,7 +91,7 @@ func (m *MockRoomWatcher) Stop() error {
 }
 
 // Stop indicates an expected call of Stop.
-func (mr *MockRoomWatcherMockRecorder) Stop() *gomock.Call {
+func (mr *MockWatcherMockRecorder) Stop() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockRoomWatcher)(nil).Stop))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockWatcher)(nil).Stop))
 }
@@ -0,0 +1,147 @@
+package roomstate
+
+import (
+	"context"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/watcher"
+	etcdwatcher "github.com/imtaco/audio-rtc-exp/internal/watcher/etcd"
+)
+
+// Watcher caches the canonical RoomState of every room, assembled from the room keys
+type Watcher interface {
+	watcher.Watcher[etcdstate.RoomState]
+}
+
+// Rebuilder rebuilds what a consumer derives from the rooms whenever the watcher loads a fresh
+// snapshot, on start, restart and lost watches
+type Rebuilder interface {
+	RebuildStart(ctx context.Context) error
+	RebuildState(ctx context.Context, roomID string, state *etcdstate.RoomState) error
+	RebuildEnd(ctx context.Context) error
+}
+
+type Config struct {
+	Client etcd.Watcher
+	Prefix string
+	// KeyTypes are the room keys the consumer reads, other keys are ignored
+	KeyTypes []string
+	// OnChange is called with the state of a room after it changed, nil once the room is gone.
+	// Errors are retried with backoff
+	OnChange watcher.ProcessChangeFunc[etcdstate.RoomState]
+	// Rebuilder is optional
+	Rebuilder Rebuilder
+	Logger    *log.Logger
+}
+
+type watcherImpl struct {
+	watcher.Watcher[etcdstate.RoomState]
+	rebuilder Rebuilder
+}
+
+func New(cfg *Config) Watcher {
+	w := &watcherImpl{
+		rebuilder: cfg.Rebuilder,
+	}
+	onChange := cfg.OnChange
+	if onChange == nil {
+		onChange = func(context.Context, string, *etcdstate.RoomState) error { return nil }
+	}
+
+	w.Watcher = etcdwatcher.New(etcdwatcher.Config[etcdstate.RoomState]{
+		Client:           cfg.Client,
+		PrefixToWatch:    cfg.Prefix,
+		AllowedKeyTypes:  cfg.KeyTypes,
+		Logger:           cfg.Logger,
+		ProcessChange:    onChange,
+		StateTransformer: w,
+	})
+	return w
+}
+
+func (w *watcherImpl) RebuildStart(ctx context.Context) error {
+	if w.rebuilder == nil {
+		return nil
+	}
+	return w.rebuilder.RebuildStart(ctx)
+}
+
+func (w *watcherImpl) RebuildState(ctx context.Context, roomID string, state *etcdstate.RoomState) error {
+	if w.rebuilder == nil {
+		return nil
+	}
+	return w.rebuilder.RebuildState(ctx, roomID, state)
+}
+
+func (w *watcherImpl) RebuildEnd(ctx context.Context) error {
+	if w.rebuilder == nil {
+		return nil
+	}
+	return w.rebuilder.RebuildEnd(ctx)
+}
+
+func (*watcherImpl) NewState(
+	_ string,
+	keyType string,
+	data []byte,
+	curState *etcdstate.RoomState,
+) (*etcdstate.RoomState, error) {
+	return ApplyKey(keyType, data, curState)
+}
+
+// ApplyKey applies the value of a room key to curState, empty data clears the key.
+// It returns nil once the state holds no key.
+func ApplyKey(
+	keyType string,
+	data []byte,
+	curState *etcdstate.RoomState,
+) (*etcdstate.RoomState, error) {
+	if len(data) > 0 && curState == nil {
+		curState = &etcdstate.RoomState{}
+	}
+
+	var err error
+	switch keyType {
+	case constants.RoomKeyMeta:
+		err = decodeInto(keyType, data, curState.SetMeta)
+	case constants.RoomKeyLiveMeta:
+		err = decodeInto(keyType, data, curState.SetLiveMeta)
+	case constants.RoomKeyJanus:
+		err = decodeInto(keyType, data, curState.SetJanus)
+	case constants.RoomKeyMixer:
+		err = decodeInto(keyType, data, curState.SetMixer)
+	case constants.RoomKeyLink:
+		err = decodeInto(keyType, data, curState.SetLink)
+	case constants.RoomKeyLinkedBy:
+		err = decodeInto(keyType, data, curState.SetLinkedBy)
+	case constants.RoomKeyQuality:
+		err = decodeInto(keyType, data, curState.SetQuality)
+	case constants.RoomKeyLatency:
+		err = decodeInto(keyType, data, curState.SetLatency)
+	case constants.RoomKeyAnchors:
+		err = decodeInto(keyType, data, curState.SetAnchors)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if curState.IsEmpty() {
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	return curState, nil
+}
+
+// decodeInto decodes the value of keyType and passes it to set, nil for a deleted key
+func decodeInto[T any](keyType string, data []byte, set func(*T)) error {
+	value, err := etcdstate.Decode[T](keyType, data)
+	if err != nil {
+		return err
+	}
+	set(value)
+	return nil
+}
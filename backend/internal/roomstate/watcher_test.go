@@ -0,0 +1,125 @@
+package roomstate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+)
+
+func TestApplyKey(t *testing.T) {
+	t.Run("creates the state", func(t *testing.T) {
+		state, err := ApplyKey(constants.RoomKeyMeta, []byte(`{"pin":"1234","hlsPath":"/hls/room-1"}`), nil)
+		require.NoError(t, err)
+		require.NotNil(t, state.Meta)
+		assert.Equal(t, "1234", state.Meta.Pin)
+	})
+
+	t.Run("sets each key", func(t *testing.T) {
+		state := &etcdstate.RoomState{}
+		for keyType, data := range map[string]string{
+			constants.RoomKeyLiveMeta: `{"janusID":"janus-1","mixerID":"mixer-1","status":"on-air"}`,
+			constants.RoomKeyJanus:    `{"janusRoomId":1}`,
+			constants.RoomKeyMixer:    `{"id":"mixer-1"}`,
+			constants.RoomKeyLinkedBy: `{}`,
+		} {
+			var err error
+			state, err = ApplyKey(keyType, []byte(data), state)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, "janus-1", state.GetLiveMeta().GetJanusID())
+		assert.NotNil(t, state.Janus)
+		assert.NotNil(t, state.Mixer)
+		assert.NotNil(t, state.LinkedBy)
+	})
+
+	t.Run("returns nil once empty", func(t *testing.T) {
+		state := &etcdstate.RoomState{Meta: &etcdstate.Meta{Pin: "1234"}}
+		state, err := ApplyKey(constants.RoomKeyMeta, nil, state)
+		require.NoError(t, err)
+		assert.Nil(t, state)
+	})
+
+	t.Run("rejects invalid documents", func(t *testing.T) {
+		_, err := ApplyKey(constants.RoomKeyMeta, []byte(`{`), nil)
+		assert.Error(t, err)
+	})
+}
+
+type cacheMap map[string]*etcdstate.RoomState
+
+func (c cacheMap) GetCachedState(roomID string) (*etcdstate.RoomState, bool) {
+	state, ok := c[roomID]
+	return state, ok
+}
+
+func TestAccessors(t *testing.T) {
+	cache := cacheMap{
+		"onair": {
+			Meta:     &etcdstate.Meta{Pin: "1234"},
+			LiveMeta: &etcdstate.LiveMeta{Status: constants.RoomStatusOnAir, JanusID: "janus-1", MixerID: "mixer-1"},
+			Janus:    &etcdstate.Janus{JanusRoomID: 7},
+		},
+		"idle": {
+			LiveMeta: &etcdstate.LiveMeta{Status: constants.RoomStatusRemoving, JanusID: "janus-1"},
+		},
+	}
+
+	assert.Equal(t, "1234", Meta(cache, "onair").Pin)
+	assert.NotNil(t, OnAirLiveMeta(cache, "onair"))
+	assert.Nil(t, OnAirLiveMeta(cache, "idle"))
+	assert.Equal(t, int64(7), Janus(cache, "onair").GetJanusRoomID())
+
+	janusID, mixerID := Modules(cache, "onair")
+	assert.Equal(t, "janus-1", janusID)
+	assert.Equal(t, "mixer-1", mixerID)
+
+	// unknown rooms read as nil
+	assert.Nil(t, Get(cache, "unknown"))
+	assert.Nil(t, Meta(cache, "unknown"))
+	assert.Nil(t, Mixer(cache, "unknown"))
+	assert.Nil(t, LinkedBy(cache, "unknown"))
+	janusID, mixerID = Modules(cache, "unknown")
+	assert.Empty(t, janusID)
+	assert.Empty(t, mixerID)
+}
+
+type recordingRebuilder struct {
+	calls []string
+}
+
+func (r *recordingRebuilder) RebuildStart(context.Context) error {
+	r.calls = append(r.calls, "start")
+	return nil
+}
+
+func (r *recordingRebuilder) RebuildState(_ context.Context, roomID string, _ *etcdstate.RoomState) error {
+	r.calls = append(r.calls, roomID)
+	return nil
+}
+
+func (r *recordingRebuilder) RebuildEnd(context.Context) error {
+	r.calls = append(r.calls, "end")
+	return nil
+}
+
+func TestWatcherRebuilder(t *testing.T) {
+	ctx := context.Background()
+
+	// rebuild hooks are optional
+	w := New(&Config{Prefix: "/rooms/"}).(*watcherImpl)
+	require.NoError(t, w.RebuildStart(ctx))
+	require.NoError(t, w.RebuildState(ctx, "room1", nil))
+	require.NoError(t, w.RebuildEnd(ctx))
+
+	rebuilder := &recordingRebuilder{}
+	w = New(&Config{Prefix: "/rooms/", Rebuilder: rebuilder}).(*watcherImpl)
+	require.NoError(t, w.RebuildStart(ctx))
+	require.NoError(t, w.RebuildState(ctx, "room1", &etcdstate.RoomState{}))
+	require.NoError(t, w.RebuildEnd(ctx))
+	assert.Equal(t, []string{"start", "room1", "end"}, rebuilder.calls)
+}
@@ -0,0 +1,397 @@
+package sdp
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxSize bounds the SDP accepted from clients
+	MaxSize = 32 * 1024
+	// maxMedia bounds the m-lines of an SDP
+	maxMedia = 16
+
+	codecOpus = "opus"
+)
+
+// allowedExtensions are the RTP header extensions Janus AudioBridge makes use of, others are stripped
+var allowedExtensions = []string{
+	"urn:ietf:params:rtp-hdrext:ssrc-audio-level",
+	"urn:ietf:params:rtp-hdrext:sdes:mid",
+	"http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time",
+	"http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01",
+}
+
+// Error describes why an SDP was rejected, Line is 1-based and 0 when not tied to a line
+type Error struct {
+	Line   int    `json:"line,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+	}
+	return e.Reason
+}
+
+// Line is a single "<type>=<value>" SDP line
+type Line struct {
+	Type  byte
+	Value string
+	num   int // 1-based line number in the parsed SDP
+}
+
+func (l Line) String() string {
+	return string(l.Type) + "=" + l.Value
+}
+
+// attribute returns the name and value of an a= line, value is "" for flags
+func (l Line) attribute() (name, value string) {
+	name, value, _ = strings.Cut(l.Value, ":")
+	return name, value
+}
+
+// Media is an m= section, Lines excludes the m= line itself
+type Media struct {
+	Kind    string   // audio, video, application...
+	Port    string   // kept as is, "0" rejects the media
+	Proto   string   // e.g. UDP/TLS/RTP/SAVPF
+	Formats []string // payload types for RTP media
+	Lines   []Line
+	num     int
+}
+
+func (m *Media) mLine() string {
+	return fmt.Sprintf("m=%s %s %s %s", m.Kind, m.Port, m.Proto, strings.Join(m.Formats, " "))
+}
+
+// Mid returns the a=mid of the media, "" when absent
+func (m *Media) Mid() string {
+	for _, l := range m.Lines {
+		if name, value := l.attribute(); l.Type == 'a' && name == "mid" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Session is a parsed SDP
+type Session struct {
+	Lines []Line // session level lines
+	Media []*Media
+}
+
+// Parse checks the structure of an SDP: "<type>=<value>" lines starting with v=0, an
+// o= line and well-formed m= lines
+func Parse(raw string) (*Session, error) {
+	if len(raw) > MaxSize {
+		return nil, &Error{Reason: fmt.Sprintf("SDP exceeds %d bytes", MaxSize)}
+	}
+
+	s := &Session{}
+	var media *Media
+	hasOrigin := false
+	for i, text := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+		num := i + 1
+		if text == "" {
+			continue
+		}
+		if len(text) < 2 || text[1] != '=' || text[0] < 'a' || text[0] > 'z' {
+			return nil, &Error{Line: num, Reason: "malformed line"}
+		}
+		line := Line{Type: text[0], Value: text[2:], num: num}
+
+		if len(s.Lines) == 0 && media == nil {
+			if line.Type != 'v' || line.Value != "0" {
+				return nil, &Error{Line: num, Reason: "SDP must start with v=0"}
+			}
+		}
+
+		switch line.Type {
+		case 'o':
+			hasOrigin = true
+		case 'm':
+			fields := strings.Fields(line.Value)
+			if len(fields) < 4 {
+				return nil, &Error{Line: num, Reason: "malformed m= line"}
+			}
+			if len(s.Media) == maxMedia {
+				return nil, &Error{Line: num, Reason: fmt.Sprintf("more than %d m= lines", maxMedia)}
+			}
+			media = &Media{Kind: fields[0], Port: fields[1], Proto: fields[2], Formats: fields[3:], num: num}
+			s.Media = append(s.Media, media)
+			continue
+		}
+
+		if media != nil {
+			media.Lines = append(media.Lines, line)
+		} else {
+			s.Lines = append(s.Lines, line)
+		}
+	}
+
+	if len(s.Lines) == 0 {
+		return nil, &Error{Reason: "empty SDP"}
+	}
+	if !hasOrigin {
+		return nil, &Error{Reason: "missing o= line"}
+	}
+	return s, nil
+}
+
+// String serializes the session with CRLF line endings
+func (s *Session) String() string {
+	var b strings.Builder
+	for _, l := range s.Lines {
+		b.WriteString(l.String())
+		b.WriteString("\r\n")
+	}
+	for _, m := range s.Media {
+		b.WriteString(m.mLine())
+		b.WriteString("\r\n")
+		for _, l := range m.Lines {
+			b.WriteString(l.String())
+			b.WriteString("\r\n")
+		}
+	}
+	return b.String()
+}
+
+// SanitizeOffer validates a client offer and rewrites it for Janus AudioBridge:
+//   - audio keeps only its opus payload types, an offer without opus audio is rejected
+//   - other media is rejected with port 0 and stripped down to its mid, so the answer keeps
+//     the m-line order the client expects, and it leaves the BUNDLE group
+//   - RTP header extensions outside allowedExtensions are stripped
+func SanitizeOffer(raw string) (string, error) {
+	s, err := Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	var rejectedMids []string
+	hasOpus := false
+	for _, m := range s.Media {
+		if m.Kind != "audio" {
+			if mid := m.Mid(); mid != "" {
+				rejectedMids = append(rejectedMids, mid)
+			}
+			rejectMedia(m)
+			continue
+		}
+		if err := keepOpus(m); err != nil {
+			return "", err
+		}
+		if m.Port != "0" {
+			hasOpus = true
+		}
+	}
+	if !hasOpus {
+		return "", &Error{Reason: "no opus audio m= line"}
+	}
+
+	s.Lines = slices.DeleteFunc(s.Lines, unexpectedExtension)
+	if len(rejectedMids) > 0 {
+		for i, l := range s.Lines {
+			if name, value := l.attribute(); l.Type == 'a' && name == "group" {
+				s.Lines[i].Value = "group:" + removeMids(value, rejectedMids)
+			}
+		}
+	}
+	return s.String(), nil
+}
+
+// rejectMedia disables a media section, only its mid is kept for the answer to refer to
+func rejectMedia(m *Media) {
+	m.Port = "0"
+	m.Lines = slices.DeleteFunc(m.Lines, func(l Line) bool {
+		name, _ := l.attribute()
+		return l.Type != 'a' || name != "mid"
+	})
+	if len(m.Formats) > 1 {
+		m.Formats = m.Formats[:1]
+	}
+}
+
+// opusPayloadTypes returns the payload types an audio media maps to opus
+func opusPayloadTypes(m *Media) (map[string]bool, error) {
+	opus := make(map[string]bool)
+	for _, l := range m.Lines {
+		name, value := l.attribute()
+		if l.Type != 'a' || name != "rtpmap" {
+			continue
+		}
+		pt, encoding, ok := strings.Cut(value, " ")
+		if !ok {
+			return nil, &Error{Line: l.num, Reason: "malformed rtpmap"}
+		}
+		codec, _, _ := strings.Cut(encoding, "/")
+		if strings.EqualFold(codec, codecOpus) {
+			opus[pt] = true
+		}
+	}
+	return opus, nil
+}
+
+// keepOpus drops the payload types of an audio media other than opus, with their attributes
+func keepOpus(m *Media) error {
+	opus, err := opusPayloadTypes(m)
+	if err != nil {
+		return err
+	}
+	if len(opus) == 0 {
+		return &Error{Line: m.num, Reason: "audio m= line without opus"}
+	}
+
+	m.Formats = slices.DeleteFunc(m.Formats, func(pt string) bool { return !opus[pt] })
+	m.Lines = slices.DeleteFunc(m.Lines, func(l Line) bool {
+		if unexpectedExtension(l) {
+			return true
+		}
+		name, value := l.attribute()
+		if l.Type != 'a' {
+			return false
+		}
+		switch name {
+		case "rtpmap", "fmtp", "rtcp-fb":
+			pt, _, _ := strings.Cut(value, " ")
+			return pt != "*" && !opus[pt]
+		}
+		return false
+	})
+	return nil
+}
+
+// OpusParams are the Opus fmtp parameters an answer asks the offerer to encode with, nil and
+// zero fields keep what the answer says
+type OpusParams struct {
+	FEC               *bool // useinbandfec
+	DTX               *bool // usedtx
+	MaxAverageBitrate int   // maxaveragebitrate in bps
+	Stereo            *bool // stereo
+}
+
+// Empty reports whether p changes nothing
+func (p OpusParams) Empty() bool {
+	return p.FEC == nil && p.DTX == nil && p.MaxAverageBitrate == 0 && p.Stereo == nil
+}
+
+// ApplyOpusParams rewrites the a=fmtp lines of the opus payload types of an answer with p,
+// adding them where missing
+func ApplyOpusParams(raw string, p OpusParams) (string, error) {
+	s, err := Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	set := make([][2]string, 0, 4)
+	flag := func(key string, v *bool) {
+		if v == nil {
+			return
+		}
+		value := "0"
+		if *v {
+			value = "1"
+		}
+		set = append(set, [2]string{key, value})
+	}
+	flag("useinbandfec", p.FEC)
+	flag("usedtx", p.DTX)
+	if p.MaxAverageBitrate > 0 {
+		set = append(set, [2]string{"maxaveragebitrate", strconv.Itoa(p.MaxAverageBitrate)})
+	}
+	flag("stereo", p.Stereo)
+
+	for _, m := range s.Media {
+		if m.Kind != "audio" || m.Port == "0" {
+			continue
+		}
+		opus, err := opusPayloadTypes(m)
+		if err != nil {
+			return "", err
+		}
+		for _, pt := range m.Formats {
+			if opus[pt] {
+				setFmtp(m, pt, set)
+			}
+		}
+	}
+	return s.String(), nil
+}
+
+// setFmtp sets params on the a=fmtp line of a payload type, the line is added after its
+// rtpmap when missing
+func setFmtp(m *Media, pt string, params [][2]string) {
+	at := -1
+	for i, l := range m.Lines {
+		name, value := l.attribute()
+		if l.Type != 'a' {
+			continue
+		}
+		linePT, _, _ := strings.Cut(value, " ")
+		if linePT != pt {
+			continue
+		}
+		if name == "fmtp" {
+			m.Lines[i].Value = "fmtp:" + pt + " " + mergeFmtp(strings.TrimPrefix(value, pt+" "), params)
+			return
+		}
+		if name == "rtpmap" {
+			at = i + 1
+		}
+	}
+	if at < 0 {
+		at = len(m.Lines)
+	}
+	m.Lines = slices.Insert(m.Lines, at, Line{Type: 'a', Value: "fmtp:" + pt + " " + mergeFmtp("", params)})
+}
+
+// mergeFmtp sets params in a "key=value;key=value" fmtp parameter list, keeping the order
+// of the existing ones
+func mergeFmtp(list string, params [][2]string) string {
+	var fields []string
+	if list = strings.TrimSpace(list); list != "" {
+		fields = strings.Split(list, ";")
+	}
+	for _, param := range params {
+		found := false
+		for i, field := range fields {
+			key, _, _ := strings.Cut(strings.TrimSpace(field), "=")
+			if strings.EqualFold(key, param[0]) {
+				fields[i] = param[0] + "=" + param[1]
+				found = true
+			}
+		}
+		if !found {
+			fields = append(fields, param[0]+"="+param[1])
+		}
+	}
+	return strings.Join(fields, ";")
+}
+
+// unexpectedExtension reports an a=extmap line of an extension not in allowedExtensions
+func unexpectedExtension(l Line) bool {
+	name, value := l.attribute()
+	if l.Type != 'a' || name != "extmap" {
+		return false
+	}
+	fields := strings.Fields(value)
+	return len(fields) < 2 || !slices.Contains(allowedExtensions, fields[1])
+}
+
+// removeMids removes mids from an a=group value ("BUNDLE 0 1")
+func removeMids(group string, mids []string) string {
+	fields := strings.Fields(group)
+	if len(fields) == 0 {
+		return group
+	}
+	kept := fields[:1]
+	for _, mid := range fields[1:] {
+		if !slices.Contains(mids, mid) {
+			kept = append(kept, mid)
+		}
+	}
+	return strings.Join(kept, " ")
+}
@@ -0,0 +1,144 @@
+package sdp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const browserOffer = "v=0\r\n" +
+	"o=- 4611731400430051336 2 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"t=0 0\r\n" +
+	"a=group:BUNDLE 0 1\r\n" +
+	"a=extmap-allow-mixed\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111 63 0 126\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:0\r\n" +
+	"a=extmap:1 urn:ietf:params:rtp-hdrext:ssrc-audio-level\r\n" +
+	"a=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid\r\n" +
+	"a=extmap:9 urn:example:tracking\r\n" +
+	"a=sendrecv\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n" +
+	"a=rtcp-fb:111 transport-cc\r\n" +
+	"a=fmtp:111 minptime=10;useinbandfec=1\r\n" +
+	"a=rtpmap:63 red/48000/2\r\n" +
+	"a=fmtp:63 111/111\r\n" +
+	"a=rtpmap:0 PCMU/8000\r\n" +
+	"a=rtpmap:126 telephone-event/8000\r\n" +
+	"m=video 9 UDP/TLS/RTP/SAVPF 96 97\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:1\r\n" +
+	"a=extmap:2 urn:ietf:params:rtp-hdrext:toffset\r\n" +
+	"a=rtpmap:96 VP8/90000\r\n" +
+	"a=rtpmap:97 rtx/90000\r\n"
+
+func TestSanitizeOffer(t *testing.T) {
+	sanitized, err := SanitizeOffer(browserOffer)
+	require.NoError(t, err)
+
+	assert.Equal(t, "v=0\r\n"+
+		"o=- 4611731400430051336 2 IN IP4 127.0.0.1\r\n"+
+		"s=-\r\n"+
+		"t=0 0\r\n"+
+		"a=group:BUNDLE 0\r\n"+
+		"a=extmap-allow-mixed\r\n"+
+		"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n"+
+		"c=IN IP4 0.0.0.0\r\n"+
+		"a=mid:0\r\n"+
+		"a=extmap:1 urn:ietf:params:rtp-hdrext:ssrc-audio-level\r\n"+
+		"a=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid\r\n"+
+		"a=sendrecv\r\n"+
+		"a=rtpmap:111 opus/48000/2\r\n"+
+		"a=rtcp-fb:111 transport-cc\r\n"+
+		"a=fmtp:111 minptime=10;useinbandfec=1\r\n"+
+		"m=video 0 UDP/TLS/RTP/SAVPF 96\r\n"+
+		"a=mid:1\r\n", sanitized)
+}
+
+func TestSanitizeOffer_AcceptsLF(t *testing.T) {
+	sanitized, err := SanitizeOffer(strings.ReplaceAll(browserOffer, "\r\n", "\n"))
+	require.NoError(t, err)
+	assert.Contains(t, sanitized, "m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n")
+}
+
+func TestSanitizeOffer_Rejects(t *testing.T) {
+	tests := []struct {
+		name string
+		sdp  string
+		line int
+	}{
+		{name: "empty", sdp: ""},
+		{name: "not starting with version", sdp: "o=- 1 1 IN IP4 0.0.0.0\r\nv=0\r\n", line: 1},
+		{name: "malformed line", sdp: "v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\ngarbage\r\n", line: 3},
+		{name: "missing origin", sdp: "v=0\r\ns=-\r\nm=audio 9 RTP/SAVPF 111\r\na=rtpmap:111 opus/48000/2\r\n"},
+		{name: "malformed m line", sdp: "v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\nm=audio 9\r\n", line: 3},
+		{name: "no audio", sdp: "v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\nm=video 9 RTP/SAVPF 96\r\na=rtpmap:96 VP8/90000\r\n"},
+		{name: "audio without opus", sdp: "v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\nm=audio 9 RTP/SAVPF 0\r\na=rtpmap:0 PCMU/8000\r\n", line: 3},
+		{name: "malformed rtpmap", sdp: "v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\nm=audio 9 RTP/SAVPF 111\r\na=rtpmap:111\r\n", line: 4},
+		{name: "too large", sdp: "v=0\r\n" + strings.Repeat("a=x\r\n", MaxSize/5+1)},
+		{name: "too many m lines", sdp: "v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\n" + strings.Repeat("m=audio 9 RTP/SAVPF 111\r\n", maxMedia+1), line: 3 + maxMedia},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SanitizeOffer(tt.sdp)
+			require.Error(t, err)
+
+			var sdpErr *Error
+			require.ErrorAs(t, err, &sdpErr)
+			assert.Equal(t, tt.line, sdpErr.Line)
+			assert.NotEmpty(t, sdpErr.Reason)
+		})
+	}
+}
+
+func TestParse_RoundTrip(t *testing.T) {
+	s, err := Parse(browserOffer)
+	require.NoError(t, err)
+	assert.Equal(t, browserOffer, s.String())
+	require.Len(t, s.Media, 2)
+	assert.Equal(t, "0", s.Media[0].Mid())
+	assert.Equal(t, "video", s.Media[1].Kind)
+}
+
+const janusAnswer = "v=0\r\n" +
+	"o=- 1 2 IN IP4 127.0.0.1\r\n" +
+	"s=AudioBridge\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:0\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n" +
+	"a=fmtp:111 minptime=10;useinbandfec=1\r\n" +
+	"a=sendrecv\r\n"
+
+func TestApplyOpusParams(t *testing.T) {
+	on, off := true, false
+
+	answer, err := ApplyOpusParams(janusAnswer, OpusParams{FEC: &off, DTX: &on, MaxAverageBitrate: 24000, Stereo: &on})
+	require.NoError(t, err)
+	assert.Contains(t, answer, "a=fmtp:111 minptime=10;useinbandfec=0;usedtx=1;maxaveragebitrate=24000;stereo=1\r\n")
+	assert.Equal(t, 1, strings.Count(answer, "a=fmtp:"))
+
+	// unset fields keep the answer as is
+	answer, err = ApplyOpusParams(janusAnswer, OpusParams{DTX: &off})
+	require.NoError(t, err)
+	assert.Contains(t, answer, "a=fmtp:111 minptime=10;useinbandfec=1;usedtx=0\r\n")
+}
+
+func TestApplyOpusParams_AddsFmtp(t *testing.T) {
+	on := true
+	noFmtp := strings.Replace(janusAnswer, "a=fmtp:111 minptime=10;useinbandfec=1\r\n", "", 1)
+
+	answer, err := ApplyOpusParams(noFmtp, OpusParams{FEC: &on})
+	require.NoError(t, err)
+	assert.Contains(t, answer, "a=rtpmap:111 opus/48000/2\r\na=fmtp:111 useinbandfec=1\r\n")
+}
+
+func TestApplyOpusParams_Invalid(t *testing.T) {
+	_, err := ApplyOpusParams("m=audio 9 RTP/AVP 111", OpusParams{})
+	assert.Error(t, err)
+}
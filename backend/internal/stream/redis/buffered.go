@@ -0,0 +1,286 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/backoff"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+var (
+	// ErrBufferFull is returned by a buffered producer when entries come faster than Redis takes them
+	ErrBufferFull = errors.New("stream producer buffer is full")
+	// ErrProducerClosed is returned by a buffered producer once closed
+	ErrProducerClosed = errors.New("stream producer is closed")
+)
+
+// BufferConfig configures a buffered producer, entries are added in batches by a background
+// loop and retried until added, so each entry is added at least once unless the retry gives up
+type BufferConfig struct {
+	// Size of the buffer, 0 adds each entry synchronously
+	Size      int `mapstructure:"size"`
+	BatchSize int `mapstructure:"batch_size"`
+	// FlushInterval bounds how long an entry waits for its batch to fill
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	// RetryTimeout bounds how long a failing batch is retried before its entries are dropped
+	RetryTimeout time.Duration `mapstructure:"retry_timeout"`
+	// CloseTimeout bounds flushing the buffer on close
+	CloseTimeout time.Duration `mapstructure:"close_timeout"`
+}
+
+func SetupBuffer(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("size"), 0)
+	v.SetDefault(p("batch_size"), 100)
+	v.SetDefault(p("flush_interval"), "10ms")
+	v.SetDefault(p("retry_timeout"), "30s")
+	v.SetDefault(p("close_timeout"), "5s")
+}
+
+// Enabled reports whether entries are buffered
+func (c *BufferConfig) Enabled() bool {
+	return c != nil && c.Size > 0
+}
+
+// Validator checks the values of an entry before it is buffered
+type Validator func(values map[string]any) error
+
+// BufferedProducer is a producer adding entries in the background, Add returns once the entry is
+// buffered with an empty ID. Close flushes the buffered entries
+type BufferedProducer interface {
+	Producer
+	Open(ctx context.Context) error
+	Close() error
+}
+
+type bufferedEntry struct {
+	id     string
+	values map[string]any
+}
+
+type bufferedProducerImpl struct {
+	client   *redis.Client
+	stream   string
+	cfg      BufferConfig
+	validate Validator
+	entries  chan *bufferedEntry
+	mu       sync.RWMutex // guards closed against adding to a closed channel
+	opened   bool
+	closed   bool
+	done     chan struct{}
+	logger   *log.Logger
+}
+
+// NewBufferedProducer creates a producer buffering up to cfg.Size entries, validate may be nil
+func NewBufferedProducer(
+	client *redis.Client,
+	stream string,
+	cfg *BufferConfig,
+	validate Validator,
+	logger *log.Logger,
+) (BufferedProducer, error) {
+	if client == nil {
+		return nil, fmt.Errorf("redis client is required")
+	}
+	if stream == "" {
+		return nil, fmt.Errorf("stream name is required")
+	}
+	if logger == nil {
+		return nil, fmt.Errorf("logger is required")
+	}
+	if !cfg.Enabled() {
+		return nil, fmt.Errorf("buffer size is required")
+	}
+
+	c := *cfg
+	c.BatchSize = max(c.BatchSize, 1)
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = 10 * time.Millisecond
+	}
+	if c.CloseTimeout <= 0 {
+		c.CloseTimeout = 5 * time.Second
+	}
+
+	return &bufferedProducerImpl{
+		client:   client,
+		stream:   stream,
+		cfg:      c,
+		validate: validate,
+		entries:  make(chan *bufferedEntry, c.Size),
+		done:     make(chan struct{}),
+		logger:   logger,
+	}, nil
+}
+
+func (bp *bufferedProducerImpl) Open(_ context.Context) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if bp.closed {
+		return ErrProducerClosed
+	}
+	if !bp.opened {
+		bp.opened = true
+		go bp.loop()
+	}
+	return nil
+}
+
+// Close stops taking entries and flushes the buffered ones
+func (bp *bufferedProducerImpl) Close() error {
+	bp.mu.Lock()
+	if bp.closed {
+		bp.mu.Unlock()
+		return nil
+	}
+	bp.closed = true
+	close(bp.entries)
+	opened := bp.opened
+	bp.mu.Unlock()
+
+	if opened {
+		<-bp.done
+	}
+	return nil
+}
+
+func (bp *bufferedProducerImpl) Add(_ context.Context, values map[string]any) (string, error) {
+	return "", bp.enqueue(&bufferedEntry{values: values})
+}
+
+func (bp *bufferedProducerImpl) AddWithID(_ context.Context, id string, values map[string]any) error {
+	return bp.enqueue(&bufferedEntry{id: id, values: values})
+}
+
+func (bp *bufferedProducerImpl) enqueue(entry *bufferedEntry) error {
+	if bp.validate != nil {
+		if err := bp.validate(entry.values); err != nil {
+			return fmt.Errorf("invalid stream entry: %w", err)
+		}
+	}
+
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+	if bp.closed {
+		return ErrProducerClosed
+	}
+	select {
+	case bp.entries <- entry:
+		return nil
+	default:
+		return ErrBufferFull
+	}
+}
+
+func (bp *bufferedProducerImpl) loop() {
+	defer close(bp.done)
+
+	ticker := time.NewTicker(bp.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	ctx := context.Background()
+	batch := make([]*bufferedEntry, 0, bp.cfg.BatchSize)
+	for {
+		select {
+		case entry, ok := <-bp.entries:
+			if !ok {
+				// closed, the channel is drained so batch holds the last entries
+				if len(batch) > 0 {
+					closeCtx, cancel := context.WithTimeout(ctx, bp.cfg.CloseTimeout)
+					bp.flush(closeCtx, batch)
+					cancel()
+				}
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= bp.cfg.BatchSize {
+				bp.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				bp.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush adds the batch in a pipeline, entries failing on transient errors are retried
+func (bp *bufferedProducerImpl) flush(ctx context.Context, batch []*bufferedEntry) {
+	policy := backoff.Exponential{Initial: 50 * time.Millisecond, Max: 2 * time.Second, Jitter: 0.2}
+
+	pending := batch
+	err := backoff.Retry(ctx, policy, func(ctx context.Context) error {
+		var err error
+		pending, err = bp.add(ctx, pending)
+		return err
+	}, backoff.WithMaxElapsed(bp.cfg.RetryTimeout),
+		backoff.WithOnRetry(func(_ int, err error, _ time.Duration) {
+			bp.logger.Warn("Failed to add buffered entries to stream, retrying",
+				log.String("stream", bp.stream),
+				log.Int("pending", len(pending)),
+				log.Error(err))
+		}))
+
+	if err != nil {
+		bp.logger.Error("Dropped buffered stream entries",
+			log.String("stream", bp.stream),
+			log.Int("dropped", len(pending)),
+			log.Error(err))
+	}
+}
+
+// add returns the entries failed on transient errors, entries rejected by Redis are dropped
+func (bp *bufferedProducerImpl) add(ctx context.Context, entries []*bufferedEntry) ([]*bufferedEntry, error) {
+	pipe := bp.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(entries))
+	for i, entry := range entries {
+		cmds[i] = pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: bp.stream,
+			ID:     entry.id,
+			Values: entry.values,
+		})
+	}
+	// per command errors are checked below, commands not sent, e.g. on dial errors, have none
+	_, execErr := pipe.Exec(ctx)
+
+	var failed []*bufferedEntry
+	var lastErr error
+	for i, cmd := range cmds {
+		err := cmd.Err()
+		if err == nil && cmd.Val() != "" {
+			continue
+		}
+		if err == nil {
+			if err = execErr; err == nil {
+				continue
+			}
+		}
+		var redisErr redis.Error
+		if errors.As(err, &redisErr) {
+			bp.logger.Error("Stream entry rejected",
+				log.String("stream", bp.stream),
+				log.String("id", entries[i].id),
+				log.Error(err))
+			continue
+		}
+		failed = append(failed, entries[i])
+		lastErr = err
+	}
+
+	if lastErr != nil {
+		return failed, lastErr
+	}
+	bp.logger.Debug("Added buffered entries to stream",
+		log.String("stream", bp.stream),
+		log.Int("count", len(entries)))
+	return nil, nil
+}
@@ -0,0 +1,147 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type BufferedProducerSuite struct {
+	suite.Suite
+	mr     *miniredis.Miniredis
+	client *redis.Client
+	ctx    context.Context
+}
+
+func TestBufferedProducerSuite(t *testing.T) {
+	suite.Run(t, new(BufferedProducerSuite))
+}
+
+func (s *BufferedProducerSuite) SetupTest() {
+	s.mr = miniredis.RunT(s.T())
+	s.client = redis.NewClient(&redis.Options{Addr: s.mr.Addr(), MaxRetries: -1})
+	s.ctx = context.Background()
+}
+
+func (s *BufferedProducerSuite) TearDownTest() {
+	_ = s.client.Close()
+}
+
+func (s *BufferedProducerSuite) newProducer(cfg *BufferConfig, validate Validator) BufferedProducer {
+	p, err := NewBufferedProducer(s.client, "buffered", cfg, validate, log.NewNop())
+	s.Require().NoError(err)
+	s.Require().NoError(p.Open(s.ctx))
+	return p
+}
+
+func (s *BufferedProducerSuite) streamLen() int64 {
+	return s.client.XLen(s.ctx, "buffered").Val()
+}
+
+func (s *BufferedProducerSuite) TestNewBufferedProducerRequiresSize() {
+	_, err := NewBufferedProducer(s.client, "buffered", &BufferConfig{}, nil, log.NewNop())
+	s.ErrorContains(err, "buffer size is required")
+
+	_, err = NewBufferedProducer(s.client, "buffered", nil, nil, log.NewNop())
+	s.Error(err)
+}
+
+func (s *BufferedProducerSuite) TestFlushesBatches() {
+	p := s.newProducer(&BufferConfig{Size: 10, BatchSize: 3, FlushInterval: time.Hour}, nil)
+	defer p.Close()
+
+	for range 3 {
+		id, err := p.Add(s.ctx, map[string]any{"k": "v"})
+		s.Require().NoError(err)
+		s.Empty(id)
+	}
+	s.Eventually(func() bool { return s.streamLen() == 3 }, 3*time.Second, 10*time.Millisecond)
+}
+
+func (s *BufferedProducerSuite) TestFlushesOnInterval() {
+	p := s.newProducer(&BufferConfig{Size: 10, BatchSize: 100, FlushInterval: 10 * time.Millisecond}, nil)
+	defer p.Close()
+
+	s.Require().NoError(p.AddWithID(s.ctx, "1-1", map[string]any{"k": "v"}))
+	s.Eventually(func() bool { return s.streamLen() == 1 }, 3*time.Second, 10*time.Millisecond)
+}
+
+func (s *BufferedProducerSuite) TestCloseFlushes() {
+	p := s.newProducer(&BufferConfig{Size: 10, BatchSize: 100, FlushInterval: time.Hour}, nil)
+
+	for range 5 {
+		_, err := p.Add(s.ctx, map[string]any{"k": "v"})
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(p.Close())
+	s.Equal(int64(5), s.streamLen())
+
+	_, err := p.Add(s.ctx, map[string]any{"k": "v"})
+	s.ErrorIs(err, ErrProducerClosed)
+	s.NoError(p.Close(), "close twice")
+}
+
+func (s *BufferedProducerSuite) TestValidation() {
+	p := s.newProducer(&BufferConfig{Size: 10}, func(values map[string]any) error {
+		if _, ok := values["data"]; !ok {
+			return errors.New("missing data")
+		}
+		return nil
+	})
+
+	_, err := p.Add(s.ctx, map[string]any{"k": "v"})
+	s.ErrorContains(err, "missing data")
+	_, err = p.Add(s.ctx, map[string]any{"data": "v"})
+	s.NoError(err)
+
+	s.Require().NoError(p.Close())
+	s.Equal(int64(1), s.streamLen())
+}
+
+func (s *BufferedProducerSuite) TestBufferFull() {
+	// not opened, nothing drains the buffer
+	p, err := NewBufferedProducer(s.client, "buffered", &BufferConfig{Size: 1}, nil, log.NewNop())
+	s.Require().NoError(err)
+
+	_, err = p.Add(s.ctx, map[string]any{"k": "v"})
+	s.Require().NoError(err)
+	_, err = p.Add(s.ctx, map[string]any{"k": "v"})
+	s.ErrorIs(err, ErrBufferFull)
+	s.NoError(p.Close())
+}
+
+func (s *BufferedProducerSuite) TestRetriesWhileRedisIsDown() {
+	p := s.newProducer(&BufferConfig{
+		Size:          10,
+		BatchSize:     1,
+		FlushInterval: 10 * time.Millisecond,
+		RetryTimeout:  10 * time.Second,
+	}, nil)
+	defer p.Close()
+
+	s.mr.Close()
+	_, err := p.Add(s.ctx, map[string]any{"k": "v"})
+	s.Require().NoError(err)
+
+	time.Sleep(100 * time.Millisecond)
+	s.Require().NoError(s.mr.Restart())
+	s.Eventually(func() bool { return s.streamLen() == 1 }, 5*time.Second, 20*time.Millisecond)
+}
+
+func (s *BufferedProducerSuite) TestRejectedEntriesAreDropped() {
+	p := s.newProducer(&BufferConfig{Size: 10, BatchSize: 2, FlushInterval: time.Hour}, nil)
+
+	// the second ID is not greater than the first, Redis rejects it
+	s.Require().NoError(p.AddWithID(s.ctx, "5-1", map[string]any{"k": "v"}))
+	s.Require().NoError(p.AddWithID(s.ctx, "1-1", map[string]any{"k": "v"}))
+
+	s.Require().NoError(p.Close())
+	s.Equal(int64(1), s.streamLen())
+}
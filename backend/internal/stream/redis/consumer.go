@@ -9,7 +9,7 @@ import (
 
 	"github.com/jonboulle/clockwork"
 
-	"github.com/imtaco/audio-rtc-exp/internal/retry"
+	"github.com/imtaco/audio-rtc-exp/internal/backoff"
 
 	"github.com/redis/go-redis/v9"
 
@@ -21,6 +21,9 @@ const (
 	broadcastModeBacktime = 3 * time.Second
 )
 
+// retryBackoff paces the retries of consumer group commands, retried until the context is done
+var retryBackoff = backoff.Exponential{Initial: 100 * time.Millisecond, Max: 10 * time.Second, Jitter: 0.2}
+
 type Consumer interface {
 	Open(ctx context.Context) error
 	Close()
@@ -41,7 +44,6 @@ type consumerImpl struct {
 	blockTime     time.Duration
 	lastID        string
 	pendingMode   bool
-	retry         retry.Retry
 	logger        *log.Logger
 	clock         clockwork.Clock
 }
@@ -91,7 +93,6 @@ func NewConsumer(
 		blockTime:     blockTime,
 		lastID:        "$",
 		pendingMode:   false,
-		retry:         retry.New(logger, 100*time.Millisecond, 10*time.Second, 0), // 0 = retry forever
 		logger:        logger,
 		clock:         clockwork.NewRealClock(),
 	}, nil
@@ -101,11 +102,21 @@ func (sc *consumerImpl) useGroup() bool {
 	return sc.consumerGroup != ""
 }
 
+// retry runs operation until it succeeds or ctx is done
+func (sc *consumerImpl) retry(ctx context.Context, operation func(ctx context.Context) error) error {
+	return backoff.Retry(ctx, retryBackoff, operation,
+		backoff.WithOnRetry(func(attempt int, err error, _ time.Duration) {
+			sc.logger.Warn("Retry attempt failed",
+				log.Int("attempt", attempt+1),
+				log.Error(err))
+		}))
+}
+
 func (sc *consumerImpl) ensureConsumerGroup(ctx context.Context) error {
 	if !sc.useGroup() {
 		return nil
 	}
-	return sc.retry.Do(ctx, func() error {
+	return sc.retry(ctx, func(ctx context.Context) error {
 		err := sc.client.XGroupCreateMkStream(ctx, sc.stream, sc.consumerGroup, "$").Err()
 		if err != nil {
 			if err.Error() == "BUSYGROUP Consumer Group name already exists" {
@@ -193,7 +204,7 @@ func (sc *consumerImpl) Ack(ctx context.Context, ids ...string) error {
 		return nil
 	}
 
-	return sc.retry.Do(ctx, func() error {
+	return sc.retry(ctx, func(ctx context.Context) error {
 		err := sc.client.XAck(ctx, sc.stream, sc.consumerGroup, ids...).Err()
 		if err != nil {
 			return fmt.Errorf("failed to ack messages: %w", err)
@@ -203,7 +214,7 @@ func (sc *consumerImpl) Ack(ctx context.Context, ids ...string) error {
 }
 
 func (sc *consumerImpl) DeleteConsumer(ctx context.Context) error {
-	return sc.retry.Do(ctx, func() error {
+	return sc.retry(ctx, func(ctx context.Context) error {
 		err := sc.client.XGroupDelConsumer(ctx, sc.stream, sc.consumerGroup, sc.consumerName).Err()
 		if err != nil {
 			return fmt.Errorf("failed to delete consumer: %w", err)
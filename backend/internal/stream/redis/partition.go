@@ -0,0 +1,96 @@
+package redis
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+
+	"github.com/spf13/viper"
+)
+
+// PartitionConfig splits a stream into partitions by key hash, each partition is consumed by
+// a single owner so entries of a key keep their order without every consumer reading them
+type PartitionConfig struct {
+	// Partitions of the stream, 0 keeps the single stream read by every consumer
+	Partitions int `mapstructure:"partitions"`
+}
+
+func SetupPartition(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("partitions"), 0)
+}
+
+// PartitionOf returns the partition of key
+func PartitionOf(key string, partitions int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(partitions))
+}
+
+// RouteKey returns an opaque token of key hashed like PartitionOf, load balancers hashing
+// on it route the connections of a key together without seeing the key
+func RouteKey(key string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// PartitionStream returns the stream name of a partition, "stream" -> "stream:p3"
+func PartitionStream(stream string, partition int) string {
+	return fmt.Sprintf("%s:p%d", stream, partition)
+}
+
+// PartitionStreams returns the stream names of all partitions
+func PartitionStreams(stream string, partitions int) []string {
+	streams := make([]string, 0, partitions)
+	for i := range partitions {
+		streams = append(streams, PartitionStream(stream, i))
+	}
+	return streams
+}
+
+// OwnedPartitions returns the partitions owned by self among members by rendezvous hashing,
+// a membership change only moves the partitions of the members joining or leaving
+func OwnedPartitions(self string, members []string, partitions int) []int {
+	if !slices.Contains(members, self) {
+		members = append(slices.Clone(members), self)
+	}
+
+	var owned []int
+	for i := range partitions {
+		if Owner(members, strconv.Itoa(i)) == self {
+			owned = append(owned, i)
+		}
+	}
+	return owned
+}
+
+// Owner returns the member owning key by rendezvous hashing, empty without members. Partitions
+// are owned by Owner(members, strconv.Itoa(partition))
+func Owner(members []string, key string) string {
+	var owner string
+	var best uint64
+	for _, member := range members {
+		// ties, if ever, go to the smallest name so every member agrees
+		if score := rendezvousScore(member, key); owner == "" || score > best || (score == best && member < owner) {
+			owner, best = member, score
+		}
+	}
+	return owner
+}
+
+// rendezvousScore hashes member and key, FNV is finalized as its high bits barely change
+// between names differing only in their last bytes
+func rendezvousScore(member, key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(member + "/" + key))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionOf(t *testing.T) {
+	for _, key := range []string{"", "room-1", "room-2", "a-much-longer-room-identifier"} {
+		p := PartitionOf(key, 8)
+		assert.GreaterOrEqual(t, p, 0)
+		assert.Less(t, p, 8)
+		assert.Equal(t, p, PartitionOf(key, 8), "stable for %q", key)
+	}
+}
+
+func TestPartitionStreams(t *testing.T) {
+	assert.Equal(t, "ws:p3", PartitionStream("ws", 3))
+	assert.Equal(t, []string{"ws:p0", "ws:p1", "ws:p2"}, PartitionStreams("ws", 3))
+	assert.Empty(t, PartitionStreams("ws", 0))
+}
+
+func TestOwnedPartitions(t *testing.T) {
+	members := []string{"gw-a", "gw-b", "gw-c"}
+	const partitions = 32
+
+	t.Run("every partition has exactly one owner", func(t *testing.T) {
+		var all []int
+		for _, m := range members {
+			all = append(all, OwnedPartitions(m, members, partitions)...)
+		}
+		slices.Sort(all)
+		assert.Len(t, all, partitions)
+		for i, p := range all {
+			assert.Equal(t, i, p)
+		}
+	})
+
+	t.Run("self is a member even when missing", func(t *testing.T) {
+		assert.Equal(t,
+			OwnedPartitions("gw-a", members, partitions),
+			OwnedPartitions("gw-a", []string{"gw-b", "gw-c"}, partitions))
+		assert.Len(t, OwnedPartitions("gw-a", nil, partitions), partitions)
+	})
+
+	t.Run("leaving member only moves its partitions", func(t *testing.T) {
+		before := OwnedPartitions("gw-a", members, partitions)
+		after := OwnedPartitions("gw-a", []string{"gw-a", "gw-b"}, partitions)
+		for _, p := range before {
+			assert.Contains(t, after, p)
+		}
+		left := OwnedPartitions("gw-c", members, partitions)
+		for _, p := range after {
+			assert.True(t, slices.Contains(before, p) || slices.Contains(left, p))
+		}
+	})
+}
+
+func TestOwner(t *testing.T) {
+	members := []string{"gw-a", "gw-b", "gw-c"}
+
+	assert.Empty(t, Owner(nil, "room-1"))
+	// agrees with the partition owners
+	for i := range 8 {
+		owned := OwnedPartitions(Owner(members, strconv.Itoa(i)), members, 8)
+		assert.Contains(t, owned, i)
+	}
+	// order of members does not matter
+	assert.Equal(t, Owner(members, "room-1"), Owner([]string{"gw-c", "gw-a", "gw-b"}, "room-1"))
+}
+
+func TestRouteKey(t *testing.T) {
+	assert.Len(t, RouteKey("room-1"), 8)
+	assert.Equal(t, RouteKey("room-1"), RouteKey("room-1"))
+	assert.NotEqual(t, RouteKey("room-1"), RouteKey("room-2"))
+}
@@ -1,8 +1,11 @@
 package redis
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jonboulle/clockwork"
@@ -14,6 +17,21 @@ import (
 type Trimer interface {
 	TrimByTime(ctx context.Context, maxAge time.Duration) error
 	TrimByMaxLen(ctx context.Context, maxLen int64) error
+	// Trim applies the policy, never trimming entries not yet delivered to or
+	// acknowledged by every consumer group
+	Trim(ctx context.Context, policy *TrimPolicy) (*TrimResult, error)
+}
+
+// TrimPolicy bounds a stream by length and by age, zero disables a bound
+type TrimPolicy struct {
+	MaxLen int64         `mapstructure:"max_len"`
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+type TrimResult struct {
+	Trimmed  int64
+	HeldBack bool             // policy asked for more, held back by consumer groups
+	Lag      map[string]int64 // group -> entries not yet delivered
 }
 
 func NewTrimer(
@@ -72,3 +90,109 @@ func (st *trimerImpl) TrimByMaxLen(ctx context.Context, maxLen int64) error {
 
 	return nil
 }
+
+func (st *trimerImpl) Trim(ctx context.Context, policy *TrimPolicy) (*TrimResult, error) {
+	result := &TrimResult{Lag: make(map[string]int64)}
+
+	length, err := st.client.XLen(ctx, st.stream).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stream length: %w", err)
+	}
+	if length == 0 {
+		return result, nil
+	}
+
+	var minID string
+	if policy.MaxAge > 0 {
+		minID = st.minID(policy.MaxAge)
+	}
+	if policy.MaxLen > 0 && length > policy.MaxLen {
+		// first entry kept, the excess is expected to be small between runs
+		excess := length - policy.MaxLen
+		msgs, err := st.client.XRangeN(ctx, st.stream, "-", "+", excess+1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to range stream: %w", err)
+		}
+		if len(msgs) > 0 && compareID(msgs[len(msgs)-1].ID, minID) > 0 {
+			minID = msgs[len(msgs)-1].ID
+		}
+	}
+	if minID == "" {
+		return result, nil
+	}
+
+	safeID, err := st.safeID(ctx, result.Lag)
+	if err != nil {
+		return nil, err
+	}
+	if safeID != "" && compareID(safeID, minID) < 0 {
+		st.logger.Warn("Stream trim held back by consumer groups",
+			log.String("stream", st.stream),
+			log.String("min_id", minID),
+			log.String("safe_id", safeID))
+		minID = safeID
+		result.HeldBack = true
+	}
+
+	result.Trimmed, err = st.client.XTrimMinID(ctx, st.stream, minID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to trim stream: %w", err)
+	}
+
+	st.logger.Debug("Trimmed stream by policy",
+		log.String("stream", st.stream),
+		log.String("min_id", minID),
+		log.Int64("trimmed_count", result.Trimmed))
+	return result, nil
+}
+
+// safeID is the lowest ID still needed by a consumer group, its oldest pending entry or
+// the entry after the last delivered one, empty without consumer groups
+func (st *trimerImpl) safeID(ctx context.Context, lag map[string]int64) (string, error) {
+	groups, err := st.client.XInfoGroups(ctx, st.stream).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to get consumer groups: %w", err)
+	}
+
+	var safeID string
+	for _, g := range groups {
+		lag[g.Name] = g.Lag
+
+		groupID := nextID(g.LastDeliveredID)
+		if g.Pending > 0 {
+			pending, err := st.client.XPending(ctx, st.stream, g.Name).Result()
+			if err != nil {
+				return "", fmt.Errorf("failed to get pending entries: %w", err)
+			}
+			if pending.Count > 0 && compareID(pending.Lower, groupID) < 0 {
+				groupID = pending.Lower
+			}
+		}
+		if safeID == "" || compareID(groupID, safeID) < 0 {
+			safeID = groupID
+		}
+	}
+	return safeID, nil
+}
+
+// compareID compares stream IDs <ms>-<seq>, an empty ID is lower than any other
+func compareID(a, b string) int {
+	ams, aseq := parseID(a)
+	bms, bseq := parseID(b)
+	if c := cmp.Compare(ams, bms); c != 0 {
+		return c
+	}
+	return cmp.Compare(aseq, bseq)
+}
+
+func nextID(id string) string {
+	ms, seq := parseID(id)
+	return fmt.Sprintf("%d-%d", ms, seq+1)
+}
+
+func parseID(id string) (uint64, uint64) {
+	msStr, seqStr, _ := strings.Cut(id, "-")
+	ms, _ := strconv.ParseUint(msStr, 10, 64)
+	seq, _ := strconv.ParseUint(seqStr, 10, 64)
+	return ms, seq
+}
@@ -144,3 +144,92 @@ func (s *TrimerTestSuite) TestTrimerWithFakeClock() {
 
 	s.Equal(expectedID, minID)
 }
+
+func (s *TrimerTestSuite) addEntries(n int) []string {
+	ids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		id, err := s.client.XAdd(context.Background(), &redis.XAddArgs{
+			Stream: "test-stream",
+			Values: map[string]any{"msg": i},
+		}).Result()
+		s.Require().NoError(err)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (s *TrimerTestSuite) TestTrim_MaxLen() {
+	ctx := context.Background()
+	s.addEntries(10)
+
+	trimer := NewTrimer(s.client, "test-stream", s.logger)
+	res, err := trimer.Trim(ctx, &TrimPolicy{MaxLen: 4})
+	s.Require().NoError(err)
+
+	s.Equal(int64(6), res.Trimmed)
+	s.False(res.HeldBack)
+	s.Equal(int64(4), s.client.XLen(ctx, "test-stream").Val())
+}
+
+func (s *TrimerTestSuite) TestTrim_MaxAge() {
+	ctx := context.Background()
+	s.addEntries(5)
+
+	trimer := NewTrimer(s.client, "test-stream", s.logger)
+	impl := trimer.(*trimerImpl)
+
+	// entries are younger than max age
+	res, err := trimer.Trim(ctx, &TrimPolicy{MaxAge: time.Hour})
+	s.Require().NoError(err)
+	s.Equal(int64(0), res.Trimmed)
+
+	impl.clock = clockwork.NewFakeClockAt(time.Now().Add(2 * time.Hour))
+	res, err = trimer.Trim(ctx, &TrimPolicy{MaxAge: time.Hour})
+	s.Require().NoError(err)
+	s.Equal(int64(5), res.Trimmed)
+}
+
+func (s *TrimerTestSuite) TestTrim_HeldBackByConsumerGroup() {
+	ctx := context.Background()
+	ids := s.addEntries(10)
+
+	// group has read 3 entries and acked the first one
+	s.Require().NoError(s.client.XGroupCreate(ctx, "test-stream", "slow-group", "0").Err())
+	streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "slow-group",
+		Consumer: "c1",
+		Streams:  []string{"test-stream", ">"},
+		Count:    3,
+	}).Result()
+	s.Require().NoError(err)
+	s.Require().Len(streams[0].Messages, 3)
+	s.Require().NoError(s.client.XAck(ctx, "test-stream", "slow-group", ids[0]).Err())
+
+	trimer := NewTrimer(s.client, "test-stream", s.logger)
+	res, err := trimer.Trim(ctx, &TrimPolicy{MaxLen: 2})
+	s.Require().NoError(err)
+
+	// oldest pending entry is kept
+	s.True(res.HeldBack)
+	s.Equal(int64(1), res.Trimmed)
+	s.Contains(res.Lag, "slow-group")
+
+	first, err := s.client.XRangeN(ctx, "test-stream", "-", "+", 1).Result()
+	s.Require().NoError(err)
+	s.Equal(ids[1], first[0].ID)
+}
+
+func (s *TrimerTestSuite) TestTrim_EmptyStream() {
+	trimer := NewTrimer(s.client, "test-stream", s.logger)
+	res, err := trimer.Trim(context.Background(), &TrimPolicy{MaxLen: 2, MaxAge: time.Minute})
+	s.Require().NoError(err)
+	s.Equal(int64(0), res.Trimmed)
+}
+
+func (s *TrimerTestSuite) TestCompareID() {
+	s.Equal(0, compareID("5-1", "5-1"))
+	s.Equal(-1, compareID("5-1", "5-2"))
+	s.Equal(1, compareID("10-0", "9-99"))
+	s.Equal(-1, compareID("", "0-1"))
+	s.Equal("5-2", nextID("5-1"))
+}
@@ -0,0 +1,305 @@
+package streamrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/imtaco/audio-rtc-exp/internal/backoff"
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+)
+
+const (
+	ErrTimeout errors.Code = "streamrpc timeout"
+
+	defaultTimeout = 5 * time.Second
+)
+
+// Client sends requests to a stream and waits for replies on another one
+type Client interface {
+	Open(ctx context.Context) error
+	Close() error
+	// Call sends a request and decodes the reply into result, retried with the same ID on timeout
+	Call(ctx context.Context, method string, params, result any, opts ...CallOption) error
+	// Notify sends a request without waiting for a reply
+	Notify(ctx context.Context, method string, params any) error
+}
+
+// ClientConfig holds the defaults of every call, CallOption overrides them per call
+type ClientConfig struct {
+	// Timeout is the wait for a reply of each attempt
+	Timeout time.Duration `mapstructure:"timeout"`
+	Retries int           `mapstructure:"retries"`
+	// RetryBackoff is the delay before the first retry, doubled on each following one and jittered
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("timeout"), "2s")
+	v.SetDefault(p("retries"), 2)
+	v.SetDefault(p("retry_backoff"), "100ms")
+}
+
+type CallOption func(*ClientConfig)
+
+// WithTimeout overrides the per attempt timeout
+func WithTimeout(timeout time.Duration) CallOption {
+	return func(c *ClientConfig) { c.Timeout = timeout }
+}
+
+// WithRetries overrides the number of retries after the first attempt
+func WithRetries(retries int) CallOption {
+	return func(c *ClientConfig) { c.Retries = retries }
+}
+
+type clientImpl struct {
+	producer    redisstream.Producer
+	consumer    redisstream.Consumer
+	replyStream string
+	cfg         ClientConfig
+	mu          sync.Mutex
+	pending     map[string]chan *response
+	newID       func() string
+	tracer      trace.Tracer
+	cancel      context.CancelFunc
+	logger      *log.Logger
+}
+
+// NewClient sends requests to requestStream, replies are read from replyStream by every client
+// instance and matched by request ID. Without replyStream only notifications can be sent
+func NewClient(
+	redisClient *redis.Client,
+	requestStream string,
+	replyStream string,
+	cfg *ClientConfig,
+	logger *log.Logger,
+) (Client, error) {
+	producer, err := redisstream.NewProducer(redisClient, requestStream, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var consumer redisstream.Consumer
+	if replyStream != "" {
+		consumer, err = redisstream.NewConsumer(redisClient, replyStream, "", "", time.Second, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg == nil {
+		cfg = &ClientConfig{}
+	}
+
+	return &clientImpl{
+		producer:    producer,
+		consumer:    consumer,
+		replyStream: replyStream,
+		cfg:         *cfg,
+		pending:     make(map[string]chan *response),
+		newID:       uuid.NewString,
+		tracer:      otel.Tracer("streamrpc"),
+		logger:      logger,
+	}, nil
+}
+
+func (c *clientImpl) Open(ctx context.Context) error {
+	if c.consumer == nil {
+		return nil
+	}
+	if err := c.consumer.Open(ctx); err != nil {
+		return err
+	}
+	ctx, c.cancel = context.WithCancel(ctx)
+	go c.readReplies(ctx)
+	return nil
+}
+
+func (c *clientImpl) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	if c.consumer != nil {
+		c.consumer.Close()
+	}
+	return nil
+}
+
+func (c *clientImpl) Call(ctx context.Context, method string, params, result any, opts ...CallOption) error {
+	if c.consumer == nil {
+		return fmt.Errorf("no reply stream to call %s", method)
+	}
+
+	cfg := c.cfg
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	req, err := c.newRequest(method, params)
+	if err != nil {
+		return err
+	}
+	req.ReplyTo = c.replyStream
+
+	ctx, span := c.tracer.Start(ctx, "streamrpc.Call "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.method", method),
+			attribute.String("rpc.request_id", req.ID),
+		))
+	defer span.End()
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(req.Trace))
+
+	ch := make(chan *response, 1)
+	c.mu.Lock()
+	c.pending[req.ID] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, req.ID)
+		c.mu.Unlock()
+	}()
+
+	resp, err := c.roundTrip(ctx, req, ch, &cfg)
+	span.SetAttributes(attribute.Int("rpc.attempts", req.Attempt+1))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if resp.Error != nil {
+		span.RecordError(resp.Error)
+		return resp.Error
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("failed to unmarshal %s result: %w", method, err)
+	}
+	return nil
+}
+
+// roundTrip sends the request until a reply arrives, every attempt keeps the request ID
+// so a slow reply to an earlier attempt is accepted as well
+func (c *clientImpl) roundTrip(
+	ctx context.Context,
+	req *request,
+	ch <-chan *response,
+	cfg *ClientConfig,
+) (*response, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	for attempt := 0; attempt <= cfg.Retries; attempt++ {
+		if attempt > 0 {
+			c.logger.Debug("Retrying request",
+				log.String("method", req.Method),
+				log.String("id", req.ID),
+				log.Int("attempt", attempt))
+			delay := backoff.Exponential{Initial: cfg.RetryBackoff, Jitter: 0.2}.Delay(attempt - 1)
+			if err := backoff.Sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+		}
+		req.Attempt = attempt
+		req.Deadline = time.Now().Add(timeout).UnixMilli()
+
+		timer := time.NewTimer(timeout)
+		if err := c.send(ctx, req); err != nil {
+			timer.Stop()
+			c.logger.Warn("Failed to send request", log.String("method", req.Method), log.Error(err))
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case resp := <-ch:
+			timer.Stop()
+			return resp, nil
+		case <-timer.C:
+		}
+	}
+	return nil, errors.Newf(ErrTimeout, "no reply to %s after %d attempts", req.Method, cfg.Retries+1)
+}
+
+func (c *clientImpl) Notify(ctx context.Context, method string, params any) error {
+	req, err := c.newRequest(method, params)
+	if err != nil {
+		return err
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(req.Trace))
+	return c.send(ctx, req)
+}
+
+func (c *clientImpl) newRequest(method string, params any) (*request, error) {
+	req := &request{
+		ID:     c.newID(),
+		Method: method,
+		Trace:  map[string]string{},
+	}
+	if params != nil {
+		bs, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s params: %w", method, err)
+		}
+		req.Params = bs
+	}
+	return req, nil
+}
+
+func (c *clientImpl) send(ctx context.Context, req *request) error {
+	values, err := encode(req)
+	if err != nil {
+		return err
+	}
+	_, err = c.producer.Add(ctx, values)
+	return err
+}
+
+// readReplies hands replies to the waiting calls, replies to other clients are skipped
+func (c *clientImpl) readReplies(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-c.consumer.Channel():
+			if !ok {
+				return
+			}
+			resp := &response{}
+			if err := decode(msg.Values, resp); err != nil {
+				c.logger.Warn("Failed to decode reply", log.String("id", msg.ID), log.Error(err))
+				continue
+			}
+
+			c.mu.Lock()
+			ch, ok := c.pending[resp.ID]
+			c.mu.Unlock()
+			if !ok {
+				continue
+			}
+			// buffered, a duplicate reply to a retried request is dropped
+			select {
+			case ch <- resp:
+			default:
+			}
+		}
+	}
+}
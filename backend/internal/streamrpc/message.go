@@ -0,0 +1,69 @@
+package streamrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+)
+
+// dataField holds the JSON payload of stream entries, same layout as the jsonrpc redis streams
+const dataField = "data"
+
+// request is a call or a notification, notifications have no reply stream
+type request struct {
+	// ID correlates the reply and stays the same across retries so servers can dedup
+	ID      string          `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ReplyTo string          `json:"replyTo,omitempty"`
+	Attempt int             `json:"attempt,omitempty"`
+	// Deadline in unix milliseconds, servers drop requests the caller gave up on
+	Deadline int64 `json:"deadline,omitempty"`
+	// Trace carries the caller span context
+	Trace map[string]string `json:"trace,omitempty"`
+}
+
+type response struct {
+	ID     string          `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *jsonrpc.Error  `json:"error,omitempty"`
+}
+
+func (r *request) isNotify() bool {
+	return r.ReplyTo == ""
+}
+
+func encode(v any) (map[string]any, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return map[string]any{dataField: bs}, nil
+}
+
+func decode(values map[string]any, v any) error {
+	var raw []byte
+	switch val := values[dataField].(type) {
+	case string:
+		raw = []byte(val)
+	case []byte:
+		raw = val
+	default:
+		return fmt.Errorf("message missing %s field", dataField)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// toRPCError keeps the code of jsonrpc errors so callers can tell them apart
+func toRPCError(err error) *jsonrpc.Error {
+	if err == nil {
+		return nil
+	}
+	var rpcErr *jsonrpc.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	return jsonrpc.ErrInternal(err.Error())
+}
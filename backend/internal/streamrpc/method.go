@@ -0,0 +1,43 @@
+package streamrpc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+)
+
+// Empty is the response of methods replying nothing but success or error
+type Empty struct{}
+
+// Method binds a method name to its request and response types, declared once and shared
+// by callers and the server
+type Method[Req, Resp any] string
+
+// Call sends req and waits for the typed response
+func (m Method[Req, Resp]) Call(ctx context.Context, c Client, req *Req, opts ...CallOption) (*Resp, error) {
+	resp := new(Resp)
+	if err := c.Call(ctx, string(m), req, resp, opts...); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Notify sends req without waiting for a response
+func (m Method[Req, Resp]) Notify(ctx context.Context, c Client, req *Req) error {
+	return c.Notify(ctx, string(m), req)
+}
+
+// Handle registers h on s, params are bound and validated the same way as jsonrpc params
+func (m Method[Req, Resp]) Handle(s *Server, h func(ctx context.Context, req *Req, reply func(*Resp, error))) {
+	s.Register(string(m), func(ctx context.Context, params json.RawMessage, reply Reply) {
+		req := new(Req)
+		if err := jsonrpc.ShouldBindParams(&params, req); err != nil {
+			reply(nil, err)
+			return
+		}
+		h(ctx, req, func(resp *Resp, err error) {
+			reply(resp, err)
+		})
+	})
+}
@@ -0,0 +1,258 @@
+package streamrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+)
+
+const (
+	dedupCacheSize = 4096
+	dedupTTL       = time.Minute
+)
+
+// Reply sends the result of a request, only the first reply counts. Replies to notifications are dropped
+type Reply func(result any, err error)
+
+// Handler serves a request, reply may be called later from another goroutine
+type Handler func(ctx context.Context, params json.RawMessage, reply Reply)
+
+// Server consumes requests from a stream with a consumer group and replies to the stream
+// named by each request
+type Server struct {
+	redisClient *redis.Client
+	consumer    redisstream.Consumer
+	handlers    map[string]Handler
+	mu          sync.Mutex
+	producers   map[string]redisstream.Producer
+	// dedup keeps the reply of recent requests, retries get it again instead of running twice
+	dedup  *expirable.LRU[string, *dedupEntry]
+	now    func() time.Time
+	tracer trace.Tracer
+	cancel context.CancelFunc
+	logger *log.Logger
+}
+
+type dedupEntry struct {
+	mu   sync.Mutex
+	resp *response // nil while in flight
+}
+
+func NewServer(
+	redisClient *redis.Client,
+	requestStream string,
+	group string,
+	logger *log.Logger,
+) (*Server, error) {
+	consumer, err := redisstream.NewConsumer(
+		redisClient,
+		requestStream,
+		group,
+		uuid.NewString(),
+		time.Second,
+		logger,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		redisClient: redisClient,
+		consumer:    consumer,
+		handlers:    make(map[string]Handler),
+		producers:   make(map[string]redisstream.Producer),
+		dedup:       expirable.NewLRU[string, *dedupEntry](dedupCacheSize, nil, dedupTTL),
+		now:         time.Now,
+		tracer:      otel.Tracer("streamrpc"),
+		logger:      logger,
+	}, nil
+}
+
+// Register adds the handler of method, must be called before Open
+func (s *Server) Register(method string, handler Handler) {
+	s.handlers[method] = handler
+}
+
+func (s *Server) Open(ctx context.Context) error {
+	if err := s.consumer.Open(ctx); err != nil {
+		return err
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	go s.serve(ctx)
+	return nil
+}
+
+func (s *Server) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.consumer.Close()
+	return nil
+}
+
+func (s *Server) serve(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-s.consumer.Channel():
+			if !ok {
+				return
+			}
+			req := &request{}
+			if err := decode(msg.Values, req); err != nil {
+				s.logger.Warn("Failed to decode request", log.String("id", msg.ID), log.Error(err))
+			} else {
+				s.dispatch(ctx, req)
+			}
+			if err := msg.Ack(); err != nil {
+				s.logger.Error("Failed to ack request", log.String("id", msg.ID), log.Error(err))
+			}
+		}
+	}
+}
+
+func (s *Server) dispatch(ctx context.Context, req *request) {
+	if req.Deadline > 0 && s.now().UnixMilli() > req.Deadline {
+		s.logger.Debug("Drop expired request",
+			log.String("method", req.Method),
+			log.String("id", req.ID),
+			log.Int("attempt", req.Attempt))
+		return
+	}
+
+	var entry *dedupEntry
+	if !req.isNotify() {
+		var seen bool
+		entry, seen = s.claim(req.ID)
+		if seen {
+			s.replayReply(ctx, req, entry)
+			return
+		}
+	}
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(req.Trace))
+	ctx, span := s.tracer.Start(ctx, "streamrpc.Handle "+req.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.method", req.Method),
+			attribute.String("rpc.request_id", req.ID),
+			attribute.Int("rpc.attempt", req.Attempt),
+		))
+
+	var once sync.Once
+	reply := func(result any, err error) {
+		once.Do(func() {
+			defer span.End()
+			if err != nil {
+				span.RecordError(err)
+			}
+			if req.isNotify() {
+				return
+			}
+			resp := newResponse(req.ID, result, err)
+			entry.mu.Lock()
+			entry.resp = resp
+			entry.mu.Unlock()
+			s.send(ctx, req.ReplyTo, resp)
+		})
+	}
+
+	handler, ok := s.handlers[req.Method]
+	if !ok {
+		reply(nil, jsonrpc.ErrMethodNotFound(req.Method))
+		return
+	}
+	handler(ctx, req.Params, reply)
+}
+
+// claim returns the entry of the request ID, seen when an earlier attempt already claimed it
+func (s *Server) claim(id string) (*dedupEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.dedup.Get(id); ok {
+		return entry, true
+	}
+	entry := &dedupEntry{}
+	s.dedup.Add(id, entry)
+	return entry, false
+}
+
+// replayReply resends the reply of a retried request, still running requests reply once done
+func (s *Server) replayReply(ctx context.Context, req *request, entry *dedupEntry) {
+	entry.mu.Lock()
+	resp := entry.resp
+	entry.mu.Unlock()
+
+	s.logger.Debug("Duplicate request",
+		log.String("method", req.Method),
+		log.String("id", req.ID),
+		log.Int("attempt", req.Attempt),
+		log.Bool("done", resp != nil))
+	if resp != nil {
+		s.send(ctx, req.ReplyTo, resp)
+	}
+}
+
+func (s *Server) send(ctx context.Context, stream string, resp *response) {
+	producer, err := s.producer(stream)
+	if err == nil {
+		var values map[string]any
+		if values, err = encode(resp); err == nil {
+			_, err = producer.Add(ctx, values)
+		}
+	}
+	if err != nil {
+		s.logger.Error("Failed to send reply",
+			log.String("stream", stream),
+			log.String("id", resp.ID),
+			log.Error(err))
+	}
+}
+
+func (s *Server) producer(stream string) (redisstream.Producer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if producer, ok := s.producers[stream]; ok {
+		return producer, nil
+	}
+	producer, err := redisstream.NewProducer(s.redisClient, stream, s.logger)
+	if err != nil {
+		return nil, err
+	}
+	s.producers[stream] = producer
+	return producer, nil
+}
+
+func newResponse(id string, result any, err error) *response {
+	resp := &response{ID: id}
+	if err != nil {
+		resp.Error = toRPCError(err)
+		return resp
+	}
+	if result != nil {
+		bs, err := json.Marshal(result)
+		if err != nil {
+			resp.Error = jsonrpc.ErrInternal(fmt.Sprintf("failed to marshal result: %v", err))
+			return resp
+		}
+		resp.Result = bs
+	}
+	return resp
+}
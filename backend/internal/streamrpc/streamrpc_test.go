@@ -0,0 +1,256 @@
+package streamrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const (
+	testRequestStream = "test:rpc:req"
+	testReplyStream   = "test:rpc:reply"
+)
+
+type echoRequest struct {
+	Text string `json:"text" validate:"required"`
+}
+
+type echoResponse struct {
+	Text string `json:"text"`
+}
+
+var methodEcho = Method[echoRequest, echoResponse]("echo")
+
+type StreamRPCSuite struct {
+	suite.Suite
+	mr          *miniredis.Miniredis
+	redisClient *redis.Client
+	server      *Server
+	client      Client
+	ctx         context.Context
+	cancel      context.CancelFunc
+}
+
+func TestStreamRPCSuite(t *testing.T) {
+	suite.Run(t, new(StreamRPCSuite))
+}
+
+func (s *StreamRPCSuite) SetupTest() {
+	mr, err := miniredis.Run()
+	s.Require().NoError(err)
+	s.mr = mr
+	s.redisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	s.ctx, s.cancel = context.WithTimeout(context.Background(), 5*time.Second)
+
+	s.server, err = NewServer(s.redisClient, testRequestStream, "test-group", log.NewNop())
+	s.Require().NoError(err)
+
+	s.client, err = NewClient(s.redisClient, testRequestStream, testReplyStream, &ClientConfig{
+		Timeout: time.Second,
+	}, log.NewNop())
+	s.Require().NoError(err)
+}
+
+func (s *StreamRPCSuite) TearDownTest() {
+	s.cancel()
+	s.client.Close()
+	s.server.Close()
+	s.redisClient.Close()
+	s.mr.Close()
+}
+
+func (s *StreamRPCSuite) open() {
+	s.Require().NoError(s.server.Open(s.ctx))
+	s.Require().NoError(s.client.Open(s.ctx))
+}
+
+func (s *StreamRPCSuite) TestCall() {
+	methodEcho.Handle(s.server, func(_ context.Context, req *echoRequest, reply func(*echoResponse, error)) {
+		reply(&echoResponse{Text: "re: " + req.Text}, nil)
+	})
+	s.open()
+
+	resp, err := methodEcho.Call(s.ctx, s.client, &echoRequest{Text: "hi"})
+	s.Require().NoError(err)
+	s.Equal("re: hi", resp.Text)
+}
+
+func (s *StreamRPCSuite) TestCall_AsyncReply() {
+	methodEcho.Handle(s.server, func(_ context.Context, req *echoRequest, reply func(*echoResponse, error)) {
+		go reply(&echoResponse{Text: req.Text}, nil)
+	})
+	s.open()
+
+	resp, err := methodEcho.Call(s.ctx, s.client, &echoRequest{Text: "later"})
+	s.Require().NoError(err)
+	s.Equal("later", resp.Text)
+}
+
+func (s *StreamRPCSuite) TestCall_Errors() {
+	methodEcho.Handle(s.server, func(_ context.Context, req *echoRequest, reply func(*echoResponse, error)) {
+		if req.Text == "invalid" {
+			reply(nil, jsonrpc.ErrInvalidRequest("room not found"))
+			return
+		}
+		reply(nil, fmt.Errorf("redis down"))
+	})
+	s.open()
+
+	cases := []struct {
+		name   string
+		method string
+		params any
+		code   int64
+	}{
+		{"handler jsonrpc error", "echo", &echoRequest{Text: "invalid"}, jsonrpc.CodeInvalidRequest},
+		{"handler plain error", "echo", &echoRequest{Text: "boom"}, jsonrpc.CodeInternalError},
+		{"invalid params", "echo", &echoRequest{}, jsonrpc.CodeInvalidParams},
+		{"method not found", "nope", &echoRequest{Text: "hi"}, jsonrpc.CodeMethodNotFound},
+	}
+	for _, tc := range cases {
+		s.Run(tc.name, func() {
+			err := s.client.Call(s.ctx, tc.method, tc.params, nil)
+			var rpcErr *jsonrpc.Error
+			s.Require().ErrorAs(err, &rpcErr)
+			s.Equal(tc.code, rpcErr.Code)
+		})
+	}
+}
+
+func (s *StreamRPCSuite) TestNotify() {
+	received := make(chan string, 1)
+	methodEcho.Handle(s.server, func(_ context.Context, req *echoRequest, reply func(*echoResponse, error)) {
+		received <- req.Text
+		reply(&echoResponse{}, nil)
+	})
+	s.open()
+
+	s.Require().NoError(methodEcho.Notify(s.ctx, s.client, &echoRequest{Text: "fire"}))
+
+	select {
+	case text := <-received:
+		s.Equal("fire", text)
+	case <-s.ctx.Done():
+		s.T().Fatal("notification not handled")
+	}
+	s.Zero(s.mr.Exists(testReplyStream), "notifications are not replied")
+}
+
+func (s *StreamRPCSuite) TestCall_RetryRunsOnce() {
+	var calls atomic.Int32
+	methodEcho.Handle(s.server, func(_ context.Context, req *echoRequest, reply func(*echoResponse, error)) {
+		calls.Add(1)
+		// slower than the first attempt, the retry finds it in flight
+		time.AfterFunc(300*time.Millisecond, func() {
+			reply(&echoResponse{Text: req.Text}, nil)
+		})
+	})
+	s.open()
+
+	resp, err := methodEcho.Call(s.ctx, s.client, &echoRequest{Text: "slow"},
+		WithTimeout(200*time.Millisecond), WithRetries(2))
+	s.Require().NoError(err)
+	s.Equal("slow", resp.Text)
+	s.Equal(int32(1), calls.Load())
+}
+
+func (s *StreamRPCSuite) TestCall_Timeout() {
+	// no server consuming requests
+	s.Require().NoError(s.client.Open(s.ctx))
+
+	start := time.Now()
+	err := s.client.Call(s.ctx, "echo", &echoRequest{Text: "hi"}, nil,
+		WithTimeout(50*time.Millisecond), WithRetries(1))
+	s.Require().ErrorIs(err, errors.Code(ErrTimeout))
+	s.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+
+	// both attempts carry the same request ID
+	entries, err := s.redisClient.XRange(s.ctx, testRequestStream, "-", "+").Result()
+	s.Require().NoError(err)
+	s.Require().Len(entries, 2)
+	var first, second request
+	s.Require().NoError(decode(entries[0].Values, &first))
+	s.Require().NoError(decode(entries[1].Values, &second))
+	s.Equal(first.ID, second.ID)
+	s.Equal(0, first.Attempt)
+	s.Equal(1, second.Attempt)
+}
+
+func (s *StreamRPCSuite) TestCall_ContextCanceled() {
+	s.Require().NoError(s.client.Open(s.ctx))
+
+	ctx, cancel := context.WithTimeout(s.ctx, 50*time.Millisecond)
+	defer cancel()
+	err := s.client.Call(ctx, "echo", &echoRequest{Text: "hi"}, nil)
+	s.Require().ErrorIs(err, context.DeadlineExceeded)
+}
+
+func (s *StreamRPCSuite) TestCall_NoReplyStream() {
+	client, err := NewClient(s.redisClient, testRequestStream, "", nil, log.NewNop())
+	s.Require().NoError(err)
+
+	s.Require().Error(client.Call(s.ctx, "echo", &echoRequest{Text: "hi"}, nil))
+	s.Require().NoError(client.Notify(s.ctx, "echo", &echoRequest{Text: "hi"}))
+}
+
+func (s *StreamRPCSuite) TestDispatch_ReplaysDoneRequest() {
+	var calls int
+	methodEcho.Handle(s.server, func(_ context.Context, req *echoRequest, reply func(*echoResponse, error)) {
+		calls++
+		reply(&echoResponse{Text: req.Text}, nil)
+	})
+
+	req := &request{
+		ID:      "req-1",
+		Method:  "echo",
+		Params:  json.RawMessage(`{"text":"once"}`),
+		ReplyTo: testReplyStream,
+	}
+	s.server.dispatch(s.ctx, req)
+	// the reply was lost, the caller retries
+	req.Attempt = 1
+	s.server.dispatch(s.ctx, req)
+
+	s.Equal(1, calls)
+	entries, err := s.redisClient.XRange(s.ctx, testReplyStream, "-", "+").Result()
+	s.Require().NoError(err)
+	s.Require().Len(entries, 2)
+	for _, entry := range entries {
+		var resp response
+		s.Require().NoError(decode(entry.Values, &resp))
+		s.Equal("req-1", resp.ID)
+		s.JSONEq(`{"text":"once"}`, string(resp.Result))
+	}
+}
+
+func (s *StreamRPCSuite) TestDispatch_DropsExpiredRequest() {
+	var calls int
+	methodEcho.Handle(s.server, func(_ context.Context, _ *echoRequest, reply func(*echoResponse, error)) {
+		calls++
+		reply(nil, nil)
+	})
+	now := time.Now()
+	s.server.now = func() time.Time { return now }
+
+	s.server.dispatch(s.ctx, &request{
+		ID:       "req-1",
+		Method:   "echo",
+		Params:   json.RawMessage(`{"text":"late"}`),
+		ReplyTo:  testReplyStream,
+		Deadline: now.Add(-time.Second).UnixMilli(),
+	})
+
+	s.Zero(calls)
+	s.Zero(s.mr.Exists(testReplyStream))
+}
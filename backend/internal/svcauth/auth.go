@@ -0,0 +1,208 @@
+// Package svcauth authorizes calls to the HTTP routers of modules, e.g. mixers, with a service
+// JWT or the mTLS identity of the caller, and audits the calls changing something. The rooms API
+// authenticates its API keys on top of the principals and service JWTs of this package.
+package svcauth
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/tlsid"
+)
+
+// Scopes of module routes
+const (
+	ScopeRead  = "read"  // read state and logs
+	ScopeDebug = "debug" // change what a room plays, e.g. test sources
+	ScopeAdmin = "admin" // implies all scopes
+)
+
+const (
+	principalKey  = "principal"
+	serviceLeeway = 30 * time.Second
+	// anonymous is the principal audited while auth is disabled
+	anonymous = "anonymous"
+)
+
+// Config of module router authorization, callers send "Authorization: Bearer <jwt>" or
+// present an mTLS client certificate
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	// ServiceSecret verifies HS256 service JWTs with sub and scopes claims, the same tokens the
+	// rooms API takes, empty disables them
+	ServiceSecret string `mapstructure:"service_secret"`
+	// PeerScopes are granted to callers authenticated by their mTLS certificate, which are
+	// limited to the allowed peers of the mTLS config
+	PeerScopes []string `mapstructure:"peer_scopes"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), false)
+	v.SetDefault(p("service_secret"), "")
+	v.SetDefault(p("peer_scopes"), []string{ScopeRead})
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	ID string // "svc:<sub>" for service JWTs, the SPIFFE ID for mTLS peers, the key ID for API keys
+	// Tenant is the tenant of API keys, the subject of service JWTs
+	Tenant string
+	Scopes []string
+	// RateLimit is requests per minute of API keys with a limit of their own, 0 otherwise
+	RateLimit int
+}
+
+// HasScope reports whether the principal may use scope, admin implies all scopes
+func (p *Principal) HasScope(scope string) bool {
+	return scope == "" || slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// PrincipalFrom returns the principal authenticated by Require, nil when auth is disabled
+func PrincipalFrom(c *gin.Context) *Principal {
+	if v, ok := c.Get(principalKey); ok {
+		return v.(*Principal)
+	}
+	return nil
+}
+
+// SetPrincipal stores the authenticated caller of the request, for authenticators of their own
+// such as the rooms API keys
+func SetPrincipal(c *gin.Context, principal *Principal) {
+	c.Set(principalKey, principal)
+}
+
+// ServiceClaims are the claims of service JWTs
+type ServiceClaims struct {
+	Scopes []string `json:"scopes"`
+	jwt.RegisteredClaims
+}
+
+// ServiceVerifier verifies HS256 service JWTs with sub and scopes claims
+type ServiceVerifier struct {
+	parser *jwt.Parser
+}
+
+func NewServiceVerifier() *ServiceVerifier {
+	return &ServiceVerifier{
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(serviceLeeway),
+		),
+	}
+}
+
+// Verify returns the principal of a service token signed with secret, an empty secret rejects
+// every token
+func (v *ServiceVerifier) Verify(token, secret string) (*Principal, bool) {
+	if secret == "" {
+		return nil, false
+	}
+
+	var claims ServiceClaims
+	_, err := v.parser.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return []byte(secret), nil
+	})
+	if err != nil || claims.Subject == "" {
+		return nil, false
+	}
+	return &Principal{
+		ID:     "svc:" + claims.Subject,
+		Tenant: claims.Subject,
+		Scopes: claims.Scopes,
+	}, true
+}
+
+// Authenticator checks the callers of module routes. A nil Authenticator lets every call
+// through unaudited
+type Authenticator struct {
+	cfg     *Config
+	service *ServiceVerifier
+	logger  *log.Logger
+}
+
+// NewAuthenticator returns an authenticator auditing calls, it only rejects them once enabled
+func NewAuthenticator(cfg *Config, logger *log.Logger) *Authenticator {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	return &Authenticator{
+		cfg:     cfg,
+		service: NewServiceVerifier(),
+		logger:  logger,
+	}
+}
+
+// Require authenticates the request and checks scope ("" for any authenticated caller).
+// Calls other than GET and HEAD are audit logged with their caller and outcome
+func (a *Authenticator) Require(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a == nil {
+			c.Next()
+			return
+		}
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			defer a.audit(c, scope)
+		}
+		if !a.cfg.Enabled {
+			c.Next()
+			return
+		}
+
+		principal, ok := a.authenticate(c.Request)
+		if !ok {
+			Abort(c, http.StatusUnauthorized, "Invalid or missing service token")
+			return
+		}
+		SetPrincipal(c, principal)
+		if !principal.HasScope(scope) {
+			Abort(c, http.StatusForbidden, fmt.Sprintf("Caller lacks scope %s", scope))
+			return
+		}
+		c.Next()
+	}
+}
+
+func (a *Authenticator) authenticate(req *http.Request) (*Principal, bool) {
+	if token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+		return a.service.Verify(token, a.cfg.ServiceSecret)
+	}
+	if peer := tlsid.PeerID(req.TLS); peer != "" {
+		return &Principal{ID: peer, Scopes: a.cfg.PeerScopes}, true
+	}
+	return nil, false
+}
+
+// audit logs a call once handled, rejected ones included
+func (a *Authenticator) audit(c *gin.Context, scope string) {
+	caller := anonymous
+	if principal := PrincipalFrom(c); principal != nil {
+		caller = principal.ID
+	}
+	a.logger.Info("Audit",
+		log.String("caller", caller),
+		log.String("scope", scope),
+		log.String("method", c.Request.Method),
+		log.String("path", c.Request.URL.Path),
+		log.String("roomId", c.Param("roomId")),
+		log.String("clientIp", c.ClientIP()),
+		log.Int("status", c.Writer.Status()))
+}
+
+// Abort rejects the request with the error response of the APIs
+func Abort(c *gin.Context, status int, msg string) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"success": false,
+		"error":   msg,
+	})
+}
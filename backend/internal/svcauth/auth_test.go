@@ -0,0 +1,150 @@
+package svcauth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type AuthenticatorSuite struct {
+	suite.Suite
+	cfg  *Config
+	auth *Authenticator
+}
+
+func TestAuthenticatorSuite(t *testing.T) {
+	suite.Run(t, new(AuthenticatorSuite))
+}
+
+func (s *AuthenticatorSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	s.cfg = &Config{
+		Enabled:       true,
+		ServiceSecret: "svc-secret",
+		PeerScopes:    []string{ScopeRead},
+	}
+	s.auth = NewAuthenticator(s.cfg, log.NewTest(s.T()))
+}
+
+func (s *AuthenticatorSuite) do(
+	a *Authenticator,
+	method, scope string,
+	setup func(*http.Request),
+) (*httptest.ResponseRecorder, *Principal) {
+	var principal *Principal
+	engine := gin.New()
+	engine.Handle(method, "/rooms/:roomId", a.Require(scope), func(c *gin.Context) {
+		principal = PrincipalFrom(c)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, "/rooms/room1", nil)
+	if setup != nil {
+		setup(req)
+	}
+	engine.ServeHTTP(w, req)
+	return w, principal
+}
+
+func (s *AuthenticatorSuite) bearer(token string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+func (s *AuthenticatorSuite) serviceToken(secret string, scopes []string, exp time.Time) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, ServiceClaims{
+		Scopes: scopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "rooms",
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	})
+	signed, err := token.SignedString([]byte(secret))
+	s.Require().NoError(err)
+	return signed
+}
+
+func (s *AuthenticatorSuite) TestNil() {
+	w, principal := s.do(nil, http.MethodPut, ScopeDebug, nil)
+	s.Equal(http.StatusOK, w.Code)
+	s.Nil(principal)
+}
+
+func (s *AuthenticatorSuite) TestDisabled() {
+	a := NewAuthenticator(&Config{}, log.NewTest(s.T()))
+
+	w, principal := s.do(a, http.MethodPut, ScopeDebug, nil)
+	s.Equal(http.StatusOK, w.Code)
+	s.Nil(principal)
+}
+
+func (s *AuthenticatorSuite) TestMissingToken() {
+	w, _ := s.do(s.auth, http.MethodGet, ScopeRead, nil)
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func (s *AuthenticatorSuite) TestServiceToken() {
+	token := s.serviceToken("svc-secret", []string{ScopeDebug}, time.Now().Add(time.Minute))
+
+	w, principal := s.do(s.auth, http.MethodPut, ScopeDebug, s.bearer(token))
+	s.Equal(http.StatusOK, w.Code)
+	s.Require().NotNil(principal)
+	s.Equal("svc:rooms", principal.ID)
+
+	// scopes are not implied by one another, but admin implies all
+	w, _ = s.do(s.auth, http.MethodGet, ScopeRead, s.bearer(token))
+	s.Equal(http.StatusForbidden, w.Code)
+
+	admin := s.serviceToken("svc-secret", []string{ScopeAdmin}, time.Now().Add(time.Minute))
+	w, _ = s.do(s.auth, http.MethodGet, ScopeRead, s.bearer(admin))
+	s.Equal(http.StatusOK, w.Code)
+}
+
+func (s *AuthenticatorSuite) TestServiceToken_Invalid() {
+	for name, token := range map[string]string{
+		"wrong secret": s.serviceToken("other", []string{ScopeAdmin}, time.Now().Add(time.Minute)),
+		"expired":      s.serviceToken("svc-secret", []string{ScopeAdmin}, time.Now().Add(-time.Hour)),
+		"not a jwt":    "garbage",
+	} {
+		w, _ := s.do(s.auth, http.MethodGet, ScopeRead, s.bearer(token))
+		s.Equal(http.StatusUnauthorized, w.Code, name)
+	}
+
+	s.cfg.ServiceSecret = ""
+	token := s.serviceToken("", []string{ScopeAdmin}, time.Now().Add(time.Minute))
+	w, _ := s.do(s.auth, http.MethodGet, ScopeRead, s.bearer(token))
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func (s *AuthenticatorSuite) TestMTLSPeer() {
+	peer := func(req *http.Request) {
+		id, _ := url.Parse("spiffe://audio-rtc.local/rooms")
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{URIs: []*url.URL{id}}}}
+	}
+
+	w, principal := s.do(s.auth, http.MethodGet, ScopeRead, peer)
+	s.Equal(http.StatusOK, w.Code)
+	s.Require().NotNil(principal)
+	s.Equal("spiffe://audio-rtc.local/rooms", principal.ID)
+
+	w, _ = s.do(s.auth, http.MethodPut, ScopeDebug, peer)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// TLS without a client certificate
+	w, _ = s.do(s.auth, http.MethodGet, ScopeRead, func(req *http.Request) {
+		req.TLS = &tls.ConnectionState{}
+	})
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,168 @@
+// Package synthanchor is a synthetic anchor built on Pion. It joins a room through the
+// signaling of the gateway, negotiates a PeerConnection with Janus and publishes generated
+// Opus audio, for probes, load generation and integration tests.
+package synthanchor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc/websocket"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const (
+	opusClockRate = 48000
+	opusChannels  = 2
+)
+
+// Anchor is a synthetic anchor connected to the gateway, it publishes a single Opus track.
+// Notifications of the gateway are not handled
+type Anchor struct {
+	peer      jsonrpc.Peer[struct{}]
+	pc        *webrtc.PeerConnection
+	track     *webrtc.TrackLocalStaticSample
+	connected chan struct{}
+	failed    chan struct{}
+	logger    *log.Logger
+}
+
+// Dial connects to the gateway signaling endpoint with the token of an anchor
+func Dial(ctx context.Context, wsURL, token string, logger *log.Logger) (*Anchor, error) {
+	u, err := url.Parse(wsURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid websocket URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+
+	peer, err := websocket.Dial[struct{}](ctx, u.String(), nil, logger)
+	if err != nil {
+		return nil, err
+	}
+	if err := peer.Open(ctx); err != nil {
+		return nil, fmt.Errorf("failed to open signaling: %w", err)
+	}
+	return &Anchor{
+		peer:      peer,
+		connected: make(chan struct{}),
+		failed:    make(chan struct{}),
+		logger:    logger,
+	}, nil
+}
+
+// Join joins the room of the pin, it fails until the room is on air
+func (a *Anchor) Join(ctx context.Context, pin string) error {
+	params := map[string]any{"pin": pin, "clientId": uuid.New().String()}
+	if err := a.peer.Call(ctx, "join", params, nil); err != nil {
+		return fmt.Errorf("failed to join: %w", err)
+	}
+	return nil
+}
+
+// Publish negotiates the PeerConnection with Janus, waits for ICE to connect and reports the
+// anchor on air. Candidates are gathered before the offer is sent so no trickle is needed,
+// iceServers are STUN/TURN URLs, none for host candidates only
+func (a *Anchor) Publish(ctx context.Context, iceServers []string) error {
+	var cfg webrtc.Configuration
+	if len(iceServers) > 0 {
+		cfg.ICEServers = []webrtc.ICEServer{{URLs: iceServers}}
+	}
+	pc, err := webrtc.NewPeerConnection(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create peer connection: %w", err)
+	}
+	a.pc = pc
+	pc.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
+		a.logger.Debug("ICE connection state changed", log.String("state", state.String()))
+		switch state {
+		case webrtc.ICEConnectionStateConnected:
+			closeOnce(a.connected)
+		case webrtc.ICEConnectionStateFailed:
+			closeOnce(a.failed)
+		}
+	})
+
+	a.track, err = webrtc.NewTrackLocalStaticSample(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: opusClockRate, Channels: opusChannels},
+		"audio", "synthanchor")
+	if err != nil {
+		return fmt.Errorf("failed to create track: %w", err)
+	}
+	if _, err := pc.AddTrack(a.track); err != nil {
+		return fmt.Errorf("failed to add track: %w", err)
+	}
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create offer: %w", err)
+	}
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(offer); err != nil {
+		return fmt.Errorf("failed to set offer: %w", err)
+	}
+	select {
+	case <-gathered:
+	case <-ctx.Done():
+		return fmt.Errorf("failed to gather candidates: %w", ctx.Err())
+	}
+
+	var result struct {
+		SDP *webrtc.SessionDescription `json:"sdp"`
+	}
+	params := map[string]any{"sdp": pc.LocalDescription()}
+	if err := a.peer.Call(ctx, "offer", params, &result); err != nil {
+		return fmt.Errorf("failed to send offer: %w", err)
+	}
+	if result.SDP == nil {
+		return errors.New("no answer to the offer")
+	}
+	if err := pc.SetRemoteDescription(*result.SDP); err != nil {
+		return fmt.Errorf("failed to set answer: %w", err)
+	}
+
+	select {
+	case <-a.connected:
+	case <-a.failed:
+		return errors.New("ICE connection failed")
+	case <-ctx.Done():
+		return fmt.Errorf("ICE connection not established: %w", ctx.Err())
+	}
+	return a.Keepalive(ctx, constants.AnchorStatusOnAir)
+}
+
+// Keepalive reports the status of the anchor, anchors silent longer than the idle timeout
+// of the gateway are evicted
+func (a *Anchor) Keepalive(ctx context.Context, status constants.AnchorStatus) error {
+	return a.peer.Notify(ctx, "keepalive", map[string]any{"status": status})
+}
+
+// Close leaves the room and closes the PeerConnection, the gateway closes the signaling
+// connection on leave
+func (a *Anchor) Close(ctx context.Context) {
+	if err := a.peer.Call(ctx, "leave", nil, nil); err != nil {
+		a.logger.Debug("Failed to leave", log.Error(err))
+	}
+	_ = a.peer.Close()
+	if a.pc != nil {
+		if err := a.pc.Close(); err != nil {
+			a.logger.Debug("Failed to close peer connection", log.Error(err))
+		}
+	}
+}
+
+func closeOnce(ch chan struct{}) {
+	select {
+	case <-ch:
+	default:
+		close(ch)
+	}
+}
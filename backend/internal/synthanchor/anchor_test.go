@@ -0,0 +1,207 @@
+package synthanchor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	wsrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/websocket"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const testToken = "anchor-token"
+
+// fakeGateway answers offers with a Pion PeerConnection standing in for Janus
+type fakeGateway struct {
+	mu       sync.Mutex
+	pin      string
+	statuses []string
+	left     bool
+	pc       *webrtc.PeerConnection
+	packets  chan *rtp.Packet
+}
+
+func (g *fakeGateway) OnVerify(r *http.Request) (*struct{}, bool, error) {
+	return &struct{}{}, r.URL.Query().Get("token") == testToken, nil
+}
+
+func (g *fakeGateway) OnConnect(jsonrpc.MethodContext[struct{}]) {}
+
+func (g *fakeGateway) OnDisconnect(jsonrpc.MethodContext[struct{}], int) {}
+
+func newFakeGateway(t *testing.T) (*fakeGateway, string) {
+	g := &fakeGateway{packets: make(chan *rtp.Packet, 100)}
+	server := wsrpc.NewServer[struct{}](g, nil, log.NewNop())
+
+	server.Def("join", func(_ jsonrpc.MethodContext[struct{}], params *json.RawMessage) (any, error) {
+		var p struct {
+			Pin string `json:"pin"`
+		}
+		if err := json.Unmarshal(*params, &p); err != nil {
+			return nil, err
+		}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.pin = p.Pin
+		return map[string]any{}, nil
+	})
+	server.Def("offer", func(_ jsonrpc.MethodContext[struct{}], params *json.RawMessage) (any, error) {
+		var p struct {
+			SDP webrtc.SessionDescription `json:"sdp"`
+		}
+		if err := json.Unmarshal(*params, &p); err != nil {
+			return nil, err
+		}
+		answer, err := g.answer(p.SDP)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"sdp": answer}, nil
+	})
+	server.Def("keepalive", func(_ jsonrpc.MethodContext[struct{}], params *json.RawMessage) (any, error) {
+		var p struct {
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal(*params, &p); err != nil {
+			return nil, err
+		}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.statuses = append(g.statuses, p.Status)
+		//nolint:nilnil
+		return nil, nil
+	})
+	server.Def("leave", func(jsonrpc.MethodContext[struct{}], *json.RawMessage) (any, error) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.left = true
+		return map[string]any{}, nil
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(server.HandleWebSocket))
+	t.Cleanup(func() {
+		ts.Close()
+		if g.pc != nil {
+			_ = g.pc.Close()
+		}
+	})
+	return g, "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func (g *fakeGateway) answer(offer webrtc.SessionDescription) (*webrtc.SessionDescription, error) {
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return nil, err
+	}
+	g.pc = pc
+	pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		for {
+			pkt, _, err := track.ReadRTP()
+			if err != nil {
+				return
+			}
+			g.packets <- pkt
+		}
+	})
+	if err := pc.SetRemoteDescription(offer); err != nil {
+		return nil, err
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return nil, err
+	}
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		return nil, err
+	}
+	<-gathered
+	return pc.LocalDescription(), nil
+}
+
+func (g *fakeGateway) state() (string, []string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.pin, append([]string(nil), g.statuses...), g.left
+}
+
+// oggStream writes frames of 20ms into an Ogg Opus stream, one per page
+func oggStream(t *testing.T, payloads ...[]byte) *bytes.Buffer {
+	var buf bytes.Buffer
+	w, err := oggwriter.NewWith(&buf, opusClockRate, opusChannels)
+	require.NoError(t, err)
+	for i, payload := range payloads {
+		require.NoError(t, w.WriteRTP(&rtp.Packet{
+			Header:  rtp.Header{SequenceNumber: uint16(i), Timestamp: uint32(i * 960)},
+			Payload: payload,
+		}))
+	}
+	require.NoError(t, w.Close())
+	return &buf
+}
+
+func TestAnchor(t *testing.T) {
+	logger := log.NewNop()
+
+	t.Run("rejected token", func(t *testing.T) {
+		_, wsURL := newFakeGateway(t)
+
+		_, err := Dial(context.Background(), wsURL, "wrong", logger)
+		assert.Error(t, err)
+	})
+
+	t.Run("audio before publish", func(t *testing.T) {
+		_, wsURL := newFakeGateway(t)
+		a, err := Dial(context.Background(), wsURL, testToken, logger)
+		require.NoError(t, err)
+		defer a.Close(context.Background())
+
+		err = a.PlayOgg(context.Background(), oggStream(t, []byte{1}))
+		assert.True(t, errors.Is(err, ErrNotPublished))
+	})
+
+	t.Run("joins, publishes and sends audio", func(t *testing.T) {
+		g, wsURL := newFakeGateway(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+
+		a, err := Dial(ctx, wsURL, testToken, logger)
+		require.NoError(t, err)
+		require.NoError(t, a.Join(ctx, "123456"))
+		require.NoError(t, a.Publish(ctx, nil))
+
+		// a second of frames, the first ones may be lost before DTLS completes on the receiving side
+		payloads := make([][]byte, 50)
+		for i := range payloads {
+			payloads[i] = []byte{0xf8, byte(i)}
+		}
+		require.NoError(t, a.PlayOgg(ctx, oggStream(t, payloads...)))
+
+		select {
+		case pkt := <-g.packets:
+			assert.Equal(t, byte(0xf8), pkt.Payload[0])
+		case <-ctx.Done():
+			t.Fatal("no audio received")
+		}
+
+		a.Close(ctx)
+		pin, statuses, left := g.state()
+		assert.Equal(t, "123456", pin)
+		assert.Equal(t, []string{string(constants.AnchorStatusOnAir)}, statuses)
+		assert.True(t, left)
+	})
+}
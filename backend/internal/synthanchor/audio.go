@@ -0,0 +1,89 @@
+package synthanchor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/pion/webrtc/v4/pkg/media"
+	"github.com/pion/webrtc/v4/pkg/media/oggreader"
+)
+
+// ErrNotPublished is returned when audio is sent before Publish
+var ErrNotPublished = errors.New("anchor not published")
+
+// PlayOgg publishes an Ogg Opus stream in real time until it ends, each page must hold a
+// single packet, as ffmpeg writes with -page_duration set to the frame duration
+func (a *Anchor) PlayOgg(ctx context.Context, r io.Reader) error {
+	if a.track == nil {
+		return ErrNotPublished
+	}
+	ogg, _, err := oggreader.NewWith(r)
+	if err != nil {
+		return fmt.Errorf("failed to read ogg: %w", err)
+	}
+
+	var granule uint64
+	next := time.Now()
+	for {
+		page, header, err := ogg.ParseNextPage()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read ogg page: %w", err)
+		}
+		// the granule position counts samples up to the end of the page, the tags page
+		// and the end of stream page carry none
+		if header.GranulePosition <= granule || len(page) == 0 {
+			continue
+		}
+		duration := time.Duration(header.GranulePosition-granule) * time.Second / opusClockRate
+		granule = header.GranulePosition
+		if err := a.track.WriteSample(media.Sample{Data: page, Duration: duration}); err != nil {
+			return fmt.Errorf("failed to write sample: %w", err)
+		}
+
+		next = next.Add(duration)
+		select {
+		case <-time.After(time.Until(next)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// PlayTone publishes a sine tone of freq Hz for duration, encoded to Opus by ffmpeg
+func (a *Anchor) PlayTone(ctx context.Context, freq int, duration time.Duration) error {
+	if a.track == nil {
+		return ErrNotPublished
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	source := fmt.Sprintf("sine=frequency=%d:sample_rate=%d:duration=%s",
+		freq, opusClockRate, strconv.FormatFloat(duration.Seconds(), 'f', 3, 64))
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-f", "lavfi", "-i", source,
+		"-c:a", "libopus", "-b:a", "48k", "-frame_duration", "20", "-page_duration", "20000",
+		"-f", "ogg", "pipe:1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to pipe ffmpeg: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	defer func() {
+		// stop ffmpeg first, it blocks writing to the pipe once the stream is no longer read
+		cancel()
+		_ = cmd.Wait()
+	}()
+
+	return a.PlayOgg(ctx, stdout)
+}
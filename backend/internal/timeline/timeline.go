@@ -0,0 +1,142 @@
+package timeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// Event types of the room timeline
+const (
+	EventCreated         = "created"
+	EventLiveStarted     = "liveStarted"
+	EventLiveReassigned  = "liveReassigned"
+	EventLiveStopped     = "liveStopped"
+	EventMixerAssigned   = "mixerAssigned"
+	EventFFmpegRestarted = "ffmpegRestarted"
+	EventEnding          = "ending"
+	EventEnded           = "ended"
+	EventFrozen          = "frozen"
+	EventUnfrozen        = "unfrozen"
+)
+
+const (
+	// MaxEvents bounds the timeline of a room, the oldest events are dropped
+	MaxEvents = 100
+
+	maxAppendAttempts = 5
+	recordTimeout     = 3 * time.Second
+)
+
+// Key is the etcd key of the timeline of a room
+func Key(prefixRooms, roomID string) string {
+	return fmt.Sprintf("%s%s/%s", prefixRooms, roomID, constants.RoomKeyTimeline)
+}
+
+// Writer appends events to room timelines in etcd on behalf of a service
+type Writer struct {
+	client      etcd.Client
+	prefixRooms string
+	source      string
+	now         func() time.Time
+	logger      *log.Logger
+}
+
+// NewWriter creates a Writer, source tells readers which service wrote the events
+func NewWriter(client etcd.Client, prefixRooms, source string, logger *log.Logger) *Writer {
+	return &Writer{
+		client:      client,
+		prefixRooms: prefixRooms,
+		source:      source,
+		now:         time.Now,
+		logger:      logger,
+	}
+}
+
+// Append adds an event to the timeline of the room. Events of rooms without meta are dropped,
+// so a late event does not bring back keys of a deleted room
+func (w *Writer) Append(ctx context.Context, roomID, eventType, detail string) error {
+	key := Key(w.prefixRooms, roomID)
+	metaKey := fmt.Sprintf("%s%s/%s", w.prefixRooms, roomID, constants.RoomKeyMeta)
+	event := etcdstate.TimelineEvent{
+		Type:   eventType,
+		At:     w.now().UTC(),
+		Source: w.source,
+		Detail: detail,
+	}
+
+	for range maxAppendAttempts {
+		resp, err := w.client.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to get timeline: %w", err)
+		}
+
+		var tl etcdstate.Timeline
+		var rev int64
+		if len(resp.Kvs) > 0 {
+			if err := json.Unmarshal(resp.Kvs[0].Value, &tl); err != nil {
+				return fmt.Errorf("failed to unmarshal timeline: %w", err)
+			}
+			rev = resp.Kvs[0].ModRevision
+		}
+
+		tl.Events = append(tl.Events, event)
+		if over := len(tl.Events) - MaxEvents; over > 0 {
+			tl.Events = tl.Events[over:]
+		}
+		data, err := json.Marshal(&tl)
+		if err != nil {
+			return fmt.Errorf("failed to marshal timeline: %w", err)
+		}
+
+		txnResp, err := w.client.Txn(ctx).
+			If(
+				clientv3.Compare(clientv3.CreateRevision(metaKey), ">", 0),
+				clientv3.Compare(clientv3.ModRevision(key), "=", rev),
+			).
+			Then(clientv3.OpPut(key, string(data))).
+			Commit()
+		if err != nil {
+			return fmt.Errorf("failed to append timeline: %w", err)
+		}
+		if txnResp.Succeeded {
+			return nil
+		}
+
+		metaResp, err := w.client.Get(ctx, metaKey, clientv3.WithCountOnly())
+		if err != nil {
+			return fmt.Errorf("failed to check room existence: %w", err)
+		}
+		if metaResp.Count == 0 {
+			return nil
+		}
+		// the timeline was appended meanwhile, retry on top of it
+	}
+	return fmt.Errorf("timeline of room %s kept changing", roomID)
+}
+
+// Record appends the event in the background, failures are only logged as the timeline is
+// informational. A nil Writer records nothing
+func (w *Writer) Record(roomID, eventType, detail string) {
+	if w == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+		defer cancel()
+		if err := w.Append(ctx, roomID, eventType, detail); err != nil {
+			w.logger.Warn("Failed to record room timeline event",
+				log.String("roomId", roomID),
+				log.String("event", eventType),
+				log.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,137 @@
+package timeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/mock/gomock"
+
+	etcdfakes "github.com/imtaco/audio-rtc-exp/internal/etcd/fakes"
+	etcdmocks "github.com/imtaco/audio-rtc-exp/internal/etcd/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type TimelineTestSuite struct {
+	suite.Suite
+	ctrl     *gomock.Controller
+	mockEtcd *etcdmocks.MockClient
+	writer   *Writer
+	now      time.Time
+	ctx      context.Context
+}
+
+func TestTimelineSuite(t *testing.T) {
+	suite.Run(t, new(TimelineTestSuite))
+}
+
+func (s *TimelineTestSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.mockEtcd = etcdmocks.NewMockClient(s.ctrl)
+	s.writer = NewWriter(s.mockEtcd, "/rooms/", "mixer:mixer-1", log.NewTest(s.T()))
+	s.now = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.writer.now = func() time.Time { return s.now }
+	s.ctx = context.Background()
+}
+
+func (s *TimelineTestSuite) TearDownTest() {
+	s.ctrl.Finish()
+}
+
+func (s *TimelineTestSuite) expectTimeline(tl *etcdstate.Timeline, rev int64) {
+	resp := &clientv3.GetResponse{}
+	if tl != nil {
+		data, err := json.Marshal(tl)
+		s.Require().NoError(err)
+		resp.Kvs = []*mvccpb.KeyValue{{Key: []byte("/rooms/room1/timeline"), Value: data, ModRevision: rev}}
+	}
+	s.mockEtcd.EXPECT().Get(gomock.Any(), "/rooms/room1/timeline").Return(resp, nil)
+}
+
+func (s *TimelineTestSuite) written(txn *etcdfakes.Txn) *etcdstate.Timeline {
+	s.Require().Len(txn.Ops, 1)
+	s.Require().True(txn.Ops[0].IsPut())
+	s.Equal("/rooms/room1/timeline", string(txn.Ops[0].KeyBytes()))
+	var tl etcdstate.Timeline
+	s.Require().NoError(json.Unmarshal(txn.Ops[0].ValueBytes(), &tl))
+	return &tl
+}
+
+func (s *TimelineTestSuite) TestAppend_First() {
+	s.expectTimeline(nil, 0)
+	txn := &etcdfakes.Txn{}
+	s.mockEtcd.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	s.Require().NoError(s.writer.Append(s.ctx, "room1", EventMixerAssigned, "mixer-1"))
+
+	s.Len(txn.Cmps, 2)
+	s.Equal([]etcdstate.TimelineEvent{
+		{Type: EventMixerAssigned, At: s.now, Source: "mixer:mixer-1", Detail: "mixer-1"},
+	}, s.written(txn).Events)
+}
+
+func (s *TimelineTestSuite) TestAppend_DropsOldest() {
+	tl := &etcdstate.Timeline{}
+	for i := range MaxEvents {
+		tl.Events = append(tl.Events, etcdstate.TimelineEvent{Type: EventFFmpegRestarted, Detail: fmt.Sprint(i)})
+	}
+	s.expectTimeline(tl, 7)
+	txn := &etcdfakes.Txn{}
+	s.mockEtcd.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	s.Require().NoError(s.writer.Append(s.ctx, "room1", EventEnded, ""))
+
+	events := s.written(txn).Events
+	s.Len(events, MaxEvents)
+	s.Equal("1", events[0].Detail)
+	s.Equal(EventEnded, events[MaxEvents-1].Type)
+}
+
+func (s *TimelineTestSuite) TestAppend_RetriesOnConflict() {
+	s.expectTimeline(nil, 0)
+	s.mockEtcd.EXPECT().Txn(gomock.Any()).Return(&etcdfakes.Txn{Failed: true})
+	s.mockEtcd.EXPECT().Get(gomock.Any(), "/rooms/room1/meta", gomock.Any()).
+		Return(&clientv3.GetResponse{Count: 1}, nil)
+
+	s.expectTimeline(&etcdstate.Timeline{Events: []etcdstate.TimelineEvent{{Type: EventCreated}}}, 3)
+	txn := &etcdfakes.Txn{}
+	s.mockEtcd.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	s.Require().NoError(s.writer.Append(s.ctx, "room1", EventLiveStarted, ""))
+
+	events := s.written(txn).Events
+	s.Require().Len(events, 2)
+	s.Equal(EventCreated, events[0].Type)
+	s.Equal(EventLiveStarted, events[1].Type)
+}
+
+func (s *TimelineTestSuite) TestAppend_RoomGone() {
+	s.expectTimeline(nil, 0)
+	s.mockEtcd.EXPECT().Txn(gomock.Any()).Return(&etcdfakes.Txn{Failed: true})
+	s.mockEtcd.EXPECT().Get(gomock.Any(), "/rooms/room1/meta", gomock.Any()).
+		Return(&clientv3.GetResponse{Count: 0}, nil)
+
+	s.NoError(s.writer.Append(s.ctx, "room1", EventFFmpegRestarted, "exited"))
+}
+
+func (s *TimelineTestSuite) TestAppend_KeepsConflicting() {
+	for range maxAppendAttempts {
+		s.expectTimeline(nil, 0)
+		s.mockEtcd.EXPECT().Txn(gomock.Any()).Return(&etcdfakes.Txn{Failed: true})
+		s.mockEtcd.EXPECT().Get(gomock.Any(), "/rooms/room1/meta", gomock.Any()).
+			Return(&clientv3.GetResponse{Count: 1}, nil)
+	}
+
+	s.Error(s.writer.Append(s.ctx, "room1", EventCreated, ""))
+}
+
+func (s *TimelineTestSuite) TestRecord_NilWriter() {
+	var w *Writer
+	s.NotPanics(func() { w.Record("room1", EventCreated, "") })
+}
@@ -0,0 +1,75 @@
+package tlsid
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Enforcement modes of mutual TLS between services
+const (
+	// ModeOff serves and calls without TLS identities
+	ModeOff = "off"
+	// ModePermissive serves TLS and verifies client certificates when presented, for rolling
+	// out mTLS while some clients do not present one yet
+	ModePermissive = "permissive"
+	// ModeStrict requires every client to present a certificate of an allowed peer
+	ModeStrict = "strict"
+)
+
+type Config struct {
+	Mode string `mapstructure:"mode"`
+	// TrustDomain is the trust domain of the SPIFFE IDs, spiffe://<trust_domain>/<service>
+	TrustDomain string `mapstructure:"trust_domain"`
+	// Service is the name of this service in its SPIFFE ID, e.g. rooms
+	Service string `mapstructure:"service"`
+	// AllowedPeers are the services allowed to call this one, empty allows any service of the
+	// trust domain
+	AllowedPeers []string `mapstructure:"allowed_peers"`
+
+	// CertFile, KeyFile and CAFile are PEM files of the certificate, its key and the CA bundle
+	// peers are verified against
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	CAFile   string `mapstructure:"ca_file"`
+	// EtcdPrefix reads the certificate from etcd instead of files, the certificate and key of
+	// the service at <prefix><service>/cert and <prefix><service>/key, the CA bundle at <prefix>ca
+	EtcdPrefix string `mapstructure:"etcd_prefix"`
+	// ReloadInterval is how often the certificate is checked for rotation, 0 loads it once
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("mode"), ModeOff)
+	v.SetDefault(p("trust_domain"), "audio-rtc.local")
+	v.SetDefault(p("service"), "")
+	v.SetDefault(p("allowed_peers"), []string{})
+	v.SetDefault(p("cert_file"), "")
+	v.SetDefault(p("key_file"), "")
+	v.SetDefault(p("ca_file"), "")
+	v.SetDefault(p("etcd_prefix"), "")
+	v.SetDefault(p("reload_interval"), time.Minute)
+}
+
+// Enabled reports whether the service uses TLS identities
+func (c *Config) Enabled() bool {
+	return c.Mode != "" && c.Mode != ModeOff
+}
+
+func (c *Config) validate() error {
+	switch c.Mode {
+	case ModePermissive, ModeStrict:
+	default:
+		return fmt.Errorf("unknown mTLS mode %q", c.Mode)
+	}
+	if c.TrustDomain == "" || c.Service == "" {
+		return fmt.Errorf("mTLS requires trust_domain and service")
+	}
+	if c.EtcdPrefix == "" && (c.CertFile == "" || c.KeyFile == "" || c.CAFile == "") {
+		return fmt.Errorf("mTLS requires cert_file, key_file and ca_file, or etcd_prefix")
+	}
+	return nil
+}
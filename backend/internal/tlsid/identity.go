@@ -0,0 +1,255 @@
+// Package tlsid provides mutual TLS between services with SPIFFE-style identities. Every
+// service holds a certificate carrying spiffe://<trust domain>/<service> as URI SAN, issued by
+// a CA shared by the services. Certificates rotate from files or etcd keys without restart.
+package tlsid
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const spiffeScheme = "spiffe"
+
+// ID returns the SPIFFE ID of a service
+func ID(trustDomain, service string) string {
+	return (&url.URL{Scheme: spiffeScheme, Host: trustDomain, Path: "/" + service}).String()
+}
+
+// material is the parsed certificate and CA pool in use
+type material struct {
+	pem   *pemBundle
+	cert  *tls.Certificate
+	roots *x509.CertPool
+}
+
+// Identity is the TLS identity of a service, it serves certificates to peers and verifies
+// theirs. A nil Identity means mTLS is off
+type Identity struct {
+	cfg      *Config
+	src      source
+	material atomic.Pointer[material]
+	cancel   context.CancelFunc
+	stopped  chan struct{}
+	logger   *log.Logger
+}
+
+// New loads the identity of the service, nil when mTLS is off. kv is only used with an etcd prefix
+func New(ctx context.Context, cfg *Config, kv etcd.KV, logger *log.Logger) (*Identity, error) {
+	if !cfg.Enabled() {
+		//nolint:nilnil
+		return nil, nil
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	var src source = &fileSource{certFile: cfg.CertFile, keyFile: cfg.KeyFile, caFile: cfg.CAFile}
+	if cfg.EtcdPrefix != "" {
+		src = newEtcdSource(kv, cfg.EtcdPrefix, cfg.Service)
+	}
+	i := &Identity{
+		cfg:     cfg,
+		src:     src,
+		stopped: make(chan struct{}),
+		logger:  logger,
+	}
+	if _, err := i.reload(ctx); err != nil {
+		return nil, err
+	}
+	logger.Info("Loaded TLS identity",
+		log.String("id", i.ID()),
+		log.String("mode", cfg.Mode))
+	return i, nil
+}
+
+// ID returns the SPIFFE ID of the service
+func (i *Identity) ID() string {
+	return ID(i.cfg.TrustDomain, i.cfg.Service)
+}
+
+// Start checks the certificate for rotation every reload interval until stopped
+func (i *Identity) Start(ctx context.Context) error {
+	if i.cfg.ReloadInterval <= 0 {
+		return nil
+	}
+	ctx, i.cancel = context.WithCancel(ctx)
+	go i.loop(ctx)
+	return nil
+}
+
+// Stop stops checking for rotation
+func (i *Identity) Stop() {
+	if i.cancel != nil {
+		i.cancel()
+		<-i.stopped
+	}
+}
+
+func (i *Identity) loop(ctx context.Context) {
+	ticker := time.NewTicker(i.cfg.ReloadInterval)
+	defer close(i.stopped)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// keep the loaded certificate while the new one is being written
+			rotated, err := i.reload(ctx)
+			if err != nil {
+				i.logger.Warn("Failed to reload TLS identity", log.Error(err))
+				continue
+			}
+			if rotated {
+				i.logger.Info("Rotated TLS identity", log.String("id", i.ID()))
+			}
+		}
+	}
+}
+
+// reload reads the material again and swaps it in when it changed
+func (i *Identity) reload(ctx context.Context) (bool, error) {
+	b, err := i.src.read(ctx)
+	if err != nil {
+		return false, err
+	}
+	if cur := i.material.Load(); cur != nil &&
+		bytes.Equal(cur.pem.cert, b.cert) && bytes.Equal(cur.pem.key, b.key) && bytes.Equal(cur.pem.ca, b.ca) {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(b.cert, b.key)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return false, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	if id := spiffeID(leaf); id != i.ID() {
+		return false, fmt.Errorf("certificate is issued to %q, not %q", id, i.ID())
+	}
+	cert.Leaf = leaf
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(b.ca) {
+		return false, errors.New("no CA certificate found")
+	}
+	i.material.Store(&material{pem: b, cert: &cert, roots: roots})
+	return true, nil
+}
+
+// ServerConfig returns the TLS config of servers of the service, client certificates are
+// verified against allowed peers and required in strict mode
+func (i *Identity) ServerConfig() *tls.Config {
+	clientAuth := tls.RequestClientCert
+	if i.cfg.Mode == ModeStrict {
+		clientAuth = tls.RequireAnyClientCert
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return i.material.Load().cert, nil
+		},
+		ClientAuth: clientAuth,
+		// chains are verified by verifyPeer against the CA in use, which rotates
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return nil
+			}
+			return i.verifyPeer(rawCerts, x509.ExtKeyUsageClientAuth, i.cfg.AllowedPeers)
+		},
+	}
+}
+
+// ClientConfig returns the TLS config of calls to the service named peer, the server must
+// present the certificate of that service
+func (i *Identity) ClientConfig(peer string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return i.material.Load().cert, nil
+		},
+		// #nosec G402 -- the chain and SPIFFE ID are verified by verifyPeer, host names are not used
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return i.verifyPeer(rawCerts, x509.ExtKeyUsageServerAuth, []string{peer})
+		},
+	}
+}
+
+// ClientConfig returns the TLS config of calls to peer, nil when id is nil so clients keep
+// their defaults
+func ClientConfig(id *Identity, peer string) *tls.Config {
+	if id == nil {
+		return nil
+	}
+	return id.ClientConfig(peer)
+}
+
+// verifyPeer verifies the chain of the peer and that its SPIFFE ID is of an allowed service,
+// any service of the trust domain when allowed is empty
+func (i *Identity) verifyPeer(rawCerts [][]byte, usage x509.ExtKeyUsage, allowed []string) error {
+	if len(rawCerts) == 0 {
+		return errors.New("peer presented no certificate")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("failed to parse peer certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         i.material.Load().roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}); err != nil {
+		return fmt.Errorf("failed to verify peer certificate: %w", err)
+	}
+
+	id := spiffeID(certs[0])
+	u, err := url.Parse(id)
+	if id == "" || err != nil || u.Host != i.cfg.TrustDomain {
+		return fmt.Errorf("peer %q is not of trust domain %s", id, i.cfg.TrustDomain)
+	}
+	if len(allowed) > 0 && !slices.Contains(allowed, u.Path[1:]) {
+		return fmt.Errorf("peer %s is not allowed", id)
+	}
+	return nil
+}
+
+// PeerID returns the SPIFFE ID of the client of a TLS connection, empty without a client
+// certificate. Servers from ServerConfig only accept certificates verified against allowed peers
+func PeerID(state *tls.ConnectionState) string {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return ""
+	}
+	return spiffeID(state.PeerCertificates[0])
+}
+
+// spiffeID returns the SPIFFE ID of the certificate, empty when it has none
+func spiffeID(cert *x509.Certificate) string {
+	for _, u := range cert.URIs {
+		if u.Scheme == spiffeScheme && len(u.Path) > 1 {
+			return u.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,266 @@
+package tlsid
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	stdlog "log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	etcdfakes "github.com/imtaco/audio-rtc-exp/internal/etcd/fakes"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const testTrustDomain = "test.local"
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key of the service, with the serial
+func (ca *testCA) issue(t *testing.T, trustDomain, service string, serial int64) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	id, err := url.Parse(ID(trustDomain, service))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{id},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// newFileIdentity writes the certificate of the service to files and loads it
+func newFileIdentity(t *testing.T, ca *testCA, mode, service string, allowed ...string) (*Identity, *Config) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &Config{
+		Mode:         mode,
+		TrustDomain:  testTrustDomain,
+		Service:      service,
+		AllowedPeers: allowed,
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		CAFile:       filepath.Join(dir, "ca.crt"),
+	}
+	writeFiles(t, cfg, ca, service, 1)
+	id, err := New(context.Background(), cfg, nil, log.NewTest(t))
+	require.NoError(t, err)
+	return id, cfg
+}
+
+func writeFiles(t *testing.T, cfg *Config, ca *testCA, service string, serial int64) {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, testTrustDomain, service, serial)
+	require.NoError(t, os.WriteFile(cfg.CertFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(cfg.KeyFile, keyPEM, 0o600))
+	require.NoError(t, os.WriteFile(cfg.CAFile, ca.pem, 0o600))
+}
+
+// serveTLS serves with the server config of the identity, StartTLS would replace its certificate
+func serveTLS(t *testing.T, id *Identity) string {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.Config.ErrorLog = stdlog.New(io.Discard, "", 0)
+	srv.Listener = tls.NewListener(srv.Listener, id.ServerConfig())
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return "https://" + srv.Listener.Addr().String()
+}
+
+func call(url string, tlsConfig *tls.Config) error {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}, Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestNew(t *testing.T) {
+	ca := newTestCA(t)
+
+	t.Run("off", func(t *testing.T) {
+		id, err := New(context.Background(), &Config{Mode: ModeOff}, nil, log.NewTest(t))
+		require.NoError(t, err)
+		assert.Nil(t, id)
+		assert.Nil(t, ClientConfig(id, "rooms"))
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := New(context.Background(), &Config{Mode: "loose"}, nil, log.NewTest(t))
+		assert.Error(t, err)
+		_, err = New(context.Background(), &Config{Mode: ModeStrict, TrustDomain: testTrustDomain, Service: "rooms"}, nil, log.NewTest(t))
+		assert.Error(t, err)
+	})
+
+	t.Run("certificate of another service", func(t *testing.T) {
+		_, cfg := newFileIdentity(t, ca, ModeStrict, "rooms")
+		cfg.Service = "users"
+		_, err := New(context.Background(), cfg, nil, log.NewTest(t))
+		assert.ErrorContains(t, err, "spiffe://test.local/rooms")
+	})
+
+	t.Run("from etcd", func(t *testing.T) {
+		kv := etcdfakes.NewMemKV()
+		certPEM, keyPEM := ca.issue(t, testTrustDomain, "rooms", 1)
+		ctx := context.Background()
+		_, err := kv.Put(ctx, "/tls/rooms/cert", string(certPEM))
+		require.NoError(t, err)
+		_, err = kv.Put(ctx, "/tls/rooms/key", string(keyPEM))
+		require.NoError(t, err)
+
+		cfg := &Config{Mode: ModeStrict, TrustDomain: testTrustDomain, Service: "rooms", EtcdPrefix: "/tls/"}
+		_, err = New(ctx, cfg, kv, log.NewTest(t))
+		assert.ErrorContains(t, err, "/tls/ca")
+
+		_, err = kv.Put(ctx, "/tls/ca", string(ca.pem))
+		require.NoError(t, err)
+		id, err := New(ctx, cfg, kv, log.NewTest(t))
+		require.NoError(t, err)
+		assert.Equal(t, "spiffe://test.local/rooms", id.ID())
+	})
+}
+
+func TestHandshake(t *testing.T) {
+	ca := newTestCA(t)
+	rooms, _ := newFileIdentity(t, ca, ModeStrict, "rooms", "wsgateway")
+	gateway, _ := newFileIdentity(t, ca, ModeStrict, "wsgateway")
+	mixers, _ := newFileIdentity(t, ca, ModeStrict, "mixers")
+	srv := serveTLS(t, rooms)
+
+	t.Run("allowed peer", func(t *testing.T) {
+		assert.NoError(t, call(srv, gateway.ClientConfig("rooms")))
+	})
+
+	t.Run("peer not allowed by server", func(t *testing.T) {
+		assert.Error(t, call(srv, mixers.ClientConfig("rooms")))
+	})
+
+	t.Run("server of another service", func(t *testing.T) {
+		assert.ErrorContains(t, call(srv, gateway.ClientConfig("users")), "not allowed")
+	})
+
+	t.Run("other trust domain", func(t *testing.T) {
+		certPEM, keyPEM := ca.issue(t, "other.local", "wsgateway", 1)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		require.NoError(t, err)
+		// #nosec G402 -- test client presenting a certificate of another trust domain
+		assert.Error(t, call(srv, &tls.Config{Certificates: []tls.Certificate{cert}, InsecureSkipVerify: true}))
+	})
+
+	t.Run("other CA", func(t *testing.T) {
+		stranger, _ := newFileIdentity(t, newTestCA(t), ModeStrict, "wsgateway")
+		assert.Error(t, call(srv, stranger.ClientConfig("rooms")))
+	})
+
+	t.Run("strict requires a certificate", func(t *testing.T) {
+		// #nosec G402 -- test client without identity
+		assert.Error(t, call(srv, &tls.Config{InsecureSkipVerify: true}))
+	})
+
+	t.Run("permissive allows no certificate", func(t *testing.T) {
+		permissive, _ := newFileIdentity(t, ca, ModePermissive, "rooms", "wsgateway")
+		psrv := serveTLS(t, permissive)
+		// #nosec G402 -- test client without identity
+		assert.NoError(t, call(psrv, &tls.Config{InsecureSkipVerify: true}))
+		assert.NoError(t, call(psrv, gateway.ClientConfig("rooms")))
+		assert.Error(t, call(psrv, mixers.ClientConfig("rooms")))
+	})
+}
+
+func TestReload(t *testing.T) {
+	ca := newTestCA(t)
+	id, cfg := newFileIdentity(t, ca, ModeStrict, "rooms")
+	serial := func() int64 { return id.material.Load().cert.Leaf.SerialNumber.Int64() }
+	ctx := context.Background()
+
+	rotated, err := id.reload(ctx)
+	require.NoError(t, err)
+	assert.False(t, rotated)
+
+	writeFiles(t, cfg, ca, "rooms", 2)
+	rotated, err = id.reload(ctx)
+	require.NoError(t, err)
+	assert.True(t, rotated)
+	assert.Equal(t, int64(2), serial())
+
+	// a broken key pair keeps the loaded certificate
+	require.NoError(t, os.WriteFile(cfg.KeyFile, []byte("broken"), 0o600))
+	_, err = id.reload(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, int64(2), serial())
+
+	// a certificate of another service is refused too
+	writeFiles(t, cfg, ca, "users", 3)
+	_, err = id.reload(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, int64(2), serial())
+}
+
+func TestStartStop(t *testing.T) {
+	ca := newTestCA(t)
+	id, cfg := newFileIdentity(t, ca, ModeStrict, "rooms")
+	cfg.ReloadInterval = 10 * time.Millisecond
+	require.NoError(t, id.Start(context.Background()))
+
+	writeFiles(t, cfg, ca, "rooms", 2)
+	assert.Eventually(t, func() bool {
+		return id.material.Load().cert.Leaf.SerialNumber.Int64() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	id.Stop()
+
+	// never started
+	other, _ := newFileIdentity(t, ca, ModeStrict, "rooms")
+	other.Stop()
+}
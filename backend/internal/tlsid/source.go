@@ -0,0 +1,77 @@
+package tlsid
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+)
+
+// pemBundle is the PEM material of an identity as read from its source
+type pemBundle struct {
+	cert []byte
+	key  []byte
+	ca   []byte
+}
+
+// source reads the PEM material of the identity, read again on every reload
+type source interface {
+	read(ctx context.Context) (*pemBundle, error)
+}
+
+type fileSource struct {
+	certFile string
+	keyFile  string
+	caFile   string
+}
+
+func (s *fileSource) read(context.Context) (*pemBundle, error) {
+	var b pemBundle
+	for _, f := range []struct {
+		name string
+		data *[]byte
+	}{{s.certFile, &b.cert}, {s.keyFile, &b.key}, {s.caFile, &b.ca}} {
+		data, err := os.ReadFile(f.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.name, err)
+		}
+		*f.data = data
+	}
+	return &b, nil
+}
+
+// etcdSource reads the material from etcd keys, as a secret discovery service pushes them
+type etcdSource struct {
+	kv      etcd.KV
+	certKey string
+	keyKey  string
+	caKey   string
+}
+
+func newEtcdSource(kv etcd.KV, prefix, service string) *etcdSource {
+	return &etcdSource{
+		kv:      kv,
+		certKey: prefix + service + "/cert",
+		keyKey:  prefix + service + "/key",
+		caKey:   prefix + "ca",
+	}
+}
+
+func (s *etcdSource) read(ctx context.Context) (*pemBundle, error) {
+	var b pemBundle
+	for _, k := range []struct {
+		key  string
+		data *[]byte
+	}{{s.certKey, &b.cert}, {s.keyKey, &b.key}, {s.caKey, &b.ca}} {
+		resp, err := s.kv.Get(ctx, k.key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", k.key, err)
+		}
+		if len(resp.Kvs) == 0 {
+			return nil, fmt.Errorf("no %s in etcd", k.key)
+		}
+		*k.data = resp.Kvs[0].Value
+	}
+	return &b, nil
+}
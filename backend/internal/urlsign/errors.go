@@ -0,0 +1,9 @@
+package urlsign
+
+import "github.com/imtaco/audio-rtc-exp/internal/errors"
+
+const (
+	ErrNoSignature      errors.Code = "no signature"
+	ErrInvalidSignature errors.Code = "invalid signature"
+	ErrExpired          errors.Code = "signature expired"
+)
@@ -0,0 +1,98 @@
+package urlsign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+)
+
+// Query params carrying the signature
+const (
+	ParamExpires   = "expires"
+	ParamSignature = "sig"
+)
+
+// Signer grants time limited access to a resource (e.g. a room) with HMAC signed query params.
+// Unlike a bearer token, the signature can be shared as part of a plain URL.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func New(secret string, ttl time.Duration) *Signer {
+	return &Signer{
+		secret: []byte(secret),
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// WithTTL returns a signer of the same secret granting access for ttl
+func (s *Signer) WithTTL(ttl time.Duration) *Signer {
+	c := *s
+	c.ttl = ttl
+	return &c
+}
+
+// Sign returns the query params granting access to resource until now + ttl
+func (s *Signer) Sign(resource string) url.Values {
+	expires := strconv.FormatInt(s.now().Add(s.ttl).Unix(), 10)
+	return url.Values{
+		ParamExpires:   {expires},
+		ParamSignature: {s.mac(resource, expires)},
+	}
+}
+
+// SignURL appends the signature of resource to rawURL
+func (s *Signer) SignURL(rawURL, resource string) string {
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + s.Sign(resource).Encode()
+}
+
+// Verify checks the signature in query is valid for resource and not expired
+func (s *Signer) Verify(resource string, query url.Values) error {
+	expires := query.Get(ParamExpires)
+	sig := query.Get(ParamSignature)
+	if expires == "" || sig == "" {
+		return ErrNoSignature
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(s.mac(resource, expires))) {
+		return ErrInvalidSignature
+	}
+
+	ts, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errors.Wrap(ErrInvalidSignature, err, "malformed expires")
+	}
+	if s.now().Unix() > ts {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Query keeps only the signature params of query, to carry a signature over to another URL
+func Query(query url.Values) url.Values {
+	return url.Values{
+		ParamExpires:   {query.Get(ParamExpires)},
+		ParamSignature: {query.Get(ParamSignature)},
+	}
+}
+
+func (s *Signer) mac(resource, expires string) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(resource))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(expires))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
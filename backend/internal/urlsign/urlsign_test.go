@@ -0,0 +1,98 @@
+package urlsign
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+)
+
+func newTestSigner(now time.Time) *Signer {
+	s := New("secret", time.Hour)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := newTestSigner(now)
+
+	query := s.Sign("room-1")
+	assert.Equal(t, "1700003600", query.Get(ParamExpires))
+	assert.NotEmpty(t, query.Get(ParamSignature))
+
+	t.Run("valid", func(t *testing.T) {
+		require.NoError(t, s.Verify("room-1", query))
+	})
+
+	t.Run("other resource", func(t *testing.T) {
+		err := s.Verify("room-2", query)
+		assert.True(t, errors.Is(err, ErrInvalidSignature))
+	})
+
+	t.Run("other secret", func(t *testing.T) {
+		other := New("other", time.Hour)
+		other.now = s.now
+		assert.True(t, errors.Is(other.Verify("room-1", query), ErrInvalidSignature))
+	})
+
+	t.Run("tampered expires", func(t *testing.T) {
+		tampered := url.Values{
+			ParamExpires:   {"1800000000"},
+			ParamSignature: {query.Get(ParamSignature)},
+		}
+		assert.True(t, errors.Is(s.Verify("room-1", tampered), ErrInvalidSignature))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		later := newTestSigner(now.Add(time.Hour + time.Second))
+		assert.True(t, errors.Is(later.Verify("room-1", query), ErrExpired))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		assert.True(t, errors.Is(s.Verify("room-1", url.Values{}), ErrNoSignature))
+	})
+}
+
+func TestSignURL(t *testing.T) {
+	s := newTestSigner(time.Unix(1700000000, 0))
+
+	signed := s.SignURL("http://localhost:8080/hls/room-1/stream.m3u8", "room-1")
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "/hls/room-1/stream.m3u8", u.Path)
+	require.NoError(t, s.Verify("room-1", u.Query()))
+
+	signed = s.SignURL("http://localhost/stream.m3u8?foo=bar", "room-1")
+	u, err = url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "bar", u.Query().Get("foo"))
+	require.NoError(t, s.Verify("room-1", u.Query()))
+}
+
+func TestQuery(t *testing.T) {
+	s := newTestSigner(time.Unix(1700000000, 0))
+
+	query := s.Sign("room-1")
+	query.Set("foo", "bar")
+
+	carried := Query(query)
+	assert.Empty(t, carried.Get("foo"))
+	require.NoError(t, s.Verify("room-1", carried))
+}
+
+func TestWithTTL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := newTestSigner(now)
+
+	query := s.WithTTL(24 * time.Hour).Sign("room-1")
+	assert.Equal(t, "1700086400", query.Get(ParamExpires))
+	require.NoError(t, s.Verify("room-1", query))
+
+	// the original signer keeps its ttl
+	assert.Equal(t, "1700003600", s.Sign("room-1").Get(ParamExpires))
+}
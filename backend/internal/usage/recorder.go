@@ -0,0 +1,131 @@
+package usage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const flushTimeout = 5 * time.Second
+
+// Recorder accumulates the bytes of rooms, they are buffered and added to the hour buckets every
+// flush interval
+type Recorder struct {
+	client        redis.Cmdable
+	keyPrefix     string
+	retention     time.Duration
+	flushInterval time.Duration
+	clock         clockwork.Clock
+
+	mu      sync.Mutex
+	pending map[string]map[Kind]int64 // bucket key -> bytes per kind
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	logger *log.Logger
+}
+
+func NewRecorder(client redis.Cmdable, cfg *Config, logger *log.Logger) *Recorder {
+	return &Recorder{
+		client:        client,
+		keyPrefix:     cfg.KeyPrefix,
+		retention:     cfg.Retention,
+		flushInterval: cfg.FlushInterval,
+		clock:         clockwork.NewRealClock(),
+		pending:       make(map[string]map[Kind]int64),
+		logger:        logger,
+	}
+}
+
+// Add accounts bytes of the kind to the room in the current hour
+func (r *Recorder) Add(roomID string, kind Kind, bytes int64) {
+	if bytes <= 0 {
+		return
+	}
+	k := key(r.keyPrefix, roomID, r.clock.Now().Truncate(bucket))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addLocked(k, kind, bytes)
+}
+
+func (r *Recorder) addLocked(k string, kind Kind, bytes int64) {
+	kinds, ok := r.pending[k]
+	if !ok {
+		kinds = make(map[Kind]int64)
+		r.pending[k] = kinds
+	}
+	kinds[kind] += bytes
+}
+
+func (r *Recorder) Start(ctx context.Context) error {
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		ticker := r.clock.NewTicker(r.flushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.Chan():
+				if err := r.flush(ctx); err != nil {
+					r.logger.Error("Failed to account usage", log.Error(err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop adds the buffered bytes to their buckets
+func (r *Recorder) Stop() error {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	return r.flush(ctx)
+}
+
+// flush adds the buffered bytes to their buckets in a transaction, they are buffered again when
+// it fails so usage is not lost to a Redis outage
+func (r *Recorder) flush(ctx context.Context) error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[string]map[Kind]int64)
+	r.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for k, kinds := range pending {
+			for kind, bytes := range kinds {
+				pipe.HIncrBy(ctx, k, string(kind), bytes)
+			}
+			pipe.Expire(ctx, k, r.retention)
+		}
+		return nil
+	})
+	if err != nil {
+		r.mu.Lock()
+		for k, kinds := range pending {
+			for kind, bytes := range kinds {
+				r.addLocked(k, kind, bytes)
+			}
+		}
+		r.mu.Unlock()
+	}
+	return err
+}
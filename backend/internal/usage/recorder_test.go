@@ -0,0 +1,119 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jonboulle/clockwork"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type RecorderTestSuite struct {
+	suite.Suite
+	miniRedis *miniredis.Miniredis
+	client    *redis.Client
+	clock     *clockwork.FakeClock
+	cfg       *Config
+	ctx       context.Context
+}
+
+func TestRecorderSuite(t *testing.T) {
+	suite.Run(t, new(RecorderTestSuite))
+}
+
+func (s *RecorderTestSuite) SetupTest() {
+	mr, err := miniredis.Run()
+	s.Require().NoError(err)
+	s.miniRedis = mr
+	s.client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	s.clock = clockwork.NewFakeClockAt(time.Date(2026, 1, 1, 12, 30, 0, 0, time.UTC))
+	s.cfg = &Config{
+		KeyPrefix:     "usage:",
+		Retention:     24 * time.Hour,
+		FlushInterval: 10 * time.Second,
+	}
+	s.ctx = context.Background()
+}
+
+func (s *RecorderTestSuite) TearDownTest() {
+	s.client.Close()
+	s.miniRedis.Close()
+}
+
+func (s *RecorderTestSuite) newRecorder() *Recorder {
+	r := NewRecorder(s.client, s.cfg, log.NewNop())
+	r.clock = s.clock
+	return r
+}
+
+func (s *RecorderTestSuite) TestUsage_AggregatesPerHour() {
+	r := s.newRecorder()
+	r.Add("room-1", KindForwarded, 1000)
+	r.Add("room-1", KindForwarded, 500)
+	r.Add("room-1", KindHLS, 200)
+	r.Add("room-2", KindHLS, 7)
+	s.Require().NoError(r.flush(s.ctx))
+
+	s.clock.Advance(time.Hour)
+	r.Add("room-1", KindHLS, 300)
+	s.Require().NoError(r.flush(s.ctx))
+
+	from := time.Date(2026, 1, 1, 11, 15, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 1, 13, 45, 0, 0, time.UTC)
+	usage, err := NewReader(s.client, s.cfg).Usage(s.ctx, "room-1", from, to)
+	s.Require().NoError(err)
+	s.Equal(&RoomUsage{
+		RoomID: "room-1",
+		From:   time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC),
+		To:     time.Date(2026, 1, 1, 14, 0, 0, 0, time.UTC),
+		Hours: []HourUsage{
+			{Hour: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC), ForwardedBytes: 1500, HLSBytes: 200},
+			{Hour: time.Date(2026, 1, 1, 13, 0, 0, 0, time.UTC), HLSBytes: 300},
+		},
+		ForwardedBytes: 1500,
+		HLSBytes:       500,
+	}, usage)
+
+	ttl := s.miniRedis.TTL(key("usage:", "room-1", time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)))
+	s.Equal(24*time.Hour, ttl)
+}
+
+func (s *RecorderTestSuite) TestUsage_NoUsage() {
+	usage, err := NewReader(s.client, s.cfg).Usage(s.ctx, "room-1", s.clock.Now().Add(-time.Hour), s.clock.Now())
+	s.Require().NoError(err)
+	s.Empty(usage.Hours)
+	s.Zero(usage.ForwardedBytes)
+	s.Zero(usage.HLSBytes)
+}
+
+func (s *RecorderTestSuite) TestUsage_InvalidRange() {
+	reader := NewReader(s.client, s.cfg)
+	now := s.clock.Now()
+
+	_, err := reader.Usage(s.ctx, "room-1", now, now.Add(-time.Hour))
+	s.ErrorIs(err, ErrInvalidRange)
+	_, err = reader.Usage(s.ctx, "room-1", now.Add(-32*24*time.Hour), now)
+	s.ErrorIs(err, ErrInvalidRange)
+}
+
+func (s *RecorderTestSuite) TestFlush_KeepsBytesOnFailure() {
+	r := s.newRecorder()
+	r.Add("room-1", KindForwarded, 1000)
+	r.Add("room-1", KindForwarded, 0)
+
+	s.miniRedis.SetError("LOADING")
+	s.Require().Error(r.flush(s.ctx))
+	s.miniRedis.SetError("")
+
+	r.Add("room-1", KindForwarded, 24)
+	s.Require().NoError(r.Stop())
+
+	usage, err := NewReader(s.client, s.cfg).Usage(s.ctx, "room-1", s.clock.Now(), s.clock.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	s.Equal(int64(1024), usage.ForwardedBytes)
+}
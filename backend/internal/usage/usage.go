@@ -0,0 +1,132 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// Usage is the bandwidth of rooms, accumulated in one Redis hash per room and hour:
+//
+//	<prefix><roomId>:<hour>   bytes per kind
+const bucket = time.Hour
+
+// maxRange bounds the hours read at once
+const maxRange = 31 * 24 * time.Hour
+
+// Kind is the source of accounted bytes
+type Kind string
+
+const (
+	// KindForwarded is the RTP forwarded by Janus to the mixer of the room
+	KindForwarded Kind = "forwarded"
+	// KindHLS is the playlists, segments and keys served by the hlsserver
+	KindHLS Kind = "hls"
+)
+
+// ErrInvalidRange is returned for ranges ending before they start or longer than 31 days
+var ErrInvalidRange = errors.New("invalid usage range")
+
+// HourUsage is the bytes of a room within an hour
+type HourUsage struct {
+	Hour           time.Time `json:"hour"`
+	ForwardedBytes int64     `json:"forwardedBytes"`
+	HLSBytes       int64     `json:"hlsBytes"`
+}
+
+// RoomUsage is the bytes of a room per hour within [From, To), hours without usage are left out
+type RoomUsage struct {
+	RoomID         string      `json:"roomId"`
+	From           time.Time   `json:"from"`
+	To             time.Time   `json:"to"`
+	Hours          []HourUsage `json:"hours"`
+	ForwardedBytes int64       `json:"forwardedBytes"`
+	HLSBytes       int64       `json:"hlsBytes"`
+}
+
+type Config struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	KeyPrefix string `mapstructure:"key_prefix"`
+	// Retention bounds the lifetime of hour buckets, usage is to be exported within it
+	Retention time.Duration `mapstructure:"retention"`
+	// FlushInterval is the period bytes are buffered before being added to the buckets
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), false)
+	v.SetDefault(p("key_prefix"), "usage:")
+	v.SetDefault(p("retention"), 90*24*time.Hour)
+	v.SetDefault(p("flush_interval"), 10*time.Second)
+}
+
+func key(prefix, roomID string, hour time.Time) string {
+	return fmt.Sprintf("%s%s:%d", prefix, roomID, hour.Unix()/int64(bucket/time.Second))
+}
+
+// Reader reads the usage of rooms
+type Reader struct {
+	client    redis.Cmdable
+	keyPrefix string
+}
+
+func NewReader(client redis.Cmdable, cfg *Config) *Reader {
+	return &Reader{
+		client:    client,
+		keyPrefix: cfg.KeyPrefix,
+	}
+}
+
+// Usage returns the usage of the room in the hours within [from, to), from is rounded down and
+// to up to hours
+func (r *Reader) Usage(ctx context.Context, roomID string, from, to time.Time) (*RoomUsage, error) {
+	from = from.UTC().Truncate(bucket)
+	if end := to.UTC().Truncate(bucket); end.Before(to) {
+		to = end.Add(bucket)
+	} else {
+		to = end
+	}
+	if !to.After(from) || to.Sub(from) > maxRange {
+		return nil, ErrInvalidRange
+	}
+
+	var hours []time.Time
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, 0, to.Sub(from)/bucket)
+	for hour := from; hour.Before(to); hour = hour.Add(bucket) {
+		hours = append(hours, hour)
+		cmds = append(cmds, pipe.HGetAll(ctx, key(r.keyPrefix, roomID, hour)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read usage: %w", err)
+	}
+
+	usage := &RoomUsage{RoomID: roomID, From: from, To: to, Hours: []HourUsage{}}
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 {
+			continue
+		}
+		hour := HourUsage{
+			Hour:           hours[i],
+			ForwardedBytes: parseBytes(fields[string(KindForwarded)]),
+			HLSBytes:       parseBytes(fields[string(KindHLS)]),
+		}
+		usage.Hours = append(usage.Hours, hour)
+		usage.ForwardedBytes += hour.ForwardedBytes
+		usage.HLSBytes += hour.HLSBytes
+	}
+	return usage, nil
+}
+
+func parseBytes(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
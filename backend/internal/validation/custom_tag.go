@@ -3,18 +3,44 @@ package validation
 import (
 	"regexp"
 
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 )
 
-var roomIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+// RoomIDPattern is the accepted room ID format, also published in API specs
+const RoomIDPattern = `^[A-Za-z0-9_-]{3,32}$`
+
+var roomIDRegex = regexp.MustCompile(RoomIDPattern)
+
+// Aliases maps custom binding tags to the built-in rules they expand to
+var Aliases = map[string]string{
+	"userid":     "uuid4",
+	"modules":    "oneof=mixers januses",
+	"moduleid":   "alphanum,min=3,max=32",
+	"externalid": "printascii,min=1,max=128,excludesall=/",
+	"role":       "oneof=host guest anchor",
+	"label":      "oneof=ready cordon draining drained unready",
+	"locale":     "max=35,bcp47_language_tag",
+	"tagkey":     "printascii,min=1,max=63,excludesall=/=:",
+	"tagvalue":   "printascii,min=1,max=128,excludesall=/",
+}
 
 func init() {
 	MustRegisterGin("roomid", ValidateRoomID)
-	MustRegisterGinAlias("userid", "uuid4")
-	MustRegisterGinAlias("modules", "oneof=mixers januses")
-	MustRegisterGinAlias("moduleid", "alphanum,min=3,max=32")
-	MustRegisterGinAlias("role", "oneof=host guest anchor")
-	MustRegisterGinAlias("label", "oneof=ready cordon draining drained unready")
+	for tag, alias := range Aliases {
+		MustRegisterGinAlias(tag, alias)
+	}
+}
+
+// IsRoomID reports whether id is a valid room ID, for IDs not bound from requests
+func IsRoomID(id string) bool {
+	return roomIDRegex.MatchString(id)
+}
+
+// IsTag reports whether key: value is a valid room tag, for tags not bound from requests
+func IsTag(key, value string) bool {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	return ok && v.Var(key, "tagkey") == nil && v.Var(value, "tagvalue") == nil
 }
 
 // ValidateRoomID validates room ID format: 3-32 characters, alphanumeric with hyphens and underscores
@@ -0,0 +1,24 @@
+package etcd
+
+import (
+	"go.opentelemetry.io/otel/metric"
+
+	intotel "github.com/imtaco/audio-rtc-exp/internal/otel"
+)
+
+var (
+	// Entries of the etcd snapshots taken on (re)start, by whether they differ from the state
+	// last processed, the unchanged share is the no-op rebuild ratio
+	rebuildEntries metric.Int64Counter
+	// (Re)starts of the watch, by whether it resumed from the last revision or took a snapshot
+	watchRestarts metric.Int64Counter
+)
+
+func init() {
+	f := intotel.NewFactory("internal.watcher.etcd", intotel.PrefixWatcher)
+
+	f.Int64Counter(&rebuildEntries, "rebuild.entries",
+		metric.WithDescription("Entries of etcd snapshots on watcher (re)start, by outcome changed or unchanged"))
+	f.Int64Counter(&watchRestarts, "restarts",
+		metric.WithDescription("Watch (re)starts, by mode resume or snapshot"))
+}
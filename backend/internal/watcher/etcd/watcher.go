@@ -4,11 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"hash/fnv"
+	"strconv"
 	"strings"
+	gosync "sync"
 	"time"
 
+	mvccpb "go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 
+	"github.com/imtaco/audio-rtc-exp/internal/backoff"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 
 	"github.com/imtaco/audio-rtc-exp/internal/etcd"
@@ -22,6 +29,16 @@ import (
 // automatic recovery from connection failures. Changes are processed through a scheduler with
 // retry logic using exponential backoff.
 //
+// When the watch is lost, e.g. on compaction, the fresh snapshot is diffed against hashes of the
+// states last processed, so only entries that changed meanwhile, deletions included, go through
+// ProcessChange again. Restart processes every entry.
+//
+// A watch lost to a transient error, e.g. a disconnect, resumes from the revision after the last
+// one seen without a fresh snapshot, only compaction and Restart take one. With CheckpointKV set
+// the revision up to which every change was processed is also saved to etcd, so a new process
+// diffs its first snapshot against the one at that revision instead of processing every entry.
+// Only use it when the effects of ProcessChange outlive the process.
+//
 // Example usage:
 //
 //	type MyData struct {
@@ -62,7 +79,22 @@ type BaseEtcdWatcher[T any] struct {
 	processChange watcher.ProcessChangeFunc[T]
 	stateTrans    watcher.StateTransformer[T]
 	retryAttampts map[string]int
-	retryDelay    time.Duration // configurable retry delay for testing
+	retryBackoff  backoff.Policy // delays restarts of a failing watch, configurable for testing
+
+	processed   map[string]uint64 // id -> hash of the state last processed, only used by the loop
+	fullMu      gosync.Mutex
+	fullRebuild bool // set by Restart, the next snapshot is processed as a whole
+
+	// rev is the revision the cache is at, 0 when a fresh snapshot is needed. pending holds the
+	// ids scheduled but not processed yet. Both are only used by the loop
+	rev     int64
+	pending map[string]struct{}
+
+	checkpointKV       etcd.KV
+	checkpointKey      string
+	checkpointInterval time.Duration
+	checkpointRev      int64 // revision last saved or loaded
+	checkpointLoaded   bool
 
 	logger *log.Logger
 }
@@ -74,8 +106,28 @@ type Config[T any] struct {
 	Logger           *log.Logger
 	ProcessChange    watcher.ProcessChangeFunc[T]
 	StateTransformer watcher.StateTransformer[T]
+	// CheckpointKV saves the revision processed under CheckpointKey every CheckpointInterval,
+	// optional
+	CheckpointKV       etcd.KV
+	CheckpointKey      string
+	CheckpointInterval time.Duration
 }
 
+const defaultCheckpointInterval = 10 * time.Second
+
+var (
+	// processBackoff delays retries of a key failing ProcessChange
+	processBackoff = backoff.Exponential{Initial: 100 * time.Millisecond, Max: 10 * time.Second}
+	// watchBackoff delays restarts of a failing watch, jittered so watchers of every service do not
+	// hit etcd at once when it comes back
+	watchBackoff = backoff.Exponential{Initial: time.Second, Max: 30 * time.Second, Jitter: 0.2}
+)
+
+// watchStableAfter is how long a watch runs before its failure no longer counts as a failed restart
+const watchStableAfter = time.Minute
+
+var errWatchClosed = errors.New("etcd watch channel closed")
+
 // NewWithEtcdClient creates a new watcher with a real etcd client
 func NewWithEtcdClient[T any](client *clientv3.Client, cfg Config[T]) watcher.Watcher[T] {
 	cfg.Client = client
@@ -83,16 +135,25 @@ func NewWithEtcdClient[T any](client *clientv3.Client, cfg Config[T]) watcher.Wa
 }
 
 func New[T any](cfg Config[T]) watcher.Watcher[T] {
+	interval := cfg.CheckpointInterval
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
 	return &BaseEtcdWatcher[T]{
-		client:          cfg.Client,
-		prefixToWatch:   cfg.PrefixToWatch,
-		allowedKeyTypes: cfg.AllowedKeyTypes,
-		cache:           sync.NewMap[string, *T](),
-		processChange:   cfg.ProcessChange,
-		stateTrans:      cfg.StateTransformer,
-		initGetCh:       make(chan struct{}),
-		retryDelay:      time.Second, // default retry delay
-		logger:          cfg.Logger,
+		client:             cfg.Client,
+		prefixToWatch:      cfg.PrefixToWatch,
+		allowedKeyTypes:    cfg.AllowedKeyTypes,
+		cache:              sync.NewMap[string, *T](),
+		processChange:      cfg.ProcessChange,
+		stateTrans:         cfg.StateTransformer,
+		initGetCh:          make(chan struct{}),
+		retryBackoff:       watchBackoff,
+		processed:          make(map[string]uint64),
+		pending:            make(map[string]struct{}),
+		checkpointKV:       cfg.CheckpointKV,
+		checkpointKey:      cfg.CheckpointKey,
+		checkpointInterval: interval,
+		logger:             cfg.Logger,
 	}
 }
 
@@ -132,6 +193,10 @@ func (w *BaseEtcdWatcher[T]) Stop() error {
 }
 
 func (w *BaseEtcdWatcher[T]) Restart() {
+	w.fullMu.Lock()
+	w.fullRebuild = true
+	w.fullMu.Unlock()
+
 	if w.gawCancel != nil {
 		w.gawCancel()
 	}
@@ -190,6 +255,18 @@ func (w *BaseEtcdWatcher[T]) parseAndUpdateCache(key string, value []byte) (id,
 		return "", "", false
 	}
 
+	curState, _ := w.cache.Load(id)
+	newState, ok := w.newState(key, id, keyType, value, curState)
+	if !ok {
+		return "", "", false
+	}
+	w.updateCache(id, newState)
+
+	return id, keyType, true
+}
+
+// newState returns the state of id with the key applied, false when the key is skipped
+func (w *BaseEtcdWatcher[T]) newState(key, id, keyType string, value []byte, curState *T) (*T, bool) {
 	if len(w.allowedKeyTypes) > 0 {
 		allowed := false
 		for _, kt := range w.allowedKeyTypes {
@@ -199,23 +276,109 @@ func (w *BaseEtcdWatcher[T]) parseAndUpdateCache(key string, value []byte) (id,
 			}
 		}
 		if !allowed {
-			return "", "", false
+			return nil, false
 		}
 	}
 
-	curState, _ := w.cache.Load(id)
 	newState, err := w.stateTrans.NewState(id, keyType, value, curState)
 	if err != nil {
 		w.logger.Error("Error updating cache", log.String("key", key), log.Error(err))
-		return "", "", false
+		return nil, false
 	}
-	w.updateCache(id, newState)
+	return newState, true
+}
 
-	return id, keyType, true
+// loadSnapshot replaces the cache with the states of the snapshot, returning the ids whose state
+// differs from the one last processed
+func (w *BaseEtcdWatcher[T]) loadSnapshot(kvs []*mvccpb.KeyValue) map[string]struct{} {
+	w.fullMu.Lock()
+	if w.fullRebuild {
+		w.processed = make(map[string]uint64)
+		w.fullRebuild = false
+	}
+	w.fullMu.Unlock()
+
+	snapshot := w.snapshotStates(kvs)
+
+	// entries deleted while the watch was down
+	var gone []string
+	w.cache.Range(func(id string, _ *T) bool {
+		if _, ok := snapshot[id]; !ok {
+			gone = append(gone, id)
+		}
+		return true
+	})
+	changed := make(map[string]struct{})
+	for _, id := range gone {
+		w.cache.Delete(id)
+		changed[id] = struct{}{}
+	}
+
+	unchanged := 0
+	for id, state := range snapshot {
+		w.cache.Store(id, state)
+		sum, ok := stateHash(state)
+		if last, processed := w.processed[id]; ok && processed && sum == last {
+			unchanged++
+			continue
+		}
+		changed[id] = struct{}{}
+	}
+	for id := range w.processed {
+		if _, ok := snapshot[id]; !ok {
+			changed[id] = struct{}{}
+		}
+	}
+
+	rebuildEntries.Add(context.Background(), int64(len(changed)), metric.WithAttributes(
+		attribute.String("prefix", w.prefixToWatch),
+		attribute.String("outcome", "changed")))
+	rebuildEntries.Add(context.Background(), int64(unchanged), metric.WithAttributes(
+		attribute.String("prefix", w.prefixToWatch),
+		attribute.String("outcome", "unchanged")))
+	w.logger.Info("Diffed etcd snapshot",
+		log.Int("entries", len(snapshot)),
+		log.Int("changed", len(changed)),
+		log.Int("unchanged", unchanged))
+
+	return changed
+}
+
+// snapshotStates assembles the states of the keys of a snapshot
+func (w *BaseEtcdWatcher[T]) snapshotStates(kvs []*mvccpb.KeyValue) map[string]*T {
+	snapshot := make(map[string]*T)
+	for _, kv := range kvs {
+		key := string(kv.Key)
+		id, keyType, ok := w.parseKey(key)
+		if !ok {
+			continue
+		}
+		newState, ok := w.newState(key, id, keyType, kv.Value, snapshot[id])
+		if !ok {
+			continue
+		}
+		if newState == nil {
+			delete(snapshot, id)
+		} else {
+			snapshot[id] = newState
+		}
+	}
+	return snapshot
+}
+
+// markProcessed records the state processed for id, so an unchanged one is skipped on rebuild
+func (w *BaseEtcdWatcher[T]) markProcessed(id string, state *T) {
+	sum, ok := stateHash(state)
+	if state == nil || !ok {
+		delete(w.processed, id)
+		return
+	}
+	w.processed[id] = sum
 }
 
 func (w *BaseEtcdWatcher[T]) getAndWatch(ctx context.Context) {
 	first := true
+	failures := 0
 
 	// user new scheduler
 	if w.scheduler != nil {
@@ -243,10 +406,20 @@ func (w *BaseEtcdWatcher[T]) getAndWatch(ctx context.Context) {
 		defer cancel()
 		w.gawCancel = cancel
 
+		started := time.Now()
 		if err := w.getAndWatchOnce(gawCtx, ch); err != nil {
 			if !errors.Is(err, context.Canceled) {
-				w.logger.Error("Error in getAndWatch loop", log.Error(err))
-				time.Sleep(w.retryDelay)
+				// a watch which ran for a while failed on its own, not right after the last restart
+				if time.Since(started) > watchStableAfter {
+					failures = 0
+				}
+				delay := w.retryBackoff.Delay(failures)
+				failures++
+				w.logger.Error("Error in getAndWatch loop",
+					log.Int("failures", failures),
+					log.Duration("retryIn", delay),
+					log.Error(err))
+				_ = backoff.Sleep(ctx, delay)
 				continue
 			}
 
@@ -263,10 +436,26 @@ func (w *BaseEtcdWatcher[T]) getAndWatch(ctx context.Context) {
 }
 
 func (w *BaseEtcdWatcher[T]) getAndWatchOnce(ctx context.Context, getNotify chan struct{}) error {
+	if w.rev > 0 && !w.fullRebuildPending() {
+		watchRestarts.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("prefix", w.prefixToWatch),
+			attribute.String("mode", "resume")))
+		w.logger.Info("Resuming etcd watcher", log.Int64("revision", w.rev+1))
+		return w.watch(ctx)
+	}
+
+	watchRestarts.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("prefix", w.prefixToWatch),
+		attribute.String("mode", "snapshot")))
 	w.logger.Info("Getting current data and starting watcher...")
 
 	// clear retry attempts on each full restart
 	w.retryAttampts = make(map[string]int)
+	w.rev = 0
+
+	if !w.checkpointLoaded {
+		w.loadCheckpoint(ctx)
+	}
 
 	resp, err := w.client.Get(ctx, w.prefixToWatch, clientv3.WithPrefix())
 	if err != nil {
@@ -279,16 +468,7 @@ func (w *BaseEtcdWatcher[T]) getAndWatchOnce(ctx context.Context, getNotify chan
 	kvs := resp.Kvs
 	w.logger.Info("Found keys in etcd, rebuilding state...", log.Int("count", len(kvs)))
 
-	idsToProcess := make(map[string]struct{})
-
-	for _, kv := range kvs {
-		key := string(kv.Key)
-		value := kv.Value
-		id, _, ok := w.parseAndUpdateCache(key, value)
-		if ok {
-			idsToProcess[id] = struct{}{}
-		}
-	}
+	idsToProcess := w.loadSnapshot(kvs)
 
 	// cacheSize := len(w.cache)
 	w.logger.Info("Rebuilt state from etcd")
@@ -304,12 +484,19 @@ func (w *BaseEtcdWatcher[T]) getAndWatchOnce(ctx context.Context, getNotify chan
 		close(getNotify)
 	}
 
+	w.pending = make(map[string]struct{})
 	for id := range idsToProcess {
-		w.scheduler.Enqueue(id, 0)
+		w.enqueue(id, 0)
 	}
 
 	// need to get from last revision
-	nextRev := revision + 1
+	w.rev = revision
+	return w.watch(ctx)
+}
+
+// watch follows the changes after the revision of the cache until the watch fails
+func (w *BaseEtcdWatcher[T]) watch(ctx context.Context) error {
+	nextRev := w.rev + 1
 	w.logger.Info("Starting etcd watcher from revision", log.Int64("revision", nextRev))
 
 	watchChan := w.client.Watch(ctx, w.prefixToWatch,
@@ -318,6 +505,13 @@ func (w *BaseEtcdWatcher[T]) getAndWatchOnce(ctx context.Context, getNotify chan
 
 	w.logger.Info("Etcd watcher started successfully")
 
+	var checkpointC <-chan time.Time
+	if w.checkpointKV != nil {
+		ticker := time.NewTicker(w.checkpointInterval)
+		defer ticker.Stop()
+		checkpointC = ticker.C
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -328,15 +522,29 @@ func (w *BaseEtcdWatcher[T]) getAndWatchOnce(ctx context.Context, getNotify chan
 				w.logger.Error("Error processing change for key", log.String("key", key), log.Error(err))
 				// re-enqueue
 				retryCount := w.retryAttampts[key]
-				w.scheduler.Enqueue(key, nextDelay(retryCount))
+				w.enqueue(key, nextDelay(retryCount))
 				w.retryAttampts[key] = retryCount + 1
 			} else {
 				delete(w.retryAttampts, key)
+				delete(w.pending, key)
+				w.markProcessed(key, state)
+			}
+		case <-checkpointC:
+			w.saveCheckpoint(ctx)
+		case watchResp, ok := <-watchChan:
+			if !ok {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return errWatchClosed
 			}
-		case watchResp := <-watchChan:
-			if watchResp.Err() != nil {
-				w.logger.Error("Etcd watcher error", log.Error(watchResp.Err()))
-				return watchResp.Err()
+			if err := watchResp.Err(); err != nil {
+				w.logger.Error("Etcd watcher error", log.Error(err))
+				if watchResp.CompactRevision != 0 {
+					// the revisions to resume from are gone
+					w.rev = 0
+				}
+				return err
 			}
 
 			w.handleWatch(watchResp)
@@ -344,6 +552,65 @@ func (w *BaseEtcdWatcher[T]) getAndWatchOnce(ctx context.Context, getNotify chan
 	}
 }
 
+func (w *BaseEtcdWatcher[T]) fullRebuildPending() bool {
+	w.fullMu.Lock()
+	defer w.fullMu.Unlock()
+	return w.fullRebuild
+}
+
+func (w *BaseEtcdWatcher[T]) enqueue(id string, delay time.Duration) {
+	w.pending[id] = struct{}{}
+	w.scheduler.Enqueue(id, delay)
+}
+
+// loadCheckpoint primes the states last processed with the snapshot at the saved revision, so
+// only the entries changed since are processed. Skipped when the revision was compacted
+func (w *BaseEtcdWatcher[T]) loadCheckpoint(ctx context.Context) {
+	if w.checkpointKV == nil {
+		return
+	}
+
+	resp, err := w.checkpointKV.Get(ctx, w.checkpointKey)
+	if err != nil {
+		w.logger.Error("Failed to load watcher checkpoint", log.Error(err))
+		return
+	}
+	w.checkpointLoaded = true
+	if len(resp.Kvs) == 0 {
+		return
+	}
+	rev, err := strconv.ParseInt(string(resp.Kvs[0].Value), 10, 64)
+	if err != nil || rev <= 0 {
+		w.logger.Error("Invalid watcher checkpoint", log.String("key", w.checkpointKey))
+		return
+	}
+
+	snap, err := w.client.Get(ctx, w.prefixToWatch, clientv3.WithPrefix(), clientv3.WithRev(rev))
+	if err != nil {
+		w.logger.Warn("Watcher checkpoint not usable, processing every entry",
+			log.Int64("revision", rev),
+			log.Error(err))
+		return
+	}
+	for id, state := range w.snapshotStates(snap.Kvs) {
+		w.markProcessed(id, state)
+	}
+	w.checkpointRev = rev
+	w.logger.Info("Loaded watcher checkpoint", log.Int64("revision", rev))
+}
+
+// saveCheckpoint saves the revision of the cache once every change up to it was processed
+func (w *BaseEtcdWatcher[T]) saveCheckpoint(ctx context.Context) {
+	if len(w.pending) > 0 || w.rev == 0 || w.rev == w.checkpointRev {
+		return
+	}
+	if _, err := w.checkpointKV.Put(ctx, w.checkpointKey, strconv.FormatInt(w.rev, 10)); err != nil {
+		w.logger.Error("Failed to save watcher checkpoint", log.Error(err))
+		return
+	}
+	w.checkpointRev = w.rev
+}
+
 func (w *BaseEtcdWatcher[T]) handleWatch(watchResp clientv3.WatchResponse) {
 	for _, event := range watchResp.Events {
 		key := string(event.Kv.Key)
@@ -368,7 +635,7 @@ func (w *BaseEtcdWatcher[T]) handleWatch(watchResp clientv3.WatchResponse) {
 				log.String("key", key),
 				log.Any("value", data))
 
-			w.scheduler.Enqueue(id, 0)
+			w.enqueue(id, 0)
 			// new attempt, reset counter
 			delete(w.retryAttampts, id)
 
@@ -376,19 +643,33 @@ func (w *BaseEtcdWatcher[T]) handleWatch(watchResp clientv3.WatchResponse) {
 			id, _, ok := w.parseAndUpdateCache(key, nil)
 			if ok {
 				w.logger.Info("Key deleted", log.String("key", key))
-				w.scheduler.Enqueue(id, 0)
+				w.enqueue(id, 0)
 				// new attempt, reset counter
 				delete(w.retryAttampts, id)
 			}
 		}
 	}
+
+	// the revision to resume from
+	for _, event := range watchResp.Events {
+		w.rev = max(w.rev, event.Kv.ModRevision)
+	}
+	if watchResp.IsProgressNotify() {
+		w.rev = max(w.rev, watchResp.Header.Revision)
+	}
 }
 
-func nextDelay(attempt int) time.Duration {
-	// Exponential backoff with jitter
-	baseDelay := time.Duration(100*(1<<attempt)) * time.Millisecond
-	if baseDelay > 10*time.Second {
-		baseDelay = 10 * time.Second
+// stateHash hashes the JSON of the state, false when it fails to encode
+func stateHash[T any](state *T) (uint64, bool) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return 0, false
 	}
-	return baseDelay
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return h.Sum64(), true
+}
+
+func nextDelay(attempt int) time.Duration {
+	return processBackoff.Delay(attempt)
 }
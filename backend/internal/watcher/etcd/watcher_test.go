@@ -10,9 +10,11 @@ import (
 	"github.com/stretchr/testify/suite"
 	"go.etcd.io/etcd/api/v3/etcdserverpb"
 	mvccpb "go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/mock/gomock"
 
+	"github.com/imtaco/audio-rtc-exp/internal/backoff"
 	etcdmock "github.com/imtaco/audio-rtc-exp/internal/etcd/mocks"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	"github.com/imtaco/audio-rtc-exp/internal/scheduler"
@@ -211,14 +213,19 @@ func (s *WatcherTestSuite) TestRunLoop_SchedulerEvents() {
 	mockTrans := mocks.NewMockStateTransformer[TestData](ctrl)
 	watcher := s.newWatcherWithClient(mockClient, mockTrans)
 
-	// Setup Get success
+	// Setup Get success, the snapshot holds the state
+	data := &TestData{Value: "scheduled", Count: 1}
+	jsonData, _ := json.Marshal(data)
 	getResponse := &clientv3.GetResponse{
 		Header: &etcdserverpb.ResponseHeader{Revision: 100},
-		Kvs:    []*mvccpb.KeyValue{},
+		Kvs: []*mvccpb.KeyValue{
+			{Key: []byte("/test/prefix/server1/data"), Value: jsonData},
+		},
 	}
 	mockClient.EXPECT().
 		Get(gomock.Any(), "/test/prefix/", gomock.Any()).
 		Return(getResponse, nil)
+	mockTrans.EXPECT().NewState("server1", "data", jsonData, nil).Return(data, nil)
 
 	mockTrans.EXPECT().RebuildStart(gomock.Any()).Return(nil)
 	mockTrans.EXPECT().RebuildEnd(gomock.Any()).Return(nil)
@@ -229,18 +236,15 @@ func (s *WatcherTestSuite) TestRunLoop_SchedulerEvents() {
 		Watch(gomock.Any(), "/test/prefix/", gomock.Any(), gomock.Any()).
 		Return((clientv3.WatchChan)(watchCh))
 
-	// Manually inject a state into cache
-	data := &TestData{Value: "scheduled", Count: 1}
-	watcher.cache.Store("server1", data)
-
 	mockTrans.EXPECT().RebuildState(gomock.Any(), "server1", data).Return(nil)
 
 	// Setup ProcessChange expectation
 	// We need to override the ProcessChange function in the watcher
-	processed := make(chan struct{})
+	// the snapshot enqueues the entry as well
+	processed := make(chan struct{}, 2)
 	watcher.processChange = func(_ context.Context, id string, state *TestData) error {
 		if id == "server1" && state == data {
-			close(processed)
+			processed <- struct{}{}
 		}
 		return nil
 	}
@@ -264,7 +268,7 @@ func (s *WatcherTestSuite) TestRunLoop_WatchError() {
 	watcher := s.newWatcherWithClient(mockClient, mockTrans)
 
 	// Override retry delay to make test faster
-	watcher.retryDelay = time.Millisecond
+	watcher.retryBackoff = backoff.Constant{Interval: time.Millisecond}
 
 	// Setup Get success
 	getResponse := &clientv3.GetResponse{
@@ -333,6 +337,189 @@ func (s *WatcherTestSuite) TestRunLoop_WatchError() {
 	<-stateUpdated
 }
 
+func (s *WatcherTestSuite) TestRunLoop_WatchResume() {
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	mockClient := etcdmock.NewMockWatcher(ctrl)
+	mockTrans := mocks.NewMockStateTransformer[TestData](ctrl)
+	watcher := s.newWatcherWithClient(mockClient, mockTrans)
+	watcher.retryBackoff = backoff.Constant{Interval: time.Millisecond}
+
+	// a single snapshot, the lost watch resumes without one
+	mockClient.EXPECT().
+		Get(gomock.Any(), "/test/prefix/", gomock.Any()).
+		Return(&clientv3.GetResponse{Header: &etcdserverpb.ResponseHeader{Revision: 100}}, nil)
+	mockTrans.EXPECT().RebuildStart(gomock.Any()).Return(nil)
+	mockTrans.EXPECT().RebuildEnd(gomock.Any()).Return(nil)
+
+	data := &TestData{Value: "a", Count: 1}
+	jsonData, _ := json.Marshal(data)
+	mockTrans.EXPECT().NewState("server1", "data", jsonData, gomock.Any()).Return(data, nil)
+
+	watchCh1 := make(chan clientv3.WatchResponse)
+	watchCh2 := make(chan clientv3.WatchResponse)
+	resumed := make(chan int64)
+	gomock.InOrder(
+		mockClient.EXPECT().
+			Watch(gomock.Any(), "/test/prefix/", gomock.Any(), gomock.Any()).
+			Return((clientv3.WatchChan)(watchCh1)),
+		mockClient.EXPECT().
+			Watch(gomock.Any(), "/test/prefix/", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, opts ...clientv3.OpOption) clientv3.WatchChan {
+				resumed <- clientv3.OpGet("", opts...).Rev()
+				return watchCh2
+			}),
+	)
+
+	s.Require().NoError(watcher.Start(context.Background()))
+	defer func() { _ = watcher.Stop() }()
+
+	watchCh1 <- clientv3.WatchResponse{
+		Events: []*clientv3.Event{{
+			Type: clientv3.EventTypePut,
+			Kv: &mvccpb.KeyValue{
+				Key:         []byte("/test/prefix/server1/data"),
+				Value:       jsonData,
+				ModRevision: 105,
+			},
+		}},
+	}
+	// a transient error, e.g. the connection dropped
+	close(watchCh1)
+
+	s.Equal(int64(106), <-resumed)
+	state, ok := watcher.GetCachedState("server1")
+	s.True(ok)
+	s.Equal(data, state)
+}
+
+func (s *WatcherTestSuite) TestCheckpoint() {
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	mockClient := etcdmock.NewMockWatcher(ctrl)
+	mockKV := etcdmock.NewMockKV(ctrl)
+	mockTrans := mocks.NewMockStateTransformer[TestData](ctrl)
+	mockTrans.EXPECT().NewState(gomock.Any(), "data", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, _ string, data []byte, _ *TestData) (*TestData, error) {
+			var state TestData
+			err := json.Unmarshal(data, &state)
+			return &state, err
+		}).AnyTimes()
+	watcher := New(Config[TestData]{
+		Client:           mockClient,
+		PrefixToWatch:    "/test/prefix/",
+		AllowedKeyTypes:  []string{"data"},
+		Logger:           log.NewTest(s.T()),
+		ProcessChange:    func(_ context.Context, _ string, _ *TestData) error { return nil },
+		StateTransformer: mockTrans,
+		CheckpointKV:     mockKV,
+		CheckpointKey:    "/checkpoints/test",
+	}).(*BaseEtcdWatcher[TestData])
+
+	kvs := func(counts map[string]int) []*mvccpb.KeyValue {
+		var kvs []*mvccpb.KeyValue
+		for id, count := range counts {
+			data, _ := json.Marshal(&TestData{Value: id, Count: count})
+			kvs = append(kvs, &mvccpb.KeyValue{Key: []byte("/test/prefix/" + id + "/data"), Value: data})
+		}
+		return kvs
+	}
+
+	mockKV.EXPECT().Get(gomock.Any(), "/checkpoints/test").Return(&clientv3.GetResponse{
+		Kvs: []*mvccpb.KeyValue{{Value: []byte("90")}},
+	}, nil)
+	mockClient.EXPECT().Get(gomock.Any(), "/test/prefix/", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+			s.Equal(int64(90), clientv3.OpGet("", opts...).Rev())
+			return &clientv3.GetResponse{Kvs: kvs(map[string]int{"a": 1, "b": 1})}, nil
+		})
+
+	// only the entries changed since the checkpoint are processed
+	watcher.loadCheckpoint(context.Background())
+	s.Equal(map[string]struct{}{"b": {}, "c": {}}, watcher.loadSnapshot(kvs(map[string]int{"a": 1, "b": 2, "c": 1})))
+
+	// saved once every change was processed
+	watcher.rev = 100
+	watcher.pending = map[string]struct{}{"b": {}}
+	watcher.saveCheckpoint(context.Background())
+
+	mockKV.EXPECT().Put(gomock.Any(), "/checkpoints/test", "100").Return(&clientv3.PutResponse{}, nil)
+	watcher.pending = map[string]struct{}{}
+	watcher.saveCheckpoint(context.Background())
+	watcher.saveCheckpoint(context.Background())
+}
+
+func (s *WatcherTestSuite) TestCheckpoint_Compacted() {
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	mockClient := etcdmock.NewMockWatcher(ctrl)
+	mockKV := etcdmock.NewMockKV(ctrl)
+	watcher := s.newWatcherWithClient(mockClient, mocks.NewMockStateTransformer[TestData](ctrl))
+	watcher.checkpointKV = mockKV
+	watcher.checkpointKey = "/checkpoints/test"
+
+	mockKV.EXPECT().Get(gomock.Any(), "/checkpoints/test").Return(&clientv3.GetResponse{
+		Kvs: []*mvccpb.KeyValue{{Value: []byte("90")}},
+	}, nil)
+	mockClient.EXPECT().Get(gomock.Any(), "/test/prefix/", gomock.Any()).
+		Return(nil, rpctypes.ErrCompacted)
+
+	watcher.loadCheckpoint(context.Background())
+	s.True(watcher.checkpointLoaded)
+	s.Empty(watcher.processed)
+	s.Zero(watcher.checkpointRev)
+}
+
+func (s *WatcherTestSuite) TestLoadSnapshot_DiffsAgainstProcessed() {
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	mockTrans := mocks.NewMockStateTransformer[TestData](ctrl)
+	mockTrans.EXPECT().NewState(gomock.Any(), "data", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, _ string, data []byte, _ *TestData) (*TestData, error) {
+			var state TestData
+			err := json.Unmarshal(data, &state)
+			return &state, err
+		}).AnyTimes()
+	watcher := s.newWatcher(mockTrans)
+
+	kvs := func(counts map[string]int) []*mvccpb.KeyValue {
+		var kvs []*mvccpb.KeyValue
+		for id, count := range counts {
+			data, _ := json.Marshal(&TestData{Value: id, Count: count})
+			kvs = append(kvs, &mvccpb.KeyValue{Key: []byte("/test/prefix/" + id + "/data"), Value: data})
+		}
+		return kvs
+	}
+	load := func(counts map[string]int) map[string]struct{} {
+		changed := watcher.loadSnapshot(kvs(counts))
+		for id := range changed {
+			state, _ := watcher.GetCachedState(id)
+			watcher.markProcessed(id, state)
+		}
+		return changed
+	}
+
+	s.Equal(map[string]struct{}{"a": {}, "b": {}}, load(map[string]int{"a": 1, "b": 1}))
+
+	// only the updated and new entries are processed again
+	s.Equal(map[string]struct{}{"b": {}, "c": {}}, load(map[string]int{"a": 1, "b": 2, "c": 1}))
+
+	// entries deleted meanwhile are processed as deletions
+	s.Equal(map[string]struct{}{"b": {}, "c": {}}, load(map[string]int{"a": 1}))
+	_, ok := watcher.GetCachedState("b")
+	s.False(ok)
+
+	s.Empty(load(map[string]int{"a": 1}))
+
+	// restart processes every entry
+	watcher.Restart()
+	s.Equal(map[string]struct{}{"a": {}}, load(map[string]int{"a": 1}))
+}
+
 func (s *WatcherTestSuite) TestNewWithEtcdClient() {
 	// Just verify it doesn't panic and returns a watcher
 	client := &clientv3.Client{}
@@ -1372,7 +1559,7 @@ func (s *WatcherTestSuite) TestRestart_TriggersNewGetAndWatchCycle() {
 	mockClient := etcdmock.NewMockWatcher(ctrl)
 	mockTrans := mocks.NewMockStateTransformer[TestData](ctrl)
 	watcher := s.newWatcherWithClient(mockClient, mockTrans)
-	watcher.retryDelay = time.Millisecond
+	watcher.retryBackoff = backoff.Constant{Interval: time.Millisecond}
 
 	getResponse := &clientv3.GetResponse{
 		Header: &etcdserverpb.ResponseHeader{Revision: 100},
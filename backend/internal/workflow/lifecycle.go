@@ -0,0 +1,193 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// Component is a part of a service started and stopped by a Lifecycle
+type Component struct {
+	Name string
+	// DependsOn names the components started before this one and stopped after it
+	DependsOn []string
+	// Start starts the component, nil for components ready once built
+	Start func(ctx context.Context) error
+	// Stop stops the component, nil for components without cleanup
+	Stop func(ctx context.Context) error
+	// StopTimeout bounds Stop, 0 only bounds it by the shutdown timeout
+	StopTimeout time.Duration
+}
+
+// Stopper adapts a Stop method without context nor error to Component.Stop
+func Stopper(stop func()) func(context.Context) error {
+	return func(context.Context) error {
+		stop()
+		return nil
+	}
+}
+
+// Closer adapts a Stop or Close method without context to Component.Stop
+func Closer(stop func() error) func(context.Context) error {
+	return func(context.Context) error {
+		return stop()
+	}
+}
+
+// Lifecycle starts components in dependency order, components without dependencies between
+// them start in the order they are added, and stops the started ones in reverse order
+type Lifecycle struct {
+	mu         sync.Mutex
+	components []*Component
+	started    []*Component
+	logger     *log.Logger
+}
+
+func NewLifecycle(logger *log.Logger) *Lifecycle {
+	return &Lifecycle{logger: logger}
+}
+
+// Add registers a component, dependencies may be added later
+func (l *Lifecycle) Add(c Component) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.components = append(l.components, &c)
+}
+
+// Start starts the components, when one fails the started ones are stopped again
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	order, err := l.order()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range order {
+		if c.Start != nil {
+			l.logger.Info("Starting component", log.String("component", c.Name))
+			if err := c.Start(ctx); err != nil {
+				err = fmt.Errorf("start %s: %w", c.Name, err)
+				if stopErr := l.stop(context.Background()); stopErr != nil {
+					err = errors.Join(err, stopErr)
+				}
+				return err
+			}
+		}
+		l.started = append(l.started, c)
+	}
+	return nil
+}
+
+// Stop stops the started components in reverse start order, a component failing or exceeding
+// its timeout does not keep the others from stopping, their errors are joined
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stop(ctx)
+}
+
+func (l *Lifecycle) stop(ctx context.Context) error {
+	var errs []error
+	for _, c := range slices.Backward(l.started) {
+		if c.Stop == nil {
+			continue
+		}
+		l.logger.Info("Stopping component", log.String("component", c.Name))
+		if err := l.stopComponent(ctx, c); err != nil {
+			l.logger.Error("Failed to stop component", log.String("component", c.Name), log.Error(err))
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.Name, err))
+		}
+	}
+	l.started = nil
+	return errors.Join(errs...)
+}
+
+// stopComponent runs Stop until it returns or its timeout passes, Stop keeps running in the
+// background after a timeout as most components cannot be interrupted
+func (l *Lifecycle) stopComponent(ctx context.Context, c *Component) error {
+	if c.StopTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.StopTimeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- c.Stop(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out: %w", ctx.Err())
+	}
+}
+
+// order sorts the components so each comes after its dependencies, keeping the order they
+// were added otherwise
+func (l *Lifecycle) order() ([]*Component, error) {
+	byName := make(map[string]*Component, len(l.components))
+	for _, c := range l.components {
+		if _, dup := byName[c.Name]; dup {
+			return nil, fmt.Errorf("duplicate component %s", c.Name)
+		}
+		byName[c.Name] = c
+	}
+	for _, c := range l.components {
+		for _, dep := range c.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("component %s depends on unknown component %s", c.Name, dep)
+			}
+		}
+	}
+
+	placed := make(map[string]bool, len(l.components))
+	order := make([]*Component, 0, len(l.components))
+	for len(order) < len(l.components) {
+		next := slices.IndexFunc(l.components, func(c *Component) bool {
+			if placed[c.Name] {
+				return false
+			}
+			for _, dep := range c.DependsOn {
+				if !placed[dep] {
+					return false
+				}
+			}
+			return true
+		})
+		if next < 0 {
+			var cycle []string
+			for _, c := range l.components {
+				if !placed[c.Name] {
+					cycle = append(cycle, c.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle between components %v", cycle)
+		}
+		placed[l.components[next].Name] = true
+		order = append(order, l.components[next])
+	}
+	return order, nil
+}
+
+// WaitGracefulShutdown waits for SIGINT or SIGTERM, then stops the components within timeout
+func (l *Lifecycle) WaitGracefulShutdown(ctx context.Context, timeout time.Duration) {
+	WaitGracefulShutdown(ctx, l.logger, func(ctx context.Context) {
+		if err := l.Stop(ctx); err != nil {
+			l.logger.Error("Components failed to stop", log.Error(err))
+		}
+	}, timeout)
+}
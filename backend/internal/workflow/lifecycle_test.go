@@ -0,0 +1,123 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type LifecycleTestSuite struct {
+	suite.Suite
+	mu     sync.Mutex
+	events []string
+	lc     *Lifecycle
+}
+
+func TestLifecycleSuite(t *testing.T) {
+	suite.Run(t, new(LifecycleTestSuite))
+}
+
+func (s *LifecycleTestSuite) SetupTest() {
+	s.events = nil
+	s.lc = NewLifecycle(log.NewTest(s.T()))
+}
+
+func (s *LifecycleTestSuite) record(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+// component records its start and stop, failing them with the given errors
+func (s *LifecycleTestSuite) component(name string, startErr, stopErr error, deps ...string) Component {
+	return Component{
+		Name:      name,
+		DependsOn: deps,
+		Start: func(context.Context) error {
+			s.record("start " + name)
+			return startErr
+		},
+		Stop: func(context.Context) error {
+			s.record("stop " + name)
+			return stopErr
+		},
+	}
+}
+
+func (s *LifecycleTestSuite) TestOrder() {
+	s.lc.Add(s.component("server", nil, nil, "watcher", "etcd"))
+	s.lc.Add(s.component("watcher", nil, nil, "etcd"))
+	s.lc.Add(s.component("otel", nil, nil))
+	s.lc.Add(s.component("etcd", nil, nil))
+
+	s.Require().NoError(s.lc.Start(context.Background()))
+	s.Equal([]string{"start otel", "start etcd", "start watcher", "start server"}, s.events)
+
+	s.events = nil
+	s.Require().NoError(s.lc.Stop(context.Background()))
+	s.Equal([]string{"stop server", "stop watcher", "stop etcd", "stop otel"}, s.events)
+}
+
+func (s *LifecycleTestSuite) TestStartFailureStopsStarted() {
+	s.lc.Add(s.component("etcd", nil, nil))
+	s.lc.Add(s.component("watcher", errors.New("boom"), nil, "etcd"))
+	s.lc.Add(s.component("server", nil, nil, "watcher"))
+
+	err := s.lc.Start(context.Background())
+	s.Require().ErrorContains(err, "start watcher: boom")
+	s.Equal([]string{"start etcd", "start watcher", "stop etcd"}, s.events)
+}
+
+func (s *LifecycleTestSuite) TestStopAggregatesErrors() {
+	s.lc.Add(s.component("a", nil, errors.New("a failed")))
+	s.lc.Add(s.component("b", nil, nil))
+	s.lc.Add(Component{Name: "c", Stop: func(context.Context) error { panic("c panicked") }})
+
+	s.Require().NoError(s.lc.Start(context.Background()))
+	err := s.lc.Stop(context.Background())
+	s.ErrorContains(err, "stop a: a failed")
+	s.ErrorContains(err, "stop c: panic: c panicked")
+	s.Contains(s.events, "stop b")
+}
+
+func (s *LifecycleTestSuite) TestStopTimeout() {
+	release := make(chan struct{})
+	defer close(release)
+	s.lc.Add(s.component("etcd", nil, nil))
+	s.lc.Add(Component{
+		Name:        "stuck",
+		StopTimeout: 20 * time.Millisecond,
+		Stop: func(context.Context) error {
+			<-release
+			return nil
+		},
+	})
+
+	s.Require().NoError(s.lc.Start(context.Background()))
+	err := s.lc.Stop(context.Background())
+	s.ErrorIs(err, context.DeadlineExceeded)
+	// later components still stop
+	s.Equal([]string{"start etcd", "stop etcd"}, s.events)
+}
+
+func (s *LifecycleTestSuite) TestInvalidDependencies() {
+	s.lc.Add(s.component("a", nil, nil, "missing"))
+	s.ErrorContains(s.lc.Start(context.Background()), "unknown component missing")
+
+	lc := NewLifecycle(log.NewNop())
+	lc.Add(s.component("a", nil, nil, "b"))
+	lc.Add(s.component("b", nil, nil, "a"))
+	s.ErrorContains(lc.Start(context.Background()), "dependency cycle")
+
+	lc = NewLifecycle(log.NewNop())
+	lc.Add(s.component("a", nil, nil))
+	lc.Add(s.component("a", nil, nil))
+	s.ErrorContains(lc.Start(context.Background()), "duplicate component a")
+	s.Empty(s.events)
+}
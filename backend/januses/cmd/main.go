@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/http"
 	"time"
 
+	"github.com/gin-gonic/gin"
+	goredis "github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 
+	"github.com/imtaco/audio-rtc-exp/internal/backoff"
 	"github.com/imtaco/audio-rtc-exp/internal/config"
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/etcd"
@@ -16,10 +18,16 @@ import (
 	etcdheartbeat "github.com/imtaco/audio-rtc-exp/internal/heartbeat/etcd"
 	"github.com/imtaco/audio-rtc-exp/internal/httputil"
 	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	redisrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/redis"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	"github.com/imtaco/audio-rtc-exp/internal/network"
 	"github.com/imtaco/audio-rtc-exp/internal/otel"
+	"github.com/imtaco/audio-rtc-exp/internal/redis"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+	"github.com/imtaco/audio-rtc-exp/internal/tlsid"
+	"github.com/imtaco/audio-rtc-exp/internal/usage"
 	"github.com/imtaco/audio-rtc-exp/internal/workflow"
+	"github.com/imtaco/audio-rtc-exp/januses/events"
 	"github.com/imtaco/audio-rtc-exp/januses/transport"
 	"github.com/imtaco/audio-rtc-exp/januses/watcher"
 )
@@ -33,6 +41,7 @@ type Config struct {
 	Etcd              etcd.Config     `mapstructure:"etcd"`
 	Otel              otel.Config     `mapstructure:"otel"`
 	HTTP              httputil.Config `mapstructure:"http"`
+	MTLS              tlsid.Config    `mapstructure:"mtls"`
 	JanusID           string          `mapstructure:"janus_id"`
 	JanusAdvHost      string          `mapstructure:"janus_adv_host"`
 	JanusBaseURL      string          `mapstructure:"janus_base_url"`
@@ -42,6 +51,20 @@ type Config struct {
 	EtcdPrefixJanuses string          `mapstructure:"etcd_prefix_januses"`
 	CanaryRoomID      int64           `mapstructure:"canary_room_id"`
 	LeaseTTL          time.Duration   `mapstructure:"lease_ttl"`
+	RoomGCInterval    time.Duration   `mapstructure:"room_gc_interval"`
+	RoomGCGracePeriod time.Duration   `mapstructure:"room_gc_grace_period"`
+	MarkerInterval    time.Duration   `mapstructure:"marker_interval"`
+	// active speakers are sent to mixers next to latency markers, needs Janus events
+	SpeakerMetadata bool `mapstructure:"speaker_metadata"`
+	// participant events of Janus are relayed to the gateways through the ws-notify stream
+	JanusEvents         events.Config               `mapstructure:"janus_events"`
+	Redis               redis.Config                `mapstructure:"redis"`
+	RedisWSNotifyStream string                      `mapstructure:"redis_ws_notify_stream"`
+	WSNotify            redisstream.PartitionConfig `mapstructure:"ws_notify"`
+	// forwarded bytes are estimated per room for billing, AudioBridge does not count them
+	Usage               usage.Config  `mapstructure:"usage"`
+	UsagePollInterval   time.Duration `mapstructure:"usage_poll_interval"`
+	UsageDefaultBitrate int           `mapstructure:"usage_default_bitrate"`
 }
 
 func loadConfig() (*Config, error) {
@@ -55,11 +78,24 @@ func loadConfig() (*Config, error) {
 		v.SetDefault("etcd_prefix_januses", "/januses/")
 		v.SetDefault("canary_room_id", 999999)
 		v.SetDefault("lease_ttl", 10*time.Second)
+		v.SetDefault("room_gc_interval", time.Minute)
+		v.SetDefault("room_gc_grace_period", 5*time.Minute)
+		v.SetDefault("marker_interval", 5*time.Second)
+		v.SetDefault("speaker_metadata", false)
+		v.SetDefault("redis_ws_notify_stream", "rtcus:user-status-ws-stream")
+		v.SetDefault("usage_poll_interval", 30*time.Second)
+		v.SetDefault("usage_default_bitrate", 64000)
 
 		config.Setup(v, "app")
 		etcd.Setup(v, "etcd")
 		otel.Setup(v, "otel")
 		httputil.Setup(v, "http")
+		tlsid.Setup(v, "mtls")
+		v.SetDefault("mtls.service", "januses")
+		redis.Setup(v, "redis")
+		redisstream.SetupPartition(v, "ws_notify")
+		events.Setup(v, "janus_events")
+		usage.Setup(v, "usage")
 	})
 }
 
@@ -97,6 +133,14 @@ func main() {
 	if err != nil {
 		logger.Fatal("Failed to create etcd client", log.Error(err))
 	}
+	if config.App.LogLevelsKey != "" {
+		go etcd.WatchLogLevels(ctx, etcdClient, config.App.LogLevelsKey, logger.Levels(), logger.Module("LogLevels"))
+	}
+
+	identity, err := tlsid.New(ctx, &config.MTLS, etcdClient, logger.Module("TLSID"))
+	if err != nil {
+		logger.Fatal("Failed to load TLS identity", log.Error(err))
+	}
 
 	// Create Janus API
 	logger.Info("baseURL", log.String("url", config.JanusBaseURL))
@@ -110,33 +154,151 @@ func main() {
 	// Start keepalive for admin instance
 	janusAdminInst.StartKeepalive()
 
-	// Create Janus monitor
-	janusMonitor := watcher.NewJanusHealthMonitor(
-		janusAdminInst,
-		config.CanaryRoomID,
-		monitorInterval,
-		logger.Module("Monitor"),
-	)
+	// Redis is only used for relayed events and usage
+	var redisClient *goredis.Client
+	if config.JanusEvents.Enabled || config.Usage.Enabled {
+		redisClient = redis.NewClient(&config.Redis)
+		if err := redis.Ping(redisClient); err != nil {
+			logger.Fatal("Failed to connect to Redis", log.Error(err))
+		}
+	}
 
-	// Create room watcher
-	roomWatcher := watcher.NewRoomWatcher(
-		etcdClient,
-		config.JanusID,
-		config.JanusAdvHost,
-		janusAdminInst,
-		config.EtcdPrefixRooms,
-		config.EtcdPrefixJanuses,
-		config.CanaryRoomID,
-		logger.Module("RoomWatcher"),
-	)
+	// Relay participant events posted by the Janus event handler
+	var relay *events.Relay
+	var wsNotifier redisrpc.Notifier
+	if config.JanusEvents.Enabled {
+		wsNotifier, err = redisrpc.NewNotifier(
+			redisClient,
+			config.RedisWSNotifyStream,
+			config.WSNotify.Partitions,
+			nil,
+			logger.Module("RPCWsOUT"),
+		)
+		if err != nil {
+			logger.Fatal("Failed to create WS notifier", log.Error(err))
+		}
+		if err := wsNotifier.Open(ctx); err != nil {
+			logger.Fatal("Failed to open WS notifier", log.Error(err))
+		}
+		relay = events.NewRelay(wsNotifier, logger.Module("Events"))
+	}
+	var usageRecorder *usage.Recorder
+	if config.Usage.Enabled {
+		usageRecorder = usage.NewRecorder(redisClient, &config.Usage, logger.Module("Usage"))
+	}
+	speakerMetadata := config.SpeakerMetadata && relay != nil && config.MarkerInterval > 0
+	if config.SpeakerMetadata && !speakerMetadata {
+		logger.Warn("Speaker metadata needs Janus events and latency markers, disabled")
+	}
 
-	// Connect restart event from monitor to watcher
-	janusMonitor.SetRestartHandler(func(reason string) {
-		logger.Warn("Janus server restarted, cleaning up etcd entries", log.String("reason", reason))
-		if err := roomWatcher.JanusRestartDetected(); err != nil {
-			logger.Error("Failed to handle Janus restart", log.Error(err))
+	// Components touching Janus run only while this manager owns the Janus ID, they are
+	// created anew on every acquisition
+	startOwned := func(ctx context.Context) (func(), error) {
+		janusMonitor := watcher.NewJanusHealthMonitor(
+			janusAdminInst,
+			config.CanaryRoomID,
+			monitorInterval,
+			logger.Module("Monitor"),
+		)
+		roomWatcher := watcher.NewRoomWatcher(
+			etcdClient,
+			config.JanusID,
+			config.JanusAdvHost,
+			janusAdminInst,
+			config.EtcdPrefixRooms,
+			config.EtcdPrefixJanuses,
+			config.CanaryRoomID,
+			logger.Module("RoomWatcher"),
+		)
+		if speakerMetadata {
+			roomWatcher.EnableTalkingEvents()
+		}
+		roomGC := watcher.NewRoomGC(
+			roomWatcher,
+			janusAdminInst,
+			config.CanaryRoomID,
+			config.RoomGCInterval,
+			config.RoomGCGracePeriod,
+			logger.Module("RoomGC"),
+		)
+		// 0 disables latency markers, mixers estimate latency then
+		var markerSender *watcher.MarkerSender
+		if config.MarkerInterval > 0 {
+			markerSender = watcher.NewMarkerSender(
+				roomWatcher,
+				config.MarkerInterval,
+				logger.Module("MarkerSender"),
+			)
+		}
+		var usagePoller *watcher.UsagePoller
+		if usageRecorder != nil {
+			usagePoller = watcher.NewUsagePoller(
+				roomWatcher,
+				usageRecorder,
+				config.UsagePollInterval,
+				config.UsageDefaultBitrate,
+				logger.Module("UsagePoller"),
+			)
+		}
+
+		// Connect restart event from monitor to watcher
+		janusMonitor.SetRestartHandler(func(reason string) {
+			logger.Warn("Janus server restarted, cleaning up etcd entries", log.String("reason", reason))
+			if err := roomWatcher.JanusRestartDetected(); err != nil {
+				logger.Error("Failed to handle Janus restart", log.Error(err))
+			}
+		})
+
+		stop := func() {
+			if relay != nil {
+				relay.SetResolver(nil)
+				relay.SetSpeakerSink(nil)
+			}
+			if markerSender != nil {
+				markerSender.Stop()
+			}
+			if usagePoller != nil {
+				usagePoller.Stop()
+			}
+			roomGC.Stop()
+			if err := roomWatcher.Stop(); err != nil {
+				logger.Error("Failed to cleanup room watcher", log.Error(err))
+			}
+			janusMonitor.Stop()
 		}
-	})
+
+		if err := janusMonitor.Start(ctx); err != nil {
+			return nil, fmt.Errorf("failed to start Janus monitor: %w", err)
+		}
+		if err := roomWatcher.Start(ctx); err != nil {
+			janusMonitor.Stop()
+			return nil, fmt.Errorf("failed to start room watcher: %w", err)
+		}
+		if err := roomGC.Start(ctx); err != nil {
+			stop()
+			return nil, fmt.Errorf("failed to start room GC: %w", err)
+		}
+		if markerSender != nil {
+			if err := markerSender.Start(ctx); err != nil {
+				stop()
+				return nil, fmt.Errorf("failed to start latency marker sender: %w", err)
+			}
+		}
+		if usagePoller != nil {
+			if err := usagePoller.Start(ctx); err != nil {
+				stop()
+				return nil, fmt.Errorf("failed to start usage poller: %w", err)
+			}
+		}
+		if relay != nil {
+			relay.SetResolver(roomWatcher)
+			if speakerMetadata {
+				relay.SetSpeakerSink(markerSender)
+			}
+		}
+		return stop, nil
+	}
+	ownership := watcher.NewOwnership(startOwned, logger.Module("Ownership"))
 
 	// Start Janus heartbeat
 	hbKey := fmt.Sprintf("%s%s/heartbeat", config.EtcdPrefixJanuses, config.JanusID)
@@ -146,57 +308,114 @@ func main() {
 		Capacity:  config.JanusCapacity,
 		StartedAt: time.Now().UTC(),
 	}
-	heartbeat := etcdheartbeat.New(
+	// the heartbeat key is the ownership lock of the Janus ID, a second manager started
+	// with the same ID must not touch Janus
+	heartbeat := etcdheartbeat.NewExclusive(
 		etcdClient,
 		hbKey,
 		hbData,
 		config.LeaseTTL,
 		logger.Module("Heartbeat"),
 	)
+	heartbeat.SetHeldHandler(ownership.SetHeld)
 
-	// Start all components
-	if err := janusMonitor.Start(ctx); err != nil {
-		logger.Fatal("Failed to start Janus monitor", log.Error(err))
+	// Setup Gin router
+	var eventSink transport.EventSink
+	var eventsAuth gin.Accounts
+	if relay != nil {
+		eventSink = relay
+		if config.JanusEvents.Password != "" {
+			eventsAuth = gin.Accounts{config.JanusEvents.User: config.JanusEvents.Password}
+		}
 	}
+	router := transport.NewRouter(config.JanusID, heartbeat, eventSink, eventsAuth, logger.Module("Router"))
+	server := httputil.NewServer(&config.HTTP, router.Handler())
+	server.SetIdentity(identity)
 
-	if err := roomWatcher.Start(ctx); err != nil {
-		logger.Fatal("Failed to start room watcher", log.Error(err))
+	lc := workflow.NewLifecycle(logger.Module("Lifecycle"))
+	lc.Add(workflow.Component{Name: "otel", Stop: otelShutdown})
+	lc.Add(workflow.Component{
+		Name: "etcd",
+		Stop: workflow.Closer(etcdClient.Close),
+	})
+	if identity != nil {
+		lc.Add(workflow.Component{
+			Name:  "tlsid",
+			Start: identity.Start,
+			Stop:  workflow.Stopper(identity.Stop),
+		})
 	}
-
-	if err := heartbeat.Start(ctx); err != nil {
-		logger.Fatal("Failed to start heartbeat", log.Error(err))
+	ownerDeps := []string{"etcd", "http"}
+	if redisClient != nil {
+		lc.Add(workflow.Component{Name: "redis", Stop: workflow.Closer(redisClient.Close)})
+	}
+	if wsNotifier != nil {
+		lc.Add(workflow.Component{
+			Name:      "wsNotifier",
+			DependsOn: []string{"redis"},
+			Stop:      workflow.Closer(wsNotifier.Close),
+		})
+		ownerDeps = append(ownerDeps, "wsNotifier")
+	}
+	// the usage poller stops with the ownership, before the buffered usage is flushed
+	if usageRecorder != nil {
+		lc.Add(workflow.Component{
+			Name:      "usage",
+			DependsOn: []string{"redis"},
+			Start:     usageRecorder.Start,
+			Stop:      workflow.Closer(usageRecorder.Stop),
+		})
+		ownerDeps = append(ownerDeps, "usage")
+	}
+	// health reports the ownership conflict meanwhile
+	lc.Add(server.Component("http", logger))
+	lc.Add(workflow.Component{
+		Name:      "ownership",
+		DependsOn: ownerDeps,
+		Start: func(ctx context.Context) error {
+			if err := ownership.Start(ctx); err != nil {
+				return err
+			}
+			// Acquire the ownership before touching Janus.
+			// The lease of a crashed manager expires after lease_ttl.
+			waitBackoff := backoff.Exponential{Initial: time.Second, Max: 15 * time.Second, Jitter: 0.2}
+			for failures := 0; ; {
+				err := heartbeat.Start(ctx)
+				if err == nil {
+					return nil
+				}
+				if !errors.Is(err, etcdheartbeat.ErrKeyHeld) {
+					ownership.Stop()
+					return err
+				}
+				logger.Error("Janus ID is owned by another manager, waiting for its release",
+					log.String("janusId", config.JanusID))
+				if err := heartbeat.WaitReleased(ctx); err != nil {
+					logger.Warn("Failed to wait for Janus ID release", log.Error(err))
+					if err := backoff.Sleep(ctx, waitBackoff.Delay(failures)); err != nil {
+						ownership.Stop()
+						return err
+					}
+					failures++
+					continue
+				}
+				failures = 0
+			}
+		},
+		// stop touching Janus before releasing the Janus ID
+		Stop: func(ctx context.Context) error {
+			ownership.Stop()
+			return heartbeat.Stop(ctx)
+		},
+	})
+	if adminServer := httputil.NewAdminServer(config.App.AdminAddr, "janus-service", logger); adminServer != nil {
+		lc.Add(adminServer.Component("admin", logger, "ownership"))
 	}
 
-	// Setup Gin router
-	router := transport.NewRouter(config.JanusID, logger.Module("Router"))
-	server := httputil.NewServer(&config.HTTP, router.Handler())
-
-	go func() {
-		logger.Info("Starting HTTP server", log.String("addr", config.HTTP.Addr))
-		if err := server.Listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			logger.Fatal("Failed to start HTTP server", log.Error(err))
-		}
-	}()
+	if err := lc.Start(ctx); err != nil {
+		logger.Fatal("Failed to start Janus Manager", log.Error(err))
+	}
 	logger.Info("Janus Manager started")
 
-	// Setup graceful shutdown
-	cleanup := func(ctx context.Context) {
-		_ = server.Shutdown(ctx)
-
-		if err := heartbeat.Stop(ctx); err != nil {
-			logger.Error("Failed to cleanup heartbeat", log.Error(err))
-		}
-
-		if err := roomWatcher.Stop(); err != nil {
-			logger.Error("Failed to cleanup room watcher", log.Error(err))
-		}
-		janusMonitor.Stop()
-		if err := etcdClient.Close(); err != nil {
-			logger.Error("Failed to close etcd client", log.Error(err))
-		}
-		if err := otelShutdown(ctx); err != nil {
-			logger.Error("Failed to shutdown OTEL", log.Error(err))
-		}
-	}
-	workflow.WaitGracefulShutdown(ctx, logger.Module("CleanUp"), cleanup, config.App.ShutdownTimeout)
+	lc.WaitGracefulShutdown(ctx, config.App.ShutdownTimeout)
 }
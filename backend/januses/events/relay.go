@@ -0,0 +1,183 @@
+package events
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	redisrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/redis"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+// NotifyMethod is the ws-notify method of participant events
+const NotifyMethod = "participantEvent"
+
+// Config enables the endpoint the Janus HTTP event handler posts to
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	// User and Password are the basic auth credentials configured in the event handler,
+	// an empty password accepts events without credentials
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), false)
+	v.SetDefault(p("user"), "janus")
+	v.SetDefault(p("password"), "")
+}
+
+// RoomResolver maps Janus room IDs to rooms
+type RoomResolver interface {
+	RoomIDOf(janusRoomID int64) (string, bool)
+}
+
+// Relay translates AudioBridge participant events of Janus to room notifications on the
+// ws-notify stream, so gateways tell anchors who is in the Janus room. Talking events set the
+// active speaker of the room on the speaker sink
+type Relay struct {
+	notifier redisrpc.Notifier
+	resolver atomic.Pointer[RoomResolver]
+	speakers atomic.Pointer[SpeakerSink]
+	tracker  *speakerTracker
+	logger   *log.Logger
+}
+
+func NewRelay(notifier redisrpc.Notifier, logger *log.Logger) *Relay {
+	return &Relay{
+		notifier: notifier,
+		tracker:  newSpeakerTracker(),
+		logger:   logger,
+	}
+}
+
+// SetResolver sets the rooms of the Janus room IDs, nil drops events while Janus is not owned
+func (r *Relay) SetResolver(resolver RoomResolver) {
+	if resolver == nil {
+		r.resolver.Store(nil)
+		return
+	}
+	r.resolver.Store(&resolver)
+}
+
+// SetSpeakerSink sets where active speakers go, nil drops talking events
+func (r *Relay) SetSpeakerSink(sink SpeakerSink) {
+	if sink == nil {
+		r.speakers.Store(nil)
+		return
+	}
+	r.speakers.Store(&sink)
+}
+
+// Handle relays the participant events among events and returns how many were relayed
+func (r *Relay) Handle(ctx context.Context, events []janus.HandlerEvent) int {
+	resolver := r.resolver.Load()
+	if resolver == nil {
+		return 0
+	}
+
+	relayed := 0
+	for i := range events {
+		event, ok := events[i].AudioBridgeEvent()
+		if !ok {
+			continue
+		}
+		r.trackSpeaker(*resolver, event)
+		notify, ok := r.translate(*resolver, event)
+		if !ok {
+			continue
+		}
+		if err := r.notifier.Notify(ctx, notify.RoomID, NotifyMethod, notify); err != nil {
+			r.logger.Error("Failed to relay participant event",
+				log.String("roomId", notify.RoomID),
+				log.String("userId", notify.UserID),
+				log.String("event", notify.Event),
+				log.Error(err))
+			continue
+		}
+		relayed++
+	}
+	return relayed
+}
+
+// trackSpeaker follows who talks in the room of the event and passes active speaker changes
+// on to the speaker sink
+func (r *Relay) trackSpeaker(resolver RoomResolver, event *janus.AudioBridgeEvent) {
+	sink := r.speakers.Load()
+	if sink == nil {
+		return
+	}
+	key := participantKey{janusRoomID: event.Room, id: event.ID}
+	display, _ := janus.UserIDOf(event.Display)
+
+	switch event.Event {
+	case janus.AudioBridgeJoined:
+		if display != "" {
+			r.tracker.joined(key, display)
+		}
+		return
+	case janus.AudioBridgeLeft, janus.AudioBridgeTalking, janus.AudioBridgeStoppedTalking:
+	default:
+		return
+	}
+
+	roomID, ok := resolver.RoomIDOf(event.Room)
+	if !ok {
+		return
+	}
+	var speaker string
+	var changed bool
+	if event.Event == janus.AudioBridgeLeft {
+		speaker, changed = r.tracker.left(roomID, key, display)
+	} else {
+		speaker, changed = r.tracker.talkingChanged(roomID, key, display, event.Event == janus.AudioBridgeTalking)
+	}
+	if changed {
+		(*sink).SetActiveSpeaker(roomID, speaker)
+	}
+}
+
+func (r *Relay) translate(resolver RoomResolver, event *janus.AudioBridgeEvent) (*users.NotifyParticipant, bool) {
+	var kind string
+	switch event.Event {
+	case janus.AudioBridgeJoined:
+		kind = users.ParticipantJoined
+	case janus.AudioBridgeLeft:
+		kind = users.ParticipantLeft
+	case janus.AudioBridgeConfigured:
+		// configure requests without a mute change carry no muted flag
+		if event.Muted == nil {
+			return nil, false
+		}
+		kind = users.ParticipantUnmuted
+		if *event.Muted {
+			kind = users.ParticipantMuted
+		}
+	default:
+		return nil, false
+	}
+
+	roomID, ok := resolver.RoomIDOf(event.Room)
+	if !ok {
+		r.logger.Debug("Participant event of unknown Janus room", log.Int64("janusRoomId", event.Room))
+		return nil, false
+	}
+	userID, ok := janus.UserIDOf(event.Display)
+	if !ok {
+		r.logger.Debug("Participant event without user",
+			log.String("roomId", roomID),
+			log.String("display", event.Display))
+		return nil, false
+	}
+
+	return &users.NotifyParticipant{
+		RoomID: roomID,
+		UserID: userID,
+		Event:  kind,
+	}, true
+}
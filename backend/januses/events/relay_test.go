@@ -0,0 +1,129 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+type fakeNotifier struct {
+	notified []*users.NotifyParticipant
+	err      error
+}
+
+func (*fakeNotifier) Open(context.Context) error { return nil }
+
+func (*fakeNotifier) Close() error { return nil }
+
+func (n *fakeNotifier) Notify(_ context.Context, key, method string, params any) error {
+	if n.err != nil {
+		return n.err
+	}
+	notify := params.(*users.NotifyParticipant)
+	if key != notify.RoomID || method != NotifyMethod {
+		return errors.New("unexpected notification")
+	}
+	n.notified = append(n.notified, notify)
+	return nil
+}
+
+type fakeResolver map[int64]string
+
+func (r fakeResolver) RoomIDOf(janusRoomID int64) (string, bool) {
+	roomID, ok := r[janusRoomID]
+	return roomID, ok
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	events, err := janus.ParseHandlerEvents([]byte(`[
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"event":"joined","room":100001,"id":1,"display":"user-u1"}}},
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"event":"configured","room":100001,"id":1,"display":"user-u1","muted":true}}},
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"event":"configured","room":100001,"id":1,"display":"user-u1"}}},
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"event":"left","room":100001,"id":1,"display":"user-u1"}}},
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"event":"joined","room":999999,"id":2,"display":"user-u2"}}},
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"event":"joined","room":100001,"id":3,"display":"canary"}}}
+	]`))
+	require.NoError(t, err)
+
+	t.Run("relays participant events of known rooms", func(t *testing.T) {
+		notifier := &fakeNotifier{}
+		relay := NewRelay(notifier, log.NewNop())
+		relay.SetResolver(fakeResolver{100001: "room1"})
+
+		assert.Equal(t, 3, relay.Handle(ctx, events))
+		require.Len(t, notifier.notified, 3)
+		assert.Equal(t, &users.NotifyParticipant{RoomID: "room1", UserID: "u1", Event: users.ParticipantJoined}, notifier.notified[0])
+		assert.Equal(t, users.ParticipantMuted, notifier.notified[1].Event)
+		assert.Equal(t, users.ParticipantLeft, notifier.notified[2].Event)
+	})
+
+	t.Run("drops events without resolver", func(t *testing.T) {
+		notifier := &fakeNotifier{}
+		relay := NewRelay(notifier, log.NewNop())
+		assert.Zero(t, relay.Handle(ctx, events))
+
+		relay.SetResolver(fakeResolver{100001: "room1"})
+		relay.SetResolver(nil)
+		assert.Zero(t, relay.Handle(ctx, events))
+	})
+
+	t.Run("notify errors", func(t *testing.T) {
+		relay := NewRelay(&fakeNotifier{err: errors.New("redis down")}, log.NewNop())
+		relay.SetResolver(fakeResolver{100001: "room1"})
+		assert.Zero(t, relay.Handle(ctx, events))
+	})
+}
+
+type fakeSpeakerSink struct {
+	speakers []string
+}
+
+func (f *fakeSpeakerSink) SetActiveSpeaker(roomID, userID string) {
+	f.speakers = append(f.speakers, roomID+":"+userID)
+}
+
+func TestRelay_ActiveSpeaker(t *testing.T) {
+	ctx := context.Background()
+	events, err := janus.ParseHandlerEvents([]byte(`[
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"event":"joined","room":100001,"id":1,"display":"user-u1"}}},
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"event":"joined","room":100001,"id":2,"display":"user-u2"}}},
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"audiobridge":"talking","room":100001,"id":1}}},
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"audiobridge":"talking","room":100001,"id":2}}},
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"audiobridge":"talking","room":100001,"id":2}}},
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"audiobridge":"stopped-talking","room":100001,"id":2}}},
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"event":"left","room":100001,"id":1,"display":"user-u1"}}},
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"audiobridge":"talking","room":100001,"id":9}}},
+		{"type":64,"event":{"plugin":"janus.plugin.audiobridge","data":{"audiobridge":"talking","room":999999,"id":3,"display":"user-u3"}}}
+	]`))
+	require.NoError(t, err)
+
+	t.Run("follows who talks last", func(t *testing.T) {
+		sink := &fakeSpeakerSink{}
+		relay := NewRelay(&fakeNotifier{}, log.NewNop())
+		relay.SetResolver(fakeResolver{100001: "room1"})
+		relay.SetSpeakerSink(sink)
+
+		// talking events are not relayed as participant events
+		assert.Equal(t, 3, relay.Handle(ctx, events))
+		assert.Equal(t, []string{"room1:u1", "room1:u2", "room1:u1", "room1:"}, sink.speakers)
+	})
+
+	t.Run("drops talking events without sink", func(t *testing.T) {
+		sink := &fakeSpeakerSink{}
+		relay := NewRelay(&fakeNotifier{}, log.NewNop())
+		relay.SetResolver(fakeResolver{100001: "room1"})
+		relay.SetSpeakerSink(sink)
+		relay.SetSpeakerSink(nil)
+
+		relay.Handle(ctx, events)
+		assert.Empty(t, sink.speakers)
+	})
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"slices"
+	"sync"
+)
+
+// SpeakerSink takes the active speaker of rooms, an empty user ID once nobody talks
+type SpeakerSink interface {
+	SetActiveSpeaker(roomID, userID string)
+}
+
+// participantKey identifies a participant of a Janus room
+type participantKey struct {
+	janusRoomID int64
+	id          int64
+}
+
+// speakerTracker follows who talks in every room. The active speaker is the participant who
+// started talking last, when they stop the one talking before them takes over
+type speakerTracker struct {
+	mu sync.Mutex
+	// users maps participants to users, talking events carry the participant ID only
+	users map[participantKey]string
+	// talking lists the users talking per room, in the order they started
+	talking map[string][]string
+}
+
+func newSpeakerTracker() *speakerTracker {
+	return &speakerTracker{
+		users:   map[participantKey]string{},
+		talking: map[string][]string{},
+	}
+}
+
+func (t *speakerTracker) joined(key participantKey, userID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.users[key] = userID
+}
+
+// left forgets the participant, it stops talking if it did. display is used when the join
+// was missed
+func (t *speakerTracker) left(roomID string, key participantKey, display string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	userID, ok := t.users[key]
+	if !ok {
+		userID = display
+	}
+	delete(t.users, key)
+	if userID == "" {
+		return active(t.talking[roomID]), false
+	}
+	return t.setTalking(roomID, userID, false)
+}
+
+// talkingChanged records the participant starting or stopping to talk, it returns the active
+// speaker of the room and whether it changed. display is used when the join was missed
+func (t *speakerTracker) talkingChanged(roomID string, key participantKey, display string, talking bool) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	userID, ok := t.users[key]
+	if !ok {
+		userID = display
+	}
+	if userID == "" {
+		return active(t.talking[roomID]), false
+	}
+	return t.setTalking(roomID, userID, talking)
+}
+
+func (t *speakerTracker) setTalking(roomID, userID string, talking bool) (string, bool) {
+	users := t.talking[roomID]
+	before := active(users)
+
+	users = slices.DeleteFunc(users, func(u string) bool { return u == userID })
+	if talking {
+		users = append(users, userID)
+	}
+	if len(users) == 0 {
+		delete(t.talking, roomID)
+	} else {
+		t.talking[roomID] = users
+	}
+
+	after := active(users)
+	return after, after != before
+}
+
+func active(users []string) string {
+	if len(users) == 0 {
+		return ""
+	}
+	return users[len(users)-1]
+}
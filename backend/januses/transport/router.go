@@ -1,30 +1,58 @@
 package transport
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
+	"github.com/imtaco/audio-rtc-exp/internal/httputil"
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 )
 
+// maxEventsBody bounds the body posted by the Janus event handler
+const maxEventsBody = 1 << 20
+
+// OwnershipLock reports whether this manager holds the ownership of the Janus ID
+type OwnershipLock interface {
+	Held() bool
+}
+
+// EventSink takes the events posted by the Janus HTTP event handler
+type EventSink interface {
+	Handle(ctx context.Context, events []janus.HandlerEvent) int
+}
+
 type Router struct {
-	janusID string
-	engine  *gin.Engine
-	logger  *log.Logger
+	janusID    string
+	owner      OwnershipLock
+	events     EventSink
+	eventsAuth gin.Accounts
+	engine     *gin.Engine
+	logger     *log.Logger
 }
 
-func NewRouter(janusID string, logger *log.Logger) *Router {
-	gin.SetMode(gin.ReleaseMode)
-	engine := gin.New()
-	engine.Use(gin.Recovery())
+// NewRouter creates the router, events are taken on POST /janus/events when events is not nil,
+// behind basic auth when eventsAuth is not empty
+func NewRouter(
+	janusID string,
+	owner OwnershipLock,
+	events EventSink,
+	eventsAuth gin.Accounts,
+	logger *log.Logger,
+) *Router {
+	engine := httputil.NewEngine("janus-service", logger)
 
 	r := &Router{
-		janusID: janusID,
-		engine:  engine,
-		logger:  logger,
+		janusID:    janusID,
+		owner:      owner,
+		events:     events,
+		eventsAuth: eventsAuth,
+		engine:     engine,
+		logger:     logger,
 	}
 
 	r.setupRoutes()
@@ -36,13 +64,49 @@ func (r *Router) Handler() http.Handler {
 }
 
 func (r *Router) setupRoutes() {
-	r.engine.Use(otelgin.Middleware("janus-service"))
-
 	// Health check
 	r.engine.GET("/health", r.healthCheck)
+
+	if r.events != nil {
+		handlers := []gin.HandlerFunc{r.postEvents}
+		if len(r.eventsAuth) > 0 {
+			handlers = append([]gin.HandlerFunc{gin.BasicAuth(r.eventsAuth)}, handlers...)
+		}
+		r.engine.POST("/janus/events", handlers...)
+	}
+}
+
+// postEvents takes the events of the Janus HTTP event handler, a single event or an array
+func (r *Router) postEvents(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxEventsBody))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Body too large"})
+		return
+	}
+	events, err := janus.ParseHandlerEvents(body)
+	if err != nil {
+		r.logger.Debug("Invalid Janus events", log.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid events"})
+		return
+	}
+
+	relayed := r.events.Handle(c.Request.Context(), events)
+	r.logger.Debug("Janus events handled", log.Int("events", len(events)), log.Int("relayed", relayed))
+	c.Status(http.StatusNoContent)
 }
 
 func (r *Router) healthCheck(c *gin.Context) {
+	// another manager runs with the same Janus ID, this one does not touch Janus
+	if !r.owner.Held() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":    "conflict",
+			"error":     "Janus ID is owned by another manager",
+			"janus_id":  r.janusID,
+			"service":   "janus-service",
+			"timestamp": time.Now(),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "ok",
 		"janus_id":  r.janusID,
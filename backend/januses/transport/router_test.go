@@ -0,0 +1,98 @@
+package transport_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/januses/transport"
+)
+
+type stubOwnership bool
+
+func (o stubOwnership) Held() bool {
+	return bool(o)
+}
+
+type RouterSuite struct {
+	suite.Suite
+}
+
+func TestRouterSuite(t *testing.T) {
+	suite.Run(t, new(RouterSuite))
+}
+
+func (s *RouterSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+}
+
+func (s *RouterSuite) healthCheck(held bool) (int, map[string]any) {
+	router := transport.NewRouter("janus-1", stubOwnership(held), nil, nil, log.NewTest(s.T()))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/health", nil)
+	router.Handler().ServeHTTP(w, req)
+
+	var resp map[string]any
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+func (s *RouterSuite) TestHealthCheck_Owner() {
+	code, resp := s.healthCheck(true)
+
+	s.Equal(http.StatusOK, code)
+	s.Equal("ok", resp["status"])
+	s.Equal("janus-1", resp["janus_id"])
+}
+
+func (s *RouterSuite) TestHealthCheck_OwnershipConflict() {
+	code, resp := s.healthCheck(false)
+
+	s.Equal(http.StatusServiceUnavailable, code)
+	s.Equal("conflict", resp["status"])
+}
+
+type recordingSink struct {
+	events []janus.HandlerEvent
+}
+
+func (r *recordingSink) Handle(_ context.Context, events []janus.HandlerEvent) int {
+	r.events = append(r.events, events...)
+	return len(events)
+}
+
+func (s *RouterSuite) postEvents(router *transport.Router, body string, auth bool) int {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/janus/events", strings.NewReader(body))
+	if auth {
+		req.SetBasicAuth("janus", "secret")
+	}
+	router.Handler().ServeHTTP(w, req)
+	return w.Code
+}
+
+func (s *RouterSuite) TestPostEvents() {
+	sink := &recordingSink{}
+	router := transport.NewRouter("janus-1", stubOwnership(true), sink, gin.Accounts{"janus": "secret"}, log.NewTest(s.T()))
+
+	s.Equal(http.StatusUnauthorized, s.postEvents(router, `{"type":64,"event":{}}`, false))
+	s.Equal(http.StatusBadRequest, s.postEvents(router, `nope`, true))
+	s.Equal(http.StatusNoContent, s.postEvents(router, `{"type":64,"event":{}}`, true))
+	s.Equal(http.StatusNoContent, s.postEvents(router, `[{"type":1,"event":{}},{"type":64,"event":{}}]`, true))
+	s.Len(sink.events, 3)
+}
+
+func (s *RouterSuite) TestPostEvents_Disabled() {
+	router := transport.NewRouter("janus-1", stubOwnership(true), nil, nil, log.NewTest(s.T()))
+
+	s.Equal(http.StatusNotFound, s.postEvents(router, `{"type":64,"event":{}}`, false))
+}
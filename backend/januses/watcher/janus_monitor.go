@@ -74,7 +74,7 @@ func (m *JanusHealthMonitor) Start(ctx context.Context) error {
 func (m *JanusHealthMonitor) createCanaryRoom(ctx context.Context) error {
 	description := fmt.Sprintf("canary %d", time.Now().UnixMilli())
 
-	err := m.janusAdmin.CreateRoom(ctx, m.canaryRoomID, description, "111111")
+	err := m.janusAdmin.CreateRoom(ctx, m.canaryRoomID, description, "111111", 0, nil)
 	if err != nil {
 		m.logger.Error("Failed to create canary room", log.Error(err))
 		return err
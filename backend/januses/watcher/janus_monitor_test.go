@@ -77,7 +77,7 @@ func (s *JanusHealthMonitorTestSuite) TestStart_CreateCanaryRoom_Success() {
 		Return(false, nil)
 
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), s.monitor.canaryRoomID, gomock.Any(), "111111").
+		CreateRoom(gomock.Any(), s.monitor.canaryRoomID, gomock.Any(), "111111", 0, nil).
 		Return(nil)
 
 	go func() {
@@ -109,7 +109,7 @@ func (s *JanusHealthMonitorTestSuite) TestStart_CreateCanaryRoom_Error() {
 		Return(false, nil)
 
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), s.monitor.canaryRoomID, gomock.Any(), "111111").
+		CreateRoom(gomock.Any(), s.monitor.canaryRoomID, gomock.Any(), "111111", 0, nil).
 		Return(errors.New("create failed"))
 
 	err := s.monitor.Start(s.ctx)
@@ -138,7 +138,7 @@ func (s *JanusHealthMonitorTestSuite) TestCheckCanaryRoom_NotFound() {
 
 	// Recreate canary after detecting disappearance
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), s.monitor.canaryRoomID, gomock.Any(), "111111").
+		CreateRoom(gomock.Any(), s.monitor.canaryRoomID, gomock.Any(), "111111", 0, nil).
 		Return(nil)
 
 	s.monitor.checkCanaryRoom()
@@ -162,7 +162,7 @@ func (s *JanusHealthMonitorTestSuite) TestHandleJanusRestart_CallsHandler() {
 	}
 
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), s.monitor.canaryRoomID, gomock.Any(), "111111").
+		CreateRoom(gomock.Any(), s.monitor.canaryRoomID, gomock.Any(), "111111", 0, nil).
 		Return(nil)
 
 	s.monitor.SetRestartHandler(handler)
@@ -174,7 +174,7 @@ func (s *JanusHealthMonitorTestSuite) TestHandleJanusRestart_CallsHandler() {
 
 func (s *JanusHealthMonitorTestSuite) TestHandleJanusRestart_NoHandler() {
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), s.monitor.canaryRoomID, gomock.Any(), "111111").
+		CreateRoom(gomock.Any(), s.monitor.canaryRoomID, gomock.Any(), "111111", 0, nil).
 		Return(nil)
 
 	s.NotPanics(func() {
@@ -184,7 +184,7 @@ func (s *JanusHealthMonitorTestSuite) TestHandleJanusRestart_NoHandler() {
 
 func (s *JanusHealthMonitorTestSuite) TestHandleJanusRestart_CreateCanaryFails() {
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), s.monitor.canaryRoomID, gomock.Any(), "111111").
+		CreateRoom(gomock.Any(), s.monitor.canaryRoomID, gomock.Any(), "111111", 0, nil).
 		Return(errors.New("create failed"))
 
 	s.NotPanics(func() {
@@ -0,0 +1,210 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/network"
+)
+
+// MarkerSender periodically sends a latency marker next to the RTP forward of every room
+// forwarded here, to the marker port its mixer advertises. The mixer measures the publish to
+// HLS segment latency of the room from the send time of the markers.
+//
+// The active speaker of rooms is sent the same way, on change and along with every marker as
+// datagrams may be lost, for the mixer to tag the HLS stream with it.
+type MarkerSender struct {
+	roomWatcher *RoomWatcher
+	interval    time.Duration
+	conn        *net.UDPConn
+	cancel      context.CancelFunc
+	stopped     chan struct{}
+	logger      *log.Logger
+
+	speakersMu sync.Mutex
+	speakers   map[string]string
+}
+
+// NewMarkerSender creates a new MarkerSender
+func NewMarkerSender(roomWatcher *RoomWatcher, interval time.Duration, logger *log.Logger) *MarkerSender {
+	return &MarkerSender{
+		roomWatcher: roomWatcher,
+		interval:    interval,
+		stopped:     make(chan struct{}),
+		logger:      logger,
+		speakers:    map[string]string{},
+	}
+}
+
+// Start starts the periodic send loop
+func (m *MarkerSender) Start(ctx context.Context) error {
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return fmt.Errorf("failed to open latency marker socket: %w", err)
+	}
+	m.conn = conn
+	m.logger.Info("Starting latency marker sender", log.Duration("interval", m.interval))
+
+	ctx, m.cancel = context.WithCancel(ctx)
+	go m.loop(ctx)
+	return nil
+}
+
+// Stop stops the send loop
+func (m *MarkerSender) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+		<-m.stopped
+		_ = m.conn.Close()
+	}
+	m.logger.Info("Stopped latency marker sender")
+}
+
+func (m *MarkerSender) loop(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer close(m.stopped)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.send()
+		}
+	}
+}
+
+// markerTarget is the marker address of a forwarded room
+type markerTarget struct {
+	roomID string
+	addr   string
+}
+
+// markerTargets lists the forwarded rooms whose mixer takes markers
+func (w *RoomWatcher) markerTargets() []markerTarget {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var targets []markerTarget
+	w.activeRooms.Range(func(key, val any) bool {
+		if target, ok := w.markerTargetLocked(key.(string), val.(*ActiveRoom)); ok {
+			targets = append(targets, target)
+		}
+		return true
+	})
+	return targets
+}
+
+// markerTarget returns the marker target of a room, if forwarded to a mixer taking markers
+func (w *RoomWatcher) markerTarget(roomID string) (markerTarget, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	val, ok := w.activeRooms.Load(roomID)
+	if !ok {
+		return markerTarget{}, false
+	}
+	return w.markerTargetLocked(roomID, val.(*ActiveRoom))
+}
+
+func (w *RoomWatcher) markerTargetLocked(roomID string, room *ActiveRoom) (markerTarget, bool) {
+	if room.StreamID == 0 {
+		return markerTarget{}, false
+	}
+	state, ok := w.GetCachedState(roomID)
+	if !ok {
+		return markerTarget{}, false
+	}
+	mixer := state.GetMixer()
+	if mixer.GetIP() == "" || mixer.GetMarkerPort() == 0 {
+		return markerTarget{}, false
+	}
+	return markerTarget{
+		roomID: roomID,
+		addr:   net.JoinHostPort(mixer.GetIP(), strconv.Itoa(mixer.GetMarkerPort())),
+	}, true
+}
+
+// send sends a marker for every forwarded room whose mixer takes markers, stamped right
+// before sending as room processing may hold the targets back
+func (m *MarkerSender) send() {
+	targets := m.roomWatcher.markerTargets()
+	m.pruneSpeakers(targets)
+	for _, target := range targets {
+		addr, err := net.ResolveUDPAddr("udp", target.addr)
+		if err != nil {
+			m.logger.Warn("Invalid mixer marker address", log.String("roomId", target.roomID), log.Error(err))
+			continue
+		}
+		data, err := network.EncodeLatencyMarker(network.LatencyMarker{RoomID: target.roomID, SentAt: time.Now()})
+		if err != nil {
+			m.logger.Error("Failed to encode latency marker", log.String("roomId", target.roomID), log.Error(err))
+			continue
+		}
+		if _, err := m.conn.WriteToUDP(data, addr); err != nil {
+			m.logger.Debug("Failed to send latency marker", log.String("roomId", target.roomID), log.Error(err))
+		}
+
+		m.speakersMu.Lock()
+		userID, ok := m.speakers[target.roomID]
+		m.speakersMu.Unlock()
+		if ok {
+			m.sendActiveSpeaker(target, addr, userID)
+		}
+	}
+}
+
+// SetActiveSpeaker sends the active speaker of the room to its mixer, an empty user ID once
+// nobody talks
+func (m *MarkerSender) SetActiveSpeaker(roomID, userID string) {
+	m.speakersMu.Lock()
+	m.speakers[roomID] = userID
+	m.speakersMu.Unlock()
+
+	target, ok := m.roomWatcher.markerTarget(roomID)
+	if !ok {
+		return
+	}
+	addr, err := net.ResolveUDPAddr("udp", target.addr)
+	if err != nil {
+		m.logger.Warn("Invalid mixer marker address", log.String("roomId", roomID), log.Error(err))
+		return
+	}
+	m.sendActiveSpeaker(target, addr, userID)
+}
+
+// pruneSpeakers forgets the speakers of rooms no longer forwarded to a mixer taking markers
+func (m *MarkerSender) pruneSpeakers(targets []markerTarget) {
+	forwarded := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		forwarded[target.roomID] = true
+	}
+	m.speakersMu.Lock()
+	defer m.speakersMu.Unlock()
+	for roomID := range m.speakers {
+		if !forwarded[roomID] {
+			delete(m.speakers, roomID)
+		}
+	}
+}
+
+func (m *MarkerSender) sendActiveSpeaker(target markerTarget, addr *net.UDPAddr, userID string) {
+	data, err := network.EncodeActiveSpeaker(network.ActiveSpeaker{
+		RoomID: target.roomID,
+		UserID: userID,
+		SentAt: time.Now(),
+	})
+	if err != nil {
+		m.logger.Error("Failed to encode active speaker", log.String("roomId", target.roomID), log.Error(err))
+		return
+	}
+	if _, err := m.conn.WriteToUDP(data, addr); err != nil {
+		m.logger.Debug("Failed to send active speaker", log.String("roomId", target.roomID), log.Error(err))
+	}
+}
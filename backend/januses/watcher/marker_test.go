@@ -0,0 +1,109 @@
+package watcher
+
+import (
+	"net"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/network"
+	roomstatemocks "github.com/imtaco/audio-rtc-exp/internal/roomstate/mocks"
+)
+
+func (s *RoomWatcherTestSuite) TestMarkerSender_Send() {
+	mixerConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	s.Require().NoError(err)
+	defer mixerConn.Close()
+	markerPort := mixerConn.LocalAddr().(*net.UDPAddr).Port
+
+	roomWatcher := roomstatemocks.NewMockWatcher(s.ctrl)
+	s.watcher.Watcher = roomWatcher
+	s.watcher.activeRooms.Store("room-1", &ActiveRoom{JanusRoomID: 100001, StreamID: 7})
+	s.watcher.activeRooms.Store("room-2", &ActiveRoom{JanusRoomID: 100002}) // not forwarding yet
+	roomWatcher.EXPECT().GetCachedState("room-1").Return(&etcdstate.RoomState{
+		Mixer: &etcdstate.Mixer{ID: "mixer-1", IP: "127.0.0.1", Port: 5004, MarkerPort: markerPort},
+	}, true)
+
+	sender := NewMarkerSender(s.watcher, time.Hour, log.NewNop())
+	s.Require().NoError(sender.Start(s.ctx))
+	defer sender.Stop()
+
+	before := time.Now()
+	sender.send()
+
+	s.Require().NoError(mixerConn.SetReadDeadline(time.Now().Add(2 * time.Second)))
+	buf := make([]byte, 512)
+	n, _, err := mixerConn.ReadFromUDP(buf)
+	s.Require().NoError(err)
+
+	marker, err := network.DecodeLatencyMarker(buf[:n])
+	s.Require().NoError(err)
+	s.Equal("room-1", marker.RoomID)
+	s.False(marker.SentAt.Before(before.Truncate(time.Microsecond)))
+}
+
+func (s *RoomWatcherTestSuite) TestMarkerSender_SkipsMixerWithoutMarkerPort() {
+	roomWatcher := roomstatemocks.NewMockWatcher(s.ctrl)
+	s.watcher.Watcher = roomWatcher
+	s.watcher.activeRooms.Store("room-1", &ActiveRoom{JanusRoomID: 100001, StreamID: 7})
+	roomWatcher.EXPECT().GetCachedState("room-1").Return(&etcdstate.RoomState{
+		Mixer: &etcdstate.Mixer{ID: "mixer-1", IP: "127.0.0.1", Port: 5004},
+	}, true)
+
+	sender := NewMarkerSender(s.watcher, time.Hour, log.NewNop())
+	s.Require().NoError(sender.Start(s.ctx))
+	defer sender.Stop()
+
+	sender.send()
+}
+
+func (s *RoomWatcherTestSuite) TestMarkerSender_ActiveSpeaker() {
+	mixerConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	s.Require().NoError(err)
+	defer mixerConn.Close()
+	markerPort := mixerConn.LocalAddr().(*net.UDPAddr).Port
+
+	roomWatcher := roomstatemocks.NewMockWatcher(s.ctrl)
+	s.watcher.Watcher = roomWatcher
+	s.watcher.activeRooms.Store("room-1", &ActiveRoom{JanusRoomID: 100001, StreamID: 7})
+	roomWatcher.EXPECT().GetCachedState("room-1").Return(&etcdstate.RoomState{
+		Mixer: &etcdstate.Mixer{ID: "mixer-1", IP: "127.0.0.1", Port: 5004, MarkerPort: markerPort},
+	}, true).AnyTimes()
+
+	sender := NewMarkerSender(s.watcher, time.Hour, log.NewNop())
+	s.Require().NoError(sender.Start(s.ctx))
+	defer sender.Stop()
+
+	buf := make([]byte, 512)
+	read := func() []byte {
+		s.Require().NoError(mixerConn.SetReadDeadline(time.Now().Add(2 * time.Second)))
+		n, _, err := mixerConn.ReadFromUDP(buf)
+		s.Require().NoError(err)
+		return buf[:n]
+	}
+
+	// sent on change
+	sender.SetActiveSpeaker("room-1", "u1")
+	speaker, err := network.DecodeActiveSpeaker(read())
+	s.Require().NoError(err)
+	s.Equal("room-1", speaker.RoomID)
+	s.Equal("u1", speaker.UserID)
+
+	// and again along with markers
+	sender.send()
+	_, err = network.DecodeLatencyMarker(read())
+	s.Require().NoError(err)
+	speaker, err = network.DecodeActiveSpeaker(read())
+	s.Require().NoError(err)
+	s.Equal("u1", speaker.UserID)
+
+	sender.SetActiveSpeaker("room-1", "")
+	speaker, err = network.DecodeActiveSpeaker(read())
+	s.Require().NoError(err)
+	s.Empty(speaker.UserID)
+
+	// unknown rooms are forgotten on the next send
+	sender.SetActiveSpeaker("room-2", "u2")
+	sender.send()
+	s.NotContains(sender.speakers, "room-2")
+}
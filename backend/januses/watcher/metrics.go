@@ -22,6 +22,13 @@ var (
 	// Heartbeat metrics
 	heartbeatUpdates  metric.Int64Counter
 	heartbeatFailures metric.Int64Counter
+
+	// Room GC metrics
+	roomGCRuns           metric.Int64Counter
+	roomGCFailures       metric.Int64Counter
+	orphanRoomsDetected  metric.Int64Counter
+	orphanRoomsDestroyed metric.Int64Counter
+	orphanRoomsPending   metric.Int64UpDownCounter
 )
 
 func init() {
@@ -56,4 +63,19 @@ func init() {
 
 	f.Int64Counter(&heartbeatFailures, "heartbeat.failures",
 		metric.WithDescription("Number of heartbeat update failures"))
+
+	f.Int64Counter(&roomGCRuns, "room_gc.runs",
+		metric.WithDescription("Total number of room GC passes"))
+
+	f.Int64Counter(&roomGCFailures, "room_gc.failures",
+		metric.WithDescription("Number of room GC passes or destroys that failed"))
+
+	f.Int64Counter(&orphanRoomsDetected, "room_gc.orphans.detected",
+		metric.WithDescription("Total number of Janus rooms found without etcd reference"))
+
+	f.Int64Counter(&orphanRoomsDestroyed, "room_gc.orphans.destroyed",
+		metric.WithDescription("Total number of orphaned Janus rooms destroyed"))
+
+	f.Int64UpDownCounter(&orphanRoomsPending, "room_gc.orphans.pending",
+		metric.WithDescription("Number of orphaned Janus rooms waiting for the grace period"))
 }
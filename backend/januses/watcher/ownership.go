@@ -0,0 +1,104 @@
+package watcher
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/backoff"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// StartOwnedFunc starts the components touching Janus, it returns the func stopping them
+type StartOwnedFunc func(ctx context.Context) (stop func(), err error)
+
+// Ownership runs the components touching Janus only while this manager owns the Janus ID.
+// They are started when the ownership lock is acquired and stopped as soon as it is lost,
+// a later acquisition starts fresh ones, so nothing acts on state another manager changed.
+type Ownership struct {
+	start        StartOwnedFunc
+	retryBackoff backoff.Policy
+	held         atomic.Bool
+	changed      chan struct{}
+	cancel       context.CancelFunc
+	stopped      chan struct{}
+	logger       *log.Logger
+}
+
+// NewOwnership creates a new Ownership
+func NewOwnership(start StartOwnedFunc, logger *log.Logger) *Ownership {
+	return &Ownership{
+		start:        start,
+		retryBackoff: backoff.Exponential{Initial: 5 * time.Second, Max: time.Minute, Jitter: 0.2},
+		changed:      make(chan struct{}, 1),
+		stopped:      make(chan struct{}),
+		logger:       logger,
+	}
+}
+
+// Start starts applying ownership changes
+func (o *Ownership) Start(ctx context.Context) error {
+	ctx, o.cancel = context.WithCancel(ctx)
+	go o.loop(ctx)
+	return nil
+}
+
+// Stop stops the owned components if running
+func (o *Ownership) Stop() {
+	if o.cancel != nil {
+		o.cancel()
+		<-o.stopped
+	}
+}
+
+// SetHeld records whether the ownership lock is held, components follow asynchronously
+func (o *Ownership) SetHeld(held bool) {
+	o.held.Store(held)
+	select {
+	case o.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (o *Ownership) loop(ctx context.Context) {
+	defer close(o.stopped)
+
+	var stop func()
+	defer func() {
+		if stop != nil {
+			stop()
+		}
+	}()
+
+	var retry <-chan time.Time
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.changed:
+		case <-retry:
+		}
+		retry = nil
+
+		held := o.held.Load()
+		switch {
+		case held && stop == nil:
+			o.logger.Info("Janus ID acquired, starting components")
+			var err error
+			if stop, err = o.start(ctx); err != nil {
+				delay := o.retryBackoff.Delay(failures)
+				failures++
+				o.logger.Error("Failed to start components, retrying", log.Duration("retryIn", delay), log.Error(err))
+				stop = nil
+				retry = time.After(delay)
+			} else {
+				failures = 0
+			}
+		case !held && stop != nil:
+			o.logger.Warn("Janus ID lost, stopping components")
+			stop()
+			stop = nil
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/backoff"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+func TestOwnership(t *testing.T) {
+	events := make(chan string, 10)
+	attempts := 0
+	start := func(context.Context) (func(), error) {
+		attempts++
+		if attempts == 1 {
+			events <- "failed"
+			return nil, errors.New("etcd down")
+		}
+		events <- "started"
+		return func() { events <- "stopped" }, nil
+	}
+
+	o := NewOwnership(start, log.NewTest(t))
+	o.retryBackoff = backoff.Constant{Interval: 10 * time.Millisecond}
+	require.NoError(t, o.Start(context.Background()))
+
+	next := func() string {
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(2 * time.Second):
+			return "timeout"
+		}
+	}
+
+	o.SetHeld(true)
+	assert.Equal(t, "failed", next())
+	assert.Equal(t, "started", next())
+
+	o.SetHeld(false)
+	assert.Equal(t, "stopped", next())
+
+	o.SetHeld(true)
+	assert.Equal(t, "started", next())
+
+	o.Stop()
+	assert.Equal(t, "stopped", next())
+}
@@ -0,0 +1,232 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// RoomGC periodically reconciles rooms living in Janus against the etcd room set.
+// Rebuild only runs when the watch restarts, so rooms whose etcd keys were removed while
+// this manager was down would otherwise linger in Janus forever. A room is destroyed only
+// after it stayed unreferenced for the whole grace period, which protects rooms that are
+// being created or handed over while the pass runs.
+type RoomGC struct {
+	roomWatcher  *RoomWatcher
+	janusAdmin   janus.Admin
+	canaryRoomID int64
+	interval     time.Duration
+	gracePeriod  time.Duration
+	orphans      map[int64]time.Time // janusRoomId -> first seen unreferenced
+	cancel       context.CancelFunc
+	stopped      chan struct{}
+	logger       *log.Logger
+}
+
+// NewRoomGC creates a new RoomGC
+func NewRoomGC(
+	roomWatcher *RoomWatcher,
+	janusAdmin janus.Admin,
+	canaryRoomID int64,
+	interval time.Duration,
+	gracePeriod time.Duration,
+	logger *log.Logger,
+) *RoomGC {
+	return &RoomGC{
+		roomWatcher:  roomWatcher,
+		janusAdmin:   janusAdmin,
+		canaryRoomID: canaryRoomID,
+		interval:     interval,
+		gracePeriod:  gracePeriod,
+		orphans:      make(map[int64]time.Time),
+		stopped:      make(chan struct{}),
+		logger:       logger,
+	}
+}
+
+// Start starts the periodic reconciliation loop
+func (g *RoomGC) Start(ctx context.Context) error {
+	g.logger.Info("Starting Janus room GC",
+		log.Duration("interval", g.interval),
+		log.Duration("gracePeriod", g.gracePeriod))
+
+	ctx, g.cancel = context.WithCancel(ctx)
+	go g.loop(ctx)
+	return nil
+}
+
+// Stop stops the reconciliation loop
+func (g *RoomGC) Stop() {
+	if g.cancel != nil {
+		g.cancel()
+		<-g.stopped
+	}
+	g.logger.Info("Stopped Janus room GC")
+}
+
+func (g *RoomGC) loop(ctx context.Context) {
+	ticker := time.NewTicker(g.interval)
+	defer close(g.stopped)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := g.collect(ctx, time.Now()); err != nil {
+				g.logger.Error("Janus room GC pass failed", log.Error(err))
+			}
+		}
+	}
+}
+
+// collect runs a single reconciliation pass
+func (g *RoomGC) collect(ctx context.Context, now time.Time) error {
+	roomGCRuns.Add(ctx, 1)
+
+	rooms, err := g.janusAdmin.ListRooms(ctx)
+	if err != nil {
+		roomGCFailures.Add(ctx, 1)
+		return err
+	}
+
+	seen := make(map[int64]struct{}, len(rooms))
+	for _, room := range rooms {
+		janusRoomID := room.Room
+		if janusRoomID == g.canaryRoomID {
+			continue
+		}
+		roomID := room.Description // use description as our roomId
+
+		if g.roomWatcher.isReferenced(roomID, janusRoomID) {
+			continue
+		}
+		seen[janusRoomID] = struct{}{}
+
+		firstSeen, ok := g.orphans[janusRoomID]
+		if !ok {
+			g.logger.Info("Found unreferenced Janus room",
+				log.String("roomId", roomID),
+				log.Int64("janusRoomId", janusRoomID))
+			g.orphans[janusRoomID] = now
+			orphanRoomsDetected.Add(ctx, 1)
+			orphanRoomsPending.Add(ctx, 1)
+			continue
+		}
+		if now.Sub(firstSeen) < g.gracePeriod {
+			continue
+		}
+
+		g.logger.Warn("Destroying orphaned Janus room",
+			log.String("roomId", roomID),
+			log.Int64("janusRoomId", janusRoomID),
+			log.Time("firstSeen", firstSeen))
+
+		collected, err := g.roomWatcher.collectRoom(ctx, roomID, janusRoomID)
+		if err != nil {
+			roomGCFailures.Add(ctx, 1)
+			g.logger.Error("Failed to destroy orphaned Janus room",
+				log.Int64("janusRoomId", janusRoomID),
+				log.Error(err))
+			continue
+		}
+		if collected {
+			orphanRoomsDestroyed.Add(ctx, 1)
+		}
+
+		delete(g.orphans, janusRoomID)
+		delete(seen, janusRoomID)
+		orphanRoomsPending.Add(ctx, -1)
+	}
+
+	// rooms that got referenced again or disappeared from Janus are no longer candidates
+	for janusRoomID := range g.orphans {
+		if _, ok := seen[janusRoomID]; ok {
+			continue
+		}
+		delete(g.orphans, janusRoomID)
+		orphanRoomsPending.Add(ctx, -1)
+	}
+
+	return nil
+}
+
+// isReferenced reports whether a Janus room is still backed by etcd state assigned to us
+func (w *RoomWatcher) isReferenced(roomID string, janusRoomID int64) bool {
+	val, ok := w.activeRooms.Load(roomID)
+	if !ok || val.(*ActiveRoom).JanusRoomID != janusRoomID {
+		return false
+	}
+
+	state, ok := w.GetCachedState(roomID)
+	if !ok {
+		return false
+	}
+	livemeta := state.GetLiveMeta()
+	return state.GetMeta() != nil &&
+		livemeta != nil &&
+		livemeta.JanusID == w.janusID &&
+		livemeta.Status == constants.RoomStatusOnAir
+}
+
+// collectRoom destroys an orphaned Janus room unless it got referenced meanwhile, and drops
+// what this manager kept for it: the active room, its link forwarders and its janus status
+// key. It holds the room processing lock, so the room can not be (re)created concurrently.
+func (w *RoomWatcher) collectRoom(ctx context.Context, roomID string, janusRoomID int64) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.isReferenced(roomID, janusRoomID) {
+		return false, nil
+	}
+	if err := w.destroyRoom(ctx, janusRoomID); err != nil {
+		return false, err
+	}
+
+	val, ok := w.activeRooms.Load(roomID)
+	if ok && val.(*ActiveRoom).JanusRoomID == janusRoomID {
+		w.activeRooms.Delete(roomID)
+		w.forgetLinks(roomID)
+	}
+	if err := w.clearJanusStatus(ctx, roomID, janusRoomID); err != nil {
+		w.logger.Warn("Failed to clear janus status of orphaned room",
+			log.String("roomId", roomID),
+			log.Error(err))
+	}
+	return true, nil
+}
+
+// clearJanusStatus deletes the janus status key of a room if it still describes the given
+// Janus room of this manager, a manager the room moved to owns the key otherwise
+func (w *RoomWatcher) clearJanusStatus(ctx context.Context, roomID string, janusRoomID int64) error {
+	key := fmt.Sprintf("%s%s/%s", w.prefixRooms, roomID, constants.RoomKeyJanus)
+	resp, err := w.etcdClient.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil
+	}
+	var status etcdstate.Janus
+	if err := json.Unmarshal(resp.Kvs[0].Value, &status); err != nil {
+		return err
+	}
+	if status.JanusID != w.janusID || status.JanusRoomID != janusRoomID {
+		return nil
+	}
+
+	_, err = w.etcdClient.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)).
+		Then(clientv3.OpDelete(key)).
+		Commit()
+	return err
+}
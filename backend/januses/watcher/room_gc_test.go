@@ -0,0 +1,215 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	etcdfakes "github.com/imtaco/audio-rtc-exp/internal/etcd/fakes"
+	etcdmocks "github.com/imtaco/audio-rtc-exp/internal/etcd/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	"github.com/imtaco/audio-rtc-exp/internal/janus/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	roomstatemocks "github.com/imtaco/audio-rtc-exp/internal/roomstate/mocks"
+
+	"github.com/stretchr/testify/suite"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/mock/gomock"
+)
+
+type RoomGCTestSuite struct {
+	suite.Suite
+	ctrl        *gomock.Controller
+	mockJanus   *mocks.MockAdmin
+	mockRooms   *roomstatemocks.MockWatcher
+	mockEtcd    *etcdmocks.MockClient
+	watcher     *RoomWatcher
+	gc          *RoomGC
+	ctx         context.Context
+	cancel      context.CancelFunc
+	gracePeriod time.Duration
+}
+
+func TestRoomGCSuite(t *testing.T) {
+	suite.Run(t, new(RoomGCTestSuite))
+}
+
+func (s *RoomGCTestSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.mockJanus = mocks.NewMockAdmin(s.ctrl)
+	s.mockRooms = roomstatemocks.NewMockWatcher(s.ctrl)
+	s.mockEtcd = etcdmocks.NewMockClient(s.ctrl)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.gracePeriod = time.Minute
+
+	logger := log.NewTest(s.T())
+	s.watcher = &RoomWatcher{
+		Watcher:     s.mockRooms,
+		etcdClient:  s.mockEtcd,
+		janusAdmin:  s.mockJanus,
+		janusID:     "test-janus-01",
+		prefixRooms: "/rooms/",
+		logger:      logger,
+	}
+	s.gc = NewRoomGC(s.watcher, s.mockJanus, 999999, time.Second, s.gracePeriod, logger)
+}
+
+func (s *RoomGCTestSuite) TearDownTest() {
+	s.cancel()
+	s.ctrl.Finish()
+}
+
+func (s *RoomGCTestSuite) onAirState(janusID string) *etcdstate.RoomState {
+	return &etcdstate.RoomState{
+		Meta: &etcdstate.Meta{Pin: "1234"},
+		LiveMeta: &etcdstate.LiveMeta{
+			JanusID: janusID,
+			Status:  constants.RoomStatusOnAir,
+		},
+	}
+}
+
+// expectJanusStatus returns the janus status of a room from etcd, a nil status means no key
+func (s *RoomGCTestSuite) expectJanusStatus(roomID string, status *etcdstate.Janus) {
+	resp := &clientv3.GetResponse{}
+	if status != nil {
+		value, _ := json.Marshal(status)
+		resp.Kvs = []*mvccpb.KeyValue{{Key: []byte("/rooms/" + roomID + "/janus"), Value: value, ModRevision: 7}}
+	}
+	s.mockEtcd.EXPECT().Get(gomock.Any(), "/rooms/"+roomID+"/janus").Return(resp, nil)
+}
+
+func (s *RoomGCTestSuite) TestCollect_SkipsCanaryAndReferencedRooms() {
+	s.watcher.activeRooms.Store("room-1", &ActiveRoom{JanusRoomID: 100001})
+
+	s.mockJanus.EXPECT().ListRooms(gomock.Any()).Return([]janus.RoomInfo{
+		{Room: 999999, Description: "canary"},
+		{Room: 100001, Description: "room-1"},
+	}, nil)
+	s.mockRooms.EXPECT().GetCachedState("room-1").Return(s.onAirState("test-janus-01"), true)
+
+	s.Require().NoError(s.gc.collect(s.ctx, time.Now()))
+	s.Empty(s.gc.orphans)
+}
+
+func (s *RoomGCTestSuite) TestCollect_DestroysAfterGracePeriod() {
+	now := time.Now()
+	rooms := []janus.RoomInfo{{Room: 100002, Description: "room-2"}}
+
+	// first pass only marks the room
+	s.mockJanus.EXPECT().ListRooms(gomock.Any()).Return(rooms, nil)
+	s.Require().NoError(s.gc.collect(s.ctx, now))
+	s.Contains(s.gc.orphans, int64(100002))
+
+	// still within grace period
+	s.mockJanus.EXPECT().ListRooms(gomock.Any()).Return(rooms, nil)
+	s.Require().NoError(s.gc.collect(s.ctx, now.Add(s.gracePeriod/2)))
+	s.Contains(s.gc.orphans, int64(100002))
+
+	// grace period elapsed
+	s.mockJanus.EXPECT().ListRooms(gomock.Any()).Return(rooms, nil)
+	s.mockJanus.EXPECT().DestroyRoom(gomock.Any(), int64(100002)).Return(nil)
+	s.expectJanusStatus("room-2", nil)
+	s.Require().NoError(s.gc.collect(s.ctx, now.Add(s.gracePeriod)))
+	s.Empty(s.gc.orphans)
+}
+
+func (s *RoomGCTestSuite) TestCollect_ForgetsStaleActiveRoom() {
+	now := time.Now()
+	rooms := []janus.RoomInfo{{Room: 100003, Description: "room-3"}}
+	// left behind by rebuild, etcd no longer knows the room
+	s.watcher.activeRooms.Store("room-3", &ActiveRoom{JanusRoomID: 100003})
+	s.watcher.activeLinks.Store("target-room", &LinkForwarder{SourceRoomID: "room-3"})
+
+	s.mockRooms.EXPECT().GetCachedState("room-3").Return(nil, false).Times(3)
+	s.mockJanus.EXPECT().ListRooms(gomock.Any()).Return(rooms, nil).Times(2)
+	s.mockJanus.EXPECT().DestroyRoom(gomock.Any(), int64(100003)).Return(nil)
+	s.expectJanusStatus("room-3", &etcdstate.Janus{JanusID: "test-janus-01", JanusRoomID: 100003})
+	txn := &etcdfakes.Txn{}
+	s.mockEtcd.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	s.Require().NoError(s.gc.collect(s.ctx, now))
+	s.Require().NoError(s.gc.collect(s.ctx, now.Add(s.gracePeriod)))
+
+	_, ok := s.watcher.activeRooms.Load("room-3")
+	s.False(ok)
+	_, ok = s.watcher.activeLinks.Load("target-room")
+	s.False(ok)
+	s.Require().Len(txn.Ops, 1)
+	s.True(txn.Ops[0].IsDelete())
+	s.Equal("/rooms/room-3/janus", string(txn.Ops[0].KeyBytes()))
+}
+
+func (s *RoomGCTestSuite) TestCollect_KeepsJanusStatusOfOtherManager() {
+	now := time.Now()
+	rooms := []janus.RoomInfo{{Room: 100007, Description: "room-7"}}
+
+	s.mockJanus.EXPECT().ListRooms(gomock.Any()).Return(rooms, nil).Times(2)
+	s.mockJanus.EXPECT().DestroyRoom(gomock.Any(), int64(100007)).Return(nil)
+	// the room moved to another manager, which owns the key now
+	s.expectJanusStatus("room-7", &etcdstate.Janus{JanusID: "other-janus", JanusRoomID: 200007})
+
+	s.Require().NoError(s.gc.collect(s.ctx, now))
+	s.Require().NoError(s.gc.collect(s.ctx, now.Add(s.gracePeriod)))
+	s.Empty(s.gc.orphans)
+}
+
+func (s *RoomGCTestSuite) TestCollect_AssignedToOtherJanus() {
+	s.watcher.activeRooms.Store("room-4", &ActiveRoom{JanusRoomID: 100004})
+
+	s.mockJanus.EXPECT().ListRooms(gomock.Any()).Return([]janus.RoomInfo{
+		{Room: 100004, Description: "room-4"},
+	}, nil)
+	s.mockRooms.EXPECT().GetCachedState("room-4").Return(s.onAirState("other-janus"), true)
+
+	s.Require().NoError(s.gc.collect(s.ctx, time.Now()))
+	s.Contains(s.gc.orphans, int64(100004))
+}
+
+func (s *RoomGCTestSuite) TestCollect_DropsCandidateWhenReferencedAgain() {
+	now := time.Now()
+	s.mockJanus.EXPECT().ListRooms(gomock.Any()).Return([]janus.RoomInfo{
+		{Room: 100005, Description: "room-5"},
+	}, nil).Times(2)
+
+	s.Require().NoError(s.gc.collect(s.ctx, now))
+	s.Contains(s.gc.orphans, int64(100005))
+
+	s.watcher.activeRooms.Store("room-5", &ActiveRoom{JanusRoomID: 100005})
+	s.mockRooms.EXPECT().GetCachedState("room-5").Return(s.onAirState("test-janus-01"), true)
+
+	s.Require().NoError(s.gc.collect(s.ctx, now.Add(s.gracePeriod)))
+	s.Empty(s.gc.orphans)
+}
+
+func (s *RoomGCTestSuite) TestCollect_DestroyErrorKeepsCandidate() {
+	now := time.Now()
+	rooms := []janus.RoomInfo{{Room: 100006, Description: "room-6"}}
+
+	s.mockJanus.EXPECT().ListRooms(gomock.Any()).Return(rooms, nil).Times(2)
+	s.mockJanus.EXPECT().DestroyRoom(gomock.Any(), int64(100006)).Return(errors.New("janus down"))
+
+	s.Require().NoError(s.gc.collect(s.ctx, now))
+	s.Require().NoError(s.gc.collect(s.ctx, now.Add(s.gracePeriod)))
+	s.Contains(s.gc.orphans, int64(100006))
+}
+
+func (s *RoomGCTestSuite) TestCollect_ListRoomsError() {
+	s.mockJanus.EXPECT().ListRooms(gomock.Any()).Return(nil, errors.New("list failed"))
+
+	s.Error(s.gc.collect(s.ctx, time.Now()))
+}
+
+func (s *RoomGCTestSuite) TestStartStop() {
+	s.mockJanus.EXPECT().ListRooms(gomock.Any()).Return(nil, nil).AnyTimes()
+
+	s.gc.interval = 10 * time.Millisecond
+	s.Require().NoError(s.gc.Start(s.ctx))
+	time.Sleep(30 * time.Millisecond)
+	s.gc.Stop()
+}
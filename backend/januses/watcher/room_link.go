@@ -0,0 +1,159 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	etcdstate "github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// LinkForwarder tracks the RTP forwarder of a source room into the mixer of a linked target room
+type LinkForwarder struct {
+	SourceRoomID string
+	JanusRoomID  int64
+	StreamID     int64
+	FwIP         string
+	FwPort       int
+	// FwSRTP is the SRTP key of the forwarder, empty when it forwards plain RTP
+	FwSRTP string
+	// Group is the forwarded participant group, empty when the whole source room is forwarded
+	Group string
+}
+
+// processLink keeps the forwarder of a cross-room link, the link is stored under the target
+// room and handled by the Janus hosting the source room
+func (w *RoomWatcher) processLink(ctx context.Context, roomID string, state *etcdstate.RoomState) error {
+	link := state.GetLink()
+	mixer := state.GetMixer()
+
+	var linkFw *LinkForwarder
+	if val, ok := w.activeLinks.Load(roomID); ok {
+		linkFw = val.(*LinkForwarder)
+	}
+
+	var source *ActiveRoom
+	if link != nil {
+		if val, ok := w.activeRooms.Load(link.SourceRoomID); ok {
+			source = val.(*ActiveRoom)
+		} else if w.isAssignedToUs(link.SourceRoomID) {
+			// nothing else triggers the target room once the source room is created, retry
+			return fmt.Errorf("source room %s of link not created yet", link.SourceRoomID)
+		}
+	}
+
+	group := ""
+	if link.GetAnchorID() != "" {
+		group = janus.GroupLink
+	}
+
+	shouldForward := source != nil &&
+		state.GetLiveMeta().GetStatus() == constants.RoomStatusOnAir &&
+		mixer.GetLinkPort() != 0
+
+	// drop the forwarder when the link, source room or target mixer changed
+	if linkFw != nil && (!shouldForward ||
+		linkFw.SourceRoomID != link.SourceRoomID ||
+		linkFw.JanusRoomID != source.JanusRoomID ||
+		linkFw.FwIP != mixer.IP ||
+		linkFw.FwPort != mixer.LinkPort ||
+		linkFw.FwSRTP != mixer.GetSRTP().GetKey() ||
+		linkFw.Group != group) {
+		if err := w.stopLinkForwarder(ctx, roomID, linkFw); err != nil {
+			return err
+		}
+		linkFw = nil
+	}
+
+	if !shouldForward || linkFw != nil {
+		return nil
+	}
+	return w.createLinkForwarder(ctx, roomID, link.SourceRoomID, source, mixer.IP, mixer.LinkPort, group, mixer.SRTP)
+}
+
+// isAssignedToUs checks the cached state of another room
+func (w *RoomWatcher) isAssignedToUs(roomID string) bool {
+	state, ok := w.GetCachedState(roomID)
+	if !ok {
+		return false
+	}
+	livemeta := state.GetLiveMeta()
+	return state.GetMeta() != nil &&
+		livemeta.GetJanusID() == w.janusID &&
+		livemeta.GetStatus() == constants.RoomStatusOnAir
+}
+
+func (w *RoomWatcher) createLinkForwarder(
+	ctx context.Context,
+	roomID, sourceRoomID string,
+	source *ActiveRoom,
+	fwip string,
+	fwport int,
+	group string,
+	srtp *etcdstate.SRTP,
+) error {
+	linkFw := &LinkForwarder{
+		SourceRoomID: sourceRoomID,
+		JanusRoomID:  source.JanusRoomID,
+		FwIP:         fwip,
+		FwPort:       fwport,
+		FwSRTP:       srtp.GetKey(),
+		Group:        group,
+	}
+
+	// adopt the forwarder found in Janus at rebuild instead of doubling the audio
+	if streamID, ok := source.adoptExtra(fwip, fwport, group, srtp != nil); ok {
+		w.logger.Info("Adopted link RTP forwarder",
+			log.String("roomId", roomID),
+			log.String("sourceRoomId", sourceRoomID),
+			log.Int64("streamId", streamID))
+		linkFw.StreamID = streamID
+		w.activeLinks.Store(roomID, linkFw)
+		return nil
+	}
+
+	w.logger.Info("Creating link RTP forwarder",
+		log.String("roomId", roomID),
+		log.String("sourceRoomId", sourceRoomID),
+		log.Int64("janusRoomId", source.JanusRoomID),
+		log.String("fwip", fwip),
+		log.Int("fwport", fwport),
+		log.String("group", group),
+		log.Bool("srtp", srtp != nil))
+
+	streamID, err := w.janusAdmin.CreateRTPForwarder(ctx, source.JanusRoomID, fwip, fwport, group, forwardSRTP(srtp))
+	if err != nil {
+		return err
+	}
+
+	linkFw.StreamID = streamID
+	w.activeLinks.Store(roomID, linkFw)
+	return nil
+}
+
+func (w *RoomWatcher) stopLinkForwarder(ctx context.Context, roomID string, linkFw *LinkForwarder) error {
+	w.logger.Info("Stopping link RTP forwarder",
+		log.String("roomId", roomID),
+		log.String("sourceRoomId", linkFw.SourceRoomID))
+
+	err := w.janusAdmin.StopRTPForwarder(ctx, linkFw.JanusRoomID, linkFw.StreamID)
+	if err != nil && !errors.Is(err, janus.ErrNotFound) {
+		return err
+	}
+
+	w.activeLinks.Delete(roomID)
+	return nil
+}
+
+// forgetLinks drops the link forwarders of a destroyed source room, they are gone with the Janus room
+func (w *RoomWatcher) forgetLinks(sourceRoomID string) {
+	w.activeLinks.Range(func(key, value any) bool {
+		if value.(*LinkForwarder).SourceRoomID == sourceRoomID {
+			w.activeLinks.Delete(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,226 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	"github.com/imtaco/audio-rtc-exp/internal/janus/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	roomstatemocks "github.com/imtaco/audio-rtc-exp/internal/roomstate/mocks"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type RoomLinkTestSuite struct {
+	suite.Suite
+	ctrl      *gomock.Controller
+	mockJanus *mocks.MockAdmin
+	mockRooms *roomstatemocks.MockWatcher
+	watcher   *RoomWatcher
+	ctx       context.Context
+}
+
+func TestRoomLinkSuite(t *testing.T) {
+	suite.Run(t, new(RoomLinkTestSuite))
+}
+
+func (s *RoomLinkTestSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.mockJanus = mocks.NewMockAdmin(s.ctrl)
+	s.mockRooms = roomstatemocks.NewMockWatcher(s.ctrl)
+	s.ctx = context.Background()
+
+	s.watcher = &RoomWatcher{
+		Watcher:     s.mockRooms,
+		janusAdmin:  s.mockJanus,
+		janusID:     "test-janus-01",
+		prefixRooms: "/rooms/",
+		logger:      log.NewTest(s.T()),
+	}
+}
+
+func (s *RoomLinkTestSuite) TearDownTest() {
+	s.ctrl.Finish()
+}
+
+// targetState is a target room hosted by another Janus, linked from source-room
+func (s *RoomLinkTestSuite) targetState(linkPort int) *etcdstate.RoomState {
+	return &etcdstate.RoomState{
+		Meta: &etcdstate.Meta{Pin: "1234"},
+		LiveMeta: &etcdstate.LiveMeta{
+			JanusID: "other-janus",
+			Status:  constants.RoomStatusOnAir,
+		},
+		Mixer: &etcdstate.Mixer{ID: "mixer-2", IP: "10.0.0.2", Port: 5000, LinkPort: linkPort},
+		Link:  &etcdstate.Link{SourceRoomID: "source-room"},
+	}
+}
+
+func (s *RoomLinkTestSuite) TestProcessLink_CreatesForwarder() {
+	s.watcher.activeRooms.Store("source-room", &ActiveRoom{JanusRoomID: 100001})
+
+	s.mockJanus.EXPECT().
+		CreateRTPForwarder(gomock.Any(), int64(100001), "10.0.0.2", 5002, "", nil).
+		Return(int64(7890), nil)
+
+	err := s.watcher.processLink(s.ctx, "target-room", s.targetState(5002))
+	s.Require().NoError(err)
+
+	val, ok := s.watcher.activeLinks.Load("target-room")
+	s.Require().True(ok)
+	linkFw := val.(*LinkForwarder)
+	s.Equal("source-room", linkFw.SourceRoomID)
+	s.Equal(int64(7890), linkFw.StreamID)
+}
+
+func (s *RoomLinkTestSuite) TestProcessLink_ForwardsSelectedAnchor() {
+	s.watcher.activeRooms.Store("source-room", &ActiveRoom{JanusRoomID: 100001})
+	s.watcher.activeLinks.Store("target-room", &LinkForwarder{
+		SourceRoomID: "source-room",
+		JanusRoomID:  100001,
+		StreamID:     7890,
+		FwIP:         "10.0.0.2",
+		FwPort:       5002,
+	})
+
+	state := s.targetState(5002)
+	state.Link.AnchorID = "anchor-1"
+
+	// the whole-room forwarder is replaced by one of the link group
+	gomock.InOrder(
+		s.mockJanus.EXPECT().
+			StopRTPForwarder(gomock.Any(), int64(100001), int64(7890)).
+			Return(nil),
+		s.mockJanus.EXPECT().
+			CreateRTPForwarder(gomock.Any(), int64(100001), "10.0.0.2", 5002, janus.GroupLink, nil).
+			Return(int64(7891), nil),
+	)
+
+	err := s.watcher.processLink(s.ctx, "target-room", state)
+	s.Require().NoError(err)
+
+	val, _ := s.watcher.activeLinks.Load("target-room")
+	s.Equal(janus.GroupLink, val.(*LinkForwarder).Group)
+}
+
+func (s *RoomLinkTestSuite) TestProcessLink_WaitsForLinkPort() {
+	s.watcher.activeRooms.Store("source-room", &ActiveRoom{JanusRoomID: 100001})
+
+	// mixer has not published the link port yet
+	err := s.watcher.processLink(s.ctx, "target-room", s.targetState(0))
+	s.Require().NoError(err)
+
+	_, ok := s.watcher.activeLinks.Load("target-room")
+	s.False(ok)
+}
+
+func (s *RoomLinkTestSuite) TestProcessLink_SourceNotOurs() {
+	s.mockRooms.EXPECT().
+		GetCachedState("source-room").
+		Return(&etcdstate.RoomState{
+			Meta:     &etcdstate.Meta{Pin: "1234"},
+			LiveMeta: &etcdstate.LiveMeta{JanusID: "other-janus", Status: constants.RoomStatusOnAir},
+		}, true)
+
+	err := s.watcher.processLink(s.ctx, "target-room", s.targetState(5002))
+	s.Require().NoError(err)
+}
+
+func (s *RoomLinkTestSuite) TestProcessLink_SourceNotCreatedYet() {
+	s.mockRooms.EXPECT().
+		GetCachedState("source-room").
+		Return(&etcdstate.RoomState{
+			Meta:     &etcdstate.Meta{Pin: "1234"},
+			LiveMeta: &etcdstate.LiveMeta{JanusID: "test-janus-01", Status: constants.RoomStatusOnAir},
+		}, true)
+
+	// error makes the watcher retry the target room
+	err := s.watcher.processLink(s.ctx, "target-room", s.targetState(5002))
+	s.Require().Error(err)
+}
+
+func (s *RoomLinkTestSuite) TestProcessLink_StopsForwarderOnUnlink() {
+	s.watcher.activeRooms.Store("source-room", &ActiveRoom{JanusRoomID: 100001})
+	s.watcher.activeLinks.Store("target-room", &LinkForwarder{
+		SourceRoomID: "source-room",
+		JanusRoomID:  100001,
+		StreamID:     7890,
+		FwIP:         "10.0.0.2",
+		FwPort:       5002,
+	})
+
+	state := s.targetState(5002)
+	state.Link = nil
+
+	s.mockJanus.EXPECT().
+		StopRTPForwarder(gomock.Any(), int64(100001), int64(7890)).
+		Return(nil)
+
+	err := s.watcher.processLink(s.ctx, "target-room", state)
+	s.Require().NoError(err)
+
+	_, ok := s.watcher.activeLinks.Load("target-room")
+	s.False(ok)
+}
+
+func (s *RoomLinkTestSuite) TestProcessLink_RecreatesOnLinkPortChange() {
+	s.watcher.activeRooms.Store("source-room", &ActiveRoom{JanusRoomID: 100001})
+	s.watcher.activeLinks.Store("target-room", &LinkForwarder{
+		SourceRoomID: "source-room",
+		JanusRoomID:  100001,
+		StreamID:     7890,
+		FwIP:         "10.0.0.2",
+		FwPort:       5002,
+	})
+
+	gomock.InOrder(
+		s.mockJanus.EXPECT().
+			StopRTPForwarder(gomock.Any(), int64(100001), int64(7890)).
+			Return(janus.ErrNotFound),
+		s.mockJanus.EXPECT().
+			CreateRTPForwarder(gomock.Any(), int64(100001), "10.0.0.2", 5004, "", nil).
+			Return(int64(7891), nil),
+	)
+
+	err := s.watcher.processLink(s.ctx, "target-room", s.targetState(5004))
+	s.Require().NoError(err)
+
+	val, _ := s.watcher.activeLinks.Load("target-room")
+	s.Equal(int64(7891), val.(*LinkForwarder).StreamID)
+}
+
+func (s *RoomLinkTestSuite) TestProcessLink_AdoptsRebuiltForwarder() {
+	s.watcher.activeRooms.Store("source-room", &ActiveRoom{
+		JanusRoomID: 100001,
+		StreamID:    111,
+		FwIP:        "10.0.0.1",
+		FwPort:      5000,
+		Extra:       []janus.RTPForwarderInfo{{StreamID: 222, Host: "10.0.0.2", Port: 5002}},
+	})
+
+	// no Janus call expected, the forwarder from rebuild is reused
+	err := s.watcher.processLink(s.ctx, "target-room", s.targetState(5002))
+	s.Require().NoError(err)
+
+	val, _ := s.watcher.activeLinks.Load("target-room")
+	s.Equal(int64(222), val.(*LinkForwarder).StreamID)
+
+	val, _ = s.watcher.activeRooms.Load("source-room")
+	s.Empty(val.(*ActiveRoom).Extra)
+}
+
+func (s *RoomLinkTestSuite) TestForgetLinks() {
+	s.watcher.activeLinks.Store("target-1", &LinkForwarder{SourceRoomID: "source-room"})
+	s.watcher.activeLinks.Store("target-2", &LinkForwarder{SourceRoomID: "other-room"})
+
+	s.watcher.forgetLinks("source-room")
+
+	_, ok := s.watcher.activeLinks.Load("target-1")
+	s.False(ok)
+	_, ok = s.watcher.activeLinks.Load("target-2")
+	s.True(ok)
+}
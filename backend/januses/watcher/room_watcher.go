@@ -2,10 +2,9 @@ package watcher
 
 import (
 	"context"
-	"crypto/rand"
 	"encoding/json"
 	"fmt"
-	"math/big"
+	"hash/fnv"
 	"sync"
 	"time"
 
@@ -15,11 +14,17 @@ import (
 	etcdstate "github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/janus"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
-	etcdwatcher "github.com/imtaco/audio-rtc-exp/internal/reswatcher/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/roomstate"
 )
 
 const (
 	maxRoomCreationAttempts = 5
+	// Janus room IDs are derived into [janusRoomIDMin, 2^53), above the 6-digit IDs such as the
+	// canary room and within the integers JavaScript clients read exactly
+	janusRoomIDMin   = 1_000_000
+	janusRoomIDSpace = 1<<53 - janusRoomIDMin
+	// srtpAdopted marks SRTP forwarders found in Janus at rebuild, Janus does not list their key
+	srtpAdopted = "adopted"
 )
 
 // ActiveRoom tracks the Janus room state
@@ -28,19 +33,65 @@ type ActiveRoom struct {
 	StreamID    int64
 	FwIP        string
 	FwPort      int
+	// FwSRTP is the SRTP key of the forwarder, empty when it forwards plain RTP
+	FwSRTP string
+	// forwarders found in Janus at rebuild besides the main one, adopted by links
+	Extra []janus.RTPForwarderInfo
+}
+
+// adoptExtra takes the extra forwarder of group to fwip:fwport out of the room
+func (r *ActiveRoom) adoptExtra(fwip string, fwport int, group string, srtp bool) (int64, bool) {
+	for i, fw := range r.Extra {
+		if fw.Host == fwip && fw.Port == fwport && fw.Group == group && fw.SRTP == srtp {
+			r.Extra = append(r.Extra[:i:i], r.Extra[i+1:]...)
+			return fw.StreamID, true
+		}
+	}
+	return 0, false
+}
+
+// srtpMatches reports whether a forwarder encrypted with key suits the mixer SRTP, any key is
+// taken for forwarders adopted at rebuild
+func srtpMatches(key string, srtp *etcdstate.SRTP) bool {
+	if key == srtpAdopted {
+		return srtp != nil
+	}
+	return key == srtp.GetKey()
+}
+
+// forwardSRTP returns the Janus forwarder crypto of the mixer SRTP, nil for plain RTP
+func forwardSRTP(srtp *etcdstate.SRTP) *janus.SRTP {
+	if srtp == nil {
+		return nil
+	}
+	return &janus.SRTP{
+		Suite:  etcdstate.SRTPTagLength(srtp.Suite),
+		Crypto: srtp.Key,
+	}
+}
+
+// etcdKV is the etcd access of RoomWatcher
+type etcdKV interface {
+	etcd.KV
+	etcd.Tx
 }
 
 // RoomWatcher watches mixer data and manages Janus RTP forwarders
 type RoomWatcher struct {
-	etcdwatcher.RoomWatcher
-	etcdClient    etcd.KV
+	roomstate.Watcher
+	etcdClient etcdKV
+	// mu serializes room processing and rebuild with room GC and the marker sender
+	mu            sync.Mutex
 	janusAdmin    janus.Admin
 	janusID       string
 	janusAdvHost  string
 	prefixRooms   string
 	prefixJanuses string
 	canaryRoomID  int64
+	// talkingEvents creates Janus rooms reporting who talks, for the active speaker metadata
+	talkingEvents bool
 	activeRooms   sync.Map
+	activeLinks   sync.Map // target roomID -> *LinkForwarder
 	logger        *log.Logger
 }
 
@@ -66,13 +117,14 @@ func NewRoomWatcher(
 		etcdClient:    etcdClient,
 	}
 
-	w.RoomWatcher = etcdwatcher.NewRoomWatcher(
-		etcdClient,
-		prefixRooms,
-		[]string{constants.RoomKeyMeta, constants.RoomKeyLiveMeta, constants.RoomKeyMixer},
-		w.processChange,
-		logger,
-	)
+	w.Watcher = roomstate.New(&roomstate.Config{
+		Client:    etcdClient,
+		Prefix:    prefixRooms,
+		KeyTypes:  []string{constants.RoomKeyMeta, constants.RoomKeyLiveMeta, constants.RoomKeyMixer, constants.RoomKeyLink},
+		OnChange:  w.processChange,
+		Rebuilder: w,
+		Logger:    logger,
+	})
 	return w
 }
 
@@ -107,25 +159,63 @@ func (w *RoomWatcher) updateJanusStatus(ctx context.Context, roomID string, janu
 	return nil
 }
 
-// createRoom creates a Janus room with random ID to avoid collisions
-func (w *RoomWatcher) createRoom(ctx context.Context, roomID, pin string) (int64, error) {
+// fecExpectedLoss is the packet loss Janus sizes in-band FEC for in rooms with FEC on, in percent
+const fecExpectedLoss = 10
+
+// EnableTalkingEvents creates the Janus rooms with talking events from now on, rooms
+// created before keep running without
+func (w *RoomWatcher) EnableTalkingEvents() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.talkingEvents = true
+}
+
+// roomAudio maps the audio settings of a room to the Opus settings of its Janus room
+func roomAudio(audio *etcdstate.AudioParams, talkingEvents bool) *janus.RoomAudio {
+	if audio == nil && !talkingEvents {
+		return nil
+	}
+	ra := &janus.RoomAudio{TalkingEvents: talkingEvents}
+	if fec := audio.GetFEC(); fec != nil && *fec {
+		ra.ExpectedLoss = fecExpectedLoss
+	}
+	if stereo := audio.GetStereo(); stereo != nil {
+		ra.Stereo = *stereo
+	}
+	return ra
+}
+
+// janusRoomID derives the Janus room ID of a room from a stable hash of its ID, so a room maps
+// to the same Janus room across restarts. Attempts after the first rehash the room ID with the
+// attempt number to probe past collisions
+func janusRoomID(roomID string, attempt int) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(roomID))
+	if attempt > 1 {
+		_, _ = fmt.Fprintf(h, "#%d", attempt)
+	}
+	return janusRoomIDMin + int64(h.Sum64()%janusRoomIDSpace) // #nosec G115 -- below 2^53
+}
+
+// createRoom creates a Janus room with the ID derived from roomID, probing on collisions
+func (w *RoomWatcher) createRoom(ctx context.Context, roomID, pin string, bitrate int, audio *etcdstate.AudioParams) (int64, error) {
 	for attempt := 1; attempt <= maxRoomCreationAttempts; attempt++ {
-		// Generate 6-digit room ID using crypto/rand
-		randNum, err := cryptoRandInt(900000)
-		if err != nil {
-			return 0, fmt.Errorf("failed to generate random room ID: %w", err)
-		}
-		janusRoomID := 100000 + randNum
+		janusRoomID := janusRoomID(roomID, attempt)
 
-		err = w.janusAdmin.CreateRoom(ctx, janusRoomID, roomID, pin)
+		err := w.janusAdmin.CreateRoom(ctx, janusRoomID, roomID, pin, bitrate, roomAudio(audio, w.talkingEvents))
 		if err == nil {
+			if attempt > 1 {
+				w.logger.Info("Room created with probed ID",
+					log.String("roomId", roomID),
+					log.Int64("janusRoomId", janusRoomID),
+					log.Int("attempt", attempt))
+			}
 			return janusRoomID, nil
 		}
 		if !errors.Is(err, janus.ErrAlreadyExisted) {
 			return 0, err
 		}
 		w.logger.Info("Room ID already exists, retrying...", log.Int64("janusRoomId", janusRoomID))
-		continue
 	}
 
 	return 0, fmt.Errorf("failed to create room after %d attempts", maxRoomCreationAttempts)
@@ -145,7 +235,14 @@ func (w *RoomWatcher) destroyRoom(ctx context.Context, janusRoomID int64) error
 }
 
 // createRtpForwarder creates an RTP forwarder for a room
-func (w *RoomWatcher) createRtpForwarder(ctx context.Context, roomID string, activeRoom *ActiveRoom, fwip string, fwport int) error {
+func (w *RoomWatcher) createRtpForwarder(
+	ctx context.Context,
+	roomID string,
+	activeRoom *ActiveRoom,
+	fwip string,
+	fwport int,
+	srtp *etcdstate.SRTP,
+) error {
 	if activeRoom.JanusRoomID == 0 {
 		w.logger.Info("Room meta not found or no janusRoomId, skipping forwarder setup", log.String("roomId", roomID))
 		return nil
@@ -155,9 +252,10 @@ func (w *RoomWatcher) createRtpForwarder(ctx context.Context, roomID string, act
 		log.String("roomId", roomID),
 		log.Int64("janusRoomId", activeRoom.JanusRoomID),
 		log.String("fwip", fwip),
-		log.Int("fwport", fwport))
+		log.Int("fwport", fwport),
+		log.Bool("srtp", srtp != nil))
 
-	streamID, err := w.janusAdmin.CreateRTPForwarder(ctx, activeRoom.JanusRoomID, fwip, fwport)
+	streamID, err := w.janusAdmin.CreateRTPForwarder(ctx, activeRoom.JanusRoomID, fwip, fwport, "", forwardSRTP(srtp))
 	if err != nil {
 		return err
 	}
@@ -165,6 +263,7 @@ func (w *RoomWatcher) createRtpForwarder(ctx context.Context, roomID string, act
 	activeRoom.StreamID = streamID
 	activeRoom.FwIP = fwip
 	activeRoom.FwPort = fwport
+	activeRoom.FwSRTP = srtp.GetKey()
 
 	return nil
 }
@@ -187,15 +286,26 @@ func (w *RoomWatcher) stopRtpForwarder(ctx context.Context, roomID string, activ
 	activeRoom.StreamID = 0
 	activeRoom.FwIP = ""
 	activeRoom.FwPort = 0
+	activeRoom.FwSRTP = ""
 
 	return nil
 }
 
-//nolint:gocyclo
 func (w *RoomWatcher) processChange(_ context.Context, roomID string, state *etcdstate.RoomState) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if err := w.processRoom(ctx, roomID, state); err != nil {
+		return err
+	}
+	return w.processLink(ctx, roomID, state)
+}
+
+//nolint:gocyclo
+func (w *RoomWatcher) processRoom(ctx context.Context, roomID string, state *etcdstate.RoomState) error {
 	mixer := state.GetMixer()
 	meta := state.GetMeta()
 	livemeta := state.GetLiveMeta()
@@ -212,18 +322,20 @@ func (w *RoomWatcher) processChange(_ context.Context, roomID string, state *etc
 		livemeta.JanusID == w.janusID &&
 		livemeta.Status == constants.RoomStatusOnAir
 
-	// Should have forwarder if: assigned to us, mixer data exists with port
-	shouldHaveForwarder := isAssignedToUs && mixer != nil && mixer.Port != 0
+	// Should have forwarder if: assigned to us, mixer data exists with port and the room is not
+	// ending past its forwarder, the Janus room is kept until the live is stopped
+	shouldHaveForwarder := isAssignedToUs && mixer != nil && mixer.Port != 0 &&
+		!meta.GetEndStage().Reached(constants.EndStageStoppingForwarder)
 
 	// Handle room creation/removal
 	switch {
 	case isAssignedToUs && !hasJanusRoom:
 		// Ensure Janus room exists
-		janusRoomID, err := w.createRoom(ctx, roomID, meta.Pin)
+		janusRoomID, err := w.createRoom(ctx, roomID, meta.Pin, meta.MaxBitrate, meta.GetAudio())
 		if err != nil {
 			return err
 		}
-		if err := w.updateJanusStatus(ctx, roomID, janusRoomID, "room_created"); err != nil {
+		if err := w.updateJanusStatus(ctx, roomID, janusRoomID, constants.JanusStatusRoomCreated); err != nil {
 			return err
 		}
 		activeRoom = &ActiveRoom{JanusRoomID: janusRoomID}
@@ -238,6 +350,7 @@ func (w *RoomWatcher) processChange(_ context.Context, roomID string, state *etc
 			return err
 		}
 		w.activeRooms.Delete(roomID)
+		w.forgetLinks(roomID)
 		return nil
 	case !isAssignedToUs && !hasJanusRoom:
 		// not our business
@@ -255,10 +368,10 @@ func (w *RoomWatcher) processChange(_ context.Context, roomID string, state *etc
 	switch {
 	case shouldHaveForwarder && !hasRTPForwarder:
 		// Create RTP forwarder
-		if err := w.createRtpForwarder(ctx, roomID, activeRoom, mixer.IP, mixer.Port); err != nil {
+		if err := w.createRtpForwarder(ctx, roomID, activeRoom, mixer.IP, mixer.Port, mixer.SRTP); err != nil {
 			return err
 		}
-		if err := w.updateJanusStatus(ctx, roomID, activeRoom.JanusRoomID, "forwarding"); err != nil {
+		if err := w.updateJanusStatus(ctx, roomID, activeRoom.JanusRoomID, constants.JanusStatusForwarding); err != nil {
 			return err
 		}
 
@@ -266,22 +379,22 @@ func (w *RoomWatcher) processChange(_ context.Context, roomID string, state *etc
 		if err := w.stopRtpForwarder(ctx, roomID, activeRoom); err != nil {
 			return err
 		}
-		if err := w.updateJanusStatus(ctx, roomID, activeRoom.JanusRoomID, "not_forwarding"); err != nil {
+		if err := w.updateJanusStatus(ctx, roomID, activeRoom.JanusRoomID, constants.JanusStatusNotForwarding); err != nil {
 			return err
 		}
 
 	case shouldHaveForwarder && hasRTPForwarder:
 		// Check if mixer endpoint changed
-		if activeRoom.FwIP != mixer.IP || activeRoom.FwPort != mixer.Port {
+		if activeRoom.FwIP != mixer.IP || activeRoom.FwPort != mixer.Port || !srtpMatches(activeRoom.FwSRTP, mixer.SRTP) {
 			w.logger.Info("Mixer endpoint changed, recreating forwarder", log.String("roomId", roomID))
 
 			if err := w.stopRtpForwarder(ctx, roomID, activeRoom); err != nil {
 				return err
 			}
-			if err := w.createRtpForwarder(ctx, roomID, activeRoom, mixer.IP, mixer.Port); err != nil {
+			if err := w.createRtpForwarder(ctx, roomID, activeRoom, mixer.IP, mixer.Port, mixer.SRTP); err != nil {
 				return err
 			}
-			if err := w.updateJanusStatus(ctx, roomID, activeRoom.JanusRoomID, "forwarding"); err != nil {
+			if err := w.updateJanusStatus(ctx, roomID, activeRoom.JanusRoomID, constants.JanusStatusForwarding); err != nil {
 				return err
 			}
 		}
@@ -290,6 +403,19 @@ func (w *RoomWatcher) processChange(_ context.Context, roomID string, state *etc
 	return nil
 }
 
+// RoomIDOf returns the room of a Janus room ID, false when no active room uses it
+func (w *RoomWatcher) RoomIDOf(janusRoomID int64) (string, bool) {
+	var roomID string
+	w.activeRooms.Range(func(key, val any) bool {
+		if val.(*ActiveRoom).JanusRoomID == janusRoomID {
+			roomID = key.(string)
+			return false
+		}
+		return true
+	})
+	return roomID, roomID != ""
+}
+
 // JanusRestartDetected handles Janus restart event
 func (w *RoomWatcher) JanusRestartDetected() error {
 	w.logger.Warn("Janus restart detected, clearing active rooms")
@@ -301,8 +427,12 @@ func (w *RoomWatcher) JanusRestartDetected() error {
 
 // rebuildStart is called before rebuild
 func (w *RoomWatcher) RebuildStart(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	w.logger.Info("Starting rebuild of RoomWatcher")
-	w.activeRooms = sync.Map{}
+	w.activeRooms.Clear()
+	w.activeLinks.Clear()
 
 	w.logger.Info("Building janusRoomId -> streamId mapping from Janus...")
 
@@ -332,12 +462,16 @@ func (w *RoomWatcher) RebuildStart(ctx context.Context) error {
 			JanusRoomID: janusRoomID,
 		}
 
-		// Pick the first forwarder if exists
+		// Pick the first forwarder if exists, the rest may belong to links
 		if len(forwarders) > 0 {
 			fw := forwarders[0]
 			activeRoom.StreamID = fw.StreamID
 			activeRoom.FwIP = fw.Host
 			activeRoom.FwPort = fw.Port
+			if fw.SRTP {
+				activeRoom.FwSRTP = srtpAdopted
+			}
+			activeRoom.Extra = forwarders[1:]
 		}
 
 		w.activeRooms.Store(roomID, activeRoom)
@@ -356,6 +490,9 @@ func (w *RoomWatcher) RebuildEnd(_ context.Context) error {
 
 // rebuildState is called for each room during rebuild
 func (w *RoomWatcher) RebuildState(_ context.Context, roomID string, stateData *etcdstate.RoomState) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	val, ok := w.activeRooms.Load(roomID)
 	if !ok {
 		return nil // no active room, nothing to do
@@ -366,10 +503,29 @@ func (w *RoomWatcher) RebuildState(_ context.Context, roomID string, stateData *
 	mixerData := stateData.Mixer
 
 	// Match forwarder with cached mixer data
-	if mixerData != nil && activeRoom.FwIP == mixerData.IP && activeRoom.FwPort == mixerData.Port {
+	if mixerData != nil && activeRoom.FwIP == mixerData.IP && activeRoom.FwPort == mixerData.Port &&
+		srtpMatches(activeRoom.FwSRTP, mixerData.SRTP) {
 		w.logger.Debug("Room matched during rebuild", log.String("roomId", roomID))
+		activeRoom.FwSRTP = mixerData.SRTP.GetKey()
 		return nil
 	}
+	// The first forwarder may be a link one, swap with the extra one matching the mixer
+	if mixerData != nil && activeRoom.StreamID != 0 {
+		if streamID, ok := activeRoom.adoptExtra(mixerData.IP, mixerData.Port, "", mixerData.SRTP != nil); ok {
+			w.logger.Debug("Room matched extra forwarder during rebuild", log.String("roomId", roomID))
+			activeRoom.Extra = append(activeRoom.Extra, janus.RTPForwarderInfo{
+				StreamID: activeRoom.StreamID,
+				Host:     activeRoom.FwIP,
+				Port:     activeRoom.FwPort,
+				SRTP:     activeRoom.FwSRTP != "",
+			})
+			activeRoom.StreamID = streamID
+			activeRoom.FwIP = mixerData.IP
+			activeRoom.FwPort = mixerData.Port
+			activeRoom.FwSRTP = mixerData.SRTP.GetKey()
+			return nil
+		}
+	}
 	if activeRoom.StreamID != 0 {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
@@ -381,12 +537,3 @@ func (w *RoomWatcher) RebuildState(_ context.Context, roomID string, stateData *
 
 	return nil
 }
-
-// cryptoRandInt generates a cryptographically secure random integer in range [0, maxVal)
-func cryptoRandInt(maxVal int64) (int64, error) {
-	nBig, err := rand.Int(rand.Reader, big.NewInt(maxVal))
-	if err != nil {
-		return 0, err
-	}
-	return nBig.Int64(), nil
-}
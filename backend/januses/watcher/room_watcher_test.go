@@ -8,6 +8,7 @@ import (
 	"github.com/imtaco/audio-rtc-exp/internal/errors"
 	etcdfakes "github.com/imtaco/audio-rtc-exp/internal/etcd/fakes"
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate/fixtures"
 	"github.com/imtaco/audio-rtc-exp/internal/janus"
 	"github.com/imtaco/audio-rtc-exp/internal/janus/mocks"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
@@ -53,57 +54,82 @@ func (s *RoomWatcherTestSuite) TearDownTest() {
 	s.ctrl.Finish()
 }
 
-func (s *RoomWatcherTestSuite) TestCryptoRandInt_WithinRange() {
-	maxVal := int64(900000)
-
-	for i := 0; i < 100; i++ {
-		val, err := cryptoRandInt(maxVal)
-		s.Require().NoError(err)
-		s.GreaterOrEqual(val, int64(0))
-		s.Less(val, maxVal)
+func (s *RoomWatcherTestSuite) TestJanusRoomID() {
+	// stable for a room, distinct across rooms and attempts
+	s.Equal(janusRoomID("room-123", 1), janusRoomID("room-123", 1))
+	s.NotEqual(janusRoomID("room-123", 1), janusRoomID("room-456", 1))
+	s.NotEqual(janusRoomID("room-123", 1), janusRoomID("room-123", 2))
+
+	for _, roomID := range []string{"", "a", "room-123", "550e8400-e29b-41d4-a716-446655440000"} {
+		for attempt := 1; attempt <= maxRoomCreationAttempts; attempt++ {
+			id := janusRoomID(roomID, attempt)
+			s.GreaterOrEqual(id, int64(janusRoomIDMin))
+			s.Less(id, int64(1<<53))
+		}
 	}
 }
 
-func (s *RoomWatcherTestSuite) TestCryptoRandInt_SmallRange() {
-	maxVal := int64(10)
+func (s *RoomWatcherTestSuite) TestCreateRoom_Success() {
+	roomID := "room-123"
+	pin := "1234"
 
-	for i := 0; i < 50; i++ {
-		val, err := cryptoRandInt(maxVal)
-		s.Require().NoError(err)
-		s.GreaterOrEqual(val, int64(0))
-		s.Less(val, maxVal)
-	}
+	s.mockJanus.EXPECT().
+		CreateRoom(gomock.Any(), janusRoomID(roomID, 1), roomID, pin, 0, nil).
+		Return(nil)
+
+	id, err := s.watcher.createRoom(s.ctx, roomID, pin, 0, nil)
+	s.Require().NoError(err)
+	s.Equal(janusRoomID(roomID, 1), id)
 }
 
-func (s *RoomWatcherTestSuite) TestCryptoRandInt_Distribution() {
-	maxVal := int64(10)
-	counts := make(map[int64]int)
+func (s *RoomWatcherTestSuite) TestCreateRoom_WithBitrateCap() {
+	roomID := "room-123"
+	pin := "1234"
 
-	for i := 0; i < 1000; i++ {
-		val, err := cryptoRandInt(maxVal)
-		s.Require().NoError(err)
-		counts[val]++
-	}
+	s.mockJanus.EXPECT().
+		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin, 32000, nil).
+		Return(nil)
 
-	s.Len(counts, int(maxVal), "Should generate all possible values")
+	_, err := s.watcher.createRoom(s.ctx, roomID, pin, 32000, nil)
+	s.Require().NoError(err)
+}
 
-	for i := int64(0); i < maxVal; i++ {
-		s.Greater(counts[i], 0, "Value %d should appear at least once", i)
-	}
+func (s *RoomWatcherTestSuite) TestCreateRoom_WithAudio() {
+	roomID := "room-123"
+	pin := "1234"
+	on, off := true, false
+
+	s.mockJanus.EXPECT().
+		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin, 0, &janus.RoomAudio{ExpectedLoss: fecExpectedLoss, Stereo: true}).
+		Return(nil)
+	_, err := s.watcher.createRoom(s.ctx, roomID, pin, 0, &etcdstate.AudioParams{FEC: &on, Stereo: &on})
+	s.Require().NoError(err)
+
+	// DTX and the average bitrate are negotiated with the anchors only
+	s.mockJanus.EXPECT().
+		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin, 0, &janus.RoomAudio{}).
+		Return(nil)
+	_, err = s.watcher.createRoom(s.ctx, roomID, pin, 0, &etcdstate.AudioParams{FEC: &off, DTX: &on, MaxAverageBitrate: 24000})
+	s.Require().NoError(err)
 }
 
-func (s *RoomWatcherTestSuite) TestCreateRoom_Success() {
+func (s *RoomWatcherTestSuite) TestCreateRoom_WithTalkingEvents() {
 	roomID := "room-123"
 	pin := "1234"
+	on := true
+	s.watcher.EnableTalkingEvents()
 
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin).
+		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin, 0, &janus.RoomAudio{TalkingEvents: true}).
 		Return(nil)
+	_, err := s.watcher.createRoom(s.ctx, roomID, pin, 0, nil)
+	s.Require().NoError(err)
 
-	janusRoomID, err := s.watcher.createRoom(s.ctx, roomID, pin)
+	s.mockJanus.EXPECT().
+		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin, 0, &janus.RoomAudio{Stereo: true, TalkingEvents: true}).
+		Return(nil)
+	_, err = s.watcher.createRoom(s.ctx, roomID, pin, 0, &etcdstate.AudioParams{Stereo: &on})
 	s.Require().NoError(err)
-	s.GreaterOrEqual(janusRoomID, int64(100000))
-	s.Less(janusRoomID, int64(1000000))
 }
 
 func (s *RoomWatcherTestSuite) TestCreateRoom_RetryOnCollision() {
@@ -112,15 +138,15 @@ func (s *RoomWatcherTestSuite) TestCreateRoom_RetryOnCollision() {
 
 	// First attempt fails with collision
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin).
+		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin, 0, nil).
 		Return(errors.New(janus.ErrAlreadyExisted, "room exists"))
 
 	// Second attempt succeeds
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin).
+		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin, 0, nil).
 		Return(nil)
 
-	janusRoomID, err := s.watcher.createRoom(s.ctx, roomID, pin)
+	janusRoomID, err := s.watcher.createRoom(s.ctx, roomID, pin, 0, nil)
 	s.Require().NoError(err)
 	s.GreaterOrEqual(janusRoomID, int64(100000))
 }
@@ -131,11 +157,11 @@ func (s *RoomWatcherTestSuite) TestCreateRoom_MaxRetriesExceeded() {
 
 	// All attempts fail with collision
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin).
+		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin, 0, nil).
 		Return(errors.New(janus.ErrAlreadyExisted, "room exists")).
 		Times(maxRoomCreationAttempts)
 
-	janusRoomID, err := s.watcher.createRoom(s.ctx, roomID, pin)
+	janusRoomID, err := s.watcher.createRoom(s.ctx, roomID, pin, 0, nil)
 	s.Require().Error(err)
 	s.Contains(err.Error(), "failed to create room after")
 	s.Zero(janusRoomID)
@@ -146,10 +172,10 @@ func (s *RoomWatcherTestSuite) TestCreateRoom_OtherError() {
 	pin := "1234"
 
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin).
+		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin, 0, nil).
 		Return(errors.New(janus.ErrFailedRequest, "network error"))
 
-	janusRoomID, err := s.watcher.createRoom(s.ctx, roomID, pin)
+	janusRoomID, err := s.watcher.createRoom(s.ctx, roomID, pin, 0, nil)
 	s.Require().Error(err)
 	s.Contains(err.Error(), "network error")
 	s.Zero(janusRoomID)
@@ -199,10 +225,10 @@ func (s *RoomWatcherTestSuite) TestCreateRtpForwarder_Success() {
 	streamID := int64(7890)
 
 	s.mockJanus.EXPECT().
-		CreateRTPForwarder(gomock.Any(), activeRoom.JanusRoomID, fwip, fwport).
+		CreateRTPForwarder(gomock.Any(), activeRoom.JanusRoomID, fwip, fwport, "", nil).
 		Return(streamID, nil)
 
-	err := s.watcher.createRtpForwarder(s.ctx, roomID, activeRoom, fwip, fwport)
+	err := s.watcher.createRtpForwarder(s.ctx, roomID, activeRoom, fwip, fwport, nil)
 	s.Require().NoError(err)
 	s.Equal(streamID, activeRoom.StreamID)
 	s.Equal(fwip, activeRoom.FwIP)
@@ -218,7 +244,7 @@ func (s *RoomWatcherTestSuite) TestCreateRtpForwarder_NoJanusRoom() {
 	fwport := 5000
 
 	// Should not call Janus API
-	err := s.watcher.createRtpForwarder(s.ctx, roomID, activeRoom, fwip, fwport)
+	err := s.watcher.createRtpForwarder(s.ctx, roomID, activeRoom, fwip, fwport, nil)
 	s.Require().NoError(err)
 	s.Zero(activeRoom.StreamID)
 }
@@ -232,10 +258,10 @@ func (s *RoomWatcherTestSuite) TestCreateRtpForwarder_Error() {
 	fwport := 5000
 
 	s.mockJanus.EXPECT().
-		CreateRTPForwarder(gomock.Any(), activeRoom.JanusRoomID, fwip, fwport).
+		CreateRTPForwarder(gomock.Any(), activeRoom.JanusRoomID, fwip, fwport, "", nil).
 		Return(int64(0), janus.ErrNoneSuccessResponse)
 
-	err := s.watcher.createRtpForwarder(s.ctx, roomID, activeRoom, fwip, fwport)
+	err := s.watcher.createRtpForwarder(s.ctx, roomID, activeRoom, fwip, fwport, nil)
 	s.Require().ErrorIs(err, janus.ErrNoneSuccessResponse)
 	// s.Contains(err.Error(), "forwarder creation failed")
 	s.Zero(activeRoom.StreamID)
@@ -301,13 +327,8 @@ func (s *RoomWatcherTestSuite) TestStopRtpForwarder_OtherError() {
 
 func (s *RoomWatcherTestSuite) TestProcessChange_StateLogic_NotAssignedToUs() {
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{
-		Pin: "1234", MaxAnchors: 5,
-	})
-	state.SetLiveMeta(&etcdstate.LiveMeta{
-		JanusID: "other-janus",
-		Status:  constants.RoomStatusOnAir,
-	})
+	state.SetMeta(fixtures.Meta())
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("other-janus")))
 
 	s.NotNil(state.GetMeta())
 	s.NotNil(state.GetLiveMeta())
@@ -316,17 +337,9 @@ func (s *RoomWatcherTestSuite) TestProcessChange_StateLogic_NotAssignedToUs() {
 
 func (s *RoomWatcherTestSuite) TestProcessChange_StateLogic_AssignedToUs() {
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{
-		Pin: "1234", MaxAnchors: 5,
-	})
-	state.SetLiveMeta(&etcdstate.LiveMeta{
-		JanusID: "test-janus-01",
-		Status:  constants.RoomStatusOnAir,
-	})
-	state.SetMixer(&etcdstate.Mixer{
-		IP:   "10.0.0.1",
-		Port: 5000,
-	})
+	state.SetMeta(fixtures.Meta())
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("test-janus-01")))
+	state.SetMixer(fixtures.Mixer(fixtures.MixerAddr("10.0.0.1", 5000)))
 
 	s.NotNil(state.GetMeta())
 	s.NotNil(state.GetLiveMeta())
@@ -369,10 +382,10 @@ func (s *RoomWatcherTestSuite) TestBusinessLogic_CreateRoom_ThenAddForwarder() {
 
 	// Step 1: Create room
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin).
+		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin, 0, nil).
 		Return(nil)
 
-	janusRoomID, err := s.watcher.createRoom(s.ctx, roomID, pin)
+	janusRoomID, err := s.watcher.createRoom(s.ctx, roomID, pin, 0, nil)
 	s.Require().NoError(err)
 	s.NotZero(janusRoomID)
 
@@ -382,10 +395,10 @@ func (s *RoomWatcherTestSuite) TestBusinessLogic_CreateRoom_ThenAddForwarder() {
 	}
 
 	s.mockJanus.EXPECT().
-		CreateRTPForwarder(gomock.Any(), janusRoomID, "10.0.0.1", 5000).
+		CreateRTPForwarder(gomock.Any(), janusRoomID, "10.0.0.1", 5000, "", nil).
 		Return(int64(7890), nil)
 
-	err = s.watcher.createRtpForwarder(s.ctx, roomID, activeRoom, "10.0.0.1", 5000)
+	err = s.watcher.createRtpForwarder(s.ctx, roomID, activeRoom, "10.0.0.1", 5000, nil)
 	s.Require().NoError(err)
 	s.Equal(int64(7890), activeRoom.StreamID)
 	s.Equal("10.0.0.1", activeRoom.FwIP)
@@ -440,10 +453,10 @@ func (s *RoomWatcherTestSuite) TestBusinessLogic_RecreateForwarder_OnEndpointCha
 
 	// Step 2: Create new forwarder with different endpoint
 	s.mockJanus.EXPECT().
-		CreateRTPForwarder(gomock.Any(), int64(123456), "10.0.0.2", 5001).
+		CreateRTPForwarder(gomock.Any(), int64(123456), "10.0.0.2", 5001, "", nil).
 		Return(int64(9999), nil)
 
-	err = s.watcher.createRtpForwarder(s.ctx, roomID, activeRoom, "10.0.0.2", 5001)
+	err = s.watcher.createRtpForwarder(s.ctx, roomID, activeRoom, "10.0.0.2", 5001, nil)
 	s.Require().NoError(err)
 	s.Equal(int64(9999), activeRoom.StreamID)
 	s.Equal("10.0.0.2", activeRoom.FwIP)
@@ -455,15 +468,9 @@ func (s *RoomWatcherTestSuite) TestBusinessLogic_RecreateForwarder_OnEndpointCha
 func (s *RoomWatcherTestSuite) TestStateLogic_ShouldHaveForwarder_AllConditionsMet() {
 	// shouldHaveForwarder = isAssignedToUs && mixer != nil && mixer.Port != 0
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{Pin: "1234", MaxAnchors: 5})
-	state.SetLiveMeta(&etcdstate.LiveMeta{
-		JanusID: "test-janus-01",
-		Status:  constants.RoomStatusOnAir,
-	})
-	state.SetMixer(&etcdstate.Mixer{
-		IP:   "10.0.0.1",
-		Port: 5000,
-	})
+	state.SetMeta(fixtures.Meta())
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("test-janus-01")))
+	state.SetMixer(fixtures.Mixer(fixtures.MixerAddr("10.0.0.1", 5000)))
 
 	// Verify conditions for shouldHaveForwarder
 	meta := state.GetMeta()
@@ -482,11 +489,8 @@ func (s *RoomWatcherTestSuite) TestStateLogic_ShouldHaveForwarder_AllConditionsM
 
 func (s *RoomWatcherTestSuite) TestStateLogic_ShouldNotHaveForwarder_MixerPortZero() {
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{Pin: "1234", MaxAnchors: 5})
-	state.SetLiveMeta(&etcdstate.LiveMeta{
-		JanusID: "test-janus-01",
-		Status:  constants.RoomStatusOnAir,
-	})
+	state.SetMeta(fixtures.Meta())
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("test-janus-01")))
 	state.SetMixer(&etcdstate.Mixer{
 		IP:   "10.0.0.1",
 		Port: 0, // Port is 0
@@ -506,17 +510,38 @@ func (s *RoomWatcherTestSuite) TestStateLogic_ShouldNotHaveForwarder_MixerPortZe
 	s.False(shouldHaveForwarder, "Should not have forwarder when port is 0")
 }
 
+func (s *RoomWatcherTestSuite) TestStateLogic_ShouldNotHaveForwarder_RoomEnding() {
+	state := &etcdstate.RoomState{}
+	state.SetMeta(&etcdstate.Meta{
+		Pin:    "1234",
+		Ending: &etcdstate.Ending{Stage: constants.EndStageStoppingForwarder},
+	})
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("test-janus-01")))
+	state.SetMixer(fixtures.Mixer(fixtures.MixerAddr("10.0.0.1", 5000)))
+
+	meta := state.GetMeta()
+	livemeta := state.GetLiveMeta()
+	mixer := state.GetMixer()
+
+	isAssignedToUs := meta != nil && livemeta != nil &&
+		livemeta.JanusID == s.watcher.janusID &&
+		livemeta.Status == constants.RoomStatusOnAir
+
+	shouldHaveForwarder := isAssignedToUs && mixer != nil && mixer.Port != 0 &&
+		!meta.GetEndStage().Reached(constants.EndStageStoppingForwarder)
+
+	s.True(isAssignedToUs, "Janus room is kept until the live is stopped")
+	s.False(shouldHaveForwarder, "Should not have forwarder once the room stops its forwarder")
+}
+
 func (s *RoomWatcherTestSuite) TestStateLogic_ShouldNotHaveForwarder_StatusNotOnAir() {
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{Pin: "1234", MaxAnchors: 5})
+	state.SetMeta(fixtures.Meta())
 	state.SetLiveMeta(&etcdstate.LiveMeta{
 		JanusID: "test-janus-01",
 		Status:  "idle", // Not on-air
 	})
-	state.SetMixer(&etcdstate.Mixer{
-		IP:   "10.0.0.1",
-		Port: 5000,
-	})
+	state.SetMixer(fixtures.Mixer(fixtures.MixerAddr("10.0.0.1", 5000)))
 
 	meta := state.GetMeta()
 	livemeta := state.GetLiveMeta()
@@ -534,15 +559,9 @@ func (s *RoomWatcherTestSuite) TestStateLogic_ShouldNotHaveForwarder_StatusNotOn
 
 func (s *RoomWatcherTestSuite) TestStateLogic_NotAssignedToUs_DifferentJanusID() {
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{Pin: "1234", MaxAnchors: 5})
-	state.SetLiveMeta(&etcdstate.LiveMeta{
-		JanusID: "other-janus",
-		Status:  constants.RoomStatusOnAir,
-	})
-	state.SetMixer(&etcdstate.Mixer{
-		IP:   "10.0.0.1",
-		Port: 5000,
-	})
+	state.SetMeta(fixtures.Meta())
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("other-janus")))
+	state.SetMixer(fixtures.Mixer(fixtures.MixerAddr("10.0.0.1", 5000)))
 
 	meta := state.GetMeta()
 	livemeta := state.GetLiveMeta()
@@ -561,10 +580,7 @@ func (s *RoomWatcherTestSuite) TestStateLogic_NotAssignedToUs_DifferentJanusID()
 func (s *RoomWatcherTestSuite) TestStateLogic_NoMetaData() {
 	state := &etcdstate.RoomState{}
 	// No meta set
-	state.SetLiveMeta(&etcdstate.LiveMeta{
-		JanusID: "test-janus-01",
-		Status:  constants.RoomStatusOnAir,
-	})
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("test-janus-01")))
 
 	meta := state.GetMeta()
 	livemeta := state.GetLiveMeta()
@@ -666,25 +682,25 @@ func (s *RoomWatcherTestSuite) TestBusinessLogic_RetryMechanism() {
 	roomID := "room-123"
 	pin := "1234"
 
-	// Simulate 3 collisions then success
+	// Simulate 3 collisions then success, each attempt probing the next derived ID
 	gomock.InOrder(
 		s.mockJanus.EXPECT().
-			CreateRoom(gomock.Any(), gomock.Any(), roomID, pin).
+			CreateRoom(gomock.Any(), janusRoomID(roomID, 1), roomID, pin, 0, nil).
 			Return(errors.New(janus.ErrAlreadyExisted, "exists")),
 		s.mockJanus.EXPECT().
-			CreateRoom(gomock.Any(), gomock.Any(), roomID, pin).
+			CreateRoom(gomock.Any(), janusRoomID(roomID, 2), roomID, pin, 0, nil).
 			Return(errors.New(janus.ErrAlreadyExisted, "exists")),
 		s.mockJanus.EXPECT().
-			CreateRoom(gomock.Any(), gomock.Any(), roomID, pin).
+			CreateRoom(gomock.Any(), janusRoomID(roomID, 3), roomID, pin, 0, nil).
 			Return(errors.New(janus.ErrAlreadyExisted, "exists")),
 		s.mockJanus.EXPECT().
-			CreateRoom(gomock.Any(), gomock.Any(), roomID, pin).
+			CreateRoom(gomock.Any(), janusRoomID(roomID, 4), roomID, pin, 0, nil).
 			Return(nil),
 	)
 
-	janusRoomID, err := s.watcher.createRoom(s.ctx, roomID, pin)
+	id, err := s.watcher.createRoom(s.ctx, roomID, pin, 0, nil)
 	s.Require().NoError(err)
-	s.NotZero(janusRoomID)
+	s.Equal(janusRoomID(roomID, 4), id)
 }
 
 func (s *RoomWatcherTestSuite) TestBusinessLogic_ErrorPropagation() {
@@ -693,11 +709,11 @@ func (s *RoomWatcherTestSuite) TestBusinessLogic_ErrorPropagation() {
 	pin := "1234"
 
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin).
+		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin, 0, nil).
 		Return(errors.New(janus.ErrFailedRequest, "network error")).
 		Times(1) // Only called once, not retried
 
-	_, err := s.watcher.createRoom(s.ctx, roomID, pin)
+	_, err := s.watcher.createRoom(s.ctx, roomID, pin, 0, nil)
 	s.Require().Error(err)
 	s.Contains(err.Error(), "network error")
 }
@@ -838,7 +854,7 @@ func (s *RoomWatcherTestSuite) TestRebuildEnd() {
 func (s *RoomWatcherTestSuite) TestRebuildState_NoActiveRoom() {
 	roomID := "room-123"
 	state := &etcdstate.RoomState{}
-	state.Mixer = &etcdstate.Mixer{IP: "10.0.0.1", Port: 5000}
+	state.Mixer = fixtures.Mixer(fixtures.MixerAddr("10.0.0.1", 5000))
 
 	// No active room in watcher
 	err := s.watcher.RebuildState(context.Background(), roomID, state)
@@ -859,7 +875,7 @@ func (s *RoomWatcherTestSuite) TestRebuildState_EndpointMatches() {
 
 	// State matches active room
 	state := &etcdstate.RoomState{}
-	state.Mixer = &etcdstate.Mixer{IP: "10.0.0.1", Port: 5000}
+	state.Mixer = fixtures.Mixer(fixtures.MixerAddr("10.0.0.1", 5000))
 
 	err := s.watcher.RebuildState(context.Background(), roomID, state)
 	s.Require().NoError(err)
@@ -870,6 +886,29 @@ func (s *RoomWatcherTestSuite) TestRebuildState_EndpointMatches() {
 	s.Equal(int64(7890), room.StreamID)
 }
 
+func (s *RoomWatcherTestSuite) TestRebuildState_AdoptedSRTPForwarder() {
+	roomID := "room-123"
+	srtp := &etcdstate.SRTP{Suite: etcdstate.SRTPSuiteHMAC80, Key: "key-1"}
+
+	// Janus does not list the key of SRTP forwarders
+	activeRoom := &ActiveRoom{
+		JanusRoomID: 123456,
+		StreamID:    7890,
+		FwIP:        "10.0.0.1",
+		FwPort:      5000,
+		FwSRTP:      srtpAdopted,
+	}
+	s.watcher.activeRooms.Store(roomID, activeRoom)
+
+	state := &etcdstate.RoomState{}
+	state.Mixer = &etcdstate.Mixer{IP: "10.0.0.1", Port: 5000, SRTP: srtp}
+
+	err := s.watcher.RebuildState(context.Background(), roomID, state)
+	s.Require().NoError(err)
+	s.Equal(int64(7890), activeRoom.StreamID)
+	s.Equal("key-1", activeRoom.FwSRTP)
+}
+
 func (s *RoomWatcherTestSuite) TestRebuildState_EndpointMismatch_StopsForwarder() {
 	roomID := "room-123"
 
@@ -884,7 +923,7 @@ func (s *RoomWatcherTestSuite) TestRebuildState_EndpointMismatch_StopsForwarder(
 
 	// State has different endpoint
 	state := &etcdstate.RoomState{}
-	state.Mixer = &etcdstate.Mixer{IP: "10.0.0.2", Port: 5001}
+	state.Mixer = fixtures.Mixer(fixtures.MixerAddr("10.0.0.2", 5001))
 
 	// Expect forwarder to be stopped
 	s.mockJanus.EXPECT().
@@ -942,7 +981,7 @@ func (s *RoomWatcherTestSuite) TestRebuildState_StopForwarderError() {
 	s.watcher.activeRooms.Store(roomID, activeRoom)
 
 	state := &etcdstate.RoomState{}
-	state.Mixer = &etcdstate.Mixer{IP: "10.0.0.2", Port: 5001}
+	state.Mixer = fixtures.Mixer(fixtures.MixerAddr("10.0.0.2", 5001))
 
 	// Stop forwarder fails but should not return error
 	s.mockJanus.EXPECT().
@@ -964,7 +1003,7 @@ func (s *RoomWatcherTestSuite) TestRebuildState_NoForwarder() {
 	s.watcher.activeRooms.Store(roomID, activeRoom)
 
 	state := &etcdstate.RoomState{}
-	state.Mixer = &etcdstate.Mixer{IP: "10.0.0.1", Port: 5000}
+	state.Mixer = fixtures.Mixer(fixtures.MixerAddr("10.0.0.1", 5000))
 
 	// No Janus API calls expected (no forwarder to stop)
 
@@ -980,7 +1019,7 @@ func (s *RoomWatcherTestSuite) TestProcessChange_NotAssignedToUs_NoAction() {
 
 	// State: NOT assigned to us
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{Pin: "1234", MaxAnchors: 5})
+	state.SetMeta(fixtures.Meta())
 	state.SetLiveMeta(&etcdstate.LiveMeta{
 		JanusID: "other-janus", // Different janus
 		Status:  constants.RoomStatusOnAir,
@@ -1000,7 +1039,7 @@ func (s *RoomWatcherTestSuite) TestProcessChange_NoMetaOrLiveMeta_NoAction() {
 
 	// State: missing livemeta
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{Pin: "1234", MaxAnchors: 5})
+	state.SetMeta(fixtures.Meta())
 	// No livemeta
 
 	err := s.watcher.processChange(context.Background(), roomID, state)
@@ -1030,23 +1069,17 @@ func (s *RoomWatcherTestSuite) TestProcessChange_Full_CreateRoomAndForwarder() {
 
 	// State: assigned to us with mixer
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{Pin: pin, MaxAnchors: 5})
-	state.SetLiveMeta(&etcdstate.LiveMeta{
-		JanusID: "test-janus-01",
-		Status:  constants.RoomStatusOnAir,
-	})
-	state.SetMixer(&etcdstate.Mixer{
-		IP:   "10.0.0.1",
-		Port: 5000,
-	})
+	state.SetMeta(fixtures.Meta(fixtures.MetaPin(pin)))
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("test-janus-01")))
+	state.SetMixer(fixtures.Mixer(fixtures.MixerAddr("10.0.0.1", 5000)))
 
 	// Expect room creation then forwarder creation
 	gomock.InOrder(
 		s.mockJanus.EXPECT().
-			CreateRoom(gomock.Any(), gomock.Any(), roomID, pin).
+			CreateRoom(gomock.Any(), gomock.Any(), roomID, pin, 0, nil).
 			Return(nil),
 		s.mockJanus.EXPECT().
-			CreateRTPForwarder(gomock.Any(), gomock.Any(), "10.0.0.1", 5000).
+			CreateRTPForwarder(gomock.Any(), gomock.Any(), "10.0.0.1", 5000, "", nil).
 			Return(int64(7890), nil),
 	)
 
@@ -1070,16 +1103,13 @@ func (s *RoomWatcherTestSuite) TestProcessChange_Full_CreateRoomOnly_NoMixer() {
 
 	// State: assigned to us but no mixer
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{Pin: pin, MaxAnchors: 5})
-	state.SetLiveMeta(&etcdstate.LiveMeta{
-		JanusID: "test-janus-01",
-		Status:  constants.RoomStatusOnAir,
-	})
+	state.SetMeta(fixtures.Meta(fixtures.MetaPin(pin)))
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("test-janus-01")))
 	// No mixer
 
 	// Expect only room creation
 	s.mockJanus.EXPECT().
-		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin).
+		CreateRoom(gomock.Any(), gomock.Any(), roomID, pin, 0, nil).
 		Return(nil)
 
 	err := w.processChange(context.Background(), roomID, state)
@@ -1106,11 +1136,8 @@ func (s *RoomWatcherTestSuite) TestProcessChange_Full_DestroyRoom() {
 
 	// State: no longer assigned to us
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{Pin: "1234", MaxAnchors: 5})
-	state.SetLiveMeta(&etcdstate.LiveMeta{
-		JanusID: "other-janus",
-		Status:  constants.RoomStatusOnAir,
-	})
+	state.SetMeta(fixtures.Meta())
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("other-janus")))
 
 	// Expect room destruction
 	s.mockJanus.EXPECT().
@@ -1138,19 +1165,13 @@ func (s *RoomWatcherTestSuite) TestProcessChange_Full_AddForwarder() {
 
 	// State: should have forwarder
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{Pin: "1234", MaxAnchors: 5})
-	state.SetLiveMeta(&etcdstate.LiveMeta{
-		JanusID: "test-janus-01",
-		Status:  constants.RoomStatusOnAir,
-	})
-	state.SetMixer(&etcdstate.Mixer{
-		IP:   "10.0.0.1",
-		Port: 5000,
-	})
+	state.SetMeta(fixtures.Meta())
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("test-janus-01")))
+	state.SetMixer(fixtures.Mixer(fixtures.MixerAddr("10.0.0.1", 5000)))
 
 	// Expect forwarder creation
 	s.mockJanus.EXPECT().
-		CreateRTPForwarder(gomock.Any(), int64(123456), "10.0.0.1", 5000).
+		CreateRTPForwarder(gomock.Any(), int64(123456), "10.0.0.1", 5000, "", nil).
 		Return(int64(7890), nil)
 
 	err := w.processChange(context.Background(), roomID, state)
@@ -1177,15 +1198,9 @@ func (s *RoomWatcherTestSuite) TestProcessChange_Full_RemoveForwarder() {
 
 	// State: should NOT have forwarder (port = 0)
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{Pin: "1234", MaxAnchors: 5})
-	state.SetLiveMeta(&etcdstate.LiveMeta{
-		JanusID: "test-janus-01",
-		Status:  constants.RoomStatusOnAir,
-	})
-	state.SetMixer(&etcdstate.Mixer{
-		IP:   "10.0.0.1",
-		Port: 0,
-	})
+	state.SetMeta(fixtures.Meta())
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("test-janus-01")))
+	state.SetMixer(fixtures.Mixer(fixtures.MixerAddr("10.0.0.1", 0)))
 
 	// Expect forwarder to be stopped
 	s.mockJanus.EXPECT().
@@ -1216,15 +1231,9 @@ func (s *RoomWatcherTestSuite) TestProcessChange_Full_RecreateForwarder() {
 
 	// State: forwarder needed at NEW endpoint
 	state := &etcdstate.RoomState{}
-	state.SetMeta(&etcdstate.Meta{Pin: "1234", MaxAnchors: 5})
-	state.SetLiveMeta(&etcdstate.LiveMeta{
-		JanusID: "test-janus-01",
-		Status:  constants.RoomStatusOnAir,
-	})
-	state.SetMixer(&etcdstate.Mixer{
-		IP:   "10.0.0.2",
-		Port: 5001,
-	})
+	state.SetMeta(fixtures.Meta())
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("test-janus-01")))
+	state.SetMixer(fixtures.Mixer(fixtures.MixerAddr("10.0.0.2", 5001)))
 
 	// Expect old forwarder stopped and new one created
 	gomock.InOrder(
@@ -1232,7 +1241,7 @@ func (s *RoomWatcherTestSuite) TestProcessChange_Full_RecreateForwarder() {
 			StopRTPForwarder(gomock.Any(), int64(123456), int64(7890)).
 			Return(nil),
 		s.mockJanus.EXPECT().
-			CreateRTPForwarder(gomock.Any(), int64(123456), "10.0.0.2", 5001).
+			CreateRTPForwarder(gomock.Any(), int64(123456), "10.0.0.2", 5001, "", nil).
 			Return(int64(9999), nil),
 	)
 
@@ -1246,3 +1255,54 @@ func (s *RoomWatcherTestSuite) TestProcessChange_Full_RecreateForwarder() {
 	s.Equal("10.0.0.2", room.FwIP)
 	s.Equal(5001, room.FwPort)
 }
+
+func (s *RoomWatcherTestSuite) TestProcessChange_Full_SRTPKeyChanged() {
+	w := s.createWatcherWithFakeEtcd()
+	roomID := "room-123"
+
+	activeRoom := &ActiveRoom{
+		JanusRoomID: 123456,
+		StreamID:    7890,
+		FwIP:        "10.0.0.1",
+		FwPort:      5000,
+		FwSRTP:      "old-key",
+	}
+	w.activeRooms.Store(roomID, activeRoom)
+
+	// the mixer restarted FFmpeg with a new key at the same endpoint
+	state := &etcdstate.RoomState{}
+	state.SetMeta(fixtures.Meta())
+	state.SetLiveMeta(fixtures.LiveMeta(fixtures.LiveJanus("test-janus-01")))
+	state.SetMixer(&etcdstate.Mixer{
+		IP:   "10.0.0.1",
+		Port: 5000,
+		SRTP: &etcdstate.SRTP{Suite: etcdstate.SRTPSuiteHMAC32, Key: "new-key"},
+	})
+
+	gomock.InOrder(
+		s.mockJanus.EXPECT().
+			StopRTPForwarder(gomock.Any(), int64(123456), int64(7890)).
+			Return(nil),
+		s.mockJanus.EXPECT().
+			CreateRTPForwarder(gomock.Any(), int64(123456), "10.0.0.1", 5000, "",
+				&janus.SRTP{Suite: 32, Crypto: "new-key"}).
+			Return(int64(9999), nil),
+	)
+
+	err := w.processChange(context.Background(), roomID, state)
+	s.Require().NoError(err)
+	s.Equal(int64(9999), activeRoom.StreamID)
+	s.Equal("new-key", activeRoom.FwSRTP)
+}
+
+func (s *RoomWatcherTestSuite) TestRoomIDOf() {
+	s.watcher.activeRooms.Store("room-1", &ActiveRoom{JanusRoomID: 100001})
+	s.watcher.activeRooms.Store("room-2", &ActiveRoom{JanusRoomID: 100002})
+
+	roomID, ok := s.watcher.RoomIDOf(100002)
+	s.True(ok)
+	s.Equal("room-2", roomID)
+
+	_, ok = s.watcher.RoomIDOf(100003)
+	s.False(ok)
+}
@@ -0,0 +1,134 @@
+package watcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/usage"
+)
+
+// packetOverhead is the bytes per second of the RTP, UDP and IPv4 headers of 20 ms Opus packets
+const packetOverhead = 50 * (12 + 8 + 20)
+
+// UsageRecorder accumulates the bytes of rooms, for billing
+type UsageRecorder interface {
+	Add(roomID string, kind usage.Kind, bytes int64)
+}
+
+// UsagePoller accounts the bytes forwarded by the RTP forwarders of the rooms hosted here.
+// AudioBridge does not count the bytes of its forwarders, they are estimated every interval from
+// the forwarders Janus lists for the room and the bitrate of the room.
+type UsagePoller struct {
+	roomWatcher *RoomWatcher
+	recorder    UsageRecorder
+	interval    time.Duration
+	// defaultBitrate is the bitrate of rooms without a bitrate cap, in bps
+	defaultBitrate int
+	cancel         context.CancelFunc
+	stopped        chan struct{}
+	logger         *log.Logger
+}
+
+// NewUsagePoller creates a new UsagePoller
+func NewUsagePoller(
+	roomWatcher *RoomWatcher,
+	recorder UsageRecorder,
+	interval time.Duration,
+	defaultBitrate int,
+	logger *log.Logger,
+) *UsagePoller {
+	return &UsagePoller{
+		roomWatcher:    roomWatcher,
+		recorder:       recorder,
+		interval:       interval,
+		defaultBitrate: defaultBitrate,
+		stopped:        make(chan struct{}),
+		logger:         logger,
+	}
+}
+
+// Start starts the poll loop
+func (p *UsagePoller) Start(ctx context.Context) error {
+	p.logger.Info("Starting usage poller", log.Duration("interval", p.interval))
+
+	ctx, p.cancel = context.WithCancel(ctx)
+	go p.loop(ctx)
+	return nil
+}
+
+// Stop stops the poll loop
+func (p *UsagePoller) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+		<-p.stopped
+	}
+	p.logger.Info("Stopped usage poller")
+}
+
+func (p *UsagePoller) loop(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer close(p.stopped)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.poll(ctx)
+		}
+	}
+}
+
+// usageTarget is a Janus room hosted here
+type usageTarget struct {
+	roomID      string
+	janusRoomID int64
+	bitrate     int
+}
+
+// usageTargets lists the Janus rooms hosted here with their bitrate cap, 0 when uncapped
+func (w *RoomWatcher) usageTargets() []usageTarget {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var targets []usageTarget
+	w.activeRooms.Range(func(key, val any) bool {
+		room := val.(*ActiveRoom)
+		if room.JanusRoomID == 0 {
+			return true
+		}
+		target := usageTarget{roomID: key.(string), janusRoomID: room.JanusRoomID}
+		if state, ok := w.GetCachedState(target.roomID); ok {
+			target.bitrate = state.GetMeta().GetMaxBitrate()
+		}
+		targets = append(targets, target)
+		return true
+	})
+	return targets
+}
+
+// poll accounts the forwarded bytes of every room over the last interval, room links forward
+// the room as well and are accounted to it
+func (p *UsagePoller) poll(ctx context.Context) {
+	for _, target := range p.roomWatcher.usageTargets() {
+		forwarders, err := p.roomWatcher.janusAdmin.ListRTPForwarders(ctx, target.janusRoomID)
+		if err != nil {
+			p.logger.Warn("Failed to list RTP forwarders",
+				log.String("roomId", target.roomID),
+				log.Int64("janusRoomId", target.janusRoomID),
+				log.Error(err))
+			continue
+		}
+		if len(forwarders) == 0 {
+			continue
+		}
+		bitrate := target.bitrate
+		if bitrate <= 0 {
+			bitrate = p.defaultBitrate
+		}
+		perSecond := float64(len(forwarders) * (bitrate/8 + packetOverhead))
+		p.recorder.Add(target.roomID, usage.KindForwarded, int64(perSecond*p.interval.Seconds()))
+	}
+}
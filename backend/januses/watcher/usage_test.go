@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	roomstatemocks "github.com/imtaco/audio-rtc-exp/internal/roomstate/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/usage"
+)
+
+// fakeUsage sums the accounted bytes per room and kind
+type fakeUsage map[string]map[usage.Kind]int64
+
+func (f fakeUsage) Add(roomID string, kind usage.Kind, bytes int64) {
+	if f[roomID] == nil {
+		f[roomID] = map[usage.Kind]int64{}
+	}
+	f[roomID][kind] += bytes
+}
+
+func (s *RoomWatcherTestSuite) TestUsagePoller_Poll() {
+	roomWatcher := roomstatemocks.NewMockWatcher(s.ctrl)
+	s.watcher.Watcher = roomWatcher
+	s.watcher.activeRooms.Store("room-1", &ActiveRoom{JanusRoomID: 100001, StreamID: 7})
+	s.watcher.activeRooms.Store("room-2", &ActiveRoom{JanusRoomID: 100002}) // not forwarding yet
+	s.watcher.activeRooms.Store("room-3", &ActiveRoom{JanusRoomID: 100003, StreamID: 9})
+	s.watcher.activeRooms.Store("room-4", &ActiveRoom{JanusRoomID: 100004, StreamID: 11})
+	roomWatcher.EXPECT().GetCachedState("room-1").Return(&etcdstate.RoomState{
+		Meta: &etcdstate.Meta{MaxBitrate: 32000},
+	}, true)
+	roomWatcher.EXPECT().GetCachedState(gomock.Any()).Return(nil, false).Times(3)
+
+	// room-1 is forwarded to its mixer and a linked room
+	s.mockJanus.EXPECT().ListRTPForwarders(gomock.Any(), int64(100001)).Return([]janus.RTPForwarderInfo{
+		{StreamID: 7, Host: "10.0.0.1", Port: 5004},
+		{StreamID: 8, Host: "10.0.0.2", Port: 5006},
+	}, nil)
+	s.mockJanus.EXPECT().ListRTPForwarders(gomock.Any(), int64(100002)).Return(nil, nil)
+	s.mockJanus.EXPECT().ListRTPForwarders(gomock.Any(), int64(100003)).Return([]janus.RTPForwarderInfo{
+		{StreamID: 9, Host: "10.0.0.1", Port: 5008},
+	}, nil)
+	s.mockJanus.EXPECT().ListRTPForwarders(gomock.Any(), int64(100004)).Return(nil, errors.New("timeout"))
+
+	recorder := fakeUsage{}
+	poller := NewUsagePoller(s.watcher, recorder, 10*time.Second, 64000, log.NewNop())
+	poller.poll(s.ctx)
+
+	s.Equal(fakeUsage{
+		// 2 forwarders * (4000 B/s of 32 kbps + 2000 B/s of headers) * 10 s
+		"room-1": {usage.KindForwarded: 120000},
+		// uncapped rooms are estimated at the default bitrate
+		"room-3": {usage.KindForwarded: 100000},
+	}, recorder)
+}
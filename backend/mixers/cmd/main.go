@@ -2,9 +2,7 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"net/http"
 	"time"
 
 	"github.com/spf13/viper"
@@ -18,6 +16,9 @@ import (
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	"github.com/imtaco/audio-rtc-exp/internal/network"
 	"github.com/imtaco/audio-rtc-exp/internal/otel"
+	"github.com/imtaco/audio-rtc-exp/internal/outbox"
+	"github.com/imtaco/audio-rtc-exp/internal/svcauth"
+	"github.com/imtaco/audio-rtc-exp/internal/tlsid"
 	"github.com/imtaco/audio-rtc-exp/internal/workflow"
 	"github.com/imtaco/audio-rtc-exp/mixers/ffmpeg"
 	"github.com/imtaco/audio-rtc-exp/mixers/transport"
@@ -25,22 +26,35 @@ import (
 )
 
 type Config struct {
-	App             config.App      `mapstructure:"app"`
-	Etcd            etcd.Config     `mapstructure:"etcd"`
-	HTTP            httputil.Config `mapstructure:"http"`
-	Otel            otel.Config     `mapstructure:"otel"`
-	MixerID         string          `mapstructure:"mixer_id"`
-	MixerIP         string          `mapstructure:"mixer_ip"`
-	MixerCapacity   int             `mapstructure:"mixer_capacity"`
-	RTPPortStart    int             `mapstructure:"rtp_port_start"`
-	RTPPortEnd      int             `mapstructure:"rtp_port_end"`
-	EtcdPrefixRooms string          `mapstructure:"etcd_prefix_rooms"`
-	EtcdPrefixMixer string          `mapstructure:"etcd_prefix_mixer"`
-	KeyBaseURL      string          `mapstructure:"key_base_url"`
-	HLSDir          string          `mapstructure:"hls_dir"`
-	TempDir         string          `mapstructure:"temp_dir"`
-	SDPDir          string          `mapstructure:"sdp_dir"`
-	LeaseTTL        time.Duration   `mapstructure:"lease_ttl"`
+	App                   config.App            `mapstructure:"app"`
+	Etcd                  etcd.Config           `mapstructure:"etcd"`
+	HTTP                  httputil.Config       `mapstructure:"http"`
+	MTLS                  tlsid.Config          `mapstructure:"mtls"`
+	Otel                  otel.Config           `mapstructure:"otel"`
+	MixerID               string                `mapstructure:"mixer_id"`
+	MixerIP               string                `mapstructure:"mixer_ip"`
+	MixerCapacity         int                   `mapstructure:"mixer_capacity"`
+	RTPPortStart          int                   `mapstructure:"rtp_port_start"`
+	RTPPortEnd            int                   `mapstructure:"rtp_port_end"`
+	MarkerPort            int                   `mapstructure:"marker_port"`
+	SpeakerMetadata       bool                  `mapstructure:"speaker_metadata"`
+	SRTPSuite             string                `mapstructure:"srtp_suite"`
+	EtcdPrefixRooms       string                `mapstructure:"etcd_prefix_rooms"`
+	EtcdPrefixMixer       string                `mapstructure:"etcd_prefix_mixer"`
+	EtcdKeyHLSDefaults    string                `mapstructure:"etcd_key_hls_defaults"`
+	EtcdPrefixOutbox      string                `mapstructure:"etcd_prefix_outbox"`
+	KeyBaseURL            string                `mapstructure:"key_base_url"`
+	HLSDir                string                `mapstructure:"hls_dir"`
+	TempDir               string                `mapstructure:"temp_dir"`
+	SDPDir                string                `mapstructure:"sdp_dir"`
+	LeaseTTL              time.Duration         `mapstructure:"lease_ttl"`
+	LatencyReportInterval time.Duration         `mapstructure:"latency_report_interval"`
+	SegmentCheckInterval  time.Duration         `mapstructure:"segment_check_interval"`
+	SegmentStallTimeout   time.Duration         `mapstructure:"segment_stall_timeout"`
+	Budget                watcher.BudgetConfig  `mapstructure:"budget"`
+	Debug                 transport.DebugConfig `mapstructure:"debug"`
+	FFmpegLogs            ffmpeg.LogCapture     `mapstructure:"ffmpeg_logs"`
+	AdminAuth             svcauth.Config        `mapstructure:"admin_auth"`
 }
 
 func loadConfig() (*Config, error) {
@@ -50,18 +64,32 @@ func loadConfig() (*Config, error) {
 		v.SetDefault("mixer_capacity", 10)
 		v.SetDefault("rtp_port_start", 10000)
 		v.SetDefault("rtp_port_end", 20000)
+		v.SetDefault("marker_port", 3002)
+		v.SetDefault("speaker_metadata", false)
+		v.SetDefault("srtp_suite", "") // empty forwards plaintext RTP
 		v.SetDefault("etcd_prefix_rooms", "/rooms/")
 		v.SetDefault("etcd_prefix_mixer", "/mixers/")
+		v.SetDefault("etcd_key_hls_defaults", "/config/mixers/hls")
+		v.SetDefault("etcd_prefix_outbox", "") // empty disables roomHlsLive events
 		v.SetDefault("key_base_url", "http://localhost:3101/hls/rooms/")
 		v.SetDefault("hls_dir", "/hls")
 		v.SetDefault("temp_dir", "/tmp")
 		v.SetDefault("sdp_dir", "/tmp/sdp")
 		v.SetDefault("lease_ttl", 10*time.Second)
+		v.SetDefault("latency_report_interval", 10*time.Second)
+		v.SetDefault("segment_check_interval", 5*time.Second)
+		v.SetDefault("segment_stall_timeout", 30*time.Second) // 0 disables the watchdog
 
 		config.Setup(v, "app")
 		etcd.Setup(v, "etcd")
 		httputil.Setup(v, "http")
+		tlsid.Setup(v, "mtls")
+		v.SetDefault("mtls.service", "mixers")
 		otel.Setup(v, "otel")
+		watcher.SetupBudget(v, "budget")
+		transport.SetupDebug(v, "debug")
+		ffmpeg.SetupLogCapture(v, "ffmpeg_logs")
+		svcauth.Setup(v, "admin_auth")
 
 		// override default http.addr
 		v.SetDefault("http.addr", "0.0.0.0:3001")
@@ -85,6 +113,10 @@ func main() {
 		logger.Info("Mixer IP not set, detecting automatically", log.String("ip", config.MixerIP))
 	}
 
+	if config.SRTPSuite != "" && etcdstate.SRTPTagLength(config.SRTPSuite) == 0 {
+		logger.Fatal("Unsupported SRTP suite", log.String("suite", config.SRTPSuite))
+	}
+
 	logger.Info("Starting Mixer service",
 		log.String("mixerId", config.MixerID),
 		log.String("mixerIp", config.MixerIP),
@@ -101,7 +133,14 @@ func main() {
 	if err != nil {
 		logger.Fatal("Failed to create etcd client", log.Error(err))
 	}
-	defer etcdClient.Close()
+	if config.App.LogLevelsKey != "" {
+		go etcd.WatchLogLevels(ctx, etcdClient, config.App.LogLevelsKey, logger.Levels(), logger.Module("LogLevels"))
+	}
+
+	identity, err := tlsid.New(ctx, &config.MTLS, etcdClient, logger.Module("TLSID"))
+	if err != nil {
+		logger.Fatal("Failed to load TLS identity", log.Error(err))
+	}
 
 	// Create components
 	encGenerator := ffmpeg.NewEncryptionGenerator(config.KeyBaseURL, config.TempDir)
@@ -112,8 +151,27 @@ func main() {
 		sdpGenerator,
 		1*time.Second, // retry delay
 		5*time.Second, // force kill delay
+		&config.FFmpegLogs,
 		logger.Module("FFmpegMgr"),
 	)
+	// rooms needing what the binary lacks are refused, the scheduler sees the features in the heartbeat
+	caps, err := ffmpeg.ProbeCapabilities(ctx)
+	if err != nil {
+		logger.Error("Failed to probe FFmpeg capabilities, assuming full support", log.Error(err))
+	} else {
+		logger.Info("Probed FFmpeg capabilities",
+			log.String("version", caps.Version),
+			log.String("audioEncoder", caps.AudioEncoder),
+			log.Strings("features", caps.Features))
+		ffmpegManager.SetCapabilities(caps)
+	}
+
+	hlsDefaultsWatcher := watcher.NewHLSDefaultsWatcher(
+		etcdClient,
+		config.EtcdKeyHLSDefaults,
+		ffmpegManager,
+		logger.Module("HLSDefaults"),
+	)
 
 	// Create room watcher
 	portManager := watcher.NewPortManager(
@@ -125,12 +183,57 @@ func main() {
 		etcdClient,
 		config.MixerID,
 		config.MixerIP,
+		config.MarkerPort,
+		config.SRTPSuite,
 		portManager,
 		ffmpegManager,
 		config.EtcdPrefixRooms,
 		config.EtcdPrefixMixer,
 		logger.Module("RoomWatcher"),
 	)
+	// FFmpeg respawns go to the room timeline
+	ffmpegManager.OnRespawn(roomWatcher.FFmpegRespawned)
+
+	// 0 disables latency markers, latency is estimated then
+	var markerListener *watcher.MarkerListener
+	if config.MarkerPort > 0 {
+		markerListener = watcher.NewMarkerListener(
+			config.MarkerPort,
+			ffmpegManager,
+			logger.Module("MarkerListener"),
+		)
+		// active speakers arrive on the marker port
+		if config.SpeakerMetadata {
+			ffmpegManager.EnableSpeakerMetadata()
+		}
+	} else if config.SpeakerMetadata {
+		logger.Warn("Speaker metadata needs the marker port, disabled")
+	}
+
+	latencyReporter := watcher.NewLatencyReporter(
+		roomWatcher,
+		config.LatencyReportInterval,
+		logger.Module("LatencyReporter"),
+	)
+
+	// HLS live events are only committed here, the rooms service relays its outbox
+	var hlsLiveEvents outbox.Writer
+	if config.EtcdPrefixOutbox != "" {
+		hlsLiveEvents = outbox.New(etcdClient, config.EtcdPrefixOutbox, nil, logger.Module("Outbox"))
+	}
+
+	// the watchdog also runs without stall timeout to commit HLS live events
+	var freshnessWatchdog *watcher.FreshnessWatchdog
+	if config.SegmentStallTimeout > 0 || hlsLiveEvents != nil {
+		freshnessWatchdog = watcher.NewFreshnessWatchdog(
+			roomWatcher,
+			config.HLSDir,
+			config.SegmentCheckInterval,
+			config.SegmentStallTimeout,
+			hlsLiveEvents,
+			logger.Module("FreshnessWatchdog"),
+		)
+	}
 
 	// Create heartbeat
 	hbKey := fmt.Sprintf("%s%s/heartbeat", config.EtcdPrefixMixer, config.MixerID)
@@ -140,6 +243,9 @@ func main() {
 		Capacity:  config.MixerCapacity,
 		StartedAt: time.Now().UTC(),
 	}
+	if caps != nil {
+		hbData.Capabilities = caps.Features
+	}
 	heartbeat := etcdheartbeat.New(
 		etcdClient,
 		hbKey,
@@ -148,46 +254,101 @@ func main() {
 		logger.Module("Heartbeat"),
 	)
 
-	// initCtx := context.Background()
-	// TODO: init with timeout ?!
-	if err := roomWatcher.Start(ctx); err != nil {
-		logger.Fatal("Failed to start room watcher", log.Error(err))
+	// rooms over the CPU budget are refused, the mixer reports itself saturated meanwhile so
+	// placement stops picking it even below its capacity
+	roomWatcher.SetBudget(watcher.NewCPUBudget(&config.Budget, config.MixerID, func(saturated bool) {
+		data := hbData
+		if saturated {
+			data.Status = constants.ModuleStatusSaturated
+		}
+		if err := heartbeat.Update(ctx, data); err != nil {
+			logger.Error("Failed to report CPU budget saturation", log.Error(err))
+		}
+	}, logger.Module("Budget")))
+
+	lc := workflow.NewLifecycle(logger.Module("Lifecycle"))
+	lc.Add(workflow.Component{Name: "otel", Stop: otelShutdown})
+	lc.Add(workflow.Component{
+		Name: "etcd",
+		Stop: workflow.Closer(etcdClient.Close),
+	})
+	if identity != nil {
+		lc.Add(workflow.Component{
+			Name:  "tlsid",
+			Start: identity.Start,
+			Stop:  workflow.Stopper(identity.Stop),
+		})
+	}
+	lc.Add(workflow.Component{
+		Name: "ffmpeg",
+		Stop: workflow.Closer(ffmpegManager.Stop),
+	})
+	// defaults are loaded before rooms start
+	lc.Add(workflow.Component{
+		Name:      "hlsDefaults",
+		DependsOn: []string{"etcd", "ffmpeg"},
+		Start:     hlsDefaultsWatcher.Start,
+		Stop:      workflow.Stopper(hlsDefaultsWatcher.Stop),
+	})
+	if markerListener != nil {
+		lc.Add(workflow.Component{
+			Name:      "markerListener",
+			DependsOn: []string{"ffmpeg"},
+			Start:     markerListener.Start,
+			Stop:      workflow.Stopper(markerListener.Stop),
+		})
 	}
-	if err := heartbeat.Start(ctx); err != nil {
-		logger.Fatal("Failed to start heartbeat", log.Error(err))
+	lc.Add(workflow.Component{
+		Name:      "roomWatcher",
+		DependsOn: []string{"etcd", "ffmpeg", "hlsDefaults"},
+		Start:     roomWatcher.Start,
+		Stop:      workflow.Closer(roomWatcher.Stop),
+	})
+	lc.Add(workflow.Component{
+		Name:      "latencyReporter",
+		DependsOn: []string{"roomWatcher"},
+		Start:     latencyReporter.Start,
+		Stop:      workflow.Stopper(latencyReporter.Stop),
+	})
+	if freshnessWatchdog != nil {
+		lc.Add(workflow.Component{
+			Name:      "freshnessWatchdog",
+			DependsOn: []string{"roomWatcher"},
+			Start:     freshnessWatchdog.Start,
+			Stop:      workflow.Stopper(freshnessWatchdog.Stop),
+		})
 	}
+	lc.Add(workflow.Component{
+		Name:      "heartbeat",
+		DependsOn: []string{"etcd", "roomWatcher"},
+		Start:     heartbeat.Start,
+		Stop:      heartbeat.Stop,
+	})
 
 	// Setup Gin router
-	router := transport.NewRouter(config.MixerID, logger.Module("Router"))
+	if !config.AdminAuth.Enabled {
+		logger.Warn("Admin auth disabled, the mixer routes are open to anyone reaching them")
+	}
+	router := transport.NewRouter(
+		config.MixerID,
+		ffmpegManager,
+		&config.Debug,
+		svcauth.NewAuthenticator(&config.AdminAuth, logger.Module("Audit")),
+		logger.Module("Router"),
+	)
 	server := httputil.NewServer(&config.HTTP, router.Handler())
+	server.SetIdentity(identity)
+	lc.Add(server.Component("http", logger, "heartbeat"))
 
-	go func() {
-		logger.Info("Starting HTTP server", log.String("addr", config.HTTP.Addr))
-		if err := server.Listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			logger.Fatal("Failed to start HTTP server", log.Error(err))
-		}
-	}()
-	logger.Info("Mixer started")
-
-	// Setup graceful shutdown
-	cleanup := func(ctx context.Context) {
-		_ = server.Shutdown(ctx)
+	if adminServer := httputil.NewAdminServer(config.App.AdminAddr, "mixer-service", logger); adminServer != nil {
+		lc.Add(adminServer.Component("admin", logger))
+	}
 
-		if err := heartbeat.Stop(ctx); err != nil {
-			logger.Error("Error cleaning up heartbeat", log.Error(err))
-		}
-		if err := roomWatcher.Stop(); err != nil {
-			logger.Error("Error cleaning up room watcher", log.Error(err))
-		}
-		if err := ffmpegManager.Stop(); err != nil {
-			logger.Error("Error cleaning up FFmpeg manager", log.Error(err))
-		}
-		if err := etcdClient.Close(); err != nil {
-			logger.Error("Failed to close etcd client", log.Error(err))
-		}
-		if err := otelShutdown(ctx); err != nil {
-			logger.Error("Failed to shutdown OTEL", log.Error(err))
-		}
+	// TODO: init with timeout ?!
+	if err := lc.Start(ctx); err != nil {
+		logger.Fatal("Failed to start mixer", log.Error(err))
 	}
-	workflow.WaitGracefulShutdown(ctx, logger.Module("CleanUp"), cleanup, config.App.ShutdownTimeout)
+	logger.Info("Mixer started")
+
+	lc.WaitGracefulShutdown(ctx, config.App.ShutdownTimeout)
 }
@@ -0,0 +1,137 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/mixers"
+)
+
+const probeTimeout = 10 * time.Second
+
+// audioEncoders are the AAC encoders in order of preference, builds for some architectures
+// only ship libfdk_aac
+var audioEncoders = []string{"aac", "libfdk_aac"}
+
+// runFFmpeg runs FFmpeg with args and returns its output (can be replaced for testing)
+var runFFmpeg = func(ctx context.Context, args ...string) ([]byte, error) {
+	// #nosec G204 -- fixed listing flags
+	return exec.CommandContext(ctx, "ffmpeg", args...).Output()
+}
+
+// ffmpegListing is what FFmpeg lists of a kind, e.g. encoders
+type ffmpegListing map[string]struct{}
+
+func (l ffmpegListing) has(names ...string) bool {
+	for _, name := range names {
+		if _, ok := l[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ProbeCapabilities lists the encoders, formats, protocols and filters of the FFmpeg binary and
+// returns the features rooms need it supports
+func ProbeCapabilities(ctx context.Context) (*mixers.Capabilities, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	version, err := runFFmpeg(ctx, "-hide_banner", "-version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ffmpeg: %w", err)
+	}
+	listings := make(map[string]ffmpegListing, 5)
+	for _, kind := range []string{"encoders", "muxers", "demuxers", "filters"} {
+		out, err := runFFmpeg(ctx, "-hide_banner", "-"+kind)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ffmpeg %s: %w", kind, err)
+		}
+		listings[kind] = parseListing(out)
+	}
+	out, err := runFFmpeg(ctx, "-hide_banner", "-protocols")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ffmpeg protocols: %w", err)
+	}
+	inputs, outputs := parseProtocols(out)
+
+	caps := &mixers.Capabilities{Version: firstLine(version)}
+	for _, encoder := range audioEncoders {
+		if listings["encoders"].has(encoder) {
+			caps.AudioEncoder = encoder
+			caps.Features = append(caps.Features, mixers.FeatureAAC)
+			break
+		}
+	}
+	features := []struct {
+		name string
+		ok   bool
+	}{
+		{mixers.FeatureHLS, listings["muxers"].has("hls")},
+		{mixers.FeatureHLSEncryption, outputs.has("crypto")},
+		{mixers.FeatureRTP, listings["demuxers"].has("sdp") && inputs.has("file", "udp", "rtp")},
+		{mixers.FeatureSRTP, inputs.has("srtp")},
+		{mixers.FeatureLink, listings["filters"].has("amix")},
+		{mixers.FeatureSpeakerMetadata, listings["demuxers"].has("mpegts") && inputs.has("pipe")},
+		{mixers.FeatureTestSource, listings["demuxers"].has("lavfi") && listings["filters"].has("sine")},
+	}
+	for _, f := range features {
+		if f.ok {
+			caps.Features = append(caps.Features, f.name)
+		}
+	}
+	return caps, nil
+}
+
+// parseListing parses the names listed by -encoders, -muxers, -demuxers or -filters, one per
+// line after the legend as "<flags> <name>[,<alias>...] <description>"
+func parseListing(out []byte) ffmpegListing {
+	listing := make(ffmpegListing)
+	legend := true
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if legend {
+			// the legend ends with a dashed line, " --" or "------"
+			legend = !strings.HasPrefix(line, "--")
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for name := range strings.SplitSeq(fields[1], ",") {
+			listing[name] = struct{}{}
+		}
+	}
+	return listing
+}
+
+// parseProtocols parses the input and output protocols listed by -protocols
+func parseProtocols(out []byte) (inputs, outputs ffmpegListing) {
+	inputs, outputs = make(ffmpegListing), make(ffmpegListing)
+	var current ffmpegListing
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "Input:":
+			current = inputs
+		case line == "Output:":
+			current = outputs
+		case line != "" && current != nil:
+			current[line] = struct{}{}
+		}
+	}
+	return inputs, outputs
+}
+
+func firstLine(out []byte) string {
+	line, _, _ := bytes.Cut(out, []byte("\n"))
+	return strings.TrimSpace(string(line))
+}
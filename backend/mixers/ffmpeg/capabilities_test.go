@@ -0,0 +1,152 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/mixers"
+)
+
+const (
+	probeVersion  = "ffmpeg version 6.1.1 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc 13\n"
+	probeEncoders = `Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)
+ A....D aac                  AAC (Advanced Audio Coding)
+ A....D libopus              libopus Opus (codec opus)
+`
+	probeMuxers = `File formats:
+ D. = Demuxing supported
+ .E = Muxing supported
+ --
+  E hls             Apple HTTP Live Streaming
+  E mpegts          MPEG-TS (MPEG-2 Transport Stream)
+`
+	probeDemuxers = `File formats:
+ D. = Demuxing supported
+ .E = Muxing supported
+ --
+ D  lavfi           Libavfilter virtual input device
+ D  mov,mp4,m4a,3gp,3g2,mj2 QuickTime / MOV
+ D  mpegts          MPEG-TS (MPEG-2 Transport Stream)
+ D  sdp             SDP
+`
+	probeFilters = `Filters:
+  T.. = Timeline support
+  ... = Neither
+ ------
+ ... amix              N->A       Audio mixing.
+ ... sine              |->A       Generate sine wave audio signal.
+`
+	probeProtocols = `Supported file protocols:
+Input:
+  crypto
+  file
+  pipe
+  rtp
+  srtp
+  udp
+Output:
+  crypto
+  file
+  pipe
+`
+)
+
+// fakeFFmpeg answers probe runs with outputs, by listing flag
+func fakeFFmpeg(t *testing.T, outputs map[string]string) {
+	orig := runFFmpeg
+	t.Cleanup(func() { runFFmpeg = orig })
+	runFFmpeg = func(_ context.Context, args ...string) ([]byte, error) {
+		out, ok := outputs[args[len(args)-1]]
+		if !ok {
+			return nil, errors.New("exit status 1")
+		}
+		return []byte(out), nil
+	}
+}
+
+func probeOutputs() map[string]string {
+	return map[string]string{
+		"-version":   probeVersion,
+		"-encoders":  probeEncoders,
+		"-muxers":    probeMuxers,
+		"-demuxers":  probeDemuxers,
+		"-filters":   probeFilters,
+		"-protocols": probeProtocols,
+	}
+}
+
+func TestProbeCapabilities(t *testing.T) {
+	t.Run("full build", func(t *testing.T) {
+		fakeFFmpeg(t, probeOutputs())
+
+		caps, err := ProbeCapabilities(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "ffmpeg version 6.1.1 Copyright (c) 2000-2023 the FFmpeg developers", caps.Version)
+		assert.Equal(t, "aac", caps.AudioEncoder)
+		assert.Equal(t, []string{
+			mixers.FeatureAAC,
+			mixers.FeatureHLS,
+			mixers.FeatureHLSEncryption,
+			mixers.FeatureRTP,
+			mixers.FeatureSRTP,
+			mixers.FeatureLink,
+			mixers.FeatureSpeakerMetadata,
+			mixers.FeatureTestSource,
+		}, caps.Features)
+	})
+
+	t.Run("falls back to libfdk_aac", func(t *testing.T) {
+		outputs := probeOutputs()
+		outputs["-encoders"] = strings.Replace(probeEncoders, " aac ", " libfdk_aac ", 1)
+		fakeFFmpeg(t, outputs)
+
+		caps, err := ProbeCapabilities(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "libfdk_aac", caps.AudioEncoder)
+		assert.True(t, caps.Has(mixers.FeatureAAC))
+	})
+
+	t.Run("minimal build", func(t *testing.T) {
+		outputs := probeOutputs()
+		outputs["-encoders"] = strings.Replace(probeEncoders, " aac ", " mp2 ", 1)
+		outputs["-filters"] = "Filters:\n ------\n"
+		outputs["-protocols"] = "Input:\n  file\n  rtp\n  udp\nOutput:\n  file\n"
+		fakeFFmpeg(t, outputs)
+
+		caps, err := ProbeCapabilities(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, caps.AudioEncoder)
+		assert.Equal(t, []string{mixers.FeatureHLS, mixers.FeatureRTP}, caps.Features)
+		assert.Equal(t, []string{mixers.FeatureAAC, mixers.FeatureSRTP}, caps.Missing(mixers.FeatureAAC, mixers.FeatureRTP, mixers.FeatureSRTP))
+	})
+
+	t.Run("ffmpeg missing", func(t *testing.T) {
+		fakeFFmpeg(t, nil)
+
+		_, err := ProbeCapabilities(context.Background())
+		require.Error(t, err)
+	})
+}
+
+func TestParseListing(t *testing.T) {
+	listing := parseListing([]byte(probeDemuxers))
+
+	// aliases are listed on their own and legend lines are skipped
+	assert.True(t, listing.has("mov", "mp4", "sdp"))
+	assert.False(t, listing.has("D."))
+}
+
+func TestCapabilitiesNotProbed(t *testing.T) {
+	var caps *mixers.Capabilities
+	assert.True(t, caps.Has(mixers.FeatureSRTP))
+	assert.Empty(t, caps.Missing(mixers.FeatureAAC))
+}
@@ -26,9 +26,10 @@ func NewEncryptionGenerator(keyBaseURL, tmpDir string) *EncryptionGenerator {
 	}
 }
 
-// Generate creates encryption key and keyinfo files for FFmpeg
+// Generate creates encryption key and keyinfo files for FFmpeg, keyBaseURL overrides the
+// generator's base URL of the key URI when not empty
 // Note: nonce should not change for a given room to ensure consistent key generation
-func (eg *EncryptionGenerator) Generate(roomID, nonce, _ string) (string, error) {
+func (eg *EncryptionGenerator) Generate(roomID, nonce, keyBaseURL string) (string, error) {
 	keyPath := filepath.Join(eg.tmpDir, "enc.key")
 	keyInfoPath := filepath.Join(eg.tmpDir, fmt.Sprintf("enc-%s.keyinfo", roomID))
 
@@ -45,9 +46,12 @@ func (eg *EncryptionGenerator) Generate(roomID, nonce, _ string) (string, error)
 	}
 
 	// Construct key URI
+	if keyBaseURL == "" {
+		keyBaseURL = eg.keyBaseURL
+	}
 	keyURI := "enc.key"
-	if eg.keyBaseURL != "" {
-		keyURI = fmt.Sprintf("%s%s/enc.key", eg.keyBaseURL, roomID)
+	if keyBaseURL != "" {
+		keyURI = fmt.Sprintf("%s%s/enc.key", keyBaseURL, roomID)
 	}
 
 	// Create keyinfo file for FFmpeg
@@ -27,16 +27,12 @@ func TestEncryptionGenerate(t *testing.T) {
 	assert.NoError(t, err)
 	defer os.RemoveAll(tmpDir)
 
-	hlsDir := filepath.Join(tmpDir, "hls", "room1")
-	err = os.MkdirAll(hlsDir, 0755)
-	assert.NoError(t, err)
-
 	t.Run("generate encryption key and keyinfo", func(t *testing.T) {
 		eg := NewEncryptionGenerator("https://example.com/keys/", tmpDir)
 		roomID := "room1"
 		nonce := "testnonce123"
 
-		keyInfoPath, err := eg.Generate(roomID, nonce, hlsDir)
+		keyInfoPath, err := eg.Generate(roomID, nonce, "")
 
 		assert.NoError(t, err)
 		assert.NotEmpty(t, keyInfoPath)
@@ -60,7 +56,7 @@ func TestEncryptionGenerate(t *testing.T) {
 		roomID := "room2"
 		nonce := "nonce456"
 
-		keyInfoPath, err := eg.Generate(roomID, nonce, hlsDir)
+		keyInfoPath, err := eg.Generate(roomID, nonce, "")
 
 		assert.NoError(t, err)
 
@@ -71,12 +67,25 @@ func TestEncryptionGenerate(t *testing.T) {
 		assert.Equal(t, "enc.key", lines[0])
 	})
 
+	t.Run("keyBaseURL overrides generator base URL", func(t *testing.T) {
+		eg := NewEncryptionGenerator("https://example.com/keys/", tmpDir)
+
+		keyInfoPath, err := eg.Generate("room5", "nonce", "https://cdn.example.com/keys/")
+		assert.NoError(t, err)
+
+		keyInfo, err := os.ReadFile(keyInfoPath)
+		assert.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(string(keyInfo)), "\n")
+		assert.Equal(t, "https://cdn.example.com/keys/room5/enc.key", lines[0])
+	})
+
 	t.Run("generate creates key file", func(t *testing.T) {
 		eg := NewEncryptionGenerator("https://example.com/keys/", tmpDir)
 		roomID := "room3"
 		nonce := "nonce789"
 
-		_, err := eg.Generate(roomID, nonce, hlsDir)
+		_, err := eg.Generate(roomID, nonce, "")
 
 		assert.NoError(t, err)
 
@@ -91,14 +100,14 @@ func TestEncryptionGenerate(t *testing.T) {
 		roomID := "room4"
 		nonce := "consistentnonce"
 
-		_, err := eg.Generate(roomID, nonce, hlsDir)
+		_, err := eg.Generate(roomID, nonce, "")
 		assert.NoError(t, err)
 
 		keyPath := filepath.Join(tmpDir, "enc.key")
 		key1, err := os.ReadFile(keyPath)
 		assert.NoError(t, err)
 
-		_, err = eg.Generate(roomID, nonce, hlsDir)
+		_, err = eg.Generate(roomID, nonce, "")
 		assert.NoError(t, err)
 
 		key2, err := os.ReadFile(keyPath)
@@ -113,15 +122,11 @@ func TestEncryptionDelete(t *testing.T) {
 	assert.NoError(t, err)
 	defer os.RemoveAll(tmpDir)
 
-	hlsDir := filepath.Join(tmpDir, "hls", "room1")
-	err = os.MkdirAll(hlsDir, 0755)
-	assert.NoError(t, err)
-
 	t.Run("delete existing keyinfo file", func(t *testing.T) {
 		eg := NewEncryptionGenerator("https://example.com/keys/", tmpDir)
 		roomID := "room1"
 
-		_, err := eg.Generate(roomID, "nonce", hlsDir)
+		_, err := eg.Generate(roomID, "nonce", "")
 		assert.NoError(t, err)
 
 		keyInfoPath := filepath.Join(tmpDir, "enc-room1.keyinfo")
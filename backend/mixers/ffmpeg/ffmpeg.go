@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel"
@@ -14,6 +15,8 @@ import (
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/imtaco/audio-rtc-exp/internal/backoff"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	"github.com/imtaco/audio-rtc-exp/mixers"
 )
@@ -26,6 +29,11 @@ type ffmpegMgrImpl struct {
 	retryDelay       time.Duration
 	forceKillTimeout time.Duration
 	processes        sync.Map // map[string]*ProcessInfo
+	hlsDefaults      atomic.Pointer[etcdstate.HLSParams]
+	speakerMetadata  atomic.Bool
+	onRespawn        func(roomID, reason string)
+	caps             *mixers.Capabilities // nil when not probed
+	logCapture       *LogCapture
 	logger           *log.Logger
 	tracer           trace.Tracer
 }
@@ -36,6 +44,7 @@ func NewFFmpegManager(
 	encGen *EncryptionGenerator,
 	sdpGen *SDPGenerator,
 	retryDelay, forceKillTimeout time.Duration,
+	logCapture *LogCapture,
 	logger *log.Logger,
 ) mixers.FFmpegManager {
 	if retryDelay == 0 {
@@ -55,18 +64,62 @@ func NewFFmpegManager(
 		sdpGen:           sdpGen,
 		retryDelay:       retryDelay,
 		forceKillTimeout: forceKillTimeout,
+		logCapture:       logCapture,
 		logger:           logger,
 		tracer:           otel.Tracer("mixer.ffmpeg"),
 	}
 }
 
-// StartFFmpeg starts an FFmpeg process for a room
-func (fm *ffmpegMgrImpl) StartFFmpeg(roomID string, rtpPort int, createdAt time.Time, nonce string) error {
+// OnRespawn sets the handler called whenever FFmpeg of a room is respawned, with
+// mixers.RespawnRequested or mixers.RespawnExited as reason. It must be set before rooms are started
+func (fm *ffmpegMgrImpl) OnRespawn(handler func(roomID, reason string)) {
+	fm.onRespawn = handler
+}
+
+// SetHLSDefaults replaces the HLS defaults, running processes keep the parameters they started with
+func (fm *ffmpegMgrImpl) SetHLSDefaults(params *etcdstate.HLSParams) {
+	fm.hlsDefaults.Store(params)
+	fm.logger.Info("Updated HLS defaults", log.Any("params", params))
+}
+
+// SetCapabilities sets what the FFmpeg binary supports, it must be set before rooms are started
+func (fm *ffmpegMgrImpl) SetCapabilities(caps *mixers.Capabilities) {
+	fm.caps = caps
+}
+
+// Capabilities returns what the FFmpeg binary supports, nil when not probed
+func (fm *ffmpegMgrImpl) Capabilities() *mixers.Capabilities {
+	return fm.caps
+}
+
+// EnableSpeakerMetadata tags the segments of rooms started from now on with their active speaker
+func (fm *ffmpegMgrImpl) EnableSpeakerMetadata() {
+	if !fm.caps.Has(mixers.FeatureSpeakerMetadata) {
+		fm.logger.Warn("FFmpeg cannot read speaker metadata, disabled")
+		return
+	}
+	fm.speakerMetadata.Store(true)
+	fm.logger.Info("Enabled speaker metadata")
+}
+
+// StartFFmpeg starts an FFmpeg process for a room, hls overrides the HLS defaults and srtp
+// decrypts the RTP inputs when set
+func (fm *ffmpegMgrImpl) StartFFmpeg(
+	roomID string,
+	rtpPort int,
+	createdAt time.Time,
+	nonce string,
+	dvrWindow int,
+	hls *etcdstate.HLSParams,
+	srtp *etcdstate.SRTP,
+) error {
 	startTime := time.Now()
 	ctx, span := fm.tracer.Start(context.Background(), "ffmpeg.StartFFmpeg",
 		trace.WithAttributes(
 			attribute.String("room.id", roomID),
 			attribute.Int("rtp.port", rtpPort),
+			attribute.Int("hls.dvr_window", dvrWindow),
+			attribute.Bool("rtp.srtp", srtp != nil),
 		))
 	defer span.End()
 
@@ -81,11 +134,22 @@ func (fm *ffmpegMgrImpl) StartFFmpeg(roomID string, rtpPort int, createdAt time.
 		return err
 	}
 
+	params := fm.hlsDefaults.Load().Merge(hls)
+	hlsOpts := HLSOptions{
+		SegmentDuration: time.Duration(params.SegmentDuration) * time.Second,
+		ListSize:        params.PlaylistSize,
+		DVRWindow:       dvrWindow,
+		SpeakerMetadata: fm.speakerMetadata.Load(),
+	}
+	if fm.caps != nil {
+		hlsOpts.AudioEncoder = fm.caps.AudioEncoder
+	}
+
 	// Calculate initial sequence number based on createdAt
-	initSeq := fm.calculateSeqNo(roomID, createdAt)
+	initSeq := fm.calculateSeqNo(roomID, createdAt, hlsOpts.segmentDuration())
 	span.SetAttributes(attribute.Int("hls.init_seq", initSeq))
 
-	sdpPath, err := fm.sdpGen.Generate(roomID, rtpPort)
+	sdpPath, err := fm.sdpGen.Generate(roomID, rtpPort, srtp)
 	if err != nil {
 		span.RecordError(err)
 		processesFailed.Add(ctx, 1, attrs)
@@ -101,7 +165,7 @@ func (fm *ffmpegMgrImpl) StartFFmpeg(roomID string, rtpPort int, createdAt time.
 	}
 
 	// Create AES encryption key info file
-	keyInfoPath, err := fm.encGen.Generate(roomID, nonce, hlsDir)
+	keyInfoPath, err := fm.encGen.Generate(roomID, nonce, params.KeyBaseURL)
 	if err != nil {
 		span.RecordError(err)
 		processesFailed.Add(ctx, 1, attrs)
@@ -111,7 +175,11 @@ func (fm *ffmpegMgrImpl) StartFFmpeg(roomID string, rtpPort int, createdAt time.
 	fm.logger.Info("Starting FFmpeg with AES encryption",
 		log.String("roomId", roomID),
 		log.Int("rtpPort", rtpPort),
-		log.Int("initSeq", initSeq))
+		log.Int("initSeq", initSeq),
+		log.Int("dvrWindow", dvrWindow),
+		log.Duration("segmentDuration", hlsOpts.segmentDuration()),
+		log.Int("listSize", hlsOpts.listSize()),
+		log.Bool("srtp", srtp != nil))
 
 	processInfo := NewProcessInfo(
 		roomID,
@@ -120,8 +188,18 @@ func (fm *ffmpegMgrImpl) StartFFmpeg(roomID string, rtpPort int, createdAt time.
 		hlsDir,
 		keyInfoPath,
 		initSeq,
+		hlsOpts,
 		fm.logger,
 	)
+	processInfo.srtp = srtp
+	processInfo.onRespawn = fm.onRespawn
+	processInfo.respawnBackoff = backoff.Exponential{
+		Initial: fm.retryDelay,
+		Max:     maxRespawnDelay,
+		Jitter:  0.2,
+	}
+	processInfo.logs = newLogRing(fm.logCapture.lines())
+	processInfo.logPath = fm.logCapture.path(roomID)
 
 	fm.processes.Store(roomID, processInfo)
 
@@ -136,6 +214,115 @@ func (fm *ffmpegMgrImpl) StartFFmpeg(roomID string, rtpPort int, createdAt time.
 	return nil
 }
 
+// SetLink mixes the linked room input received on rtpPort into the room, 0 removes it
+func (fm *ffmpegMgrImpl) SetLink(roomID string, rtpPort int) error {
+	val, exists := fm.processes.Load(roomID)
+	if !exists {
+		return fmt.Errorf("no FFmpeg process found for room %s", roomID)
+	}
+	processInfo := val.(*ProcessInfo)
+
+	if rtpPort == 0 {
+		fm.logger.Info("Removing linked room input", log.String("roomId", roomID))
+		processInfo.SetLinkSDP("")
+		if err := fm.sdpGen.DeleteLink(roomID); err != nil {
+			fm.logger.Error("Failed to delete link SDP file",
+				log.String("roomId", roomID),
+				log.Error(err))
+		}
+		return nil
+	}
+
+	sdpPath, err := fm.sdpGen.GenerateLink(roomID, rtpPort, processInfo.srtp)
+	if err != nil {
+		return fmt.Errorf("failed to generate link SDP: %w", err)
+	}
+
+	fm.logger.Info("Adding linked room input",
+		log.String("roomId", roomID),
+		log.Int("rtpPort", rtpPort))
+	processInfo.SetLinkSDP(sdpPath)
+	return nil
+}
+
+// SetTestSource replaces the RTP input of the room by src and restarts FFmpeg, nil restores the RTP input
+func (fm *ffmpegMgrImpl) SetTestSource(roomID string, src *mixers.TestSource) error {
+	val, exists := fm.processes.Load(roomID)
+	if !exists {
+		return fmt.Errorf("no FFmpeg process found for room %s", roomID)
+	}
+	if src != nil && src.Kind != mixers.TestSourceFile && !fm.caps.Has(mixers.FeatureTestSource) {
+		return fmt.Errorf("%w: %s", mixers.ErrUnsupported, mixers.FeatureTestSource)
+	}
+	if src == nil {
+		fm.logger.Info("Restoring RTP input", log.String("roomId", roomID))
+	} else {
+		fm.logger.Warn("Replacing RTP input by test source", log.String("roomId", roomID), log.Any("source", src))
+	}
+	val.(*ProcessInfo).SetTestSource(src)
+	return nil
+}
+
+// Restart kills FFmpeg of the room, it is respawned right away continuing the playlist
+func (fm *ffmpegMgrImpl) Restart(roomID string) error {
+	val, exists := fm.processes.Load(roomID)
+	if !exists {
+		return fmt.Errorf("no FFmpeg process found for room %s", roomID)
+	}
+	fm.logger.Info("Restarting FFmpeg", log.String("roomId", roomID))
+	val.(*ProcessInfo).Restart()
+	return nil
+}
+
+// SetPublishedAt sets when Janus started forwarding the room, anchoring latency estimation
+func (fm *ffmpegMgrImpl) SetPublishedAt(roomID string, at time.Time) error {
+	val, exists := fm.processes.Load(roomID)
+	if !exists {
+		return fmt.Errorf("no FFmpeg process found for room %s", roomID)
+	}
+	val.(*ProcessInfo).SetPublishedAt(at)
+	return nil
+}
+
+// MarkerReceived records a latency marker of a room sent by Janus at sentAt
+func (fm *ffmpegMgrImpl) MarkerReceived(roomID string, sentAt time.Time) error {
+	val, exists := fm.processes.Load(roomID)
+	if !exists {
+		return fmt.Errorf("no FFmpeg process found for room %s", roomID)
+	}
+	val.(*ProcessInfo).MarkerReceived(sentAt)
+	return nil
+}
+
+// SetActiveSpeaker records the active speaker of a room as of at, empty while nobody talks
+func (fm *ffmpegMgrImpl) SetActiveSpeaker(roomID, userID string, at time.Time) error {
+	val, exists := fm.processes.Load(roomID)
+	if !exists {
+		return fmt.Errorf("no FFmpeg process found for room %s", roomID)
+	}
+	val.(*ProcessInfo).SetActiveSpeaker(userID, at)
+	return nil
+}
+
+// Latency returns the publish to HLS segment latency of a room, false until measured
+func (fm *ffmpegMgrImpl) Latency(roomID string) (mixers.Latency, bool) {
+	val, exists := fm.processes.Load(roomID)
+	if !exists {
+		return mixers.Latency{}, false
+	}
+	current, average, ok := val.(*ProcessInfo).Latency()
+	return mixers.Latency{Current: current, Average: average}, ok
+}
+
+// Logs returns the last tail stderr lines of FFmpeg of a room, oldest first
+func (fm *ffmpegMgrImpl) Logs(roomID string, tail int) ([]string, error) {
+	val, exists := fm.processes.Load(roomID)
+	if !exists {
+		return nil, fmt.Errorf("no FFmpeg process found for room %s", roomID)
+	}
+	return val.(*ProcessInfo).Logs(tail), nil
+}
+
 // StopFFmpeg stops the FFmpeg process for a room
 func (fm *ffmpegMgrImpl) StopFFmpeg(roomID string) error {
 	ctx, span := fm.tracer.Start(context.Background(), "ffmpeg.StopFFmpeg",
@@ -163,6 +350,11 @@ func (fm *ffmpegMgrImpl) StopFFmpeg(roomID string) error {
 			log.String("roomId", roomID),
 			log.Error(err))
 	}
+	if err := fm.sdpGen.DeleteLink(roomID); err != nil {
+		fm.logger.Error("Failed to delete link SDP file",
+			log.String("roomId", roomID),
+			log.Error(err))
+	}
 	if err := fm.encGen.Delete(roomID); err != nil {
 		fm.logger.Error("Failed to delete encryption file",
 			log.String("roomId", roomID),
@@ -203,14 +395,16 @@ func (fm *ffmpegMgrImpl) Stop() error {
 	return nil
 }
 
-func (fm *ffmpegMgrImpl) calculateSeqNo(roomID string, createdAt time.Time) int {
+// calculateSeqNo estimates the segments written since createdAt, so a restart on another mixer
+// continues the sequence. A longer segment duration than the previous run may rewind it
+func (fm *ffmpegMgrImpl) calculateSeqNo(roomID string, createdAt time.Time, segmentDuration time.Duration) int {
 	if createdAt.IsZero() {
 		return 0
 	}
 
-	elapsedSeconds := time.Since(createdAt).Seconds()
-	// 2s per segment * 1.1 safety margin
-	initSeq := int(math.Ceil((elapsedSeconds / 2) * 1.1))
+	elapsed := time.Since(createdAt)
+	// 1.1 safety margin
+	initSeq := int(math.Ceil(float64(elapsed) / float64(segmentDuration) * 1.1))
 	fm.logger.Info("Calculated initial sequence",
 		log.String("roomId", roomID),
 		log.Time("createdAt", createdAt),
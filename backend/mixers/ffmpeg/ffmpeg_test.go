@@ -41,6 +41,7 @@ func (s *FFmpegManagerTestSuite) SetupTest() {
 		s.sdpGen,
 		100*time.Millisecond,
 		500*time.Millisecond,
+		nil,
 		log.NewNop(),
 	)
 
@@ -61,6 +62,7 @@ func (s *FFmpegManagerTestSuite) TestNewFFmpegManager() {
 			s.sdpGen,
 			0,
 			0,
+			nil,
 			log.NewNop(),
 		).(*ffmpegMgrImpl)
 
@@ -76,6 +78,7 @@ func (s *FFmpegManagerTestSuite) TestNewFFmpegManager() {
 			s.sdpGen,
 			2*time.Second,
 			10*time.Second,
+			nil,
 			log.NewNop(),
 		).(*ffmpegMgrImpl)
 
@@ -91,6 +94,7 @@ func (s *FFmpegManagerTestSuite) TestNewFFmpegManager() {
 			s.sdpGen,
 			0,
 			0,
+			nil,
 			log.NewNop(),
 		).(*ffmpegMgrImpl)
 
@@ -103,7 +107,7 @@ func (s *FFmpegManagerTestSuite) TestCalculateSeqNo() {
 		roomID := "room1"
 		createdAt := time.Now().Add(-10 * time.Second)
 
-		seqNo := s.ffmpegMgr.calculateSeqNo(roomID, createdAt)
+		seqNo := s.ffmpegMgr.calculateSeqNo(roomID, createdAt, defaultSegmentDuration)
 
 		s.Greater(seqNo, 0)
 		s.LessOrEqual(seqNo, 10)
@@ -113,7 +117,7 @@ func (s *FFmpegManagerTestSuite) TestCalculateSeqNo() {
 		roomID := "room1"
 		createdAt := time.Time{}
 
-		seqNo := s.ffmpegMgr.calculateSeqNo(roomID, createdAt)
+		seqNo := s.ffmpegMgr.calculateSeqNo(roomID, createdAt, defaultSegmentDuration)
 
 		s.Equal(0, seqNo)
 	})
@@ -122,7 +126,7 @@ func (s *FFmpegManagerTestSuite) TestCalculateSeqNo() {
 		roomID := "room1"
 		createdAt := time.Now().Add(-100 * time.Second)
 
-		seqNo := s.ffmpegMgr.calculateSeqNo(roomID, createdAt)
+		seqNo := s.ffmpegMgr.calculateSeqNo(roomID, createdAt, defaultSegmentDuration)
 
 		s.Greater(seqNo, 40)
 	})
@@ -131,7 +135,7 @@ func (s *FFmpegManagerTestSuite) TestCalculateSeqNo() {
 		roomID := "room1"
 		createdAt := time.Now().Add(-1 * time.Second)
 
-		seqNo := s.ffmpegMgr.calculateSeqNo(roomID, createdAt)
+		seqNo := s.ffmpegMgr.calculateSeqNo(roomID, createdAt, defaultSegmentDuration)
 
 		s.GreaterOrEqual(seqNo, 0)
 	})
@@ -144,7 +148,7 @@ func (s *FFmpegManagerTestSuite) TestStartFFmpeg() {
 		createdAt := time.Now()
 		nonce := "abc123"
 
-		err := s.ffmpegMgr.StartFFmpeg(roomID, rtpPort, createdAt, nonce)
+		err := s.ffmpegMgr.StartFFmpeg(roomID, rtpPort, createdAt, nonce, 0, nil, nil)
 
 		s.Require().NoError(err)
 
@@ -164,7 +168,7 @@ func (s *FFmpegManagerTestSuite) TestStartFFmpeg() {
 		createdAt := time.Now()
 		nonce := "def456"
 
-		err := s.ffmpegMgr.StartFFmpeg(roomID, rtpPort, createdAt, nonce)
+		err := s.ffmpegMgr.StartFFmpeg(roomID, rtpPort, createdAt, nonce, 0, nil, nil)
 
 		s.Require().NoError(err)
 
@@ -181,10 +185,10 @@ func (s *FFmpegManagerTestSuite) TestStartFFmpeg() {
 		roomID := "existing-room"
 		rtpPort := 5008
 
-		err := s.ffmpegMgr.StartFFmpeg(roomID, rtpPort, time.Now(), "nonce1")
+		err := s.ffmpegMgr.StartFFmpeg(roomID, rtpPort, time.Now(), "nonce1", 0, nil, nil)
 		s.Require().NoError(err)
 
-		err = s.ffmpegMgr.StartFFmpeg(roomID, rtpPort, time.Now(), "nonce2")
+		err = s.ffmpegMgr.StartFFmpeg(roomID, rtpPort, time.Now(), "nonce2", 0, nil, nil)
 
 		s.Require().Error(err)
 		s.Contains(err.Error(), "already running")
@@ -196,7 +200,7 @@ func (s *FFmpegManagerTestSuite) TestStopFFmpeg() {
 		roomID := "stop-test"
 		rtpPort := 5010
 
-		err := s.ffmpegMgr.StartFFmpeg(roomID, rtpPort, time.Now(), "nonce")
+		err := s.ffmpegMgr.StartFFmpeg(roomID, rtpPort, time.Now(), "nonce", 0, nil, nil)
 		s.Require().NoError(err)
 
 		err = s.ffmpegMgr.StopFFmpeg(roomID)
@@ -217,7 +221,7 @@ func (s *FFmpegManagerTestSuite) TestStopFFmpeg() {
 		roomID := "cleanup-test"
 		rtpPort := 5012
 
-		err := s.ffmpegMgr.StartFFmpeg(roomID, rtpPort, time.Now(), "nonce")
+		err := s.ffmpegMgr.StartFFmpeg(roomID, rtpPort, time.Now(), "nonce", 0, nil, nil)
 		s.Require().NoError(err)
 
 		sdpPath := filepath.Join(s.sdpDir, roomID+".sdp")
@@ -235,12 +239,45 @@ func (s *FFmpegManagerTestSuite) TestStopFFmpeg() {
 	})
 }
 
+func (s *FFmpegManagerTestSuite) TestSetLink() {
+	s.Run("add and remove linked input", func() {
+		roomID := "link-test"
+
+		err := s.ffmpegMgr.StartFFmpeg(roomID, 5020, time.Now(), "nonce", 0, nil, nil)
+		s.Require().NoError(err)
+
+		linkSDPPath := filepath.Join(s.sdpDir, roomID+"-link.sdp")
+
+		err = s.ffmpegMgr.SetLink(roomID, 5022)
+		s.Require().NoError(err)
+		s.FileExists(linkSDPPath)
+
+		content, err := os.ReadFile(linkSDPPath)
+		s.Require().NoError(err)
+		s.Contains(string(content), "m=audio 5022 RTP/AVP 100")
+
+		err = s.ffmpegMgr.SetLink(roomID, 0)
+		s.Require().NoError(err)
+		s.NoFileExists(linkSDPPath)
+
+		err = s.ffmpegMgr.StopFFmpeg(roomID)
+		s.Require().NoError(err)
+	})
+
+	s.Run("set link on non-existent ffmpeg process", func() {
+		err := s.ffmpegMgr.SetLink("nonexistent-room", 5022)
+
+		s.Require().Error(err)
+		s.Contains(err.Error(), "no FFmpeg process found")
+	})
+}
+
 func (s *FFmpegManagerTestSuite) TestStopAll() {
 	s.Run("stop all processes", func() {
 		rooms := []string{"room1", "room2", "room3"}
 
 		for i, roomID := range rooms {
-			err := s.ffmpegMgr.StartFFmpeg(roomID, 5020+i*2, time.Now(), "nonce")
+			err := s.ffmpegMgr.StartFFmpeg(roomID, 5020+i*2, time.Now(), "nonce", 0, nil, nil)
 			s.Require().NoError(err)
 		}
 
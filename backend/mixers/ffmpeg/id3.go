@@ -0,0 +1,261 @@
+package ffmpeg
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+// Timed ID3 metadata is fed to FFmpeg as a side MPEG-TS input carrying a single ID3 stream, as
+// Apple's "Timed Metadata for HTTP Live Streaming" lays out, and copied into the HLS segments.
+// Every tag holds a TXXX frame described "speaker" whose value is the active speaker user ID,
+// empty while nobody talks.
+const (
+	tsPacketSize = 188
+	tsPIDPAT     = 0x0000
+	tsPIDPMT     = 0x1000
+	tsPIDID3     = 0x0101
+	// tsPIDNone is the PCR PID of programs without PCR
+	tsPIDNone = 0x1FFF
+	// tsStreamTypeMetadata is metadata carried in PES packets
+	tsStreamTypeMetadata = 0x15
+	// pesStreamPrivate1 is the PES stream ID of timed ID3
+	pesStreamPrivate1 = 0xBD
+
+	// id3Speaker is the description of the TXXX frame carrying the active speaker
+	id3Speaker = "speaker"
+	// speakerKeepalive is how often the active speaker is repeated, FFmpeg waits on the input
+	// otherwise and players joining midway learn the speaker
+	speakerKeepalive = time.Second
+)
+
+// id3MetadataDescriptor is the metadata_descriptor of ID3 streams
+var id3MetadataDescriptor = []byte{
+	0x26, 13,
+	0xFF, 0xFF, 'I', 'D', '3', ' ', // application format identifier
+	0xFF, 'I', 'D', '3', ' ', // format identifier
+	0x00, // service ID
+	0x0F, // no decoder config, no DSM-CC
+}
+
+// speakerMetadata holds the active speaker of a room and streams it as timed ID3
+type speakerMetadata struct {
+	mu     sync.Mutex
+	userID string
+	at     time.Time
+	// changed is signaled when the active speaker changes
+	changed chan struct{}
+}
+
+func newSpeakerMetadata() *speakerMetadata {
+	return &speakerMetadata{changed: make(chan struct{}, 1)}
+}
+
+// set records the active speaker as of at, updates older than the current one are ignored as
+// datagrams may arrive out of order
+func (s *speakerMetadata) set(userID string, at time.Time) {
+	s.mu.Lock()
+	if at.Before(s.at) {
+		s.mu.Unlock()
+		return
+	}
+	changed := userID != s.userID
+	s.userID, s.at = userID, at
+	s.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (s *speakerMetadata) get() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.userID
+}
+
+// stream writes the active speaker to w as an MPEG-TS until done is closed or writing fails,
+// on change and every speakerKeepalive. Timestamps start at 0 when streaming starts
+func (s *speakerMetadata) stream(w io.Writer, done <-chan struct{}) error {
+	ts := &tsWriter{w: w}
+	start := time.Now()
+	ticker := time.NewTicker(speakerKeepalive)
+	defer ticker.Stop()
+
+	for {
+		if err := ts.writeTables(); err != nil {
+			return err
+		}
+		if err := ts.writeID3(id3Tag(id3Speaker, s.get()), time.Since(start)); err != nil {
+			return err
+		}
+		select {
+		case <-done:
+			return nil
+		case <-s.changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+// tsWriter writes a single program MPEG-TS with an ID3 stream
+type tsWriter struct {
+	w          io.Writer
+	continuity map[uint16]byte
+}
+
+// writeTables writes the PAT and PMT, repeated for FFmpeg to pick up the stream anytime
+func (t *tsWriter) writeTables() error {
+	pat := []byte{
+		0x00, 0x01, // program number
+		0xE0 | tsPIDPMT>>8, tsPIDPMT & 0xFF,
+	}
+	if err := t.writeSection(tsPIDPAT, 0x00, 0x0001, pat); err != nil {
+		return err
+	}
+
+	pmt := []byte{
+		0xE0 | tsPIDNone>>8, tsPIDNone & 0xFF,
+		0xF0, 0x00, // no program info
+		tsStreamTypeMetadata,
+		0xE0 | tsPIDID3>>8, tsPIDID3 & 0xFF,
+		0xF0, byte(len(id3MetadataDescriptor)),
+	}
+	pmt = append(pmt, id3MetadataDescriptor...)
+	return t.writeSection(tsPIDPMT, 0x02, 0x0001, pmt)
+}
+
+// writeSection writes a PSI section fitting a single packet
+func (t *tsWriter) writeSection(pid uint16, tableID byte, tableIDExt uint16, body []byte) error {
+	// section length counts the bytes following it, the CRC included
+	sectionLen := 5 + len(body) + 4
+	section := make([]byte, 0, 3+sectionLen)
+	section = append(section,
+		tableID,
+		0xB0|byte(sectionLen>>8), byte(sectionLen),
+		byte(tableIDExt>>8), byte(tableIDExt),
+		0xC1,       // version 0, current
+		0x00, 0x00, // section 0 of 0
+	)
+	section = append(section, body...)
+	section = binary.BigEndian.AppendUint32(section, crc32MPEG2(section))
+
+	// pointer field, sections are padded with 0xFF
+	payload := append([]byte{0x00}, section...)
+	packet := t.header(pid, true, false)
+	packet = append(packet, payload...)
+	for len(packet) < tsPacketSize {
+		packet = append(packet, 0xFF)
+	}
+	_, err := t.w.Write(packet)
+	return err
+}
+
+// writeID3 writes an ID3 tag as a PES packet presented at pts
+func (t *tsWriter) writeID3(tag []byte, pts time.Duration) error {
+	ticks := uint64(pts.Seconds()*90000) & (1<<33 - 1)
+	pes := []byte{
+		0x00, 0x00, 0x01, pesStreamPrivate1,
+		0x00, 0x00, // PES packet length, set below
+		0x84, // data aligned
+		0x80, // PTS only
+		0x05, // header data length
+		0x21 | byte(ticks>>29)&0x0E,
+		byte(ticks >> 22),
+		0x01 | byte(ticks>>14)&0xFE,
+		byte(ticks >> 7),
+		0x01 | byte(ticks<<1)&0xFE,
+	}
+	pes = append(pes, tag...)
+	binary.BigEndian.PutUint16(pes[4:], uint16(len(pes)-6)) // #nosec G115 -- tags are small
+
+	for first := true; len(pes) > 0; first = false {
+		room := tsPacketSize - 4
+		n := min(len(pes), room)
+		// the last packet is padded by an adaptation field
+		packet := t.header(tsPIDID3, first, n < room)
+		if n < room {
+			packet = appendStuffing(packet, room-n)
+		}
+		packet = append(packet, pes[:n]...)
+		if _, err := t.w.Write(packet); err != nil {
+			return err
+		}
+		pes = pes[n:]
+	}
+	return nil
+}
+
+// header returns the header of the next packet of the PID
+func (t *tsWriter) header(pid uint16, unitStart, adaptation bool) []byte {
+	if t.continuity == nil {
+		t.continuity = map[uint16]byte{}
+	}
+	cc := t.continuity[pid]
+	t.continuity[pid] = (cc + 1) & 0x0F
+
+	b1 := byte(pid>>8) & 0x1F
+	if unitStart {
+		b1 |= 0x40
+	}
+	control := byte(0x10) // payload only
+	if adaptation {
+		control = 0x30
+	}
+	packet := make([]byte, 4, tsPacketSize)
+	packet[0], packet[1], packet[2], packet[3] = 0x47, b1, byte(pid), control|cc
+	return packet
+}
+
+// appendStuffing appends an adaptation field of n bytes padding a packet
+func appendStuffing(packet []byte, n int) []byte {
+	packet = append(packet, byte(n-1))
+	if n > 1 {
+		packet = append(packet, 0x00) // no flags
+		for i := 2; i < n; i++ {
+			packet = append(packet, 0xFF)
+		}
+	}
+	return packet
+}
+
+// id3Tag returns an ID3v2.4 tag with a single TXXX frame
+func id3Tag(description, value string) []byte {
+	frame := []byte{0x03} // UTF-8
+	frame = append(frame, description...)
+	frame = append(frame, 0x00)
+	frame = append(frame, value...)
+
+	tag := []byte{'I', 'D', '3', 0x04, 0x00, 0x00}
+	tag = append(tag, synchsafe(10+len(frame))...)
+	tag = append(tag, 'T', 'X', 'X', 'X')
+	tag = append(tag, synchsafe(len(frame))...)
+	tag = append(tag, 0x00, 0x00) // no frame flags
+	return append(tag, frame...)
+}
+
+// synchsafe encodes an ID3 size, 7 bits per byte
+func synchsafe(n int) []byte {
+	return []byte{byte(n>>21) & 0x7F, byte(n>>14) & 0x7F, byte(n>>7) & 0x7F, byte(n) & 0x7F}
+}
+
+// crc32MPEG2 is the CRC of PSI sections
+func crc32MPEG2(data []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,125 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestID3Tag(t *testing.T) {
+	tag := id3Tag(id3Speaker, "u1")
+
+	assert.Equal(t, []byte("ID3\x04\x00\x00"), tag[:6])
+	assert.Equal(t, []byte{0, 0, 0, byte(len(tag) - 10)}, tag[6:10])
+	assert.Equal(t, []byte("TXXX"), tag[10:14])
+	assert.Equal(t, []byte("\x03speaker\x00u1"), tag[20:])
+	assert.Equal(t, []byte{0, 0, 0x02, 0x00}, synchsafe(256))
+}
+
+func TestCRC32MPEG2(t *testing.T) {
+	// check value of CRC-32/MPEG-2
+	assert.Equal(t, uint32(0x0376E6E7), crc32MPEG2([]byte("123456789")))
+}
+
+func TestTSWriter(t *testing.T) {
+	var buf bytes.Buffer
+	ts := &tsWriter{w: &buf}
+
+	require.NoError(t, ts.writeTables())
+	require.NoError(t, ts.writeID3(id3Tag(id3Speaker, "u1"), 2*time.Second))
+	// a tag spanning packets
+	long := id3Tag(id3Speaker, strings.Repeat("x", 300))
+	require.NoError(t, ts.writeID3(long, 3*time.Second))
+
+	data := buf.Bytes()
+	require.Zero(t, len(data)%tsPacketSize)
+	packets := make([][]byte, 0, len(data)/tsPacketSize)
+	for i := 0; i < len(data); i += tsPacketSize {
+		packet := data[i : i+tsPacketSize]
+		require.Equal(t, byte(0x47), packet[0])
+		packets = append(packets, packet)
+	}
+	require.Len(t, packets, 5)
+
+	pid := func(packet []byte) uint16 { return binary.BigEndian.Uint16(packet[1:]) & 0x1FFF }
+	assert.Equal(t, uint16(tsPIDPAT), pid(packets[0]))
+	assert.Equal(t, uint16(tsPIDPMT), pid(packets[1]))
+
+	t.Run("sections are checksummed", func(t *testing.T) {
+		for _, packet := range packets[:2] {
+			section := packet[5:]
+			length := int(binary.BigEndian.Uint16(section[1:]) & 0x0FFF)
+			// the CRC of a section including its CRC is 0
+			assert.Zero(t, crc32MPEG2(section[:3+length]))
+		}
+		assert.Equal(t, byte(tsStreamTypeMetadata), packets[1][5+12])
+	})
+
+	t.Run("tags are PES packets with PTS", func(t *testing.T) {
+		packet := packets[2]
+		assert.Equal(t, uint16(tsPIDID3), pid(packet))
+		assert.NotZero(t, packet[1]&0x40, "unit start")
+		assert.Equal(t, byte(0x30), packet[3]&0x30, "stuffed")
+
+		pes := packet[4+1+int(packet[4]):]
+		assert.Equal(t, []byte{0, 0, 1, pesStreamPrivate1}, pes[:4])
+		ptsBytes := pes[9:14]
+		pts := uint64(ptsBytes[0]>>1&0x07)<<30 | uint64(ptsBytes[1])<<22 | uint64(ptsBytes[2]>>1)<<15 |
+			uint64(ptsBytes[3])<<7 | uint64(ptsBytes[4]>>1)
+		assert.Equal(t, uint64(180000), pts)
+		assert.Equal(t, id3Tag(id3Speaker, "u1"), pes[14:])
+	})
+
+	t.Run("long tags continue in the next packet", func(t *testing.T) {
+		first, next := packets[3], packets[4]
+		assert.NotZero(t, first[1]&0x40)
+		assert.Zero(t, next[1]&0x40)
+		assert.Equal(t, (first[3]+1)&0x0F, next[3]&0x0F, "continuity")
+
+		payload := append([]byte{}, first[4:]...)
+		payload = append(payload, next[4+1+int(next[4]):]...)
+		assert.Equal(t, long, payload[14:])
+	})
+}
+
+func TestSpeakerMetadata(t *testing.T) {
+	t.Run("ignores older updates", func(t *testing.T) {
+		s := newSpeakerMetadata()
+		now := time.Now()
+		s.set("u2", now)
+		s.set("u1", now.Add(-time.Second))
+		assert.Equal(t, "u2", s.get())
+		s.set("", now.Add(time.Second))
+		assert.Empty(t, s.get())
+	})
+
+	t.Run("streams on change", func(t *testing.T) {
+		s := newSpeakerMetadata()
+		r, w := io.Pipe()
+		done := make(chan struct{})
+		go func() {
+			_ = s.stream(w, done)
+			_ = w.Close()
+		}()
+
+		// tables and the tag of nobody talking, then again on change
+		read := func() []byte {
+			buf := make([]byte, 3*tsPacketSize)
+			_, err := io.ReadFull(r, buf)
+			require.NoError(t, err)
+			return buf[2*tsPacketSize:]
+		}
+		assert.Contains(t, string(read()), "speaker\x00")
+		s.set("u1", time.Now())
+		assert.Contains(t, string(read()), "speaker\x00u1")
+
+		close(done)
+		_, _ = io.Copy(io.Discard, r)
+	})
+}
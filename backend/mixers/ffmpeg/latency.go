@@ -0,0 +1,146 @@
+package ffmpeg
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSegmentDuration is -hls_time of rooms without a configured segment duration
+	defaultSegmentDuration = 2 * time.Second
+	// latencyAvgWeight is the weight of a new sample in the moving average
+	latencyAvgWeight = 0.2
+	// maxPendingMarkers bounds the markers kept for the segment being written
+	maxPendingMarkers = 16
+)
+
+// latencyTracker measures the publish to HLS segment latency of a room. Janus hosts send
+// latency markers next to the RTP forward, a marker arriving while a segment is written is
+// held in that segment, so its completion minus the marker send time is the latency.
+// Until a marker is seen it falls back to an estimate: Janus AudioBridge forwards a
+// continuous stream once forwarding starts, so the last sample of a segment was published
+// at anchor + segments since anchor * segment duration. The anchor is the later of
+// forwarding start reported by Janus and the spawn of the current FFmpeg run, as packets
+// sent before FFmpeg listens are lost. Janus and mixer clocks are assumed to be NTP synced.
+type latencyTracker struct {
+	segmentDuration time.Duration // zero means defaultSegmentDuration
+
+	mu          sync.Mutex
+	publishedAt time.Time // forwarding start reported by Janus, zero until known
+	anchor      time.Time // publish time of the first sample of anchorSeq
+	anchorSeq   int
+	lastSeq     int         // last completed segment
+	markers     []time.Time // send times of markers received during the current segment
+	markerBased bool        // markers were seen, estimation is off
+	current     time.Duration
+	average     time.Duration
+	measured    bool
+}
+
+// setPublishedAt rebases the estimation on a forwarding (re)start during the current run
+func (t *latencyTracker) setPublishedAt(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if at.Equal(t.publishedAt) {
+		return
+	}
+	t.publishedAt = at
+	if at.After(t.anchor) {
+		t.anchor = at
+		t.anchorSeq = t.lastSeq + 1
+	}
+}
+
+// startRun is called when FFmpeg is spawned to write segments from startNumber on
+func (t *latencyTracker) startRun(now time.Time, startNumber int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.anchor = now
+	if t.publishedAt.After(now) {
+		t.anchor = t.publishedAt
+	}
+	t.anchorSeq = startNumber
+	t.lastSeq = startNumber - 1
+	// audio of markers received before the spawn was lost with the previous run
+	t.markers = t.markers[:0]
+}
+
+// markerReceived records a latency marker sent at sentAt, its audio is in the segment being written
+func (t *latencyTracker) markerReceived(sentAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.markerBased = true
+	if len(t.markers) < maxPendingMarkers {
+		t.markers = append(t.markers, sentAt)
+	}
+}
+
+// segmentCompleted records the latency of segment seq completed at now
+func (t *latencyTracker) segmentCompleted(now time.Time, seq int) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lastSeq = seq
+	if t.markerBased {
+		return t.completeMarkers(now)
+	}
+
+	segments := seq - t.anchorSeq + 1
+	if t.publishedAt.IsZero() || segments <= 0 {
+		return 0, false
+	}
+
+	segmentDuration := t.segmentDuration
+	if segmentDuration <= 0 {
+		segmentDuration = defaultSegmentDuration
+	}
+	latency := now.Sub(t.anchor.Add(time.Duration(segments) * segmentDuration))
+	if latency < 0 {
+		// clock skew between Janus and mixer, do not report a bogus value
+		return 0, false
+	}
+	t.record(latency)
+	return latency, true
+}
+
+// completeMarkers measures the latency from the earliest marker of the completed segment,
+// segments without a marker are not measured
+func (t *latencyTracker) completeMarkers(now time.Time) (time.Duration, bool) {
+	if len(t.markers) == 0 {
+		return 0, false
+	}
+	earliest := t.markers[0]
+	for _, sentAt := range t.markers[1:] {
+		if sentAt.Before(earliest) {
+			earliest = sentAt
+		}
+	}
+	t.markers = t.markers[:0]
+
+	latency := now.Sub(earliest)
+	if latency < 0 {
+		// clock skew between Janus and mixer, do not report a bogus value
+		return 0, false
+	}
+	t.record(latency)
+	return latency, true
+}
+
+func (t *latencyTracker) record(latency time.Duration) {
+	t.current = latency
+	if t.measured {
+		t.average += time.Duration(latencyAvgWeight * float64(latency-t.average))
+	} else {
+		t.average = latency
+		t.measured = true
+	}
+}
+
+func (t *latencyTracker) get() (current, average time.Duration, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current, t.average, t.measured
+}
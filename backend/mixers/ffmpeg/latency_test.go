@@ -0,0 +1,105 @@
+package ffmpeg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("not measured until forwarding starts", func(t *testing.T) {
+		var tr latencyTracker
+		tr.startRun(base, 10)
+
+		_, ok := tr.segmentCompleted(base.Add(5*time.Second), 10)
+		assert.False(t, ok)
+
+		_, _, ok = tr.get()
+		assert.False(t, ok)
+	})
+
+	t.Run("anchored at forwarding start after spawn", func(t *testing.T) {
+		var tr latencyTracker
+		tr.startRun(base, 10)
+		tr.setPublishedAt(base.Add(3 * time.Second))
+
+		// first segment ends with audio published at 3s + 2s
+		latency, ok := tr.segmentCompleted(base.Add(6500*time.Millisecond), 10)
+		assert.True(t, ok)
+		assert.Equal(t, 1500*time.Millisecond, latency)
+
+		latency, ok = tr.segmentCompleted(base.Add(9*time.Second), 11)
+		assert.True(t, ok)
+		assert.Equal(t, 2*time.Second, latency)
+
+		current, average, ok := tr.get()
+		assert.True(t, ok)
+		assert.Equal(t, 2*time.Second, current)
+		assert.Equal(t, 1600*time.Millisecond, average)
+	})
+
+	t.Run("anchored at spawn when restarted while forwarding", func(t *testing.T) {
+		var tr latencyTracker
+		tr.setPublishedAt(base)
+		tr.startRun(base.Add(time.Minute), 40)
+
+		latency, ok := tr.segmentCompleted(base.Add(time.Minute+3*time.Second), 40)
+		assert.True(t, ok)
+		assert.Equal(t, time.Second, latency)
+	})
+
+	t.Run("rebased on forwarding restart", func(t *testing.T) {
+		var tr latencyTracker
+		tr.setPublishedAt(base)
+		tr.startRun(base, 0)
+		_, ok := tr.segmentCompleted(base.Add(3*time.Second), 0)
+		assert.True(t, ok)
+
+		tr.setPublishedAt(base.Add(10 * time.Second))
+		latency, ok := tr.segmentCompleted(base.Add(13*time.Second), 1)
+		assert.True(t, ok)
+		assert.Equal(t, time.Second, latency)
+	})
+
+	t.Run("skips negative latency from clock skew", func(t *testing.T) {
+		var tr latencyTracker
+		tr.setPublishedAt(base.Add(time.Minute))
+		tr.startRun(base, 0)
+
+		_, ok := tr.segmentCompleted(base.Add(3*time.Second), 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("measured from markers", func(t *testing.T) {
+		var tr latencyTracker
+		tr.setPublishedAt(base)
+		tr.startRun(base, 0)
+
+		tr.markerReceived(base.Add(1500 * time.Millisecond))
+		tr.markerReceived(base.Add(time.Second))
+		latency, ok := tr.segmentCompleted(base.Add(4*time.Second), 0)
+		assert.True(t, ok)
+		assert.Equal(t, 3*time.Second, latency)
+
+		// no marker in the segment, estimation stays off
+		_, ok = tr.segmentCompleted(base.Add(6*time.Second), 1)
+		assert.False(t, ok)
+
+		current, _, ok := tr.get()
+		assert.True(t, ok)
+		assert.Equal(t, 3*time.Second, current)
+	})
+
+	t.Run("drops markers of a previous run", func(t *testing.T) {
+		var tr latencyTracker
+		tr.startRun(base, 0)
+		tr.markerReceived(base.Add(time.Second))
+		tr.startRun(base.Add(10*time.Second), 5)
+
+		_, ok := tr.segmentCompleted(base.Add(12*time.Second), 5)
+		assert.False(t, ok)
+	})
+}
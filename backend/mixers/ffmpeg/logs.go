@@ -0,0 +1,102 @@
+package ffmpeg
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultLogLines = 500
+	// maxLogLineLen truncates stderr lines kept, progress output can be long
+	maxLogLineLen = 1024
+)
+
+// LogCapture keeps the FFmpeg stderr of each room, so broken mixes can be debugged without
+// shell access to the mixer
+type LogCapture struct {
+	// Lines is the stderr lines kept in memory per room
+	Lines int `mapstructure:"lines"`
+	// Dir also appends the stderr of each room to <Dir>/<roomId>.log, empty keeps it in memory only.
+	// The file starts over when the room starts on the mixer
+	Dir string `mapstructure:"dir"`
+}
+
+func SetupLogCapture(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("lines"), defaultLogLines)
+	v.SetDefault(p("dir"), "")
+}
+
+func (c *LogCapture) lines() int {
+	if c == nil || c.Lines <= 0 {
+		return defaultLogLines
+	}
+	return c.Lines
+}
+
+func (c *LogCapture) path(roomID string) string {
+	if c == nil || c.Dir == "" {
+		return ""
+	}
+	return filepath.Join(c.Dir, roomID+".log")
+}
+
+// logRing holds the last lines written, oldest first once full
+type logRing struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+func newLogRing(size int) *logRing {
+	return &logRing{lines: make([]string, size)}
+}
+
+func (r *logRing) add(line string) {
+	if len(line) > maxLogLineLen {
+		line = line[:maxLogLineLen]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// tail returns the last n lines, oldest first
+func (r *logRing) tail(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	size := r.next
+	if r.full {
+		size = len(r.lines)
+	}
+	n = min(n, size)
+	out := make([]string, 0, n)
+	for i := size - n; i < size; i++ {
+		out = append(out, r.lines[(r.next-size+i+len(r.lines))%len(r.lines)])
+	}
+	return out
+}
+
+// openLogFile opens the stderr file of a run, truncated on the first run of the room
+func openLogFile(path string, first bool) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if first {
+		flags |= os.O_TRUNC
+	}
+	// #nosec G304 -- path is built from the configured directory and a validated room ID
+	return os.OpenFile(path, flags, 0o640)
+}
@@ -0,0 +1,71 @@
+package ffmpeg
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+func TestLogRing(t *testing.T) {
+	t.Run("tail before full", func(t *testing.T) {
+		r := newLogRing(3)
+		assert.Empty(t, r.tail(10))
+
+		r.add("a")
+		r.add("b")
+		assert.Equal(t, []string{"a", "b"}, r.tail(10))
+		assert.Equal(t, []string{"b"}, r.tail(1))
+	})
+
+	t.Run("keeps the last lines once full", func(t *testing.T) {
+		r := newLogRing(3)
+		for _, line := range []string{"a", "b", "c", "d", "e"} {
+			r.add(line)
+		}
+		assert.Equal(t, []string{"c", "d", "e"}, r.tail(10))
+		assert.Equal(t, []string{"d", "e"}, r.tail(2))
+	})
+
+	t.Run("truncates long lines", func(t *testing.T) {
+		r := newLogRing(1)
+		r.add(strings.Repeat("x", 2*maxLogLineLen))
+		assert.Len(t, r.tail(1)[0], maxLogLineLen)
+	})
+}
+
+func TestHandleStderrCapture(t *testing.T) {
+	dir := t.TempDir()
+	p := NewProcessInfo("room1", 5004, "", "", "", 0, HLSOptions{}, log.NewNop())
+	p.logs = newLogRing(2)
+	p.logPath = filepath.Join(dir, "logs", "room1.log")
+
+	run := func(stderr string) {
+		p.handleStderr(io.NopCloser(strings.NewReader(stderr)), p.openLogFile())
+	}
+
+	run("first run\n")
+	run("Input #0, rtp\n\n[hls @ 0x1] Opening '/hls/room1/segment_003.ts' for writing\nbroken pipe\n")
+
+	assert.Equal(t, []string{"[hls @ 0x1] Opening '/hls/room1/segment_003.ts' for writing", "broken pipe"}, p.Logs(10))
+	assert.Equal(t, 2, *p.curSeq.Load())
+
+	// respawns append to the file of the room
+	data, err := os.ReadFile(p.logPath)
+	require.NoError(t, err)
+	assert.Equal(t, "first run\nInput #0, rtp\n[hls @ 0x1] Opening '/hls/room1/segment_003.ts' for writing\nbroken pipe\n", string(data))
+
+	// a new process of the room starts it over
+	p = NewProcessInfo("room1", 5004, "", "", "", 0, HLSOptions{}, log.NewNop())
+	p.logPath = filepath.Join(dir, "logs", "room1.log")
+	run("restarted\n")
+	data, err = os.ReadFile(p.logPath)
+	require.NoError(t, err)
+	assert.Equal(t, "restarted\n", string(data))
+}
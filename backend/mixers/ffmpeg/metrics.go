@@ -13,6 +13,7 @@ var (
 	processesStopped metric.Int64Counter
 	processesFailed  metric.Int64Counter
 	startDuration    metric.Int64Histogram
+	segmentLatency   metric.Int64Histogram
 )
 
 func init() {
@@ -33,4 +34,8 @@ func init() {
 	f.Int64Histogram(&startDuration, "ffmpeg.start.duration",
 		metric.WithDescription("Duration of FFmpeg start operations in milliseconds"),
 		metric.WithUnit("ms"))
+
+	f.Int64Histogram(&segmentLatency, "ffmpeg.segment.latency",
+		metric.WithDescription("Latency from audio published at Janus to its HLS segment written, measured from latency markers or estimated"),
+		metric.WithUnit("ms"))
 }
@@ -2,7 +2,10 @@ package ffmpeg
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
@@ -11,22 +14,73 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/imtaco/audio-rtc-exp/internal/backoff"
 	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/mixers"
 )
 
 const (
 	forceKillTimeout = 5 * time.Second
-	retryDelay       = 2 * time.Second
+	// maxRespawnDelay caps the delay before FFmpeg exiting over and over is spawned again
+	maxRespawnDelay = 30 * time.Second
+	// stableRun is how long FFmpeg runs before exiting no longer counts as a failed spawn
+	stableRun = time.Minute
+	// defaultListSize is the playlist length of rooms without DVR window
+	defaultListSize = 5
+	// defaultTestFrequency is the tone of sine test sources without frequency
+	defaultTestFrequency = 440
+	// defaultAudioEncoder is the native AAC encoder of FFmpeg
+	defaultAudioEncoder = "aac"
 )
 
+// HLSOptions are the HLS parameters of a room, resolved from mixer defaults and room overrides
+type HLSOptions struct {
+	SegmentDuration time.Duration
+	ListSize        int // live playlist length, DVR windows keep more segments
+	DVRWindow       int // seconds
+	// SpeakerMetadata tags the segments with the active speaker as timed ID3
+	SpeakerMetadata bool
+	// AudioEncoder is the FFmpeg AAC encoder, aac when empty
+	AudioEncoder string
+	// resumed is set when FFmpeg is respawned for a playlist it already wrote segments to
+	resumed bool
+}
+
+func (o HLSOptions) segmentDuration() time.Duration {
+	if o.SegmentDuration <= 0 {
+		return defaultSegmentDuration
+	}
+	return o.SegmentDuration
+}
+
+func (o HLSOptions) audioEncoder() string {
+	if o.AudioEncoder == "" {
+		return defaultAudioEncoder
+	}
+	return o.AudioEncoder
+}
+
+func (o HLSOptions) listSize() int {
+	if o.ListSize <= 0 {
+		return defaultListSize
+	}
+	return o.ListSize
+}
+
 func NewProcessInfo(
 	roomID string,
 	rtpPort int,
 	sdpPath, hlsDir, keyInfoPath string,
 	initSeq int,
+	hls HLSOptions,
 	logger *log.Logger,
 ) *ProcessInfo {
+	var speakers *speakerMetadata
+	if hls.SpeakerMetadata {
+		speakers = newSpeakerMetadata()
+	}
 	return &ProcessInfo{
 		roomID:      roomID,
 		rtpPort:     rtpPort,
@@ -34,10 +88,20 @@ func NewProcessInfo(
 		hlsDir:      hlsDir,
 		keyInfoPath: keyInfoPath,
 		initSeq:     initSeq,
+		hls:         hls,
+		latency:     latencyTracker{segmentDuration: hls.segmentDuration()},
+		speakers:    speakers,
+		logs:        newLogRing(defaultLogLines),
 		chanStop:    make(chan struct{}),
+		chanRestart: make(chan struct{}, 1),
 		curSeq:      atomic.Pointer[int]{},
 		SpawnFFmpeg: spawnFFmpeg, // Default implementation
-		logger:      logger,
+		respawnBackoff: backoff.Exponential{
+			Initial: 2 * time.Second,
+			Max:     maxRespawnDelay,
+			Jitter:  0.2,
+		},
+		logger: logger,
 	}
 }
 
@@ -50,16 +114,41 @@ type ProcessInfo struct {
 	hlsDir      string
 	keyInfoPath string
 	initSeq     int
+	hls         HLSOptions
+	// srtp decrypts the RTP inputs, nil when they are plaintext
+	srtp *etcdstate.SRTP
 
-	pid      int32
-	process  *exec.Cmd
-	chanStop chan struct{}
+	pid         int32
+	process     *exec.Cmd
+	chanStop    chan struct{}
+	chanRestart chan struct{}
 
 	// Atomic fields for lock-free concurrent access
-	curSeq atomic.Pointer[int]
+	curSeq      atomic.Pointer[int]
+	linkSDPPath atomic.Pointer[string]
+	testSource  atomic.Pointer[mixers.TestSource]
+
+	latency latencyTracker
+	// speakers is the active speaker fed to FFmpeg, nil without speaker metadata
+	speakers *speakerMetadata
+	// onRespawn is called before FFmpeg is spawned again, nil when nobody listens
+	onRespawn func(roomID, reason string)
+	// respawnBackoff delays spawning FFmpeg again after it exited on its own
+	respawnBackoff backoff.Policy
+	// logs keeps the last stderr lines, logPath also appends them to a file when set
+	logs       *logRing
+	logPath    string
+	logStarted bool // the log file was started over, only used by the run loop
 
 	// Function for spawning FFmpeg process (can be replaced for testing)
-	SpawnFFmpeg func(sdpPath, hlsDir string, startNumber int, keyInfoPath string) *exec.Cmd
+	SpawnFFmpeg func(
+		sdpPath, linkSDPPath string,
+		src *mixers.TestSource,
+		hlsDir string,
+		startNumber int,
+		hls HLSOptions,
+		keyInfoPath string,
+	) *exec.Cmd
 
 	logger *log.Logger
 }
@@ -80,17 +169,54 @@ func (p *ProcessInfo) Run() {
 		default:
 		}
 
-		if attempts > 0 {
-			// exponential backoff with max cap
-			time.Sleep(retryDelay)
+		if attempts > 0 && !p.waitRespawn(p.respawnBackoff.Delay(attempts-1)) {
+			p.logger.Info("FFmpeg process stopping",
+				log.String("roomId", p.roomID))
+			return
 		}
 
 		p.logger.Info("FFmpeg retry attempt",
 			log.String("roomId", p.roomID),
 			log.Int("attempt", attempts))
 
-		p.runOnce()
+		started := time.Now()
+		if p.runOnce() {
+			// restarted on purpose, respawn right away
+			attempts = 0
+			p.respawning(mixers.RespawnRequested)
+			continue
+		}
+		// a long run exiting is not a failing spawn, respawn it as quickly as the first time
+		if time.Since(started) > stableRun {
+			attempts = 0
+		}
 		attempts++
+		p.respawning(mixers.RespawnExited)
+	}
+}
+
+// waitRespawn waits d before spawning FFmpeg again, false when the process is stopped meanwhile
+func (p *ProcessInfo) waitRespawn(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-p.chanStop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// respawning tells the respawn handler FFmpeg is about to be spawned again, unless the
+// process is being stopped
+func (p *ProcessInfo) respawning(reason string) {
+	if p.onRespawn == nil {
+		return
+	}
+	select {
+	case <-p.chanStop:
+	default:
+		p.onRespawn(p.roomID, reason)
 	}
 }
 
@@ -106,13 +232,60 @@ func (p *ProcessInfo) Stop() {
 	close(p.chanStop)
 }
 
-// Start starts the FFmpeg process
-func (p *ProcessInfo) runOnce() {
+// SetPublishedAt sets when Janus started forwarding the room
+func (p *ProcessInfo) SetPublishedAt(at time.Time) {
+	p.latency.setPublishedAt(at)
+}
+
+// MarkerReceived records a latency marker sent by Janus at sentAt
+func (p *ProcessInfo) MarkerReceived(sentAt time.Time) {
+	p.latency.markerReceived(sentAt)
+}
+
+// SetActiveSpeaker records the active speaker as of at, ignored without speaker metadata
+func (p *ProcessInfo) SetActiveSpeaker(userID string, at time.Time) {
+	if p.speakers != nil {
+		p.speakers.set(userID, at)
+	}
+}
+
+// Latency returns the publish to HLS segment latency, false until measured
+func (p *ProcessInfo) Latency() (current, average time.Duration, ok bool) {
+	return p.latency.get()
+}
+
+// SetLinkSDP sets the SDP of the linked room input ("" to remove it) and restarts FFmpeg,
+// the HLS sequence continues from the last completed segment after a discontinuity
+func (p *ProcessInfo) SetLinkSDP(sdpPath string) {
+	p.linkSDPPath.Store(&sdpPath)
+	p.Restart()
+}
+
+// SetTestSource replaces the RTP input by src (nil restores it) and restarts FFmpeg,
+// the HLS sequence continues after a discontinuity as for links
+func (p *ProcessInfo) SetTestSource(src *mixers.TestSource) {
+	p.testSource.Store(src)
+	p.Restart()
+}
+
+// Restart kills the running FFmpeg, it is respawned right away continuing the HLS sequence
+func (p *ProcessInfo) Restart() {
+	select {
+	case p.chanRestart <- struct{}{}:
+	default:
+		// restart already pending
+	}
+}
+
+// runOnce runs FFmpeg until it exits, returns true if it was restarted on purpose
+func (p *ProcessInfo) runOnce() bool {
 	// Determine start number
 	startNumber := p.initSeq
+	hls := p.hls
 	curSeqPtr := p.curSeq.Load()
 	if curSeqPtr != nil {
 		startNumber = *curSeqPtr + 1
+		hls.resumed = true
 	}
 
 	// Read attempt atomically
@@ -121,14 +294,47 @@ func (p *ProcessInfo) runOnce() {
 		log.String("roomId", p.roomID),
 		log.Int("startNumber", startNumber))
 
-	cmd := p.SpawnFFmpeg(p.sdpPath, p.hlsDir, startNumber, p.keyInfoPath)
+	var linkSDPPath string
+	if ptr := p.linkSDPPath.Load(); ptr != nil {
+		linkSDPPath = *ptr
+	}
+
+	cmd := p.SpawnFFmpeg(p.sdpPath, linkSDPPath, p.testSource.Load(), p.hlsDir, startNumber, hls, p.keyInfoPath)
+	p.latency.startRun(time.Now(), startNumber)
 
 	stdout, _ := cmd.StdoutPipe()
 	stderr, _ := cmd.StderrPipe()
 
+	// the active speaker is streamed to FFmpeg through a pipe inherited as fd 3
+	var metaWriter *os.File
+	if p.speakers != nil {
+		metaReader, w, err := os.Pipe()
+		if err != nil {
+			p.logger.Error("Failed to create speaker metadata pipe", log.String("roomId", p.roomID), log.Error(err))
+			return false
+		}
+		cmd.ExtraFiles = []*os.File{metaReader}
+		metaWriter = w
+		defer func() { _ = metaReader.Close() }()
+	}
+
 	if err := cmd.Start(); err != nil {
 		p.logger.Error("Failed to start FFmpeg", log.String("roomId", p.roomID), log.Error(err))
-		return
+		if metaWriter != nil {
+			_ = metaWriter.Close()
+		}
+		return false
+	}
+
+	if metaWriter != nil {
+		metaDone := make(chan struct{})
+		defer close(metaDone)
+		go func() {
+			defer func() { _ = metaWriter.Close() }()
+			if err := p.speakers.stream(metaWriter, metaDone); err != nil {
+				p.logger.Debug("Speaker metadata stream ended", log.String("roomId", p.roomID), log.Error(err))
+			}
+		}()
 	}
 
 	// Store PID atomically
@@ -140,7 +346,7 @@ func (p *ProcessInfo) runOnce() {
 	go p.handleStdout(stdout)
 
 	// Handle stderr
-	go p.handleStderr(stderr)
+	go p.handleStderr(stderr, p.openLogFile())
 
 	// Wait for process to exit
 	done := p.startWaitForExit()
@@ -151,7 +357,13 @@ func (p *ProcessInfo) runOnce() {
 		p.stop()
 		// still need to wait for done
 		<-done
+	case <-p.chanRestart:
+		p.logger.Info("Restarting FFmpeg", log.String("roomId", p.roomID))
+		p.stop()
+		<-done
+		return true
 	}
+	return false
 }
 
 // Stop stops the FFmpeg process
@@ -199,8 +411,31 @@ func (p *ProcessInfo) handleStdout(stdout io.ReadCloser) {
 	}
 }
 
-// handleStderr reads and logs FFmpeg stderr, extracting sequence numbers
-func (p *ProcessInfo) handleStderr(stderr io.ReadCloser) {
+// openLogFile opens the stderr file of the run, nil without one
+func (p *ProcessInfo) openLogFile() *os.File {
+	if p.logPath == "" {
+		return nil
+	}
+	f, err := openLogFile(p.logPath, !p.logStarted)
+	if err != nil {
+		p.logger.Warn("Failed to open FFmpeg log file", log.String("roomId", p.roomID), log.Error(err))
+		return nil
+	}
+	p.logStarted = true
+	return f
+}
+
+// Logs returns the last n stderr lines of FFmpeg, across respawns
+func (p *ProcessInfo) Logs(n int) []string {
+	return p.logs.tail(n)
+}
+
+// handleStderr reads and logs FFmpeg stderr, extracting sequence numbers. Lines are kept in the
+// log ring and written to logFile when not nil
+func (p *ProcessInfo) handleStderr(stderr io.ReadCloser, logFile *os.File) {
+	if logFile != nil {
+		defer func() { _ = logFile.Close() }()
+	}
 	scanner := bufio.NewScanner(stderr)
 	segmentRegex := regexp.MustCompile(`Opening '.*\/segment_(\d+)\.ts' for writing`)
 
@@ -209,6 +444,15 @@ func (p *ProcessInfo) handleStderr(stderr io.ReadCloser) {
 		if line == "" {
 			continue
 		}
+		p.logs.add(line)
+		if logFile != nil {
+			if _, err := logFile.WriteString(line + "\n"); err != nil {
+				p.logger.Warn("Failed to write FFmpeg log file", log.String("roomId", p.roomID), log.Error(err))
+				_ = logFile.Close()
+				logFile = nil
+			}
+		}
+
 		matches := segmentRegex.FindStringSubmatch(line)
 		if matches == nil {
 			continue
@@ -222,6 +466,10 @@ func (p *ProcessInfo) handleStderr(stderr io.ReadCloser) {
 		completedSeq := sequence - 1
 		p.curSeq.Store(&completedSeq)
 
+		if latency, ok := p.latency.segmentCompleted(time.Now(), completedSeq); ok {
+			segmentLatency.Record(context.Background(), latency.Milliseconds())
+		}
+
 		p.logger.Debug("HLS Segment completed",
 			log.String("roomId", p.roomID),
 			log.Int("curSeq", completedSeq),
@@ -261,22 +509,117 @@ func (p *ProcessInfo) startWaitForExit() <-chan struct{} {
 	return done
 }
 
-// spawnFFmpeg spawns a new FFmpeg process
-func spawnFFmpeg(sdpPath, hlsDir string, startNumber int, keyInfoPath string) *exec.Cmd {
-	args := []string{
-		"-protocol_whitelist", "file,udp,rtp",
-		"-i", sdpPath,
-		"-c:a", "aac",
+// hlsArgs returns the segment and playlist options, a DVR window keeps DVRWindow seconds of segments.
+// FFmpeg's EVENT playlist type never deletes segments, so the window is a long sliding live
+// playlist instead and hlsserver serves EVENT playlists for catch-up playback.
+// A respawned FFmpeg keeps the segments already listed and marks a discontinuity before its
+// own, its timestamps start over and players would stall or skip without the tag
+func hlsArgs(hls HLSOptions) []string {
+	segmentDuration := hls.segmentDuration()
+	args := []string{"-hls_time", strconv.FormatFloat(segmentDuration.Seconds(), 'f', -1, 64)}
+
+	if hls.DVRWindow <= 0 {
+		flags := "delete_segments"
+		if hls.resumed {
+			flags += "+append_list+discont_start"
+		}
+		return append(args,
+			"-hls_list_size", strconv.Itoa(hls.listSize()),
+			"-hls_flags", flags,
+		)
+	}
+	window := time.Duration(hls.DVRWindow) * time.Second
+	listSize := max(hls.listSize(), int((window+segmentDuration-1)/segmentDuration))
+	// restarts append to the window instead of starting over, program date time maps
+	// segments to wall clock for time-shifted playlists
+	flags := "delete_segments+append_list+program_date_time"
+	if hls.resumed {
+		flags += "+discont_start"
+	}
+	return append(args,
+		"-hls_list_size", strconv.Itoa(listSize),
+		"-hls_flags", flags,
+	)
+}
+
+// inputArgs returns the input of the room, its RTP forward described by sdpPath or a test source
+// paced like a live input
+func inputArgs(sdpPath string, src *mixers.TestSource) []string {
+	switch {
+	case src == nil:
+		return []string{"-protocol_whitelist", "file,udp,rtp", "-i", sdpPath}
+	case src.Kind == mixers.TestSourceFile:
+		return []string{"-re", "-stream_loop", "-1", "-i", src.File}
+	default:
+		frequency := src.Frequency
+		if frequency <= 0 {
+			frequency = defaultTestFrequency
+		}
+		return []string{"-re", "-f", "lavfi", "-i", fmt.Sprintf("sine=frequency=%d:sample_rate=48000", frequency)}
+	}
+}
+
+// metadataArgs reads the timed ID3 stream of the active speaker from fd 3, as input number
+// input, and copies it next to the audio. The input is read right away with nothing to probe
+func metadataArgs(linked bool, input int) []string {
+	audio := "0:a"
+	if linked {
+		audio = "[aout]"
+	}
+	return []string{
+		"-analyzeduration", "0",
+		"-probesize", "4096",
+		"-f", "mpegts",
+		"-i", "pipe:3",
+		"-map", audio,
+		"-map", strconv.Itoa(input) + ":d",
+		"-c:d", "copy",
+	}
+}
+
+// spawnFFmpeg spawns a new FFmpeg process, linkSDPPath is mixed in as a second input when not empty
+// and src replaces the RTP input when set
+func spawnFFmpeg(
+	sdpPath, linkSDPPath string,
+	src *mixers.TestSource,
+	hlsDir string,
+	startNumber int,
+	hls HLSOptions,
+	keyInfoPath string,
+) *exec.Cmd {
+	args := inputArgs(sdpPath, src)
+
+	inputs := 1
+	if linkSDPPath != "" {
+		// keep the room's own input as the clock, the linked room may drop out anytime
+		mix := "[0:a][1:a]amix=inputs=2:duration=first:dropout_transition=0"
+		if hls.SpeakerMetadata {
+			// mapped explicitly next to the metadata
+			mix += "[aout]"
+		}
+		args = append(args,
+			"-protocol_whitelist", "file,udp,rtp",
+			"-i", linkSDPPath,
+			"-filter_complex", mix,
+		)
+		inputs++
+	}
+	if hls.SpeakerMetadata {
+		args = append(args, metadataArgs(linkSDPPath != "", inputs)...)
+	}
+
+	args = append(args,
+		"-c:a", hls.audioEncoder(),
 		"-b:a", "48k",
 		"-ar", "44100",
 		"-ac", "1",
 		"-f", "hls",
-		"-hls_time", "2",
-		"-hls_list_size", "5",
-		"-hls_flags", "delete_segments",
+	)
+	args = append(args, hlsArgs(hls)...)
+	args = append(args,
 		"-hls_start_number_source", "generic",
 		"-start_number", strconv.Itoa(startNumber),
-	}
+	)
 
 	// Add encryption parameters if keyInfoPath is provided
 	if keyInfoPath != "" {
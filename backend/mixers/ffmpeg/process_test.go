@@ -9,7 +9,9 @@ import (
 
 	"github.com/stretchr/testify/suite"
 
+	"github.com/imtaco/audio-rtc-exp/internal/backoff"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/mixers"
 )
 
 type ProcessTestSuite struct {
@@ -58,12 +60,13 @@ func (s *ProcessTestSuite) TestProcessInfo_StartStopWithEcho() {
 		s.hlsDir,
 		s.keyInfoPath,
 		0,
+		HLSOptions{},
 		log.NewNop(),
 	)
 
 	started := make(chan struct{})
 	// Use echo command instead of ffmpeg (exits immediately)
-	processInfo.SpawnFFmpeg = func(_, _ string, _ int, _ string) *exec.Cmd {
+	processInfo.SpawnFFmpeg = func(_, _ string, _ *mixers.TestSource, _ string, _ int, _ HLSOptions, _ string) *exec.Cmd {
 		close(started)
 		return exec.Command("echo", "test")
 	}
@@ -90,12 +93,13 @@ func (s *ProcessTestSuite) TestProcessInfo_StartStopWithSleep() {
 		s.hlsDir,
 		s.keyInfoPath,
 		0,
+		HLSOptions{},
 		log.NewNop(),
 	)
 
 	started := make(chan struct{})
 	// Use sleep command (runs for a while)
-	processInfo.SpawnFFmpeg = func(_, _ string, _ int, _ string) *exec.Cmd {
+	processInfo.SpawnFFmpeg = func(_, _ string, _ *mixers.TestSource, _ string, _ int, _ HLSOptions, _ string) *exec.Cmd {
 		close(started)
 		return exec.Command("sleep", "10")
 	}
@@ -122,6 +126,7 @@ func (s *ProcessTestSuite) TestProcessInfo_StoresCorrectValues() {
 		s.hlsDir,
 		s.keyInfoPath,
 		10,
+		HLSOptions{SegmentDuration: 4 * time.Second, DVRWindow: 1800},
 		log.NewNop(),
 	)
 
@@ -131,6 +136,8 @@ func (s *ProcessTestSuite) TestProcessInfo_StoresCorrectValues() {
 	s.Equal(s.hlsDir, processInfo.hlsDir)
 	s.Equal(s.keyInfoPath, processInfo.keyInfoPath)
 	s.Equal(10, processInfo.initSeq)
+	s.Equal(HLSOptions{SegmentDuration: 4 * time.Second, DVRWindow: 1800}, processInfo.hls)
+	s.Equal(4*time.Second, processInfo.latency.segmentDuration)
 	s.NotNil(processInfo.chanStop)
 	s.NotNil(processInfo.logger)
 }
@@ -143,12 +150,13 @@ func (s *ProcessTestSuite) TestProcessInfo_QuickExitCommands() {
 		s.hlsDir,
 		s.keyInfoPath,
 		0,
+		HLSOptions{},
 		log.NewNop(),
 	)
 
 	started := make(chan struct{})
 	// Use true command (exits successfully immediately)
-	processInfo.SpawnFFmpeg = func(_, _ string, _ int, _ string) *exec.Cmd {
+	processInfo.SpawnFFmpeg = func(_, _ string, _ *mixers.TestSource, _ string, _ int, _ HLSOptions, _ string) *exec.Cmd {
 		close(started)
 		return exec.Command("true")
 	}
@@ -172,12 +180,13 @@ func (s *ProcessTestSuite) TestProcessInfo_FailingCommands() {
 		s.hlsDir,
 		s.keyInfoPath,
 		0,
+		HLSOptions{},
 		log.NewNop(),
 	)
 
 	started := make(chan struct{})
 	// Use false command (exits with failure immediately)
-	processInfo.SpawnFFmpeg = func(_, _ string, _ int, _ string) *exec.Cmd {
+	processInfo.SpawnFFmpeg = func(_, _ string, _ *mixers.TestSource, _ string, _ int, _ HLSOptions, _ string) *exec.Cmd {
 		close(started)
 		return exec.Command("false")
 	}
@@ -192,3 +201,245 @@ func (s *ProcessTestSuite) TestProcessInfo_FailingCommands() {
 
 	processInfo.Stop()
 }
+
+func (s *ProcessTestSuite) TestProcessInfo_StopDuringRespawnDelay() {
+	processInfo := NewProcessInfo(
+		"backoff-room",
+		5012,
+		s.sdpPath,
+		s.hlsDir,
+		s.keyInfoPath,
+		0,
+		HLSOptions{},
+		log.NewNop(),
+	)
+	processInfo.respawnBackoff = backoff.Constant{Interval: time.Hour}
+
+	started := make(chan struct{})
+	processInfo.SpawnFFmpeg = func(_, _ string, _ *mixers.TestSource, _ string, _ int, _ HLSOptions, _ string) *exec.Cmd {
+		close(started)
+		return exec.Command("false")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		processInfo.Run()
+		close(stopped)
+	}()
+	<-started
+
+	processInfo.Stop()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		s.Fail("Run didn't return while waiting to respawn")
+	}
+}
+
+func (s *ProcessTestSuite) TestProcessInfo_SetLinkSDPRestarts() {
+	processInfo := NewProcessInfo(
+		"link-room",
+		5014,
+		s.sdpPath,
+		s.hlsDir,
+		s.keyInfoPath,
+		0,
+		HLSOptions{},
+		log.NewNop(),
+	)
+
+	type spawn struct {
+		linkSDPPath string
+		startNumber int
+		hls         HLSOptions
+	}
+	spawned := make(chan spawn, 2)
+	processInfo.SpawnFFmpeg = func(_, linkSDPPath string, _ *mixers.TestSource, _ string, startNumber int, hls HLSOptions, _ string) *exec.Cmd {
+		spawned <- spawn{linkSDPPath, startNumber, hls}
+		return exec.Command("sleep", "10")
+	}
+
+	processInfo.Start()
+	defer processInfo.Stop()
+
+	select {
+	case sp := <-spawned:
+		s.Empty(sp.linkSDPPath)
+		s.False(sp.hls.resumed)
+	case <-time.After(50 * time.Millisecond):
+		s.Fail("Process didn't start")
+	}
+
+	completedSeq := 41
+	processInfo.curSeq.Store(&completedSeq)
+	processInfo.SetLinkSDP("/tmp/sdp/link-room-link.sdp")
+
+	// respawned right away with the linked input, continuing the playlist
+	select {
+	case sp := <-spawned:
+		s.Equal("/tmp/sdp/link-room-link.sdp", sp.linkSDPPath)
+		s.Equal(42, sp.startNumber)
+		s.True(sp.hls.resumed)
+	case <-time.After(time.Second):
+		s.Fail("Process didn't restart")
+	}
+}
+
+func (s *ProcessTestSuite) TestProcessInfo_RestartCallsRespawnHandler() {
+	processInfo := NewProcessInfo(
+		"respawn-room",
+		5016,
+		s.sdpPath,
+		s.hlsDir,
+		s.keyInfoPath,
+		0,
+		HLSOptions{},
+		log.NewNop(),
+	)
+
+	spawned := make(chan struct{}, 2)
+	processInfo.SpawnFFmpeg = func(_, _ string, _ *mixers.TestSource, _ string, _ int, _ HLSOptions, _ string) *exec.Cmd {
+		spawned <- struct{}{}
+		return exec.Command("sleep", "10")
+	}
+	respawns := make(chan string, 2)
+	processInfo.onRespawn = func(roomID, reason string) {
+		s.Equal("respawn-room", roomID)
+		respawns <- reason
+	}
+
+	processInfo.Start()
+
+	select {
+	case <-spawned:
+	case <-time.After(50 * time.Millisecond):
+		s.Fail("Process didn't start")
+	}
+
+	processInfo.Restart()
+	select {
+	case reason := <-respawns:
+		s.Equal(mixers.RespawnRequested, reason)
+	case <-time.After(time.Second):
+		s.Fail("Respawn handler not called")
+	}
+
+	// stopping is not a respawn
+	processInfo.Stop()
+	select {
+	case reason := <-respawns:
+		s.Failf("Respawn handler called on stop", "reason %s", reason)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (s *ProcessTestSuite) TestSetTestSource() {
+	processInfo := NewProcessInfo(
+		"test-room",
+		5016,
+		s.sdpPath,
+		s.hlsDir,
+		s.keyInfoPath,
+		0,
+		HLSOptions{},
+		log.NewNop(),
+	)
+
+	spawned := make(chan *mixers.TestSource, 2)
+	processInfo.SpawnFFmpeg = func(_, _ string, src *mixers.TestSource, _ string, _ int, _ HLSOptions, _ string) *exec.Cmd {
+		spawned <- src
+		return exec.Command("sleep", "10")
+	}
+
+	processInfo.Start()
+	defer processInfo.Stop()
+
+	select {
+	case src := <-spawned:
+		s.Nil(src)
+	case <-time.After(50 * time.Millisecond):
+		s.Fail("Process didn't start")
+	}
+
+	sine := &mixers.TestSource{Kind: mixers.TestSourceSine, Frequency: 880}
+	processInfo.SetTestSource(sine)
+
+	select {
+	case src := <-spawned:
+		s.Equal(sine, src)
+	case <-time.After(time.Second):
+		s.Fail("Process didn't restart")
+	}
+}
+
+func (s *ProcessTestSuite) TestInputArgs() {
+	s.Equal([]string{"-protocol_whitelist", "file,udp,rtp", "-i", "/tmp/room.sdp"}, inputArgs("/tmp/room.sdp", nil))
+	s.Equal([]string{"-re", "-f", "lavfi", "-i", "sine=frequency=440:sample_rate=48000"},
+		inputArgs("/tmp/room.sdp", &mixers.TestSource{Kind: mixers.TestSourceSine}))
+	s.Equal([]string{"-re", "-stream_loop", "-1", "-i", "/srv/test/tone.wav"},
+		inputArgs("/tmp/room.sdp", &mixers.TestSource{Kind: mixers.TestSourceFile, File: "/srv/test/tone.wav"}))
+}
+
+func (s *ProcessTestSuite) TestHLSArgs() {
+	s.Equal([]string{"-hls_time", "2", "-hls_list_size", "5", "-hls_flags", "delete_segments"}, hlsArgs(HLSOptions{}))
+	s.Equal([]string{"-hls_time", "2", "-hls_list_size", "900", "-hls_flags", "delete_segments+append_list+program_date_time"},
+		hlsArgs(HLSOptions{DVRWindow: 1800}))
+	// rounded up to whole segments, never shorter than the live playlist
+	s.Equal("6", hlsArgs(HLSOptions{DVRWindow: 11})[3])
+	s.Equal("5", hlsArgs(HLSOptions{DVRWindow: 3})[3])
+
+	// room parameters
+	s.Equal([]string{"-hls_time", "4", "-hls_list_size", "8", "-hls_flags", "delete_segments"},
+		hlsArgs(HLSOptions{SegmentDuration: 4 * time.Second, ListSize: 8}))
+	s.Equal("450", hlsArgs(HLSOptions{SegmentDuration: 4 * time.Second, ListSize: 8, DVRWindow: 1800})[3])
+	s.Equal("1.5", hlsArgs(HLSOptions{SegmentDuration: 1500 * time.Millisecond})[1])
+
+	// respawned FFmpeg continues the playlist after a discontinuity
+	s.Equal([]string{"-hls_time", "2", "-hls_list_size", "5", "-hls_flags", "delete_segments+append_list+discont_start"},
+		hlsArgs(HLSOptions{resumed: true}))
+	s.Equal("delete_segments+append_list+program_date_time+discont_start",
+		hlsArgs(HLSOptions{DVRWindow: 1800, resumed: true})[5])
+}
+
+func (s *ProcessTestSuite) TestMetadataArgs() {
+	s.Equal([]string{
+		"-analyzeduration", "0", "-probesize", "4096", "-f", "mpegts", "-i", "pipe:3",
+		"-map", "0:a", "-map", "1:d", "-c:d", "copy",
+	}, metadataArgs(false, 1))
+	args := metadataArgs(true, 2)
+	s.Equal("[aout]", args[9])
+	s.Equal("2:d", args[11])
+}
+
+func (s *ProcessTestSuite) TestProcessInfo_StreamsSpeakerMetadata() {
+	processInfo := NewProcessInfo(
+		"speaker-room",
+		5018,
+		s.sdpPath,
+		s.hlsDir,
+		s.keyInfoPath,
+		0,
+		HLSOptions{SpeakerMetadata: true},
+		log.NewNop(),
+	)
+	processInfo.SetActiveSpeaker("u1", time.Now())
+
+	out := filepath.Join(s.T().TempDir(), "meta.ts")
+	processInfo.SpawnFFmpeg = func(_, _ string, _ *mixers.TestSource, _ string, _ int, hls HLSOptions, _ string) *exec.Cmd {
+		s.True(hls.SpeakerMetadata)
+		// the tables and the tag of the active speaker
+		return exec.Command("sh", "-c", "head -c 564 <&3 > "+out+"; sleep 10")
+	}
+
+	processInfo.Start()
+	defer processInfo.Stop()
+
+	s.Eventually(func() bool {
+		data, err := os.ReadFile(out)
+		return err == nil && len(data) == 3*tsPacketSize
+	}, 2*time.Second, 10*time.Millisecond)
+	data, err := os.ReadFile(out)
+	s.Require().NoError(err)
+	s.Equal(byte(0x47), data[0])
+	s.Contains(string(data[2*tsPacketSize:]), "speaker\x00u1")
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 )
 
 // SDPGenerator generates SDP files for FFmpeg
@@ -21,23 +23,37 @@ func NewSDPGenerator(sdpDir string) *SDPGenerator {
 	}
 }
 
-// Generate creates an SDP file for the given room and RTP port
-func (sg *SDPGenerator) Generate(roomID string, rtpPort int) (string, error) {
+// Generate creates an SDP file for the given room and RTP port, srtp decrypts the input when set
+func (sg *SDPGenerator) Generate(roomID string, rtpPort int, srtp *etcdstate.SRTP) (string, error) {
+	return sg.generate(roomID, roomID, rtpPort, srtp)
+}
+
+// GenerateLink creates the SDP file of the linked room input, forwarded by Janus of the source room
+func (sg *SDPGenerator) GenerateLink(roomID string, rtpPort int, srtp *etcdstate.SRTP) (string, error) {
+	return sg.generate(roomID+"-link", roomID, rtpPort, srtp)
+}
+
+func (sg *SDPGenerator) generate(name, roomID string, rtpPort int, srtp *etcdstate.SRTP) (string, error) {
+	proto, crypto := "RTP/AVP", ""
+	if srtp != nil {
+		proto = "RTP/SAVP"
+		crypto = fmt.Sprintf("a=crypto:1 %s inline:%s\n", srtp.Suite, srtp.Key)
+	}
 	sdpContent := fmt.Sprintf(`v=0
 o=- 0 0 IN IP4 127.0.0.1
 s=Janus AudioBridge Stream - Room %s
 c=IN IP4 0.0.0.0
 t=0 0
-m=audio %d RTP/AVP 100
+m=audio %d %s 100
 a=rtpmap:100 opus/48000/2
-`, roomID, rtpPort)
+%s`, roomID, rtpPort, proto, crypto)
 
 	// Create directory if it doesn't exist
 	if err := os.MkdirAll(sg.sdpDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create SDP directory: %w", err)
 	}
 
-	sdpPath := filepath.Join(sg.sdpDir, fmt.Sprintf("%s.sdp", roomID))
+	sdpPath := filepath.Join(sg.sdpDir, fmt.Sprintf("%s.sdp", name))
 	if err := os.WriteFile(sdpPath, []byte(sdpContent), 0600); err != nil {
 		return "", fmt.Errorf("failed to write SDP file: %w", err)
 	}
@@ -47,7 +63,16 @@ a=rtpmap:100 opus/48000/2
 
 // Delete removes the SDP file for the given room
 func (sg *SDPGenerator) Delete(roomID string) error {
-	sdpPath := filepath.Join(sg.sdpDir, fmt.Sprintf("%s.sdp", roomID))
+	return sg.delete(roomID)
+}
+
+// DeleteLink removes the SDP file of the linked room input
+func (sg *SDPGenerator) DeleteLink(roomID string) error {
+	return sg.delete(roomID + "-link")
+}
+
+func (sg *SDPGenerator) delete(name string) error {
+	sdpPath := filepath.Join(sg.sdpDir, fmt.Sprintf("%s.sdp", name))
 	err := os.Remove(sdpPath)
 	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete SDP file: %w", err)
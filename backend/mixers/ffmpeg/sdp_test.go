@@ -7,6 +7,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 )
 
 func TestNewSDPGenerator(t *testing.T) {
@@ -31,7 +33,7 @@ func TestSDPGenerate(t *testing.T) {
 		roomID := "room1"
 		rtpPort := 5004
 
-		sdpPath, err := sg.Generate(roomID, rtpPort)
+		sdpPath, err := sg.Generate(roomID, rtpPort, nil)
 
 		assert.NoError(t, err)
 		assert.NotEmpty(t, sdpPath)
@@ -53,7 +55,7 @@ func TestSDPGenerate(t *testing.T) {
 		roomID := "room2"
 		rtpPort := 6008
 
-		sdpPath, err := sg.Generate(roomID, rtpPort)
+		sdpPath, err := sg.Generate(roomID, rtpPort, nil)
 
 		assert.NoError(t, err)
 
@@ -63,12 +65,26 @@ func TestSDPGenerate(t *testing.T) {
 		assert.Contains(t, string(content), "m=audio 6008 RTP/AVP 100")
 	})
 
+	t.Run("generate SDP with SRTP crypto", func(t *testing.T) {
+		sg := NewSDPGenerator(tmpDir)
+		srtp := &etcdstate.SRTP{Suite: etcdstate.SRTPSuiteHMAC80, Key: "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5"}
+
+		sdpPath, err := sg.Generate("room-srtp", 5006, srtp)
+		assert.NoError(t, err)
+
+		content, err := os.ReadFile(sdpPath)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "m=audio 5006 RTP/SAVP 100")
+		assert.Contains(t, string(content),
+			"a=crypto:1 AES_CM_128_HMAC_SHA1_80 inline:a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5\n")
+	})
+
 	t.Run("generate creates directory if not exists", func(t *testing.T) {
 		newDir := filepath.Join(tmpDir, "new-sdp-dir")
 		sg := NewSDPGenerator(newDir)
 		roomID := "room3"
 
-		sdpPath, err := sg.Generate(roomID, 5010)
+		sdpPath, err := sg.Generate(roomID, 5010, nil)
 
 		assert.NoError(t, err)
 		assert.FileExists(t, sdpPath)
@@ -79,13 +95,13 @@ func TestSDPGenerate(t *testing.T) {
 		sg := NewSDPGenerator(tmpDir)
 		roomID := "room4"
 
-		sdpPath1, err := sg.Generate(roomID, 5012)
+		sdpPath1, err := sg.Generate(roomID, 5012, nil)
 		assert.NoError(t, err)
 
 		content1, err := os.ReadFile(sdpPath1)
 		assert.NoError(t, err)
 
-		sdpPath2, err := sg.Generate(roomID, 5014)
+		sdpPath2, err := sg.Generate(roomID, 5014, nil)
 		assert.NoError(t, err)
 
 		content2, err := os.ReadFile(sdpPath2)
@@ -100,7 +116,7 @@ func TestSDPGenerate(t *testing.T) {
 		roomID := "format-test"
 		rtpPort := 5016
 
-		sdpPath, err := sg.Generate(roomID, rtpPort)
+		sdpPath, err := sg.Generate(roomID, rtpPort, nil)
 		assert.NoError(t, err)
 
 		content, err := os.ReadFile(sdpPath)
@@ -127,7 +143,7 @@ func TestSDPDelete(t *testing.T) {
 		sg := NewSDPGenerator(tmpDir)
 		roomID := "room1"
 
-		sdpPath, err := sg.Generate(roomID, 5004)
+		sdpPath, err := sg.Generate(roomID, 5004, nil)
 		assert.NoError(t, err)
 		assert.FileExists(t, sdpPath)
 
@@ -150,7 +166,7 @@ func TestSDPDelete(t *testing.T) {
 
 		rooms := []string{"room1", "room2", "room3"}
 		for _, roomID := range rooms {
-			_, err := sg.Generate(roomID, 5004)
+			_, err := sg.Generate(roomID, 5004, nil)
 			assert.NoError(t, err)
 		}
 
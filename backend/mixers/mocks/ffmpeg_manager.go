@@ -13,6 +13,8 @@ import (
 	reflect "reflect"
 	time "time"
 
+	etcdstate "github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	mixers "github.com/imtaco/audio-rtc-exp/mixers"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -40,18 +42,194 @@ func (m *MockFFmpegManager) EXPECT() *MockFFmpegManagerMockRecorder {
 	return m.recorder
 }
 
+// Capabilities mocks base method.
+func (m *MockFFmpegManager) Capabilities() *mixers.Capabilities {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Capabilities")
+	ret0, _ := ret[0].(*mixers.Capabilities)
+	return ret0
+}
+
+// Capabilities indicates an expected call of Capabilities.
+func (mr *MockFFmpegManagerMockRecorder) Capabilities() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Capabilities", reflect.TypeOf((*MockFFmpegManager)(nil).Capabilities))
+}
+
+// EnableSpeakerMetadata mocks base method.
+func (m *MockFFmpegManager) EnableSpeakerMetadata() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "EnableSpeakerMetadata")
+}
+
+// EnableSpeakerMetadata indicates an expected call of EnableSpeakerMetadata.
+func (mr *MockFFmpegManagerMockRecorder) EnableSpeakerMetadata() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableSpeakerMetadata", reflect.TypeOf((*MockFFmpegManager)(nil).EnableSpeakerMetadata))
+}
+
+// Latency mocks base method.
+func (m *MockFFmpegManager) Latency(roomID string) (mixers.Latency, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Latency", roomID)
+	ret0, _ := ret[0].(mixers.Latency)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Latency indicates an expected call of Latency.
+func (mr *MockFFmpegManagerMockRecorder) Latency(roomID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Latency", reflect.TypeOf((*MockFFmpegManager)(nil).Latency), roomID)
+}
+
+// Logs mocks base method.
+func (m *MockFFmpegManager) Logs(roomID string, tail int) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logs", roomID, tail)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Logs indicates an expected call of Logs.
+func (mr *MockFFmpegManagerMockRecorder) Logs(roomID, tail any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logs", reflect.TypeOf((*MockFFmpegManager)(nil).Logs), roomID, tail)
+}
+
+// MarkerReceived mocks base method.
+func (m *MockFFmpegManager) MarkerReceived(roomID string, sentAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkerReceived", roomID, sentAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkerReceived indicates an expected call of MarkerReceived.
+func (mr *MockFFmpegManagerMockRecorder) MarkerReceived(roomID, sentAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkerReceived", reflect.TypeOf((*MockFFmpegManager)(nil).MarkerReceived), roomID, sentAt)
+}
+
+// OnRespawn mocks base method.
+func (m *MockFFmpegManager) OnRespawn(handler func(string, string)) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnRespawn", handler)
+}
+
+// OnRespawn indicates an expected call of OnRespawn.
+func (mr *MockFFmpegManagerMockRecorder) OnRespawn(handler any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnRespawn", reflect.TypeOf((*MockFFmpegManager)(nil).OnRespawn), handler)
+}
+
+// Restart mocks base method.
+func (m *MockFFmpegManager) Restart(roomID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restart", roomID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restart indicates an expected call of Restart.
+func (mr *MockFFmpegManagerMockRecorder) Restart(roomID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restart", reflect.TypeOf((*MockFFmpegManager)(nil).Restart), roomID)
+}
+
+// SetActiveSpeaker mocks base method.
+func (m *MockFFmpegManager) SetActiveSpeaker(roomID, userID string, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetActiveSpeaker", roomID, userID, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetActiveSpeaker indicates an expected call of SetActiveSpeaker.
+func (mr *MockFFmpegManagerMockRecorder) SetActiveSpeaker(roomID, userID, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetActiveSpeaker", reflect.TypeOf((*MockFFmpegManager)(nil).SetActiveSpeaker), roomID, userID, at)
+}
+
+// SetCapabilities mocks base method.
+func (m *MockFFmpegManager) SetCapabilities(caps *mixers.Capabilities) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetCapabilities", caps)
+}
+
+// SetCapabilities indicates an expected call of SetCapabilities.
+func (mr *MockFFmpegManagerMockRecorder) SetCapabilities(caps any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCapabilities", reflect.TypeOf((*MockFFmpegManager)(nil).SetCapabilities), caps)
+}
+
+// SetHLSDefaults mocks base method.
+func (m *MockFFmpegManager) SetHLSDefaults(params *etcdstate.HLSParams) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetHLSDefaults", params)
+}
+
+// SetHLSDefaults indicates an expected call of SetHLSDefaults.
+func (mr *MockFFmpegManagerMockRecorder) SetHLSDefaults(params any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetHLSDefaults", reflect.TypeOf((*MockFFmpegManager)(nil).SetHLSDefaults), params)
+}
+
+// SetLink mocks base method.
+func (m *MockFFmpegManager) SetLink(roomID string, rtpPort int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLink", roomID, rtpPort)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLink indicates an expected call of SetLink.
+func (mr *MockFFmpegManagerMockRecorder) SetLink(roomID, rtpPort any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLink", reflect.TypeOf((*MockFFmpegManager)(nil).SetLink), roomID, rtpPort)
+}
+
+// SetPublishedAt mocks base method.
+func (m *MockFFmpegManager) SetPublishedAt(roomID string, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPublishedAt", roomID, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPublishedAt indicates an expected call of SetPublishedAt.
+func (mr *MockFFmpegManagerMockRecorder) SetPublishedAt(roomID, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPublishedAt", reflect.TypeOf((*MockFFmpegManager)(nil).SetPublishedAt), roomID, at)
+}
+
+// SetTestSource mocks base method.
+func (m *MockFFmpegManager) SetTestSource(roomID string, src *mixers.TestSource) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTestSource", roomID, src)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTestSource indicates an expected call of SetTestSource.
+func (mr *MockFFmpegManagerMockRecorder) SetTestSource(roomID, src any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTestSource", reflect.TypeOf((*MockFFmpegManager)(nil).SetTestSource), roomID, src)
+}
+
 // StartFFmpeg mocks base method.
-func (m *MockFFmpegManager) StartFFmpeg(roomID string, rtpPort int, createdAt time.Time, nonce string) error {
+func (m *MockFFmpegManager) StartFFmpeg(roomID string, rtpPort int, createdAt time.Time, nonce string, dvrWindow int, hls *etcdstate.HLSParams, srtp *etcdstate.SRTP) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "StartFFmpeg", roomID, rtpPort, createdAt, nonce)
+	ret := m.ctrl.Call(m, "StartFFmpeg", roomID, rtpPort, createdAt, nonce, dvrWindow, hls, srtp)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // StartFFmpeg indicates an expected call of StartFFmpeg.
-func (mr *MockFFmpegManagerMockRecorder) StartFFmpeg(roomID, rtpPort, createdAt, nonce any) *gomock.Call {
+func (mr *MockFFmpegManagerMockRecorder) StartFFmpeg(roomID, rtpPort, createdAt, nonce, dvrWindow, hls, srtp any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartFFmpeg", reflect.TypeOf((*MockFFmpegManager)(nil).StartFFmpeg), roomID, rtpPort, createdAt, nonce)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartFFmpeg", reflect.TypeOf((*MockFFmpegManager)(nil).StartFFmpeg), roomID, rtpPort, createdAt, nonce, dvrWindow, hls, srtp)
 }
 
 // Stop mocks base method.
@@ -0,0 +1,122 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/validation"
+	"github.com/imtaco/audio-rtc-exp/mixers"
+)
+
+// DebugConfig enables the debug endpoints of mixers, keep them off production mixers
+type DebugConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// TestSourceDir holds the audio files test sources may loop, empty allows sine sources only
+	TestSourceDir string `mapstructure:"test_source_dir"`
+}
+
+func SetupDebug(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), false)
+	v.SetDefault(p("test_source_dir"), "")
+}
+
+// setTestSource replaces the RTP input of a room running on this mixer by a test source,
+// so HLS packaging, encryption and key serving can be checked without Janus and anchors
+func (r *Router) setTestSource(c *gin.Context) {
+	var uri TestSourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+	var req SetTestSourceBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	src := &mixers.TestSource{Kind: req.Kind, Frequency: req.Frequency}
+	if req.Kind == mixers.TestSourceFile {
+		path, ok := r.testSourceFile(req.File)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Test source file not found: " + req.File,
+			})
+			return
+		}
+		src.File = path
+	}
+
+	if err := r.ffmpegMgr.SetTestSource(uri.RoomID, src); err != nil {
+		r.logger.Warn("Failed to set test source", log.String("roomId", uri.RoomID), log.Error(err))
+		status := http.StatusNotFound
+		if errors.Is(err, mixers.ErrUnsupported) {
+			status = http.StatusNotImplemented
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"roomId":  uri.RoomID,
+		"source":  src,
+	})
+}
+
+// deleteTestSource restores the RTP input of a room
+func (r *Router) deleteTestSource(c *gin.Context) {
+	var uri TestSourceURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	if err := r.ffmpegMgr.SetTestSource(uri.RoomID, nil); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"roomId":  uri.RoomID,
+	})
+}
+
+// testSourceFile resolves name in the test source directory, FFmpeg must not read other files
+func (r *Router) testSourceFile(name string) (string, bool) {
+	if r.debug.TestSourceDir == "" || !filepath.IsLocal(name) {
+		return "", false
+	}
+	path := filepath.Join(r.debug.TestSourceDir, name)
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	return path, true
+}
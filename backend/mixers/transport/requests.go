@@ -0,0 +1,29 @@
+package transport
+
+// TestSourceURI represents the URI parameters for test source operations
+type TestSourceURI struct {
+	// RoomID: 3-32 characters (letters, numbers, hyphens, underscores) - required
+	RoomID string `uri:"roomId" binding:"required,roomid"`
+}
+
+// SetTestSourceBody represents the request body for replacing the input of a room by a test source
+type SetTestSourceBody struct {
+	// Kind: sine or file - required
+	Kind string `json:"kind" binding:"required,oneof=sine file"`
+	// Frequency: tone of sine sources in Hz (optional, defaults to 440)
+	Frequency int `json:"frequency" binding:"omitempty,min=20,max=20000"`
+	// File: path of the looped file relative to the test source directory, required for file sources
+	File string `json:"file" binding:"required_if=Kind file,max=256"`
+}
+
+// FFmpegLogsURI represents the URI parameters for reading the FFmpeg logs of a room
+type FFmpegLogsURI struct {
+	// RoomID: 3-32 characters (letters, numbers, hyphens, underscores) - required
+	RoomID string `uri:"roomId" binding:"required,roomid"`
+}
+
+// FFmpegLogsQuery represents the query parameters for reading the FFmpeg logs of a room
+type FFmpegLogsQuery struct {
+	// Tail: last lines returned, 1-5000 (optional, defaults to 200)
+	Tail int `form:"tail" binding:"min=1,max=5000"`
+}
@@ -5,29 +5,44 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
+	"github.com/imtaco/audio-rtc-exp/internal/httputil"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/svcauth"
+	"github.com/imtaco/audio-rtc-exp/internal/validation"
+	"github.com/imtaco/audio-rtc-exp/mixers"
 )
 
+// defaultLogTail is the FFmpeg log lines returned without tail
+const defaultLogTail = 200
+
 type Router struct {
-	mixerID string
-	engine  *gin.Engine
-	logger  *log.Logger
+	mixerID   string
+	ffmpegMgr mixers.FFmpegManager
+	debug     *DebugConfig // nil disables the debug endpoints
+	auth      *svcauth.Authenticator
+	engine    *gin.Engine
+	logger    *log.Logger
 }
 
-func NewRouter(mixerID string, logger *log.Logger) *Router {
-	gin.SetMode(gin.ReleaseMode)
-	engine := gin.New()
-	engine.Use(gin.Recovery())
-
-	// Add OpenTelemetry middleware for automatic HTTP tracing
-	engine.Use(otelgin.Middleware("mixer-service"))
+// NewRouter creates the router, routes other than health are authorized by auth, nil leaves
+// them open
+func NewRouter(
+	mixerID string,
+	ffmpegMgr mixers.FFmpegManager,
+	debug *DebugConfig,
+	auth *svcauth.Authenticator,
+	logger *log.Logger,
+) *Router {
+	engine := httputil.NewEngine("mixer-service", logger)
 
 	r := &Router{
-		mixerID: mixerID,
-		engine:  engine,
-		logger:  logger,
+		mixerID:   mixerID,
+		ffmpegMgr: ffmpegMgr,
+		debug:     debug,
+		auth:      auth,
+		engine:    engine,
+		logger:    logger,
 	}
 
 	r.setupRoutes()
@@ -41,6 +56,13 @@ func (r *Router) Handler() http.Handler {
 func (r *Router) setupRoutes() {
 	// Health check
 	r.engine.GET("/health", r.healthCheck)
+	r.engine.GET("/rooms/:roomId/ffmpeg/logs", r.auth.Require(svcauth.ScopeRead), r.getFFmpegLogs)
+
+	if r.debug != nil && r.debug.Enabled {
+		debug := r.engine.Group("/debug", r.auth.Require(svcauth.ScopeDebug))
+		debug.PUT("/rooms/:roomId/test-source", r.setTestSource)
+		debug.DELETE("/rooms/:roomId/test-source", r.deleteTestSource)
+	}
 }
 
 func (r *Router) healthCheck(c *gin.Context) {
@@ -51,3 +73,40 @@ func (r *Router) healthCheck(c *gin.Context) {
 		"timestamp": time.Now(),
 	})
 }
+
+// getFFmpegLogs returns the last stderr lines of FFmpeg of a room running on this mixer
+func (r *Router) getFFmpegLogs(c *gin.Context) {
+	var uri FFmpegLogsURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+	query := FFmpegLogsQuery{Tail: defaultLogTail}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	lines, err := r.ffmpegMgr.Logs(uri.RoomID, query.Tail)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"roomId":  uri.RoomID,
+		"lines":   lines,
+	})
+}
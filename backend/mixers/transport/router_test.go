@@ -0,0 +1,150 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/svcauth"
+	"github.com/imtaco/audio-rtc-exp/mixers"
+	"github.com/imtaco/audio-rtc-exp/mixers/mocks"
+)
+
+func setupRouter(t *testing.T, debug *DebugConfig) (*Router, *mocks.MockFFmpegManager) {
+	gin.SetMode(gin.TestMode)
+
+	ffmpegMgr := mocks.NewMockFFmpegManager(gomock.NewController(t))
+	return NewRouter("mixer1", ffmpegMgr, debug, nil, log.NewTest(t)), ffmpegMgr
+}
+
+func serve(router *Router, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	router.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestTestSource(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		router, _ := setupRouter(t, &DebugConfig{})
+
+		w := serve(router, http.MethodPut, "/debug/rooms/room-1/test-source", `{"kind":"sine"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("sine", func(t *testing.T) {
+		router, ffmpegMgr := setupRouter(t, &DebugConfig{Enabled: true})
+		ffmpegMgr.EXPECT().
+			SetTestSource("room-1", &mixers.TestSource{Kind: mixers.TestSourceSine, Frequency: 880}).
+			Return(nil)
+
+		w := serve(router, http.MethodPut, "/debug/rooms/room-1/test-source", `{"kind":"sine","frequency":880}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("file in test source dir", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "tone.wav"), []byte("RIFF"), 0o600))
+		router, ffmpegMgr := setupRouter(t, &DebugConfig{Enabled: true, TestSourceDir: dir})
+		ffmpegMgr.EXPECT().
+			SetTestSource("room-1", &mixers.TestSource{Kind: mixers.TestSourceFile, File: filepath.Join(dir, "tone.wav")}).
+			Return(nil)
+
+		w := serve(router, http.MethodPut, "/debug/rooms/room-1/test-source", `{"kind":"file","file":"tone.wav"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// files outside the directory are never handed to FFmpeg
+		w = serve(router, http.MethodPut, "/debug/rooms/room-1/test-source", `{"kind":"file","file":"../etc/passwd"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = serve(router, http.MethodPut, "/debug/rooms/room-1/test-source", `{"kind":"file","file":"missing.wav"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = serve(router, http.MethodPut, "/debug/rooms/room-1/test-source", `{"kind":"file"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("file without test source dir", func(t *testing.T) {
+		router, _ := setupRouter(t, &DebugConfig{Enabled: true})
+
+		w := serve(router, http.MethodPut, "/debug/rooms/room-1/test-source", `{"kind":"file","file":"tone.wav"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("room not on this mixer", func(t *testing.T) {
+		router, ffmpegMgr := setupRouter(t, &DebugConfig{Enabled: true})
+		ffmpegMgr.EXPECT().SetTestSource("room-1", gomock.Any()).Return(errors.New("no FFmpeg process found for room room-1"))
+
+		w := serve(router, http.MethodPut, "/debug/rooms/room-1/test-source", `{"kind":"sine"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("restore RTP input", func(t *testing.T) {
+		router, ffmpegMgr := setupRouter(t, &DebugConfig{Enabled: true})
+		ffmpegMgr.EXPECT().SetTestSource("room-1", nil).Return(nil)
+
+		w := serve(router, http.MethodDelete, "/debug/rooms/room-1/test-source", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestFFmpegLogs(t *testing.T) {
+	t.Run("default tail", func(t *testing.T) {
+		router, ffmpegMgr := setupRouter(t, nil)
+		ffmpegMgr.EXPECT().Logs("room-1", defaultLogTail).Return([]string{"Input #0, rtp"}, nil)
+
+		w := serve(router, http.MethodGet, "/rooms/room-1/ffmpeg/logs", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"success":true,"roomId":"room-1","lines":["Input #0, rtp"]}`, w.Body.String())
+	})
+
+	t.Run("tail", func(t *testing.T) {
+		router, ffmpegMgr := setupRouter(t, nil)
+		ffmpegMgr.EXPECT().Logs("room-1", 20).Return([]string{}, nil)
+
+		w := serve(router, http.MethodGet, "/rooms/room-1/ffmpeg/logs?tail=20", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("invalid tail", func(t *testing.T) {
+		router, _ := setupRouter(t, nil)
+
+		w := serve(router, http.MethodGet, "/rooms/room-1/ffmpeg/logs?tail=0", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = serve(router, http.MethodGet, "/rooms/room-1/ffmpeg/logs?tail=9999", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("room not on this mixer", func(t *testing.T) {
+		router, ffmpegMgr := setupRouter(t, nil)
+		ffmpegMgr.EXPECT().Logs("room-1", defaultLogTail).Return(nil, errors.New("no FFmpeg process found for room room-1"))
+
+		w := serve(router, http.MethodGet, "/rooms/room-1/ffmpeg/logs", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ffmpegMgr := mocks.NewMockFFmpegManager(gomock.NewController(t))
+	auth := svcauth.NewAuthenticator(&svcauth.Config{Enabled: true, ServiceSecret: "svc-secret"}, log.NewTest(t))
+	router := NewRouter("mixer1", ffmpegMgr, &DebugConfig{Enabled: true}, auth, log.NewTest(t))
+
+	// probes stay open
+	w := serve(router, http.MethodGet, "/health", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(router, http.MethodGet, "/rooms/room-1/ffmpeg/logs", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = serve(router, http.MethodPut, "/debug/rooms/room-1/test-source", `{"kind":"sine"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = serve(router, http.MethodDelete, "/debug/rooms/room-1/test-source", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -1,13 +1,137 @@
 package mixers
 
-import "time"
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+)
 
 type FFmpegManager interface {
-	StartFFmpeg(roomID string, rtpPort int, createdAt time.Time, nonce string) error
+	// StartFFmpeg starts mixing the room, dvrWindow is the seconds of segments kept for catch-up,
+	// hls overrides the HLS defaults for the room and srtp decrypts the RTP inputs when set
+	StartFFmpeg(
+		roomID string,
+		rtpPort int,
+		createdAt time.Time,
+		nonce string,
+		dvrWindow int,
+		hls *etcdstate.HLSParams,
+		srtp *etcdstate.SRTP,
+	) error
 	StopFFmpeg(roomID string) error
+	// Restart kills FFmpeg of the room, it is respawned right away continuing the playlist
+	Restart(roomID string) error
+	// SetLink mixes a linked room input received on rtpPort into the room, 0 removes it
+	SetLink(roomID string, rtpPort int) error
+	// SetPublishedAt sets when Janus started forwarding the room, anchoring latency estimation
+	SetPublishedAt(roomID string, at time.Time) error
+	// MarkerReceived records a latency marker of a room sent by Janus at sentAt
+	MarkerReceived(roomID string, sentAt time.Time) error
+	// SetActiveSpeaker records the active speaker of a room as of at, empty while nobody talks
+	SetActiveSpeaker(roomID, userID string, at time.Time) error
+	// Latency returns the publish to HLS segment latency of a room, false until measured
+	Latency(roomID string) (Latency, bool)
+	// Logs returns the last tail stderr lines of FFmpeg of a room, oldest first
+	Logs(roomID string, tail int) ([]string, error)
+	// SetTestSource replaces the RTP input of a running room by src and restarts FFmpeg,
+	// nil restores the RTP input
+	SetTestSource(roomID string, src *TestSource) error
+	// SetHLSDefaults replaces the HLS defaults of rooms started from now on, nil restores the built-in ones
+	SetHLSDefaults(params *etcdstate.HLSParams)
+	// EnableSpeakerMetadata tags the HLS segments of rooms started from now on with their
+	// active speaker as timed ID3
+	EnableSpeakerMetadata()
+	// OnRespawn sets the handler called whenever FFmpeg of a room is respawned, see RespawnRequested
+	OnRespawn(handler func(roomID, reason string))
+	// SetCapabilities sets what the FFmpeg binary supports, it must be set before rooms are started.
+	// Rooms use the audio encoder of caps and features missing from it are refused
+	SetCapabilities(caps *Capabilities)
+	// Capabilities returns what the FFmpeg binary supports, nil when not probed
+	Capabilities() *Capabilities
 	Stop() error
 }
 
+// Reasons FFmpeg of a room is respawned for
+const (
+	// RespawnRequested follows a restart on purpose, e.g. a link or test source change
+	RespawnRequested = "requested"
+	// RespawnExited follows FFmpeg exiting on its own
+	RespawnExited = "exited"
+)
+
+// ErrUnsupported is returned for what the FFmpeg binary of the mixer lacks a feature for
+var ErrUnsupported = errors.New("not supported by FFmpeg of the mixer")
+
+// Features of the FFmpeg binary rooms need, reported in the mixer heartbeat
+const (
+	FeatureAAC             = "aac"              // an AAC encoder, native or libfdk_aac
+	FeatureHLS             = "hls"              // the HLS muxer
+	FeatureHLSEncryption   = "hls_encryption"   // AES-128 segments with a key info file
+	FeatureRTP             = "rtp"              // RTP input described by an SDP file
+	FeatureSRTP            = "srtp"             // SRTP decryption of the RTP input
+	FeatureLink            = "link"             // mixing a linked room in
+	FeatureSpeakerMetadata = "speaker_metadata" // timed ID3 read from an MPEG-TS pipe
+	FeatureTestSource      = "test_source"      // sine tone test sources
+)
+
+// Capabilities is what the FFmpeg binary of the mixer supports, probed at startup
+type Capabilities struct {
+	Version string
+	// AudioEncoder encodes the HLS audio, the first supported of aac and libfdk_aac
+	AudioEncoder string
+	Features     []string
+}
+
+// Has reports whether feature is supported, everything is assumed supported when not probed
+func (c *Capabilities) Has(feature string) bool {
+	return c == nil || slices.Contains(c.Features, feature)
+}
+
+// Missing returns the features not supported out of features
+func (c *Capabilities) Missing(features ...string) []string {
+	var missing []string
+	for _, feature := range features {
+		if !c.Has(feature) {
+			missing = append(missing, feature)
+		}
+	}
+	return missing
+}
+
 type PortManager interface {
 	GetFreeRTPPort() (int, error)
 }
+
+// Test source kinds
+const (
+	TestSourceSine = "sine"
+	TestSourceFile = "file"
+)
+
+// TestSource is a local audio input mixed instead of the RTP forward of Janus, to check HLS
+// packaging, encryption and key serving without Janus and anchors
+type TestSource struct {
+	Kind string `json:"kind"`
+	// Frequency of the sine tone in Hz
+	Frequency int `json:"frequency,omitempty"`
+	// File is the path of an audio file looped as input
+	File string `json:"file,omitempty"`
+}
+
+// Latency is the delay from audio published at Janus to the HLS segment holding it being written
+type Latency struct {
+	Current time.Duration
+	Average time.Duration
+}
+
+// EventRoomHLSLive is committed to the room outbox once the first playable HLS segment of a
+// room run is written, the rooms service relays it to the room event stream
+const EventRoomHLSLive = "roomHlsLive"
+
+type RoomHLSLive struct {
+	RoomID  string    `json:"roomId"`
+	MixerID string    `json:"mixerId"`
+	LiveAt  time.Time `json:"liveAt"`
+}
@@ -0,0 +1,174 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// ErrBudgetExceeded is returned when starting a room would exceed the CPU budget of the mixer
+var ErrBudgetExceeded = errors.New("mixer CPU budget exceeded")
+
+const budgetEpsilon = 1e-9
+
+// BudgetConfig is the CPU budget of the mixer, in cores. The cost of a room is estimated from
+// what its FFmpeg does, a CPU of 0 disables budgeting and only the capacity limits rooms
+type BudgetConfig struct {
+	CPU        float64 `mapstructure:"cpu"`
+	RoomCost   float64 `mapstructure:"room_cost"`   // a room mixing and encoding mono
+	StereoCost float64 `mapstructure:"stereo_cost"` // added for stereo rooms
+	LinkCost   float64 `mapstructure:"link_cost"`   // added while a linked room is mixed in
+}
+
+// SetupBudget sets the defaults of the CPU budget
+func SetupBudget(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("cpu"), 0.0)
+	v.SetDefault(p("room_cost"), 0.1)
+	v.SetDefault(p("stereo_cost"), 0.05)
+	v.SetDefault(p("link_cost"), 0.05)
+}
+
+// CPUBudget admits rooms while their estimated cost fits the budget. It is saturated once
+// another mono room would not fit, placement should stop picking the mixer then
+type CPUBudget struct {
+	cfg         BudgetConfig
+	mixerID     string
+	onSaturated func(saturated bool)
+	logger      *log.Logger
+
+	mu        sync.Mutex
+	costs     map[string]float64
+	used      float64
+	saturated bool
+}
+
+// NewCPUBudget returns the budget, nil when disabled. onSaturated is called on each change of
+// saturation by the call of Admit or Release causing it
+func NewCPUBudget(cfg *BudgetConfig, mixerID string, onSaturated func(saturated bool), logger *log.Logger) *CPUBudget {
+	if cfg.CPU <= 0 {
+		return nil
+	}
+	return &CPUBudget{
+		cfg:         *cfg,
+		mixerID:     mixerID,
+		onSaturated: onSaturated,
+		costs:       make(map[string]float64),
+		logger:      logger,
+	}
+}
+
+// Cost estimates the CPU a room takes
+func (b *CPUBudget) Cost(meta *etcdstate.Meta, linked bool) float64 {
+	if b == nil {
+		return 0
+	}
+	cost := b.cfg.RoomCost
+	if stereo := meta.GetAudio().GetStereo(); stereo != nil && *stereo {
+		cost += b.cfg.StereoCost
+	}
+	if linked {
+		cost += b.cfg.LinkCost
+	}
+	return cost
+}
+
+// Admit reserves the cost of a room, failing with ErrBudgetExceeded when it does not fit.
+// A room already admitted is updated to the new cost, which is never refused. A nil budget
+// admits every room
+func (b *CPUBudget) Admit(ctx context.Context, roomID string, cost float64) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	prev, admitted := b.costs[roomID]
+	if !admitted && !b.fits(cost) {
+		used := b.used
+		b.mu.Unlock()
+
+		roomsRefused.Add(ctx, 1, metric.WithAttributes(attribute.String("mixer.id", b.mixerID)))
+		b.logger.Warn("Refusing room over the CPU budget",
+			log.String("roomId", roomID),
+			log.Float64("cost", cost),
+			log.Float64("used", used),
+			log.Float64("budget", b.cfg.CPU))
+		return ErrBudgetExceeded
+	}
+	b.costs[roomID] = cost
+	changed := b.add(ctx, cost-prev)
+	b.mu.Unlock()
+
+	b.notify(changed)
+	return nil
+}
+
+// Release frees the cost of a room
+func (b *CPUBudget) Release(ctx context.Context, roomID string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	cost, ok := b.costs[roomID]
+	delete(b.costs, roomID)
+	changed := ok && b.add(ctx, -cost)
+	b.mu.Unlock()
+
+	b.notify(changed)
+}
+
+// Used returns the CPU reserved by the admitted rooms
+func (b *CPUBudget) Used() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Saturated reports whether a mono room no longer fits
+func (b *CPUBudget) Saturated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.saturated
+}
+
+// add changes the CPU used, reporting whether saturation changed
+func (b *CPUBudget) add(ctx context.Context, delta float64) bool {
+	if delta == 0 {
+		return false
+	}
+	b.used += delta
+	if len(b.costs) == 0 {
+		// no float drift left behind once empty
+		b.used = 0
+	}
+	budgetUsed.Add(ctx, delta, metric.WithAttributes(attribute.String("mixer.id", b.mixerID)))
+
+	saturated := !b.fits(b.cfg.RoomCost)
+	if saturated == b.saturated {
+		return false
+	}
+	b.saturated = saturated
+	b.logger.Info("CPU budget saturation changed",
+		log.Bool("saturated", saturated),
+		log.Float64("used", b.used),
+		log.Float64("budget", b.cfg.CPU))
+	return true
+}
+
+// fits reports whether cost fits the budget left, tolerating the rounding of summed costs
+func (b *CPUBudget) fits(cost float64) bool {
+	return b.used+cost <= b.cfg.CPU+budgetEpsilon
+}
+
+func (b *CPUBudget) notify(changed bool) {
+	if changed && b.onSaturated != nil {
+		b.onSaturated(b.Saturated())
+	}
+}
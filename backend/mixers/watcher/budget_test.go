@@ -0,0 +1,62 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+func TestCPUBudget(t *testing.T) {
+	ctx := context.Background()
+	cfg := &BudgetConfig{CPU: 0.3, RoomCost: 0.1, StereoCost: 0.05, LinkCost: 0.05}
+
+	t.Run("disabled without CPU", func(t *testing.T) {
+		b := NewCPUBudget(&BudgetConfig{RoomCost: 0.1}, "mixer-1", nil, log.NewNop())
+		assert.Nil(t, b)
+		// a nil budget admits every room
+		assert.NoError(t, b.Admit(ctx, "room1", b.Cost(nil, true)))
+		b.Release(ctx, "room1")
+	})
+
+	t.Run("cost of rooms", func(t *testing.T) {
+		b := NewCPUBudget(cfg, "mixer-1", nil, log.NewNop())
+		stereo := true
+
+		assert.InDelta(t, 0.1, b.Cost(nil, false), 1e-9)
+		assert.InDelta(t, 0.15, b.Cost(nil, true), 1e-9)
+		assert.InDelta(t, 0.2, b.Cost(&etcdstate.Meta{Audio: &etcdstate.AudioParams{Stereo: &stereo}}, true), 1e-9)
+	})
+
+	t.Run("refuses rooms over the budget and reports saturation", func(t *testing.T) {
+		var reported []bool
+		b := NewCPUBudget(cfg, "mixer-1", func(saturated bool) {
+			reported = append(reported, saturated)
+		}, log.NewNop())
+
+		require.NoError(t, b.Admit(ctx, "room1", 0.1))
+		require.NoError(t, b.Admit(ctx, "room2", 0.1))
+		assert.False(t, b.Saturated())
+		// summed costs are not refused for rounding
+		require.NoError(t, b.Admit(ctx, "room3", 0.1))
+		assert.True(t, b.Saturated())
+		assert.ErrorIs(t, b.Admit(ctx, "room4", 0.1), ErrBudgetExceeded)
+
+		// admitted rooms are updated over the budget
+		require.NoError(t, b.Admit(ctx, "room1", 0.15))
+		assert.InDelta(t, 0.35, b.Used(), 1e-9)
+
+		b.Release(ctx, "room1")
+		assert.False(t, b.Saturated())
+		b.Release(ctx, "room2")
+		b.Release(ctx, "room3")
+		b.Release(ctx, "unknown")
+		assert.Zero(t, b.Used())
+
+		assert.Equal(t, []bool{true, false}, reported)
+	})
+}
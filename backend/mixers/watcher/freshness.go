@@ -0,0 +1,217 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/outbox"
+	"github.com/imtaco/audio-rtc-exp/mixers"
+)
+
+const playlistName = "stream.m3u8"
+
+// FreshnessWatchdog restarts FFmpeg of rooms whose newest HLS segment stopped advancing,
+// catching FFmpeg hung with its process alive. Stalled rooms are flagged degraded in their
+// mixer data until a segment is written again.
+// Once the playlist and a segment of a room run are written, a roomHlsLive event is committed
+// to the room outbox so clients only show rooms live when playback works. Without a stall
+// timeout the watchdog only commits these events.
+type FreshnessWatchdog struct {
+	roomWatcher  *RoomWatcher
+	hlsDir       string
+	interval     time.Duration
+	stallTimeout time.Duration
+	events       outbox.Writer        // nil disables roomHlsLive events
+	since        map[string]time.Time // roomID -> first seen or last restart, only used by loop
+	live         map[string]time.Time // roomID -> run the live event was committed for, only used by loop
+	cancel       context.CancelFunc
+	stopped      chan struct{}
+	logger       *log.Logger
+}
+
+// NewFreshnessWatchdog creates a new FreshnessWatchdog, stallTimeout is the segment age
+// considered stalled and should span a few segment durations, 0 disables restarts, events
+// receives roomHlsLive events and may be nil
+func NewFreshnessWatchdog(
+	roomWatcher *RoomWatcher,
+	hlsDir string,
+	interval, stallTimeout time.Duration,
+	events outbox.Writer,
+	logger *log.Logger,
+) *FreshnessWatchdog {
+	return &FreshnessWatchdog{
+		roomWatcher:  roomWatcher,
+		hlsDir:       hlsDir,
+		interval:     interval,
+		stallTimeout: stallTimeout,
+		events:       events,
+		since:        make(map[string]time.Time),
+		live:         make(map[string]time.Time),
+		stopped:      make(chan struct{}),
+		logger:       logger,
+	}
+}
+
+// Start starts the periodic check loop
+func (d *FreshnessWatchdog) Start(ctx context.Context) error {
+	d.logger.Info("Starting segment freshness watchdog",
+		log.Duration("interval", d.interval),
+		log.Duration("stallTimeout", d.stallTimeout))
+
+	ctx, d.cancel = context.WithCancel(ctx)
+	go d.loop(ctx)
+	return nil
+}
+
+// Stop stops the check loop
+func (d *FreshnessWatchdog) Stop() {
+	if d.cancel != nil {
+		d.cancel()
+		<-d.stopped
+	}
+	d.logger.Info("Stopped segment freshness watchdog")
+}
+
+func (d *FreshnessWatchdog) loop(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer close(d.stopped)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.check(ctx, time.Now())
+		}
+	}
+}
+
+// check restarts FFmpeg of running rooms without a segment newer than stallTimeout, rooms
+// get stallTimeout from start or restart to write their first segment
+func (d *FreshnessWatchdog) check(ctx context.Context, now time.Time) {
+	active := d.roomWatcher.GetActiveRooms()
+	for roomID := range d.since {
+		if _, ok := active[roomID]; !ok {
+			delete(d.since, roomID)
+		}
+	}
+	for roomID := range d.live {
+		if _, ok := active[roomID]; !ok {
+			delete(d.live, roomID)
+		}
+	}
+
+	attrs := metric.WithAttributes(attribute.String("mixer.id", d.roomWatcher.id))
+	for roomID, room := range active {
+		d.checkLive(ctx, roomID, room, now)
+		if d.stallTimeout <= 0 {
+			continue
+		}
+
+		since, ok := d.since[roomID]
+		if !ok {
+			d.since[roomID] = now
+			continue
+		}
+
+		newest := d.newestSegment(roomID)
+		last := since
+		if newest.After(last) {
+			last = newest
+		}
+		if now.Sub(last) < d.stallTimeout {
+			// degraded until a segment is written after the restart
+			if newest.After(since) && room.Status == roomStatusDegraded {
+				d.logger.Info("Segments resumed", log.String("roomId", roomID))
+				d.setDegraded(ctx, roomID, false)
+			}
+			continue
+		}
+
+		segmentStalls.Add(ctx, 1, attrs)
+		d.logger.Warn("Segments stalled, restarting FFmpeg",
+			log.String("roomId", roomID),
+			log.Time("newestSegment", newest),
+			log.Duration("stallTimeout", d.stallTimeout))
+
+		if err := d.roomWatcher.ffmpegManager.Restart(roomID); err != nil {
+			d.logger.Error("Failed to restart stalled FFmpeg", log.String("roomId", roomID), log.Error(err))
+			continue
+		}
+		d.since[roomID] = now
+		d.setDegraded(ctx, roomID, true)
+	}
+}
+
+func (d *FreshnessWatchdog) setDegraded(ctx context.Context, roomID string, degraded bool) {
+	if err := d.roomWatcher.setDegraded(ctx, roomID, degraded); err != nil {
+		d.logger.Warn("Failed to update degraded state of room",
+			log.String("roomId", roomID),
+			log.Bool("degraded", degraded),
+			log.Error(err))
+	}
+}
+
+// checkLive commits the live event of a room run once its playlist and a segment written
+// after FFmpeg started exist, segments left by a previous run do not count
+func (d *FreshnessWatchdog) checkLive(ctx context.Context, roomID string, room *ActiveRoom, now time.Time) {
+	if d.events == nil {
+		return
+	}
+	if startedAt, ok := d.live[roomID]; ok && startedAt.Equal(room.StartedAt) {
+		return
+	}
+	newest := d.newestSegment(roomID)
+	if newest.IsZero() || newest.Before(room.StartedAt) || !d.hasPlaylist(roomID) {
+		return
+	}
+
+	err := d.events.Commit(ctx, mixers.EventRoomHLSLive, &mixers.RoomHLSLive{
+		RoomID:  roomID,
+		MixerID: d.roomWatcher.id,
+		LiveAt:  now.UTC(),
+	})
+	if err != nil {
+		// retried on the next check
+		d.logger.Warn("Failed to commit HLS live event", log.String("roomId", roomID), log.Error(err))
+		return
+	}
+	d.logger.Info("HLS stream is live", log.String("roomId", roomID))
+	d.live[roomID] = room.StartedAt
+}
+
+func (d *FreshnessWatchdog) hasPlaylist(roomID string) bool {
+	info, err := os.Stat(filepath.Join(d.hlsDir, roomID, playlistName))
+	return err == nil && info.Size() > 0
+}
+
+// newestSegment returns the modification time of the newest segment of a room, zero when none
+func (d *FreshnessWatchdog) newestSegment(roomID string) time.Time {
+	var newest time.Time
+	entries, err := os.ReadDir(filepath.Join(d.hlsDir, roomID))
+	if err != nil {
+		return newest
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "segment_") || !strings.HasSuffix(name, ".ts") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest
+}
@@ -0,0 +1,166 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	outboxmocks "github.com/imtaco/audio-rtc-exp/internal/outbox/mocks"
+	"github.com/imtaco/audio-rtc-exp/mixers"
+)
+
+func (s *RoomWatcherTestSuite) TestFreshnessWatchdog() {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	hlsDir := s.T().TempDir()
+	watchdog := NewFreshnessWatchdog(s.watcher, hlsDir, time.Second, 30*time.Second, nil, log.NewNop())
+
+	writeSegment := func(roomID, name string, at time.Time) {
+		dir := filepath.Join(hlsDir, roomID)
+		s.Require().NoError(os.MkdirAll(dir, 0755))
+		path := filepath.Join(dir, name)
+		s.Require().NoError(os.WriteFile(path, []byte("ts"), 0600))
+		s.Require().NoError(os.Chtimes(path, at, at))
+	}
+	mixerJSON := func(degraded bool, logs []string) string {
+		data, _ := json.Marshal(etcdstate.Mixer{
+			ID:         "mixer-1",
+			IP:         "192.168.1.100",
+			Port:       5004,
+			MarkerPort: 5300,
+			Degraded:   degraded,
+			Logs:       logs,
+		})
+		return string(data)
+	}
+
+	s.watcher.activeRooms.Store("room1", &ActiveRoom{Port: 5004, Status: roomStatusRunning})
+
+	s.Run("gives new rooms time to write segments", func() {
+		watchdog.check(s.ctx, now)
+		watchdog.check(s.ctx, now.Add(20*time.Second))
+	})
+
+	s.Run("keeps rooms writing segments", func() {
+		writeSegment("room1", "segment_001.ts", now.Add(25*time.Second))
+		writeSegment("room1", "stream.m3u8", now.Add(40*time.Second))
+		watchdog.check(s.ctx, now.Add(50*time.Second))
+	})
+
+	s.Run("restarts stalled rooms and flags them degraded", func() {
+		logs := []string{"[udp @ 0x1] bind failed: Address already in use"}
+		s.mockFFmpegMgr.EXPECT().Restart("room1").Return(nil)
+		s.mockFFmpegMgr.EXPECT().Logs("room1", degradedLogLines).Return(logs, nil)
+		s.mockEtcdClient.EXPECT().
+			Put(gomock.Any(), "/rooms/room1/mixer", mixerJSON(true, logs)).
+			Return(nil, nil)
+
+		watchdog.check(s.ctx, now.Add(60*time.Second))
+		s.Equal(roomStatusDegraded, s.watcher.GetActiveRooms()["room1"].Status)
+		s.Equal(logs, s.watcher.GetActiveRooms()["room1"].Logs)
+	})
+
+	s.Run("clears degraded once segments resume", func() {
+		// old segments do not count after the restart
+		watchdog.check(s.ctx, now.Add(70*time.Second))
+
+		writeSegment("room1", "segment_002.ts", now.Add(75*time.Second))
+		s.mockEtcdClient.EXPECT().
+			Put(gomock.Any(), "/rooms/room1/mixer", mixerJSON(false, nil)).
+			Return(nil, nil)
+
+		watchdog.check(s.ctx, now.Add(80*time.Second))
+		s.Equal(roomStatusRunning, s.watcher.GetActiveRooms()["room1"].Status)
+	})
+
+	s.Run("forgets stopped rooms", func() {
+		s.watcher.activeRooms.Delete("room1")
+		watchdog.check(s.ctx, now.Add(200*time.Second))
+		s.Empty(watchdog.since)
+	})
+}
+
+func (s *RoomWatcherTestSuite) TestFreshnessWatchdog_HLSLive() {
+	startedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	hlsDir := s.T().TempDir()
+	events := outboxmocks.NewMockWriter(s.ctrl)
+	watchdog := NewFreshnessWatchdog(s.watcher, hlsDir, time.Second, time.Hour, events, log.NewNop())
+
+	writeFile := func(name string, at time.Time) {
+		dir := filepath.Join(hlsDir, "room1")
+		s.Require().NoError(os.MkdirAll(dir, 0755))
+		path := filepath.Join(dir, name)
+		s.Require().NoError(os.WriteFile(path, []byte("data"), 0600))
+		s.Require().NoError(os.Chtimes(path, at, at))
+	}
+	s.watcher.activeRooms.Store("room1", &ActiveRoom{Port: 5004, Status: roomStatusRunning, StartedAt: startedAt})
+
+	s.Run("ignores segments of a previous run", func() {
+		writeFile("segment_000.ts", startedAt.Add(-time.Minute))
+		writeFile("stream.m3u8", startedAt.Add(-time.Minute))
+		watchdog.check(s.ctx, startedAt.Add(time.Second))
+	})
+
+	s.Run("waits for the playlist", func() {
+		s.Require().NoError(os.Remove(filepath.Join(hlsDir, "room1", "stream.m3u8")))
+		writeFile("segment_001.ts", startedAt.Add(2*time.Second))
+		watchdog.check(s.ctx, startedAt.Add(3*time.Second))
+	})
+
+	s.Run("retries failed commits", func() {
+		writeFile("stream.m3u8", startedAt.Add(2*time.Second))
+		events.EXPECT().
+			Commit(gomock.Any(), mixers.EventRoomHLSLive, gomock.Any()).
+			Return(errors.New("etcd down"))
+		watchdog.check(s.ctx, startedAt.Add(4*time.Second))
+	})
+
+	s.Run("commits the live event once per run", func() {
+		events.EXPECT().
+			Commit(gomock.Any(), mixers.EventRoomHLSLive, &mixers.RoomHLSLive{
+				RoomID:  "room1",
+				MixerID: "mixer-1",
+				LiveAt:  startedAt.Add(5 * time.Second),
+			}).
+			Return(nil)
+		watchdog.check(s.ctx, startedAt.Add(5*time.Second))
+		watchdog.check(s.ctx, startedAt.Add(6*time.Second))
+	})
+
+	s.Run("commits again for a new run", func() {
+		restartedAt := startedAt.Add(time.Minute)
+		s.watcher.activeRooms.Store("room1", &ActiveRoom{Port: 5006, Status: roomStatusRunning, StartedAt: restartedAt})
+		watchdog.check(s.ctx, restartedAt.Add(time.Second))
+
+		writeFile("segment_010.ts", restartedAt.Add(2*time.Second))
+		events.EXPECT().Commit(gomock.Any(), mixers.EventRoomHLSLive, gomock.Any()).Return(nil)
+		watchdog.check(s.ctx, restartedAt.Add(3*time.Second))
+	})
+}
+
+func (s *RoomWatcherTestSuite) TestFreshnessWatchdog_HLSLiveWithoutStallTimeout() {
+	startedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	hlsDir := s.T().TempDir()
+	events := outboxmocks.NewMockWriter(s.ctrl)
+	watchdog := NewFreshnessWatchdog(s.watcher, hlsDir, time.Second, 0, events, log.NewNop())
+
+	dir := filepath.Join(hlsDir, "room1")
+	s.Require().NoError(os.MkdirAll(dir, 0755))
+	for _, name := range []string{"segment_000.ts", "stream.m3u8"} {
+		path := filepath.Join(dir, name)
+		s.Require().NoError(os.WriteFile(path, []byte("data"), 0600))
+		s.Require().NoError(os.Chtimes(path, startedAt.Add(time.Second), startedAt.Add(time.Second)))
+	}
+	s.watcher.activeRooms.Store("room1", &ActiveRoom{Port: 5004, Status: roomStatusRunning, StartedAt: startedAt})
+
+	// segments stopped long ago, yet FFmpeg is not restarted
+	events.EXPECT().Commit(gomock.Any(), mixers.EventRoomHLSLive, gomock.Any()).Return(nil)
+	watchdog.check(s.ctx, startedAt.Add(2*time.Second))
+	watchdog.check(s.ctx, startedAt.Add(time.Hour))
+	s.Empty(watchdog.since)
+}
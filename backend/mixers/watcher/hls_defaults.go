@@ -0,0 +1,145 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/mixers"
+)
+
+// HLSDefaultsWatcher applies the HLS defaults stored at a single etcd key to FFmpeg manager,
+// so key URL and segment parameters change without redeploying mixers. Rooms started
+// afterwards use them, running rooms keep theirs until they are started again.
+type HLSDefaultsWatcher struct {
+	etcdClient    etcd.Watcher
+	key           string
+	ffmpegManager mixers.FFmpegManager
+	retryDelay    time.Duration
+	cancel        context.CancelFunc
+	stopped       chan struct{}
+	logger        *log.Logger
+}
+
+// NewHLSDefaultsWatcher creates a new HLSDefaultsWatcher
+func NewHLSDefaultsWatcher(
+	etcdClient etcd.Watcher,
+	key string,
+	ffmpegManager mixers.FFmpegManager,
+	logger *log.Logger,
+) *HLSDefaultsWatcher {
+	return &HLSDefaultsWatcher{
+		etcdClient:    etcdClient,
+		key:           key,
+		ffmpegManager: ffmpegManager,
+		retryDelay:    time.Second,
+		stopped:       make(chan struct{}),
+		logger:        logger,
+	}
+}
+
+// Start loads the current defaults and watches the key for changes
+func (w *HLSDefaultsWatcher) Start(ctx context.Context) error {
+	w.logger.Info("Starting HLS defaults watcher", log.String("key", w.key))
+
+	rev, err := w.load(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, w.cancel = context.WithCancel(ctx)
+	go w.loop(ctx, rev)
+	return nil
+}
+
+// Stop stops watching the key
+func (w *HLSDefaultsWatcher) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+		<-w.stopped
+	}
+	w.logger.Info("Stopped HLS defaults watcher")
+}
+
+// load applies the current value of the key, returns the revision to watch from
+func (w *HLSDefaultsWatcher) load(ctx context.Context) (int64, error) {
+	resp, err := w.etcdClient.Get(ctx, w.key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get HLS defaults: %w", err)
+	}
+	var value []byte
+	if len(resp.Kvs) > 0 {
+		value = resp.Kvs[0].Value
+	}
+	w.apply(value)
+	return resp.Header.GetRevision(), nil
+}
+
+// apply sets the defaults from a key value, an empty value restores the built-in defaults and
+// an invalid one keeps the current defaults
+func (w *HLSDefaultsWatcher) apply(value []byte) {
+	if len(value) == 0 {
+		w.ffmpegManager.SetHLSDefaults(nil)
+		return
+	}
+	var params etcdstate.HLSParams
+	if err := json.Unmarshal(value, &params); err != nil {
+		w.logger.Error("Invalid HLS defaults, keeping current ones",
+			log.String("key", w.key),
+			log.Error(err))
+		return
+	}
+	w.ffmpegManager.SetHLSDefaults(&params)
+}
+
+func (w *HLSDefaultsWatcher) loop(ctx context.Context, rev int64) {
+	defer close(w.stopped)
+
+	for {
+		rev = w.watch(ctx, rev)
+
+		// watch closed or compacted, reload before watching again
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.retryDelay):
+		}
+		if latest, err := w.load(ctx); err != nil {
+			w.logger.Warn("Failed to reload HLS defaults", log.Error(err))
+		} else {
+			rev = latest
+		}
+	}
+}
+
+// watch applies changes after rev until the watch ends or ctx is done, returns the last seen revision
+func (w *HLSDefaultsWatcher) watch(ctx context.Context, rev int64) int64 {
+	watchCh := w.etcdClient.Watch(ctx, w.key, clientv3.WithRev(rev+1))
+	for {
+		select {
+		case <-ctx.Done():
+			return rev
+		case resp, ok := <-watchCh:
+			if !ok {
+				return rev
+			}
+			if err := resp.Err(); err != nil {
+				w.logger.Warn("HLS defaults watch failed", log.Error(err))
+				return rev
+			}
+			for _, ev := range resp.Events {
+				if ev.Type == clientv3.EventTypeDelete {
+					w.apply(nil)
+				} else {
+					w.apply(ev.Kv.Value)
+				}
+			}
+			rev = resp.Header.Revision
+		}
+	}
+}
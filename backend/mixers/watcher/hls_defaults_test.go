@@ -0,0 +1,114 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	mvccpb "go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/mock/gomock"
+
+	etcdmocks "github.com/imtaco/audio-rtc-exp/internal/etcd/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/mixers/mocks"
+)
+
+const testHLSDefaultsKey = "/config/mixers/hls"
+
+type HLSDefaultsWatcherTestSuite struct {
+	suite.Suite
+	ctrl          *gomock.Controller
+	mockEtcd      *etcdmocks.MockWatcher
+	mockFFmpegMgr *mocks.MockFFmpegManager
+	watcher       *HLSDefaultsWatcher
+}
+
+func TestHLSDefaultsWatcherSuite(t *testing.T) {
+	suite.Run(t, new(HLSDefaultsWatcherTestSuite))
+}
+
+func (s *HLSDefaultsWatcherTestSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.mockEtcd = etcdmocks.NewMockWatcher(s.ctrl)
+	s.mockFFmpegMgr = mocks.NewMockFFmpegManager(s.ctrl)
+	s.watcher = NewHLSDefaultsWatcher(s.mockEtcd, testHLSDefaultsKey, s.mockFFmpegMgr, log.NewNop())
+	s.watcher.retryDelay = 10 * time.Millisecond
+}
+
+func getResponse(rev int64, value string) *clientv3.GetResponse {
+	resp := &clientv3.GetResponse{Header: &etcdserverpb.ResponseHeader{Revision: rev}}
+	if value != "" {
+		resp.Kvs = []*mvccpb.KeyValue{{Key: []byte(testHLSDefaultsKey), Value: []byte(value)}}
+	}
+	return resp
+}
+
+func (s *HLSDefaultsWatcherTestSuite) TestStart_GetError() {
+	s.mockEtcd.EXPECT().Get(gomock.Any(), testHLSDefaultsKey).Return(nil, errors.New("etcd down"))
+
+	s.Require().Error(s.watcher.Start(context.Background()))
+}
+
+func (s *HLSDefaultsWatcherTestSuite) TestApplyChanges() {
+	watchCh := make(chan clientv3.WatchResponse)
+	applied := make(chan *etcdstate.HLSParams, 1)
+
+	s.mockEtcd.EXPECT().Get(gomock.Any(), testHLSDefaultsKey).
+		Return(getResponse(7, `{"segmentDuration":4}`), nil)
+	s.mockEtcd.EXPECT().Watch(gomock.Any(), testHLSDefaultsKey, gomock.Any()).
+		Return((clientv3.WatchChan)(watchCh))
+	s.mockFFmpegMgr.EXPECT().SetHLSDefaults(gomock.Any()).
+		Do(func(params *etcdstate.HLSParams) { applied <- params }).
+		AnyTimes()
+
+	s.Require().NoError(s.watcher.Start(context.Background()))
+	defer s.watcher.Stop()
+	s.Equal(&etcdstate.HLSParams{SegmentDuration: 4}, <-applied)
+
+	watchCh <- clientv3.WatchResponse{Events: []*clientv3.Event{{
+		Type: clientv3.EventTypePut,
+		Kv:   &mvccpb.KeyValue{Value: []byte(`{"keyBaseUrl":"https://cdn/keys/","playlistSize":8}`)},
+	}}}
+	s.Equal(&etcdstate.HLSParams{KeyBaseURL: "https://cdn/keys/", PlaylistSize: 8}, <-applied)
+
+	// invalid values keep the current defaults
+	watchCh <- clientv3.WatchResponse{Events: []*clientv3.Event{{
+		Type: clientv3.EventTypePut,
+		Kv:   &mvccpb.KeyValue{Value: []byte(`{invalid`)},
+	}}}
+	watchCh <- clientv3.WatchResponse{Events: []*clientv3.Event{{
+		Type: clientv3.EventTypeDelete,
+		Kv:   &mvccpb.KeyValue{},
+	}}}
+	s.Nil(<-applied)
+}
+
+func (s *HLSDefaultsWatcherTestSuite) TestReloadAfterWatchClosed() {
+	watchCh := make(chan clientv3.WatchResponse)
+	applied := make(chan *etcdstate.HLSParams, 1)
+
+	gomock.InOrder(
+		s.mockEtcd.EXPECT().Get(gomock.Any(), testHLSDefaultsKey).Return(getResponse(7, ""), nil),
+		s.mockEtcd.EXPECT().Watch(gomock.Any(), testHLSDefaultsKey, gomock.Any()).
+			Return((clientv3.WatchChan)(watchCh)),
+		s.mockEtcd.EXPECT().Get(gomock.Any(), testHLSDefaultsKey).
+			Return(getResponse(12, `{"playlistSize":6}`), nil),
+		s.mockEtcd.EXPECT().Watch(gomock.Any(), testHLSDefaultsKey, gomock.Any()).
+			Return((clientv3.WatchChan)(make(chan clientv3.WatchResponse))),
+	)
+	s.mockFFmpegMgr.EXPECT().SetHLSDefaults(gomock.Any()).
+		Do(func(params *etcdstate.HLSParams) { applied <- params }).
+		Times(2)
+
+	s.Require().NoError(s.watcher.Start(context.Background()))
+	defer s.watcher.Stop()
+	s.Nil(<-applied)
+
+	close(watchCh)
+	s.Equal(&etcdstate.HLSParams{PlaylistSize: 6}, <-applied)
+}
@@ -0,0 +1,127 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// LatencyReporter periodically writes the publish to HLS segment latency of running rooms
+// to etcd, where rooms serves it with the room. Latency is measured by FFmpeg manager on
+// every segment, the report interval only bounds etcd writes.
+type LatencyReporter struct {
+	roomWatcher *RoomWatcher
+	interval    time.Duration
+	reported    map[string]etcdstate.Latency // roomID -> last written, only used by loop
+	cancel      context.CancelFunc
+	stopped     chan struct{}
+	logger      *log.Logger
+}
+
+// NewLatencyReporter creates a new LatencyReporter
+func NewLatencyReporter(roomWatcher *RoomWatcher, interval time.Duration, logger *log.Logger) *LatencyReporter {
+	return &LatencyReporter{
+		roomWatcher: roomWatcher,
+		interval:    interval,
+		reported:    make(map[string]etcdstate.Latency),
+		stopped:     make(chan struct{}),
+		logger:      logger,
+	}
+}
+
+// Start starts the periodic report loop
+func (r *LatencyReporter) Start(ctx context.Context) error {
+	r.logger.Info("Starting latency reporter", log.Duration("interval", r.interval))
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	go r.loop(ctx)
+	return nil
+}
+
+// Stop stops the report loop
+func (r *LatencyReporter) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.stopped
+	}
+	r.logger.Info("Stopped latency reporter")
+}
+
+func (r *LatencyReporter) loop(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer close(r.stopped)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.report(ctx, time.Now())
+		}
+	}
+}
+
+// report writes the latency of running rooms that changed since the last report
+func (r *LatencyReporter) report(ctx context.Context, now time.Time) {
+	active := r.roomWatcher.GetActiveRooms()
+	for roomID := range r.reported {
+		if _, ok := active[roomID]; !ok {
+			delete(r.reported, roomID)
+		}
+	}
+
+	for roomID := range active {
+		latency, ok := r.roomWatcher.ffmpegManager.Latency(roomID)
+		if !ok {
+			continue
+		}
+		data := etcdstate.Latency{
+			CurrentMs: latency.Current.Milliseconds(),
+			AvgMs:     latency.Average.Milliseconds(),
+			UpdatedAt: now.UTC(),
+		}
+		if prev, ok := r.reported[roomID]; ok && prev.CurrentMs == data.CurrentMs && prev.AvgMs == data.AvgMs {
+			continue
+		}
+		if err := r.roomWatcher.updateLatency(ctx, roomID, &data); err != nil {
+			r.logger.Warn("Failed to report room latency", log.String("roomId", roomID), log.Error(err))
+			continue
+		}
+		r.reported[roomID] = data
+	}
+}
+
+// updateLatency writes latency data to etcd, nil deletes it
+func (w *RoomWatcher) updateLatency(ctx context.Context, roomID string, latency *etcdstate.Latency) error {
+	key := fmt.Sprintf("%s%s/latency", w.prefixRooms, roomID)
+
+	if latency != nil {
+		jsonData, err := json.Marshal(latency)
+		if err != nil {
+			return fmt.Errorf("failed to marshal latency data: %w", err)
+		}
+		_, err = w.etcdClient.Put(ctx, key, string(jsonData))
+		return err
+	}
+
+	_, err := w.etcdClient.Delete(ctx, key)
+	return err
+}
+
+// syncPublishedAt passes the forwarding start of Janus to FFmpeg for latency estimation
+func (w *RoomWatcher) syncPublishedAt(roomID string, janus *etcdstate.Janus) {
+	if janus.GetStatus() != constants.JanusStatusForwarding {
+		return
+	}
+	if err := w.ffmpegManager.SetPublishedAt(roomID, janus.GetTimestamp()); err != nil {
+		w.logger.Warn("Failed to set publish time of room",
+			log.String("roomId", roomID),
+			log.Error(err))
+	}
+}
@@ -0,0 +1,84 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/mixers"
+)
+
+func (s *RoomWatcherTestSuite) TestSyncPublishedAt() {
+	forwardedAt := time.Now().Add(-time.Minute)
+
+	s.Run("passes forwarding start to FFmpeg", func() {
+		roomID := "room1"
+		s.watcher.activeRooms.Store(roomID, &ActiveRoom{Port: 5004, Status: "running"})
+
+		state := &etcdstate.RoomState{
+			LiveMeta: &etcdstate.LiveMeta{Status: constants.RoomStatusOnAir, MixerID: "mixer-1"},
+			Mixer:    &etcdstate.Mixer{ID: "mixer-1", Port: 5004},
+			Janus:    &etcdstate.Janus{Status: constants.JanusStatusForwarding, Timestamp: forwardedAt},
+		}
+
+		s.mockFFmpegMgr.EXPECT().
+			SetPublishedAt(roomID, forwardedAt).
+			Return(nil)
+
+		err := s.watcher.processChange(s.ctx, roomID, state)
+		s.Require().NoError(err)
+	})
+
+	s.Run("ignores Janus not forwarding yet", func() {
+		s.watcher.syncPublishedAt("room1", &etcdstate.Janus{
+			Status:    constants.JanusStatusRoomCreated,
+			Timestamp: forwardedAt,
+		})
+		s.watcher.syncPublishedAt("room1", nil)
+	})
+}
+
+func (s *RoomWatcherTestSuite) TestLatencyReporter() {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	reporter := NewLatencyReporter(s.watcher, time.Second, log.NewNop())
+
+	s.watcher.activeRooms.Store("room1", &ActiveRoom{Port: 5004, Status: "running"})
+	s.watcher.activeRooms.Store("room2", &ActiveRoom{Port: 5006, Status: "running"})
+
+	latency := mixers.Latency{Current: 4200 * time.Millisecond, Average: 4100 * time.Millisecond}
+	expectedJSON, _ := json.Marshal(etcdstate.Latency{CurrentMs: 4200, AvgMs: 4100, UpdatedAt: now})
+
+	s.Run("writes measured rooms", func() {
+		s.mockFFmpegMgr.EXPECT().Latency("room1").Return(latency, true)
+		s.mockFFmpegMgr.EXPECT().Latency("room2").Return(mixers.Latency{}, false)
+		s.mockEtcdClient.EXPECT().
+			Put(gomock.Any(), "/rooms/room1/latency", string(expectedJSON)).
+			Return(nil, nil)
+
+		reporter.report(s.ctx, now)
+		s.Contains(reporter.reported, "room1")
+	})
+
+	s.Run("skips unchanged latency", func() {
+		s.mockFFmpegMgr.EXPECT().Latency("room1").Return(latency, true)
+		s.mockFFmpegMgr.EXPECT().Latency("room2").Return(mixers.Latency{}, false)
+
+		reporter.report(s.ctx, now.Add(time.Second))
+	})
+
+	s.Run("retries failed writes and forgets stopped rooms", func() {
+		s.watcher.activeRooms.Delete("room1")
+		s.mockFFmpegMgr.EXPECT().Latency("room2").Return(latency, true)
+		s.mockEtcdClient.EXPECT().
+			Put(gomock.Any(), "/rooms/room2/latency", gomock.Any()).
+			Return(nil, errors.New("etcd error"))
+
+		reporter.report(s.ctx, now)
+		s.Empty(reporter.reported)
+	})
+}
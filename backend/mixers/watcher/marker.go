@@ -0,0 +1,107 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/network"
+	"github.com/imtaco/audio-rtc-exp/mixers"
+)
+
+// maxMarkerSize bounds a latency marker datagram
+const maxMarkerSize = 512
+
+// MarkerListener receives the latency markers Janus hosts send next to the RTP forward of
+// rooms and hands them to FFmpeg manager, which measures latency from them per segment.
+// The active speaker of rooms arrives on the same port.
+type MarkerListener struct {
+	port          int
+	ffmpegManager mixers.FFmpegManager
+	conn          *net.UDPConn
+	stopped       chan struct{}
+	logger        *log.Logger
+}
+
+// NewMarkerListener creates a new MarkerListener on UDP port
+func NewMarkerListener(port int, ffmpegManager mixers.FFmpegManager, logger *log.Logger) *MarkerListener {
+	return &MarkerListener{
+		port:          port,
+		ffmpegManager: ffmpegManager,
+		stopped:       make(chan struct{}),
+		logger:        logger,
+	}
+}
+
+// Start listens for markers until Stop
+func (l *MarkerListener) Start(_ context.Context) error {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: l.port})
+	if err != nil {
+		return fmt.Errorf("failed to listen for latency markers: %w", err)
+	}
+	l.conn = conn
+	l.logger.Info("Starting latency marker listener", log.String("addr", conn.LocalAddr().String()))
+
+	go l.loop()
+	return nil
+}
+
+// Stop closes the listener
+func (l *MarkerListener) Stop() {
+	if l.conn != nil {
+		_ = l.conn.Close()
+		<-l.stopped
+	}
+	l.logger.Info("Stopped latency marker listener")
+}
+
+func (l *MarkerListener) loop() {
+	defer close(l.stopped)
+
+	buf := make([]byte, maxMarkerSize)
+	for {
+		n, _, err := l.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			l.logger.Warn("Failed to read latency marker", log.Error(err))
+			continue
+		}
+		l.handle(buf[:n])
+	}
+}
+
+func (l *MarkerListener) handle(data []byte) {
+	if network.DatagramKind(data) == network.KindActiveSpeaker {
+		l.handleSpeaker(data)
+		return
+	}
+
+	marker, err := network.DecodeLatencyMarker(data)
+	if err != nil {
+		l.logger.Debug("Dropped invalid latency marker", log.Error(err))
+		return
+	}
+	// markers of rooms not mixed here (yet) are expected around room start and stop
+	if err := l.ffmpegManager.MarkerReceived(marker.RoomID, marker.SentAt); err != nil {
+		l.logger.Debug("Dropped latency marker",
+			log.String("roomId", marker.RoomID),
+			log.Error(err))
+	}
+}
+
+func (l *MarkerListener) handleSpeaker(data []byte) {
+	speaker, err := network.DecodeActiveSpeaker(data)
+	if err != nil {
+		l.logger.Debug("Dropped invalid active speaker", log.Error(err))
+		return
+	}
+	if err := l.ffmpegManager.SetActiveSpeaker(speaker.RoomID, speaker.UserID, speaker.SentAt); err != nil {
+		l.logger.Debug("Dropped active speaker",
+			log.String("roomId", speaker.RoomID),
+			log.Error(err))
+	}
+}
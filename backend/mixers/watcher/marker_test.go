@@ -0,0 +1,79 @@
+package watcher
+
+import (
+	"net"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/network"
+)
+
+func (s *RoomWatcherTestSuite) TestMarkerListener() {
+	sentAt := time.Now().Add(-time.Second).UTC()
+	received := make(chan struct{})
+
+	s.mockFFmpegMgr.EXPECT().
+		MarkerReceived("room1", sentAt).
+		DoAndReturn(func(string, time.Time) error {
+			close(received)
+			return nil
+		})
+
+	listener := NewMarkerListener(0, s.mockFFmpegMgr, log.NewNop())
+	s.Require().NoError(listener.Start(s.ctx))
+	defer listener.Stop()
+
+	conn, err := net.DialUDP("udp", nil, listener.conn.LocalAddr().(*net.UDPAddr))
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	// invalid datagrams are dropped without stopping the listener
+	_, err = conn.Write([]byte("garbage"))
+	s.Require().NoError(err)
+
+	data, err := network.EncodeLatencyMarker(network.LatencyMarker{RoomID: "room1", SentAt: sentAt})
+	s.Require().NoError(err)
+	_, err = conn.Write(data)
+	s.Require().NoError(err)
+
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		s.Fail("marker not received")
+	}
+}
+
+func (s *RoomWatcherTestSuite) TestMarkerListener_ActiveSpeaker() {
+	sentAt := time.Now().UTC()
+	received := make(chan struct{})
+
+	s.mockFFmpegMgr.EXPECT().
+		SetActiveSpeaker("room1", "u1", sentAt).
+		DoAndReturn(func(string, string, time.Time) error {
+			close(received)
+			return nil
+		})
+
+	listener := NewMarkerListener(0, s.mockFFmpegMgr, log.NewNop())
+	s.Require().NoError(listener.Start(s.ctx))
+	defer listener.Stop()
+
+	conn, err := net.DialUDP("udp", nil, listener.conn.LocalAddr().(*net.UDPAddr))
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	// speakers without room are dropped
+	_, err = conn.Write([]byte(`{"kind":"speaker","userId":"u1"}`))
+	s.Require().NoError(err)
+
+	data, err := network.EncodeActiveSpeaker(network.ActiveSpeaker{RoomID: "room1", UserID: "u1", SentAt: sentAt})
+	s.Require().NoError(err)
+	_, err = conn.Write(data)
+	s.Require().NoError(err)
+
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		s.Fail("active speaker not received")
+	}
+}
@@ -13,6 +13,10 @@ var (
 	roomsStarted     metric.Int64Counter
 	roomsStopped     metric.Int64Counter
 	roomsFailed      metric.Int64Counter
+	segmentStalls    metric.Int64Counter
+	staleCleared     metric.Int64Counter
+	roomsRefused     metric.Int64Counter
+	budgetUsed       metric.Float64UpDownCounter
 )
 
 func init() {
@@ -32,4 +36,16 @@ func init() {
 
 	f.Int64Counter(&roomsFailed, "rooms.failed",
 		metric.WithDescription("Total number of rooms that failed to start"))
+
+	f.Int64Counter(&segmentStalls, "rooms.segment_stalls",
+		metric.WithDescription("Total number of FFmpeg restarts of rooms whose HLS segments stalled"))
+
+	f.Int64Counter(&staleCleared, "rooms.stale_cleared",
+		metric.WithDescription("Total number of stale mixer data cleared, left by a previous run of the mixer"))
+
+	f.Int64Counter(&roomsRefused, "rooms.refused",
+		metric.WithDescription("Total number of rooms refused over the CPU budget"))
+
+	f.Float64UpDownCounter(&budgetUsed, "budget.cpu.used",
+		metric.WithDescription("Estimated CPU of the running rooms, in cores"))
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.opentelemetry.io/otel"
@@ -16,34 +18,56 @@ import (
 	"github.com/imtaco/audio-rtc-exp/internal/etcd"
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
-	etcdwatcher "github.com/imtaco/audio-rtc-exp/internal/reswatcher/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/roomstate"
+	"github.com/imtaco/audio-rtc-exp/internal/timeline"
 	"github.com/imtaco/audio-rtc-exp/mixers"
 )
 
 // RoomWatcher watches etcd for room changes and manages FFmpeg lifecycle
 type RoomWatcher struct {
-	etcdwatcher.RoomWatcher
+	roomstate.Watcher
 	etcdClient    etcd.Client
 	id            string
 	mixerIP       string
+	markerPort    int
+	srtpSuite     string // empty forwards plaintext RTP
 	portManager   mixers.PortManager
 	ffmpegManager mixers.FFmpegManager
 	prefixRooms   string
 	activeRooms   sync.Map
+	budget        *CPUBudget       // nil without CPU budget
+	timeline      *timeline.Writer // nil in tests
 	logger        *log.Logger
 	tracer        trace.Tracer
 }
 
+const (
+	roomStatusRunning = "running"
+	// roomStatusDegraded marks a room whose FFmpeg stopped writing segments, see FreshnessWatchdog
+	roomStatusDegraded = "degraded"
+	// degradedLogLines is the FFmpeg stderr lines reported in the mixer data of degraded rooms
+	degradedLogLines = 20
+)
+
 // ActiveRoom represents an active room being processed
 type ActiveRoom struct {
-	Port   int    `json:"port"`
-	Status string `json:"status"`
+	Port     int    `json:"port"`
+	LinkPort int    `json:"linkPort,omitempty"`
+	Status   string `json:"status"`
+	// StartedAt is when FFmpeg of the room was started by this mixer
+	StartedAt time.Time `json:"startedAt"`
+	// SRTP is the crypto Janus forwards the room with, kept out of debug output
+	SRTP *etcdstate.SRTP `json:"-"`
+	// Logs is the last FFmpeg stderr lines when the room was flagged degraded
+	Logs []string `json:"logs,omitempty"`
 }
 
 // NewRoomWatcher creates a new RoomWatcher
 func NewRoomWatcher(
 	etcdClient *clientv3.Client,
 	id, mixerIP string,
+	markerPort int,
+	srtpSuite string,
 	portManager mixers.PortManager,
 	ffmpegManager mixers.FFmpegManager,
 	prefixRooms, _ string,
@@ -52,33 +76,46 @@ func NewRoomWatcher(
 	w := &RoomWatcher{
 		id:            id,
 		mixerIP:       mixerIP,
+		markerPort:    markerPort,
+		srtpSuite:     srtpSuite,
 		portManager:   portManager,
 		ffmpegManager: ffmpegManager,
 		prefixRooms:   prefixRooms,
 		etcdClient:    etcdClient,
+		timeline:      timeline.NewWriter(etcdClient, prefixRooms, "mixer:"+id, logger.Module("Timeline")),
 		logger:        logger,
 		tracer:        otel.Tracer("mixer.watcher"),
 	}
 
-	w.RoomWatcher = etcdwatcher.NewRoomWatcher(
-		etcdClient,
-		prefixRooms,
-		[]string{constants.RoomKeyLiveMeta, constants.RoomKeyMixer},
-		w.processChange,
-		logger,
-	)
+	w.Watcher = roomstate.New(&roomstate.Config{
+		Client:   etcdClient,
+		Prefix:   prefixRooms,
+		KeyTypes: []string{constants.RoomKeyMeta, constants.RoomKeyLiveMeta, constants.RoomKeyMixer, constants.RoomKeyLink, constants.RoomKeyJanus},
+		OnChange: w.processChange,
+		Logger:   logger,
+	})
 	return w
 }
 
-// updateMixer writes mixer data to etcd
-func (w *RoomWatcher) updateMixer(ctx context.Context, roomID string, port *int) error {
+// SetBudget sets the CPU budget rooms are admitted with, it must be set before Start
+func (w *RoomWatcher) SetBudget(budget *CPUBudget) {
+	w.budget = budget
+}
+
+// updateMixer writes mixer data of the room to etcd, nil deletes it
+func (w *RoomWatcher) updateMixer(ctx context.Context, roomID string, room *ActiveRoom) error {
 	key := fmt.Sprintf("%s%s/mixer", w.prefixRooms, roomID)
 
-	if port != nil {
+	if room != nil {
 		data := etcdstate.Mixer{
-			ID:   w.id,
-			IP:   w.mixerIP,
-			Port: *port,
+			ID:         w.id,
+			IP:         w.mixerIP,
+			Port:       room.Port,
+			LinkPort:   room.LinkPort,
+			MarkerPort: w.markerPort,
+			Degraded:   room.Status == roomStatusDegraded,
+			Logs:       room.Logs,
+			SRTP:       room.SRTP,
 		}
 		jsonData, err := json.Marshal(data)
 		if err != nil {
@@ -94,7 +131,12 @@ func (w *RoomWatcher) updateMixer(ctx context.Context, roomID string, port *int)
 }
 
 // startRoomFFmpeg starts FFmpeg for a room
-func (w *RoomWatcher) startRoomFFmpeg(ctx context.Context, roomID string, livemeta *etcdstate.LiveMeta) error {
+func (w *RoomWatcher) startRoomFFmpeg(
+	ctx context.Context,
+	roomID string,
+	livemeta *etcdstate.LiveMeta,
+	meta *etcdstate.Meta,
+) error {
 	ctx, span := w.tracer.Start(ctx, "watcher.startRoomFFmpeg",
 		trace.WithAttributes(
 			attribute.String("room.id", roomID),
@@ -108,6 +150,26 @@ func (w *RoomWatcher) startRoomFFmpeg(ctx context.Context, roomID string, liveme
 		attribute.String("mixer.id", w.id),
 	)
 
+	if missing := w.ffmpegManager.Capabilities().Missing(w.requiredFeatures()...); len(missing) > 0 {
+		err := fmt.Errorf("%w: %s", mixers.ErrUnsupported, strings.Join(missing, ","))
+		span.RecordError(err)
+		roomsFailed.Add(ctx, 1, attrs)
+		return err
+	}
+
+	// refused rooms are retried by the watcher until rooms stop or the budget frees up, the
+	// mixer reports saturation so no more rooms are placed on it meanwhile
+	if err := w.budget.Admit(ctx, roomID, w.budget.Cost(meta, false)); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	admitted := false
+	defer func() {
+		if !admitted {
+			w.budget.Release(ctx, roomID)
+		}
+	}()
+
 	port, err := w.portManager.GetFreeRTPPort()
 	if err != nil {
 		span.RecordError(err)
@@ -120,19 +182,34 @@ func (w *RoomWatcher) startRoomFFmpeg(ctx context.Context, roomID string, liveme
 		log.String("roomId", roomID),
 		log.Int("port", port))
 
-	if err := w.ffmpegManager.StartFFmpeg(roomID, port, livemeta.CreatedAt, livemeta.Nonce); err != nil {
+	// a key per run, Janus forwarders follow the mixer data
+	var srtp *etcdstate.SRTP
+	if w.srtpSuite != "" {
+		if srtp, err = etcdstate.NewSRTP(w.srtpSuite); err != nil {
+			span.RecordError(err)
+			roomsFailed.Add(ctx, 1, attrs)
+			return err
+		}
+	}
+
+	if err := w.ffmpegManager.StartFFmpeg(
+		roomID, port, livemeta.CreatedAt, livemeta.Nonce, meta.GetDVRWindow(), meta.GetHLS(), srtp,
+	); err != nil {
 		span.RecordError(err)
 		roomsFailed.Add(ctx, 1, attrs)
 		return fmt.Errorf("failed to start FFmpeg: %w", err)
 	}
 
-	if err := w.updateMixer(ctx, roomID, &port); err != nil {
+	activeRoom := &ActiveRoom{Port: port, Status: roomStatusRunning, StartedAt: time.Now(), SRTP: srtp}
+	if err := w.updateMixer(ctx, roomID, activeRoom); err != nil {
 		span.RecordError(err)
 		roomsFailed.Add(ctx, 1, attrs)
 		return fmt.Errorf("failed to update mixer data: %w", err)
 	}
 
-	w.activeRooms.Store(roomID, &ActiveRoom{Port: port, Status: "running"})
+	w.activeRooms.Store(roomID, activeRoom)
+	admitted = true
+	w.timeline.Record(roomID, timeline.EventMixerAssigned, w.id)
 
 	// Record metrics
 	roomsStarted.Add(ctx, 1, attrs)
@@ -141,6 +218,21 @@ func (w *RoomWatcher) startRoomFFmpeg(ctx context.Context, roomID string, liveme
 	return nil
 }
 
+// requiredFeatures are the FFmpeg features every room of the mixer needs
+func (w *RoomWatcher) requiredFeatures() []string {
+	features := []string{mixers.FeatureAAC, mixers.FeatureHLS, mixers.FeatureHLSEncryption, mixers.FeatureRTP}
+	if w.srtpSuite != "" {
+		features = append(features, mixers.FeatureSRTP)
+	}
+	return features
+}
+
+// FFmpegRespawned records FFmpeg of the room being respawned for reason on the room timeline,
+// it is the respawn handler of FFmpeg manager
+func (w *RoomWatcher) FFmpegRespawned(roomID, reason string) {
+	w.timeline.Record(roomID, timeline.EventFFmpegRestarted, reason)
+}
+
 // stopRoomFFmpeg stops FFmpeg for a room
 func (w *RoomWatcher) stopRoomFFmpeg(ctx context.Context, roomID string, isStateRunner bool) error {
 	ctx, span := w.tracer.Start(ctx, "watcher.stopRoomFFmpeg",
@@ -164,6 +256,7 @@ func (w *RoomWatcher) stopRoomFFmpeg(ctx context.Context, roomID string, isState
 	}
 
 	w.activeRooms.Delete(roomID)
+	w.budget.Release(ctx, roomID)
 
 	// Record metrics
 	roomsStopped.Add(ctx, 1, attrs)
@@ -176,6 +269,10 @@ func (w *RoomWatcher) stopRoomFFmpeg(ctx context.Context, roomID string, isState
 			span.RecordError(err)
 			return fmt.Errorf("failed to remove mixer data: %w", err)
 		}
+		if err := w.updateLatency(ctx, roomID, nil); err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("failed to remove latency data: %w", err)
+		}
 	} else {
 		w.logger.Info("Someone else holds state, not removing port for room",
 			log.String("roomId", roomID))
@@ -184,6 +281,21 @@ func (w *RoomWatcher) stopRoomFFmpeg(ctx context.Context, roomID string, isState
 	return nil
 }
 
+// clearStaleMixer removes mixer data of this mixer that no FFmpeg runs for, the room is no
+// longer assigned to it so Janus drops its forwarders
+func (w *RoomWatcher) clearStaleMixer(ctx context.Context, roomID string) error {
+	w.logger.Warn("Clearing stale mixer data of room", log.String("roomId", roomID))
+
+	if err := w.updateMixer(ctx, roomID, nil); err != nil {
+		return fmt.Errorf("failed to remove stale mixer data: %w", err)
+	}
+	if err := w.updateLatency(ctx, roomID, nil); err != nil {
+		return fmt.Errorf("failed to remove stale latency data: %w", err)
+	}
+	staleCleared.Add(ctx, 1, metric.WithAttributes(attribute.String("mixer.id", w.id)))
+	return nil
+}
+
 // syncMixerData syncs mixer data to etcd
 func (w *RoomWatcher) syncMixerData(ctx context.Context, roomID string) error {
 	w.logger.Info("Syncing mixer data to etcd", log.String("roomId", roomID))
@@ -193,8 +305,86 @@ func (w *RoomWatcher) syncMixerData(ctx context.Context, roomID string) error {
 		return fmt.Errorf("room not found in active rooms")
 	}
 
+	return w.updateMixer(ctx, roomID, val.(*ActiveRoom))
+}
+
+// setDegraded flags a running room degraded, or back to running, in memory and etcd
+func (w *RoomWatcher) setDegraded(ctx context.Context, roomID string, degraded bool) error {
+	val, ok := w.activeRooms.Load(roomID)
+	if !ok {
+		return fmt.Errorf("room not found in active rooms")
+	}
+	activeRoom := val.(*ActiveRoom)
+
+	status := roomStatusRunning
+	if degraded {
+		status = roomStatusDegraded
+	}
+	if activeRoom.Status == status {
+		return nil
+	}
+	updated := *activeRoom
+	updated.Status = status
+	updated.Logs = nil
+	if degraded {
+		// reported with the flag, saves a trip to the mixer when looking into the room
+		logs, err := w.ffmpegManager.Logs(roomID, degradedLogLines)
+		if err != nil {
+			w.logger.Warn("Failed to read FFmpeg logs of degraded room", log.String("roomId", roomID), log.Error(err))
+		}
+		updated.Logs = logs
+	}
+	w.activeRooms.Store(roomID, &updated)
+	return w.updateMixer(ctx, roomID, &updated)
+}
+
+// syncLink adds or removes the linked room input of a running room, Janus of the
+// source room forwards to the link port once it is published in mixer data
+func (w *RoomWatcher) syncLink(ctx context.Context, roomID string, state *etcdstate.RoomState) error {
+	val, ok := w.activeRooms.Load(roomID)
+	if !ok {
+		return fmt.Errorf("room not found in active rooms")
+	}
 	activeRoom := val.(*ActiveRoom)
-	return w.updateMixer(ctx, roomID, &activeRoom.Port)
+
+	linkPort := activeRoom.LinkPort
+	switch {
+	case state.GetLink() != nil && linkPort == 0:
+		if !w.ffmpegManager.Capabilities().Has(mixers.FeatureLink) {
+			return fmt.Errorf("failed to add link to FFmpeg: %w: %s", mixers.ErrUnsupported, mixers.FeatureLink)
+		}
+		port, err := w.portManager.GetFreeRTPPort()
+		if err != nil {
+			return fmt.Errorf("failed to allocate link RTP port: %w", err)
+		}
+		if err := w.ffmpegManager.SetLink(roomID, port); err != nil {
+			return fmt.Errorf("failed to add link to FFmpeg: %w", err)
+		}
+		w.logger.Info("Linked room into mix",
+			log.String("roomId", roomID),
+			log.String("sourceRoomId", state.GetLink().GetSourceRoomID()),
+			log.Int("linkPort", port))
+		linkPort = port
+	case state.GetLink() == nil && linkPort != 0:
+		if err := w.ffmpegManager.SetLink(roomID, 0); err != nil {
+			return fmt.Errorf("failed to remove link from FFmpeg: %w", err)
+		}
+		w.logger.Info("Unlinked room from mix", log.String("roomId", roomID))
+		linkPort = 0
+	}
+
+	if linkPort != activeRoom.LinkPort {
+		// the mixer admitted the room, a link is mixed in even over the budget
+		_ = w.budget.Admit(ctx, roomID, w.budget.Cost(state.GetMeta(), linkPort != 0))
+		updated := *activeRoom
+		updated.LinkPort = linkPort
+		activeRoom = &updated
+		w.activeRooms.Store(roomID, activeRoom)
+	}
+	if state.GetMixer().GetLinkPort() == linkPort {
+		return nil
+	}
+	return w.updateMixer(ctx, roomID, activeRoom)
 }
 
 // processChange processes a room state change
@@ -237,11 +427,18 @@ func (w *RoomWatcher) processChange(ctx context.Context, roomID string, state *e
 	switch {
 	case shouldBeRunning && !isRunning:
 		// Must have livemeta here
-		return w.startRoomFFmpeg(ctx, roomID, livemeta)
+		return w.startRoomFFmpeg(ctx, roomID, livemeta, state.Meta)
 	case shouldBeRunning && isRunning && !isStateRunner:
 		return w.syncMixerData(ctx, roomID)
+	case shouldBeRunning && isRunning:
+		w.syncPublishedAt(roomID, state.GetJanus())
+		return w.syncLink(ctx, roomID, state)
 	case !shouldBeRunning && isRunning:
 		return w.stopRoomFFmpeg(ctx, roomID, isStateRunner)
+	case isStateRunner && !isRunning:
+		// mixer data left by a previous run of this mixer, e.g. after a crash. Rooms still
+		// assigned were restarted on fresh ports above, the others are cleared
+		return w.clearStaleMixer(ctx, roomID)
 	default:
 		return nil
 	}
@@ -8,13 +8,16 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/mock/gomock"
 
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	etcdmocks "github.com/imtaco/audio-rtc-exp/internal/etcd/mocks"
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate/fixtures"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/mixers"
 	"github.com/imtaco/audio-rtc-exp/mixers/mocks"
 )
 
@@ -37,11 +40,14 @@ func (s *RoomWatcherTestSuite) SetupTest() {
 	s.mockEtcdClient = etcdmocks.NewMockClient(s.ctrl)
 	s.mockPortMgr = mocks.NewMockPortManager(s.ctrl)
 	s.mockFFmpegMgr = mocks.NewMockFFmpegManager(s.ctrl)
+	// not probed, everything is supported
+	s.mockFFmpegMgr.EXPECT().Capabilities().Return(nil).AnyTimes()
 	s.ctx = context.Background()
 
 	s.watcher = &RoomWatcher{
 		id:            "mixer-1",
 		mixerIP:       "192.168.1.100",
+		markerPort:    5300,
 		portManager:   s.mockPortMgr,
 		ffmpegManager: s.mockFFmpegMgr,
 		prefixRooms:   "/rooms/",
@@ -62,9 +68,10 @@ func (s *RoomWatcherTestSuite) TestUpdateMixer() {
 
 		expectedKey := "/rooms/room1/mixer"
 		expectedData := etcdstate.Mixer{
-			ID:   "mixer-1",
-			IP:   "192.168.1.100",
-			Port: port,
+			ID:         "mixer-1",
+			IP:         "192.168.1.100",
+			Port:       port,
+			MarkerPort: 5300,
 		}
 		expectedJSON, _ := json.Marshal(expectedData)
 
@@ -72,7 +79,7 @@ func (s *RoomWatcherTestSuite) TestUpdateMixer() {
 			Put(gomock.Any(), expectedKey, string(expectedJSON)).
 			Return(nil, nil)
 
-		err := s.watcher.updateMixer(s.ctx, roomID, &port)
+		err := s.watcher.updateMixer(s.ctx, roomID, &ActiveRoom{Port: port})
 
 		s.Require().NoError(err)
 	})
@@ -98,7 +105,7 @@ func (s *RoomWatcherTestSuite) TestUpdateMixer() {
 			Put(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(nil, errors.New("etcd error"))
 
-		err := s.watcher.updateMixer(s.ctx, roomID, &port)
+		err := s.watcher.updateMixer(s.ctx, roomID, &ActiveRoom{Port: port})
 
 		s.Require().Error(err)
 	})
@@ -120,26 +127,21 @@ func (s *RoomWatcherTestSuite) TestStartRoomFFmpeg() {
 	s.Run("start ffmpeg successfully", func() {
 		roomID := "room1"
 		port := 5004
-		livemeta := &etcdstate.LiveMeta{
-			Status:    constants.RoomStatusOnAir,
-			MixerID:   "mixer-1",
-			CreatedAt: time.Now(),
-			Nonce:     "abc123",
-		}
+		livemeta := fixtures.LiveMeta()
 
 		s.mockPortMgr.EXPECT().
 			GetFreeRTPPort().
 			Return(port, nil)
 
 		s.mockFFmpegMgr.EXPECT().
-			StartFFmpeg(roomID, port, livemeta.CreatedAt, livemeta.Nonce).
+			StartFFmpeg(roomID, port, livemeta.CreatedAt, livemeta.Nonce, 0, nil, nil).
 			Return(nil)
 
 		s.mockEtcdClient.EXPECT().
 			Put(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(nil, nil)
 
-		err := s.watcher.startRoomFFmpeg(s.ctx, roomID, livemeta)
+		err := s.watcher.startRoomFFmpeg(s.ctx, roomID, livemeta, nil)
 
 		s.Require().NoError(err)
 
@@ -149,18 +151,48 @@ func (s *RoomWatcherTestSuite) TestStartRoomFFmpeg() {
 		s.Equal("running", activeRooms[roomID].Status)
 	})
 
+	s.Run("start ffmpeg with SRTP", func() {
+		roomID := "room-srtp"
+		port := 5008
+		livemeta := fixtures.LiveMeta()
+		s.watcher.srtpSuite = etcdstate.SRTPSuiteHMAC80
+		defer func() { s.watcher.srtpSuite = "" }()
+
+		var srtp *etcdstate.SRTP
+		s.mockPortMgr.EXPECT().GetFreeRTPPort().Return(port, nil)
+		s.mockFFmpegMgr.EXPECT().
+			StartFFmpeg(roomID, port, livemeta.CreatedAt, livemeta.Nonce, 0, nil, gomock.Not(gomock.Nil())).
+			DoAndReturn(func(_ string, _ int, _ time.Time, _ string, _ int, _ *etcdstate.HLSParams, given *etcdstate.SRTP) error {
+				srtp = given
+				return nil
+			})
+		var written etcdstate.Mixer
+		s.mockEtcdClient.EXPECT().
+			Put(gomock.Any(), "/rooms/room-srtp/mixer", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, val string, _ ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+				s.Require().NoError(json.Unmarshal([]byte(val), &written))
+				return nil, nil
+			})
+
+		err := s.watcher.startRoomFFmpeg(s.ctx, roomID, livemeta, nil)
+		s.Require().NoError(err)
+
+		// FFmpeg decrypts with the key Janus is told to encrypt with
+		s.Require().NotNil(srtp)
+		s.Equal(etcdstate.SRTPSuiteHMAC80, srtp.Suite)
+		s.Len(srtp.Key, 40)
+		s.Equal(srtp, written.SRTP)
+	})
+
 	s.Run("port allocation fails", func() {
 		roomID := "room1"
-		livemeta := &etcdstate.LiveMeta{
-			Status:  constants.RoomStatusOnAir,
-			MixerID: "mixer-1",
-		}
+		livemeta := fixtures.LiveMeta()
 
 		s.mockPortMgr.EXPECT().
 			GetFreeRTPPort().
 			Return(0, errors.New("no free ports"))
 
-		err := s.watcher.startRoomFFmpeg(s.ctx, roomID, livemeta)
+		err := s.watcher.startRoomFFmpeg(s.ctx, roomID, livemeta, nil)
 
 		s.Require().Error(err)
 		s.Contains(err.Error(), "failed to allocate RTP port")
@@ -169,22 +201,17 @@ func (s *RoomWatcherTestSuite) TestStartRoomFFmpeg() {
 	s.Run("ffmpeg start fails", func() {
 		roomID := "room1"
 		port := 5004
-		livemeta := &etcdstate.LiveMeta{
-			Status:    constants.RoomStatusOnAir,
-			MixerID:   "mixer-1",
-			CreatedAt: time.Now(),
-			Nonce:     "abc123",
-		}
+		livemeta := fixtures.LiveMeta()
 
 		s.mockPortMgr.EXPECT().
 			GetFreeRTPPort().
 			Return(port, nil)
 
 		s.mockFFmpegMgr.EXPECT().
-			StartFFmpeg(roomID, port, livemeta.CreatedAt, livemeta.Nonce).
+			StartFFmpeg(roomID, port, livemeta.CreatedAt, livemeta.Nonce, 0, nil, nil).
 			Return(errors.New("ffmpeg error"))
 
-		err := s.watcher.startRoomFFmpeg(s.ctx, roomID, livemeta)
+		err := s.watcher.startRoomFFmpeg(s.ctx, roomID, livemeta, nil)
 
 		s.Require().Error(err)
 		s.Contains(err.Error(), "failed to start FFmpeg")
@@ -193,30 +220,64 @@ func (s *RoomWatcherTestSuite) TestStartRoomFFmpeg() {
 	s.Run("update mixer fails", func() {
 		roomID := "room1"
 		port := 5004
-		livemeta := &etcdstate.LiveMeta{
-			Status:    constants.RoomStatusOnAir,
-			MixerID:   "mixer-1",
-			CreatedAt: time.Now(),
-			Nonce:     "abc123",
-		}
+		livemeta := fixtures.LiveMeta()
 
 		s.mockPortMgr.EXPECT().
 			GetFreeRTPPort().
 			Return(port, nil)
 
 		s.mockFFmpegMgr.EXPECT().
-			StartFFmpeg(roomID, port, livemeta.CreatedAt, livemeta.Nonce).
+			StartFFmpeg(roomID, port, livemeta.CreatedAt, livemeta.Nonce, 0, nil, nil).
 			Return(nil)
 
 		s.mockEtcdClient.EXPECT().
 			Put(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(nil, errors.New("etcd error"))
 
-		err := s.watcher.startRoomFFmpeg(s.ctx, roomID, livemeta)
+		err := s.watcher.startRoomFFmpeg(s.ctx, roomID, livemeta, nil)
 
 		s.Require().Error(err)
 		s.Contains(err.Error(), "failed to update mixer data")
 	})
+
+	s.Run("refused without FFmpeg features", func() {
+		livemeta := fixtures.LiveMeta()
+		mgr := mocks.NewMockFFmpegManager(s.ctrl)
+		s.watcher.ffmpegManager = mgr
+		s.watcher.srtpSuite = etcdstate.SRTPSuiteHMAC80
+		defer func() {
+			s.watcher.ffmpegManager = s.mockFFmpegMgr
+			s.watcher.srtpSuite = ""
+		}()
+
+		mgr.EXPECT().Capabilities().Return(&mixers.Capabilities{
+			AudioEncoder: "aac",
+			Features:     []string{mixers.FeatureAAC, mixers.FeatureHLS, mixers.FeatureHLSEncryption, mixers.FeatureRTP},
+		})
+
+		err := s.watcher.startRoomFFmpeg(s.ctx, "room-unsupported", livemeta, nil)
+		s.Require().ErrorIs(err, mixers.ErrUnsupported)
+		s.Contains(err.Error(), mixers.FeatureSRTP)
+		s.NotContains(s.watcher.GetActiveRooms(), "room-unsupported")
+	})
+
+	s.Run("refused over the CPU budget", func() {
+		livemeta := fixtures.LiveMeta()
+		budget := NewCPUBudget(&BudgetConfig{CPU: 0.1, RoomCost: 0.1}, "mixer-1", nil, log.NewNop())
+		s.watcher.SetBudget(budget)
+		defer s.watcher.SetBudget(nil)
+		s.Require().NoError(budget.Admit(s.ctx, "busy", 0.1))
+
+		err := s.watcher.startRoomFFmpeg(s.ctx, "room-over", livemeta, nil)
+		s.Require().ErrorIs(err, ErrBudgetExceeded)
+		s.NotContains(s.watcher.GetActiveRooms(), "room-over")
+
+		// the cost of a room failing to start is released
+		budget.Release(s.ctx, "busy")
+		s.mockPortMgr.EXPECT().GetFreeRTPPort().Return(0, errors.New("no free ports"))
+		s.Require().Error(s.watcher.startRoomFFmpeg(s.ctx, "room-over", livemeta, nil))
+		s.Zero(budget.Used())
+	})
 }
 
 func (s *RoomWatcherTestSuite) TestStopRoomFFmpeg() {
@@ -229,7 +290,10 @@ func (s *RoomWatcherTestSuite) TestStopRoomFFmpeg() {
 			Return(nil)
 
 		s.mockEtcdClient.EXPECT().
-			Delete(gomock.Any(), gomock.Any()).
+			Delete(gomock.Any(), "/rooms/room1/mixer").
+			Return(nil, nil)
+		s.mockEtcdClient.EXPECT().
+			Delete(gomock.Any(), "/rooms/room1/latency").
 			Return(nil, nil)
 
 		err := s.watcher.stopRoomFFmpeg(s.ctx, roomID, true)
@@ -319,12 +383,7 @@ func (s *RoomWatcherTestSuite) TestProcessChange() {
 		roomID := "room1"
 		port := 5004
 		state := &etcdstate.RoomState{
-			LiveMeta: &etcdstate.LiveMeta{
-				Status:    constants.RoomStatusOnAir,
-				MixerID:   "mixer-1",
-				CreatedAt: time.Now(),
-				Nonce:     "abc123",
-			},
+			LiveMeta: fixtures.LiveMeta(),
 		}
 
 		s.mockPortMgr.EXPECT().
@@ -332,7 +391,7 @@ func (s *RoomWatcherTestSuite) TestProcessChange() {
 			Return(port, nil)
 
 		s.mockFFmpegMgr.EXPECT().
-			StartFFmpeg(roomID, port, state.LiveMeta.CreatedAt, state.LiveMeta.Nonce).
+			StartFFmpeg(roomID, port, state.LiveMeta.CreatedAt, state.LiveMeta.Nonce, 0, nil, nil).
 			Return(nil)
 
 		s.mockEtcdClient.EXPECT().
@@ -345,16 +404,62 @@ func (s *RoomWatcherTestSuite) TestProcessChange() {
 		s.Contains(s.watcher.GetActiveRooms(), roomID)
 	})
 
+	s.Run("start room with DVR window of room meta", func() {
+		roomID := "room-dvr"
+		port := 5006
+		state := &etcdstate.RoomState{
+			Meta:     &etcdstate.Meta{DVRWindow: 1800},
+			LiveMeta: fixtures.LiveMeta(),
+		}
+
+		s.mockPortMgr.EXPECT().
+			GetFreeRTPPort().
+			Return(port, nil)
+
+		s.mockFFmpegMgr.EXPECT().
+			StartFFmpeg(roomID, port, state.LiveMeta.CreatedAt, state.LiveMeta.Nonce, 1800, nil, nil).
+			Return(nil)
+
+		s.mockEtcdClient.EXPECT().
+			Put(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, nil)
+
+		err := s.watcher.processChange(s.ctx, roomID, state)
+		s.Require().NoError(err)
+	})
+
+	s.Run("start room with HLS overrides of room meta", func() {
+		roomID := "room-hls"
+		port := 5008
+		hls := &etcdstate.HLSParams{SegmentDuration: 4, PlaylistSize: 8}
+		state := &etcdstate.RoomState{
+			Meta:     &etcdstate.Meta{HLS: hls},
+			LiveMeta: fixtures.LiveMeta(),
+		}
+
+		s.mockPortMgr.EXPECT().
+			GetFreeRTPPort().
+			Return(port, nil)
+
+		s.mockFFmpegMgr.EXPECT().
+			StartFFmpeg(roomID, port, state.LiveMeta.CreatedAt, state.LiveMeta.Nonce, 0, hls, nil).
+			Return(nil)
+
+		s.mockEtcdClient.EXPECT().
+			Put(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, nil)
+
+		err := s.watcher.processChange(s.ctx, roomID, state)
+		s.Require().NoError(err)
+	})
+
 	s.Run("sync mixer data when running but not state runner", func() {
 		roomID := "room1"
 		port := 5004
 		s.watcher.activeRooms.Store(roomID, &ActiveRoom{Port: port, Status: "running"})
 
 		state := &etcdstate.RoomState{
-			LiveMeta: &etcdstate.LiveMeta{
-				Status:  constants.RoomStatusOnAir,
-				MixerID: "mixer-1",
-			},
+			LiveMeta: fixtures.LiveMeta(),
 			Mixer: &etcdstate.Mixer{
 				ID:   "mixer-2",
 				Port: 5006,
@@ -375,10 +480,7 @@ func (s *RoomWatcherTestSuite) TestProcessChange() {
 		s.watcher.activeRooms.Store(roomID, &ActiveRoom{Port: 5004, Status: "running"})
 
 		state := &etcdstate.RoomState{
-			LiveMeta: &etcdstate.LiveMeta{
-				Status:  constants.RoomStatusRemoving,
-				MixerID: "mixer-1",
-			},
+			LiveMeta: fixtures.LiveMeta(fixtures.LiveStatus(constants.RoomStatusRemoving)),
 			Mixer: &etcdstate.Mixer{
 				ID: "mixer-1",
 			},
@@ -389,7 +491,10 @@ func (s *RoomWatcherTestSuite) TestProcessChange() {
 			Return(nil)
 
 		s.mockEtcdClient.EXPECT().
-			Delete(gomock.Any(), gomock.Any()).
+			Delete(gomock.Any(), "/rooms/room1/mixer").
+			Return(nil, nil)
+		s.mockEtcdClient.EXPECT().
+			Delete(gomock.Any(), "/rooms/room1/latency").
 			Return(nil, nil)
 
 		err := s.watcher.processChange(s.ctx, roomID, state)
@@ -401,10 +506,7 @@ func (s *RoomWatcherTestSuite) TestProcessChange() {
 	s.Run("do nothing when already in correct state", func() {
 		roomID := "room1"
 		state := &etcdstate.RoomState{
-			LiveMeta: &etcdstate.LiveMeta{
-				Status:  constants.RoomStatusRemoving,
-				MixerID: "mixer-1",
-			},
+			LiveMeta: fixtures.LiveMeta(fixtures.LiveStatus(constants.RoomStatusRemoving)),
 		}
 
 		err := s.watcher.processChange(s.ctx, roomID, state)
@@ -420,13 +522,71 @@ func (s *RoomWatcherTestSuite) TestProcessChange() {
 		s.Require().NoError(err)
 	})
 
+	s.Run("restart room on a fresh port when mixer data is stale", func() {
+		roomID := "room-crashed"
+		port := 5010
+		state := &etcdstate.RoomState{
+			LiveMeta: fixtures.LiveMeta(),
+			Mixer:    &etcdstate.Mixer{ID: "mixer-1", Port: 5002},
+		}
+
+		s.mockPortMgr.EXPECT().
+			GetFreeRTPPort().
+			Return(port, nil)
+		s.mockFFmpegMgr.EXPECT().
+			StartFFmpeg(roomID, port, state.LiveMeta.CreatedAt, state.LiveMeta.Nonce, 0, nil, nil).
+			Return(nil)
+		s.mockEtcdClient.EXPECT().
+			Put(gomock.Any(), "/rooms/room-crashed/mixer", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, val string, _ ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+				var mixer etcdstate.Mixer
+				s.Require().NoError(json.Unmarshal([]byte(val), &mixer))
+				s.Equal(port, mixer.Port)
+				return nil, nil
+			})
+
+		err := s.watcher.processChange(s.ctx, roomID, state)
+
+		s.Require().NoError(err)
+		s.Contains(s.watcher.GetActiveRooms(), roomID)
+	})
+
+	s.Run("clear stale mixer data of room no longer assigned", func() {
+		roomID := "room-stale"
+		state := &etcdstate.RoomState{
+			LiveMeta: fixtures.LiveMeta(fixtures.LiveMixer("mixer-2")),
+			Mixer:    &etcdstate.Mixer{ID: "mixer-1", Port: 5002},
+		}
+
+		s.mockEtcdClient.EXPECT().
+			Delete(gomock.Any(), "/rooms/room-stale/mixer").
+			Return(nil, nil)
+		s.mockEtcdClient.EXPECT().
+			Delete(gomock.Any(), "/rooms/room-stale/latency").
+			Return(nil, nil)
+
+		err := s.watcher.processChange(s.ctx, roomID, state)
+
+		s.Require().NoError(err)
+		s.NotContains(s.watcher.GetActiveRooms(), roomID)
+	})
+
+	s.Run("keep mixer data of another mixer", func() {
+		roomID := "room-other"
+		state := &etcdstate.RoomState{
+			LiveMeta: fixtures.LiveMeta(fixtures.LiveMixer("mixer-2")),
+			Mixer:    &etcdstate.Mixer{ID: "mixer-2", Port: 5002},
+		}
+
+		err := s.watcher.processChange(s.ctx, roomID, state)
+
+		s.Require().NoError(err)
+	})
+
 	s.Run("different mixer ID should not start", func() {
 		roomID := "room1"
 		state := &etcdstate.RoomState{
-			LiveMeta: &etcdstate.LiveMeta{
-				Status:  constants.RoomStatusOnAir,
-				MixerID: "mixer-2",
-			},
+			LiveMeta: fixtures.LiveMeta(fixtures.LiveMixer("mixer-2")),
 		}
 
 		err := s.watcher.processChange(s.ctx, roomID, state)
@@ -436,6 +596,101 @@ func (s *RoomWatcherTestSuite) TestProcessChange() {
 	})
 }
 
+func (s *RoomWatcherTestSuite) TestSyncLink() {
+	onAir := fixtures.LiveMeta()
+
+	s.Run("add link input and publish link port", func() {
+		roomID := "room1"
+		s.watcher.activeRooms.Store(roomID, &ActiveRoom{Port: 5004, Status: "running"})
+
+		state := &etcdstate.RoomState{
+			LiveMeta: onAir,
+			Mixer:    &etcdstate.Mixer{ID: "mixer-1", Port: 5004},
+			Link:     &etcdstate.Link{SourceRoomID: "room2"},
+		}
+
+		s.mockPortMgr.EXPECT().
+			GetFreeRTPPort().
+			Return(5008, nil)
+		s.mockFFmpegMgr.EXPECT().
+			SetLink(roomID, 5008).
+			Return(nil)
+
+		expectedJSON, _ := json.Marshal(etcdstate.Mixer{
+			ID:         "mixer-1",
+			IP:         "192.168.1.100",
+			Port:       5004,
+			LinkPort:   5008,
+			MarkerPort: 5300,
+		})
+		s.mockEtcdClient.EXPECT().
+			Put(gomock.Any(), "/rooms/room1/mixer", string(expectedJSON)).
+			Return(nil, nil)
+
+		err := s.watcher.processChange(s.ctx, roomID, state)
+
+		s.Require().NoError(err)
+		s.Equal(5008, s.watcher.GetActiveRooms()[roomID].LinkPort)
+	})
+
+	s.Run("already linked and published", func() {
+		roomID := "room1"
+		s.watcher.activeRooms.Store(roomID, &ActiveRoom{Port: 5004, LinkPort: 5008, Status: "running"})
+
+		state := &etcdstate.RoomState{
+			LiveMeta: onAir,
+			Mixer:    &etcdstate.Mixer{ID: "mixer-1", Port: 5004, LinkPort: 5008},
+			Link:     &etcdstate.Link{SourceRoomID: "room2"},
+		}
+
+		err := s.watcher.processChange(s.ctx, roomID, state)
+
+		s.Require().NoError(err)
+	})
+
+	s.Run("remove link input when unlinked", func() {
+		roomID := "room1"
+		s.watcher.activeRooms.Store(roomID, &ActiveRoom{Port: 5004, LinkPort: 5008, Status: "running"})
+
+		state := &etcdstate.RoomState{
+			LiveMeta: onAir,
+			Mixer:    &etcdstate.Mixer{ID: "mixer-1", Port: 5004, LinkPort: 5008},
+		}
+
+		s.mockFFmpegMgr.EXPECT().
+			SetLink(roomID, 0).
+			Return(nil)
+		s.mockEtcdClient.EXPECT().
+			Put(gomock.Any(), "/rooms/room1/mixer", gomock.Any()).
+			Return(nil, nil)
+
+		err := s.watcher.processChange(s.ctx, roomID, state)
+
+		s.Require().NoError(err)
+		s.Zero(s.watcher.GetActiveRooms()[roomID].LinkPort)
+	})
+
+	s.Run("link port allocation failure", func() {
+		roomID := "room1"
+		s.watcher.activeRooms.Store(roomID, &ActiveRoom{Port: 5004, Status: "running"})
+
+		state := &etcdstate.RoomState{
+			LiveMeta: onAir,
+			Mixer:    &etcdstate.Mixer{ID: "mixer-1", Port: 5004},
+			Link:     &etcdstate.Link{SourceRoomID: "room2"},
+		}
+
+		s.mockPortMgr.EXPECT().
+			GetFreeRTPPort().
+			Return(0, errors.New("no ports"))
+
+		err := s.watcher.processChange(s.ctx, roomID, state)
+
+		s.Require().Error(err)
+		s.Contains(err.Error(), "failed to allocate link RTP port")
+	})
+}
+
 func (s *RoomWatcherTestSuite) TestGetActiveRooms() {
 	s.Run("get empty active rooms", func() {
 		rooms := s.watcher.GetActiveRooms()
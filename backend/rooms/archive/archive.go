@@ -0,0 +1,197 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// Config of room archive manifests, written when a room is purged. Empty Dir and URL
+// disable archiving
+type Config struct {
+	// Dir is a local or mounted directory manifests are written to
+	Dir string `mapstructure:"dir"`
+	// URL is an object storage base URL, manifests are uploaded with PUT <url>/<name>
+	URL string `mapstructure:"url"`
+	// Token is sent as bearer token on uploads, empty sends none
+	Token   string        `mapstructure:"token"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("dir"), "")
+	v.SetDefault(p("url"), "")
+	v.SetDefault(p("token"), "")
+	v.SetDefault(p("timeout"), "10s")
+}
+
+// Manifest describes a purged room for audit and VOD ingestion
+type Manifest struct {
+	RoomID     string    `json:"roomId"`
+	MaxAnchors int       `json:"maxAnchors"`
+	MaxBitrate int       `json:"maxBitrate,omitempty"`
+	DVRWindow  int       `json:"dvrWindow,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// StartedAt and EndedAt bound the live, unset when the room never went live
+	StartedAt       *time.Time `json:"startedAt,omitempty"`
+	EndedAt         *time.Time `json:"endedAt,omitempty"`
+	LiveDurationSec int64      `json:"liveDurationSec"`
+	MixerID         string     `json:"mixerId,omitempty"`
+	JanusID         string     `json:"janusId,omitempty"`
+	Anchors         []string   `json:"anchors"`
+	// Recordings are HLS playlist paths relative to the HLS root
+	Recordings []string `json:"recordings"`
+	// KeyVersions are nonces the HLS keys of the room were derived from
+	KeyVersions []string  `json:"keyVersions"`
+	ArchivedAt  time.Time `json:"archivedAt"`
+}
+
+// Name is the object name of the manifest, stable across retries of the same room
+func (m *Manifest) Name() string {
+	return fmt.Sprintf("%s-%d.json", m.RoomID, m.CreatedAt.Unix())
+}
+
+// NewManifest builds the manifest of a room from its etcd state, the pin is left out
+func NewManifest(roomID string, state *etcdstate.RoomState, now time.Time) *Manifest {
+	meta := state.GetMeta()
+	m := &Manifest{
+		RoomID:      roomID,
+		MaxAnchors:  meta.GetMaxAnchors(),
+		MaxBitrate:  meta.GetMaxBitrate(),
+		DVRWindow:   meta.GetDVRWindow(),
+		CreatedAt:   meta.GetCreatedAt(),
+		Anchors:     []string{},
+		Recordings:  []string{},
+		KeyVersions: []string{},
+		ArchivedAt:  now,
+	}
+	m.Anchors = append(m.Anchors, state.GetAnchors().GetUserIDs()...)
+
+	liveMeta := state.GetLiveMeta()
+	if liveMeta == nil {
+		return m
+	}
+
+	startedAt := liveMeta.GetCreatedAt()
+	endedAt := now
+	if discardAt := liveMeta.GetDiscardAt(); discardAt != nil {
+		endedAt = *discardAt
+	}
+	m.StartedAt = &startedAt
+	m.EndedAt = &endedAt
+	m.LiveDurationSec = int64(max(endedAt.Sub(startedAt), 0) / time.Second)
+	m.MixerID = liveMeta.GetMixerID()
+	m.JanusID = liveMeta.GetJanusID()
+	if hlsPath := meta.GetHLSPath(); hlsPath != "" {
+		m.Recordings = append(m.Recordings, hlsPath)
+	}
+	if nonce := liveMeta.GetNonce(); nonce != "" {
+		m.KeyVersions = append(m.KeyVersions, nonce)
+	}
+	return m
+}
+
+// Archiver stores manifests of purged rooms
+type Archiver interface {
+	Archive(ctx context.Context, m *Manifest) error
+}
+
+// New returns an archiver writing to every configured target, nil when none is configured
+func New(cfg *Config, logger *log.Logger) Archiver {
+	var targets multiArchiver
+	if cfg.Dir != "" {
+		targets = append(targets, &dirArchiver{dir: cfg.Dir})
+	}
+	if cfg.URL != "" {
+		client := resty.New().
+			SetTimeout(cfg.Timeout).
+			SetHeader("Content-Type", "application/json")
+		if cfg.Token != "" {
+			client.SetAuthToken(cfg.Token)
+		}
+		targets = append(targets, &httpArchiver{
+			baseURL: strings.TrimRight(cfg.URL, "/"),
+			client:  client,
+		})
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	logger.Info("Room archiving enabled", log.String("dir", cfg.Dir), log.String("url", cfg.URL))
+	return targets
+}
+
+type multiArchiver []Archiver
+
+func (ms multiArchiver) Archive(ctx context.Context, m *Manifest) error {
+	var errs []error
+	for _, a := range ms {
+		errs = append(errs, a.Archive(ctx, m))
+	}
+	return errors.Join(errs...)
+}
+
+type dirArchiver struct {
+	dir string
+}
+
+// Archive writes to a temp file renamed into place, readers never see partial manifests
+func (a *dirArchiver) Archive(_ context.Context, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := os.MkdirAll(a.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create archive dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(a.dir, ".manifest-*")
+	if err != nil {
+		return fmt.Errorf("failed to create manifest: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(a.dir, m.Name())); err != nil {
+		return fmt.Errorf("failed to rename manifest: %w", err)
+	}
+	return nil
+}
+
+type httpArchiver struct {
+	baseURL string
+	client  *resty.Client
+}
+
+func (a *httpArchiver) Archive(ctx context.Context, m *Manifest) error {
+	url := a.baseURL + "/" + m.Name()
+	resp, err := a.client.R().
+		SetContext(ctx).
+		SetBody(m).
+		Put(url)
+	if err != nil {
+		return fmt.Errorf("failed to upload manifest: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("failed to upload manifest: %s", resp.Status())
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type ArchiveSuite struct {
+	suite.Suite
+	now   time.Time
+	state *etcdstate.RoomState
+}
+
+func TestArchiveSuite(t *testing.T) {
+	suite.Run(t, new(ArchiveSuite))
+}
+
+func (s *ArchiveSuite) SetupTest() {
+	s.now = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	discardAt := s.now.Add(-time.Minute)
+	s.state = &etcdstate.RoomState{
+		Meta: &etcdstate.Meta{
+			Pin:        "123456",
+			HLSPath:    "room1/stream.m3u8",
+			MaxAnchors: 3,
+			CreatedAt:  s.now.Add(-time.Hour),
+		},
+		LiveMeta: &etcdstate.LiveMeta{
+			Status:    constants.RoomStatusRemoving,
+			MixerID:   "mixer1",
+			JanusID:   "janus1",
+			CreatedAt: s.now.Add(-31 * time.Minute),
+			DiscardAt: &discardAt,
+			Nonce:     "nonce1",
+		},
+		Anchors: &etcdstate.Anchors{UserIDs: []string{"user1", "user2"}},
+	}
+}
+
+func (s *ArchiveSuite) TestNewManifest() {
+	m := NewManifest("room1", s.state, s.now)
+
+	s.Equal("room1", m.RoomID)
+	s.Equal(3, m.MaxAnchors)
+	s.Equal(int64(30*60), m.LiveDurationSec)
+	s.Equal("mixer1", m.MixerID)
+	s.Equal([]string{"user1", "user2"}, m.Anchors)
+	s.Equal([]string{"room1/stream.m3u8"}, m.Recordings)
+	s.Equal([]string{"nonce1"}, m.KeyVersions)
+	s.Equal(fmt.Sprintf("room1-%d.json", s.now.Add(-time.Hour).Unix()), m.Name())
+
+	data, err := json.Marshal(m)
+	s.Require().NoError(err)
+	s.NotContains(string(data), "123456")
+}
+
+func (s *ArchiveSuite) TestNewManifest_NeverLive() {
+	s.state.LiveMeta = nil
+	s.state.Anchors = nil
+
+	m := NewManifest("room1", s.state, s.now)
+	s.Nil(m.StartedAt)
+	s.Zero(m.LiveDurationSec)
+	s.Empty(m.Recordings)
+	s.Empty(m.KeyVersions)
+	s.NotNil(m.Anchors, "lists are written as empty arrays")
+}
+
+func (s *ArchiveSuite) TestNew_Disabled() {
+	s.Nil(New(&Config{}, log.NewNop()))
+}
+
+func (s *ArchiveSuite) TestArchive_Dir() {
+	dir := filepath.Join(s.T().TempDir(), "archive")
+	a := New(&Config{Dir: dir}, log.NewNop())
+	m := NewManifest("room1", s.state, s.now)
+
+	s.Require().NoError(a.Archive(context.Background(), m))
+	// retries overwrite the same manifest
+	s.Require().NoError(a.Archive(context.Background(), m))
+
+	entries, err := os.ReadDir(dir)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Equal(m.Name(), entries[0].Name())
+
+	data, err := os.ReadFile(filepath.Join(dir, m.Name()))
+	s.Require().NoError(err)
+	var got Manifest
+	s.Require().NoError(json.Unmarshal(data, &got))
+	s.Equal(m.Anchors, got.Anchors)
+}
+
+func (s *ArchiveSuite) TestArchive_URL() {
+	var gotPath, gotAuth string
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodPut, r.Method)
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	a := New(&Config{URL: srv.URL + "/archive/", Token: "secret", Timeout: time.Second}, log.NewNop())
+	m := NewManifest("room1", s.state, s.now)
+
+	s.Require().NoError(a.Archive(context.Background(), m))
+	s.Equal("/archive/"+m.Name(), gotPath)
+	s.Equal("Bearer secret", gotAuth)
+	s.Contains(string(gotBody), `"keyVersions":["nonce1"]`)
+}
+
+func (s *ArchiveSuite) TestArchive_URLError() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	dir := s.T().TempDir()
+	a := New(&Config{Dir: dir, URL: srv.URL, Timeout: time.Second}, log.NewNop())
+
+	err := a.Archive(context.Background(), NewManifest("room1", s.state, s.now))
+	s.Require().Error(err)
+	s.Contains(err.Error(), "403")
+}
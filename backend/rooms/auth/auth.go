@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/svcauth"
+	"github.com/imtaco/audio-rtc-exp/rooms"
+)
+
+const (
+	keyCacheSize   = 1024
+	unknownKeyTTL  = 5 * time.Second
+	adminPrincipal = "admin"
+)
+
+// Config of the rooms API authentication, requests carry an API key or a service JWT as
+// "Authorization: Bearer <token>"
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	// AdminKey is a bootstrap token with all scopes, used to manage API keys
+	AdminKey string `mapstructure:"admin_key"`
+	// ServiceSecret verifies HS256 service JWTs with sub and scopes claims, empty disables them
+	ServiceSecret string `mapstructure:"service_secret"`
+	// RateLimit is requests per minute of keys without their own limit and services, 0 is unlimited
+	RateLimit int `mapstructure:"rate_limit"`
+	// CacheTTL bounds how long a deleted key is still accepted
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	// FailureLimit is failed authentications per minute of a client IP before it gets 429, 0 is unlimited
+	FailureLimit int `mapstructure:"failure_limit"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), false)
+	v.SetDefault(p("admin_key"), "")
+	v.SetDefault(p("service_secret"), "")
+	v.SetDefault(p("rate_limit"), 600)
+	v.SetDefault(p("cache_ttl"), 30*time.Second)
+	v.SetDefault(p("failure_limit"), 30)
+}
+
+// Authenticator checks API keys and service JWTs, verified keys are cached for CacheTTL and
+// unknown key IDs briefly, so guessed IDs do not reach the store on every request. Callers are
+// stored as svcauth principals, read them with svcauth.PrincipalFrom
+type Authenticator struct {
+	cfg      *Config
+	store    rooms.APIKeyStore
+	keys     *expirable.LRU[string, *rooms.APIKey]
+	unknown  *expirable.LRU[string, struct{}]
+	limiter  *limiter
+	failures *limiter // failed authentications per client IP
+	service  *svcauth.ServiceVerifier
+	now      func() time.Time
+	logger   *log.Logger
+}
+
+// NewAuthenticator returns nil when auth is disabled, Require is a no-op on nil
+func NewAuthenticator(cfg *Config, store rooms.APIKeyStore, logger *log.Logger) *Authenticator {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	return &Authenticator{
+		cfg:      cfg,
+		store:    store,
+		keys:     expirable.NewLRU[string, *rooms.APIKey](keyCacheSize, nil, cfg.CacheTTL),
+		unknown:  expirable.NewLRU[string, struct{}](keyCacheSize, nil, unknownKeyTTL),
+		limiter:  newLimiter(),
+		failures: newLimiter(),
+		service:  svcauth.NewServiceVerifier(),
+		now:      time.Now,
+		logger:   logger,
+	}
+}
+
+// Require authenticates the request, checks scope ("" for any authenticated caller) and
+// applies the rate limit of the caller
+func (a *Authenticator) Require(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a == nil {
+			c.Next()
+			return
+		}
+
+		clientIP := c.ClientIP()
+		if wait, blocked := a.failures.exhausted(clientIP, a.cfg.FailureLimit, a.now()); blocked {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			svcauth.Abort(c, http.StatusTooManyRequests, "Too many failed authentications")
+			return
+		}
+
+		principal, authErr := a.authenticate(c.Request.Context(), c.Request)
+		if authErr != nil {
+			if authErr.status == http.StatusUnauthorized {
+				a.failures.allow(clientIP, a.cfg.FailureLimit, a.now())
+			}
+			svcauth.Abort(c, authErr.status, authErr.msg)
+			return
+		}
+		if !principal.HasScope(scope) {
+			svcauth.Abort(c, http.StatusForbidden, fmt.Sprintf("API key lacks scope %s", scope))
+			return
+		}
+
+		limit := principal.RateLimit
+		if limit == 0 {
+			limit = a.cfg.RateLimit
+		}
+		if wait, ok := a.limiter.allow(principal.ID, limit, a.now()); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			svcauth.Abort(c, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+
+		svcauth.SetPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// Forget drops a deleted key from the cache and rate limiter of this instance
+func (a *Authenticator) Forget(keyID string) {
+	if a == nil {
+		return
+	}
+	a.keys.Remove(keyID)
+	a.unknown.Remove(keyID)
+	a.limiter.forget(keyID)
+}
+
+// authError is the response of a rejected request
+type authError struct {
+	status int
+	msg    string
+}
+
+var (
+	errMissingKey  = &authError{http.StatusUnauthorized, "Missing API key"}
+	errInvalidKey  = &authError{http.StatusUnauthorized, "Invalid API key"}
+	errInvalidSvc  = &authError{http.StatusUnauthorized, "Invalid service token"}
+	errUnavailable = &authError{http.StatusServiceUnavailable, "Authentication unavailable"}
+)
+
+func (a *Authenticator) authenticate(ctx context.Context, req *http.Request) (*svcauth.Principal, *authError) {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, errMissingKey
+	}
+
+	if a.cfg.AdminKey != "" && verifyAdminKey(token, a.cfg.AdminKey) {
+		return &svcauth.Principal{ID: adminPrincipal, Scopes: []string{rooms.ScopeAdmin}}, nil
+	}
+	// API keys have a single dot, JWTs two
+	if strings.Count(token, ".") == 2 {
+		return a.authenticateService(token)
+	}
+	return a.authenticateKey(ctx, token)
+}
+
+func (a *Authenticator) authenticateKey(ctx context.Context, token string) (*svcauth.Principal, *authError) {
+	id, secret, ok := splitToken(token)
+	if !ok {
+		return nil, errInvalidKey
+	}
+
+	if _, unknown := a.unknown.Get(id); unknown {
+		return nil, errInvalidKey
+	}
+	key, cached := a.keys.Get(id)
+	if !cached {
+		var err error
+		if key, err = a.store.GetAPIKey(ctx, id); err != nil {
+			a.logger.Error("Failed to get API key", log.String("keyId", id), log.Error(err))
+			return nil, errUnavailable
+		}
+		if key == nil {
+			a.unknown.Add(id, struct{}{})
+			return nil, errInvalidKey
+		}
+		a.keys.Add(id, key)
+	}
+	if !verifySecret(key, secret) {
+		return nil, errInvalidKey
+	}
+
+	return &svcauth.Principal{
+		ID:        key.ID,
+		Tenant:    key.Tenant,
+		Scopes:    key.Scopes,
+		RateLimit: key.RateLimit,
+	}, nil
+}
+
+func (a *Authenticator) authenticateService(token string) (*svcauth.Principal, *authError) {
+	principal, ok := a.service.Verify(token, a.cfg.ServiceSecret)
+	if !ok {
+		return nil, errInvalidSvc
+	}
+	return principal, nil
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/svcauth"
+	"github.com/imtaco/audio-rtc-exp/rooms"
+	"github.com/imtaco/audio-rtc-exp/rooms/mocks"
+)
+
+type AuthenticatorSuite struct {
+	suite.Suite
+	ctrl      *gomock.Controller
+	mockStore *mocks.MockAPIKeyStore
+	cfg       *Config
+	auth      *Authenticator
+	now       time.Time
+	key       *rooms.APIKey
+	token     string
+}
+
+func TestAuthenticatorSuite(t *testing.T) {
+	suite.Run(t, new(AuthenticatorSuite))
+}
+
+func (s *AuthenticatorSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	s.ctrl = gomock.NewController(s.T())
+	s.mockStore = mocks.NewMockAPIKeyStore(s.ctrl)
+	s.cfg = &Config{
+		Enabled:       true,
+		AdminKey:      "admin-secret",
+		ServiceSecret: "svc-secret",
+		RateLimit:     600,
+		CacheTTL:      time.Minute,
+	}
+	s.now = time.Now()
+	s.auth = NewAuthenticator(s.cfg, s.mockStore, log.NewTest(s.T()))
+	s.auth.now = func() time.Time { return s.now }
+
+	var err error
+	s.key, s.token, err = NewAPIKey("acme", []string{rooms.ScopeCreate}, 0)
+	s.Require().NoError(err)
+}
+
+func (s *AuthenticatorSuite) TearDownTest() {
+	s.ctrl.Finish()
+}
+
+func (s *AuthenticatorSuite) do(a *Authenticator, scope, token string) (*httptest.ResponseRecorder, *svcauth.Principal) {
+	var principal *svcauth.Principal
+	engine := gin.New()
+	engine.GET("/test", a.Require(scope), func(c *gin.Context) {
+		principal = svcauth.PrincipalFrom(c)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	engine.ServeHTTP(w, req)
+	return w, principal
+}
+
+func (s *AuthenticatorSuite) serviceToken(secret string, exp time.Time) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, svcauth.ServiceClaims{
+		Scopes: []string{rooms.ScopeMarkModules},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "mixers",
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	})
+	signed, err := token.SignedString([]byte(secret))
+	s.Require().NoError(err)
+	return signed
+}
+
+func (s *AuthenticatorSuite) TestDisabled() {
+	a := NewAuthenticator(&Config{Enabled: false}, s.mockStore, log.NewTest(s.T()))
+	s.Nil(a)
+
+	w, principal := s.do(a, rooms.ScopeCreate, "")
+	s.Equal(http.StatusOK, w.Code)
+	s.Nil(principal)
+}
+
+func (s *AuthenticatorSuite) TestMissingKey() {
+	w, _ := s.do(s.auth, rooms.ScopeCreate, "")
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func (s *AuthenticatorSuite) TestValidKey() {
+	s.mockStore.EXPECT().GetAPIKey(gomock.Any(), s.key.ID).Return(s.key, nil)
+
+	w, principal := s.do(s.auth, rooms.ScopeCreate, s.token)
+	s.Equal(http.StatusOK, w.Code)
+	s.Require().NotNil(principal)
+	s.Equal(s.key.ID, principal.ID)
+	s.Equal("acme", principal.Tenant)
+}
+
+func (s *AuthenticatorSuite) TestWrongSecret() {
+	s.mockStore.EXPECT().GetAPIKey(gomock.Any(), s.key.ID).Return(s.key, nil)
+
+	w, _ := s.do(s.auth, rooms.ScopeCreate, s.key.ID+".wrong")
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func (s *AuthenticatorSuite) TestUnknownKey() {
+	s.mockStore.EXPECT().GetAPIKey(gomock.Any(), s.key.ID).Return(nil, nil)
+
+	w, _ := s.do(s.auth, rooms.ScopeCreate, s.token)
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func (s *AuthenticatorSuite) TestUnknownKey_Cached() {
+	s.mockStore.EXPECT().GetAPIKey(gomock.Any(), s.key.ID).Return(nil, nil).Times(1)
+
+	for range 3 {
+		w, _ := s.do(s.auth, rooms.ScopeCreate, s.token)
+		s.Equal(http.StatusUnauthorized, w.Code)
+	}
+}
+
+func (s *AuthenticatorSuite) TestFailureLimit() {
+	s.cfg.FailureLimit = 2
+
+	for range 2 {
+		w, _ := s.do(s.auth, rooms.ScopeCreate, "bad-token")
+		s.Equal(http.StatusUnauthorized, w.Code)
+	}
+
+	// blocked before the key is looked up, valid keys included
+	w, _ := s.do(s.auth, rooms.ScopeCreate, s.token)
+	s.Equal(http.StatusTooManyRequests, w.Code)
+	s.Equal("30", w.Header().Get("Retry-After"))
+
+	s.now = s.now.Add(30 * time.Second)
+	s.mockStore.EXPECT().GetAPIKey(gomock.Any(), s.key.ID).Return(s.key, nil)
+	w, _ = s.do(s.auth, rooms.ScopeCreate, s.token)
+	s.Equal(http.StatusOK, w.Code)
+}
+
+func (s *AuthenticatorSuite) TestMissingScope() {
+	s.mockStore.EXPECT().GetAPIKey(gomock.Any(), s.key.ID).Return(s.key, nil)
+
+	w, _ := s.do(s.auth, rooms.ScopeDelete, s.token)
+	s.Equal(http.StatusForbidden, w.Code)
+	s.Contains(w.Body.String(), "API key lacks scope delete")
+}
+
+func (s *AuthenticatorSuite) TestStoreError() {
+	s.mockStore.EXPECT().GetAPIKey(gomock.Any(), s.key.ID).Return(nil, errors.New("etcd down"))
+
+	w, _ := s.do(s.auth, rooms.ScopeCreate, s.token)
+	s.Equal(http.StatusServiceUnavailable, w.Code)
+}
+
+func (s *AuthenticatorSuite) TestAdminKey() {
+	w, principal := s.do(s.auth, rooms.ScopeDelete, "admin-secret")
+	s.Equal(http.StatusOK, w.Code)
+	s.Require().NotNil(principal)
+	s.Equal(adminPrincipal, principal.ID)
+}
+
+func (s *AuthenticatorSuite) TestServiceToken() {
+	w, principal := s.do(s.auth, rooms.ScopeMarkModules, s.serviceToken("svc-secret", s.now.Add(time.Minute)))
+	s.Equal(http.StatusOK, w.Code)
+	s.Require().NotNil(principal)
+	s.Equal("svc:mixers", principal.ID)
+
+	w, _ = s.do(s.auth, rooms.ScopeCreate, s.serviceToken("svc-secret", s.now.Add(time.Minute)))
+	s.Equal(http.StatusForbidden, w.Code)
+}
+
+func (s *AuthenticatorSuite) TestServiceToken_Invalid() {
+	w, _ := s.do(s.auth, rooms.ScopeMarkModules, s.serviceToken("other-secret", s.now.Add(time.Minute)))
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	w, _ = s.do(s.auth, rooms.ScopeMarkModules, s.serviceToken("svc-secret", s.now.Add(-time.Hour)))
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	s.cfg.ServiceSecret = ""
+	w, _ = s.do(s.auth, rooms.ScopeMarkModules, s.serviceToken("svc-secret", s.now.Add(time.Minute)))
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func (s *AuthenticatorSuite) TestRateLimit() {
+	s.key.RateLimit = 2
+	s.mockStore.EXPECT().GetAPIKey(gomock.Any(), s.key.ID).Return(s.key, nil)
+
+	for range 2 {
+		w, _ := s.do(s.auth, rooms.ScopeCreate, s.token)
+		s.Equal(http.StatusOK, w.Code)
+	}
+
+	w, _ := s.do(s.auth, rooms.ScopeCreate, s.token)
+	s.Equal(http.StatusTooManyRequests, w.Code)
+	s.Equal("30", w.Header().Get("Retry-After"))
+
+	s.now = s.now.Add(30 * time.Second)
+	w, _ = s.do(s.auth, rooms.ScopeCreate, s.token)
+	s.Equal(http.StatusOK, w.Code)
+}
+
+func (s *AuthenticatorSuite) TestCacheAndForget() {
+	s.mockStore.EXPECT().GetAPIKey(gomock.Any(), s.key.ID).Return(s.key, nil)
+
+	for range 2 {
+		w, _ := s.do(s.auth, rooms.ScopeCreate, s.token)
+		s.Equal(http.StatusOK, w.Code)
+	}
+
+	s.auth.Forget(s.key.ID)
+	s.mockStore.EXPECT().GetAPIKey(gomock.Any(), s.key.ID).Return(nil, nil)
+
+	w, _ := s.do(s.auth, rooms.ScopeCreate, s.token)
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func (s *AuthenticatorSuite) TestLimiter_Unlimited() {
+	l := newLimiter()
+	for range 10 {
+		_, ok := l.allow("id", 0, s.now)
+		s.True(ok)
+	}
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/rooms"
+)
+
+const (
+	keyIDBytes  = 8
+	secretBytes = 32
+)
+
+// NewAPIKey creates a key with a random secret, the returned token "<id>.<secret>" is the
+// only place the secret appears, the key keeps its hash
+func NewAPIKey(tenant string, scopes []string, rateLimit int) (*rooms.APIKey, string, error) {
+	id := make([]byte, keyIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", fmt.Errorf("failed to generate key ID: %w", err)
+	}
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate key secret: %w", err)
+	}
+
+	key := &rooms.APIKey{
+		ID:        hex.EncodeToString(id),
+		Tenant:    tenant,
+		Scopes:    scopes,
+		RateLimit: rateLimit,
+		CreatedAt: time.Now().UTC(),
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	key.Hash = hashSecret(encoded)
+	return key, key.ID + "." + encoded, nil
+}
+
+// splitToken splits "<id>.<secret>" as returned by NewAPIKey
+func splitToken(token string) (id, secret string, ok bool) {
+	id, secret, ok = strings.Cut(token, ".")
+	return id, secret, ok && id != "" && secret != ""
+}
+
+// verifySecret compares the secret to the stored hash in constant time, secrets are
+// random and long so a plain hash is enough
+func verifySecret(key *rooms.APIKey, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.Hash)) == 1
+}
+
+// verifyAdminKey compares hashes so the comparison does not leak the key length
+func verifyAdminKey(token, adminKey string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashSecret(token)), []byte(hashSecret(adminKey))) == 1
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
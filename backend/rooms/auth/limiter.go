@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket per principal refilled at perMinute/60 per second with a burst
+// of perMinute, buckets are local to the rooms instance
+type limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter() *limiter {
+	return &limiter{buckets: make(map[string]*bucket)}
+}
+
+// allow takes a token for id, when none is left it returns the wait until the next one
+func (l *limiter) allow(id string, perMinute int, now time.Time) (time.Duration, bool) {
+	if perMinute <= 0 {
+		return 0, true
+	}
+	limit := float64(perMinute)
+	rate := limit / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.refill(id, limit, rate, now)
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// exhausted reports whether id has no token left without taking one, with the wait until the next one
+func (l *limiter) exhausted(id string, perMinute int, now time.Time) (time.Duration, bool) {
+	if perMinute <= 0 {
+		return 0, false
+	}
+	limit := float64(perMinute)
+	rate := limit / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.refill(id, limit, rate, now)
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second)), true
+	}
+	return 0, false
+}
+
+func (l *limiter) refill(id string, limit, rate float64, now time.Time) *bucket {
+	b, ok := l.buckets[id]
+	if !ok {
+		b = &bucket{tokens: limit, last: now}
+		l.buckets[id] = b
+	}
+	b.tokens = min(limit, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	return b
+}
+
+// forget drops the bucket of a deleted key
+func (l *limiter) forget(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.buckets, id)
+}
@@ -2,44 +2,111 @@ package main
 
 import (
 	"context"
-	"errors"
-	"net/http"
+	"time"
 
+	goredis "github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 
 	"github.com/imtaco/audio-rtc-exp/internal/config"
 	"github.com/imtaco/audio-rtc-exp/internal/etcd"
 	"github.com/imtaco/audio-rtc-exp/internal/httputil"
+	"github.com/imtaco/audio-rtc-exp/internal/listeners"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	"github.com/imtaco/audio-rtc-exp/internal/otel"
+	"github.com/imtaco/audio-rtc-exp/internal/outbox"
+	"github.com/imtaco/audio-rtc-exp/internal/pin"
+	"github.com/imtaco/audio-rtc-exp/internal/redis"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/timeline"
+	"github.com/imtaco/audio-rtc-exp/internal/tlsid"
+	"github.com/imtaco/audio-rtc-exp/internal/urlsign"
+	"github.com/imtaco/audio-rtc-exp/internal/usage"
 	"github.com/imtaco/audio-rtc-exp/internal/workflow"
+	"github.com/imtaco/audio-rtc-exp/rooms"
+	"github.com/imtaco/audio-rtc-exp/rooms/archive"
+	"github.com/imtaco/audio-rtc-exp/rooms/auth"
+	"github.com/imtaco/audio-rtc-exp/rooms/idgen"
 	"github.com/imtaco/audio-rtc-exp/rooms/service"
 	"github.com/imtaco/audio-rtc-exp/rooms/store"
 	"github.com/imtaco/audio-rtc-exp/rooms/transport"
 )
 
 type Config struct {
-	App                  config.App      `mapstructure:"app"`
-	HTTP                 httputil.Config `mapstructure:"http"`
-	Etcd                 etcd.Config     `mapstructure:"etcd"`
-	Otel                 otel.Config     `mapstructure:"otel"`
-	HLSAdvURL            string          `mapstructure:"hls_adv_url"`
-	EtcdPrefixRoomStore  string          `mapstructure:"etcd_prefix_room_store"`
-	EtcdPrefixJanusStore string          `mapstructure:"etcd_prefix_janus_store"`
-	EtcdPrefixMixerStore string          `mapstructure:"etcd_prefix_mixer_store"`
+	App                   config.App                `mapstructure:"app"`
+	HTTP                  httputil.Config           `mapstructure:"http"`
+	MTLS                  tlsid.Config              `mapstructure:"mtls"`
+	Etcd                  etcd.Config               `mapstructure:"etcd"`
+	EtcdMaintenance       etcd.MaintenanceConfig    `mapstructure:"etcd_maintenance"`
+	Redis                 redis.Config              `mapstructure:"redis"`
+	Otel                  otel.Config               `mapstructure:"otel"`
+	HLSAdvURL             string                    `mapstructure:"hls_adv_url"`
+	HLSURLSecret          string                    `mapstructure:"hls_url_secret"`
+	HLSSignedAdvURL       string                    `mapstructure:"hls_signed_adv_url"`
+	HLSURLTTL             time.Duration             `mapstructure:"hls_url_ttl"`
+	EtcdPrefixRoomStore   string                    `mapstructure:"etcd_prefix_room_store"`
+	EtcdPrefixJanusStore  string                    `mapstructure:"etcd_prefix_janus_store"`
+	EtcdPrefixMixerStore  string                    `mapstructure:"etcd_prefix_mixer_store"`
+	EtcdPrefixOutbox      string                    `mapstructure:"etcd_prefix_outbox"`
+	EtcdPrefixAPIKeys     string                    `mapstructure:"etcd_prefix_api_keys"`
+	EtcdPrefixExternalIDs string                    `mapstructure:"etcd_prefix_external_ids"`
+	EtcdPrefixTenants     string                    `mapstructure:"etcd_prefix_tenants"`
+	EtcdPrefixRoomTags    string                    `mapstructure:"etcd_prefix_room_tags"`
+	RedisRoomEventStream  string                    `mapstructure:"redis_room_event_stream"`
+	RoomEventTrim         redisstream.TrimPolicy    `mapstructure:"room_event_trim"`
+	RoomEventTrimInterval time.Duration             `mapstructure:"room_event_trim_interval"`
+	RedisUserReqStream    string                    `mapstructure:"redis_user_req_stream"`
+	RedisUserReplyStream  string                    `mapstructure:"redis_user_reply_stream"`
+	UserRPC               streamrpc.ClientConfig    `mapstructure:"user_rpc"`
+	HousekeepDryRun       bool                      `mapstructure:"housekeep_dry_run"`
+	Pin                   pin.Policy                `mapstructure:"pin"`
+	APIAuth               auth.Config               `mapstructure:"api_auth"`
+	Archive               archive.Config            `mapstructure:"archive"`
+	Reservation           service.ReservationConfig `mapstructure:"reservation"`
+	RoomID                idgen.Config              `mapstructure:"room_id"`
+	Listeners             listeners.Config          `mapstructure:"listeners"`
+	Usage                 usage.Config              `mapstructure:"usage"`
 }
 
 func loadConfig() (*Config, error) {
 	return config.Load(&Config{}, func(v *viper.Viper) {
 		v.SetDefault("hls_adv_url", "http://localhost:8080/hls/")
+		v.SetDefault("hls_url_secret", "") // empty disables signed HLS URLs
+		v.SetDefault("hls_url_ttl", "6h")
+		// signatures are only checked by the hlsserver m3u8 server, so signed URLs point there
+		v.SetDefault("hls_signed_adv_url", "http://localhost:3102/hls/")
 		v.SetDefault("etcd_prefix_room_store", "/rooms/")
 		v.SetDefault("etcd_prefix_janus_store", "/januses/")
 		v.SetDefault("etcd_prefix_mixer_store", "/mixers/")
+		v.SetDefault("etcd_prefix_outbox", "/outbox/rooms/")
+		v.SetDefault("etcd_prefix_api_keys", "/apikeys/")
+		v.SetDefault("etcd_prefix_external_ids", "/externalids/")
+		v.SetDefault("etcd_prefix_tenants", "/tenants/")
+		v.SetDefault("etcd_prefix_room_tags", "/roomtags/")
+		v.SetDefault("redis_room_event_stream", "") // empty disables room events
+		v.SetDefault("room_event_trim.max_len", 100000)
+		v.SetDefault("room_event_trim.max_age", 24*time.Hour)
+		v.SetDefault("room_event_trim_interval", time.Minute)
+		v.SetDefault("redis_user_req_stream", "") // empty leaves users out of room details
+		v.SetDefault("redis_user_reply_stream", "rtcus:user-status-reply-stream")
+		v.SetDefault("housekeep_dry_run", false)
 
 		config.Setup(v, "app")
 		etcd.Setup(v, "etcd")
+		etcd.SetupMaintenance(v, "etcd_maintenance")
+		redis.Setup(v, "redis")
 		otel.Setup(v, "otel")
 		httputil.Setup(v, "http")
+		tlsid.Setup(v, "mtls")
+		v.SetDefault("mtls.service", "rooms")
+		pin.Setup(v, "pin")
+		auth.Setup(v, "api_auth")
+		streamrpc.Setup(v, "user_rpc")
+		archive.Setup(v, "archive")
+		service.SetupReservation(v, "reservation")
+		idgen.Setup(v, "room_id")
+		listeners.Setup(v, "listeners")
+		usage.Setup(v, "usage")
 
 		// override default addrs to ease testing
 		v.SetDefault("http.addr", "0.0.0.0:3000")
@@ -66,74 +133,222 @@ func main() {
 	if err != nil {
 		logger.Fatal("Failed to initialize OTEL provider", log.Error(err))
 	}
+	lc := workflow.NewLifecycle(logger.Module("Lifecycle"))
+	lc.Add(workflow.Component{Name: "otel", Stop: otelShutdown})
 
 	logger.Info("Starting Room Manager service",
 		log.String("addr", config.HTTP.Addr),
 		log.Any("etcdUrl", config.Etcd.Endpoints),
-		log.String("hlsAdvUrl", config.HLSAdvURL))
+		log.String("hlsAdvUrl", config.HLSAdvURL),
+		log.String("hlsSignedAdvUrl", config.HLSSignedAdvURL),
+		log.Bool("hlsUrlSigning", config.HLSURLSecret != ""),
+		log.Bool("apiAuth", config.APIAuth.Enabled))
 
 	// Create etcd client
 	etcdClient, err := etcd.NewClient(&config.Etcd)
 	if err != nil {
 		logger.Fatal("Failed to create etcd client", log.Error(err))
 	}
-	defer etcdClient.Close()
+	lc.Add(workflow.Component{Name: "etcd", Stop: workflow.Closer(etcdClient.Close)})
+	if config.App.LogLevelsKey != "" {
+		go etcd.WatchLogLevels(ctx, etcdClient, config.App.LogLevelsKey, logger.Levels(), logger.Module("LogLevels"))
+	}
+
+	identity, err := tlsid.New(ctx, &config.MTLS, etcdClient, logger.Module("TLSID"))
+	if err != nil {
+		logger.Fatal("Failed to load TLS identity", log.Error(err))
+	}
+	if identity != nil {
+		lc.Add(workflow.Component{
+			Name:  "tlsid",
+			Start: identity.Start,
+			Stop:  workflow.Stopper(identity.Stop),
+		})
+	}
+
+	if config.EtcdMaintenance.Enabled {
+		maintainer := etcd.NewMaintainer(etcdClient, &config.EtcdMaintenance, logger.Module("EtcdMaint"))
+		lc.Add(workflow.Component{
+			Name:      "etcdMaintenance",
+			DependsOn: []string{"etcd"},
+			Start:     maintainer.Start,
+			Stop:      workflow.Stopper(maintainer.Stop),
+		})
+	}
+
+	if err := store.MigrateLegacyModuleMarks(
+		ctx,
+		etcdClient,
+		config.EtcdPrefixJanusStore,
+		config.EtcdPrefixMixerStore,
+		logger.Module("Migrate"),
+	); err != nil {
+		logger.Fatal("Failed to migrate legacy module marks", log.Error(err))
+	}
+
+	// Redis is only used for room events, listener counts, usage and room users
+	var redisClient *goredis.Client
+	if config.RedisRoomEventStream != "" || config.Listeners.Enabled || config.Usage.Enabled || config.RedisUserReqStream != "" {
+		redisClient = redis.NewClient(&config.Redis)
+		if err := redis.Ping(redisClient); err != nil {
+			logger.Fatal("Failed to connect to Redis", log.Error(err))
+		}
+		lc.Add(workflow.Component{Name: "redis", Stop: workflow.Closer(redisClient.Close)})
+	}
+
+	// Room events are published to Redis through the etcd outbox, only when a stream is set
+	var roomEvents *service.EventStream
+	var roomEventWriter outbox.Writer
+	if config.RedisRoomEventStream != "" {
+		roomEvents, err = service.NewEventStream(
+			redisClient,
+			etcdClient,
+			config.RedisRoomEventStream,
+			config.EtcdPrefixOutbox,
+			&config.RoomEventTrim,
+			config.RoomEventTrimInterval,
+			logger.Module("RoomEvents"),
+		)
+		if err != nil {
+			logger.Fatal("Failed to create room event stream", log.Error(err))
+		}
+		roomEventWriter = roomEvents.Writer()
+		lc.Add(workflow.Component{
+			Name:      "roomEvents",
+			DependsOn: []string{"etcd", "redis"},
+			Start:     roomEvents.Start,
+			Stop:      workflow.Closer(roomEvents.Stop),
+		})
+	}
 
 	// Create components
 	roomStore := store.NewRoomStore(
 		etcdClient,
+		roomEventWriter,
 		config.EtcdPrefixRoomStore,
+		config.EtcdPrefixJanusStore,
+		config.EtcdPrefixMixerStore,
+		config.EtcdPrefixExternalIDs,
+		config.EtcdPrefixTenants,
+		config.EtcdPrefixRoomTags,
 		logger.Module("RoomStore"),
 	)
 
+	apiKeyStore := store.NewAPIKeyStore(
+		etcdClient,
+		config.EtcdPrefixAPIKeys,
+		logger.Module("APIKeyStore"),
+	)
+
+	// Room timelines are written by every service changing a room, rooms records its own transitions
+	roomTimeline := timeline.NewWriter(etcdClient, config.EtcdPrefixRoomStore, "rooms", logger.Module("Timeline"))
+
 	resManager := service.NewResourceManager(
 		etcdClient,
 		roomStore,
 		config.EtcdPrefixRoomStore,
 		config.EtcdPrefixJanusStore,
 		config.EtcdPrefixMixerStore,
+		archive.New(&config.Archive, logger.Module("Archive")),
+		&config.Reservation,
+		roomTimeline,
+		config.HousekeepDryRun,
 		logger.Module("ResMgr"),
 	)
 
+	hlsAdvURL := config.HLSAdvURL
+	var hlsSigner *urlsign.Signer
+	if config.HLSURLSecret != "" {
+		hlsAdvURL = config.HLSSignedAdvURL
+		hlsSigner = urlsign.New(config.HLSURLSecret, config.HLSURLTTL)
+	}
+
+	// Room users are read from the users controller for room details
+	var roomUsers rooms.RoomUsersReader
+	if config.RedisUserReqStream != "" {
+		userRPC, err := streamrpc.NewClient(
+			redisClient,
+			config.RedisUserReqStream,
+			config.RedisUserReplyStream,
+			&config.UserRPC,
+			logger.Module("UserRPC"),
+		)
+		if err != nil {
+			logger.Fatal("Failed to create users RPC client", log.Error(err))
+		}
+		roomUsers = service.NewRoomUsersClient(userRPC)
+		lc.Add(workflow.Component{
+			Name:      "userRPC",
+			DependsOn: []string{"redis"},
+			Start:     userRPC.Open,
+			Stop:      workflow.Closer(userRPC.Close),
+		})
+	}
+
 	roomService := service.NewRoomService(
 		roomStore,
 		resManager,
-		config.HLSAdvURL,
+		roomUsers,
+		hlsAdvURL,
+		hlsSigner,
+		roomTimeline,
 		logger.Module("RoomSvc"),
 	)
 
-	// Initialize resource manager
-	if err := resManager.Start(ctx); err != nil {
-		logger.Fatal("Failed to start resource manager", log.Error(err))
+	lc.Add(workflow.Component{
+		Name:      "resManager",
+		DependsOn: []string{"etcd"},
+		Start:     resManager.Start,
+		Stop:      workflow.Closer(resManager.Stop),
+	})
+
+	idProvider, err := idgen.New(&config.RoomID, logger.Module("IDGen"))
+	if err != nil {
+		logger.Fatal("Failed to create room ID provider", log.Error(err))
+	}
+
+	// listeners are counted by the hlsserver
+	var listenerCounter rooms.ListenerCounter
+	if config.Listeners.Enabled {
+		listenerCounter = listeners.NewCounter(redisClient, &config.Listeners)
+	}
+	// usage is accounted by januses and the hlsserver
+	var usageReader rooms.UsageReader
+	if config.Usage.Enabled {
+		usageReader = usage.NewReader(redisClient, &config.Usage)
 	}
 
 	// Setup router
-	router := transport.NewRouter(roomService, roomStore, logger.Module("Router"))
+	authenticator := auth.NewAuthenticator(
+		&config.APIAuth,
+		apiKeyStore,
+		logger.Module("Auth"),
+	)
+	router := transport.NewRouter(
+		roomService,
+		roomStore,
+		apiKeyStore,
+		resManager,
+		&config.Pin,
+		idProvider,
+		listenerCounter,
+		usageReader,
+		authenticator,
+		logger.Module("Router"),
+	)
 	server := httputil.NewServer(&config.HTTP, router.Handler())
+	server.SetIdentity(identity)
 
-	// Start HTTP server
-	go func() {
-		logger.Info("Starting HTTP server", log.String("addr", config.HTTP.Addr))
-		if err := server.Listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			logger.Fatal("Failed to start HTTP server", log.Error(err))
-		}
-	}()
-
-	logger.Info("Room Manager started")
+	lc.Add(server.Component("http", logger, "resManager"))
 
-	// Setup graceful shutdown
-	cleanup := func(ctx context.Context) {
-		_ = server.Shutdown(ctx)
+	if adminServer := httputil.NewAdminServer(config.App.AdminAddr, "room-service", logger); adminServer != nil {
+		lc.Add(adminServer.Component("admin", logger))
+	}
 
-		if err := resManager.Stop(); err != nil {
-			logger.Error("Error cleaning up resource manager", log.Error(err))
-		}
-		if err := etcdClient.Close(); err != nil {
-			logger.Error("Failed to close etcd client", log.Error(err))
-		}
-		if err := otelShutdown(ctx); err != nil {
-			logger.Error("Failed to shutdown OTEL", log.Error(err))
-		}
+	if err := lc.Start(ctx); err != nil {
+		logger.Fatal("Failed to start Room Manager", log.Error(err))
 	}
-	workflow.WaitGracefulShutdown(ctx, logger.Module("CleanUp"), cleanup, config.App.ShutdownTimeout)
+	logger.Info("Room Manager started")
+
+	lc.WaitGracefulShutdown(ctx, config.App.ShutdownTimeout)
 }
@@ -0,0 +1,155 @@
+package idgen
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/validation"
+	"github.com/imtaco/audio-rtc-exp/rooms"
+)
+
+// Provider names
+const (
+	ProviderHex      = "hex"
+	ProviderUUIDv7   = "uuidv7"
+	ProviderKSUID    = "ksuid"
+	ProviderExternal = "external"
+)
+
+// Config selects how IDs of rooms created without one are generated
+type Config struct {
+	Provider string `mapstructure:"provider"`
+	// URL of the external provider, receives POST {"externalId"} and answers {"roomId"}
+	URL string `mapstructure:"url"`
+	// Token is sent as bearer token to the external provider, empty sends none
+	Token   string        `mapstructure:"token"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("provider"), ProviderHex)
+	v.SetDefault(p("url"), "")
+	v.SetDefault(p("token"), "")
+	v.SetDefault(p("timeout"), "5s")
+}
+
+// New returns the provider of the config
+func New(cfg *Config, logger *log.Logger) (rooms.IDProvider, error) {
+	switch cfg.Provider {
+	case ProviderHex, "":
+		return hexProvider{}, nil
+	case ProviderUUIDv7:
+		return uuidv7Provider{}, nil
+	case ProviderKSUID:
+		return ksuidProvider{}, nil
+	case ProviderExternal:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("room ID provider %q requires a URL", cfg.Provider)
+		}
+		client := resty.New().
+			SetTimeout(cfg.Timeout).
+			SetHeader("Content-Type", "application/json")
+		if cfg.Token != "" {
+			client.SetAuthToken(cfg.Token)
+		}
+		logger.Info("External room ID provider enabled", log.String("url", cfg.URL))
+		return &externalProvider{url: cfg.URL, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown room ID provider %q", cfg.Provider)
+	}
+}
+
+// hexProvider generates 20 random hex characters
+type hexProvider struct{}
+
+func (hexProvider) NewRoomID(_ context.Context, _ string) (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// uuidv7Provider generates time ordered UUIDs, hyphens are dropped to fit room ID length
+type uuidv7Provider struct{}
+
+func (uuidv7Provider) NewRoomID(_ context.Context, _ string) (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(id.String(), "-", ""), nil
+}
+
+const (
+	// ksuidEpoch is the KSUID epoch, 2014-05-13T16:53:20Z
+	ksuidEpoch     = 1400000000
+	ksuidLength    = 27
+	base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// ksuidProvider generates KSUIDs, a second precision timestamp and 128 random bits in base62
+type ksuidProvider struct{}
+
+func (ksuidProvider) NewRoomID(_ context.Context, _ string) (string, error) {
+	return newKSUID(time.Now())
+}
+
+func newKSUID(now time.Time) (string, error) {
+	buf := make([]byte, 20)
+	binary.BigEndian.PutUint32(buf, uint32(now.Unix()-ksuidEpoch))
+	if _, err := rand.Read(buf[4:]); err != nil {
+		return "", err
+	}
+
+	n := new(big.Int).SetBytes(buf)
+	base := big.NewInt(int64(len(base62Alphabet)))
+	mod := new(big.Int)
+	out := make([]byte, ksuidLength)
+	for i := ksuidLength - 1; i >= 0; i-- {
+		n.DivMod(n, base, mod)
+		out[i] = base62Alphabet[mod.Int64()]
+	}
+	return string(out), nil
+}
+
+// externalProvider asks an upstream service, e.g. a CMS, for the ID of the room
+type externalProvider struct {
+	url    string
+	client *resty.Client
+}
+
+func (p *externalProvider) NewRoomID(ctx context.Context, externalID string) (string, error) {
+	var result struct {
+		RoomID string `json:"roomId"`
+	}
+	resp, err := p.client.R().
+		SetContext(ctx).
+		SetBody(map[string]string{"externalId": externalID}).
+		SetResult(&result).
+		ForceContentType("application/json").
+		Post(p.url)
+	if err != nil {
+		return "", fmt.Errorf("failed to request room ID: %w", err)
+	}
+	if resp.IsError() {
+		return "", fmt.Errorf("failed to request room ID: %s", resp.Status())
+	}
+	if !validation.IsRoomID(result.RoomID) {
+		return "", fmt.Errorf("invalid room ID %q from provider", result.RoomID)
+	}
+	return result.RoomID, nil
+}
@@ -0,0 +1,93 @@
+package idgen
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/validation"
+)
+
+type IDGenSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func TestIDGenSuite(t *testing.T) {
+	suite.Run(t, new(IDGenSuite))
+}
+
+func (s *IDGenSuite) SetupTest() {
+	s.ctx = context.Background()
+}
+
+func (s *IDGenSuite) newID(cfg *Config) string {
+	provider, err := New(cfg, log.NewNop())
+	s.Require().NoError(err)
+	id, err := provider.NewRoomID(s.ctx, "")
+	s.Require().NoError(err)
+	s.True(validation.IsRoomID(id), "valid room ID %q", id)
+	return id
+}
+
+func (s *IDGenSuite) TestLocalProviders() {
+	s.Len(s.newID(&Config{}), 20)
+	s.Len(s.newID(&Config{Provider: ProviderHex}), 20)
+	s.Len(s.newID(&Config{Provider: ProviderUUIDv7}), 32)
+	s.Len(s.newID(&Config{Provider: ProviderKSUID}), ksuidLength)
+}
+
+func (s *IDGenSuite) TestKSUIDSortsByTime() {
+	earlier, err := newKSUID(time.Unix(1700000000, 0))
+	s.Require().NoError(err)
+	later, err := newKSUID(time.Unix(1700000001, 0))
+	s.Require().NoError(err)
+
+	s.Less(earlier, later)
+}
+
+func (s *IDGenSuite) TestUnknownProvider() {
+	_, err := New(&Config{Provider: "snowflake"}, log.NewNop())
+	s.Error(err)
+
+	_, err = New(&Config{Provider: ProviderExternal}, log.NewNop())
+	s.Error(err, "external provider requires a URL")
+}
+
+func (s *IDGenSuite) TestExternalProvider() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("Bearer secret", r.Header.Get("Authorization"))
+		var req struct {
+			ExternalID string `json:"externalId"`
+		}
+		s.NoError(json.NewDecoder(r.Body).Decode(&req))
+		switch req.ExternalID {
+		case "cms-42":
+			_, _ = w.Write([]byte(`{"roomId":"cms-room-42"}`))
+		case "bad":
+			_, _ = w.Write([]byte(`{"roomId":"no spaces allowed"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	provider, err := New(&Config{Provider: ProviderExternal, URL: srv.URL, Token: "secret", Timeout: time.Second}, log.NewNop())
+	s.Require().NoError(err)
+
+	id, err := provider.NewRoomID(s.ctx, "cms-42")
+	s.Require().NoError(err)
+	s.Equal("cms-room-42", id)
+
+	_, err = provider.NewRoomID(s.ctx, "bad")
+	s.ErrorContains(err, "invalid room ID")
+
+	_, err = provider.NewRoomID(s.ctx, "unknown")
+	s.Error(err)
+}
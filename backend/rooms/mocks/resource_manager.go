@@ -40,34 +40,72 @@ func (m *MockResourceManager) EXPECT() *MockResourceManagerMockRecorder {
 	return m.recorder
 }
 
+// HousekeepDryRun mocks base method.
+func (m *MockResourceManager) HousekeepDryRun() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HousekeepDryRun")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// HousekeepDryRun indicates an expected call of HousekeepDryRun.
+func (mr *MockResourceManagerMockRecorder) HousekeepDryRun() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HousekeepDryRun", reflect.TypeOf((*MockResourceManager)(nil).HousekeepDryRun))
+}
+
 // PickJanus mocks base method.
-func (m *MockResourceManager) PickJanus() (string, error) {
+func (m *MockResourceManager) PickJanus(ctx context.Context, roomID string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PickJanus")
+	ret := m.ctrl.Call(m, "PickJanus", ctx, roomID)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // PickJanus indicates an expected call of PickJanus.
-func (mr *MockResourceManagerMockRecorder) PickJanus() *gomock.Call {
+func (mr *MockResourceManagerMockRecorder) PickJanus(ctx, roomID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PickJanus", reflect.TypeOf((*MockResourceManager)(nil).PickJanus))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PickJanus", reflect.TypeOf((*MockResourceManager)(nil).PickJanus), ctx, roomID)
 }
 
 // PickMixer mocks base method.
-func (m *MockResourceManager) PickMixer() (string, error) {
+func (m *MockResourceManager) PickMixer(ctx context.Context, roomID string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PickMixer")
+	ret := m.ctrl.Call(m, "PickMixer", ctx, roomID)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // PickMixer indicates an expected call of PickMixer.
-func (mr *MockResourceManagerMockRecorder) PickMixer() *gomock.Call {
+func (mr *MockResourceManagerMockRecorder) PickMixer(ctx, roomID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PickMixer", reflect.TypeOf((*MockResourceManager)(nil).PickMixer), ctx, roomID)
+}
+
+// Release mocks base method.
+func (m *MockResourceManager) Release(ctx context.Context, roomID, janusID, mixerID string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Release", ctx, roomID, janusID, mixerID)
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockResourceManagerMockRecorder) Release(ctx, roomID, janusID, mixerID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockResourceManager)(nil).Release), ctx, roomID, janusID, mixerID)
+}
+
+// SetHousekeepDryRun mocks base method.
+func (m *MockResourceManager) SetHousekeepDryRun(enabled bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetHousekeepDryRun", enabled)
+}
+
+// SetHousekeepDryRun indicates an expected call of SetHousekeepDryRun.
+func (mr *MockResourceManagerMockRecorder) SetHousekeepDryRun(enabled any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PickMixer", reflect.TypeOf((*MockResourceManager)(nil).PickMixer))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetHousekeepDryRun", reflect.TypeOf((*MockResourceManager)(nil).SetHousekeepDryRun), enabled)
 }
 
 // Start mocks base method.
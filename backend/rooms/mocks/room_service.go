@@ -13,9 +13,9 @@ import (
 	context "context"
 	reflect "reflect"
 
-	gomock "go.uber.org/mock/gomock"
-
+	etcdstate "github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	rooms "github.com/imtaco/audio-rtc-exp/rooms"
+	gomock "go.uber.org/mock/gomock"
 )
 
 // MockRoomService is a mock of RoomService interface.
@@ -43,18 +43,18 @@ func (m *MockRoomService) EXPECT() *MockRoomServiceMockRecorder {
 }
 
 // CreateRoom mocks base method.
-func (m *MockRoomService) CreateRoom(ctx context.Context, roomID, pin string, maxAnchors int) (*rooms.RoomResponse, error) {
+func (m *MockRoomService) CreateRoom(ctx context.Context, roomID string, params *rooms.CreateRoomParams) (*rooms.RoomResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateRoom", ctx, roomID, pin, maxAnchors)
+	ret := m.ctrl.Call(m, "CreateRoom", ctx, roomID, params)
 	ret0, _ := ret[0].(*rooms.RoomResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateRoom indicates an expected call of CreateRoom.
-func (mr *MockRoomServiceMockRecorder) CreateRoom(ctx, roomID, pin, maxAnchors any) *gomock.Call {
+func (mr *MockRoomServiceMockRecorder) CreateRoom(ctx, roomID, params any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRoom", reflect.TypeOf((*MockRoomService)(nil).CreateRoom), ctx, roomID, pin, maxAnchors)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRoom", reflect.TypeOf((*MockRoomService)(nil).CreateRoom), ctx, roomID, params)
 }
 
 // DeleteRoom mocks base method.
@@ -72,6 +72,36 @@ func (mr *MockRoomServiceMockRecorder) DeleteRoom(ctx, roomID any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRoom", reflect.TypeOf((*MockRoomService)(nil).DeleteRoom), ctx, roomID)
 }
 
+// EndRoom mocks base method.
+func (m *MockRoomService) EndRoom(ctx context.Context, roomID string) (*rooms.RoomResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EndRoom", ctx, roomID)
+	ret0, _ := ret[0].(*rooms.RoomResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EndRoom indicates an expected call of EndRoom.
+func (mr *MockRoomServiceMockRecorder) EndRoom(ctx, roomID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EndRoom", reflect.TypeOf((*MockRoomService)(nil).EndRoom), ctx, roomID)
+}
+
+// FreezeRoom mocks base method.
+func (m *MockRoomService) FreezeRoom(ctx context.Context, roomID string, frozen bool) (*rooms.RoomResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FreezeRoom", ctx, roomID, frozen)
+	ret0, _ := ret[0].(*rooms.RoomResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FreezeRoom indicates an expected call of FreezeRoom.
+func (mr *MockRoomServiceMockRecorder) FreezeRoom(ctx, roomID, frozen any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FreezeRoom", reflect.TypeOf((*MockRoomService)(nil).FreezeRoom), ctx, roomID, frozen)
+}
+
 // GetRoom mocks base method.
 func (m *MockRoomService) GetRoom(ctx context.Context, roomID string) (*rooms.RoomResponse, error) {
 	m.ctrl.T.Helper()
@@ -87,6 +117,36 @@ func (mr *MockRoomServiceMockRecorder) GetRoom(ctx, roomID any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoom", reflect.TypeOf((*MockRoomService)(nil).GetRoom), ctx, roomID)
 }
 
+// GetRoomByExternalID mocks base method.
+func (m *MockRoomService) GetRoomByExternalID(ctx context.Context, externalID string) (*rooms.RoomResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoomByExternalID", ctx, externalID)
+	ret0, _ := ret[0].(*rooms.RoomResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoomByExternalID indicates an expected call of GetRoomByExternalID.
+func (mr *MockRoomServiceMockRecorder) GetRoomByExternalID(ctx, externalID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoomByExternalID", reflect.TypeOf((*MockRoomService)(nil).GetRoomByExternalID), ctx, externalID)
+}
+
+// GetRoomDetail mocks base method.
+func (m *MockRoomService) GetRoomDetail(ctx context.Context, roomID string) (*rooms.RoomDetail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoomDetail", ctx, roomID)
+	ret0, _ := ret[0].(*rooms.RoomDetail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoomDetail indicates an expected call of GetRoomDetail.
+func (mr *MockRoomServiceMockRecorder) GetRoomDetail(ctx, roomID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoomDetail", reflect.TypeOf((*MockRoomService)(nil).GetRoomDetail), ctx, roomID)
+}
+
 // GetStats mocks base method.
 func (m *MockRoomService) GetStats(ctx context.Context) (*rooms.StatsResponse, error) {
 	m.ctrl.T.Helper()
@@ -102,19 +162,49 @@ func (mr *MockRoomServiceMockRecorder) GetStats(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockRoomService)(nil).GetStats), ctx)
 }
 
+// GetTimeline mocks base method.
+func (m *MockRoomService) GetTimeline(ctx context.Context, roomID string) ([]etcdstate.TimelineEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTimeline", ctx, roomID)
+	ret0, _ := ret[0].([]etcdstate.TimelineEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTimeline indicates an expected call of GetTimeline.
+func (mr *MockRoomServiceMockRecorder) GetTimeline(ctx, roomID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTimeline", reflect.TypeOf((*MockRoomService)(nil).GetTimeline), ctx, roomID)
+}
+
+// LinkRoom mocks base method.
+func (m *MockRoomService) LinkRoom(ctx context.Context, sourceRoomID, targetRoomID, anchorID string) (*rooms.LinkResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LinkRoom", ctx, sourceRoomID, targetRoomID, anchorID)
+	ret0, _ := ret[0].(*rooms.LinkResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LinkRoom indicates an expected call of LinkRoom.
+func (mr *MockRoomServiceMockRecorder) LinkRoom(ctx, sourceRoomID, targetRoomID, anchorID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkRoom", reflect.TypeOf((*MockRoomService)(nil).LinkRoom), ctx, sourceRoomID, targetRoomID, anchorID)
+}
+
 // ListRooms mocks base method.
-func (m *MockRoomService) ListRooms(ctx context.Context) (*rooms.ListRoomsResponse, error) {
+func (m *MockRoomService) ListRooms(ctx context.Context, tags map[string]string) (*rooms.ListRoomsResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListRooms", ctx)
+	ret := m.ctrl.Call(m, "ListRooms", ctx, tags)
 	ret0, _ := ret[0].(*rooms.ListRoomsResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListRooms indicates an expected call of ListRooms.
-func (mr *MockRoomServiceMockRecorder) ListRooms(ctx any) *gomock.Call {
+func (mr *MockRoomServiceMockRecorder) ListRooms(ctx, tags any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRooms", reflect.TypeOf((*MockRoomService)(nil).ListRooms), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRooms", reflect.TypeOf((*MockRoomService)(nil).ListRooms), ctx, tags)
 }
 
 // StartLive mocks base method.
@@ -130,3 +220,32 @@ func (mr *MockRoomServiceMockRecorder) StartLive(ctx, roomID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartLive", reflect.TypeOf((*MockRoomService)(nil).StartLive), ctx, roomID)
 }
+
+// UnlinkRoom mocks base method.
+func (m *MockRoomService) UnlinkRoom(ctx context.Context, sourceRoomID, targetRoomID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlinkRoom", ctx, sourceRoomID, targetRoomID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlinkRoom indicates an expected call of UnlinkRoom.
+func (mr *MockRoomServiceMockRecorder) UnlinkRoom(ctx, sourceRoomID, targetRoomID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlinkRoom", reflect.TypeOf((*MockRoomService)(nil).UnlinkRoom), ctx, sourceRoomID, targetRoomID)
+}
+
+// UpdateRoom mocks base method.
+func (m *MockRoomService) UpdateRoom(ctx context.Context, roomID string, patch *rooms.RoomPatch) (*rooms.RoomResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRoom", ctx, roomID, patch)
+	ret0, _ := ret[0].(*rooms.RoomResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateRoom indicates an expected call of UpdateRoom.
+func (mr *MockRoomServiceMockRecorder) UpdateRoom(ctx, roomID, patch any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRoom", reflect.TypeOf((*MockRoomService)(nil).UpdateRoom), ctx, roomID, patch)
+}
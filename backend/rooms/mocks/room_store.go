@@ -44,6 +44,21 @@ func (m *MockRoomStore) EXPECT() *MockRoomStoreMockRecorder {
 	return m.recorder
 }
 
+// CreateLink mocks base method.
+func (m *MockRoomStore) CreateLink(ctx context.Context, targetRoomID string, link *etcdstate.Link) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateLink", ctx, targetRoomID, link)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateLink indicates an expected call of CreateLink.
+func (mr *MockRoomStoreMockRecorder) CreateLink(ctx, targetRoomID, link any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLink", reflect.TypeOf((*MockRoomStore)(nil).CreateLink), ctx, targetRoomID, link)
+}
+
 // CreateLiveMeta mocks base method.
 func (m *MockRoomStore) CreateLiveMeta(ctx context.Context, roomID, mixerID, janusID, nonce string) error {
 	m.ctrl.T.Helper()
@@ -73,6 +88,20 @@ func (mr *MockRoomStoreMockRecorder) CreateRoom(ctx, roomID, roomData any) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRoom", reflect.TypeOf((*MockRoomStore)(nil).CreateRoom), ctx, roomID, roomData)
 }
 
+// DeleteLink mocks base method.
+func (m *MockRoomStore) DeleteLink(ctx context.Context, targetRoomID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLink", ctx, targetRoomID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteLink indicates an expected call of DeleteLink.
+func (mr *MockRoomStoreMockRecorder) DeleteLink(ctx, targetRoomID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLink", reflect.TypeOf((*MockRoomStore)(nil).DeleteLink), ctx, targetRoomID)
+}
+
 // DeleteModuleMark mocks base method.
 func (m *MockRoomStore) DeleteModuleMark(ctx context.Context, moduleType, moduleID string) error {
 	m.ctrl.T.Helper()
@@ -132,6 +161,36 @@ func (mr *MockRoomStoreMockRecorder) GetAllRooms(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllRooms", reflect.TypeOf((*MockRoomStore)(nil).GetAllRooms), ctx)
 }
 
+// GetLatency mocks base method.
+func (m *MockRoomStore) GetLatency(ctx context.Context, roomID string) (*etcdstate.Latency, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatency", ctx, roomID)
+	ret0, _ := ret[0].(*etcdstate.Latency)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatency indicates an expected call of GetLatency.
+func (mr *MockRoomStoreMockRecorder) GetLatency(ctx, roomID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatency", reflect.TypeOf((*MockRoomStore)(nil).GetLatency), ctx, roomID)
+}
+
+// GetLink mocks base method.
+func (m *MockRoomStore) GetLink(ctx context.Context, targetRoomID string) (*etcdstate.Link, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLink", ctx, targetRoomID)
+	ret0, _ := ret[0].(*etcdstate.Link)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLink indicates an expected call of GetLink.
+func (mr *MockRoomStoreMockRecorder) GetLink(ctx, targetRoomID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLink", reflect.TypeOf((*MockRoomStore)(nil).GetLink), ctx, targetRoomID)
+}
+
 // GetMixerData mocks base method.
 func (m *MockRoomStore) GetMixerData(ctx context.Context, roomID string) (*etcdstate.Mixer, error) {
 	m.ctrl.T.Helper()
@@ -147,6 +206,21 @@ func (mr *MockRoomStoreMockRecorder) GetMixerData(ctx, roomID any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMixerData", reflect.TypeOf((*MockRoomStore)(nil).GetMixerData), ctx, roomID)
 }
 
+// GetQuotaUsage mocks base method.
+func (m *MockRoomStore) GetQuotaUsage(ctx context.Context, tenant string) (*rooms.QuotaUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQuotaUsage", ctx, tenant)
+	ret0, _ := ret[0].(*rooms.QuotaUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQuotaUsage indicates an expected call of GetQuotaUsage.
+func (mr *MockRoomStoreMockRecorder) GetQuotaUsage(ctx, tenant any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuotaUsage", reflect.TypeOf((*MockRoomStore)(nil).GetQuotaUsage), ctx, tenant)
+}
+
 // GetRoom mocks base method.
 func (m *MockRoomStore) GetRoom(ctx context.Context, roomID string) (*etcdstate.Meta, error) {
 	m.ctrl.T.Helper()
@@ -162,6 +236,21 @@ func (mr *MockRoomStoreMockRecorder) GetRoom(ctx, roomID any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoom", reflect.TypeOf((*MockRoomStore)(nil).GetRoom), ctx, roomID)
 }
 
+// GetRoomState mocks base method.
+func (m *MockRoomStore) GetRoomState(ctx context.Context, roomID string) (*etcdstate.RoomState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoomState", ctx, roomID)
+	ret0, _ := ret[0].(*etcdstate.RoomState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoomState indicates an expected call of GetRoomState.
+func (mr *MockRoomStoreMockRecorder) GetRoomState(ctx, roomID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoomState", reflect.TypeOf((*MockRoomStore)(nil).GetRoomState), ctx, roomID)
+}
+
 // GetStats mocks base method.
 func (m *MockRoomStore) GetStats(ctx context.Context) (*rooms.RoomStats, error) {
 	m.ctrl.T.Helper()
@@ -177,6 +266,95 @@ func (mr *MockRoomStoreMockRecorder) GetStats(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockRoomStore)(nil).GetStats), ctx)
 }
 
+// GetTimeline mocks base method.
+func (m *MockRoomStore) GetTimeline(ctx context.Context, roomID string) (*etcdstate.Timeline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTimeline", ctx, roomID)
+	ret0, _ := ret[0].(*etcdstate.Timeline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTimeline indicates an expected call of GetTimeline.
+func (mr *MockRoomStoreMockRecorder) GetTimeline(ctx, roomID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTimeline", reflect.TypeOf((*MockRoomStore)(nil).GetTimeline), ctx, roomID)
+}
+
+// ListModuleStatus mocks base method.
+func (m *MockRoomStore) ListModuleStatus(ctx context.Context, moduleType string) ([]*rooms.ModuleStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListModuleStatus", ctx, moduleType)
+	ret0, _ := ret[0].([]*rooms.ModuleStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListModuleStatus indicates an expected call of ListModuleStatus.
+func (mr *MockRoomStoreMockRecorder) ListModuleStatus(ctx, moduleType any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListModuleStatus", reflect.TypeOf((*MockRoomStore)(nil).ListModuleStatus), ctx, moduleType)
+}
+
+// ListRoomsByTag mocks base method.
+func (m *MockRoomStore) ListRoomsByTag(ctx context.Context, key, value string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRoomsByTag", ctx, key, value)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRoomsByTag indicates an expected call of ListRoomsByTag.
+func (mr *MockRoomStoreMockRecorder) ListRoomsByTag(ctx, key, value any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRoomsByTag", reflect.TypeOf((*MockRoomStore)(nil).ListRoomsByTag), ctx, key, value)
+}
+
+// ReassignLiveMeta mocks base method.
+func (m *MockRoomStore) ReassignLiveMeta(ctx context.Context, roomID, mixerID, janusID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReassignLiveMeta", ctx, roomID, mixerID, janusID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReassignLiveMeta indicates an expected call of ReassignLiveMeta.
+func (mr *MockRoomStoreMockRecorder) ReassignLiveMeta(ctx, roomID, mixerID, janusID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReassignLiveMeta", reflect.TypeOf((*MockRoomStore)(nil).ReassignLiveMeta), ctx, roomID, mixerID, janusID)
+}
+
+// ResolveExternalID mocks base method.
+func (m *MockRoomStore) ResolveExternalID(ctx context.Context, externalID string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolveExternalID", ctx, externalID)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResolveExternalID indicates an expected call of ResolveExternalID.
+func (mr *MockRoomStoreMockRecorder) ResolveExternalID(ctx, externalID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveExternalID", reflect.TypeOf((*MockRoomStore)(nil).ResolveExternalID), ctx, externalID)
+}
+
+// SetModuleDegraded mocks base method.
+func (m *MockRoomStore) SetModuleDegraded(ctx context.Context, moduleType, moduleID string, degraded *etcdstate.DegradedData, ttlSeconds int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetModuleDegraded", ctx, moduleType, moduleID, degraded, ttlSeconds)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetModuleDegraded indicates an expected call of SetModuleDegraded.
+func (mr *MockRoomStoreMockRecorder) SetModuleDegraded(ctx, moduleType, moduleID, degraded, ttlSeconds any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetModuleDegraded", reflect.TypeOf((*MockRoomStore)(nil).SetModuleDegraded), ctx, moduleType, moduleID, degraded, ttlSeconds)
+}
+
 // SetModuleMark mocks base method.
 func (m *MockRoomStore) SetModuleMark(ctx context.Context, moduleType, moduleID string, label constants.MarkLabel, ttlSeconds int64) error {
 	m.ctrl.T.Helper()
@@ -191,6 +369,20 @@ func (mr *MockRoomStoreMockRecorder) SetModuleMark(ctx, moduleType, moduleID, la
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetModuleMark", reflect.TypeOf((*MockRoomStore)(nil).SetModuleMark), ctx, moduleType, moduleID, label, ttlSeconds)
 }
 
+// SetQuota mocks base method.
+func (m *MockRoomStore) SetQuota(ctx context.Context, tenant string, quota *rooms.Quota) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetQuota", ctx, tenant, quota)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetQuota indicates an expected call of SetQuota.
+func (mr *MockRoomStoreMockRecorder) SetQuota(ctx, tenant, quota any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetQuota", reflect.TypeOf((*MockRoomStore)(nil).SetQuota), ctx, tenant, quota)
+}
+
 // StopLiveMeta mocks base method.
 func (m *MockRoomStore) StopLiveMeta(ctx context.Context, roomID string) error {
 	m.ctrl.T.Helper()
@@ -218,3 +410,18 @@ func (mr *MockRoomStoreMockRecorder) StopRoom(ctx, roomID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopRoom", reflect.TypeOf((*MockRoomStore)(nil).StopRoom), ctx, roomID)
 }
+
+// UpdateRoom mocks base method.
+func (m *MockRoomStore) UpdateRoom(ctx context.Context, roomID string, update func(*etcdstate.Meta) error) (*etcdstate.Meta, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRoom", ctx, roomID, update)
+	ret0, _ := ret[0].(*etcdstate.Meta)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateRoom indicates an expected call of UpdateRoom.
+func (mr *MockRoomStoreMockRecorder) UpdateRoom(ctx, roomID, update any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRoom", reflect.TypeOf((*MockRoomStore)(nil).UpdateRoom), ctx, roomID, update)
+}
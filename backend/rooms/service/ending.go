@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/timeline"
+)
+
+const (
+	endingInterval = 1 * time.Second
+	// endNotifyGrace lets gateways tell the anchors before their sessions are stopped
+	endNotifyGrace = 3 * time.Second
+	// endUsersGrace lets the users controller stop the users before the forwarder is stopped
+	endUsersGrace = 2 * time.Second
+	// endStepTimeout bounds waiting for the teardown of a stage, the next stage is entered anyway
+	endStepTimeout = 15 * time.Second
+)
+
+// errEndStageChanged aborts advancing a room another instance advanced meanwhile
+var errEndStageChanged = errors.New("end stage changed")
+
+func (rm *resourceMgrImpl) endingLoop() {
+	ticker := time.NewTicker(endingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rm.stopCh:
+			rm.logger.Info("Stopping resourceMgrImpl ending loop")
+			return
+		case <-ticker.C:
+			rm.advanceEndingRooms(context.Background())
+		}
+	}
+}
+
+func (rm *resourceMgrImpl) advanceEndingRooms(ctx context.Context) {
+	for _, roomID := range rm.roomWatcher.EndingRooms() {
+		if err := rm.advanceEndingRoom(ctx, roomID, time.Now().UTC()); err != nil {
+			rm.logger.Error("Error advancing ending room",
+				log.String("roomId", roomID),
+				log.Error(err))
+		}
+	}
+}
+
+// advanceEndingRoom moves the room to the next end stage once the teardown of its current
+// stage is done, as observed in the room state, or took longer than endStepTimeout
+func (rm *resourceMgrImpl) advanceEndingRoom(ctx context.Context, roomID string, now time.Time) error {
+	state, ok := rm.roomWatcher.GetCachedState(roomID)
+	if !ok || state.GetMeta().Ending == nil {
+		return nil
+	}
+	ending := state.Meta.Ending
+	elapsed := now.Sub(ending.UpdatedAt)
+	timedOut := elapsed > endStepTimeout
+
+	var next constants.EndStage
+	switch ending.Stage {
+	case constants.EndStageNotifying:
+		if elapsed < endNotifyGrace {
+			return nil
+		}
+		next = constants.EndStageStoppingUsers
+	case constants.EndStageStoppingUsers:
+		// users are stopped by the users controller, which reports nothing back in the room state
+		if elapsed < endUsersGrace {
+			return nil
+		}
+		next = constants.EndStageStoppingForwarder
+	case constants.EndStageStoppingForwarder:
+		if state.GetJanus().GetStatus() == constants.JanusStatusForwarding && !timedOut {
+			return nil
+		}
+		if state.GetLiveMeta().GetStatus() == constants.RoomStatusOnAir {
+			if err := rm.roomStore.StopRoom(ctx, roomID); err != nil {
+				return err
+			}
+		}
+		next = constants.EndStageStoppingLive
+	case constants.EndStageStoppingLive:
+		// the mixer deletes its key once FFmpeg exited, after writing the final playlist
+		if state.GetMixer() != nil && !timedOut {
+			return nil
+		}
+		next = constants.EndStageEnded
+	default:
+		return nil
+	}
+
+	if timedOut && (ending.Stage == constants.EndStageStoppingForwarder || ending.Stage == constants.EndStageStoppingLive) {
+		endStageTimedOut.Add(ctx, 1, metric.WithAttributes(attribute.String("stage", string(ending.Stage))))
+		rm.logger.Warn("End stage timed out, entering next stage",
+			log.String("roomId", roomID),
+			log.String("stage", string(ending.Stage)))
+	}
+	return rm.setEndStage(ctx, roomID, ending.Stage, next, now)
+}
+
+func (rm *resourceMgrImpl) setEndStage(ctx context.Context, roomID string, from, to constants.EndStage, now time.Time) error {
+	meta, err := rm.roomStore.UpdateRoom(ctx, roomID, func(meta *etcdstate.Meta) error {
+		if meta.GetEndStage() != from {
+			return errEndStageChanged
+		}
+		meta.Ending.Stage = to
+		meta.Ending.UpdatedAt = now
+		return nil
+	})
+	if errors.Is(err, errEndStageChanged) || (err == nil && meta == nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	endStageAdvanced.Add(ctx, 1, metric.WithAttributes(attribute.String("stage", string(to))))
+	rm.logger.Info("Room entered end stage",
+		log.String("roomId", roomID),
+		log.String("stage", string(to)))
+	if to == constants.EndStageEnded {
+		endDurationSeconds.Record(ctx, now.Sub(meta.Ending.StartedAt).Seconds())
+		rm.timeline.Record(roomID, timeline.EventEnded, "")
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	roomsmocks "github.com/imtaco/audio-rtc-exp/rooms/mocks"
+	servicemocks "github.com/imtaco/audio-rtc-exp/rooms/service/mocks"
+)
+
+type EndingTestSuite struct {
+	suite.Suite
+	ctrl            *gomock.Controller
+	mockRoomStore   *roomsmocks.MockRoomStore
+	mockRoomWatcher *servicemocks.MockRoomWatcherWithStats
+	rm              *resourceMgrImpl
+	ctx             context.Context
+	now             time.Time
+}
+
+func TestEndingSuite(t *testing.T) {
+	suite.Run(t, new(EndingTestSuite))
+}
+
+func (s *EndingTestSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.mockRoomStore = roomsmocks.NewMockRoomStore(s.ctrl)
+	s.mockRoomWatcher = servicemocks.NewMockRoomWatcherWithStats(s.ctrl)
+	s.ctx = context.Background()
+	s.now = time.Now().UTC()
+
+	s.rm = &resourceMgrImpl{
+		roomStore:   s.mockRoomStore,
+		roomWatcher: s.mockRoomWatcher,
+		logger:      log.NewTest(s.T()),
+	}
+}
+
+func (s *EndingTestSuite) TearDownTest() {
+	s.ctrl.Finish()
+}
+
+// endingState returns a room which entered stage ago
+func (s *EndingTestSuite) endingState(stage constants.EndStage, ago time.Duration) *etcdstate.RoomState {
+	return &etcdstate.RoomState{
+		Meta: &etcdstate.Meta{Ending: &etcdstate.Ending{
+			Stage:     stage,
+			StartedAt: s.now.Add(-time.Minute),
+			UpdatedAt: s.now.Add(-ago),
+		}},
+	}
+}
+
+// expectStage expects the room meta to move from one stage to another
+func (s *EndingTestSuite) expectStage(state *etcdstate.RoomState, to constants.EndStage) {
+	s.mockRoomStore.EXPECT().
+		UpdateRoom(gomock.Any(), "room-1", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, update func(*etcdstate.Meta) error) (*etcdstate.Meta, error) {
+			meta := *state.Meta
+			ending := *meta.Ending
+			meta.Ending = &ending
+			s.Require().NoError(update(&meta))
+			s.Equal(to, meta.Ending.Stage)
+			s.Equal(s.now, meta.Ending.UpdatedAt)
+			return &meta, nil
+		})
+}
+
+func (s *EndingTestSuite) advance(state *etcdstate.RoomState) error {
+	s.mockRoomWatcher.EXPECT().GetCachedState("room-1").Return(state, true)
+	return s.rm.advanceEndingRoom(s.ctx, "room-1", s.now)
+}
+
+func (s *EndingTestSuite) TestNotifying() {
+	s.Run("waits for anchors to be told", func() {
+		s.Require().NoError(s.advance(s.endingState(constants.EndStageNotifying, time.Second)))
+	})
+
+	s.Run("stops users after grace", func() {
+		state := s.endingState(constants.EndStageNotifying, endNotifyGrace)
+		s.expectStage(state, constants.EndStageStoppingUsers)
+		s.Require().NoError(s.advance(state))
+	})
+}
+
+func (s *EndingTestSuite) TestStoppingUsers() {
+	state := s.endingState(constants.EndStageStoppingUsers, endUsersGrace)
+	s.expectStage(state, constants.EndStageStoppingForwarder)
+	s.Require().NoError(s.advance(state))
+}
+
+func (s *EndingTestSuite) TestStoppingForwarder() {
+	s.Run("waits for forwarder", func() {
+		state := s.endingState(constants.EndStageStoppingForwarder, time.Second)
+		state.Janus = &etcdstate.Janus{Status: constants.JanusStatusForwarding}
+		s.Require().NoError(s.advance(state))
+	})
+
+	s.Run("stops live once forwarder stopped", func() {
+		state := s.endingState(constants.EndStageStoppingForwarder, time.Second)
+		state.Janus = &etcdstate.Janus{Status: constants.JanusStatusNotForwarding}
+		state.LiveMeta = &etcdstate.LiveMeta{Status: constants.RoomStatusOnAir}
+		s.mockRoomStore.EXPECT().StopRoom(gomock.Any(), "room-1").Return(nil)
+		s.expectStage(state, constants.EndStageStoppingLive)
+		s.Require().NoError(s.advance(state))
+	})
+
+	s.Run("stops live on timeout", func() {
+		state := s.endingState(constants.EndStageStoppingForwarder, endStepTimeout+time.Second)
+		state.Janus = &etcdstate.Janus{Status: constants.JanusStatusForwarding}
+		state.LiveMeta = &etcdstate.LiveMeta{Status: constants.RoomStatusOnAir}
+		s.mockRoomStore.EXPECT().StopRoom(gomock.Any(), "room-1").Return(nil)
+		s.expectStage(state, constants.EndStageStoppingLive)
+		s.Require().NoError(s.advance(state))
+	})
+
+	s.Run("never live", func() {
+		state := s.endingState(constants.EndStageStoppingForwarder, time.Second)
+		s.expectStage(state, constants.EndStageStoppingLive)
+		s.Require().NoError(s.advance(state))
+	})
+
+	s.Run("stop live error keeps stage", func() {
+		state := s.endingState(constants.EndStageStoppingForwarder, time.Second)
+		state.LiveMeta = &etcdstate.LiveMeta{Status: constants.RoomStatusOnAir}
+		s.mockRoomStore.EXPECT().StopRoom(gomock.Any(), "room-1").Return(errors.New("etcd error"))
+		s.Error(s.advance(state))
+	})
+}
+
+func (s *EndingTestSuite) TestStoppingLive() {
+	s.Run("waits for mixer to write the final playlist", func() {
+		state := s.endingState(constants.EndStageStoppingLive, time.Second)
+		state.Mixer = &etcdstate.Mixer{}
+		s.Require().NoError(s.advance(state))
+	})
+
+	s.Run("ended once mixer stopped", func() {
+		state := s.endingState(constants.EndStageStoppingLive, time.Second)
+		s.expectStage(state, constants.EndStageEnded)
+		s.Require().NoError(s.advance(state))
+	})
+
+	s.Run("ended on timeout", func() {
+		state := s.endingState(constants.EndStageStoppingLive, endStepTimeout+time.Second)
+		state.Mixer = &etcdstate.Mixer{}
+		s.expectStage(state, constants.EndStageEnded)
+		s.Require().NoError(s.advance(state))
+	})
+}
+
+func (s *EndingTestSuite) TestStageChangedMeanwhile() {
+	state := s.endingState(constants.EndStageStoppingUsers, endUsersGrace)
+	s.mockRoomStore.EXPECT().
+		UpdateRoom(gomock.Any(), "room-1", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, update func(*etcdstate.Meta) error) (*etcdstate.Meta, error) {
+			meta := &etcdstate.Meta{Ending: &etcdstate.Ending{Stage: constants.EndStageStoppingForwarder}}
+			return nil, update(meta)
+		})
+	s.Require().NoError(s.advance(state))
+}
+
+func (s *EndingTestSuite) TestAdvanceEndingRooms() {
+	s.mockRoomWatcher.EXPECT().EndingRooms().Return([]string{"room-1", "room-2"})
+	s.mockRoomWatcher.EXPECT().GetCachedState("room-1").Return(&etcdstate.RoomState{Meta: &etcdstate.Meta{}}, true)
+	s.mockRoomWatcher.EXPECT().GetCachedState("room-2").Return(nil, false)
+
+	s.rm.advanceEndingRooms(s.ctx)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	redisrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/redis"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/outbox"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+)
+
+// EventStream publishes room lifecycle events to a Redis stream through the etcd outbox.
+// The stream is owned by the room service, so it is also trimmed here, never past entries
+// its consumer groups have not acknowledged yet.
+type EventStream struct {
+	stream       string
+	peer         jsonrpc.Peer[any]
+	outbox       *outbox.Outbox
+	trimer       redisstream.Trimer
+	trimPolicy   *redisstream.TrimPolicy
+	trimInterval time.Duration
+	cancel       context.CancelFunc
+	logger       *log.Logger
+}
+
+func NewEventStream(
+	redisClient *redis.Client,
+	etcdClient etcd.Client,
+	stream string,
+	etcdPrefixOutbox string,
+	trimPolicy *redisstream.TrimPolicy,
+	trimInterval time.Duration,
+	logger *log.Logger,
+) (*EventStream, error) {
+	peer, err := redisrpc.NewPeer[any](redisClient, stream, "", "", logger.Module("Peer"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create room event peer: %w", err)
+	}
+
+	return &EventStream{
+		stream:       stream,
+		peer:         peer,
+		outbox:       outbox.New(etcdClient, etcdPrefixOutbox, peer, logger.Module("Outbox")),
+		trimer:       redisstream.NewTrimer(redisClient, stream, logger.Module("Trimer")),
+		trimPolicy:   trimPolicy,
+		trimInterval: trimInterval,
+		logger:       logger,
+	}, nil
+}
+
+// Writer returns the outbox room events are committed to
+func (e *EventStream) Writer() outbox.Writer {
+	return e.outbox
+}
+
+func (e *EventStream) Start(ctx context.Context) error {
+	if err := e.peer.Open(ctx); err != nil {
+		return fmt.Errorf("failed to open room event peer: %w", err)
+	}
+	if err := e.outbox.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start outbox relay: %w", err)
+	}
+
+	ctx, e.cancel = context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(e.trimInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				e.trim(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (e *EventStream) Stop() error {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.outbox.Stop()
+	return e.peer.Close()
+}
+
+func (e *EventStream) trim(ctx context.Context) {
+	res, err := e.trimer.Trim(ctx, e.trimPolicy)
+	if err != nil {
+		e.logger.Error("Failed to trim room event stream", log.String("stream", e.stream), log.Error(err))
+		return
+	}
+	if res.HeldBack {
+		e.logger.Warn("Room event stream trim held back by consumer groups",
+			log.String("stream", e.stream),
+			log.Any("lag", res.Lag))
+	}
+}
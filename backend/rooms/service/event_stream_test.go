@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+)
+
+type EventStreamTestSuite struct {
+	suite.Suite
+	mr          *miniredis.Miniredis
+	redisClient *redis.Client
+	events      *EventStream
+}
+
+func TestEventStreamSuite(t *testing.T) {
+	suite.Run(t, new(EventStreamTestSuite))
+}
+
+func (s *EventStreamTestSuite) SetupTest() {
+	mr, err := miniredis.Run()
+	s.Require().NoError(err)
+	s.mr = mr
+	s.redisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	s.events, err = NewEventStream(
+		s.redisClient,
+		nil,
+		"test:room-events",
+		"/outbox/test/",
+		&redisstream.TrimPolicy{MaxLen: 2},
+		time.Minute,
+		log.NewNop(),
+	)
+	s.Require().NoError(err)
+}
+
+func (s *EventStreamTestSuite) TearDownTest() {
+	s.redisClient.Close()
+	s.mr.Close()
+}
+
+func (s *EventStreamTestSuite) TestTrim() {
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		s.redisClient.XAdd(ctx, &redis.XAddArgs{
+			Stream: "test:room-events",
+			Values: map[string]any{"data": "event"},
+		})
+	}
+
+	s.events.trim(ctx)
+
+	s.Equal(int64(2), s.redisClient.XLen(ctx, "test:room-events").Val())
+}
+
+func (s *EventStreamTestSuite) TestWriter() {
+	s.NotNil(s.events.Writer())
+}
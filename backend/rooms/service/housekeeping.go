@@ -1,11 +1,19 @@
 package service
 
 import (
+	"cmp"
 	"context"
+	"fmt"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/timeline"
+	"github.com/imtaco/audio-rtc-exp/rooms/archive"
 	utils "github.com/imtaco/audio-rtc-exp/rooms/utils"
 )
 
@@ -13,6 +21,23 @@ const (
 	startTimeout           = 10 * time.Minute
 	inactiveGracefulPeriod = 1 * time.Minute
 	roomMaxAge             = 3 * time.Hour
+	// orphanGracePeriod lets a restarting module put its keys back before its lives are moved
+	orphanGracePeriod = 1 * time.Minute
+)
+
+// Reasons of housekeeping actions, as logged and used as metric attribute
+const (
+	reasonMalformed      = "malformed"
+	reasonInactive       = "inactive"
+	reasonExpired        = "expired"
+	reasonMaxDuration    = "max_duration"
+	reasonDiscarded      = "discarded"
+	reasonEnded          = "ended"
+	reasonMixerUnhealthy = "mixer_unhealthy"
+	reasonJanusUnhealthy = "janus_unhealthy"
+	reasonMixerMissing   = "mixer_missing"
+	reasonJanusMissing   = "janus_missing"
+	reasonModulesMissing = "modules_missing"
 )
 
 func (rm *resourceMgrImpl) checkStaleRooms(ctx context.Context) error {
@@ -47,6 +72,11 @@ func (rm *resourceMgrImpl) checkRoomModules(ctx context.Context) error {
 				log.Error(err))
 		}
 	}
+	for roomID := range rm.orphans {
+		if _, ok := rooms[roomID]; !ok {
+			delete(rm.orphans, roomID)
+		}
+	}
 
 	return nil
 }
@@ -64,40 +94,85 @@ func (rm *resourceMgrImpl) checkStaleRoom(ctx context.Context, roomID string) er
 	livemeta := state.LiveMeta
 
 	if meta == nil {
-		rm.logger.Info("Deleting malformed room", log.String("roomId", roomID))
-		malformedRoomsDeleted.Add(ctx, 1)
-		staleRoomsDeleted.Add(ctx, 1)
-		return rm.deleteRoom(ctx, roomID)
+		return rm.deleteStaleRoom(ctx, roomID, reasonMalformed)
 	}
 
 	// check if room failed to start
 	if livemeta == nil {
+		// ended before going live, nothing to wait for
+		if meta.GetEndStage() == constants.EndStageEnded {
+			return rm.deleteStaleRoom(ctx, roomID, reasonEnded)
+		}
 		if time.Since(meta.CreatedAt) > startTimeout {
-			rm.logger.Info("Deleting inactive room", log.String("roomId", roomID))
-			inactiveRoomsDeleted.Add(ctx, 1)
-			staleRoomsDeleted.Add(ctx, 1)
-			return rm.deleteRoom(ctx, roomID)
+			return rm.deleteStaleRoom(ctx, roomID, reasonInactive)
 		}
 	} else {
 		// Check if room exceeded max age
 		if livemeta.Status == constants.RoomStatusOnAir && time.Since(meta.CreatedAt) > roomMaxAge {
-			rm.logger.Info("Deleting room exceeded max age", log.String("roomId", roomID))
-			expiredRoomsDeleted.Add(ctx, 1)
-			staleRoomsDeleted.Add(ctx, 1)
-			return rm.deleteRoom(ctx, roomID)
+			return rm.deleteStaleRoom(ctx, roomID, reasonExpired)
+		}
+
+		// Check if live exceeded the max duration of the room, the gateways warned its anchors
+		if maxDuration := meta.GetMaxDuration(); livemeta.Status == constants.RoomStatusOnAir &&
+			maxDuration > 0 && time.Since(livemeta.CreatedAt) > maxDuration {
+			return rm.stopOverdueRoom(ctx, roomID)
 		}
 
 		// Check if room is in removing state and grace period has passed
 		if livemeta.DiscardAt != nil && utils.IsExceed(*livemeta.DiscardAt, inactiveGracefulPeriod) {
-			rm.logger.Info("Deleting inactive room after grace period", log.String("roomId", roomID))
-			staleRoomsDeleted.Add(ctx, 1)
-			return rm.deleteRoom(ctx, roomID)
+			return rm.deleteStaleRoom(ctx, roomID, reasonDiscarded)
 		}
 	}
 
 	return nil
 }
 
+func (rm *resourceMgrImpl) deleteStaleRoom(ctx context.Context, roomID, reason string) error {
+	if rm.dryRun.Load() {
+		rm.recordDryRun(ctx, "delete", roomID, reason)
+		return nil
+	}
+
+	rm.logger.Info("Deleting stale room",
+		log.String("roomId", roomID),
+		log.String("reason", reason))
+
+	switch reason {
+	case reasonMalformed:
+		malformedRoomsDeleted.Add(ctx, 1)
+	case reasonInactive:
+		inactiveRoomsDeleted.Add(ctx, 1)
+	case reasonExpired:
+		expiredRoomsDeleted.Add(ctx, 1)
+	}
+	staleRoomsDeleted.Add(ctx, 1)
+	return rm.deleteRoom(ctx, roomID)
+}
+
+// stopOverdueRoom stops the live of a room past its max duration, it is deleted once discarded
+func (rm *resourceMgrImpl) stopOverdueRoom(ctx context.Context, roomID string) error {
+	if rm.dryRun.Load() {
+		rm.recordDryRun(ctx, "stop", roomID, reasonMaxDuration)
+		return nil
+	}
+
+	rm.logger.Info("Stopping room past its max duration", log.String("roomId", roomID))
+	overdueRoomsStopped.Add(ctx, 1)
+	return rm.roomStore.StopRoom(ctx, roomID)
+}
+
+// recordDryRun logs and counts an action housekeeping would take if dry run was disabled
+func (rm *resourceMgrImpl) recordDryRun(ctx context.Context, action, roomID, reason string) {
+	rm.logger.Info("Dry run, skipping housekeeping action",
+		log.String("action", action),
+		log.String("roomId", roomID),
+		log.String("reason", reason))
+	housekeepingDryRunActions.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("action", action),
+		attribute.String("reason", reason),
+	))
+}
+
 func (rm *resourceMgrImpl) checkRoomModule(ctx context.Context, roomID string) error {
 	state, ok := rm.roomWatcher.GetCachedState(roomID)
 	if !ok {
@@ -109,33 +184,183 @@ func (rm *resourceMgrImpl) checkRoomModule(ctx context.Context, roomID string) e
 		return nil
 	}
 
+	mixer, mixerFound := rm.mixerWatcher.Get(livemeta.MixerID)
+	janus, janusFound := rm.janusWatcher.Get(livemeta.JanusID)
+
+	// Modules gone from etcd do not come back, the live is moved off them
+	if !mixerFound || !janusFound {
+		if err := rm.repairOrphanedLive(ctx, roomID, livemeta, !mixerFound, !janusFound); err != nil {
+			return err
+		}
+	} else {
+		delete(rm.orphans, roomID)
+	}
+
 	// Check mixer health
-	mixer, ok := rm.mixerWatcher.Get(livemeta.MixerID)
-	if !ok || !mixer.IsStable() {
+	if mixerFound && !mixer.IsStable() {
 		unhealthyMixersDetected.Add(ctx, 1)
 		rm.logger.Info("Mixer unhealthy or not ready, need to pick another",
 			log.String("roomId", roomID),
 			log.String("mixerId", livemeta.MixerID))
+		if rm.dryRun.Load() {
+			rm.recordDryRun(ctx, "reassign", roomID, reasonMixerUnhealthy)
+		}
 		// TODO: pick another mixer and update livemeta
 	}
 
 	// Check janus health
-	janus, ok := rm.janusWatcher.Get(livemeta.JanusID)
-	if !ok || !janus.IsStable() {
+	if janusFound && !janus.IsStable() {
 		unhealthyJanusesDetected.Add(ctx, 1)
 		rm.logger.Info("Janus unhealthy or not ready, need to pick another",
 			log.String("roomId", roomID),
 			log.String("janusId", livemeta.JanusID))
+		if rm.dryRun.Load() {
+			rm.recordDryRun(ctx, "reassign", roomID, reasonJanusUnhealthy)
+		}
 		// TODO: pick another janus and update livemeta
 		// how to notify andor for janus change ?
 	}
 
+	// Check anchor network quality reported by the users controller
+	if degraded := state.GetQuality().GetDegraded(); len(degraded) > 0 {
+		degradedAnchorsDetected.Add(ctx, int64(len(degraded)))
+		rm.logger.Info("Room has anchors with degraded network quality",
+			log.String("roomId", roomID),
+			log.Int("score", state.GetQuality().GetScore()),
+			log.Strings("degraded", degraded))
+	}
+
+	return nil
+}
+
+// repairOrphanedLive moves a live off the modules gone from etcd, scaled down or crashed past
+// their lease, onto picked ones. The nonce of the live is kept as for a live on the same modules,
+// the live is stopped when no module can be picked
+func (rm *resourceMgrImpl) repairOrphanedLive(
+	ctx context.Context,
+	roomID string,
+	livemeta *etcdstate.LiveMeta,
+	mixerMissing, janusMissing bool,
+) error {
+	reason := reasonMixerMissing
+	switch {
+	case mixerMissing && janusMissing:
+		reason = reasonModulesMissing
+	case janusMissing:
+		reason = reasonJanusMissing
+	}
+
+	if rm.orphans == nil {
+		rm.orphans = make(map[string]time.Time)
+	}
+	since, ok := rm.orphans[roomID]
+	if !ok {
+		rm.orphans[roomID] = time.Now()
+		rm.logger.Warn("Room is live on modules missing from etcd",
+			log.String("roomId", roomID),
+			log.String("mixerId", livemeta.MixerID),
+			log.String("janusId", livemeta.JanusID),
+			log.String("reason", reason))
+		return nil
+	}
+	if time.Since(since) < orphanGracePeriod {
+		return nil
+	}
+	if rm.dryRun.Load() {
+		rm.recordDryRun(ctx, "repair", roomID, reason)
+		return nil
+	}
+
+	var pickedMixer, pickedJanus string
+	if mixerMissing {
+		mixerID, err := rm.PickMixer(ctx, roomID)
+		if err != nil || mixerID == "" {
+			return rm.stopOrphanedLive(ctx, roomID, reason)
+		}
+		pickedMixer = mixerID
+	}
+	if janusMissing {
+		janusID, err := rm.PickJanus(ctx, roomID)
+		if err != nil || janusID == "" {
+			rm.Release(ctx, roomID, "", pickedMixer)
+			return rm.stopOrphanedLive(ctx, roomID, reason)
+		}
+		pickedJanus = janusID
+	}
+
+	mixerID := cmp.Or(pickedMixer, livemeta.MixerID)
+	janusID := cmp.Or(pickedJanus, livemeta.JanusID)
+	reassigned, err := rm.roomStore.ReassignLiveMeta(ctx, roomID, mixerID, janusID)
+	if err != nil || !reassigned {
+		rm.Release(ctx, roomID, pickedJanus, pickedMixer)
+		return err
+	}
+	delete(rm.orphans, roomID)
+
+	rm.logger.Info("Reassigned orphaned live",
+		log.String("roomId", roomID),
+		log.String("mixerId", mixerID),
+		log.String("janusId", janusID),
+		log.String("reason", reason))
+	orphanedLivesRepaired.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("action", "reassign"),
+		attribute.String("reason", reason),
+	))
+	rm.timeline.Record(roomID, timeline.EventLiveReassigned,
+		fmt.Sprintf("%s, mixer %s, janus %s", reason, mixerID, janusID))
+	return nil
+}
+
+// stopOrphanedLive stops a live no module could be picked for, the room is deleted once discarded
+func (rm *resourceMgrImpl) stopOrphanedLive(ctx context.Context, roomID, reason string) error {
+	rm.logger.Warn("Stopping orphaned live, no module available",
+		log.String("roomId", roomID),
+		log.String("reason", reason))
+	if err := rm.roomStore.StopRoom(ctx, roomID); err != nil {
+		return err
+	}
+	delete(rm.orphans, roomID)
+
+	orphanedLivesRepaired.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("action", "stop"),
+		attribute.String("reason", reason),
+	))
+	rm.timeline.Record(roomID, timeline.EventLiveStopped, reason)
 	return nil
 }
 
 func (rm *resourceMgrImpl) deleteRoom(ctx context.Context, roomID string) error {
+	if err := rm.archiveRoom(ctx, roomID); err != nil {
+		return err
+	}
+
 	// TODO: delete room in user service
 	// last step
 	_, err := rm.roomStore.DeleteRoom(ctx, roomID)
 	return err
 }
+
+// archiveRoom writes the manifest of the room before its etcd state is purged, a failure
+// keeps the room so the next cycle retries
+func (rm *resourceMgrImpl) archiveRoom(ctx context.Context, roomID string) error {
+	if rm.archiver == nil {
+		return nil
+	}
+	state, ok := rm.roomWatcher.GetCachedState(roomID)
+	if !ok {
+		return nil
+	}
+
+	manifest := archive.NewManifest(roomID, state, time.Now().UTC())
+	if err := rm.archiver.Archive(ctx, manifest); err != nil {
+		roomArchiveFailed.Add(ctx, 1)
+		return fmt.Errorf("failed to archive room: %w", err)
+	}
+	roomsArchived.Add(ctx, 1)
+
+	rm.logger.Info("Room archived",
+		log.String("roomId", roomID),
+		log.String("manifest", manifest.Name()),
+		log.Int64("liveDurationSec", manifest.LiveDurationSec))
+	return nil
+}
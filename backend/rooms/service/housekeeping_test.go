@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	watchermocks "github.com/imtaco/audio-rtc-exp/internal/reswatcher/etcd/mocks"
+	"github.com/imtaco/audio-rtc-exp/rooms/archive"
 	roomsmocks "github.com/imtaco/audio-rtc-exp/rooms/mocks"
 	servicemocks "github.com/imtaco/audio-rtc-exp/rooms/service/mocks"
 	"github.com/imtaco/audio-rtc-exp/rooms/utils"
@@ -131,6 +134,28 @@ func (s *HouseKeeperTestSuite) TestCheckStaleRooms_DeletesInactiveRoomAfterTimeo
 	s.Require().NoError(err)
 }
 
+func (s *HouseKeeperTestSuite) TestCheckStaleRooms_DeletesEndedRoomWithoutLive() {
+	s.mockRoomStore.EXPECT().
+		GetAllRooms(gomock.Any()).
+		Return(map[string]*etcdstate.Meta{"room-1": {}}, nil)
+
+	s.mockRoomWatcher.EXPECT().
+		GetCachedState("room-1").
+		Return(&etcdstate.RoomState{
+			Meta: &etcdstate.Meta{
+				CreatedAt: time.Now(),
+				Ending:    &etcdstate.Ending{Stage: constants.EndStageEnded},
+			},
+		}, true)
+
+	s.mockRoomStore.EXPECT().
+		DeleteRoom(gomock.Any(), "room-1").
+		Return(true, nil)
+
+	err := s.rm.checkStaleRooms(s.ctx)
+	s.Require().NoError(err)
+}
+
 func (s *HouseKeeperTestSuite) TestCheckStaleRooms_KeepsRecentInactiveRoom() {
 	now := time.Now()
 	recentTime := now.Add(-1 * time.Minute) // Within start timeout
@@ -189,6 +214,40 @@ func (s *HouseKeeperTestSuite) TestCheckStaleRooms_DeletesRoomExceedingMaxAge()
 	s.Require().NoError(err)
 }
 
+func (s *HouseKeeperTestSuite) TestCheckStaleRooms_StopsRoomPastMaxDuration() {
+	now := time.Now()
+
+	s.mockRoomStore.EXPECT().
+		GetAllRooms(gomock.Any()).
+		Return(map[string]*etcdstate.Meta{"room-1": {}, "room-2": {}}, nil)
+
+	s.mockRoomWatcher.EXPECT().
+		GetCachedState("room-1").
+		Return(&etcdstate.RoomState{
+			Meta: &etcdstate.Meta{CreatedAt: now.Add(-time.Hour), MaxDuration: 1800},
+			LiveMeta: &etcdstate.LiveMeta{
+				Status:    constants.RoomStatusOnAir,
+				CreatedAt: now.Add(-31 * time.Minute),
+			},
+		}, true)
+	s.mockRoomWatcher.EXPECT().
+		GetCachedState("room-2").
+		Return(&etcdstate.RoomState{
+			Meta: &etcdstate.Meta{CreatedAt: now.Add(-time.Hour), MaxDuration: 1800},
+			LiveMeta: &etcdstate.LiveMeta{
+				Status:    constants.RoomStatusOnAir,
+				CreatedAt: now.Add(-29 * time.Minute),
+			},
+		}, true)
+
+	s.mockRoomStore.EXPECT().
+		StopRoom(gomock.Any(), "room-1").
+		Return(nil)
+
+	err := s.rm.checkStaleRooms(s.ctx)
+	s.Require().NoError(err)
+}
+
 func (s *HouseKeeperTestSuite) TestCheckStaleRooms_DeletesRoomAfterGracePeriod() {
 	now := time.Now()
 	discardTime := now.Add(-(inactiveGracefulPeriod + time.Minute)) // Exceeds grace period
@@ -405,6 +464,40 @@ func (s *HouseKeeperTestSuite) TestCheckRoomModules_JanusUnhealthy() {
 	s.Require().NoError(err)
 }
 
+func (s *HouseKeeperTestSuite) TestCheckRoomModules_DegradedAnchors() {
+	s.mockRoomStore.EXPECT().
+		GetAllRooms(gomock.Any()).
+		Return(map[string]*etcdstate.Meta{"room-1": {}}, nil)
+
+	s.mockRoomWatcher.EXPECT().
+		GetCachedState("room-1").
+		Return(&etcdstate.RoomState{
+			LiveMeta: &etcdstate.LiveMeta{
+				Status:  constants.RoomStatusOnAir,
+				MixerID: "mixer-1",
+				JanusID: "janus-1",
+			},
+			Quality: &etcdstate.Quality{
+				Score:    45,
+				Degraded: []string{"user-1"},
+			},
+		}, true)
+
+	healthy := etcdstate.ModuleState{
+		Heartbeat: &etcdstate.HeartbeatData{
+			Status: constants.ModuleStatusHealthy,
+		},
+		Mark: &etcdstate.MarkData{
+			Label: constants.MarkLabelReady,
+		},
+	}
+	s.mockMixerWatcher.EXPECT().Get("mixer-1").Return(healthy, true)
+	s.mockJanusWatcher.EXPECT().Get("janus-1").Return(healthy, true)
+
+	err := s.rm.checkRoomModules(s.ctx)
+	s.Require().NoError(err)
+}
+
 func (s *HouseKeeperTestSuite) TestCheckRoomModules_BothModulesHealthy() {
 	rooms := map[string]*etcdstate.Meta{
 		"room-1": &etcdstate.Meta{},
@@ -489,6 +582,100 @@ func (s *HouseKeeperTestSuite) TestCheckRoomModules_MixerNotFound() {
 	s.Require().NoError(err)
 }
 
+func (s *HouseKeeperTestSuite) expectOrphanedLive(roomID string) {
+	s.mockRoomStore.EXPECT().
+		GetAllRooms(gomock.Any()).
+		Return(map[string]*etcdstate.Meta{roomID: {}}, nil)
+	s.mockRoomWatcher.EXPECT().
+		GetCachedState(roomID).
+		Return(&etcdstate.RoomState{
+			LiveMeta: &etcdstate.LiveMeta{
+				Status:  constants.RoomStatusOnAir,
+				MixerID: "mixer-1",
+				JanusID: "janus-1",
+			},
+		}, true)
+	// gone for longer than the grace period
+	s.rm.orphans = map[string]time.Time{roomID: time.Now().Add(-2 * orphanGracePeriod)}
+}
+
+func (s *HouseKeeperTestSuite) TestCheckRoomModules_OrphanedLiveWithinGracePeriod() {
+	s.expectOrphanedLive("room-1")
+	s.rm.orphans["room-1"] = time.Now()
+	s.mockMixerWatcher.EXPECT().Get("mixer-1").Return(etcdstate.ModuleState{}, false)
+	s.mockJanusWatcher.EXPECT().Get("janus-1").Return(etcdstate.ModuleState{}, true)
+
+	// no pick nor store update expected
+	err := s.rm.checkRoomModules(s.ctx)
+	s.Require().NoError(err)
+	s.Contains(s.rm.orphans, "room-1")
+}
+
+func (s *HouseKeeperTestSuite) TestCheckRoomModules_ReassignsOrphanedMixer() {
+	s.expectOrphanedLive("room-1")
+	s.mockMixerWatcher.EXPECT().Get("mixer-1").Return(etcdstate.ModuleState{}, false)
+	s.mockJanusWatcher.EXPECT().Get("janus-1").Return(etcdstate.ModuleState{
+		Heartbeat: &etcdstate.HeartbeatData{Status: constants.ModuleStatusHealthy},
+		Mark:      &etcdstate.MarkData{Label: constants.MarkLabelReady},
+	}, true)
+
+	s.mockMixerWatcher.EXPECT().GetAllHealthy().Return([]string{"mixer-2"})
+	s.mockMixerWatcher.EXPECT().Get("mixer-2").Return(etcdstate.ModuleState{
+		Heartbeat: &etcdstate.HeartbeatData{Status: constants.ModuleStatusHealthy, Capacity: 10},
+		Mark:      &etcdstate.MarkData{Label: constants.MarkLabelReady},
+	}, true)
+	s.mockRoomWatcher.EXPECT().GetMixerStreamCount("mixer-2").Return(0)
+	// Janus is kept
+	s.mockRoomStore.EXPECT().
+		ReassignLiveMeta(gomock.Any(), "room-1", "mixer-2", "janus-1").
+		Return(true, nil)
+
+	err := s.rm.checkRoomModules(s.ctx)
+	s.Require().NoError(err)
+	s.NotContains(s.rm.orphans, "room-1")
+}
+
+func (s *HouseKeeperTestSuite) TestCheckRoomModules_StopsOrphanedLiveWithoutModules() {
+	s.expectOrphanedLive("room-1")
+	s.mockMixerWatcher.EXPECT().Get("mixer-1").Return(etcdstate.ModuleState{
+		Heartbeat: &etcdstate.HeartbeatData{Status: constants.ModuleStatusHealthy},
+		Mark:      &etcdstate.MarkData{Label: constants.MarkLabelReady},
+	}, true)
+	s.mockJanusWatcher.EXPECT().Get("janus-1").Return(etcdstate.ModuleState{}, false)
+
+	s.mockJanusWatcher.EXPECT().GetAllHealthy().Return(nil)
+	s.mockRoomStore.EXPECT().StopRoom(gomock.Any(), "room-1").Return(nil)
+
+	err := s.rm.checkRoomModules(s.ctx)
+	s.Require().NoError(err)
+	s.NotContains(s.rm.orphans, "room-1")
+}
+
+func (s *HouseKeeperTestSuite) TestCheckRoomModules_OrphanedLiveNoLongerOnAir() {
+	s.expectOrphanedLive("room-1")
+	s.mockMixerWatcher.EXPECT().Get("mixer-1").Return(etcdstate.ModuleState{}, false)
+	s.mockJanusWatcher.EXPECT().Get("janus-1").Return(etcdstate.ModuleState{}, false)
+
+	s.mockMixerWatcher.EXPECT().GetAllHealthy().Return([]string{"mixer-2"})
+	s.mockMixerWatcher.EXPECT().Get("mixer-2").Return(etcdstate.ModuleState{
+		Heartbeat: &etcdstate.HeartbeatData{Status: constants.ModuleStatusHealthy, Capacity: 10},
+		Mark:      &etcdstate.MarkData{Label: constants.MarkLabelReady},
+	}, true)
+	s.mockRoomWatcher.EXPECT().GetMixerStreamCount("mixer-2").Return(0)
+	s.mockJanusWatcher.EXPECT().GetAllHealthy().Return([]string{"janus-2"})
+	s.mockJanusWatcher.EXPECT().Get("janus-2").Return(etcdstate.ModuleState{
+		Heartbeat: &etcdstate.HeartbeatData{Status: constants.ModuleStatusHealthy, Capacity: 10},
+		Mark:      &etcdstate.MarkData{Label: constants.MarkLabelReady},
+	}, true)
+	s.mockRoomWatcher.EXPECT().GetJanusStreamCount("janus-2").Return(0)
+	s.mockRoomStore.EXPECT().
+		ReassignLiveMeta(gomock.Any(), "room-1", "mixer-2", "janus-2").
+		Return(false, nil)
+
+	err := s.rm.checkRoomModules(s.ctx)
+	s.Require().NoError(err)
+}
+
 // Test housekeepOnce
 func (s *HouseKeeperTestSuite) TestHousekeepOnce_Success() {
 	rooms := map[string]*etcdstate.Meta{
@@ -651,3 +838,122 @@ func (s *HouseKeeperTestSuite) TestCheckRoomModules_ErrorLogging() {
 	err := s.rm.checkRoomModules(s.ctx)
 	s.Require().NoError(err) // checkRoomModules doesn't propagate individual room errors
 }
+
+// Dry run Tests
+
+func (s *HouseKeeperTestSuite) TestCheckStaleRooms_DryRunSkipsDelete() {
+	s.rm.SetHousekeepDryRun(true)
+	s.True(s.rm.HousekeepDryRun())
+
+	rooms := map[string]*etcdstate.Meta{
+		"room-1": &etcdstate.Meta{},
+		"room-2": &etcdstate.Meta{},
+	}
+
+	s.mockRoomStore.EXPECT().
+		GetAllRooms(gomock.Any()).
+		Return(rooms, nil)
+
+	s.mockRoomWatcher.EXPECT().
+		GetCachedState("room-1").
+		Return(&etcdstate.RoomState{Meta: nil}, true)
+	s.mockRoomWatcher.EXPECT().
+		GetCachedState("room-2").
+		Return(&etcdstate.RoomState{
+			Meta: &etcdstate.Meta{
+				CreatedAt: time.Now().Add(-(roomMaxAge + time.Minute)),
+			},
+			LiveMeta: &etcdstate.LiveMeta{Status: constants.RoomStatusOnAir},
+		}, true)
+
+	// no DeleteRoom expected
+	err := s.rm.checkStaleRooms(s.ctx)
+	s.Require().NoError(err)
+}
+
+func (s *HouseKeeperTestSuite) TestCheckStaleRooms_DryRunDisabledAtRuntime() {
+	s.rm.SetHousekeepDryRun(true)
+	s.rm.SetHousekeepDryRun(false)
+
+	s.mockRoomStore.EXPECT().
+		GetAllRooms(gomock.Any()).
+		Return(map[string]*etcdstate.Meta{"room-1": {}}, nil)
+	s.mockRoomWatcher.EXPECT().
+		GetCachedState("room-1").
+		Return(&etcdstate.RoomState{Meta: nil}, true)
+	s.mockRoomStore.EXPECT().
+		DeleteRoom(gomock.Any(), "room-1").
+		Return(true, nil)
+
+	err := s.rm.checkStaleRooms(s.ctx)
+	s.Require().NoError(err)
+}
+
+func (s *HouseKeeperTestSuite) TestCheckRoomModules_DryRunUnhealthyModules() {
+	s.rm.SetHousekeepDryRun(true)
+
+	s.mockRoomStore.EXPECT().
+		GetAllRooms(gomock.Any()).
+		Return(map[string]*etcdstate.Meta{"room-1": {}}, nil)
+	s.mockRoomWatcher.EXPECT().
+		GetCachedState("room-1").
+		Return(&etcdstate.RoomState{
+			LiveMeta: &etcdstate.LiveMeta{
+				Status:  constants.RoomStatusOnAir,
+				MixerID: "mixer-1",
+				JanusID: "janus-1",
+			},
+		}, true)
+	s.mockMixerWatcher.EXPECT().Get("mixer-1").Return(etcdstate.ModuleState{}, false)
+	s.mockJanusWatcher.EXPECT().Get("janus-1").Return(etcdstate.ModuleState{}, false)
+
+	err := s.rm.checkRoomModules(s.ctx)
+	s.Require().NoError(err)
+}
+
+func (s *HouseKeeperTestSuite) TestCheckRoomModules_DryRunOrphanedLive() {
+	s.rm.SetHousekeepDryRun(true)
+	s.expectOrphanedLive("room-1")
+	s.mockMixerWatcher.EXPECT().Get("mixer-1").Return(etcdstate.ModuleState{}, false)
+	s.mockJanusWatcher.EXPECT().Get("janus-1").Return(etcdstate.ModuleState{}, false)
+
+	// no pick nor store update expected
+	err := s.rm.checkRoomModules(s.ctx)
+	s.Require().NoError(err)
+	s.Contains(s.rm.orphans, "room-1")
+}
+
+func (s *HouseKeeperTestSuite) TestDeleteRoom_ArchivesBeforePurge() {
+	dir := s.T().TempDir()
+	s.rm.archiver = archive.New(&archive.Config{Dir: dir}, log.NewNop())
+
+	s.mockRoomWatcher.EXPECT().
+		GetCachedState("room-1").
+		Return(&etcdstate.RoomState{
+			Meta:    &etcdstate.Meta{CreatedAt: time.Now().Add(-time.Hour)},
+			Anchors: &etcdstate.Anchors{UserIDs: []string{"user1"}},
+		}, true)
+	s.mockRoomStore.EXPECT().
+		DeleteRoom(gomock.Any(), "room-1").
+		Return(true, nil)
+
+	s.Require().NoError(s.rm.deleteRoom(s.ctx, "room-1"))
+
+	entries, err := os.ReadDir(dir)
+	s.Require().NoError(err)
+	s.Len(entries, 1)
+}
+
+func (s *HouseKeeperTestSuite) TestDeleteRoom_ArchiveFailedKeepsRoom() {
+	// a file in place of the archive dir
+	file := filepath.Join(s.T().TempDir(), "archive")
+	s.Require().NoError(os.WriteFile(file, nil, 0o600))
+	s.rm.archiver = archive.New(&archive.Config{Dir: file}, log.NewNop())
+
+	s.mockRoomWatcher.EXPECT().
+		GetCachedState("room-1").
+		Return(&etcdstate.RoomState{Meta: &etcdstate.Meta{}}, true)
+	// no DeleteRoom expectation, the purge is retried next cycle
+
+	s.Require().Error(s.rm.deleteRoom(s.ctx, "room-1"))
+}
@@ -20,15 +20,31 @@ var (
 	availableMixers  metric.Int64UpDownCounter
 
 	// Housekeeping metrics
-	housekeepingRuns         metric.Int64Counter
-	housekeepingDuration     metric.Float64Histogram
-	staleRoomsChecked        metric.Int64Counter
-	staleRoomsDeleted        metric.Int64Counter
-	malformedRoomsDeleted    metric.Int64Counter
-	inactiveRoomsDeleted     metric.Int64Counter
-	expiredRoomsDeleted      metric.Int64Counter
-	unhealthyMixersDetected  metric.Int64Counter
-	unhealthyJanusesDetected metric.Int64Counter
+	housekeepingRuns          metric.Int64Counter
+	housekeepingDuration      metric.Float64Histogram
+	staleRoomsChecked         metric.Int64Counter
+	staleRoomsDeleted         metric.Int64Counter
+	malformedRoomsDeleted     metric.Int64Counter
+	inactiveRoomsDeleted      metric.Int64Counter
+	expiredRoomsDeleted       metric.Int64Counter
+	overdueRoomsStopped       metric.Int64Counter
+	unhealthyMixersDetected   metric.Int64Counter
+	unhealthyJanusesDetected  metric.Int64Counter
+	orphanedLivesRepaired     metric.Int64Counter
+	degradedAnchorsDetected   metric.Int64Counter
+	housekeepingDryRunActions metric.Int64Counter
+	roomsArchived             metric.Int64Counter
+	roomArchiveFailed         metric.Int64Counter
+
+	// Room ending metrics
+	roomsEnded         metric.Int64Counter
+	endStageAdvanced   metric.Int64Counter
+	endStageTimedOut   metric.Int64Counter
+	endDurationSeconds metric.Float64Histogram
+	roomsFrozen        metric.Int64Counter
+
+	// Quota metrics
+	quotaExceeded metric.Int64Counter
 
 	// Module watcher metrics
 	watcherStarted metric.Int64Counter
@@ -88,12 +104,51 @@ func init() {
 	f.Int64Counter(&expiredRoomsDeleted, "housekeeping.expired_rooms.deleted",
 		metric.WithDescription("Total expired rooms deleted (exceeded max age)"))
 
+	f.Int64Counter(&overdueRoomsStopped, "housekeeping.overdue_rooms.stopped",
+		metric.WithDescription("Lives stopped for exceeding the max duration of their room"))
+
 	f.Int64Counter(&unhealthyMixersDetected, "housekeeping.unhealthy_mixers.detected",
 		metric.WithDescription("Total unhealthy mixers detected during checks"))
 
 	f.Int64Counter(&unhealthyJanusesDetected, "housekeeping.unhealthy_januses.detected",
 		metric.WithDescription("Total unhealthy Janus servers detected during checks"))
 
+	f.Int64Counter(&orphanedLivesRepaired, "housekeeping.orphaned_lives.repaired",
+		metric.WithDescription("Total lives on modules gone from etcd, by action and reason"))
+
+	f.Int64Counter(&degradedAnchorsDetected, "housekeeping.degraded_anchors.detected",
+		metric.WithDescription("Total anchors with degraded network quality detected during checks"))
+
+	f.Int64Counter(&housekeepingDryRunActions, "housekeeping.dry_run.actions",
+		metric.WithDescription("Total housekeeping actions skipped in dry run, by action and reason"))
+
+	f.Int64Counter(&roomsArchived, "housekeeping.rooms.archived",
+		metric.WithDescription("Total archive manifests written before purging rooms"))
+
+	f.Int64Counter(&roomArchiveFailed, "housekeeping.rooms.archive_failed",
+		metric.WithDescription("Total failures writing archive manifests, purge is retried next cycle"))
+
+	// Room ending
+	f.Int64Counter(&roomsEnded, "rooms.ended",
+		metric.WithDescription("Total rooms ended through the end room API"))
+
+	f.Int64Counter(&roomsFrozen, "rooms.frozen",
+		metric.WithDescription("Total rooms frozen through the freeze room API"))
+
+	f.Int64Counter(&endStageAdvanced, "rooms.end_stage.advanced",
+		metric.WithDescription("Total end stage transitions, by stage entered"))
+
+	f.Int64Counter(&endStageTimedOut, "rooms.end_stage.timed_out",
+		metric.WithDescription("Total end stages left without their teardown confirmed, by stage"))
+
+	f.Float64Histogram(&endDurationSeconds, "rooms.end.duration",
+		metric.WithDescription("Duration from ending a room to its final playlist in seconds"),
+		metric.WithUnit("s"))
+
+	// Quotas
+	f.Int64Counter(&quotaExceeded, "quota.exceeded",
+		metric.WithDescription("Total room creations and lives rejected by tenant quotas, by resource"))
+
 	// Watcher lifecycle
 	f.Int64Counter(&watcherStarted, "watcher.started",
 		metric.WithDescription("Total watcher start operations"))
@@ -13,9 +13,8 @@ import (
 	context "context"
 	reflect "reflect"
 
-	gomock "go.uber.org/mock/gomock"
-
 	etcdstate "github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	gomock "go.uber.org/mock/gomock"
 )
 
 // MockRoomWatcherWithStats is a mock of RoomWatcherWithStats interface.
@@ -42,6 +41,20 @@ func (m *MockRoomWatcherWithStats) EXPECT() *MockRoomWatcherWithStatsMockRecorde
 	return m.recorder
 }
 
+// EndingRooms mocks base method.
+func (m *MockRoomWatcherWithStats) EndingRooms() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EndingRooms")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// EndingRooms indicates an expected call of EndingRooms.
+func (mr *MockRoomWatcherWithStatsMockRecorder) EndingRooms() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EndingRooms", reflect.TypeOf((*MockRoomWatcherWithStats)(nil).EndingRooms))
+}
+
 // GetCachedState mocks base method.
 func (m *MockRoomWatcherWithStats) GetCachedState(id string) (*etcdstate.RoomState, bool) {
 	m.ctrl.T.Helper()
@@ -85,6 +98,33 @@ func (mr *MockRoomWatcherWithStatsMockRecorder) GetMixerStreamCount(mixerID any)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMixerStreamCount", reflect.TypeOf((*MockRoomWatcherWithStats)(nil).GetMixerStreamCount), mixerID)
 }
 
+// GetRoomModules mocks base method.
+func (m *MockRoomWatcherWithStats) GetRoomModules(roomID string) (string, string) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoomModules", roomID)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	return ret0, ret1
+}
+
+// GetRoomModules indicates an expected call of GetRoomModules.
+func (mr *MockRoomWatcherWithStatsMockRecorder) GetRoomModules(roomID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoomModules", reflect.TypeOf((*MockRoomWatcherWithStats)(nil).GetRoomModules), roomID)
+}
+
+// OnRelease mocks base method.
+func (m *MockRoomWatcherWithStats) OnRelease(fn func(string, string, string)) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnRelease", fn)
+}
+
+// OnRelease indicates an expected call of OnRelease.
+func (mr *MockRoomWatcherWithStatsMockRecorder) OnRelease(fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnRelease", reflect.TypeOf((*MockRoomWatcherWithStats)(nil).OnRelease), fn)
+}
+
 // Restart mocks base method.
 func (m *MockRoomWatcherWithStats) Restart() {
 	m.ctrl.T.Helper()
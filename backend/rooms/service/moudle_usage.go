@@ -20,10 +20,11 @@ type moduleUsage struct {
 	logger  *log.Logger
 }
 
-func (m *moduleUsage) set(roomID, newModuleID string) {
+// set assigns the room to the module, returns the module the room left or empty
+func (m *moduleUsage) set(roomID, newModuleID string) string {
 	oldModuleID := m.assigns[roomID]
 	if oldModuleID == newModuleID {
-		return
+		return ""
 	}
 	if oldModuleID != "" {
 		m.counts[oldModuleID]--
@@ -46,8 +47,13 @@ func (m *moduleUsage) set(roomID, newModuleID string) {
 			log.Int("newCount", m.counts[newModuleID]),
 		)
 	}
+	return oldModuleID
 }
 
 func (m *moduleUsage) count(moduleID string) int {
 	return m.counts[moduleID]
 }
+
+func (m *moduleUsage) moduleOf(roomID string) string {
+	return m.assigns[roomID]
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/spf13/viper"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// maxReservationAttempts bounds the compare-and-swap retries of a reservation update
+const maxReservationAttempts = 5
+
+var errReservationConflict = errors.New("reservations changed concurrently, retries exhausted")
+
+type ReservationConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Prefix  string `mapstructure:"prefix"`
+	// Grace keeps reservations of rooms not seen on their module yet, covering the time
+	// between the pick and the livemeta write
+	Grace time.Duration `mapstructure:"grace"`
+}
+
+func SetupReservation(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), true)
+	v.SetDefault(p("prefix"), "/reservations/")
+	v.SetDefault(p("grace"), time.Minute)
+}
+
+// reservationKV reads and swaps reservations
+type reservationKV interface {
+	etcd.KV
+	etcd.Tx
+}
+
+// moduleReservations are the rooms holding capacity of a module, with the time they claimed it
+type moduleReservations struct {
+	Rooms map[string]time.Time `json:"rooms"`
+}
+
+// capacityReservations claims module capacity in etcd, so rooms started concurrently through
+// any rooms instance never place more rooms on a module than its heartbeat capacity:
+//
+//	<prefix><moduleType>/<moduleId>   rooms reserving the module
+//
+// Updates compare the mod revision of the key and retry on conflicts
+type capacityReservations struct {
+	kv     reservationKV
+	prefix string
+	grace  time.Duration
+	clock  clockwork.Clock
+	logger *log.Logger
+}
+
+func newCapacityReservations(kv reservationKV, cfg *ReservationConfig, logger *log.Logger) *capacityReservations {
+	return &capacityReservations{
+		kv:     kv,
+		prefix: cfg.Prefix,
+		grace:  cfg.Grace,
+		clock:  clockwork.NewRealClock(),
+		logger: logger,
+	}
+}
+
+func (c *capacityReservations) key(moduleType, moduleID string) string {
+	return c.prefix + moduleType + "/" + moduleID
+}
+
+// claim reserves capacity of the module for the room, false when the module is full. inUse is
+// the count of rooms on the module per the room watcher, rooms placed before reservations were
+// enabled count through it
+func (c *capacityReservations) claim(ctx context.Context, moduleType, moduleID, roomID string, capacity, inUse int) (bool, error) {
+	claimed := false
+	err := c.update(ctx, c.key(moduleType, moduleID), func(r *moduleReservations) bool {
+		if _, ok := r.Rooms[roomID]; ok {
+			claimed = true
+			return false
+		}
+		if max(len(r.Rooms), inUse) >= capacity {
+			claimed = false
+			return false
+		}
+		r.Rooms[roomID] = c.clock.Now()
+		claimed = true
+		return true
+	})
+	return claimed, err
+}
+
+// release gives the capacity reserved by the room back
+func (c *capacityReservations) release(ctx context.Context, moduleType, moduleID, roomID string) error {
+	return c.update(ctx, c.key(moduleType, moduleID), func(r *moduleReservations) bool {
+		if _, ok := r.Rooms[roomID]; !ok {
+			return false
+		}
+		delete(r.Rooms, roomID)
+		return true
+	})
+}
+
+// reconcile drops the reservations of rooms past the grace period which do not use the module,
+// covering releases lost while no rooms instance was watching
+func (c *capacityReservations) reconcile(ctx context.Context, moduleOf func(moduleType, roomID string) string) error {
+	resp, err := c.kv.Get(ctx, c.prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return fmt.Errorf("failed to list reservations: %w", err)
+	}
+
+	deadline := c.clock.Now().Add(-c.grace)
+	for _, kv := range resp.Kvs {
+		key := string(kv.Key)
+		moduleType, moduleID, ok := strings.Cut(strings.TrimPrefix(key, c.prefix), "/")
+		if !ok {
+			continue
+		}
+		err := c.update(ctx, key, func(r *moduleReservations) bool {
+			changed := false
+			for roomID, reservedAt := range r.Rooms {
+				if reservedAt.Before(deadline) && moduleOf(moduleType, roomID) != moduleID {
+					c.logger.Info("Dropped stale reservation",
+						log.String("moduleType", moduleType),
+						log.String("moduleId", moduleID),
+						log.String("roomId", roomID))
+					delete(r.Rooms, roomID)
+					changed = true
+				}
+			}
+			return changed
+		})
+		if err != nil {
+			c.logger.Error("Failed to reconcile reservations", log.String("key", key), log.Error(err))
+		}
+	}
+	return nil
+}
+
+// update applies fn to the reservations of the key and swaps them in when fn changed them,
+// empty reservations delete the key
+func (c *capacityReservations) update(ctx context.Context, key string, fn func(r *moduleReservations) bool) error {
+	for range maxReservationAttempts {
+		resp, err := c.kv.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to get reservations: %w", err)
+		}
+
+		r := &moduleReservations{}
+		var rev int64
+		if len(resp.Kvs) > 0 {
+			if err := json.Unmarshal(resp.Kvs[0].Value, r); err != nil {
+				return fmt.Errorf("failed to unmarshal reservations: %w", err)
+			}
+			rev = resp.Kvs[0].ModRevision
+		}
+		if r.Rooms == nil {
+			r.Rooms = make(map[string]time.Time)
+		}
+		if !fn(r) {
+			return nil
+		}
+
+		op := clientv3.OpDelete(key)
+		if len(r.Rooms) > 0 {
+			data, err := json.Marshal(r)
+			if err != nil {
+				return fmt.Errorf("failed to marshal reservations: %w", err)
+			}
+			op = clientv3.OpPut(key, string(data))
+		}
+		txnResp, err := c.kv.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
+			Then(op).
+			Commit()
+		if err != nil {
+			return fmt.Errorf("failed to update reservations: %w", err)
+		}
+		if txnResp.Succeeded {
+			return nil
+		}
+	}
+	return errReservationConflict
+}
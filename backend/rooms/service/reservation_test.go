@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// memKV is an in-memory etcd answering gets and mod revision compare transactions
+type memKV struct {
+	mu   sync.Mutex
+	rev  int64
+	data map[string]*mvccpb.KeyValue
+}
+
+func newMemKV() *memKV {
+	return &memKV{data: make(map[string]*mvccpb.KeyValue)}
+}
+
+func (m *memKV) Get(_ context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	op := clientv3.OpGet(key, opts...)
+	resp := &clientv3.GetResponse{}
+	for k, kv := range m.data {
+		if k == key || (len(op.RangeBytes()) > 0 && strings.HasPrefix(k, key)) {
+			resp.Kvs = append(resp.Kvs, kv)
+		}
+	}
+	return resp, nil
+}
+
+func (m *memKV) Put(_ context.Context, _, _ string, _ ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	panic("not used")
+}
+
+func (m *memKV) Delete(_ context.Context, _ string, _ ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	panic("not used")
+}
+
+func (m *memKV) Txn(_ context.Context) clientv3.Txn {
+	return &memTxn{kv: m}
+}
+
+type memTxn struct {
+	kv   *memKV
+	cmps []clientv3.Cmp
+	ops  []clientv3.Op
+}
+
+func (t *memTxn) If(cmps ...clientv3.Cmp) clientv3.Txn {
+	t.cmps = append(t.cmps, cmps...)
+	return t
+}
+
+func (t *memTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.ops = append(t.ops, ops...)
+	return t
+}
+
+func (t *memTxn) Else(_ ...clientv3.Op) clientv3.Txn {
+	return t
+}
+
+func (t *memTxn) Commit() (*clientv3.TxnResponse, error) {
+	m := t.kv
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, cmp := range t.cmps {
+		var rev int64
+		if kv, ok := m.data[string(cmp.Key)]; ok {
+			rev = kv.ModRevision
+		}
+		if rev != cmp.TargetUnion.(*pb.Compare_ModRevision).ModRevision {
+			return &clientv3.TxnResponse{Succeeded: false}, nil
+		}
+	}
+	m.rev++
+	for _, op := range t.ops {
+		key := string(op.KeyBytes())
+		if op.IsDelete() {
+			delete(m.data, key)
+			continue
+		}
+		m.data[key] = &mvccpb.KeyValue{Key: op.KeyBytes(), Value: op.ValueBytes(), ModRevision: m.rev}
+	}
+	return &clientv3.TxnResponse{Succeeded: true}, nil
+}
+
+func newTestReservations(kv *memKV, clock clockwork.Clock) *capacityReservations {
+	c := newCapacityReservations(kv, &ReservationConfig{Prefix: "/reservations/", Grace: time.Minute}, log.NewNop())
+	c.clock = clock
+	return c
+}
+
+func TestCapacityReservations(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("claims up to capacity", func(t *testing.T) {
+		c := newTestReservations(newMemKV(), clockwork.NewFakeClock())
+
+		for _, roomID := range []string{"room1", "room2"} {
+			claimed, err := c.claim(ctx, "mixer", "mixer1", roomID, 2, 0)
+			require.NoError(t, err)
+			assert.True(t, claimed)
+		}
+		claimed, err := c.claim(ctx, "mixer", "mixer1", "room3", 2, 0)
+		require.NoError(t, err)
+		assert.False(t, claimed)
+
+		// claiming again for a room holding a reservation succeeds
+		claimed, err = c.claim(ctx, "mixer", "mixer1", "room1", 2, 0)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+	})
+
+	t.Run("counts rooms seen on the module", func(t *testing.T) {
+		c := newTestReservations(newMemKV(), clockwork.NewFakeClock())
+
+		claimed, err := c.claim(ctx, "janus", "janus1", "room1", 2, 2)
+		require.NoError(t, err)
+		assert.False(t, claimed)
+	})
+
+	t.Run("concurrent claims never over-commit", func(t *testing.T) {
+		c := newTestReservations(newMemKV(), clockwork.NewFakeClock())
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		won := 0
+		for i := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				claimed, err := c.claim(ctx, "mixer", "mixer1", "room"+string(rune('a'+i)), 3, 0)
+				if err == nil && claimed {
+					mu.Lock()
+					won++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Positive(t, won)
+		assert.LessOrEqual(t, won, 3)
+	})
+
+	t.Run("release frees capacity and deletes empty keys", func(t *testing.T) {
+		kv := newMemKV()
+		c := newTestReservations(kv, clockwork.NewFakeClock())
+
+		_, err := c.claim(ctx, "mixer", "mixer1", "room1", 1, 0)
+		require.NoError(t, err)
+		require.NoError(t, c.release(ctx, "mixer", "mixer1", "room1"))
+		assert.Empty(t, kv.data)
+
+		claimed, err := c.claim(ctx, "mixer", "mixer1", "room2", 1, 0)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+	})
+
+	t.Run("reconcile drops stale reservations", func(t *testing.T) {
+		kv := newMemKV()
+		clock := clockwork.NewFakeClock()
+		c := newTestReservations(kv, clock)
+
+		for _, roomID := range []string{"live", "ended"} {
+			_, err := c.claim(ctx, "mixer", "mixer1", roomID, 3, 0)
+			require.NoError(t, err)
+		}
+		clock.Advance(2 * time.Minute)
+		_, err := c.claim(ctx, "mixer", "mixer1", "starting", 3, 0)
+		require.NoError(t, err)
+
+		moduleOf := func(moduleType, roomID string) string {
+			if moduleType == "mixer" && roomID == "live" {
+				return "mixer1"
+			}
+			return ""
+		}
+		require.NoError(t, c.reconcile(ctx, moduleOf))
+
+		// the ended room is dropped, the starting one is within the grace period
+		claimed, err := c.claim(ctx, "mixer", "mixer1", "next", 3, 0)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+		claimed, err = c.claim(ctx, "mixer", "mixer1", "ended", 3, 0)
+		require.NoError(t, err)
+		assert.False(t, claimed)
+	})
+}
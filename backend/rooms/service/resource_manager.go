@@ -1,14 +1,19 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"math/rand/v2"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	etcdwatcher "github.com/imtaco/audio-rtc-exp/internal/reswatcher/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/timeline"
 	"github.com/imtaco/audio-rtc-exp/rooms"
+	"github.com/imtaco/audio-rtc-exp/rooms/archive"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
@@ -18,6 +23,11 @@ type resourceMgrImpl struct {
 	roomWatcher  RoomWatcherWithStats
 	janusWatcher etcdwatcher.HealthyModuleWatcher
 	mixerWatcher etcdwatcher.HealthyModuleWatcher
+	archiver     archive.Archiver      // nil disables archiving
+	reservations *capacityReservations // nil places rooms by the watched usage only
+	timeline     *timeline.Writer      // nil records no room timeline
+	dryRun       atomic.Bool
+	orphans      map[string]time.Time // rooms live on missing modules since, housekeeping only
 	stopCh       chan struct{}
 	logger       *log.Logger
 }
@@ -32,6 +42,10 @@ func NewResourceManager(
 	prefixRoom string,
 	prefixJanus string,
 	prefixMixer string,
+	archiver archive.Archiver,
+	reservationCfg *ReservationConfig,
+	timelineWriter *timeline.Writer,
+	dryRun bool,
 	logger *log.Logger,
 ) rooms.ResourceManager {
 	// Use custom room watcher with statistics
@@ -43,14 +57,22 @@ func NewResourceManager(
 	janusWatcher := etcdwatcher.NewHealthyModuleWatcher(etcdClient, prefixJanus, logger.Module("Janus"))
 	mixerWatcher := etcdwatcher.NewHealthyModuleWatcher(etcdClient, prefixMixer, logger.Module("Mixer"))
 
-	return &resourceMgrImpl{
+	rm := &resourceMgrImpl{
 		roomStore:    roomStore,
 		roomWatcher:  roomWatcher,
 		janusWatcher: janusWatcher,
 		mixerWatcher: mixerWatcher,
+		archiver:     archiver,
+		timeline:     timelineWriter,
 		stopCh:       make(chan struct{}),
 		logger:       logger,
 	}
+	if reservationCfg != nil && reservationCfg.Enabled {
+		rm.reservations = newCapacityReservations(etcdClient, reservationCfg, logger.Module("Reservation"))
+		roomWatcher.OnRelease(rm.releaseModule)
+	}
+	rm.dryRun.Store(dryRun)
+	return rm
 }
 
 func (rm *resourceMgrImpl) Start(ctx context.Context) error {
@@ -74,6 +96,7 @@ func (rm *resourceMgrImpl) Start(ctx context.Context) error {
 
 	// Start housekeeping in background
 	go rm.housekeepLoop()
+	go rm.endingLoop()
 
 	return nil
 }
@@ -93,8 +116,19 @@ func (rm *resourceMgrImpl) housekeepLoop() {
 	}
 }
 
+// SetHousekeepDryRun toggles dry run, housekeeping then only logs and counts what it would change
+func (rm *resourceMgrImpl) SetHousekeepDryRun(enabled bool) {
+	if rm.dryRun.Swap(enabled) != enabled {
+		rm.logger.Info("Housekeeping dry run toggled", log.Bool("dryRun", enabled))
+	}
+}
+
+func (rm *resourceMgrImpl) HousekeepDryRun() bool {
+	return rm.dryRun.Load()
+}
+
 func (rm *resourceMgrImpl) housekeepOnce() {
-	rm.logger.Info("Starting housekeeping cycle")
+	rm.logger.Info("Starting housekeeping cycle", log.Bool("dryRun", rm.dryRun.Load()))
 
 	ctx := context.Background()
 	startTime := time.Now()
@@ -107,6 +141,11 @@ func (rm *resourceMgrImpl) housekeepOnce() {
 	if err := rm.checkRoomModules(ctx); err != nil {
 		rm.logger.Error("Error during checking room modules", log.Error(err))
 	}
+	if rm.reservations != nil {
+		if err := rm.reservations.reconcile(ctx, rm.moduleOf); err != nil {
+			rm.logger.Error("Error during reconciling reservations", log.Error(err))
+		}
+	}
 
 	duration := time.Since(startTime).Seconds()
 	housekeepingDuration.Record(ctx, duration)
@@ -137,12 +176,15 @@ func (rm *resourceMgrImpl) Stop() error {
 	return nil
 }
 
-func (rm *resourceMgrImpl) PickJanus() (string, error) {
-	ctx := context.Background()
-	rm.logger.Debug("Picking Janus for room")
+func (rm *resourceMgrImpl) PickJanus(ctx context.Context, roomID string) (string, error) {
+	rm.logger.Debug("Picking Janus for room", log.String("roomId", roomID))
 
 	janusPickAttempts.Add(ctx, 1)
-	janusID := rm.randomPickModule(rm.janusWatcher, "janus")
+	janusID, err := rm.pickModule(ctx, rm.janusWatcher, "janus", roomID)
+	if err != nil {
+		janusPickFailed.Add(ctx, 1)
+		return "", err
+	}
 
 	if janusID == "" {
 		janusPickFailed.Add(ctx, 1)
@@ -153,12 +195,15 @@ func (rm *resourceMgrImpl) PickJanus() (string, error) {
 	return janusID, nil
 }
 
-func (rm *resourceMgrImpl) PickMixer() (string, error) {
-	ctx := context.Background()
-	rm.logger.Debug("Picking mixer for room")
+func (rm *resourceMgrImpl) PickMixer(ctx context.Context, roomID string) (string, error) {
+	rm.logger.Debug("Picking mixer for room", log.String("roomId", roomID))
 
 	mixerPickAttempts.Add(ctx, 1)
-	mixerID := rm.randomPickModule(rm.mixerWatcher, "mixer")
+	mixerID, err := rm.pickModule(ctx, rm.mixerWatcher, "mixer", roomID)
+	if err != nil {
+		mixerPickFailed.Add(ctx, 1)
+		return "", err
+	}
 
 	if mixerID == "" {
 		mixerPickFailed.Add(ctx, 1)
@@ -169,8 +214,108 @@ func (rm *resourceMgrImpl) PickMixer() (string, error) {
 	return mixerID, nil
 }
 
-func (rm *resourceMgrImpl) randomPickModule(watcher etcdwatcher.HealthyModuleWatcher, moduleType string) string {
-	var pickableKeys []string
+// Release gives back the capacity reserved for a room which did not go live on the modules
+func (rm *resourceMgrImpl) Release(ctx context.Context, roomID, janusID, mixerID string) {
+	if rm.reservations == nil {
+		return
+	}
+	for moduleType, moduleID := range map[string]string{"janus": janusID, "mixer": mixerID} {
+		// the room may already be live on the module, its reservation is still used then
+		if moduleID == "" || rm.moduleOf(moduleType, roomID) == moduleID {
+			continue
+		}
+		if err := rm.reservations.release(ctx, moduleType, moduleID, roomID); err != nil {
+			rm.logger.Error("Failed to release reservation",
+				log.String("roomId", roomID),
+				log.String("moduleType", moduleType),
+				log.String("moduleId", moduleID),
+				log.Error(err))
+		}
+	}
+}
+
+// releaseModule gives back the capacity of a room which left the module
+func (rm *resourceMgrImpl) releaseModule(moduleType, moduleID, roomID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := rm.reservations.release(ctx, moduleType, moduleID, roomID); err != nil {
+		rm.logger.Error("Failed to release reservation",
+			log.String("roomId", roomID),
+			log.String("moduleType", moduleType),
+			log.String("moduleId", moduleID),
+			log.Error(err))
+	}
+}
+
+// moduleOf returns the module of the type the room is live on per the room watcher
+func (rm *resourceMgrImpl) moduleOf(moduleType, roomID string) string {
+	janusID, mixerID := rm.roomWatcher.GetRoomModules(roomID)
+	if moduleType == "janus" {
+		return janusID
+	}
+	return mixerID
+}
+
+// pickModule picks a module with spare capacity at random, reserving the capacity when
+// reservations are enabled. Modules filled concurrently are skipped for the next candidate.
+// Modules reported degraded are only picked when no other module has capacity
+func (rm *resourceMgrImpl) pickModule(
+	ctx context.Context,
+	watcher etcdwatcher.HealthyModuleWatcher,
+	moduleType, roomID string,
+) (string, error) {
+	candidates := rm.pickableModules(watcher, moduleType)
+	if rm.reservations == nil {
+		if len(candidates) == 0 {
+			return "", nil
+		}
+		if preferred := slices.DeleteFunc(slices.Clone(candidates), pickableModule.isDegraded); len(preferred) > 0 {
+			candidates = preferred
+		}
+		// Randomly pick one
+		return candidates[rand.IntN(len(candidates))].id, nil // #nosec G404 -- weak random is acceptable for load balancing resource selection, no security impact
+	}
+
+	rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] }) // #nosec G404 -- load balancing only
+	slices.SortStableFunc(candidates, func(a, b pickableModule) int {
+		return cmp.Compare(b2i(a.degraded), b2i(b.degraded))
+	})
+	for _, c := range candidates {
+		claimed, err := rm.reservations.claim(ctx, moduleType, c.id, roomID, c.capacity, c.streams)
+		if err != nil {
+			return "", fmt.Errorf("failed to reserve %s capacity: %w", moduleType, err)
+		}
+		if claimed {
+			return c.id, nil
+		}
+		rm.logger.Debug("Module filled concurrently",
+			log.String("moduleType", moduleType),
+			log.String("moduleID", c.id))
+	}
+	return "", nil
+}
+
+// pickableModule is a module below its capacity per the watched usage
+type pickableModule struct {
+	id       string
+	capacity int
+	streams  int
+	degraded bool
+}
+
+func (m pickableModule) isDegraded() bool {
+	return m.degraded
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (rm *resourceMgrImpl) pickableModules(watcher etcdwatcher.HealthyModuleWatcher, moduleType string) []pickableModule {
+	var pickable []pickableModule
 
 	// Note that GetStreamCount might be delayed due to eventual consistency
 	// It's hard to precisely track real-time usage
@@ -205,15 +350,14 @@ func (rm *resourceMgrImpl) randomPickModule(watcher etcdwatcher.HealthyModuleWat
 		)
 
 		if currentStreams < capacity {
-			pickableKeys = append(pickableKeys, id)
+			pickable = append(pickable, pickableModule{
+				id:       id,
+				capacity: capacity,
+				streams:  currentStreams,
+				degraded: data.IsDegraded(),
+			})
 			continue
 		}
 	}
-
-	if len(pickableKeys) == 0 {
-		return ""
-	}
-
-	// Randomly pick one
-	return pickableKeys[rand.IntN(len(pickableKeys))] // #nosec G404 -- weak random is acceptable for load balancing resource selection, no security impact
+	return pickable
 }
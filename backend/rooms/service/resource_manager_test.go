@@ -13,6 +13,7 @@ import (
 	roomsmocks "github.com/imtaco/audio-rtc-exp/rooms/mocks"
 	servicemocks "github.com/imtaco/audio-rtc-exp/rooms/service/mocks"
 
+	"github.com/jonboulle/clockwork"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/mock/gomock"
 )
@@ -73,6 +74,9 @@ func (s *ResourceManagerTestSuite) TestStart_Success() {
 		Start(gomock.Any()).
 		Return(nil)
 
+	// the ending loop may tick before the test ends
+	s.mockRoomWatcher.EXPECT().EndingRooms().Return(nil).AnyTimes()
+
 	err := s.rm.Start(s.ctx)
 	s.Require().NoError(err)
 }
@@ -196,7 +200,7 @@ func (s *ResourceManagerTestSuite) TestPickJanus_Success() {
 		GetJanusStreamCount("janus-2").
 		Return(0)
 
-	janusID, err := s.rm.PickJanus()
+	janusID, err := s.rm.PickJanus(s.ctx, "room1")
 	s.Require().NoError(err)
 	s.NotEmpty(janusID)
 	s.Contains([]string{"janus-1", "janus-2"}, janusID)
@@ -207,7 +211,7 @@ func (s *ResourceManagerTestSuite) TestPickJanus_NoHealthyModules() {
 		GetAllHealthy().
 		Return([]string{})
 
-	janusID, err := s.rm.PickJanus()
+	janusID, err := s.rm.PickJanus(s.ctx, "room1")
 	s.Require().NoError(err)
 	s.Empty(janusID)
 }
@@ -227,7 +231,7 @@ func (s *ResourceManagerTestSuite) TestPickJanus_NoPickableModules() {
 		Get("janus-1").
 		Return(unpickableModule, true)
 
-	janusID, err := s.rm.PickJanus()
+	janusID, err := s.rm.PickJanus(s.ctx, "room1")
 	s.Require().NoError(err)
 	s.Empty(janusID)
 }
@@ -265,18 +269,73 @@ func (s *ResourceManagerTestSuite) TestPickMixer_Success() {
 		GetMixerStreamCount("mixer-2").
 		Return(0)
 
-	mixerID, err := s.rm.PickMixer()
+	mixerID, err := s.rm.PickMixer(s.ctx, "room1")
 	s.Require().NoError(err)
 	s.NotEmpty(mixerID)
 	s.Contains([]string{"mixer-1", "mixer-2"}, mixerID)
 }
 
+func (s *ResourceManagerTestSuite) TestPickMixer_SkipsReservedModules() {
+	s.rm.reservations = newTestReservations(newMemKV(), clockwork.NewFakeClock())
+	pickableModule := etcdstate.ModuleState{
+		Heartbeat: &etcdstate.HeartbeatData{
+			Status:   constants.ModuleStatusHealthy,
+			Capacity: 1,
+		},
+		Mark: &etcdstate.MarkData{
+			Label: constants.MarkLabelReady,
+		},
+	}
+	s.mockMixerWatcher.EXPECT().GetAllHealthy().Return([]string{"mixer-1", "mixer-2"}).Times(3)
+	s.mockMixerWatcher.EXPECT().Get(gomock.Any()).Return(pickableModule, true).Times(6)
+	// rooms picked concurrently are not seen by the room watcher yet
+	s.mockRoomWatcher.EXPECT().GetMixerStreamCount(gomock.Any()).Return(0).Times(6)
+
+	first, err := s.rm.PickMixer(s.ctx, "room1")
+	s.Require().NoError(err)
+	second, err := s.rm.PickMixer(s.ctx, "room2")
+	s.Require().NoError(err)
+	s.ElementsMatch([]string{"mixer-1", "mixer-2"}, []string{first, second})
+
+	third, err := s.rm.PickMixer(s.ctx, "room3")
+	s.Require().NoError(err)
+	s.Empty(third)
+}
+
+func (s *ResourceManagerTestSuite) TestPickJanus_PrefersNotDegraded() {
+	healthy := etcdstate.ModuleState{
+		Heartbeat: &etcdstate.HeartbeatData{Status: constants.ModuleStatusHealthy, Capacity: 1},
+	}
+	degraded := healthy
+	degraded.Degraded = &etcdstate.DegradedData{Reason: "circuit open"}
+
+	s.mockJanusWatcher.EXPECT().GetAllHealthy().Return([]string{"janus-1", "janus-2"}).AnyTimes()
+	s.mockJanusWatcher.EXPECT().Get("janus-1").Return(degraded, true).AnyTimes()
+	s.mockJanusWatcher.EXPECT().Get("janus-2").Return(healthy, true).AnyTimes()
+	s.mockRoomWatcher.EXPECT().GetJanusStreamCount(gomock.Any()).Return(0).AnyTimes()
+
+	for range 10 {
+		janusID, err := s.rm.PickJanus(s.ctx, "room1")
+		s.Require().NoError(err)
+		s.Equal("janus-2", janusID)
+	}
+
+	// degraded modules still take rooms once the others are full, with reservations too
+	s.rm.reservations = newTestReservations(newMemKV(), clockwork.NewFakeClock())
+	first, err := s.rm.PickJanus(s.ctx, "room1")
+	s.Require().NoError(err)
+	s.Equal("janus-2", first)
+	second, err := s.rm.PickJanus(s.ctx, "room2")
+	s.Require().NoError(err)
+	s.Equal("janus-1", second)
+}
+
 func (s *ResourceManagerTestSuite) TestPickMixer_NoHealthyModules() {
 	s.mockMixerWatcher.EXPECT().
 		GetAllHealthy().
 		Return([]string{})
 
-	mixerID, err := s.rm.PickMixer()
+	mixerID, err := s.rm.PickMixer(s.ctx, "room1")
 	s.Require().NoError(err)
 	s.Empty(mixerID)
 }
@@ -296,11 +355,34 @@ func (s *ResourceManagerTestSuite) TestPickMixer_NoPickableModules() {
 		Get("mixer-1").
 		Return(unpickableModule, true)
 
-	mixerID, err := s.rm.PickMixer()
+	mixerID, err := s.rm.PickMixer(s.ctx, "room1")
 	s.Require().NoError(err)
 	s.Empty(mixerID)
 }
 
+func (s *ResourceManagerTestSuite) TestPickMixer_SkipsSaturatedModules() {
+	saturated := etcdstate.ModuleState{
+		Heartbeat: &etcdstate.HeartbeatData{Status: constants.ModuleStatusSaturated, Capacity: 10},
+	}
+	healthy := etcdstate.ModuleState{
+		Heartbeat: &etcdstate.HeartbeatData{Status: constants.ModuleStatusHealthy, Capacity: 10},
+	}
+	// saturated mixers keep serving their rooms
+	s.True(saturated.IsHealthy())
+	s.True(saturated.IsStable())
+
+	s.mockMixerWatcher.EXPECT().GetAllHealthy().Return([]string{"mixer-1", "mixer-2"}).AnyTimes()
+	s.mockMixerWatcher.EXPECT().Get("mixer-1").Return(saturated, true).AnyTimes()
+	s.mockMixerWatcher.EXPECT().Get("mixer-2").Return(healthy, true).AnyTimes()
+	s.mockRoomWatcher.EXPECT().GetMixerStreamCount("mixer-2").Return(0).AnyTimes()
+
+	for range 10 {
+		mixerID, err := s.rm.PickMixer(s.ctx, "room1")
+		s.Require().NoError(err)
+		s.Equal("mixer-2", mixerID)
+	}
+}
+
 // randomPickModule Tests
 
 func (s *ResourceManagerTestSuite) TestRandomPickModule_MultipleCalls() {
@@ -344,7 +426,7 @@ func (s *ResourceManagerTestSuite) TestRandomPickModule_MultipleCalls() {
 			GetJanusStreamCount("janus-3").
 			Return(0)
 
-		janusID, err := s.rm.PickJanus()
+		janusID, err := s.rm.PickJanus(s.ctx, "room1")
 		s.Require().NoError(err)
 		s.NotEmpty(janusID)
 	}
@@ -394,7 +476,7 @@ func (s *ResourceManagerTestSuite) TestPickJanus_WithCapacityLimit() {
 		GetJanusStreamCount("janus-3").
 		Return(6) // Over capacity
 
-	janusID, err := s.rm.PickJanus()
+	janusID, err := s.rm.PickJanus(s.ctx, "room1")
 	s.Require().NoError(err)
 	s.Equal("janus-1", janusID) // Only janus-1 should be picked
 }
@@ -430,7 +512,7 @@ func (s *ResourceManagerTestSuite) TestPickJanus_AllAtCapacity() {
 		GetJanusStreamCount("janus-2").
 		Return(4) // Over capacity
 
-	janusID, err := s.rm.PickJanus()
+	janusID, err := s.rm.PickJanus(s.ctx, "room1")
 	s.Require().NoError(err)
 	s.Empty(janusID) // No module available
 }
@@ -455,7 +537,7 @@ func (s *ResourceManagerTestSuite) TestPickJanus_NoCapacitySet() {
 		Get("janus-1").
 		Return(moduleNoCapacity, true)
 
-	janusID, err := s.rm.PickJanus()
+	janusID, err := s.rm.PickJanus(s.ctx, "room1")
 	s.Require().NoError(err)
 	s.Empty(janusID)
 }
@@ -493,7 +575,7 @@ func (s *ResourceManagerTestSuite) TestPickMixer_WithCapacityLimit() {
 		GetMixerStreamCount("mixer-2").
 		Return(10) // At capacity
 
-	mixerID, err := s.rm.PickMixer()
+	mixerID, err := s.rm.PickMixer(s.ctx, "room1")
 	s.Require().NoError(err)
 	s.Equal("mixer-1", mixerID) // Only mixer-1 should be picked
 }
@@ -537,7 +619,7 @@ func (s *ResourceManagerTestSuite) TestPickMixer_MixedCapacityAndNoCapacity() {
 		GetMixerStreamCount("mixer-1").
 		Return(2) // Under capacity
 
-	mixerID, err := s.rm.PickMixer()
+	mixerID, err := s.rm.PickMixer(s.ctx, "room1")
 	s.Require().NoError(err)
 	s.Equal("mixer-1", mixerID) // Only mixer-1 should be picked
 }
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/rooms"
+)
+
+func (rs *roomSvcImpl) GetRoomDetail(ctx context.Context, roomID string) (*rooms.RoomDetail, error) {
+	room, err := rs.GetRoom(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &rooms.RoomDetail{Room: room}
+	fail := func(part string, err error) {
+		rs.logger.Warn("Failed to get room detail",
+			log.String("roomId", roomID),
+			log.String("part", part),
+			log.Error(err))
+		if detail.Errors == nil {
+			detail.Errors = make(map[string]string)
+		}
+		detail.Errors[part] = err.Error()
+	}
+
+	state, err := rs.roomStore.GetRoomState(ctx, roomID)
+	if err != nil {
+		fail(rooms.RoomDetailState, err)
+	}
+	detail.LiveMeta = state.GetLiveMeta()
+	detail.Mixer = state.GetMixer()
+	detail.Janus = state.GetJanus()
+	detail.Link = state.GetLink()
+	detail.Quality = state.GetQuality()
+	if detail.Mixer != nil {
+		detail.FFmpeg = rooms.FFmpegRunning
+		if detail.Mixer.Degraded {
+			detail.FFmpeg = rooms.FFmpegDegraded
+		}
+	}
+
+	if detail.MixerModule, err = rs.moduleStatus(ctx, rooms.ModuleTypeMixers, detail.LiveMeta.GetMixerID()); err != nil {
+		fail(rooms.RoomDetailModules, err)
+	}
+	if detail.JanusModule, err = rs.moduleStatus(ctx, rooms.ModuleTypeJanuses, detail.LiveMeta.GetJanusID()); err != nil {
+		fail(rooms.RoomDetailModules, err)
+	}
+
+	if rs.roomUsers != nil && detail.LiveMeta != nil {
+		if detail.Users, err = rs.roomUsers.GetActiveRoomUsers(ctx, roomID); err != nil {
+			fail(rooms.RoomDetailUsers, err)
+		}
+	}
+	return detail, nil
+}
+
+// moduleStatus returns the status of a module assigned to a room, nil when none is assigned or
+// the module is gone
+func (rs *roomSvcImpl) moduleStatus(ctx context.Context, moduleType, moduleID string) (*rooms.ModuleStatus, error) {
+	if moduleID == "" {
+		//nolint:nilnil
+		return nil, nil
+	}
+	statuses, err := rs.roomStore.ListModuleStatus(ctx, moduleType)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		if status.ModuleID == moduleID {
+			return status, nil
+		}
+	}
+	//nolint:nilnil
+	return nil, nil
+}
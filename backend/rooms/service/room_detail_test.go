@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/rooms"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+// roomUsersFunc adapts a function to rooms.RoomUsersReader
+type roomUsersFunc func(ctx context.Context, roomID string) ([]*users.RoomUser, error)
+
+func (f roomUsersFunc) GetActiveRoomUsers(ctx context.Context, roomID string) ([]*users.RoomUser, error) {
+	return f(ctx, roomID)
+}
+
+func (s *RoomServiceTestSuite) TestGetRoomDetail() {
+	roomUsers := []*users.RoomUser{{UserID: "user1", Role: "anchor", Status: constants.AnchorStatusOnAir}}
+	var usersErr error
+	s.svc.roomUsers = roomUsersFunc(func(_ context.Context, roomID string) ([]*users.RoomUser, error) {
+		s.Equal("room1", roomID)
+		return roomUsers, usersErr
+	})
+
+	expectRoom := func() {
+		s.mockStore.EXPECT().GetRoom(gomock.Any(), "room1").Return(&etcdstate.Meta{Pin: "1234"}, nil)
+		s.mockStore.EXPECT().GetMixerData(gomock.Any(), "room1").Return(nil, nil)
+		s.mockStore.EXPECT().GetLatency(gomock.Any(), "room1").Return(nil, nil)
+	}
+	state := &etcdstate.RoomState{
+		LiveMeta: &etcdstate.LiveMeta{Status: constants.RoomStatusOnAir, MixerID: "mixer1", JanusID: "janus1"},
+		Mixer:    &etcdstate.Mixer{ID: "mixer1", Port: 5004, Degraded: true},
+		Janus:    &etcdstate.Janus{JanusID: "janus1", Status: "ready"},
+	}
+
+	s.Run("aggregates all parts", func() {
+		expectRoom()
+		s.mockStore.EXPECT().GetRoomState(gomock.Any(), "room1").Return(state, nil)
+		s.mockStore.EXPECT().ListModuleStatus(gomock.Any(), rooms.ModuleTypeMixers).
+			Return([]*rooms.ModuleStatus{{ModuleID: "mixer2"}, {ModuleID: "mixer1", Healthy: true}}, nil)
+		s.mockStore.EXPECT().ListModuleStatus(gomock.Any(), rooms.ModuleTypeJanuses).
+			Return([]*rooms.ModuleStatus{{ModuleID: "janus1", Healthy: true}}, nil)
+
+		detail, err := s.svc.GetRoomDetail(s.ctx, "room1")
+		s.Require().NoError(err)
+		s.Equal("room1", detail.Room.RoomID)
+		s.Equal(state.LiveMeta, detail.LiveMeta)
+		s.Equal(state.Janus, detail.Janus)
+		s.Equal(rooms.FFmpegDegraded, detail.FFmpeg)
+		s.Equal(&rooms.ModuleStatus{ModuleID: "mixer1", Healthy: true}, detail.MixerModule)
+		s.Equal(&rooms.ModuleStatus{ModuleID: "janus1", Healthy: true}, detail.JanusModule)
+		s.Equal(roomUsers, detail.Users)
+		s.Empty(detail.Errors)
+	})
+
+	s.Run("reports failing parts", func() {
+		usersErr = errors.New("users timeout")
+		expectRoom()
+		s.mockStore.EXPECT().GetRoomState(gomock.Any(), "room1").Return(state, nil)
+		s.mockStore.EXPECT().ListModuleStatus(gomock.Any(), gomock.Any()).
+			Return(nil, errors.New("etcd down")).Times(2)
+
+		detail, err := s.svc.GetRoomDetail(s.ctx, "room1")
+		s.Require().NoError(err)
+		s.Equal(rooms.FFmpegDegraded, detail.FFmpeg)
+		s.Nil(detail.MixerModule)
+		s.Equal(map[string]string{
+			rooms.RoomDetailModules: "etcd down",
+			rooms.RoomDetailUsers:   "users timeout",
+		}, detail.Errors)
+	})
+
+	s.Run("room not found", func() {
+		s.mockStore.EXPECT().GetRoom(gomock.Any(), "room1").Return(nil, nil)
+
+		_, err := s.svc.GetRoomDetail(s.ctx, "room1")
+		var notFound *rooms.RoomNotFoundError
+		s.ErrorAs(err, &notFound)
+	})
+}
@@ -2,36 +2,70 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/timeline"
+	"github.com/imtaco/audio-rtc-exp/internal/urlsign"
 	"github.com/imtaco/audio-rtc-exp/rooms"
 	utils "github.com/imtaco/audio-rtc-exp/rooms/utils"
 )
 
+// errAlreadyEnding aborts the meta update of a room already ending
+var (
+	errAlreadyEnding   = errors.New("room is already ending")
+	errFreezeUnchanged = errors.New("room is already frozen or unfrozen")
+)
+
 type roomSvcImpl struct {
 	roomStore rooms.RoomStore
 	resMgr    rooms.ResourceManager
+	roomUsers rooms.RoomUsersReader // optional, nil leaves users out of room details
 	hlsAdvURL string
+	hlsSigner *urlsign.Signer  // optional, nil means plain HLS URLs
+	timeline  *timeline.Writer // optional, nil records no room timeline
 	logger    *log.Logger
 }
 
 func NewRoomService(
 	roomStore rooms.RoomStore,
 	resMgr rooms.ResourceManager,
+	roomUsers rooms.RoomUsersReader,
 	hlsAdvURL string,
+	hlsSigner *urlsign.Signer,
+	timelineWriter *timeline.Writer,
 	logger *log.Logger,
 ) rooms.RoomService {
 	return &roomSvcImpl{
 		roomStore: roomStore,
 		resMgr:    resMgr,
+		roomUsers: roomUsers,
 		hlsAdvURL: hlsAdvURL,
+		hlsSigner: hlsSigner,
+		timeline:  timelineWriter,
 		logger:    logger,
 	}
 }
 
-func (rs *roomSvcImpl) CreateRoom(ctx context.Context, roomID, pin string, maxAnchors int) (*rooms.RoomResponse, error) {
+// hlsURL builds the playlist URL of a room, signed with an expiry when signing is enabled
+func (rs *roomSvcImpl) hlsURL(roomID string, room *etcdstate.Meta) string {
+	hlsURL := rs.hlsAdvURL + room.HLSPath
+	if rs.hlsSigner != nil {
+		hlsURL = rs.hlsSigner.SignURL(hlsURL, roomID)
+	}
+	return hlsURL
+}
+
+func (rs *roomSvcImpl) CreateRoom(ctx context.Context, roomID string, params *rooms.CreateRoomParams) (*rooms.RoomResponse, error) {
 	// Check if room already exists
 	exists, err := rs.roomStore.Exists(ctx, roomID)
 	if err != nil {
@@ -43,33 +77,58 @@ func (rs *roomSvcImpl) CreateRoom(ctx context.Context, roomID, pin string, maxAn
 
 	// Store room data
 	room, err := rs.roomStore.CreateRoom(ctx, roomID, &etcdstate.Meta{
-		Pin:        pin,
-		HLSPath:    fmt.Sprintf("%s/stream.m3u8", roomID),
-		MaxAnchors: maxAnchors,
+		Pin:         params.Pin,
+		HLSPath:     fmt.Sprintf("%s/stream.m3u8", roomID),
+		ExternalID:  params.ExternalID,
+		Tenant:      params.Tenant,
+		MaxAnchors:  params.MaxAnchors,
+		MaxBitrate:  params.MaxBitrate,
+		DVRWindow:   params.DVRWindow,
+		MaxDuration: params.MaxDuration,
+		Audio:       params.Audio,
+		Tags:        params.Tags,
 	})
 	if err != nil {
+		countQuotaExceeded(ctx, err)
 		return nil, fmt.Errorf("failed to create room: %w", err)
 	}
+	rs.timeline.Record(roomID, timeline.EventCreated, "")
 
 	return &rooms.RoomResponse{
-		RoomID:    roomID,
-		HLSURL:    rs.hlsAdvURL + room.HLSPath,
-		Pin:       room.Pin,
-		CreatedAt: room.CreatedAt,
+		RoomID:      roomID,
+		ExternalID:  room.ExternalID,
+		HLSURL:      rs.hlsURL(roomID, room),
+		Pin:         room.Pin,
+		MaxAnchors:  room.MaxAnchors,
+		MaxBitrate:  room.MaxBitrate,
+		DVRWindow:   room.DVRWindow,
+		MaxDuration: room.MaxDuration,
+		Audio:       room.Audio,
+		Tags:        room.Tags,
+		CreatedAt:   room.CreatedAt,
 	}, nil
 }
 
 func (rs *roomSvcImpl) StartLive(ctx context.Context, roomID string) error {
-	mixerID, err := rs.resMgr.PickMixer()
+	mixerID, err := rs.resMgr.PickMixer(ctx, roomID)
 	if err != nil || mixerID == "" {
 		return fmt.Errorf("no available mixer")
 	}
 
-	janusID, err := rs.resMgr.PickJanus()
+	janusID, err := rs.resMgr.PickJanus(ctx, roomID)
 	if err != nil || janusID == "" {
+		rs.resMgr.Release(ctx, roomID, "", mixerID)
 		return fmt.Errorf("no available Janus server")
 	}
 
+	if err := rs.createLiveMeta(ctx, roomID, mixerID, janusID); err != nil {
+		rs.resMgr.Release(ctx, roomID, janusID, mixerID)
+		return err
+	}
+	return nil
+}
+
+func (rs *roomSvcImpl) createLiveMeta(ctx context.Context, roomID, mixerID, janusID string) error {
 	exists, err := rs.roomStore.Exists(ctx, roomID)
 	if err != nil {
 		return fmt.Errorf("failed to check room existence: %w", err)
@@ -84,7 +143,66 @@ func (rs *roomSvcImpl) StartLive(ctx context.Context, roomID string) error {
 		return fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
-	return rs.roomStore.CreateLiveMeta(ctx, roomID, mixerID, janusID, nonce)
+	err = rs.roomStore.CreateLiveMeta(ctx, roomID, mixerID, janusID, nonce)
+	countQuotaExceeded(ctx, err)
+	if err == nil {
+		rs.timeline.Record(roomID, timeline.EventLiveStarted, fmt.Sprintf("mixer %s, janus %s", mixerID, janusID))
+	}
+	return err
+}
+
+// countQuotaExceeded counts err when a quota of a tenant rejected it
+func countQuotaExceeded(ctx context.Context, err error) {
+	var quotaErr *rooms.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		quotaExceeded.Add(ctx, 1, metric.WithAttributes(attribute.String("resource", quotaErr.Resource)))
+	}
+}
+
+// LinkRoom forwards the mix of the source room into the target room, Janus of the source room
+// and mixer of the target room pick the link up from etcd while both rooms are on air.
+// A non-empty anchorID forwards that anchor of the source room only.
+func (rs *roomSvcImpl) LinkRoom(ctx context.Context, sourceRoomID, targetRoomID, anchorID string) (*rooms.LinkResponse, error) {
+	for _, roomID := range []string{sourceRoomID, targetRoomID} {
+		exists, err := rs.roomStore.Exists(ctx, roomID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check room existence: %w", err)
+		}
+		if !exists {
+			return nil, &rooms.RoomNotFoundError{RoomID: roomID}
+		}
+	}
+
+	link := &etcdstate.Link{SourceRoomID: sourceRoomID, AnchorID: anchorID}
+	created, err := rs.roomStore.CreateLink(ctx, targetRoomID, link)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create link: %w", err)
+	}
+	if !created {
+		return nil, &rooms.RoomLinkExistsError{RoomID: targetRoomID}
+	}
+
+	return &rooms.LinkResponse{
+		SourceRoomID: sourceRoomID,
+		TargetRoomID: targetRoomID,
+		AnchorID:     anchorID,
+		CreatedAt:    link.CreatedAt,
+	}, nil
+}
+
+func (rs *roomSvcImpl) UnlinkRoom(ctx context.Context, sourceRoomID, targetRoomID string) error {
+	link, err := rs.roomStore.GetLink(ctx, targetRoomID)
+	if err != nil {
+		return fmt.Errorf("failed to get link: %w", err)
+	}
+	if link.GetSourceRoomID() != sourceRoomID {
+		return &rooms.RoomLinkNotFoundError{SourceRoomID: sourceRoomID, TargetRoomID: targetRoomID}
+	}
+
+	if err := rs.roomStore.DeleteLink(ctx, targetRoomID); err != nil {
+		return fmt.Errorf("failed to delete link: %w", err)
+	}
+	return nil
 }
 
 func (rs *roomSvcImpl) GetRoom(ctx context.Context, roomID string) (*rooms.RoomResponse, error) {
@@ -102,21 +220,170 @@ func (rs *roomSvcImpl) GetRoom(ctx context.Context, roomID string) (*rooms.RoomR
 		rs.logger.Warn("Failed to get mixer data", log.String("roomId", roomID), log.Error(err))
 	}
 
-	response := &rooms.RoomResponse{
-		RoomID:    roomID,
-		HLSURL:    rs.hlsAdvURL + room.HLSPath,
-		CreatedAt: room.CreatedAt,
-	}
+	response := rs.roomResponse(roomID, room)
 
 	if mixerData != nil && mixerData.Port > 0 {
 		response.RTPPort = &mixerData.Port
 	}
 
+	latency, err := rs.roomStore.GetLatency(ctx, roomID)
+	if err != nil {
+		rs.logger.Warn("Failed to get latency data", log.String("roomId", roomID), log.Error(err))
+	}
+	response.Latency = latency
+
 	return response, nil
 }
 
-func (rs *roomSvcImpl) ListRooms(ctx context.Context) (*rooms.ListRoomsResponse, error) {
-	rms, err := rs.roomStore.GetAllRooms(ctx)
+// GetRoomByExternalID gets the room indexed by an external ID
+func (rs *roomSvcImpl) GetRoomByExternalID(ctx context.Context, externalID string) (*rooms.RoomResponse, error) {
+	roomID, err := rs.roomStore.ResolveExternalID(ctx, externalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve external ID: %w", err)
+	}
+	if roomID == "" {
+		return nil, &rooms.RoomNotFoundError{RoomID: externalID}
+	}
+	return rs.GetRoom(ctx, roomID)
+}
+
+// UpdateRoom changes the mutable fields of a room, watchers pick the new meta up: the users
+// service applies maxAnchors and joinQueue to the next joins, the gateways publishSlots to the
+// next grants and offers, the other fields apply from the next live
+func (rs *roomSvcImpl) UpdateRoom(ctx context.Context, roomID string, patch *rooms.RoomPatch) (*rooms.RoomResponse, error) {
+	room, err := rs.roomStore.UpdateRoom(ctx, roomID, func(meta *etcdstate.Meta) error {
+		if patch.MaxAnchors != nil {
+			meta.MaxAnchors = *patch.MaxAnchors
+		}
+		if patch.Recording != nil {
+			meta.Recording = *patch.Recording
+		}
+		if patch.MixProfile != nil {
+			meta.MixProfile = *patch.MixProfile
+		}
+		if patch.ScheduledAt != nil {
+			meta.ScheduledAt = patch.ScheduledAt
+		}
+		if patch.Locale != nil {
+			meta.Locale = *patch.Locale
+		}
+		if patch.Tags != nil {
+			meta.Tags = nil
+			if len(patch.Tags) > 0 {
+				meta.Tags = patch.Tags
+			}
+		}
+		if patch.PublishSlots != nil {
+			meta.PublishSlots = *patch.PublishSlots
+		}
+		if patch.JoinQueue != nil {
+			meta.JoinQueue = *patch.JoinQueue
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update room: %w", err)
+	}
+	if room == nil {
+		return nil, &rooms.RoomNotFoundError{RoomID: roomID}
+	}
+
+	return rs.roomResponse(roomID, room), nil
+}
+
+// EndRoom marks the room ending, the resource manager then walks it through the end stages:
+// anchors are told, users stopped, the Janus forwarder and the live stopped, and once the
+// mixer wrote the final playlist the room is left to housekeeping. Ending an ending room
+// returns its progress
+func (rs *roomSvcImpl) EndRoom(ctx context.Context, roomID string) (*rooms.RoomResponse, error) {
+	room, err := rs.roomStore.UpdateRoom(ctx, roomID, func(meta *etcdstate.Meta) error {
+		if meta.Ending != nil {
+			return errAlreadyEnding
+		}
+		now := time.Now().UTC()
+		meta.Ending = &etcdstate.Ending{
+			Stage:     constants.EndStageNotifying,
+			StartedAt: now,
+			UpdatedAt: now,
+		}
+		return nil
+	})
+	if errors.Is(err, errAlreadyEnding) {
+		return rs.GetRoom(ctx, roomID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to end room: %w", err)
+	}
+	if room == nil {
+		return nil, &rooms.RoomNotFoundError{RoomID: roomID}
+	}
+
+	rs.logger.Info("Ending room", log.String("roomId", roomID))
+	rs.timeline.Record(roomID, timeline.EventEnding, "")
+	roomsEnded.Add(ctx, 1)
+	return rs.roomResponse(roomID, room), nil
+}
+
+// FreezeRoom freezes or unfreezes the room. The gateways reject new joins of a frozen room and
+// mute its anchors in Janus, Janus keeps forwarding so HLS goes on with silence
+func (rs *roomSvcImpl) FreezeRoom(ctx context.Context, roomID string, frozen bool) (*rooms.RoomResponse, error) {
+	room, err := rs.roomStore.UpdateRoom(ctx, roomID, func(meta *etcdstate.Meta) error {
+		if meta.IsFrozen() == frozen {
+			return errFreezeUnchanged
+		}
+		meta.FrozenAt = nil
+		if frozen {
+			now := time.Now().UTC()
+			meta.FrozenAt = &now
+		}
+		return nil
+	})
+	if errors.Is(err, errFreezeUnchanged) {
+		return rs.GetRoom(ctx, roomID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to freeze room: %w", err)
+	}
+	if room == nil {
+		return nil, &rooms.RoomNotFoundError{RoomID: roomID}
+	}
+
+	event := timeline.EventUnfrozen
+	if frozen {
+		event = timeline.EventFrozen
+		roomsFrozen.Add(ctx, 1)
+	}
+	rs.logger.Info("Room freeze changed", log.String("roomId", roomID), log.Bool("frozen", frozen))
+	rs.timeline.Record(roomID, event, "")
+	return rs.roomResponse(roomID, room), nil
+}
+
+// roomResponse describes the stored meta of a room, without its live state
+func (rs *roomSvcImpl) roomResponse(roomID string, room *etcdstate.Meta) *rooms.RoomResponse {
+	return &rooms.RoomResponse{
+		RoomID:       roomID,
+		ExternalID:   room.ExternalID,
+		HLSURL:       rs.hlsURL(roomID, room),
+		MaxAnchors:   room.MaxAnchors,
+		MaxBitrate:   room.MaxBitrate,
+		DVRWindow:    room.DVRWindow,
+		MaxDuration:  room.MaxDuration,
+		Recording:    room.Recording,
+		MixProfile:   room.MixProfile,
+		ScheduledAt:  room.ScheduledAt,
+		Locale:       room.Locale,
+		Tags:         room.Tags,
+		Audio:        room.Audio,
+		EndStage:     room.GetEndStage(),
+		FrozenAt:     room.FrozenAt,
+		PublishSlots: room.PublishSlots,
+		JoinQueue:    room.JoinQueue,
+		CreatedAt:    room.CreatedAt,
+	}
+}
+
+func (rs *roomSvcImpl) ListRooms(ctx context.Context, tags map[string]string) (*rooms.ListRoomsResponse, error) {
+	rms, err := rs.listRooms(ctx, tags)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list rooms: %w", err)
 	}
@@ -129,7 +396,8 @@ func (rs *roomSvcImpl) ListRooms(ctx context.Context) (*rooms.ListRoomsResponse,
 	for roomID, room := range rms {
 		response.Rooms = append(response.Rooms, &rooms.RoomResponse{
 			RoomID:    roomID,
-			HLSURL:    rs.hlsAdvURL + room.HLSPath,
+			HLSURL:    rs.hlsURL(roomID, room),
+			Tags:      room.Tags,
 			CreatedAt: room.CreatedAt,
 		})
 	}
@@ -137,6 +405,43 @@ func (rs *roomSvcImpl) ListRooms(ctx context.Context) (*rooms.ListRoomsResponse,
 	return response, nil
 }
 
+// listRooms reads all rooms, or with tags the rooms indexed with one of them then keeps those
+// having all, the index may lag the metas
+func (rs *roomSvcImpl) listRooms(ctx context.Context, tags map[string]string) (map[string]*etcdstate.Meta, error) {
+	if len(tags) == 0 {
+		return rs.roomStore.GetAllRooms(ctx)
+	}
+
+	key := slices.Min(slices.Collect(maps.Keys(tags)))
+	roomIDs, err := rs.roomStore.ListRoomsByTag(ctx, key, tags[key])
+	if err != nil {
+		return nil, err
+	}
+
+	rms := make(map[string]*etcdstate.Meta, len(roomIDs))
+	for _, roomID := range roomIDs {
+		room, err := rs.roomStore.GetRoom(ctx, roomID)
+		if err != nil {
+			return nil, err
+		}
+		if room == nil || !hasTags(room, tags) {
+			continue
+		}
+		rms[roomID] = room
+	}
+	return rms, nil
+}
+
+// hasTags reports whether room is tagged with all of tags
+func hasTags(room *etcdstate.Meta, tags map[string]string) bool {
+	for key, value := range tags {
+		if v, ok := room.Tags[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
 func (rs *roomSvcImpl) DeleteRoom(ctx context.Context, roomID string) (*rooms.DeleteRoomResponse, error) {
 	room, err := rs.roomStore.GetRoom(ctx, roomID)
 	if err != nil {
@@ -150,12 +455,34 @@ func (rs *roomSvcImpl) DeleteRoom(ctx context.Context, roomID string) (*rooms.De
 	if err := rs.roomStore.StopRoom(ctx, roomID); err != nil {
 		return nil, fmt.Errorf("failed to stop room: %w", err)
 	}
+	rs.timeline.Record(roomID, timeline.EventEnded, "deleted")
 
 	return &rooms.DeleteRoomResponse{
 		Message: fmt.Sprintf("Room %s stopped", roomID),
 	}, nil
 }
 
+// GetTimeline returns the significant transitions of the room recorded by the services
+func (rs *roomSvcImpl) GetTimeline(ctx context.Context, roomID string) ([]etcdstate.TimelineEvent, error) {
+	exists, err := rs.roomStore.Exists(ctx, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check room existence: %w", err)
+	}
+	if !exists {
+		return nil, &rooms.RoomNotFoundError{RoomID: roomID}
+	}
+
+	tl, err := rs.roomStore.GetTimeline(ctx, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timeline: %w", err)
+	}
+	events := tl.GetEvents()
+	if events == nil {
+		events = []etcdstate.TimelineEvent{}
+	}
+	return events, nil
+}
+
 func (rs *roomSvcImpl) GetStats(ctx context.Context) (*rooms.StatsResponse, error) {
 	roomStats, err := rs.roomStore.GetStats(ctx)
 	if err != nil {
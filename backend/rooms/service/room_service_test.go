@@ -3,11 +3,14 @@ package service
 import (
 	"context"
 	"errors"
+	"net/url"
 	"testing"
 	"time"
 
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/urlsign"
 	"github.com/imtaco/audio-rtc-exp/rooms"
 	"github.com/imtaco/audio-rtc-exp/rooms/mocks"
 
@@ -37,7 +40,10 @@ func (s *RoomServiceTestSuite) SetupTest() {
 	s.svc = NewRoomService(
 		s.mockStore,
 		s.mockResMgr,
+		nil,
 		"https://example.com/hls/",
+		nil,
+		nil,
 		log.NewNop(),
 	).(*roomSvcImpl)
 }
@@ -63,20 +69,27 @@ func (s *RoomServiceTestSuite) TestCreateRoom() {
 				s.Equal(pin, data.Pin)
 				s.Equal("room1/stream.m3u8", data.HLSPath)
 				s.Equal(maxAnchors, data.MaxAnchors)
+				s.Equal(64000, data.MaxBitrate)
 				return &etcdstate.Meta{
 					Pin:        pin,
 					HLSPath:    "room1/stream.m3u8",
 					MaxAnchors: maxAnchors,
+					MaxBitrate: 64000,
 					CreatedAt:  now,
 				}, nil
 			})
 
-		resp, err := s.svc.CreateRoom(s.ctx, roomID, pin, maxAnchors)
+		resp, err := s.svc.CreateRoom(s.ctx, roomID, &rooms.CreateRoomParams{
+			Pin:        pin,
+			MaxAnchors: maxAnchors,
+			MaxBitrate: 64000,
+		})
 
 		s.Require().NoError(err)
 		s.Equal(roomID, resp.RoomID)
 		s.Equal(pin, resp.Pin)
 		s.Equal("https://example.com/hls/room1/stream.m3u8", resp.HLSURL)
+		s.Equal(64000, resp.MaxBitrate)
 		s.Equal(now, resp.CreatedAt)
 	})
 
@@ -89,7 +102,11 @@ func (s *RoomServiceTestSuite) TestCreateRoom() {
 			Exists(gomock.Any(), roomID).
 			Return(true, nil)
 
-		resp, err := s.svc.CreateRoom(s.ctx, roomID, pin, maxAnchors)
+		resp, err := s.svc.CreateRoom(s.ctx, roomID, &rooms.CreateRoomParams{
+			Pin:        pin,
+			MaxAnchors: maxAnchors,
+			MaxBitrate: 64000,
+		})
 
 		s.Require().Error(err)
 		s.Nil(resp)
@@ -107,7 +124,11 @@ func (s *RoomServiceTestSuite) TestCreateRoom() {
 			Exists(gomock.Any(), roomID).
 			Return(false, errors.New("database error"))
 
-		resp, err := s.svc.CreateRoom(s.ctx, roomID, pin, maxAnchors)
+		resp, err := s.svc.CreateRoom(s.ctx, roomID, &rooms.CreateRoomParams{
+			Pin:        pin,
+			MaxAnchors: maxAnchors,
+			MaxBitrate: 64000,
+		})
 
 		s.Require().Error(err)
 		s.Nil(resp)
@@ -127,7 +148,11 @@ func (s *RoomServiceTestSuite) TestCreateRoom() {
 			CreateRoom(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(nil, errors.New("storage error"))
 
-		resp, err := s.svc.CreateRoom(s.ctx, roomID, pin, maxAnchors)
+		resp, err := s.svc.CreateRoom(s.ctx, roomID, &rooms.CreateRoomParams{
+			Pin:        pin,
+			MaxAnchors: maxAnchors,
+			MaxBitrate: 64000,
+		})
 
 		s.Require().Error(err)
 		s.Nil(resp)
@@ -142,11 +167,11 @@ func (s *RoomServiceTestSuite) TestStartLive() {
 		janusID := "janus1"
 
 		s.mockResMgr.EXPECT().
-			PickMixer().
+			PickMixer(gomock.Any(), "room1").
 			Return(mixerID, nil)
 
 		s.mockResMgr.EXPECT().
-			PickJanus().
+			PickJanus(gomock.Any(), "room1").
 			Return(janusID, nil)
 
 		s.mockStore.EXPECT().
@@ -168,7 +193,7 @@ func (s *RoomServiceTestSuite) TestStartLive() {
 
 	s.Run("no available mixer", func() {
 		s.mockResMgr.EXPECT().
-			PickMixer().
+			PickMixer(gomock.Any(), "room1").
 			Return("", errors.New("no mixer available"))
 
 		err := s.svc.StartLive(s.ctx, "room1")
@@ -179,7 +204,7 @@ func (s *RoomServiceTestSuite) TestStartLive() {
 
 	s.Run("mixer returns empty string", func() {
 		s.mockResMgr.EXPECT().
-			PickMixer().
+			PickMixer(gomock.Any(), "room1").
 			Return("", nil)
 
 		err := s.svc.StartLive(s.ctx, "room1")
@@ -190,13 +215,15 @@ func (s *RoomServiceTestSuite) TestStartLive() {
 
 	s.Run("no available janus", func() {
 		s.mockResMgr.EXPECT().
-			PickMixer().
+			PickMixer(gomock.Any(), "room1").
 			Return("mixer1", nil)
 
 		s.mockResMgr.EXPECT().
-			PickJanus().
+			PickJanus(gomock.Any(), "room1").
 			Return("", errors.New("no janus available"))
 
+		s.mockResMgr.EXPECT().Release(gomock.Any(), "room1", "", "mixer1")
+
 		err := s.svc.StartLive(s.ctx, "room1")
 
 		s.Require().Error(err)
@@ -205,13 +232,15 @@ func (s *RoomServiceTestSuite) TestStartLive() {
 
 	s.Run("janus returns empty string", func() {
 		s.mockResMgr.EXPECT().
-			PickMixer().
+			PickMixer(gomock.Any(), "room1").
 			Return("mixer1", nil)
 
 		s.mockResMgr.EXPECT().
-			PickJanus().
+			PickJanus(gomock.Any(), "room1").
 			Return("", nil)
 
+		s.mockResMgr.EXPECT().Release(gomock.Any(), "room1", "", "mixer1")
+
 		err := s.svc.StartLive(s.ctx, "room1")
 
 		s.Require().Error(err)
@@ -222,17 +251,19 @@ func (s *RoomServiceTestSuite) TestStartLive() {
 		roomID := "nonexistent"
 
 		s.mockResMgr.EXPECT().
-			PickMixer().
+			PickMixer(gomock.Any(), roomID).
 			Return("mixer1", nil)
 
 		s.mockResMgr.EXPECT().
-			PickJanus().
+			PickJanus(gomock.Any(), roomID).
 			Return("janus1", nil)
 
 		s.mockStore.EXPECT().
 			Exists(gomock.Any(), roomID).
 			Return(false, nil)
 
+		s.mockResMgr.EXPECT().Release(gomock.Any(), roomID, "janus1", "mixer1")
+
 		err := s.svc.StartLive(s.ctx, roomID)
 
 		s.Require().Error(err)
@@ -243,17 +274,19 @@ func (s *RoomServiceTestSuite) TestStartLive() {
 
 	s.Run("exists check fails", func() {
 		s.mockResMgr.EXPECT().
-			PickMixer().
+			PickMixer(gomock.Any(), "room1").
 			Return("mixer1", nil)
 
 		s.mockResMgr.EXPECT().
-			PickJanus().
+			PickJanus(gomock.Any(), "room1").
 			Return("janus1", nil)
 
 		s.mockStore.EXPECT().
 			Exists(gomock.Any(), "room1").
 			Return(false, errors.New("database error"))
 
+		s.mockResMgr.EXPECT().Release(gomock.Any(), "room1", "janus1", "mixer1")
+
 		err := s.svc.StartLive(s.ctx, "room1")
 
 		s.Require().Error(err)
@@ -264,11 +297,11 @@ func (s *RoomServiceTestSuite) TestStartLive() {
 		roomID := "room1"
 
 		s.mockResMgr.EXPECT().
-			PickMixer().
+			PickMixer(gomock.Any(), "room1").
 			Return("mixer1", nil)
 
 		s.mockResMgr.EXPECT().
-			PickJanus().
+			PickJanus(gomock.Any(), "room1").
 			Return("janus1", nil)
 
 		s.mockStore.EXPECT().
@@ -279,6 +312,8 @@ func (s *RoomServiceTestSuite) TestStartLive() {
 			CreateLiveMeta(gomock.Any(), roomID, "mixer1", "janus1", gomock.Any()).
 			Return(errors.New("meta creation failed"))
 
+		s.mockResMgr.EXPECT().Release(gomock.Any(), roomID, "janus1", "mixer1")
+
 		err := s.svc.StartLive(s.ctx, roomID)
 
 		s.Require().Error(err)
@@ -304,6 +339,10 @@ func (s *RoomServiceTestSuite) TestGetRoom() {
 			GetMixerData(gomock.Any(), roomID).
 			Return(nil, errors.New("no mixer data"))
 
+		s.mockStore.EXPECT().
+			GetLatency(gomock.Any(), roomID).
+			Return(nil, nil)
+
 		resp, err := s.svc.GetRoom(s.ctx, roomID)
 
 		s.Require().NoError(err)
@@ -334,6 +373,10 @@ func (s *RoomServiceTestSuite) TestGetRoom() {
 			GetMixerData(gomock.Any(), roomID).
 			Return(mixerData, nil)
 
+		s.mockStore.EXPECT().
+			GetLatency(gomock.Any(), roomID).
+			Return(nil, nil)
+
 		resp, err := s.svc.GetRoom(s.ctx, roomID)
 
 		s.Require().NoError(err)
@@ -361,12 +404,38 @@ func (s *RoomServiceTestSuite) TestGetRoom() {
 			GetMixerData(gomock.Any(), roomID).
 			Return(mixerData, nil)
 
+		s.mockStore.EXPECT().
+			GetLatency(gomock.Any(), roomID).
+			Return(nil, nil)
+
 		resp, err := s.svc.GetRoom(s.ctx, roomID)
 
 		s.Require().NoError(err)
 		s.Nil(resp.RTPPort)
 	})
 
+	s.Run("get room with latency reported by mixer", func() {
+		roomID := "room1"
+		latency := &etcdstate.Latency{CurrentMs: 4200, AvgMs: 4100}
+
+		s.mockStore.EXPECT().
+			GetRoom(gomock.Any(), roomID).
+			Return(&etcdstate.Meta{HLSPath: "room1/stream.m3u8"}, nil)
+
+		s.mockStore.EXPECT().
+			GetMixerData(gomock.Any(), roomID).
+			Return(&etcdstate.Mixer{Port: 5004}, nil)
+
+		s.mockStore.EXPECT().
+			GetLatency(gomock.Any(), roomID).
+			Return(latency, nil)
+
+		resp, err := s.svc.GetRoom(s.ctx, roomID)
+
+		s.Require().NoError(err)
+		s.Equal(latency, resp.Latency)
+	})
+
 	s.Run("room not found - nil returned", func() {
 		roomID := "nonexistent"
 
@@ -416,7 +485,7 @@ func (s *RoomServiceTestSuite) TestListRooms() {
 			GetAllRooms(gomock.Any()).
 			Return(roomsData, nil)
 
-		resp, err := s.svc.ListRooms(s.ctx)
+		resp, err := s.svc.ListRooms(s.ctx, nil)
 
 		s.Require().NoError(err)
 		s.Equal(2, resp.Count)
@@ -439,7 +508,7 @@ func (s *RoomServiceTestSuite) TestListRooms() {
 			GetAllRooms(gomock.Any()).
 			Return(map[string]*etcdstate.Meta{}, nil)
 
-		resp, err := s.svc.ListRooms(s.ctx)
+		resp, err := s.svc.ListRooms(s.ctx, nil)
 
 		s.Require().NoError(err)
 		s.Equal(0, resp.Count)
@@ -451,12 +520,31 @@ func (s *RoomServiceTestSuite) TestListRooms() {
 			GetAllRooms(gomock.Any()).
 			Return(nil, errors.New("database error"))
 
-		resp, err := s.svc.ListRooms(s.ctx)
+		resp, err := s.svc.ListRooms(s.ctx, nil)
 
 		s.Require().Error(err)
 		s.Nil(resp)
 		s.Contains(err.Error(), "failed to list rooms")
 	})
+
+	s.Run("list rooms by tags", func() {
+		s.mockStore.EXPECT().
+			ListRoomsByTag(gomock.Any(), "env", "prod").
+			Return([]string{"room1", "room2", "room3"}, nil)
+		s.mockStore.EXPECT().GetRoom(gomock.Any(), "room1").
+			Return(&etcdstate.Meta{Tags: map[string]string{"env": "prod", "show": "morning"}}, nil)
+		// untagged meanwhile, the index lags the meta
+		s.mockStore.EXPECT().GetRoom(gomock.Any(), "room2").
+			Return(&etcdstate.Meta{Tags: map[string]string{"env": "prod", "show": "evening"}}, nil)
+		s.mockStore.EXPECT().GetRoom(gomock.Any(), "room3").Return(nil, nil)
+
+		resp, err := s.svc.ListRooms(s.ctx, map[string]string{"show": "morning", "env": "prod"})
+
+		s.Require().NoError(err)
+		s.Equal(1, resp.Count)
+		s.Equal("room1", resp.Rooms[0].RoomID)
+		s.Equal("morning", resp.Rooms[0].Tags["show"])
+	})
 }
 
 func (s *RoomServiceTestSuite) TestDeleteRoom() {
@@ -536,6 +624,39 @@ func (s *RoomServiceTestSuite) TestDeleteRoom() {
 	})
 }
 
+func (s *RoomServiceTestSuite) TestGetTimeline() {
+	s.Run("returns recorded events", func() {
+		events := []etcdstate.TimelineEvent{{Type: "created", Source: "rooms"}, {Type: "liveStarted", Source: "rooms"}}
+		s.mockStore.EXPECT().Exists(gomock.Any(), "room1").Return(true, nil)
+		s.mockStore.EXPECT().GetTimeline(gomock.Any(), "room1").Return(&etcdstate.Timeline{Events: events}, nil)
+
+		resp, err := s.svc.GetTimeline(s.ctx, "room1")
+
+		s.Require().NoError(err)
+		s.Equal(events, resp)
+	})
+
+	s.Run("empty when nothing was recorded", func() {
+		s.mockStore.EXPECT().Exists(gomock.Any(), "room1").Return(true, nil)
+		s.mockStore.EXPECT().GetTimeline(gomock.Any(), "room1").Return(nil, nil)
+
+		resp, err := s.svc.GetTimeline(s.ctx, "room1")
+
+		s.Require().NoError(err)
+		s.NotNil(resp)
+		s.Empty(resp)
+	})
+
+	s.Run("room not found", func() {
+		s.mockStore.EXPECT().Exists(gomock.Any(), "room1").Return(false, nil)
+
+		_, err := s.svc.GetTimeline(s.ctx, "room1")
+
+		var notFound *rooms.RoomNotFoundError
+		s.ErrorAs(err, &notFound)
+	})
+}
+
 func (s *RoomServiceTestSuite) TestGetStats() {
 	s.Run("get stats successfully", func() {
 		stats := &rooms.RoomStats{
@@ -584,12 +705,424 @@ func (s *RoomServiceTestSuite) TestGetStats() {
 	})
 }
 
+func (s *RoomServiceTestSuite) TestLinkRoom() {
+	s.Run("link room successfully", func() {
+		s.mockStore.EXPECT().Exists(gomock.Any(), "room1").Return(true, nil)
+		s.mockStore.EXPECT().Exists(gomock.Any(), "room2").Return(true, nil)
+		s.mockStore.EXPECT().
+			CreateLink(gomock.Any(), "room2", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, link *etcdstate.Link) (bool, error) {
+				s.Equal("room1", link.SourceRoomID)
+				link.CreatedAt = time.Now().UTC()
+				return true, nil
+			})
+
+		resp, err := s.svc.LinkRoom(s.ctx, "room1", "room2", "")
+
+		s.Require().NoError(err)
+		s.Equal("room1", resp.SourceRoomID)
+		s.Equal("room2", resp.TargetRoomID)
+		s.False(resp.CreatedAt.IsZero())
+	})
+
+	s.Run("target room not found", func() {
+		s.mockStore.EXPECT().Exists(gomock.Any(), "room1").Return(true, nil)
+		s.mockStore.EXPECT().Exists(gomock.Any(), "room2").Return(false, nil)
+
+		resp, err := s.svc.LinkRoom(s.ctx, "room1", "room2", "")
+
+		s.Require().Error(err)
+		s.Nil(resp)
+		var notFoundErr *rooms.RoomNotFoundError
+		s.Require().ErrorAs(err, &notFoundErr)
+		s.Equal("room2", notFoundErr.RoomID)
+	})
+
+	s.Run("target room already linked", func() {
+		s.mockStore.EXPECT().Exists(gomock.Any(), "room1").Return(true, nil)
+		s.mockStore.EXPECT().Exists(gomock.Any(), "room2").Return(true, nil)
+		s.mockStore.EXPECT().CreateLink(gomock.Any(), "room2", gomock.Any()).Return(false, nil)
+
+		resp, err := s.svc.LinkRoom(s.ctx, "room1", "room2", "")
+
+		s.Require().Error(err)
+		s.Nil(resp)
+		var existsErr *rooms.RoomLinkExistsError
+		s.ErrorAs(err, &existsErr)
+	})
+}
+
+func (s *RoomServiceTestSuite) TestUnlinkRoom() {
+	s.Run("unlink room successfully", func() {
+		s.mockStore.EXPECT().GetLink(gomock.Any(), "room2").Return(&etcdstate.Link{SourceRoomID: "room1"}, nil)
+		s.mockStore.EXPECT().DeleteLink(gomock.Any(), "room2").Return(nil)
+
+		err := s.svc.UnlinkRoom(s.ctx, "room1", "room2")
+
+		s.Require().NoError(err)
+	})
+
+	s.Run("linked from another room", func() {
+		s.mockStore.EXPECT().GetLink(gomock.Any(), "room2").Return(&etcdstate.Link{SourceRoomID: "room3"}, nil)
+
+		err := s.svc.UnlinkRoom(s.ctx, "room1", "room2")
+
+		var notFoundErr *rooms.RoomLinkNotFoundError
+		s.ErrorAs(err, &notFoundErr)
+	})
+
+	s.Run("not linked", func() {
+		s.mockStore.EXPECT().GetLink(gomock.Any(), "room2").Return(nil, nil)
+
+		err := s.svc.UnlinkRoom(s.ctx, "room1", "room2")
+
+		var notFoundErr *rooms.RoomLinkNotFoundError
+		s.ErrorAs(err, &notFoundErr)
+	})
+}
+
+func (s *RoomServiceTestSuite) TestCreateRoom_ExternalID() {
+	s.mockStore.EXPECT().Exists(gomock.Any(), "room1").Return(false, nil)
+	s.mockStore.EXPECT().
+		CreateRoom(gomock.Any(), "room1", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, data *etcdstate.Meta) (*etcdstate.Meta, error) {
+			return data, nil
+		})
+
+	resp, err := s.svc.CreateRoom(s.ctx, "room1", &rooms.CreateRoomParams{
+		Pin:        "1234",
+		ExternalID: "cms-42",
+		MaxAnchors: 3,
+	})
+
+	s.Require().NoError(err)
+	s.Equal("cms-42", resp.ExternalID)
+}
+
+func (s *RoomServiceTestSuite) TestCreateRoom_Audio() {
+	on := true
+	audio := &etcdstate.AudioParams{FEC: &on, MaxAverageBitrate: 24000}
+	s.mockStore.EXPECT().Exists(gomock.Any(), "room1").Return(false, nil)
+	s.mockStore.EXPECT().
+		CreateRoom(gomock.Any(), "room1", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, data *etcdstate.Meta) (*etcdstate.Meta, error) {
+			s.Equal(audio, data.Audio)
+			return data, nil
+		})
+
+	resp, err := s.svc.CreateRoom(s.ctx, "room1", &rooms.CreateRoomParams{
+		Pin:        "1234",
+		MaxAnchors: 3,
+		Audio:      audio,
+	})
+
+	s.Require().NoError(err)
+	s.Equal(audio, resp.Audio)
+}
+
+func (s *RoomServiceTestSuite) TestCreateRoom_Tenant() {
+	s.mockStore.EXPECT().Exists(gomock.Any(), "room1").Return(false, nil)
+	s.mockStore.EXPECT().
+		CreateRoom(gomock.Any(), "room1", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, data *etcdstate.Meta) (*etcdstate.Meta, error) {
+			s.Equal("acme", data.Tenant)
+			return nil, &rooms.QuotaExceededError{Tenant: "acme", Resource: rooms.QuotaRooms, Limit: 1}
+		})
+
+	_, err := s.svc.CreateRoom(s.ctx, "room1", &rooms.CreateRoomParams{
+		Pin:        "1234",
+		Tenant:     "acme",
+		MaxAnchors: 3,
+	})
+
+	var quotaErr *rooms.QuotaExceededError
+	s.ErrorAs(err, &quotaErr)
+}
+
+func (s *RoomServiceTestSuite) TestGetRoomByExternalID() {
+	s.Run("indexed room", func() {
+		s.mockStore.EXPECT().ResolveExternalID(gomock.Any(), "cms-42").Return("room1", nil)
+		s.mockStore.EXPECT().GetRoom(gomock.Any(), "room1").Return(&etcdstate.Meta{ExternalID: "cms-42"}, nil)
+		s.mockStore.EXPECT().GetMixerData(gomock.Any(), "room1").Return(nil, nil)
+		s.mockStore.EXPECT().GetLatency(gomock.Any(), "room1").Return(nil, nil)
+
+		resp, err := s.svc.GetRoomByExternalID(s.ctx, "cms-42")
+
+		s.Require().NoError(err)
+		s.Equal("room1", resp.RoomID)
+		s.Equal("cms-42", resp.ExternalID)
+	})
+
+	s.Run("unknown external ID", func() {
+		s.mockStore.EXPECT().ResolveExternalID(gomock.Any(), "cms-43").Return("", nil)
+
+		resp, err := s.svc.GetRoomByExternalID(s.ctx, "cms-43")
+
+		s.Nil(resp)
+		var notFoundErr *rooms.RoomNotFoundError
+		s.ErrorAs(err, &notFoundErr)
+	})
+}
+
+func (s *RoomServiceTestSuite) TestUpdateRoom() {
+	s.Run("applies set fields only", func() {
+		meta := &etcdstate.Meta{HLSPath: "room1/stream.m3u8", MaxAnchors: 3, MixProfile: "talk"}
+		s.mockStore.EXPECT().
+			UpdateRoom(gomock.Any(), "room1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, update func(*etcdstate.Meta) error) (*etcdstate.Meta, error) {
+				s.Require().NoError(update(meta))
+				return meta, nil
+			})
+
+		maxAnchors, recording := 5, true
+		resp, err := s.svc.UpdateRoom(s.ctx, "room1", &rooms.RoomPatch{MaxAnchors: &maxAnchors, Recording: &recording})
+
+		s.Require().NoError(err)
+		s.Equal(5, resp.MaxAnchors)
+		s.True(resp.Recording)
+		s.Equal("talk", resp.MixProfile)
+		s.Nil(resp.ScheduledAt)
+		s.Empty(resp.Locale)
+	})
+
+	s.Run("sets locale", func() {
+		meta := &etcdstate.Meta{HLSPath: "room1/stream.m3u8"}
+		s.mockStore.EXPECT().
+			UpdateRoom(gomock.Any(), "room1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, update func(*etcdstate.Meta) error) (*etcdstate.Meta, error) {
+				s.Require().NoError(update(meta))
+				return meta, nil
+			})
+
+		locale := "ja"
+		resp, err := s.svc.UpdateRoom(s.ctx, "room1", &rooms.RoomPatch{Locale: &locale})
+
+		s.Require().NoError(err)
+		s.Equal("ja", resp.Locale)
+		s.Equal("ja", meta.Locale)
+	})
+
+	s.Run("sets publish slots", func() {
+		meta := &etcdstate.Meta{HLSPath: "room1/stream.m3u8"}
+		s.mockStore.EXPECT().
+			UpdateRoom(gomock.Any(), "room1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, update func(*etcdstate.Meta) error) (*etcdstate.Meta, error) {
+				s.Require().NoError(update(meta))
+				return meta, nil
+			})
+
+		slots := 4
+		resp, err := s.svc.UpdateRoom(s.ctx, "room1", &rooms.RoomPatch{PublishSlots: &slots})
+
+		s.Require().NoError(err)
+		s.Equal(4, resp.PublishSlots)
+		s.Equal(4, meta.GetPublishSlots())
+	})
+
+	s.Run("sets join queue", func() {
+		meta := &etcdstate.Meta{HLSPath: "room1/stream.m3u8"}
+		s.mockStore.EXPECT().
+			UpdateRoom(gomock.Any(), "room1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, update func(*etcdstate.Meta) error) (*etcdstate.Meta, error) {
+				s.Require().NoError(update(meta))
+				return meta, nil
+			})
+
+		queue := 10
+		resp, err := s.svc.UpdateRoom(s.ctx, "room1", &rooms.RoomPatch{JoinQueue: &queue})
+
+		s.Require().NoError(err)
+		s.Equal(10, resp.JoinQueue)
+		s.Equal(10, meta.GetJoinQueue())
+	})
+
+	s.Run("replaces tags", func() {
+		meta := &etcdstate.Meta{HLSPath: "room1/stream.m3u8", Tags: map[string]string{"show": "morning"}}
+		s.mockStore.EXPECT().
+			UpdateRoom(gomock.Any(), "room1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, update func(*etcdstate.Meta) error) (*etcdstate.Meta, error) {
+				s.Require().NoError(update(meta))
+				return meta, nil
+			})
+
+		resp, err := s.svc.UpdateRoom(s.ctx, "room1", &rooms.RoomPatch{Tags: map[string]string{"env": "prod"}})
+
+		s.Require().NoError(err)
+		s.Equal(map[string]string{"env": "prod"}, resp.Tags)
+	})
+
+	s.Run("removes tags", func() {
+		meta := &etcdstate.Meta{HLSPath: "room1/stream.m3u8", Tags: map[string]string{"show": "morning"}}
+		s.mockStore.EXPECT().
+			UpdateRoom(gomock.Any(), "room1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, update func(*etcdstate.Meta) error) (*etcdstate.Meta, error) {
+				s.Require().NoError(update(meta))
+				return meta, nil
+			})
+
+		_, err := s.svc.UpdateRoom(s.ctx, "room1", &rooms.RoomPatch{Tags: map[string]string{}})
+
+		s.Require().NoError(err)
+		s.Nil(meta.Tags)
+	})
+
+	s.Run("room not found", func() {
+		s.mockStore.EXPECT().UpdateRoom(gomock.Any(), "room1", gomock.Any()).Return(nil, nil)
+
+		recording := true
+		resp, err := s.svc.UpdateRoom(s.ctx, "room1", &rooms.RoomPatch{Recording: &recording})
+
+		s.Nil(resp)
+		var notFoundErr *rooms.RoomNotFoundError
+		s.ErrorAs(err, &notFoundErr)
+	})
+
+	s.Run("concurrent update", func() {
+		s.mockStore.EXPECT().
+			UpdateRoom(gomock.Any(), "room1", gomock.Any()).
+			Return(nil, &rooms.RoomUpdateConflictError{RoomID: "room1"})
+
+		recording := true
+		_, err := s.svc.UpdateRoom(s.ctx, "room1", &rooms.RoomPatch{Recording: &recording})
+
+		var conflictErr *rooms.RoomUpdateConflictError
+		s.ErrorAs(err, &conflictErr)
+	})
+}
+
+func (s *RoomServiceTestSuite) TestEndRoom() {
+	s.Run("starts ending", func() {
+		meta := &etcdstate.Meta{HLSPath: "room1/stream.m3u8"}
+		s.mockStore.EXPECT().
+			UpdateRoom(gomock.Any(), "room1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, update func(*etcdstate.Meta) error) (*etcdstate.Meta, error) {
+				s.Require().NoError(update(meta))
+				return meta, nil
+			})
+
+		resp, err := s.svc.EndRoom(s.ctx, "room1")
+
+		s.Require().NoError(err)
+		s.Equal(constants.EndStageNotifying, resp.EndStage)
+		s.Require().NotNil(meta.Ending)
+		s.Equal(meta.Ending.StartedAt, meta.Ending.UpdatedAt)
+	})
+
+	s.Run("already ending returns progress", func() {
+		meta := &etcdstate.Meta{
+			HLSPath: "room1/stream.m3u8",
+			Ending:  &etcdstate.Ending{Stage: constants.EndStageStoppingLive},
+		}
+		s.mockStore.EXPECT().
+			UpdateRoom(gomock.Any(), "room1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, update func(*etcdstate.Meta) error) (*etcdstate.Meta, error) {
+				return nil, update(meta)
+			})
+		s.mockStore.EXPECT().GetRoom(gomock.Any(), "room1").Return(meta, nil)
+		s.mockStore.EXPECT().GetMixerData(gomock.Any(), "room1").Return(nil, nil)
+		s.mockStore.EXPECT().GetLatency(gomock.Any(), "room1").Return(nil, nil)
+
+		resp, err := s.svc.EndRoom(s.ctx, "room1")
+
+		s.Require().NoError(err)
+		s.Equal(constants.EndStageStoppingLive, resp.EndStage)
+	})
+
+	s.Run("room not found", func() {
+		s.mockStore.EXPECT().UpdateRoom(gomock.Any(), "room1", gomock.Any()).Return(nil, nil)
+
+		resp, err := s.svc.EndRoom(s.ctx, "room1")
+
+		s.Nil(resp)
+		var notFoundErr *rooms.RoomNotFoundError
+		s.ErrorAs(err, &notFoundErr)
+	})
+}
+
+func (s *RoomServiceTestSuite) TestFreezeRoom() {
+	s.Run("freezes and unfreezes", func() {
+		meta := &etcdstate.Meta{HLSPath: "room1/stream.m3u8"}
+		s.mockStore.EXPECT().
+			UpdateRoom(gomock.Any(), "room1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, update func(*etcdstate.Meta) error) (*etcdstate.Meta, error) {
+				s.Require().NoError(update(meta))
+				return meta, nil
+			}).
+			Times(2)
+
+		resp, err := s.svc.FreezeRoom(s.ctx, "room1", true)
+		s.Require().NoError(err)
+		s.True(meta.IsFrozen())
+		s.Equal(meta.FrozenAt, resp.FrozenAt)
+
+		resp, err = s.svc.FreezeRoom(s.ctx, "room1", false)
+		s.Require().NoError(err)
+		s.False(meta.IsFrozen())
+		s.Nil(resp.FrozenAt)
+	})
+
+	s.Run("already frozen returns the room", func() {
+		frozenAt := time.Now().UTC()
+		meta := &etcdstate.Meta{HLSPath: "room1/stream.m3u8", FrozenAt: &frozenAt}
+		s.mockStore.EXPECT().
+			UpdateRoom(gomock.Any(), "room1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, update func(*etcdstate.Meta) error) (*etcdstate.Meta, error) {
+				return nil, update(meta)
+			})
+		s.mockStore.EXPECT().GetRoom(gomock.Any(), "room1").Return(meta, nil)
+		s.mockStore.EXPECT().GetMixerData(gomock.Any(), "room1").Return(nil, nil)
+		s.mockStore.EXPECT().GetLatency(gomock.Any(), "room1").Return(nil, nil)
+
+		resp, err := s.svc.FreezeRoom(s.ctx, "room1", true)
+
+		s.Require().NoError(err)
+		s.Equal(&frozenAt, resp.FrozenAt)
+	})
+
+	s.Run("room not found", func() {
+		s.mockStore.EXPECT().UpdateRoom(gomock.Any(), "room1", gomock.Any()).Return(nil, nil)
+
+		resp, err := s.svc.FreezeRoom(s.ctx, "room1", true)
+
+		s.Nil(resp)
+		var notFoundErr *rooms.RoomNotFoundError
+		s.ErrorAs(err, &notFoundErr)
+	})
+}
+
+func (s *RoomServiceTestSuite) TestGetRoom_SignedHLSURL() {
+	signer := urlsign.New("secret", time.Hour)
+	s.svc.hlsSigner = signer
+
+	s.mockStore.EXPECT().
+		GetRoom(gomock.Any(), "room1").
+		Return(&etcdstate.Meta{HLSPath: "room1/stream.m3u8"}, nil)
+	s.mockStore.EXPECT().
+		GetMixerData(gomock.Any(), "room1").
+		Return(nil, nil)
+	s.mockStore.EXPECT().
+		GetLatency(gomock.Any(), "room1").
+		Return(nil, nil)
+
+	resp, err := s.svc.GetRoom(s.ctx, "room1")
+	s.Require().NoError(err)
+
+	u, err := url.Parse(resp.HLSURL)
+	s.Require().NoError(err)
+	s.Equal("/hls/room1/stream.m3u8", u.Path)
+	s.Require().NoError(signer.Verify("room1", u.Query()))
+	s.Error(signer.Verify("room2", u.Query()))
+}
+
 func (s *RoomServiceTestSuite) TestNewRoomService() {
 	s.Run("create new room service", func() {
 		svc := NewRoomService(
 			s.mockStore,
 			s.mockResMgr,
+			nil,
 			"https://test.com/",
+			nil,
+			nil,
 			log.NewNop(),
 		).(*roomSvcImpl)
 
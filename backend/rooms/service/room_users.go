@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/rooms"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+// roomUsersClient reads room users from the users controller over its request stream
+type roomUsersClient struct {
+	rpcClient streamrpc.Client
+}
+
+func NewRoomUsersClient(rpcClient streamrpc.Client) rooms.RoomUsersReader {
+	return &roomUsersClient{rpcClient: rpcClient}
+}
+
+func (c *roomUsersClient) GetActiveRoomUsers(ctx context.Context, roomID string) ([]*users.RoomUser, error) {
+	request := &users.GetRoomUsersRequest{
+		RoomID: roomID,
+		TS:     time.Now(),
+	}
+	resp, err := users.MethodGetRoomUsers.Call(ctx, c.rpcClient, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room users: %w", err)
+	}
+	return resp.Users, nil
+}
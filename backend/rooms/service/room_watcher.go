@@ -2,24 +2,27 @@ package service
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/etcd"
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
-	"github.com/imtaco/audio-rtc-exp/internal/watcher"
-	etcdwatcher "github.com/imtaco/audio-rtc-exp/internal/watcher/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/roomstate"
 )
 
 // roomWatcherWithStats extends the base RoomWatcher with module usage statistics
 type roomWatcherWithStats struct {
-	watcher.Watcher[etcdstate.RoomState]
+	roomstate.Watcher
 	// Track module usage: moduleID -> count of rooms using it
 	rwLock     sync.RWMutex
 	janusUsage *moduleUsage
 	mixerUsage *moduleUsage
-	logger     *log.Logger
+	ending     map[string]struct{}
+	// onRelease is called when a room leaves a module, outside of rwLock
+	onRelease func(moduleType, moduleID, roomID string)
+	logger    *log.Logger
 }
 
 // NewRoomWatcherWithStats creates a new room watcher that tracks module usage statistics
@@ -32,17 +35,14 @@ func NewRoomWatcherWithStats(
 		logger: logger,
 	}
 
-	allowedTypes := []string{constants.RoomKeyMeta, constants.RoomKeyLiveMeta, constants.RoomKeyJanus, constants.RoomKeyMixer}
-
-	cfg := etcdwatcher.Config[etcdstate.RoomState]{
-		Client:           etcdClient,
-		PrefixToWatch:    prefixRooms,
-		AllowedKeyTypes:  allowedTypes,
-		Logger:           logger,
-		ProcessChange:    w.processChange,
-		StateTransformer: w,
-	}
-	w.Watcher = etcdwatcher.New(cfg)
+	w.Watcher = roomstate.New(&roomstate.Config{
+		Client:    etcdClient,
+		Prefix:    prefixRooms,
+		KeyTypes:  []string{constants.RoomKeyMeta, constants.RoomKeyLiveMeta, constants.RoomKeyJanus, constants.RoomKeyMixer, constants.RoomKeyQuality, constants.RoomKeyAnchors},
+		OnChange:  w.processChange,
+		Rebuilder: w,
+		Logger:    logger,
+	})
 
 	return w
 }
@@ -54,25 +54,54 @@ func (w *roomWatcherWithStats) processChange(_ context.Context, roomID string, s
 	newMixerID := state.GetLiveMeta().GetMixerID()
 
 	w.rwLock.Lock()
-	defer w.rwLock.Unlock()
-
 	// Update Janus usage
-	w.janusUsage.set(roomID, newJanusID)
-	w.mixerUsage.set(roomID, newMixerID)
+	oldJanusID := w.janusUsage.set(roomID, newJanusID)
+	oldMixerID := w.mixerUsage.set(roomID, newMixerID)
+	w.setEnding(roomID, state)
+	onRelease := w.onRelease
+	w.rwLock.Unlock()
 
+	if onRelease != nil {
+		if oldJanusID != "" {
+			onRelease(w.janusUsage.name, oldJanusID, roomID)
+		}
+		if oldMixerID != "" {
+			onRelease(w.mixerUsage.name, oldMixerID, roomID)
+		}
+	}
 	return nil
 }
 
+// OnRelease sets the handler called when a room leaves its Janus or mixer
+func (w *roomWatcherWithStats) OnRelease(fn func(moduleType, moduleID, roomID string)) {
+	w.rwLock.Lock()
+	defer w.rwLock.Unlock()
+	w.onRelease = fn
+}
+
+// setEnding tracks whether the room is being ended, callers hold rwLock
+func (w *roomWatcherWithStats) setEnding(roomID string, state *etcdstate.RoomState) {
+	stage := state.GetMeta().GetEndStage()
+	if stage != "" && !stage.Reached(constants.EndStageEnded) {
+		w.ending[roomID] = struct{}{}
+	} else {
+		delete(w.ending, roomID)
+	}
+}
+
 func (w *roomWatcherWithStats) RebuildStart(_ context.Context) error {
 	w.rwLock.Lock()
 
 	// Clear usage maps before rebuilding
 	w.janusUsage = newModuleUsage("janus", w.logger)
 	w.mixerUsage = newModuleUsage("mixer", w.logger)
+	w.ending = make(map[string]struct{})
 	return nil
 }
 
 func (w *roomWatcherWithStats) RebuildState(_ context.Context, id string, etcdData *etcdstate.RoomState) error {
+	w.setEnding(id, etcdData)
+
 	// During rebuild, count all active rooms
 	liveMeta := etcdData.GetLiveMeta()
 	if liveMeta == nil {
@@ -110,30 +139,22 @@ func (w *roomWatcherWithStats) GetMixerStreamCount(mixerID string) int {
 	return w.mixerUsage.count(mixerID)
 }
 
-func (w *roomWatcherWithStats) NewState(
-	_, keyType string,
-	data []byte,
-	curState *etcdstate.RoomState,
-) (*etcdstate.RoomState, error) {
-	if len(data) > 0 && curState == nil {
-		curState = &etcdstate.RoomState{}
-	}
+// GetRoomModules returns the Janus and mixer the room is live on, empty when it is not live
+func (w *roomWatcherWithStats) GetRoomModules(roomID string) (string, string) {
+	w.rwLock.RLock()
+	defer w.rwLock.RUnlock()
+	return w.janusUsage.moduleOf(roomID), w.mixerUsage.moduleOf(roomID)
+}
 
-	switch keyType {
-	case constants.RoomKeyMeta:
-		curState.SetMeta(etcdwatcher.ParseValue[etcdstate.Meta](data))
-	case constants.RoomKeyLiveMeta:
-		curState.SetLiveMeta(etcdwatcher.ParseValue[etcdstate.LiveMeta](data))
-	case constants.RoomKeyJanus:
-		curState.SetJanus(etcdwatcher.ParseValue[etcdstate.Janus](data))
-	case constants.RoomKeyMixer:
-		curState.SetMixer(etcdwatcher.ParseValue[etcdstate.Mixer](data))
-	}
+// EndingRooms returns the rooms being ended which did not reach the last end stage
+func (w *roomWatcherWithStats) EndingRooms() []string {
+	w.rwLock.RLock()
+	defer w.rwLock.RUnlock()
 
-	if curState.IsEmpty() {
-		//nolint:nilnil
-		return nil, nil
+	roomIDs := make([]string, 0, len(w.ending))
+	for roomID := range w.ending {
+		roomIDs = append(roomIDs, roomID)
 	}
-
-	return curState, nil
+	sort.Strings(roomIDs)
+	return roomIDs
 }
@@ -28,6 +28,7 @@ func (s *RoomWatcherTestSuite) SetupTest() {
 	s.watcher = &roomWatcherWithStats{
 		janusUsage: newModuleUsage("janus", logger),
 		mixerUsage: newModuleUsage("mixer", logger),
+		ending:     make(map[string]struct{}),
 		logger:     logger,
 	}
 }
@@ -321,71 +322,6 @@ func (s *RoomWatcherTestSuite) TestRebuildEnd() {
 	s.Require().NoError(err)
 }
 
-func (s *RoomWatcherTestSuite) TestNewState_Meta() {
-	data := []byte(`{"pin":"1234","hlsPath":"/hls/room-1","createdAt":"2024-01-01T00:00:00Z"}`)
-	newState, err := s.watcher.NewState("room-1", constants.RoomKeyMeta, data, nil)
-
-	s.Require().NoError(err)
-	s.NotNil(newState)
-	s.NotNil(newState.Meta)
-	s.Equal("1234", newState.Meta.Pin)
-}
-
-func (s *RoomWatcherTestSuite) TestNewState_LiveMeta() {
-	data := []byte(`{"janusID":"janus-1","mixerID":"mixer-1","status":"on-air"}`)
-	curState := &etcdstate.RoomState{}
-	newState, err := s.watcher.NewState("room-1", constants.RoomKeyLiveMeta, data, curState)
-
-	s.Require().NoError(err)
-	s.NotNil(newState)
-	s.NotNil(newState.LiveMeta)
-	s.Equal("janus-1", newState.LiveMeta.JanusID)
-	s.Equal("mixer-1", newState.LiveMeta.MixerID)
-}
-
-func (s *RoomWatcherTestSuite) TestNewState_Janus() {
-	data := []byte(`{"roomID":"room-1","instanceID":"janus-1"}`)
-	curState := &etcdstate.RoomState{}
-	newState, err := s.watcher.NewState("room-1", constants.RoomKeyJanus, data, curState)
-
-	s.Require().NoError(err)
-	s.NotNil(newState)
-	s.NotNil(newState.Janus)
-}
-
-func (s *RoomWatcherTestSuite) TestNewState_Mixer() {
-	data := []byte(`{"roomID":"room-1","instanceID":"mixer-1"}`)
-	curState := &etcdstate.RoomState{}
-	newState, err := s.watcher.NewState("room-1", constants.RoomKeyMixer, data, curState)
-
-	s.Require().NoError(err)
-	s.NotNil(newState)
-	s.NotNil(newState.Mixer)
-}
-
-func (s *RoomWatcherTestSuite) TestNewState_EmptyData() {
-	curState := &etcdstate.RoomState{
-		Meta: &etcdstate.Meta{
-			Pin: "1234",
-		},
-	}
-
-	newState, err := s.watcher.NewState("room-1", constants.RoomKeyMeta, []byte{}, curState)
-
-	s.Require().NoError(err)
-	// When data is empty and state is now empty, should return nil
-	s.Nil(newState)
-}
-
-func (s *RoomWatcherTestSuite) TestNewState_CreateNewStateWhenNil() {
-	data := []byte(`{"pin":"1234"}`)
-	newState, err := s.watcher.NewState("room-1", constants.RoomKeyMeta, data, nil)
-
-	s.Require().NoError(err)
-	s.NotNil(newState)
-	s.NotNil(newState.Meta)
-}
-
 func (s *RoomWatcherTestSuite) TestConcurrentStreamCountReads() {
 	// Setup initial data
 	state := &etcdstate.RoomState{
@@ -425,3 +361,29 @@ func (s *RoomWatcherTestSuite) TestConcurrentStreamCountReads() {
 		<-done
 	}
 }
+
+func (s *RoomWatcherTestSuite) TestEndingRooms() {
+	ending := func(stage constants.EndStage) *etcdstate.RoomState {
+		return &etcdstate.RoomState{Meta: &etcdstate.Meta{Ending: &etcdstate.Ending{Stage: stage}}}
+	}
+
+	s.Require().NoError(s.watcher.processChange(s.ctx, "room-b", ending(constants.EndStageStoppingUsers)))
+	s.Require().NoError(s.watcher.processChange(s.ctx, "room-a", ending(constants.EndStageNotifying)))
+	s.Require().NoError(s.watcher.processChange(s.ctx, "room-c", &etcdstate.RoomState{Meta: &etcdstate.Meta{}}))
+	s.Equal([]string{"room-a", "room-b"}, s.watcher.EndingRooms())
+
+	// ended and deleted rooms are no longer tracked
+	s.Require().NoError(s.watcher.processChange(s.ctx, "room-a", ending(constants.EndStageEnded)))
+	s.Require().NoError(s.watcher.processChange(s.ctx, "room-b", nil))
+	s.Empty(s.watcher.EndingRooms())
+}
+
+func (s *RoomWatcherTestSuite) TestRebuildState_Ending() {
+	s.Require().NoError(s.watcher.RebuildStart(s.ctx))
+	s.Require().NoError(s.watcher.RebuildState(s.ctx, "room-1", &etcdstate.RoomState{
+		Meta: &etcdstate.Meta{Ending: &etcdstate.Ending{Stage: constants.EndStageStoppingLive}},
+	}))
+	s.Require().NoError(s.watcher.RebuildEnd(s.ctx))
+
+	s.Equal([]string{"room-1"}, s.watcher.EndingRooms())
+}
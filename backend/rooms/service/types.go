@@ -1,12 +1,18 @@
 package service
 
 import (
-	reswatcher "github.com/imtaco/audio-rtc-exp/internal/reswatcher/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/roomstate"
 )
 
 // RoomWatcherWithStats extends RoomWatcher with module usage statistics
 type RoomWatcherWithStats interface {
-	reswatcher.RoomWatcher
+	roomstate.Watcher
 	GetJanusStreamCount(janusID string) int
 	GetMixerStreamCount(mixerID string) int
+	// GetRoomModules returns the Janus and mixer the room is live on, empty when it is not live
+	GetRoomModules(roomID string) (janusID, mixerID string)
+	// OnRelease sets the handler called when a room leaves its Janus or mixer
+	OnRelease(fn func(moduleType, moduleID, roomID string))
+	// EndingRooms returns the rooms being ended which did not reach the last end stage
+	EndingRooms() []string
 }
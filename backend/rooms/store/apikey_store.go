@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/rooms"
+)
+
+type apiKeyStoreImpl struct {
+	etcdClient etcd.KV
+	prefix     string
+	logger     *log.Logger
+}
+
+func NewAPIKeyStore(etcdClient etcd.KV, prefix string, logger *log.Logger) rooms.APIKeyStore {
+	return &apiKeyStoreImpl{
+		etcdClient: etcdClient,
+		prefix:     prefix,
+		logger:     logger,
+	}
+}
+
+func (ks *apiKeyStoreImpl) key(keyID string) string {
+	return ks.prefix + keyID
+}
+
+func (ks *apiKeyStoreImpl) CreateAPIKey(ctx context.Context, key *rooms.APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal API key: %w", err)
+	}
+	if _, err := ks.etcdClient.Put(ctx, ks.key(key.ID), string(data)); err != nil {
+		return fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	ks.logger.Info("Created API key",
+		log.String("keyId", key.ID),
+		log.String("tenant", key.Tenant),
+		log.Strings("scopes", key.Scopes))
+	return nil
+}
+
+func (ks *apiKeyStoreImpl) GetAPIKey(ctx context.Context, keyID string) (*rooms.APIKey, error) {
+	resp, err := ks.etcdClient.Get(ctx, ks.key(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	if len(resp.Kvs) == 0 {
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	var key rooms.APIKey
+	if err := json.Unmarshal(resp.Kvs[0].Value, &key); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal API key: %w", err)
+	}
+
+	return &key, nil
+}
+
+func (ks *apiKeyStoreImpl) ListAPIKeys(ctx context.Context) ([]*rooms.APIKey, error) {
+	resp, err := ks.etcdClient.Get(ctx, ks.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	keys := make([]*rooms.APIKey, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var key rooms.APIKey
+		if err := json.Unmarshal(kv.Value, &key); err != nil {
+			ks.logger.Warn("Skipping malformed API key", log.String("key", string(kv.Key)), log.Error(err))
+			continue
+		}
+		keys = append(keys, &key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// DeleteAPIKey returns false if the key does not exist
+func (ks *apiKeyStoreImpl) DeleteAPIKey(ctx context.Context, keyID string) (bool, error) {
+	resp, err := ks.etcdClient.Delete(ctx, ks.key(keyID))
+	if err != nil {
+		return false, fmt.Errorf("failed to delete API key: %w", err)
+	}
+	if resp.Deleted == 0 {
+		return false, nil
+	}
+
+	ks.logger.Info("Deleted API key", log.String("keyId", keyID))
+	return true, nil
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/mock/gomock"
+
+	etcdmocks "github.com/imtaco/audio-rtc-exp/internal/etcd/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/rooms"
+)
+
+type APIKeyStoreTestSuite struct {
+	suite.Suite
+	ctrl           *gomock.Controller
+	mockEtcdClient *etcdmocks.MockClient
+	store          rooms.APIKeyStore
+	ctx            context.Context
+}
+
+func TestAPIKeyStoreSuite(t *testing.T) {
+	suite.Run(t, new(APIKeyStoreTestSuite))
+}
+
+func (s *APIKeyStoreTestSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.mockEtcdClient = etcdmocks.NewMockClient(s.ctrl)
+	s.store = NewAPIKeyStore(s.mockEtcdClient, "/apikeys/", log.NewTest(s.T()))
+	s.ctx = context.Background()
+}
+
+func (s *APIKeyStoreTestSuite) TearDownTest() {
+	s.ctrl.Finish()
+}
+
+func (s *APIKeyStoreTestSuite) TestCreateAndGet() {
+	key := &rooms.APIKey{ID: "0123456789abcdef", Tenant: "acme", Scopes: []string{rooms.ScopeCreate}, Hash: "h"}
+	data, _ := json.Marshal(key)
+
+	s.mockEtcdClient.EXPECT().
+		Put(gomock.Any(), "/apikeys/0123456789abcdef", string(data)).
+		Return(&clientv3.PutResponse{}, nil)
+	s.Require().NoError(s.store.CreateAPIKey(s.ctx, key))
+
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/apikeys/0123456789abcdef").
+		Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{Value: data}}}, nil)
+	got, err := s.store.GetAPIKey(s.ctx, "0123456789abcdef")
+	s.Require().NoError(err)
+	s.Equal(key, got)
+}
+
+func (s *APIKeyStoreTestSuite) TestGet_NotFound() {
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/apikeys/0123456789abcdef").
+		Return(&clientv3.GetResponse{}, nil)
+
+	got, err := s.store.GetAPIKey(s.ctx, "0123456789abcdef")
+	s.Require().NoError(err)
+	s.Nil(got)
+}
+
+func (s *APIKeyStoreTestSuite) TestList_SortedByCreation() {
+	now := time.Now().UTC()
+	newer, _ := json.Marshal(&rooms.APIKey{ID: "b", CreatedAt: now})
+	older, _ := json.Marshal(&rooms.APIKey{ID: "a", CreatedAt: now.Add(-time.Hour)})
+
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/apikeys/", gomock.Any()).
+		Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{
+			{Key: []byte("/apikeys/b"), Value: newer},
+			{Key: []byte("/apikeys/bad"), Value: []byte("invalid")},
+			{Key: []byte("/apikeys/a"), Value: older},
+		}}, nil)
+
+	keys, err := s.store.ListAPIKeys(s.ctx)
+	s.Require().NoError(err)
+	s.Require().Len(keys, 2)
+	s.Equal("a", keys[0].ID)
+	s.Equal("b", keys[1].ID)
+}
+
+func (s *APIKeyStoreTestSuite) TestDelete() {
+	s.mockEtcdClient.EXPECT().
+		Delete(gomock.Any(), "/apikeys/a").
+		Return(&clientv3.DeleteResponse{Deleted: 1}, nil)
+	deleted, err := s.store.DeleteAPIKey(s.ctx, "a")
+	s.Require().NoError(err)
+	s.True(deleted)
+
+	s.mockEtcdClient.EXPECT().
+		Delete(gomock.Any(), "/apikeys/b").
+		Return(&clientv3.DeleteResponse{Header: &etcdserverpb.ResponseHeader{}}, nil)
+	deleted, err = s.store.DeleteAPIKey(s.ctx, "b")
+	s.Require().NoError(err)
+	s.False(deleted)
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/rooms"
+)
+
+// MigrateLegacyModuleMarks moves module marks written by older room services to the module prefix.
+// Those were stored at "<moduleType><moduleID>/mark" (e.g. "janusesjanus-1/mark"), outside
+// the prefix the resource manager and the modules watch, so they never took effect.
+// A mark already present at the new key wins, the legacy key is deleted either way.
+func MigrateLegacyModuleMarks(
+	ctx context.Context,
+	etcdClient etcd.Client,
+	prefixJanus string,
+	prefixMixer string,
+	logger *log.Logger,
+) error {
+	prefixes := map[string]string{
+		rooms.ModuleTypeJanuses: prefixJanus,
+		rooms.ModuleTypeMixers:  prefixMixer,
+	}
+
+	for moduleType, prefix := range prefixes {
+		resp, err := etcdClient.Get(ctx, moduleType, clientv3.WithPrefix())
+		if err != nil {
+			return fmt.Errorf("failed to list legacy %s marks: %w", moduleType, err)
+		}
+
+		for _, kv := range resp.Kvs {
+			legacyKey := string(kv.Key)
+			moduleID, ok := strings.CutSuffix(strings.TrimPrefix(legacyKey, moduleType), "/"+constants.ModuleKeyMark)
+			if !ok || moduleID == "" || strings.Contains(moduleID, "/") {
+				continue
+			}
+
+			markKey := fmt.Sprintf("%s%s/%s", prefix, moduleID, constants.ModuleKeyMark)
+			var opts []clientv3.OpOption
+			if kv.Lease != 0 {
+				opts = append(opts, clientv3.WithLease(clientv3.LeaseID(kv.Lease)))
+			}
+
+			_, err := etcdClient.Txn(ctx).
+				If(clientv3.Compare(clientv3.CreateRevision(markKey), "=", 0)).
+				Then(clientv3.OpPut(markKey, string(kv.Value), opts...), clientv3.OpDelete(legacyKey)).
+				Else(clientv3.OpDelete(legacyKey)).
+				Commit()
+			if err != nil {
+				return fmt.Errorf("failed to migrate mark %s: %w", legacyKey, err)
+			}
+
+			logger.Info("Migrated legacy module mark",
+				log.String("from", legacyKey),
+				log.String("to", markKey))
+		}
+	}
+	return nil
+}
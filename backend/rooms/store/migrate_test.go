@@ -0,0 +1,41 @@
+package store
+
+import (
+	"errors"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+func (s *RoomStoreTestSuite) TestMigrateLegacyModuleMarks() {
+	s.mockEtcdClient.EXPECT().Get(gomock.Any(), "januses", gomock.Any()).Return(&clientv3.GetResponse{
+		Kvs: []*mvccpb.KeyValue{
+			{Key: []byte("janusesjanus-1/mark"), Value: []byte(`{"label":"cordon"}`), Lease: 42},
+			{Key: []byte("januses-unrelated"), Value: []byte("x")},
+		},
+	}, nil)
+	s.mockEtcdClient.EXPECT().Get(gomock.Any(), "mixers", gomock.Any()).Return(&clientv3.GetResponse{}, nil)
+
+	txn := &fakeTxn{}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	err := MigrateLegacyModuleMarks(s.ctx, s.mockEtcdClient, "/januses/", "/mixers/", log.NewNop())
+	s.Require().NoError(err)
+
+	s.Require().Len(txn.ops, 2)
+	s.True(txn.ops[0].IsPut())
+	s.Equal("/januses/janus-1/mark", string(txn.ops[0].KeyBytes()))
+	s.JSONEq(`{"label":"cordon"}`, string(txn.ops[0].ValueBytes()))
+	s.True(txn.ops[1].IsDelete())
+	s.Equal("janusesjanus-1/mark", string(txn.ops[1].KeyBytes()))
+}
+
+func (s *RoomStoreTestSuite) TestMigrateLegacyModuleMarks_GetError() {
+	s.mockEtcdClient.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("etcd down"))
+
+	err := MigrateLegacyModuleMarks(s.ctx, s.mockEtcdClient, "/januses/", "/mixers/", log.NewNop())
+	s.Require().Error(err)
+}
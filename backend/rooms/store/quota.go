@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/rooms"
+)
+
+// maxQuotaTxnAttempts bounds the retries of writes racing with other writes of the same tenant
+const maxQuotaTxnAttempts = 5
+
+var (
+	errQuotaConflict  = errors.New("tenant rooms changed concurrently, retries exhausted")
+	errQuotasDisabled = errors.New("tenant quotas are disabled")
+)
+
+// Tenant keys, under the tenants prefix:
+//
+//	<tenant>/quota            rooms.Quota
+//	<tenant>/rooms/<roomId>   rooms of the tenant, from creation to deletion
+//	<tenant>/onair/<roomId>   rooms of the tenant on air, from start to stop of the live
+func (rs *roomStoreImpl) quotaKey(tenant string) string {
+	return rs.prefixTenants + tenant + "/quota"
+}
+
+func (rs *roomStoreImpl) tenantRoomsPrefix(tenant string) string {
+	return rs.prefixTenants + tenant + "/rooms/"
+}
+
+func (rs *roomStoreImpl) tenantOnAirPrefix(tenant string) string {
+	return rs.prefixTenants + tenant + "/onair/"
+}
+
+// tenantIndexed reports whether the rooms of tenant are counted against its quota,
+// rooms without tenant are not, nor any room when no tenants prefix is set
+func (rs *roomStoreImpl) tenantIndexed(tenant string) bool {
+	return rs.prefixTenants != "" && tenant != ""
+}
+
+// quotaCheck is the usage of a quota read at one revision
+type quotaCheck struct {
+	// cmps fail once a room is added under the counted prefix or the quota changes
+	cmps []clientv3.Cmp
+	// counted is set when the room is counted already
+	counted bool
+}
+
+// checkQuota counts the rooms under prefix against the limit of the quota of tenant,
+// the returned compares keep the count valid up to the transaction using them
+func (rs *roomStoreImpl) checkQuota(
+	ctx context.Context,
+	tenant, prefix, roomID, resource string,
+	limitOf func(*rooms.Quota) int,
+) (*quotaCheck, error) {
+	quotaKey := rs.quotaKey(tenant)
+	resp, err := rs.etcdClient.Txn(ctx).
+		Then(
+			clientv3.OpGet(quotaKey),
+			clientv3.OpGet(prefix, clientv3.WithPrefix(), clientv3.WithCountOnly()),
+			clientv3.OpGet(prefix+roomID),
+		).
+		Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check quota: %w", err)
+	}
+	if len(resp.Responses) < 3 {
+		return nil, fmt.Errorf("failed to check quota: unexpected response")
+	}
+
+	quota, err := parseQuota(resp.Responses[0].GetResponseRange().GetKvs())
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses[2].GetResponseRange().GetKvs()) > 0 {
+		return &quotaCheck{counted: true}, nil
+	}
+	count := resp.Responses[1].GetResponseRange().GetCount()
+	if limit := limitOf(quota); limit > 0 && count >= int64(limit) {
+		return nil, &rooms.QuotaExceededError{Tenant: tenant, Resource: resource, Limit: limit}
+	}
+
+	rev := resp.Header.GetRevision()
+	return &quotaCheck{cmps: []clientv3.Cmp{
+		clientv3.Compare(clientv3.ModRevision(prefix), "<", rev+1).WithPrefix(),
+		clientv3.Compare(clientv3.ModRevision(quotaKey), "<", rev+1),
+	}}, nil
+}
+
+// reserveOnAir counts the room of tenant as on air, unless its quota of on-air rooms is reached
+func (rs *roomStoreImpl) reserveOnAir(ctx context.Context, tenant, roomID string) error {
+	prefix := rs.tenantOnAirPrefix(tenant)
+	for range maxQuotaTxnAttempts {
+		check, err := rs.checkQuota(ctx, tenant, prefix, roomID, rooms.QuotaOnAirRooms,
+			func(q *rooms.Quota) int { return q.MaxOnAirRooms })
+		if err != nil {
+			return err
+		}
+		if check.counted {
+			return nil
+		}
+
+		resp, err := rs.etcdClient.Txn(ctx).
+			If(check.cmps...).
+			Then(clientv3.OpPut(prefix+roomID, "")).
+			Commit()
+		if err != nil {
+			return fmt.Errorf("failed to reserve on-air room: %w", err)
+		}
+		if resp.Succeeded {
+			return nil
+		}
+	}
+	return errQuotaConflict
+}
+
+func (rs *roomStoreImpl) GetQuotaUsage(ctx context.Context, tenant string) (*rooms.QuotaUsage, error) {
+	if rs.prefixTenants == "" {
+		return nil, errQuotasDisabled
+	}
+
+	resp, err := rs.etcdClient.Txn(ctx).
+		Then(
+			clientv3.OpGet(rs.quotaKey(tenant)),
+			clientv3.OpGet(rs.tenantRoomsPrefix(tenant), clientv3.WithPrefix(), clientv3.WithCountOnly()),
+			clientv3.OpGet(rs.tenantOnAirPrefix(tenant), clientv3.WithPrefix(), clientv3.WithCountOnly()),
+		).
+		Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+	if len(resp.Responses) < 3 {
+		return nil, fmt.Errorf("failed to get quota usage: unexpected response")
+	}
+
+	quota, err := parseQuota(resp.Responses[0].GetResponseRange().GetKvs())
+	if err != nil {
+		return nil, err
+	}
+	return &rooms.QuotaUsage{
+		Tenant:     tenant,
+		Quota:      *quota,
+		Rooms:      int(resp.Responses[1].GetResponseRange().GetCount()),
+		OnAirRooms: int(resp.Responses[2].GetResponseRange().GetCount()),
+	}, nil
+}
+
+func (rs *roomStoreImpl) SetQuota(ctx context.Context, tenant string, quota *rooms.Quota) error {
+	if rs.prefixTenants == "" {
+		return errQuotasDisabled
+	}
+
+	data, err := json.Marshal(quota)
+	if err != nil {
+		return fmt.Errorf("failed to marshal quota: %w", err)
+	}
+	if _, err := rs.etcdClient.Put(ctx, rs.quotaKey(tenant), string(data)); err != nil {
+		return fmt.Errorf("failed to store quota: %w", err)
+	}
+
+	rs.logger.Info("Set tenant quota",
+		log.String("tenant", tenant),
+		log.Int("maxRooms", quota.MaxRooms),
+		log.Int("maxOnAirRooms", quota.MaxOnAirRooms))
+	return nil
+}
+
+// parseQuota returns the stored quota, unlimited when none is stored
+func parseQuota(kvs []*mvccpb.KeyValue) (*rooms.Quota, error) {
+	var quota rooms.Quota
+	if len(kvs) == 0 {
+		return &quota, nil
+	}
+	if err := json.Unmarshal(kvs[0].Value, &quota); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quota: %w", err)
+	}
+	return &quota, nil
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate/fixtures"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/rooms"
+)
+
+// tenantStore returns a store counting the rooms of tenants against their quotas
+func (s *RoomStoreTestSuite) tenantStore() rooms.RoomStore {
+	return NewRoomStore(s.mockEtcdClient, s.mockOutbox, "/rooms/", "/januses/", "/mixers/", "/externalids/", "/tenants/", "/roomtags/", log.NewNop())
+}
+
+// quotaTxn answers the reads of a quota check
+func (s *RoomStoreTestSuite) quotaTxn(quota *rooms.Quota, count int64, counted bool) *fakeTxn {
+	var quotaKVs, roomKVs []*mvccpb.KeyValue
+	if quota != nil {
+		quotaKVs = append(quotaKVs, fixtures.KV("/tenants/acme/quota", quota))
+	}
+	if counted {
+		roomKVs = append(roomKVs, &mvccpb.KeyValue{Key: []byte("/tenants/acme/onair/room-1")})
+	}
+	return &fakeTxn{
+		ranges: [][]*mvccpb.KeyValue{quotaKVs, nil, roomKVs},
+		counts: []int64{0, count, 0},
+		rev:    10,
+	}
+}
+
+func (s *RoomStoreTestSuite) TestCreateRoom_TenantWithinQuota() {
+	store := s.tenantStore()
+	s.expectGet("/rooms/room-1/meta", nil, 0)
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(s.quotaTxn(&rooms.Quota{MaxRooms: 2}, 1, false))
+	txn := &fakeTxn{}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	_, err := store.CreateRoom(s.ctx, "room-1", &etcdstate.Meta{Tenant: "acme"})
+	s.Require().NoError(err)
+
+	// the meta is new, no room was added and the quota is unchanged since the check
+	s.Len(txn.cmps, 3)
+	s.Require().Len(txn.ops, 2)
+	s.Equal("/rooms/room-1/meta", string(txn.ops[0].KeyBytes()))
+	s.Equal("/tenants/acme/rooms/room-1", string(txn.ops[1].KeyBytes()))
+}
+
+func (s *RoomStoreTestSuite) TestCreateRoom_TenantOverQuota() {
+	store := s.tenantStore()
+	s.expectGet("/rooms/room-1/meta", nil, 0)
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(s.quotaTxn(&rooms.Quota{MaxRooms: 2}, 2, false))
+
+	result, err := store.CreateRoom(s.ctx, "room-1", &etcdstate.Meta{Tenant: "acme"})
+	s.Nil(result)
+	var quotaErr *rooms.QuotaExceededError
+	s.Require().ErrorAs(err, &quotaErr)
+	s.Equal(rooms.QuotaRooms, quotaErr.Resource)
+	s.Equal(2, quotaErr.Limit)
+}
+
+func (s *RoomStoreTestSuite) TestCreateRoom_TenantRetriesOnConflict() {
+	store := s.tenantStore()
+	s.expectGet("/rooms/room-1/meta", nil, 0)
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(s.quotaTxn(nil, 5, false))
+	// another room of the tenant was added after the check, the meta is still missing
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{failed: true, ranges: [][]*mvccpb.KeyValue{nil}})
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(s.quotaTxn(nil, 6, false))
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{})
+
+	_, err := store.CreateRoom(s.ctx, "room-1", &etcdstate.Meta{Tenant: "acme"})
+	s.Require().NoError(err)
+}
+
+func (s *RoomStoreTestSuite) TestCreateLiveMeta_TenantOverOnAirQuota() {
+	store := s.tenantStore()
+	s.expectGet("/rooms/room-1/meta", &etcdstate.Meta{Tenant: "acme"}, 3)
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(s.quotaTxn(&rooms.Quota{MaxOnAirRooms: 1}, 1, false))
+
+	err := store.CreateLiveMeta(s.ctx, "room-1", "mixer-1", "janus-1", "nonce")
+	var quotaErr *rooms.QuotaExceededError
+	s.Require().ErrorAs(err, &quotaErr)
+	s.Equal(rooms.QuotaOnAirRooms, quotaErr.Resource)
+}
+
+func (s *RoomStoreTestSuite) TestCreateLiveMeta_TenantReservesOnAir() {
+	store := s.tenantStore()
+	s.expectGet("/rooms/room-1/meta", &etcdstate.Meta{Tenant: "acme"}, 3)
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(s.quotaTxn(&rooms.Quota{MaxOnAirRooms: 2}, 1, false))
+	reserve := &fakeTxn{}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(reserve)
+	s.mockOutbox.EXPECT().Commit(gomock.Any(), rooms.EventRoomLive, gomock.Any(), gomock.Any()).Return(nil)
+
+	err := store.CreateLiveMeta(s.ctx, "room-1", "mixer-1", "janus-1", "nonce")
+	s.Require().NoError(err)
+	s.Len(reserve.cmps, 2)
+	s.Require().Len(reserve.ops, 1)
+	s.Equal("/tenants/acme/onair/room-1", string(reserve.ops[0].KeyBytes()))
+}
+
+func (s *RoomStoreTestSuite) TestCreateLiveMeta_TenantAlreadyOnAir() {
+	store := s.tenantStore()
+	s.expectGet("/rooms/room-1/meta", &etcdstate.Meta{Tenant: "acme"}, 3)
+	// a restarted live is counted once, even at the limit
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(s.quotaTxn(&rooms.Quota{MaxOnAirRooms: 1}, 1, true))
+	s.mockOutbox.EXPECT().Commit(gomock.Any(), rooms.EventRoomLive, gomock.Any(), gomock.Any()).Return(nil)
+
+	err := store.CreateLiveMeta(s.ctx, "room-1", "mixer-1", "janus-1", "nonce")
+	s.Require().NoError(err)
+}
+
+func (s *RoomStoreTestSuite) TestStopLiveMeta_TenantReleasesOnAir() {
+	store := s.tenantStore()
+	s.expectGet("/rooms/room-1/meta", &etcdstate.Meta{Tenant: "acme"}, 3)
+	s.mockOutbox.EXPECT().
+		Commit(gomock.Any(), rooms.EventRoomStopped, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, _ any, ops ...clientv3.Op) error {
+			s.Require().Len(ops, 2)
+			s.Equal("/rooms/room-1/livemeta", string(ops[0].KeyBytes()))
+			s.True(ops[1].IsDelete())
+			s.Equal("/tenants/acme/onair/room-1", string(ops[1].KeyBytes()))
+			return nil
+		})
+
+	s.Require().NoError(store.StopLiveMeta(s.ctx, "room-1"))
+}
+
+func (s *RoomStoreTestSuite) TestGetQuotaUsage() {
+	store := s.tenantStore()
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{
+		ranges: [][]*mvccpb.KeyValue{{fixtures.KV("/tenants/acme/quota", &rooms.Quota{MaxRooms: 10, MaxOnAirRooms: 2})}, nil, nil},
+		counts: []int64{1, 4, 1},
+	})
+
+	usage, err := store.GetQuotaUsage(s.ctx, "acme")
+	s.Require().NoError(err)
+	s.Equal(&rooms.QuotaUsage{
+		Tenant:     "acme",
+		Quota:      rooms.Quota{MaxRooms: 10, MaxOnAirRooms: 2},
+		Rooms:      4,
+		OnAirRooms: 1,
+	}, usage)
+}
+
+func (s *RoomStoreTestSuite) TestSetQuota() {
+	store := s.tenantStore()
+	s.mockEtcdClient.EXPECT().
+		Put(gomock.Any(), "/tenants/acme/quota", `{"maxRooms":10,"maxOnAirRooms":2}`).
+		Return(&clientv3.PutResponse{}, nil)
+
+	s.Require().NoError(store.SetQuota(s.ctx, "acme", &rooms.Quota{MaxRooms: 10, MaxOnAirRooms: 2}))
+}
+
+func (s *RoomStoreTestSuite) TestQuotas_Disabled() {
+	_, err := s.store.GetQuotaUsage(s.ctx, "acme")
+	s.ErrorIs(err, errQuotasDisabled)
+	s.ErrorIs(s.store.SetQuota(s.ctx, "acme", &rooms.Quota{}), errQuotasDisabled)
+}
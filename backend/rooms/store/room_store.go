@@ -3,30 +3,80 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/etcd"
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/outbox"
+	"github.com/imtaco/audio-rtc-exp/internal/roomstate"
+	"github.com/imtaco/audio-rtc-exp/internal/timeline"
 	"github.com/imtaco/audio-rtc-exp/rooms"
 	"github.com/imtaco/audio-rtc-exp/rooms/utils"
 
+	"github.com/hashicorp/golang-lru/v2/expirable"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
+// maxLinkTxnAttempts bounds the retries of link updates racing on the same link index
+const maxLinkTxnAttempts = 3
+
+var errLinkConflict = errors.New("link index changed concurrently, retries exhausted")
+
+// maxMetaTxnAttempts bounds the retries of meta updates racing with other writers of the meta
+const maxMetaTxnAttempts = 3
+
+// moduleStatusTTL bounds how stale ListModuleStatus may be, it reads the whole room prefix
+// so console polling is served from the last snapshot
+const moduleStatusTTL = 2 * time.Second
+
 type roomStoreImpl struct {
-	etcdClient etcd.Client
-	prefix     string
-	logger     *log.Logger
+	etcdClient  etcd.Client
+	outbox      outbox.Writer
+	prefix      string
+	prefixJanus string
+	prefixMixer string
+	// externalIDs indexes rooms by their external ID, externalID -> roomID
+	prefixExternalIDs string
+	// tenants holds the quotas and room indexes of tenants, empty disables quotas
+	prefixTenants string
+	// tags indexes rooms by their tags, empty disables the index
+	prefixTags string
+	// module type -> last ListModuleStatus result
+	moduleStatus *expirable.LRU[string, []*rooms.ModuleStatus]
+	logger       *log.Logger
 }
 
-func NewRoomStore(etcdClient etcd.Client, prefix string, logger *log.Logger) rooms.RoomStore {
+func NewRoomStore(
+	etcdClient etcd.Client,
+	outbox outbox.Writer, // nil when room events are not published
+	prefix string,
+	prefixJanus string,
+	prefixMixer string,
+	prefixExternalIDs string,
+	prefixTenants string,
+	prefixTags string,
+	logger *log.Logger,
+) rooms.RoomStore {
 	return &roomStoreImpl{
-		etcdClient: etcdClient,
-		prefix:     prefix,
-		logger:     logger,
+		etcdClient:        etcdClient,
+		outbox:            outbox,
+		prefix:            prefix,
+		prefixJanus:       prefixJanus,
+		prefixMixer:       prefixMixer,
+		prefixExternalIDs: prefixExternalIDs,
+		prefixTenants:     prefixTenants,
+		prefixTags:        prefixTags,
+		moduleStatus: expirable.NewLRU[string, []*rooms.ModuleStatus](
+			2, nil, moduleStatusTTL,
+		),
+		logger: logger,
 	}
 }
 
@@ -42,6 +92,22 @@ func (rs *roomStoreImpl) mixerKey(roomID string) string {
 	return fmt.Sprintf("%s%s/%s", rs.prefix, roomID, constants.RoomKeyMixer)
 }
 
+func (rs *roomStoreImpl) linkKey(roomID string) string {
+	return fmt.Sprintf("%s%s/%s", rs.prefix, roomID, constants.RoomKeyLink)
+}
+
+func (rs *roomStoreImpl) linkedByKey(roomID string) string {
+	return fmt.Sprintf("%s%s/%s", rs.prefix, roomID, constants.RoomKeyLinkedBy)
+}
+
+func (rs *roomStoreImpl) latencyKey(roomID string) string {
+	return fmt.Sprintf("%s%s/%s", rs.prefix, roomID, constants.RoomKeyLatency)
+}
+
+func (rs *roomStoreImpl) externalIDKey(externalID string) string {
+	return rs.prefixExternalIDs + externalID
+}
+
 func (rs *roomStoreImpl) CreateRoom(ctx context.Context, roomID string, roomData *etcdstate.Meta) (*etcdstate.Meta, error) {
 	metaKey := rs.metaKey(roomID)
 	rs.logger.Info("create room with key", log.String("metaKey", metaKey))
@@ -64,6 +130,17 @@ func (rs *roomStoreImpl) CreateRoom(ctx context.Context, roomID string, roomData
 		return nil, fmt.Errorf("failed to marshal room data: %w", err)
 	}
 
+	if roomData.ExternalID != "" || rs.tenantIndexed(roomData.Tenant) || (rs.tagsIndexed() && len(roomData.Tags) > 0) {
+		if err := rs.createIndexedRoom(ctx, roomID, roomData, string(data)); err != nil {
+			return nil, err
+		}
+		rs.logger.Info("Created room",
+			log.String("roomId", roomID),
+			log.String("externalId", roomData.ExternalID),
+			log.String("tenant", roomData.Tenant))
+		return roomData, nil
+	}
+
 	// Store in etcd
 	_, err = rs.etcdClient.Put(ctx, metaKey, string(data))
 	if err != nil {
@@ -74,6 +151,69 @@ func (rs *roomStoreImpl) CreateRoom(ctx context.Context, roomID string, roomData
 	return roomData, nil
 }
 
+// createIndexedRoom stores the meta together with its external ID index, its tag indexes and
+// the room index of its tenant, the external ID must not index another room and the tenant
+// must be within quota
+func (rs *roomStoreImpl) createIndexedRoom(ctx context.Context, roomID string, room *etcdstate.Meta, meta string) error {
+	metaKey := rs.metaKey(roomID)
+	cmps := []clientv3.Cmp{clientv3.Compare(clientv3.CreateRevision(metaKey), "=", 0)}
+	ops := []clientv3.Op{clientv3.OpPut(metaKey, meta)}
+	var elseOps []clientv3.Op
+
+	if room.ExternalID != "" {
+		indexKey := rs.externalIDKey(room.ExternalID)
+		cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(indexKey), "=", 0))
+		ops = append(ops, clientv3.OpPut(indexKey, roomID))
+		elseOps = append(elseOps, clientv3.OpGet(indexKey))
+	}
+	ops = append(ops, rs.tagOps(roomID, nil, room.Tags)...)
+
+	for range maxQuotaTxnAttempts {
+		txnCmps, txnOps := cmps, ops
+		if rs.tenantIndexed(room.Tenant) {
+			prefix := rs.tenantRoomsPrefix(room.Tenant)
+			check, err := rs.checkQuota(ctx, room.Tenant, prefix, roomID, rooms.QuotaRooms,
+				func(q *rooms.Quota) int { return q.MaxRooms })
+			if err != nil {
+				return err
+			}
+			txnCmps = append(slices.Clone(cmps), check.cmps...)
+			txnOps = append(slices.Clone(ops), clientv3.OpPut(prefix+roomID, ""))
+		}
+
+		resp, err := rs.etcdClient.Txn(ctx).
+			If(txnCmps...).
+			Then(txnOps...).
+			Else(append(slices.Clone(elseOps), clientv3.OpGet(metaKey))...).
+			Commit()
+		if err != nil {
+			return fmt.Errorf("failed to store room: %w", err)
+		}
+		if resp.Succeeded {
+			return nil
+		}
+		if room.ExternalID != "" && len(resp.Responses) > 0 && len(resp.Responses[0].GetResponseRange().GetKvs()) > 0 {
+			return &rooms.ExternalIDExistsError{ExternalID: room.ExternalID}
+		}
+		// only the quota compares of a tenant room can fail without the room existing
+		if !rs.tenantIndexed(room.Tenant) || len(resp.Responses[len(resp.Responses)-1].GetResponseRange().GetKvs()) > 0 {
+			return fmt.Errorf("room %s already exists", roomID)
+		}
+	}
+	return fmt.Errorf("failed to store room: %w", errQuotaConflict)
+}
+
+func (rs *roomStoreImpl) ResolveExternalID(ctx context.Context, externalID string) (string, error) {
+	resp, err := rs.etcdClient.Get(ctx, rs.externalIDKey(externalID))
+	if err != nil {
+		return "", fmt.Errorf("failed to get external ID: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return "", nil
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
 func (rs *roomStoreImpl) GetRoom(ctx context.Context, roomID string) (*etcdstate.Meta, error) {
 	metaKey := rs.metaKey(roomID)
 	resp, err := rs.etcdClient.Get(ctx, metaKey)
@@ -94,6 +234,50 @@ func (rs *roomStoreImpl) GetRoom(ctx context.Context, roomID string) (*etcdstate
 	return &room, nil
 }
 
+// UpdateRoom applies update to the room meta and writes it back if the meta was not modified
+// meanwhile, returns nil when the room does not exist. update may run once per attempt.
+func (rs *roomStoreImpl) UpdateRoom(ctx context.Context, roomID string, update func(meta *etcdstate.Meta) error) (*etcdstate.Meta, error) {
+	metaKey := rs.metaKey(roomID)
+
+	for range maxMetaTxnAttempts {
+		resp, err := rs.etcdClient.Get(ctx, metaKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get room: %w", err)
+		}
+		if len(resp.Kvs) == 0 {
+			//nolint:nilnil
+			return nil, nil
+		}
+
+		var meta etcdstate.Meta
+		if err := json.Unmarshal(resp.Kvs[0].Value, &meta); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal room data: %w", err)
+		}
+		tags := meta.Tags
+		if err := update(&meta); err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(&meta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal room data: %w", err)
+		}
+
+		ops := append([]clientv3.Op{clientv3.OpPut(metaKey, string(data))}, rs.tagOps(roomID, tags, meta.Tags)...)
+		txnResp, err := rs.etcdClient.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(metaKey), "=", resp.Kvs[0].ModRevision)).
+			Then(ops...).
+			Commit()
+		if err != nil {
+			return nil, fmt.Errorf("failed to update room: %w", err)
+		}
+		if txnResp.Succeeded {
+			rs.logger.Info("Updated room meta", log.String("roomId", roomID))
+			return &meta, nil
+		}
+	}
+	return nil, &rooms.RoomUpdateConflictError{RoomID: roomID}
+}
+
 func (rs *roomStoreImpl) Exists(ctx context.Context, roomID string) (bool, error) {
 	metaKey := rs.metaKey(roomID)
 	rs.logger.Info("Check room existence", log.String("metaKey", metaKey))
@@ -112,28 +296,107 @@ func (rs *roomStoreImpl) StopRoom(ctx context.Context, roomID string) error {
 	return rs.StopLiveMeta(ctx, roomID)
 }
 
+// DeleteRoom deletes all keys of the room together with the links from and into it
 func (rs *roomStoreImpl) DeleteRoom(ctx context.Context, roomID string) (bool, error) {
 	roomPrefix := fmt.Sprintf("%s%s/", rs.prefix, roomID)
 
-	// Delete all keys with prefix /rooms/<room_id>/
-	resp, err := rs.etcdClient.Delete(ctx, roomPrefix, clientv3.WithPrefix())
+	// the external ID never changes, its index goes with the room as do the tag indexes
+	meta, err := rs.GetRoom(ctx, roomID)
 	if err != nil {
-		return false, fmt.Errorf("failed to delete room: %w", err)
+		return false, err
 	}
 
-	if resp.Deleted == 0 {
-		rs.logger.Info("Room not found", log.String("roomId", roomID))
-		return false, nil
-	}
+	for range maxLinkTxnAttempts {
+		link, linkRev, err := rs.getLink(ctx, roomID)
+		if err != nil {
+			return false, err
+		}
+		linkedBy, linkedByRev, err := rs.getLinkedBy(ctx, roomID)
+		if err != nil {
+			return false, err
+		}
+
+		cmps := []clientv3.Cmp{
+			clientv3.Compare(clientv3.ModRevision(rs.linkKey(roomID)), "=", linkRev),
+			clientv3.Compare(clientv3.ModRevision(rs.linkedByKey(roomID)), "=", linkedByRev),
+		}
+		// Delete all keys with prefix /rooms/<room_id>/, the prefix delete goes first to count the keys
+		ops := []clientv3.Op{clientv3.OpDelete(roomPrefix, clientv3.WithPrefix())}
+
+		if tenant := meta.GetTenant(); rs.tenantIndexed(tenant) {
+			ops = append(ops,
+				clientv3.OpDelete(rs.tenantRoomsPrefix(tenant)+roomID),
+				clientv3.OpDelete(rs.tenantOnAirPrefix(tenant)+roomID))
+		}
+
+		if externalID := meta.GetExternalID(); externalID != "" {
+			indexKey := rs.externalIDKey(externalID)
+			ops = append(ops, clientv3.OpTxn(
+				[]clientv3.Cmp{clientv3.Compare(clientv3.Value(indexKey), "=", roomID)},
+				[]clientv3.Op{clientv3.OpDelete(indexKey)},
+				nil,
+			))
+		}
+		ops = append(ops, rs.tagOps(roomID, meta.GetTags(), nil)...)
+
+		// links forwarding this room into other rooms
+		for _, targetRoomID := range linkedBy.TargetIDs() {
+			ops = append(ops, clientv3.OpDelete(rs.linkKey(targetRoomID)))
+		}
 
-	rs.logger.Info("Deleted room", log.String("roomId", roomID), log.Int64("deleted", resp.Deleted))
-	return true, nil
+		// the link forwarding another room into this one
+		if link != nil {
+			sourceLinkedBy, rev, err := rs.getLinkedBy(ctx, link.SourceRoomID)
+			if err != nil {
+				return false, err
+			}
+			delete(sourceLinkedBy.Targets, roomID)
+			indexOp, err := rs.linkedByOp(link.SourceRoomID, sourceLinkedBy)
+			if err != nil {
+				return false, err
+			}
+			cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(rs.linkedByKey(link.SourceRoomID)), "=", rev))
+			ops = append(ops, indexOp)
+		}
+
+		resp, err := rs.etcdClient.Txn(ctx).If(cmps...).Then(ops...).Commit()
+		if err != nil {
+			return false, fmt.Errorf("failed to delete room: %w", err)
+		}
+		if !resp.Succeeded {
+			continue
+		}
+
+		deleted := resp.Responses[0].GetResponseDeleteRange().GetDeleted()
+		if deleted == 0 {
+			rs.logger.Info("Room not found", log.String("roomId", roomID))
+			return false, nil
+		}
+
+		rs.logger.Info("Deleted room",
+			log.String("roomId", roomID),
+			log.Int64("deleted", deleted),
+			log.Int("linksFrom", len(linkedBy.Targets)))
+		return true, nil
+	}
+	return false, fmt.Errorf("failed to delete room: %w", errLinkConflict)
 }
 
+// CreateLiveMeta puts the room on air, a room of a tenant is counted as on air first
 func (rs *roomStoreImpl) CreateLiveMeta(ctx context.Context, roomID, mixerID, janusID, nonce string) error {
 	livemetaKey := rs.livemetaKey(roomID)
 	rs.logger.Info("Starting livemeta for room", log.String("roomId", roomID))
 
+	tenant, err := rs.roomTenant(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if tenant != "" {
+		if err := rs.reserveOnAir(ctx, tenant, roomID); err != nil {
+			return err
+		}
+	}
+
 	livemeta := etcdstate.LiveMeta{
 		Status:    constants.RoomStatusOnAir,
 		MixerID:   mixerID,
@@ -147,8 +410,12 @@ func (rs *roomStoreImpl) CreateLiveMeta(ctx context.Context, roomID, mixerID, ja
 		return fmt.Errorf("failed to marshal livemeta: %w", err)
 	}
 
-	_, err = rs.etcdClient.Put(ctx, livemetaKey, string(data))
+	event := &rooms.RoomEvent{RoomID: roomID, LiveMeta: &livemeta}
+	err = rs.putWithEvent(ctx, rooms.EventRoomLive, event, livemetaKey, string(data))
 	if err != nil {
+		if tenant != "" {
+			_, _ = rs.etcdClient.Delete(ctx, rs.tenantOnAirPrefix(tenant)+roomID)
+		}
 		return fmt.Errorf("failed to store livemeta: %w", err)
 	}
 
@@ -172,7 +439,17 @@ func (rs *roomStoreImpl) StopLiveMeta(ctx context.Context, roomID string) error
 		return fmt.Errorf("failed to marshal livemeta: %w", err)
 	}
 
-	_, err = rs.etcdClient.Put(ctx, livemetaKey, string(data))
+	tenant, err := rs.roomTenant(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	var ops []clientv3.Op
+	if tenant != "" {
+		ops = append(ops, clientv3.OpDelete(rs.tenantOnAirPrefix(tenant)+roomID))
+	}
+
+	event := &rooms.RoomEvent{RoomID: roomID, LiveMeta: &livemeta}
+	err = rs.putWithEvent(ctx, rooms.EventRoomStopped, event, livemetaKey, string(data), ops...)
 	if err != nil {
 		return fmt.Errorf("failed to store livemeta: %w", err)
 	}
@@ -183,6 +460,87 @@ func (rs *roomStoreImpl) StopLiveMeta(ctx context.Context, roomID string) error
 	return nil
 }
 
+// ReassignLiveMeta moves the live of the room onto other modules with a compare-and-swap on the
+// livemeta. The nonce and start of the live are kept, HLS keys and timestamps carry on over the
+// move. It reports false when the room is no longer on air
+func (rs *roomStoreImpl) ReassignLiveMeta(ctx context.Context, roomID, mixerID, janusID string) (bool, error) {
+	livemetaKey := rs.livemetaKey(roomID)
+
+	for range maxMetaTxnAttempts {
+		resp, err := rs.etcdClient.Get(ctx, livemetaKey)
+		if err != nil {
+			return false, fmt.Errorf("failed to get livemeta: %w", err)
+		}
+		if len(resp.Kvs) == 0 {
+			return false, nil
+		}
+
+		var livemeta etcdstate.LiveMeta
+		if err := json.Unmarshal(resp.Kvs[0].Value, &livemeta); err != nil {
+			return false, fmt.Errorf("failed to unmarshal livemeta: %w", err)
+		}
+		if livemeta.Status != constants.RoomStatusOnAir {
+			return false, nil
+		}
+		livemeta.MixerID = mixerID
+		livemeta.JanusID = janusID
+
+		data, err := json.Marshal(&livemeta)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal livemeta: %w", err)
+		}
+
+		txnResp, err := rs.etcdClient.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(livemetaKey), "=", resp.Kvs[0].ModRevision)).
+			Then(clientv3.OpPut(livemetaKey, string(data))).
+			Commit()
+		if err != nil {
+			return false, fmt.Errorf("failed to reassign livemeta: %w", err)
+		}
+		if txnResp.Succeeded {
+			rs.logger.Info("Reassigned livemeta for room",
+				log.String("roomId", roomID),
+				log.String("mixerId", mixerID),
+				log.String("janusId", janusID))
+			return true, nil
+		}
+	}
+	return false, &rooms.RoomUpdateConflictError{RoomID: roomID}
+}
+
+// putWithEvent writes key and ops and the room event atomically through the outbox,
+// or only the key and ops when room events are not published
+func (rs *roomStoreImpl) putWithEvent(
+	ctx context.Context,
+	method string,
+	event *rooms.RoomEvent,
+	key, value string,
+	ops ...clientv3.Op,
+) error {
+	ops = append([]clientv3.Op{clientv3.OpPut(key, value)}, ops...)
+	if rs.outbox != nil {
+		return rs.outbox.Commit(ctx, method, event, ops...)
+	}
+	if len(ops) == 1 {
+		_, err := rs.etcdClient.Put(ctx, key, value)
+		return err
+	}
+	_, err := rs.etcdClient.Txn(ctx).Then(ops...).Commit()
+	return err
+}
+
+// roomTenant returns the tenant of a room counted against its quota, empty when none
+func (rs *roomStoreImpl) roomTenant(ctx context.Context, roomID string) (string, error) {
+	if rs.prefixTenants == "" {
+		return "", nil
+	}
+	meta, err := rs.GetRoom(ctx, roomID)
+	if err != nil {
+		return "", err
+	}
+	return meta.GetTenant(), nil
+}
+
 func (rs *roomStoreImpl) GetAllRooms(ctx context.Context) (map[string]*etcdstate.Meta, error) {
 	resp, err := rs.etcdClient.Get(ctx, rs.prefix, clientv3.WithPrefix())
 	if err != nil {
@@ -244,12 +602,232 @@ func (rs *roomStoreImpl) GetMixerData(ctx context.Context, roomID string) (*etcd
 	return &mixerData, nil
 }
 
-func (rs *roomStoreImpl) moduleMarkKey(moduleType, moduleID string) string {
-	return fmt.Sprintf("%s%s/%s", moduleType, moduleID, constants.ModuleKeyMark)
+func (rs *roomStoreImpl) GetRoomState(ctx context.Context, roomID string) (*etcdstate.RoomState, error) {
+	roomPrefix := fmt.Sprintf("%s%s/", rs.prefix, roomID)
+	resp, err := rs.etcdClient.Get(ctx, roomPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room state: %w", err)
+	}
+
+	var state *etcdstate.RoomState
+	for _, kv := range resp.Kvs {
+		keyType := strings.TrimPrefix(string(kv.Key), roomPrefix)
+		if state, err = roomstate.ApplyKey(keyType, kv.Value, state); err != nil {
+			return nil, fmt.Errorf("failed to decode room %s: %w", keyType, err)
+		}
+	}
+	return state, nil
+}
+
+// GetLatency gets the publish to HLS segment latency reported by the mixer of the room
+func (rs *roomStoreImpl) GetLatency(ctx context.Context, roomID string) (*etcdstate.Latency, error) {
+	resp, err := rs.etcdClient.Get(ctx, rs.latencyKey(roomID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latency data: %w", err)
+	}
+
+	if len(resp.Kvs) == 0 {
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	var latency etcdstate.Latency
+	if err := json.Unmarshal(resp.Kvs[0].Value, &latency); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal latency data: %w", err)
+	}
+
+	return &latency, nil
+}
+
+// GetTimeline gets the event timeline of the room, see timeline.Writer
+func (rs *roomStoreImpl) GetTimeline(ctx context.Context, roomID string) (*etcdstate.Timeline, error) {
+	resp, err := rs.etcdClient.Get(ctx, timeline.Key(rs.prefix, roomID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timeline: %w", err)
+	}
+
+	if len(resp.Kvs) == 0 {
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	var tl etcdstate.Timeline
+	if err := json.Unmarshal(resp.Kvs[0].Value, &tl); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal timeline: %w", err)
+	}
+
+	return &tl, nil
+}
+
+// CreateLink stores the link under the target room and indexes it under the source room,
+// returns false if the target room is already linked
+func (rs *roomStoreImpl) CreateLink(ctx context.Context, targetRoomID string, link *etcdstate.Link) (bool, error) {
+	linkKey := rs.linkKey(targetRoomID)
+
+	link.CreatedAt = time.Now().UTC()
+	data, err := json.Marshal(link)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal link: %w", err)
+	}
+
+	for range maxLinkTxnAttempts {
+		linkedBy, rev, err := rs.getLinkedBy(ctx, link.SourceRoomID)
+		if err != nil {
+			return false, err
+		}
+		linkedBy.Targets[targetRoomID] = link.AnchorID
+		indexOp, err := rs.linkedByOp(link.SourceRoomID, linkedBy)
+		if err != nil {
+			return false, err
+		}
+
+		resp, err := rs.etcdClient.Txn(ctx).
+			If(
+				clientv3.Compare(clientv3.CreateRevision(linkKey), "=", 0),
+				clientv3.Compare(clientv3.ModRevision(rs.linkedByKey(link.SourceRoomID)), "=", rev),
+			).
+			Then(clientv3.OpPut(linkKey, string(data)), indexOp).
+			Else(clientv3.OpGet(linkKey)).
+			Commit()
+		if err != nil {
+			return false, fmt.Errorf("failed to store link: %w", err)
+		}
+		if resp.Succeeded {
+			rs.logger.Info("Created room link",
+				log.String("sourceRoomId", link.SourceRoomID),
+				log.String("targetRoomId", targetRoomID),
+				log.String("anchorId", link.AnchorID))
+			return true, nil
+		}
+		if len(resp.Responses) > 0 && len(resp.Responses[0].GetResponseRange().GetKvs()) > 0 {
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("failed to store link: %w", errLinkConflict)
+}
+
+func (rs *roomStoreImpl) GetLink(ctx context.Context, targetRoomID string) (*etcdstate.Link, error) {
+	link, _, err := rs.getLink(ctx, targetRoomID)
+	return link, err
+}
+
+// getLink reads the link of the target room with its mod revision, 0 when not linked
+func (rs *roomStoreImpl) getLink(ctx context.Context, targetRoomID string) (*etcdstate.Link, int64, error) {
+	resp, err := rs.etcdClient.Get(ctx, rs.linkKey(targetRoomID))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get link: %w", err)
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, 0, nil
+	}
+
+	var link etcdstate.Link
+	if err := json.Unmarshal(resp.Kvs[0].Value, &link); err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal link: %w", err)
+	}
+
+	return &link, resp.Kvs[0].ModRevision, nil
+}
+
+// DeleteLink deletes the link of the target room and its entry in the source room index
+func (rs *roomStoreImpl) DeleteLink(ctx context.Context, targetRoomID string) error {
+	linkKey := rs.linkKey(targetRoomID)
+
+	for range maxLinkTxnAttempts {
+		link, linkRev, err := rs.getLink(ctx, targetRoomID)
+		if err != nil {
+			return err
+		}
+		if link == nil {
+			return nil
+		}
+
+		linkedBy, rev, err := rs.getLinkedBy(ctx, link.SourceRoomID)
+		if err != nil {
+			return err
+		}
+		delete(linkedBy.Targets, targetRoomID)
+		indexOp, err := rs.linkedByOp(link.SourceRoomID, linkedBy)
+		if err != nil {
+			return err
+		}
+
+		resp, err := rs.etcdClient.Txn(ctx).
+			If(
+				clientv3.Compare(clientv3.ModRevision(linkKey), "=", linkRev),
+				clientv3.Compare(clientv3.ModRevision(rs.linkedByKey(link.SourceRoomID)), "=", rev),
+			).
+			Then(clientv3.OpDelete(linkKey), indexOp).
+			Commit()
+		if err != nil {
+			return fmt.Errorf("failed to delete link: %w", err)
+		}
+		if resp.Succeeded {
+			rs.logger.Info("Deleted room link", log.String("targetRoomId", targetRoomID))
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to delete link: %w", errLinkConflict)
+}
+
+// getLinkedBy reads the index of the links from the source room with its mod revision, 0 when absent
+func (rs *roomStoreImpl) getLinkedBy(ctx context.Context, sourceRoomID string) (*etcdstate.LinkedBy, int64, error) {
+	resp, err := rs.etcdClient.Get(ctx, rs.linkedByKey(sourceRoomID))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get link index: %w", err)
+	}
+
+	linkedBy := &etcdstate.LinkedBy{}
+	var rev int64
+	if len(resp.Kvs) > 0 {
+		if err := json.Unmarshal(resp.Kvs[0].Value, linkedBy); err != nil {
+			return nil, 0, fmt.Errorf("failed to unmarshal link index: %w", err)
+		}
+		rev = resp.Kvs[0].ModRevision
+	}
+	if linkedBy.Targets == nil {
+		linkedBy.Targets = map[string]string{}
+	}
+	return linkedBy, rev, nil
+}
+
+// linkedByOp writes the index of the links from the source room, deleting it once no link is left
+func (rs *roomStoreImpl) linkedByOp(sourceRoomID string, linkedBy *etcdstate.LinkedBy) (clientv3.Op, error) {
+	key := rs.linkedByKey(sourceRoomID)
+	if len(linkedBy.Targets) == 0 {
+		return clientv3.OpDelete(key), nil
+	}
+	data, err := json.Marshal(linkedBy)
+	if err != nil {
+		return clientv3.Op{}, fmt.Errorf("failed to marshal link index: %w", err)
+	}
+	return clientv3.OpPut(key, string(data)), nil
+}
+
+func (rs *roomStoreImpl) modulePrefix(moduleType string) (string, error) {
+	switch moduleType {
+	case rooms.ModuleTypeJanuses:
+		return rs.prefixJanus, nil
+	case rooms.ModuleTypeMixers:
+		return rs.prefixMixer, nil
+	}
+	return "", fmt.Errorf("unknown module type %s", moduleType)
+}
+
+func (rs *roomStoreImpl) moduleMarkKey(moduleType, moduleID string) (string, error) {
+	prefix, err := rs.modulePrefix(moduleType)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s/%s", prefix, moduleID, constants.ModuleKeyMark), nil
 }
 
 func (rs *roomStoreImpl) SetModuleMark(ctx context.Context, moduleType, moduleID string, label constants.MarkLabel, ttlSeconds int64) error {
-	markKey := rs.moduleMarkKey(moduleType, moduleID)
+	markKey, err := rs.moduleMarkKey(moduleType, moduleID)
+	if err != nil {
+		return err
+	}
 	rs.logger.Info("Setting module mark",
 		log.String("moduleType", moduleType),
 		log.String("moduleID", moduleID),
@@ -283,6 +861,7 @@ func (rs *roomStoreImpl) SetModuleMark(ctx context.Context, moduleType, moduleID
 	if err != nil {
 		return fmt.Errorf("failed to set module mark: %w", err)
 	}
+	rs.moduleStatus.Remove(moduleType)
 
 	rs.logger.Info("Set module mark successfully",
 		log.String("moduleType", moduleType),
@@ -292,18 +871,166 @@ func (rs *roomStoreImpl) SetModuleMark(ctx context.Context, moduleType, moduleID
 }
 
 func (rs *roomStoreImpl) DeleteModuleMark(ctx context.Context, moduleType, moduleID string) error {
-	markKey := rs.moduleMarkKey(moduleType, moduleID)
+	markKey, err := rs.moduleMarkKey(moduleType, moduleID)
+	if err != nil {
+		return err
+	}
 	rs.logger.Info("Deleting module mark",
 		log.String("moduleType", moduleType),
 		log.String("moduleID", moduleID))
 
-	_, err := rs.etcdClient.Delete(ctx, markKey)
+	_, err = rs.etcdClient.Delete(ctx, markKey)
 	if err != nil {
 		return fmt.Errorf("failed to delete module mark: %w", err)
 	}
+	rs.moduleStatus.Remove(moduleType)
 
 	rs.logger.Info("Deleted module mark successfully",
 		log.String("moduleType", moduleType),
 		log.String("moduleID", moduleID))
 	return nil
 }
+
+func (rs *roomStoreImpl) SetModuleDegraded(
+	ctx context.Context,
+	moduleType, moduleID string,
+	degraded *etcdstate.DegradedData,
+	ttlSeconds int64,
+) error {
+	prefix, err := rs.modulePrefix(moduleType)
+	if err != nil {
+		return err
+	}
+	degradedKey := fmt.Sprintf("%s%s/%s", prefix, moduleID, constants.ModuleKeyDegraded)
+
+	data, err := json.Marshal(degraded)
+	if err != nil {
+		return fmt.Errorf("failed to marshal degraded data: %w", err)
+	}
+
+	// reports always expire, the reporter renews them while the module keeps failing
+	lease, err := rs.etcdClient.Grant(ctx, ttlSeconds)
+	if err != nil {
+		return fmt.Errorf("failed to create lease: %w", err)
+	}
+	if _, err := rs.etcdClient.Put(ctx, degradedKey, string(data), clientv3.WithLease(lease.ID)); err != nil {
+		return fmt.Errorf("failed to set module degraded: %w", err)
+	}
+	rs.moduleStatus.Remove(moduleType)
+
+	rs.logger.Info("Module reported degraded",
+		log.String("moduleType", moduleType),
+		log.String("moduleID", moduleID),
+		log.String("reason", degraded.Reason),
+		log.String("reporter", degraded.Reporter),
+		log.Int64("ttl", ttlSeconds))
+	return nil
+}
+
+// ListModuleStatus reads heartbeats, marks and room assignments of a module type in a single txn,
+// so the result is consistent at one revision unlike the separate watchers in ResourceManager.
+// Results are reused for moduleStatusTTL, or until a mark of the module type changes.
+func (rs *roomStoreImpl) ListModuleStatus(ctx context.Context, moduleType string) ([]*rooms.ModuleStatus, error) {
+	modulePrefix, err := rs.modulePrefix(moduleType)
+	if err != nil {
+		return nil, err
+	}
+	if result, ok := rs.moduleStatus.Get(moduleType); ok {
+		return result, nil
+	}
+
+	resp, err := rs.etcdClient.Txn(ctx).Then(
+		clientv3.OpGet(modulePrefix, clientv3.WithPrefix()),
+		clientv3.OpGet(rs.prefix, clientv3.WithPrefix()),
+	).Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module status: %w", err)
+	}
+
+	states := make(map[string]*etcdstate.ModuleState)
+	for _, kv := range resp.Responses[0].GetResponseRange().GetKvs() {
+		key := string(kv.Key)
+		moduleID, field, ok := strings.Cut(strings.TrimPrefix(key, modulePrefix), "/")
+		if !ok {
+			continue
+		}
+
+		state, ok := states[moduleID]
+		if !ok {
+			state = &etcdstate.ModuleState{}
+			states[moduleID] = state
+		}
+
+		switch field {
+		case constants.ModuleKeyHeartbeat:
+			var hb etcdstate.HeartbeatData
+			if err := json.Unmarshal(kv.Value, &hb); err != nil {
+				rs.logger.Error("Failed to unmarshal heartbeat", log.String("key", key), log.Error(err))
+				continue
+			}
+			state.SetHeartbeat(&hb)
+		case constants.ModuleKeyMark:
+			var mark etcdstate.MarkData
+			if err := json.Unmarshal(kv.Value, &mark); err != nil {
+				rs.logger.Error("Failed to unmarshal mark", log.String("key", key), log.Error(err))
+				continue
+			}
+			state.SetMark(&mark)
+		case constants.ModuleKeyDegraded:
+			var degraded etcdstate.DegradedData
+			if err := json.Unmarshal(kv.Value, &degraded); err != nil {
+				rs.logger.Error("Failed to unmarshal degraded", log.String("key", key), log.Error(err))
+				continue
+			}
+			state.SetDegraded(&degraded)
+		}
+	}
+
+	// count rooms the same way as the room watcher, by livemeta assignment
+	assigned := make(map[string]int)
+	for _, kv := range resp.Responses[1].GetResponseRange().GetKvs() {
+		key := string(kv.Key)
+		if !strings.HasSuffix(key, "/"+constants.RoomKeyLiveMeta) {
+			continue
+		}
+
+		var livemeta etcdstate.LiveMeta
+		if err := json.Unmarshal(kv.Value, &livemeta); err != nil {
+			rs.logger.Error("Failed to unmarshal livemeta", log.String("key", key), log.Error(err))
+			continue
+		}
+
+		moduleID := livemeta.GetMixerID()
+		if moduleType == rooms.ModuleTypeJanuses {
+			moduleID = livemeta.GetJanusID()
+		}
+		if moduleID == "" {
+			continue
+		}
+		assigned[moduleID]++
+		// keep modules with rooms but no heartbeat (e.g. crashed) visible
+		if _, ok := states[moduleID]; !ok {
+			states[moduleID] = &etcdstate.ModuleState{}
+		}
+	}
+
+	result := make([]*rooms.ModuleStatus, 0, len(states))
+	for moduleID, state := range states {
+		result = append(result, &rooms.ModuleStatus{
+			ModuleID:      moduleID,
+			Heartbeat:     state.GetHeartbeat(),
+			Mark:          state.GetMark(),
+			Degraded:      state.GetDegraded(),
+			Capacity:      state.GetHeartbeat().GetCapacity(),
+			AssignedRooms: assigned[moduleID],
+			Healthy:       state.IsHealthy(),
+			Pickable:      state.IsPickable(),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ModuleID < result[j].ModuleID
+	})
+
+	rs.moduleStatus.Add(moduleType, result)
+	return result, nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/suite"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
 	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/mock/gomock"
@@ -15,7 +16,9 @@ import (
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	etcdmocks "github.com/imtaco/audio-rtc-exp/internal/etcd/mocks"
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate/fixtures"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	obmocks "github.com/imtaco/audio-rtc-exp/internal/outbox/mocks"
 	"github.com/imtaco/audio-rtc-exp/rooms"
 )
 
@@ -23,6 +26,7 @@ type RoomStoreTestSuite struct {
 	suite.Suite
 	ctrl           *gomock.Controller
 	mockEtcdClient *etcdmocks.MockClient
+	mockOutbox     *obmocks.MockWriter
 	store          rooms.RoomStore
 	ctx            context.Context
 	cancel         context.CancelFunc
@@ -36,7 +40,8 @@ func (s *RoomStoreTestSuite) SetupTest() {
 	s.ctrl = gomock.NewController(s.T())
 	s.mockEtcdClient = etcdmocks.NewMockClient(s.ctrl)
 	logger := log.NewTest(s.T())
-	s.store = NewRoomStore(s.mockEtcdClient, "/rooms/", logger)
+	s.mockOutbox = obmocks.NewMockWriter(s.ctrl)
+	s.store = NewRoomStore(s.mockEtcdClient, s.mockOutbox, "/rooms/", "/januses/", "/mixers/", "/externalids/", "", "/roomtags/", logger)
 	s.ctx, s.cancel = context.WithCancel(context.Background())
 }
 
@@ -79,6 +84,93 @@ func (s *RoomStoreTestSuite) TestCreateRoom_Success() {
 	s.NotEmpty(result.CreatedAt)
 }
 
+func (s *RoomStoreTestSuite) TestCreateRoom_ExternalID() {
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/rooms/room-123/meta").
+		Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{}}, nil)
+	txn := &fakeTxn{}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	result, err := s.store.CreateRoom(s.ctx, "room-123", &etcdstate.Meta{ExternalID: "cms-42"})
+	s.Require().NoError(err)
+	s.Equal("cms-42", result.ExternalID)
+
+	s.Len(txn.cmps, 2)
+	s.Require().Len(txn.ops, 2)
+	s.Equal("/rooms/room-123/meta", string(txn.ops[0].KeyBytes()))
+	s.Equal("/externalids/cms-42", string(txn.ops[1].KeyBytes()))
+	s.Equal("room-123", string(txn.ops[1].ValueBytes()))
+}
+
+func (s *RoomStoreTestSuite) TestCreateRoom_ExternalIDTaken() {
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/rooms/room-123/meta").
+		Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{}}, nil)
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{
+		failed: true,
+		ranges: [][]*mvccpb.KeyValue{{{Key: []byte("/externalids/cms-42"), Value: []byte("room-9")}}},
+	})
+
+	result, err := s.store.CreateRoom(s.ctx, "room-123", &etcdstate.Meta{ExternalID: "cms-42"})
+	s.Nil(result)
+	var existsErr *rooms.ExternalIDExistsError
+	s.Require().ErrorAs(err, &existsErr)
+	s.Equal("cms-42", existsErr.ExternalID)
+}
+
+func (s *RoomStoreTestSuite) TestCreateRoom_Tags() {
+	s.expectGet("/rooms/room-123/meta", nil, 0)
+	txn := &fakeTxn{}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	_, err := s.store.CreateRoom(s.ctx, "room-123", &etcdstate.Meta{
+		Tags: map[string]string{"show": "morning", "env": "prod"},
+	})
+	s.Require().NoError(err)
+
+	s.Len(txn.cmps, 1)
+	s.Require().Len(txn.ops, 3)
+	s.Equal("/roomtags/env=prod/room-123", string(txn.ops[1].KeyBytes()))
+	s.Equal("/roomtags/show=morning/room-123", string(txn.ops[2].KeyBytes()))
+}
+
+func (s *RoomStoreTestSuite) TestListRoomsByTag() {
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/roomtags/show=morning/", gomock.Any(), gomock.Any()).
+		Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{
+			{Key: []byte("/roomtags/show=morning/room-1")},
+			{Key: []byte("/roomtags/show=morning/room-2")},
+		}}, nil)
+
+	roomIDs, err := s.store.ListRoomsByTag(s.ctx, "show", "morning")
+	s.Require().NoError(err)
+	s.Equal([]string{"room-1", "room-2"}, roomIDs)
+}
+
+func (s *RoomStoreTestSuite) TestListRoomsByTag_Disabled() {
+	store := NewRoomStore(s.mockEtcdClient, nil, "/rooms/", "/januses/", "/mixers/", "/externalids/", "", "", log.NewNop())
+
+	_, err := store.ListRoomsByTag(s.ctx, "show", "morning")
+	s.ErrorIs(err, errTagsDisabled)
+}
+
+func (s *RoomStoreTestSuite) TestResolveExternalID() {
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/externalids/cms-42").
+		Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{Value: []byte("room-123")}}}, nil)
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/externalids/cms-43").
+		Return(&clientv3.GetResponse{}, nil)
+
+	roomID, err := s.store.ResolveExternalID(s.ctx, "cms-42")
+	s.Require().NoError(err)
+	s.Equal("room-123", roomID)
+
+	roomID, err = s.store.ResolveExternalID(s.ctx, "cms-43")
+	s.Require().NoError(err)
+	s.Empty(roomID)
+}
+
 func (s *RoomStoreTestSuite) TestCreateRoom_AlreadyExists() {
 	roomData := &etcdstate.Meta{
 		Pin:     "1234",
@@ -229,20 +321,185 @@ func (s *RoomStoreTestSuite) TestExists_Error() {
 
 // DeleteRoom Tests
 
+// expectGet answers a single key read, v nil for a missing key
+func (s *RoomStoreTestSuite) expectGet(key string, v any, modRev int64) {
+	resp := &clientv3.GetResponse{}
+	if v != nil {
+		kv := fixtures.KV(key, v)
+		kv.ModRevision = modRev
+		resp.Kvs = []*mvccpb.KeyValue{kv}
+	}
+	s.mockEtcdClient.EXPECT().Get(gomock.Any(), key).Return(resp, nil)
+}
+
+func (s *RoomStoreTestSuite) TestUpdateRoom_Success() {
+	s.expectGet("/rooms/room-123/meta", &etcdstate.Meta{Pin: "123456", MaxAnchors: 3}, 7)
+	txn := &fakeTxn{}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	meta, err := s.store.UpdateRoom(s.ctx, "room-123", func(meta *etcdstate.Meta) error {
+		meta.MaxAnchors = 5
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal(5, meta.MaxAnchors)
+	s.Equal("123456", meta.Pin)
+
+	s.Require().Len(txn.cmps, 1)
+	s.Equal(int64(7), txn.cmps[0].TargetUnion.(*etcdserverpb.Compare_ModRevision).ModRevision)
+	s.Require().Len(txn.ops, 1)
+	s.Equal("/rooms/room-123/meta", string(txn.ops[0].KeyBytes()))
+	var stored etcdstate.Meta
+	s.Require().NoError(json.Unmarshal(txn.ops[0].ValueBytes(), &stored))
+	s.Equal(5, stored.MaxAnchors)
+}
+
+func (s *RoomStoreTestSuite) TestUpdateRoom_Tags() {
+	s.expectGet("/rooms/room-123/meta", &etcdstate.Meta{
+		Tags: map[string]string{"show": "morning", "env": "prod", "host": "amy"},
+	}, 7)
+	txn := &fakeTxn{}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	_, err := s.store.UpdateRoom(s.ctx, "room-123", func(meta *etcdstate.Meta) error {
+		meta.Tags = map[string]string{"show": "evening", "env": "prod"}
+		return nil
+	})
+	s.Require().NoError(err)
+
+	// unchanged tags keep their index
+	s.Require().Len(txn.ops, 4)
+	s.True(txn.ops[1].IsDelete())
+	s.Equal("/roomtags/host=amy/room-123", string(txn.ops[1].KeyBytes()))
+	s.True(txn.ops[2].IsDelete())
+	s.Equal("/roomtags/show=morning/room-123", string(txn.ops[2].KeyBytes()))
+	s.True(txn.ops[3].IsPut())
+	s.Equal("/roomtags/show=evening/room-123", string(txn.ops[3].KeyBytes()))
+}
+
+func (s *RoomStoreTestSuite) TestUpdateRoom_RetriesOnConflict() {
+	s.expectGet("/rooms/room-123/meta", &etcdstate.Meta{MaxAnchors: 3}, 7)
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{failed: true})
+	s.expectGet("/rooms/room-123/meta", &etcdstate.Meta{MaxAnchors: 4}, 8)
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{})
+
+	var seen []int
+	meta, err := s.store.UpdateRoom(s.ctx, "room-123", func(meta *etcdstate.Meta) error {
+		seen = append(seen, meta.MaxAnchors)
+		meta.Recording = true
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]int{3, 4}, seen)
+	s.Equal(4, meta.MaxAnchors)
+	s.True(meta.Recording)
+}
+
+func (s *RoomStoreTestSuite) TestUpdateRoom_ConflictExhausted() {
+	for range maxMetaTxnAttempts {
+		s.expectGet("/rooms/room-123/meta", &etcdstate.Meta{}, 7)
+		s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{failed: true})
+	}
+
+	_, err := s.store.UpdateRoom(s.ctx, "room-123", func(*etcdstate.Meta) error { return nil })
+	var conflictErr *rooms.RoomUpdateConflictError
+	s.ErrorAs(err, &conflictErr)
+}
+
+func (s *RoomStoreTestSuite) TestUpdateRoom_NotFound() {
+	s.expectGet("/rooms/room-123/meta", nil, 0)
+
+	meta, err := s.store.UpdateRoom(s.ctx, "room-123", func(*etcdstate.Meta) error { return nil })
+	s.Require().NoError(err)
+	s.Nil(meta)
+}
+
 func (s *RoomStoreTestSuite) TestDeleteRoom_Success() {
-	s.mockEtcdClient.EXPECT().
-		Delete(gomock.Any(), "/rooms/room-123/", gomock.Any()).
-		Return(&clientv3.DeleteResponse{Deleted: 3}, nil)
+	s.expectGet("/rooms/room-123/meta", nil, 0)
+	s.expectGet("/rooms/room-123/link", nil, 0)
+	s.expectGet("/rooms/room-123/linkedby", nil, 0)
+	txn := &fakeTxn{deletes: []int64{3}}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	deleted, err := s.store.DeleteRoom(s.ctx, "room-123")
+	s.Require().NoError(err)
+	s.True(deleted)
+
+	s.Require().Len(txn.ops, 1)
+	s.True(txn.ops[0].IsDelete())
+	s.Equal("/rooms/room-123/", string(txn.ops[0].KeyBytes()))
+}
+
+func (s *RoomStoreTestSuite) TestDeleteRoom_CleansUpLinks() {
+	// room-123 is linked from room-src and forwarded into room-a and room-b
+	s.expectGet("/rooms/room-123/meta", &etcdstate.Meta{}, 9)
+	s.expectGet("/rooms/room-123/link", &etcdstate.Link{SourceRoomID: "room-src"}, 10)
+	s.expectGet("/rooms/room-123/linkedby", &etcdstate.LinkedBy{
+		Targets: map[string]string{"room-b": "", "room-a": "anchor-1"},
+	}, 11)
+	s.expectGet("/rooms/room-src/linkedby", &etcdstate.LinkedBy{
+		Targets: map[string]string{"room-123": "", "room-c": ""},
+	}, 12)
+	txn := &fakeTxn{deletes: []int64{4}}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	deleted, err := s.store.DeleteRoom(s.ctx, "room-123")
+	s.Require().NoError(err)
+	s.True(deleted)
+
+	s.Require().Len(txn.ops, 4)
+	s.Equal("/rooms/room-123/", string(txn.ops[0].KeyBytes()))
+	s.True(txn.ops[1].IsDelete())
+	s.Equal("/rooms/room-a/link", string(txn.ops[1].KeyBytes()))
+	s.True(txn.ops[2].IsDelete())
+	s.Equal("/rooms/room-b/link", string(txn.ops[2].KeyBytes()))
+	s.True(txn.ops[3].IsPut())
+	s.Equal("/rooms/room-src/linkedby", string(txn.ops[3].KeyBytes()))
+	s.JSONEq(`{"targets":{"room-c":""}}`, string(txn.ops[3].ValueBytes()))
+	s.Len(txn.cmps, 3)
+}
+
+func (s *RoomStoreTestSuite) TestDeleteRoom_RemovesExternalIDIndex() {
+	s.expectGet("/rooms/room-123/meta", &etcdstate.Meta{ExternalID: "cms-42"}, 9)
+	s.expectGet("/rooms/room-123/link", nil, 0)
+	s.expectGet("/rooms/room-123/linkedby", nil, 0)
+	txn := &fakeTxn{deletes: []int64{1}}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	deleted, err := s.store.DeleteRoom(s.ctx, "room-123")
+	s.Require().NoError(err)
+	s.True(deleted)
+
+	// the index is only deleted while it still points to the room
+	s.Require().Len(txn.ops, 2)
+	s.True(txn.ops[1].IsTxn())
+	cmps, thenOps, _ := txn.ops[1].Txn()
+	s.Require().Len(cmps, 1)
+	s.Equal("/externalids/cms-42", string(cmps[0].Key))
+	s.Equal("/externalids/cms-42", string(thenOps[0].KeyBytes()))
+}
+
+func (s *RoomStoreTestSuite) TestDeleteRoom_RemovesTagIndexes() {
+	s.expectGet("/rooms/room-123/meta", &etcdstate.Meta{Tags: map[string]string{"show": "morning"}}, 9)
+	s.expectGet("/rooms/room-123/link", nil, 0)
+	s.expectGet("/rooms/room-123/linkedby", nil, 0)
+	txn := &fakeTxn{deletes: []int64{1}}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
 
 	deleted, err := s.store.DeleteRoom(s.ctx, "room-123")
 	s.Require().NoError(err)
 	s.True(deleted)
+
+	s.Require().Len(txn.ops, 2)
+	s.True(txn.ops[1].IsDelete())
+	s.Equal("/roomtags/show=morning/room-123", string(txn.ops[1].KeyBytes()))
 }
 
 func (s *RoomStoreTestSuite) TestDeleteRoom_NotFound() {
-	s.mockEtcdClient.EXPECT().
-		Delete(gomock.Any(), "/rooms/room-123/", gomock.Any()).
-		Return(&clientv3.DeleteResponse{Deleted: 0}, nil)
+	s.expectGet("/rooms/room-123/meta", nil, 0)
+	s.expectGet("/rooms/room-123/link", nil, 0)
+	s.expectGet("/rooms/room-123/linkedby", nil, 0)
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{deletes: []int64{0}})
 
 	deleted, err := s.store.DeleteRoom(s.ctx, "room-123")
 	s.Require().NoError(err)
@@ -250,9 +507,10 @@ func (s *RoomStoreTestSuite) TestDeleteRoom_NotFound() {
 }
 
 func (s *RoomStoreTestSuite) TestDeleteRoom_Error() {
-	s.mockEtcdClient.EXPECT().
-		Delete(gomock.Any(), "/rooms/room-123/", gomock.Any()).
-		Return(nil, errors.New("etcd error"))
+	s.expectGet("/rooms/room-123/meta", nil, 0)
+	s.expectGet("/rooms/room-123/link", nil, 0)
+	s.expectGet("/rooms/room-123/linkedby", nil, 0)
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{err: errors.New("etcd error")})
 
 	deleted, err := s.store.DeleteRoom(s.ctx, "room-123")
 	s.Require().Error(err)
@@ -263,11 +521,15 @@ func (s *RoomStoreTestSuite) TestDeleteRoom_Error() {
 // CreateLiveMeta Tests
 
 func (s *RoomStoreTestSuite) TestCreateLiveMeta_Success() {
-	s.mockEtcdClient.EXPECT().
-		Put(gomock.Any(), "/rooms/room-123/livemeta", gomock.Any()).
-		DoAndReturn(func(_ context.Context, _, val string, _ ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	s.mockOutbox.EXPECT().
+		Commit(gomock.Any(), rooms.EventRoomLive, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, params any, ops ...clientv3.Op) error {
+			s.Require().Len(ops, 1)
+			s.True(ops[0].IsPut())
+			s.Equal("/rooms/room-123/livemeta", string(ops[0].KeyBytes()))
+
 			var livemeta rooms.LiveMeta
-			err := json.Unmarshal([]byte(val), &livemeta)
+			err := json.Unmarshal(ops[0].ValueBytes(), &livemeta)
 			s.Require().NoError(err)
 			s.Equal(constants.RoomStatusOnAir, livemeta.Status)
 			s.Equal("mixer-1", livemeta.MixerID)
@@ -275,7 +537,10 @@ func (s *RoomStoreTestSuite) TestCreateLiveMeta_Success() {
 			s.Equal("nonce-123", livemeta.Nonce)
 			s.NotEmpty(livemeta.CreatedAt)
 
-			return &clientv3.PutResponse{}, nil
+			event := params.(*rooms.RoomEvent)
+			s.Equal("room-123", event.RoomID)
+			s.Equal(livemeta.Nonce, event.LiveMeta.Nonce)
+			return nil
 		})
 
 	err := s.store.CreateLiveMeta(s.ctx, "room-123", "mixer-1", "janus-1", "nonce-123")
@@ -283,28 +548,47 @@ func (s *RoomStoreTestSuite) TestCreateLiveMeta_Success() {
 }
 
 func (s *RoomStoreTestSuite) TestCreateLiveMeta_PutError() {
-	s.mockEtcdClient.EXPECT().
-		Put(gomock.Any(), "/rooms/room-123/livemeta", gomock.Any()).
-		Return(nil, errors.New("etcd error"))
+	s.mockOutbox.EXPECT().
+		Commit(gomock.Any(), rooms.EventRoomLive, gomock.Any(), gomock.Any()).
+		Return(errors.New("etcd error"))
 
 	err := s.store.CreateLiveMeta(s.ctx, "room-123", "mixer-1", "janus-1", "nonce-123")
 	s.Require().Error(err)
 	s.Contains(err.Error(), "failed to store livemeta")
 }
 
+func (s *RoomStoreTestSuite) TestCreateLiveMeta_WithoutEvents() {
+	store := NewRoomStore(s.mockEtcdClient, nil, "/rooms/", "/januses/", "/mixers/", "/externalids/", "", "", log.NewNop())
+	s.mockEtcdClient.EXPECT().
+		Put(gomock.Any(), "/rooms/room-123/livemeta", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, value string, _ ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+			var livemeta rooms.LiveMeta
+			s.Require().NoError(json.Unmarshal([]byte(value), &livemeta))
+			s.Equal(constants.RoomStatusOnAir, livemeta.Status)
+			return &clientv3.PutResponse{}, nil
+		})
+
+	err := store.CreateLiveMeta(s.ctx, "room-123", "mixer-1", "janus-1", "nonce-123")
+	s.Require().NoError(err)
+}
+
 // StopLiveMeta Tests
 
 func (s *RoomStoreTestSuite) TestStopLiveMeta_Success() {
-	s.mockEtcdClient.EXPECT().
-		Put(gomock.Any(), "/rooms/room-123/livemeta", gomock.Any()).
-		DoAndReturn(func(_ context.Context, _, val string, _ ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	s.mockOutbox.EXPECT().
+		Commit(gomock.Any(), rooms.EventRoomStopped, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, params any, ops ...clientv3.Op) error {
+			s.Require().Len(ops, 1)
+			s.Equal("/rooms/room-123/livemeta", string(ops[0].KeyBytes()))
+
 			var livemeta rooms.LiveMeta
-			err := json.Unmarshal([]byte(val), &livemeta)
+			err := json.Unmarshal(ops[0].ValueBytes(), &livemeta)
 			s.Require().NoError(err)
 			s.Equal(constants.RoomStatusRemoving, livemeta.Status)
 			s.NotEmpty(livemeta.DiscardAt)
 
-			return &clientv3.PutResponse{}, nil
+			s.Equal("room-123", params.(*rooms.RoomEvent).RoomID)
+			return nil
 		})
 
 	err := s.store.StopLiveMeta(s.ctx, "room-123")
@@ -312,14 +596,51 @@ func (s *RoomStoreTestSuite) TestStopLiveMeta_Success() {
 }
 
 func (s *RoomStoreTestSuite) TestStopRoom_CallsStopLiveMeta() {
-	s.mockEtcdClient.EXPECT().
-		Put(gomock.Any(), "/rooms/room-123/livemeta", gomock.Any()).
-		Return(&clientv3.PutResponse{}, nil)
+	s.mockOutbox.EXPECT().
+		Commit(gomock.Any(), rooms.EventRoomStopped, gomock.Any(), gomock.Any()).
+		Return(nil)
 
 	err := s.store.StopRoom(s.ctx, "room-123")
 	s.Require().NoError(err)
 }
 
+// ReassignLiveMeta Tests
+
+func (s *RoomStoreTestSuite) TestReassignLiveMeta_KeepsNonce() {
+	createdAt := time.Now().UTC().Add(-time.Hour)
+	s.expectGet("/rooms/room-123/livemeta", &etcdstate.LiveMeta{
+		Status:    constants.RoomStatusOnAir,
+		MixerID:   "mixer-1",
+		JanusID:   "janus-1",
+		Nonce:     "nonce-1",
+		CreatedAt: createdAt,
+	}, 7)
+	txn := &fakeTxn{}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	reassigned, err := s.store.ReassignLiveMeta(s.ctx, "room-123", "mixer-2", "janus-1")
+	s.Require().NoError(err)
+	s.True(reassigned)
+
+	s.Require().Len(txn.cmps, 1)
+	s.Equal(int64(7), txn.cmps[0].TargetUnion.(*etcdserverpb.Compare_ModRevision).ModRevision)
+	s.Require().Len(txn.ops, 1)
+	var stored etcdstate.LiveMeta
+	s.Require().NoError(json.Unmarshal(txn.ops[0].ValueBytes(), &stored))
+	s.Equal("mixer-2", stored.MixerID)
+	s.Equal("janus-1", stored.JanusID)
+	s.Equal("nonce-1", stored.Nonce)
+	s.True(createdAt.Equal(stored.CreatedAt))
+}
+
+func (s *RoomStoreTestSuite) TestReassignLiveMeta_NotOnAir() {
+	s.expectGet("/rooms/room-123/livemeta", &etcdstate.LiveMeta{Status: constants.RoomStatusRemoving}, 7)
+
+	reassigned, err := s.store.ReassignLiveMeta(s.ctx, "room-123", "mixer-2", "janus-2")
+	s.Require().NoError(err)
+	s.False(reassigned)
+}
+
 // GetAllRooms Tests
 
 func (s *RoomStoreTestSuite) TestGetAllRooms_Success() {
@@ -445,6 +766,32 @@ func (s *RoomStoreTestSuite) TestGetMixerData_Success() {
 	s.Equal(5000, mixerData.Port)
 }
 
+func (s *RoomStoreTestSuite) TestGetRoomState() {
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/rooms/room-123/", gomock.Any()).
+		Return(&clientv3.GetResponse{
+			Kvs: []*mvccpb.KeyValue{
+				{Key: []byte("/rooms/room-123/livemeta"), Value: []byte(`{"status":"onair","mixerId":"mixer1"}`)},
+				{Key: []byte("/rooms/room-123/mixer"), Value: []byte(`{"id":"mixer1","port":5000}`)},
+				{Key: []byte("/rooms/room-123/janus"), Value: []byte(`{"janusId":"janus1"}`)},
+			},
+		}, nil)
+
+	state, err := s.store.GetRoomState(s.ctx, "room-123")
+	s.Require().NoError(err)
+	s.Equal("mixer1", state.GetLiveMeta().GetMixerID())
+	s.Equal(5000, state.GetMixer().Port)
+	s.Equal("janus1", state.GetJanus().GetJanusID())
+	s.Nil(state.GetMeta())
+
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/rooms/room-456/", gomock.Any()).
+		Return(&clientv3.GetResponse{}, nil)
+	state, err = s.store.GetRoomState(s.ctx, "room-456")
+	s.Require().NoError(err)
+	s.Nil(state)
+}
+
 func (s *RoomStoreTestSuite) TestGetMixerData_NotFound() {
 	s.mockEtcdClient.EXPECT().
 		Get(gomock.Any(), "/rooms/room-123/mixer").
@@ -479,6 +826,151 @@ func (s *RoomStoreTestSuite) TestGetMixerData_UnmarshalError() {
 	s.Nil(mixerData)
 }
 
+func (s *RoomStoreTestSuite) TestGetLatency() {
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/rooms/room-123/latency").
+		Return(&clientv3.GetResponse{
+			Kvs: []*mvccpb.KeyValue{
+				{Key: []byte("/rooms/room-123/latency"), Value: []byte(`{"currentMs":4200,"avgMs":4100}`)},
+			},
+		}, nil)
+
+	latency, err := s.store.GetLatency(s.ctx, "room-123")
+	s.Require().NoError(err)
+	s.Equal(int64(4200), latency.GetCurrentMs())
+	s.Equal(int64(4100), latency.GetAvgMs())
+}
+
+func (s *RoomStoreTestSuite) TestGetLatency_NotReported() {
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/rooms/room-123/latency").
+		Return(&clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{}}, nil)
+
+	latency, err := s.store.GetLatency(s.ctx, "room-123")
+	s.Require().NoError(err)
+	s.Nil(latency)
+}
+
+func (s *RoomStoreTestSuite) TestGetTimeline() {
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/rooms/room-123/timeline").
+		Return(&clientv3.GetResponse{
+			Kvs: []*mvccpb.KeyValue{
+				{Key: []byte("/rooms/room-123/timeline"), Value: []byte(`{"events":[{"type":"created","source":"rooms"},{"type":"ffmpegRestarted","source":"mixer:mixer-1","detail":"exited"}]}`)},
+			},
+		}, nil)
+
+	tl, err := s.store.GetTimeline(s.ctx, "room-123")
+	s.Require().NoError(err)
+	s.Require().Len(tl.GetEvents(), 2)
+	s.Equal("ffmpegRestarted", tl.Events[1].Type)
+	s.Equal("exited", tl.Events[1].Detail)
+}
+
+// Link Tests
+
+func (s *RoomStoreTestSuite) TestCreateLink_Success() {
+	s.expectGet("/rooms/room-1/linkedby", &etcdstate.LinkedBy{Targets: map[string]string{"room-3": ""}}, 7)
+	txn := &fakeTxn{}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	link := &etcdstate.Link{SourceRoomID: "room-1", AnchorID: "anchor-1"}
+	created, err := s.store.CreateLink(s.ctx, "room-2", link)
+	s.Require().NoError(err)
+	s.True(created)
+	s.False(link.CreatedAt.IsZero())
+
+	s.Require().Len(txn.ops, 2)
+	s.Equal("/rooms/room-2/link", string(txn.ops[0].KeyBytes()))
+
+	var stored etcdstate.Link
+	s.Require().NoError(json.Unmarshal(txn.ops[0].ValueBytes(), &stored))
+	s.Equal("room-1", stored.SourceRoomID)
+	s.Equal("anchor-1", stored.AnchorID)
+
+	s.Equal("/rooms/room-1/linkedby", string(txn.ops[1].KeyBytes()))
+	s.JSONEq(`{"targets":{"room-2":"anchor-1","room-3":""}}`, string(txn.ops[1].ValueBytes()))
+}
+
+func (s *RoomStoreTestSuite) TestCreateLink_AlreadyLinked() {
+	s.expectGet("/rooms/room-1/linkedby", nil, 0)
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{
+		failed: true,
+		ranges: [][]*mvccpb.KeyValue{{fixtures.KV("/rooms/room-2/link", &etcdstate.Link{SourceRoomID: "room-9"})}},
+	})
+
+	created, err := s.store.CreateLink(s.ctx, "room-2", &etcdstate.Link{SourceRoomID: "room-1"})
+	s.Require().NoError(err)
+	s.False(created)
+}
+
+func (s *RoomStoreTestSuite) TestCreateLink_RetriesOnIndexConflict() {
+	s.expectGet("/rooms/room-1/linkedby", nil, 0)
+	s.expectGet("/rooms/room-1/linkedby", &etcdstate.LinkedBy{Targets: map[string]string{"room-3": ""}}, 8)
+	gomock.InOrder(
+		s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{failed: true, ranges: [][]*mvccpb.KeyValue{{}}}),
+		s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{}),
+	)
+
+	created, err := s.store.CreateLink(s.ctx, "room-2", &etcdstate.Link{SourceRoomID: "room-1"})
+	s.Require().NoError(err)
+	s.True(created)
+}
+
+func (s *RoomStoreTestSuite) TestCreateLink_TxnError() {
+	s.expectGet("/rooms/room-1/linkedby", nil, 0)
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{err: errors.New("etcd error")})
+
+	created, err := s.store.CreateLink(s.ctx, "room-2", &etcdstate.Link{SourceRoomID: "room-1"})
+	s.Require().Error(err)
+	s.False(created)
+}
+
+func (s *RoomStoreTestSuite) TestGetLink_Success() {
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/rooms/room-2/link").
+		Return(&clientv3.GetResponse{
+			Kvs: []*mvccpb.KeyValue{fixtures.KV("/rooms/room-2/link", &etcdstate.Link{SourceRoomID: "room-1"})},
+		}, nil)
+
+	link, err := s.store.GetLink(s.ctx, "room-2")
+	s.Require().NoError(err)
+	s.Equal("room-1", link.GetSourceRoomID())
+}
+
+func (s *RoomStoreTestSuite) TestGetLink_NotFound() {
+	s.mockEtcdClient.EXPECT().
+		Get(gomock.Any(), "/rooms/room-2/link").
+		Return(&clientv3.GetResponse{}, nil)
+
+	link, err := s.store.GetLink(s.ctx, "room-2")
+	s.Require().NoError(err)
+	s.Nil(link)
+}
+
+func (s *RoomStoreTestSuite) TestDeleteLink() {
+	s.expectGet("/rooms/room-2/link", &etcdstate.Link{SourceRoomID: "room-1"}, 5)
+	s.expectGet("/rooms/room-1/linkedby", &etcdstate.LinkedBy{Targets: map[string]string{"room-2": ""}}, 5)
+	txn := &fakeTxn{}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	err := s.store.DeleteLink(s.ctx, "room-2")
+	s.Require().NoError(err)
+
+	// the index goes away with the last link of the source room
+	s.Require().Len(txn.ops, 2)
+	s.Equal("/rooms/room-2/link", string(txn.ops[0].KeyBytes()))
+	s.True(txn.ops[1].IsDelete())
+	s.Equal("/rooms/room-1/linkedby", string(txn.ops[1].KeyBytes()))
+}
+
+func (s *RoomStoreTestSuite) TestDeleteLink_NotLinked() {
+	s.expectGet("/rooms/room-2/link", nil, 0)
+
+	err := s.store.DeleteLink(s.ctx, "room-2")
+	s.Require().NoError(err)
+}
+
 // Helper method tests
 
 func (s *RoomStoreTestSuite) TestKeyGeneration() {
@@ -519,7 +1011,7 @@ func (s *RoomStoreTestSuite) TestCreateRoom_SetsTimestamp() {
 
 func (s *RoomStoreTestSuite) TestSetModuleMark_SuccessWithoutTTL() {
 	s.mockEtcdClient.EXPECT().
-		Put(gomock.Any(), "mixersmixer-1/mark", gomock.Any()).
+		Put(gomock.Any(), "/mixers/mixer-1/mark", gomock.Any()).
 		DoAndReturn(func(_ context.Context, _ string, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
 			// Verify JSON structure
 			var markData etcdstate.MarkData
@@ -545,7 +1037,7 @@ func (s *RoomStoreTestSuite) TestSetModuleMark_SuccessWithTTL() {
 		Return(&clientv3.LeaseGrantResponse{ID: leaseID}, nil)
 
 	s.mockEtcdClient.EXPECT().
-		Put(gomock.Any(), "janusesjan-1/mark", gomock.Any(), gomock.Any()).
+		Put(gomock.Any(), "/januses/jan-1/mark", gomock.Any(), gomock.Any()).
 		DoAndReturn(func(_ context.Context, _, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
 			// Verify JSON structure
 			var markData etcdstate.MarkData
@@ -563,6 +1055,30 @@ func (s *RoomStoreTestSuite) TestSetModuleMark_SuccessWithTTL() {
 	s.Require().NoError(err)
 }
 
+func (s *RoomStoreTestSuite) TestSetModuleDegraded() {
+	leaseID := clientv3.LeaseID(12345)
+
+	s.mockEtcdClient.EXPECT().
+		Grant(gomock.Any(), int64(60)).
+		Return(&clientv3.LeaseGrantResponse{ID: leaseID}, nil)
+	s.mockEtcdClient.EXPECT().
+		Put(gomock.Any(), "/januses/jan-1/degraded", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+			var degraded etcdstate.DegradedData
+			s.Require().NoError(json.Unmarshal([]byte(val), &degraded))
+			s.Equal("circuit open", degraded.Reason)
+			s.Equal("gw-1", degraded.Reporter)
+			s.Len(opts, 1, "Expected exactly one option (the lease)")
+			return &clientv3.PutResponse{}, nil
+		})
+
+	err := s.store.SetModuleDegraded(s.ctx, "januses", "jan-1", &etcdstate.DegradedData{Reason: "circuit open", Reporter: "gw-1"}, 60)
+	s.Require().NoError(err)
+
+	err = s.store.SetModuleDegraded(s.ctx, "unknown", "jan-1", &etcdstate.DegradedData{}, 60)
+	s.Error(err)
+}
+
 func (s *RoomStoreTestSuite) TestSetModuleMark_GrantLeaseError() {
 	s.mockEtcdClient.EXPECT().
 		Grant(gomock.Any(), int64(3600)).
@@ -600,7 +1116,7 @@ func (s *RoomStoreTestSuite) TestSetModuleMark_AllLabels() {
 
 func (s *RoomStoreTestSuite) TestSetModuleMark_PutError() {
 	s.mockEtcdClient.EXPECT().
-		Put(gomock.Any(), "mixersmixer-1/mark", gomock.Any()).
+		Put(gomock.Any(), "/mixers/mixer-1/mark", gomock.Any()).
 		Return(nil, errors.New("etcd write error"))
 
 	err := s.store.SetModuleMark(s.ctx, "mixers", "mixer-1", constants.MarkLabelReady, 0)
@@ -629,7 +1145,7 @@ func (s *RoomStoreTestSuite) TestSetModuleMark_ModuleTypes() {
 
 func (s *RoomStoreTestSuite) TestDeleteModuleMark_Success() {
 	s.mockEtcdClient.EXPECT().
-		Delete(gomock.Any(), "mixersmixer-1/mark").
+		Delete(gomock.Any(), "/mixers/mixer-1/mark").
 		Return(&clientv3.DeleteResponse{Deleted: 1}, nil)
 
 	err := s.store.DeleteModuleMark(s.ctx, "mixers", "mixer-1")
@@ -638,7 +1154,7 @@ func (s *RoomStoreTestSuite) TestDeleteModuleMark_Success() {
 
 func (s *RoomStoreTestSuite) TestDeleteModuleMark_DeleteError() {
 	s.mockEtcdClient.EXPECT().
-		Delete(gomock.Any(), "mixersmixer-1/mark").
+		Delete(gomock.Any(), "/mixers/mixer-1/mark").
 		Return(nil, errors.New("etcd delete error"))
 
 	err := s.store.DeleteModuleMark(s.ctx, "mixers", "mixer-1")
@@ -685,7 +1201,186 @@ func (s *RoomStoreTestSuite) TestDeleteModuleMark_MultipleModules() {
 func (s *RoomStoreTestSuite) TestModuleMarkKey_Generation() {
 	store := s.store.(*roomStoreImpl)
 
-	s.Equal("mixersmixer-1/mark", store.moduleMarkKey("mixers", "mixer-1"))
-	s.Equal("janusesjan-1/mark", store.moduleMarkKey("januses", "jan-1"))
-	s.Equal("mixerstest-module/mark", store.moduleMarkKey("mixers", "test-module"))
+	for _, tc := range []struct {
+		moduleType, moduleID, expected string
+	}{
+		{"mixers", "mixer-1", "/mixers/mixer-1/mark"},
+		{"januses", "jan-1", "/januses/jan-1/mark"},
+		{"mixers", "test-module", "/mixers/test-module/mark"},
+	} {
+		key, err := store.moduleMarkKey(tc.moduleType, tc.moduleID)
+		s.Require().NoError(err)
+		s.Equal(tc.expected, key)
+	}
+}
+
+func (s *RoomStoreTestSuite) TestModuleMarkKey_UnknownType() {
+	store := s.store.(*roomStoreImpl)
+
+	_, err := store.moduleMarkKey("unknown", "mixer-1")
+	s.Require().Error(err)
+}
+
+// ListModuleStatus Tests
+
+// fakeTxn answers Then ops with the given delete counts then range responses, in order
+type fakeTxn struct {
+	ops     []clientv3.Op
+	cmps    []clientv3.Cmp
+	deletes []int64
+	ranges  [][]*mvccpb.KeyValue
+	counts  []int64 // counts of the ranges of the same index
+	rev     int64
+	failed  bool
+	err     error
+}
+
+func (t *fakeTxn) If(cmps ...clientv3.Cmp) clientv3.Txn {
+	t.cmps = append(t.cmps, cmps...)
+	return t
+}
+func (t *fakeTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.ops = append(t.ops, ops...)
+	return t
+}
+func (t *fakeTxn) Else(_ ...clientv3.Op) clientv3.Txn { return t }
+func (t *fakeTxn) Commit() (*clientv3.TxnResponse, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	resp := &clientv3.TxnResponse{
+		Header:    &etcdserverpb.ResponseHeader{Revision: t.rev},
+		Succeeded: !t.failed,
+	}
+	for _, deleted := range t.deletes {
+		resp.Responses = append(resp.Responses, &etcdserverpb.ResponseOp{
+			Response: &etcdserverpb.ResponseOp_ResponseDeleteRange{
+				ResponseDeleteRange: &etcdserverpb.DeleteRangeResponse{Deleted: deleted},
+			},
+		})
+	}
+	for i, kvs := range t.ranges {
+		var count int64
+		if i < len(t.counts) {
+			count = t.counts[i]
+		}
+		resp.Responses = append(resp.Responses, &etcdserverpb.ResponseOp{
+			Response: &etcdserverpb.ResponseOp_ResponseRange{
+				ResponseRange: &etcdserverpb.RangeResponse{Kvs: kvs, Count: count},
+			},
+		})
+	}
+	return resp, nil
+}
+
+func (s *RoomStoreTestSuite) TestListModuleStatus_Success() {
+	txn := &fakeTxn{ranges: [][]*mvccpb.KeyValue{
+		{
+			fixtures.KV("/mixers/mixer-1/heartbeat", &etcdstate.HeartbeatData{Status: constants.ModuleStatusHealthy, Capacity: 10}),
+			fixtures.KV("/mixers/mixer-2/heartbeat", &etcdstate.HeartbeatData{Status: constants.ModuleStatusHealthy, Capacity: 5}),
+			fixtures.KV("/mixers/mixer-2/mark", &etcdstate.MarkData{Label: constants.MarkLabelCordon}),
+		},
+		{
+			fixtures.KV("/rooms/room-1/meta", &etcdstate.Meta{Pin: "123456"}),
+			fixtures.KV("/rooms/room-1/livemeta", &etcdstate.LiveMeta{MixerID: "mixer-1", JanusID: "janus-1"}),
+			fixtures.KV("/rooms/room-2/livemeta", &etcdstate.LiveMeta{MixerID: "mixer-1", JanusID: "janus-1"}),
+			fixtures.KV("/rooms/room-3/livemeta", &etcdstate.LiveMeta{MixerID: "mixer-3", JanusID: "janus-1"}),
+		},
+	}}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	modules, err := s.store.ListModuleStatus(s.ctx, "mixers")
+	s.Require().NoError(err)
+
+	// both ranges are read in the same txn
+	s.Require().Len(txn.ops, 2)
+	s.Equal("/mixers/", string(txn.ops[0].KeyBytes()))
+	s.Equal("/rooms/", string(txn.ops[1].KeyBytes()))
+
+	s.Require().Len(modules, 3)
+
+	s.Equal("mixer-1", modules[0].ModuleID)
+	s.Equal(10, modules[0].Capacity)
+	s.Equal(2, modules[0].AssignedRooms)
+	s.True(modules[0].Healthy)
+	s.True(modules[0].Pickable)
+
+	s.Equal("mixer-2", modules[1].ModuleID)
+	s.Equal(constants.MarkLabelCordon, modules[1].Mark.GetLabel())
+	s.Equal(0, modules[1].AssignedRooms)
+	s.True(modules[1].Healthy)
+	s.False(modules[1].Pickable)
+
+	// assigned but no heartbeat
+	s.Equal("mixer-3", modules[2].ModuleID)
+	s.Nil(modules[2].Heartbeat)
+	s.Equal(1, modules[2].AssignedRooms)
+	s.False(modules[2].Healthy)
+}
+
+func (s *RoomStoreTestSuite) TestListModuleStatus_Januses() {
+	txn := &fakeTxn{ranges: [][]*mvccpb.KeyValue{
+		{
+			fixtures.KV("/januses/janus-1/heartbeat", &etcdstate.HeartbeatData{Status: constants.ModuleStatusHealthy, Capacity: 3}),
+		},
+		{
+			fixtures.KV("/rooms/room-1/livemeta", &etcdstate.LiveMeta{MixerID: "mixer-1", JanusID: "janus-1"}),
+		},
+	}}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(txn)
+
+	modules, err := s.store.ListModuleStatus(s.ctx, "januses")
+	s.Require().NoError(err)
+	s.Equal("/januses/", string(txn.ops[0].KeyBytes()))
+	s.Require().Len(modules, 1)
+	s.Equal("janus-1", modules[0].ModuleID)
+	s.Equal(1, modules[0].AssignedRooms)
+}
+
+func (s *RoomStoreTestSuite) TestListModuleStatus_SkipsMalformed() {
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{ranges: [][]*mvccpb.KeyValue{
+		{{Key: []byte("/mixers/mixer-1/heartbeat"), Value: []byte("not json")}},
+		{{Key: []byte("/rooms/room-1/livemeta"), Value: []byte("not json")}},
+	}})
+
+	modules, err := s.store.ListModuleStatus(s.ctx, "mixers")
+	s.Require().NoError(err)
+	s.Require().Len(modules, 1)
+	s.Nil(modules[0].Heartbeat)
+	s.Equal(0, modules[0].AssignedRooms)
+}
+
+func (s *RoomStoreTestSuite) TestListModuleStatus_TxnError() {
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{err: errors.New("etcd down")})
+
+	_, err := s.store.ListModuleStatus(s.ctx, "mixers")
+	s.Require().Error(err)
+	s.Contains(err.Error(), "failed to read module status")
+}
+
+func (s *RoomStoreTestSuite) TestListModuleStatus_UnknownType() {
+	_, err := s.store.ListModuleStatus(s.ctx, "unknown")
+	s.Require().Error(err)
+}
+
+func (s *RoomStoreTestSuite) TestListModuleStatus_Cached() {
+	ranges := [][]*mvccpb.KeyValue{
+		{fixtures.KV("/mixers/mixer-1/heartbeat", &etcdstate.HeartbeatData{Status: constants.ModuleStatusHealthy})},
+		{},
+	}
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{ranges: ranges})
+
+	first, err := s.store.ListModuleStatus(s.ctx, "mixers")
+	s.Require().NoError(err)
+	second, err := s.store.ListModuleStatus(s.ctx, "mixers")
+	s.Require().NoError(err)
+	s.Equal(first, second)
+
+	// a mark change is visible on the next read
+	s.mockEtcdClient.EXPECT().Put(gomock.Any(), "/mixers/mixer-1/mark", gomock.Any()).Return(&clientv3.PutResponse{}, nil)
+	s.Require().NoError(s.store.SetModuleMark(s.ctx, "mixers", "mixer-1", constants.MarkLabelCordon, 0))
+
+	s.mockEtcdClient.EXPECT().Txn(gomock.Any()).Return(&fakeTxn{ranges: ranges})
+	_, err = s.store.ListModuleStatus(s.ctx, "mixers")
+	s.Require().NoError(err)
 }
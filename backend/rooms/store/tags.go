@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var errTagsDisabled = errors.New("room tags index is disabled")
+
+// Tag index keys, under the room tags prefix:
+//
+//	<key>=<value>/<roomId>   rooms tagged key: value, from tagging to untagging or deletion
+//
+// Tag keys never contain = nor /, tag values never contain /
+func (rs *roomStoreImpl) tagPrefix(key, value string) string {
+	return rs.prefixTags + key + "=" + value + "/"
+}
+
+// tagsIndexed reports whether room tags are indexed, none are when no tags prefix is set
+func (rs *roomStoreImpl) tagsIndexed() bool {
+	return rs.prefixTags != ""
+}
+
+// tagOps returns the index updates of the tags of a room changing from before to after
+func (rs *roomStoreImpl) tagOps(roomID string, before, after map[string]string) []clientv3.Op {
+	if !rs.tagsIndexed() {
+		return nil
+	}
+
+	var ops []clientv3.Op
+	for _, key := range slices.Sorted(maps.Keys(before)) {
+		if value, ok := after[key]; !ok || value != before[key] {
+			ops = append(ops, clientv3.OpDelete(rs.tagPrefix(key, before[key])+roomID))
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(after)) {
+		if value, ok := before[key]; !ok || value != after[key] {
+			ops = append(ops, clientv3.OpPut(rs.tagPrefix(key, after[key])+roomID, ""))
+		}
+	}
+	return ops
+}
+
+// ListRoomsByTag returns the rooms indexed with the tag key: value, the index is not read at
+// the revision of the metas so callers check the tags of the rooms they read
+func (rs *roomStoreImpl) ListRoomsByTag(ctx context.Context, key, value string) ([]string, error) {
+	if !rs.tagsIndexed() {
+		return nil, errTagsDisabled
+	}
+
+	prefix := rs.tagPrefix(key, value)
+	resp, err := rs.etcdClient.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rooms by tag: %w", err)
+	}
+
+	roomIDs := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		roomID := strings.TrimPrefix(string(kv.Key), prefix)
+		if roomID != "" && !strings.Contains(roomID, "/") {
+			roomIDs = append(roomIDs, roomID)
+		}
+	}
+	return roomIDs, nil
+}
@@ -1,13 +1,66 @@
 package transport
 
+import (
+	"strings"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/validation"
+)
+
 // CreateRoomRequest represents the request to create a room
 type CreateRoomRequest struct {
 	// RoomID: 3-32 characters (letters, numbers, hyphens, underscores) - optional
 	RoomID string `json:"roomId,omitempty" binding:"omitempty,roomid"`
-	// Pin: exactly 6 alphanumeric characters (optional)
-	Pin string `json:"pin,omitempty" binding:"omitempty,len=6,alphanum"`
+	// Pin: format and length of the configured PIN policy (optional)
+	Pin string `json:"pin,omitempty" binding:"omitempty,max=32,alphanum"`
 	// MaxAnchors: optional, min 1, max 5
 	MaxAnchors int `json:"maxAnchors,omitempty" binding:"omitempty,min=1,max=5"`
+	// MaxBitrate: optional, per publisher Opus bitrate cap in bps, within Opus range
+	MaxBitrate int `json:"maxBitrate,omitempty" binding:"omitempty,min=6000,max=510000"`
+	// DVRWindow: optional, seconds of audio kept for catch-up playback, max 4 hours
+	DVRWindow int `json:"dvrWindow,omitempty" binding:"omitempty,min=10,max=14400"`
+	// MaxDuration: optional, seconds a live may last before it is stopped, max 24 hours
+	MaxDuration int `json:"maxDuration,omitempty" binding:"omitempty,min=60,max=86400"`
+	// ExternalID: optional, upstream identifier (e.g. CMS) the room can be looked up by, unique
+	ExternalID string `json:"externalId,omitempty" binding:"omitempty,externalid"`
+	// Audio: optional, Opus settings of the room
+	Audio *AudioSettings `json:"audio,omitempty"`
+	// Tags: optional, up to 16 labels to group rooms by, e.g. {"show": "morning"}
+	Tags map[string]string `json:"tags,omitempty" binding:"omitempty,max=16,dive,keys,tagkey,endkeys,tagvalue"`
+}
+
+// AudioSettings tunes Opus of a room, omitted fields keep what Janus and the anchors negotiate
+type AudioSettings struct {
+	// FEC: optional, in-band forward error correction, for lossy mobile links
+	FEC *bool `json:"fec,omitempty"`
+	// DTX: optional, anchors stop sending during silence
+	DTX *bool `json:"dtx,omitempty"`
+	// MaxAverageBitrate: optional, average bitrate anchors encode at in bps, within Opus range
+	MaxAverageBitrate int `json:"maxAverageBitrate,omitempty" binding:"omitempty,min=6000,max=510000"`
+	// Stereo: optional, mix the room and let anchors send in stereo
+	Stereo *bool `json:"stereo,omitempty"`
+}
+
+// params converts the settings to the room meta, nil when not given
+func (a *AudioSettings) params() *etcdstate.AudioParams {
+	if a == nil {
+		return nil
+	}
+	return &etcdstate.AudioParams{
+		FEC:               a.FEC,
+		DTX:               a.DTX,
+		MaxAverageBitrate: a.MaxAverageBitrate,
+		Stereo:            a.Stereo,
+	}
+}
+
+// GetRoomUsageQuery bounds the hours of the usage of a room
+type GetRoomUsageQuery struct {
+	// From: optional RFC 3339 time, rounded down to the hour, defaults to a day before to
+	From time.Time `form:"from"`
+	// To: optional RFC 3339 time, rounded up to the hour, defaults to now
+	To time.Time `form:"to"`
 }
 
 // GetRoomRequest represents the request to get a room (from URL param)
@@ -16,12 +69,104 @@ type GetRoomRequest struct {
 	RoomID string `uri:"roomId" binding:"required,roomid"`
 }
 
+// GetExternalRoomRequest represents the request to look a room up by its external ID
+type GetExternalRoomRequest struct {
+	// ExternalID: up to 128 printable characters without slashes - required
+	ExternalID string `uri:"externalId" binding:"required,externalid"`
+}
+
+// UpdateRoomURI represents the URI parameters for updating a room
+type UpdateRoomURI struct {
+	// RoomID: 3-32 characters (letters, numbers, hyphens, underscores) - required
+	RoomID string `uri:"roomId" binding:"required,roomid"`
+}
+
+// UpdateRoomBody represents the mutable fields of a room, omitted fields are left unchanged
+type UpdateRoomBody struct {
+	// MaxAnchors: optional, min 1, max 5, applies to the next joins
+	MaxAnchors *int `json:"maxAnchors,omitempty" binding:"omitempty,min=1,max=5"`
+	// Recording: optional, record the lives of the room
+	Recording *bool `json:"recording,omitempty"`
+	// MixProfile: optional, name of a mixer mix profile, empty resets to the default mix
+	MixProfile *string `json:"mixProfile,omitempty" binding:"omitempty,max=32,printascii"`
+	// ScheduledAt: optional, RFC 3339 planned start of the live
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty"`
+	// Locale: optional, BCP 47 language of the room, e.g. zh-TW, empty resets it
+	Locale *string `json:"locale,omitempty" binding:"omitempty,locale"`
+	// Tags: optional, replace all tags of the room, {} removes them
+	Tags map[string]string `json:"tags,omitempty" binding:"omitempty,max=16,dive,keys,tagkey,endkeys,tagvalue"`
+	// PublishSlots: optional, max 32, anchors publishing at once in push-to-talk, 0 turns it off
+	PublishSlots *int `json:"publishSlots,omitempty" binding:"omitempty,min=0,max=32"`
+	// JoinQueue: optional, max 100, users waiting for a free anchor slot of the full room, 0 rejects them
+	JoinQueue *int `json:"joinQueue,omitempty" binding:"omitempty,min=0,max=100"`
+}
+
+// ListRoomsQuery filters the listed rooms
+type ListRoomsQuery struct {
+	// Tag: optional, repeatable key:value, rooms must have all the tags
+	Tag []string `form:"tag" binding:"omitempty,max=8"`
+}
+
+// tags parses the tag filters, false when a filter is not a valid key:value
+func (q *ListRoomsQuery) tags() (map[string]string, bool) {
+	if len(q.Tag) == 0 {
+		return nil, true
+	}
+	tags := make(map[string]string, len(q.Tag))
+	for _, tag := range q.Tag {
+		key, value, _ := strings.Cut(tag, ":")
+		if !validation.IsTag(key, value) {
+			return nil, false
+		}
+		tags[key] = value
+	}
+	return tags, true
+}
+
+// EndRoomRequest represents the request to end a room (from URL param)
+type EndRoomRequest struct {
+	RoomID string `uri:"roomId" binding:"required,roomid"`
+}
+
+// FreezeRoomRequest represents the request to freeze or unfreeze a room (from URL param)
+type FreezeRoomRequest struct {
+	RoomID string `uri:"roomId" binding:"required,roomid"`
+}
+
 // DeleteRoomRequest represents the request to delete a room (from URL param)
 type DeleteRoomRequest struct {
 	// RoomID: 3-32 characters (letters, numbers, hyphens, underscores) - required
 	RoomID string `uri:"roomId" binding:"required,roomid"`
 }
 
+// LinkRoomURI represents the URI parameters for linking a room
+type LinkRoomURI struct {
+	// RoomID: source room whose audio is forwarded - required
+	RoomID string `uri:"roomId" binding:"required,roomid"`
+}
+
+// LinkRoomBody represents the request body to link a room into another room's mix
+type LinkRoomBody struct {
+	// TargetRoomID: room receiving the forwarded audio - required
+	TargetRoomID string `json:"targetRoomId" binding:"required,roomid"`
+	// AnchorID: only forward this anchor of the source room instead of its whole mix - optional
+	AnchorID string `json:"anchorId,omitempty" binding:"omitempty,userid"`
+}
+
+// UnlinkRoomRequest represents the request to remove a link (from URL params)
+type UnlinkRoomRequest struct {
+	// RoomID: source room of the link - required
+	RoomID string `uri:"roomId" binding:"required,roomid"`
+	// TargetRoomID: target room of the link - required
+	TargetRoomID string `uri:"targetRoomId" binding:"required,roomid"`
+}
+
+// ListModulesURI represents the URI parameters for listing modules
+type ListModulesURI struct {
+	// ModuleType: "mixers" or "januses"
+	ModuleType string `uri:"moduleType" binding:"required,modules"`
+}
+
 // ModuleMarkURI represents the URI parameters for module mark operations
 type ModuleMarkURI struct {
 	// ModuleType: "mixers" or "januses"
@@ -37,3 +182,49 @@ type SetModuleMarkBody struct {
 	// TTL: time to live in seconds (optional, 0 means no expiration)
 	TTL int64 `json:"ttl" binding:"omitempty,min=0,max=86400"`
 }
+
+// ReportModuleDegradedBody represents the request body for reporting a failing module
+type ReportModuleDegradedBody struct {
+	// Reason: what failed, free form - optional
+	Reason string `json:"reason" binding:"omitempty,max=256"`
+	// Reporter: identifies the reporting client, e.g. a gateway instance - optional
+	Reporter string `json:"reporter" binding:"omitempty,max=128"`
+	// TTL: seconds until the report expires unless renewed - required
+	TTL int64 `json:"ttl" binding:"required,min=1,max=3600"`
+}
+
+// SetHousekeepingBody represents the request body for toggling housekeeping dry run
+type SetHousekeepingBody struct {
+	// DryRun: only log and count stale rooms and unhealthy modules, without changing etcd
+	DryRun *bool `json:"dryRun" binding:"required"`
+}
+
+// CreateAPIKeyBody represents the request body for creating an API key
+type CreateAPIKeyBody struct {
+	// Tenant: owner of the key - required
+	Tenant string `json:"tenant" binding:"required,max=64,excludes=/"`
+	// Scopes: create, delete, mark-modules or admin (optional, none only allows reads)
+	Scopes []string `json:"scopes" binding:"omitempty,dive,oneof=create delete mark-modules admin"`
+	// RateLimit: requests per minute (optional, 0 uses the configured default)
+	RateLimit int `json:"rateLimit" binding:"omitempty,min=0,max=100000"`
+}
+
+// APIKeyURI represents the URI parameters for API key operations
+type APIKeyURI struct {
+	// KeyID: 16 hex characters, the part of the key before the dot
+	KeyID string `uri:"keyId" binding:"required,hexadecimal,len=16"`
+}
+
+// TenantURI represents the URI parameters for tenant operations
+type TenantURI struct {
+	// Tenant: owner of API keys and rooms
+	Tenant string `uri:"tenant" binding:"required,max=64"`
+}
+
+// SetQuotaBody represents the request body for setting the room quota of a tenant
+type SetQuotaBody struct {
+	// MaxRooms: max rooms of the tenant (0 means unlimited)
+	MaxRooms int `json:"maxRooms" binding:"min=0"`
+	// MaxOnAirRooms: max rooms of the tenant on air at once (0 means unlimited)
+	MaxOnAirRooms int `json:"maxOnAirRooms" binding:"min=0"`
+}
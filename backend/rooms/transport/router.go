@@ -6,49 +6,94 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
+	"github.com/imtaco/audio-rtc-exp/internal/apispec"
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/httputil"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/pin"
+	"github.com/imtaco/audio-rtc-exp/internal/svcauth"
+	"github.com/imtaco/audio-rtc-exp/internal/usage"
 	"github.com/imtaco/audio-rtc-exp/internal/validation"
 	"github.com/imtaco/audio-rtc-exp/rooms"
-	utils "github.com/imtaco/audio-rtc-exp/rooms/utils"
+	"github.com/imtaco/audio-rtc-exp/rooms/auth"
 )
 
 const (
 	defaultMaxAnchors = 3
+	// defaultUsageRange is the usage read without from
+	defaultUsageRange = 24 * time.Hour
 )
 
+// routeScopes maps route names to the scope they require, other routes only require a valid key
+var routeScopes = map[string]string{
+	"createRoom":       rooms.ScopeCreate,
+	"updateRoom":       rooms.ScopeCreate,
+	"linkRoom":         rooms.ScopeCreate,
+	"deleteRoom":       rooms.ScopeDelete,
+	"endRoom":          rooms.ScopeDelete,
+	"freezeRoom":       rooms.ScopeDelete,
+	"unfreezeRoom":     rooms.ScopeDelete,
+	"unlinkRoom":       rooms.ScopeDelete,
+	"setModuleMark":    rooms.ScopeMarkModules,
+	"deleteModuleMark": rooms.ScopeMarkModules,
+	"reportDegraded":   rooms.ScopeMarkModules,
+	"setHousekeeping":  rooms.ScopeAdmin,
+	"createAPIKey":     rooms.ScopeAdmin,
+	"listAPIKeys":      rooms.ScopeAdmin,
+	"deleteAPIKey":     rooms.ScopeAdmin,
+	"getTenantQuota":   rooms.ScopeAdmin,
+	"getRoomDetail":    rooms.ScopeAdmin,
+	"getRoomTimeline":  rooms.ScopeAdmin,
+	"getRoomUsage":     rooms.ScopeAdmin,
+	"setTenantQuota":   rooms.ScopeAdmin,
+}
+
 type Router struct {
 	roomService rooms.RoomService
 	roomStore   rooms.RoomStore
+	apiKeyStore rooms.APIKeyStore
+	resManager  rooms.ResourceManager
+	pinPolicy   *pin.Policy
+	idProvider  rooms.IDProvider
+	listeners   rooms.ListenerCounter // nil when listener counting is disabled
+	usage       rooms.UsageReader     // nil when usage accounting is disabled
+	auth        *auth.Authenticator   // nil when authentication is disabled
 	engine      *gin.Engine
+	spec        *apispec.Spec
 	logger      *log.Logger
 }
 
-func NewRouter(roomService rooms.RoomService, roomStore rooms.RoomStore, logger *log.Logger) *Router {
-	gin.SetMode(gin.ReleaseMode)
-	engine := gin.New()
-	engine.Use(gin.Recovery())
-
-	// Add OpenTelemetry middleware for automatic HTTP tracing
-	engine.Use(otelgin.Middleware("room-service"))
+func NewRouter(
+	roomService rooms.RoomService,
+	roomStore rooms.RoomStore,
+	apiKeyStore rooms.APIKeyStore,
+	resManager rooms.ResourceManager,
+	pinPolicy *pin.Policy,
+	idProvider rooms.IDProvider,
+	listeners rooms.ListenerCounter,
+	usageReader rooms.UsageReader,
+	authenticator *auth.Authenticator,
+	logger *log.Logger,
+) *Router {
+	engine := httputil.NewEngine("room-service", logger)
 
 	r := &Router{
 		roomService: roomService,
 		roomStore:   roomStore,
+		apiKeyStore: apiKeyStore,
+		resManager:  resManager,
+		pinPolicy:   pinPolicy,
+		idProvider:  idProvider,
+		listeners:   listeners,
+		usage:       usageReader,
+		auth:        authenticator,
 		engine:      engine,
+		spec:        apispec.New("Room Service API", "1.0.0"),
 		logger:      logger,
 	}
 
-	// Request logging middleware
-	r.engine.Use(func(c *gin.Context) {
-		r.logger.Info("Incoming request",
-			log.String("method", c.Request.Method),
-			log.String("url", c.Request.URL.String()))
-		c.Next()
-	})
-
 	r.setupRoutes()
 	return r
 }
@@ -58,25 +103,406 @@ func (r *Router) Handler() http.Handler {
 }
 
 func (r *Router) setupRoutes() {
-	r.engine.Use(otelgin.Middleware("room-service"))
-
 	// Room management routes
-	r.engine.POST("/api/rooms", r.createRoom)
-	r.engine.GET("/api/rooms/:roomId", r.getRoom)
-	r.engine.GET("/api/rooms", r.listRooms)
-	r.engine.DELETE("/api/rooms/:roomId", r.deleteRoom)
+	r.handle(apispec.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/rooms",
+		Name:    "createRoom",
+		Summary: "Create a room and start it live",
+		Body:    CreateRoomRequest{},
+		Responses: map[int]any{
+			http.StatusCreated:             gin.H{"success": true, "room": rooms.RoomResponse{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusConflict:            apispec.ErrorResponse,
+			http.StatusTooManyRequests:     apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.createRoom)
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/rooms/:roomId",
+		Name:    "getRoom",
+		Summary: "Get a room",
+		URI:     GetRoomRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "room": rooms.RoomResponse{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusNotFound:            apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.getRoom)
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/rooms/:roomId/full",
+		Name:    "getRoomDetail",
+		Summary: "Get a room with its live state, modules, anchors and FFmpeg state, for admin dashboards",
+		URI:     GetRoomRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "detail": rooms.RoomDetail{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusNotFound:            apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.getRoomDetail)
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/rooms/:roomId/timeline",
+		Name:    "getRoomTimeline",
+		Summary: "Get the significant transitions of a room recorded by the services, oldest first",
+		URI:     GetRoomRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "events": []etcdstate.TimelineEvent{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusNotFound:            apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.getRoomTimeline)
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/external/rooms/:externalId",
+		Name:    "getExternalRoom",
+		Summary: "Get a room by its external ID",
+		URI:     GetExternalRoomRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "room": rooms.RoomResponse{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusNotFound:            apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.getExternalRoom)
+	r.handle(apispec.Route{
+		Method:  http.MethodPatch,
+		Path:    "/api/rooms/:roomId",
+		Name:    "updateRoom",
+		Summary: "Update the mutable fields of a room",
+		URI:     UpdateRoomURI{},
+		Body:    UpdateRoomBody{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "room": rooms.RoomResponse{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusNotFound:            apispec.ErrorResponse,
+			http.StatusConflict:            apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.updateRoom)
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/rooms",
+		Name:    "listRooms",
+		Summary: "List rooms, filtered by tags",
+		Query:   ListRoomsQuery{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "count": 0, "rooms": []*rooms.RoomResponse{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.listRooms)
+	r.handle(apispec.Route{
+		Method:  http.MethodDelete,
+		Path:    "/api/rooms/:roomId",
+		Name:    "deleteRoom",
+		Summary: "Stop and delete a room",
+		URI:     DeleteRoomRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "message": ""},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusNotFound:            apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.deleteRoom)
+	r.handle(apispec.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/rooms/:roomId/end",
+		Name:    "endRoom",
+		Summary: "End a room, tearing down anchors, forwarder and live in order",
+		URI:     EndRoomRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "room": rooms.RoomResponse{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusNotFound:            apispec.ErrorResponse,
+			http.StatusConflict:            apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.endRoom)
+	r.handle(apispec.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/rooms/:roomId/freeze",
+		Name:    "freezeRoom",
+		Summary: "Freeze a room for incident handling, new joins are rejected and anchors muted while the live goes on",
+		URI:     FreezeRoomRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "room": rooms.RoomResponse{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusNotFound:            apispec.ErrorResponse,
+			http.StatusConflict:            apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.freezeRoom(true))
+	r.handle(apispec.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/rooms/:roomId/unfreeze",
+		Name:    "unfreezeRoom",
+		Summary: "Unfreeze a room, joins are taken again and anchors unmuted",
+		URI:     FreezeRoomRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "room": rooms.RoomResponse{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusNotFound:            apispec.ErrorResponse,
+			http.StatusConflict:            apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.freezeRoom(false))
+
+	// Cross-room link (co-hosting) routes
+	r.handle(apispec.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/rooms/:roomId/link",
+		Name:    "linkRoom",
+		Summary: "Forward the audio of a room into the mix of a target room",
+		URI:     LinkRoomURI{},
+		Body:    LinkRoomBody{},
+		Responses: map[int]any{
+			http.StatusCreated:             gin.H{"success": true, "link": rooms.LinkResponse{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusNotFound:            apispec.ErrorResponse,
+			http.StatusConflict:            apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.linkRoom)
+	r.handle(apispec.Route{
+		Method:  http.MethodDelete,
+		Path:    "/api/rooms/:roomId/link/:targetRoomId",
+		Name:    "unlinkRoom",
+		Summary: "Stop forwarding the audio of a room into a target room",
+		URI:     UnlinkRoomRequest{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "message": ""},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusNotFound:            apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.unlinkRoom)
 
 	// Module mark management routes
-	r.engine.PUT("/api/modules/:moduleType/:moduleId/mark", r.setModuleMark)
-	r.engine.DELETE("/api/modules/:moduleType/:moduleId/mark", r.deleteModuleMark)
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/modules/:moduleType",
+		Name:    "listModules",
+		Summary: "List modules with heartbeat, mark, capacity and assigned rooms",
+		URI:     ListModulesURI{},
+		Responses: map[int]any{
+			http.StatusOK: gin.H{
+				"success": true,
+				"type":    "",
+				"count":   0,
+				"modules": []*rooms.ModuleStatus{},
+			},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.listModules)
+	r.handle(apispec.Route{
+		Method:  http.MethodPut,
+		Path:    "/api/modules/:moduleType/:moduleId/mark",
+		Name:    "setModuleMark",
+		Summary: "Set the mark label of a module",
+		URI:     ModuleMarkURI{},
+		Body:    SetModuleMarkBody{},
+		Responses: map[int]any{
+			http.StatusOK: gin.H{
+				"success": true,
+				"message": "",
+				"module":  gin.H{"type": "", "id": "", "label": "", "ttl": int64(0)},
+			},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.setModuleMark)
+	r.handle(apispec.Route{
+		Method:  http.MethodDelete,
+		Path:    "/api/modules/:moduleType/:moduleId/mark",
+		Name:    "deleteModuleMark",
+		Summary: "Delete the mark label of a module",
+		URI:     ModuleMarkURI{},
+		Responses: map[int]any{
+			http.StatusOK: gin.H{
+				"success": true,
+				"message": "",
+				"module":  gin.H{"type": "", "id": ""},
+			},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.deleteModuleMark)
+	r.handle(apispec.Route{
+		Method:  http.MethodPut,
+		Path:    "/api/modules/:moduleType/:moduleId/degraded",
+		Name:    "reportDegraded",
+		Summary: "Report a module failing its clients, placement prefers other modules until it expires",
+		URI:     ModuleMarkURI{},
+		Body:    ReportModuleDegradedBody{},
+		Responses: map[int]any{
+			http.StatusOK: gin.H{
+				"success": true,
+				"module":  gin.H{"type": "", "id": "", "ttl": int64(0)},
+			},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.reportDegraded)
+
+	// Housekeeping routes
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/housekeeping",
+		Name:    "getHousekeeping",
+		Summary: "Get the housekeeping mode",
+		Responses: map[int]any{
+			http.StatusOK: gin.H{"success": true, "dryRun": false},
+		},
+	}, r.getHousekeeping)
+	r.handle(apispec.Route{
+		Method:  http.MethodPut,
+		Path:    "/api/housekeeping",
+		Name:    "setHousekeeping",
+		Summary: "Toggle housekeeping dry run, reporting stale rooms and unhealthy modules without changing them",
+		Body:    SetHousekeepingBody{},
+		Responses: map[int]any{
+			http.StatusOK:         gin.H{"success": true, "dryRun": false},
+			http.StatusBadRequest: apispec.ValidationErrorResponse,
+		},
+	}, r.setHousekeeping)
+
+	// API key management routes
+	r.handle(apispec.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/apikeys",
+		Name:    "createAPIKey",
+		Summary: "Create an API key, the returned key is not retrievable later",
+		Body:    CreateAPIKeyBody{},
+		Responses: map[int]any{
+			http.StatusCreated:             gin.H{"success": true, "key": "", "apiKey": rooms.APIKey{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.createAPIKey)
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/apikeys",
+		Name:    "listAPIKeys",
+		Summary: "List API keys",
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "count": 0, "apiKeys": []*rooms.APIKey{}},
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.listAPIKeys)
+	r.handle(apispec.Route{
+		Method:  http.MethodDelete,
+		Path:    "/api/apikeys/:keyId",
+		Name:    "deleteAPIKey",
+		Summary: "Revoke an API key",
+		URI:     APIKeyURI{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "message": ""},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusNotFound:            apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.deleteAPIKey)
+
+	// Tenant quota routes
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/quota",
+		Name:    "getQuota",
+		Summary: "Get the room quota and usage of the tenant of the caller",
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "quota": rooms.QuotaUsage{}},
+			http.StatusBadRequest:          apispec.ErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.getQuota)
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/tenants/:tenant/quota",
+		Name:    "getTenantQuota",
+		Summary: "Get the room quota and usage of a tenant",
+		URI:     TenantURI{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "quota": rooms.QuotaUsage{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.getTenantQuota)
+	r.handle(apispec.Route{
+		Method:  http.MethodPut,
+		Path:    "/api/tenants/:tenant/quota",
+		Name:    "setTenantQuota",
+		Summary: "Set the room quota of a tenant, 0 means unlimited",
+		URI:     TenantURI{},
+		Body:    SetQuotaBody{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "quota": rooms.QuotaUsage{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.setTenantQuota)
+
+	if r.listeners != nil {
+		r.handle(apispec.Route{
+			Method:  http.MethodGet,
+			Path:    "/api/rooms/:roomId/listeners",
+			Name:    "getRoomListeners",
+			Summary: "Estimate the unique HLS listeners of a room within the last minutes",
+			URI:     GetRoomRequest{},
+			Responses: map[int]any{
+				http.StatusOK:                  gin.H{"success": true, "listeners": rooms.RoomListeners{}},
+				http.StatusBadRequest:          apispec.ValidationErrorResponse,
+				http.StatusInternalServerError: apispec.ErrorResponse,
+			},
+		}, r.getRoomListeners)
+	}
+	if r.usage != nil {
+		r.handle(apispec.Route{
+			Method:  http.MethodGet,
+			Path:    "/api/rooms/:roomId/usage",
+			Name:    "getRoomUsage",
+			Summary: "Get the forwarded and HLS bytes of a room per hour, for billing exports, up to 31 days",
+			URI:     GetRoomRequest{},
+			Query:   GetRoomUsageQuery{},
+			Responses: map[int]any{
+				http.StatusOK:                  gin.H{"success": true, "usage": usage.RoomUsage{}},
+				http.StatusBadRequest:          apispec.ValidationErrorResponse,
+				http.StatusInternalServerError: apispec.ErrorResponse,
+			},
+		}, r.getRoomUsage)
+	}
 
 	// Stats
-	r.engine.GET("/api/stats", r.getStats)
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/stats",
+		Name:    "getStats",
+		Summary: "Get room statistics",
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"success": true, "stats": rooms.RoomStats{}},
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.getStats)
+
+	// Machine-readable API description
+	r.engine.GET("/api/spec", gin.WrapH(r.spec))
 
 	// Health check
 	r.engine.GET("/health", r.healthCheck)
 }
 
+// handle registers the route to gin behind authentication and publishes it in the API spec
+func (r *Router) handle(route apispec.Route, handler gin.HandlerFunc) {
+	r.spec.Add(route)
+	r.engine.Handle(route.Method, route.Path, r.auth.Require(routeScopes[route.Name]), handler)
+}
+
 func (r *Router) createRoom(c *gin.Context) {
 	var req CreateRoomRequest
 
@@ -89,12 +515,15 @@ func (r *Router) createRoom(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
+
 	// Generate room ID if not provided
 	roomID := req.RoomID
 	if roomID == "" {
 		var err error
-		roomID, err = utils.GenerateRandomHex(10)
+		roomID, err = r.idProvider.NewRoomID(ctx, req.ExternalID)
 		if err != nil {
+			r.logger.Error("Failed to generate room ID", log.Error(err))
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
 				"error":   "Failed to generate room ID",
@@ -104,17 +533,24 @@ func (r *Router) createRoom(c *gin.Context) {
 	}
 
 	// Generate PIN if not provided
-	pin := req.Pin
-	if pin == "" {
+	roomPin := req.Pin
+	if roomPin == "" {
 		var err error
-		pin, err = utils.GenerateRandomHex(3)
+		roomPin, err = r.pinPolicy.Generate()
 		if err != nil {
+			r.logger.Error("Failed to generate PIN", log.Error(err))
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
 				"error":   "Failed to generate PIN",
 			})
 			return
 		}
+	} else if err := r.pinPolicy.Validate(roomPin); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
 	}
 
 	maxAnchors := req.MaxAnchors
@@ -122,17 +558,40 @@ func (r *Router) createRoom(c *gin.Context) {
 		maxAnchors = defaultMaxAnchors
 	}
 
-	ctx := c.Request.Context()
-	room, err := r.roomService.CreateRoom(ctx, roomID, pin, maxAnchors)
+	var tenant string
+	if principal := svcauth.PrincipalFrom(c); principal != nil {
+		tenant = principal.Tenant
+	}
+
+	room, err := r.roomService.CreateRoom(ctx, roomID, &rooms.CreateRoomParams{
+		Pin:         roomPin,
+		ExternalID:  req.ExternalID,
+		Tenant:      tenant,
+		MaxAnchors:  maxAnchors,
+		MaxBitrate:  req.MaxBitrate,
+		DVRWindow:   req.DVRWindow,
+		MaxDuration: req.MaxDuration,
+		Audio:       req.Audio.params(),
+		Tags:        req.Tags,
+	})
 	if err != nil {
 		var roomExistsErr *rooms.RoomExistsError
-		if errors.As(err, &roomExistsErr) {
+		var externalIDErr *rooms.ExternalIDExistsError
+		var quotaErr *rooms.QuotaExceededError
+		if errors.As(err, &roomExistsErr) || errors.As(err, &externalIDErr) {
 			c.JSON(http.StatusConflict, gin.H{
 				"success": false,
 				"error":   err.Error(),
 			})
 			return
 		}
+		if errors.As(err, &quotaErr) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"success": false,
+				"error":   quotaErr.Error(),
+			})
+			return
+		}
 		r.logger.Error("Failed to create room", log.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -143,6 +602,18 @@ func (r *Router) createRoom(c *gin.Context) {
 
 	// TODO: separate start live API ?!
 	if err := r.roomService.StartLive(ctx, roomID); err != nil {
+		var quotaErr *rooms.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			// the room never went on air, do not keep it counted against the rooms quota
+			if _, err := r.roomStore.DeleteRoom(ctx, roomID); err != nil {
+				r.logger.Error("Failed to delete room over quota", log.String("roomId", roomID), log.Error(err))
+			}
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"success": false,
+				"error":   quotaErr.Error(),
+			})
+			return
+		}
 		r.logger.Error("Failed to start live", log.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -157,8 +628,7 @@ func (r *Router) createRoom(c *gin.Context) {
 	})
 }
 
-func (r *Router) getRoom(c *gin.Context) {
-	// Validate room ID using manual validation
+func (r *Router) getRoomDetail(c *gin.Context) {
 	var req GetRoomRequest
 	if err := c.ShouldBindUri(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -169,10 +639,7 @@ func (r *Router) getRoom(c *gin.Context) {
 		return
 	}
 
-	roomID := req.RoomID
-	ctx := c.Request.Context()
-
-	room, err := r.roomService.GetRoom(ctx, roomID)
+	detail, err := r.roomService.GetRoomDetail(c.Request.Context(), req.RoomID)
 	if err != nil {
 		var roomNotFoundErr *rooms.RoomNotFoundError
 		if errors.As(err, &roomNotFoundErr) {
@@ -182,42 +649,58 @@ func (r *Router) getRoom(c *gin.Context) {
 			})
 			return
 		}
-		r.logger.Error("Failed to get room", log.Error(err))
+		r.logger.Error("Failed to get room detail", log.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"error":   "Failed to get room",
+			"error":   "Failed to get room detail",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"room":    room,
+		"detail":  detail,
 	})
 }
 
-func (r *Router) listRooms(c *gin.Context) {
-	ctx := c.Request.Context()
+func (r *Router) getRoomTimeline(c *gin.Context) {
+	var req GetRoomRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
 
-	result, err := r.roomService.ListRooms(ctx)
+	events, err := r.roomService.GetTimeline(c.Request.Context(), req.RoomID)
 	if err != nil {
-		r.logger.Error("Failed to list rooms", log.Error(err))
+		var roomNotFoundErr *rooms.RoomNotFoundError
+		if errors.As(err, &roomNotFoundErr) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		r.logger.Error("Failed to get room timeline", log.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"error":   "Failed to list rooms",
+			"error":   "Failed to get room timeline",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"count":   result.Count,
-		"rooms":   result.Rooms,
+		"events":  events,
 	})
 }
 
-func (r *Router) deleteRoom(c *gin.Context) {
-	var req DeleteRoomRequest
+func (r *Router) getRoom(c *gin.Context) {
+	// Validate room ID using manual validation
+	var req GetRoomRequest
 	if err := c.ShouldBindUri(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -230,7 +713,7 @@ func (r *Router) deleteRoom(c *gin.Context) {
 	roomID := req.RoomID
 	ctx := c.Request.Context()
 
-	result, err := r.roomService.DeleteRoom(ctx, roomID)
+	room, err := r.roomService.GetRoom(ctx, roomID)
 	if err != nil {
 		var roomNotFoundErr *rooms.RoomNotFoundError
 		if errors.As(err, &roomNotFoundErr) {
@@ -240,52 +723,59 @@ func (r *Router) deleteRoom(c *gin.Context) {
 			})
 			return
 		}
-		r.logger.Error("Failed to delete room", log.Error(err))
+		r.logger.Error("Failed to get room", log.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"error":   "Failed to delete room",
+			"error":   "Failed to get room",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": result.Message,
+		"room":    room,
 	})
 }
 
-func (r *Router) getStats(c *gin.Context) {
-	ctx := c.Request.Context()
+func (r *Router) getExternalRoom(c *gin.Context) {
+	var req GetExternalRoomRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
 
-	stats, err := r.roomService.GetStats(ctx)
+	room, err := r.roomService.GetRoomByExternalID(c.Request.Context(), req.ExternalID)
 	if err != nil {
-		r.logger.Error("Failed to get stats", log.Error(err))
+		var roomNotFoundErr *rooms.RoomNotFoundError
+		if errors.As(err, &roomNotFoundErr) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   "Room with external ID " + req.ExternalID + " not found",
+			})
+			return
+		}
+		r.logger.Error("Failed to get room by external ID", log.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"error":   "Failed to get stats",
+			"error":   "Failed to get room",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"stats":   stats,
-	})
-}
-
-func (r *Router) healthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":    "ok",
-		"service":   "rooms",
-		"timestamp": time.Now().Unix(),
+		"room":    room,
 	})
 }
 
-func (r *Router) setModuleMark(c *gin.Context) {
-	var uriParams ModuleMarkURI
-	var bodyParams SetModuleMarkBody
+func (r *Router) updateRoom(c *gin.Context) {
+	var uriParams UpdateRoomURI
+	var bodyParams UpdateRoomBody
 
-	// Bind URI params
 	if err := c.ShouldBindUri(&uriParams); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -294,7 +784,6 @@ func (r *Router) setModuleMark(c *gin.Context) {
 		})
 		return
 	}
-	// Bind JSON body
 	if err := c.ShouldBindJSON(&bodyParams); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -304,55 +793,93 @@ func (r *Router) setModuleMark(c *gin.Context) {
 		return
 	}
 
-	ctx := c.Request.Context()
+	patch := &rooms.RoomPatch{
+		MaxAnchors:   bodyParams.MaxAnchors,
+		Recording:    bodyParams.Recording,
+		MixProfile:   bodyParams.MixProfile,
+		ScheduledAt:  bodyParams.ScheduledAt,
+		Locale:       bodyParams.Locale,
+		Tags:         bodyParams.Tags,
+		PublishSlots: bodyParams.PublishSlots,
+		JoinQueue:    bodyParams.JoinQueue,
+	}
+	if patch.Empty() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "No fields to update",
+		})
+		return
+	}
 
-	// Convert label string to MarkLabel type
-	var markLabel constants.MarkLabel
-	switch bodyParams.Label {
-	case "ready":
-		markLabel = constants.MarkLabelReady
-	case "cordon":
-		markLabel = constants.MarkLabelCordon
-	case "draining":
-		markLabel = constants.MarkLabelDraining
-	case "drained":
-		markLabel = constants.MarkLabelDrained
-	case "unready":
-		markLabel = constants.MarkLabelUnready
-	default:
+	room, err := r.roomService.UpdateRoom(c.Request.Context(), uriParams.RoomID, patch)
+	if err != nil {
+		var roomNotFoundErr *rooms.RoomNotFoundError
+		var conflictErr *rooms.RoomUpdateConflictError
+		switch {
+		case errors.As(err, &roomNotFoundErr):
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+		case errors.As(err, &conflictErr):
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   conflictErr.Error(),
+			})
+		default:
+			r.logger.Error("Failed to update room", log.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to update room",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"room":    room,
+	})
+}
+
+func (r *Router) listRooms(c *gin.Context) {
+	var query ListRoomsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   "Invalid label value",
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+	tags, ok := query.tags()
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "tag must be key:value",
 		})
 		return
 	}
 
-	// Set the module mark
-	if err := r.roomStore.SetModuleMark(ctx, uriParams.ModuleType, uriParams.ModuleID, markLabel, bodyParams.TTL); err != nil {
-		r.logger.Error("Failed to set module mark", log.Error(err))
+	result, err := r.roomService.ListRooms(c.Request.Context(), tags)
+	if err != nil {
+		r.logger.Error("Failed to list rooms", log.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"error":   "Failed to set module mark",
+			"error":   "Failed to list rooms",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Module mark set successfully",
-		"module": gin.H{
-			"type":  uriParams.ModuleType,
-			"id":    uriParams.ModuleID,
-			"label": bodyParams.Label,
-			"ttl":   bodyParams.TTL,
-		},
+		"count":   result.Count,
+		"rooms":   result.Rooms,
 	})
 }
 
-func (r *Router) deleteModuleMark(c *gin.Context) {
-	var req ModuleMarkURI
-
-	// Validate the request
+func (r *Router) deleteRoom(c *gin.Context) {
+	var req DeleteRoomRequest
 	if err := c.ShouldBindUri(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -362,24 +889,692 @@ func (r *Router) deleteModuleMark(c *gin.Context) {
 		return
 	}
 
+	roomID := req.RoomID
 	ctx := c.Request.Context()
 
-	// Delete the module mark
-	if err := r.roomStore.DeleteModuleMark(ctx, req.ModuleType, req.ModuleID); err != nil {
-		r.logger.Error("Failed to delete module mark", log.Error(err))
+	result, err := r.roomService.DeleteRoom(ctx, roomID)
+	if err != nil {
+		var roomNotFoundErr *rooms.RoomNotFoundError
+		if errors.As(err, &roomNotFoundErr) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		r.logger.Error("Failed to delete room", log.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"error":   "Failed to delete module mark",
+			"error":   "Failed to delete room",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Module mark deleted successfully",
-		"module": gin.H{
-			"type": req.ModuleType,
-			"id":   req.ModuleID,
+		"message": result.Message,
+	})
+}
+
+// endRoom starts ending the room and returns its end stage, polled through getRoom
+func (r *Router) endRoom(c *gin.Context) {
+	var req EndRoomRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	room, err := r.roomService.EndRoom(c.Request.Context(), req.RoomID)
+	if err != nil {
+		var roomNotFoundErr *rooms.RoomNotFoundError
+		var conflictErr *rooms.RoomUpdateConflictError
+		switch {
+		case errors.As(err, &roomNotFoundErr):
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+		case errors.As(err, &conflictErr):
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   conflictErr.Error(),
+			})
+		default:
+			r.logger.Error("Failed to end room", log.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to end room",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"room":    room,
+	})
+}
+
+// freezeRoom returns the handler freezing or unfreezing the room
+func (r *Router) freezeRoom(frozen bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req FreezeRoomRequest
+		if err := c.ShouldBindUri(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Validation failed",
+				"details": validation.FormatValidationError(err),
+			})
+			return
+		}
+
+		room, err := r.roomService.FreezeRoom(c.Request.Context(), req.RoomID, frozen)
+		if err != nil {
+			var roomNotFoundErr *rooms.RoomNotFoundError
+			var conflictErr *rooms.RoomUpdateConflictError
+			switch {
+			case errors.As(err, &roomNotFoundErr):
+				c.JSON(http.StatusNotFound, gin.H{
+					"success": false,
+					"error":   err.Error(),
+				})
+			case errors.As(err, &conflictErr):
+				c.JSON(http.StatusConflict, gin.H{
+					"success": false,
+					"error":   conflictErr.Error(),
+				})
+			default:
+				r.logger.Error("Failed to freeze room", log.Bool("frozen", frozen), log.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"error":   "Failed to freeze room",
+				})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"room":    room,
+		})
+	}
+}
+
+func (r *Router) linkRoom(c *gin.Context) {
+	var uriParams LinkRoomURI
+	var bodyParams LinkRoomBody
+
+	if err := c.ShouldBindUri(&uriParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+	if err := c.ShouldBindJSON(&bodyParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+	if uriParams.RoomID == bodyParams.TargetRoomID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Cannot link a room to itself",
+		})
+		return
+	}
+
+	link, err := r.roomService.LinkRoom(c.Request.Context(), uriParams.RoomID, bodyParams.TargetRoomID, bodyParams.AnchorID)
+	if err != nil {
+		var roomNotFoundErr *rooms.RoomNotFoundError
+		var linkExistsErr *rooms.RoomLinkExistsError
+		switch {
+		case errors.As(err, &roomNotFoundErr):
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+		case errors.As(err, &linkExistsErr):
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+		default:
+			r.logger.Error("Failed to link room", log.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to link room",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"link":    link,
+	})
+}
+
+func (r *Router) unlinkRoom(c *gin.Context) {
+	var req UnlinkRoomRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	if err := r.roomService.UnlinkRoom(c.Request.Context(), req.RoomID, req.TargetRoomID); err != nil {
+		var linkNotFoundErr *rooms.RoomLinkNotFoundError
+		if errors.As(err, &linkNotFoundErr) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		r.logger.Error("Failed to unlink room", log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to unlink room",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Room " + req.RoomID + " unlinked from room " + req.TargetRoomID,
+	})
+}
+
+func (r *Router) getStats(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	stats, err := r.roomService.GetStats(ctx)
+	if err != nil {
+		r.logger.Error("Failed to get stats", log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get stats",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"stats":   stats,
+	})
+}
+
+func (r *Router) getRoomListeners(c *gin.Context) {
+	var req GetRoomRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	count, err := r.listeners.Estimate(c.Request.Context(), req.RoomID)
+	if err != nil {
+		r.logger.Error("Failed to estimate listeners", log.String("roomId", req.RoomID), log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to estimate listeners",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"listeners": rooms.RoomListeners{RoomID: req.RoomID, Listeners: count},
+	})
+}
+
+func (r *Router) getRoomUsage(c *gin.Context) {
+	var req GetRoomRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+	var query GetRoomUsageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	to := query.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := query.From
+	if from.IsZero() {
+		from = to.Add(-defaultUsageRange)
+	}
+
+	roomUsage, err := r.usage.Usage(c.Request.Context(), req.RoomID, from, to)
+	if errors.Is(err, usage.ErrInvalidRange) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "from must be before to and within 31 days of it",
+		})
+		return
+	}
+	if err != nil {
+		r.logger.Error("Failed to read usage", log.String("roomId", req.RoomID), log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to read usage",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"usage":   roomUsage,
+	})
+}
+
+func (r *Router) getHousekeeping(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"dryRun":  r.resManager.HousekeepDryRun(),
+	})
+}
+
+func (r *Router) setHousekeeping(c *gin.Context) {
+	var req SetHousekeepingBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	r.resManager.SetHousekeepDryRun(*req.DryRun)
+	r.logger.Info("Housekeeping mode updated", log.Bool("dryRun", *req.DryRun))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"dryRun":  *req.DryRun,
+	})
+}
+
+func (r *Router) createAPIKey(c *gin.Context) {
+	var req CreateAPIKeyBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	key, token, err := auth.NewAPIKey(req.Tenant, req.Scopes, req.RateLimit)
+	if err == nil {
+		err = r.apiKeyStore.CreateAPIKey(c.Request.Context(), key)
+	}
+	if err != nil {
+		r.logger.Error("Failed to create API key", log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to create API key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"key":     token,
+		"apiKey":  withoutHash(key),
+	})
+}
+
+func (r *Router) listAPIKeys(c *gin.Context) {
+	keys, err := r.apiKeyStore.ListAPIKeys(c.Request.Context())
+	if err != nil {
+		r.logger.Error("Failed to list API keys", log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to list API keys",
+		})
+		return
+	}
+
+	result := make([]*rooms.APIKey, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, withoutHash(key))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"count":   len(result),
+		"apiKeys": result,
+	})
+}
+
+func (r *Router) deleteAPIKey(c *gin.Context) {
+	var req APIKeyURI
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	deleted, err := r.apiKeyStore.DeleteAPIKey(c.Request.Context(), req.KeyID)
+	if err != nil {
+		r.logger.Error("Failed to delete API key", log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to delete API key",
+		})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "API key " + req.KeyID + " not found",
+		})
+		return
+	}
+	// other rooms instances accept the key until their cache expires
+	r.auth.Forget(req.KeyID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "API key " + req.KeyID + " revoked",
+	})
+}
+
+func (r *Router) getQuota(c *gin.Context) {
+	principal := svcauth.PrincipalFrom(c)
+	if principal == nil || principal.Tenant == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Caller has no tenant",
+		})
+		return
+	}
+	r.respondQuota(c, principal.Tenant)
+}
+
+func (r *Router) getTenantQuota(c *gin.Context) {
+	var req TenantURI
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+	r.respondQuota(c, req.Tenant)
+}
+
+func (r *Router) setTenantQuota(c *gin.Context) {
+	var uri TenantURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+	var req SetQuotaBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	quota := &rooms.Quota{MaxRooms: req.MaxRooms, MaxOnAirRooms: req.MaxOnAirRooms}
+	if err := r.roomStore.SetQuota(c.Request.Context(), uri.Tenant, quota); err != nil {
+		r.logger.Error("Failed to set quota", log.String("tenant", uri.Tenant), log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to set quota",
+		})
+		return
+	}
+	r.respondQuota(c, uri.Tenant)
+}
+
+// respondQuota responds the quota and usage of tenant
+func (r *Router) respondQuota(c *gin.Context, tenant string) {
+	usage, err := r.roomStore.GetQuotaUsage(c.Request.Context(), tenant)
+	if err != nil {
+		r.logger.Error("Failed to get quota usage", log.String("tenant", tenant), log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to get quota usage",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"quota":   usage,
+	})
+}
+
+// withoutHash copies the key for responses, the secret hash never leaves the service
+func withoutHash(key *rooms.APIKey) *rooms.APIKey {
+	result := *key
+	result.Hash = ""
+	return &result
+}
+
+func (r *Router) healthCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "ok",
+		"service":   "rooms",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+func (r *Router) listModules(c *gin.Context) {
+	var req ListModulesURI
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	modules, err := r.roomStore.ListModuleStatus(c.Request.Context(), req.ModuleType)
+	if err != nil {
+		r.logger.Error("Failed to list modules", log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to list modules",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"type":    req.ModuleType,
+		"count":   len(modules),
+		"modules": modules,
+	})
+}
+
+func (r *Router) setModuleMark(c *gin.Context) {
+	var uriParams ModuleMarkURI
+	var bodyParams SetModuleMarkBody
+
+	// Bind URI params
+	if err := c.ShouldBindUri(&uriParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+	// Bind JSON body
+	if err := c.ShouldBindJSON(&bodyParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Convert label string to MarkLabel type
+	var markLabel constants.MarkLabel
+	switch bodyParams.Label {
+	case "ready":
+		markLabel = constants.MarkLabelReady
+	case "cordon":
+		markLabel = constants.MarkLabelCordon
+	case "draining":
+		markLabel = constants.MarkLabelDraining
+	case "drained":
+		markLabel = constants.MarkLabelDrained
+	case "unready":
+		markLabel = constants.MarkLabelUnready
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid label value",
+		})
+		return
+	}
+
+	// Set the module mark
+	if err := r.roomStore.SetModuleMark(ctx, uriParams.ModuleType, uriParams.ModuleID, markLabel, bodyParams.TTL); err != nil {
+		r.logger.Error("Failed to set module mark", log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to set module mark",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Module mark set successfully",
+		"module": gin.H{
+			"type":  uriParams.ModuleType,
+			"id":    uriParams.ModuleID,
+			"label": bodyParams.Label,
+			"ttl":   bodyParams.TTL,
+		},
+	})
+}
+
+func (r *Router) deleteModuleMark(c *gin.Context) {
+	var req ModuleMarkURI
+
+	// Validate the request
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Delete the module mark
+	if err := r.roomStore.DeleteModuleMark(ctx, req.ModuleType, req.ModuleID); err != nil {
+		r.logger.Error("Failed to delete module mark", log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to delete module mark",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Module mark deleted successfully",
+		"module": gin.H{
+			"type": req.ModuleType,
+			"id":   req.ModuleID,
+		},
+	})
+}
+
+func (r *Router) reportDegraded(c *gin.Context) {
+	var uriParams ModuleMarkURI
+	var bodyParams ReportModuleDegradedBody
+
+	if err := c.ShouldBindUri(&uriParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+	if err := c.ShouldBindJSON(&bodyParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	degraded := &etcdstate.DegradedData{
+		Reason:   bodyParams.Reason,
+		Reporter: bodyParams.Reporter,
+		Since:    time.Now().UTC(),
+	}
+	if err := r.roomStore.SetModuleDegraded(c.Request.Context(), uriParams.ModuleType, uriParams.ModuleID, degraded, bodyParams.TTL); err != nil {
+		r.logger.Error("Failed to report module degraded", log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to report module degraded",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"module": gin.H{
+			"type": uriParams.ModuleType,
+			"id":   uriParams.ModuleID,
+			"ttl":  bodyParams.TTL,
 		},
 	})
 }
@@ -5,29 +5,81 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	gomock "go.uber.org/mock/gomock"
 
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/pin"
+	"github.com/imtaco/audio-rtc-exp/internal/usage"
 	"github.com/imtaco/audio-rtc-exp/rooms"
+	"github.com/imtaco/audio-rtc-exp/rooms/auth"
+	"github.com/imtaco/audio-rtc-exp/rooms/idgen"
 	"github.com/imtaco/audio-rtc-exp/rooms/mocks"
 )
 
+var testPinPolicy = &pin.Policy{Format: pin.FormatHex, Length: 6}
+
+func testIDProvider(t *testing.T) rooms.IDProvider {
+	provider, err := idgen.New(&idgen.Config{Provider: idgen.ProviderHex}, log.NewTest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
 func setupRouter(t *testing.T) (*Router, *mocks.MockRoomService, *mocks.MockRoomStore) {
 	gin.SetMode(gin.TestMode)
 
 	ctrl := gomock.NewController(t)
 	mockService := mocks.NewMockRoomService(ctrl)
 	mockStore := mocks.NewMockRoomStore(ctrl)
-	router := NewRouter(mockService, mockStore, log.NewTest(t))
+	router := NewRouter(
+		mockService,
+		mockStore,
+		mocks.NewMockAPIKeyStore(ctrl),
+		mocks.NewMockResourceManager(ctrl),
+		testPinPolicy,
+		testIDProvider(t),
+		nil,
+		nil,
+		nil,
+		log.NewTest(t),
+	)
 	return router, mockService, mockStore
 }
 
+func setupHousekeepingRouter(t *testing.T) (*Router, *mocks.MockResourceManager) {
+	gin.SetMode(gin.TestMode)
+
+	ctrl := gomock.NewController(t)
+	mockResManager := mocks.NewMockResourceManager(ctrl)
+	router := NewRouter(
+		mocks.NewMockRoomService(ctrl),
+		mocks.NewMockRoomStore(ctrl),
+		mocks.NewMockAPIKeyStore(ctrl),
+		mockResManager,
+		testPinPolicy,
+		testIDProvider(t),
+		nil,
+		nil,
+		nil,
+		log.NewTest(t),
+	)
+	return router, mockResManager
+}
+
 func TestHealthCheck(t *testing.T) {
 	router, _, _ := setupRouter(t)
 
@@ -44,6 +96,30 @@ func TestHealthCheck(t *testing.T) {
 	assert.Equal(t, "rooms", response["service"])
 }
 
+func TestAPISpec(t *testing.T) {
+	router, _, _ := setupRouter(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/spec", nil)
+	router.Handler().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "3.0.3", response["openapi"])
+	assert.Contains(t, response["paths"], "/api/rooms/{roomId}")
+}
+
+// anyPin matches the params of a room created with a generated pin
+func anyPin(want rooms.CreateRoomParams) gomock.Matcher {
+	return gomock.Cond(func(params *rooms.CreateRoomParams) bool {
+		want.Pin = params.Pin
+		return reflect.DeepEqual(&want, params)
+	})
+}
+
 func TestCreateRoom(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		router, mockService, _ := setupRouter(t)
@@ -56,7 +132,7 @@ func TestCreateRoom(t *testing.T) {
 			HLSURL: "http://example.com/hls/test-room/index.m3u8",
 		}
 
-		mockService.EXPECT().CreateRoom(gomock.Any(), roomID, pin, defaultMaxAnchors).Return(expectedRoom, nil)
+		mockService.EXPECT().CreateRoom(gomock.Any(), roomID, &rooms.CreateRoomParams{Pin: pin, MaxAnchors: defaultMaxAnchors}).Return(expectedRoom, nil)
 		mockService.EXPECT().StartLive(gomock.Any(), roomID).Return(nil)
 
 		payload := map[string]string{
@@ -81,13 +157,30 @@ func TestCreateRoom(t *testing.T) {
 		assert.Equal(t, roomID, roomData["roomId"])
 	})
 
+	t.Run("PinNotMatchingPolicy", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		jsonValue, _ := json.Marshal(map[string]string{
+			"roomId": "test-room",
+			"pin":    "ZZZZZZ",
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "pin must be hex")
+	})
+
 	t.Run("RoomExists", func(t *testing.T) {
 		router, mockService, _ := setupRouter(t)
 
 		roomID := "existing-room"
 		pin := "123456"
 
-		mockService.EXPECT().CreateRoom(gomock.Any(), roomID, pin, defaultMaxAnchors).Return(nil, &rooms.RoomExistsError{RoomID: roomID})
+		mockService.EXPECT().CreateRoom(gomock.Any(), roomID, &rooms.CreateRoomParams{Pin: pin, MaxAnchors: defaultMaxAnchors}).Return(nil, &rooms.RoomExistsError{RoomID: roomID})
 
 		payload := map[string]string{
 			"roomId": roomID,
@@ -108,7 +201,7 @@ func TestCreateRoom(t *testing.T) {
 		roomID := "test-room"
 		pin := "123456"
 
-		mockService.EXPECT().CreateRoom(gomock.Any(), roomID, pin, defaultMaxAnchors).Return(nil, errors.New("internal error"))
+		mockService.EXPECT().CreateRoom(gomock.Any(), roomID, &rooms.CreateRoomParams{Pin: pin, MaxAnchors: defaultMaxAnchors}).Return(nil, errors.New("internal error"))
 
 		payload := map[string]string{
 			"roomId": roomID,
@@ -133,7 +226,7 @@ func TestCreateRoom(t *testing.T) {
 			Pin:    pin,
 		}
 
-		mockService.EXPECT().CreateRoom(gomock.Any(), roomID, pin, defaultMaxAnchors).Return(expectedRoom, nil)
+		mockService.EXPECT().CreateRoom(gomock.Any(), roomID, &rooms.CreateRoomParams{Pin: pin, MaxAnchors: defaultMaxAnchors}).Return(expectedRoom, nil)
 		mockService.EXPECT().StartLive(gomock.Any(), roomID).Return(errors.New("start live failed"))
 
 		payload := map[string]string{
@@ -153,11 +246,12 @@ func TestCreateRoom(t *testing.T) {
 		router, mockService, _ := setupRouter(t)
 
 		// Expect CreateRoom to be called with ANY string for roomID and pin, and default maxAnchors
-		mockService.EXPECT().CreateRoom(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, roomID, pin string, maxAnchors int) (*rooms.RoomResponse, error) {
-			assert.Len(t, roomID, 20)                      // Generated roomID is 10 bytes = 20 hex chars
-			assert.Len(t, pin, 6)                          // Generated pin is 3 bytes = 6 hex chars
-			assert.Equal(t, defaultMaxAnchors, maxAnchors) // Should use default value
-			return &rooms.RoomResponse{RoomID: roomID, Pin: pin}, nil
+		mockService.EXPECT().CreateRoom(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, roomID string, params *rooms.CreateRoomParams) (*rooms.RoomResponse, error) {
+			assert.Len(t, roomID, 20)                             // Generated roomID is 10 bytes = 20 hex chars
+			assert.Len(t, params.Pin, 6)                          // Generated pin is 3 bytes = 6 hex chars
+			assert.Equal(t, defaultMaxAnchors, params.MaxAnchors) // Should use default value
+			assert.Zero(t, params.MaxBitrate)                     // No cap unless requested
+			return &rooms.RoomResponse{RoomID: roomID, Pin: params.Pin}, nil
 		})
 		mockService.EXPECT().StartLive(gomock.Any(), gomock.Any()).Return(nil)
 
@@ -171,6 +265,45 @@ func TestCreateRoom(t *testing.T) {
 		assert.Equal(t, http.StatusCreated, w.Code)
 	})
 
+	t.Run("ExternalID", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().
+			CreateRoom(gomock.Any(), gomock.Any(), anyPin(rooms.CreateRoomParams{ExternalID: "cms-42", MaxAnchors: defaultMaxAnchors})).
+			Return(&rooms.RoomResponse{RoomID: "generated", ExternalID: "cms-42"}, nil)
+		mockService.EXPECT().StartLive(gomock.Any(), gomock.Any()).Return(nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms", strings.NewReader(`{"externalId":"cms-42"}`))
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("ExternalIDTaken", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().
+			CreateRoom(gomock.Any(), "test-room", anyPin(rooms.CreateRoomParams{ExternalID: "cms-42", MaxAnchors: defaultMaxAnchors})).
+			Return(nil, fmt.Errorf("failed to create room: %w", &rooms.ExternalIDExistsError{ExternalID: "cms-42"}))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms", strings.NewReader(`{"roomId":"test-room","externalId":"cms-42"}`))
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("InvalidExternalID", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms", strings.NewReader(`{"externalId":"a/b"}`))
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
 	t.Run("ValidationError", func(t *testing.T) {
 		router, _, _ := setupRouter(t)
 
@@ -194,7 +327,7 @@ func TestCreateRoom(t *testing.T) {
 			HLSURL: "http://example.com/hls/test-room/index.m3u8",
 		}
 
-		mockService.EXPECT().CreateRoom(gomock.Any(), roomID, pin, customMaxAnchors).Return(expectedRoom, nil)
+		mockService.EXPECT().CreateRoom(gomock.Any(), roomID, &rooms.CreateRoomParams{Pin: pin, MaxAnchors: customMaxAnchors}).Return(expectedRoom, nil)
 		mockService.EXPECT().StartLive(gomock.Any(), roomID).Return(nil)
 
 		payload := map[string]any{
@@ -217,6 +350,177 @@ func TestCreateRoom(t *testing.T) {
 		assert.Equal(t, true, response["success"])
 	})
 
+	t.Run("CustomMaxBitrate", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		roomID := "test-room"
+		pin := "123456"
+		customMaxBitrate := 32000
+		expectedRoom := &rooms.RoomResponse{
+			RoomID:     roomID,
+			Pin:        pin,
+			MaxBitrate: customMaxBitrate,
+		}
+
+		mockService.EXPECT().CreateRoom(gomock.Any(), roomID, &rooms.CreateRoomParams{Pin: pin, MaxAnchors: defaultMaxAnchors, MaxBitrate: customMaxBitrate}).Return(expectedRoom, nil)
+		mockService.EXPECT().StartLive(gomock.Any(), roomID).Return(nil)
+
+		payload := map[string]any{
+			"roomId":     roomID,
+			"pin":        pin,
+			"maxBitrate": customMaxBitrate,
+		}
+		jsonValue, _ := json.Marshal(payload)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("DVRWindow", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		roomID := "test-room"
+		pin := "123456"
+		expectedRoom := &rooms.RoomResponse{
+			RoomID:    roomID,
+			Pin:       pin,
+			DVRWindow: 1800,
+		}
+
+		mockService.EXPECT().CreateRoom(gomock.Any(), roomID, &rooms.CreateRoomParams{Pin: pin, MaxAnchors: defaultMaxAnchors, DVRWindow: 1800}).Return(expectedRoom, nil)
+		mockService.EXPECT().StartLive(gomock.Any(), roomID).Return(nil)
+
+		w := httptest.NewRecorder()
+		body := `{"roomId":"test-room","pin":"123456","dvrWindow":1800}`
+		req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"dvrWindow":1800`)
+	})
+
+	t.Run("MaxDuration", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		roomID := "test-room"
+		pin := "123456"
+		expectedRoom := &rooms.RoomResponse{
+			RoomID:      roomID,
+			Pin:         pin,
+			MaxDuration: 3600,
+		}
+
+		mockService.EXPECT().CreateRoom(gomock.Any(), roomID, &rooms.CreateRoomParams{Pin: pin, MaxAnchors: defaultMaxAnchors, MaxDuration: 3600}).Return(expectedRoom, nil)
+		mockService.EXPECT().StartLive(gomock.Any(), roomID).Return(nil)
+
+		w := httptest.NewRecorder()
+		body := `{"roomId":"test-room","pin":"123456","maxDuration":3600}`
+		req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"maxDuration":3600`)
+	})
+
+	t.Run("InvalidDVRWindow", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := httptest.NewRecorder()
+		body := `{"roomId":"test-room","pin":"123456","dvrWindow":86400}`
+		req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("InvalidMaxBitrate", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		payload := map[string]any{
+			"roomId":     "test-room",
+			"pin":        "123456",
+			"maxBitrate": 1000000, // Invalid: exceeds Opus max of 510000
+		}
+		jsonValue, _ := json.Marshal(payload)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Audio", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		on, off := true, false
+		audio := &etcdstate.AudioParams{FEC: &on, DTX: &off, MaxAverageBitrate: 24000}
+		mockService.EXPECT().CreateRoom(gomock.Any(), "test-room", &rooms.CreateRoomParams{Pin: "123456", MaxAnchors: defaultMaxAnchors, Audio: audio}).
+			Return(&rooms.RoomResponse{RoomID: "test-room", Audio: audio}, nil)
+		mockService.EXPECT().StartLive(gomock.Any(), "test-room").Return(nil)
+
+		w := httptest.NewRecorder()
+		body := `{"roomId":"test-room","pin":"123456","audio":{"fec":true,"dtx":false,"maxAverageBitrate":24000}}`
+		req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"audio":{"fec":true,"dtx":false,"maxAverageBitrate":24000}`)
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		tags := map[string]string{"show": "morning", "env": "prod"}
+		mockService.EXPECT().CreateRoom(gomock.Any(), "test-room", &rooms.CreateRoomParams{Pin: "123456", MaxAnchors: defaultMaxAnchors, Tags: tags}).
+			Return(&rooms.RoomResponse{RoomID: "test-room", Tags: tags}, nil)
+		mockService.EXPECT().StartLive(gomock.Any(), "test-room").Return(nil)
+
+		w := httptest.NewRecorder()
+		body := `{"roomId":"test-room","pin":"123456","tags":{"show":"morning","env":"prod"}}`
+		req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"tags":{"env":"prod","show":"morning"}`)
+	})
+
+	t.Run("InvalidTags", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		for _, tags := range []string{`{"a/b":"c"}`, `{"a":""}`, `{"a:b":"c"}`, `{"a":"b/c"}`} {
+			w := httptest.NewRecorder()
+			body := `{"roomId":"test-room","pin":"123456","tags":` + tags + `}`
+			req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			router.Handler().ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, tags)
+		}
+	})
+
+	t.Run("InvalidAudioBitrate", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := httptest.NewRecorder()
+		body := `{"roomId":"test-room","pin":"123456","audio":{"maxAverageBitrate":1000}}`
+		req, _ := http.NewRequest("POST", "/api/rooms", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
 	t.Run("InvalidMaxAnchors", func(t *testing.T) {
 		router, _, _ := setupRouter(t)
 
@@ -303,92 +607,624 @@ func TestGetRoom(t *testing.T) {
 	})
 }
 
-func TestListRooms(t *testing.T) {
-	router, mockService, _ := setupRouter(t)
-
-	expectedResponse := &rooms.ListRoomsResponse{
-		Count: 1,
-		Rooms: []*rooms.RoomResponse{
-			{RoomID: "room1", HLSURL: "url1"},
-		},
-	}
+func TestGetRoomDetail(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
 
-	mockService.EXPECT().ListRooms(gomock.Any()).Return(expectedResponse, nil)
+		detail := &rooms.RoomDetail{
+			Room:   &rooms.RoomResponse{RoomID: "test-room"},
+			FFmpeg: rooms.FFmpegDegraded,
+			Errors: map[string]string{rooms.RoomDetailUsers: "timeout"},
+		}
+		mockService.EXPECT().GetRoomDetail(gomock.Any(), "test-room").Return(detail, nil)
 
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/api/rooms", nil)
-	router.Handler().ServeHTTP(w, req)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/rooms/test-room/full", nil)
+		router.Handler().ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusOK, w.Code)
 
-	var response map[string]any
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Equal(t, true, response["success"])
-	assert.Equal(t, float64(1), response["count"])
+		var response struct {
+			Success bool             `json:"success"`
+			Detail  rooms.RoomDetail `json:"detail"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.Success)
+		assert.Equal(t, *detail, response.Detail)
+	})
 
-	t.Run("InternalError", func(t *testing.T) {
+	t.Run("NotFound", func(t *testing.T) {
 		router, mockService, _ := setupRouter(t)
 
-		mockService.EXPECT().ListRooms(gomock.Any()).Return(nil, errors.New("internal error"))
+		mockService.EXPECT().GetRoomDetail(gomock.Any(), "unknown-room").
+			Return(nil, &rooms.RoomNotFoundError{RoomID: "unknown-room"})
 
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("GET", "/api/rooms", nil)
+		req, _ := http.NewRequest("GET", "/api/rooms/unknown-room/full", nil)
 		router.Handler().ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
 }
 
-func TestDeleteRoom(t *testing.T) {
+func TestGetRoomTimeline(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		router, mockService, _ := setupRouter(t)
 
-		roomID := "test-room"
-		mockService.EXPECT().DeleteRoom(gomock.Any(), roomID).Return(&rooms.DeleteRoomResponse{Message: "deleted"}, nil)
+		events := []etcdstate.TimelineEvent{
+			{Type: "created", At: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Source: "rooms"},
+			{Type: "mixerAssigned", At: time.Date(2026, 1, 2, 3, 4, 6, 0, time.UTC), Source: "mixer:mixer-1", Detail: "mixer-1"},
+		}
+		mockService.EXPECT().GetTimeline(gomock.Any(), "test-room").Return(events, nil)
 
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("DELETE", "/api/rooms/"+roomID, nil)
+		req, _ := http.NewRequest("GET", "/api/rooms/test-room/timeline", nil)
 		router.Handler().ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Success bool                      `json:"success"`
+			Events  []etcdstate.TimelineEvent `json:"events"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.Success)
+		assert.Equal(t, events, response.Events)
 	})
 
 	t.Run("NotFound", func(t *testing.T) {
 		router, mockService, _ := setupRouter(t)
 
-		roomID := "unknown-room"
-		mockService.EXPECT().DeleteRoom(gomock.Any(), roomID).Return(nil, &rooms.RoomNotFoundError{RoomID: roomID})
+		mockService.EXPECT().GetTimeline(gomock.Any(), "unknown-room").
+			Return(nil, &rooms.RoomNotFoundError{RoomID: "unknown-room"})
 
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("DELETE", "/api/rooms/"+roomID, nil)
+		req, _ := http.NewRequest("GET", "/api/rooms/unknown-room/timeline", nil)
 		router.Handler().ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
+}
 
-	t.Run("InternalError", func(t *testing.T) {
+func TestGetExternalRoom(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
 		router, mockService, _ := setupRouter(t)
 
-		roomID := "test-room"
-		mockService.EXPECT().DeleteRoom(gomock.Any(), roomID).Return(nil, errors.New("internal error"))
+		mockService.EXPECT().
+			GetRoomByExternalID(gomock.Any(), "cms-42").
+			Return(&rooms.RoomResponse{RoomID: "test-room", ExternalID: "cms-42"}, nil)
 
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("DELETE", "/api/rooms/"+roomID, nil)
+		req, _ := http.NewRequest("GET", "/api/external/rooms/cms-42", nil)
 		router.Handler().ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "test-room", response["room"].(map[string]any)["roomId"])
 	})
 
-	t.Run("InvalidID", func(t *testing.T) {
+	t.Run("NotFound", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().
+			GetRoomByExternalID(gomock.Any(), "cms-43").
+			Return(nil, &rooms.RoomNotFoundError{RoomID: "cms-43"})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/external/rooms/cms-43", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestUpdateRoom(t *testing.T) {
+	patchRoom := func(router *Router, roomID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/api/rooms/"+roomID, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().
+			UpdateRoom(gomock.Any(), "test-room", gomock.Any()).
+			DoAndReturn(func(_ context.Context, roomID string, patch *rooms.RoomPatch) (*rooms.RoomResponse, error) {
+				assert.Equal(t, 4, *patch.MaxAnchors)
+				assert.Equal(t, "music", *patch.MixProfile)
+				assert.Nil(t, patch.Recording)
+				assert.Equal(t, 2026, patch.ScheduledAt.Year())
+				return &rooms.RoomResponse{RoomID: roomID, MaxAnchors: 4, MixProfile: "music"}, nil
+			})
+
+		w := patchRoom(router, "test-room", `{"maxAnchors":4,"mixProfile":"music","scheduledAt":"2026-01-02T15:04:05Z"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, true, response["success"])
+		assert.Equal(t, float64(4), response["room"].(map[string]any)["maxAnchors"])
+	})
+
+	t.Run("NoFields", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := patchRoom(router, "test-room", `{}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("ClearTags", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().
+			UpdateRoom(gomock.Any(), "test-room", gomock.Any()).
+			DoAndReturn(func(_ context.Context, roomID string, patch *rooms.RoomPatch) (*rooms.RoomResponse, error) {
+				assert.NotNil(t, patch.Tags)
+				assert.Empty(t, patch.Tags)
+				return &rooms.RoomResponse{RoomID: roomID}, nil
+			})
+
+		w := patchRoom(router, "test-room", `{"tags":{}}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("PublishSlots", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().
+			UpdateRoom(gomock.Any(), "test-room", gomock.Any()).
+			DoAndReturn(func(_ context.Context, roomID string, patch *rooms.RoomPatch) (*rooms.RoomResponse, error) {
+				if assert.NotNil(t, patch.PublishSlots) {
+					assert.Equal(t, 0, *patch.PublishSlots)
+				}
+				return &rooms.RoomResponse{RoomID: roomID}, nil
+			})
+
+		w := patchRoom(router, "test-room", `{"publishSlots":0}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("InvalidPublishSlots", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := patchRoom(router, "test-room", `{"publishSlots":33}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("JoinQueue", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().
+			UpdateRoom(gomock.Any(), "test-room", gomock.Any()).
+			DoAndReturn(func(_ context.Context, roomID string, patch *rooms.RoomPatch) (*rooms.RoomResponse, error) {
+				if assert.NotNil(t, patch.JoinQueue) {
+					assert.Equal(t, 20, *patch.JoinQueue)
+				}
+				return &rooms.RoomResponse{RoomID: roomID}, nil
+			})
+
+		w := patchRoom(router, "test-room", `{"joinQueue":20}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("InvalidJoinQueue", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := patchRoom(router, "test-room", `{"joinQueue":101}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("InvalidMaxAnchors", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := patchRoom(router, "test-room", `{"maxAnchors":9}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().
+			UpdateRoom(gomock.Any(), "test-room", gomock.Any()).
+			Return(nil, &rooms.RoomNotFoundError{RoomID: "test-room"})
+
+		w := patchRoom(router, "test-room", `{"recording":true}`)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().
+			UpdateRoom(gomock.Any(), "test-room", gomock.Any()).
+			Return(nil, fmt.Errorf("failed to update room: %w", &rooms.RoomUpdateConflictError{RoomID: "test-room"}))
+
+		w := patchRoom(router, "test-room", `{"recording":false}`)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+func TestListRooms(t *testing.T) {
+	router, mockService, _ := setupRouter(t)
+
+	expectedResponse := &rooms.ListRoomsResponse{
+		Count: 1,
+		Rooms: []*rooms.RoomResponse{
+			{RoomID: "room1", HLSURL: "url1"},
+		},
+	}
+
+	mockService.EXPECT().ListRooms(gomock.Any(), gomock.Nil()).Return(expectedResponse, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/rooms", nil)
+	router.Handler().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, true, response["success"])
+	assert.Equal(t, float64(1), response["count"])
+
+	t.Run("InternalError", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().ListRooms(gomock.Any(), gomock.Nil()).Return(nil, errors.New("internal error"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/rooms", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("ByTags", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().
+			ListRooms(gomock.Any(), map[string]string{"show": "morning", "env": "prod:eu"}).
+			Return(&rooms.ListRoomsResponse{Rooms: []*rooms.RoomResponse{}}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/rooms?tag=show:morning&tag=env:prod:eu", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("InvalidTag", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		for _, tag := range []string{"show", "show:", ":morning", "a/b:c", "a=b:c"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/rooms?tag="+url.QueryEscape(tag), nil)
+			router.Handler().ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, tag)
+		}
+	})
+}
+
+func TestDeleteRoom(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		roomID := "test-room"
+		mockService.EXPECT().DeleteRoom(gomock.Any(), roomID).Return(&rooms.DeleteRoomResponse{Message: "deleted"}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/api/rooms/"+roomID, nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		roomID := "unknown-room"
+		mockService.EXPECT().DeleteRoom(gomock.Any(), roomID).Return(nil, &rooms.RoomNotFoundError{RoomID: roomID})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/api/rooms/"+roomID, nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("InternalError", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		roomID := "test-room"
+		mockService.EXPECT().DeleteRoom(gomock.Any(), roomID).Return(nil, errors.New("internal error"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/api/rooms/"+roomID, nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
 		router, _, _ := setupRouter(t)
 
 		roomID := "invalid@id"
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("DELETE", "/api/rooms/"+roomID, nil)
+		req, _ := http.NewRequest("DELETE", "/api/rooms/"+roomID, nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestEndRoom(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().EndRoom(gomock.Any(), "test-room").Return(&rooms.RoomResponse{
+			RoomID:   "test-room",
+			EndStage: constants.EndStageNotifying,
+		}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms/test-room/end", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		room := response["room"].(map[string]any)
+		assert.Equal(t, "notifying", room["endStage"])
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().EndRoom(gomock.Any(), "unknown-room").Return(nil, &rooms.RoomNotFoundError{RoomID: "unknown-room"})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms/unknown-room/end", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().EndRoom(gomock.Any(), "test-room").Return(nil, &rooms.RoomUpdateConflictError{RoomID: "test-room"})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms/test-room/end", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("InternalError", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().EndRoom(gomock.Any(), "test-room").Return(nil, errors.New("internal error"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms/test-room/end", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestFreezeRoom(t *testing.T) {
+	t.Run("Freeze", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		frozenAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		mockService.EXPECT().FreezeRoom(gomock.Any(), "test-room", true).Return(&rooms.RoomResponse{
+			RoomID:   "test-room",
+			FrozenAt: &frozenAt,
+		}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms/test-room/freeze", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		room := response["room"].(map[string]any)
+		assert.Equal(t, "2026-01-02T03:04:05Z", room["frozenAt"])
+	})
+
+	t.Run("Unfreeze", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().FreezeRoom(gomock.Any(), "test-room", false).Return(&rooms.RoomResponse{RoomID: "test-room"}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms/test-room/unfreeze", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().FreezeRoom(gomock.Any(), "unknown-room", true).Return(nil, &rooms.RoomNotFoundError{RoomID: "unknown-room"})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms/unknown-room/freeze", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("InternalError", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().FreezeRoom(gomock.Any(), "test-room", false).Return(nil, errors.New("internal error"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms/test-room/unfreeze", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestLinkRoom(t *testing.T) {
+	linkRequest := func(roomID, targetRoomID string) *http.Request {
+		jsonValue, _ := json.Marshal(map[string]string{"targetRoomId": targetRoomID})
+		req, _ := http.NewRequest("POST", "/api/rooms/"+roomID+"/link", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().LinkRoom(gomock.Any(), "room-a", "room-b", "").Return(&rooms.LinkResponse{
+			SourceRoomID: "room-a",
+			TargetRoomID: "room-b",
+		}, nil)
+
+		w := httptest.NewRecorder()
+		router.Handler().ServeHTTP(w, linkRequest("room-a", "room-b"))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		link := response["link"].(map[string]any)
+		assert.Equal(t, "room-a", link["sourceRoomId"])
+		assert.Equal(t, "room-b", link["targetRoomId"])
+	})
+
+	t.Run("SelectedAnchor", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		anchorID := "6f1c2a8e-3b4d-4e5f-8a9b-0c1d2e3f4a5b"
+		mockService.EXPECT().LinkRoom(gomock.Any(), "room-a", "room-b", anchorID).Return(&rooms.LinkResponse{
+			SourceRoomID: "room-a",
+			TargetRoomID: "room-b",
+			AnchorID:     anchorID,
+		}, nil)
+
+		jsonValue, _ := json.Marshal(map[string]string{"targetRoomId": "room-b", "anchorId": anchorID})
+		req, _ := http.NewRequest("POST", "/api/rooms/room-a/link", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("InvalidAnchorID", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		jsonValue, _ := json.Marshal(map[string]string{"targetRoomId": "room-b", "anchorId": "not-a-user"})
+		req, _ := http.NewRequest("POST", "/api/rooms/room-a/link", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("SameRoom", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := httptest.NewRecorder()
+		router.Handler().ServeHTTP(w, linkRequest("room-a", "room-a"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("InvalidTargetRoomID", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := httptest.NewRecorder()
+		router.Handler().ServeHTTP(w, linkRequest("room-a", "invalid@id"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().LinkRoom(gomock.Any(), "room-a", "room-b", "").Return(nil, &rooms.RoomNotFoundError{RoomID: "room-b"})
+
+		w := httptest.NewRecorder()
+		router.Handler().ServeHTTP(w, linkRequest("room-a", "room-b"))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("AlreadyLinked", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().LinkRoom(gomock.Any(), "room-a", "room-b", "").Return(nil, &rooms.RoomLinkExistsError{RoomID: "room-b"})
+
+		w := httptest.NewRecorder()
+		router.Handler().ServeHTTP(w, linkRequest("room-a", "room-b"))
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("InternalError", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().LinkRoom(gomock.Any(), "room-a", "room-b", "").Return(nil, errors.New("internal error"))
+
+		w := httptest.NewRecorder()
+		router.Handler().ServeHTTP(w, linkRequest("room-a", "room-b"))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestUnlinkRoom(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().UnlinkRoom(gomock.Any(), "room-a", "room-b").Return(nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/api/rooms/room-a/link/room-b", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("NotLinked", func(t *testing.T) {
+		router, mockService, _ := setupRouter(t)
+
+		mockService.EXPECT().UnlinkRoom(gomock.Any(), "room-a", "room-b").
+			Return(&rooms.RoomLinkNotFoundError{SourceRoomID: "room-a", TargetRoomID: "room-b"})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/api/rooms/room-a/link/room-b", nil)
 		router.Handler().ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
 }
 
@@ -428,6 +1264,123 @@ func TestGetStats(t *testing.T) {
 	})
 }
 
+func TestListModules(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		router, _, mockStore := setupRouter(t)
+
+		mockStore.EXPECT().ListModuleStatus(gomock.Any(), "mixers").Return([]*rooms.ModuleStatus{
+			{
+				ModuleID:      "mixer1",
+				Heartbeat:     &etcdstate.HeartbeatData{Status: "healthy", Capacity: 10},
+				Capacity:      10,
+				AssignedRooms: 2,
+				Healthy:       true,
+				Pickable:      true,
+			},
+			{
+				ModuleID: "mixer2",
+				Mark:     &etcdstate.MarkData{Label: "cordon"},
+			},
+		}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/modules/mixers", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, true, response["success"])
+		assert.Equal(t, "mixers", response["type"])
+		assert.Equal(t, float64(2), response["count"])
+
+		modules := response["modules"].([]any)
+		first := modules[0].(map[string]any)
+		assert.Equal(t, "mixer1", first["moduleId"])
+		assert.Equal(t, float64(10), first["capacity"])
+		assert.Equal(t, float64(2), first["assignedRooms"])
+		second := modules[1].(map[string]any)
+		assert.Equal(t, "cordon", second["mark"].(map[string]any)["label"])
+	})
+
+	t.Run("InvalidModuleType", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/modules/invalid", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("StoreError", func(t *testing.T) {
+		router, _, mockStore := setupRouter(t)
+
+		mockStore.EXPECT().ListModuleStatus(gomock.Any(), "januses").Return(nil, errors.New("etcd error"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/modules/januses", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+		var response map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "Failed to list modules", response["error"])
+	})
+}
+
+func TestReportDegraded(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		router, _, mockStore := setupRouter(t)
+
+		mockStore.EXPECT().SetModuleDegraded(gomock.Any(), "januses", "janus1", gomock.Any(), int64(60)).
+			DoAndReturn(func(_ context.Context, _, _ string, degraded *etcdstate.DegradedData, _ int64) error {
+				assert.Equal(t, "circuit open", degraded.Reason)
+				assert.Equal(t, "gw-1", degraded.Reporter)
+				assert.False(t, degraded.Since.IsZero())
+				return nil
+			})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/modules/januses/janus1/degraded",
+			bytes.NewBufferString(`{"reason":"circuit open","reporter":"gw-1","ttl":60}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"success":true,"module":{"type":"januses","id":"janus1","ttl":60}}`, w.Body.String())
+	})
+
+	t.Run("TTLRequired", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/modules/januses/janus1/degraded", bytes.NewBufferString(`{"reason":"circuit open"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("StoreError", func(t *testing.T) {
+		router, _, mockStore := setupRouter(t)
+
+		mockStore.EXPECT().SetModuleDegraded(gomock.Any(), "mixers", "mixer1", gomock.Any(), int64(30)).
+			Return(errors.New("etcd down"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/modules/mixers/mixer1/degraded", bytes.NewBufferString(`{"ttl":30}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
 func TestSetModuleMark(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		router, _, mockStore := setupRouter(t)
@@ -830,3 +1783,428 @@ func TestDeleteModuleMark(t *testing.T) {
 		assert.Equal(t, "Failed to delete module mark", response["error"])
 	})
 }
+
+func TestHousekeeping(t *testing.T) {
+	t.Run("get mode", func(t *testing.T) {
+		router, mockResManager := setupHousekeepingRouter(t)
+		mockResManager.EXPECT().HousekeepDryRun().Return(true)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/housekeeping", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"success":true,"dryRun":true}`, w.Body.String())
+	})
+
+	t.Run("disable dry run", func(t *testing.T) {
+		router, mockResManager := setupHousekeepingRouter(t)
+		mockResManager.EXPECT().SetHousekeepDryRun(false)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/housekeeping", bytes.NewBufferString(`{"dryRun":false}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"success":true,"dryRun":false}`, w.Body.String())
+	})
+
+	t.Run("missing dryRun", func(t *testing.T) {
+		router, _ := setupHousekeepingRouter(t)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/housekeeping", bytes.NewBufferString(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func setupAPIKeyRouter(t *testing.T, authenticator *auth.Authenticator) (*Router, *mocks.MockAPIKeyStore) {
+	gin.SetMode(gin.TestMode)
+
+	ctrl := gomock.NewController(t)
+	mockAPIKeyStore := mocks.NewMockAPIKeyStore(ctrl)
+	router := NewRouter(
+		mocks.NewMockRoomService(ctrl),
+		mocks.NewMockRoomStore(ctrl),
+		mockAPIKeyStore,
+		mocks.NewMockResourceManager(ctrl),
+		testPinPolicy,
+		testIDProvider(t),
+		nil,
+		nil,
+		authenticator,
+		log.NewTest(t),
+	)
+	return router, mockAPIKeyStore
+}
+
+func TestAPIKeys(t *testing.T) {
+	t.Run("create returns token without hash", func(t *testing.T) {
+		router, mockAPIKeyStore := setupAPIKeyRouter(t, nil)
+		var stored *rooms.APIKey
+		mockAPIKeyStore.EXPECT().
+			CreateAPIKey(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, key *rooms.APIKey) error {
+				stored = key
+				return nil
+			})
+
+		w := httptest.NewRecorder()
+		body := `{"tenant":"acme","scopes":["create"],"rateLimit":60}`
+		req, _ := http.NewRequest("POST", "/api/apikeys", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response struct {
+			Key    string       `json:"key"`
+			APIKey rooms.APIKey `json:"apiKey"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEmpty(t, stored.Hash)
+		assert.Empty(t, response.APIKey.Hash)
+		assert.Equal(t, stored.ID, response.APIKey.ID)
+		assert.True(t, strings.HasPrefix(response.Key, stored.ID+"."))
+		assert.Equal(t, []string{rooms.ScopeCreate}, response.APIKey.Scopes)
+	})
+
+	t.Run("create with unknown scope", func(t *testing.T) {
+		router, _ := setupAPIKeyRouter(t, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/apikeys", bytes.NewBufferString(`{"tenant":"acme","scopes":["root"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("list hides hashes", func(t *testing.T) {
+		router, mockAPIKeyStore := setupAPIKeyRouter(t, nil)
+		mockAPIKeyStore.EXPECT().
+			ListAPIKeys(gomock.Any()).
+			Return([]*rooms.APIKey{{ID: "0123456789abcdef", Tenant: "acme", Hash: "secret-hash"}}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/apikeys", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "secret-hash")
+		assert.Contains(t, w.Body.String(), `"count":1`)
+	})
+
+	t.Run("delete missing key", func(t *testing.T) {
+		router, mockAPIKeyStore := setupAPIKeyRouter(t, nil)
+		mockAPIKeyStore.EXPECT().DeleteAPIKey(gomock.Any(), "0123456789abcdef").Return(false, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/api/apikeys/0123456789abcdef", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("scope enforced when auth is enabled", func(t *testing.T) {
+		authenticator := auth.NewAuthenticator(&auth.Config{Enabled: true, AdminKey: "admin-secret"}, nil, log.NewTest(t))
+		router, mockAPIKeyStore := setupAPIKeyRouter(t, authenticator)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/apikeys", nil)
+		router.Handler().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		mockAPIKeyStore.EXPECT().ListAPIKeys(gomock.Any()).Return(nil, nil)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/api/apikeys", nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		router.Handler().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func setupQuotaRouter(
+	t *testing.T,
+	authenticator func(rooms.APIKeyStore) *auth.Authenticator,
+) (*Router, *mocks.MockRoomService, *mocks.MockRoomStore, *mocks.MockAPIKeyStore) {
+	gin.SetMode(gin.TestMode)
+
+	ctrl := gomock.NewController(t)
+	mockService := mocks.NewMockRoomService(ctrl)
+	mockStore := mocks.NewMockRoomStore(ctrl)
+	mockAPIKeyStore := mocks.NewMockAPIKeyStore(ctrl)
+	router := NewRouter(
+		mockService,
+		mockStore,
+		mockAPIKeyStore,
+		mocks.NewMockResourceManager(ctrl),
+		testPinPolicy,
+		testIDProvider(t),
+		nil,
+		nil,
+		authenticator(mockAPIKeyStore),
+		log.NewTest(t),
+	)
+	return router, mockService, mockStore, mockAPIKeyStore
+}
+
+func TestQuota(t *testing.T) {
+	noAuth := func(rooms.APIKeyStore) *auth.Authenticator { return nil }
+	withAuth := func(store rooms.APIKeyStore) *auth.Authenticator {
+		return auth.NewAuthenticator(&auth.Config{Enabled: true, AdminKey: "admin-secret"}, store, log.NewTest(t))
+	}
+	tenantKey := func(t *testing.T, mockAPIKeyStore *mocks.MockAPIKeyStore, scopes ...string) string {
+		key, token, err := auth.NewAPIKey("acme", scopes, 0)
+		assert.NoError(t, err)
+		mockAPIKeyStore.EXPECT().GetAPIKey(gomock.Any(), key.ID).Return(key, nil).AnyTimes()
+		return token
+	}
+
+	t.Run("create over rooms quota", func(t *testing.T) {
+		router, mockService, _, mockAPIKeyStore := setupQuotaRouter(t, withAuth)
+		token := tenantKey(t, mockAPIKeyStore, rooms.ScopeCreate)
+
+		mockService.EXPECT().
+			CreateRoom(gomock.Any(), "test-room", anyPin(rooms.CreateRoomParams{Tenant: "acme", MaxAnchors: defaultMaxAnchors})).
+			Return(nil, fmt.Errorf("failed to create room: %w",
+				&rooms.QuotaExceededError{Tenant: "acme", Resource: rooms.QuotaRooms, Limit: 2}))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms", strings.NewReader(`{"roomId":"test-room"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Contains(t, w.Body.String(), "rooms")
+	})
+
+	t.Run("start live over on-air quota deletes the room", func(t *testing.T) {
+		router, mockService, mockStore, _ := setupQuotaRouter(t, noAuth)
+
+		mockService.EXPECT().
+			CreateRoom(gomock.Any(), "test-room", anyPin(rooms.CreateRoomParams{MaxAnchors: defaultMaxAnchors})).
+			Return(&rooms.RoomResponse{RoomID: "test-room"}, nil)
+		mockService.EXPECT().
+			StartLive(gomock.Any(), "test-room").
+			Return(&rooms.QuotaExceededError{Tenant: "acme", Resource: rooms.QuotaOnAirRooms, Limit: 1})
+		mockStore.EXPECT().DeleteRoom(gomock.Any(), "test-room").Return(true, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms", strings.NewReader(`{"roomId":"test-room"}`))
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+
+	t.Run("usage of the caller tenant", func(t *testing.T) {
+		router, _, mockStore, mockAPIKeyStore := setupQuotaRouter(t, withAuth)
+		token := tenantKey(t, mockAPIKeyStore)
+
+		mockStore.EXPECT().GetQuotaUsage(gomock.Any(), "acme").Return(&rooms.QuotaUsage{
+			Tenant:     "acme",
+			Quota:      rooms.Quota{MaxRooms: 10, MaxOnAirRooms: 2},
+			Rooms:      4,
+			OnAirRooms: 1,
+		}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/quota", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Quota rooms.QuotaUsage `json:"quota"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 10, response.Quota.Quota.MaxRooms)
+		assert.Equal(t, 1, response.Quota.OnAirRooms)
+	})
+
+	t.Run("usage without tenant", func(t *testing.T) {
+		router, _, _, _ := setupQuotaRouter(t, noAuth)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/quota", nil)
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("set tenant quota requires admin", func(t *testing.T) {
+		router, _, mockStore, mockAPIKeyStore := setupQuotaRouter(t, withAuth)
+		token := tenantKey(t, mockAPIKeyStore, rooms.ScopeCreate)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/tenants/acme/quota", strings.NewReader(`{"maxRooms":100}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.Handler().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		mockStore.EXPECT().SetQuota(gomock.Any(), "acme", &rooms.Quota{MaxRooms: 100, MaxOnAirRooms: 5}).Return(nil)
+		mockStore.EXPECT().GetQuotaUsage(gomock.Any(), "acme").Return(&rooms.QuotaUsage{Tenant: "acme"}, nil)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("PUT", "/api/tenants/acme/quota", strings.NewReader(`{"maxRooms":100,"maxOnAirRooms":5}`))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		router.Handler().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("set negative quota", func(t *testing.T) {
+		router, _, _, _ := setupQuotaRouter(t, noAuth)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/tenants/acme/quota", strings.NewReader(`{"maxRooms":-1}`))
+		router.Handler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestGetRoomListeners(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setup := func(t *testing.T, listeners rooms.ListenerCounter) *Router {
+		ctrl := gomock.NewController(t)
+		return NewRouter(
+			mocks.NewMockRoomService(ctrl),
+			mocks.NewMockRoomStore(ctrl),
+			mocks.NewMockAPIKeyStore(ctrl),
+			mocks.NewMockResourceManager(ctrl),
+			testPinPolicy,
+			testIDProvider(t),
+			listeners,
+			nil,
+			nil,
+			log.NewTest(t),
+		)
+	}
+	get := func(router *Router, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", target, nil)
+		router.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("estimate", func(t *testing.T) {
+		mockListeners := mocks.NewMockListenerCounter(gomock.NewController(t))
+		mockListeners.EXPECT().Estimate(gomock.Any(), "test-room").Return(int64(42), nil)
+
+		w := get(setup(t, mockListeners), "/api/rooms/test-room/listeners")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"success":true,"listeners":{"roomId":"test-room","listeners":42}}`, w.Body.String())
+	})
+
+	t.Run("redis error", func(t *testing.T) {
+		mockListeners := mocks.NewMockListenerCounter(gomock.NewController(t))
+		mockListeners.EXPECT().Estimate(gomock.Any(), "test-room").Return(int64(0), errors.New("redis down"))
+
+		w := get(setup(t, mockListeners), "/api/rooms/test-room/listeners")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("invalid room ID", func(t *testing.T) {
+		w := get(setup(t, mocks.NewMockListenerCounter(gomock.NewController(t))), "/api/rooms/bad@room/listeners")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("counting disabled", func(t *testing.T) {
+		w := get(setup(t, nil), "/api/rooms/test-room/listeners")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestGetRoomUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setup := func(t *testing.T, usageReader rooms.UsageReader) *Router {
+		ctrl := gomock.NewController(t)
+		return NewRouter(
+			mocks.NewMockRoomService(ctrl),
+			mocks.NewMockRoomStore(ctrl),
+			mocks.NewMockAPIKeyStore(ctrl),
+			mocks.NewMockResourceManager(ctrl),
+			testPinPolicy,
+			testIDProvider(t),
+			nil,
+			usageReader,
+			nil,
+			log.NewTest(t),
+		)
+	}
+	get := func(router *Router, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", target, nil)
+		router.Handler().ServeHTTP(w, req)
+		return w
+	}
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	t.Run("usage", func(t *testing.T) {
+		mockUsage := mocks.NewMockUsageReader(gomock.NewController(t))
+		mockUsage.EXPECT().Usage(gomock.Any(), "test-room", from, to).Return(&usage.RoomUsage{
+			RoomID: "test-room",
+			From:   from,
+			To:     to,
+			Hours: []usage.HourUsage{
+				{Hour: from.Add(time.Hour), ForwardedBytes: 1000, HLSBytes: 200},
+			},
+			ForwardedBytes: 1000,
+			HLSBytes:       200,
+		}, nil)
+
+		w := get(setup(t, mockUsage), "/api/rooms/test-room/usage?from=2026-01-01T00:00:00Z&to=2026-01-02T00:00:00Z")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"success":true,"usage":{
+			"roomId":"test-room","from":"2026-01-01T00:00:00Z","to":"2026-01-02T00:00:00Z",
+			"hours":[{"hour":"2026-01-01T01:00:00Z","forwardedBytes":1000,"hlsBytes":200}],
+			"forwardedBytes":1000,"hlsBytes":200}}`, w.Body.String())
+	})
+
+	t.Run("last day by default", func(t *testing.T) {
+		mockUsage := mocks.NewMockUsageReader(gomock.NewController(t))
+		mockUsage.EXPECT().Usage(gomock.Any(), "test-room", gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, _ string, from, to time.Time) (*usage.RoomUsage, error) {
+				assert.Equal(t, 24*time.Hour, to.Sub(from))
+				assert.WithinDuration(t, time.Now(), to, time.Minute)
+				return &usage.RoomUsage{RoomID: "test-room", Hours: []usage.HourUsage{}}, nil
+			})
+
+		w := get(setup(t, mockUsage), "/api/rooms/test-room/usage")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		mockUsage := mocks.NewMockUsageReader(gomock.NewController(t))
+		mockUsage.EXPECT().Usage(gomock.Any(), "test-room", to, from).Return(nil, usage.ErrInvalidRange)
+
+		w := get(setup(t, mockUsage), "/api/rooms/test-room/usage?from=2026-01-02T00:00:00Z&to=2026-01-01T00:00:00Z")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid time", func(t *testing.T) {
+		w := get(setup(t, mocks.NewMockUsageReader(gomock.NewController(t))), "/api/rooms/test-room/usage?from=yesterday")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("redis error", func(t *testing.T) {
+		mockUsage := mocks.NewMockUsageReader(gomock.NewController(t))
+		mockUsage.EXPECT().Usage(gomock.Any(), "test-room", from, to).Return(nil, errors.New("redis down"))
+
+		w := get(setup(t, mockUsage), "/api/rooms/test-room/usage?from=2026-01-01T00:00:00Z&to=2026-01-02T00:00:00Z")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("accounting disabled", func(t *testing.T) {
+		w := get(setup(t, nil), "/api/rooms/test-room/usage")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
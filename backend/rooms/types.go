@@ -7,22 +7,44 @@ import (
 
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/usage"
+	"github.com/imtaco/audio-rtc-exp/users"
 )
 
 // RoomService defines the interface for room management operations
 type RoomService interface {
-	CreateRoom(ctx context.Context, roomID, pin string, maxAnchors int) (*RoomResponse, error)
+	CreateRoom(ctx context.Context, roomID string, params *CreateRoomParams) (*RoomResponse, error)
 	GetRoom(ctx context.Context, roomID string) (*RoomResponse, error)
-	ListRooms(ctx context.Context) (*ListRoomsResponse, error)
+	GetRoomByExternalID(ctx context.Context, externalID string) (*RoomResponse, error)
+	// GetRoomDetail aggregates the room state kept by all services, for admin dashboards
+	GetRoomDetail(ctx context.Context, roomID string) (*RoomDetail, error)
+	UpdateRoom(ctx context.Context, roomID string, patch *RoomPatch) (*RoomResponse, error)
+	// EndRoom starts the ordered teardown of a room, see constants.EndStage
+	EndRoom(ctx context.Context, roomID string) (*RoomResponse, error)
+	// FreezeRoom freezes or unfreezes a room, a frozen room takes no new joins and its anchors
+	// are muted while the live goes on
+	FreezeRoom(ctx context.Context, roomID string, frozen bool) (*RoomResponse, error)
+	// ListRooms lists the rooms having all of tags, all rooms when tags is empty
+	ListRooms(ctx context.Context, tags map[string]string) (*ListRoomsResponse, error)
 	DeleteRoom(ctx context.Context, roomID string) (*DeleteRoomResponse, error)
 	GetStats(ctx context.Context) (*StatsResponse, error)
 	StartLive(ctx context.Context, roomID string) error
+	LinkRoom(ctx context.Context, sourceRoomID, targetRoomID, anchorID string) (*LinkResponse, error)
+	UnlinkRoom(ctx context.Context, sourceRoomID, targetRoomID string) error
+	// GetTimeline returns the significant transitions of the room, oldest first
+	GetTimeline(ctx context.Context, roomID string) ([]etcdstate.TimelineEvent, error)
 }
 
 type RoomStore interface {
 	CreateRoom(ctx context.Context, roomID string, roomData *etcdstate.Meta) (*etcdstate.Meta, error)
 	GetRoom(ctx context.Context, roomID string) (*etcdstate.Meta, error)
+	// UpdateRoom applies update to the meta with a compare-and-swap on its mod revision
+	UpdateRoom(ctx context.Context, roomID string, update func(meta *etcdstate.Meta) error) (*etcdstate.Meta, error)
 	Exists(ctx context.Context, roomID string) (bool, error)
+	// ResolveExternalID returns the room indexed by an external ID, empty when none
+	ResolveExternalID(ctx context.Context, externalID string) (string, error)
+	// ListRoomsByTag returns the rooms indexed with the tag key: value
+	ListRoomsByTag(ctx context.Context, key, value string) ([]string, error)
 	StopRoom(ctx context.Context, roomID string) error
 
 	DeleteRoom(ctx context.Context, roomID string) (bool, error)
@@ -30,27 +52,190 @@ type RoomStore interface {
 
 	CreateLiveMeta(ctx context.Context, roomID, mixerID, janusID, nonce string) error
 	StopLiveMeta(ctx context.Context, roomID string) error
+	// ReassignLiveMeta moves an on-air room onto other modules keeping the nonce of its live,
+	// false when the room is no longer on air
+	ReassignLiveMeta(ctx context.Context, roomID, mixerID, janusID string) (bool, error)
 
 	GetMixerData(ctx context.Context, roomID string) (*etcdstate.Mixer, error)
+	// GetRoomState reads all keys of the room at the same etcd revision, nil when none
+	GetRoomState(ctx context.Context, roomID string) (*etcdstate.RoomState, error)
+	GetLatency(ctx context.Context, roomID string) (*etcdstate.Latency, error)
+	// GetTimeline returns the event timeline of the room, nil when none was recorded
+	GetTimeline(ctx context.Context, roomID string) (*etcdstate.Timeline, error)
 	GetStats(ctx context.Context) (*RoomStats, error)
 
+	// Cross-room link operations, the link is stored under the target room
+	CreateLink(ctx context.Context, targetRoomID string, link *etcdstate.Link) (bool, error)
+	GetLink(ctx context.Context, targetRoomID string) (*etcdstate.Link, error)
+	DeleteLink(ctx context.Context, targetRoomID string) error
+
+	// Tenant quotas, a room of a tenant counts against its quota from creation to deletion
+	// and while on air, CreateRoom and CreateLiveMeta fail with QuotaExceededError
+	GetQuotaUsage(ctx context.Context, tenant string) (*QuotaUsage, error)
+	SetQuota(ctx context.Context, tenant string, quota *Quota) error
+
 	// Module mark operations
 	SetModuleMark(ctx context.Context, moduleType, moduleID string, label constants.MarkLabel, ttlSeconds int64) error
 	DeleteModuleMark(ctx context.Context, moduleType, moduleID string) error
+	// SetModuleDegraded records a module reported failing by its clients until ttlSeconds elapse,
+	// placement prefers other modules meanwhile
+	SetModuleDegraded(ctx context.Context, moduleType, moduleID string, degraded *etcdstate.DegradedData, ttlSeconds int64) error
+	ListModuleStatus(ctx context.Context, moduleType string) ([]*ModuleStatus, error)
+}
+
+// APIKeyStore keeps API keys of the rooms API, keys are stored with the hash of their secret only
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	GetAPIKey(ctx context.Context, keyID string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]*APIKey, error)
+	DeleteAPIKey(ctx context.Context, keyID string) (bool, error)
+}
+
+// IDProvider generates the IDs of rooms created without one, externalID is the upstream
+// identifier given on creation, empty when none
+type IDProvider interface {
+	NewRoomID(ctx context.Context, externalID string) (string, error)
+}
+
+// ListenerCounter estimates the unique HLS listeners of rooms, as counted by the hlsserver
+type ListenerCounter interface {
+	Estimate(ctx context.Context, roomID string) (int64, error)
+}
+
+// UsageReader reads the hourly bandwidth of rooms, as accounted by januses and the hlsserver
+type UsageReader interface {
+	Usage(ctx context.Context, roomID string, from, to time.Time) (*usage.RoomUsage, error)
+}
+
+// RoomUsersReader reads the active users of a room from the users service
+type RoomUsersReader interface {
+	GetActiveRoomUsers(ctx context.Context, roomID string) ([]*users.RoomUser, error)
+}
+
+// RoomListeners is the estimate of the unique HLS listeners of a room within the last minutes
+type RoomListeners struct {
+	RoomID    string `json:"roomId"`
+	Listeners int64  `json:"listeners"`
 }
 
 type ResourceManager interface {
 	Start(context.Context) error
 	Stop() error
-	PickJanus() (string, error)
-	PickMixer() (string, error)
+	// PickJanus and PickMixer reserve capacity of the picked module for the room, empty when
+	// every module is full
+	PickJanus(ctx context.Context, roomID string) (string, error)
+	PickMixer(ctx context.Context, roomID string) (string, error)
+	// Release gives back the capacity reserved for a room which did not go live on the modules
+	Release(ctx context.Context, roomID, janusID, mixerID string)
 	// PickResource(module string) (string, error)
+
+	// Housekeeping dry run, stale rooms and unhealthy modules are only reported
+	SetHousekeepDryRun(enabled bool)
+	HousekeepDryRun() bool
+}
+
+// Room lifecycle events, published to the room event stream through the outbox
+const (
+	EventRoomLive    = "roomLive"
+	EventRoomStopped = "roomStopped"
+)
+
+type RoomEvent struct {
+	RoomID   string    `json:"roomId"`
+	LiveMeta *LiveMeta `json:"livemeta"`
+}
+
+// API scopes, reading rooms and modules only requires a valid key
+const (
+	ScopeCreate      = "create"       // create and link rooms
+	ScopeDelete      = "delete"       // delete and unlink rooms
+	ScopeMarkModules = "mark-modules" // set and delete module marks
+	ScopeAdmin       = "admin"        // manage API keys and housekeeping, implies all scopes
+)
+
+// APIKey is a credential of a tenant or service for the rooms API
+type APIKey struct {
+	ID        string    `json:"id"`
+	Tenant    string    `json:"tenant"`
+	Scopes    []string  `json:"scopes"`
+	RateLimit int       `json:"rateLimit,omitempty"` // requests per minute, 0 uses the configured default
+	Hash      string    `json:"hash,omitempty"`      // SHA-256 of the secret, never returned by the API
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Quota limits the rooms of a tenant, 0 is unlimited
+type Quota struct {
+	MaxRooms      int `json:"maxRooms"`
+	MaxOnAirRooms int `json:"maxOnAirRooms"`
+}
+
+// QuotaUsage is the quota of a tenant and its rooms counting against it
+type QuotaUsage struct {
+	Tenant     string `json:"tenant"`
+	Quota      Quota  `json:"quota"`
+	Rooms      int    `json:"rooms"`
+	OnAirRooms int    `json:"onAirRooms"`
+}
+
+// Quota resources, as reported by QuotaExceededError
+const (
+	QuotaRooms      = "rooms"
+	QuotaOnAirRooms = "onAirRooms"
+)
+
+// Module types, as used in /api/modules/:moduleType
+const (
+	ModuleTypeJanuses = "januses"
+	ModuleTypeMixers  = "mixers"
+)
+
+// ModuleStatus is heartbeat, mark and room assignments of a module read at the same etcd revision
+type ModuleStatus struct {
+	ModuleID      string                   `json:"moduleId"`
+	Heartbeat     *etcdstate.HeartbeatData `json:"heartbeat,omitempty"`
+	Mark          *etcdstate.MarkData      `json:"mark,omitempty"`
+	Degraded      *etcdstate.DegradedData  `json:"degraded,omitempty"`
+	Capacity      int                      `json:"capacity"`
+	AssignedRooms int                      `json:"assignedRooms"`
+	Healthy       bool                     `json:"healthy"`
+	Pickable      bool                     `json:"pickable"`
 }
 
 // Alias types from etcdstate for convenience
 type LiveMeta = etcdstate.LiveMeta
 type Mixer = etcdstate.Mixer
 
+// FFmpeg states of RoomDetail, as reported by the mixer of the room
+const (
+	FFmpegRunning  = "running"
+	FFmpegDegraded = "degraded"
+)
+
+// RoomDetail is the state of a room kept by all services. Parts failing to load are left
+// empty and their error is reported by part in Errors, so a single broken service does not
+// hide the rest.
+type RoomDetail struct {
+	Room     *RoomResponse      `json:"room"`
+	LiveMeta *LiveMeta          `json:"livemeta,omitempty"`
+	Mixer    *Mixer             `json:"mixer,omitempty"`
+	Janus    *etcdstate.Janus   `json:"janus,omitempty"`
+	Link     *etcdstate.Link    `json:"link,omitempty"`
+	Quality  *etcdstate.Quality `json:"quality,omitempty"`
+	// FFmpeg is empty while no mixer runs the room
+	FFmpeg      string            `json:"ffmpeg,omitempty"`
+	MixerModule *ModuleStatus     `json:"mixerModule,omitempty"`
+	JanusModule *ModuleStatus     `json:"janusModule,omitempty"`
+	Users       []*users.RoomUser `json:"users,omitempty"`
+	Errors      map[string]string `json:"errors,omitempty"`
+}
+
+// Parts of RoomDetail, as keyed in its Errors
+const (
+	RoomDetailState   = "state"
+	RoomDetailModules = "modules"
+	RoomDetailUsers   = "users"
+)
+
 type RoomStats struct {
 	Total             int `json:"total"`
 	TotalParticipants int `json:"totalParticipants"`
@@ -58,12 +243,73 @@ type RoomStats struct {
 
 // Response types for RoomService
 type RoomResponse struct {
-	RoomID    string    `json:"roomId"`
-	HLSURL    string    `json:"hlsUrl"`
-	Pin       string    `json:"pin,omitempty"`
-	RTPPort   *int      `json:"rtpPort,omitempty"`
-	Status    string    `json:"status,omitempty"`
+	RoomID     string `json:"roomId"`
+	ExternalID string `json:"externalId,omitempty"`
+	HLSURL     string `json:"hlsUrl"`
+	Pin        string `json:"pin,omitempty"`
+	RTPPort    *int   `json:"rtpPort,omitempty"`
+	MaxAnchors int    `json:"maxAnchors,omitempty"`
+	MaxBitrate int    `json:"maxBitrate,omitempty"`
+	DVRWindow  int    `json:"dvrWindow,omitempty"`
+	// MaxDuration is the seconds a live may last, 0 means unlimited
+	MaxDuration int        `json:"maxDuration,omitempty"`
+	Recording   bool       `json:"recording,omitempty"`
+	MixProfile  string     `json:"mixProfile,omitempty"`
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty"`
+	Locale      string     `json:"locale,omitempty"`
+	// Tags are the labels the room is grouped by
+	Tags map[string]string `json:"tags,omitempty"`
+	// Audio is the Opus settings of the room, nil keeps the defaults
+	Audio  *etcdstate.AudioParams `json:"audio,omitempty"`
+	Status string                 `json:"status,omitempty"`
+	// EndStage is the teardown progress once the room is ended
+	EndStage constants.EndStage `json:"endStage,omitempty"`
+	// FrozenAt is when the room was frozen, unset unless frozen
+	FrozenAt *time.Time `json:"frozenAt,omitempty"`
+	// PublishSlots is how many anchors may publish at once in a push-to-talk room
+	PublishSlots int `json:"publishSlots,omitempty"`
+	// JoinQueue is how many users may wait for a free anchor slot of the full room
+	JoinQueue int       `json:"joinQueue,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
+	// Latency from audio published at Janus to its HLS segment, for SLA monitoring
+	Latency *etcdstate.Latency `json:"latency,omitempty"`
+}
+
+// CreateRoomParams holds the settings of a new room
+type CreateRoomParams struct {
+	Pin        string
+	ExternalID string
+	// Tenant owns the room, empty when the caller has none
+	Tenant      string
+	MaxAnchors  int
+	MaxBitrate  int
+	DVRWindow   int
+	MaxDuration int
+	// Audio of nil keeps the Opus defaults
+	Audio *etcdstate.AudioParams
+	Tags  map[string]string
+}
+
+// RoomPatch holds the mutable fields of a room, nil fields are left unchanged
+type RoomPatch struct {
+	MaxAnchors  *int
+	Recording   *bool
+	MixProfile  *string
+	ScheduledAt *time.Time
+	Locale      *string
+	// Tags replace all tags of the room, empty removes them
+	Tags map[string]string
+	// PublishSlots of 0 turns push-to-talk off
+	PublishSlots *int
+	// JoinQueue of 0 rejects joins of a full room again
+	JoinQueue *int
+}
+
+// Empty reports whether the patch changes nothing
+func (p *RoomPatch) Empty() bool {
+	return p.MaxAnchors == nil && p.Recording == nil && p.MixProfile == nil && p.ScheduledAt == nil &&
+		p.Locale == nil && p.Tags == nil && p.PublishSlots == nil &&
+		p.JoinQueue == nil
 }
 
 type ListRoomsResponse struct {
@@ -71,6 +317,14 @@ type ListRoomsResponse struct {
 	Rooms []*RoomResponse `json:"rooms"`
 }
 
+// LinkResponse describes a source room forwarded into the mix of a target room
+type LinkResponse struct {
+	SourceRoomID string    `json:"sourceRoomId"`
+	TargetRoomID string    `json:"targetRoomId"`
+	AnchorID     string    `json:"anchorId,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
 type DeleteRoomResponse struct {
 	Message string `json:"message"`
 }
@@ -88,6 +342,14 @@ func (e *RoomExistsError) Error() string {
 	return fmt.Sprintf("Room %s already exists", e.RoomID)
 }
 
+type ExternalIDExistsError struct {
+	ExternalID string
+}
+
+func (e *ExternalIDExistsError) Error() string {
+	return fmt.Sprintf("External ID %s is already used by another room", e.ExternalID)
+}
+
 type RoomNotFoundError struct {
 	RoomID string
 }
@@ -95,3 +357,38 @@ type RoomNotFoundError struct {
 func (e *RoomNotFoundError) Error() string {
 	return fmt.Sprintf("Room %s not found", e.RoomID)
 }
+
+type RoomLinkExistsError struct {
+	RoomID string
+}
+
+func (e *RoomLinkExistsError) Error() string {
+	return fmt.Sprintf("Room %s is already linked", e.RoomID)
+}
+
+type RoomLinkNotFoundError struct {
+	SourceRoomID string
+	TargetRoomID string
+}
+
+func (e *RoomLinkNotFoundError) Error() string {
+	return fmt.Sprintf("Room %s is not linked to room %s", e.SourceRoomID, e.TargetRoomID)
+}
+
+type RoomUpdateConflictError struct {
+	RoomID string
+}
+
+func (e *RoomUpdateConflictError) Error() string {
+	return fmt.Sprintf("Room %s was modified concurrently, retry the update", e.RoomID)
+}
+
+type QuotaExceededError struct {
+	Tenant   string
+	Resource string
+	Limit    int
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("Tenant %s reached its quota of %d %s", e.Tenant, e.Limit, e.Resource)
+}
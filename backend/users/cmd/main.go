@@ -2,40 +2,59 @@ package main
 
 import (
 	"context"
-	"errors"
-	"net/http"
+	"net"
 	"time"
 
 	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/imtaco/audio-rtc-exp/internal/config"
+	"github.com/imtaco/audio-rtc-exp/internal/config/secret"
 	"github.com/imtaco/audio-rtc-exp/internal/etcd"
 	"github.com/imtaco/audio-rtc-exp/internal/httputil"
 	"github.com/imtaco/audio-rtc-exp/internal/jwt"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	"github.com/imtaco/audio-rtc-exp/internal/otel"
 	"github.com/imtaco/audio-rtc-exp/internal/redis"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/tlsid"
 	"github.com/imtaco/audio-rtc-exp/internal/workflow"
+	"github.com/imtaco/audio-rtc-exp/users"
+	"github.com/imtaco/audio-rtc-exp/users/connlock"
 	"github.com/imtaco/audio-rtc-exp/users/control"
+	"github.com/imtaco/audio-rtc-exp/users/refresh"
 	"github.com/imtaco/audio-rtc-exp/users/room"
 	"github.com/imtaco/audio-rtc-exp/users/status"
 	"github.com/imtaco/audio-rtc-exp/users/transport"
 )
 
 type Config struct {
-	App                 config.App      `mapstructure:"app"`
-	HTTP                httputil.Config `mapstructure:"http"`
-	Redis               redis.Config    `mapstructure:"redis"`
-	Etcd                etcd.Config     `mapstructure:"etcd"`
-	Otel                otel.Config     `mapstructure:"otel"`
-	RedisUserSvcPrefix  string          `mapstructure:"redis_user_svc_prefix"`
-	EtcdRoomPrefix      string          `mapstructure:"etcd_room_prefix"`
-	RedisReqStream      string          `mapstructure:"redis_req_stream"`
-	RedisReplyStream    string          `mapstructure:"redis_reply_stream"`
-	RedisWSNotifyStream string          `mapstructure:"redis_ws_notify_stream"`
-	StreamTrimInterval  time.Duration   `mapstructure:"stream_trim_interval"`
-	JWTSecret           string          `mapstructure:"jwt_secret"`
-	JWTExpiresIn        string          `mapstructure:"jwt_expires_in"`
+	App                 config.App                  `mapstructure:"app"`
+	HTTP                httputil.Config             `mapstructure:"http"`
+	MTLS                tlsid.Config                `mapstructure:"mtls"`
+	Redis               redis.Config                `mapstructure:"redis"`
+	Etcd                etcd.Config                 `mapstructure:"etcd"`
+	Otel                otel.Config                 `mapstructure:"otel"`
+	RedisUserSvcPrefix  string                      `mapstructure:"redis_user_svc_prefix"`
+	EtcdRoomPrefix      string                      `mapstructure:"etcd_room_prefix"`
+	RedisReqStream      string                      `mapstructure:"redis_req_stream"`
+	RedisReplyStream    string                      `mapstructure:"redis_reply_stream"`
+	RedisWSNotifyStream string                      `mapstructure:"redis_ws_notify_stream"`
+	WSNotify            redisstream.PartitionConfig `mapstructure:"ws_notify"`
+	WSNotifyBuffer      redisstream.BufferConfig    `mapstructure:"ws_notify_buffer"`
+	UserRPC             streamrpc.ClientConfig      `mapstructure:"user_rpc"`
+	StreamTrimInterval  time.Duration               `mapstructure:"stream_trim_interval"`
+	StreamTrim          control.TrimPolicies        `mapstructure:"stream_trim"`
+	Eviction            control.EvictionPolicy      `mapstructure:"eviction"`
+	StatusBatch         control.StatusBatch         `mapstructure:"status_batch"`
+	JoinQueue           control.JoinQueue           `mapstructure:"join_queue"`
+	JWT                 jwt.Config                  `mapstructure:"jwt"`
+	Secrets             secret.Config               `mapstructure:"secrets"`
+	Refresh             refresh.Config              `mapstructure:"refresh"`
+	// GRPCAddr serves the user status over gRPC, empty disables it
+	GRPCAddr string `mapstructure:"grpc_addr"`
 }
 
 func loadConfig() (*Config, error) {
@@ -45,16 +64,26 @@ func loadConfig() (*Config, error) {
 		v.SetDefault("redis_req_stream", "rtcus:user-status-req-stream")
 		v.SetDefault("redis_reply_stream", "rtcus:user-status-reply-stream")
 		v.SetDefault("redis_ws_notify_stream", "rtcus:user-status-ws-stream")
-		v.SetDefault("jwt_secret", "MY-secret-key-change-in-production")
-		v.SetDefault("jwt_expires_in", "1h")
 		v.SetDefault("prefix_room_store", "/rooms/")
 		v.SetDefault("stream_trim_interval", 30*time.Second)
+		v.SetDefault("grpc_addr", "")
 
 		redis.Setup(v, "app")
 		redis.Setup(v, "redis")
 		etcd.Setup(v, "etcd")
 		otel.Setup(v, "otel")
 		httputil.Setup(v, "http")
+		tlsid.Setup(v, "mtls")
+		secret.Setup(v, "secrets")
+		v.SetDefault("mtls.service", "users")
+		redisstream.SetupPartition(v, "ws_notify")
+		redisstream.SetupBuffer(v, "ws_notify_buffer")
+		control.SetupTrimPolicies(v, "stream_trim")
+		control.SetupEvictionPolicy(v, "eviction")
+		control.SetupStatusBatch(v, "status_batch")
+		control.SetupJoinQueue(v, "join_queue")
+		streamrpc.Setup(v, "user_rpc")
+		refresh.Setup(v, "refresh")
 
 		// override default addrs to ease testing
 		v.SetDefault("http.addr", "0.0.0.0:8085")
@@ -94,9 +123,22 @@ func main() {
 	if err != nil {
 		logger.Fatal("Failed to create etcd client", log.Error(err))
 	}
+	if config.App.LogLevelsKey != "" {
+		go etcd.WatchLogLevels(ctx, etcdClient, config.App.LogLevelsKey, logger.Levels(), logger.Module("LogLevels"))
+	}
 
-	// Initialize JWT Auth (expiresIn handled in JWT library if needed)
-	jwtAuth := jwt.NewAuth(config.JWTSecret)
+	identity, err := tlsid.New(ctx, &config.MTLS, etcdClient, logger.Module("TLSID"))
+	if err != nil {
+		logger.Fatal("Failed to load TLS identity", log.Error(err))
+	}
+
+	// Initialize JWT Auth, secrets are resolved from their references kept out of the config
+	secrets := secret.NewResolver(&config.Secrets, logger.Module("Secrets"))
+	jwtSecret, err := secrets.Get(ctx, config.JWT.Secret)
+	if err != nil {
+		logger.Fatal("Failed to resolve JWT secret", log.Error(err))
+	}
+	jwtAuth := jwt.NewAuthWithSecret(&config.JWT, jwtSecret)
 
 	// Initialize User Status Service
 	userService, err := status.NewUserService(
@@ -104,6 +146,7 @@ func main() {
 		jwtAuth,
 		config.RedisReqStream,
 		config.RedisReplyStream,
+		&config.UserRPC,
 		logger.Module("UserSvc"),
 	)
 	if err != nil {
@@ -120,6 +163,11 @@ func main() {
 		config.RedisReqStream,
 		config.RedisReplyStream,
 		config.RedisWSNotifyStream,
+		config.WSNotify.Partitions,
+		&config.WSNotifyBuffer,
+		&config.Eviction,
+		&config.StatusBatch,
+		&config.JoinQueue,
 		logger.Module("UserCtrl"),
 	)
 	if err != nil {
@@ -132,6 +180,8 @@ func main() {
 		config.RedisReqStream,
 		config.RedisReplyStream,
 		config.RedisWSNotifyStream,
+		config.WSNotify.Partitions,
+		&config.StreamTrim,
 		config.StreamTrimInterval,
 		logger.Module("Trimer"),
 	)
@@ -139,46 +189,107 @@ func main() {
 		logger.Fatal("Failed to create Trimer", log.Error(err))
 	}
 
+	// Refresh tokens let clients renew short-lived access tokens without creating a new user
+	var refreshTokens users.RefreshTokens
+	if config.Refresh.Enabled {
+		refreshTokens = refresh.NewStore(redisClient, config.RedisUserSvcPrefix, &config.Refresh)
+	}
+
 	// Initialize REST API router
-	router := transport.NewRouter(userService, jwtAuth, logger.Module("Router"))
+	// Connection locks are written by the gateways sharing the user service Redis prefix
+	connLocks := connlock.NewStore(redisClient, config.RedisUserSvcPrefix)
+
+	router := transport.NewRouter(userService, jwtAuth, refreshTokens, connLocks, userCtrl, logger.Module("Router"))
 	server := httputil.NewServer(&config.HTTP, router.Handler())
+	server.SetIdentity(identity)
 
-	// Start components
-	if err := trimer.Start(ctx); err != nil {
-		logger.Fatal("Failed to start Trimer", log.Error(err))
+	lc := workflow.NewLifecycle(logger.Module("Lifecycle"))
+	lc.Add(workflow.Component{Name: "otel", Stop: otelShutdown})
+	lc.Add(workflow.Component{
+		Name:  "secrets",
+		Start: secrets.Start,
+		Stop:  workflow.Stopper(secrets.Stop),
+	})
+	lc.Add(workflow.Component{
+		Name: "etcd",
+		Stop: workflow.Closer(etcdClient.Close),
+	})
+	if identity != nil {
+		lc.Add(workflow.Component{
+			Name:  "tlsid",
+			Start: identity.Start,
+			Stop:  workflow.Stopper(identity.Stop),
+		})
+	}
+	lc.Add(workflow.Component{
+		Name: "redis",
+		Stop: workflow.Closer(redisClient.Close),
+	})
+	lc.Add(workflow.Component{
+		Name:      "trimer",
+		DependsOn: []string{"redis"},
+		Start:     trimer.Start,
+		Stop:      workflow.Stopper(trimer.Stop),
+	})
+	lc.Add(workflow.Component{
+		Name:      "userCtrl",
+		DependsOn: []string{"etcd", "redis"},
+		Start:     userCtrl.Start,
+		Stop:      workflow.Closer(userCtrl.Stop),
+	})
+	lc.Add(workflow.Component{
+		Name:      "userService",
+		DependsOn: []string{"redis", "userCtrl"},
+		Start:     userService.Start,
+	})
+	lc.Add(server.Component("http", logger, "userService"))
+	if config.GRPCAddr != "" {
+		var grpcOpts []grpc.ServerOption
+		if identity != nil {
+			grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(identity.ServerConfig())))
+		}
+		grpcServer := grpc.NewServer(grpcOpts...)
+		grpcService := transport.NewGRPCServer(userService, userCtrl, logger.Module("GRPC"))
+		grpcService.Register(grpcServer)
+		lc.Add(workflow.Component{
+			Name:      "grpc",
+			DependsOn: []string{"userService"},
+			Start: func(context.Context) error {
+				lis, err := net.Listen("tcp", config.GRPCAddr)
+				if err != nil {
+					return err
+				}
+				logger.Info("Starting server", log.String("server", "grpc"), log.String("addr", lis.Addr().String()))
+				go func() {
+					if err := grpcServer.Serve(lis); err != nil {
+						logger.Error("gRPC server stopped", log.Error(err))
+					}
+				}()
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				grpcService.Close()
+				done := make(chan struct{})
+				go func() {
+					grpcServer.GracefulStop()
+					close(done)
+				}()
+				select {
+				case <-done:
+				case <-ctx.Done():
+					grpcServer.Stop()
+				}
+				return nil
+			},
+		})
 	}
-	if err := userCtrl.Start(ctx); err != nil {
-		logger.Fatal("Failed to start User Control", log.Error(err))
+	if adminServer := httputil.NewAdminServer(config.App.AdminAddr, "user-service", logger); adminServer != nil {
+		lc.Add(adminServer.Component("admin", logger))
 	}
-	if err := userService.Start(ctx); err != nil {
+
+	if err := lc.Start(ctx); err != nil {
 		logger.Fatal("Failed to start User Service", log.Error(err))
 	}
 
-	// Start HTTP server in goroutine
-	go func() {
-		logger.Info("Starting REST API server", log.String("addr", config.HTTP.Addr))
-		if err := server.Listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			logger.Fatal("Failed to start REST API server", log.Error(err))
-		}
-	}()
-
-	// Graceful shutdown
-	cleanup := func(ctx context.Context) {
-		_ = server.Shutdown(ctx)
-		trimer.Stop()
-
-		if err := userCtrl.Stop(); err != nil {
-			logger.Error("Error closing user consumer", log.Error(err))
-		}
-		if err := redisClient.Close(); err != nil {
-			logger.Error("Error closing Redis client", log.Error(err))
-		}
-		if err := etcdClient.Close(); err != nil {
-			logger.Error("Failed to close etcd client", log.Error(err))
-		}
-		if err := otelShutdown(ctx); err != nil {
-			logger.Error("Failed to shutdown OTEL", log.Error(err))
-		}
-	}
-	workflow.WaitGracefulShutdown(ctx, logger.Module("CleanUp"), cleanup, config.App.ShutdownTimeout)
+	lc.WaitGracefulShutdown(ctx, config.App.ShutdownTimeout)
 }
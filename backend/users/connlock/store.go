@@ -0,0 +1,241 @@
+package connlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+const scanCount = 100
+
+// Store reads and force-releases the connection locks the gateways keep in Redis, the layout
+// is owned by the ConnGuard of wsgateway:
+//
+//	<prefix>:c:<userId>     lock value serverId:connId[:roomId]
+//	<prefix>:d:<userId>     lock values scored by expiry, under allow_n
+//	<prefix>:ct:<userId>    acquisition time of the lock values, unix milliseconds
+//	<prefix>:s:<serverId>   gateway heartbeat
+type Store struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewStore(client *redis.Client, prefix string) *Store {
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (s *Store) connKey(userID string) string {
+	return s.prefix + ":c:" + userID
+}
+
+func (s *Store) devicesKey(userID string) string {
+	return s.prefix + ":d:" + userID
+}
+
+func (s *Store) acquiredKey(userID string) string {
+	return s.prefix + ":ct:" + userID
+}
+
+func (s *Store) serverKey(serverID string) string {
+	return s.prefix + ":s:" + serverID
+}
+
+// lockValue is a held lock value with its expiry
+type lockValue struct {
+	userID    string
+	value     string
+	expiresAt time.Time
+}
+
+func (s *Store) List(ctx context.Context, userID string) ([]*users.ConnLock, error) {
+	var userIDs []string
+	if userID != "" {
+		userIDs = []string{userID}
+	} else {
+		var err error
+		if userIDs, err = s.scanUsers(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	values, err := s.lockValues(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return []*users.ConnLock{}, nil
+	}
+
+	acquired, err := s.acquiredAt(ctx, values)
+	if err != nil {
+		return nil, err
+	}
+
+	locks := make([]*users.ConnLock, 0, len(values))
+	servers := make(map[string]bool)
+	for _, v := range values {
+		// serverId:connId, with the room under kick_old
+		parts := strings.SplitN(v.value, ":", 3)
+		lock := &users.ConnLock{
+			UserID:    v.userID,
+			ServerID:  parts[0],
+			ExpiresAt: v.expiresAt,
+		}
+		if len(parts) > 1 {
+			lock.ConnID = parts[1]
+		}
+		if len(parts) > 2 {
+			lock.RoomID = parts[2]
+		}
+		if at, ok := acquired[v.userID+"/"+v.value]; ok {
+			lock.AcquiredAt = &at
+		}
+		servers[lock.ServerID] = false
+		locks = append(locks, lock)
+	}
+
+	if err := s.serversAlive(ctx, servers); err != nil {
+		return nil, err
+	}
+	for _, lock := range locks {
+		lock.ServerAlive = servers[lock.ServerID]
+	}
+
+	slices.SortFunc(locks, func(a, b *users.ConnLock) int {
+		if c := strings.Compare(a.UserID, b.UserID); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ConnID, b.ConnID)
+	})
+	return locks, nil
+}
+
+func (s *Store) ForceRelease(ctx context.Context, userID string) (bool, error) {
+	n, err := s.client.Del(ctx, s.connKey(userID), s.devicesKey(userID), s.acquiredKey(userID)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to release locks: %w", err)
+	}
+	return n > 0, nil
+}
+
+// scanUsers returns the users holding a lock under any policy
+func (s *Store) scanUsers(ctx context.Context) ([]string, error) {
+	seen := make(map[string]struct{})
+	for _, kind := range []string{":c:", ":d:"} {
+		prefix := s.prefix + kind
+		iter := s.client.Scan(ctx, 0, prefix+"*", scanCount).Iterator()
+		for iter.Next(ctx) {
+			seen[strings.TrimPrefix(iter.Val(), prefix)] = struct{}{}
+		}
+		if err := iter.Err(); err != nil {
+			return nil, fmt.Errorf("failed to scan locks: %w", err)
+		}
+	}
+	userIDs := make([]string, 0, len(seen))
+	for userID := range seen {
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, nil
+}
+
+// lockValues returns the held lock values of the users, device locks past their expiry are
+// left out as the next acquisition drops them
+func (s *Store) lockValues(ctx context.Context, userIDs []string) ([]lockValue, error) {
+	now := time.Now()
+	conns := make([]*redis.StringCmd, len(userIDs))
+	ttls := make([]*redis.DurationCmd, len(userIDs))
+	devices := make([]*redis.ZSliceCmd, len(userIDs))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, userID := range userIDs {
+			conns[i] = pipe.Get(ctx, s.connKey(userID))
+			ttls[i] = pipe.PTTL(ctx, s.connKey(userID))
+			devices[i] = pipe.ZRangeWithScores(ctx, s.devicesKey(userID), 0, -1)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to get locks: %w", err)
+	}
+
+	var values []lockValue
+	for i, userID := range userIDs {
+		if value, err := conns[i].Result(); err == nil {
+			values = append(values, lockValue{
+				userID:    userID,
+				value:     value,
+				expiresAt: now.Add(ttls[i].Val()),
+			})
+		}
+		for _, member := range devices[i].Val() {
+			expiresAt := time.UnixMilli(int64(member.Score))
+			if value, ok := member.Member.(string); ok && expiresAt.After(now) {
+				values = append(values, lockValue{
+					userID:    userID,
+					value:     value,
+					expiresAt: expiresAt,
+				})
+			}
+		}
+	}
+	return values, nil
+}
+
+// acquiredAt returns the acquisition times of the lock values by userId/value, locks taken
+// before gateways recorded them have none
+func (s *Store) acquiredAt(ctx context.Context, values []lockValue) (map[string]time.Time, error) {
+	cmds := make([]*redis.SliceCmd, len(values))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, v := range values {
+			cmds[i] = pipe.HMGet(ctx, s.acquiredKey(v.userID), v.value)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lock acquisitions: %w", err)
+	}
+
+	acquired := make(map[string]time.Time, len(values))
+	for i, v := range values {
+		res := cmds[i].Val()
+		if len(res) == 0 {
+			continue
+		}
+		str, ok := res[0].(string)
+		if !ok {
+			continue
+		}
+		if ms, err := strconv.ParseInt(str, 10, 64); err == nil {
+			acquired[v.userID+"/"+v.value] = time.UnixMilli(ms)
+		}
+	}
+	return acquired, nil
+}
+
+// serversAlive sets whether each server of the map still heartbeats
+func (s *Store) serversAlive(ctx context.Context, servers map[string]bool) error {
+	cmds := make(map[string]*redis.IntCmd, len(servers))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for serverID := range servers {
+			cmds[serverID] = pipe.Exists(ctx, s.serverKey(serverID))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check gateways: %w", err)
+	}
+	for serverID, cmd := range cmds {
+		servers[serverID] = cmd.Val() > 0
+	}
+	return nil
+}
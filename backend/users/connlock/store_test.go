@@ -0,0 +1,83 @@
+package connlock
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T) (*Store, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewStore(client, "test"), client
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("lists locks of every policy", func(t *testing.T) {
+		s, client := newTestStore(t)
+		acquired := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+
+		require.NoError(t, client.Set(ctx, "test:c:user1", "gw1:conn1", 30*time.Second).Err())
+		require.NoError(t, client.HSet(ctx, "test:ct:user1", "gw1:conn1", strconv.FormatInt(acquired.UnixMilli(), 10)).Err())
+		require.NoError(t, client.Set(ctx, "test:c:user2", "gw2:conn2:room1", 30*time.Second).Err())
+		require.NoError(t, client.ZAdd(ctx, "test:d:user3",
+			redis.Z{Score: float64(time.Now().Add(time.Minute).UnixMilli()), Member: "gw1:conn3"},
+			redis.Z{Score: float64(time.Now().Add(-time.Minute).UnixMilli()), Member: "gw1:expired"},
+		).Err())
+		require.NoError(t, client.Set(ctx, "test:s:gw1", "ws://gw1/ws", 3*time.Second).Err())
+
+		locks, err := s.List(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, locks, 3)
+
+		assert.Equal(t, "user1", locks[0].UserID)
+		assert.Equal(t, "gw1", locks[0].ServerID)
+		assert.Equal(t, "conn1", locks[0].ConnID)
+		require.NotNil(t, locks[0].AcquiredAt)
+		assert.True(t, acquired.Equal(*locks[0].AcquiredAt))
+		assert.True(t, locks[0].ServerAlive)
+
+		// held by a gateway which stopped heartbeating
+		assert.Equal(t, "user2", locks[1].UserID)
+		assert.Equal(t, "room1", locks[1].RoomID)
+		assert.Nil(t, locks[1].AcquiredAt)
+		assert.False(t, locks[1].ServerAlive)
+
+		assert.Equal(t, "user3", locks[2].UserID)
+		assert.Equal(t, "conn3", locks[2].ConnID)
+
+		locks, err = s.List(ctx, "user2")
+		require.NoError(t, err)
+		require.Len(t, locks, 1)
+		assert.Equal(t, "conn2", locks[0].ConnID)
+	})
+
+	t.Run("force releases the locks of the user", func(t *testing.T) {
+		s, client := newTestStore(t)
+
+		require.NoError(t, client.Set(ctx, "test:c:user1", "gw1:conn1", 30*time.Second).Err())
+		require.NoError(t, client.HSet(ctx, "test:ct:user1", "gw1:conn1", "1").Err())
+
+		released, err := s.ForceRelease(ctx, "user1")
+		require.NoError(t, err)
+		assert.True(t, released)
+		assert.Zero(t, client.Exists(ctx, "test:c:user1", "test:ct:user1").Val())
+
+		released, err = s.ForceRelease(ctx, "user1")
+		require.NoError(t, err)
+		assert.False(t, released)
+
+		locks, err := s.List(ctx, "")
+		require.NoError(t, err)
+		assert.Empty(t, locks)
+	})
+}
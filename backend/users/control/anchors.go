@@ -0,0 +1,48 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+)
+
+// recordAnchor appends the user to the anchors of the room in etcd, kept until the room is
+// purged so the rooms service can archive who went on air
+func (c *UserStatusControl) recordAnchor(ctx context.Context, roomID, userID string) error {
+	room, ok := c.roomWatcher.GetCachedState(roomID)
+	if !ok {
+		// do not recreate keys of a deleted room
+		delete(c.anchors, roomID)
+		return nil
+	}
+
+	// the cache lags behind our own writes, it only seeds the list after a restart
+	userIDs, ok := c.anchors[roomID]
+	if !ok {
+		userIDs = slices.Clone(room.GetAnchors().GetUserIDs())
+	}
+	if slices.Contains(userIDs, userID) {
+		c.anchors[roomID] = userIDs
+		return nil
+	}
+	userIDs = append(userIDs, userID)
+
+	data, err := json.Marshal(&etcdstate.Anchors{
+		UserIDs:   userIDs,
+		UpdatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal room anchors: %w", err)
+	}
+	key := fmt.Sprintf("%s%s/%s", c.prefixRoom, roomID, constants.RoomKeyAnchors)
+	if _, err := c.etcdKV.Put(ctx, key, string(data)); err != nil {
+		return fmt.Errorf("failed to put room anchors: %w", err)
+	}
+	c.anchors[roomID] = userIDs
+	return nil
+}
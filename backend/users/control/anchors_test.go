@@ -0,0 +1,43 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+)
+
+func (s *UserStatusControlTestSuite) TestRecordAnchor() {
+	s.Run("seeds from cached state and appends", func() {
+		s.mockRoomWatcher.EXPECT().GetCachedState("room1").Return(&etcdstate.RoomState{
+			Anchors: &etcdstate.Anchors{UserIDs: []string{"user1"}},
+		}, true)
+		s.mockKV.EXPECT().Put(gomock.Any(), "/rooms/room1/anchors", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, val string, _ ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+				var anchors etcdstate.Anchors
+				s.Require().NoError(json.Unmarshal([]byte(val), &anchors))
+				s.Equal([]string{"user1", "user2"}, anchors.UserIDs)
+				return &clientv3.PutResponse{}, nil
+			})
+
+		s.Require().NoError(s.ctrl.recordAnchor(s.ctx, "room1", "user2"))
+		s.Equal([]string{"user1", "user2"}, s.ctrl.anchors["room1"])
+	})
+
+	s.Run("skips known anchor", func() {
+		// stale cache, the local list wins
+		s.mockRoomWatcher.EXPECT().GetCachedState("room1").Return(&etcdstate.RoomState{}, true)
+
+		s.Require().NoError(s.ctrl.recordAnchor(s.ctx, "room1", "user2"))
+	})
+
+	s.Run("does not recreate deleted room", func() {
+		s.mockRoomWatcher.EXPECT().GetCachedState("room1").Return(nil, false)
+
+		s.Require().NoError(s.ctrl.recordAnchor(s.ctx, "room1", "user3"))
+		s.NotContains(s.ctrl.anchors, "room1")
+	})
+}
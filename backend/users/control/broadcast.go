@@ -0,0 +1,23 @@
+package control
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+// broadcastMethod is the ws-notify method of room broadcasts
+const broadcastMethod = "roomBroadcast"
+
+// Broadcast relays notify to the gateways of its room. It writes to the ws-notify stream
+// directly, broadcasts change no user state so they skip the event loop
+func (c *UserStatusControl) Broadcast(ctx context.Context, notify *users.NotifyBroadcast) error {
+	if err := c.peer2ws.Notify(ctx, notify.RoomID, broadcastMethod, notify); err != nil {
+		rpcNotificationsFailed.Add(ctx, 1)
+		return fmt.Errorf("failed to broadcast to room: %w", err)
+	}
+	rpcNotificationsSent.Add(ctx, 1)
+	roomBroadcasts.Add(ctx, 1)
+	return nil
+}
@@ -0,0 +1,26 @@
+package control
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+func (s *UserStatusControlTestSuite) TestBroadcast() {
+	sentAt := time.Now().UTC()
+	err := s.ctrl.Broadcast(s.ctx, &users.NotifyBroadcast{
+		RoomID:  "room1",
+		Event:   "countdown",
+		Payload: json.RawMessage(`{"seconds":10}`),
+		SentAt:  sentAt,
+	})
+	s.Require().NoError(err)
+
+	var notify users.NotifyBroadcast
+	s.lastWSNotification("roomBroadcast", &notify)
+	s.Equal("room1", notify.RoomID)
+	s.Equal("countdown", notify.Event)
+	s.JSONEq(`{"seconds":10}`, string(notify.Payload))
+	s.True(sentAt.Equal(notify.SentAt))
+}
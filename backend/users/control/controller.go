@@ -2,16 +2,20 @@ package control
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
 	redisrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/redis"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
-	etcdwatcher "github.com/imtaco/audio-rtc-exp/internal/reswatcher/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/roomstate"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
 	"github.com/imtaco/audio-rtc-exp/users"
 
 	"github.com/redis/go-redis/v9"
@@ -24,17 +28,30 @@ const (
 // Only one controller instance is expected to run in the system
 type UserStatusControl struct {
 	roomState   users.RoomsState
-	roomWatcher etcdwatcher.RoomWatcher
+	roomWatcher roomstate.Watcher
+	etcdKV      etcd.KV
+	prefixRoom  string
+	qualities   map[string]*etcdstate.Quality // last written room quality, accessed from loop only
+	anchors     map[string][]string           // last written room anchors, accessed from loop only
+	queues      map[string][]*queuedUser      // join queues of full rooms, accessed from loop only
 	// rpc
-	peer2svc            jsonrpc.Peer[any]
-	peer2ws             jsonrpc.Peer[any]
+	rpcServer           *streamrpc.Server
+	peer2ws             redisrpc.Notifier
 	userEventCh         chan *userEvent
 	logger              *log.Logger
 	expireCheckInterval time.Duration
+	eviction            *EvictionPolicy
+	statusWatchers      roomStatusWatchers
+	statusBatch         *StatusBatch
+	joinQueue           *JoinQueue
+	pendingStatuses     []*pendingStatus // batched status updates, accessed from loop only
+	batchTimer          *time.Timer
 }
 
 type userEvent struct {
 	action func(ctx context.Context) error
+	// status is set instead of action for status updates to batch
+	status *pendingStatus
 	ts     time.Time
 }
 
@@ -46,49 +63,60 @@ func NewUserStatusControl(
 	streamIn string,
 	streamReply string,
 	wsStreamName string,
+	wsNotifyPartitions int,
+	wsNotifyBuffer *redisstream.BufferConfig,
+	eviction *EvictionPolicy,
+	statusBatch *StatusBatch,
+	joinQueue *JoinQueue,
 	logger *log.Logger,
 ) (*UserStatusControl, error) {
 
-	// TODO: use forever client ?
-	peer2svc, err := redisrpc.NewPeer[any](
+	// replies go to the stream named by each request
+	rpcServer, err := streamrpc.NewServer(
 		redisClient,
-		streamReply,
 		streamIn,
 		"user-status-controller",
 		logger,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create WS RPC peer: %w", err)
+		return nil, fmt.Errorf("failed to create RPC server: %w", err)
 	}
 
-	roomWatcher := etcdwatcher.NewRoomWatcher(
-		etcdClient,
-		etcdPrefixRoom,
-		[]string{constants.RoomKeyMeta},
-		nil,
-		logger.Module("Room"),
-	)
-
-	peer2ws, err := redisrpc.NewPeer[any](
+	peer2ws, err := redisrpc.NewNotifier(
 		redisClient,
 		wsStreamName,
-		"",
-		"",
+		wsNotifyPartitions,
+		wsNotifyBuffer,
 		logger,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create RPC peer: %w", err)
 	}
 
-	return &UserStatusControl{
+	c := &UserStatusControl{
 		roomState:           roomState,
-		roomWatcher:         roomWatcher,
-		peer2svc:            peer2svc,
+		etcdKV:              etcdClient,
+		prefixRoom:          etcdPrefixRoom,
+		qualities:           make(map[string]*etcdstate.Quality),
+		anchors:             make(map[string][]string),
+		queues:              make(map[string][]*queuedUser),
+		rpcServer:           rpcServer,
 		peer2ws:             peer2ws,
 		userEventCh:         make(chan *userEvent, 10),
 		logger:              logger,
 		expireCheckInterval: defaultExpireCheckInterval,
-	}, nil
+		eviction:            eviction,
+		statusBatch:         statusBatch,
+		joinQueue:           joinQueue,
+	}
+	c.roomWatcher = roomstate.New(&roomstate.Config{
+		Client:   etcdClient,
+		Prefix:   etcdPrefixRoom,
+		KeyTypes: []string{constants.RoomKeyMeta, constants.RoomKeyAnchors},
+		OnChange: c.processRoomChange,
+		Logger:   logger.Module("Room"),
+	})
+	return c, nil
 }
 
 func (c *UserStatusControl) Start(ctx context.Context) error {
@@ -106,8 +134,8 @@ func (c *UserStatusControl) Start(ctx context.Context) error {
 	}
 	watcherStarted.Add(ctx, 1)
 
-	if err := c.peer2svc.Open(ctx); err != nil {
-		return fmt.Errorf("failed to start svc RPC peer: %w", err)
+	if err := c.rpcServer.Open(ctx); err != nil {
+		return fmt.Errorf("failed to start RPC server: %w", err)
 	}
 	if err := c.peer2ws.Open(ctx); err != nil {
 		return fmt.Errorf("failed to start WS RPC peer: %w", err)
@@ -118,26 +146,24 @@ func (c *UserStatusControl) Start(ctx context.Context) error {
 }
 
 func (c *UserStatusControl) registerRPC() {
-	c.peer2svc.DefAsync("createUser", c.handleCreate)
-	c.peer2svc.DefAsync("deleteUser", c.handleDelete)
-	c.peer2svc.DefAsync("setUserStatus", c.handleSetStatus)
+	users.MethodCreateUser.Handle(c.rpcServer, c.handleCreate)
+	users.MethodDeleteUser.Handle(c.rpcServer, c.handleDelete)
+	users.MethodSetUserStatus.Handle(c.rpcServer, c.handleSetStatus)
+	users.MethodSetUserQuality.Handle(c.rpcServer, c.handleSetQuality)
+	users.MethodSetUserHand.Handle(c.rpcServer, c.handleSetHand)
+	users.MethodGrantFloor.Handle(c.rpcServer, c.handleGrantFloor)
+	users.MethodSetPublish.Handle(c.rpcServer, c.handleSetPublish)
+	users.MethodGetRoomUsers.Handle(c.rpcServer, c.handleGetRoomUsers)
+	users.MethodQueuePosition.Handle(c.rpcServer, c.handleQueuePosition)
 }
 
 func (c *UserStatusControl) handleCreate(
-	_ jsonrpc.MethodContext[any],
-	params *json.RawMessage,
-	reply jsonrpc.Reply,
+	ctx context.Context,
+	req *users.CreateUserRequest,
+	reply func(*streamrpc.Empty, error),
 ) {
-	ctx := context.Background()
 	rpcRequestsReceived.Add(ctx, 1)
 
-	req := users.CreateUserRequest{}
-	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
-		rpcRequestsFailed.Add(ctx, 1)
-		reply(nil, err)
-		return
-	}
-
 	c.logger.Debug("receive create user RPC",
 		log.String("roomId", req.RoomID),
 		log.String("userId", req.UserID),
@@ -153,15 +179,32 @@ func (c *UserStatusControl) handleCreate(
 		reply(nil, jsonrpc.ErrInvalidRequest("room not found"))
 		return
 	}
+	if room.GetMeta().GetEndStage() != "" {
+		c.logger.Warn("Room is ending",
+			log.String("roomId", req.RoomID),
+		)
+		rpcRequestsFailed.Add(ctx, 1)
+		reply(nil, jsonrpc.ErrInvalidRequest("room is ending"))
+		return
+	}
 	maxAnchors := room.GetMeta().GetMaxAnchors()
+	maxQueue := room.GetMeta().GetJoinQueue()
 
 	action := func(ctx context.Context) error {
+		// users already waiting go first
+		c.admitQueued(ctx, req.RoomID)
+
 		// Check current anchors count
-		currentUsers := c.roomState.GetRoomUsers(ctx, req.RoomID)
-		if len(currentUsers) >= maxAnchors {
+		currentUsers := countAnchors(c.roomState.GetRoomUsers(ctx, req.RoomID))
+		if currentUsers >= maxAnchors || len(c.queues[req.RoomID]) > 0 {
+			if c.enqueue(ctx, req, maxQueue) > 0 {
+				rpcRequestsProcessed.Add(ctx, 1)
+				reply(nil, nil)
+				return nil
+			}
 			c.logger.Warn("Reached max anchors limit",
 				log.String("roomId", req.RoomID),
-				log.Int("currentUsers", len(currentUsers)),
+				log.Int("currentUsers", currentUsers),
 				log.Int("maxAnchors", maxAnchors),
 			)
 			maxAnchorsReached.Add(ctx, 1)
@@ -188,6 +231,10 @@ func (c *UserStatusControl) handleCreate(
 		if ok {
 			usersCreated.Add(ctx, 1)
 			activeUsers.Add(ctx, 1)
+
+			if err := c.recordAnchor(ctx, req.RoomID, req.UserID); err != nil {
+				c.logger.Error("Failed to record room anchor", log.Error(err))
+			}
 		}
 
 		c.logger.Info("User created",
@@ -211,20 +258,12 @@ func (c *UserStatusControl) handleCreate(
 }
 
 func (c *UserStatusControl) handleDelete(
-	_ jsonrpc.MethodContext[any],
-	params *json.RawMessage,
-	reply jsonrpc.Reply,
+	ctx context.Context,
+	req *users.DeleteUserRequest,
+	reply func(*streamrpc.Empty, error),
 ) {
-	ctx := context.Background()
 	rpcRequestsReceived.Add(ctx, 1)
 
-	req := users.DeleteUserRequest{}
-	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
-		rpcRequestsFailed.Add(ctx, 1)
-		reply(nil, err)
-		return
-	}
-
 	action := func(ctx context.Context) error {
 
 		ok, err := c.roomState.RemoveUser(ctx, req.RoomID, req.UserID)
@@ -242,6 +281,12 @@ func (c *UserStatusControl) handleDelete(
 			if err := c.notifyUserStatus(ctx, req.RoomID); err != nil {
 				c.logger.Error("Failed to send WS room members", log.Error(err))
 			}
+			if err := c.syncRoomQuality(ctx, req.RoomID); err != nil {
+				c.logger.Error("Failed to sync room quality", log.Error(err))
+			}
+			c.admitQueued(ctx, req.RoomID)
+		} else {
+			ok = c.leaveQueue(ctx, req.RoomID, req.UserID)
 		}
 
 		c.logger.Info("User deleted",
@@ -264,18 +309,19 @@ func (c *UserStatusControl) handleDelete(
 }
 
 func (c *UserStatusControl) handleSetStatus(
-	_ jsonrpc.MethodContext[any],
-	params *json.RawMessage,
-	reply jsonrpc.Reply,
+	ctx context.Context,
+	req *users.SetStatusUserRequest,
+	reply func(*streamrpc.Empty, error),
 ) {
-	ctx := context.Background()
 	rpcRequestsReceived.Add(ctx, 1)
 
-	req := users.SetStatusUserRequest{}
-	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
-		c.logger.Debug("handleSetStatus called error", log.Error(err))
-		rpcRequestsFailed.Add(ctx, 1)
-		reply(nil, err)
+	if c.statusBatch.enabled() {
+		userEventsQueued.Add(ctx, 1)
+		userEventQueueDepth.Add(ctx, 1)
+		c.userEventCh <- &userEvent{
+			status: &pendingStatus{req: req, reply: reply},
+			ts:     req.TS,
+		}
 		return
 	}
 
@@ -300,6 +346,7 @@ func (c *UserStatusControl) handleSetStatus(
 				c.logger.Error("Failed to send WS room members", log.Error(err))
 			}
 		}
+		c.queueOnStatus(ctx, req.RoomID, req.UserID, req.Status, ok)
 
 		c.logger.Debug("User status updated",
 			log.String("roomId", req.RoomID),
@@ -321,32 +368,45 @@ func (c *UserStatusControl) handleSetStatus(
 	}
 }
 
-func (c *UserStatusControl) notifyUserStatus(ctx context.Context, roomID string) error {
-
+// roomMembers returns the active users of the room
+func (c *UserStatusControl) roomMembers(ctx context.Context, roomID string) []*users.RoomUser {
 	us := c.roomState.GetRoomUsers(ctx, roomID)
 	members := make([]*users.RoomUser, 0, len(us))
 
-	c.logger.Debug("Notifying room user status",
-		log.String("roomId", roomID),
-		log.Any("members", members),
-	)
-
 	for userID, u := range us {
 		if !u.IsActive() {
 			continue
 		}
-		members = append(members, &users.RoomUser{
-			UserID: userID,
-			Role:   u.Role,
-			Status: u.Status,
-		})
+		member := &users.RoomUser{
+			UserID:  userID,
+			Role:    u.Role,
+			Status:  u.Status,
+			Quality: u.Quality,
+			Floor:   u.Floor,
+			Publish: u.Publish,
+		}
+		if !u.HandRaisedAt.IsZero() {
+			member.HandRaisedAt = &u.HandRaisedAt
+		}
+		members = append(members, member)
 	}
+	return members
+}
+
+func (c *UserStatusControl) notifyUserStatus(ctx context.Context, roomID string) error {
+	members := c.roomMembers(ctx, roomID)
+
+	c.logger.Debug("Notifying room user status",
+		log.String("roomId", roomID),
+		log.Any("members", members),
+	)
 
 	req := &users.NotifyRoomStatus{
 		RoomID:  roomID,
 		Members: members,
 	}
-	if err := c.peer2ws.Notify(ctx, "broadcastRoomStatus", req); err != nil {
+	c.statusWatchers.publish(req)
+	if err := c.peer2ws.Notify(ctx, roomID, "broadcastRoomStatus", req); err != nil {
 		c.logger.Error("Failed to send WS room members", log.Error(err))
 		rpcNotificationsFailed.Add(ctx, 1)
 		return err
@@ -376,6 +436,14 @@ func (c *UserStatusControl) loop(ctx context.Context) {
 	expireTicker := time.NewTicker(c.expireCheckInterval)
 	defer expireTicker.Stop()
 
+	// fires when the status batch is due, nil while it is empty
+	var batchDue <-chan time.Time
+	if c.statusBatch.enabled() {
+		c.batchTimer = time.NewTimer(c.statusBatch.MaxWait)
+		c.batchTimer.Stop()
+		defer c.batchTimer.Stop()
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -384,6 +452,14 @@ func (c *UserStatusControl) loop(ctx context.Context) {
 			// Decrement queue depth when processing event
 			userEventQueueDepth.Add(ctx, -1)
 
+			if event.status != nil {
+				batchDue = c.batchStatus(ctx, event.status)
+				continue
+			}
+			// any other event sees the statuses received before it
+			c.flushStatuses(ctx)
+			batchDue = nil
+
 			// TODO: check outdated ts
 			// if event.ts.Before(time.Now().Add(-userStatusTimeout)) {
 			// outdated event, skip
@@ -395,7 +471,13 @@ func (c *UserStatusControl) loop(ctx context.Context) {
 			} else {
 				userEventsProcessed.Add(ctx, 1)
 			}
+		case <-batchDue:
+			c.flushStatuses(ctx)
+			batchDue = nil
 		case <-expireTicker.C:
+			c.flushStatuses(ctx)
+			batchDue = nil
+
 			// TODO: stop scheduler when suffer some errors ?
 			timeoutChecksRun.Add(ctx, 1)
 
@@ -414,7 +496,13 @@ func (c *UserStatusControl) loop(ctx context.Context) {
 				if err := c.notifyUserStatus(ctx, roomID); err != nil {
 					c.logger.Error("Failed to notify user status", log.Error(err))
 				}
+				if err := c.syncRoomQuality(ctx, roomID); err != nil {
+					c.logger.Error("Failed to sync room quality", log.Error(err))
+				}
 			}
+
+			c.evictInactive(ctx)
+			c.serveQueues(ctx)
 		}
 	}
 }
@@ -423,8 +511,8 @@ func (c *UserStatusControl) Stop() error {
 	ctx := context.Background()
 	c.logger.Info("Closing")
 
-	if err := c.peer2svc.Close(); err != nil {
-		return fmt.Errorf("failed to close svc RPC peer: %w", err)
+	if err := c.rpcServer.Close(); err != nil {
+		return fmt.Errorf("failed to close RPC server: %w", err)
 	}
 	if err := c.peer2ws.Close(); err != nil {
 		return fmt.Errorf("failed to close ws RPC peer: %w", err)
@@ -437,3 +525,31 @@ func (c *UserStatusControl) Stop() error {
 
 	return nil
 }
+
+// handleGetRoomUsers replies the active users of a room, read in the event loop like any other
+// access to the room state
+func (c *UserStatusControl) handleGetRoomUsers(
+	ctx context.Context,
+	req *users.GetRoomUsersRequest,
+	reply func(*users.GetRoomUsersResponse, error),
+) {
+	rpcRequestsReceived.Add(ctx, 1)
+
+	action := func(ctx context.Context) error {
+		members := c.roomMembers(ctx, req.RoomID)
+		slices.SortFunc(members, func(a, b *users.RoomUser) int {
+			return strings.Compare(a.UserID, b.UserID)
+		})
+
+		rpcRequestsProcessed.Add(ctx, 1)
+		reply(&users.GetRoomUsersResponse{Users: members}, nil)
+		return nil
+	}
+
+	userEventsQueued.Add(ctx, 1)
+	userEventQueueDepth.Add(ctx, 1)
+	c.userEventCh <- &userEvent{
+		action: action,
+		ts:     req.TS,
+	}
+}
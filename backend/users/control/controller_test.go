@@ -7,17 +7,20 @@ import (
 	"time"
 
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	kvmocks "github.com/imtaco/audio-rtc-exp/internal/etcd/mocks"
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
 	redisrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/redis"
 	log "github.com/imtaco/audio-rtc-exp/internal/log"
-	etcdmocks "github.com/imtaco/audio-rtc-exp/internal/reswatcher/etcd/mocks"
+	roomstatemocks "github.com/imtaco/audio-rtc-exp/internal/roomstate/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
 	"github.com/imtaco/audio-rtc-exp/users"
 	"github.com/imtaco/audio-rtc-exp/users/mocks"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/mock/gomock"
 )
 
@@ -28,7 +31,8 @@ type UserStatusControlTestSuite struct {
 	mr              *miniredis.Miniredis
 	ctx             context.Context
 	mockRoomState   *mocks.MockRoomsState
-	mockRoomWatcher *etcdmocks.MockRoomWatcher
+	mockRoomWatcher *roomstatemocks.MockWatcher
+	mockKV          *kvmocks.MockKV
 	gomockCtrl      *gomock.Controller
 }
 
@@ -44,22 +48,22 @@ func (s *UserStatusControlTestSuite) SetupTest() {
 
 	s.gomockCtrl = gomock.NewController(s.T())
 	s.mockRoomState = mocks.NewMockRoomsState(s.gomockCtrl)
-	s.mockRoomWatcher = etcdmocks.NewMockRoomWatcher(s.gomockCtrl)
+	s.mockRoomWatcher = roomstatemocks.NewMockWatcher(s.gomockCtrl)
+	s.mockKV = kvmocks.NewMockKV(s.gomockCtrl)
 
-	peer2svc, err := redisrpc.NewPeer[any](
+	rpcServer, err := streamrpc.NewServer(
 		redisClient,
-		"test:stream:reply",
 		"test:stream:input",
 		"test-controller",
 		logger,
 	)
 	s.Require().NoError(err)
 
-	peer2ws, err := redisrpc.NewPeer[any](
+	peer2ws, err := redisrpc.NewNotifier(
 		redisClient,
 		"test:ws:stream",
-		"",
-		"",
+		0,
+		nil,
 		logger,
 	)
 	s.Require().NoError(err)
@@ -67,7 +71,11 @@ func (s *UserStatusControlTestSuite) SetupTest() {
 	ctrl := &UserStatusControl{
 		roomState:           s.mockRoomState,
 		roomWatcher:         s.mockRoomWatcher,
-		peer2svc:            peer2svc,
+		etcdKV:              s.mockKV,
+		prefixRoom:          "/rooms/",
+		qualities:           make(map[string]*etcdstate.Quality),
+		anchors:             make(map[string][]string),
+		rpcServer:           rpcServer,
 		peer2ws:             peer2ws,
 		userEventCh:         make(chan *userEvent, 10),
 		logger:              logger,
@@ -80,6 +88,25 @@ func (s *UserStatusControlTestSuite) SetupTest() {
 	s.ctx = context.Background()
 }
 
+// lastWSNotification decodes the params of the latest notification of method sent to the WS stream
+func (s *UserStatusControlTestSuite) lastWSNotification(method string, params any) {
+	msgs, err := s.redisClient.XRevRange(s.ctx, "test:ws:stream", "+", "-").Result()
+	s.Require().NoError(err)
+	for _, msg := range msgs {
+		data, _ := msg.Values["data"].(string)
+		var notification struct {
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		s.Require().NoError(json.Unmarshal([]byte(data), &notification))
+		if notification.Method == method {
+			s.Require().NoError(json.Unmarshal(notification.Params, params))
+			return
+		}
+	}
+	s.Failf("notification not sent", "no %s notification in WS stream", method)
+}
+
 func (s *UserStatusControlTestSuite) TearDownTest() {
 	if s.redisClient != nil {
 		s.redisClient.Close()
@@ -98,7 +125,7 @@ func TestUserStatusControlSuite(t *testing.T) {
 
 func (s *UserStatusControlTestSuite) TestNewUserStatusControl() {
 	s.Require().NotNil(s.ctrl.roomState)
-	s.NotNil(s.ctrl.peer2svc)
+	s.NotNil(s.ctrl.rpcServer)
 	s.NotNil(s.ctrl.peer2ws)
 	s.NotNil(s.ctrl.userEventCh)
 }
@@ -121,13 +148,8 @@ func (s *UserStatusControlTestSuite) TestHandleCreate() {
 			TS:     time.Now(),
 		}
 
-		params, err := json.Marshal(req)
-		s.Require().NoError(err)
-
-		rawParams := json.RawMessage(params)
-
 		replyCalled := false
-		reply := func(_ any, err error) {
+		reply := func(_ *streamrpc.Empty, err error) {
 			replyCalled = true
 			s.Require().NoError(err)
 		}
@@ -138,7 +160,7 @@ func (s *UserStatusControlTestSuite) TestHandleCreate() {
 				MaxAnchors: 5,
 			},
 		}
-		s.mockRoomWatcher.EXPECT().GetCachedState(req.RoomID).Return(roomState, true)
+		s.mockRoomWatcher.EXPECT().GetCachedState(req.RoomID).Return(roomState, true).Times(2)
 
 		// Expect GetRoomUsers to check current count
 		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), req.RoomID).Return(map[string]users.User{})
@@ -146,8 +168,10 @@ func (s *UserStatusControlTestSuite) TestHandleCreate() {
 		// Expect CreateUser call
 		s.mockRoomState.EXPECT().CreateUser(gomock.Any(), req.RoomID, req.UserID, gomock.Any()).Return(true, nil)
 
-		methodCtx := jsonrpc.NewContext[any](nil, nil)
-		s.ctrl.handleCreate(methodCtx, &rawParams, reply)
+		// Expect the anchor recorded for the room archive
+		s.mockKV.EXPECT().Put(gomock.Any(), "/rooms/room1/anchors", gomock.Any()).Return(&clientv3.PutResponse{}, nil)
+
+		s.ctrl.handleCreate(s.ctx, req, reply)
 
 		select {
 		case event := <-s.ctrl.userEventCh:
@@ -160,21 +184,20 @@ func (s *UserStatusControlTestSuite) TestHandleCreate() {
 		s.True(replyCalled)
 	})
 
-	s.Run("handle create with invalid JSON", func() {
-		invalidParams := json.RawMessage(`{invalid json}`)
+	s.Run("handle create with invalid params", func() {
+		s.ctrl.registerRPC()
+		s.Require().NoError(s.ctrl.rpcServer.Open(ctx))
+		defer s.ctrl.rpcServer.Close()
 
-		replyCalled := false
-		var replyErr error
-		reply := func(_ any, err error) {
-			replyCalled = true
-			replyErr = err
-		}
-
-		methodCtx := jsonrpc.NewContext[any](nil, nil)
-		s.ctrl.handleCreate(methodCtx, &invalidParams, reply)
+		client, err := streamrpc.NewClient(s.redisClient, "test:stream:input", "test:stream:reply", nil, log.NewNop())
+		s.Require().NoError(err)
+		s.Require().NoError(client.Open(ctx))
+		defer client.Close()
 
-		s.True(replyCalled)
-		s.Require().Error(replyErr)
+		err = client.Call(ctx, string(users.MethodCreateUser), "invalid", nil)
+		var rpcErr *jsonrpc.Error
+		s.Require().ErrorAs(err, &rpcErr)
+		s.Equal(int64(jsonrpc.CodeInvalidParams), rpcErr.Code)
 	})
 
 	s.Run("handle create when room not found", func() {
@@ -185,14 +208,9 @@ func (s *UserStatusControlTestSuite) TestHandleCreate() {
 			TS:     time.Now(),
 		}
 
-		params, err := json.Marshal(req)
-		s.Require().NoError(err)
-
-		rawParams := json.RawMessage(params)
-
 		replyCalled := false
 		var replyErr error
-		reply := func(_ any, err error) {
+		reply := func(_ *streamrpc.Empty, err error) {
 			replyCalled = true
 			replyErr = err
 		}
@@ -200,8 +218,7 @@ func (s *UserStatusControlTestSuite) TestHandleCreate() {
 		// Mock room watcher returning room not found
 		s.mockRoomWatcher.EXPECT().GetCachedState(req.RoomID).Return(nil, false)
 
-		methodCtx := jsonrpc.NewContext[any](nil, nil)
-		s.ctrl.handleCreate(methodCtx, &rawParams, reply)
+		s.ctrl.handleCreate(s.ctx, req, reply)
 
 		s.True(replyCalled)
 		s.Require().Error(replyErr)
@@ -215,14 +232,9 @@ func (s *UserStatusControlTestSuite) TestHandleCreate() {
 			TS:     time.Now(),
 		}
 
-		params, err := json.Marshal(req)
-		s.Require().NoError(err)
-
-		rawParams := json.RawMessage(params)
-
 		replyCalled := false
 		var replyErr error
-		reply := func(_ any, err error) {
+		reply := func(_ *streamrpc.Empty, err error) {
 			replyCalled = true
 			replyErr = err
 		}
@@ -245,8 +257,7 @@ func (s *UserStatusControlTestSuite) TestHandleCreate() {
 		}
 		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), req.RoomID).Return(existingUsers)
 
-		methodCtx := jsonrpc.NewContext[any](nil, nil)
-		s.ctrl.handleCreate(methodCtx, &rawParams, reply)
+		s.ctrl.handleCreate(s.ctx, req, reply)
 
 		select {
 		case event := <-s.ctrl.userEventCh:
@@ -269,13 +280,8 @@ func (s *UserStatusControlTestSuite) TestHandleCreate() {
 			TS:     time.Now(),
 		}
 
-		params, err := json.Marshal(req)
-		s.Require().NoError(err)
-
-		rawParams := json.RawMessage(params)
-
 		replyCalled := false
-		reply := func(_ any, err error) {
+		reply := func(_ *streamrpc.Empty, err error) {
 			replyCalled = true
 			s.Require().NoError(err)
 		}
@@ -286,7 +292,7 @@ func (s *UserStatusControlTestSuite) TestHandleCreate() {
 				MaxAnchors: 3,
 			},
 		}
-		s.mockRoomWatcher.EXPECT().GetCachedState(req.RoomID).Return(roomState, true)
+		s.mockRoomWatcher.EXPECT().GetCachedState(req.RoomID).Return(roomState, true).Times(2)
 
 		// Mock current room has 2 users (one slot available)
 		existingUsers := map[string]users.User{
@@ -297,9 +303,9 @@ func (s *UserStatusControlTestSuite) TestHandleCreate() {
 
 		// Expect CreateUser to be called since we haven't reached the limit yet
 		s.mockRoomState.EXPECT().CreateUser(gomock.Any(), req.RoomID, req.UserID, gomock.Any()).Return(true, nil)
+		s.mockKV.EXPECT().Put(gomock.Any(), "/rooms/room1/anchors", gomock.Any()).Return(&clientv3.PutResponse{}, nil)
 
-		methodCtx := jsonrpc.NewContext[any](nil, nil)
-		s.ctrl.handleCreate(methodCtx, &rawParams, reply)
+		s.ctrl.handleCreate(s.ctx, req, reply)
 
 		select {
 		case event := <-s.ctrl.userEventCh:
@@ -323,23 +329,17 @@ func (s *UserStatusControlTestSuite) TestHandleDelete() {
 		TS:     time.Now(),
 	}
 
-	params, err := json.Marshal(req)
-	s.Require().NoError(err)
-
-	rawParams := json.RawMessage(params)
-
 	replyCalled := false
-	reply := func(_ any, _ error) {
+	reply := func(_ *streamrpc.Empty, _ error) {
 		replyCalled = true
 	}
 
 	// Expect RemoveUser call
 	s.mockRoomState.EXPECT().RemoveUser(gomock.Any(), req.RoomID, req.UserID).Return(true, nil)
-	// Expect GetRoomUsers call (for notification)
-	s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), req.RoomID).Return(map[string]users.User{})
+	// Expect GetRoomUsers calls (for notification and room quality)
+	s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), req.RoomID).Return(map[string]users.User{}).Times(2)
 
-	methodCtx := jsonrpc.NewContext[any](nil, nil)
-	s.ctrl.handleDelete(methodCtx, &rawParams, reply)
+	s.ctrl.handleDelete(s.ctx, req, reply)
 
 	select {
 	case event := <-s.ctrl.userEventCh:
@@ -365,13 +365,8 @@ func (s *UserStatusControlTestSuite) TestHandleSetStatus() {
 			TS:     time.Now(),
 		}
 
-		params, err := json.Marshal(req)
-		s.Require().NoError(err)
-
-		rawParams := json.RawMessage(params)
-
 		replyCalled := false
-		reply := func(_ any, _ error) {
+		reply := func(_ *streamrpc.Empty, _ error) {
 			replyCalled = true
 		}
 
@@ -382,8 +377,7 @@ func (s *UserStatusControlTestSuite) TestHandleSetStatus() {
 			"user1": {Status: constants.AnchorStatusOnAir, TS: time.Now()},
 		})
 
-		methodCtx := jsonrpc.NewContext[any](nil, nil)
-		s.ctrl.handleSetStatus(methodCtx, &rawParams, reply)
+		s.ctrl.handleSetStatus(s.ctx, req, reply)
 
 		select {
 		case event := <-s.ctrl.userEventCh:
@@ -405,21 +399,15 @@ func (s *UserStatusControlTestSuite) TestHandleSetStatus() {
 			TS:     time.Now(),
 		}
 
-		params, err := json.Marshal(req)
-		s.Require().NoError(err)
-
-		rawParams := json.RawMessage(params)
-
 		replyCalled := false
-		reply := func(_ any, _ error) {
+		reply := func(_ *streamrpc.Empty, _ error) {
 			replyCalled = true
 		}
 
 		// Expect UpdateUserStatus call returning false (not updated)
 		s.mockRoomState.EXPECT().UpdateUserStatus(gomock.Any(), req.RoomID, req.UserID, gomock.Any()).Return(false, nil)
 
-		methodCtx := jsonrpc.NewContext[any](nil, nil)
-		s.ctrl.handleSetStatus(methodCtx, &rawParams, reply)
+		s.ctrl.handleSetStatus(s.ctx, req, reply)
 
 		select {
 		case event := <-s.ctrl.userEventCh:
@@ -441,6 +429,11 @@ func (s *UserStatusControlTestSuite) TestNotifyUserStatus() {
 
 		err := s.ctrl.notifyUserStatus(s.ctx, roomID)
 		s.Require().NoError(err)
+
+		var req users.NotifyRoomStatus
+		s.lastWSNotification("broadcastRoomStatus", &req)
+		s.Equal(roomID, req.RoomID)
+		s.Len(req.Members, 1)
 	})
 
 	s.Run("notify with no active users", func() {
@@ -452,6 +445,29 @@ func (s *UserStatusControlTestSuite) TestNotifyUserStatus() {
 	})
 }
 
+func (s *UserStatusControlTestSuite) TestWatchRoomStatus() {
+	statuses, cancel := s.ctrl.WatchRoomStatus("room1")
+	others, cancelOthers := s.ctrl.WatchRoomStatus("room2")
+	defer cancelOthers()
+
+	s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{
+		"user1": {Status: constants.AnchorStatusOnAir, TS: time.Now()},
+	})
+	s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{})
+	s.Require().NoError(s.ctrl.notifyUserStatus(s.ctx, "room1"))
+	s.Require().NoError(s.ctrl.notifyUserStatus(s.ctx, "room1"))
+
+	// only the latest status is kept for a watcher falling behind
+	status := <-statuses
+	s.Equal("room1", status.RoomID)
+	s.Empty(status.Members)
+	s.Empty(others)
+
+	cancel()
+	cancel()
+	s.NotContains(s.ctrl.statusWatchers.watchers, "room1")
+}
+
 func (s *UserStatusControlTestSuite) TestStop() {
 	s.mockRoomWatcher.EXPECT().Stop().Return(nil)
 	err := s.ctrl.Stop()
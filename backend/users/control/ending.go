@@ -0,0 +1,63 @@
+package control
+
+import (
+	"context"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// processRoomChange stops the users of a room once it is ending past its anchors being told
+func (c *UserStatusControl) processRoomChange(ctx context.Context, roomID string, state *etcdstate.RoomState) error {
+	if state.GetMeta().GetEndStage() != constants.EndStageStoppingUsers {
+		return nil
+	}
+
+	userEventsQueued.Add(ctx, 1)
+	userEventQueueDepth.Add(ctx, 1)
+	select {
+	case c.userEventCh <- &userEvent{
+		action: func(ctx context.Context) error {
+			return c.removeRoomUsers(ctx, roomID)
+		},
+	}:
+	case <-ctx.Done():
+		userEventQueueDepth.Add(ctx, -1)
+		return ctx.Err()
+	}
+	return nil
+}
+
+// removeRoomUsers removes all users of the room and notifies the room, no-op when it has none
+func (c *UserStatusControl) removeRoomUsers(ctx context.Context, roomID string) error {
+	delete(c.queues, roomID)
+
+	removed := 0
+	for userID := range c.roomState.GetRoomUsers(ctx, roomID) {
+		ok, err := c.roomState.RemoveUser(ctx, roomID, userID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			removed++
+			activeUsers.Add(ctx, -1)
+			endedRoomUsersRemoved.Add(ctx, 1)
+		}
+	}
+	if removed == 0 {
+		return nil
+	}
+
+	c.logger.Info("Removed users of ending room",
+		log.String("roomId", roomID),
+		log.Int("removed", removed))
+
+	if err := c.notifyUserStatus(ctx, roomID); err != nil {
+		c.logger.Error("Failed to send WS room members", log.Error(err))
+	}
+	if err := c.syncRoomQuality(ctx, roomID); err != nil {
+		c.logger.Error("Failed to sync room quality", log.Error(err))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package control
+
+import (
+	"time"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+func (s *UserStatusControlTestSuite) TestProcessRoomChange() {
+	ending := func(stage constants.EndStage) *etcdstate.RoomState {
+		return &etcdstate.RoomState{Meta: &etcdstate.Meta{Ending: &etcdstate.Ending{Stage: stage}}}
+	}
+
+	s.Run("removes users once stopping users", func() {
+		s.Require().NoError(s.ctrl.processRoomChange(s.ctx, "room1", ending(constants.EndStageStoppingUsers)))
+
+		gomock.InOrder(
+			s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{
+				"user1": {Role: "anchor", Status: constants.AnchorStatusOnAir, TS: time.Now()},
+				"user2": {Role: "anchor", Status: constants.AnchorStatusIdle, TS: time.Now()},
+			}),
+			s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{}).Times(2),
+		)
+		s.mockRoomState.EXPECT().RemoveUser(gomock.Any(), "room1", "user1").Return(true, nil)
+		s.mockRoomState.EXPECT().RemoveUser(gomock.Any(), "room1", "user2").Return(true, nil)
+
+		event := <-s.ctrl.userEventCh
+		s.Require().NoError(event.action(s.ctx))
+
+		var status users.NotifyRoomStatus
+		s.lastWSNotification("broadcastRoomStatus", &status)
+		s.Equal("room1", status.RoomID)
+		s.Empty(status.Members)
+	})
+
+	s.Run("ignores other stages", func() {
+		s.Require().NoError(s.ctrl.processRoomChange(s.ctx, "room1", ending(constants.EndStageNotifying)))
+		s.Require().NoError(s.ctrl.processRoomChange(s.ctx, "room1", ending(constants.EndStageStoppingLive)))
+		s.Require().NoError(s.ctrl.processRoomChange(s.ctx, "room1", nil))
+		s.Empty(s.ctrl.userEventCh)
+	})
+}
+
+func (s *UserStatusControlTestSuite) TestRemoveRoomUsers_NoUsers() {
+	s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{})
+
+	s.Require().NoError(s.ctrl.removeRoomUsers(s.ctx, "room1"))
+}
+
+func (s *UserStatusControlTestSuite) TestHandleCreate_RoomEnding() {
+	s.mockRoomWatcher.EXPECT().GetCachedState("room1").Return(&etcdstate.RoomState{
+		Meta: &etcdstate.Meta{
+			MaxAnchors: 5,
+			Ending:     &etcdstate.Ending{Stage: constants.EndStageNotifying},
+		},
+	}, true)
+
+	var replyErr error
+	s.ctrl.handleCreate(s.ctx, &users.CreateUserRequest{RoomID: "room1", UserID: "user1"}, func(_ *streamrpc.Empty, err error) {
+		replyErr = err
+	})
+
+	s.Error(replyErr)
+	s.Empty(s.ctrl.userEventCh)
+}
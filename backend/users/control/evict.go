@@ -0,0 +1,94 @@
+package control
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+// EvictionPolicy moves anchors stuck idle or disconnected to left, so they no longer count
+// toward the max anchors of the room
+type EvictionPolicy struct {
+	// IdleTimeout is how long an anchor may stay idle or disconnected, 0 disables eviction
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+	// ReleaseHandle asks the gateway holding the connection to release the Janus handle of the anchor
+	ReleaseHandle bool `mapstructure:"release_handle"`
+}
+
+func SetupEvictionPolicy(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("idle_timeout"), 5*time.Minute)
+	v.SetDefault(p("release_handle"), false)
+}
+
+// evictInactive moves the inactive anchors to left and notifies their rooms
+func (c *UserStatusControl) evictInactive(ctx context.Context) {
+	if c.eviction == nil || c.eviction.IdleTimeout <= 0 {
+		return
+	}
+
+	now := time.Now()
+	for roomID, userIDs := range c.roomState.InactiveUsers(ctx, c.eviction.IdleTimeout) {
+		evicted := false
+		for _, userID := range userIDs {
+			ok, err := c.roomState.UpdateUserStatus(ctx, roomID, userID, &users.User{
+				Status: constants.AnchorStatusLeft,
+				TS:     now,
+			})
+			if err != nil {
+				c.logger.Error("Failed to evict inactive user",
+					log.String("roomId", roomID),
+					log.String("userId", userID),
+					log.Error(err))
+				continue
+			}
+			if !ok {
+				continue
+			}
+
+			evicted = true
+			inactiveUsersEvicted.Add(ctx, 1)
+			c.logger.Info("Evicted inactive user",
+				log.String("roomId", roomID),
+				log.String("userId", userID))
+
+			if !c.eviction.ReleaseHandle {
+				continue
+			}
+			if err := c.peer2ws.Notify(ctx, roomID, "userEvicted", &users.NotifyUserEvicted{
+				RoomID: roomID,
+				UserID: userID,
+			}); err != nil {
+				c.logger.Error("Failed to notify evicted user", log.Error(err))
+				rpcNotificationsFailed.Add(ctx, 1)
+			}
+		}
+
+		if !evicted {
+			continue
+		}
+		if err := c.notifyUserStatus(ctx, roomID); err != nil {
+			c.logger.Error("Failed to notify user status", log.Error(err))
+		}
+		if err := c.syncRoomQuality(ctx, roomID); err != nil {
+			c.logger.Error("Failed to sync room quality", log.Error(err))
+		}
+	}
+}
+
+// countAnchors counts the users of a room toward its max anchors, left users do not
+func countAnchors(us map[string]users.User) int {
+	n := 0
+	for _, u := range us {
+		if u.Status != constants.AnchorStatusLeft {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,62 @@
+package control
+
+import (
+	"time"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+func (s *UserStatusControlTestSuite) TestEvictInactive() {
+	s.Run("moves inactive users to left and releases their handles", func() {
+		s.ctrl.eviction = &EvictionPolicy{IdleTimeout: time.Minute, ReleaseHandle: true}
+
+		s.mockRoomState.EXPECT().InactiveUsers(gomock.Any(), time.Minute).Return(map[string][]string{
+			"room1": {"user1"},
+		})
+		s.mockRoomState.EXPECT().UpdateUserStatus(gomock.Any(), "room1", "user1", gomock.Any()).
+			DoAndReturn(func(_ any, _, _ string, u *users.User) (bool, error) {
+				s.Equal(constants.AnchorStatusLeft, u.Status)
+				return true, nil
+			})
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{
+			"user1": {Role: "anchor", Status: constants.AnchorStatusLeft, TS: time.Now()},
+		}).Times(2)
+
+		s.ctrl.evictInactive(s.ctx)
+
+		var evicted users.NotifyUserEvicted
+		s.lastWSNotification("userEvicted", &evicted)
+		s.Equal(users.NotifyUserEvicted{RoomID: "room1", UserID: "user1"}, evicted)
+
+		var status users.NotifyRoomStatus
+		s.lastWSNotification("broadcastRoomStatus", &status)
+		s.Equal("room1", status.RoomID)
+	})
+
+	s.Run("skips users already gone", func() {
+		s.ctrl.eviction = &EvictionPolicy{IdleTimeout: time.Minute}
+
+		s.mockRoomState.EXPECT().InactiveUsers(gomock.Any(), time.Minute).Return(map[string][]string{
+			"room2": {"user2"},
+		})
+		s.mockRoomState.EXPECT().UpdateUserStatus(gomock.Any(), "room2", "user2", gomock.Any()).Return(false, nil)
+
+		s.ctrl.evictInactive(s.ctx)
+	})
+
+	s.Run("disabled", func() {
+		s.ctrl.eviction = &EvictionPolicy{}
+		s.ctrl.evictInactive(s.ctx)
+	})
+}
+
+func (s *UserStatusControlTestSuite) TestCountAnchors() {
+	s.Equal(2, countAnchors(map[string]users.User{
+		"user1": {Status: constants.AnchorStatusOnAir},
+		"user2": {},
+		"user3": {Status: constants.AnchorStatusLeft},
+	}))
+}
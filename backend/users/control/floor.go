@@ -0,0 +1,118 @@
+package control
+
+import (
+	"context"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+func (c *UserStatusControl) handleSetHand(
+	ctx context.Context,
+	req *users.SetHandUserRequest,
+	reply func(*streamrpc.Empty, error),
+) {
+	rpcRequestsReceived.Add(ctx, 1)
+
+	action := func(ctx context.Context) error {
+		ok, err := c.roomState.SetUserHand(ctx, req.RoomID, req.UserID, req.Raised, req.TS)
+		if err != nil {
+			rpcRequestsFailed.Add(ctx, 1)
+			reply(nil, err)
+			return err
+		}
+
+		if ok {
+			userHandUpdated.Add(ctx, 1)
+
+			if err := c.notifyUserStatus(ctx, req.RoomID); err != nil {
+				c.logger.Error("Failed to send WS room members", log.Error(err))
+			}
+		}
+
+		c.logger.Debug("User hand updated",
+			log.String("roomId", req.RoomID),
+			log.String("userId", req.UserID),
+			log.Bool("raised", req.Raised),
+			log.Bool("ok", ok),
+		)
+
+		rpcRequestsProcessed.Add(ctx, 1)
+		reply(nil, nil)
+		return nil
+	}
+
+	userEventsQueued.Add(ctx, 1)
+	userEventQueueDepth.Add(ctx, 1)
+	c.userEventCh <- &userEvent{
+		action: action,
+		ts:     req.TS,
+	}
+}
+
+func (c *UserStatusControl) handleGrantFloor(
+	ctx context.Context,
+	req *users.GrantFloorRequest,
+	reply func(*users.GrantFloorResponse, error),
+) {
+	rpcRequestsReceived.Add(ctx, 1)
+
+	action := func(ctx context.Context) error {
+		userID := req.UserID
+		if userID == "" {
+			queue := users.SpeakingQueue(c.roomState.GetRoomUsers(ctx, req.RoomID))
+			if len(queue) == 0 {
+				rpcRequestsFailed.Add(ctx, 1)
+				reply(nil, jsonrpc.ErrInvalidRequest("no raised hand"))
+				return nil
+			}
+			userID = queue[0]
+		}
+
+		ok, err := c.roomState.GrantFloor(ctx, req.RoomID, userID)
+		if err != nil {
+			rpcRequestsFailed.Add(ctx, 1)
+			reply(nil, err)
+			return err
+		}
+		if !ok {
+			rpcRequestsFailed.Add(ctx, 1)
+			reply(nil, jsonrpc.ErrInvalidRequest("user not found"))
+			return nil
+		}
+		floorGranted.Add(ctx, 1)
+
+		if err := c.peer2ws.Notify(ctx, req.RoomID, "floorGranted", &users.NotifyFloorGranted{
+			RoomID: req.RoomID,
+			UserID: userID,
+			Unmute: req.Unmute,
+		}); err != nil {
+			c.logger.Error("Failed to send WS floor granted", log.Error(err))
+			rpcNotificationsFailed.Add(ctx, 1)
+		} else {
+			rpcNotificationsSent.Add(ctx, 1)
+		}
+		if err := c.notifyUserStatus(ctx, req.RoomID); err != nil {
+			c.logger.Error("Failed to send WS room members", log.Error(err))
+		}
+
+		c.logger.Info("Floor granted",
+			log.String("roomId", req.RoomID),
+			log.String("userId", userID),
+			log.Bool("unmute", req.Unmute),
+		)
+
+		rpcRequestsProcessed.Add(ctx, 1)
+		reply(&users.GrantFloorResponse{UserID: userID}, nil)
+		return nil
+	}
+
+	userEventsQueued.Add(ctx, 1)
+	userEventQueueDepth.Add(ctx, 1)
+	c.userEventCh <- &userEvent{
+		action: action,
+		ts:     req.TS,
+	}
+}
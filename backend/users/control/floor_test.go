@@ -0,0 +1,251 @@
+package control
+
+import (
+	"time"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+func (s *UserStatusControlTestSuite) runEvent() {
+	select {
+	case event := <-s.ctrl.userEventCh:
+		s.Require().NoError(event.action(s.ctx))
+	case <-time.After(1 * time.Second):
+		s.T().Fatal("timeout waiting for event")
+	}
+}
+
+func (s *UserStatusControlTestSuite) TestHandleSetHand() {
+	req := &users.SetHandUserRequest{
+		RoomID: "room1",
+		UserID: "user1",
+		Raised: true,
+		TS:     time.Now(),
+	}
+
+	var replyErr error
+	replyCalled := false
+	reply := func(_ *streamrpc.Empty, err error) {
+		replyCalled = true
+		replyErr = err
+	}
+
+	s.mockRoomState.EXPECT().SetUserHand(gomock.Any(), "room1", "user1", true, req.TS).Return(true, nil)
+	s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{
+		"user1": {Role: "anchor", TS: time.Now(), HandRaisedAt: req.TS},
+	})
+
+	s.ctrl.handleSetHand(s.ctx, req, reply)
+	s.runEvent()
+
+	s.True(replyCalled)
+	s.Require().NoError(replyErr)
+
+	var status users.NotifyRoomStatus
+	s.lastWSNotification("broadcastRoomStatus", &status)
+	s.Require().Len(status.Members, 1)
+	s.Require().NotNil(status.Members[0].HandRaisedAt)
+	s.True(req.TS.Equal(*status.Members[0].HandRaisedAt))
+}
+
+func (s *UserStatusControlTestSuite) TestHandleGrantFloor() {
+	now := time.Now()
+	roomUsers := map[string]users.User{
+		"user1": {Role: "anchor", TS: now, HandRaisedAt: now.Add(-time.Second)},
+		"user2": {Role: "anchor", TS: now, HandRaisedAt: now.Add(-time.Minute)},
+	}
+
+	s.Run("grants head of the queue", func() {
+		var resp *users.GrantFloorResponse
+		reply := func(r *users.GrantFloorResponse, err error) {
+			s.Require().NoError(err)
+			resp = r
+		}
+
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(roomUsers).Times(2)
+		s.mockRoomState.EXPECT().GrantFloor(gomock.Any(), "room1", "user2").Return(true, nil)
+
+		s.ctrl.handleGrantFloor(s.ctx, &users.GrantFloorRequest{RoomID: "room1", Unmute: true, TS: now}, reply)
+		s.runEvent()
+
+		s.Require().NotNil(resp)
+		s.Equal("user2", resp.UserID)
+
+		var granted users.NotifyFloorGranted
+		s.lastWSNotification("floorGranted", &granted)
+		s.Equal(users.NotifyFloorGranted{RoomID: "room1", UserID: "user2", Unmute: true}, granted)
+	})
+
+	s.Run("empty queue", func() {
+		var replyErr error
+		reply := func(_ *users.GrantFloorResponse, err error) {
+			replyErr = err
+		}
+
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{})
+
+		s.ctrl.handleGrantFloor(s.ctx, &users.GrantFloorRequest{RoomID: "room1", TS: now}, reply)
+		s.runEvent()
+
+		var rpcErr *jsonrpc.Error
+		s.Require().ErrorAs(replyErr, &rpcErr)
+		s.Equal(int64(jsonrpc.CodeInvalidRequest), rpcErr.Code)
+	})
+
+	s.Run("unknown user", func() {
+		var replyErr error
+		reply := func(_ *users.GrantFloorResponse, err error) {
+			replyErr = err
+		}
+
+		s.mockRoomState.EXPECT().GrantFloor(gomock.Any(), "room1", "ghost").Return(false, nil)
+
+		s.ctrl.handleGrantFloor(s.ctx, &users.GrantFloorRequest{RoomID: "room1", UserID: "ghost", TS: now}, reply)
+		s.runEvent()
+
+		s.Require().Error(replyErr)
+		s.Contains(replyErr.Error(), "user not found")
+	})
+}
+
+func (s *UserStatusControlTestSuite) TestHandleSetPublish() {
+	now := time.Now()
+	roomUsers := map[string]users.User{
+		"user1": {Role: "anchor", TS: now, Publish: true},
+		"user2": {Role: "anchor", TS: now, HandRaisedAt: now.Add(-time.Minute)},
+		"user3": {Role: "anchor", TS: now, HandRaisedAt: now.Add(-time.Second)},
+		"idle":  {Role: "anchor", TS: now.Add(-time.Hour), Publish: true},
+	}
+	rejected := func(replyErr error, msg string) {
+		var rpcErr *jsonrpc.Error
+		s.Require().ErrorAs(replyErr, &rpcErr)
+		s.Equal(int64(jsonrpc.CodeInvalidRequest), rpcErr.Code)
+		s.Contains(rpcErr.Message, msg)
+	}
+
+	s.Run("grants head of the queue", func() {
+		var resp *users.SetPublishResponse
+		reply := func(r *users.SetPublishResponse, err error) {
+			s.Require().NoError(err)
+			resp = r
+		}
+
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(roomUsers).Times(2)
+		s.mockRoomState.EXPECT().SetPublish(gomock.Any(), "room1", "user2", true).Return(true, nil)
+
+		// the idle user does not hold a slot
+		s.ctrl.handleSetPublish(s.ctx, &users.SetPublishRequest{RoomID: "room1", Granted: true, Slots: 2, TS: now}, reply)
+		s.runEvent()
+
+		s.Require().NotNil(resp)
+		s.Equal("user2", resp.UserID)
+
+		var granted users.NotifyPublish
+		s.lastWSNotification("publishChanged", &granted)
+		s.Equal(users.NotifyPublish{RoomID: "room1", UserID: "user2", Granted: true}, granted)
+	})
+
+	s.Run("all slots taken", func() {
+		var replyErr error
+		reply := func(_ *users.SetPublishResponse, err error) {
+			replyErr = err
+		}
+
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(roomUsers)
+
+		s.ctrl.handleSetPublish(s.ctx, &users.SetPublishRequest{RoomID: "room1", UserID: "user3", Granted: true, Slots: 1, TS: now}, reply)
+		s.runEvent()
+
+		rejected(replyErr, "no publish slot left")
+	})
+
+	s.Run("granted again", func() {
+		var resp *users.SetPublishResponse
+		reply := func(r *users.SetPublishResponse, err error) {
+			s.Require().NoError(err)
+			resp = r
+		}
+
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(roomUsers)
+
+		s.ctrl.handleSetPublish(s.ctx, &users.SetPublishRequest{RoomID: "room1", UserID: "user1", Granted: true, Slots: 1, TS: now}, reply)
+		s.runEvent()
+
+		s.Require().NotNil(resp)
+		s.Equal("user1", resp.UserID)
+	})
+
+	s.Run("revokes", func() {
+		replyCalled := false
+		reply := func(_ *users.SetPublishResponse, err error) {
+			s.Require().NoError(err)
+			replyCalled = true
+		}
+
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(roomUsers).Times(2)
+		s.mockRoomState.EXPECT().SetPublish(gomock.Any(), "room1", "user1", false).Return(true, nil)
+
+		s.ctrl.handleSetPublish(s.ctx, &users.SetPublishRequest{RoomID: "room1", UserID: "user1", TS: now}, reply)
+		s.runEvent()
+
+		s.True(replyCalled)
+		var revoked users.NotifyPublish
+		s.lastWSNotification("publishChanged", &revoked)
+		s.Equal(users.NotifyPublish{RoomID: "room1", UserID: "user1"}, revoked)
+	})
+
+	s.Run("empty queue", func() {
+		var replyErr error
+		reply := func(_ *users.SetPublishResponse, err error) {
+			replyErr = err
+		}
+
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{})
+
+		s.ctrl.handleSetPublish(s.ctx, &users.SetPublishRequest{RoomID: "room1", Granted: true, Slots: 2, TS: now}, reply)
+		s.runEvent()
+
+		rejected(replyErr, "no publish request")
+	})
+
+	s.Run("unknown user", func() {
+		var replyErr error
+		reply := func(_ *users.SetPublishResponse, err error) {
+			replyErr = err
+		}
+
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(roomUsers)
+
+		s.ctrl.handleSetPublish(s.ctx, &users.SetPublishRequest{RoomID: "room1", UserID: "ghost", Granted: true, Slots: 2, TS: now}, reply)
+		s.runEvent()
+
+		rejected(replyErr, "user not found")
+	})
+}
+
+func (s *UserStatusControlTestSuite) TestHandleGetRoomUsers() {
+	now := time.Now()
+	s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{
+		"user2": {Role: "anchor", Status: constants.AnchorStatusOnAir, TS: now, Floor: true},
+		"user1": {Role: "host", Status: constants.AnchorStatusIdle, TS: now},
+		"gone":  {Role: "anchor", Status: constants.AnchorStatusOnAir, TS: now.Add(-time.Hour)},
+	})
+
+	var resp *users.GetRoomUsersResponse
+	s.ctrl.handleGetRoomUsers(s.ctx, &users.GetRoomUsersRequest{RoomID: "room1", TS: now}, func(r *users.GetRoomUsersResponse, err error) {
+		s.Require().NoError(err)
+		resp = r
+	})
+	s.runEvent()
+
+	s.Require().NotNil(resp)
+	s.Equal([]*users.RoomUser{
+		{UserID: "user1", Role: "host", Status: constants.AnchorStatusIdle},
+		{UserID: "user2", Role: "anchor", Status: constants.AnchorStatusOnAir, Floor: true},
+	}, resp.Users)
+}
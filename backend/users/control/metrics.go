@@ -8,14 +8,20 @@ import (
 
 var (
 	// User lifecycle metrics
-	usersCreated      metric.Int64Counter
-	usersDeleted      metric.Int64Counter
-	userStatusUpdated metric.Int64Counter
-	userCreateFailed  metric.Int64Counter
-	userDeleteFailed  metric.Int64Counter
-	userStatusFailed  metric.Int64Counter
-	activeUsers       metric.Int64UpDownCounter
-	maxAnchorsReached metric.Int64Counter
+	usersCreated       metric.Int64Counter
+	usersDeleted       metric.Int64Counter
+	userStatusUpdated  metric.Int64Counter
+	userCreateFailed   metric.Int64Counter
+	userDeleteFailed   metric.Int64Counter
+	userStatusFailed   metric.Int64Counter
+	userQualityUpdated metric.Int64Counter
+	userHandUpdated    metric.Int64Counter
+	floorGranted       metric.Int64Counter
+	publishChanged     metric.Int64Counter
+	publishSlotsFull   metric.Int64Counter
+	roomBroadcasts     metric.Int64Counter
+	activeUsers        metric.Int64UpDownCounter
+	maxAnchorsReached  metric.Int64Counter
 
 	// RPC metrics
 	rpcRequestsReceived    metric.Int64Counter
@@ -30,10 +36,23 @@ var (
 	userEventsFailed    metric.Int64Counter
 	userEventQueueDepth metric.Int64UpDownCounter
 
+	// Join queue metrics
+	usersQueued         metric.Int64Counter
+	queuedUsersAdmitted metric.Int64Counter
+	queuedUsersLeft     metric.Int64Counter
+	queuedUsersExpired  metric.Int64Counter
+	joinQueueFull       metric.Int64Counter
+
+	// Status batch metrics
+	statusBatchesFlushed metric.Int64Counter
+	statusBatchSize      metric.Int64Histogram
+
 	// Timeout/expiration metrics
 	timeoutChecksRun      metric.Int64Counter
 	expiredUsersDetected  metric.Int64Counter
 	roomsWithExpiredUsers metric.Int64Counter
+	inactiveUsersEvicted  metric.Int64Counter
+	endedRoomUsersRemoved metric.Int64Counter
 
 	// State management metrics
 	stateRebuildRuns     metric.Int64Counter
@@ -44,6 +63,12 @@ var (
 	watcherStarted metric.Int64Counter
 	watcherStopped metric.Int64Counter
 	watcherErrors  metric.Int64Counter
+
+	// Stream trim metrics
+	streamEntriesTrimmed metric.Int64Counter
+	streamTrimHeldBack   metric.Int64Counter
+	streamTrimFailed     metric.Int64Counter
+	streamConsumerLag    metric.Int64Gauge
 )
 
 func init() {
@@ -59,6 +84,21 @@ func init() {
 	f.Int64Counter(&userStatusUpdated, "users.status.updated",
 		metric.WithDescription("Total user status updates"))
 
+	f.Int64Counter(&userQualityUpdated, "users.quality.updated",
+		metric.WithDescription("Total user network quality updates"))
+
+	f.Int64Counter(&userHandUpdated, "users.hand.updated",
+		metric.WithDescription("Total hands raised and lowered"))
+
+	f.Int64Counter(&floorGranted, "users.floor.granted",
+		metric.WithDescription("Total floor grants by moderators"))
+	f.Int64Counter(&publishChanged, "users.publish.changed",
+		metric.WithDescription("Total publish slots granted and revoked in push-to-talk rooms"))
+	f.Int64Counter(&publishSlotsFull, "users.publish.slots_full",
+		metric.WithDescription("Publish grants rejected with all slots of the room taken"))
+	f.Int64Counter(&roomBroadcasts, "users.room.broadcasts",
+		metric.WithDescription("Total notifications broadcast to rooms by backends"))
+
 	f.Int64Counter(&userCreateFailed, "users.create.failed",
 		metric.WithDescription("Failed user creation attempts"))
 
@@ -103,6 +143,29 @@ func init() {
 	f.Int64UpDownCounter(&userEventQueueDepth, "events.queue_depth",
 		metric.WithDescription("Current depth of user event queue"))
 
+	// Join queue
+	f.Int64Counter(&usersQueued, "queue.users.queued",
+		metric.WithDescription("Total users queued for an anchor slot of a full room"))
+
+	f.Int64Counter(&queuedUsersAdmitted, "queue.users.admitted",
+		metric.WithDescription("Total queued users admitted once a slot freed"))
+
+	f.Int64Counter(&queuedUsersLeft, "queue.users.left",
+		metric.WithDescription("Total queued users leaving before being admitted"))
+
+	f.Int64Counter(&queuedUsersExpired, "queue.users.expired",
+		metric.WithDescription("Total queued users dropped for not asking their position in time"))
+
+	f.Int64Counter(&joinQueueFull, "queue.full",
+		metric.WithDescription("Users rejected with the join queue of the room full"))
+
+	// Status batch
+	f.Int64Counter(&statusBatchesFlushed, "status.batch.flushed",
+		metric.WithDescription("Total batches of status updates applied"))
+
+	f.Int64Histogram(&statusBatchSize, "status.batch.size",
+		metric.WithDescription("Status updates applied per batch"))
+
 	// Timeouts
 	f.Int64Counter(&timeoutChecksRun, "timeout.checks.run",
 		metric.WithDescription("Total timeout check cycles executed"))
@@ -110,6 +173,12 @@ func init() {
 	f.Int64Counter(&expiredUsersDetected, "timeout.users.expired",
 		metric.WithDescription("Total expired users detected"))
 
+	f.Int64Counter(&inactiveUsersEvicted, "timeout.users.evicted",
+		metric.WithDescription("Total users moved to left after staying idle or disconnected too long"))
+
+	f.Int64Counter(&endedRoomUsersRemoved, "ending.users.removed",
+		metric.WithDescription("Total users removed from rooms being ended"))
+
 	f.Int64Counter(&roomsWithExpiredUsers, "timeout.rooms.affected",
 		metric.WithDescription("Total rooms with expired users"))
 
@@ -133,4 +202,17 @@ func init() {
 
 	f.Int64Counter(&watcherErrors, "watcher.errors",
 		metric.WithDescription("Total watcher errors"))
+
+	// Stream trim
+	f.Int64Counter(&streamEntriesTrimmed, "stream.trim.entries",
+		metric.WithDescription("Total stream entries trimmed"))
+
+	f.Int64Counter(&streamTrimHeldBack, "stream.trim.held_back",
+		metric.WithDescription("Trims held back by consumer groups lagging behind the policy"))
+
+	f.Int64Counter(&streamTrimFailed, "stream.trim.failed",
+		metric.WithDescription("Failed stream trims"))
+
+	f.Int64Gauge(&streamConsumerLag, "stream.consumer.lag",
+		metric.WithDescription("Stream entries not yet delivered to the consumer group"))
 }
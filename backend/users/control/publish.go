@@ -0,0 +1,93 @@
+package control
+
+import (
+	"context"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+// publishMethod tells the gateways of a room a publish slot was granted or revoked
+const publishMethod = "publishChanged"
+
+// handleSetPublish grants or revokes a publish slot of a push-to-talk room. Slots are taken by
+// grants and given back by revokes, a grant finding all slots taken is rejected
+func (c *UserStatusControl) handleSetPublish(
+	ctx context.Context,
+	req *users.SetPublishRequest,
+	reply func(*users.SetPublishResponse, error),
+) {
+	rpcRequestsReceived.Add(ctx, 1)
+
+	action := func(ctx context.Context) error {
+		us := c.roomState.GetRoomUsers(ctx, req.RoomID)
+		userID := req.UserID
+		if req.Granted && userID == "" {
+			queue := users.SpeakingQueue(us)
+			if len(queue) == 0 {
+				rpcRequestsFailed.Add(ctx, 1)
+				reply(nil, jsonrpc.ErrInvalidRequest("no publish request"))
+				return nil
+			}
+			userID = queue[0]
+		}
+
+		u, ok := us[userID]
+		if !ok || u.Role == "" {
+			rpcRequestsFailed.Add(ctx, 1)
+			reply(nil, jsonrpc.ErrInvalidRequest("user not found"))
+			return nil
+		}
+		// granting or revoking again changes nothing
+		if u.Publish == req.Granted {
+			rpcRequestsProcessed.Add(ctx, 1)
+			reply(&users.SetPublishResponse{UserID: userID}, nil)
+			return nil
+		}
+		if req.Granted && users.Publishers(us) >= req.Slots {
+			publishSlotsFull.Add(ctx, 1)
+			rpcRequestsFailed.Add(ctx, 1)
+			reply(nil, jsonrpc.ErrInvalidRequest("no publish slot left"))
+			return nil
+		}
+
+		if _, err := c.roomState.SetPublish(ctx, req.RoomID, userID, req.Granted); err != nil {
+			rpcRequestsFailed.Add(ctx, 1)
+			reply(nil, err)
+			return err
+		}
+		publishChanged.Add(ctx, 1)
+
+		if err := c.peer2ws.Notify(ctx, req.RoomID, publishMethod, &users.NotifyPublish{
+			RoomID:  req.RoomID,
+			UserID:  userID,
+			Granted: req.Granted,
+		}); err != nil {
+			c.logger.Error("Failed to send WS publish changed", log.Error(err))
+			rpcNotificationsFailed.Add(ctx, 1)
+		} else {
+			rpcNotificationsSent.Add(ctx, 1)
+		}
+		if err := c.notifyUserStatus(ctx, req.RoomID); err != nil {
+			c.logger.Error("Failed to send WS room members", log.Error(err))
+		}
+
+		c.logger.Info("Publish slot changed",
+			log.String("roomId", req.RoomID),
+			log.String("userId", userID),
+			log.Bool("granted", req.Granted),
+		)
+
+		rpcRequestsProcessed.Add(ctx, 1)
+		reply(&users.SetPublishResponse{UserID: userID}, nil)
+		return nil
+	}
+
+	userEventsQueued.Add(ctx, 1)
+	userEventQueueDepth.Add(ctx, 1)
+	c.userEventCh <- &userEvent{
+		action: action,
+		ts:     req.TS,
+	}
+}
@@ -0,0 +1,146 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+const (
+	// change of the room score worth an etcd write, anchors report every few seconds
+	qualityScoreStep = 5
+)
+
+func (c *UserStatusControl) handleSetQuality(
+	ctx context.Context,
+	req *users.SetQualityUserRequest,
+	reply func(*streamrpc.Empty, error),
+) {
+	rpcRequestsReceived.Add(ctx, 1)
+
+	action := func(ctx context.Context) error {
+		ok, err := c.roomState.UpdateUserQuality(ctx, req.RoomID, req.UserID, req.Quality)
+		if err != nil {
+			rpcRequestsFailed.Add(ctx, 1)
+			reply(nil, err)
+			return err
+		}
+
+		if ok {
+			userQualityUpdated.Add(ctx, 1)
+
+			if err := c.syncRoomQuality(ctx, req.RoomID); err != nil {
+				c.logger.Error("Failed to sync room quality", log.Error(err))
+			}
+		}
+
+		c.logger.Debug("User quality updated",
+			log.String("roomId", req.RoomID),
+			log.String("userId", req.UserID),
+			log.Int("quality", req.Quality),
+			log.Bool("ok", ok),
+		)
+
+		rpcRequestsProcessed.Add(ctx, 1)
+		reply(nil, nil)
+		return nil
+	}
+
+	userEventsQueued.Add(ctx, 1)
+	userEventQueueDepth.Add(ctx, 1)
+	c.userEventCh <- &userEvent{
+		action: action,
+		ts:     req.TS,
+	}
+}
+
+// syncRoomQuality writes the aggregated quality of the room anchors to etcd, when the score
+// moved by qualityScoreStep or the degraded anchors changed
+func (c *UserStatusControl) syncRoomQuality(ctx context.Context, roomID string) error {
+	quality := aggregateQuality(c.roomState.GetRoomUsers(ctx, roomID))
+	last := c.qualities[roomID]
+	if !qualityChanged(last, quality) {
+		return nil
+	}
+
+	key := fmt.Sprintf("%s%s/%s", c.prefixRoom, roomID, constants.RoomKeyQuality)
+	if quality == nil {
+		if _, err := c.etcdKV.Delete(ctx, key); err != nil {
+			return fmt.Errorf("failed to delete room quality: %w", err)
+		}
+		delete(c.qualities, roomID)
+		return nil
+	}
+
+	// do not recreate keys of a deleted room
+	if _, ok := c.roomWatcher.GetCachedState(roomID); !ok {
+		delete(c.qualities, roomID)
+		return nil
+	}
+
+	data, err := json.Marshal(quality)
+	if err != nil {
+		return fmt.Errorf("failed to marshal room quality: %w", err)
+	}
+	if _, err := c.etcdKV.Put(ctx, key, string(data)); err != nil {
+		return fmt.Errorf("failed to put room quality: %w", err)
+	}
+	c.qualities[roomID] = quality
+
+	if len(quality.Degraded) > 0 {
+		c.logger.Warn("Degraded anchors in room",
+			log.String("roomId", roomID),
+			log.Int("score", quality.Score),
+			log.Strings("degraded", quality.Degraded))
+	}
+	return nil
+}
+
+// aggregateQuality averages the scores of the active anchors, nil when none reported
+func aggregateQuality(us map[string]users.User) *etcdstate.Quality {
+	var sum, count int
+	var degraded []string
+	for userID, u := range us {
+		if !u.IsActive() || u.Quality == 0 {
+			continue
+		}
+		sum += u.Quality
+		count++
+		if u.Quality < users.DegradedQuality {
+			degraded = append(degraded, userID)
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	slices.Sort(degraded)
+
+	return &etcdstate.Quality{
+		Score:     sum / count,
+		Degraded:  degraded,
+		UpdatedAt: time.Now().UTC(),
+	}
+}
+
+func qualityChanged(last, quality *etcdstate.Quality) bool {
+	if last == nil || quality == nil {
+		return last != quality
+	}
+	return abs(last.Score-quality.Score) >= qualityScoreStep ||
+		!slices.Equal(last.Degraded, quality.Degraded)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
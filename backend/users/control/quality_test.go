@@ -0,0 +1,87 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+func (s *UserStatusControlTestSuite) TestHandleSetQuality() {
+	req := &users.SetQualityUserRequest{
+		RoomID:  "room1",
+		UserID:  "user1",
+		Quality: 40,
+		TS:      time.Now(),
+	}
+
+	replyCalled := false
+	reply := func(_ *streamrpc.Empty, err error) {
+		replyCalled = true
+		s.Require().NoError(err)
+	}
+
+	s.mockRoomState.EXPECT().UpdateUserQuality(gomock.Any(), "room1", "user1", 40).Return(true, nil)
+	s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{
+		"user1": {Status: "onair", TS: time.Now(), Quality: 40},
+		"user2": {Status: "onair", TS: time.Now(), Quality: 90},
+	})
+	s.mockRoomWatcher.EXPECT().GetCachedState("room1").Return(&etcdstate.RoomState{}, true)
+	s.mockKV.EXPECT().Put(gomock.Any(), "/rooms/room1/quality", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, val string, _ ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+			var quality etcdstate.Quality
+			s.Require().NoError(json.Unmarshal([]byte(val), &quality))
+			s.Equal(65, quality.Score)
+			s.Equal([]string{"user1"}, quality.Degraded)
+			return &clientv3.PutResponse{}, nil
+		})
+
+	s.ctrl.handleSetQuality(s.ctx, req, reply)
+
+	select {
+	case event := <-s.ctrl.userEventCh:
+		s.Require().NoError(event.action(s.ctx))
+	case <-time.After(1 * time.Second):
+		s.T().Fatal("timeout waiting for event")
+	}
+	s.True(replyCalled)
+}
+
+func (s *UserStatusControlTestSuite) TestSyncRoomQuality() {
+	s.Run("skips small score changes", func() {
+		s.ctrl.qualities["room1"] = &etcdstate.Quality{Score: 80}
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{
+			"user1": {Status: "onair", TS: time.Now(), Quality: 82},
+		})
+
+		s.Require().NoError(s.ctrl.syncRoomQuality(s.ctx, "room1"))
+	})
+
+	s.Run("deletes quality when no anchor reports", func() {
+		s.ctrl.qualities["room1"] = &etcdstate.Quality{Score: 80}
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{
+			// inactive anchor
+			"user1": {Status: "onair", Quality: 82},
+		})
+		s.mockKV.EXPECT().Delete(gomock.Any(), "/rooms/room1/quality").Return(&clientv3.DeleteResponse{}, nil)
+
+		s.Require().NoError(s.ctrl.syncRoomQuality(s.ctx, "room1"))
+		s.NotContains(s.ctrl.qualities, "room1")
+	})
+
+	s.Run("does not recreate deleted room", func() {
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room2").Return(map[string]users.User{
+			"user1": {Status: "onair", TS: time.Now(), Quality: 82},
+		})
+		s.mockRoomWatcher.EXPECT().GetCachedState("room2").Return(nil, false)
+
+		s.Require().NoError(s.ctrl.syncRoomQuality(s.ctx, "room2"))
+		s.NotContains(s.ctrl.qualities, "room2")
+	})
+}
@@ -0,0 +1,244 @@
+package control
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+const defaultJoinQueueTimeout = 2 * time.Minute
+
+// JoinQueue tunes the join queues of full rooms, the length of each is set by the joinQueue of
+// the room meta. Queues are held by the controller only, a restart drops them
+type JoinQueue struct {
+	// Timeout drops queued users not asking for their position for this long, e.g. once their
+	// connection dropped
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+func SetupJoinQueue(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("timeout"), defaultJoinQueueTimeout)
+}
+
+// queuedUser waits in the join queue of a room for a free anchor slot
+type queuedUser struct {
+	userID string
+	role   string
+	seenAt time.Time // last time the user asked for its position
+}
+
+// enqueue adds the user to the back of the join queue of the room, returns its position or 0
+// when the queue already holds maxLength users
+func (c *UserStatusControl) enqueue(ctx context.Context, req *users.CreateUserRequest, maxLength int) int {
+	if maxLength <= 0 {
+		return 0
+	}
+	queue := c.queues[req.RoomID]
+	if len(queue) >= maxLength {
+		joinQueueFull.Add(ctx, 1)
+		return 0
+	}
+	if c.queues == nil {
+		c.queues = make(map[string][]*queuedUser)
+	}
+	c.queues[req.RoomID] = append(queue, &queuedUser{
+		userID: req.UserID,
+		role:   req.Role,
+		seenAt: time.Now(),
+	})
+	usersQueued.Add(ctx, 1)
+
+	c.logger.Info("User queued",
+		log.String("roomId", req.RoomID),
+		log.String("userId", req.UserID),
+		log.Int("position", len(queue)+1))
+	c.notifyQueue(ctx, req.RoomID, nil)
+	return len(queue) + 1
+}
+
+// queuePosition returns the position of the user in the join queue of the room from 1, 0 when
+// not queued
+func (c *UserStatusControl) queuePosition(roomID, userID string) int {
+	i := slices.IndexFunc(c.queues[roomID], func(q *queuedUser) bool { return q.userID == userID })
+	return i + 1
+}
+
+// leaveQueue removes the user from the join queue of the room, the users behind move up
+func (c *UserStatusControl) leaveQueue(ctx context.Context, roomID, userID string) bool {
+	position := c.queuePosition(roomID, userID)
+	if position == 0 {
+		return false
+	}
+	c.setQueue(roomID, slices.Delete(c.queues[roomID], position-1, position))
+	queuedUsersLeft.Add(ctx, 1)
+
+	c.logger.Info("User left join queue",
+		log.String("roomId", roomID),
+		log.String("userId", userID))
+	c.notifyQueue(ctx, roomID, nil)
+	return true
+}
+
+// admitQueued creates the users at the head of the join queue of the room while it has free
+// anchor slots. The queue of a room gone or ending is dropped
+func (c *UserStatusControl) admitQueued(ctx context.Context, roomID string) {
+	queue := c.queues[roomID]
+	if len(queue) == 0 {
+		return
+	}
+	room, ok := c.roomWatcher.GetCachedState(roomID)
+	if !ok || room.GetMeta().GetEndStage() != "" {
+		delete(c.queues, roomID)
+		return
+	}
+
+	free := room.GetMeta().GetMaxAnchors() - countAnchors(c.roomState.GetRoomUsers(ctx, roomID))
+	var admitted []string
+	for free > 0 && len(queue) > 0 {
+		q := queue[0]
+		ok, err := c.roomState.CreateUser(ctx, roomID, q.userID, &users.User{
+			Role: q.role,
+			TS:   time.Now(),
+		})
+		if err != nil {
+			// kept at the head, retried on the next slot freed or check
+			c.logger.Error("Failed to admit queued user",
+				log.String("roomId", roomID),
+				log.String("userId", q.userID),
+				log.Error(err))
+			userCreateFailed.Add(ctx, 1)
+			break
+		}
+		queue = queue[1:]
+		if !ok {
+			continue
+		}
+
+		free--
+		admitted = append(admitted, q.userID)
+		usersCreated.Add(ctx, 1)
+		activeUsers.Add(ctx, 1)
+		queuedUsersAdmitted.Add(ctx, 1)
+		if err := c.recordAnchor(ctx, roomID, q.userID); err != nil {
+			c.logger.Error("Failed to record room anchor", log.Error(err))
+		}
+		c.logger.Info("Queued user admitted",
+			log.String("roomId", roomID),
+			log.String("userId", q.userID))
+	}
+	c.setQueue(roomID, queue)
+
+	if len(admitted) > 0 {
+		c.notifyQueue(ctx, roomID, admitted)
+	}
+}
+
+// serveQueues drops the queued users that stopped asking for their position, then admits users
+// into the slots freed by timeouts, evictions or raised max anchors
+func (c *UserStatusControl) serveQueues(ctx context.Context) {
+	if len(c.queues) == 0 {
+		return
+	}
+
+	timeout := defaultJoinQueueTimeout
+	if c.joinQueue != nil && c.joinQueue.Timeout > 0 {
+		timeout = c.joinQueue.Timeout
+	}
+	deadline := time.Now().Add(-timeout)
+
+	for _, roomID := range slices.Sorted(maps.Keys(c.queues)) {
+		queue := c.queues[roomID]
+		kept := slices.DeleteFunc(slices.Clone(queue), func(q *queuedUser) bool {
+			return q.seenAt.Before(deadline)
+		})
+		if expired := len(queue) - len(kept); expired > 0 {
+			queuedUsersExpired.Add(ctx, int64(expired))
+			c.setQueue(roomID, kept)
+			c.notifyQueue(ctx, roomID, nil)
+		}
+		c.admitQueued(ctx, roomID)
+	}
+}
+
+// queueOnStatus keeps the join queue of the room in step with a status update: a user leaving
+// frees its slot for the head of the queue, a queued user leaving gives up its place
+func (c *UserStatusControl) queueOnStatus(
+	ctx context.Context,
+	roomID, userID string,
+	status constants.AnchorStatus,
+	applied bool,
+) {
+	if status != constants.AnchorStatusLeft || len(c.queues[roomID]) == 0 {
+		return
+	}
+	if applied {
+		c.admitQueued(ctx, roomID)
+		return
+	}
+	c.leaveQueue(ctx, roomID, userID)
+}
+
+func (c *UserStatusControl) setQueue(roomID string, queue []*queuedUser) {
+	if len(queue) == 0 {
+		delete(c.queues, roomID)
+		return
+	}
+	c.queues[roomID] = queue
+}
+
+// notifyQueue tells the gateways of the room the order of its join queue and who got in
+func (c *UserStatusControl) notifyQueue(ctx context.Context, roomID string, admitted []string) {
+	queue := c.queues[roomID]
+	userIDs := make([]string, len(queue))
+	for i, q := range queue {
+		userIDs[i] = q.userID
+	}
+
+	if err := c.peer2ws.Notify(ctx, roomID, "joinQueueChanged", &users.NotifyJoinQueue{
+		RoomID:   roomID,
+		Queue:    userIDs,
+		Admitted: admitted,
+	}); err != nil {
+		c.logger.Error("Failed to notify join queue", log.Error(err))
+		rpcNotificationsFailed.Add(ctx, 1)
+		return
+	}
+	rpcNotificationsSent.Add(ctx, 1)
+}
+
+// handleQueuePosition replies the position of the user in the join queue, asking keeps the user
+// queued
+func (c *UserStatusControl) handleQueuePosition(
+	ctx context.Context,
+	req *users.QueuePositionRequest,
+	reply func(*users.QueuePositionResponse, error),
+) {
+	rpcRequestsReceived.Add(ctx, 1)
+
+	action := func(ctx context.Context) error {
+		position := c.queuePosition(req.RoomID, req.UserID)
+		if position > 0 {
+			c.queues[req.RoomID][position-1].seenAt = time.Now()
+		}
+
+		rpcRequestsProcessed.Add(ctx, 1)
+		reply(&users.QueuePositionResponse{Position: position}, nil)
+		return nil
+	}
+
+	userEventsQueued.Add(ctx, 1)
+	userEventQueueDepth.Add(ctx, 1)
+	c.userEventCh <- &userEvent{
+		action: action,
+		ts:     req.TS,
+	}
+}
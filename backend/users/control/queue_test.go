@@ -0,0 +1,135 @@
+package control
+
+import (
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+// expectFullRoom has room1 hold one anchor of one, with a join queue of two
+func (s *UserStatusControlTestSuite) expectFullRoom() map[string]users.User {
+	members := map[string]users.User{
+		"anchor1": {Role: "anchor", Status: constants.AnchorStatusOnAir},
+	}
+	s.mockRoomWatcher.EXPECT().GetCachedState("room1").Return(&etcdstate.RoomState{
+		Meta: &etcdstate.Meta{MaxAnchors: 1, JoinQueue: 2},
+	}, true).AnyTimes()
+	s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").DoAndReturn(
+		func(_ any, _ string) map[string]users.User { return members },
+	).AnyTimes()
+	return members
+}
+
+func (s *UserStatusControlTestSuite) create(userID string) error {
+	var replyErr error
+	s.ctrl.handleCreate(s.ctx, &users.CreateUserRequest{
+		RoomID: "room1",
+		UserID: userID,
+		Role:   "anchor",
+		TS:     time.Now(),
+	}, func(_ *streamrpc.Empty, err error) {
+		replyErr = err
+	})
+	s.runEvent()
+	return replyErr
+}
+
+func (s *UserStatusControlTestSuite) position(userID string) int {
+	var position int
+	s.ctrl.handleQueuePosition(s.ctx, &users.QueuePositionRequest{
+		RoomID: "room1",
+		UserID: userID,
+	}, func(resp *users.QueuePositionResponse, err error) {
+		s.Require().NoError(err)
+		position = resp.Position
+	})
+	s.runEvent()
+	return position
+}
+
+func (s *UserStatusControlTestSuite) TestJoinQueueFull() {
+	s.expectFullRoom()
+
+	s.Require().NoError(s.create("user1"))
+	s.Require().NoError(s.create("user2"))
+	err := s.create("user3")
+	var rpcErr *jsonrpc.Error
+	s.Require().ErrorAs(err, &rpcErr)
+
+	s.Equal(1, s.position("user1"))
+	s.Equal(2, s.position("user2"))
+	s.Equal(0, s.position("user3"))
+
+	var notify users.NotifyJoinQueue
+	s.lastWSNotification("joinQueueChanged", &notify)
+	s.Equal([]string{"user1", "user2"}, notify.Queue)
+	s.Empty(notify.Admitted)
+}
+
+func (s *UserStatusControlTestSuite) TestJoinQueueAdmit() {
+	members := s.expectFullRoom()
+	s.Require().NoError(s.create("user1"))
+	s.Require().NoError(s.create("user2"))
+
+	s.mockRoomState.EXPECT().RemoveUser(gomock.Any(), "room1", "anchor1").DoAndReturn(
+		func(_ any, _, _ string) (bool, error) {
+			delete(members, "anchor1")
+			return true, nil
+		})
+	s.mockKV.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(&clientv3.GetResponse{}, nil).AnyTimes()
+	s.mockKV.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(&clientv3.PutResponse{}, nil).AnyTimes()
+	s.mockKV.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(&clientv3.DeleteResponse{}, nil).AnyTimes()
+	s.mockRoomState.EXPECT().CreateUser(gomock.Any(), "room1", "user1", gomock.Any()).Return(true, nil)
+
+	s.ctrl.handleDelete(s.ctx, &users.DeleteUserRequest{RoomID: "room1", UserID: "anchor1"},
+		func(_ *streamrpc.Empty, err error) { s.Require().NoError(err) })
+	s.runEvent()
+
+	var notify users.NotifyJoinQueue
+	s.lastWSNotification("joinQueueChanged", &notify)
+	s.Equal([]string{"user2"}, notify.Queue)
+	s.Equal([]string{"user1"}, notify.Admitted)
+	s.Equal(0, s.position("user1"))
+	s.Equal(1, s.position("user2"))
+}
+
+func (s *UserStatusControlTestSuite) TestJoinQueueLeave() {
+	s.expectFullRoom()
+	s.Require().NoError(s.create("user1"))
+	s.Require().NoError(s.create("user2"))
+
+	s.mockRoomState.EXPECT().UpdateUserStatus(gomock.Any(), "room1", "user1", gomock.Any()).Return(false, nil)
+	s.ctrl.handleSetStatus(s.ctx, &users.SetStatusUserRequest{
+		RoomID: "room1",
+		UserID: "user1",
+		Status: constants.AnchorStatusLeft,
+	}, func(_ *streamrpc.Empty, err error) { s.Require().NoError(err) })
+	s.runEvent()
+	s.Equal(0, s.position("user1"))
+	s.Equal(1, s.position("user2"))
+
+	s.ctrl.queues["room1"][0].seenAt = time.Now().Add(-time.Hour)
+	s.ctrl.serveQueues(s.ctx)
+	s.Empty(s.ctrl.queues)
+
+	var notify users.NotifyJoinQueue
+	s.lastWSNotification("joinQueueChanged", &notify)
+	s.Empty(notify.Queue)
+}
+
+func (s *UserStatusControlTestSuite) TestJoinQueueEndingRoom() {
+	s.ctrl.queues = map[string][]*queuedUser{"room2": {{userID: "user1", seenAt: time.Now()}}}
+	s.mockRoomWatcher.EXPECT().GetCachedState("room2").Return(&etcdstate.RoomState{
+		Meta: &etcdstate.Meta{Ending: &etcdstate.Ending{Stage: constants.EndStageNotifying}},
+	}, true)
+
+	s.ctrl.admitQueued(s.ctx, "room2")
+	s.Empty(s.ctrl.queues)
+}
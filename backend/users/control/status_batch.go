@@ -0,0 +1,126 @@
+package control
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+// StatusBatch accumulates status updates of anchors, so a burst of them is written to Redis in
+// one round trip and each room notified once
+type StatusBatch struct {
+	// MaxItems flushes the batch once it holds this many updates, 1 or less disables batching
+	MaxItems int `mapstructure:"max_items"`
+	// MaxWait flushes the batch this long after its first update
+	MaxWait time.Duration `mapstructure:"max_wait"`
+}
+
+func SetupStatusBatch(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("max_items"), 64)
+	v.SetDefault(p("max_wait"), 10*time.Millisecond)
+}
+
+func (b *StatusBatch) enabled() bool {
+	return b != nil && b.MaxItems > 1
+}
+
+// pendingStatus is a status update waiting in the batch, with the reply of its request
+type pendingStatus struct {
+	req   *users.SetStatusUserRequest
+	reply func(*streamrpc.Empty, error)
+}
+
+// batchStatus adds a status update to the batch, flushing it once full. It returns the channel
+// firing when the batch is due, nil while the batch is empty
+func (c *UserStatusControl) batchStatus(ctx context.Context, status *pendingStatus) <-chan time.Time {
+	c.pendingStatuses = append(c.pendingStatuses, status)
+	if len(c.pendingStatuses) >= c.statusBatch.MaxItems {
+		c.flushStatuses(ctx)
+		return nil
+	}
+	if len(c.pendingStatuses) == 1 {
+		c.batchTimer.Reset(c.statusBatch.MaxWait)
+	}
+	return c.batchTimer.C
+}
+
+// flushStatuses applies the batched status updates in arrival order, then notifies each room
+// changed once
+func (c *UserStatusControl) flushStatuses(ctx context.Context) {
+	pending := c.pendingStatuses
+	if len(pending) == 0 {
+		return
+	}
+	c.pendingStatuses = nil
+	if c.batchTimer != nil {
+		c.batchTimer.Stop()
+	}
+	statusBatchesFlushed.Add(ctx, 1)
+	statusBatchSize.Record(ctx, int64(len(pending)))
+
+	updates := make([]users.StatusUpdate, len(pending))
+	for i, p := range pending {
+		updates[i] = users.StatusUpdate{
+			RoomID: p.req.RoomID,
+			UserID: p.req.UserID,
+			User: &users.User{
+				Status: p.req.Status,
+				TS:     p.req.TS,
+				Gen:    p.req.Gen,
+			},
+		}
+	}
+
+	applied, err := c.roomState.UpdateUserStatuses(ctx, updates)
+	if err != nil {
+		c.logger.Error("Failed to apply user status batch",
+			log.Int("size", len(pending)),
+			log.Error(err))
+		userEventsFailed.Add(ctx, int64(len(pending)))
+		userStatusFailed.Add(ctx, int64(len(pending)))
+		rpcRequestsFailed.Add(ctx, int64(len(pending)))
+		for _, p := range pending {
+			p.reply(nil, err)
+		}
+		return
+	}
+
+	var roomIDs []string
+	for i, p := range pending {
+		if applied[i] {
+			userStatusUpdated.Add(ctx, 1)
+			if !slices.Contains(roomIDs, p.req.RoomID) {
+				roomIDs = append(roomIDs, p.req.RoomID)
+			}
+		}
+
+		c.logger.Debug("User status updated",
+			log.String("roomId", p.req.RoomID),
+			log.String("userId", p.req.UserID),
+			log.Any("status", p.req.Status),
+			log.Bool("ok", applied[i]),
+		)
+
+		userEventsProcessed.Add(ctx, 1)
+		rpcRequestsProcessed.Add(ctx, 1)
+		p.reply(nil, nil)
+	}
+
+	slices.Sort(roomIDs)
+	for _, roomID := range roomIDs {
+		if err := c.notifyUserStatus(ctx, roomID); err != nil {
+			c.logger.Error("Failed to send WS room members", log.Error(err))
+		}
+	}
+	for i, p := range pending {
+		c.queueOnStatus(ctx, p.req.RoomID, p.req.UserID, p.req.Status, applied[i])
+	}
+}
@@ -0,0 +1,125 @@
+package control
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+func (s *UserStatusControlTestSuite) sendStatus(roomID, userID string, gen int32, replies chan<- error) {
+	s.ctrl.handleSetStatus(s.ctx, &users.SetStatusUserRequest{
+		RoomID: roomID,
+		UserID: userID,
+		Status: constants.AnchorStatusOnAir,
+		Gen:    gen,
+		TS:     time.Now(),
+	}, func(_ *streamrpc.Empty, err error) {
+		replies <- err
+	})
+}
+
+func (s *UserStatusControlTestSuite) waitReplies(replies <-chan error, n int) {
+	for range n {
+		select {
+		case err := <-replies:
+			s.Require().NoError(err)
+		case <-time.After(1 * time.Second):
+			s.T().Fatal("timeout waiting for reply")
+		}
+	}
+}
+
+// startLoop runs the event loop, the returned func stops it
+func (s *UserStatusControlTestSuite) startLoop() func() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	done := make(chan struct{})
+	go func() {
+		s.ctrl.loop(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (s *UserStatusControlTestSuite) TestStatusBatch() {
+	s.Run("flushes a full batch with one notify per room", func() {
+		s.ctrl.statusBatch = &StatusBatch{MaxItems: 3, MaxWait: time.Minute}
+		defer s.startLoop()()
+
+		s.mockRoomState.EXPECT().UpdateUserStatuses(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, updates []users.StatusUpdate) ([]bool, error) {
+				s.Require().Len(updates, 3)
+				s.Equal("user1", updates[0].UserID)
+				s.Equal(int32(2), updates[2].User.Gen)
+				return []bool{true, true, true}, nil
+			})
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room1").Return(map[string]users.User{}).Times(1)
+		s.mockRoomState.EXPECT().GetRoomUsers(gomock.Any(), "room2").Return(map[string]users.User{}).Times(1)
+
+		replies := make(chan error, 3)
+		s.sendStatus("room1", "user1", 1, replies)
+		s.sendStatus("room2", "user2", 1, replies)
+		s.sendStatus("room1", "user1", 2, replies)
+		s.waitReplies(replies, 3)
+	})
+
+	s.Run("flushes after max wait", func() {
+		s.ctrl.statusBatch = &StatusBatch{MaxItems: 10, MaxWait: 10 * time.Millisecond}
+		defer s.startLoop()()
+
+		s.mockRoomState.EXPECT().UpdateUserStatuses(gomock.Any(), gomock.Len(1)).Return([]bool{false}, nil)
+
+		replies := make(chan error, 1)
+		s.sendStatus("room1", "user1", 1, replies)
+		s.waitReplies(replies, 1)
+	})
+
+	s.Run("flushes before other events", func() {
+		s.ctrl.statusBatch = &StatusBatch{MaxItems: 10, MaxWait: time.Minute}
+		defer s.startLoop()()
+
+		flushed := false
+		s.mockRoomState.EXPECT().UpdateUserStatuses(gomock.Any(), gomock.Len(1)).
+			DoAndReturn(func(context.Context, []users.StatusUpdate) ([]bool, error) {
+				flushed = true
+				return []bool{false}, nil
+			})
+
+		replies := make(chan error, 2)
+		s.sendStatus("room1", "user1", 1, replies)
+		s.ctrl.userEventCh <- &userEvent{
+			action: func(context.Context) error {
+				s.True(flushed)
+				replies <- nil
+				return nil
+			},
+		}
+		s.waitReplies(replies, 2)
+	})
+
+	s.Run("fails every request of a failed batch", func() {
+		s.ctrl.statusBatch = &StatusBatch{MaxItems: 2, MaxWait: time.Minute}
+		defer s.startLoop()()
+
+		s.mockRoomState.EXPECT().UpdateUserStatuses(gomock.Any(), gomock.Len(2)).Return(nil, context.DeadlineExceeded)
+
+		replies := make(chan error, 2)
+		s.sendStatus("room1", "user1", 1, replies)
+		s.sendStatus("room1", "user2", 1, replies)
+		for range 2 {
+			select {
+			case err := <-replies:
+				s.ErrorIs(err, context.DeadlineExceeded)
+			case <-time.After(1 * time.Second):
+				s.T().Fatal("timeout waiting for reply")
+			}
+		}
+	})
+}
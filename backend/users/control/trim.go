@@ -5,60 +5,88 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
 )
 
-const (
-	inStreamRetention    = 3 * time.Minute
-	replyStreamRetention = 3 * time.Minute
-	wsStreamRetention    = 3 * time.Minute
-)
+// TrimPolicies are the trim policies of the streams of the user service
+type TrimPolicies struct {
+	In    redisstream.TrimPolicy `mapstructure:"in"`
+	Reply redisstream.TrimPolicy `mapstructure:"reply"`
+	WS    redisstream.TrimPolicy `mapstructure:"ws"`
+}
+
+func SetupTrimPolicies(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	for _, stream := range []string{"in", "reply", "ws"} {
+		v.SetDefault(p(stream+".max_len"), 100000)
+		v.SetDefault(p(stream+".max_age"), 3*time.Minute)
+	}
+}
 
 func NewTrimer(
 	redisClient *redis.Client,
 	streamIn string,
 	streamReply string,
 	wsStream string,
+	wsPartitions int,
+	policies *TrimPolicies,
 	interval time.Duration,
 	logger *log.Logger,
 ) (*Trimer, error) {
 	inTrimer := redisstream.NewTrimer(redisClient, streamIn, logger.Module("InTrimer"))
 	outTrimer := redisstream.NewTrimer(redisClient, streamReply, logger.Module("OutTrimer"))
-	wsTrimer := redisstream.NewTrimer(redisClient, wsStream, logger.Module("WsTrimer"))
+	// the unpartitioned stream is kept trimmed while writers switch to partitions
+	wsStreams := append([]string{wsStream}, redisstream.PartitionStreams(wsStream, wsPartitions)...)
+	wsTrimers := make([]redisstream.Trimer, 0, len(wsStreams))
+	for _, stream := range wsStreams {
+		wsTrimers = append(wsTrimers, redisstream.NewTrimer(redisClient, stream, logger.Module("WsTrimer")))
+	}
 
 	return &Trimer{
-		inTrimer:  inTrimer,
-		outTrimer: outTrimer,
-		wsTrimer:  wsTrimer,
-		interval:  interval,
-		logger:    logger,
+		inTrimer:    inTrimer,
+		outTrimer:   outTrimer,
+		wsTrimers:   wsTrimers,
+		streamIn:    streamIn,
+		streamReply: streamReply,
+		wsStreams:   wsStreams,
+		policies:    policies,
+		interval:    interval,
+		logger:      logger,
 	}, nil
 }
 
 type Trimer struct {
-	inTrimer  redisstream.Trimer
-	outTrimer redisstream.Trimer
-	wsTrimer  redisstream.Trimer
-	interval  time.Duration
-	cancel    context.CancelFunc
-	logger    *log.Logger
+	inTrimer    redisstream.Trimer
+	outTrimer   redisstream.Trimer
+	wsTrimers   []redisstream.Trimer
+	streamIn    string
+	streamReply string
+	wsStreams   []string
+	policies    *TrimPolicies
+	interval    time.Duration
+	cancel      context.CancelFunc
+	logger      *log.Logger
 }
 
 func (t *Trimer) Start(ctx context.Context) error {
-	ticker := time.NewTicker(t.interval)
-
 	ctx, t.cancel = context.WithCancel(ctx)
-	defer t.cancel()
 
 	go func() {
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+
 		for {
 			select {
 			case <-ticker.C:
 				t.trimOnce(ctx)
 			case <-ctx.Done():
-				ticker.Stop()
+				return
 			}
 		}
 	}()
@@ -73,14 +101,31 @@ func (t *Trimer) Stop() {
 }
 
 func (t *Trimer) trimOnce(ctx context.Context) {
+	t.trim(ctx, t.inTrimer, t.streamIn, &t.policies.In)
+	t.trim(ctx, t.outTrimer, t.streamReply, &t.policies.Reply)
+	for i, trimer := range t.wsTrimers {
+		t.trim(ctx, trimer, t.wsStreams[i], &t.policies.WS)
+	}
+}
+
+func (t *Trimer) trim(ctx context.Context, trimer redisstream.Trimer, stream string, policy *redisstream.TrimPolicy) {
+	attrs := metric.WithAttributes(attribute.String("stream", stream))
 
-	if err := t.inTrimer.TrimByTime(ctx, inStreamRetention); err != nil {
-		t.logger.Error("failed to trim in stream", log.Error(err))
+	res, err := trimer.Trim(ctx, policy)
+	if err != nil {
+		streamTrimFailed.Add(ctx, 1, attrs)
+		t.logger.Error("failed to trim stream", log.String("stream", stream), log.Error(err))
+		return
 	}
-	if err := t.outTrimer.TrimByTime(ctx, replyStreamRetention); err != nil {
-		t.logger.Error("failed to trim reply stream", log.Error(err))
+
+	streamEntriesTrimmed.Add(ctx, res.Trimmed, attrs)
+	if res.HeldBack {
+		streamTrimHeldBack.Add(ctx, 1, attrs)
 	}
-	if err := t.wsTrimer.TrimByTime(ctx, wsStreamRetention); err != nil {
-		t.logger.Error("failed to trim ws stream", log.Error(err))
+	for group, lag := range res.Lag {
+		streamConsumerLag.Record(ctx, lag, metric.WithAttributes(
+			attribute.String("stream", stream),
+			attribute.String("group", group),
+		))
 	}
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/suite"
 
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
 )
 
 type TrimerTestSuite struct {
@@ -33,6 +34,12 @@ func (s *TrimerTestSuite) SetupTest() {
 		"test:stream:in",
 		"test:stream:reply",
 		"test:ws:stream",
+		0,
+		&TrimPolicies{
+			In:    redisstream.TrimPolicy{MaxLen: 1},
+			Reply: redisstream.TrimPolicy{MaxLen: 1},
+			WS:    redisstream.TrimPolicy{MaxAge: time.Minute},
+		},
 		100*time.Millisecond,
 		logger,
 	)
@@ -62,7 +69,7 @@ func TestTrimerSuite(t *testing.T) {
 func (s *TrimerTestSuite) TestNewTrimer() {
 	s.NotNil(s.trimer.inTrimer)
 	s.NotNil(s.trimer.outTrimer)
-	s.NotNil(s.trimer.wsTrimer)
+	s.Len(s.trimer.wsTrimers, 1)
 	s.Equal(100*time.Millisecond, s.trimer.interval)
 	s.NotNil(s.trimer.logger)
 }
@@ -83,7 +90,16 @@ func (s *TrimerTestSuite) TestTrimOnce() {
 		Values: map[string]any{"data": "test3"},
 	})
 
+	s.redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: "test:stream:in",
+		Values: map[string]any{"data": "test4"},
+	})
+
 	s.trimer.trimOnce(ctx)
+
+	s.Equal(int64(1), s.redisClient.XLen(ctx, "test:stream:in").Val())
+	s.Equal(int64(1), s.redisClient.XLen(ctx, "test:stream:reply").Val())
+	s.Equal(int64(1), s.redisClient.XLen(ctx, "test:ws:stream").Val())
 }
 
 func (s *TrimerTestSuite) TestStartStop_Multiple() {
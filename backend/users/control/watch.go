@@ -0,0 +1,60 @@
+package control
+
+import (
+	"sync"
+
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+// roomStatusWatchers fans the room status broadcast to the gateways out to in-process watchers.
+// Every status holds all members, a watcher falling behind only gets the latest one
+type roomStatusWatchers struct {
+	mu       sync.Mutex
+	watchers map[string]map[chan *users.NotifyRoomStatus]struct{}
+}
+
+func (w *roomStatusWatchers) watch(roomID string) (<-chan *users.NotifyRoomStatus, func()) {
+	ch := make(chan *users.NotifyRoomStatus, 1)
+
+	w.mu.Lock()
+	if w.watchers == nil {
+		w.watchers = map[string]map[chan *users.NotifyRoomStatus]struct{}{}
+	}
+	if w.watchers[roomID] == nil {
+		w.watchers[roomID] = map[chan *users.NotifyRoomStatus]struct{}{}
+	}
+	w.watchers[roomID][ch] = struct{}{}
+	w.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			delete(w.watchers[roomID], ch)
+			if len(w.watchers[roomID]) == 0 {
+				delete(w.watchers, roomID)
+			}
+		})
+	}
+	return ch, cancel
+}
+
+func (w *roomStatusWatchers) publish(status *users.NotifyRoomStatus) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for ch := range w.watchers[status.RoomID] {
+		// replace a status not taken yet
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- status
+	}
+}
+
+// WatchRoomStatus returns the statuses of the room broadcast from now on until cancel is called
+func (c *UserStatusControl) WatchRoomStatus(roomID string) (<-chan *users.NotifyRoomStatus, func()) {
+	return c.statusWatchers.watch(roomID)
+}
@@ -0,0 +1,34 @@
+package users
+
+import (
+	"slices"
+	"strings"
+)
+
+// SpeakingQueue returns the active users with a raised hand, earliest first
+func SpeakingQueue(us map[string]User) []string {
+	queue := make([]string, 0, len(us))
+	for userID, u := range us {
+		if u.IsActive() && !u.HandRaisedAt.IsZero() {
+			queue = append(queue, userID)
+		}
+	}
+	slices.SortFunc(queue, func(a, b string) int {
+		if c := us[a].HandRaisedAt.Compare(us[b].HandRaisedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	return queue
+}
+
+// Publishers returns how many active users hold a publish slot
+func Publishers(us map[string]User) int {
+	n := 0
+	for _, u := range us {
+		if u.IsActive() && u.Publish {
+			n++
+		}
+	}
+	return n
+}
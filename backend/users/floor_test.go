@@ -0,0 +1,34 @@
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpeakingQueue(t *testing.T) {
+	now := time.Now()
+	us := map[string]User{
+		"late":     {TS: now, HandRaisedAt: now.Add(-time.Second)},
+		"early":    {TS: now, HandRaisedAt: now.Add(-time.Minute)},
+		"tie":      {TS: now, HandRaisedAt: now.Add(-time.Second)},
+		"lowered":  {TS: now},
+		"inactive": {HandRaisedAt: now.Add(-time.Hour)},
+	}
+
+	assert.Equal(t, []string{"early", "late", "tie"}, SpeakingQueue(us))
+	assert.Empty(t, SpeakingQueue(nil))
+}
+
+func TestPublishers(t *testing.T) {
+	now := time.Now()
+	us := map[string]User{
+		"granted":  {TS: now, Publish: true},
+		"waiting":  {TS: now, HandRaisedAt: now},
+		"inactive": {Publish: true},
+	}
+
+	assert.Equal(t, 1, Publishers(us))
+	assert.Zero(t, Publishers(nil))
+}
@@ -12,6 +12,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 
@@ -86,6 +87,35 @@ func (mr *MockRoomsStateMockRecorder) GetRoomUsers(ctx, roomID any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoomUsers", reflect.TypeOf((*MockRoomsState)(nil).GetRoomUsers), ctx, roomID)
 }
 
+// GrantFloor mocks base method.
+func (m *MockRoomsState) GrantFloor(ctx context.Context, roomID, userID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GrantFloor", ctx, roomID, userID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GrantFloor indicates an expected call of GrantFloor.
+func (mr *MockRoomsStateMockRecorder) GrantFloor(ctx, roomID, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantFloor", reflect.TypeOf((*MockRoomsState)(nil).GrantFloor), ctx, roomID, userID)
+}
+
+// InactiveUsers mocks base method.
+func (m *MockRoomsState) InactiveUsers(ctx context.Context, timeout time.Duration) map[string][]string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InactiveUsers", ctx, timeout)
+	ret0, _ := ret[0].(map[string][]string)
+	return ret0
+}
+
+// InactiveUsers indicates an expected call of InactiveUsers.
+func (mr *MockRoomsStateMockRecorder) InactiveUsers(ctx, timeout any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InactiveUsers", reflect.TypeOf((*MockRoomsState)(nil).InactiveUsers), ctx, timeout)
+}
+
 // Rebuild mocks base method.
 func (m *MockRoomsState) Rebuild(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -115,6 +145,51 @@ func (mr *MockRoomsStateMockRecorder) RemoveUser(ctx, roomID, userID any) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveUser", reflect.TypeOf((*MockRoomsState)(nil).RemoveUser), ctx, roomID, userID)
 }
 
+// SetPublish mocks base method.
+func (m *MockRoomsState) SetPublish(ctx context.Context, roomID, userID string, granted bool) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPublish", ctx, roomID, userID, granted)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetPublish indicates an expected call of SetPublish.
+func (mr *MockRoomsStateMockRecorder) SetPublish(ctx, roomID, userID, granted any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPublish", reflect.TypeOf((*MockRoomsState)(nil).SetPublish), ctx, roomID, userID, granted)
+}
+
+// SetUserHand mocks base method.
+func (m *MockRoomsState) SetUserHand(ctx context.Context, roomID, userID string, raised bool, ts time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserHand", ctx, roomID, userID, raised, ts)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetUserHand indicates an expected call of SetUserHand.
+func (mr *MockRoomsStateMockRecorder) SetUserHand(ctx, roomID, userID, raised, ts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserHand", reflect.TypeOf((*MockRoomsState)(nil).SetUserHand), ctx, roomID, userID, raised, ts)
+}
+
+// UpdateUserQuality mocks base method.
+func (m *MockRoomsState) UpdateUserQuality(ctx context.Context, roomID, userID string, quality int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserQuality", ctx, roomID, userID, quality)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserQuality indicates an expected call of UpdateUserQuality.
+func (mr *MockRoomsStateMockRecorder) UpdateUserQuality(ctx, roomID, userID, quality any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserQuality", reflect.TypeOf((*MockRoomsState)(nil).UpdateUserQuality), ctx, roomID, userID, quality)
+}
+
 // UpdateUserStatus mocks base method.
 func (m *MockRoomsState) UpdateUserStatus(ctx context.Context, roomID, userID string, u *users.User) (bool, error) {
 	m.ctrl.T.Helper()
@@ -129,3 +204,18 @@ func (mr *MockRoomsStateMockRecorder) UpdateUserStatus(ctx, roomID, userID, u an
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserStatus", reflect.TypeOf((*MockRoomsState)(nil).UpdateUserStatus), ctx, roomID, userID, u)
 }
+
+// UpdateUserStatuses mocks base method.
+func (m *MockRoomsState) UpdateUserStatuses(ctx context.Context, updates []users.StatusUpdate) ([]bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserStatuses", ctx, updates)
+	ret0, _ := ret[0].([]bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserStatuses indicates an expected call of UpdateUserStatuses.
+func (mr *MockRoomsStateMockRecorder) UpdateUserStatuses(ctx, updates any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserStatuses", reflect.TypeOf((*MockRoomsState)(nil).UpdateUserStatuses), ctx, updates)
+}
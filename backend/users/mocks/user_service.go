@@ -44,9 +44,9 @@ func (m *MockUserService) EXPECT() *MockUserServiceMockRecorder {
 }
 
 // CreateUser mocks base method.
-func (m *MockUserService) CreateUser(ctx context.Context, roomId, userId, role string) (string, string, error) {
+func (m *MockUserService) CreateUser(ctx context.Context, roomID, userID, role, locale string) (string, string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", ctx, roomId, userId, role)
+	ret := m.ctrl.Call(m, "CreateUser", ctx, roomID, userID, role, locale)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(string)
 	ret2, _ := ret[2].(error)
@@ -54,52 +54,139 @@ func (m *MockUserService) CreateUser(ctx context.Context, roomId, userId, role s
 }
 
 // CreateUser indicates an expected call of CreateUser.
-func (mr *MockUserServiceMockRecorder) CreateUser(ctx, roomId, userId, role any) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) CreateUser(ctx, roomID, userID, role, locale any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUserService)(nil).CreateUser), ctx, roomId, userId, role)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUserService)(nil).CreateUser), ctx, roomID, userID, role, locale)
 }
 
 // DeleteUser mocks base method.
-func (m *MockUserService) DeleteUser(ctx context.Context, roomId, userId string) error {
+func (m *MockUserService) DeleteUser(ctx context.Context, roomID, userID string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteUser", ctx, roomId, userId)
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, roomID, userID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteUser indicates an expected call of DeleteUser.
-func (mr *MockUserServiceMockRecorder) DeleteUser(ctx, roomId, userId any) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) DeleteUser(ctx, roomID, userID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserService)(nil).DeleteUser), ctx, roomId, userId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserService)(nil).DeleteUser), ctx, roomID, userID)
 }
 
 // GetActiveRoomUsers mocks base method.
-func (m *MockUserService) GetActiveRoomUsers(ctx context.Context, roomId string) ([]*users.RoomUser, error) {
+func (m *MockUserService) GetActiveRoomUsers(ctx context.Context, roomID string) ([]*users.RoomUser, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetActiveRoomUsers", ctx, roomId)
+	ret := m.ctrl.Call(m, "GetActiveRoomUsers", ctx, roomID)
 	ret0, _ := ret[0].([]*users.RoomUser)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetActiveRoomUsers indicates an expected call of GetActiveRoomUsers.
-func (mr *MockUserServiceMockRecorder) GetActiveRoomUsers(ctx, roomId any) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) GetActiveRoomUsers(ctx, roomID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActiveRoomUsers", reflect.TypeOf((*MockUserService)(nil).GetActiveRoomUsers), ctx, roomId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActiveRoomUsers", reflect.TypeOf((*MockUserService)(nil).GetActiveRoomUsers), ctx, roomID)
+}
+
+// GrantFloor mocks base method.
+func (m *MockUserService) GrantFloor(ctx context.Context, roomID, userID string, unmute bool) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GrantFloor", ctx, roomID, userID, unmute)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GrantFloor indicates an expected call of GrantFloor.
+func (mr *MockUserServiceMockRecorder) GrantFloor(ctx, roomID, userID, unmute any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantFloor", reflect.TypeOf((*MockUserService)(nil).GrantFloor), ctx, roomID, userID, unmute)
+}
+
+// GrantPublish mocks base method.
+func (m *MockUserService) GrantPublish(ctx context.Context, roomID, userID string, slots int) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GrantPublish", ctx, roomID, userID, slots)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GrantPublish indicates an expected call of GrantPublish.
+func (mr *MockUserServiceMockRecorder) GrantPublish(ctx, roomID, userID, slots any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantPublish", reflect.TypeOf((*MockUserService)(nil).GrantPublish), ctx, roomID, userID, slots)
+}
+
+// QueuePosition mocks base method.
+func (m *MockUserService) QueuePosition(ctx context.Context, roomID, userID string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueuePosition", ctx, roomID, userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueuePosition indicates an expected call of QueuePosition.
+func (mr *MockUserServiceMockRecorder) QueuePosition(ctx, roomID, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueuePosition", reflect.TypeOf((*MockUserService)(nil).QueuePosition), ctx, roomID, userID)
+}
+
+// RevokePublish mocks base method.
+func (m *MockUserService) RevokePublish(ctx context.Context, roomID, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokePublish", ctx, roomID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokePublish indicates an expected call of RevokePublish.
+func (mr *MockUserServiceMockRecorder) RevokePublish(ctx, roomID, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokePublish", reflect.TypeOf((*MockUserService)(nil).RevokePublish), ctx, roomID, userID)
+}
+
+// SetUserHand mocks base method.
+func (m *MockUserService) SetUserHand(ctx context.Context, roomID, userID string, raised bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserHand", ctx, roomID, userID, raised)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserHand indicates an expected call of SetUserHand.
+func (mr *MockUserServiceMockRecorder) SetUserHand(ctx, roomID, userID, raised any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserHand", reflect.TypeOf((*MockUserService)(nil).SetUserHand), ctx, roomID, userID, raised)
+}
+
+// SetUserQuality mocks base method.
+func (m *MockUserService) SetUserQuality(ctx context.Context, roomID, userID string, quality int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserQuality", ctx, roomID, userID, quality)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserQuality indicates an expected call of SetUserQuality.
+func (mr *MockUserServiceMockRecorder) SetUserQuality(ctx, roomID, userID, quality any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserQuality", reflect.TypeOf((*MockUserService)(nil).SetUserQuality), ctx, roomID, userID, quality)
 }
 
 // SetUserStatus mocks base method.
-func (m *MockUserService) SetUserStatus(ctx context.Context, roomId, userId string, status constants.AnchorStatus, gen int32) error {
+func (m *MockUserService) SetUserStatus(ctx context.Context, roomID, userID string, status constants.AnchorStatus, gen int32) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetUserStatus", ctx, roomId, userId, status, gen)
+	ret := m.ctrl.Call(m, "SetUserStatus", ctx, roomID, userID, status, gen)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SetUserStatus indicates an expected call of SetUserStatus.
-func (mr *MockUserServiceMockRecorder) SetUserStatus(ctx, roomId, userId, status, gen any) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) SetUserStatus(ctx, roomID, userID, status, gen any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserStatus", reflect.TypeOf((*MockUserService)(nil).SetUserStatus), ctx, roomId, userId, status, gen)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserStatus", reflect.TypeOf((*MockUserService)(nil).SetUserStatus), ctx, roomID, userID, status, gen)
 }
 
 // Start mocks base method.
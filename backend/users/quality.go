@@ -0,0 +1,26 @@
+package users
+
+import "math"
+
+const (
+	// DegradedQuality is the score below which an anchor is flagged as degraded
+	DegradedQuality = 50
+)
+
+// NetworkStats is a summary of WebRTC getStats reported by an anchor
+type NetworkStats struct {
+	RTT        float64 `json:"rtt" validate:"gte=0"`              // round trip time in ms
+	Jitter     float64 `json:"jitter" validate:"gte=0"`           // in ms
+	PacketLoss float64 `json:"packetLoss" validate:"gte=0,lte=1"` // fraction of packets lost
+}
+
+// QualityScore maps network stats to a score from 1 (unusable) to 100, loosely following
+// the E-model: RTT over 150ms, jitter over 20ms and any packet loss degrade the voice
+func QualityScore(stats *NetworkStats) int {
+	score := 100.0
+	score -= math.Max(0, stats.RTT-150) / 10
+	score -= math.Max(0, stats.Jitter-20) / 2
+	score -= stats.PacketLoss * 500
+
+	return int(math.Round(math.Min(100, math.Max(1, score))))
+}
@@ -0,0 +1,27 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQualityScore(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats NetworkStats
+		want  int
+	}{
+		{"perfect", NetworkStats{RTT: 40, Jitter: 5}, 100},
+		{"high rtt", NetworkStats{RTT: 650, Jitter: 5}, 50},
+		{"high jitter", NetworkStats{RTT: 40, Jitter: 60}, 80},
+		{"packet loss", NetworkStats{RTT: 40, Jitter: 5, PacketLoss: 0.05}, 75},
+		{"unusable", NetworkStats{RTT: 2000, Jitter: 200, PacketLoss: 0.5}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, QualityScore(&tt.stats))
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package refresh
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	// TTL is the lifetime of a refresh token, every refresh restarts it
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+func Setup(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("enabled"), false)
+	v.SetDefault(p("ttl"), 30*24*time.Hour)
+}
+
+// Store keeps refresh tokens hashed in Redis, a stolen dump does not yield usable tokens:
+//
+//	<prefix>:refresh:<hash>                   grant of the token
+//	<prefix>:refresh:user:<roomId>:<userId>   hashes of the user's tokens, for revocation
+type Store struct {
+	client    *redis.Client
+	keyPrefix string
+	ttl       time.Duration
+}
+
+func NewStore(client *redis.Client, keyPrefix string, cfg *Config) *Store {
+	return &Store{
+		client:    client,
+		keyPrefix: keyPrefix + ":refresh:",
+		ttl:       cfg.TTL,
+	}
+}
+
+func (s *Store) tokenKey(hash string) string {
+	return s.keyPrefix + hash
+}
+
+func (s *Store) userKey(roomID, userID string) string {
+	return s.keyPrefix + "user:" + roomID + ":" + userID
+}
+
+func (s *Store) Issue(ctx context.Context, grant *users.RefreshGrant) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	data, err := json.Marshal(grant)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal refresh grant: %w", err)
+	}
+	hash := hashToken(token)
+	userKey := s.userKey(grant.RoomID, grant.UserID)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.tokenKey(hash), data, s.ttl)
+		pipe.SAdd(ctx, userKey, hash)
+		pipe.Expire(ctx, userKey, s.ttl)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return token, nil
+}
+
+func (s *Store) Rotate(ctx context.Context, token string) (*users.RefreshGrant, string, error) {
+	grant, err := s.consume(ctx, token)
+	if err != nil {
+		return nil, "", err
+	}
+	next, err := s.Issue(ctx, grant)
+	if err != nil {
+		return nil, "", err
+	}
+	return grant, next, nil
+}
+
+func (s *Store) Revoke(ctx context.Context, token string) error {
+	_, err := s.consume(ctx, token)
+	if errors.Is(err, users.ErrInvalidRefreshToken) {
+		// already gone, logging out twice is fine
+		return nil
+	}
+	return err
+}
+
+func (s *Store) RevokeUser(ctx context.Context, roomID, userID string) error {
+	userKey := s.userKey(roomID, userID)
+	hashes, err := s.client.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list refresh tokens: %w", err)
+	}
+
+	keys := make([]string, 0, len(hashes)+1)
+	for _, hash := range hashes {
+		keys = append(keys, s.tokenKey(hash))
+	}
+	keys = append(keys, userKey)
+	if err := s.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// consume deletes the token and returns its grant, a token is only ever consumed once
+func (s *Store) consume(ctx context.Context, token string) (*users.RefreshGrant, error) {
+	if token == "" {
+		return nil, users.ErrInvalidRefreshToken
+	}
+	hash := hashToken(token)
+	data, err := s.client.GetDel(ctx, s.tokenKey(hash)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, users.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	var grant users.RefreshGrant
+	if err := json.Unmarshal(data, &grant); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal refresh grant: %w", err)
+	}
+	if err := s.client.SRem(ctx, s.userKey(grant.RoomID, grant.UserID), hash).Err(); err != nil {
+		return nil, fmt.Errorf("failed to remove refresh token: %w", err)
+	}
+	return &grant, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,113 @@
+package refresh
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+type StoreTestSuite struct {
+	suite.Suite
+	miniRedis *miniredis.Miniredis
+	client    *redis.Client
+	store     *Store
+	ctx       context.Context
+}
+
+func TestStoreSuite(t *testing.T) {
+	suite.Run(t, new(StoreTestSuite))
+}
+
+func (s *StoreTestSuite) SetupTest() {
+	mr, err := miniredis.Run()
+	s.Require().NoError(err)
+	s.miniRedis = mr
+	s.client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	s.store = NewStore(s.client, "rtcus", &Config{Enabled: true, TTL: time.Hour})
+	s.ctx = context.Background()
+}
+
+func (s *StoreTestSuite) TearDownTest() {
+	s.client.Close()
+	s.miniRedis.Close()
+}
+
+func (s *StoreTestSuite) grant(userID string) *users.RefreshGrant {
+	return &users.RefreshGrant{RoomID: "room-1", UserID: userID, Role: "anchor"}
+}
+
+func (s *StoreTestSuite) TestIssue_StoresHashedToken() {
+	token, err := s.store.Issue(s.ctx, s.grant("user-1"))
+	s.Require().NoError(err)
+	s.NotEmpty(token)
+
+	for _, key := range s.miniRedis.Keys() {
+		s.NotContains(key, token)
+	}
+	s.Equal(time.Hour, s.miniRedis.TTL("rtcus:refresh:"+hashToken(token)))
+	s.True(s.miniRedis.Exists("rtcus:refresh:user:room-1:user-1"))
+}
+
+func (s *StoreTestSuite) TestRotate_IsSingleUse() {
+	token, err := s.store.Issue(s.ctx, s.grant("user-1"))
+	s.Require().NoError(err)
+
+	grant, next, err := s.store.Rotate(s.ctx, token)
+	s.Require().NoError(err)
+	s.Equal(s.grant("user-1"), grant)
+	s.NotEqual(token, next)
+
+	_, _, err = s.store.Rotate(s.ctx, token)
+	s.ErrorIs(err, users.ErrInvalidRefreshToken)
+
+	_, _, err = s.store.Rotate(s.ctx, next)
+	s.NoError(err)
+}
+
+func (s *StoreTestSuite) TestRotate_ExpiredToken() {
+	token, err := s.store.Issue(s.ctx, s.grant("user-1"))
+	s.Require().NoError(err)
+
+	s.miniRedis.FastForward(2 * time.Hour)
+	_, _, err = s.store.Rotate(s.ctx, token)
+	s.ErrorIs(err, users.ErrInvalidRefreshToken)
+
+	_, _, err = s.store.Rotate(s.ctx, "")
+	s.ErrorIs(err, users.ErrInvalidRefreshToken)
+}
+
+func (s *StoreTestSuite) TestRevoke() {
+	token, err := s.store.Issue(s.ctx, s.grant("user-1"))
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.store.Revoke(s.ctx, token))
+	s.NoError(s.store.Revoke(s.ctx, token))
+
+	_, _, err = s.store.Rotate(s.ctx, token)
+	s.ErrorIs(err, users.ErrInvalidRefreshToken)
+}
+
+func (s *StoreTestSuite) TestRevokeUser() {
+	token1, err := s.store.Issue(s.ctx, s.grant("user-1"))
+	s.Require().NoError(err)
+	token2, err := s.store.Issue(s.ctx, s.grant("user-1"))
+	s.Require().NoError(err)
+	other, err := s.store.Issue(s.ctx, s.grant("user-2"))
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.store.RevokeUser(s.ctx, "room-1", "user-1"))
+
+	for _, token := range []string{token1, token2} {
+		_, _, err = s.store.Rotate(s.ctx, token)
+		s.ErrorIs(err, users.ErrInvalidRefreshToken)
+	}
+	_, _, err = s.store.Rotate(s.ctx, other)
+	s.NoError(err)
+	s.False(s.miniRedis.Exists("rtcus:refresh:user:room-1:user-1"))
+}
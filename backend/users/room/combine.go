@@ -7,6 +7,7 @@ import (
 
 	"github.com/redis/go-redis/v9"
 
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	fredis "github.com/imtaco/audio-rtc-exp/internal/redis"
 	"github.com/imtaco/audio-rtc-exp/internal/zset"
@@ -74,6 +75,78 @@ func (c *combinedRoom) UpdateUserStatus(
 	return true, c.redisState.setUserStatus(ctx, roomID, userID, u)
 }
 
+func (c *combinedRoom) UpdateUserStatuses(ctx context.Context, updates []users.StatusUpdate) ([]bool, error) {
+	type userKey struct{ roomID, userID string }
+
+	applied := make([]bool, len(updates))
+	gens := make(map[userKey]int32, len(updates))
+	last := make(map[userKey]int, len(updates))
+	for i, update := range updates {
+		key := userKey{update.RoomID, update.UserID}
+		if gen, ok := gens[key]; ok && update.User.Gen < gen {
+			continue
+		}
+		if !c.memState.setUserStatus(update.RoomID, update.UserID, update.User) {
+			continue
+		}
+		applied[i] = true
+		gens[key] = update.User.Gen
+		last[key] = i
+	}
+
+	// only the last status of each user is written
+	writes := make([]users.StatusUpdate, 0, len(last))
+	for i, update := range updates {
+		if applied[i] && last[userKey{update.RoomID, update.UserID}] == i {
+			writes = append(writes, update)
+		}
+	}
+	return applied, c.redisState.setUserStatuses(ctx, writes)
+}
+
+func (c *combinedRoom) UpdateUserQuality(
+	ctx context.Context,
+	roomID string,
+	userID string,
+	quality int,
+) (bool, error) {
+	if !c.memState.setUserQuality(roomID, userID, quality) {
+		return false, nil
+	}
+	return true, c.redisState.setUserQuality(ctx, roomID, userID, quality)
+}
+
+func (c *combinedRoom) SetUserHand(
+	ctx context.Context,
+	roomID string,
+	userID string,
+	raised bool,
+	ts time.Time,
+) (bool, error) {
+	if !c.memState.setUserHand(roomID, userID, raised, ts) {
+		return false, nil
+	}
+	if !raised {
+		ts = time.Time{}
+	}
+	return true, c.redisState.setUserHand(ctx, roomID, userID, ts)
+}
+
+func (c *combinedRoom) GrantFloor(ctx context.Context, roomID, userID string) (bool, error) {
+	ok, prev := c.memState.grantFloor(roomID, userID)
+	if !ok {
+		return false, nil
+	}
+	return true, c.redisState.grantFloor(ctx, roomID, userID, prev)
+}
+
+func (c *combinedRoom) SetPublish(ctx context.Context, roomID, userID string, granted bool) (bool, error) {
+	if !c.memState.setPublish(roomID, userID, granted) {
+		return false, nil
+	}
+	return true, c.redisState.setPublish(ctx, roomID, userID, granted)
+}
+
 func (c *combinedRoom) RemoveUser(ctx context.Context, roomID, userID string) (bool, error) {
 	ok, lastUser := c.memState.removeRoomUser(roomID, userID)
 	if !ok {
@@ -128,7 +201,9 @@ func (c *combinedRoom) Rebuild(ctx context.Context) error {
 				continue
 			}
 
-			if user.Status != "" {
+			// the last status change is not persisted, inactivity counts from the rebuild
+			user.Since = time.Now()
+			if user.Status != "" && user.Status != constants.AnchorStatusLeft {
 				if user.TS.IsZero() {
 					user.TS = time.Now()
 				}
@@ -148,6 +223,10 @@ func (c *combinedRoom) Rebuild(ctx context.Context) error {
 	return nil
 }
 
+func (c *combinedRoom) InactiveUsers(_ context.Context, timeout time.Duration) map[string][]string {
+	return c.memState.inactiveUsers(time.Now().Add(-timeout))
+}
+
 func (c *combinedRoom) CheckTimeout(ctx context.Context) ([]string, error) {
 
 	effectedRooms := make(map[string]struct{})
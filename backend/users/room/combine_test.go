@@ -219,6 +219,166 @@ func (s *CombinedRoomTestSuite) TestUpdateUserStatus() {
 	}
 }
 
+func (s *CombinedRoomTestSuite) TestUpdateUserStatuses() {
+	s.resetRoomState()
+	now := time.Now().Truncate(time.Millisecond)
+
+	for _, userID := range []string{"user1", "user2"} {
+		_, err := s.room.CreateUser(s.ctx, "room1", userID, &users.User{Role: "anchor", TS: now})
+		s.Require().NoError(err)
+	}
+
+	applied, err := s.room.UpdateUserStatuses(s.ctx, []users.StatusUpdate{
+		{RoomID: "room1", UserID: "user1", User: &users.User{Status: constants.AnchorStatusIdle, Gen: 1, TS: now}},
+		{RoomID: "room1", UserID: "user2", User: &users.User{Status: constants.AnchorStatusOnAir, Gen: 1, TS: now}},
+		{RoomID: "room1", UserID: "user1", User: &users.User{Status: constants.AnchorStatusOnAir, Gen: 2, TS: now}},
+		// older generation than the update before it
+		{RoomID: "room1", UserID: "user1", User: &users.User{Status: constants.AnchorStatusIdle, Gen: 1, TS: now}},
+		{RoomID: "room1", UserID: "user999", User: &users.User{Status: constants.AnchorStatusOnAir, Gen: 1, TS: now}},
+	})
+	s.Require().NoError(err)
+	s.Equal([]bool{true, true, true, false, false}, applied)
+
+	us := s.room.GetRoomUsers(s.ctx, "room1")
+	s.Equal(constants.AnchorStatusOnAir, us["user1"].Status)
+	s.Equal(int32(2), us["user1"].Gen)
+	s.Equal(constants.AnchorStatusOnAir, us["user2"].Status)
+	s.NotContains(us, "user999")
+
+	// the last status of each user is in Redis
+	s.resetRoomState()
+	s.Require().NoError(s.room.Rebuild(s.ctx))
+	us = s.room.GetRoomUsers(s.ctx, "room1")
+	s.Equal(constants.AnchorStatusOnAir, us["user1"].Status)
+	s.Equal(int32(2), us["user1"].Gen)
+	s.Equal(constants.AnchorStatusOnAir, us["user2"].Status)
+
+	applied, err = s.room.UpdateUserStatuses(s.ctx, []users.StatusUpdate{
+		{RoomID: "room1", UserID: "user2", User: &users.User{Status: "", Gen: 2, TS: now}},
+	})
+	s.Require().NoError(err)
+	s.Equal([]bool{true}, applied)
+	s.Empty(s.mr.HGet("test:r:room1:us", "s:user2"))
+}
+
+func (s *CombinedRoomTestSuite) TestUpdateUserQuality() {
+	s.resetRoomState()
+
+	ok, err := s.room.UpdateUserQuality(s.ctx, "room1", "user1", 70)
+	s.Require().NoError(err)
+	s.False(ok, "unknown user")
+
+	_, err = s.room.CreateUser(s.ctx, "room1", "user1", &users.User{Role: "host", TS: time.Now()})
+	s.Require().NoError(err)
+
+	ok, err = s.room.UpdateUserQuality(s.ctx, "room1", "user1", 70)
+	s.Require().NoError(err)
+	s.True(ok)
+	s.Equal(70, s.room.GetRoomUsers(s.ctx, "room1")["user1"].Quality)
+
+	val, err := s.redisClient.HGet(s.ctx, "test:r:room1:us", "q:user1").Result()
+	s.Require().NoError(err)
+	s.Equal("70", val)
+
+	// quality survives a rebuild
+	s.resetRoomState()
+	s.Require().NoError(s.room.Rebuild(s.ctx))
+	s.Equal(70, s.room.GetRoomUsers(s.ctx, "room1")["user1"].Quality)
+
+	ok, err = s.room.RemoveUser(s.ctx, "room1", "user1")
+	s.Require().NoError(err)
+	s.True(ok)
+	s.False(s.mr.Exists("test:r:room1:us"))
+}
+
+func (s *CombinedRoomTestSuite) TestSetUserHandAndGrantFloor() {
+	s.resetRoomState()
+	now := time.Now().Truncate(time.Millisecond)
+
+	ok, err := s.room.SetUserHand(s.ctx, "room1", "user1", true, now)
+	s.Require().NoError(err)
+	s.False(ok, "unknown user")
+
+	for _, userID := range []string{"user1", "user2"} {
+		_, err = s.room.CreateUser(s.ctx, "room1", userID, &users.User{Role: "anchor", TS: now})
+		s.Require().NoError(err)
+	}
+
+	ok, err = s.room.SetUserHand(s.ctx, "room1", "user1", true, now)
+	s.Require().NoError(err)
+	s.True(ok)
+	// raising again keeps the place in the queue
+	ok, err = s.room.SetUserHand(s.ctx, "room1", "user1", true, now.Add(time.Second))
+	s.Require().NoError(err)
+	s.False(ok)
+	s.Equal(now, s.room.GetRoomUsers(s.ctx, "room1")["user1"].HandRaisedAt)
+
+	ok, err = s.room.GrantFloor(s.ctx, "room1", "user2")
+	s.Require().NoError(err)
+	s.True(ok)
+	ok, err = s.room.GrantFloor(s.ctx, "room1", "user1")
+	s.Require().NoError(err)
+	s.True(ok)
+
+	us := s.room.GetRoomUsers(s.ctx, "room1")
+	s.True(us["user1"].Floor)
+	s.True(us["user1"].HandRaisedAt.IsZero(), "hand lowered with the floor")
+	s.False(us["user2"].Floor, "floor taken from the previous holder")
+	s.Empty(s.mr.HGet("test:r:room1:us", "f:user2"))
+
+	// floor survives a rebuild
+	s.resetRoomState()
+	s.Require().NoError(s.room.Rebuild(s.ctx))
+	us = s.room.GetRoomUsers(s.ctx, "room1")
+	s.True(us["user1"].Floor)
+	s.False(us["user2"].Floor)
+
+	ok, err = s.room.SetUserHand(s.ctx, "room1", "user2", true, now)
+	s.Require().NoError(err)
+	s.True(ok)
+	s.resetRoomState()
+	s.Require().NoError(s.room.Rebuild(s.ctx))
+	s.Equal(now, s.room.GetRoomUsers(s.ctx, "room1")["user2"].HandRaisedAt)
+
+	ok, err = s.room.SetUserHand(s.ctx, "room1", "user2", false, now)
+	s.Require().NoError(err)
+	s.True(ok)
+	s.Empty(s.mr.HGet("test:r:room1:us", "h:user2"))
+}
+
+func (s *CombinedRoomTestSuite) TestSetPublish() {
+	s.resetRoomState()
+	now := time.Now().Truncate(time.Millisecond)
+
+	ok, err := s.room.SetPublish(s.ctx, "room1", "user1", true)
+	s.Require().NoError(err)
+	s.False(ok, "unknown user")
+
+	_, err = s.room.CreateUser(s.ctx, "room1", "user1", &users.User{Role: "anchor", TS: now})
+	s.Require().NoError(err)
+	_, err = s.room.SetUserHand(s.ctx, "room1", "user1", true, now)
+	s.Require().NoError(err)
+
+	ok, err = s.room.SetPublish(s.ctx, "room1", "user1", true)
+	s.Require().NoError(err)
+	s.True(ok)
+	us := s.room.GetRoomUsers(s.ctx, "room1")
+	s.True(us["user1"].Publish)
+	s.True(us["user1"].HandRaisedAt.IsZero(), "hand lowered with the grant")
+	s.Empty(s.mr.HGet("test:r:room1:us", "h:user1"))
+
+	// grant survives a rebuild
+	s.resetRoomState()
+	s.Require().NoError(s.room.Rebuild(s.ctx))
+	s.True(s.room.GetRoomUsers(s.ctx, "room1")["user1"].Publish)
+
+	ok, err = s.room.SetPublish(s.ctx, "room1", "user1", false)
+	s.Require().NoError(err)
+	s.True(ok)
+	s.False(s.room.GetRoomUsers(s.ctx, "room1")["user1"].Publish)
+	s.Empty(s.mr.HGet("test:r:room1:us", "p:user1"))
+}
+
 func (s *CombinedRoomTestSuite) TestRemoveUser() {
 	now := time.Now()
 
@@ -4,6 +4,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	"github.com/imtaco/audio-rtc-exp/internal/zset"
 	"github.com/imtaco/audio-rtc-exp/users"
@@ -37,8 +38,9 @@ func (r *roomsStateMem) createRoomUser(roomID, userID string, u *users.User) boo
 	}
 	// newly created user only have role set
 	room[userID] = &users.User{
-		Role: u.Role,
-		Gen:  u.Gen,
+		Role:  u.Role,
+		Gen:   u.Gen,
+		Since: u.TS,
 	}
 
 	r.userTracks.Put(userID, roomID, u.TS)
@@ -62,11 +64,15 @@ func (r *roomsStateMem) setUserStatus(roomID, userID string, u *users.User) bool
 	if !ok || ou.Role == "" {
 		return false
 	}
+	if ou.Status != u.Status {
+		ou.Since = u.TS
+	}
 	ou.Status = u.Status
 	ou.Gen = u.Gen
 	ou.TS = u.TS
 
-	if u.Status == "" {
+	// left users have nothing to time out
+	if u.Status == "" || u.Status == constants.AnchorStatusLeft {
 		// zero time for empty status
 		u.TS = time.Time{}
 		r.userTracks.Remove(userID)
@@ -77,6 +83,74 @@ func (r *roomsStateMem) setUserStatus(roomID, userID string, u *users.User) bool
 	return true
 }
 
+func (r *roomsStateMem) setUserQuality(roomID, userID string, quality int) bool {
+	r.rwLock.Lock()
+	defer r.rwLock.Unlock()
+
+	ou, ok := r.rooms[roomID][userID]
+	if !ok || ou.Role == "" {
+		return false
+	}
+	ou.Quality = quality
+	return true
+}
+
+func (r *roomsStateMem) setUserHand(roomID, userID string, raised bool, ts time.Time) bool {
+	r.rwLock.Lock()
+	defer r.rwLock.Unlock()
+
+	ou, ok := r.rooms[roomID][userID]
+	if !ok || ou.Role == "" {
+		return false
+	}
+	// raising again keeps the place in the queue
+	if raised == !ou.HandRaisedAt.IsZero() {
+		return false
+	}
+	if raised {
+		ou.HandRaisedAt = ts
+	} else {
+		ou.HandRaisedAt = time.Time{}
+	}
+	return true
+}
+
+// grantFloor returns the previous floor holder, empty when there was none
+func (r *roomsStateMem) grantFloor(roomID, userID string) (ok bool, prev string) {
+	r.rwLock.Lock()
+	defer r.rwLock.Unlock()
+
+	room := r.rooms[roomID]
+	ou, ok := room[userID]
+	if !ok || ou.Role == "" {
+		return false, ""
+	}
+	for id, u := range room {
+		if u.Floor && id != userID {
+			u.Floor = false
+			prev = id
+		}
+	}
+	ou.Floor = true
+	ou.HandRaisedAt = time.Time{}
+	return true, prev
+}
+
+func (r *roomsStateMem) setPublish(roomID, userID string, granted bool) bool {
+	r.rwLock.Lock()
+	defer r.rwLock.Unlock()
+
+	ou, ok := r.rooms[roomID][userID]
+	if !ok || ou.Role == "" {
+		return false
+	}
+	ou.Publish = granted
+	if granted {
+		ou.HandRaisedAt = time.Time{}
+	}
+	return true
+}
+
 func (r *roomsStateMem) removeRoomUser(roomID, userID string) (ok bool, lastUser bool) {
 	r.rwLock.Lock()
 	defer r.rwLock.Unlock()
@@ -117,6 +191,25 @@ func (r *roomsStateMem) getRoomUsers(roomID string) map[string]users.User {
 	return copied
 }
 
+// inactiveUsers returns the users per room whose status has been idle or empty since before deadline
+func (r *roomsStateMem) inactiveUsers(deadline time.Time) map[string][]string {
+	r.rwLock.RLock()
+	defer r.rwLock.RUnlock()
+
+	inactive := make(map[string][]string)
+	for roomID, room := range r.rooms {
+		for userID, u := range room {
+			if u.Role == "" || (u.Status != "" && u.Status != constants.AnchorStatusIdle) {
+				continue
+			}
+			if u.Since.Before(deadline) {
+				inactive[roomID] = append(inactive[roomID], userID)
+			}
+		}
+	}
+	return inactive
+}
+
 func ensureUser(us map[string]*users.User, userID string) *users.User {
 	u, ok := us[userID]
 	if ok {
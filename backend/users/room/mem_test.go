@@ -136,6 +136,25 @@ func TestRoomsStateMem_SetUserStatus(t *testing.T) {
 			validate: func(t *testing.T, r *roomsStateMem) {
 				assert.Equal(t, constants.AnchorStatusOnAir, r.rooms["room1"]["user1"].Status)
 				assert.Equal(t, int32(1), r.rooms["room1"]["user1"].Gen)
+				assert.Equal(t, now, r.rooms["room1"]["user1"].Since)
+			},
+		},
+		{
+			name: "left user is not tracked",
+			setup: func(r *roomsStateMem) {
+				r.rooms["room1"] = map[string]*users.User{"user1": {Role: "anchor"}}
+				r.userTracks.Put("user1", "room1", now)
+			},
+			roomID: "room1",
+			userID: "user1",
+			user: &users.User{
+				Status: constants.AnchorStatusLeft,
+				TS:     now,
+			},
+			wantOk: true,
+			validate: func(t *testing.T, r *roomsStateMem) {
+				assert.Equal(t, constants.AnchorStatusLeft, r.rooms["room1"]["user1"].Status)
+				assert.Equal(t, 0, r.userTracks.Len())
 			},
 		},
 		{
@@ -397,6 +416,24 @@ func TestRoomsStateMem_GetRoomUsers(t *testing.T) {
 	}
 }
 
+func TestRoomsStateMem_InactiveUsers(t *testing.T) {
+	now := time.Now()
+	r := newTestMemState()
+	r.rooms["room1"] = map[string]*users.User{
+		"idle":       {Role: "anchor", Status: constants.AnchorStatusIdle, Since: now.Add(-time.Hour)},
+		"gone":       {Role: "anchor", Since: now.Add(-time.Hour)},
+		"recent":     {Role: "anchor", Status: constants.AnchorStatusIdle, Since: now},
+		"onair":      {Role: "anchor", Status: constants.AnchorStatusOnAir, Since: now.Add(-time.Hour)},
+		"left":       {Role: "anchor", Status: constants.AnchorStatusLeft, Since: now.Add(-time.Hour)},
+		"roleLess":   {Since: now.Add(-time.Hour)},
+		"idleRecent": {Role: "host", Status: constants.AnchorStatusIdle, Since: now.Add(-time.Second)},
+	}
+
+	inactive := r.inactiveUsers(now.Add(-time.Minute))
+	require.Len(t, inactive, 1)
+	assert.ElementsMatch(t, []string{"idle", "gone"}, inactive["room1"])
+}
+
 func TestRoomsStateMem_AddRoomTrack(t *testing.T) {
 	r := newTestMemState()
 	now := time.Now()
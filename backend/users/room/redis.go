@@ -7,6 +7,8 @@ import (
 	"strings"
 	"time"
 
+	goredis "github.com/redis/go-redis/v9"
+
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/redis"
 	"github.com/imtaco/audio-rtc-exp/users"
@@ -102,13 +104,88 @@ func (r *roomStateRedis) setUserStatus(ctx context.Context,
 	return nil
 }
 
+// setUserStatuses writes the statuses of several users in one pipeline
+func (r *roomStateRedis) setUserStatuses(ctx context.Context, updates []users.StatusUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := r.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, update := range updates {
+			key := r.userStatusKey(update.RoomID)
+			if update.User.Status == "" {
+				pipe.HDel(ctx, key, statusField(update.UserID))
+				continue
+			}
+			pipe.HSet(ctx, key, statusField(update.UserID), packStatus(update.User))
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to set user statuses: %w", err)
+	}
+	return nil
+}
+
+func (r *roomStateRedis) setUserQuality(ctx context.Context, roomID, userID string, quality int) error {
+	if err := r.client.HSet(ctx, r.userStatusKey(roomID), qualityField(userID), quality); err != nil {
+		return fmt.Errorf("failed to set user quality: %w", err)
+	}
+	return nil
+}
+
+func (r *roomStateRedis) setUserHand(ctx context.Context, roomID, userID string, ts time.Time) error {
+	if ts.IsZero() {
+		if err := r.client.HDel(ctx, r.userStatusKey(roomID), handField(userID)); err != nil {
+			return fmt.Errorf("failed to delete user hand: %w", err)
+		}
+		return nil
+	}
+	if err := r.client.HSet(ctx, r.userStatusKey(roomID), handField(userID), ts.UnixMilli()); err != nil {
+		return fmt.Errorf("failed to set user hand: %w", err)
+	}
+	return nil
+}
+
+// grantFloor moves the floor from prev to userID, whose hand is lowered
+func (r *roomStateRedis) grantFloor(ctx context.Context, roomID, userID, prev string) error {
+	if prev != "" {
+		if err := r.client.HDel(ctx, r.userStatusKey(roomID), floorField(prev)); err != nil {
+			return fmt.Errorf("failed to delete previous floor: %w", err)
+		}
+	}
+	if err := r.client.HDel(ctx, r.userStatusKey(roomID), handField(userID)); err != nil {
+		return fmt.Errorf("failed to delete user hand: %w", err)
+	}
+	if err := r.client.HSet(ctx, r.userStatusKey(roomID), floorField(userID), 1); err != nil {
+		return fmt.Errorf("failed to set user floor: %w", err)
+	}
+	return nil
+}
+
+// setPublish grants or revokes the publish slot of userID, whose hand is lowered on grant
+func (r *roomStateRedis) setPublish(ctx context.Context, roomID, userID string, granted bool) error {
+	if !granted {
+		if err := r.client.HDel(ctx, r.userStatusKey(roomID), publishField(userID)); err != nil {
+			return fmt.Errorf("failed to delete user publish: %w", err)
+		}
+		return nil
+	}
+	if err := r.client.HDel(ctx, r.userStatusKey(roomID), handField(userID)); err != nil {
+		return fmt.Errorf("failed to delete user hand: %w", err)
+	}
+	if err := r.client.HSet(ctx, r.userStatusKey(roomID), publishField(userID), 1); err != nil {
+		return fmt.Errorf("failed to set user publish: %w", err)
+	}
+	return nil
+}
+
 func (r *roomStateRedis) removeRoomUser(
 	ctx context.Context,
 	roomID string,
 	userID string,
 	lastUser bool,
 ) error {
-	if err := r.client.HDel(ctx, r.userStatusKey(roomID), statusField(userID), metaField(userID)); err != nil {
+	if err := r.client.HDel(ctx, r.userStatusKey(roomID), statusField(userID), metaField(userID), qualityField(userID),
+		handField(userID), floorField(userID), publishField(userID)); err != nil {
 		return fmt.Errorf("failed to delete user from Redis: %w", err)
 	}
 	if !lastUser {
@@ -137,6 +214,22 @@ func metaField(userID string) string {
 	return fmt.Sprintf("m:%s", userID)
 }
 
+func qualityField(userID string) string {
+	return fmt.Sprintf("q:%s", userID)
+}
+
+func handField(userID string) string {
+	return fmt.Sprintf("h:%s", userID)
+}
+
+func floorField(userID string) string {
+	return fmt.Sprintf("f:%s", userID)
+}
+
+func publishField(userID string) string {
+	return fmt.Sprintf("p:%s", userID)
+}
+
 // TODO: better serialization/deserialization
 func packStatus(u *users.User) string {
 	return fmt.Sprintf("%d,%s,%d", u.TS.Unix(), u.Status, u.Gen)
@@ -181,6 +274,28 @@ func parseUsersData(data map[string]string) map[string]*users.User {
 				// TODO: log error
 				continue
 			}
+		} else if strings.HasPrefix(field, "q:") {
+			// Quality field: q:<userId> -> <score>
+			userID := field[2:]
+			user := ensureUser(users, userID)
+			user.Quality, _ = strconv.Atoi(value)
+		} else if strings.HasPrefix(field, "h:") {
+			// Hand field: h:<userId> -> <raised at unix ms>
+			userID := field[2:]
+			user := ensureUser(users, userID)
+			if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+				user.HandRaisedAt = time.UnixMilli(ms)
+			}
+		} else if strings.HasPrefix(field, "f:") {
+			// Floor field: f:<userId> -> 1
+			userID := field[2:]
+			user := ensureUser(users, userID)
+			user.Floor = true
+		} else if strings.HasPrefix(field, "p:") {
+			// Publish field: p:<userId> -> 1
+			userID := field[2:]
+			user := ensureUser(users, userID)
+			user.Publish = true
 		}
 	}
 
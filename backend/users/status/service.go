@@ -8,21 +8,16 @@ import (
 	"github.com/redis/go-redis/v9"
 
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
-	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
-	redisRpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/redis"
 	"github.com/imtaco/audio-rtc-exp/internal/jwt"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
 	"github.com/imtaco/audio-rtc-exp/users"
 )
 
-const (
-	DefaultRequestTimeoutMS = 5000 // 5 seconds
-)
-
 type userServiceImpl struct {
 	redisClient *redis.Client
 	jwtAuth     jwt.Auth
-	peerSvc     jsonrpc.Peer[any]
+	rpcClient   streamrpc.Client
 	logger      *log.Logger
 }
 
@@ -31,31 +26,26 @@ func NewUserService(
 	jwtAuth jwt.Auth,
 	streamIn string,
 	streamOut string,
+	rpcCfg *streamrpc.ClientConfig,
 	logger *log.Logger,
 ) (users.UserService, error) {
 
-	peerSvc, err := redisRpc.NewPeer[any](
-		redisClient,
-		streamIn,
-		streamOut,
-		"", // request only, no consumer group needed
-		logger,
-	)
+	rpcClient, err := streamrpc.NewClient(redisClient, streamIn, streamOut, rpcCfg, logger)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create RPC peer: %w", err)
+		return nil, fmt.Errorf("failed to create RPC client: %w", err)
 	}
 
 	return &userServiceImpl{
 		redisClient: redisClient,
 		jwtAuth:     jwtAuth,
-		peerSvc:     peerSvc,
+		rpcClient:   rpcClient,
 		logger:      logger,
 	}, nil
 }
 
 func (s *userServiceImpl) Start(ctx context.Context) error {
-	s.logger.Info("Starting user service RPC peer")
-	return s.peerSvc.Open(ctx)
+	s.logger.Info("Starting user service RPC client")
+	return s.rpcClient.Open(ctx)
 }
 
 func (s *userServiceImpl) CreateUser(
@@ -63,6 +53,7 @@ func (s *userServiceImpl) CreateUser(
 	roomID string,
 	userID string,
 	role string,
+	locale string,
 ) (string, string, error) {
 	userCreatesRequested.Add(ctx, 1)
 
@@ -75,14 +66,14 @@ func (s *userServiceImpl) CreateUser(
 	}
 
 	rpcCallsStarted.Add(ctx, 1)
-	if err := s.peerSvc.Call(ctx, "createUser", request, nil); err != nil {
+	if _, err := users.MethodCreateUser.Call(ctx, s.rpcClient, request); err != nil {
 		rpcCallsFailed.Add(ctx, 1)
 		return "", "", fmt.Errorf("failed to create user: %w", err)
 	}
 	rpcCallsSuccess.Add(ctx, 1)
 
 	// Generate JWT token
-	token, err := s.jwtAuth.Sign(userID, roomID)
+	token, err := s.jwtAuth.SignWithLocale(userID, roomID, constants.UserRole(role), locale)
 	if err != nil {
 		tokensFailed.Add(ctx, 1)
 		return "", "", fmt.Errorf("failed to sign JWT: %w", err)
@@ -99,7 +90,7 @@ func (s *userServiceImpl) DeleteUser(ctx context.Context, roomID, userID string)
 		UserID: userID,
 		TS:     time.Now(),
 	}
-	if err := s.peerSvc.Call(ctx, "deleteUser", request, nil); err != nil {
+	if _, err := users.MethodDeleteUser.Call(ctx, s.rpcClient, request); err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
 	return nil
@@ -118,13 +109,119 @@ func (s *userServiceImpl) SetUserStatus(
 		Gen:    gen,
 		TS:     time.Now(),
 	}
-	return s.peerSvc.Notify(ctx, "setUserStatus", event)
+	return users.MethodSetUserStatus.Notify(ctx, s.rpcClient, event)
+}
+
+func (s *userServiceImpl) SetUserQuality(
+	ctx context.Context,
+	roomID, userID string,
+	quality int,
+) error {
+	event := &users.SetQualityUserRequest{
+		RoomID:  roomID,
+		UserID:  userID,
+		Quality: quality,
+		TS:      time.Now(),
+	}
+	return users.MethodSetUserQuality.Notify(ctx, s.rpcClient, event)
+}
+
+func (s *userServiceImpl) SetUserHand(
+	ctx context.Context,
+	roomID, userID string,
+	raised bool,
+) error {
+	request := &users.SetHandUserRequest{
+		RoomID: roomID,
+		UserID: userID,
+		Raised: raised,
+		TS:     time.Now(),
+	}
+	if _, err := users.MethodSetUserHand.Call(ctx, s.rpcClient, request); err != nil {
+		return fmt.Errorf("failed to set user hand: %w", err)
+	}
+	return nil
+}
+
+func (s *userServiceImpl) GrantFloor(
+	ctx context.Context,
+	roomID, userID string,
+	unmute bool,
+) (string, error) {
+	request := &users.GrantFloorRequest{
+		RoomID: roomID,
+		UserID: userID,
+		Unmute: unmute,
+		TS:     time.Now(),
+	}
+	resp, err := users.MethodGrantFloor.Call(ctx, s.rpcClient, request)
+	if err != nil {
+		return "", fmt.Errorf("failed to grant floor: %w", err)
+	}
+	return resp.UserID, nil
+}
+
+func (s *userServiceImpl) GrantPublish(
+	ctx context.Context,
+	roomID, userID string,
+	slots int,
+) (string, error) {
+	request := &users.SetPublishRequest{
+		RoomID:  roomID,
+		UserID:  userID,
+		Granted: true,
+		Slots:   slots,
+		TS:      time.Now(),
+	}
+	resp, err := users.MethodSetPublish.Call(ctx, s.rpcClient, request)
+	if err != nil {
+		return "", fmt.Errorf("failed to grant publish: %w", err)
+	}
+	return resp.UserID, nil
+}
+
+func (s *userServiceImpl) RevokePublish(
+	ctx context.Context,
+	roomID, userID string,
+) error {
+	request := &users.SetPublishRequest{
+		RoomID: roomID,
+		UserID: userID,
+		TS:     time.Now(),
+	}
+	if _, err := users.MethodSetPublish.Call(ctx, s.rpcClient, request); err != nil {
+		return fmt.Errorf("failed to revoke publish: %w", err)
+	}
+	return nil
 }
 
 func (s *userServiceImpl) GetActiveRoomUsers(
-	_ context.Context,
-	_ string,
+	ctx context.Context,
+	roomID string,
 ) ([]*users.RoomUser, error) {
-	//nolint:nilnil
-	return nil, nil
+	request := &users.GetRoomUsersRequest{
+		RoomID: roomID,
+		TS:     time.Now(),
+	}
+	resp, err := users.MethodGetRoomUsers.Call(ctx, s.rpcClient, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room users: %w", err)
+	}
+	return resp.Users, nil
+}
+
+func (s *userServiceImpl) QueuePosition(
+	ctx context.Context,
+	roomID, userID string,
+) (int, error) {
+	request := &users.QueuePositionRequest{
+		RoomID: roomID,
+		UserID: userID,
+		TS:     time.Now(),
+	}
+	resp, err := users.MethodQueuePosition.Call(ctx, s.rpcClient, request)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get queue position: %w", err)
+	}
+	return resp.Position, nil
 }
@@ -13,20 +13,21 @@ import (
 	"go.uber.org/mock/gomock"
 
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
-	jsonrpcmocks "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/mocks"
 	"github.com/imtaco/audio-rtc-exp/internal/jwt"
 	jwtmocks "github.com/imtaco/audio-rtc-exp/internal/jwt/mocks"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	rpcmocks "github.com/imtaco/audio-rtc-exp/internal/streamrpc/mocks"
 	"github.com/imtaco/audio-rtc-exp/users"
 )
 
 type UserServiceUnitTestSuite struct {
 	suite.Suite
-	ctrl     *gomock.Controller
-	mockPeer *jsonrpcmocks.MockPeer[any]
-	jwtAuth  jwt.Auth
-	svc      *userServiceImpl
-	ctx      context.Context
+	ctrl    *gomock.Controller
+	mockRPC *rpcmocks.MockClient
+	jwtAuth jwt.Auth
+	svc     *userServiceImpl
+	ctx     context.Context
 }
 
 func TestUserServiceUnitSuite(t *testing.T) {
@@ -35,14 +36,14 @@ func TestUserServiceUnitSuite(t *testing.T) {
 
 func (s *UserServiceUnitTestSuite) SetupTest() {
 	s.ctrl = gomock.NewController(s.T())
-	s.mockPeer = jsonrpcmocks.NewMockPeer[any](s.ctrl)
-	s.jwtAuth = jwt.NewAuth("test-secret-key")
+	s.mockRPC = rpcmocks.NewMockClient(s.ctrl)
+	s.jwtAuth = jwt.NewAuth(&jwt.Config{Secret: "test-secret-key", ExpiresIn: time.Hour})
 	s.ctx = context.Background()
 
 	s.svc = &userServiceImpl{
-		peerSvc: s.mockPeer,
-		jwtAuth: s.jwtAuth,
-		logger:  log.NewNop(),
+		rpcClient: s.mockRPC,
+		jwtAuth:   s.jwtAuth,
+		logger:    log.NewNop(),
 	}
 }
 
@@ -53,9 +54,9 @@ func (s *UserServiceUnitTestSuite) TearDownTest() {
 func (s *UserServiceUnitTestSuite) TestCreateUser() {
 	s.Run("create user successfully", func() {
 		// Expect Call with correct parameters
-		s.mockPeer.EXPECT().
-			Call(gomock.Any(), "createUser", gomock.Any(), nil).
-			DoAndReturn(func(_ context.Context, _ string, params, _ any) error {
+		s.mockRPC.EXPECT().
+			Call(gomock.Any(), "createUser", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, params, _ any, _ ...streamrpc.CallOption) error {
 				// Verify the request parameters
 				req, ok := params.(*users.CreateUserRequest)
 				s.Require().True(ok, "params should be *createUserRequest")
@@ -66,7 +67,7 @@ func (s *UserServiceUnitTestSuite) TestCreateUser() {
 				return nil
 			})
 
-		userID, token, err := s.svc.CreateUser(s.ctx, "room1", "user1", "anchor")
+		userID, token, err := s.svc.CreateUser(s.ctx, "room1", "user1", "anchor", "")
 
 		s.Require().NoError(err)
 		s.Equal("user1", userID)
@@ -77,14 +78,28 @@ func (s *UserServiceUnitTestSuite) TestCreateUser() {
 		s.Require().NoError(err)
 		s.Equal("user1", claims.UserID)
 		s.Equal("room1", claims.RoomID)
+		s.Empty(claims.Locale)
+	})
+
+	s.Run("locale is put in the token", func() {
+		s.mockRPC.EXPECT().
+			Call(gomock.Any(), "createUser", gomock.Any(), gomock.Any()).
+			Return(nil)
+
+		_, token, err := s.svc.CreateUser(s.ctx, "room1", "user1", "anchor", "zh-TW")
+		s.Require().NoError(err)
+
+		claims, err := s.jwtAuth.Verify(token)
+		s.Require().NoError(err)
+		s.Equal("zh-TW", claims.Locale)
 	})
 
 	s.Run("RPC call fails", func() {
-		s.mockPeer.EXPECT().
-			Call(gomock.Any(), "createUser", gomock.Any(), nil).
+		s.mockRPC.EXPECT().
+			Call(gomock.Any(), "createUser", gomock.Any(), gomock.Any()).
 			Return(context.DeadlineExceeded)
 
-		_, _, err := s.svc.CreateUser(s.ctx, "room2", "user2", "viewer")
+		_, _, err := s.svc.CreateUser(s.ctx, "room2", "user2", "viewer", "")
 
 		s.Require().Error(err)
 		s.Contains(err.Error(), "failed to create user")
@@ -93,9 +108,9 @@ func (s *UserServiceUnitTestSuite) TestCreateUser() {
 
 func (s *UserServiceUnitTestSuite) TestDeleteUser() {
 	s.Run("delete user successfully", func() {
-		s.mockPeer.EXPECT().
-			Call(gomock.Any(), "deleteUser", gomock.Any(), nil).
-			DoAndReturn(func(_ context.Context, _ string, params, _ any) error {
+		s.mockRPC.EXPECT().
+			Call(gomock.Any(), "deleteUser", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, params, _ any, _ ...streamrpc.CallOption) error {
 				req, ok := params.(*users.DeleteUserRequest)
 				s.Require().True(ok, "params should be *deleteUserRequest")
 				s.Equal("room1", req.RoomID)
@@ -110,8 +125,8 @@ func (s *UserServiceUnitTestSuite) TestDeleteUser() {
 	})
 
 	s.Run("RPC call fails", func() {
-		s.mockPeer.EXPECT().
-			Call(gomock.Any(), "deleteUser", gomock.Any(), nil).
+		s.mockRPC.EXPECT().
+			Call(gomock.Any(), "deleteUser", gomock.Any(), gomock.Any()).
 			Return(context.Canceled)
 
 		err := s.svc.DeleteUser(s.ctx, "room2", "user2")
@@ -121,9 +136,28 @@ func (s *UserServiceUnitTestSuite) TestDeleteUser() {
 	})
 }
 
+func (s *UserServiceUnitTestSuite) TestGetActiveRoomUsers() {
+	s.mockRPC.EXPECT().
+		Call(gomock.Any(), "getRoomUsers", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, params, result any, _ ...streamrpc.CallOption) error {
+			req, ok := params.(*users.GetRoomUsersRequest)
+			s.Require().True(ok, "params should be *GetRoomUsersRequest")
+			s.Equal("room1", req.RoomID)
+			result.(*users.GetRoomUsersResponse).Users = []*users.RoomUser{
+				{UserID: "user1", Role: "anchor", Status: constants.AnchorStatusOnAir},
+			}
+			return nil
+		})
+
+	members, err := s.svc.GetActiveRoomUsers(s.ctx, "room1")
+	s.Require().NoError(err)
+	s.Require().Len(members, 1)
+	s.Equal("user1", members[0].UserID)
+}
+
 func (s *UserServiceUnitTestSuite) TestSetUserStatus() {
 	s.Run("set status successfully", func() {
-		s.mockPeer.EXPECT().
+		s.mockRPC.EXPECT().
 			Notify(gomock.Any(), "setUserStatus", gomock.Any()).
 			DoAndReturn(func(_ context.Context, _ string, params any) error {
 				req, ok := params.(*users.SetStatusUserRequest)
@@ -142,7 +176,7 @@ func (s *UserServiceUnitTestSuite) TestSetUserStatus() {
 	})
 
 	s.Run("notify fails", func() {
-		s.mockPeer.EXPECT().
+		s.mockRPC.EXPECT().
 			Notify(gomock.Any(), "setUserStatus", gomock.Any()).
 			Return(context.DeadlineExceeded)
 
@@ -152,7 +186,7 @@ func (s *UserServiceUnitTestSuite) TestSetUserStatus() {
 	})
 
 	s.Run("empty status", func() {
-		s.mockPeer.EXPECT().
+		s.mockRPC.EXPECT().
 			Notify(gomock.Any(), "setUserStatus", gomock.Any()).
 			DoAndReturn(func(_ context.Context, _ string, params any) error {
 				req := params.(*users.SetStatusUserRequest)
@@ -168,9 +202,9 @@ func (s *UserServiceUnitTestSuite) TestSetUserStatus() {
 
 func (s *UserServiceUnitTestSuite) TestCreateUserRequestMarshaling() {
 	s.Run("request can be marshaled to JSON", func() {
-		s.mockPeer.EXPECT().
-			Call(gomock.Any(), "createUser", gomock.Any(), nil).
-			DoAndReturn(func(_ context.Context, _ string, params, _ any) error {
+		s.mockRPC.EXPECT().
+			Call(gomock.Any(), "createUser", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, params, _ any, _ ...streamrpc.CallOption) error {
 				// Verify the struct can be marshaled
 				data, err := json.Marshal(params)
 				s.Require().NoError(err)
@@ -180,7 +214,7 @@ func (s *UserServiceUnitTestSuite) TestCreateUserRequestMarshaling() {
 				return nil
 			})
 
-		_, _, err := s.svc.CreateUser(s.ctx, "room1", "user1", "anchor")
+		_, _, err := s.svc.CreateUser(s.ctx, "room1", "user1", "anchor", "")
 		s.Require().NoError(err)
 	})
 }
@@ -188,16 +222,16 @@ func (s *UserServiceUnitTestSuite) TestCreateUserRequestMarshaling() {
 func (s *UserServiceUnitTestSuite) TestMultipleOperations() {
 	s.Run("multiple operations in sequence", func() {
 		// Create user
-		s.mockPeer.EXPECT().
-			Call(gomock.Any(), "createUser", gomock.Any(), nil).
+		s.mockRPC.EXPECT().
+			Call(gomock.Any(), "createUser", gomock.Any(), gomock.Any()).
 			Return(nil)
 
-		_, token, err := s.svc.CreateUser(s.ctx, "room1", "user1", "anchor")
+		_, token, err := s.svc.CreateUser(s.ctx, "room1", "user1", "anchor", "")
 		s.Require().NoError(err)
 		s.NotEmpty(token)
 
 		// Set status
-		s.mockPeer.EXPECT().
+		s.mockRPC.EXPECT().
 			Notify(gomock.Any(), "setUserStatus", gomock.Any()).
 			Return(nil)
 
@@ -205,8 +239,8 @@ func (s *UserServiceUnitTestSuite) TestMultipleOperations() {
 		s.Require().NoError(err)
 
 		// Delete user
-		s.mockPeer.EXPECT().
-			Call(gomock.Any(), "deleteUser", gomock.Any(), nil).
+		s.mockRPC.EXPECT().
+			Call(gomock.Any(), "deleteUser", gomock.Any(), gomock.Any()).
 			Return(nil)
 
 		err = s.svc.DeleteUser(s.ctx, "room1", "user1")
@@ -224,20 +258,25 @@ func TestNewUserService(t *testing.T) {
 	})
 	defer redisClient.Close()
 
-	jwtAuth := jwt.NewAuth("test-secret-key")
+	jwtAuth := jwt.NewAuth(&jwt.Config{Secret: "test-secret-key", ExpiresIn: time.Hour})
 	logger := log.NewNop()
 
 	t.Run("create service successfully", func(t *testing.T) {
-		svc, err := NewUserService(redisClient, jwtAuth, "stream-in", "stream-out", logger)
+		svc, err := NewUserService(redisClient, jwtAuth, "stream-in", "stream-out", nil, logger)
 		assert.NoError(t, err)
 		assert.NotNil(t, svc)
 	})
 
-	t.Run("create service with empty stream names", func(t *testing.T) {
-		svc, err := NewUserService(redisClient, jwtAuth, "", "", logger)
+	t.Run("notify only without reply stream", func(t *testing.T) {
+		svc, err := NewUserService(redisClient, jwtAuth, "stream-in", "", nil, logger)
 		assert.NoError(t, err)
 		assert.NotNil(t, svc)
 	})
+
+	t.Run("request stream is required", func(t *testing.T) {
+		_, err := NewUserService(redisClient, jwtAuth, "", "", nil, logger)
+		assert.Error(t, err)
+	})
 }
 
 func TestUserIsActive(t *testing.T) {
@@ -291,26 +330,26 @@ func TestCreateUserJWTSigningFailure(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockPeer := jsonrpcmocks.NewMockPeer[any](ctrl)
+	mockRPC := rpcmocks.NewMockClient(ctrl)
 	mockJWT := jwtmocks.NewMockAuth(ctrl)
 	ctx := context.Background()
 
 	svc := &userServiceImpl{
-		peerSvc: mockPeer,
-		jwtAuth: mockJWT,
-		logger:  log.NewNop(),
+		rpcClient: mockRPC,
+		jwtAuth:   mockJWT,
+		logger:    log.NewNop(),
 	}
 
 	t.Run("JWT signing fails after successful RPC call", func(t *testing.T) {
-		mockPeer.EXPECT().
-			Call(gomock.Any(), "createUser", gomock.Any(), nil).
+		mockRPC.EXPECT().
+			Call(gomock.Any(), "createUser", gomock.Any(), gomock.Any()).
 			Return(nil)
 
 		mockJWT.EXPECT().
-			Sign("user1", "room1").
+			SignWithLocale("user1", "room1", constants.UserRoleAnchor, "").
 			Return("", assert.AnError)
 
-		_, _, err := svc.CreateUser(ctx, "room1", "user1", "anchor")
+		_, _, err := svc.CreateUser(ctx, "room1", "user1", "anchor", "")
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to sign JWT")
 	})
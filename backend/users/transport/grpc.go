@@ -0,0 +1,184 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/internal/validation"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+// The gRPC service carries JSON messages, the same the REST API and the request stream use,
+// so backends need no generated code. Clients call with the "json" content subtype, e.g.
+// grpc.CallContentSubtype(GRPCContentSubtype), or "application/grpc+json" from other languages.
+const (
+	GRPCServiceName    = "users.v1.UserStatus"
+	GRPCContentSubtype = "json"
+)
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                       { return GRPCContentSubtype }
+
+// userStatusServer is the handler type of the gRPC service
+type userStatusServer interface {
+	SetUserStatus(ctx context.Context, req *SetUserStatusMessage) (*struct{}, error)
+	GetRoomUsers(ctx context.Context, req *RoomMessage) (*users.GetRoomUsersResponse, error)
+	WatchRoomStatus(req *RoomMessage, stream grpc.ServerStream) error
+}
+
+var userStatusServiceDesc = grpc.ServiceDesc{
+	ServiceName: GRPCServiceName,
+	HandlerType: (*userStatusServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "SetUserStatus", Handler: unaryHandler("SetUserStatus", userStatusServer.SetUserStatus)},
+		{MethodName: "GetRoomUsers", Handler: unaryHandler("GetRoomUsers", userStatusServer.GetRoomUsers)},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "WatchRoomStatus", Handler: watchRoomStatusHandler, ServerStreams: true},
+	},
+}
+
+// unaryHandler adapts a typed unary method of the service to grpc.MethodDesc
+func unaryHandler[Req, Resp any](
+	name string,
+	method func(userStatusServer, context.Context, *Req) (*Resp, error),
+) func(any, context.Context, func(any) error, grpc.UnaryServerInterceptor) (any, error) {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		req := new(Req)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		handler := func(ctx context.Context, req any) (any, error) {
+			return method(srv.(userStatusServer), ctx, req.(*Req))
+		}
+		if interceptor == nil {
+			return handler(ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + GRPCServiceName + "/" + name}
+		return interceptor(ctx, req, info, handler)
+	}
+}
+
+func watchRoomStatusHandler(srv any, stream grpc.ServerStream) error {
+	req := new(RoomMessage)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(userStatusServer).WatchRoomStatus(req, stream)
+}
+
+// GRPCServer serves the user status over gRPC next to the REST router, for backends reacting
+// to status changes without consuming the Redis streams
+type GRPCServer struct {
+	userService users.UserService
+	watcher     users.RoomStatusWatcher
+	closing     chan struct{}
+	closeOnce   sync.Once
+	logger      *log.Logger
+}
+
+// NewGRPCServer creates the gRPC service, register it with Register
+func NewGRPCServer(userService users.UserService, watcher users.RoomStatusWatcher, logger *log.Logger) *GRPCServer {
+	return &GRPCServer{
+		userService: userService,
+		watcher:     watcher,
+		closing:     make(chan struct{}),
+		logger:      logger,
+	}
+}
+
+// Close ends the watch streams, they never end on their own and would hold a graceful stop
+// of the gRPC server
+func (s *GRPCServer) Close() {
+	s.closeOnce.Do(func() { close(s.closing) })
+}
+
+// Register registers the service on a gRPC server
+func (s *GRPCServer) Register(registrar grpc.ServiceRegistrar) {
+	registrar.RegisterService(&userStatusServiceDesc, s)
+}
+
+// SetUserStatus sets the status of an anchor as the gateways do
+func (s *GRPCServer) SetUserStatus(ctx context.Context, req *SetUserStatusMessage) (*struct{}, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	if err := s.userService.SetUserStatus(ctx, req.RoomID, req.UserID, req.Status, req.Gen); err != nil {
+		s.logger.Error("Failed to set user status", log.String("roomId", req.RoomID), log.Error(err))
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &struct{}{}, nil
+}
+
+// GetRoomUsers returns the active users of a room
+func (s *GRPCServer) GetRoomUsers(ctx context.Context, req *RoomMessage) (*users.GetRoomUsersResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	members, err := s.userService.GetActiveRoomUsers(ctx, req.RoomID)
+	if err != nil {
+		s.logger.Error("Failed to get room users", log.String("roomId", req.RoomID), log.Error(err))
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &users.GetRoomUsersResponse{Users: members}, nil
+}
+
+// WatchRoomStatus streams the active users of a room, first as they are then on every change,
+// until the client cancels
+func (s *GRPCServer) WatchRoomStatus(req *RoomMessage, stream grpc.ServerStream) error {
+	if err := validate(req); err != nil {
+		return err
+	}
+	ctx := stream.Context()
+
+	// watch before reading the current users so no change falls in between
+	statuses, cancel := s.watcher.WatchRoomStatus(req.RoomID)
+	defer cancel()
+
+	members, err := s.userService.GetActiveRoomUsers(ctx, req.RoomID)
+	if err != nil {
+		s.logger.Error("Failed to get room users", log.String("roomId", req.RoomID), log.Error(err))
+		return status.Error(codes.Internal, err.Error())
+	}
+	if err := stream.SendMsg(&users.NotifyRoomStatus{RoomID: req.RoomID, Members: members}); err != nil {
+		return err
+	}
+
+	s.logger.Debug("Watching room status", log.String("roomId", req.RoomID))
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.closing:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case st := <-statuses:
+			if err := stream.SendMsg(st); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// validate validates a request with the binding rules of the REST API
+func validate(req any) error {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		details, _ := json.Marshal(validation.FormatValidationError(err))
+		return status.Error(codes.InvalidArgument, "Validation failed: "+string(details))
+	}
+	return nil
+}
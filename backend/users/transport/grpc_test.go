@@ -0,0 +1,139 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gomock "go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/users"
+	usermocks "github.com/imtaco/audio-rtc-exp/users/mocks"
+)
+
+const testUserID = "5f0c2a4e-8a0b-4c1e-9d3f-2b7a6c1d0e9f"
+
+type fakeStatusWatcher struct {
+	statuses chan *users.NotifyRoomStatus
+	watched  chan string
+}
+
+func (f *fakeStatusWatcher) WatchRoomStatus(roomID string) (<-chan *users.NotifyRoomStatus, func()) {
+	f.watched <- roomID
+	return f.statuses, func() {}
+}
+
+func setupGRPC(t *testing.T) (*grpc.ClientConn, *GRPCServer, *usermocks.MockUserService, *fakeStatusWatcher) {
+	t.Helper()
+	mockUserService := usermocks.NewMockUserService(gomock.NewController(t))
+	watcher := &fakeStatusWatcher{
+		statuses: make(chan *users.NotifyRoomStatus, 1),
+		watched:  make(chan string, 1),
+	}
+	service := NewGRPCServer(mockUserService, watcher, log.NewTest(t))
+
+	lis := bufconn.Listen(1 << 16)
+	server := grpc.NewServer()
+	service.Register(server)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(GRPCContentSubtype)),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, service, mockUserService, watcher
+}
+
+func TestGRPCSetUserStatus(t *testing.T) {
+	conn, _, mockUserService, _ := setupGRPC(t)
+	ctx := context.Background()
+	method := "/" + GRPCServiceName + "/SetUserStatus"
+
+	t.Run("Success", func(t *testing.T) {
+		mockUserService.EXPECT().SetUserStatus(gomock.Any(), "room-1", testUserID, constants.AnchorStatusOnAir, int32(2)).Return(nil)
+
+		req := &SetUserStatusMessage{RoomID: "room-1", UserID: testUserID, Status: constants.AnchorStatusOnAir, Gen: 2}
+		require.NoError(t, conn.Invoke(ctx, method, req, &struct{}{}))
+	})
+
+	t.Run("Invalid status", func(t *testing.T) {
+		req := &SetUserStatusMessage{RoomID: "room-1", UserID: testUserID, Status: "dancing"}
+		err := conn.Invoke(ctx, method, req, &struct{}{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Service error", func(t *testing.T) {
+		mockUserService.EXPECT().SetUserStatus(gomock.Any(), "room-1", testUserID, constants.AnchorStatusIdle, int32(0)).
+			Return(errors.New("stream down"))
+
+		req := &SetUserStatusMessage{RoomID: "room-1", UserID: testUserID, Status: constants.AnchorStatusIdle}
+		err := conn.Invoke(ctx, method, req, &struct{}{})
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+}
+
+func TestGRPCGetRoomUsers(t *testing.T) {
+	conn, _, mockUserService, _ := setupGRPC(t)
+	ctx := context.Background()
+	method := "/" + GRPCServiceName + "/GetRoomUsers"
+
+	members := []*users.RoomUser{{UserID: testUserID, Role: "host", Status: constants.AnchorStatusOnAir}}
+	mockUserService.EXPECT().GetActiveRoomUsers(gomock.Any(), "room-1").Return(members, nil)
+
+	var resp users.GetRoomUsersResponse
+	require.NoError(t, conn.Invoke(ctx, method, &RoomMessage{RoomID: "room-1"}, &resp))
+	assert.Equal(t, members, resp.Users)
+
+	err := conn.Invoke(ctx, method, &RoomMessage{RoomID: "x"}, &resp)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCWatchRoomStatus(t *testing.T) {
+	conn, service, mockUserService, watcher := setupGRPC(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mockUserService.EXPECT().GetActiveRoomUsers(gomock.Any(), "room-1").Return([]*users.RoomUser{}, nil)
+
+	streamDesc := &grpc.StreamDesc{StreamName: "WatchRoomStatus", ServerStreams: true}
+	stream, err := conn.NewStream(ctx, streamDesc, "/"+GRPCServiceName+"/WatchRoomStatus")
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMsg(&RoomMessage{RoomID: "room-1"}))
+	require.NoError(t, stream.CloseSend())
+
+	// the current users first
+	var st users.NotifyRoomStatus
+	require.NoError(t, stream.RecvMsg(&st))
+	assert.Equal(t, "room-1", st.RoomID)
+	assert.Empty(t, st.Members)
+	assert.Equal(t, "room-1", <-watcher.watched)
+
+	// then every change
+	watcher.statuses <- &users.NotifyRoomStatus{
+		RoomID:  "room-1",
+		Members: []*users.RoomUser{{UserID: testUserID, Status: constants.AnchorStatusIdle}},
+	}
+	require.NoError(t, stream.RecvMsg(&st))
+	require.Len(t, st.Members, 1)
+	assert.Equal(t, testUserID, st.Members[0].UserID)
+
+	// closing the service ends the stream
+	service.Close()
+	err = stream.RecvMsg(&st)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
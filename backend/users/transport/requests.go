@@ -1,5 +1,11 @@
 package transport
 
+import (
+	"encoding/json"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+)
+
 // CreateUserURI represents the URI parameters for creating a user
 type CreateUserURI struct {
 	// RoomID: 3-32 characters (letters, numbers, hyphens, underscores) - required
@@ -10,6 +16,8 @@ type CreateUserURI struct {
 type CreateUserBody struct {
 	// Role: must be host, guest, or anchor (optional)
 	Role string `json:"role,omitempty" binding:"omitempty,role"`
+	// Locale: BCP 47 language the user prefers, e.g. zh-TW, put in its token (optional)
+	Locale string `json:"locale,omitempty" binding:"omitempty,locale"`
 }
 
 // DeleteUserURI represents the URI parameters for deleting a user
@@ -18,3 +26,45 @@ type DeleteUserURI struct {
 	// UserID: must be valid UUID v4 format
 	UserID string `uri:"userId" binding:"required,userid"`
 }
+
+// BroadcastURI names the room a notification is broadcast to
+type BroadcastURI struct {
+	RoomID string `uri:"roomId" binding:"required,roomid"`
+}
+
+// BroadcastBody is the notification broadcast to the anchors and hosts of a room
+type BroadcastBody struct {
+	// Event: name of the notification for clients, e.g. countdown - required
+	Event string `json:"event" binding:"required,max=64,printascii"`
+	// Payload: any JSON value up to 4 KiB, passed on as given (optional)
+	Payload json.RawMessage `json:"payload,omitempty" binding:"omitempty,max=4096"`
+}
+
+// RefreshBody carries a refresh token issued with the user
+type RefreshBody struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// ListConnLocksQuery filters the connection locks by user
+type ListConnLocksQuery struct {
+	UserID string `form:"userId" binding:"omitempty,userid"`
+}
+
+// ConnLocksURI names the user whose connection locks are released
+type ConnLocksURI struct {
+	UserID string `uri:"userId" binding:"required,userid"`
+}
+
+// SetUserStatusMessage sets the status of an anchor over gRPC
+type SetUserStatusMessage struct {
+	RoomID string                 `json:"roomId" binding:"required,roomid"`
+	UserID string                 `json:"userId" binding:"required,userid"`
+	Status constants.AnchorStatus `json:"status" binding:"required,oneof=onair idle left"`
+	// Gen is the generation of the status, as the gateways report it
+	Gen int32 `json:"gen"`
+}
+
+// RoomMessage names the room of gRPC calls
+type RoomMessage struct {
+	RoomID string `json:"roomId" binding:"required,roomid"`
+}
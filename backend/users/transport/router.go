@@ -2,38 +2,55 @@ package transport
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
+	"github.com/imtaco/audio-rtc-exp/internal/apispec"
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/internal/httputil"
 	"github.com/imtaco/audio-rtc-exp/internal/jwt"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
 	"github.com/imtaco/audio-rtc-exp/internal/validation"
 	"github.com/imtaco/audio-rtc-exp/users"
 )
 
 type Router struct {
-	userService users.UserService
-	jwtAuth     jwt.Auth
-	engine      *gin.Engine
-	logger      *log.Logger
+	userService   users.UserService
+	jwtAuth       jwt.Auth
+	refreshTokens users.RefreshTokens // nil disables refresh tokens
+	connLocks     users.ConnLocks
+	broadcaster   users.Broadcaster // nil disables room broadcasts
+	engine        *gin.Engine
+	spec          *apispec.Spec
+	logger        *log.Logger
 }
 
-func NewRouter(userService users.UserService, jwtAuth jwt.Auth, logger *log.Logger) *Router {
-	gin.SetMode(gin.ReleaseMode)
-	engine := gin.New()
-	engine.Use(gin.Recovery())
-
-	// Add OpenTelemetry middleware for automatic HTTP tracing
-	engine.Use(otelgin.Middleware("user-service"))
+// NewRouter creates the REST router, refreshTokens is nil when refresh tokens are disabled
+// and broadcaster nil when room broadcasts are
+func NewRouter(
+	userService users.UserService,
+	jwtAuth jwt.Auth,
+	refreshTokens users.RefreshTokens,
+	connLocks users.ConnLocks,
+	broadcaster users.Broadcaster,
+	logger *log.Logger,
+) *Router {
+	engine := httputil.NewEngine("user-service", logger)
 
 	r := &Router{
-		userService: userService,
-		jwtAuth:     jwtAuth,
-		engine:      engine,
-		logger:      logger,
+		userService:   userService,
+		jwtAuth:       jwtAuth,
+		refreshTokens: refreshTokens,
+		connLocks:     connLocks,
+		broadcaster:   broadcaster,
+		engine:        engine,
+		spec:          apispec.New("User Service API", "1.0.0"),
+		logger:        logger,
 	}
 
 	r.setupRoutes()
@@ -46,13 +63,118 @@ func (r *Router) Handler() http.Handler {
 
 func (r *Router) setupRoutes() {
 	// User management routes
-	r.engine.POST("/api/rooms/:roomId/users", r.createUser)
-	r.engine.DELETE("/api/rooms/:roomId/users/:userId", r.deleteUser)
+	r.handle(apispec.Route{
+		Method:  http.MethodPost,
+		Path:    "/api/rooms/:roomId/users",
+		Name:    "createUser",
+		Summary: "Create a room user and sign its access token, and a refresh token when enabled",
+		URI:     CreateUserURI{},
+		Body:    CreateUserBody{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"userID": "", "token": "", "route": "", "refreshToken": ""},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.createUser)
+	r.handle(apispec.Route{
+		Method:  http.MethodDelete,
+		Path:    "/api/rooms/:roomId/users/:userId",
+		Name:    "deleteUser",
+		Summary: "Delete a room user and revoke its refresh tokens",
+		URI:     DeleteUserURI{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusInternalServerError: gin.H{"error": ""},
+		},
+	}, r.deleteUser)
+
+	// Notifications of backends to the anchors of a room, e.g. countdowns and producer cues
+	if r.broadcaster != nil {
+		r.handle(apispec.Route{
+			Method:  http.MethodPost,
+			Path:    "/api/rooms/:roomId/broadcast",
+			Name:    "broadcastRoom",
+			Summary: "Broadcast a notification to the anchors and hosts connected to a room, with a host token of the room",
+			URI:     BroadcastURI{},
+			Body:    BroadcastBody{},
+			Responses: map[int]any{
+				http.StatusAccepted:            gin.H{"success": true},
+				http.StatusBadRequest:          apispec.ValidationErrorResponse,
+				http.StatusUnauthorized:        apispec.ErrorResponse,
+				http.StatusForbidden:           apispec.ErrorResponse,
+				http.StatusInternalServerError: apispec.ErrorResponse,
+			},
+		}, r.broadcastRoom)
+	}
+
+	if r.refreshTokens != nil {
+		r.handle(apispec.Route{
+			Method:  http.MethodPost,
+			Path:    "/auth/refresh",
+			Name:    "refreshToken",
+			Summary: "Exchange a refresh token for a new access token and refresh token",
+			Body:    RefreshBody{},
+			Responses: map[int]any{
+				http.StatusOK:                  gin.H{"token": "", "refreshToken": ""},
+				http.StatusBadRequest:          apispec.ValidationErrorResponse,
+				http.StatusUnauthorized:        apispec.ErrorResponse,
+				http.StatusInternalServerError: apispec.ErrorResponse,
+			},
+		}, r.refresh)
+		r.handle(apispec.Route{
+			Method:  http.MethodPost,
+			Path:    "/auth/logout",
+			Name:    "logout",
+			Summary: "Revoke a refresh token",
+			Body:    RefreshBody{},
+			Responses: map[int]any{
+				http.StatusOK:                  gin.H{},
+				http.StatusBadRequest:          apispec.ValidationErrorResponse,
+				http.StatusInternalServerError: apispec.ErrorResponse,
+			},
+		}, r.logout)
+	}
+
+	// Connection locks held by the gateways, to unstick users of crashed gateways
+	r.handle(apispec.Route{
+		Method:  http.MethodGet,
+		Path:    "/api/conn-locks",
+		Name:    "listConnLocks",
+		Summary: "List the connection locks of the users, or of one user",
+		Query:   ListConnLocksQuery{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"locks": []users.ConnLock{}},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.listConnLocks)
+	r.handle(apispec.Route{
+		Method:  http.MethodDelete,
+		Path:    "/api/conn-locks/:userId",
+		Name:    "releaseConnLocks",
+		Summary: "Force-release the connection locks of a user",
+		URI:     ConnLocksURI{},
+		Responses: map[int]any{
+			http.StatusOK:                  gin.H{"released": true},
+			http.StatusBadRequest:          apispec.ValidationErrorResponse,
+			http.StatusInternalServerError: apispec.ErrorResponse,
+		},
+	}, r.releaseConnLocks)
+
+	// Machine-readable API description
+	r.engine.GET("/api/spec", gin.WrapH(r.spec))
 
 	// Health check
 	r.engine.GET("/health", r.healthCheck)
 }
 
+// handle registers the route to gin and publishes it in the API spec
+func (r *Router) handle(route apispec.Route, handler gin.HandlerFunc) {
+	r.spec.Add(route)
+	r.engine.Handle(route.Method, route.Path, handler)
+}
+
 func (r *Router) createUser(c *gin.Context) {
 	var uriParams CreateUserURI
 	var bodyParams CreateUserBody
@@ -82,7 +204,7 @@ func (r *Router) createUser(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Create user
-	_, token, err := r.userService.CreateUser(ctx, uriParams.RoomID, userID, bodyParams.Role)
+	_, token, err := r.userService.CreateUser(ctx, uriParams.RoomID, userID, bodyParams.Role, bodyParams.Locale)
 	if err != nil {
 		r.logger.Error("Failed to create user", log.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -98,10 +220,31 @@ func (r *Router) createUser(c *gin.Context) {
 		log.String("role", bodyParams.Role),
 	)
 
-	c.JSON(http.StatusOK, gin.H{
+	// clients pass route on the gateway URL, load balancers hashing on it keep the anchors of
+	// the room on one gateway
+	resp := gin.H{
 		"userID": userID,
 		"token":  token,
-	})
+		"route":  redisstream.RouteKey(uriParams.RoomID),
+	}
+	if r.refreshTokens != nil {
+		refreshToken, err := r.refreshTokens.Issue(ctx, &users.RefreshGrant{
+			RoomID: uriParams.RoomID,
+			UserID: userID,
+			Role:   bodyParams.Role,
+			Locale: bodyParams.Locale,
+		})
+		if err != nil {
+			r.logger.Error("Failed to issue refresh token", log.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		resp["refreshToken"] = refreshToken
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 func (r *Router) deleteUser(c *gin.Context) {
@@ -126,11 +269,212 @@ func (r *Router) deleteUser(c *gin.Context) {
 		return
 	}
 
+	if r.refreshTokens != nil {
+		if err := r.refreshTokens.RevokeUser(ctx, req.RoomID, req.UserID); err != nil {
+			r.logger.Error("Failed to revoke refresh tokens", log.String("userID", req.UserID), log.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
 	r.logger.Info("User deleted", log.String("userID", req.UserID))
 
 	c.JSON(http.StatusOK, gin.H{})
 }
 
+// broadcastRoom relays the notification to the gateways of the room, the caller holds a host
+// token of the room, e.g. of a user created for the producer console
+func (r *Router) broadcastRoom(c *gin.Context) {
+	var uriParams BroadcastURI
+	var bodyParams BroadcastBody
+
+	if err := c.ShouldBindUri(&uriParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Missing bearer token",
+		})
+		return
+	}
+	payload, err := r.jwtAuth.Verify(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Invalid token",
+		})
+		return
+	}
+	if payload.RoomID != uriParams.RoomID || !payload.HasRole(constants.UserRoleHost) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "Broadcasting needs a host token of the room",
+		})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&bodyParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+	err = r.broadcaster.Broadcast(c.Request.Context(), &users.NotifyBroadcast{
+		RoomID:  uriParams.RoomID,
+		Event:   bodyParams.Event,
+		Payload: bodyParams.Payload,
+		SentAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		r.logger.Error("Failed to broadcast to room", log.String("roomId", uriParams.RoomID), log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to broadcast to room",
+		})
+		return
+	}
+
+	r.logger.Info("Broadcast to room",
+		log.String("roomId", uriParams.RoomID),
+		log.String("userID", payload.UserID),
+		log.String("event", bodyParams.Event))
+	c.JSON(http.StatusAccepted, gin.H{"success": true})
+}
+
+func (r *Router) refresh(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var body RefreshBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	grant, refreshToken, err := r.refreshTokens.Rotate(ctx, body.RefreshToken)
+	if errors.Is(err, users.ErrInvalidRefreshToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		r.logger.Error("Failed to rotate refresh token", log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	token, err := r.jwtAuth.SignWithLocale(grant.UserID, grant.RoomID, constants.UserRole(grant.Role), grant.Locale)
+	if err != nil {
+		r.logger.Error("Failed to sign JWT", log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	r.logger.Debug("Token refreshed",
+		log.String("roomId", grant.RoomID),
+		log.String("userID", grant.UserID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":        token,
+		"refreshToken": refreshToken,
+	})
+}
+
+func (r *Router) logout(c *gin.Context) {
+	var body RefreshBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	if err := r.refreshTokens.Revoke(c.Request.Context(), body.RefreshToken); err != nil {
+		r.logger.Error("Failed to revoke refresh token", log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (r *Router) listConnLocks(c *gin.Context) {
+	var query ListConnLocksQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	locks, err := r.connLocks.List(c.Request.Context(), query.UserID)
+	if err != nil {
+		r.logger.Error("Failed to list connection locks", log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"locks": locks})
+}
+
+func (r *Router) releaseConnLocks(c *gin.Context) {
+	var req ConnLocksURI
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": validation.FormatValidationError(err),
+		})
+		return
+	}
+
+	released, err := r.connLocks.ForceRelease(c.Request.Context(), req.UserID)
+	if err != nil {
+		r.logger.Error("Failed to release connection locks", log.String("userID", req.UserID), log.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	r.logger.Warn("Connection locks force-released",
+		log.String("userID", req.UserID),
+		log.Bool("released", released))
+	c.JSON(http.StatusOK, gin.H{"released": released})
+}
+
 func (r *Router) healthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "ok",
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -14,8 +15,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	gomock "go.uber.org/mock/gomock"
 
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/jwt"
 	jwtmocks "github.com/imtaco/audio-rtc-exp/internal/jwt/mocks"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+	"github.com/imtaco/audio-rtc-exp/users"
 	usermocks "github.com/imtaco/audio-rtc-exp/users/mocks"
 )
 
@@ -24,7 +29,7 @@ func setupRouter(t *testing.T) (*Router, *usermocks.MockUserService, *jwtmocks.M
 	ctrl := gomock.NewController(t)
 	mockUserService := usermocks.NewMockUserService(ctrl)
 	mockJWTAuth := jwtmocks.NewMockAuth(ctrl)
-	router := NewRouter(mockUserService, mockJWTAuth, log.NewTest(t))
+	router := NewRouter(mockUserService, mockJWTAuth, nil, nil, nil, log.NewTest(t))
 	return router, mockUserService, mockJWTAuth
 }
 
@@ -51,7 +56,7 @@ func TestCreateUser(t *testing.T) {
 		role := "host"
 		expectedToken := "jwt-token"
 
-		mockUserService.EXPECT().CreateUser(gomock.Any(), roomID, gomock.Any(), role).DoAndReturn(func(_ context.Context, rID, uID, r string) (string, string, error) {
+		mockUserService.EXPECT().CreateUser(gomock.Any(), roomID, gomock.Any(), role, "").DoAndReturn(func(_ context.Context, rID, uID, r, _ string) (string, string, error) {
 			assert.Equal(t, roomID, rID)
 			assert.Equal(t, role, r)
 			assert.NotEmpty(t, uID) // UserID is generated inside handler
@@ -75,6 +80,7 @@ func TestCreateUser(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, expectedToken, response["token"])
 		assert.NotEmpty(t, response["userID"])
+		assert.Equal(t, redisstream.RouteKey(roomID), response["route"])
 	})
 
 	t.Run("ServiceError", func(t *testing.T) {
@@ -83,7 +89,7 @@ func TestCreateUser(t *testing.T) {
 		roomID := "test-room"
 		role := "host"
 
-		mockUserService.EXPECT().CreateUser(gomock.Any(), roomID, gomock.Any(), role).Return("", "", errors.New("service error"))
+		mockUserService.EXPECT().CreateUser(gomock.Any(), roomID, gomock.Any(), role, "").Return("", "", errors.New("service error"))
 
 		payload := map[string]string{
 			"role": role,
@@ -98,6 +104,25 @@ func TestCreateUser(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 	})
 
+	t.Run("Locale", func(t *testing.T) {
+		router, mockUserService, _ := setupRouter(t)
+
+		mockUserService.EXPECT().CreateUser(gomock.Any(), "test-room", gomock.Any(), "host", "zh-TW").
+			DoAndReturn(func(_ context.Context, _, uID, _, _ string) (string, string, error) {
+				return uID, "jwt-token", nil
+			})
+
+		w := postJSON(router, "/api/rooms/test-room/users", map[string]string{"role": "host", "locale": "zh-TW"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("InvalidLocale", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := postJSON(router, "/api/rooms/test-room/users", map[string]string{"role": "host", "locale": "not a locale"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
 	t.Run("ValidationError", func(t *testing.T) {
 		router, _, _ := setupRouter(t)
 
@@ -168,3 +193,266 @@ func TestDeleteUser(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
 }
+
+func setupRefreshRouter(t *testing.T) (*Router, *usermocks.MockUserService, *jwtmocks.MockAuth, *usermocks.MockRefreshTokens) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	mockUserService := usermocks.NewMockUserService(ctrl)
+	mockJWTAuth := jwtmocks.NewMockAuth(ctrl)
+	mockRefresh := usermocks.NewMockRefreshTokens(ctrl)
+	router := NewRouter(mockUserService, mockJWTAuth, mockRefresh, nil, nil, log.NewTest(t))
+	return router, mockUserService, mockJWTAuth, mockRefresh
+}
+
+func postJSON(router *Router, path string, body any) *httptest.ResponseRecorder {
+	jsonValue, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	router.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestRefreshTokens(t *testing.T) {
+	t.Run("DisabledRoutes", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := postJSON(router, "/auth/refresh", map[string]string{"refreshToken": "r1"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("CreateUserIssuesRefreshToken", func(t *testing.T) {
+		router, mockUserService, _, mockRefresh := setupRefreshRouter(t)
+
+		var userID string
+		mockUserService.EXPECT().CreateUser(gomock.Any(), "test-room", gomock.Any(), "anchor", "").
+			DoAndReturn(func(_ context.Context, _, uID, _, _ string) (string, string, error) {
+				userID = uID
+				return uID, "jwt-token", nil
+			})
+		mockRefresh.EXPECT().Issue(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, grant *users.RefreshGrant) (string, error) {
+				assert.Equal(t, &users.RefreshGrant{RoomID: "test-room", UserID: userID, Role: "anchor"}, grant)
+				return "refresh-1", nil
+			})
+
+		w := postJSON(router, "/api/rooms/test-room/users", map[string]string{"role": "anchor"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "jwt-token", response["token"])
+		assert.Equal(t, "refresh-1", response["refreshToken"])
+	})
+
+	t.Run("Refresh", func(t *testing.T) {
+		router, _, mockJWTAuth, mockRefresh := setupRefreshRouter(t)
+
+		mockRefresh.EXPECT().Rotate(gomock.Any(), "refresh-1").
+			Return(&users.RefreshGrant{RoomID: "test-room", UserID: "user-1", Role: "anchor"}, "refresh-2", nil)
+		mockJWTAuth.EXPECT().SignWithLocale("user-1", "test-room", constants.UserRoleAnchor, "").Return("jwt-2", nil)
+
+		w := postJSON(router, "/auth/refresh", map[string]string{"refreshToken": "refresh-1"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "jwt-2", response["token"])
+		assert.Equal(t, "refresh-2", response["refreshToken"])
+	})
+
+	t.Run("RefreshInvalidToken", func(t *testing.T) {
+		router, _, _, mockRefresh := setupRefreshRouter(t)
+
+		mockRefresh.EXPECT().Rotate(gomock.Any(), "used").Return(nil, "", users.ErrInvalidRefreshToken)
+
+		w := postJSON(router, "/auth/refresh", map[string]string{"refreshToken": "used"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON(router, "/auth/refresh", map[string]string{})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Logout", func(t *testing.T) {
+		router, _, _, mockRefresh := setupRefreshRouter(t)
+
+		mockRefresh.EXPECT().Revoke(gomock.Any(), "refresh-1").Return(nil)
+
+		w := postJSON(router, "/auth/logout", map[string]string{"refreshToken": "refresh-1"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("DeleteUserRevokes", func(t *testing.T) {
+		router, mockUserService, _, mockRefresh := setupRefreshRouter(t)
+		userID := uuid.New().String()
+
+		mockUserService.EXPECT().DeleteUser(gomock.Any(), "test-room", userID).Return(nil)
+		mockRefresh.EXPECT().RevokeUser(gomock.Any(), "test-room", userID).Return(nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/api/rooms/test-room/users/"+userID, nil)
+		router.Handler().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestConnLocks(t *testing.T) {
+	setup := func(t *testing.T) (*Router, *usermocks.MockConnLocks) {
+		gin.SetMode(gin.TestMode)
+		ctrl := gomock.NewController(t)
+		mockLocks := usermocks.NewMockConnLocks(ctrl)
+		router := NewRouter(usermocks.NewMockUserService(ctrl), jwtmocks.NewMockAuth(ctrl), nil, mockLocks, nil, log.NewTest(t))
+		return router, mockLocks
+	}
+	userID := uuid.New().String()
+
+	t.Run("List", func(t *testing.T) {
+		router, mockLocks := setup(t)
+
+		mockLocks.EXPECT().List(gomock.Any(), userID).Return([]*users.ConnLock{
+			{UserID: userID, ServerID: "gw1", ConnID: "conn1"},
+		}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/conn-locks?userId="+userID, nil)
+		router.Handler().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Locks []users.ConnLock `json:"locks"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Locks, 1)
+		assert.Equal(t, "gw1", response.Locks[0].ServerID)
+		assert.False(t, response.Locks[0].ServerAlive)
+	})
+
+	t.Run("ListInvalidUserID", func(t *testing.T) {
+		router, _ := setup(t)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/conn-locks?userId=invalid@id", nil)
+		router.Handler().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("ForceRelease", func(t *testing.T) {
+		router, mockLocks := setup(t)
+
+		mockLocks.EXPECT().ForceRelease(gomock.Any(), userID).Return(true, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/api/conn-locks/"+userID, nil)
+		router.Handler().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"released":true}`, w.Body.String())
+	})
+
+	t.Run("ForceReleaseError", func(t *testing.T) {
+		router, mockLocks := setup(t)
+
+		mockLocks.EXPECT().ForceRelease(gomock.Any(), userID).Return(false, errors.New("redis down"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/api/conn-locks/"+userID, nil)
+		router.Handler().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestBroadcastRoom(t *testing.T) {
+	setup := func(t *testing.T) (*Router, *jwtmocks.MockAuth, *usermocks.MockBroadcaster) {
+		gin.SetMode(gin.TestMode)
+		ctrl := gomock.NewController(t)
+		mockJWTAuth := jwtmocks.NewMockAuth(ctrl)
+		mockBroadcaster := usermocks.NewMockBroadcaster(ctrl)
+		router := NewRouter(usermocks.NewMockUserService(ctrl), mockJWTAuth, nil, nil, mockBroadcaster, log.NewTest(t))
+		return router, mockJWTAuth, mockBroadcaster
+	}
+	broadcast := func(router *Router, roomID, token, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/rooms/"+roomID+"/broadcast", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.Handler().ServeHTTP(w, req)
+		return w
+	}
+	hostToken := &jwt.Payload{UserID: "host1", RoomID: "test-room", Role: constants.UserRoleHost}
+
+	t.Run("Success", func(t *testing.T) {
+		router, mockJWTAuth, mockBroadcaster := setup(t)
+
+		mockJWTAuth.EXPECT().Verify("host-token").Return(hostToken, nil)
+		mockBroadcaster.EXPECT().Broadcast(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, notify *users.NotifyBroadcast) error {
+				assert.Equal(t, "test-room", notify.RoomID)
+				assert.Equal(t, "countdown", notify.Event)
+				assert.JSONEq(t, `{"seconds":10}`, string(notify.Payload))
+				assert.False(t, notify.SentAt.IsZero())
+				return nil
+			})
+
+		w := broadcast(router, "test-room", "host-token", `{"event":"countdown","payload":{"seconds":10}}`)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+	})
+
+	t.Run("MissingToken", func(t *testing.T) {
+		router, _, _ := setup(t)
+
+		w := broadcast(router, "test-room", "", `{"event":"countdown"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		router, mockJWTAuth, _ := setup(t)
+
+		mockJWTAuth.EXPECT().Verify("bad-token").Return(nil, errors.New("invalid"))
+
+		w := broadcast(router, "test-room", "bad-token", `{"event":"countdown"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("NotHostOfRoom", func(t *testing.T) {
+		router, mockJWTAuth, _ := setup(t)
+
+		mockJWTAuth.EXPECT().Verify("other-room").
+			Return(&jwt.Payload{UserID: "host1", RoomID: "other-room", Role: constants.UserRoleHost}, nil)
+		mockJWTAuth.EXPECT().Verify("anchor-token").
+			Return(&jwt.Payload{UserID: "anchor1", RoomID: "test-room", Role: constants.UserRoleAnchor}, nil)
+
+		w := broadcast(router, "test-room", "other-room", `{"event":"countdown"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = broadcast(router, "test-room", "anchor-token", `{"event":"countdown"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("InvalidBody", func(t *testing.T) {
+		router, mockJWTAuth, _ := setup(t)
+
+		mockJWTAuth.EXPECT().Verify("host-token").Return(hostToken, nil).Times(2)
+
+		w := broadcast(router, "test-room", "host-token", `{"payload":{}}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = broadcast(router, "test-room", "host-token", `{"event":"cue","payload":"`+strings.Repeat("x", 4096)+`"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("BroadcastError", func(t *testing.T) {
+		router, mockJWTAuth, mockBroadcaster := setup(t)
+
+		mockJWTAuth.EXPECT().Verify("host-token").Return(hostToken, nil)
+		mockBroadcaster.EXPECT().Broadcast(gomock.Any(), gomock.Any()).Return(errors.New("redis down"))
+
+		w := broadcast(router, "test-room", "host-token", `{"event":"countdown"}`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		router, _, _ := setupRouter(t)
+
+		w := broadcast(router, "test-room", "host-token", `{"event":"countdown"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -2,9 +2,12 @@ package users
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
 )
 
 const (
@@ -21,25 +24,189 @@ type RoomsState interface {
 	Rebuild(ctx context.Context) error
 	CreateUser(ctx context.Context, roomID, userID string, u *User) (bool, error)
 	UpdateUserStatus(ctx context.Context, roomID, userID string, u *User) (bool, error)
+	// UpdateUserStatuses applies the updates in order like UpdateUserStatus, writing them to Redis
+	// in one round trip. An update of an older generation than an earlier one of the same user
+	// is skipped. Returns whether each update was applied
+	UpdateUserStatuses(ctx context.Context, updates []StatusUpdate) ([]bool, error)
+	UpdateUserQuality(ctx context.Context, roomID, userID string, quality int) (bool, error)
+	// SetUserHand raises or lowers the hand of the user, ts orders the speaking queue
+	SetUserHand(ctx context.Context, roomID, userID string, raised bool, ts time.Time) (bool, error)
+	// GrantFloor gives the floor to the user, taken from the previous holder, and lowers their hand
+	GrantFloor(ctx context.Context, roomID, userID string) (bool, error)
+	// SetPublish grants or revokes the publish slot of the user in a push-to-talk room, granting
+	// lowers their hand
+	SetPublish(ctx context.Context, roomID, userID string, granted bool) (bool, error)
 	RemoveUser(ctx context.Context, roomID, userID string) (bool, error)
 	GetRoomUsers(ctx context.Context, roomID string) map[string]User
 	CheckTimeout(ctx context.Context) (roomIDs []string, err error)
+	// InactiveUsers returns the users of each room whose status has been idle or empty for longer than timeout
+	InactiveUsers(ctx context.Context, timeout time.Duration) map[string][]string
 }
 
 // UserService provides user management operations, requests are forwarded
 // to UserController for centralized processing.
 type UserService interface {
 	Start(ctx context.Context) error
-	CreateUser(ctx context.Context, roomID, userID, role string) (string, string, error)
+	// CreateUser creates the user and signs its token, carrying locale when not empty
+	CreateUser(ctx context.Context, roomID, userID, role, locale string) (string, string, error)
 	DeleteUser(ctx context.Context, roomID, userID string) error
 	SetUserStatus(ctx context.Context, roomID, userID string, status constants.AnchorStatus, gen int32) error
+	SetUserQuality(ctx context.Context, roomID, userID string, quality int) error
+	SetUserHand(ctx context.Context, roomID, userID string, raised bool) error
+	// GrantFloor gives the floor to userID, or the head of the speaking queue when empty,
+	// returns who got it
+	GrantFloor(ctx context.Context, roomID, userID string, unmute bool) (string, error)
+	// GrantPublish gives a publish slot of a push-to-talk room to userID, or the head of the
+	// speaking queue when empty, unless all slots are taken. Returns who got it
+	GrantPublish(ctx context.Context, roomID, userID string, slots int) (string, error)
+	// RevokePublish takes the publish slot back from userID
+	RevokePublish(ctx context.Context, roomID, userID string) error
 	GetActiveRoomUsers(ctx context.Context, roomID string) ([]*RoomUser, error)
+	// QueuePosition returns the place of userID in the join queue of the room from 1, 0 once
+	// admitted or when not queued. Asking keeps the user queued
+	QueuePosition(ctx context.Context, roomID, userID string) (int, error)
 }
 
+// RoomStatusWatcher follows the members of rooms as the controller broadcasts them to the gateways
+type RoomStatusWatcher interface {
+	// WatchRoomStatus returns the statuses of the room broadcast from now on, cancel stops
+	// watching. Statuses hold all active members, a watcher falling behind only gets the latest
+	WatchRoomStatus(roomID string) (statuses <-chan *NotifyRoomStatus, cancel func())
+}
+
+// ErrInvalidRefreshToken is returned for unknown, expired, revoked or already used refresh tokens
+const ErrInvalidRefreshToken errors.Code = "invalid refresh token"
+
+// RefreshTokens issues long-lived refresh tokens exchanged for short-lived access tokens.
+// Refresh tokens are single use, each exchange rotates them.
+type RefreshTokens interface {
+	Issue(ctx context.Context, grant *RefreshGrant) (string, error)
+	// Rotate consumes token and issues a new one for the same grant
+	Rotate(ctx context.Context, token string) (*RefreshGrant, string, error)
+	// Revoke revokes a single refresh token, on logout
+	Revoke(ctx context.Context, token string) error
+	// RevokeUser revokes all refresh tokens of a room user, when deleted
+	RevokeUser(ctx context.Context, roomID, userID string) error
+}
+
+// RefreshGrant is who a refresh token signs access tokens for
+type RefreshGrant struct {
+	RoomID string `json:"roomId"`
+	UserID string `json:"userId"`
+	Role   string `json:"role"`
+	Locale string `json:"locale,omitempty"`
+}
+
+// ConnLock is a connection lock a gateway holds for a user, see the ConnGuard of wsgateway
+type ConnLock struct {
+	UserID   string `json:"userId"`
+	ServerID string `json:"serverId"`
+	ConnID   string `json:"connId"`
+	// RoomID is only kept in locks taken under the kick_old policy
+	RoomID     string     `json:"roomId,omitempty"`
+	AcquiredAt *time.Time `json:"acquiredAt,omitempty"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	// ServerAlive is false once the gateway holding the lock stopped its heartbeat
+	ServerAlive bool `json:"serverAlive"`
+}
+
+// ConnLocks inspects the connection locks of the gateways, so operators can unstick users whose
+// lock is held by a crashed gateway before it expires
+type ConnLocks interface {
+	// List returns the locks of userID, or of every user when empty
+	List(ctx context.Context, userID string) ([]*ConnLock, error)
+	// ForceRelease drops every lock of the user, false when the user held none
+	ForceRelease(ctx context.Context, userID string) (bool, error)
+}
+
+// Broadcaster fans notifications of operators and backends out to the gateways of a room
+type Broadcaster interface {
+	Broadcast(ctx context.Context, notify *NotifyBroadcast) error
+}
+
+// Methods served by UserController over the request stream
+var (
+	MethodCreateUser     = streamrpc.Method[CreateUserRequest, streamrpc.Empty]("createUser")
+	MethodDeleteUser     = streamrpc.Method[DeleteUserRequest, streamrpc.Empty]("deleteUser")
+	MethodSetUserStatus  = streamrpc.Method[SetStatusUserRequest, streamrpc.Empty]("setUserStatus")
+	MethodSetUserQuality = streamrpc.Method[SetQualityUserRequest, streamrpc.Empty]("setUserQuality")
+	MethodSetUserHand    = streamrpc.Method[SetHandUserRequest, streamrpc.Empty]("setUserHand")
+	MethodGrantFloor     = streamrpc.Method[GrantFloorRequest, GrantFloorResponse]("grantFloor")
+	MethodSetPublish     = streamrpc.Method[SetPublishRequest, SetPublishResponse]("setPublish")
+	MethodGetRoomUsers   = streamrpc.Method[GetRoomUsersRequest, GetRoomUsersResponse]("getRoomUsers")
+	MethodQueuePosition  = streamrpc.Method[QueuePositionRequest, QueuePositionResponse]("queuePosition")
+)
+
 type RoomUser struct {
-	UserID string                 `json:"userId"`
-	Role   string                 `json:"role"`
-	Status constants.AnchorStatus `json:"status"`
+	UserID  string                 `json:"userId"`
+	Role    string                 `json:"role"`
+	Status  constants.AnchorStatus `json:"status"`
+	Quality int                    `json:"quality,omitempty"`
+	// HandRaisedAt orders the speaking queue, unset when the hand is down
+	HandRaisedAt *time.Time `json:"handRaisedAt,omitempty"`
+	Floor        bool       `json:"floor,omitempty"`
+	// Publish is set while the user holds a publish slot of a push-to-talk room
+	Publish bool `json:"publish,omitempty"`
+}
+
+// NotifyFloorGranted is relayed to the gateways of the room when a moderator grants the floor
+type NotifyFloorGranted struct {
+	RoomID string `json:"roomId"`
+	UserID string `json:"userId"`
+	// Unmute asks the gateway holding the user connection to unmute them in Janus
+	Unmute bool `json:"unmute"`
+}
+
+// NotifyPublish is relayed to the gateways of the room when a publish slot of a push-to-talk room
+// is granted or revoked, the gateway holding the user connection unmutes or mutes them in Janus
+type NotifyPublish struct {
+	RoomID  string `json:"roomId"`
+	UserID  string `json:"userId"`
+	Granted bool   `json:"granted"`
+}
+
+// NotifyJoinQueue is relayed to the gateways of the room when its join queue changes, the
+// gateways holding the connections of the users tell them their place
+type NotifyJoinQueue struct {
+	RoomID string `json:"roomId"`
+	// Queue holds the users waiting in turn, the first is at position 1
+	Queue []string `json:"queue"`
+	// Admitted holds the users that just got an anchor slot and may join
+	Admitted []string `json:"admitted,omitempty"`
+}
+
+// NotifyUserEvicted is relayed to the gateways of the room when an inactive anchor is moved to left,
+// the gateway holding the user connection releases its Janus handle
+type NotifyUserEvicted struct {
+	RoomID string `json:"roomId"`
+	UserID string `json:"userId"`
+}
+
+// Participant events of NotifyParticipant
+const (
+	ParticipantJoined  = "joined"
+	ParticipantLeft    = "left"
+	ParticipantMuted   = "muted"
+	ParticipantUnmuted = "unmuted"
+)
+
+// NotifyParticipant is relayed to the gateways of the room by the Janus manager when a participant
+// joins or leaves the Janus room or changes its mute state there
+type NotifyParticipant struct {
+	RoomID string `json:"roomId"`
+	UserID string `json:"userId"`
+	Event  string `json:"event"`
+}
+
+// NotifyBroadcast is relayed to the gateways of the room when a backend broadcasts to it, e.g.
+// a countdown or a producer cue, the gateways pass it on to the anchors and hosts of the room
+type NotifyBroadcast struct {
+	RoomID string `json:"roomId"`
+	// Event names the notification for clients, e.g. countdown
+	Event string `json:"event"`
+	// Payload is passed on as given, omitted when empty
+	Payload json.RawMessage `json:"payload,omitempty"`
+	SentAt  time.Time       `json:"sentAt"`
 }
 
 type NotifyRoomStatus struct {
@@ -48,10 +215,24 @@ type NotifyRoomStatus struct {
 }
 
 type User struct {
-	Role   string
-	Status constants.AnchorStatus
-	TS     time.Time
-	Gen    int32
+	Role    string
+	Status  constants.AnchorStatus
+	TS      time.Time
+	Gen     int32
+	Quality int // network quality score, 0 when not reported
+	// HandRaisedAt is when the user asked to speak, zero when the hand is down
+	HandRaisedAt time.Time
+	Floor        bool // granted the floor by a moderator
+	Publish      bool // holds a publish slot of a push-to-talk room
+	// Since is when Status last changed, in memory only
+	Since time.Time
+}
+
+// StatusUpdate is a status of a user, one of a batch
+type StatusUpdate struct {
+	RoomID string
+	UserID string
+	User   *User
 }
 
 func (u *User) IsActive() bool {
@@ -78,3 +259,63 @@ type SetStatusUserRequest struct {
 	Gen    int32                  `json:"gen"`
 	TS     time.Time              `json:"ts"`
 }
+
+type SetQualityUserRequest struct {
+	RoomID  string    `json:"roomId"`
+	UserID  string    `json:"userId"`
+	Quality int       `json:"quality"`
+	TS      time.Time `json:"ts"`
+}
+
+type SetHandUserRequest struct {
+	RoomID string    `json:"roomId"`
+	UserID string    `json:"userId"`
+	Raised bool      `json:"raised"`
+	TS     time.Time `json:"ts"`
+}
+
+type GrantFloorRequest struct {
+	RoomID string    `json:"roomId"`
+	UserID string    `json:"userId"` // empty grants the head of the speaking queue
+	Unmute bool      `json:"unmute"`
+	TS     time.Time `json:"ts"`
+}
+
+type GrantFloorResponse struct {
+	UserID string `json:"userId"`
+}
+
+type SetPublishRequest struct {
+	RoomID string `json:"roomId"`
+	UserID string `json:"userId"` // empty grants the head of the speaking queue
+	// Granted grants a slot when set, revokes it otherwise
+	Granted bool `json:"granted"`
+	// Slots is how many users of the room may hold a publish slot at once
+	Slots int       `json:"slots"`
+	TS    time.Time `json:"ts"`
+}
+
+type SetPublishResponse struct {
+	UserID string `json:"userId"`
+}
+
+type GetRoomUsersRequest struct {
+	RoomID string    `json:"roomId"`
+	TS     time.Time `json:"ts"`
+}
+
+// GetRoomUsersResponse holds the active users of the room sorted by user ID
+type GetRoomUsersResponse struct {
+	Users []*RoomUser `json:"users"`
+}
+
+type QueuePositionRequest struct {
+	RoomID string    `json:"roomId"`
+	UserID string    `json:"userId"`
+	TS     time.Time `json:"ts"`
+}
+
+type QueuePositionResponse struct {
+	// Position is the place in the join queue from 1, 0 when not queued
+	Position int `json:"position"`
+}
@@ -8,13 +8,20 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/imtaco/audio-rtc-exp/internal/config"
+	"github.com/imtaco/audio-rtc-exp/internal/config/secret"
 	"github.com/imtaco/audio-rtc-exp/internal/etcd"
 	"github.com/imtaco/audio-rtc-exp/internal/httputil"
+	"github.com/imtaco/audio-rtc-exp/internal/i18n"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc/longpoll"
 	wsrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/websocket"
 	"github.com/imtaco/audio-rtc-exp/internal/jwt"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	"github.com/imtaco/audio-rtc-exp/internal/otel"
 	"github.com/imtaco/audio-rtc-exp/internal/redis"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+	"github.com/imtaco/audio-rtc-exp/internal/streamrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/tlsid"
 	"github.com/imtaco/audio-rtc-exp/internal/workflow"
 	"github.com/imtaco/audio-rtc-exp/users/status"
 	"github.com/imtaco/audio-rtc-exp/wsgateway/janusproxy"
@@ -24,9 +31,12 @@ import (
 type Config struct {
 	App    config.App      `mapstructure:"app"`
 	WSHttp httputil.Config `mapstructure:"ws_http"`
-	Redis  redis.Config    `mapstructure:"redis"`
-	Etcd   etcd.Config     `mapstructure:"etcd"`
-	Otel   otel.Config     `mapstructure:"otel"`
+	// AdminHTTP serves internal endpoints, it must not be exposed publicly
+	AdminHTTP httputil.Config `mapstructure:"admin_http"`
+	MTLS      tlsid.Config    `mapstructure:"mtls"`
+	Redis     redis.Config    `mapstructure:"redis"`
+	Etcd      etcd.Config     `mapstructure:"etcd"`
+	Otel      otel.Config     `mapstructure:"otel"`
 
 	RedisUserSvcPrefix   string `mapstructure:"redis_user_svc_prefix"`
 	EtcdPrefixRoomStore  string `mapstructure:"etcd_prefix_room_store"`
@@ -36,14 +46,36 @@ type Config struct {
 	RedisReplyStream    string `mapstructure:"redis_reply_stream"`
 	RedisWSNotifyStream string `mapstructure:"redis_ws_notify_stream"`
 
-	JWTSecret    string `mapstructure:"jwt_secret"`
-	JWTExpiresIn string `mapstructure:"jwt_expires_in"`
+	WSNotify       redisstream.PartitionConfig `mapstructure:"ws_notify"`
+	WSNotifyBuffer redisstream.BufferConfig    `mapstructure:"ws_notify_buffer"`
+
+	UserRPC streamrpc.ClientConfig `mapstructure:"user_rpc"`
+
+	JWT     jwt.Config    `mapstructure:"jwt"`
+	Secrets secret.Config `mapstructure:"secrets"`
 
 	JanusPort          string `mapstructure:"janus_port"`
 	JanusTokenKey      string `mapstructure:"janus_token_key"`
 	JanusInstCacheSize int    `mapstructure:"janus_inst_cache_size"`
 
+	JanusPool    janusproxy.PoolConfig    `mapstructure:"janus_pool"`
+	JanusBreaker janusproxy.BreakerConfig `mapstructure:"janus_breaker"`
+
 	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	WSAdvURL       string   `mapstructure:"ws_adv_url"`
+
+	RPCLog     jsonrpc.RequestLogConfig `mapstructure:"rpc_log"`
+	RPCReplay  jsonrpc.ReplayConfig     `mapstructure:"rpc_replay"`
+	RPCMetrics signal.RPCMetricsConfig  `mapstructure:"rpc_metrics"`
+
+	PinThrottle signal.PinThrottleConfig `mapstructure:"pin_throttle"`
+	ConnGuard   signal.ConnGuardConfig   `mapstructure:"conn_guard"`
+	Reconnect   signal.ReconnectConfig   `mapstructure:"reconnect"`
+	LiveEnding  signal.LiveEndingConfig  `mapstructure:"live_ending"`
+	RoomsAPI    signal.RoomsAPIConfig    `mapstructure:"rooms_api"`
+	ClientError signal.ClientErrorConfig `mapstructure:"client_error"`
+	Messages    i18n.Config              `mapstructure:"messages"`
+	LongPoll    longpoll.Config          `mapstructure:"long_poll"`
 }
 
 func loadConfig() (*Config, error) {
@@ -55,20 +87,41 @@ func loadConfig() (*Config, error) {
 		v.SetDefault("redis_reply_stream", "rtcus:user-status-reply-stream")
 		v.SetDefault("redis_ws_notify_stream", "rtcus:user-status-ws-stream")
 		v.SetDefault("janus_port", "8088")
-		v.SetDefault("jwt_secret", "MY-secret-key-change-in-production")
-		v.SetDefault("jwt_expires_in", "1h")
 		v.SetDefault("janus_token_key", "my-janus-token-key-32bytes!!!!!!")
 		v.SetDefault("janus_inst_cache_size", 2000)
 		v.SetDefault("allowed_origins", []string{"*"})
+		v.SetDefault("ws_adv_url", "ws://localhost:8081/ws")
 
 		config.Setup(v, "app")
+		jwt.Setup(v, "jwt")
+		secret.Setup(v, "secrets")
 		redis.Setup(v, "redis")
 		etcd.Setup(v, "etcd")
 		otel.Setup(v, "otel")
 		httputil.Setup(v, "ws_http")
+		httputil.Setup(v, "admin_http")
+		tlsid.Setup(v, "mtls")
+		v.SetDefault("mtls.service", "wsgateway")
+		jsonrpc.SetupRequestLog(v, "rpc_log")
+		jsonrpc.SetupReplay(v, "rpc_replay")
+		signal.SetupRPCMetrics(v, "rpc_metrics")
+		signal.SetupPinThrottle(v, "pin_throttle")
+		signal.SetupConnGuard(v, "conn_guard")
+		signal.SetupReconnect(v, "reconnect")
+		signal.SetupLiveEnding(v, "live_ending")
+		signal.SetupRoomsAPI(v, "rooms_api")
+		signal.SetupClientErrors(v, "client_error")
+		i18n.Setup(v, "messages")
+		longpoll.Setup(v, "long_poll")
+		redisstream.SetupPartition(v, "ws_notify")
+		redisstream.SetupBuffer(v, "ws_notify_buffer")
+		streamrpc.Setup(v, "user_rpc")
+		janusproxy.SetupPool(v, "janus_pool")
+		janusproxy.SetupBreaker(v, "janus_breaker")
 
 		// override default addrs to ease testing
 		v.SetDefault("ws_http.addr", "0.0.0.0:8081")
+		v.SetDefault("admin_http.addr", "127.0.0.1:8082")
 	})
 }
 
@@ -98,20 +151,45 @@ func main() {
 	if err != nil {
 		logger.Fatal("Failed to create etcd client", log.Error(err))
 	}
+	if config.App.LogLevelsKey != "" {
+		go etcd.WatchLogLevels(ctx, etcdClient, config.App.LogLevelsKey, logger.Levels(), logger.Module("LogLevels"))
+	}
+
+	identity, err := tlsid.New(ctx, &config.MTLS, etcdClient, logger.Module("TLSID"))
+	if err != nil {
+		logger.Fatal("Failed to load TLS identity", log.Error(err))
+	}
+	roomsTLS := tlsid.ClientConfig(identity, "rooms")
 
 	redisClient := redis.NewClient(&config.Redis)
 	if err := redis.Ping(redisClient); err != nil {
 		logger.Fatal("Failed to connect to Redis", log.Error(err))
 	}
 
-	jwtAuth := jwt.NewAuth(config.JWTSecret)
+	// secrets are resolved from their references, kept out of the config
+	secrets := secret.NewResolver(&config.Secrets, logger.Module("Secrets"))
+	jwtSecret, err := secrets.Get(ctx, config.JWT.Secret)
+	if err != nil {
+		logger.Fatal("Failed to resolve JWT secret", log.Error(err))
+	}
+	jwtAuth := jwt.NewAuthWithSecret(&config.JWT, jwtSecret)
 
+	catalog, err := i18n.New(&config.Messages, logger.Module("Messages"))
+	if err != nil {
+		logger.Fatal("Failed to load message catalog", log.Error(err))
+	}
+
+	serverID := uuid.New().String()
+	// instances failing calls are reported to rooms placement, with the rooms API of endRoom
 	janusProxy, err := janusproxy.NewProxy(
 		etcdClient,
 		config.EtcdPrefixRoomStore,
 		config.EtcdPrefixJanusStore,
 		config.JanusInstCacheSize,
 		config.JanusPort,
+		&config.JanusPool,
+		&config.JanusBreaker,
+		janusproxy.NewDegradedReporter(config.RoomsAPI.URL, config.RoomsAPI.Token, roomsTLS, config.RoomsAPI.Timeout, serverID),
 		logger.Module("JanusProxy"),
 	)
 	if err != nil {
@@ -123,27 +201,37 @@ func main() {
 		jwtAuth,
 		config.RedisReqStream,
 		config.RedisReplyStream,
+		&config.UserRPC,
 		logger.Module("UserSvc"),
 	)
 	if err != nil {
 		logger.Fatal("Failed to create User Service", log.Error(err))
 	}
 
+	connGuard := signal.NewConnGuard(
+		redisClient,
+		config.RedisUserSvcPrefix,
+		serverID,
+		config.WSAdvURL,
+		&config.ConnGuard,
+		logger.Module("ConnLock"),
+	)
 	connMgr, err := signal.NewWSConnMgr(
 		redisClient,
 		config.RedisWSNotifyStream,
+		config.WSNotify.Partitions,
+		&config.WSNotifyBuffer,
+		connGuard,
 		logger.Module("ConnMgr"),
 	)
 	if err != nil {
 		logger.Fatal("Failed to create WS Client Manager", log.Error(err))
 	}
-
-	serverID := uuid.New().String()
-	connGuard := signal.NewConnGuard(
+	pinGuard := signal.NewPinGuard(
 		redisClient,
 		config.RedisUserSvcPrefix,
-		serverID,
-		logger.Module("ConnLock"),
+		&config.PinThrottle,
+		logger.Module("PinGuard"),
 	)
 	hook := signal.NewWSHook(
 		connMgr,
@@ -151,7 +239,15 @@ func main() {
 		jwtAuth,
 		logger.Module("WSHook"),
 	)
-	janusTokenCodec, err := janusproxy.NewJanusTokenCodec([]byte(config.JanusTokenKey))
+	errorReporter, err := signal.NewClientErrorReporter(redisClient, &config.ClientError, logger.Module("ClientError"))
+	if err != nil {
+		logger.Fatal("Failed to create client error reporter", log.Error(err))
+	}
+	janusTokenKey, err := secrets.Get(ctx, config.JanusTokenKey)
+	if err != nil {
+		logger.Fatal("Failed to resolve Janus token key", log.Error(err))
+	}
+	janusTokenCodec, err := janusproxy.NewJanusTokenCodecWithSecret(janusTokenKey)
 	if err != nil {
 		logger.Fatal("Failed to create Janus token codec", log.Error(err))
 	}
@@ -167,53 +263,125 @@ func main() {
 		connMgr,
 		userService,
 		connGuard,
+		pinGuard,
 		jwtAuth,
+		signal.NewRoomsAPIClient(&config.RoomsAPI, roomsTLS, logger.Module("RoomsAPI")),
+		errorReporter,
+		catalog,
+		&config.RPCLog,
+		&config.RPCReplay,
+		&config.RPCMetrics,
+		&config.Reconnect,
 		logger.Module("Signal"),
 	)
 
-	// Start components
-	if err := janusProxy.Open(ctx); err != nil {
-		logger.Fatal("Failed to initialize Janus proxy", log.Error(err))
-	}
-	if err := connMgr.Start(ctx); err != nil {
-		logger.Fatal("Failed to start WS Client Manager", log.Error(err))
+	liveEndingNotifier := signal.NewLiveEndingNotifier(
+		&config.LiveEnding,
+		connMgr,
+		janusProxy,
+		catalog,
+		logger.Module("LiveEnding"),
+	)
+
+	lc := workflow.NewLifecycle(logger.Module("Lifecycle"))
+	lc.Add(workflow.Component{Name: "otel", Stop: otelShutdown})
+	lc.Add(workflow.Component{
+		Name:  "secrets",
+		Start: secrets.Start,
+		Stop:  workflow.Stopper(secrets.Stop),
+	})
+	lc.Add(workflow.Component{
+		Name: "etcd",
+		Stop: workflow.Closer(etcdClient.Close),
+	})
+	if identity != nil {
+		lc.Add(workflow.Component{
+			Name:  "tlsid",
+			Start: identity.Start,
+			Stop:  workflow.Stopper(identity.Stop),
+		})
 	}
-	if err := signalServer.Open(ctx); err != nil {
-		logger.Fatal("Failed to open Signal Server", log.Error(err))
+	lc.Add(workflow.Component{
+		Name: "redis",
+		Stop: workflow.Closer(redisClient.Close),
+	})
+	lc.Add(workflow.Component{
+		Name:      "janusProxy",
+		DependsOn: []string{"etcd"},
+		Start:     janusProxy.Open,
+		Stop:      workflow.Closer(janusProxy.Close),
+	})
+	lc.Add(workflow.Component{
+		Name:      "connMgr",
+		DependsOn: []string{"redis"},
+		Start:     connMgr.Start,
+		Stop:      connMgr.Stop,
+	})
+	signalDeps := []string{"janusProxy", "connMgr"}
+	if errorReporter != nil {
+		lc.Add(workflow.Component{
+			Name:      "clientError",
+			DependsOn: []string{"redis"},
+			Start:     errorReporter.Start,
+			Stop:      workflow.Closer(errorReporter.Stop),
+		})
+		signalDeps = append(signalDeps, "clientError")
 	}
+	lc.Add(workflow.Component{
+		Name:      "signal",
+		DependsOn: signalDeps,
+		Start:     signalServer.Open,
+		Stop:      workflow.Closer(signalServer.Close),
+	})
+	lc.Add(workflow.Component{
+		Name:      "liveEnding",
+		DependsOn: []string{"signal"},
+		Start:     liveEndingNotifier.Start,
+		Stop:      workflow.Stopper(liveEndingNotifier.Stop),
+	})
 
 	wsMux := http.NewServeMux()
 	wsMux.HandleFunc("/ws", wsRPCServer.HandleWebSocket)
+	wsDeps := []string{"liveEnding"}
+	// clients whose network blocks WebSockets fall back to long polling, with the same
+	// methods and hooks
+	if config.LongPoll.Enabled {
+		longPollServer := longpoll.NewServer(
+			wsRPCServer.Handler,
+			hook,
+			config.AllowedOrigins,
+			&config.LongPoll,
+			logger.Module("LongPoll"),
+		)
+		longPollServer.Register(wsMux, "/poll")
+		lc.Add(workflow.Component{
+			Name:      "longPoll",
+			DependsOn: []string{"signal"},
+			Start:     longPollServer.Start,
+			Stop:      workflow.Stopper(longPollServer.Stop),
+		})
+		wsDeps = append(wsDeps, "longPoll")
+	}
+	wsMux.Handle("/api/spec", signalServer.Spec())
+	// load balancers and clients look up the gateway anchors of a room are routed to
+	routeResolver := signal.NewRouteResolver(connGuard, config.WSNotify.Partitions, logger.Module("Route"))
+	wsMux.HandleFunc("/route", routeResolver.HandleRoute)
 	// TODO: health check endpoint?
-	wsServer := httputil.NewServer(&config.WSHttp, wsMux)
-
-	// Start WebSocket server in goroutine
-	go func() {
-		logger.Info("Starting WebSocket server", log.String("addr", config.WSHttp.Addr))
-		if err := wsServer.Listen(); err != nil {
-			logger.Fatal("Failed to start WebSocket server", log.Error(err))
-		}
-	}()
-
-	// Graceful shutdown
-	cleanup := func(ctx context.Context) {
-		_ = wsServer.Shutdown(ctx)
-
-		signalServer.Close()
-		_ = connMgr.Stop(ctx)
-
-		if err := janusProxy.Close(); err != nil {
-			logger.Error("Error cleaning up Janus proxy", log.Error(err))
-		}
-		if err := redisClient.Close(); err != nil {
-			logger.Error("Error closing Redis client", log.Error(err))
-		}
-		if err := etcdClient.Close(); err != nil {
-			logger.Error("Error closing etcd client", log.Error(err))
-		}
-		if err := otelShutdown(ctx); err != nil {
-			logger.Error("Failed to shutdown OTEL", log.Error(err))
-		}
-	}
-	workflow.WaitGracefulShutdown(ctx, logger.Module("CleanUp"), cleanup, config.App.ShutdownTimeout)
+	wsServer := httputil.NewServer(&config.WSHttp, httputil.Wrap("wsgateway", logger, wsMux))
+
+	// autoscaling signals, polled by the HPA external metrics adapter. They list room IDs,
+	// so they are kept off the public listener
+	adminMux := httputil.NewAdminMux(logger)
+	adminMux.HandleFunc("/stats", connMgr.HandleStats)
+	adminServer := httputil.NewServer(&config.AdminHTTP, httputil.Wrap("wsgateway", logger, adminMux))
+	adminServer.SetIdentity(identity)
+
+	lc.Add(adminServer.Component("admin", logger, "connMgr"))
+	lc.Add(wsServer.Component("ws", logger, wsDeps...))
+
+	if err := lc.Start(ctx); err != nil {
+		logger.Fatal("Failed to start WebSocket Gateway", log.Error(err))
+	}
+
+	lc.WaitGracefulShutdown(ctx, config.App.ShutdownTimeout)
 }
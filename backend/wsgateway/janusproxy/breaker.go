@@ -0,0 +1,299 @@
+package janusproxy
+
+import (
+	"context"
+	"crypto/tls"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jonboulle/clockwork"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/imtaco/audio-rtc-exp/internal/breaker"
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const reportTimeout = 5 * time.Second
+
+// BreakerConfig guards the calls to each Janus instance, a method failing FailureThreshold
+// times in a row fails fast until OpenDuration elapsed. Only timeouts and connection errors
+// count as failures, errors answered by Janus do not
+type BreakerConfig struct {
+	// FailureThreshold is consecutive failed calls of a method opening its breaker, 0 disables breakers
+	FailureThreshold int           `mapstructure:"failure_threshold"`
+	OpenDuration     time.Duration `mapstructure:"open_duration"`
+	// DegradedTTL is how long placement deprioritizes an instance reported when a breaker opens
+	DegradedTTL time.Duration `mapstructure:"degraded_ttl"`
+}
+
+func SetupBreaker(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("failure_threshold"), 5)
+	v.SetDefault(p("open_duration"), "30s")
+	v.SetDefault(p("degraded_ttl"), "2m")
+}
+
+// DegradedReporter tells placement a Janus instance is failing
+type DegradedReporter interface {
+	ReportDegraded(ctx context.Context, janusID, reason string, ttl time.Duration) error
+}
+
+// NewDegradedReporter reports through the module API of rooms, nil when no URL is set.
+// The token needs the mark-modules scope
+func NewDegradedReporter(roomsURL, token string, tlsConfig *tls.Config, timeout time.Duration, reporterID string) DegradedReporter {
+	if roomsURL == "" {
+		return nil
+	}
+	client := resty.New().
+		SetBaseURL(strings.TrimSuffix(roomsURL, "/")).
+		SetTimeout(timeout)
+	if token != "" {
+		client.SetAuthToken(token)
+	}
+	if tlsConfig != nil {
+		client.SetTLSClientConfig(tlsConfig)
+	}
+	return &roomsReporter{client: client, reporterID: reporterID}
+}
+
+type roomsReporter struct {
+	client     *resty.Client
+	reporterID string
+}
+
+func (r *roomsReporter) ReportDegraded(ctx context.Context, janusID, reason string, ttl time.Duration) error {
+	resp, err := r.client.R().
+		SetContext(ctx).
+		SetBody(map[string]any{
+			"reason":   reason,
+			"reporter": r.reporterID,
+			"ttl":      max(int64(ttl.Seconds()), 1),
+		}).
+		Put("/api/modules/januses/" + url.PathEscape(janusID) + "/degraded")
+	if err != nil {
+		return fmt.Errorf("failed to report janus degraded: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("failed to report janus degraded: %s", resp.Status())
+	}
+	return nil
+}
+
+// breakerAPI wraps the API of one Janus instance with a breaker per method, anchors created
+// through it share the breakers
+type breakerAPI struct {
+	janus.API
+	janusID  string
+	cfg      *BreakerConfig
+	reporter DegradedReporter // optional
+	clock    clockwork.Clock
+	mu       sync.Mutex
+	breakers map[string]*breaker.Breaker
+	logger   *log.Logger
+}
+
+func newBreakerAPI(
+	api janus.API,
+	janusID string,
+	cfg *BreakerConfig,
+	reporter DegradedReporter,
+	clock clockwork.Clock,
+	logger *log.Logger,
+) *breakerAPI {
+	return &breakerAPI{
+		API:      api,
+		janusID:  janusID,
+		cfg:      cfg,
+		reporter: reporter,
+		clock:    clock,
+		breakers: make(map[string]*breaker.Breaker),
+		logger:   logger,
+	}
+}
+
+func (b *breakerAPI) breakerOf(method string) *breaker.Breaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	br, ok := b.breakers[method]
+	if !ok {
+		br = breaker.New(b.cfg.FailureThreshold, b.cfg.OpenDuration, b.clock)
+		b.breakers[method] = br
+	}
+	return br
+}
+
+// call runs fn unless the breaker of method is open
+func (b *breakerAPI) call(ctx context.Context, method string, fn func() error) error {
+	attrs := metric.WithAttributes(attribute.String("method", method))
+	br := b.breakerOf(method)
+	if !br.Allow() {
+		janusBreakerRejected.Add(ctx, 1, attrs)
+		return errors.Newf(janus.ErrCircuitOpen, "%s on janus %s", method, b.janusID)
+	}
+
+	err := fn()
+	switch {
+	case err != nil && stderrors.Is(ctx.Err(), context.Canceled):
+		// the caller gave up, Janus may be fine
+		br.Forget()
+	case br.Record(!isInstanceFailure(err)):
+		janusBreakerOpened.Add(ctx, 1, attrs)
+		b.logger.Warn("Janus circuit opened",
+			log.String("janusId", b.janusID),
+			log.String("method", method),
+			log.Error(err))
+		b.report(method)
+	}
+	return err
+}
+
+// report tells placement in background, it is renewed each time a breaker reopens
+func (b *breakerAPI) report(method string) {
+	if b.reporter == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+		defer cancel()
+		if err := b.reporter.ReportDegraded(ctx, b.janusID, "circuit open: "+method, b.cfg.DegradedTTL); err != nil {
+			b.logger.Warn("Failed to report degraded Janus", log.String("janusId", b.janusID), log.Error(err))
+		}
+	}()
+}
+
+// isInstanceFailure tells timeouts and connection errors, the instance did not answer
+func isInstanceFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return stderrors.Is(err, context.DeadlineExceeded) ||
+		stderrors.As(err, &netErr) ||
+		stderrors.Is(err, janus.ErrFailedRequest)
+}
+
+func (b *breakerAPI) CreateAnchorInstance(
+	ctx context.Context,
+	clientID string,
+	sessionID int64,
+	handleID int64,
+) (janus.Anchor, error) {
+	var anchor janus.Anchor
+	err := b.call(ctx, "createAnchor", func() error {
+		var err error
+		anchor, err = b.API.CreateAnchorInstance(ctx, clientID, sessionID, handleID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &breakerAnchor{Anchor: anchor, api: b}, nil
+}
+
+func (b *breakerAPI) CreateAdminInstance(ctx context.Context, adminKey string) (janus.Admin, error) {
+	var admin janus.Admin
+	err := b.call(ctx, "createAdmin", func() error {
+		var err error
+		admin, err = b.API.CreateAdminInstance(ctx, adminKey)
+		return err
+	})
+	return admin, err
+}
+
+func (b *breakerAPI) CreateSession(ctx context.Context) (int64, error) {
+	var sessionID int64
+	err := b.call(ctx, "createSession", func() error {
+		var err error
+		sessionID, err = b.API.CreateSession(ctx)
+		return err
+	})
+	return sessionID, err
+}
+
+func (b *breakerAPI) KeepAliveSession(ctx context.Context, sessionID int64) error {
+	return b.call(ctx, "keepalive", func() error {
+		return b.API.KeepAliveSession(ctx, sessionID)
+	})
+}
+
+func (b *breakerAPI) DestroySession(ctx context.Context, sessionID int64) error {
+	return b.call(ctx, "destroySession", func() error {
+		return b.API.DestroySession(ctx, sessionID)
+	})
+}
+
+// breakerAnchor guards the signaling calls of an anchor, long polls of events are not
+// guarded since they time out by design
+type breakerAnchor struct {
+	janus.Anchor
+	api *breakerAPI
+}
+
+func (a *breakerAnchor) Join(
+	ctx context.Context,
+	roomID int64,
+	pin, displayName string,
+	bitrate int,
+	group string,
+	jsep *janus.JSEP,
+) (*janus.Response, error) {
+	var resp *janus.Response
+	err := a.api.call(ctx, "join", func() error {
+		var err error
+		resp, err = a.Anchor.Join(ctx, roomID, pin, displayName, bitrate, group, jsep)
+		return err
+	})
+	return resp, err
+}
+
+func (a *breakerAnchor) Leave(ctx context.Context) (*janus.Response, error) {
+	var resp *janus.Response
+	err := a.api.call(ctx, "leave", func() error {
+		var err error
+		resp, err = a.Anchor.Leave(ctx)
+		return err
+	})
+	return resp, err
+}
+
+func (a *breakerAnchor) IceCandidate(ctx context.Context, candidate janus.ICECandidate) (*janus.Response, error) {
+	var resp *janus.Response
+	err := a.api.call(ctx, "trickle", func() error {
+		var err error
+		resp, err = a.Anchor.IceCandidate(ctx, candidate)
+		return err
+	})
+	return resp, err
+}
+
+func (a *breakerAnchor) IceRestart(ctx context.Context, jsep *janus.JSEP) (*janus.Response, error) {
+	var resp *janus.Response
+	err := a.api.call(ctx, "iceRestart", func() error {
+		var err error
+		resp, err = a.Anchor.IceRestart(ctx, jsep)
+		return err
+	})
+	return resp, err
+}
+
+func (a *breakerAnchor) Check(ctx context.Context) (bool, error) {
+	var ok bool
+	err := a.api.call(ctx, "check", func() error {
+		var err error
+		ok, err = a.Anchor.Check(ctx)
+		return err
+	})
+	return ok, err
+}
@@ -0,0 +1,172 @@
+package janusproxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	janusmocks "github.com/imtaco/audio-rtc-exp/internal/janus/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type degradedReport struct {
+	janusID string
+	reason  string
+	ttl     time.Duration
+}
+
+type recordingReporter struct {
+	reports chan degradedReport
+}
+
+func (r *recordingReporter) ReportDegraded(_ context.Context, janusID, reason string, ttl time.Duration) error {
+	r.reports <- degradedReport{janusID: janusID, reason: reason, ttl: ttl}
+	return nil
+}
+
+type BreakerAPISuite struct {
+	suite.Suite
+	ctrl     *gomock.Controller
+	api      *janusmocks.MockAPI
+	anchor   *janusmocks.MockAnchor
+	clock    *clockwork.FakeClock
+	reporter *recordingReporter
+	breaker  *breakerAPI
+	ctx      context.Context
+}
+
+func TestBreakerAPISuite(t *testing.T) {
+	suite.Run(t, new(BreakerAPISuite))
+}
+
+func (s *BreakerAPISuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.api = janusmocks.NewMockAPI(s.ctrl)
+	s.anchor = janusmocks.NewMockAnchor(s.ctrl)
+	s.clock = clockwork.NewFakeClock()
+	s.reporter = &recordingReporter{reports: make(chan degradedReport, 4)}
+	cfg := &BreakerConfig{FailureThreshold: 2, OpenDuration: 30 * time.Second, DegradedTTL: time.Minute}
+	s.breaker = newBreakerAPI(s.api, "janus1", cfg, s.reporter, s.clock, log.NewNop())
+	s.ctx = context.Background()
+}
+
+func (s *BreakerAPISuite) expectReport() {
+	select {
+	case report := <-s.reporter.reports:
+		s.Equal(degradedReport{janusID: "janus1", reason: "circuit open: createSession", ttl: time.Minute}, report)
+	case <-time.After(time.Second):
+		s.Fail("no degraded report")
+	}
+}
+
+func (s *BreakerAPISuite) TestOpensOnTimeouts() {
+	s.api.EXPECT().CreateSession(gomock.Any()).Return(int64(0), context.DeadlineExceeded).Times(2)
+
+	for range 2 {
+		_, err := s.breaker.CreateSession(s.ctx)
+		s.ErrorIs(err, context.DeadlineExceeded)
+	}
+	s.expectReport()
+
+	// fails fast without calling Janus
+	_, err := s.breaker.CreateSession(s.ctx)
+	s.ErrorIs(err, janus.ErrCircuitOpen)
+
+	// other methods have their own breaker
+	s.api.EXPECT().KeepAliveSession(gomock.Any(), int64(1)).Return(nil)
+	s.NoError(s.breaker.KeepAliveSession(s.ctx, 1))
+
+	// a successful trial closes it
+	s.clock.Advance(30 * time.Second)
+	s.api.EXPECT().CreateSession(gomock.Any()).Return(int64(7), nil)
+	id, err := s.breaker.CreateSession(s.ctx)
+	s.Require().NoError(err)
+	s.Equal(int64(7), id)
+}
+
+func (s *BreakerAPISuite) TestFailedTrialReportsAgain() {
+	s.api.EXPECT().CreateSession(gomock.Any()).Return(int64(0), context.DeadlineExceeded).Times(3)
+
+	for range 2 {
+		_, _ = s.breaker.CreateSession(s.ctx)
+	}
+	s.expectReport()
+
+	s.clock.Advance(30 * time.Second)
+	_, err := s.breaker.CreateSession(s.ctx)
+	s.ErrorIs(err, context.DeadlineExceeded)
+	s.expectReport()
+}
+
+func (s *BreakerAPISuite) TestJanusErrorsDoNotCount() {
+	s.api.EXPECT().DestroySession(gomock.Any(), int64(1)).
+		Return(errors.New(janus.ErrNoneSuccessResponse, "no such session")).Times(3)
+
+	for range 3 {
+		err := s.breaker.DestroySession(s.ctx, 1)
+		s.ErrorIs(err, janus.ErrNoneSuccessResponse)
+	}
+}
+
+func (s *BreakerAPISuite) TestCanceledCallsDoNotCount() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	cancel()
+	s.api.EXPECT().CreateSession(gomock.Any()).Return(int64(0), context.Canceled).Times(3)
+
+	for range 3 {
+		_, err := s.breaker.CreateSession(ctx)
+		s.ErrorIs(err, context.Canceled)
+	}
+}
+
+func (s *BreakerAPISuite) TestAnchorCalls() {
+	s.api.EXPECT().CreateAnchorInstance(gomock.Any(), "conn1", int64(0), int64(0)).Return(s.anchor, nil)
+	anchor, err := s.breaker.CreateAnchorInstance(s.ctx, "conn1", 0, 0)
+	s.Require().NoError(err)
+
+	s.anchor.EXPECT().Join(gomock.Any(), int64(1), "", "user", 0, janus.GroupRoom, nil).
+		Return(nil, context.DeadlineExceeded).Times(2)
+	for range 2 {
+		_, err = anchor.Join(s.ctx, 1, "", "user", 0, janus.GroupRoom, nil)
+		s.ErrorIs(err, context.DeadlineExceeded)
+	}
+	<-s.reporter.reports
+
+	_, err = anchor.Join(s.ctx, 1, "", "user", 0, janus.GroupRoom, nil)
+	s.ErrorIs(err, janus.ErrCircuitOpen)
+
+	// unguarded calls go through
+	s.anchor.EXPECT().GetSessionID().Return(int64(5))
+	s.Equal(int64(5), anchor.GetSessionID())
+}
+
+func TestDegradedReporter(t *testing.T) {
+	var path, auth string
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.Method + " " + r.URL.Path
+		auth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	reporter := NewDegradedReporter(srv.URL+"/", "secret", nil, time.Second, "gw-1")
+	require.NoError(t, reporter.ReportDegraded(context.Background(), "janus-1", "circuit open: join", 90*time.Second))
+	assert.Equal(t, "PUT /api/modules/januses/janus-1/degraded", path)
+	assert.Equal(t, "Bearer secret", auth)
+	assert.Equal(t, map[string]any{"reason": "circuit open: join", "reporter": "gw-1", "ttl": float64(90)}, body)
+
+	assert.Nil(t, NewDegradedReporter("", "", nil, time.Second, "gw-1"))
+}
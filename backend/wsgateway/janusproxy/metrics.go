@@ -12,6 +12,14 @@ var (
 	janusInstCacheMisses metric.Int64Counter
 	janusInstCacheSize   metric.Int64UpDownCounter
 
+	// Pre-warmed session pool metrics
+	janusPoolHits   metric.Int64Counter
+	janusPoolMisses metric.Int64Counter
+
+	// Janus circuit breaker metrics
+	janusBreakerOpened   metric.Int64Counter
+	janusBreakerRejected metric.Int64Counter
+
 	// Janus proxy metrics
 	janusProxyRequests metric.Int64Counter
 	janusProxyFailures metric.Int64Counter
@@ -33,6 +41,18 @@ func init() {
 	f.Int64UpDownCounter(&janusInstCacheSize, "janus_cache.size",
 		metric.WithDescription("Current Janus instance cache size"))
 
+	f.Int64Counter(&janusPoolHits, "session_pool.hits",
+		metric.WithDescription("Anchors created on a pre-warmed Janus session"))
+
+	f.Int64Counter(&janusPoolMisses, "session_pool.misses",
+		metric.WithDescription("Anchors created without a pre-warmed Janus session"))
+
+	f.Int64Counter(&janusBreakerOpened, "breaker.opened",
+		metric.WithDescription("Janus circuit breakers opened, by method"))
+
+	f.Int64Counter(&janusBreakerRejected, "breaker.rejected",
+		metric.WithDescription("Janus calls failed fast by an open circuit breaker, by method"))
+
 	f.Int64Counter(&janusProxyRequests, "proxy.requests",
 		metric.WithDescription("Total requests proxied to Janus"))
 
@@ -40,8 +60,8 @@ func init() {
 		metric.WithDescription("Total failed proxy requests"))
 
 	f.Int64Counter(&roomLookupsTotal, "room_lookups.total",
-		metric.WithDescription("Total room lookups from etcd"))
+		metric.WithDescription("Total room meta lookups in the watched room state"))
 
 	f.Int64Counter(&roomLookupsFailed, "room_lookups.failed",
-		metric.WithDescription("Total failed room lookups"))
+		metric.WithDescription("Room meta lookups of rooms not found"))
 }
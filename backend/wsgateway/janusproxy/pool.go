@@ -0,0 +1,193 @@
+package janusproxy
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const poolDestroyTimeout = 5 * time.Second
+
+// PoolConfig pre-warms Janus sessions so joins only attach a plugin handle
+type PoolConfig struct {
+	// Size of idle sessions kept per Janus instance, 0 disables pre-warming
+	Size int `mapstructure:"size"`
+	// KeepaliveInterval must stay below the Janus session timeout (60s by default)
+	KeepaliveInterval time.Duration `mapstructure:"keepalive_interval"`
+}
+
+func SetupPool(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("size"), 0)
+	v.SetDefault(p("keepalive_interval"), "20s")
+}
+
+// sessionPool wraps the API of one Janus instance, new anchors claim a created-but-unattached
+// session instead of creating one. Claimed sessions are replaced in background
+type sessionPool struct {
+	janus.API
+	janusID  string
+	cfg      *PoolConfig
+	mu       sync.Mutex
+	idle     []int64 // oldest first
+	refillCh chan struct{}
+	cancel   context.CancelFunc
+	done     chan struct{}
+	logger   *log.Logger
+}
+
+func newSessionPool(api janus.API, janusID string, cfg *PoolConfig, logger *log.Logger) *sessionPool {
+	return &sessionPool{
+		API:      api,
+		janusID:  janusID,
+		cfg:      cfg,
+		refillCh: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		logger:   logger,
+	}
+}
+
+func (p *sessionPool) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	go p.run(ctx)
+}
+
+// stop returns immediately, idle sessions are destroyed in background since the instance
+// may be unhealthy
+func (p *sessionPool) stop() {
+	if p.cancel == nil {
+		return
+	}
+	p.cancel()
+
+	go func() {
+		<-p.done
+		p.mu.Lock()
+		ids := p.idle
+		p.idle = nil
+		p.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), poolDestroyTimeout)
+		defer cancel()
+		for _, id := range ids {
+			if err := p.DestroySession(ctx, id); err != nil {
+				p.logger.Debug("Failed to destroy pooled session", log.Int64("sessionId", id), log.Error(err))
+			}
+		}
+	}()
+}
+
+// CreateAnchorInstance claims a pooled session for new anchors, resumed anchors keep theirs
+func (p *sessionPool) CreateAnchorInstance(
+	ctx context.Context,
+	clientID string,
+	sessionID int64,
+	handleID int64,
+) (janus.Anchor, error) {
+	if sessionID != 0 {
+		return p.API.CreateAnchorInstance(ctx, clientID, sessionID, handleID)
+	}
+
+	if id, ok := p.claim(); ok {
+		anchor, err := p.API.CreateAnchorInstance(ctx, clientID, id, 0)
+		if err == nil {
+			janusPoolHits.Add(ctx, 1)
+			return anchor, nil
+		}
+		p.logger.Warn("Pooled session unusable, creating a new one",
+			log.String("janusId", p.janusID),
+			log.Int64("sessionId", id),
+			log.Error(err))
+	}
+	janusPoolMisses.Add(ctx, 1)
+	return p.API.CreateAnchorInstance(ctx, clientID, 0, 0)
+}
+
+func (p *sessionPool) claim() (int64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	defer p.signalRefill()
+	if len(p.idle) == 0 {
+		return 0, false
+	}
+	id := p.idle[0]
+	p.idle = p.idle[1:]
+	return id, true
+}
+
+func (p *sessionPool) signalRefill() {
+	select {
+	case p.refillCh <- struct{}{}:
+	default:
+	}
+}
+
+func (p *sessionPool) run(ctx context.Context) {
+	defer close(p.done)
+
+	interval := p.cfg.KeepaliveInterval
+	if interval <= 0 {
+		interval = 20 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	p.refill(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.refillCh:
+			p.refill(ctx)
+		case <-ticker.C:
+			p.keepalive(ctx)
+			p.refill(ctx)
+		}
+	}
+}
+
+// refill creates sessions up to the pool size, failures are retried on the next tick
+func (p *sessionPool) refill(ctx context.Context) {
+	for ctx.Err() == nil {
+		p.mu.Lock()
+		missing := p.cfg.Size - len(p.idle)
+		p.mu.Unlock()
+		if missing <= 0 {
+			return
+		}
+
+		id, err := p.CreateSession(ctx)
+		if err != nil {
+			p.logger.Warn("Failed to pre-warm Janus session", log.String("janusId", p.janusID), log.Error(err))
+			return
+		}
+		p.mu.Lock()
+		p.idle = append(p.idle, id)
+		p.mu.Unlock()
+	}
+}
+
+// keepalive refreshes idle sessions, sessions Janus no longer knows are dropped
+func (p *sessionPool) keepalive(ctx context.Context) {
+	p.mu.Lock()
+	ids := slices.Clone(p.idle)
+	p.mu.Unlock()
+
+	for _, id := range ids {
+		if err := p.KeepAliveSession(ctx, id); err != nil {
+			p.logger.Debug("Drop expired pooled session", log.Int64("sessionId", id), log.Error(err))
+			p.mu.Lock()
+			p.idle = slices.DeleteFunc(p.idle, func(v int64) bool { return v == id })
+			p.mu.Unlock()
+		}
+	}
+}
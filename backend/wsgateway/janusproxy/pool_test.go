@@ -0,0 +1,139 @@
+package janusproxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+
+	janusmocks "github.com/imtaco/audio-rtc-exp/internal/janus/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type SessionPoolSuite struct {
+	suite.Suite
+	ctrl   *gomock.Controller
+	api    *janusmocks.MockAPI
+	anchor *janusmocks.MockAnchor
+	pool   *sessionPool
+	ctx    context.Context
+}
+
+func TestSessionPoolSuite(t *testing.T) {
+	suite.Run(t, new(SessionPoolSuite))
+}
+
+func (s *SessionPoolSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.api = janusmocks.NewMockAPI(s.ctrl)
+	s.anchor = janusmocks.NewMockAnchor(s.ctrl)
+	s.pool = newSessionPool(s.api, "janus1", &PoolConfig{Size: 2, KeepaliveInterval: time.Hour}, log.NewNop())
+	s.ctx = context.Background()
+}
+
+func (s *SessionPoolSuite) refillSignaled() bool {
+	select {
+	case <-s.pool.refillCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *SessionPoolSuite) TestCreateAnchor_ClaimsOldestSession() {
+	s.pool.idle = []int64{11, 12}
+	s.api.EXPECT().CreateAnchorInstance(s.ctx, "conn1", int64(11), int64(0)).Return(s.anchor, nil)
+
+	anchor, err := s.pool.CreateAnchorInstance(s.ctx, "conn1", 0, 0)
+	s.Require().NoError(err)
+	s.Equal(s.anchor, anchor)
+	s.Equal([]int64{12}, s.pool.idle)
+	s.True(s.refillSignaled())
+}
+
+func (s *SessionPoolSuite) TestCreateAnchor_EmptyPool() {
+	s.api.EXPECT().CreateAnchorInstance(s.ctx, "conn1", int64(0), int64(0)).Return(s.anchor, nil)
+
+	anchor, err := s.pool.CreateAnchorInstance(s.ctx, "conn1", 0, 0)
+	s.Require().NoError(err)
+	s.Equal(s.anchor, anchor)
+	s.True(s.refillSignaled())
+}
+
+func (s *SessionPoolSuite) TestCreateAnchor_UnusableSession() {
+	s.pool.idle = []int64{11}
+	gomock.InOrder(
+		s.api.EXPECT().CreateAnchorInstance(s.ctx, "conn1", int64(11), int64(0)).Return(nil, errors.New("no such session")),
+		s.api.EXPECT().CreateAnchorInstance(s.ctx, "conn1", int64(0), int64(0)).Return(s.anchor, nil),
+	)
+
+	anchor, err := s.pool.CreateAnchorInstance(s.ctx, "conn1", 0, 0)
+	s.Require().NoError(err)
+	s.Equal(s.anchor, anchor)
+	s.Empty(s.pool.idle)
+}
+
+func (s *SessionPoolSuite) TestCreateAnchor_ResumeKeepsSession() {
+	s.pool.idle = []int64{11}
+	s.api.EXPECT().CreateAnchorInstance(s.ctx, "conn1", int64(21), int64(22)).Return(s.anchor, nil)
+
+	_, err := s.pool.CreateAnchorInstance(s.ctx, "conn1", 21, 22)
+	s.Require().NoError(err)
+	s.Equal([]int64{11}, s.pool.idle)
+	s.False(s.refillSignaled())
+}
+
+func (s *SessionPoolSuite) TestRefill() {
+	s.Run("fills up to size", func() {
+		s.pool.idle = []int64{11}
+		s.api.EXPECT().CreateSession(s.ctx).Return(int64(12), nil)
+
+		s.pool.refill(s.ctx)
+		s.Equal([]int64{11, 12}, s.pool.idle)
+	})
+
+	s.Run("stops on error", func() {
+		s.pool.idle = nil
+		s.api.EXPECT().CreateSession(s.ctx).Return(int64(0), errors.New("janus down"))
+
+		s.pool.refill(s.ctx)
+		s.Empty(s.pool.idle)
+	})
+}
+
+func (s *SessionPoolSuite) TestKeepalive_DropsExpiredSessions() {
+	s.pool.idle = []int64{11, 12}
+	s.api.EXPECT().KeepAliveSession(s.ctx, int64(11)).Return(errors.New("no such session"))
+	s.api.EXPECT().KeepAliveSession(s.ctx, int64(12)).Return(nil)
+
+	s.pool.keepalive(s.ctx)
+	s.Equal([]int64{12}, s.pool.idle)
+}
+
+func (s *SessionPoolSuite) TestStop_DestroysIdleSessions() {
+	s.pool.cfg.Size = 1
+	created := make(chan struct{})
+	destroyed := make(chan int64, 1)
+	s.api.EXPECT().CreateSession(gomock.Any()).DoAndReturn(func(context.Context) (int64, error) {
+		close(created)
+		return int64(7), nil
+	})
+	s.api.EXPECT().DestroySession(gomock.Any(), int64(7)).DoAndReturn(func(_ context.Context, id int64) error {
+		destroyed <- id
+		return nil
+	})
+
+	s.pool.start()
+	<-created
+	s.pool.stop()
+
+	select {
+	case id := <-destroyed:
+		s.Equal(int64(7), id)
+	case <-time.After(time.Second):
+		s.T().Fatal("pooled session not destroyed")
+	}
+}
@@ -3,8 +3,10 @@ package janusproxy
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 
 	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/jonboulle/clockwork"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"golang.org/x/sync/singleflight"
 
@@ -13,15 +15,20 @@ import (
 	"github.com/imtaco/audio-rtc-exp/internal/janus"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	etcdwatcher "github.com/imtaco/audio-rtc-exp/internal/reswatcher/etcd"
+	"github.com/imtaco/audio-rtc-exp/internal/roomstate"
 	"github.com/imtaco/audio-rtc-exp/wsgateway"
 )
 
 type janusProxyImpl struct {
 	janusPort    string
 	janusWatcher etcdwatcher.HealthyModuleWatcher
-	roomWatcher  etcdwatcher.RoomWatcher
+	roomWatcher  roomstate.Watcher
 	instCache    *lru.Cache[string, janus.API]
+	poolCfg      *PoolConfig
+	breakerCfg   *BreakerConfig   // nil disables breakers
+	reporter     DegradedReporter // optional, told when a breaker opens
 	sfJanus      singleflight.Group
+	onRoomChange atomic.Pointer[func(roomID string)]
 	logger       *log.Logger
 }
 
@@ -31,29 +38,53 @@ func NewProxy(
 	prefixJanus string,
 	janusInstCacheSize int,
 	janusPort string,
+	poolCfg *PoolConfig,
+	breakerCfg *BreakerConfig,
+	reporter DegradedReporter,
 	logger *log.Logger,
 ) (wsgateway.JanusProxy, error) {
-	instCache, err := lru.New[string, janus.API](janusInstCacheSize)
+	instCache, err := lru.NewWithEvict(janusInstCacheSize, stopPool)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create LRU cache: %w", err)
 	}
 
-	janusWatcher := etcdwatcher.NewHealthyModuleWatcher(etcdClient, prefixJanus, logger.Module("JanusWatcher"))
-	roomWatcher := etcdwatcher.NewRoomWatcher(
-		etcdClient,
-		prefixRoom,
-		[]string{constants.RoomKeyMeta, constants.RoomKeyLiveMeta, constants.RoomKeyJanus},
-		nil,
-		logger.Module("RoomWatcher"),
-	)
-
-	return &janusProxyImpl{
-		janusPort:    janusPort,
-		instCache:    instCache,
-		janusWatcher: janusWatcher,
-		roomWatcher:  roomWatcher,
-		logger:       logger,
-	}, nil
+	jp := &janusProxyImpl{
+		janusPort:  janusPort,
+		instCache:  instCache,
+		poolCfg:    poolCfg,
+		breakerCfg: breakerCfg,
+		reporter:   reporter,
+		logger:     logger,
+	}
+	jp.janusWatcher = etcdwatcher.NewHealthyModuleWatcher(etcdClient, prefixJanus, logger.Module("JanusWatcher"))
+	jp.roomWatcher = roomstate.New(&roomstate.Config{
+		Client:   etcdClient,
+		Prefix:   prefixRoom,
+		KeyTypes: []string{constants.RoomKeyMeta, constants.RoomKeyLiveMeta, constants.RoomKeyJanus, constants.RoomKeyLinkedBy},
+		OnChange: jp.processChange,
+		Logger:   logger.Module("RoomWatcher"),
+	})
+
+	return jp, nil
+}
+
+func (jp *janusProxyImpl) processChange(_ context.Context, roomID string, _ *etcdstate.RoomState) error {
+	if fn := jp.onRoomChange.Load(); fn != nil {
+		(*fn)(roomID)
+	}
+	return nil
+}
+
+func (jp *janusProxyImpl) OnRoomChange(fn func(roomID string)) {
+	jp.onRoomChange.Store(&fn)
+}
+
+// stopPool stops pre-warming on instances evicted from the cache or found unhealthy
+func stopPool(_ string, api janus.API) {
+	janusInstCacheSize.Add(context.Background(), -1)
+	if pool, ok := api.(*sessionPool); ok {
+		pool.stop()
+	}
 }
 
 func (jp *janusProxyImpl) Open(ctx context.Context) error {
@@ -67,23 +98,34 @@ func (jp *janusProxyImpl) Open(ctx context.Context) error {
 }
 
 func (jp *janusProxyImpl) GetRoomLiveMeta(roomID string) *etcdstate.LiveMeta {
-	state, _ := jp.roomWatcher.GetCachedState(roomID)
-	return state.GetLiveMeta()
+	return roomstate.LiveMeta(jp.roomWatcher, roomID)
 }
 
+// GetRoomMeta returns the meta of the room from the watched room state, lookups of unknown
+// rooms are answered from memory as well and never reach etcd
 func (jp *janusProxyImpl) GetRoomMeta(roomID string) *etcdstate.Meta {
-	state, _ := jp.roomWatcher.GetCachedState(roomID)
-	return state.GetMeta()
+	meta := roomstate.Meta(jp.roomWatcher, roomID)
+	roomLookupsTotal.Add(context.Background(), 1)
+	if meta == nil {
+		roomLookupsFailed.Add(context.Background(), 1)
+	}
+	return meta
+}
+
+func (jp *janusProxyImpl) GetLinkGroup(roomID, userID string) string {
+	if roomstate.LinkedBy(jp.roomWatcher, roomID).HasAnchor(userID) {
+		return janus.GroupLink
+	}
+	return janus.GroupRoom
 }
 
 func (jp *janusProxyImpl) getJanusID(roomID string) string {
-	state, _ := jp.roomWatcher.GetCachedState(roomID)
-	return state.GetLiveMeta().GetJanusID()
+	janusID, _ := roomstate.Modules(jp.roomWatcher, roomID)
+	return janusID
 }
 
 func (jp *janusProxyImpl) GetJanusRoomID(roomID string) int64 {
-	state, _ := jp.roomWatcher.GetCachedState(roomID)
-	return state.GetJanus().GetJanusRoomID()
+	return roomstate.Janus(jp.roomWatcher, roomID).GetJanusRoomID()
 }
 
 func (jp *janusProxyImpl) GetJanusAPI(roomID string) janus.API {
@@ -106,12 +148,30 @@ func (jp *janusProxyImpl) GetJanusAPI(roomID string) janus.API {
 
 		janusAPI, ok := jp.instCache.Get(janusID)
 		if ok {
+			janusInstCacheHits.Add(context.Background(), 1)
 			return janusAPI, nil
 		}
+		janusInstCacheMisses.Add(context.Background(), 1)
 
 		url := fmt.Sprintf("http://%s:%s", host, jp.janusPort)
 		janusAPI = janus.New(url, jp.logger)
-		jp.instCache.Add(janusID, janusAPI)
+		if jp.breakerCfg != nil && jp.breakerCfg.FailureThreshold > 0 {
+			janusAPI = newBreakerAPI(janusAPI, janusID, jp.breakerCfg, jp.reporter, clockwork.NewRealClock(), jp.logger.Module("Breaker"))
+		}
+		var pool *sessionPool
+		if jp.poolCfg != nil && jp.poolCfg.Size > 0 {
+			pool = newSessionPool(janusAPI, janusID, jp.poolCfg, jp.logger.Module("SessionPool"))
+			pool.start()
+			janusAPI = pool
+		}
+		// lookups are shared per room, a room on the same Janus may have added it meanwhile
+		if prev, found, _ := jp.instCache.PeekOrAdd(janusID, janusAPI); found {
+			if pool != nil {
+				pool.stop()
+			}
+			return prev, nil
+		}
+		janusInstCacheSize.Add(context.Background(), 1)
 
 		jp.logger.Info("Created new Janus API instance",
 			log.String("janusId", janusID),
@@ -134,5 +194,6 @@ func (jp *janusProxyImpl) Close() error {
 	if err := jp.roomWatcher.Stop(); err != nil {
 		jp.logger.Error("Error stopping Room watcher", log.Error(err))
 	}
+	jp.instCache.Purge()
 	return nil
 }
@@ -12,16 +12,16 @@ import (
 
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
-	"github.com/imtaco/audio-rtc-exp/internal/janus"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 	mockwatcher "github.com/imtaco/audio-rtc-exp/internal/reswatcher/etcd/mocks"
+	roomstatemocks "github.com/imtaco/audio-rtc-exp/internal/roomstate/mocks"
 )
 
 type ProxySuite struct {
 	suite.Suite
 	ctrl         *gomock.Controller
 	janusWatcher *mockwatcher.MockHealthyModuleWatcher
-	roomWatcher  *mockwatcher.MockRoomWatcher
+	roomWatcher  *roomstatemocks.MockWatcher
 	proxy        *janusProxyImpl
 	logger       *log.Logger
 }
@@ -33,10 +33,10 @@ func TestProxySuite(t *testing.T) {
 func (s *ProxySuite) SetupTest() {
 	s.ctrl = gomock.NewController(s.T())
 	s.janusWatcher = mockwatcher.NewMockHealthyModuleWatcher(s.ctrl)
-	s.roomWatcher = mockwatcher.NewMockRoomWatcher(s.ctrl)
+	s.roomWatcher = roomstatemocks.NewMockWatcher(s.ctrl)
 	s.logger = log.NewNop()
 
-	cache, err := lru.New[string, janus.API](10)
+	cache, err := lru.NewWithEvict(10, stopPool)
 	s.Require().NoError(err)
 
 	s.proxy = &janusProxyImpl{
@@ -53,13 +53,13 @@ func (s *ProxySuite) TearDownTest() {
 }
 
 func (s *ProxySuite) TestNewProxy_Success() {
-	p, err := NewProxy(nil, "room/", "janus/", 10, "8088", log.NewTest(s.T()))
+	p, err := NewProxy(nil, "room/", "janus/", 10, "8088", nil, nil, nil, log.NewTest(s.T()))
 	s.Require().NoError(err)
 	s.NotNil(p)
 }
 
 func (s *ProxySuite) TestNewProxy_Error() {
-	_, err := NewProxy(nil, "", "", 0, "", log.NewTest(s.T()))
+	_, err := NewProxy(nil, "", "", 0, "", nil, nil, nil, log.NewTest(s.T()))
 	s.Require().Error(err)
 	s.Contains(err.Error(), "failed to create LRU cache")
 }
@@ -209,6 +209,38 @@ func (s *ProxySuite) TestGetJanusAPI_Success() {
 	s.Equal(api, cached)
 }
 
+func (s *ProxySuite) TestGetJanusAPI_SessionPool() {
+	roomID := "room1"
+	janusID := "janus1"
+	s.proxy.poolCfg = &PoolConfig{Size: 1, KeepaliveInterval: time.Hour}
+
+	roomState := &etcdstate.RoomState{
+		LiveMeta: &etcdstate.LiveMeta{
+			JanusID: janusID,
+		},
+	}
+	moduleState := &etcdstate.ModuleState{
+		Heartbeat: &etcdstate.HeartbeatData{
+			Host:   "127.0.0.1",
+			Status: "healthy",
+		},
+	}
+	s.roomWatcher.EXPECT().GetCachedState(roomID).Return(roomState, true)
+	s.janusWatcher.EXPECT().Get(janusID).Return(*moduleState, true)
+
+	api := s.proxy.GetJanusAPI(roomID)
+	pool, ok := api.(*sessionPool)
+	s.Require().True(ok)
+
+	// evicted instances stop pre-warming
+	s.proxy.instCache.Remove(janusID)
+	select {
+	case <-pool.done:
+	case <-time.After(time.Second):
+		s.T().Fatal("session pool not stopped")
+	}
+}
+
 func (s *ProxySuite) TestGetJanusAPI_EmptyJanusID() {
 	roomID := "room1"
 
@@ -277,6 +309,21 @@ func (s *ProxySuite) TestGetJanusAPI_CacheHit() {
 	s.Equal(api1, api2)
 }
 
+func (s *ProxySuite) TestGetJanusAPI_SharedAcrossRooms() {
+	roomState := &etcdstate.RoomState{LiveMeta: &etcdstate.LiveMeta{JanusID: "janus1"}}
+	moduleState := etcdstate.ModuleState{Heartbeat: &etcdstate.HeartbeatData{Host: "192.168.1.10", Status: "healthy"}}
+
+	s.roomWatcher.EXPECT().GetCachedState("room1").Return(roomState, true)
+	s.roomWatcher.EXPECT().GetCachedState("room2").Return(roomState, true)
+	s.janusWatcher.EXPECT().Get("janus1").Return(moduleState, true).Times(2)
+
+	api1 := s.proxy.GetJanusAPI("room1")
+	api2 := s.proxy.GetJanusAPI("room2")
+	s.Require().NotNil(api1)
+	s.Same(api1, api2)
+	s.Equal(1, s.proxy.instCache.Len())
+}
+
 func (s *ProxySuite) TestClose() {
 	s.janusWatcher.EXPECT().Stop().Return(nil)
 	s.roomWatcher.EXPECT().Stop().Return(nil)
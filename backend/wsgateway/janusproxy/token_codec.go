@@ -7,19 +7,66 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"io"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 
+	"github.com/imtaco/audio-rtc-exp/internal/config/secret"
 	"github.com/imtaco/audio-rtc-exp/wsgateway"
 )
 
+const (
+	// compact tokens: version(1) || nonce(12) || seal(session(7) || handle(7)) + tag(16),
+	// in unpadded URL-safe base64 so they fit QR codes and URLs as is
+	compactVersion  byte = 1
+	compactIDBytes       = 7
+	compactMaxID         = 1<<(8*compactIDBytes) - 1
+	compactPlainLen      = 2 * compactIDBytes
+	compactRawLen        = 1 + 12 + compactPlainLen + 16
+	legacyPlainLen       = 18
+)
+
+var compactTokenLen = base64.RawURLEncoding.EncodedLen(compactRawLen)
+
 func NewJanusTokenCodec(key []byte) (wsgateway.JanusTokenCodec, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	c := &janusIDCodec{}
+	c.keys.Store(&codecKeys{current: gcm})
+	return c, nil
+}
+
+// NewJanusTokenCodecWithSecret creates a codec keyed by a resolved secret. Once the secret
+// rotates tokens are sealed with the new key, tokens sealed with the previous one still decode
+// until the next rotation so reconnecting clients keep their Janus sessions
+func NewJanusTokenCodecWithSecret(sec *secret.Secret) (wsgateway.JanusTokenCodec, error) {
+	codec, err := NewJanusTokenCodec([]byte(sec.Value()))
+	if err != nil {
+		return nil, err
+	}
+	c := codec.(*janusIDCodec)
+	sec.OnRotate(func(value string) error {
+		gcm, err := newGCM([]byte(value))
+		if err != nil {
+			return err
+		}
+		c.keys.Store(&codecKeys{current: gcm, previous: c.keys.Load().current})
+		return nil
+	})
+	return c, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
 	if len(key) != 32 {
 		return nil, errors.Errorf("key must be 32 bytes (AES-256), got %d", len(key))
 	}
-	return &janusIDCodec{
-		key: key,
-	}, nil
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
 }
 
 type JanusToken struct {
@@ -28,53 +75,100 @@ type JanusToken struct {
 	HandleID  int64
 }
 
+// janusIDCodec seals session and handle IDs with AES-256-GCM, the room key is bound as
+// additional data so tokens cannot be reused across rooms or lives
 type janusIDCodec struct {
-	key []byte
+	keys atomic.Pointer[codecKeys]
 }
 
-// AES-256-GCM encrypts two int64 packed into 16 bytes.
-// Output token: standard Base64 of nonce(12) || ciphertext+tag
-func (c *janusIDCodec) Encode(roomKey string, sessionID, handleID int64) (string, error) {
-	plain := make([]byte, 18)
-	plain[0] = 'J'
-	plain[1] = 'T'
-	binary.BigEndian.PutUint64(plain[2:10], uint64(sessionID))   // #nosec G115 -- sessionID is int64, conversion to uint64 is safe for binary encoding
-	binary.BigEndian.PutUint64(plain[10:18], uint64(handleID)) // #nosec G115 -- handleID is int64, conversion to uint64 is safe for binary encoding
+// codecKeys are the key tokens are sealed with and the one before its rotation, if any
+type codecKeys struct {
+	current  cipher.AEAD
+	previous cipher.AEAD
+}
 
-	block, err := aes.NewCipher(c.key)
-	if err != nil {
-		return "", err
-	}
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
+// Encode produces a compact token. Janus IDs fit in 53 bits, IDs out of the 7 bytes range
+// fall back to the legacy format
+func (c *janusIDCodec) Encode(roomKey string, sessionID, handleID int64) (string, error) {
+	if !fitsCompact(sessionID) || !fitsCompact(handleID) {
+		return c.encodeLegacy(roomKey, sessionID, handleID)
 	}
+	gcm := c.keys.Load().current
 
-	nonce := make([]byte, gcm.NonceSize()) // 12 bytes
+	plain := make([]byte, compactPlainLen)
+	putCompactID(plain[:compactIDBytes], sessionID)
+	putCompactID(plain[compactIDBytes:], handleID)
+
+	raw := make([]byte, 1+gcm.NonceSize(), compactRawLen)
+	raw[0] = compactVersion
+	nonce := raw[1:]
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return "", err
 	}
+	raw = gcm.Seal(raw, nonce, plain, compactAAD(roomKey))
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
 
-	// Bind ciphertext to this userId (prevents swapping token across users)
-	aad := []byte(roomKey)
-	ciphertext := gcm.Seal(nil, nonce, plain, aad)
+// Decode accepts compact tokens and legacy ones issued before the compact format
+func (c *janusIDCodec) Decode(roomKey string, token string) (int64, int64, error) {
+	keys := c.keys.Load()
+	sessionID, handleID, err := decode(keys.current, roomKey, token)
+	if err != nil && keys.previous != nil {
+		// sealed before the key rotated
+		return decode(keys.previous, roomKey, token)
+	}
+	return sessionID, handleID, err
+}
 
-	raw := nonce
-	raw = append(raw, ciphertext...)
-	return base64.StdEncoding.EncodeToString(raw), nil
+func decode(gcm cipher.AEAD, roomKey string, token string) (int64, int64, error) {
+	if len(token) == compactTokenLen {
+		return decodeCompact(gcm, roomKey, token)
+	}
+	return decodeLegacy(gcm, roomKey, token)
 }
 
-func (c *janusIDCodec) Decode(roomKey string, token string) (int64, int64, error) {
-	raw, err := base64.StdEncoding.DecodeString(token)
+func decodeCompact(gcm cipher.AEAD, roomKey string, token string) (int64, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
 	if err != nil {
 		return 0, 0, err
 	}
+	if raw[0] != compactVersion {
+		return 0, 0, errors.Errorf("unsupported janus token version %d", raw[0])
+	}
 
-	block, err := aes.NewCipher(c.key)
+	ns := gcm.NonceSize()
+	nonce := raw[1 : 1+ns]
+	plain, err := gcm.Open(nil, nonce, raw[1+ns:], compactAAD(roomKey))
 	if err != nil {
 		return 0, 0, err
 	}
-	gcm, err := cipher.NewGCM(block)
+	if len(plain) != compactPlainLen {
+		return 0, 0, errors.New("unexpected plaintext length")
+	}
+	return readCompactID(plain[:compactIDBytes]), readCompactID(plain[compactIDBytes:]), nil
+}
+
+// encodeLegacy is standard Base64 of nonce(12) || seal("JT" || session(8) || handle(8)) + tag(16)
+func (c *janusIDCodec) encodeLegacy(roomKey string, sessionID, handleID int64) (string, error) {
+	gcm := c.keys.Load().current
+	plain := make([]byte, legacyPlainLen)
+	plain[0] = 'J'
+	plain[1] = 'T'
+	binary.BigEndian.PutUint64(plain[2:10], uint64(sessionID)) // #nosec G115 -- sessionID is int64, conversion to uint64 is safe for binary encoding
+	binary.BigEndian.PutUint64(plain[10:18], uint64(handleID)) // #nosec G115 -- handleID is int64, conversion to uint64 is safe for binary encoding
+
+	nonce := make([]byte, gcm.NonceSize()) // 12 bytes
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	// Bind ciphertext to this roomKey (prevents swapping token across rooms)
+	raw := gcm.Seal(nonce, nonce, plain, []byte(roomKey))
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+func decodeLegacy(gcm cipher.AEAD, roomKey string, token string) (int64, int64, error) {
+	raw, err := base64.StdEncoding.DecodeString(token)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -85,20 +179,40 @@ func (c *janusIDCodec) Decode(roomKey string, token string) (int64, int64, error
 	}
 	nonce := raw[:ns]
 	ciphertext := raw[ns:]
-	aad := []byte(roomKey)
 
-	plain, err := gcm.Open(nil, nonce, ciphertext, aad)
+	plain, err := gcm.Open(nil, nonce, ciphertext, []byte(roomKey))
 	if err != nil {
 		return 0, 0, err
 	}
-	if len(plain) != 18 {
+	if len(plain) != legacyPlainLen {
 		return 0, 0, errors.New("unexpected plaintext length")
 	}
 	if plain[0] != 'J' || plain[1] != 'T' {
 		return 0, 0, errors.New("invalid janus token prefix")
 	}
 
-	sessionID := int64(binary.BigEndian.Uint64(plain[2:10]))  // #nosec G115 -- uint64 to int64 conversion is safe, values come from our own encoding
+	sessionID := int64(binary.BigEndian.Uint64(plain[2:10])) // #nosec G115 -- uint64 to int64 conversion is safe, values come from our own encoding
 	handleID := int64(binary.BigEndian.Uint64(plain[10:18])) // #nosec G115 -- uint64 to int64 conversion is safe, values come from our own encoding
 	return sessionID, handleID, nil
 }
+
+func fitsCompact(id int64) bool {
+	return id >= 0 && id <= compactMaxID
+}
+
+func putCompactID(b []byte, id int64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(id)) // #nosec G115 -- range checked by fitsCompact
+	copy(b, buf[8-compactIDBytes:])
+}
+
+func readCompactID(b []byte) int64 {
+	var buf [8]byte
+	copy(buf[8-compactIDBytes:], b)
+	return int64(binary.BigEndian.Uint64(buf[:])) // #nosec G115 -- at most 56 bits
+}
+
+// compactAAD binds the version too, a token cannot be replayed as another format
+func compactAAD(roomKey string) []byte {
+	return append([]byte{compactVersion}, roomKey...)
+}
@@ -1,10 +1,18 @@
 package janusproxy
 
 import (
+	"context"
 	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/config/secret"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
 )
 
 type TokenCodecSuite struct {
@@ -256,12 +264,102 @@ func (s *TokenCodecSuite) TestTokenFormat() {
 	token, err := s.codec.Encode(roomKey, sessionID, handleID)
 	s.Require().NoError(err)
 
-	// Token should be base64 standard encoding (not URL encoding)
-	// and should have reasonable length
-	// Nonce (12) + Ciphertext (18) + GCM tag (16) = 46 bytes raw
-	// Base64 encoding: 46 * 4/3 ≈ 62 characters (with padding)
-	s.Greater(len(token), 50)
-	s.Less(len(token), 100)
+	// Version (1) + Nonce (12) + Ciphertext (14) + GCM tag (16) = 43 bytes raw
+	// Unpadded URL-safe base64: 58 characters
+	s.Len(token, 58)
+	s.NotContains(token, "=")
+	s.NotContains(token, "+")
+	s.NotContains(token, "/")
+}
+
+func (s *TokenCodecSuite) TestEncode_LegacyFallback() {
+	testCases := []struct {
+		name      string
+		sessionID int64
+		handleID  int64
+	}{
+		{"Beyond 7 bytes", 1 << 56, 1},
+		{"Negative", 1, -1},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			token, err := s.codec.Encode("room123", tc.sessionID, tc.handleID)
+			s.Require().NoError(err)
+			s.Len(token, 64)
+
+			sessionID, handleID, err := s.codec.Decode("room123", token)
+			s.Require().NoError(err)
+			s.Equal(tc.sessionID, sessionID)
+			s.Equal(tc.handleID, handleID)
+		})
+	}
+}
+
+func (s *TokenCodecSuite) TestDecode_LegacyToken() {
+	// tokens issued before the compact format stay valid
+	token, err := s.codec.encodeLegacy("room123", 123456, 789012)
+	s.Require().NoError(err)
+
+	sessionID, handleID, err := s.codec.Decode("room123", token)
+	s.Require().NoError(err)
+	s.Equal(int64(123456), sessionID)
+	s.Equal(int64(789012), handleID)
+}
+
+func (s *TokenCodecSuite) TestDecode_UnsupportedVersion() {
+	token, err := s.codec.Encode("room123", 123456, 789012)
+	s.Require().NoError(err)
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	s.Require().NoError(err)
+	raw[0] = 2
+
+	_, _, err = s.codec.Decode("room123", base64.RawURLEncoding.EncodeToString(raw))
+	s.Require().Error(err)
+	s.Contains(err.Error(), "unsupported janus token version")
+}
+
+func (s *TokenCodecSuite) TestKeyRotation() {
+	path := filepath.Join(s.T().TempDir(), "janus-key")
+	s.Require().NoError(os.WriteFile(path, []byte("first-janus-token-key-32-bytes!!"), 0o600))
+	resolver := secret.NewResolver(&secret.Config{RefreshInterval: 10 * time.Millisecond}, log.NewNop())
+	sec, err := resolver.Get(context.Background(), "file:"+path)
+	s.Require().NoError(err)
+	s.Require().NoError(resolver.Start(context.Background()))
+	defer resolver.Stop()
+
+	codec, err := NewJanusTokenCodecWithSecret(sec)
+	s.Require().NoError(err)
+	before, err := codec.Encode("room123", 1, 2)
+	s.Require().NoError(err)
+
+	// a key of the wrong size is rejected, the codec keeps its key
+	s.Require().NoError(os.WriteFile(path, []byte("too-short"), 0o600))
+	s.Require().Eventually(func() bool { return sec.Value() == "too-short" }, time.Second, 10*time.Millisecond)
+	token, err := codec.Encode("room123", 1, 2)
+	s.Require().NoError(err)
+	_, _, err = codec.Decode("room123", token)
+	s.Require().NoError(err)
+
+	s.Require().NoError(os.WriteFile(path, []byte("second-janus-token-key-32-bytes!"), 0o600))
+	s.Require().Eventually(func() bool { return sec.Value() == "second-janus-token-key-32-bytes!" }, time.Second, 10*time.Millisecond)
+
+	// sealed with the new key, tokens of the previous key still decode
+	after, err := codec.Encode("room123", 3, 4)
+	s.Require().NoError(err)
+	previous, err := NewJanusTokenCodec([]byte("first-janus-token-key-32-bytes!!"))
+	s.Require().NoError(err)
+	_, _, err = previous.Decode("room123", after)
+	s.Require().Error(err)
+
+	sessionID, handleID, err := codec.Decode("room123", before)
+	s.Require().NoError(err)
+	s.Equal(int64(1), sessionID)
+	s.Equal(int64(2), handleID)
+	sessionID, handleID, err = codec.Decode("room123", after)
+	s.Require().NoError(err)
+	s.Equal(int64(3), sessionID)
+	s.Equal(int64(4), handleID)
 }
 
 func (s *TokenCodecSuite) TestConcurrentEncodeDecode() {
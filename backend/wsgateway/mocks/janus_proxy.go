@@ -85,6 +85,20 @@ func (mr *MockJanusProxyMockRecorder) GetJanusRoomID(roomID any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJanusRoomID", reflect.TypeOf((*MockJanusProxy)(nil).GetJanusRoomID), roomID)
 }
 
+// GetLinkGroup mocks base method.
+func (m *MockJanusProxy) GetLinkGroup(roomID, userID string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLinkGroup", roomID, userID)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetLinkGroup indicates an expected call of GetLinkGroup.
+func (mr *MockJanusProxyMockRecorder) GetLinkGroup(roomID, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLinkGroup", reflect.TypeOf((*MockJanusProxy)(nil).GetLinkGroup), roomID, userID)
+}
+
 // GetRoomLiveMeta mocks base method.
 func (m *MockJanusProxy) GetRoomLiveMeta(roomId string) *etcdstate.LiveMeta {
 	m.ctrl.T.Helper()
@@ -113,6 +127,18 @@ func (mr *MockJanusProxyMockRecorder) GetRoomMeta(roomId any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoomMeta", reflect.TypeOf((*MockJanusProxy)(nil).GetRoomMeta), roomId)
 }
 
+// OnRoomChange mocks base method.
+func (m *MockJanusProxy) OnRoomChange(fn func(string)) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnRoomChange", fn)
+}
+
+// OnRoomChange indicates an expected call of OnRoomChange.
+func (mr *MockJanusProxyMockRecorder) OnRoomChange(fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnRoomChange", reflect.TypeOf((*MockJanusProxy)(nil).OnRoomChange), fn)
+}
+
 // Open mocks base method.
 func (m *MockJanusProxy) Open(ctx context.Context) error {
 	m.ctrl.T.Helper()
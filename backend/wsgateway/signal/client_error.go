@@ -0,0 +1,228 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	redisrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/redis"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+)
+
+// EventRoomClientErrors is published to the analytics stream with the client errors reported
+// in a room over a flush interval
+const EventRoomClientErrors = "roomClientErrors"
+
+// ClientErrorCode classifies the WebRTC failures reported by clients
+type ClientErrorCode string
+
+const (
+	ClientErrorICEFailed   ClientErrorCode = "ice_failed"
+	ClientErrorMediaDenied ClientErrorCode = "media_denied"
+	ClientErrorDevice      ClientErrorCode = "device_error"
+	ClientErrorNegotiation ClientErrorCode = "negotiation_failed"
+	ClientErrorOther       ClientErrorCode = "other"
+)
+
+// clientErrorParams is a failure reported by the client, detail holds client specifics such as
+// the ICE connection state or the device kind
+type clientErrorParams struct {
+	roomParams
+	Code    ClientErrorCode   `json:"code" validate:"required,oneof=ice_failed media_denied device_error negotiation_failed other"`
+	Message string            `json:"message" validate:"max=512"`
+	Detail  map[string]string `json:"detail" validate:"max=16,dive,keys,max=64,endkeys,max=256"`
+}
+
+// RoomClientErrors counts the client errors of a room by code, with the users reporting them
+type RoomClientErrors struct {
+	RoomID string                  `json:"roomId"`
+	Since  time.Time               `json:"since"`
+	Until  time.Time               `json:"until"`
+	Counts map[ClientErrorCode]int `json:"counts"`
+	Users  int                     `json:"users"`
+}
+
+type ClientErrorConfig struct {
+	// Stream is the analytics stream aggregates are published to, empty only logs the errors
+	Stream     string                 `mapstructure:"stream"`
+	StreamTrim redisstream.TrimPolicy `mapstructure:"stream_trim"`
+	// FlushInterval is the period errors are aggregated over
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+}
+
+func SetupClientErrors(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("stream"), "")
+	v.SetDefault(p("stream_trim.max_len"), 0)
+	v.SetDefault(p("stream_trim.max_age"), 24*time.Hour)
+	v.SetDefault(p("flush_interval"), time.Minute)
+}
+
+type roomClientErrors struct {
+	counts map[ClientErrorCode]int
+	users  map[string]struct{}
+}
+
+// ClientErrorReporter aggregates the client errors reported to this gateway by room and
+// publishes them to the analytics stream every flush interval
+type ClientErrorReporter struct {
+	peer          jsonrpc.Peer[any]
+	trimer        redisstream.Trimer
+	trimPolicy    *redisstream.TrimPolicy
+	flushInterval time.Duration
+	clock         clockwork.Clock
+
+	mu      sync.Mutex
+	since   time.Time
+	pending map[string]*roomClientErrors // roomID -> errors since
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	logger *log.Logger
+}
+
+// NewClientErrorReporter returns nil when the analytics stream is not set
+func NewClientErrorReporter(client *redis.Client, cfg *ClientErrorConfig, logger *log.Logger) (*ClientErrorReporter, error) {
+	if cfg.Stream == "" {
+		return nil, nil //nolint:nilnil
+	}
+	peer, err := redisrpc.NewPeer[any](client, cfg.Stream, "", "", logger.Module("Peer"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create analytics peer: %w", err)
+	}
+	clock := clockwork.NewRealClock()
+	return &ClientErrorReporter{
+		peer:          peer,
+		trimer:        redisstream.NewTrimer(client, cfg.Stream, logger.Module("Trimer")),
+		trimPolicy:    &cfg.StreamTrim,
+		flushInterval: cfg.FlushInterval,
+		clock:         clock,
+		since:         clock.Now(),
+		pending:       make(map[string]*roomClientErrors),
+		logger:        logger,
+	}, nil
+}
+
+// Report counts the error of the user in the room
+func (r *ClientErrorReporter) Report(roomID, userID string, code ClientErrorCode) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	room, ok := r.pending[roomID]
+	if !ok {
+		room = &roomClientErrors{
+			counts: make(map[ClientErrorCode]int),
+			users:  make(map[string]struct{}),
+		}
+		r.pending[roomID] = room
+	}
+	room.counts[code]++
+	room.users[userID] = struct{}{}
+}
+
+func (r *ClientErrorReporter) Start(ctx context.Context) error {
+	if err := r.peer.Open(ctx); err != nil {
+		return fmt.Errorf("failed to open analytics peer: %w", err)
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		ticker := r.clock.NewTicker(r.flushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.Chan():
+				r.flush(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop publishes the errors aggregated so far
+func (r *ClientErrorReporter) Stop() error {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r.flush(ctx)
+	return r.peer.Close()
+}
+
+func (r *ClientErrorReporter) flush(ctx context.Context) {
+	now := r.clock.Now()
+	r.mu.Lock()
+	pending, since := r.pending, r.since
+	r.pending = make(map[string]*roomClientErrors)
+	r.since = now
+	r.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+	for roomID, room := range pending {
+		err := r.peer.Notify(ctx, EventRoomClientErrors, &RoomClientErrors{
+			RoomID: roomID,
+			Since:  since,
+			Until:  now,
+			Counts: room.counts,
+			Users:  len(room.users),
+		})
+		if err != nil {
+			r.logger.Error("Failed to publish client errors", log.String("roomId", roomID), log.Error(err))
+		}
+	}
+	if _, err := r.trimer.Trim(ctx, r.trimPolicy); err != nil {
+		r.logger.Error("Failed to trim analytics stream", log.Error(err))
+	}
+}
+
+// handleClientError logs a failure reported by the client with its connection context, so
+// support can see why an anchor cannot go live
+func (s *Server) handleClientError(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+
+	var data clientErrorParams
+	if err := jsonrpc.ShouldBindParams(params, &data); err != nil {
+		return nil, err
+	}
+	roomID := rtcCtx.targetRoomID(params)
+	room := rtcCtx.room(roomID)
+	if room == nil {
+		return nil, jsonrpc.ErrInvalidParams("not in the room")
+	}
+
+	s.logger.Warn("Client error reported",
+		log.String("connId", rtcCtx.connID),
+		log.String("clientId", rtcCtx.clientID),
+		log.String("userId", rtcCtx.userID),
+		log.String("roomId", roomID),
+		log.String("role", string(room.role)),
+		log.Bool("joined", room.joined),
+		log.String("code", string(data.Code)),
+		log.String("message", data.Message),
+		log.Any("detail", data.Detail))
+
+	if s.errorReporter != nil {
+		s.errorReporter.Report(roomID, rtcCtx.userID, data.Code)
+	}
+
+	//nolint:nilnil
+	return nil, nil
+}
@@ -0,0 +1,101 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jonboulle/clockwork"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+func TestClientErrorReporter(t *testing.T) {
+	newReporter := func(t *testing.T, stream string) (*ClientErrorReporter, *redis.Client) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+
+		r, err := NewClientErrorReporter(client, &ClientErrorConfig{
+			Stream:        stream,
+			FlushInterval: time.Minute,
+		}, log.NewNop())
+		require.NoError(t, err)
+		return r, client
+	}
+
+	t.Run("disabled without stream", func(t *testing.T) {
+		r, _ := newReporter(t, "")
+		assert.Nil(t, r)
+	})
+
+	t.Run("publishes aggregates per room", func(t *testing.T) {
+		ctx := context.Background()
+		r, client := newReporter(t, "analytics")
+		clock := clockwork.NewFakeClock()
+		r.clock = clock
+		r.since = clock.Now()
+		require.NoError(t, r.peer.Open(ctx))
+
+		r.Report("room1", "user1", ClientErrorICEFailed)
+		r.Report("room1", "user1", ClientErrorICEFailed)
+		r.Report("room1", "user2", ClientErrorMediaDenied)
+		r.Report("room2", "user3", ClientErrorDevice)
+		clock.Advance(time.Minute)
+		r.flush(ctx)
+
+		entries, err := client.XRange(ctx, "analytics", "-", "+").Result()
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		byRoom := map[string]RoomClientErrors{}
+		for _, entry := range entries {
+			var msg struct {
+				Method string           `json:"method"`
+				Params RoomClientErrors `json:"params"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(entry.Values["data"].(string)), &msg))
+			assert.Equal(t, EventRoomClientErrors, msg.Method)
+			byRoom[msg.Params.RoomID] = msg.Params
+		}
+		assert.Equal(t, map[ClientErrorCode]int{ClientErrorICEFailed: 2, ClientErrorMediaDenied: 1}, byRoom["room1"].Counts)
+		assert.Equal(t, 2, byRoom["room1"].Users)
+		assert.Equal(t, time.Minute, byRoom["room1"].Until.Sub(byRoom["room1"].Since))
+		assert.Equal(t, 1, byRoom["room2"].Users)
+
+		// nothing reported since
+		r.flush(ctx)
+		assert.Equal(t, int64(2), client.XLen(ctx, "analytics").Val())
+	})
+}
+
+func (s *ServerSuite) TestHandleClientError() {
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: context.Background(),
+		roomID: "room1",
+		userID: "user1",
+	}, &roomContext{})
+	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
+
+	call := func(params map[string]any) error {
+		data, _ := json.Marshal(params)
+		raw := json.RawMessage(data)
+		_, err := s.server.handleClientError(mctx, &raw)
+		return err
+	}
+
+	// allowed before joining
+	s.NoError(call(map[string]any{
+		"code":    "media_denied",
+		"message": "Permission denied",
+		"detail":  map[string]string{"name": "NotAllowedError"},
+	}))
+	s.Error(call(map[string]any{"code": "unknown"}))
+	s.Error(call(map[string]any{"message": "no code"}))
+	s.Error(call(map[string]any{"roomId": "room2", "code": "ice_failed"}))
+}
@@ -4,53 +4,101 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sync"
 
+	"github.com/jonboulle/clockwork"
 	"github.com/redis/go-redis/v9"
 
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
 	redisrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/redis"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
 	"github.com/imtaco/audio-rtc-exp/users"
 )
 
-// WSConnManager manages WebSocket connections and broadcasts messages to clients in rooms
+const (
+	// participantMethod tells anchors a participant joined, left or (un)muted in the Janus room
+	participantMethod = "participant"
+	// roomStatusMethod carries the members of the token room of the connection
+	roomStatusMethod = "roomStatus"
+	// roomMembersMethod carries the members of the other rooms joined over the connection
+	roomMembersMethod = "roomMembers"
+	// roomBroadcastMethod carries notifications backends broadcast to the room
+	roomBroadcastMethod = "roomBroadcast"
+)
+
+// WSConnManager manages WebSocket connections and broadcasts messages to clients in rooms,
+// connections are indexed by (connId, roomId) as one may join several rooms
 type WSConnManager struct {
 	room2clients map[string]map[string]jsonrpc.Conn[rtcContext] // roomId -> connId -> Client
-	client2room  map[string]string                              // connId -> roomId
+	client2rooms map[string]map[string]struct{}                 // connId -> roomIds
 	clientsMux   sync.RWMutex
+	joins        *joinRate
 	peer2ws      jsonrpc.Peer[any]
+	partitions   *notifyPartitions // replaces peer2ws when the stream is partitioned
 	logger       *log.Logger
 }
 
+// NewWSConnMgr creates the manager relaying ws-notify notifications to clients. Every gateway
+// reads the whole stream unless partitions > 0, then each reads the partitions it owns.
+// Notifications sent by the gateway are buffered when notifyBuffer is enabled
 func NewWSConnMgr(
 	redisClient *redis.Client,
 	wsStreamName string,
+	partitions int,
+	notifyBuffer *redisstream.BufferConfig,
+	connGuard ConnectionGuard,
 	logger *log.Logger,
 ) (*WSConnManager, error) {
-	peer2ws, err := redisrpc.NewPeer[any](
+	m := &WSConnManager{
+		room2clients: make(map[string]map[string]jsonrpc.Conn[rtcContext]),
+		client2rooms: make(map[string]map[string]struct{}),
+		joins:        newJoinRate(clockwork.NewRealClock()),
+		logger:       logger,
+	}
+
+	if partitions > 0 {
+		var err error
+		m.partitions, err = newNotifyPartitions(
+			redisClient,
+			wsStreamName,
+			partitions,
+			notifyBuffer,
+			connGuard,
+			m.register,
+			logger.Module("RPCWsIN"),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	peer2ws, err := redisrpc.NewBufferedPeer[any](
 		redisClient,
-		"", // consumer only, no need to specify producer name
+		wsStreamName, // moderator notifications are relayed to all gateways
 		wsStreamName,
 		"", // broadcast to all consumers, no need to specify group name
+		notifyBuffer,
 		logger.Module("RPCWsIN"),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create WS RPC peer: %w", err)
 	}
 
-	return &WSConnManager{
-		peer2ws:      peer2ws,
-		room2clients: make(map[string]map[string]jsonrpc.Conn[rtcContext]),
-		client2room:  make(map[string]string),
-		logger:       logger,
-	}, nil
+	m.peer2ws = peer2ws
+	return m, nil
 }
 
 func (m *WSConnManager) Start(ctx context.Context) error {
 	m.logger.Info("Starting WebSocket client manager")
-	m.register()
+	if m.partitions != nil {
+		return m.partitions.Start(ctx)
+	}
 
+	m.register(m.peer2ws)
 	if err := m.peer2ws.Open(ctx); err != nil {
 		return fmt.Errorf("failed to open WS RPC peer: %w", err)
 	}
@@ -59,15 +107,26 @@ func (m *WSConnManager) Start(ctx context.Context) error {
 
 func (m *WSConnManager) Stop(_ context.Context) error {
 	m.logger.Info("Stopping WebSocket client manager")
+	if m.partitions != nil {
+		m.partitions.Stop()
+		return nil
+	}
 	if err := m.peer2ws.Close(); err != nil {
 		m.logger.Error("Failed to close WS RPC peer", log.Error(err))
 	}
 	return nil
 }
 
-func (m *WSConnManager) register() {
-	m.peer2ws.Def("broadcastRoomStatus", m.handleBroadcast)
-
+func (m *WSConnManager) register(peer jsonrpc.Peer[any]) {
+	peer.Def("broadcastRoomStatus", m.handleBroadcast)
+	peer.Def("notifyModerators", m.handleNotifyModerators)
+	peer.Def("floorGranted", m.handleFloorGranted)
+	peer.Def("userEvicted", m.handleUserEvicted)
+	peer.Def("participantEvent", m.handleParticipantEvent)
+	peer.Def("connReplaced", m.handleConnReplaced)
+	peer.Def("roomBroadcast", m.handleRoomBroadcast)
+	peer.Def("publishChanged", m.handlePublishChanged)
+	peer.Def("joinQueueChanged", m.handleJoinQueueChanged)
 }
 
 func (m *WSConnManager) handleBroadcast(
@@ -83,17 +142,248 @@ func (m *WSConnManager) handleBroadcast(
 	}
 
 	m.logger.Debug("broadcastRoomStatus request", log.Any("req", req))
-	m.notifyRoomLocalPeer(req.RoomID, "roomStatus", req.Members)
+	// roomStatus has no room, connections get it for their token room and roomMembers for others
+	for _, conn := range m.getRoomConns(req.RoomID) {
+		rtcCtx := conn.Context().Get()
+		var err error
+		if rtcCtx.roomID == req.RoomID {
+			err = conn.Notify(rtcCtx.reqCtx, roomStatusMethod, req.Members)
+		} else {
+			err = conn.Notify(rtcCtx.reqCtx, roomMembersMethod, &req)
+		}
+		if err != nil {
+			m.logger.Error("Failed to send to client",
+				log.String("roomId", req.RoomID),
+				log.Error(err),
+			)
+		}
+	}
 
 	//nolint:nilnil
 	return nil, nil
 }
 
+// handleFloorGranted notifies the room, and has the connections of the speaker unmute themselves
+// on their own handler goroutine
+func (m *WSConnManager) handleFloorGranted(
+	_ jsonrpc.MethodContext[any],
+	params *json.RawMessage,
+) (any, error) {
+	var req users.NotifyFloorGranted
+	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+		return nil, err
+	}
+
+	m.notifyRoomLocalPeer(req.RoomID, "floorGranted", &req)
+	if !req.Unmute {
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	// connection state belongs to the connection handlers, they check whether they are the speaker
+	for _, conn := range m.getRoomConns(req.RoomID) {
+		if err := conn.Dispatch(context.Background(), floorUnmuteMethod, &req); err != nil {
+			m.logger.Debug("Failed to dispatch unmute",
+				log.String("roomId", req.RoomID),
+				log.String("userId", req.UserID),
+				log.Error(err))
+		}
+	}
+
+	//nolint:nilnil
+	return nil, nil
+}
+
+// handlePublishChanged has the connections of the user granted or revoked a publish slot unmute
+// or mute themselves on their own handler goroutine
+func (m *WSConnManager) handlePublishChanged(
+	_ jsonrpc.MethodContext[any],
+	params *json.RawMessage,
+) (any, error) {
+	var req users.NotifyPublish
+	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+		return nil, err
+	}
+
+	for _, conn := range m.getRoomConns(req.RoomID) {
+		if err := conn.Dispatch(context.Background(), publishChangeMethod, &req); err != nil {
+			m.logger.Debug("Failed to dispatch publish change",
+				log.String("roomId", req.RoomID),
+				log.String("userId", req.UserID),
+				log.Error(err))
+		}
+	}
+
+	//nolint:nilnil
+	return nil, nil
+}
+
+// handleJoinQueueChanged dispatches the join queue of a room to the connections of the users
+// queued or admitted
+func (m *WSConnManager) handleJoinQueueChanged(
+	_ jsonrpc.MethodContext[any],
+	params *json.RawMessage,
+) (any, error) {
+	var req users.NotifyJoinQueue
+	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+		return nil, err
+	}
+
+	for _, conn := range m.getRoomConns(req.RoomID) {
+		userID := conn.Context().Get().userID
+		if !slices.Contains(req.Queue, userID) && !slices.Contains(req.Admitted, userID) {
+			continue
+		}
+		if err := conn.Dispatch(context.Background(), queueChangeMethod, &req); err != nil {
+			m.logger.Debug("Failed to dispatch join queue change",
+				log.String("roomId", req.RoomID),
+				log.String("userId", userID),
+				log.Error(err))
+		}
+	}
+
+	//nolint:nilnil
+	return nil, nil
+}
+
+// handleParticipantEvent tells the other anchors and hosts of the room who joined, left or
+// (un)muted in the Janus room
+func (m *WSConnManager) handleParticipantEvent(
+	_ jsonrpc.MethodContext[any],
+	params *json.RawMessage,
+) (any, error) {
+	var req users.NotifyParticipant
+	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+		return nil, err
+	}
+
+	for _, conn := range m.getRoomConns(req.RoomID) {
+		rtcCtx := conn.Context().Get()
+		if rtcCtx.roleIn(req.RoomID) == constants.UserRoleGuest || rtcCtx.userID == req.UserID {
+			continue
+		}
+		if err := conn.Notify(rtcCtx.reqCtx, participantMethod, &req); err != nil {
+			m.logger.Error("Failed to notify participant event",
+				log.String("roomId", req.RoomID),
+				log.String("connId", rtcCtx.connID),
+				log.Error(err))
+		}
+	}
+
+	//nolint:nilnil
+	return nil, nil
+}
+
+// handleRoomBroadcast passes a notification broadcast by a backend on to the anchors and hosts
+// of the room, guests only listen to HLS and get none
+func (m *WSConnManager) handleRoomBroadcast(
+	_ jsonrpc.MethodContext[any],
+	params *json.RawMessage,
+) (any, error) {
+	var req users.NotifyBroadcast
+	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+		return nil, err
+	}
+
+	for _, conn := range m.getRoomConns(req.RoomID) {
+		rtcCtx := conn.Context().Get()
+		if rtcCtx.roleIn(req.RoomID) == constants.UserRoleGuest {
+			continue
+		}
+		if err := conn.Notify(rtcCtx.reqCtx, roomBroadcastMethod, &req); err != nil {
+			m.logger.Error("Failed to notify room broadcast",
+				log.String("roomId", req.RoomID),
+				log.String("connId", rtcCtx.connID),
+				log.Error(err))
+		}
+	}
+
+	//nolint:nilnil
+	return nil, nil
+}
+
+// handleUserEvicted has the connections of the evicted user release its Janus handle on their own
+// handler goroutine
+func (m *WSConnManager) handleUserEvicted(
+	_ jsonrpc.MethodContext[any],
+	params *json.RawMessage,
+) (any, error) {
+	var req users.NotifyUserEvicted
+	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+		return nil, err
+	}
+
+	for _, conn := range m.getRoomConns(req.RoomID) {
+		if err := conn.Dispatch(context.Background(), userEvictedMethod, &req); err != nil {
+			m.logger.Debug("Failed to dispatch eviction",
+				log.String("roomId", req.RoomID),
+				log.String("userId", req.UserID),
+				log.Error(err))
+		}
+	}
+
+	//nolint:nilnil
+	return nil, nil
+}
+
+// NotifyModerators sends method to the hosts of the room, whichever gateway they are connected to
+func (m *WSConnManager) NotifyModerators(ctx context.Context, roomID, method string, params any) error {
+	notify := &moderatorNotify{
+		RoomID: roomID,
+		Method: method,
+		Params: params,
+	}
+	if m.partitions != nil {
+		return m.partitions.notifier.Notify(ctx, roomID, "notifyModerators", notify)
+	}
+	return m.peer2ws.Notify(ctx, "notifyModerators", notify)
+}
+
+func (m *WSConnManager) handleNotifyModerators(
+	_ jsonrpc.MethodContext[any],
+	params *json.RawMessage,
+) (any, error) {
+	// params are forwarded as is
+	var req struct {
+		RoomID string          `json:"roomId"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+		return nil, err
+	}
+
+	for _, conn := range m.getRoomConns(req.RoomID) {
+		rtcCtx := conn.Context().Get()
+		if rtcCtx.roleIn(req.RoomID) != constants.UserRoleHost {
+			continue
+		}
+		if err := conn.Notify(rtcCtx.reqCtx, req.Method, req.Params); err != nil {
+			m.logger.Error("Failed to notify moderator",
+				log.String("roomId", req.RoomID),
+				log.String("connId", rtcCtx.connID),
+				log.Error(err))
+		}
+	}
+
+	//nolint:nilnil
+	return nil, nil
+}
+
+// AddClient adds the connection to the room, a connection may be in several rooms
 func (m *WSConnManager) AddClient(connID, roomID string, peer jsonrpc.Conn[rtcContext]) {
 	m.clientsMux.Lock()
 	defer m.clientsMux.Unlock()
 
-	m.client2room[connID] = roomID
+	rooms, ok := m.client2rooms[connID]
+	if !ok {
+		rooms = make(map[string]struct{})
+		m.client2rooms[connID] = rooms
+	}
+	if _, ok := rooms[roomID]; ok {
+		return
+	}
+	rooms[roomID] = struct{}{}
 
 	room, ok := m.room2clients[roomID]
 	if !ok {
@@ -101,6 +391,7 @@ func (m *WSConnManager) AddClient(connID, roomID string, peer jsonrpc.Conn[rtcCo
 		m.room2clients[roomID] = room
 	}
 	room[connID] = peer
+	m.joins.inc()
 
 	m.logger.Debug("Client joined",
 		log.String("connId", connID),
@@ -108,14 +399,32 @@ func (m *WSConnManager) AddClient(connID, roomID string, peer jsonrpc.Conn[rtcCo
 	)
 }
 
+// RemoveClient removes the connection from all its rooms
 func (m *WSConnManager) RemoveClient(connID string) {
 	m.clientsMux.Lock()
 	defer m.clientsMux.Unlock()
 
-	roomID, ok := m.client2room[connID]
+	for roomID := range m.client2rooms[connID] {
+		m.removeClientRoom(connID, roomID)
+	}
+}
+
+// RemoveClientRoom removes the connection from one of its rooms
+func (m *WSConnManager) RemoveClientRoom(connID, roomID string) {
+	m.clientsMux.Lock()
+	defer m.clientsMux.Unlock()
+
+	m.removeClientRoom(connID, roomID)
+}
+
+func (m *WSConnManager) removeClientRoom(connID, roomID string) {
+	rooms, ok := m.client2rooms[connID]
 	if !ok {
 		return
 	}
+	if _, ok := rooms[roomID]; !ok {
+		return
+	}
 	if room, ok := m.room2clients[roomID]; ok {
 		delete(room, connID)
 		if len(room) == 0 {
@@ -123,7 +432,10 @@ func (m *WSConnManager) RemoveClient(connID string) {
 		}
 	}
 
-	delete(m.client2room, connID)
+	delete(rooms, roomID)
+	if len(rooms) == 0 {
+		delete(m.client2rooms, connID)
+	}
 
 	m.logger.Debug("Client removed from room",
 		log.String("connId", connID),
@@ -141,7 +453,11 @@ func (m *WSConnManager) RemoveRoom(roomID string) {
 	}
 
 	for connID := range room {
-		delete(m.client2room, connID)
+		rooms := m.client2rooms[connID]
+		delete(rooms, roomID)
+		if len(rooms) == 0 {
+			delete(m.client2rooms, connID)
+		}
 	}
 	delete(m.room2clients, roomID)
 
@@ -164,11 +480,52 @@ func (m *WSConnManager) getRoomConns(roomID string) []jsonrpc.Conn[rtcContext] {
 	return conns
 }
 
+// roomIDs returns the rooms with connections on this gateway
+func (m *WSConnManager) roomIDs() []string {
+	m.clientsMux.RLock()
+	defer m.clientsMux.RUnlock()
+
+	roomIDs := make([]string, 0, len(m.room2clients))
+	for roomID := range m.room2clients {
+		roomIDs = append(roomIDs, roomID)
+	}
+	return roomIDs
+}
+
+// allConns returns the connections of all rooms, once each
+func (m *WSConnManager) allConns() []jsonrpc.Conn[rtcContext] {
+	m.clientsMux.RLock()
+	defer m.clientsMux.RUnlock()
+
+	conns := make([]jsonrpc.Conn[rtcContext], 0, len(m.client2rooms))
+	seen := make(map[string]struct{}, len(m.client2rooms))
+	for _, clients := range m.room2clients {
+		for connID, client := range clients {
+			if _, ok := seen[connID]; ok {
+				continue
+			}
+			seen[connID] = struct{}{}
+			conns = append(conns, client)
+		}
+	}
+	return conns
+}
+
 func (m *WSConnManager) notifyRoomLocalPeer(
 	roomID,
 	method string,
 	data any) {
 
+	m.notifyRoomLocalPeerEach(roomID, method, func(*rtcContext) any { return data })
+}
+
+// notifyRoomLocalPeerEach notifies the local connections of the room with the params built
+// for each connection, e.g. localized in its locale
+func (m *WSConnManager) notifyRoomLocalPeerEach(
+	roomID,
+	method string,
+	build func(rtcCtx *rtcContext) any) {
+
 	conns := m.getRoomConns(roomID)
 	if conns == nil {
 		return
@@ -176,8 +533,8 @@ func (m *WSConnManager) notifyRoomLocalPeer(
 
 	// TODO: goroutine pool ?!
 	for _, conn := range conns {
-		ctx := conn.Context().Get().reqCtx
-		if err := conn.Notify(ctx, method, data); err != nil {
+		rtcCtx := conn.Context().Get()
+		if err := conn.Notify(rtcCtx.reqCtx, method, build(rtcCtx)); err != nil {
 			m.logger.Error("Failed to send to client",
 				log.String("roomId", roomID),
 				log.Error(err),
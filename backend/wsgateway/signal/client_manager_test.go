@@ -3,6 +3,7 @@ package signal
 import (
 	"context"
 	"encoding/json"
+	"strconv"
 	"testing"
 
 	"github.com/alicebob/miniredis/v2"
@@ -18,8 +19,9 @@ import (
 )
 
 type mockConn struct {
-	context    *rtcContext
-	notifyFunc func(ctx context.Context, method string, params any) error
+	context      *rtcContext
+	notifyFunc   func(ctx context.Context, method string, params any) error
+	dispatchFunc func(ctx context.Context, method string, params any) error
 }
 
 func (m *mockConn) Open(_ context.Context) error {
@@ -33,6 +35,13 @@ func (m *mockConn) Notify(ctx context.Context, method string, params any) error
 	return nil
 }
 
+func (m *mockConn) Dispatch(ctx context.Context, method string, params any) error {
+	if m.dispatchFunc != nil {
+		return m.dispatchFunc(ctx, method, params)
+	}
+	return nil
+}
+
 func (m *mockConn) Call(_ context.Context, _ string, _ any, _ any) error {
 	return nil
 }
@@ -89,7 +98,7 @@ func (s *ClientManagerSuite) SetupTest() {
 	s.logger = log.NewNop()
 	s.mockPeer = rpcmocks.NewMockPeer[any](s.ctrl)
 
-	s.manager, err = NewWSConnMgr(s.client, "test:ws:stream", s.logger)
+	s.manager, err = NewWSConnMgr(s.client, "test:ws:stream", 0, nil, nil, s.logger)
 	s.Require().NoError(err)
 
 	// Replace real peer with mock for tests that need it
@@ -117,7 +126,7 @@ func (s *ClientManagerSuite) TestAddClient() {
 
 	s.manager.AddClient(connID, roomID, peer)
 
-	s.Equal(roomID, s.manager.client2room[connID])
+	s.Contains(s.manager.client2rooms[connID], roomID)
 	s.NotNil(s.manager.room2clients[roomID])
 	s.Equal(peer, s.manager.room2clients[roomID][connID])
 }
@@ -145,7 +154,7 @@ func (s *ClientManagerSuite) TestRemoveClient() {
 	s.manager.AddClient(connID, roomID, peer)
 	s.manager.RemoveClient(connID)
 
-	_, ok := s.manager.client2room[connID]
+	_, ok := s.manager.client2rooms[connID]
 	s.False(ok)
 
 	_, ok = s.manager.room2clients[roomID]
@@ -162,7 +171,7 @@ func (s *ClientManagerSuite) TestRemoveClient_OneOfMultiple() {
 
 	s.manager.RemoveClient("conn1")
 
-	_, ok := s.manager.client2room["conn1"]
+	_, ok := s.manager.client2rooms["conn1"]
 	s.False(ok)
 
 	s.Len(s.manager.room2clients[roomID], 1)
@@ -172,7 +181,7 @@ func (s *ClientManagerSuite) TestRemoveClient_OneOfMultiple() {
 func (s *ClientManagerSuite) TestRemoveClient_NotExists() {
 	s.manager.RemoveClient("nonexistent")
 
-	s.Len(s.manager.client2room, 0)
+	s.Len(s.manager.client2rooms, 0)
 	s.Len(s.manager.room2clients, 0)
 }
 
@@ -189,13 +198,63 @@ func (s *ClientManagerSuite) TestRemoveRoom() {
 	_, ok := s.manager.room2clients[roomID]
 	s.False(ok)
 
-	_, ok = s.manager.client2room["conn1"]
+	_, ok = s.manager.client2rooms["conn1"]
 	s.False(ok)
 
-	_, ok = s.manager.client2room["conn2"]
+	_, ok = s.manager.client2rooms["conn2"]
 	s.False(ok)
 }
 
+func (s *ClientManagerSuite) TestClient_SeveralRooms() {
+	peer := &mockConn{context: &rtcContext{connID: "conn1", roomID: "room1"}}
+
+	s.manager.AddClient("conn1", "room1", peer)
+	s.manager.AddClient("conn1", "room2", peer)
+	s.manager.AddClient("conn1", "room2", peer)
+
+	s.Len(s.manager.client2rooms["conn1"], 2)
+	s.Len(s.manager.allConns(), 1)
+	s.Equal(1, s.manager.Stats().Connections)
+	s.Equal(2, s.manager.Stats().Rooms)
+
+	s.manager.RemoveClientRoom("conn1", "room2")
+	s.Equal(map[string]struct{}{"room1": {}}, s.manager.client2rooms["conn1"])
+	s.Empty(s.manager.getRoomConns("room2"))
+
+	s.manager.AddClient("conn1", "room2", peer)
+	s.manager.RemoveRoom("room1")
+	s.Equal(map[string]struct{}{"room2": {}}, s.manager.client2rooms["conn1"])
+
+	s.manager.RemoveClient("conn1")
+	s.Empty(s.manager.client2rooms)
+	s.Empty(s.manager.room2clients)
+}
+
+func (s *ClientManagerSuite) TestHandleBroadcast_OtherRoomMembers() {
+	var method string
+	var params any
+	peer := &mockConn{
+		context: &rtcContext{connID: "conn1", roomID: "room1", reqCtx: context.Background()},
+		notifyFunc: func(_ context.Context, m string, p any) error {
+			method, params = m, p
+			return nil
+		},
+	}
+	s.manager.AddClient("conn1", "room1", peer)
+	s.manager.AddClient("conn1", "room2", peer)
+
+	// roomStatus carries no room, members of other rooms come with theirs
+	req := users.NotifyRoomStatus{RoomID: "room2", Members: []*users.RoomUser{{UserID: "user2"}}}
+	raw, err := json.Marshal(req)
+	s.Require().NoError(err)
+	rawParams := json.RawMessage(raw)
+
+	_, err = s.manager.handleBroadcast(nil, &rawParams)
+	s.Require().NoError(err)
+	s.Equal(roomMembersMethod, method)
+	s.Equal(&req, params)
+}
+
 func (s *ClientManagerSuite) TestGetRoomConns() {
 	roomID := "room1"
 	peer1 := &mockConn{context: &rtcContext{connID: "conn1", roomID: roomID}}
@@ -303,11 +362,223 @@ func (s *ClientManagerSuite) TestHandleBroadcast() {
 	s.Equal(members, notifiedParams)
 }
 
+func (s *ClientManagerSuite) TestNotifyModerators() {
+	ctx := context.Background()
+	params := &pinAttemptsExceeded{RoomID: "room1", UserID: "user1", LockoutSecs: 60}
+
+	s.mockPeer.EXPECT().
+		Notify(ctx, "notifyModerators", &moderatorNotify{
+			RoomID: "room1",
+			Method: "pin_attempts_exceeded",
+			Params: params,
+		}).
+		Return(nil)
+
+	err := s.manager.NotifyModerators(ctx, "room1", "pin_attempts_exceeded", params)
+	s.Require().NoError(err)
+}
+
+func (s *ClientManagerSuite) TestHandleNotifyModerators_OnlyHosts() {
+	roomID := "room1"
+	notified := map[string]string{}
+
+	addConn := func(connID string, role constants.UserRole) {
+		s.manager.AddClient(connID, roomID, &mockConn{
+			context: inRoom(&rtcContext{
+				connID: connID,
+				roomID: roomID,
+				reqCtx: context.Background(),
+			}, &roomContext{role: role}),
+			notifyFunc: func(_ context.Context, method string, params any) error {
+				raw, ok := params.(json.RawMessage)
+				s.Require().True(ok)
+				notified[connID] = method + " " + string(raw)
+				return nil
+			},
+		})
+	}
+	addConn("conn-host", constants.UserRoleHost)
+	addConn("conn-anchor", constants.UserRoleAnchor)
+
+	rawParams := json.RawMessage(
+		`{"roomId":"room1","method":"pin_attempts_exceeded","params":{"userId":"user1"}}`,
+	)
+	_, err := s.manager.handleNotifyModerators(nil, &rawParams)
+	s.Require().NoError(err)
+
+	s.Equal(map[string]string{
+		"conn-host": `pin_attempts_exceeded {"userId":"user1"}`,
+	}, notified)
+}
+
+func (s *ClientManagerSuite) TestHandleFloorGranted_DispatchesUnmute() {
+	roomID := "room1"
+	notified := map[string]string{}
+	dispatched := map[string]string{}
+
+	addConn := func(connID string) {
+		s.manager.AddClient(connID, roomID, &mockConn{
+			context: &rtcContext{connID: connID, roomID: roomID},
+			notifyFunc: func(_ context.Context, method string, _ any) error {
+				notified[connID] = method
+				return nil
+			},
+			dispatchFunc: func(_ context.Context, method string, params any) error {
+				req, ok := params.(*users.NotifyFloorGranted)
+				s.Require().True(ok)
+				dispatched[connID] = method + " " + req.UserID
+				return nil
+			},
+		})
+	}
+	addConn("conn1")
+	addConn("conn2")
+
+	rawParams := json.RawMessage(`{"roomId":"room1","userId":"user1","unmute":true}`)
+	_, err := s.manager.handleFloorGranted(nil, &rawParams)
+	s.Require().NoError(err)
+
+	s.Equal(map[string]string{
+		"conn1": "floorGranted",
+		"conn2": "floorGranted",
+	}, notified)
+	s.Equal(map[string]string{
+		"conn1": "floor.unmute user1",
+		"conn2": "floor.unmute user1",
+	}, dispatched)
+}
+
+func (s *ClientManagerSuite) TestHandleFloorGranted_NoUnmute() {
+	s.manager.AddClient("conn1", "room1", &mockConn{
+		context: &rtcContext{connID: "conn1", roomID: "room1"},
+		dispatchFunc: func(context.Context, string, any) error {
+			s.Fail("unexpected dispatch")
+			return nil
+		},
+	})
+
+	rawParams := json.RawMessage(`{"roomId":"room1","userId":"user1"}`)
+	_, err := s.manager.handleFloorGranted(nil, &rawParams)
+	s.Require().NoError(err)
+}
+
+func (s *ClientManagerSuite) TestHandlePublishChanged() {
+	dispatched := ""
+	s.manager.AddClient("conn1", "room1", &mockConn{
+		context: &rtcContext{connID: "conn1", roomID: "room1"},
+		dispatchFunc: func(_ context.Context, method string, params any) error {
+			req, ok := params.(*users.NotifyPublish)
+			s.Require().True(ok)
+			dispatched = method + " " + req.UserID + " " + strconv.FormatBool(req.Granted)
+			return nil
+		},
+	})
+
+	rawParams := json.RawMessage(`{"roomId":"room1","userId":"user1","granted":true}`)
+	_, err := s.manager.handlePublishChanged(nil, &rawParams)
+	s.Require().NoError(err)
+	s.Equal("publish.change user1 true", dispatched)
+}
+
+func (s *ClientManagerSuite) TestHandleUserEvicted() {
+	dispatched := ""
+	s.manager.AddClient("conn1", "room1", &mockConn{
+		context: &rtcContext{connID: "conn1", roomID: "room1"},
+		dispatchFunc: func(_ context.Context, method string, params any) error {
+			req, ok := params.(*users.NotifyUserEvicted)
+			s.Require().True(ok)
+			dispatched = method + " " + req.UserID
+			return nil
+		},
+	})
+
+	rawParams := json.RawMessage(`{"roomId":"room1","userId":"user1"}`)
+	_, err := s.manager.handleUserEvicted(nil, &rawParams)
+	s.Require().NoError(err)
+	s.Equal("user.evicted user1", dispatched)
+}
+
+func (s *ClientManagerSuite) TestHandleParticipantEvent_OtherAnchors() {
+	roomID := "room1"
+	notified := map[string]string{}
+
+	addConn := func(connID, userID string, role constants.UserRole) {
+		s.manager.AddClient(connID, roomID, &mockConn{
+			context: inRoom(&rtcContext{
+				connID: connID,
+				roomID: roomID,
+				userID: userID,
+				reqCtx: context.Background(),
+			}, &roomContext{role: role}),
+			notifyFunc: func(_ context.Context, method string, params any) error {
+				req, ok := params.(*users.NotifyParticipant)
+				s.Require().True(ok)
+				notified[connID] = method + " " + req.UserID + " " + req.Event
+				return nil
+			},
+		})
+	}
+	addConn("conn-host", "host1", constants.UserRoleHost)
+	addConn("conn-anchor", "anchor1", constants.UserRoleAnchor)
+	addConn("conn-self", "anchor2", constants.UserRoleAnchor)
+	addConn("conn-guest", "guest1", constants.UserRoleGuest)
+
+	rawParams := json.RawMessage(`{"roomId":"room1","userId":"anchor2","event":"joined"}`)
+	_, err := s.manager.handleParticipantEvent(nil, &rawParams)
+	s.Require().NoError(err)
+
+	s.Equal(map[string]string{
+		"conn-host":   "participant anchor2 joined",
+		"conn-anchor": "participant anchor2 joined",
+	}, notified)
+}
+
+func (s *ClientManagerSuite) TestHandleRoomBroadcast() {
+	roomID := "room1"
+	notified := map[string]string{}
+
+	addConn := func(connID string, role constants.UserRole) {
+		s.manager.AddClient(connID, roomID, &mockConn{
+			context: inRoom(&rtcContext{
+				connID: connID,
+				roomID: roomID,
+				reqCtx: context.Background(),
+			}, &roomContext{role: role}),
+			notifyFunc: func(_ context.Context, method string, params any) error {
+				req, ok := params.(*users.NotifyBroadcast)
+				s.Require().True(ok)
+				notified[connID] = method + " " + req.Event + " " + string(req.Payload)
+				return nil
+			},
+		})
+	}
+	addConn("conn-host", constants.UserRoleHost)
+	addConn("conn-anchor", constants.UserRoleAnchor)
+	addConn("conn-guest", constants.UserRoleGuest)
+
+	rawParams := json.RawMessage(`{"roomId":"room1","event":"countdown","payload":{"seconds":10}}`)
+	_, err := s.manager.handleRoomBroadcast(nil, &rawParams)
+	s.Require().NoError(err)
+
+	s.Equal(map[string]string{
+		"conn-host":   `roomBroadcast countdown {"seconds":10}`,
+		"conn-anchor": `roomBroadcast countdown {"seconds":10}`,
+	}, notified)
+}
+
 func (s *ClientManagerSuite) TestClientManager_StartStop() {
 	ctx := context.Background()
 
 	s.mockPeer.EXPECT().Open(ctx).Return(nil)
 	s.mockPeer.EXPECT().Def("broadcastRoomStatus", gomock.Any())
+	s.mockPeer.EXPECT().Def("notifyModerators", gomock.Any())
+	s.mockPeer.EXPECT().Def("floorGranted", gomock.Any())
+	s.mockPeer.EXPECT().Def("userEvicted", gomock.Any())
+	s.mockPeer.EXPECT().Def("participantEvent", gomock.Any())
+	s.mockPeer.EXPECT().Def("connReplaced", gomock.Any())
+	s.mockPeer.EXPECT().Def("roomBroadcast", gomock.Any())
+	s.mockPeer.EXPECT().Def("publishChanged", gomock.Any())
+	s.mockPeer.EXPECT().Def("joinQueueChanged", gomock.Any())
 
 	err := s.manager.Start(ctx)
 	s.Require().NoError(err)
@@ -322,7 +593,7 @@ func (s *ClientManagerSuite) TestClientManager_Errors() {
 
 	// Start error
 	s.mockPeer.EXPECT().Open(ctx).Return(context.DeadlineExceeded)
-	s.mockPeer.EXPECT().Def(gomock.Any(), gomock.Any())
+	s.mockPeer.EXPECT().Def(gomock.Any(), gomock.Any()).Times(9)
 	err := s.manager.Start(ctx)
 	s.Require().Error(err)
 
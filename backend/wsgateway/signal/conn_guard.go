@@ -3,6 +3,9 @@ package signal
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,6 +13,7 @@ import (
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
 )
 
 const (
@@ -46,6 +50,64 @@ var (
 		return 0
 	`)
 
+	// Lua script for acquiring connection lock under kick_old, a new connection takes the lock
+	// over while a connection which held it before finds it was replaced
+	// KEYS[1]: lock key (user lock)
+	// ARGV[1]: lock value (serverID:nonce:roomID)
+	// ARGV[2]: lock TTL in milliseconds
+	// ARGV[3]: "1" when the connection held the lock before
+	// returns {1, ""} when held, {0, holder} when replaced, {2, previous} when taken over
+	luaTakeOverConnLock = redis.NewScript(`
+		local cur = redis.call('GET', KEYS[1])
+		if cur == false or cur == ARGV[1] then
+			redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+			return {1, ''}
+		end
+
+		if ARGV[3] == '1' then
+			return {0, cur}
+		end
+
+		redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+		return {2, cur}
+	`)
+
+	// Lua script for acquiring a device lock under allow_n, locks of stopped gateways are
+	// released when the user is over the limit
+	// KEYS[1]: devices key (sorted set of lock values scored by expiry)
+	// ARGV[1]: lock value (serverID:nonce)
+	// ARGV[2]: lock TTL in milliseconds
+	// ARGV[3]: now in unix milliseconds
+	// ARGV[4]: max devices
+	// ARGV[5]: server heartbeat key prefix
+	luaAcquireDeviceLock = redis.NewScript(`
+		local expireAt = tonumber(ARGV[3]) + tonumber(ARGV[2])
+		local max = tonumber(ARGV[4])
+		redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[3])
+
+		local function hold()
+			redis.call('ZADD', KEYS[1], expireAt, ARGV[1])
+			redis.call('PEXPIRE', KEYS[1], ARGV[2])
+			return 1
+		end
+
+		if redis.call('ZSCORE', KEYS[1], ARGV[1]) or redis.call('ZCARD', KEYS[1]) < max then
+			return hold()
+		end
+
+		for _, member in ipairs(redis.call('ZRANGE', KEYS[1], 0, -1)) do
+			local server = string.match(member, '^([^:]+):')
+			if server and redis.call('EXISTS', ARGV[5] .. server) == 0 then
+				redis.call('ZREM', KEYS[1], member)
+			end
+		end
+		if redis.call('ZCARD', KEYS[1]) < max then
+			return hold()
+		end
+
+		return 0
+	`)
+
 	// Lua script for releasing connection lock
 	// KEYS[1]: lock key
 	// ARGV[1]: lock value (serverID:nonce)
@@ -59,10 +121,39 @@ var (
 	`)
 )
 
+// DuplicatePolicy decides what happens when a user connects while connected elsewhere,
+// every gateway sharing the Redis prefix must run the same policy
+type DuplicatePolicy string
+
+const (
+	// DuplicateRejectNew keeps the existing connection and closes the new one
+	DuplicateRejectNew DuplicatePolicy = "reject_new"
+	// DuplicateKickOld closes the existing connection in favor of the new one
+	DuplicateKickOld DuplicatePolicy = "kick_old"
+	// DuplicateAllowN lets up to MaxDevices connections of the user in, each holding its own lock
+	DuplicateAllowN DuplicatePolicy = "allow_n"
+)
+
+type ConnGuardConfig struct {
+	Policy DuplicatePolicy `mapstructure:"policy"`
+	// MaxDevices bounds the connections of a user under allow_n
+	MaxDevices int `mapstructure:"max_devices"`
+}
+
+func SetupConnGuard(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("policy"), string(DuplicateRejectNew))
+	v.SetDefault(p("max_devices"), 3)
+}
+
 type connGuardImpl struct {
 	redisClient *redis.Client
 	prefix      string
 	serverID    string
+	advURL      string
+	policy      DuplicatePolicy
+	maxDevices  int
 	logger      *log.Logger
 
 	stopCh chan struct{}
@@ -73,29 +164,74 @@ func NewConnGuard(
 	redisClient *redis.Client,
 	redisPrefix string,
 	serverID string,
+	advURL string,
+	cfg *ConnGuardConfig,
 	logger *log.Logger,
 ) ConnectionGuard {
-	return &connGuardImpl{
+	s := &connGuardImpl{
 		redisClient: redisClient,
 		prefix:      redisPrefix,
 		serverID:    serverID,
+		advURL:      advURL,
+		policy:      DuplicateRejectNew,
 		logger:      logger,
 		stopCh:      make(chan struct{}),
 	}
+	if cfg == nil {
+		return s
+	}
+	switch cfg.Policy {
+	case DuplicateRejectNew, DuplicateKickOld:
+		s.policy = cfg.Policy
+	case DuplicateAllowN:
+		s.policy = cfg.Policy
+		s.maxDevices = max(cfg.MaxDevices, 1)
+	default:
+		logger.Warn("Unknown duplicate login policy, rejecting new connections",
+			log.String("policy", string(cfg.Policy)))
+	}
+	return s
 }
 
 func (s *connGuardImpl) connKey(userID string) string {
 	return fmt.Sprintf("%s:c:%s", s.prefix, userID)
 }
 
+func (s *connGuardImpl) devicesKey(userID string) string {
+	return fmt.Sprintf("%s:d:%s", s.prefix, userID)
+}
+
+// acquiredKey holds when each lock value of the user was acquired, in unix milliseconds, for
+// operators listing the locks through the users service
+func (s *connGuardImpl) acquiredKey(userID string) string {
+	return fmt.Sprintf("%s:ct:%s", s.prefix, userID)
+}
+
 func (s *connGuardImpl) serverKey() string {
 	return fmt.Sprintf("%s:s:%s", s.prefix, s.serverID)
 }
 
+func (s *connGuardImpl) serverKeyPattern() string {
+	return s.serverKeyPrefix() + "*"
+}
+
+func (s *connGuardImpl) serverKeyPrefix() string {
+	return fmt.Sprintf("%s:s:", s.prefix)
+}
+
 func (s *connGuardImpl) lockValue(nonce string) string {
 	return fmt.Sprintf("%s:%s", s.serverID, nonce)
 }
 
+// lockValueOf names the connection holding the lock, under kick_old it carries the token room
+// so the replaced connection is reached through the notifications of its room
+func (s *connGuardImpl) lockValueOf(rtcCtx *rtcContext) string {
+	if s.policy == DuplicateKickOld {
+		return fmt.Sprintf("%s:%s:%s", s.serverID, rtcCtx.connID, rtcCtx.roomID)
+	}
+	return s.lockValue(rtcCtx.connID)
+}
+
 func (s *connGuardImpl) GetServerID() string {
 	return s.serverID
 }
@@ -107,35 +243,141 @@ func (s *connGuardImpl) MustHold(mctx jsonrpc.MethodContext[rtcContext]) (bool,
 		log.String("userId", rtcCtx.userID),
 		log.String("nonce", rtcCtx.connID),
 		log.String("serverId", s.serverID),
+		log.String("policy", string(s.policy)),
 	)
 
-	lockVal := s.lockValue(rtcCtx.connID)
-	serverKey := s.serverKey()
-
-	result, err := luaAcquireConnLock.Run(
-		rtcCtx.reqCtx,
-		s.redisClient,
-		[]string{s.connKey(rtcCtx.userID), serverKey},
-		lockVal,
-		connLockTTL.Microseconds(),
-	).Int()
-
+	var reason CloseReason
+	var err error
+	switch s.policy {
+	case DuplicateKickOld:
+		reason, err = s.takeOver(rtcCtx)
+	case DuplicateAllowN:
+		reason, err = s.holdDevice(rtcCtx)
+	default:
+		reason, err = s.hold(rtcCtx)
+	}
 	if err != nil {
 		return false, fmt.Errorf("fail to acquire lock: %w", err)
 	}
-	if result == 1 {
+	if reason == "" {
+		rtcCtx.lockHeld = true
+		s.markAcquired(rtcCtx)
 		return true, nil
 	}
 
-	// TODO; close connection gracefully, and send proper error code/message to avoid reconnection
-	mctx.Peer().Close()
+	// the user is connected elsewhere, tell the client not to reconnect
+	hint := s.duplicateHint(reason)
+	rtcCtx.duplicate = hint
+	closeWithHint(rtcCtx.reqCtx, mctx.Peer(), hint, s.logger)
 	s.logger.Debug("Connection rejected due to existing connection",
 		log.String("connId", rtcCtx.connID),
 		log.String("userId", rtcCtx.userID),
+		log.String("reason", string(reason)),
 	)
 	return false, nil
 }
 
+// duplicateHint is the close hint of connections closed by the duplicate login policy
+func (s *connGuardImpl) duplicateHint(reason CloseReason) *closeHint {
+	return &closeHint{
+		Reason:     reason,
+		Policy:     s.policy,
+		MaxDevices: s.maxDevices,
+	}
+}
+
+// hold acquires the lock unless another connection of a live gateway holds it
+func (s *connGuardImpl) hold(rtcCtx *rtcContext) (CloseReason, error) {
+	result, err := luaAcquireConnLock.Run(
+		rtcCtx.reqCtx,
+		s.redisClient,
+		[]string{s.connKey(rtcCtx.userID), s.serverKey()},
+		s.lockValueOf(rtcCtx),
+		connLockTTL.Milliseconds(),
+	).Int()
+	if err != nil {
+		return "", err
+	}
+	if result == 1 {
+		return "", nil
+	}
+	return CloseReasonDuplicate, nil
+}
+
+// takeOver acquires the lock from whichever connection holds it, the connection it was taken
+// from is left in replaced to be closed
+func (s *connGuardImpl) takeOver(rtcCtx *rtcContext) (CloseReason, error) {
+	held := "0"
+	if rtcCtx.lockHeld {
+		held = "1"
+	}
+	result, err := luaTakeOverConnLock.Run(
+		rtcCtx.reqCtx,
+		s.redisClient,
+		[]string{s.connKey(rtcCtx.userID)},
+		s.lockValueOf(rtcCtx),
+		connLockTTL.Milliseconds(),
+		held,
+	).Slice()
+	if err != nil {
+		return "", err
+	}
+	if len(result) != 2 {
+		return "", fmt.Errorf("unexpected lock result: %v", result)
+	}
+	code, _ := result[0].(int64)
+	prev, _ := result[1].(string)
+	switch code {
+	case 0:
+		return CloseReasonReplaced, nil
+	case 2:
+		// serverID:nonce:roomID, the room is missing from locks taken under other policies
+		parts := strings.SplitN(prev, ":", 3)
+		if len(parts) == 3 {
+			rtcCtx.replaced = &connReplaced{
+				RoomID: parts[2],
+				UserID: rtcCtx.userID,
+				ConnID: parts[1],
+			}
+		}
+	}
+	return "", nil
+}
+
+// holdDevice acquires one of the device locks of the user
+func (s *connGuardImpl) holdDevice(rtcCtx *rtcContext) (CloseReason, error) {
+	result, err := luaAcquireDeviceLock.Run(
+		rtcCtx.reqCtx,
+		s.redisClient,
+		[]string{s.devicesKey(rtcCtx.userID)},
+		s.lockValueOf(rtcCtx),
+		connLockTTL.Milliseconds(),
+		time.Now().UnixMilli(),
+		s.maxDevices,
+		s.serverKeyPrefix(),
+	).Int()
+	if err != nil {
+		return "", err
+	}
+	if result == 1 {
+		return "", nil
+	}
+	return CloseReasonDeviceLimit, nil
+}
+
+// markAcquired records when the connection acquired its lock, kept as long as the lock is
+func (s *connGuardImpl) markAcquired(rtcCtx *rtcContext) {
+	key := s.acquiredKey(rtcCtx.userID)
+	_, err := s.redisClient.Pipelined(rtcCtx.reqCtx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(rtcCtx.reqCtx, key, s.lockValueOf(rtcCtx), time.Now().UnixMilli())
+		pipe.PExpire(rtcCtx.reqCtx, key, connLockTTL)
+		return nil
+	})
+	if err != nil {
+		s.logger.Warn("Failed to record lock acquisition", log.String("userId", rtcCtx.userID), log.Error(err))
+	}
+}
+
 func (s *connGuardImpl) Release(mctx jsonrpc.MethodContext[rtcContext]) error {
 	rtcCtx := mctx.Get()
 
@@ -145,17 +387,24 @@ func (s *connGuardImpl) Release(mctx jsonrpc.MethodContext[rtcContext]) error {
 		log.String("serverId", s.serverID),
 	)
 
-	lockVal := s.lockValue(rtcCtx.connID)
-	_, err := luaReleaseConnLock.Run(
-		rtcCtx.reqCtx,
-		s.redisClient,
-		[]string{s.connKey(rtcCtx.userID)},
-		lockVal,
-	).Int()
-
+	lockVal := s.lockValueOf(rtcCtx)
+	var err error
+	if s.policy == DuplicateAllowN {
+		err = s.redisClient.ZRem(rtcCtx.reqCtx, s.devicesKey(rtcCtx.userID), lockVal).Err()
+	} else {
+		err = luaReleaseConnLock.Run(
+			rtcCtx.reqCtx,
+			s.redisClient,
+			[]string{s.connKey(rtcCtx.userID)},
+			lockVal,
+		).Err()
+	}
 	if err != nil {
 		return fmt.Errorf("fail to release lock: %w", err)
 	}
+	if err := s.redisClient.HDel(rtcCtx.reqCtx, s.acquiredKey(rtcCtx.userID), lockVal).Err(); err != nil {
+		s.logger.Warn("Failed to clear lock acquisition", log.String("userId", rtcCtx.userID), log.Error(err))
+	}
 	return nil
 }
 
@@ -178,10 +427,91 @@ func (s *connGuardImpl) Stop() {
 	s.wg.Wait()
 }
 
+// PeerGateway returns the advertised URL of another live gateway, empty when there is none.
+// Server heartbeats double as the gateway presence registry
+func (s *connGuardImpl) PeerGateway(ctx context.Context) (string, error) {
+	keys, err := s.serverKeys(ctx)
+	if err != nil {
+		return "", err
+	}
+	keys = slices.DeleteFunc(keys, func(key string) bool { return key == s.serverKey() })
+	if len(keys) == 0 {
+		return "", nil
+	}
+
+	vals, err := s.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return "", fmt.Errorf("fail to get gateways: %w", err)
+	}
+	urls := make([]string, 0, len(vals))
+	for _, val := range vals {
+		// expired between scan and get, or no advertised URL
+		if url, ok := val.(string); ok && url != "" {
+			urls = append(urls, url)
+		}
+	}
+	if len(urls) == 0 {
+		return "", nil
+	}
+	return urls[rand.IntN(len(urls))], nil
+}
+
+// Gateways returns the server IDs of the live gateways, this one included once its heartbeat is set
+func (s *connGuardImpl) Gateways(ctx context.Context) ([]string, error) {
+	keys, err := s.serverKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prefix := s.serverKeyPrefix()
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, strings.TrimPrefix(key, prefix))
+	}
+	return ids, nil
+}
+
+// GatewayURLs returns the advertised URLs of the live gateways by server ID, empty for a
+// gateway advertising none
+func (s *connGuardImpl) GatewayURLs(ctx context.Context) (map[string]string, error) {
+	keys, err := s.serverKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return map[string]string{}, nil
+	}
+
+	vals, err := s.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("fail to get gateways: %w", err)
+	}
+	prefix := s.serverKeyPrefix()
+	urls := make(map[string]string, len(keys))
+	for i, val := range vals {
+		// expired between scan and get
+		if url, ok := val.(string); ok {
+			urls[strings.TrimPrefix(keys[i], prefix)] = url
+		}
+	}
+	return urls, nil
+}
+
+func (s *connGuardImpl) serverKeys(ctx context.Context) ([]string, error) {
+	var keys []string
+	iter := s.redisClient.Scan(ctx, 0, s.serverKeyPattern(), 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("fail to scan gateways: %w", err)
+	}
+	return keys, nil
+}
+
 func (s *connGuardImpl) setHearbeat(ctx context.Context) error {
 	return s.redisClient.Set(
 		ctx, s.serverKey(),
-		"1",
+		s.advURL,
 		serverHBTTL).Err()
 }
 
@@ -42,6 +42,36 @@ func (m *MockConnectionGuard) EXPECT() *MockConnectionGuardMockRecorder {
 	return m.recorder
 }
 
+// Gateways mocks base method.
+func (m *MockConnectionGuard) Gateways(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Gateways", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Gateways indicates an expected call of Gateways.
+func (mr *MockConnectionGuardMockRecorder) Gateways(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Gateways", reflect.TypeOf((*MockConnectionGuard)(nil).Gateways), ctx)
+}
+
+// GatewayURLs mocks base method.
+func (m *MockConnectionGuard) GatewayURLs(ctx context.Context) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GatewayURLs", ctx)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GatewayURLs indicates an expected call of GatewayURLs.
+func (mr *MockConnectionGuardMockRecorder) GatewayURLs(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GatewayURLs", reflect.TypeOf((*MockConnectionGuard)(nil).GatewayURLs), ctx)
+}
+
 // GetServerID mocks base method.
 func (m *MockConnectionGuard) GetServerID() string {
 	m.ctrl.T.Helper()
@@ -71,6 +101,21 @@ func (mr *MockConnectionGuardMockRecorder) MustHold(mctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MustHold", reflect.TypeOf((*MockConnectionGuard)(nil).MustHold), mctx)
 }
 
+// PeerGateway mocks base method.
+func (m *MockConnectionGuard) PeerGateway(ctx context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PeerGateway", ctx)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PeerGateway indicates an expected call of PeerGateway.
+func (mr *MockConnectionGuardMockRecorder) PeerGateway(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PeerGateway", reflect.TypeOf((*MockConnectionGuard)(nil).PeerGateway), ctx)
+}
+
 // Release mocks base method.
 func (m *MockConnectionGuard) Release(mctx jsonrpc.MethodContext[rtcContext]) error {
 	m.ctrl.T.Helper()
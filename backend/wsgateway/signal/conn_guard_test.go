@@ -38,7 +38,7 @@ func (s *ConnLockSuite) SetupTest() {
 	})
 
 	s.logger = log.NewNop()
-	s.guard = NewConnGuard(s.client, "test", "server1", s.logger)
+	s.guard = NewConnGuard(s.client, "test", "server1", "ws://gw1/ws", nil, s.logger)
 
 	// Start heartbeat so server is considered "alive" for lock conflict tests
 	err = s.guard.Start(context.Background())
@@ -74,6 +74,11 @@ func (s *ConnLockSuite) TestMustHold_Success() {
 	value, err := s.client.Get(ctx, "test:c:user1").Result()
 	s.Require().NoError(err)
 	s.Equal("server1:nonce1", value)
+
+	// acquisition time is kept for operators listing the locks
+	acquired, err := s.client.HGet(ctx, "test:ct:user1", "server1:nonce1").Int64()
+	s.Require().NoError(err)
+	s.Positive(acquired)
 }
 
 func (s *ConnLockSuite) TestMustHold_AlreadyLocked() {
@@ -93,6 +98,9 @@ func (s *ConnLockSuite) TestMustHold_AlreadyLocked() {
 	}
 	conn2 := mocks.NewMockPeer[rtcContext](s.ctrl)
 	mctx2 := jsonrpc.NewContext(conn2, &rtcCtx2)
+	conn2.EXPECT().
+		Notify(gomock.Any(), closingMethod, &closeHint{Reason: CloseReasonDuplicate, Policy: DuplicateRejectNew}).
+		Return(nil)
 	conn2.EXPECT().Close().Return(nil)
 
 	ok, err := s.guard.MustHold(mctx1)
@@ -143,6 +151,7 @@ func (s *ConnLockSuite) TestMustHold_WrongNonce() {
 	}
 	conn2 := mocks.NewMockPeer[rtcContext](s.ctrl)
 	mctx2 := jsonrpc.NewContext(conn2, &rtcCtx2)
+	conn2.EXPECT().Notify(gomock.Any(), closingMethod, gomock.Any()).Return(nil)
 	conn2.EXPECT().Close().Return(nil)
 
 	ok, err := s.guard.MustHold(mctx1)
@@ -178,6 +187,7 @@ func (s *ConnLockSuite) TestRelease_Success() {
 
 	_, err = s.client.Get(ctx, "test:c:user1").Result()
 	s.Equal(redis.Nil, err)
+	s.False(s.client.HExists(ctx, "test:ct:user1", "server1:nonce1").Val())
 }
 
 func (s *ConnLockSuite) TestRelease_WrongNonce() {
@@ -227,7 +237,7 @@ func (s *ConnLockSuite) TestRelease_NotExists() {
 func (s *ConnLockSuite) TestMustHold_ServerStopped() {
 	ctx := context.Background()
 
-	lock1 := NewConnGuard(s.client, "test", "server1", s.logger)
+	lock1 := NewConnGuard(s.client, "test", "server1", "ws://gw1/ws", nil, s.logger)
 	rtcCtx1 := rtcContext{
 		reqCtx: context.Background(),
 		userID: "user1",
@@ -245,7 +255,7 @@ func (s *ConnLockSuite) TestMustHold_ServerStopped() {
 
 	lock1.Stop()
 
-	lock2 := NewConnGuard(s.client, "test", "server2", s.logger)
+	lock2 := NewConnGuard(s.client, "test", "server2", "ws://gw2/ws", nil, s.logger)
 	rtcCtx2 := rtcContext{
 		reqCtx: context.Background(),
 		userID: "user1",
@@ -262,3 +272,157 @@ func (s *ConnLockSuite) TestMustHold_ServerStopped() {
 	s.Require().NoError(err)
 	s.Equal("server2:nonce2", value)
 }
+
+func (s *ConnLockSuite) TestPeerGateway() {
+	ctx := context.Background()
+
+	// only this gateway is alive
+	url, err := s.guard.PeerGateway(ctx)
+	s.Require().NoError(err)
+	s.Empty(url)
+
+	peer := NewConnGuard(s.client, "test", "server2", "ws://gw2/ws", nil, s.logger)
+	s.Require().NoError(peer.Start(ctx))
+
+	url, err = s.guard.PeerGateway(ctx)
+	s.Require().NoError(err)
+	s.Equal("ws://gw2/ws", url)
+
+	peer.Stop()
+
+	url, err = s.guard.PeerGateway(ctx)
+	s.Require().NoError(err)
+	s.Empty(url)
+}
+
+func (s *ConnLockSuite) TestGateways() {
+	ctx := context.Background()
+
+	ids, err := s.guard.Gateways(ctx)
+	s.Require().NoError(err)
+	s.Equal([]string{"server1"}, ids)
+
+	peer := NewConnGuard(s.client, "test", "server2", "ws://gw2/ws", nil, s.logger)
+	s.Require().NoError(peer.Start(ctx))
+
+	ids, err = s.guard.Gateways(ctx)
+	s.Require().NoError(err)
+	s.ElementsMatch([]string{"server1", "server2"}, ids)
+
+	peer.Stop()
+
+	ids, err = s.guard.Gateways(ctx)
+	s.Require().NoError(err)
+	s.Equal([]string{"server1"}, ids)
+}
+
+func (s *ConnLockSuite) TestGatewayURLs() {
+	ctx := context.Background()
+
+	peer := NewConnGuard(s.client, "test", "server2", "", nil, s.logger)
+	s.Require().NoError(peer.Start(ctx))
+	defer peer.Stop()
+
+	urls, err := s.guard.GatewayURLs(ctx)
+	s.Require().NoError(err)
+	s.Equal(map[string]string{"server1": "ws://gw1/ws", "server2": ""}, urls)
+}
+
+func (s *ConnLockSuite) newConn(guard ConnectionGuard, roomID, connID string) (*rtcContext, jsonrpc.MethodContext[rtcContext], *mocks.MockPeer[rtcContext]) {
+	rtcCtx := &rtcContext{
+		reqCtx: context.Background(),
+		userID: "user1",
+		roomID: roomID,
+		connID: connID,
+	}
+	conn := mocks.NewMockPeer[rtcContext](s.ctrl)
+	return rtcCtx, jsonrpc.NewContext(conn, rtcCtx), conn
+}
+
+func (s *ConnLockSuite) TestMustHold_KickOld() {
+	ctx := context.Background()
+	guard := NewConnGuard(s.client, "test", "server1", "ws://gw1/ws",
+		&ConnGuardConfig{Policy: DuplicateKickOld}, s.logger)
+
+	oldCtx, oldMctx, oldConn := s.newConn(guard, "room1", "nonce1")
+	ok, err := guard.MustHold(oldMctx)
+	s.Require().NoError(err)
+	s.True(ok)
+	s.Nil(oldCtx.replaced)
+
+	// the new connection takes the lock over and names the one it replaced
+	newCtx, newMctx, _ := s.newConn(guard, "room2", "nonce2")
+	ok, err = guard.MustHold(newMctx)
+	s.Require().NoError(err)
+	s.True(ok)
+	s.Equal(&connReplaced{RoomID: "room1", UserID: "user1", ConnID: "nonce1"}, newCtx.replaced)
+
+	value, err := s.client.Get(ctx, "test:c:user1").Result()
+	s.Require().NoError(err)
+	s.Equal("server1:nonce2:room2", value)
+
+	// the old connection finds out on its next refresh and does not take the lock back
+	hint := &closeHint{Reason: CloseReasonReplaced, Policy: DuplicateKickOld}
+	oldConn.EXPECT().Notify(gomock.Any(), closingMethod, hint).Return(nil)
+	oldConn.EXPECT().Close().Return(nil)
+	ok, err = guard.MustHold(oldMctx)
+	s.Require().NoError(err)
+	s.False(ok)
+	s.Equal(hint, oldCtx.duplicate)
+
+	// releasing the replaced connection leaves the new lock alone
+	s.Require().NoError(guard.Release(oldMctx))
+	s.Require().NoError(guard.Release(newMctx))
+	s.Equal(int64(0), s.client.Exists(ctx, "test:c:user1").Val())
+}
+
+func (s *ConnLockSuite) TestMustHold_AllowN() {
+	ctx := context.Background()
+	guard := NewConnGuard(s.client, "test", "server1", "ws://gw1/ws",
+		&ConnGuardConfig{Policy: DuplicateAllowN, MaxDevices: 2}, s.logger)
+
+	_, mctx1, _ := s.newConn(guard, "room1", "nonce1")
+	_, mctx2, _ := s.newConn(guard, "room1", "nonce2")
+	rtcCtx3, mctx3, conn3 := s.newConn(guard, "room1", "nonce3")
+	for _, mctx := range []jsonrpc.MethodContext[rtcContext]{mctx1, mctx2, mctx1} {
+		ok, err := guard.MustHold(mctx)
+		s.Require().NoError(err)
+		s.True(ok)
+	}
+
+	hint := &closeHint{Reason: CloseReasonDeviceLimit, Policy: DuplicateAllowN, MaxDevices: 2}
+	conn3.EXPECT().Notify(gomock.Any(), closingMethod, hint).Return(nil)
+	conn3.EXPECT().Close().Return(nil)
+	ok, err := guard.MustHold(mctx3)
+	s.Require().NoError(err)
+	s.False(ok)
+	s.Equal(hint, rtcCtx3.duplicate)
+
+	// a released device frees its slot
+	s.Require().NoError(guard.Release(mctx2))
+	ok, err = guard.MustHold(mctx3)
+	s.Require().NoError(err)
+	s.True(ok)
+
+	members, err := s.client.ZRange(ctx, "test:d:user1", 0, -1).Result()
+	s.Require().NoError(err)
+	s.ElementsMatch([]string{"server1:nonce1", "server1:nonce3"}, members)
+}
+
+func (s *ConnLockSuite) TestMustHold_AllowNServerStopped() {
+	cfg := &ConnGuardConfig{Policy: DuplicateAllowN, MaxDevices: 1}
+	stopped := NewConnGuard(s.client, "test", "server2", "ws://gw2/ws", cfg, s.logger)
+	s.Require().NoError(stopped.Start(context.Background()))
+	_, mctx1, _ := s.newConn(stopped, "room1", "nonce1")
+	ok, err := stopped.MustHold(mctx1)
+	s.Require().NoError(err)
+	s.True(ok)
+	stopped.Stop()
+
+	// the device of the stopped gateway is gone
+	guard := NewConnGuard(s.client, "test", "server1", "ws://gw1/ws", cfg, s.logger)
+	_, mctx2, _ := s.newConn(guard, "room1", "nonce2")
+	ok, err = guard.MustHold(mctx2)
+	s.Require().NoError(err)
+	s.True(ok)
+}
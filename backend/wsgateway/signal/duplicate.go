@@ -0,0 +1,94 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const (
+	// connReplacedMethod is dispatched by the connection manager to the connection a newer
+	// connection of its user took the connection lock over from, clients cannot call it
+	connReplacedMethod = "conn.replaced"
+	// codeDuplicateLogin is returned to joins of connections closed by the duplicate login
+	// policy, data holds the close hint
+	codeDuplicateLogin = -32003
+)
+
+// connReplaced is the params of the connReplaced notification, it names the replaced connection
+type connReplaced struct {
+	RoomID string `json:"roomId"`
+	UserID string `json:"userId"`
+	ConnID string `json:"connId"`
+}
+
+// duplicateLoginError tells the client its connection was closed by the duplicate login policy
+func duplicateLoginError(hint *closeHint) *jsonrpc.Error {
+	data, _ := json.Marshal(hint)
+	raw := json.RawMessage(data)
+	return &jsonrpc.Error{
+		Code:    codeDuplicateLogin,
+		Message: fmt.Sprintf("user is connected elsewhere (policy %s): %s", hint.Policy, hint.Reason),
+		Data:    &raw,
+	}
+}
+
+// NotifyConnReplaced has the gateway of the replaced connection close it, whichever gateway it is on
+func (m *WSConnManager) NotifyConnReplaced(ctx context.Context, req *connReplaced) error {
+	if m.partitions != nil {
+		return m.partitions.notifier.Notify(ctx, req.RoomID, "connReplaced", req)
+	}
+	return m.peer2ws.Notify(ctx, "connReplaced", req)
+}
+
+// handleConnReplaced has the replaced connection close itself on its own handler goroutine
+func (m *WSConnManager) handleConnReplaced(
+	_ jsonrpc.MethodContext[any],
+	params *json.RawMessage,
+) (any, error) {
+	var req connReplaced
+	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+		return nil, err
+	}
+
+	for _, conn := range m.getRoomConns(req.RoomID) {
+		if conn.Context().Get().connID != req.ConnID {
+			continue
+		}
+		if err := conn.Dispatch(context.Background(), connReplacedMethod, &req); err != nil {
+			m.logger.Debug("Failed to dispatch connection replacement",
+				log.String("roomId", req.RoomID),
+				log.String("connId", req.ConnID),
+				log.Error(err))
+		}
+	}
+
+	//nolint:nilnil
+	return nil, nil
+}
+
+// handleConnReplaced closes the connection, a newer connection of the user took over under kick_old
+func (s *Server) handleConnReplaced(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+
+	var req connReplaced
+	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+		return nil, err
+	}
+	if rtcCtx.userID != req.UserID || rtcCtx.connID != req.ConnID || rtcCtx.duplicate != nil {
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	s.logger.Info("Closing connection replaced by a newer one",
+		log.String("connId", rtcCtx.connID),
+		log.String("userId", rtcCtx.userID))
+	rtcCtx.duplicate = &closeHint{Reason: CloseReasonReplaced, Policy: DuplicateKickOld}
+	closeWithHint(context.Background(), mctx.Peer(), rtcCtx.duplicate, s.logger)
+
+	//nolint:nilnil
+	return nil, nil
+}
@@ -0,0 +1,72 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+const (
+	// userEvictedMethod is dispatched by the connection manager to the connections of a room
+	// when the users controller evicts an inactive anchor, clients cannot call it
+	userEvictedMethod  = "user.evicted"
+	userEvictedTimeout = 5 * time.Second
+	// evictedNotification tells the client its anchor was released, it has to join again
+	evictedNotification = "evicted"
+)
+
+// userEvicted is the params of the evicted notification
+type userEvicted struct {
+	RoomID string `json:"roomId"`
+	UserID string `json:"userId"`
+	// Code is the machine code of the notification, Message its text in the user locale when
+	// the gateway has a message catalog
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// handleUserEvicted releases the Janus handle of the evicted anchor, runs on the connection's
+// handler goroutine
+func (s *Server) handleUserEvicted(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+
+	var req users.NotifyUserEvicted
+	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+		return nil, err
+	}
+	room := rtcCtx.room(req.RoomID)
+	if room == nil || !room.joined || rtcCtx.userID != req.UserID {
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), userEvictedTimeout)
+	defer cancel()
+	if room.janus != nil {
+		if err := room.janus.Destroy(ctx); err != nil {
+			s.logger.Error("Failed to release Janus handle of evicted user",
+				log.String("roomId", req.RoomID),
+				log.String("userId", req.UserID),
+				log.Error(err))
+		}
+	}
+	room.janus = nil
+	room.joined = false
+	joinsActive.Add(ctx, -1)
+	room.group = ""
+
+	if err := mctx.Peer().Notify(ctx, evictedNotification, &userEvicted{
+		RoomID:  req.RoomID,
+		UserID:  req.UserID,
+		Code:    codeUserEvicted,
+		Message: localize(s.catalog, s.janusProxy, rtcCtx, req.RoomID, codeUserEvicted, nil),
+	}); err != nil {
+		s.logger.Debug("Failed to notify evicted user", log.Error(err))
+	}
+	//nolint:nilnil
+	return nil, nil
+}
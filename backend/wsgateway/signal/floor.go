@@ -0,0 +1,128 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+const (
+	// floorUnmuteMethod is dispatched by the connection manager to the connections of a room
+	// when the floor is granted with unmute, clients cannot call it
+	floorUnmuteMethod  = "floor.unmute"
+	floorUnmuteTimeout = 5 * time.Second
+)
+
+func (s *Server) handleRaiseHand(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+	room := rtcCtx.joinedRoom(params)
+	if room == nil {
+		return nil, jsonrpc.ErrInvalidRequest("not joined yet")
+	}
+
+	if err := s.userService.SetUserHand(rtcCtx.reqCtx, room.roomID, rtcCtx.userID, true); err != nil {
+		return nil, s.floorError("Failed to raise hand", room.roomID, rtcCtx, err)
+	}
+	//nolint:nilnil
+	return nil, nil
+}
+
+// handleLowerHand lowers the own hand, hosts may lower the hand of others
+func (s *Server) handleLowerHand(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+	room := rtcCtx.joinedRoom(params)
+	if room == nil {
+		return nil, jsonrpc.ErrInvalidRequest("not joined yet")
+	}
+
+	var data lowerHandParams
+	if params != nil {
+		if err := jsonrpc.ShouldBindParams(params, &data); err != nil {
+			return nil, jsonrpc.ErrInvalidParams("invalid lower hand parameters")
+		}
+	}
+	userID := rtcCtx.userID
+	if data.UserID != "" && data.UserID != userID {
+		if room.role != constants.UserRoleHost {
+			return nil, jsonrpc.ErrInvalidRequest("only hosts can lower hands of others")
+		}
+		userID = data.UserID
+	}
+
+	if err := s.userService.SetUserHand(rtcCtx.reqCtx, room.roomID, userID, false); err != nil {
+		return nil, s.floorError("Failed to lower hand", room.roomID, rtcCtx, err)
+	}
+	//nolint:nilnil
+	return nil, nil
+}
+
+func (s *Server) handleGrantFloor(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+	room := rtcCtx.joinedRoom(params)
+	if room == nil {
+		return nil, jsonrpc.ErrInvalidRequest("not joined yet")
+	}
+	if room.role != constants.UserRoleHost {
+		return nil, jsonrpc.ErrInvalidRequest("only hosts can grant the floor")
+	}
+
+	var data grantFloorParams
+	if params != nil {
+		if err := jsonrpc.ShouldBindParams(params, &data); err != nil {
+			return nil, jsonrpc.ErrInvalidParams("invalid grant floor parameters")
+		}
+	}
+
+	userID, err := s.userService.GrantFloor(rtcCtx.reqCtx, room.roomID, data.UserID, data.Unmute)
+	if err != nil {
+		return nil, s.floorError("Failed to grant floor", room.roomID, rtcCtx, err)
+	}
+	return map[string]any{"userId": userID}, nil
+}
+
+// handleFloorUnmute unmutes the speaker in Janus, runs on the connection's handler goroutine
+func (s *Server) handleFloorUnmute(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+
+	var req users.NotifyFloorGranted
+	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+		return nil, err
+	}
+	room := rtcCtx.room(req.RoomID)
+	// anchors of a frozen room, or without a publish slot, stay muted
+	if room == nil || !room.joined || room.janus == nil || room.frozen || room.publishRevoked ||
+		rtcCtx.userID != req.UserID {
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	// the context of the request that created the anchor may be gone already
+	ctx, cancel := context.WithTimeout(context.Background(), floorUnmuteTimeout)
+	defer cancel()
+	if err := room.janus.SetMuted(ctx, false); err != nil {
+		s.logger.Error("Failed to unmute speaker",
+			log.String("roomId", req.RoomID),
+			log.String("userId", req.UserID),
+			log.Error(err))
+	}
+	//nolint:nilnil
+	return nil, nil
+}
+
+// floorError passes rejections of the users controller to the client, other errors are internal
+func (s *Server) floorError(msg, roomID string, rtcCtx *rtcContext, err error) error {
+	if rpcErr, ok := errors.As[*jsonrpc.Error](err); ok {
+		return rpcErr
+	}
+	s.logger.Error(msg,
+		log.String("roomId", roomID),
+		log.String("userId", rtcCtx.userID),
+		log.Error(err))
+	return jsonrpc.ErrInternal("failed to update speaking queue")
+}
@@ -0,0 +1,143 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+const (
+	// queueChangeMethod is dispatched by the connection manager to the connections of a room
+	// when its join queue changes, clients cannot call it
+	queueChangeMethod = "queue.change"
+	// queuePositionNotification tells a parked client its place in the join queue
+	queuePositionNotification = "queue_position"
+)
+
+// queuePosition is the params of the queue_position notification
+type queuePosition struct {
+	RoomID string `json:"roomId"`
+	// Position is the place in the join queue from 1, 0 once no longer queued
+	Position int `json:"position"`
+	// Admitted is set once the user got an anchor slot, join again to go on
+	Admitted bool `json:"admitted,omitempty"`
+}
+
+// parkJoin checks the place of the user in the join queue of a room that has one, a queued
+// join is parked: the connection stays in the room for its queue_position notifications and
+// joins again once admitted. Returns nil for users not queued
+func (s *Server) parkJoin(mctx jsonrpc.MethodContext[rtcContext], room *roomContext) (any, error) {
+	rtcCtx := mctx.Get()
+
+	position, err := s.userService.QueuePosition(rtcCtx.reqCtx, room.roomID, rtcCtx.userID)
+	if err != nil {
+		s.logger.Error("Failed to get join queue position",
+			log.String("roomId", room.roomID),
+			log.String("userId", rtcCtx.userID),
+			log.Error(err))
+		return nil, jsonrpc.ErrInternal("fail to check join queue")
+	}
+	if position == 0 {
+		room.queuePosition = 0
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	if room.queuePosition == 0 {
+		joinsQueued.Add(rtcCtx.reqCtx, 1)
+		// rooms other than the one of the connection token are followed from now on
+		if room.roomID != rtcCtx.roomID {
+			rtcCtx.addRoom(room)
+			s.clientManager.AddClient(rtcCtx.connID, room.roomID, mctx.Peer())
+		}
+	}
+	room.queuePosition = position
+	return map[string]any{
+		"queued":   true,
+		"position": position,
+	}, nil
+}
+
+// queuedRoom returns the room of params the connection waits in the join queue of, nil when
+// not queued
+func (c *rtcContext) queuedRoom(params *json.RawMessage) *roomContext {
+	room := c.room(c.targetRoomID(params))
+	if room == nil || room.joined || room.queuePosition == 0 {
+		return nil
+	}
+	return room
+}
+
+// keepQueued asks the users service for the place of a parked join, which keeps it queued, and
+// tells the client when it moved
+func (s *Server) keepQueued(mctx jsonrpc.MethodContext[rtcContext], room *roomContext) (any, error) {
+	rtcCtx := mctx.Get()
+
+	position, err := s.userService.QueuePosition(rtcCtx.reqCtx, room.roomID, rtcCtx.userID)
+	if err != nil {
+		return nil, jsonrpc.ErrInternal("fail to check join queue")
+	}
+	s.moveInQueue(rtcCtx.reqCtx, mctx, room, position, false)
+	return map[string]any{"position": position}, nil
+}
+
+// leaveQueue gives up the place of a parked join
+func (s *Server) leaveQueue(rtcCtx *rtcContext, room *roomContext) {
+	room.queuePosition = 0
+	s.updateUserStatus(rtcCtx.reqCtx, room.roomID, rtcCtx.userID, constants.AnchorStatusLeft)
+	if room.roomID != rtcCtx.roomID {
+		rtcCtx.removeRoom(room.roomID)
+		s.clientManager.RemoveClientRoom(rtcCtx.connID, room.roomID)
+	}
+}
+
+// handleQueueChange tells a parked client its new place in the join queue of the room
+func (s *Server) handleQueueChange(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+
+	var req users.NotifyJoinQueue
+	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+		return nil, err
+	}
+	room := rtcCtx.room(req.RoomID)
+	if room == nil || room.joined || room.queuePosition == 0 {
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	admitted := slices.Contains(req.Admitted, rtcCtx.userID)
+	position := slices.Index(req.Queue, rtcCtx.userID) + 1
+	s.moveInQueue(rtcCtx.reqCtx, mctx, room, position, admitted)
+	//nolint:nilnil
+	return nil, nil
+}
+
+// moveInQueue records the place of a parked join and notifies the client when it changed
+func (s *Server) moveInQueue(
+	ctx context.Context,
+	mctx jsonrpc.MethodContext[rtcContext],
+	room *roomContext,
+	position int,
+	admitted bool,
+) {
+	if admitted {
+		position = 0
+	}
+	if position == room.queuePosition {
+		return
+	}
+	room.queuePosition = position
+
+	if err := mctx.Peer().Notify(ctx, queuePositionNotification, &queuePosition{
+		RoomID:   room.roomID,
+		Position: position,
+		Admitted: admitted,
+	}); err != nil {
+		s.logger.Debug("Failed to notify queue position", log.Error(err))
+	}
+}
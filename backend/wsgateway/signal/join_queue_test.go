@@ -0,0 +1,125 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+func (s *ServerSuite) TestJoinQueue() {
+	var notified []*queuePosition
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: context.Background(),
+		connID: "conn1",
+		roomID: "room1",
+		userID: "user1",
+	}, &roomContext{role: constants.UserRoleAnchor})
+	mctx := &mockMethodCtx{
+		rtcCtx: rtcCtx,
+		peer: &mockPeer{notifyFunc: func(_ context.Context, method string, p any) error {
+			s.Equal(queuePositionNotification, method)
+			notified = append(notified, p.(*queuePosition))
+			return nil
+		}},
+	}
+	rawParams := json.RawMessage(`{"clientId":"550e8400-e29b-41d4-a716-446655440000"}`)
+
+	// the join is parked while queued
+	s.janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{MaxAnchors: 1, JoinQueue: 5})
+	s.janusProxy.EXPECT().GetRoomLiveMeta("room1").Return(&etcdstate.LiveMeta{Status: constants.RoomStatusOnAir})
+	s.userService.EXPECT().QueuePosition(gomock.Any(), "room1", "user1").Return(2, nil)
+
+	res, err := s.server.handleJoin(mctx, &rawParams)
+	s.Require().NoError(err)
+	s.Equal(map[string]any{"queued": true, "position": 2}, res)
+	s.False(rtcCtx.tokenRoom().joined)
+	s.Equal(2, rtcCtx.tokenRoom().queuePosition)
+
+	// keepalives keep the place and report moves
+	s.userService.EXPECT().QueuePosition(gomock.Any(), "room1", "user1").Return(1, nil)
+	res, err = s.server.handleKeepAlive(mctx, &rawParams)
+	s.Require().NoError(err)
+	s.Equal(map[string]any{"position": 1}, res)
+
+	// others moving in the queue leave the position alone
+	other := json.RawMessage(`{"roomId":"room1","queue":["user1"],"admitted":["user0"]}`)
+	_, err = s.server.handleQueueChange(mctx, &other)
+	s.Require().NoError(err)
+
+	admitted := json.RawMessage(`{"roomId":"room1","queue":[],"admitted":["user1"]}`)
+	_, err = s.server.handleQueueChange(mctx, &admitted)
+	s.Require().NoError(err)
+	s.Equal(0, rtcCtx.tokenRoom().queuePosition)
+
+	s.Equal([]*queuePosition{
+		{RoomID: "room1", Position: 1},
+		{RoomID: "room1", Position: 0, Admitted: true},
+	}, notified)
+}
+
+func (s *ServerSuite) TestJoinQueue_Leave() {
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: context.Background(),
+		roomID: "room1",
+		userID: "user1",
+	}, &roomContext{role: constants.UserRoleAnchor, queuePosition: 3})
+
+	s.userService.EXPECT().SetUserStatus(gomock.Any(), "room1", "user1", constants.AnchorStatusLeft, gomock.Any()).Return(nil)
+
+	_, err := s.server.handleLeave(&mockMethodCtx{rtcCtx: rtcCtx}, nil)
+	s.Require().NoError(err)
+	s.Equal(0, rtcCtx.tokenRoom().queuePosition)
+
+	_, err = s.server.handleLeave(&mockMethodCtx{rtcCtx: rtcCtx}, nil)
+	s.Require().Error(err)
+}
+
+func (s *ClientManagerSuite) TestHandleJoinQueueChanged() {
+	var dispatched []string
+	for _, userID := range []string{"user1", "user2", "user3"} {
+		connID := "conn-" + userID
+		s.manager.AddClient(connID, "room1", &mockConn{
+			context: &rtcContext{connID: connID, roomID: "room1", userID: userID},
+			dispatchFunc: func(_ context.Context, method string, params any) error {
+				_, ok := params.(*users.NotifyJoinQueue)
+				s.Require().True(ok)
+				dispatched = append(dispatched, method+" "+userID)
+				return nil
+			},
+		})
+	}
+
+	rawParams := json.RawMessage(`{"roomId":"room1","queue":["user2"],"admitted":["user1"]}`)
+	_, err := s.manager.handleJoinQueueChanged(nil, &rawParams)
+	s.Require().NoError(err)
+	s.ElementsMatch([]string{"queue.change user1", "queue.change user2"}, dispatched)
+}
+
+func (s *ServerSuite) TestJoinQueue_JanusTokenNotResumed() {
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: context.Background(),
+		connID: "conn1",
+		roomID: "room1",
+		userID: "user1",
+	}, &roomContext{role: constants.UserRoleAnchor})
+	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
+	rawParams := json.RawMessage(`{"clientId":"550e8400-e29b-41d4-a716-446655440000","jtoken":"x"}`)
+
+	// a junk token waits in the queue like any other join
+	s.janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{MaxAnchors: 1, JoinQueue: 5})
+	s.janusProxy.EXPECT().GetRoomLiveMeta("room1").Return(&etcdstate.LiveMeta{Status: constants.RoomStatusOnAir, Nonce: "nonce"})
+	s.janusProxy.EXPECT().GetJanusAPI("room1").Return(s.janusAPI)
+	s.janusTokenCodec.EXPECT().Decode("nonce", "x").Return(int64(0), int64(0), errors.New("invalid token"))
+	s.userService.EXPECT().QueuePosition(gomock.Any(), "room1", "user1").Return(3, nil)
+
+	res, err := s.server.handleJoin(mctx, &rawParams)
+	s.Require().NoError(err)
+	s.Equal(map[string]any{"queued": true, "position": 3}, res)
+	s.False(rtcCtx.tokenRoom().joined)
+}
@@ -0,0 +1,76 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const (
+	// linkRegroupMethod is dispatched to the connections of a room when its state changes,
+	// anchors forwarded alone to a linked room move between AudioBridge groups, clients cannot call it
+	linkRegroupMethod  = "link.regroup"
+	linkRegroupTimeout = 5 * time.Second
+)
+
+// handleRoomChange has the connections of the room check their group, whether the room is
+// frozen and whether it is ending, on their own handler goroutine
+func (s *Server) handleRoomChange(roomID string) {
+	ending := s.janusProxy.GetRoomMeta(roomID).GetEndStage() != ""
+	for _, conn := range s.clientManager.getRoomConns(roomID) {
+		if err := conn.Dispatch(context.Background(), linkRegroupMethod, &roomParams{RoomID: roomID}); err != nil {
+			s.logger.Debug("Failed to dispatch regroup",
+				log.String("roomId", roomID),
+				log.Error(err))
+		}
+		if err := conn.Dispatch(context.Background(), roomFreezeMethod, &roomParams{RoomID: roomID}); err != nil {
+			s.logger.Debug("Failed to dispatch room freeze",
+				log.String("roomId", roomID),
+				log.Error(err))
+		}
+		if !ending {
+			continue
+		}
+		if err := conn.Dispatch(context.Background(), roomEndingMethod, &roomParams{RoomID: roomID}); err != nil {
+			s.logger.Debug("Failed to dispatch room ending",
+				log.String("roomId", roomID),
+				log.Error(err))
+		}
+	}
+}
+
+// handleLinkRegroup moves the participant to the group the room links want it in
+func (s *Server) handleLinkRegroup(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+	room := rtcCtx.room(rtcCtx.targetRoomID(params))
+	// not in the Janus room yet, the group is picked on join
+	if room == nil || room.janus == nil || room.group == "" {
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	group := s.janusProxy.GetLinkGroup(room.roomID, rtcCtx.userID)
+	if group == room.group {
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	// the context of the request that created the anchor may be gone already
+	ctx, cancel := context.WithTimeout(context.Background(), linkRegroupTimeout)
+	defer cancel()
+	if err := room.janus.SetGroup(ctx, group); err != nil {
+		s.logger.Error("Failed to move participant to group",
+			log.String("roomId", room.roomID),
+			log.String("userId", rtcCtx.userID),
+			log.String("group", group),
+			log.Error(err))
+		//nolint:nilnil
+		return nil, nil
+	}
+	room.group = group
+	//nolint:nilnil
+	return nil, nil
+}
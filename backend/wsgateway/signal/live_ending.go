@@ -0,0 +1,192 @@
+package signal
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/i18n"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/wsgateway"
+)
+
+const (
+	// liveEndingSoonNotification warns the anchors of a room its live is about to be stopped
+	liveEndingSoonNotification = "live_ending_soon"
+	liveEndingCheckInterval    = 5 * time.Second
+)
+
+// LiveEndingConfig of the warnings pushed to anchors of rooms with a max live duration
+type LiveEndingConfig struct {
+	// Warnings are the remaining live times anchors are warned at, empty disables warnings
+	Warnings []time.Duration `mapstructure:"warnings"`
+}
+
+func SetupLiveEnding(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("warnings"), []time.Duration{10 * time.Minute, time.Minute})
+}
+
+// liveEndingSoon is the params of the live_ending_soon notification
+type liveEndingSoon struct {
+	RoomID        string    `json:"roomId"`
+	EndsAt        time.Time `json:"endsAt"`
+	RemainingSecs int64     `json:"remainingSecs"`
+	Code          string    `json:"code"`
+	Message       string    `json:"message,omitempty"`
+}
+
+// liveWarnings tracks the warnings sent for a live of a room
+type liveWarnings struct {
+	liveStart time.Time
+	sent      int // warnings sent, as index into the descending thresholds
+}
+
+// LiveEndingNotifier warns the anchors connected to this gateway when the live of their room
+// nears its max duration, rooms housekeeping stops it once the max duration is reached
+type LiveEndingNotifier struct {
+	clientManager *WSConnManager
+	janusProxy    wsgateway.JanusProxy
+	catalog       *i18n.Catalog            // nil sends warnings without messages
+	thresholds    []time.Duration          // descending
+	warned        map[string]*liveWarnings // roomID -> warnings, only used by loop
+	clock         clockwork.Clock
+	cancel        context.CancelFunc
+	stopped       chan struct{}
+	logger        *log.Logger
+}
+
+func NewLiveEndingNotifier(
+	cfg *LiveEndingConfig,
+	clientManager *WSConnManager,
+	janusProxy wsgateway.JanusProxy,
+	catalog *i18n.Catalog,
+	logger *log.Logger,
+) *LiveEndingNotifier {
+	return newLiveEndingNotifier(cfg, clientManager, janusProxy, catalog, clockwork.NewRealClock(), logger)
+}
+
+func newLiveEndingNotifier(
+	cfg *LiveEndingConfig,
+	clientManager *WSConnManager,
+	janusProxy wsgateway.JanusProxy,
+	catalog *i18n.Catalog,
+	clock clockwork.Clock,
+	logger *log.Logger,
+) *LiveEndingNotifier {
+	thresholds := slices.DeleteFunc(slices.Clone(cfg.Warnings), func(d time.Duration) bool { return d <= 0 })
+	slices.SortFunc(thresholds, func(a, b time.Duration) int { return int(b - a) })
+
+	return &LiveEndingNotifier{
+		clientManager: clientManager,
+		janusProxy:    janusProxy,
+		catalog:       catalog,
+		thresholds:    slices.Compact(thresholds),
+		warned:        make(map[string]*liveWarnings),
+		clock:         clock,
+		stopped:       make(chan struct{}),
+		logger:        logger,
+	}
+}
+
+// Start starts the periodic check loop, nothing is checked without warnings configured
+func (n *LiveEndingNotifier) Start(ctx context.Context) error {
+	if len(n.thresholds) == 0 {
+		return nil
+	}
+	n.logger.Info("Starting live ending notifier", log.Any("warnings", n.thresholds))
+
+	ctx, n.cancel = context.WithCancel(ctx)
+	go n.loop(ctx)
+	return nil
+}
+
+// Stop stops the check loop
+func (n *LiveEndingNotifier) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		<-n.stopped
+	}
+}
+
+func (n *LiveEndingNotifier) loop(ctx context.Context) {
+	ticker := n.clock.NewTicker(liveEndingCheckInterval)
+	defer close(n.stopped)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.Chan():
+			n.check(n.clock.Now())
+		}
+	}
+}
+
+// check sends each warning a live crossed once, a warning crossed while the gateway had no
+// connections in the room is skipped for the following one
+func (n *LiveEndingNotifier) check(now time.Time) {
+	roomIDs := n.clientManager.roomIDs()
+	for roomID := range n.warned {
+		if !slices.Contains(roomIDs, roomID) {
+			delete(n.warned, roomID)
+		}
+	}
+
+	for _, roomID := range roomIDs {
+		maxDuration := n.janusProxy.GetRoomMeta(roomID).GetMaxDuration()
+		liveMeta := n.janusProxy.GetRoomLiveMeta(roomID)
+		if maxDuration <= 0 || liveMeta.GetStatus() != constants.RoomStatusOnAir {
+			delete(n.warned, roomID)
+			continue
+		}
+
+		endsAt := liveMeta.CreatedAt.Add(maxDuration)
+		remaining := endsAt.Sub(now)
+		if remaining <= 0 {
+			continue
+		}
+
+		warned, ok := n.warned[roomID]
+		if !ok || !warned.liveStart.Equal(liveMeta.CreatedAt) {
+			warned = &liveWarnings{liveStart: liveMeta.CreatedAt}
+			n.warned[roomID] = warned
+		}
+
+		// thresholds are descending, all up to the crossed one are due
+		crossed := 0
+		for crossed < len(n.thresholds) && n.thresholds[crossed] >= remaining {
+			crossed++
+		}
+		if crossed <= warned.sent {
+			continue
+		}
+		warned.sent = crossed
+
+		liveEndingWarnings.Add(context.Background(), 1)
+		n.logger.Info("Warning anchors of live ending soon",
+			log.String("roomId", roomID),
+			log.Duration("remaining", remaining))
+		args := map[string]string{
+			// rounded up, a warning at 59s left reads 1 minute
+			"minutes": strconv.FormatInt(int64((remaining+time.Minute-1)/time.Minute), 10),
+			"seconds": strconv.FormatInt(int64(remaining.Seconds()), 10),
+		}
+		n.clientManager.notifyRoomLocalPeerEach(roomID, liveEndingSoonNotification, func(rtcCtx *rtcContext) any {
+			return &liveEndingSoon{
+				RoomID:        roomID,
+				EndsAt:        endsAt.UTC(),
+				RemainingSecs: int64(remaining.Seconds()),
+				Code:          codeLiveEndingSoon,
+				Message:       localize(n.catalog, n.janusProxy, rtcCtx, roomID, codeLiveEndingSoon, args),
+			}
+		})
+	}
+}
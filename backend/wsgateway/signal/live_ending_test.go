@@ -0,0 +1,94 @@
+package signal
+
+import (
+	"context"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	wsgymocks "github.com/imtaco/audio-rtc-exp/wsgateway/mocks"
+)
+
+func (s *ClientManagerSuite) TestLiveEndingNotifier() {
+	liveStart := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	janusProxy := wsgymocks.NewMockJanusProxy(s.ctrl)
+	notifier := newLiveEndingNotifier(
+		&LiveEndingConfig{Warnings: []time.Duration{time.Minute, 10 * time.Minute, 0}},
+		s.manager,
+		janusProxy,
+		newTestCatalog(s.T()),
+		clockwork.NewFakeClockAt(liveStart),
+		s.logger,
+	)
+	s.Equal([]time.Duration{10 * time.Minute, time.Minute}, notifier.thresholds)
+
+	var warnings, jaWarnings []*liveEndingSoon
+	s.manager.AddClient("conn1", "room1", &mockConn{
+		context: &rtcContext{reqCtx: context.Background()},
+		notifyFunc: func(_ context.Context, method string, params any) error {
+			s.Equal(liveEndingSoonNotification, method)
+			warnings = append(warnings, params.(*liveEndingSoon))
+			return nil
+		},
+	})
+	// localized in the locale of its token
+	s.manager.AddClient("conn3", "room1", &mockConn{
+		context: &rtcContext{reqCtx: context.Background(), locale: "ja"},
+		notifyFunc: func(_ context.Context, _ string, params any) error {
+			jaWarnings = append(jaWarnings, params.(*liveEndingSoon))
+			return nil
+		},
+	})
+	s.manager.AddClient("conn2", "room2", &mockConn{context: &rtcContext{reqCtx: context.Background()}})
+
+	janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{MaxDuration: 1800}).AnyTimes()
+	janusProxy.EXPECT().GetRoomLiveMeta("room1").Return(&etcdstate.LiveMeta{
+		Status:    constants.RoomStatusOnAir,
+		CreatedAt: liveStart,
+	}).AnyTimes()
+	// unlimited room
+	janusProxy.EXPECT().GetRoomMeta("room2").Return(&etcdstate.Meta{}).AnyTimes()
+	janusProxy.EXPECT().GetRoomLiveMeta("room2").Return(&etcdstate.LiveMeta{
+		Status:    constants.RoomStatusOnAir,
+		CreatedAt: liveStart,
+	}).AnyTimes()
+
+	s.Run("nothing before the first threshold", func() {
+		notifier.check(liveStart.Add(19 * time.Minute))
+		s.Empty(warnings)
+	})
+
+	s.Run("warns once per threshold", func() {
+		notifier.check(liveStart.Add(20 * time.Minute))
+		notifier.check(liveStart.Add(25 * time.Minute))
+		s.Require().Len(warnings, 1)
+		s.Equal(&liveEndingSoon{
+			RoomID:        "room1",
+			EndsAt:        liveStart.Add(30 * time.Minute),
+			RemainingSecs: 600,
+			Code:          codeLiveEndingSoon,
+			Message:       "Live ends in 10 min",
+		}, warnings[0])
+		s.Require().Len(jaWarnings, 1)
+		s.Equal("ライブ終了まで10分", jaWarnings[0].Message)
+
+		notifier.check(liveStart.Add(29*time.Minute + 30*time.Second))
+		s.Require().Len(warnings, 2)
+		s.Equal(int64(30), warnings[1].RemainingSecs)
+		s.Equal("Live ends in 1 min", warnings[1].Message)
+	})
+
+	s.Run("stops warning past the end", func() {
+		notifier.check(liveStart.Add(31 * time.Minute))
+		s.Len(warnings, 2)
+	})
+
+	s.Run("forgets rooms without connections", func() {
+		s.manager.RemoveClient("conn1")
+		s.manager.RemoveClient("conn3")
+		notifier.check(liveStart.Add(31 * time.Minute))
+		s.NotContains(notifier.warned, "room1")
+	})
+}
@@ -0,0 +1,34 @@
+package signal
+
+import (
+	"github.com/imtaco/audio-rtc-exp/internal/i18n"
+	"github.com/imtaco/audio-rtc-exp/wsgateway"
+)
+
+// Machine codes of the notifications, clients switch on them and show the localized message
+// when one is sent. They are the message codes of the catalog too
+const (
+	codeUserEvicted    = "user_evicted"
+	codeRoomEnding     = "room_ending"
+	codeLiveEndingSoon = "live_ending_soon"
+)
+
+// localize returns the catalog message of the code for the connection, in the locale of its
+// token, else in the locale of the room. "" without a catalog or a message for the code
+func localize(
+	catalog *i18n.Catalog,
+	janusProxy wsgateway.JanusProxy,
+	rtcCtx *rtcContext,
+	roomID,
+	code string,
+	args map[string]string,
+) string {
+	if catalog == nil {
+		return ""
+	}
+	locale := rtcCtx.locale
+	if locale == "" {
+		locale = janusProxy.GetRoomMeta(roomID).GetLocale()
+	}
+	return catalog.Message(locale, code, args)
+}
@@ -0,0 +1,61 @@
+package signal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/i18n"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	wsgymocks "github.com/imtaco/audio-rtc-exp/wsgateway/mocks"
+)
+
+// newTestCatalog returns a catalog with English and Japanese notification messages
+func newTestCatalog(t *testing.T) *i18n.Catalog {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "messages.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{
+		"en": {
+			"user_evicted": "You were removed from the stage",
+			"room_ending": "The room is ending ({stage})",
+			"live_ending_soon": "Live ends in {minutes} min"
+		},
+		"ja": {"live_ending_soon": "ライブ終了まで{minutes}分"}
+	}`), 0o600))
+	catalog, err := i18n.New(&i18n.Config{File: file, DefaultLocale: "en"}, log.NewTest(t))
+	require.NoError(t, err)
+	return catalog
+}
+
+func TestLocalize(t *testing.T) {
+	catalog := newTestCatalog(t)
+	args := map[string]string{"minutes": "5"}
+
+	t.Run("token locale", func(t *testing.T) {
+		janusProxy := wsgymocks.NewMockJanusProxy(gomock.NewController(t))
+		rtcCtx := &rtcContext{locale: "ja-JP"}
+		assert.Equal(t, "ライブ終了まで5分", localize(catalog, janusProxy, rtcCtx, "room1", codeLiveEndingSoon, args))
+	})
+
+	t.Run("room locale", func(t *testing.T) {
+		janusProxy := wsgymocks.NewMockJanusProxy(gomock.NewController(t))
+		janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{Locale: "ja"})
+		assert.Equal(t, "ライブ終了まで5分", localize(catalog, janusProxy, &rtcContext{}, "room1", codeLiveEndingSoon, args))
+	})
+
+	t.Run("default locale", func(t *testing.T) {
+		janusProxy := wsgymocks.NewMockJanusProxy(gomock.NewController(t))
+		janusProxy.EXPECT().GetRoomMeta("room1").Return(nil)
+		assert.Equal(t, "Live ends in 5 min", localize(catalog, janusProxy, &rtcContext{}, "room1", codeLiveEndingSoon, args))
+	})
+
+	t.Run("no catalog", func(t *testing.T) {
+		janusProxy := wsgymocks.NewMockJanusProxy(gomock.NewController(t))
+		assert.Empty(t, localize(nil, janusProxy, &rtcContext{locale: "ja"}, "room1", codeLiveEndingSoon, args))
+	})
+}
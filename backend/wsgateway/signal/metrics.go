@@ -20,14 +20,33 @@ var (
 	// RPC metrics
 	rpcRequestsTotal  metric.Int64Counter
 	rpcRequestsFailed metric.Int64Counter
+	rpcDuration       metric.Float64Histogram
+	rpcSlowCalls      metric.Int64Counter
+
+	// Joined participants of this gateway
+	joinsActive metric.Int64UpDownCounter
+	// Offers refused to anchors holding no publish slot of a push-to-talk room
+	publishRefused metric.Int64Counter
+	// Joins parked in the join queue of a full room
+	joinsQueued metric.Int64Counter
+
+	// ICE restart metrics
+	iceRestarts       metric.Int64Counter
+	iceRestartsFailed metric.Int64Counter
 
 	// Auth metrics
 	authAttempts metric.Int64Counter
 	authFailures metric.Int64Counter
+	pinFailures  metric.Int64Counter
+	pinLockouts  metric.Int64Counter
 
 	// Notification metrics
 	notificationsSent   metric.Int64Counter
 	notificationsFailed metric.Int64Counter
+	liveEndingWarnings  metric.Int64Counter
+
+	// Partitioned notify metrics
+	notifyPartitionsOwned metric.Int64UpDownCounter
 )
 
 func init() {
@@ -57,15 +76,47 @@ func init() {
 	f.Int64Counter(&rpcRequestsFailed, "rpc.requests.failed",
 		metric.WithDescription("Total failed RPC requests"))
 
+	f.Float64Histogram(&rpcDuration, "rpc.duration",
+		metric.WithDescription("Duration of RPC requests by method and outcome"),
+		metric.WithUnit("s"))
+
+	f.Int64Counter(&rpcSlowCalls, "rpc.slow",
+		metric.WithDescription("RPC requests lasting longer than the slow call threshold"))
+
+	f.Int64UpDownCounter(&joinsActive, "joins.active",
+		metric.WithDescription("Connections joined to their room on this gateway"))
+	f.Int64Counter(&publishRefused, "publish.refused",
+		metric.WithDescription("Offers refused to anchors without a publish slot of a push-to-talk room"))
+	f.Int64Counter(&joinsQueued, "joins.queued",
+		metric.WithDescription("Joins parked in the join queue of a full room"))
+
 	f.Int64Counter(&authAttempts, "auth.attempts",
 		metric.WithDescription("Total authentication attempts"))
 
 	f.Int64Counter(&authFailures, "auth.failures",
 		metric.WithDescription("Total authentication failures"))
 
+	f.Int64Counter(&pinFailures, "pin.failures",
+		metric.WithDescription("Total joins rejected for a wrong room PIN"))
+
+	f.Int64Counter(&pinLockouts, "pin.lockouts",
+		metric.WithDescription("Total join lockouts after too many wrong room PINs"))
+
 	f.Int64Counter(&notificationsSent, "notifications.sent",
 		metric.WithDescription("Total notifications sent to clients"))
 
 	f.Int64Counter(&notificationsFailed, "notifications.failed",
 		metric.WithDescription("Total failed notification deliveries"))
+
+	f.Int64Counter(&liveEndingWarnings, "notifications.live_ending",
+		metric.WithDescription("Live ending soon warnings sent to rooms"))
+
+	f.Int64Counter(&iceRestarts, "ice.restarts",
+		metric.WithDescription("ICE restarts requested by anchors"))
+
+	f.Int64Counter(&iceRestartsFailed, "ice.restarts.failed",
+		metric.WithDescription("ICE restarts failed against Janus"))
+
+	f.Int64UpDownCounter(&notifyPartitionsOwned, "notify.partitions.owned",
+		metric.WithDescription("Partitions of the ws-notify stream consumed by this gateway"))
 }
@@ -0,0 +1,169 @@
+package signal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	redisrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/redis"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+)
+
+const (
+	// notifyConsumerGroup is shared by all gateways so an entry is handled by one of them
+	notifyConsumerGroup = "wsgateway"
+	// rebalanceInterval is below the server heartbeat TTL so departed gateways are noticed quickly
+	rebalanceInterval = 2 * time.Second
+)
+
+// notifyPartitions consumes the partitions of the ws-notify stream owned by this gateway.
+// Partitions are spread over the live gateways of the presence registry by rendezvous hashing,
+// so notifications of a room are handled in order by the single owner of its partition. Rooms
+// must be routed to the owner of their partition, notifications of other rooms are not seen.
+type notifyPartitions struct {
+	stream     string
+	partitions int
+	connGuard  ConnectionGuard
+	register   func(peer jsonrpc.Peer[any])
+	newPeer    func(partition int) (jsonrpc.Peer[any], error)
+	owned      map[int]jsonrpc.Peer[any] // partition -> consuming peer, only used by loop
+	notifier   redisrpc.Notifier
+	cancel     context.CancelFunc
+	stopped    chan struct{}
+	logger     *log.Logger
+}
+
+func newNotifyPartitions(
+	redisClient *redis.Client,
+	stream string,
+	partitions int,
+	notifyBuffer *redisstream.BufferConfig,
+	connGuard ConnectionGuard,
+	register func(peer jsonrpc.Peer[any]),
+	logger *log.Logger,
+) (*notifyPartitions, error) {
+	notifier, err := redisrpc.NewNotifier(redisClient, stream, partitions, notifyBuffer, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WS notifier: %w", err)
+	}
+
+	return &notifyPartitions{
+		stream:     stream,
+		partitions: partitions,
+		connGuard:  connGuard,
+		register:   register,
+		newPeer: func(partition int) (jsonrpc.Peer[any], error) {
+			// the server ID names the consumer, so a restarted peer resumes its pending entries
+			return redisrpc.NewGroupPeer[any](
+				redisClient,
+				"",
+				redisstream.PartitionStream(stream, partition),
+				notifyConsumerGroup,
+				connGuard.GetServerID(),
+				logger,
+			)
+		},
+		owned:    make(map[int]jsonrpc.Peer[any]),
+		notifier: notifier,
+		stopped:  make(chan struct{}),
+		logger:   logger,
+	}, nil
+}
+
+// Start takes the partitions owned now and keeps following membership changes
+func (p *notifyPartitions) Start(ctx context.Context) error {
+	p.logger.Info("Starting partitioned WS notify consumer",
+		log.String("stream", p.stream),
+		log.Int("partitions", p.partitions))
+
+	if err := p.notifier.Open(ctx); err != nil {
+		return fmt.Errorf("failed to open WS notifier: %w", err)
+	}
+
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.rebalance(ctx)
+	go p.loop(ctx)
+	return nil
+}
+
+// Stop releases all owned partitions
+func (p *notifyPartitions) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+		<-p.stopped
+	}
+	for partition := range p.owned {
+		p.release(partition)
+	}
+	if err := p.notifier.Close(); err != nil {
+		p.logger.Error("Failed to close WS notifier", log.Error(err))
+	}
+}
+
+func (p *notifyPartitions) loop(ctx context.Context) {
+	ticker := time.NewTicker(rebalanceInterval)
+	defer close(p.stopped)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.rebalance(ctx)
+		}
+	}
+}
+
+// rebalance consumes newly owned partitions and releases the ones moved to other gateways,
+// ownership is kept as is when the registry can not be read
+func (p *notifyPartitions) rebalance(ctx context.Context) {
+	gateways, err := p.connGuard.Gateways(ctx)
+	if err != nil {
+		p.logger.Warn("Failed to list gateways, keeping partitions", log.Error(err))
+		return
+	}
+
+	owned := redisstream.OwnedPartitions(p.connGuard.GetServerID(), gateways, p.partitions)
+	for partition := range p.owned {
+		if !slices.Contains(owned, partition) {
+			p.release(partition)
+		}
+	}
+	for _, partition := range owned {
+		if _, ok := p.owned[partition]; !ok {
+			p.acquire(ctx, partition)
+		}
+	}
+}
+
+func (p *notifyPartitions) acquire(ctx context.Context, partition int) {
+	peer, err := p.newPeer(partition)
+	if err == nil {
+		p.register(peer)
+		err = peer.Open(ctx)
+	}
+	if err != nil {
+		// retried on the next rebalance
+		p.logger.Error("Failed to consume partition", log.Int("partition", partition), log.Error(err))
+		return
+	}
+
+	p.owned[partition] = peer
+	notifyPartitionsOwned.Add(ctx, 1)
+	p.logger.Info("Consuming partition", log.Int("partition", partition))
+}
+
+func (p *notifyPartitions) release(partition int) {
+	if err := p.owned[partition].Close(); err != nil {
+		p.logger.Error("Failed to close partition peer", log.Int("partition", partition), log.Error(err))
+	}
+	delete(p.owned, partition)
+	notifyPartitionsOwned.Add(context.Background(), -1)
+	p.logger.Info("Released partition", log.Int("partition", partition))
+}
@@ -0,0 +1,93 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	rpcmocks "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/mocks"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+)
+
+func (s *ClientManagerSuite) TestNotifyPartitionsRebalance() {
+	const partitions = 8
+	ctx := context.Background()
+	connGuard := NewMockConnectionGuard(s.ctrl)
+	connGuard.EXPECT().GetServerID().Return("gw-a").AnyTimes()
+
+	parts, err := newNotifyPartitions(s.client, "test:ws:stream", partitions, nil, connGuard, s.manager.register, s.logger)
+	s.Require().NoError(err)
+
+	peers := make(map[int]*rpcmocks.MockPeer[any])
+	parts.newPeer = func(partition int) (jsonrpc.Peer[any], error) {
+		peer := rpcmocks.NewMockPeer[any](s.ctrl)
+		peer.EXPECT().Def(gomock.Any(), gomock.Any()).Times(9)
+		peer.EXPECT().Open(gomock.Any()).Return(nil)
+		peers[partition] = peer
+		return peer, nil
+	}
+	owned := func() []int {
+		var ps []int
+		for p := range parts.owned {
+			ps = append(ps, p)
+		}
+		return ps
+	}
+
+	s.Run("alone owns every partition", func() {
+		connGuard.EXPECT().Gateways(ctx).Return(nil, nil)
+		parts.rebalance(ctx)
+		s.Len(parts.owned, partitions)
+	})
+
+	s.Run("releases partitions moved to a joining gateway", func() {
+		members := []string{"gw-a", "gw-b"}
+		kept := redisstream.OwnedPartitions("gw-a", members, partitions)
+		for p, peer := range peers {
+			if !slices.Contains(kept, p) {
+				peer.EXPECT().Close().Return(nil)
+			}
+		}
+
+		connGuard.EXPECT().Gateways(ctx).Return(members, nil)
+		parts.rebalance(ctx)
+		s.ElementsMatch(kept, owned())
+	})
+
+	s.Run("keeps partitions when the registry fails", func() {
+		before := owned()
+		connGuard.EXPECT().Gateways(ctx).Return(nil, errors.New("redis down"))
+		parts.rebalance(ctx)
+		s.ElementsMatch(before, owned())
+	})
+
+	s.Run("takes partitions back when the gateway leaves", func() {
+		connGuard.EXPECT().Gateways(ctx).Return([]string{"gw-a"}, nil)
+		parts.rebalance(ctx)
+		s.Len(parts.owned, partitions)
+	})
+
+	s.Run("retries partitions failing to open", func() {
+		members := []string{"gw-a", "gw-b", "gw-c"}
+		kept := redisstream.OwnedPartitions("gw-a", members, partitions)
+		for p, peer := range parts.owned {
+			if !slices.Contains(kept, p) {
+				peer.(*rpcmocks.MockPeer[any]).EXPECT().Close().Return(nil)
+			}
+		}
+		connGuard.EXPECT().Gateways(ctx).Return(members, nil)
+		parts.rebalance(ctx)
+		s.Require().Less(len(parts.owned), partitions)
+
+		parts.newPeer = func(int) (jsonrpc.Peer[any], error) {
+			return nil, errors.New("boom")
+		}
+		connGuard.EXPECT().Gateways(ctx).Return([]string{"gw-a"}, nil)
+		before := len(parts.owned)
+		parts.rebalance(ctx)
+		s.Len(parts.owned, before)
+	})
+}
@@ -0,0 +1,146 @@
+package signal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+var (
+	// Lua script counting a failed PIN attempt, the lock is set once attempts reach the limit
+	// KEYS[1]: attempts key
+	// KEYS[2]: lock key
+	// ARGV[1]: max attempts
+	// ARGV[2]: attempts window in milliseconds
+	// ARGV[3]: lockout in milliseconds
+	luaPinFail = redis.NewScript(`
+		local n = redis.call('INCR', KEYS[1])
+		if n == 1 then
+			redis.call('PEXPIRE', KEYS[1], ARGV[2])
+		end
+		if n >= tonumber(ARGV[1]) then
+			redis.call('SET', KEYS[2], '1', 'PX', ARGV[3])
+			redis.call('DEL', KEYS[1])
+			return 1
+		end
+		return 0
+	`)
+)
+
+// PinThrottleConfig limits failed PIN attempts of a connection and of a user in a room,
+// reaching either limit locks joins out for a while
+type PinThrottleConfig struct {
+	ConnAttempts int           `mapstructure:"conn_attempts"`
+	UserAttempts int           `mapstructure:"user_attempts"`
+	Window       time.Duration `mapstructure:"window"`
+	Lockout      time.Duration `mapstructure:"lockout"`
+}
+
+func SetupPinThrottle(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("conn_attempts"), 3)
+	v.SetDefault(p("user_attempts"), 5)
+	v.SetDefault(p("window"), "10m")
+	v.SetDefault(p("lockout"), "15m")
+}
+
+type pinGuardImpl struct {
+	redisClient *redis.Client
+	prefix      string
+	cfg         *PinThrottleConfig
+	logger      *log.Logger
+}
+
+func NewPinGuard(
+	redisClient *redis.Client,
+	redisPrefix string,
+	cfg *PinThrottleConfig,
+	logger *log.Logger,
+) PinGuard {
+	return &pinGuardImpl{
+		redisClient: redisClient,
+		prefix:      redisPrefix,
+		cfg:         cfg,
+		logger:      logger,
+	}
+}
+
+func (g *pinGuardImpl) connKeys(connID string) (string, string) {
+	return fmt.Sprintf("%s:pf:c:%s", g.prefix, connID), fmt.Sprintf("%s:pl:c:%s", g.prefix, connID)
+}
+
+func (g *pinGuardImpl) userKeys(roomID, userID string) (string, string) {
+	return fmt.Sprintf("%s:pf:u:%s:%s", g.prefix, roomID, userID),
+		fmt.Sprintf("%s:pl:u:%s:%s", g.prefix, roomID, userID)
+}
+
+func (g *pinGuardImpl) Locked(ctx context.Context, connID, roomID, userID string) (time.Duration, error) {
+	_, connLock := g.connKeys(connID)
+	_, userLock := g.userKeys(roomID, userID)
+
+	pipe := g.redisClient.Pipeline()
+	connTTL := pipe.PTTL(ctx, connLock)
+	userTTL := pipe.PTTL(ctx, userLock)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to check pin lockout: %w", err)
+	}
+
+	// PTTL is negative for keys not present
+	return max(connTTL.Val(), userTTL.Val(), 0), nil
+}
+
+func (g *pinGuardImpl) Fail(ctx context.Context, connID, roomID, userID string) (time.Duration, error) {
+	connAttempts, connLock := g.connKeys(connID)
+	userAttempts, userLock := g.userKeys(roomID, userID)
+
+	connLocked, err := g.fail(ctx, connAttempts, connLock, g.cfg.ConnAttempts)
+	if err != nil {
+		return 0, err
+	}
+	userLocked, err := g.fail(ctx, userAttempts, userLock, g.cfg.UserAttempts)
+	if err != nil {
+		return 0, err
+	}
+	if !connLocked && !userLocked {
+		return 0, nil
+	}
+
+	g.logger.Warn("Join locked out after failed pin attempts",
+		log.String("connId", connID),
+		log.String("roomId", roomID),
+		log.String("userId", userID),
+		log.Bool("userLocked", userLocked),
+		log.Duration("lockout", g.cfg.Lockout))
+	return g.cfg.Lockout, nil
+}
+
+func (g *pinGuardImpl) fail(ctx context.Context, attemptsKey, lockKey string, maxAttempts int) (bool, error) {
+	result, err := luaPinFail.Run(
+		ctx,
+		g.redisClient,
+		[]string{attemptsKey, lockKey},
+		maxAttempts,
+		g.cfg.Window.Milliseconds(),
+		g.cfg.Lockout.Milliseconds(),
+	).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to count pin attempt: %w", err)
+	}
+	return result == 1, nil
+}
+
+func (g *pinGuardImpl) Reset(ctx context.Context, connID, roomID, userID string) error {
+	connAttempts, _ := g.connKeys(connID)
+	userAttempts, _ := g.userKeys(roomID, userID)
+
+	if err := g.redisClient.Del(ctx, connAttempts, userAttempts).Err(); err != nil {
+		return fmt.Errorf("failed to reset pin attempts: %w", err)
+	}
+	return nil
+}
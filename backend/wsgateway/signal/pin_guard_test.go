@@ -0,0 +1,96 @@
+package signal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type PinGuardSuite struct {
+	suite.Suite
+	miniRedis *miniredis.Miniredis
+	client    *redis.Client
+	guard     PinGuard
+	ctx       context.Context
+}
+
+func TestPinGuardSuite(t *testing.T) {
+	suite.Run(t, new(PinGuardSuite))
+}
+
+func (s *PinGuardSuite) SetupTest() {
+	mr, err := miniredis.Run()
+	s.Require().NoError(err)
+	s.miniRedis = mr
+	s.client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	s.ctx = context.Background()
+
+	s.guard = NewPinGuard(s.client, "test", &PinThrottleConfig{
+		ConnAttempts: 2,
+		UserAttempts: 3,
+		Window:       time.Minute,
+		Lockout:      5 * time.Minute,
+	}, log.NewNop())
+}
+
+func (s *PinGuardSuite) TearDownTest() {
+	s.client.Close()
+	s.miniRedis.Close()
+}
+
+func (s *PinGuardSuite) fail(connID string) time.Duration {
+	lockout, err := s.guard.Fail(s.ctx, connID, "room1", "user1")
+	s.Require().NoError(err)
+	return lockout
+}
+
+func (s *PinGuardSuite) locked(connID string) time.Duration {
+	lockout, err := s.guard.Locked(s.ctx, connID, "room1", "user1")
+	s.Require().NoError(err)
+	return lockout
+}
+
+func (s *PinGuardSuite) TestNotLocked() {
+	s.Zero(s.locked("conn1"))
+}
+
+func (s *PinGuardSuite) TestConnLockout() {
+	s.Zero(s.fail("conn1"))
+	s.Equal(5*time.Minute, s.fail("conn1"))
+
+	s.InDelta(5*time.Minute, s.locked("conn1"), float64(time.Second))
+	// user limit not reached yet, other connections of the user may still try
+	s.Zero(s.locked("conn2"))
+}
+
+func (s *PinGuardSuite) TestUserLockoutAcrossConnections() {
+	s.Zero(s.fail("conn1"))
+	s.Zero(s.fail("conn2"))
+	s.Equal(5*time.Minute, s.fail("conn3"))
+
+	s.Positive(s.locked("conn4"))
+
+	// lockout expires
+	s.miniRedis.FastForward(5 * time.Minute)
+	s.Zero(s.locked("conn4"))
+}
+
+func (s *PinGuardSuite) TestAttemptsWindowExpires() {
+	s.Zero(s.fail("conn1"))
+	s.miniRedis.FastForward(time.Minute)
+	s.Zero(s.fail("conn1"))
+	s.Zero(s.locked("conn1"))
+}
+
+func (s *PinGuardSuite) TestReset() {
+	s.Zero(s.fail("conn1"))
+	s.Require().NoError(s.guard.Reset(s.ctx, "conn1", "room1", "user1"))
+	s.Zero(s.fail("conn1"))
+	s.Zero(s.locked("conn1"))
+}
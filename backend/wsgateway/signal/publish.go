@@ -0,0 +1,173 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	etcdstate "github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+const (
+	// codePublishNotGranted is returned to offers of anchors holding no publish slot of a
+	// push-to-talk room, they call requestPublish and offer again once granted
+	codePublishNotGranted = -32004
+
+	// publishChangeMethod is dispatched by the connection manager to the connections of a room
+	// when a publish slot is granted or revoked, clients cannot call it
+	publishChangeMethod  = "publish.change"
+	publishChangeTimeout = 5 * time.Second
+	// publishChangedNotification tells the client its publish slot was granted or revoked
+	publishChangedNotification = "publish_changed"
+)
+
+// publishChanged is the params of the publish_changed notification
+type publishChanged struct {
+	RoomID  string `json:"roomId"`
+	Granted bool   `json:"granted"`
+}
+
+// handleRequestPublish asks the hosts of a push-to-talk room for a publish slot, the request
+// waits in the speaking queue until granted
+func (s *Server) handleRequestPublish(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+	room := rtcCtx.joinedRoom(params)
+	if room == nil {
+		return nil, jsonrpc.ErrInvalidRequest("not joined yet")
+	}
+	if s.janusProxy.GetRoomMeta(room.roomID).GetPublishSlots() == 0 {
+		return nil, jsonrpc.ErrInvalidRequest("room is not push-to-talk")
+	}
+
+	if err := s.userService.SetUserHand(rtcCtx.reqCtx, room.roomID, rtcCtx.userID, true); err != nil {
+		return nil, s.floorError("Failed to request publish", room.roomID, rtcCtx, err)
+	}
+	//nolint:nilnil
+	return nil, nil
+}
+
+func (s *Server) handleGrantPublish(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+	room := rtcCtx.joinedRoom(params)
+	if room == nil {
+		return nil, jsonrpc.ErrInvalidRequest("not joined yet")
+	}
+	if room.role != constants.UserRoleHost {
+		return nil, jsonrpc.ErrInvalidRequest("only hosts can grant publish")
+	}
+	slots := s.janusProxy.GetRoomMeta(room.roomID).GetPublishSlots()
+	if slots == 0 {
+		return nil, jsonrpc.ErrInvalidRequest("room is not push-to-talk")
+	}
+
+	var data grantPublishParams
+	if params != nil {
+		if err := jsonrpc.ShouldBindParams(params, &data); err != nil {
+			return nil, jsonrpc.ErrInvalidParams("invalid grant publish parameters")
+		}
+	}
+
+	userID, err := s.userService.GrantPublish(rtcCtx.reqCtx, room.roomID, data.UserID, slots)
+	if err != nil {
+		return nil, s.floorError("Failed to grant publish", room.roomID, rtcCtx, err)
+	}
+	return map[string]any{"userId": userID}, nil
+}
+
+// handleRevokePublish gives the own publish slot back, hosts may revoke the slot of others
+func (s *Server) handleRevokePublish(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+	room := rtcCtx.joinedRoom(params)
+	if room == nil {
+		return nil, jsonrpc.ErrInvalidRequest("not joined yet")
+	}
+
+	var data revokePublishParams
+	if params != nil {
+		if err := jsonrpc.ShouldBindParams(params, &data); err != nil {
+			return nil, jsonrpc.ErrInvalidParams("invalid revoke publish parameters")
+		}
+	}
+	userID := rtcCtx.userID
+	if data.UserID != "" && data.UserID != userID {
+		if room.role != constants.UserRoleHost {
+			return nil, jsonrpc.ErrInvalidRequest("only hosts can revoke publish of others")
+		}
+		userID = data.UserID
+	}
+
+	if err := s.userService.RevokePublish(rtcCtx.reqCtx, room.roomID, userID); err != nil {
+		return nil, s.floorError("Failed to revoke publish", room.roomID, rtcCtx, err)
+	}
+	//nolint:nilnil
+	return nil, nil
+}
+
+// checkPublish refuses offers of anchors holding no publish slot of a push-to-talk room, hosts
+// moderate the room and always publish
+func (s *Server) checkPublish(ctx context.Context, rtcCtx *rtcContext, room *roomContext, meta *etcdstate.Meta) error {
+	if meta.GetPublishSlots() == 0 || room.role == constants.UserRoleHost {
+		return nil
+	}
+
+	members, err := s.userService.GetActiveRoomUsers(ctx, room.roomID)
+	if err != nil {
+		s.logger.Error("Failed to check publish slot",
+			log.String("roomId", room.roomID),
+			log.String("userId", rtcCtx.userID),
+			log.Error(err))
+		return jsonrpc.ErrInternal("failed to check publish slot")
+	}
+	for _, member := range members {
+		if member.UserID == rtcCtx.userID && member.Publish {
+			room.publishRevoked = false
+			return nil
+		}
+	}
+	publishRefused.Add(ctx, 1)
+	return jsonrpc.ErrCustom(codePublishNotGranted, "publish not granted, call requestPublish")
+}
+
+// handlePublishChange unmutes the anchor granted a publish slot and mutes it once revoked,
+// telling the peer either way. Runs on the connection's handler goroutine
+func (s *Server) handlePublishChange(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+
+	var req users.NotifyPublish
+	if err := jsonrpc.ShouldBindParams(params, &req); err != nil {
+		return nil, err
+	}
+	room := rtcCtx.room(req.RoomID)
+	if room == nil || !room.joined || rtcCtx.userID != req.UserID {
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	// the context of the request that created the anchor may be gone already
+	ctx, cancel := context.WithTimeout(context.Background(), publishChangeTimeout)
+	defer cancel()
+	// not in the Janus room yet, a granted anchor offers next
+	if room.janus != nil && room.group != "" && room.publishRevoked == req.Granted {
+		room.publishRevoked = !req.Granted
+		// anchors of a frozen room stay muted
+		if err := room.janus.SetMuted(ctx, room.publishRevoked || room.frozen); err != nil {
+			s.logger.Error("Failed to mute anchor for publish slot",
+				log.String("roomId", req.RoomID),
+				log.String("userId", req.UserID),
+				log.Bool("granted", req.Granted),
+				log.Error(err))
+		}
+	}
+	if err := mctx.Peer().Notify(ctx, publishChangedNotification, &publishChanged{
+		RoomID:  req.RoomID,
+		Granted: req.Granted,
+	}); err != nil {
+		s.logger.Debug("Failed to notify publish changed", log.Error(err))
+	}
+	//nolint:nilnil
+	return nil, nil
+}
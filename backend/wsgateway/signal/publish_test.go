@@ -0,0 +1,223 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	janusapimocks "github.com/imtaco/audio-rtc-exp/internal/janus/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/users"
+)
+
+func (s *ServerSuite) TestHandleRequestPublish() {
+	anchor := func() *mockMethodCtx {
+		return &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			reqCtx: context.Background(),
+			roomID: "room1",
+			userID: "user1",
+		}, &roomContext{role: constants.UserRoleAnchor, joined: true})}
+	}
+
+	s.Run("raises the hand", func() {
+		s.janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{PublishSlots: 2})
+		s.userService.EXPECT().SetUserHand(gomock.Any(), "room1", "user1", true).Return(nil)
+
+		_, err := s.server.handleRequestPublish(anchor(), nil)
+		s.Require().NoError(err)
+	})
+
+	s.Run("not push-to-talk", func() {
+		s.janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{})
+
+		_, err := s.server.handleRequestPublish(anchor(), nil)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "not push-to-talk")
+	})
+}
+
+func (s *ServerSuite) TestHandleGrantPublish() {
+	hostCtx := func() *mockMethodCtx {
+		return &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			reqCtx: context.Background(),
+			roomID: "room1",
+			userID: "host1",
+		}, &roomContext{role: constants.UserRoleHost, joined: true})}
+	}
+
+	s.Run("not host", func() {
+		mctx := hostCtx()
+		mctx.rtcCtx.tokenRoom().role = constants.UserRoleAnchor
+
+		_, err := s.server.handleGrantPublish(mctx, nil)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "only hosts")
+	})
+
+	s.Run("named user", func() {
+		rawParams := json.RawMessage(`{"userId":"user2"}`)
+		s.janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{PublishSlots: 2})
+		s.userService.EXPECT().GrantPublish(gomock.Any(), "room1", "user2", 2).Return("user2", nil)
+
+		res, err := s.server.handleGrantPublish(hostCtx(), &rawParams)
+		s.Require().NoError(err)
+		s.Equal(map[string]any{"userId": "user2"}, res)
+	})
+
+	s.Run("all slots taken", func() {
+		s.janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{PublishSlots: 1})
+		s.userService.EXPECT().GrantPublish(gomock.Any(), "room1", "", 1).
+			Return("", jsonrpc.ErrInvalidRequest("no publish slot left"))
+
+		_, err := s.server.handleGrantPublish(hostCtx(), nil)
+		rpcErr, ok := errors.As[*jsonrpc.Error](err)
+		s.Require().True(ok)
+		s.Equal("no publish slot left", rpcErr.Message)
+	})
+
+	s.Run("not push-to-talk", func() {
+		s.janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{})
+
+		_, err := s.server.handleGrantPublish(hostCtx(), nil)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "not push-to-talk")
+	})
+}
+
+func (s *ServerSuite) TestHandleRevokePublish() {
+	ctxOf := func(userID string, role constants.UserRole) *mockMethodCtx {
+		return &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			reqCtx: context.Background(),
+			roomID: "room1",
+			userID: userID,
+		}, &roomContext{role: role, joined: true})}
+	}
+	rawParams := json.RawMessage(`{"userId":"user2"}`)
+
+	s.Run("own slot", func() {
+		s.userService.EXPECT().RevokePublish(gomock.Any(), "room1", "user1").Return(nil)
+
+		_, err := s.server.handleRevokePublish(ctxOf("user1", constants.UserRoleAnchor), nil)
+		s.Require().NoError(err)
+	})
+
+	s.Run("others by anchor", func() {
+		_, err := s.server.handleRevokePublish(ctxOf("user1", constants.UserRoleAnchor), &rawParams)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "only hosts")
+	})
+
+	s.Run("others by host", func() {
+		s.userService.EXPECT().RevokePublish(gomock.Any(), "room1", "user2").Return(nil)
+
+		_, err := s.server.handleRevokePublish(ctxOf("host1", constants.UserRoleHost), &rawParams)
+		s.Require().NoError(err)
+	})
+
+	s.Run("users service failure", func() {
+		s.userService.EXPECT().RevokePublish(gomock.Any(), "room1", "user1").Return(fmt.Errorf("timeout"))
+
+		_, err := s.server.handleRevokePublish(ctxOf("user1", constants.UserRoleAnchor), nil)
+		s.Require().Error(err)
+	})
+}
+
+func (s *ServerSuite) TestHandleOffer_PushToTalk() {
+	ctx := context.Background()
+	params, _ := json.Marshal(map[string]any{
+		"sdp": janus.JSEP{Type: "offer", SDP: testOfferSDP},
+	})
+	rawParams := json.RawMessage(params)
+	offer := func(role constants.UserRole, anchor janus.Anchor) *mockMethodCtx {
+		return &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			reqCtx: ctx,
+			roomID: "room1",
+			userID: "user1",
+		}, &roomContext{role: role, joined: true, janus: anchor})}
+	}
+	ptt := &etcdstate.Meta{Pin: "123", PublishSlots: 1}
+
+	s.Run("refused without a slot", func() {
+		s.janusProxy.EXPECT().GetJanusRoomID("room1").Return(int64(1234))
+		s.janusProxy.EXPECT().GetRoomMeta("room1").Return(ptt)
+		s.userService.EXPECT().GetActiveRoomUsers(gomock.Any(), "room1").Return([]*users.RoomUser{
+			{UserID: "user1", Role: "anchor"},
+			{UserID: "user2", Role: "anchor", Publish: true},
+		}, nil)
+
+		_, err := s.server.handleOffer(offer(constants.UserRoleAnchor, janusapimocks.NewMockAnchor(s.ctrl)), &rawParams)
+		var rpcErr *jsonrpc.Error
+		s.Require().ErrorAs(err, &rpcErr)
+		s.Equal(int64(codePublishNotGranted), rpcErr.Code)
+	})
+
+	s.Run("taken with a slot", func() {
+		anchor := janusapimocks.NewMockAnchor(s.ctrl)
+		answer, _ := json.Marshal(janus.JSEP{Type: "answer", SDP: testAnswerSDP})
+		s.janusProxy.EXPECT().GetJanusRoomID("room1").Return(int64(1234))
+		s.janusProxy.EXPECT().GetRoomMeta("room1").Return(ptt)
+		s.userService.EXPECT().GetActiveRoomUsers(gomock.Any(), "room1").Return([]*users.RoomUser{
+			{UserID: "user1", Role: "anchor", Publish: true},
+		}, nil)
+		s.janusProxy.EXPECT().GetLinkGroup("room1", "user1").Return(janus.GroupRoom)
+		anchor.EXPECT().Join(ctx, int64(1234), "123", "user-user1", 0, janus.GroupRoom, gomock.Any()).Return(&janus.Response{Janus: "ack"}, nil)
+		anchor.EXPECT().GetEvents(ctx, 10).Return([]*janus.Response{{Janus: "event", JSEP: (*json.RawMessage)(&answer)}}, nil)
+
+		_, err := s.server.handleOffer(offer(constants.UserRoleAnchor, anchor), &rawParams)
+		s.Require().NoError(err)
+	})
+
+	s.Run("hosts always publish", func() {
+		anchor := janusapimocks.NewMockAnchor(s.ctrl)
+		answer, _ := json.Marshal(janus.JSEP{Type: "answer", SDP: testAnswerSDP})
+		s.janusProxy.EXPECT().GetJanusRoomID("room1").Return(int64(1234))
+		s.janusProxy.EXPECT().GetRoomMeta("room1").Return(ptt)
+		s.janusProxy.EXPECT().GetLinkGroup("room1", "user1").Return(janus.GroupRoom)
+		anchor.EXPECT().Join(ctx, int64(1234), "123", "user-user1", 0, janus.GroupRoom, gomock.Any()).Return(&janus.Response{Janus: "ack"}, nil)
+		anchor.EXPECT().GetEvents(ctx, 10).Return([]*janus.Response{{Janus: "event", JSEP: (*json.RawMessage)(&answer)}}, nil)
+
+		_, err := s.server.handleOffer(offer(constants.UserRoleHost, anchor), &rawParams)
+		s.Require().NoError(err)
+	})
+}
+
+func (s *ServerSuite) TestHandlePublishChange() {
+	anchor := janusapimocks.NewMockAnchor(s.ctrl)
+	var notified []*publishChanged
+	mctx := &mockMethodCtx{
+		rtcCtx: inRoom(&rtcContext{roomID: "room1", userID: "user1"},
+			&roomContext{joined: true, janus: anchor, group: "main"}),
+		peer: &mockPeer{notifyFunc: func(_ context.Context, method string, p any) error {
+			s.Equal(publishChangedNotification, method)
+			notified = append(notified, p.(*publishChanged))
+			return nil
+		}},
+	}
+	revoked := json.RawMessage(`{"roomId":"room1","userId":"user1","granted":false}`)
+	granted := json.RawMessage(`{"roomId":"room1","userId":"user1","granted":true}`)
+	other := json.RawMessage(`{"roomId":"room1","userId":"user2","granted":false}`)
+
+	// other users are left alone
+	_, err := s.server.handlePublishChange(mctx, &other)
+	s.Require().NoError(err)
+
+	anchor.EXPECT().SetMuted(gomock.Any(), true).Return(nil)
+	_, err = s.server.handlePublishChange(mctx, &revoked)
+	s.Require().NoError(err)
+	s.True(mctx.rtcCtx.tokenRoom().publishRevoked)
+
+	// frozen rooms keep the anchor muted
+	mctx.rtcCtx.tokenRoom().frozen = true
+	anchor.EXPECT().SetMuted(gomock.Any(), true).Return(nil)
+	_, err = s.server.handlePublishChange(mctx, &granted)
+	s.Require().NoError(err)
+	s.False(mctx.rtcCtx.tokenRoom().publishRevoked)
+
+	s.Equal([]*publishChanged{{RoomID: "room1", Granted: false}, {RoomID: "room1", Granted: true}}, notified)
+}
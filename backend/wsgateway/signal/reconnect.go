@@ -0,0 +1,133 @@
+package signal
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// closingMethod is the notification sent right before the gateway closes a connection
+const closingMethod = "closing"
+
+// CloseReason tells clients why the gateway closed their connection
+type CloseReason string
+
+const (
+	// CloseReasonDrain the gateway is shutting down, reconnect following the hint
+	CloseReasonDrain CloseReason = "drain"
+	// CloseReasonDuplicate the user is connected through another connection, do not reconnect
+	CloseReasonDuplicate CloseReason = "duplicate"
+	// CloseReasonReplaced a newer connection of the user took over, do not reconnect
+	CloseReasonReplaced CloseReason = "replaced"
+	// CloseReasonDeviceLimit the user is connected through as many devices as allowed, do not reconnect
+	CloseReasonDeviceLimit CloseReason = "device_limit"
+)
+
+// ReconnectConfig shapes the reconnect hints sent to clients when the gateway closes their connection
+type ReconnectConfig struct {
+	// BaseBackoff is the first delay of the backoff schedule, doubled on each attempt
+	BaseBackoff time.Duration `mapstructure:"base_backoff"`
+	MaxBackoff  time.Duration `mapstructure:"max_backoff"`
+	Attempts    int           `mapstructure:"attempts"`
+	// DrainSpread spreads the first reconnect of drained clients to avoid a thundering herd
+	DrainSpread time.Duration `mapstructure:"drain_spread"`
+}
+
+func SetupReconnect(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("base_backoff"), "1s")
+	v.SetDefault(p("max_backoff"), "30s")
+	v.SetDefault(p("attempts"), 6)
+	v.SetDefault(p("drain_spread"), "5s")
+}
+
+// closeHint is the params of the closing notification
+type closeHint struct {
+	Reason    CloseReason `json:"reason"`
+	Reconnect bool        `json:"reconnect"`
+	// RetryAfterMs is the delay before the first reconnect attempt
+	RetryAfterMs int64 `json:"retryAfterMs,omitempty"`
+	// BackoffMs are the delays of the following attempts when reconnecting fails
+	BackoffMs []int64 `json:"backoffMs,omitempty"`
+	// Gateway is the URL of another live gateway, clients fall back to their default URL
+	Gateway string `json:"gateway,omitempty"`
+	// Policy is the duplicate login policy which closed the connection
+	Policy DuplicatePolicy `json:"policy,omitempty"`
+	// MaxDevices is the connections allowed per user under the allow_n policy
+	MaxDevices int `json:"maxDevices,omitempty"`
+}
+
+type reconnectAdvisor struct {
+	cfg       *ReconnectConfig
+	connGuard ConnectionGuard
+	rand      func() float64
+	logger    *log.Logger
+}
+
+func newReconnectAdvisor(cfg *ReconnectConfig, connGuard ConnectionGuard, logger *log.Logger) *reconnectAdvisor {
+	if cfg == nil {
+		cfg = &ReconnectConfig{}
+	}
+	return &reconnectAdvisor{
+		cfg:       cfg,
+		connGuard: connGuard,
+		rand:      rand.Float64,
+		logger:    logger,
+	}
+}
+
+// schedule returns the backoff delays in milliseconds, doubling from BaseBackoff up to MaxBackoff
+func (a *reconnectAdvisor) schedule() []int64 {
+	if a.cfg.Attempts <= 0 || a.cfg.BaseBackoff <= 0 {
+		return nil
+	}
+	delays := make([]int64, 0, a.cfg.Attempts)
+	delay := a.cfg.BaseBackoff
+	for range a.cfg.Attempts {
+		if a.cfg.MaxBackoff > 0 {
+			delay = min(delay, a.cfg.MaxBackoff)
+		}
+		delays = append(delays, delay.Milliseconds())
+		delay *= 2
+	}
+	return delays
+}
+
+// drainHint is sent to every connection when the gateway shuts down, gateway is shared by
+// all of them and is looked up once
+func (a *reconnectAdvisor) drainHint(gateway string) *closeHint {
+	return &closeHint{
+		Reason:       CloseReasonDrain,
+		Reconnect:    true,
+		RetryAfterMs: int64(a.rand() * float64(a.cfg.DrainSpread.Milliseconds())),
+		BackoffMs:    a.schedule(),
+		Gateway:      gateway,
+	}
+}
+
+// peerGateway returns another live gateway, empty on error since the hint is best effort
+func (a *reconnectAdvisor) peerGateway(ctx context.Context) string {
+	gateway, err := a.connGuard.PeerGateway(ctx)
+	if err != nil {
+		a.logger.Error("Failed to look up peer gateway", log.Error(err))
+		return ""
+	}
+	return gateway
+}
+
+// closeWithHint notifies the client of why its connection is closed then closes it,
+// the notification is flushed before the close frame
+func closeWithHint(ctx context.Context, conn jsonrpc.Conn[rtcContext], hint *closeHint, logger *log.Logger) {
+	if err := conn.Notify(ctx, closingMethod, hint); err != nil {
+		logger.Debug("Failed to send close hint", log.Error(err))
+	}
+	if err := conn.Close(); err != nil {
+		logger.Debug("Failed to close connection", log.Error(err))
+	}
+}
@@ -0,0 +1,35 @@
+package signal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+func TestReconnectSchedule(t *testing.T) {
+	t.Run("doubles up to max", func(t *testing.T) {
+		a := newReconnectAdvisor(&ReconnectConfig{
+			BaseBackoff: 500 * time.Millisecond,
+			MaxBackoff:  3 * time.Second,
+			Attempts:    5,
+		}, nil, log.NewNop())
+
+		assert.Equal(t, []int64{500, 1000, 2000, 3000, 3000}, a.schedule())
+	})
+
+	t.Run("no max", func(t *testing.T) {
+		a := newReconnectAdvisor(&ReconnectConfig{BaseBackoff: time.Second, Attempts: 3}, nil, log.NewNop())
+
+		assert.Equal(t, []int64{1000, 2000, 4000}, a.schedule())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		a := newReconnectAdvisor(nil, nil, log.NewNop())
+
+		assert.Nil(t, a.schedule())
+		assert.Zero(t, a.drainHint("").RetryAfterMs)
+	})
+}
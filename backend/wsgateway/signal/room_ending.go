@@ -0,0 +1,173 @@
+package signal
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/spf13/viper"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const (
+	// roomEndingMethod is dispatched to the connections of a room when its state changes,
+	// connections tell their peer once the room is ending, clients cannot call it
+	roomEndingMethod = "room.ending"
+	// roomEndingNotification tells the connections of a room it is being ended
+	roomEndingNotification = "room_ending"
+	roomEndingTimeout      = 5 * time.Second
+)
+
+// RoomsAPIConfig locates the rooms API rooms are ended through
+type RoomsAPIConfig struct {
+	// URL of the rooms API, empty disables the endRoom, freezeRoom and unfreezeRoom methods
+	URL string `mapstructure:"url"`
+	// Token is sent as bearer token, it needs the delete scope
+	Token   string        `mapstructure:"token"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+func SetupRoomsAPI(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("url"), "")
+	v.SetDefault(p("token"), "")
+	v.SetDefault(p("timeout"), "5s")
+}
+
+// RoomModerator runs the room actions of hosts
+type RoomModerator interface {
+	// EndRoom ends the room, returning the end stage the room is at
+	EndRoom(ctx context.Context, roomID string) (constants.EndStage, error)
+	// FreezeRoom freezes or unfreezes the room
+	FreezeRoom(ctx context.Context, roomID string, frozen bool) error
+}
+
+// NewRoomsAPIClient returns the client moderating rooms through the rooms API, nil when no URL is set.
+// tlsConfig is the mTLS config of calls to rooms, nil without mTLS
+func NewRoomsAPIClient(cfg *RoomsAPIConfig, tlsConfig *tls.Config, logger *log.Logger) RoomModerator {
+	if cfg.URL == "" {
+		return nil
+	}
+	client := resty.New().
+		SetBaseURL(strings.TrimSuffix(cfg.URL, "/")).
+		SetTimeout(cfg.Timeout)
+	if cfg.Token != "" {
+		client.SetAuthToken(cfg.Token)
+	}
+	if tlsConfig != nil {
+		client.SetTLSClientConfig(tlsConfig)
+	}
+	logger.Info("Room moderation enabled", log.String("url", cfg.URL))
+	return &roomsAPIClient{client: client}
+}
+
+type roomsAPIClient struct {
+	client *resty.Client
+}
+
+func (c *roomsAPIClient) EndRoom(ctx context.Context, roomID string) (constants.EndStage, error) {
+	var result struct {
+		Room struct {
+			EndStage constants.EndStage `json:"endStage"`
+		} `json:"room"`
+	}
+	resp, err := c.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		ForceContentType("application/json").
+		Post("/api/rooms/" + url.PathEscape(roomID) + "/end")
+	if err != nil {
+		return "", fmt.Errorf("failed to end room: %w", err)
+	}
+	if resp.IsError() {
+		return "", fmt.Errorf("failed to end room: %s", resp.Status())
+	}
+	return result.Room.EndStage, nil
+}
+
+func (c *roomsAPIClient) FreezeRoom(ctx context.Context, roomID string, frozen bool) error {
+	action := "/unfreeze"
+	if frozen {
+		action = "/freeze"
+	}
+	resp, err := c.client.R().
+		SetContext(ctx).
+		Post("/api/rooms/" + url.PathEscape(roomID) + action)
+	if err != nil {
+		return fmt.Errorf("failed to freeze room: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("failed to freeze room: %s", resp.Status())
+	}
+	return nil
+}
+
+// roomEnding is the params of the room_ending notification
+type roomEnding struct {
+	RoomID  string             `json:"roomId"`
+	Stage   constants.EndStage `json:"stage"`
+	Code    string             `json:"code"`
+	Message string             `json:"message,omitempty"`
+}
+
+// handleEndRoom ends the room the call targets, its connections are told through room_ending
+func (s *Server) handleEndRoom(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+	roomID := rtcCtx.targetRoomID(params)
+	if rtcCtx.roleIn(roomID) != constants.UserRoleHost {
+		return nil, jsonrpc.ErrInvalidRequest("only hosts can end the room")
+	}
+	if s.roomModerator == nil {
+		return nil, jsonrpc.ErrInvalidRequest("ending rooms is not enabled")
+	}
+
+	stage, err := s.roomModerator.EndRoom(rtcCtx.reqCtx, roomID)
+	if err != nil {
+		s.logger.Error("Failed to end room",
+			log.String("roomId", roomID),
+			log.String("userId", rtcCtx.userID),
+			log.Error(err))
+		return nil, jsonrpc.ErrInternal("fail to end room")
+	}
+	s.logger.Info("Room ended by host",
+		log.String("roomId", roomID),
+		log.String("userId", rtcCtx.userID),
+		log.String("stage", string(stage)))
+	return map[string]any{"stage": stage}, nil
+}
+
+// handleRoomEnding tells the peer once that its room is ending
+func (s *Server) handleRoomEnding(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+	roomID := rtcCtx.targetRoomID(params)
+	room := rtcCtx.room(roomID)
+	stage := s.janusProxy.GetRoomMeta(roomID).GetEndStage()
+	if room == nil || room.endingNotified || stage == "" {
+		//nolint:nilnil
+		return nil, nil
+	}
+	room.endingNotified = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), roomEndingTimeout)
+	defer cancel()
+	if err := mctx.Peer().Notify(ctx, roomEndingNotification, &roomEnding{
+		RoomID: room.roomID,
+		Stage:  stage,
+		Code:   codeRoomEnding,
+		Message: localize(s.catalog, s.janusProxy, rtcCtx, room.roomID, codeRoomEnding,
+			map[string]string{"stage": string(stage)}),
+	}); err != nil {
+		s.logger.Debug("Failed to notify room ending", log.Error(err))
+	}
+	//nolint:nilnil
+	return nil, nil
+}
@@ -0,0 +1,184 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+type fakeRoomModerator struct {
+	roomID string
+	stage  constants.EndStage
+	frozen *bool
+	err    error
+}
+
+func (f *fakeRoomModerator) EndRoom(_ context.Context, roomID string) (constants.EndStage, error) {
+	f.roomID = roomID
+	return f.stage, f.err
+}
+
+func (f *fakeRoomModerator) FreezeRoom(_ context.Context, roomID string, frozen bool) error {
+	f.roomID = roomID
+	f.frozen = &frozen
+	return f.err
+}
+
+func (s *ServerSuite) TestHandleEndRoom() {
+	host := func() *mockMethodCtx {
+		return &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			reqCtx: context.Background(),
+			roomID: "room1",
+			userID: "user1",
+		}, &roomContext{role: constants.UserRoleHost})}
+	}
+
+	s.Run("ends the room", func() {
+		ender := &fakeRoomModerator{stage: constants.EndStageNotifying}
+		s.server.roomModerator = ender
+
+		result, err := s.server.handleEndRoom(host(), nil)
+		s.Require().NoError(err)
+		s.Equal(map[string]any{"stage": constants.EndStageNotifying}, result)
+		s.Equal("room1", ender.roomID)
+	})
+
+	s.Run("hosts only", func() {
+		s.server.roomModerator = &fakeRoomModerator{}
+		mctx := host()
+		mctx.rtcCtx.tokenRoom().role = constants.UserRoleAnchor
+
+		_, err := s.server.handleEndRoom(mctx, nil)
+		var rpcErr *jsonrpc.Error
+		s.Require().ErrorAs(err, &rpcErr)
+		s.Equal(int64(jsonrpc.CodeInvalidRequest), rpcErr.Code)
+	})
+
+	s.Run("disabled", func() {
+		s.server.roomModerator = nil
+
+		_, err := s.server.handleEndRoom(host(), nil)
+		s.Error(err)
+	})
+
+	s.Run("rooms API error", func() {
+		s.server.roomModerator = &fakeRoomModerator{err: errors.New("unavailable")}
+
+		_, err := s.server.handleEndRoom(host(), nil)
+		var rpcErr *jsonrpc.Error
+		s.Require().ErrorAs(err, &rpcErr)
+		s.Equal(int64(jsonrpc.CodeInternalError), rpcErr.Code)
+	})
+}
+
+func (s *ServerSuite) TestHandleRoomEnding() {
+	notified := 0
+	var params *roomEnding
+	mctx := &mockMethodCtx{
+		rtcCtx: inRoom(&rtcContext{roomID: "room1", userID: "user1"}, &roomContext{}),
+		peer: &mockPeer{notifyFunc: func(_ context.Context, method string, p any) error {
+			s.Equal(roomEndingNotification, method)
+			notified++
+			params = p.(*roomEnding)
+			return nil
+		}},
+	}
+
+	s.janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{}).Times(1)
+	_, err := s.server.handleRoomEnding(mctx, nil)
+	s.Require().NoError(err)
+	s.Zero(notified)
+
+	// told once whatever the stages that follow
+	ending := &etcdstate.Meta{Ending: &etcdstate.Ending{Stage: constants.EndStageNotifying}}
+	s.janusProxy.EXPECT().GetRoomMeta("room1").Return(ending).Times(2)
+	_, err = s.server.handleRoomEnding(mctx, nil)
+	s.Require().NoError(err)
+	_, err = s.server.handleRoomEnding(mctx, nil)
+	s.Require().NoError(err)
+
+	s.Equal(1, notified)
+	s.Equal(&roomEnding{RoomID: "room1", Stage: constants.EndStageNotifying, Code: codeRoomEnding}, params)
+
+	// localized with a catalog
+	s.server.catalog = newTestCatalog(s.T())
+	mctx.rtcCtx = inRoom(&rtcContext{roomID: "room1", userID: "user1", locale: "en"}, &roomContext{})
+	s.janusProxy.EXPECT().GetRoomMeta("room1").Return(ending)
+	_, err = s.server.handleRoomEnding(mctx, nil)
+	s.Require().NoError(err)
+	s.Equal("The room is ending (notifying)", params.Message)
+}
+
+func (s *ServerSuite) TestHandleJoin_RoomEnding() {
+	mctx := &mockMethodCtx{rtcCtx: inRoom(&rtcContext{reqCtx: context.Background(), roomID: "room1", userID: "user1"}, &roomContext{})}
+	params := json.RawMessage(`{"clientId":"6f1c2a8e-3b4d-4e5f-8a9b-0c1d2e3f4a5b"}`)
+
+	s.janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{
+		Ending: &etcdstate.Ending{Stage: constants.EndStageStoppingUsers},
+	})
+
+	_, err := s.server.handleJoin(mctx, &params)
+	var rpcErr *jsonrpc.Error
+	s.Require().ErrorAs(err, &rpcErr)
+	s.Equal("room is ending", rpcErr.Message)
+}
+
+func TestRoomsAPIClient(t *testing.T) {
+	t.Run("ends room", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/api/rooms/room1/end", r.URL.Path)
+			assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"success":true,"room":{"roomId":"room1","endStage":"notifying"}}`))
+		}))
+		defer srv.Close()
+
+		client := NewRoomsAPIClient(&RoomsAPIConfig{URL: srv.URL + "/", Token: "secret", Timeout: time.Second}, nil, log.NewNop())
+		stage, err := client.EndRoom(context.Background(), "room1")
+		require.NoError(t, err)
+		assert.Equal(t, constants.EndStageNotifying, stage)
+	})
+
+	t.Run("freezes and unfreezes room", func(t *testing.T) {
+		var paths []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			paths = append(paths, r.URL.Path)
+			_, _ = w.Write([]byte(`{"success":true,"room":{"roomId":"room1"}}`))
+		}))
+		defer srv.Close()
+
+		client := NewRoomsAPIClient(&RoomsAPIConfig{URL: srv.URL, Timeout: time.Second}, nil, log.NewNop())
+		require.NoError(t, client.FreezeRoom(context.Background(), "room1", true))
+		require.NoError(t, client.FreezeRoom(context.Background(), "room1", false))
+		assert.Equal(t, []string{"/api/rooms/room1/freeze", "/api/rooms/room1/unfreeze"}, paths)
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer srv.Close()
+
+		client := NewRoomsAPIClient(&RoomsAPIConfig{URL: srv.URL, Timeout: time.Second}, nil, log.NewNop())
+		_, err := client.EndRoom(context.Background(), "room1")
+		assert.Error(t, err)
+		assert.Error(t, client.FreezeRoom(context.Background(), "room1", true))
+	})
+
+	t.Run("disabled without URL", func(t *testing.T) {
+		assert.Nil(t, NewRoomsAPIClient(&RoomsAPIConfig{}, nil, log.NewNop()))
+	})
+}
@@ -0,0 +1,96 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+const (
+	// roomFreezeMethod is dispatched to the connections of a room when its state changes,
+	// connections mute or unmute their anchor once the room is frozen or unfrozen, clients
+	// cannot call it
+	roomFreezeMethod = "room.freeze"
+	// roomFrozenNotification tells the connections of a room it was frozen or unfrozen
+	roomFrozenNotification = "room_frozen"
+	roomFreezeTimeout      = 5 * time.Second
+)
+
+// roomFrozen is the params of the room_frozen notification
+type roomFrozen struct {
+	RoomID string `json:"roomId"`
+	Frozen bool   `json:"frozen"`
+}
+
+// handleFreezeRoom returns the handler freezing or unfreezing the room the call targets, its
+// connections follow through room_frozen
+func (s *Server) handleFreezeRoom(frozen bool) jsonrpc.MethodHandler[rtcContext] {
+	return func(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+		rtcCtx := mctx.Get()
+		roomID := rtcCtx.targetRoomID(params)
+		if rtcCtx.roleIn(roomID) != constants.UserRoleHost {
+			return nil, jsonrpc.ErrInvalidRequest("only hosts can freeze the room")
+		}
+		if s.roomModerator == nil {
+			return nil, jsonrpc.ErrInvalidRequest("freezing rooms is not enabled")
+		}
+
+		if err := s.roomModerator.FreezeRoom(rtcCtx.reqCtx, roomID, frozen); err != nil {
+			s.logger.Error("Failed to freeze room",
+				log.String("roomId", roomID),
+				log.String("userId", rtcCtx.userID),
+				log.Bool("frozen", frozen),
+				log.Error(err))
+			return nil, jsonrpc.ErrInternal("fail to freeze room")
+		}
+		s.logger.Info("Room freeze changed by host",
+			log.String("roomId", roomID),
+			log.String("userId", rtcCtx.userID),
+			log.Bool("frozen", frozen))
+		return map[string]any{"frozen": frozen}, nil
+	}
+}
+
+// handleRoomFreeze mutes the anchor of the connection once the room is frozen, and unmutes it
+// once unfrozen, telling the peer either way
+func (s *Server) handleRoomFreeze(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+	room := rtcCtx.room(rtcCtx.targetRoomID(params))
+	if room == nil || !room.joined {
+		//nolint:nilnil
+		return nil, nil
+	}
+	frozen := s.janusProxy.GetRoomMeta(room.roomID).IsFrozen()
+	if frozen == room.frozen {
+		//nolint:nilnil
+		return nil, nil
+	}
+	room.frozen = frozen
+
+	// the context of the request that created the anchor may be gone already
+	ctx, cancel := context.WithTimeout(context.Background(), roomFreezeTimeout)
+	defer cancel()
+	// not in the Janus room yet, the anchor is muted on its offer
+	if room.janus != nil && room.group != "" {
+		// anchors whose publish slot was revoked stay muted
+		if err := room.janus.SetMuted(ctx, frozen || room.publishRevoked); err != nil {
+			s.logger.Error("Failed to mute anchor of frozen room",
+				log.String("roomId", room.roomID),
+				log.String("userId", rtcCtx.userID),
+				log.Bool("frozen", frozen),
+				log.Error(err))
+		}
+	}
+	if err := mctx.Peer().Notify(ctx, roomFrozenNotification, &roomFrozen{
+		RoomID: room.roomID,
+		Frozen: frozen,
+	}); err != nil {
+		s.logger.Debug("Failed to notify room frozen", log.Error(err))
+	}
+	//nolint:nilnil
+	return nil, nil
+}
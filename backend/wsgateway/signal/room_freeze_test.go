@@ -0,0 +1,172 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	janusapimocks "github.com/imtaco/audio-rtc-exp/internal/janus/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+)
+
+func (s *ServerSuite) TestHandleFreezeRoom() {
+	host := func() *mockMethodCtx {
+		return &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			reqCtx: context.Background(),
+			roomID: "room1",
+			userID: "user1",
+		}, &roomContext{role: constants.UserRoleHost})}
+	}
+
+	s.Run("freezes and unfreezes the room", func() {
+		moderator := &fakeRoomModerator{}
+		s.server.roomModerator = moderator
+
+		result, err := s.server.handleFreezeRoom(true)(host(), nil)
+		s.Require().NoError(err)
+		s.Equal(map[string]any{"frozen": true}, result)
+		s.Equal("room1", moderator.roomID)
+		s.Require().NotNil(moderator.frozen)
+		s.True(*moderator.frozen)
+
+		_, err = s.server.handleFreezeRoom(false)(host(), nil)
+		s.Require().NoError(err)
+		s.False(*moderator.frozen)
+	})
+
+	s.Run("hosts only", func() {
+		s.server.roomModerator = &fakeRoomModerator{}
+		mctx := host()
+		mctx.rtcCtx.tokenRoom().role = constants.UserRoleAnchor
+
+		_, err := s.server.handleFreezeRoom(true)(mctx, nil)
+		var rpcErr *jsonrpc.Error
+		s.Require().ErrorAs(err, &rpcErr)
+		s.Equal(int64(jsonrpc.CodeInvalidRequest), rpcErr.Code)
+	})
+
+	s.Run("disabled", func() {
+		s.server.roomModerator = nil
+
+		_, err := s.server.handleFreezeRoom(true)(host(), nil)
+		s.Error(err)
+	})
+
+	s.Run("rooms API error", func() {
+		s.server.roomModerator = &fakeRoomModerator{err: errors.New("unavailable")}
+
+		_, err := s.server.handleFreezeRoom(true)(host(), nil)
+		var rpcErr *jsonrpc.Error
+		s.Require().ErrorAs(err, &rpcErr)
+		s.Equal(int64(jsonrpc.CodeInternalError), rpcErr.Code)
+	})
+}
+
+func (s *ServerSuite) TestHandleRoomFreeze() {
+	anchor := janusapimocks.NewMockAnchor(s.ctrl)
+	var notified []*roomFrozen
+	mctx := &mockMethodCtx{
+		rtcCtx: inRoom(&rtcContext{roomID: "room1", userID: "user1"},
+			&roomContext{joined: true, janus: anchor, group: "main"}),
+		peer: &mockPeer{notifyFunc: func(_ context.Context, method string, p any) error {
+			s.Equal(roomFrozenNotification, method)
+			notified = append(notified, p.(*roomFrozen))
+			return nil
+		}},
+	}
+	frozenAt := time.Now()
+	frozen := &etcdstate.Meta{FrozenAt: &frozenAt}
+
+	// muted once whatever the changes that follow
+	s.janusProxy.EXPECT().GetRoomMeta("room1").Return(frozen).Times(2)
+	anchor.EXPECT().SetMuted(gomock.Any(), true).Return(nil)
+	_, err := s.server.handleRoomFreeze(mctx, nil)
+	s.Require().NoError(err)
+	_, err = s.server.handleRoomFreeze(mctx, nil)
+	s.Require().NoError(err)
+	s.True(mctx.rtcCtx.tokenRoom().frozen)
+
+	s.janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{})
+	anchor.EXPECT().SetMuted(gomock.Any(), false).Return(nil)
+	_, err = s.server.handleRoomFreeze(mctx, nil)
+	s.Require().NoError(err)
+
+	s.Equal([]*roomFrozen{{RoomID: "room1", Frozen: true}, {RoomID: "room1", Frozen: false}}, notified)
+}
+
+func (s *ServerSuite) TestHandleJoin_RoomFrozen() {
+	mctx := &mockMethodCtx{rtcCtx: inRoom(&rtcContext{reqCtx: context.Background(), roomID: "room1", userID: "user1"}, &roomContext{})}
+	params := json.RawMessage(`{"clientId":"6f1c2a8e-3b4d-4e5f-8a9b-0c1d2e3f4a5b"}`)
+
+	frozenAt := time.Now()
+	s.janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{FrozenAt: &frozenAt})
+
+	_, err := s.server.handleJoin(mctx, &params)
+	var rpcErr *jsonrpc.Error
+	s.Require().ErrorAs(err, &rpcErr)
+	s.Equal("room is frozen", rpcErr.Message)
+}
+
+func (s *ServerSuite) TestHandleJoin_RoomFrozenWithJanusToken() {
+	frozenAt := time.Now()
+	join := func(jtoken string) (any, error) {
+		mctx := &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			reqCtx: context.Background(),
+			connID: "conn1",
+			roomID: "room1",
+			userID: "user1",
+		}, &roomContext{})}
+		params := json.RawMessage(`{"clientId":"6f1c2a8e-3b4d-4e5f-8a9b-0c1d2e3f4a5b","jtoken":"` + jtoken + `"}`)
+
+		s.janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{FrozenAt: &frozenAt})
+		s.janusProxy.EXPECT().GetRoomLiveMeta("room1").Return(&etcdstate.LiveMeta{
+			Status: constants.RoomStatusOnAir,
+			Nonce:  "nonce",
+		})
+		s.janusProxy.EXPECT().GetJanusAPI("room1").Return(s.janusAPI)
+		return s.server.handleJoin(mctx, &params)
+	}
+	requireFrozen := func(err error) {
+		var rpcErr *jsonrpc.Error
+		s.Require().ErrorAs(err, &rpcErr)
+		s.Equal("room is frozen", rpcErr.Message)
+	}
+
+	s.Run("junk token", func() {
+		s.janusTokenCodec.EXPECT().Decode("nonce", "x").Return(int64(0), int64(0), errors.New("invalid token"))
+
+		_, err := join("x")
+		requireFrozen(err)
+	})
+
+	s.Run("expired session", func() {
+		expired := janusapimocks.NewMockAnchor(s.ctrl)
+		s.janusTokenCodec.EXPECT().Decode("nonce", "expired").Return(int64(123), int64(456), nil)
+		s.janusAPI.EXPECT().CreateAnchorInstance(gomock.Any(), "conn1", int64(123), int64(456)).Return(expired, nil)
+		expired.EXPECT().Check(gomock.Any()).Return(false, janus.ErrNoneSuccessResponse)
+
+		_, err := join("expired")
+		requireFrozen(err)
+	})
+
+	s.Run("resumed session", func() {
+		anchor := janusapimocks.NewMockAnchor(s.ctrl)
+		anchor.EXPECT().GetSessionID().Return(int64(123)).AnyTimes()
+		anchor.EXPECT().GetHandleID().Return(int64(456)).AnyTimes()
+		s.janusTokenCodec.EXPECT().Decode("nonce", "active").Return(int64(123), int64(456), nil)
+		s.janusAPI.EXPECT().CreateAnchorInstance(gomock.Any(), "conn1", int64(123), int64(456)).Return(anchor, nil)
+		anchor.EXPECT().Check(gomock.Any()).Return(true, nil)
+		s.janusTokenCodec.EXPECT().Encode("nonce", int64(123), int64(456)).Return("active", nil)
+		s.userService.EXPECT().SetUserStatus(gomock.Any(), "room1", "user1", constants.AnchorStatusIdle, gomock.Any()).Return(nil)
+
+		res, err := join("active")
+		s.Require().NoError(err)
+		s.Equal(true, res.(map[string]any)["resume"])
+	})
+}
@@ -0,0 +1,91 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+	"github.com/imtaco/audio-rtc-exp/internal/validation"
+)
+
+// GatewayRoute is the preferred gateway of a room, load balancers route the anchors of the room
+// to it so its notifications are handled where its connections are
+type GatewayRoute struct {
+	RoomID string `json:"roomId"`
+	// Route is the consistent-hash token of the room, passed by clients as the route query param
+	Route string `json:"route"`
+	// ServerID is the preferred gateway, empty when no gateway is live
+	ServerID string `json:"serverId,omitempty"`
+	// URL is the advertised URL of the preferred gateway
+	URL string `json:"url,omitempty"`
+}
+
+// RouteResolver picks the preferred gateway of rooms from the presence registry. With a
+// partitioned notify stream it is the owner of the partition of the room, notifications of the
+// room are then consumed by the gateway its anchors are on
+type RouteResolver struct {
+	connGuard  ConnectionGuard
+	partitions int
+	logger     *log.Logger
+}
+
+func NewRouteResolver(connGuard ConnectionGuard, partitions int, logger *log.Logger) *RouteResolver {
+	return &RouteResolver{
+		connGuard:  connGuard,
+		partitions: partitions,
+		logger:     logger,
+	}
+}
+
+// Resolve returns the preferred gateway of the room
+func (r *RouteResolver) Resolve(ctx context.Context, roomID string) (*GatewayRoute, error) {
+	urls, err := r.connGuard.GatewayURLs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gateways := make([]string, 0, len(urls))
+	for id := range urls {
+		gateways = append(gateways, id)
+	}
+
+	key := roomID
+	if r.partitions > 0 {
+		// partitions are owned by the same hashing, see redisstream.OwnedPartitions
+		key = strconv.Itoa(redisstream.PartitionOf(roomID, r.partitions))
+	}
+	owner := redisstream.Owner(gateways, key)
+	return &GatewayRoute{
+		RoomID:   roomID,
+		Route:    redisstream.RouteKey(roomID),
+		ServerID: owner,
+		URL:      urls[owner],
+	}, nil
+}
+
+// HandleRoute serves the preferred gateway of the roomId query param
+func (r *RouteResolver) HandleRoute(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	roomID := req.URL.Query().Get("roomId")
+	if !validation.IsRoomID(roomID) {
+		http.Error(w, "invalid roomId", http.StatusBadRequest)
+		return
+	}
+
+	route, err := r.Resolve(req.Context(), roomID)
+	if err != nil {
+		r.logger.Error("Failed to resolve gateway route", log.String("roomId", roomID), log.Error(err))
+		http.Error(w, "failed to list gateways", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// owners move as gateways join and leave
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(route)
+}
@@ -0,0 +1,115 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
+)
+
+var testGatewayURLs = map[string]string{
+	"gw-a": "ws://gw-a/ws",
+	"gw-b": "ws://gw-b/ws",
+	"gw-c": "ws://gw-c/ws",
+}
+
+func TestRouteResolver(t *testing.T) {
+	gateways := []string{"gw-a", "gw-b", "gw-c"}
+
+	t.Run("owner of the room partition", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		connGuard := NewMockConnectionGuard(ctrl)
+		connGuard.EXPECT().GatewayURLs(gomock.Any()).Return(testGatewayURLs, nil)
+
+		route, err := NewRouteResolver(connGuard, 8, log.NewTest(t)).Resolve(context.Background(), "room-1")
+		require.NoError(t, err)
+		assert.Equal(t, "room-1", route.RoomID)
+		assert.Equal(t, redisstream.RouteKey("room-1"), route.Route)
+		assert.Equal(t, testGatewayURLs[route.ServerID], route.URL)
+
+		// the gateway consuming the notifications of the room
+		partition := redisstream.PartitionOf("room-1", 8)
+		assert.Contains(t, redisstream.OwnedPartitions(route.ServerID, gateways, 8), partition)
+	})
+
+	t.Run("owner of the room without partitions", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		connGuard := NewMockConnectionGuard(ctrl)
+		connGuard.EXPECT().GatewayURLs(gomock.Any()).Return(testGatewayURLs, nil).Times(2)
+		resolver := NewRouteResolver(connGuard, 0, log.NewTest(t))
+
+		route, err := resolver.Resolve(context.Background(), "room-1")
+		require.NoError(t, err)
+		assert.Equal(t, redisstream.Owner(gateways, "room-1"), route.ServerID)
+
+		// stable for every anchor of the room
+		again, err := resolver.Resolve(context.Background(), "room-1")
+		require.NoError(t, err)
+		assert.Equal(t, route, again)
+	})
+
+	t.Run("no live gateway", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		connGuard := NewMockConnectionGuard(ctrl)
+		connGuard.EXPECT().GatewayURLs(gomock.Any()).Return(map[string]string{}, nil)
+
+		route, err := NewRouteResolver(connGuard, 8, log.NewTest(t)).Resolve(context.Background(), "room-1")
+		require.NoError(t, err)
+		assert.Empty(t, route.ServerID)
+		assert.Empty(t, route.URL)
+		assert.NotEmpty(t, route.Route)
+	})
+}
+
+func TestHandleRoute(t *testing.T) {
+	get := func(resolver *RouteResolver, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		resolver.HandleRoute(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		connGuard := NewMockConnectionGuard(ctrl)
+		connGuard.EXPECT().GatewayURLs(gomock.Any()).Return(testGatewayURLs, nil)
+
+		w := get(NewRouteResolver(connGuard, 4, log.NewTest(t)), "/route?roomId=room-1")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		var route GatewayRoute
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &route))
+		owner := redisstream.Owner([]string{"gw-a", "gw-b", "gw-c"}, strconv.Itoa(redisstream.PartitionOf("room-1", 4)))
+		assert.Equal(t, GatewayRoute{
+			RoomID:   "room-1",
+			Route:    redisstream.RouteKey("room-1"),
+			ServerID: owner,
+			URL:      testGatewayURLs[owner],
+		}, route)
+	})
+
+	t.Run("invalid room", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		w := get(NewRouteResolver(NewMockConnectionGuard(ctrl), 4, log.NewTest(t)), "/route?roomId=a")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("registry unavailable", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		connGuard := NewMockConnectionGuard(ctrl)
+		connGuard.EXPECT().GatewayURLs(gomock.Any()).Return(nil, errors.New("redis down"))
+
+		w := get(NewRouteResolver(connGuard, 4, log.NewTest(t)), "/route?roomId=room-1")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
@@ -0,0 +1,208 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+// RPCMetricsConfig configures the slow call log of RPC methods
+type RPCMetricsConfig struct {
+	// SlowThreshold logs calls lasting longer with their Janus round trips, 0 disables the log
+	SlowThreshold time.Duration `mapstructure:"slow_threshold"`
+}
+
+func SetupRPCMetrics(v *viper.Viper, prefix string) {
+	p := func(key string) string { return prefix + "." + key }
+
+	v.SetDefault(p("slow_threshold"), "1s")
+}
+
+const (
+	outcomeOK    = "ok"
+	outcomeError = "error"
+)
+
+// janusCall is a Janus round trip made while handling an RPC call
+type janusCall struct {
+	op       string
+	duration time.Duration
+}
+
+// connStats counts the RPC calls of a connection, logged when it disconnects
+type connStats struct {
+	calls  int
+	failed int
+	slow   int
+}
+
+// rpcInstrument records duration and outcome of RPC calls and logs the slow ones
+type rpcInstrument struct {
+	slowThreshold time.Duration
+	logger        *log.Logger
+}
+
+func newRPCInstrument(cfg *RPCMetricsConfig, logger *log.Logger) *rpcInstrument {
+	i := &rpcInstrument{logger: logger}
+	if cfg != nil {
+		i.slowThreshold = cfg.SlowThreshold
+	}
+	return i
+}
+
+// Wrap returns the handler recording each call of method
+func (i *rpcInstrument) Wrap(method string, handler jsonrpc.MethodHandler[rtcContext]) jsonrpc.MethodHandler[rtcContext] {
+	return func(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+		rtcCtx := mctx.Get()
+		rtcCtx.janusCalls = rtcCtx.janusCalls[:0]
+
+		start := time.Now()
+		result, err := handler(mctx, params)
+		elapsed := time.Since(start)
+
+		outcome := outcomeOK
+		attrs := []attribute.KeyValue{attribute.String("method", method)}
+		rtcCtx.stats.calls++
+		if err != nil {
+			outcome = outcomeError
+			rtcCtx.stats.failed++
+			rpcRequestsFailed.Add(context.Background(), 1, metric.WithAttributes(
+				attribute.String("method", method),
+				attribute.String("error.code", strconv.FormatInt(errorCode(err), 10))))
+		}
+		attrs = append(attrs, attribute.String("outcome", outcome))
+		rpcRequestsTotal.Add(context.Background(), 1, metric.WithAttributes(attrs...))
+		rpcDuration.Record(context.Background(), elapsed.Seconds(), metric.WithAttributes(attrs...))
+
+		if i.slowThreshold > 0 && elapsed > i.slowThreshold {
+			rtcCtx.stats.slow++
+			rpcSlowCalls.Add(context.Background(), 1, metric.WithAttributes(attribute.String("method", method)))
+			i.logSlow(method, outcome, elapsed, rtcCtx)
+		}
+		return result, err
+	}
+}
+
+// interceptor records the calls of peer requests, dispatched notifications are not counted
+func (i *rpcInstrument) interceptor(
+	mctx jsonrpc.MethodContext[rtcContext],
+	info *jsonrpc.RequestInfo,
+	params *json.RawMessage,
+	next jsonrpc.MethodHandler[rtcContext],
+) (any, error) {
+	if info.Local {
+		return next(mctx, params)
+	}
+	return i.Wrap(info.Method, next)(mctx, params)
+}
+
+func (i *rpcInstrument) logSlow(method, outcome string, elapsed time.Duration, rtcCtx *rtcContext) {
+	var janusTotal time.Duration
+	janusCalls := make([]string, 0, len(rtcCtx.janusCalls))
+	for _, call := range rtcCtx.janusCalls {
+		janusTotal += call.duration
+		janusCalls = append(janusCalls, call.op+"="+call.duration.String())
+	}
+
+	fields := []log.Field{
+		log.String("method", method),
+		log.String("outcome", outcome),
+		log.Duration("latency", elapsed),
+		log.Duration("janus", janusTotal),
+		log.Strings("janusCalls", janusCalls),
+	}
+	fields = append(fields, rtcCtx.logFields()...)
+	i.logger.Warn("Slow RPC call", fields...)
+}
+
+func errorCode(err error) int64 {
+	if rpcErr, ok := errors.As[*jsonrpc.Error](err); ok {
+		return rpcErr.Code
+	}
+	return int64(jsonrpc.CodeInternalError)
+}
+
+// trackJanus records a Janus round trip started at start for the slow call log
+func (c *rtcContext) trackJanus(op string, start time.Time) {
+	c.janusCalls = append(c.janusCalls, janusCall{op: op, duration: time.Since(start)})
+}
+
+// timedAnchor records the round trips of the anchor instance of a connection
+type timedAnchor struct {
+	janus.Anchor
+	rtcCtx *rtcContext
+}
+
+func newTimedAnchor(anchor janus.Anchor, rtcCtx *rtcContext) janus.Anchor {
+	if anchor == nil {
+		return nil
+	}
+	return &timedAnchor{Anchor: anchor, rtcCtx: rtcCtx}
+}
+
+func (a *timedAnchor) Join(
+	ctx context.Context,
+	roomID int64,
+	pin, displayName string,
+	bitrate int,
+	group string,
+	jsep *janus.JSEP,
+) (*janus.Response, error) {
+	defer a.rtcCtx.trackJanus("join", time.Now())
+	return a.Anchor.Join(ctx, roomID, pin, displayName, bitrate, group, jsep)
+}
+
+func (a *timedAnchor) Leave(ctx context.Context) (*janus.Response, error) {
+	defer a.rtcCtx.trackJanus("leave", time.Now())
+	return a.Anchor.Leave(ctx)
+}
+
+func (a *timedAnchor) IceCandidate(ctx context.Context, candidate janus.ICECandidate) (*janus.Response, error) {
+	defer a.rtcCtx.trackJanus("trickle", time.Now())
+	return a.Anchor.IceCandidate(ctx, candidate)
+}
+
+func (a *timedAnchor) IceRestart(ctx context.Context, jsep *janus.JSEP) (*janus.Response, error) {
+	defer a.rtcCtx.trackJanus("iceRestart", time.Now())
+	return a.Anchor.IceRestart(ctx, jsep)
+}
+
+func (a *timedAnchor) Check(ctx context.Context) (bool, error) {
+	defer a.rtcCtx.trackJanus("check", time.Now())
+	return a.Anchor.Check(ctx)
+}
+
+func (a *timedAnchor) SetMuted(ctx context.Context, muted bool) error {
+	defer a.rtcCtx.trackJanus("mute", time.Now())
+	return a.Anchor.SetMuted(ctx, muted)
+}
+
+func (a *timedAnchor) SetGroup(ctx context.Context, group string) error {
+	defer a.rtcCtx.trackJanus("group", time.Now())
+	return a.Anchor.SetGroup(ctx, group)
+}
+
+func (a *timedAnchor) GetEvents(ctx context.Context, maxEvents int) ([]*janus.Response, error) {
+	defer a.rtcCtx.trackJanus("events", time.Now())
+	return a.Anchor.GetEvents(ctx, maxEvents)
+}
+
+func (a *timedAnchor) Destroy(ctx context.Context) error {
+	defer a.rtcCtx.trackJanus("destroy", time.Now())
+	return a.Anchor.Destroy(ctx)
+}
+
+func (a *timedAnchor) KeepAlive(ctx context.Context) error {
+	defer a.rtcCtx.trackJanus("keepalive", time.Now())
+	return a.Anchor.KeepAlive(ctx)
+}
@@ -0,0 +1,68 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/imtaco/audio-rtc-exp/internal/janus/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+func TestRPCInstrument(t *testing.T) {
+	ok := func(jsonrpc.MethodContext[rtcContext], *json.RawMessage) (any, error) {
+		return "done", nil
+	}
+	fail := func(jsonrpc.MethodContext[rtcContext], *json.RawMessage) (any, error) {
+		return nil, jsonrpc.ErrInvalidParams("bad")
+	}
+
+	t.Run("counts calls of the connection", func(t *testing.T) {
+		i := newRPCInstrument(nil, log.NewNop())
+		rtcCtx := &rtcContext{connID: "conn-1"}
+		mctx := &mockMethodContext{context: rtcCtx}
+
+		result, err := i.Wrap("join", ok)(mctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "done", result)
+		_, err = i.Wrap("offer", fail)(mctx, nil)
+		require.Error(t, err)
+
+		assert.Equal(t, connStats{calls: 2, failed: 1}, rtcCtx.stats)
+	})
+
+	t.Run("slow calls keep their janus round trips", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		anchor := mocks.NewMockAnchor(ctrl)
+		anchor.EXPECT().Check(gomock.Any()).Return(true, nil)
+		anchor.EXPECT().SetMuted(gomock.Any(), true).Return(nil)
+
+		i := newRPCInstrument(&RPCMetricsConfig{SlowThreshold: 1}, log.NewTest(t))
+		rtcCtx := &rtcContext{connID: "conn-1", janusCalls: []janusCall{{op: "stale"}}}
+		room := &roomContext{janus: newTimedAnchor(anchor, rtcCtx)}
+		mctx := &mockMethodContext{context: rtcCtx}
+
+		_, err := i.Wrap("mute", func(jsonrpc.MethodContext[rtcContext], *json.RawMessage) (any, error) {
+			if _, err := room.janus.Check(context.Background()); err != nil {
+				return nil, err
+			}
+			return nil, room.janus.SetMuted(context.Background(), true)
+		})(mctx, nil)
+		require.NoError(t, err)
+
+		require.Len(t, rtcCtx.janusCalls, 2)
+		assert.Equal(t, "check", rtcCtx.janusCalls[0].op)
+		assert.Equal(t, "mute", rtcCtx.janusCalls[1].op)
+		assert.Equal(t, connStats{calls: 1, slow: 1}, rtcCtx.stats)
+	})
+
+	t.Run("error code", func(t *testing.T) {
+		assert.Equal(t, int64(jsonrpc.CodeInvalidParams), errorCode(jsonrpc.ErrInvalidParams("bad")))
+		assert.Equal(t, int64(jsonrpc.CodeInternalError), errorCode(context.Canceled))
+	})
+}
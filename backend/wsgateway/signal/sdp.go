@@ -0,0 +1,71 @@
+package signal
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
+	"github.com/imtaco/audio-rtc-exp/internal/janus"
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/sdp"
+)
+
+// sanitizeOffer validates a client offer and rewrites it to what Janus AudioBridge takes, so
+// junk offers are rejected before they pin a Janus handle
+func sanitizeOffer(jsep *janus.JSEP) error {
+	if jsep.Type != "offer" {
+		return jsonrpc.ErrInvalidParams("SDP type must be offer")
+	}
+	sanitized, err := sdp.SanitizeOffer(jsep.SDP)
+	if err != nil {
+		return invalidSDPError(err)
+	}
+	jsep.SDP = sanitized
+	return nil
+}
+
+// invalidSDPError carries the rejected line and reason as error data
+func invalidSDPError(err error) *jsonrpc.Error {
+	sdpErr, ok := errors.As[*sdp.Error](err)
+	if !ok {
+		sdpErr = &sdp.Error{Reason: err.Error()}
+	}
+	data, _ := json.Marshal(sdpErr)
+	raw := json.RawMessage(data)
+	return &jsonrpc.Error{
+		Code:    codeInvalidSDP,
+		Message: "invalid SDP: " + sdpErr.Error(),
+		Data:    &raw,
+	}
+}
+
+// prepareAnswer checks the answer of Janus before it is handed to the client, and applies the
+// Opus settings of the room the client encodes with
+func prepareAnswer(raw json.RawMessage, audio *etcdstate.AudioParams) (json.RawMessage, error) {
+	var jsep janus.JSEP
+	if err := json.Unmarshal(raw, &jsep); err != nil {
+		return nil, err
+	}
+	if jsep.Type != "answer" {
+		return nil, fmt.Errorf("unexpected SDP type %q", jsep.Type)
+	}
+
+	params := sdp.OpusParams{
+		FEC:               audio.GetFEC(),
+		DTX:               audio.GetDTX(),
+		MaxAverageBitrate: audio.GetMaxAverageBitrate(),
+		Stereo:            audio.GetStereo(),
+	}
+	if params.Empty() {
+		_, err := sdp.Parse(jsep.SDP)
+		return raw, err
+	}
+
+	munged, err := sdp.ApplyOpusParams(jsep.SDP, params)
+	if err != nil {
+		return nil, err
+	}
+	jsep.SDP = munged
+	return json.Marshal(&jsep)
+}
@@ -4,30 +4,66 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 
+	"github.com/imtaco/audio-rtc-exp/internal/apispec"
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/errors"
+	"github.com/imtaco/audio-rtc-exp/internal/i18n"
 	"github.com/imtaco/audio-rtc-exp/internal/janus"
 	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
 	"github.com/imtaco/audio-rtc-exp/internal/jwt"
+	"github.com/imtaco/audio-rtc-exp/internal/pin"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
 	"github.com/imtaco/audio-rtc-exp/users"
 	"github.com/imtaco/audio-rtc-exp/wsgateway"
 )
 
 const (
 	GEN = 1
+
+	// codePinLocked is returned to joins locked out after too many failed PIN attempts
+	codePinLocked = -32001
+	// codeInvalidSDP is returned to offers rejected by SDP validation, data holds line and reason
+	codeInvalidSDP = -32002
+	// codeJanusUnavailable is returned to calls failed fast while the Janus of the room keeps
+	// failing, clients may retry later
+	codeJanusUnavailable = -32003
 )
 
+// janusCallError returns the error of a failed Janus call to the client
+func janusCallError(err error, message string) *jsonrpc.Error {
+	if errors.Is(err, janus.ErrCircuitOpen) {
+		return jsonrpc.ErrCustom(codeJanusUnavailable, "janus unavailable, retry later")
+	}
+	return jsonrpc.ErrInternal(message)
+}
+
+// replayRequireID are the methods that must be called with a request ID, so a resent request
+// is answered from the replay cache instead of joining or negotiating twice
+var replayRequireID = []string{
+	"join", "leave", "offer", "iceRestart", "grantFloor", "grantPublish", "endRoom", "freezeRoom", "unfreezeRoom",
+}
+
 type Server struct {
 	jsonrpc.Handler[rtcContext]
 	janusProxy      wsgateway.JanusProxy
 	janusTokenCodec wsgateway.JanusTokenCodec
 	connGuard       ConnectionGuard
+	pinGuard        PinGuard
 	userService     users.UserService
+	roomModerator   RoomModerator        // nil disables endRoom, freezeRoom and unfreezeRoom
+	errorReporter   *ClientErrorReporter // nil only logs client errors
+	catalog         *i18n.Catalog        // nil sends notification codes without messages
 	clientManager   *WSConnManager
 	jwtAuth         jwt.Auth
+	reqLogger       *jsonrpc.RequestLogger[rtcContext]
+	replayCfg       *jsonrpc.ReplayConfig
+	rpcMetrics      *rpcInstrument
+	reconnect       *reconnectAdvisor
+	spec            *apispec.RPCSpec
 	logger          *log.Logger
 }
 
@@ -38,7 +74,15 @@ func NewServer(
 	clientManager *WSConnManager,
 	userService users.UserService,
 	connGuard ConnectionGuard,
+	pinGuard PinGuard,
 	jwtAuth jwt.Auth,
+	roomModerator RoomModerator,
+	errorReporter *ClientErrorReporter,
+	catalog *i18n.Catalog,
+	reqLogCfg *jsonrpc.RequestLogConfig,
+	replayCfg *jsonrpc.ReplayConfig,
+	rpcMetricsCfg *RPCMetricsConfig,
+	reconnectCfg *ReconnectConfig,
 	logger *log.Logger,
 ) *Server {
 	// TODO: create client manager here ?
@@ -46,17 +90,39 @@ func NewServer(
 		Handler:         handler,
 		janusProxy:      janusProxy,
 		connGuard:       connGuard,
+		pinGuard:        pinGuard,
 		userService:     userService,
+		roomModerator:   roomModerator,
+		errorReporter:   errorReporter,
+		catalog:         catalog,
 		janusTokenCodec: janusTokenCodec,
 		clientManager:   clientManager,
 		jwtAuth:         jwtAuth,
+		reqLogger:       jsonrpc.NewRequestLogger(reqLogCfg, (*rtcContext).logFields, logger.Module("RPCLog")),
+		replayCfg:       replayCfg,
+		rpcMetrics:      newRPCInstrument(rpcMetricsCfg, logger.Module("RPCSlow")),
+		reconnect:       newReconnectAdvisor(reconnectCfg, connGuard, logger),
+		spec:            apispec.NewRPC("WS Signal API", "1.0.0"),
 		logger:          logger,
 	}
 }
 
+// Spec returns the machine-readable description of the RPC methods, available after Open
+func (s *Server) Spec() *apispec.RPCSpec {
+	return s.spec
+}
+
 func (s *Server) Open(ctx context.Context) error {
 	s.logger.Info("Opening Signal Server")
+	// logged outermost, so the request log has the outcome of panics and rejected calls
+	s.Use(
+		s.reqLogger.Interceptor(),
+		s.rpcMetrics.interceptor,
+		jsonrpc.Recover[rtcContext](s.logger.Module("RPCPanic")),
+	)
 	s.register()
+	s.EnableReplay(s.replayCfg, replayRequireID...)
+	s.janusProxy.OnRoomChange(s.handleRoomChange)
 
 	if err := s.connGuard.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start heartbeat: %w", err)
@@ -67,19 +133,213 @@ func (s *Server) Open(ctx context.Context) error {
 
 func (s *Server) Close() error {
 	s.logger.Info("Closing Signal Server")
+	// stop the heartbeat first, so this gateway is neither suggested to drained clients
+	// nor holding their connect locks when they reconnect elsewhere
 	s.connGuard.Stop()
+	s.drain(context.Background())
 	return nil
 }
 
+// drain closes all connections with a hint to reconnect to another gateway
+func (s *Server) drain(ctx context.Context) {
+	conns := s.clientManager.allConns()
+	if len(conns) == 0 {
+		return
+	}
+
+	gateway := s.reconnect.peerGateway(ctx)
+	s.logger.Info("Draining connections",
+		log.Int("count", len(conns)),
+		log.String("gateway", gateway))
+
+	for _, conn := range conns {
+		closeWithHint(ctx, conn, s.reconnect.drainHint(gateway), s.logger)
+	}
+}
+
 func (s *Server) register() {
 	// Register RPC methods
 	// handler is single threaded, no need to lock here
-	s.Def("join", s.handleJoin)
-	s.Def("leave", s.handleLeave)
-	s.Def("offer", s.handleOffer)
-	s.Def("icecandidate", s.handleIceCandidate)
-	s.Def("keepalive", s.handleKeepAlive)
-	s.Def("status", s.handleKeepAlive)
+	s.def(apispec.RPCMethod{
+		Name: "join",
+		Summary: "Join the room of the connection token, or roomId with a token of that room for the same user, " +
+			"pass jtoken to resume a previous Janus session. Other methods take roomId to pick among joined rooms",
+		Params: joinParams{},
+		Result: map[string]any{"jtoken": "", "resume": false, "route": ""},
+	}, s.handleJoin)
+	s.def(apispec.RPCMethod{
+		Name:    "leave",
+		Summary: "Leave the room, the connection is closed once no joined room is left",
+		Params:  roomParams{},
+	}, s.handleLeave)
+	s.def(apispec.RPCMethod{
+		Name:    "offer",
+		Summary: "Send SDP offer and receive the Janus SDP answer, offers keep opus audio only and invalid ones fail with code -32002 and {line, reason} data",
+		Params:  offerParams{},
+		Result:  map[string]any{"sdp": janus.JSEP{}},
+	}, s.handleOffer)
+	s.def(apispec.RPCMethod{
+		Name: "iceRestart",
+		Summary: "Send an ICE restart offer after the network path changed, the Janus handle and room are kept " +
+			"and the answer is returned like for offer",
+		Params: offerParams{},
+		Result: map[string]any{"sdp": janus.JSEP{}},
+	}, s.handleIceRestart)
+	s.def(apispec.RPCMethod{
+		Name:    "icecandidate",
+		Summary: "Trickle an ICE candidate",
+		Params:  iceCandidateParams{},
+	}, s.handleIceCandidate)
+	s.def(apispec.RPCMethod{
+		Name:    "keepalive",
+		Summary: "Keep Janus session alive and report anchor status",
+		Params:  keepAliveParams{},
+	}, s.handleKeepAlive)
+	s.def(apispec.RPCMethod{
+		Name:    "status",
+		Summary: "Alias of keepalive",
+		Params:  keepAliveParams{},
+	}, s.handleKeepAlive)
+	s.def(apispec.RPCMethod{
+		Name:    "stats.report",
+		Summary: "Report a WebRTC getStats summary, the resulting quality score is tracked per anchor and room",
+		Params:  users.NetworkStats{},
+		Result:  map[string]any{"quality": 0},
+	}, s.handleStatsReport)
+	s.def(apispec.RPCMethod{
+		Name: "clientError",
+		Summary: "Report a WebRTC failure of the client, code is one of ice_failed, media_denied, device_error, " +
+			"negotiation_failed or other, with an optional message and string detail. Allowed before joining",
+		Params: clientErrorParams{},
+	}, s.handleClientError)
+
+	s.def(apispec.RPCMethod{
+		Name:    "raiseHand",
+		Summary: "Join the speaking queue of the room",
+		Params:  roomParams{},
+	}, s.handleRaiseHand)
+	s.def(apispec.RPCMethod{
+		Name:    "lowerHand",
+		Summary: "Leave the speaking queue, hosts may pass userId to lower the hand of others",
+		Params:  lowerHandParams{},
+	}, s.handleLowerHand)
+	s.def(apispec.RPCMethod{
+		Name:    "grantFloor",
+		Summary: "Hosts only, give the floor to userId or the head of the speaking queue, optionally unmuting them",
+		Params:  grantFloorParams{},
+		Result:  map[string]any{"userId": ""},
+	}, s.handleGrantFloor)
+	s.def(apispec.RPCMethod{
+		Name: "requestPublish",
+		Summary: "Anchors of push-to-talk rooms only publish once granted a slot, ask the hosts for one " +
+			"through the speaking queue",
+		Params: roomParams{},
+	}, s.handleRequestPublish)
+	s.def(apispec.RPCMethod{
+		Name: "grantPublish",
+		Summary: "Hosts only, give a publish slot of the push-to-talk room to userId or the head of the speaking " +
+			"queue, fails once all slots are taken",
+		Params: grantPublishParams{},
+		Result: map[string]any{"userId": ""},
+	}, s.handleGrantPublish)
+	s.def(apispec.RPCMethod{
+		Name:    "revokePublish",
+		Summary: "Give the publish slot back, hosts may pass userId to revoke the slot of others",
+		Params:  revokePublishParams{},
+	}, s.handleRevokePublish)
+	s.def(apispec.RPCMethod{
+		Name: "endRoom",
+		Summary: "Hosts only, end the room: anchors are told with room_ending, then users, the forwarder " +
+			"and the live are stopped in order",
+		Params: roomParams{},
+		Result: map[string]any{"stage": constants.EndStageNotifying},
+	}, s.handleEndRoom)
+	s.def(apispec.RPCMethod{
+		Name: "freezeRoom",
+		Summary: "Hosts only, freeze the room for incident handling: joins are rejected and anchors muted, " +
+			"told with room_frozen, while the live goes on",
+		Params: roomParams{},
+		Result: map[string]any{"frozen": true},
+	}, s.handleFreezeRoom(true))
+	s.def(apispec.RPCMethod{
+		Name:    "unfreezeRoom",
+		Summary: "Hosts only, unfreeze the room: joins are taken again and anchors unmuted, told with room_frozen",
+		Params:  roomParams{},
+		Result:  map[string]any{"frozen": false},
+	}, s.handleFreezeRoom(false))
+	s.DefLocal(floorUnmuteMethod, s.handleFloorUnmute)
+	s.DefLocal(publishChangeMethod, s.handlePublishChange)
+	s.DefLocal(queueChangeMethod, s.handleQueueChange)
+	s.DefLocal(linkRegroupMethod, s.handleLinkRegroup)
+	s.DefLocal(userEvictedMethod, s.handleUserEvicted)
+	s.DefLocal(connReplacedMethod, s.handleConnReplaced)
+	s.DefLocal(roomEndingMethod, s.handleRoomEnding)
+	s.DefLocal(roomFreezeMethod, s.handleRoomFreeze)
+
+	s.spec.Notification(apispec.RPCMethod{
+		Name:    roomStatusMethod,
+		Summary: "Active members of the room of the connection token, pushed whenever member status changes",
+		Params:  []*users.RoomUser{},
+	})
+	s.spec.Notification(apispec.RPCMethod{
+		Name:    roomMembersMethod,
+		Summary: "Active members of another joined room, pushed whenever member status changes",
+		Params:  users.NotifyRoomStatus{},
+	})
+	s.spec.Notification(apispec.RPCMethod{
+		Name:    "floorGranted",
+		Summary: "Pushed to the room when a host grants the floor",
+		Params:  users.NotifyFloorGranted{},
+	})
+	s.spec.Notification(apispec.RPCMethod{
+		Name: publishChangedNotification,
+		Summary: "Pushed when the publish slot of the anchor in a push-to-talk room is granted, offer or get " +
+			"unmuted, or revoked, muted in Janus",
+		Params: publishChanged{},
+	})
+	s.spec.Notification(apispec.RPCMethod{
+		Name: queuePositionNotification,
+		Summary: "Pushed to a join parked in the join queue of a full room when its position changes, " +
+			"join again once admitted or at position 0",
+		Params: queuePosition{},
+	})
+	s.spec.Notification(apispec.RPCMethod{
+		Name:    evictedNotification,
+		Summary: "Pushed when the anchor was released after staying idle or disconnected too long, join again to go on air",
+		Params:  userEvicted{},
+	})
+	s.spec.Notification(apispec.RPCMethod{
+		Name:    liveEndingSoonNotification,
+		Summary: "Pushed when the live of the room nears its max duration, it is stopped at endsAt",
+		Params:  liveEndingSoon{},
+	})
+	s.spec.Notification(apispec.RPCMethod{
+		Name:    roomEndingNotification,
+		Summary: "Pushed once when the room is being ended, joins are rejected from then on",
+		Params:  roomEnding{},
+	})
+	s.spec.Notification(apispec.RPCMethod{
+		Name:    roomFrozenNotification,
+		Summary: "Pushed when the room is frozen or unfrozen, anchors are muted in Janus while it is frozen",
+		Params:  roomFrozen{},
+	})
+	s.spec.Notification(apispec.RPCMethod{
+		Name:    "pin_attempts_exceeded",
+		Summary: "Pushed to room hosts when a user is locked out after too many wrong PINs",
+		Params:  pinAttemptsExceeded{},
+	})
+	s.spec.Notification(apispec.RPCMethod{
+		Name: closingMethod,
+		Summary: "Sent right before the gateway closes the connection, with whether and how to reconnect, " +
+			"reconnect to gateway when set",
+		Params: closeHint{},
+	})
+}
+
+// def registers the RPC method and publishes it in the API spec
+func (s *Server) def(method apispec.RPCMethod, handler jsonrpc.MethodHandler[rtcContext]) {
+	s.spec.Method(method)
+	s.Def(method.Name, handler)
 }
 
 func (s *Server) updateUserStatus(ctx context.Context, roomID, userID string, status constants.AnchorStatus) {
@@ -109,35 +369,61 @@ func (s *Server) mustHoldLock(mctx jsonrpc.MethodContext[rtcContext]) {
 func (s *Server) handleJoin(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
 
 	rtcCtx := mctx.Get()
-	if rtcCtx.joined {
+	if rtcCtx.duplicate != nil {
+		return nil, duplicateLoginError(rtcCtx.duplicate)
+	}
+	roomID := rtcCtx.targetRoomID(params)
+	room := rtcCtx.room(roomID)
+	if room != nil && room.joined {
 		return nil, jsonrpc.ErrInvalidRequest("already joined")
 	}
 
-	var data struct {
-		Pin        string `json:"pin"`
-		ClientID   string `json:"clientId" validate:"required,uuid4"`
-		JanusToken string `json:"jtoken"`
-	}
+	var data joinParams
 	if err := jsonrpc.ShouldBindParams(params, &data); err != nil {
 		return nil, jsonrpc.ErrInvalidParams("invalid join parameters")
 	}
 	// TODO: validation
 
+	// rooms other than the one of the connection token are joined with a token of their own
+	if room == nil {
+		var err error
+		if room, err = s.roomOfToken(rtcCtx, roomID, data.Token); err != nil {
+			return nil, err
+		}
+	}
+
 	ctx := rtcCtx.reqCtx
-	roomID := rtcCtx.roomID
 
 	roomMeta := s.janusProxy.GetRoomMeta(roomID)
 	if roomMeta == nil {
 		return nil, jsonrpc.ErrInvalidRequest("no room found")
 	}
 
+	if roomMeta.GetEndStage() != "" {
+		return nil, jsonrpc.ErrInvalidRequest("room is ending")
+	}
+	// anchors reconnecting with their Janus token keep their place, muted, checked once their
+	// session is resumed
+	if roomMeta.IsFrozen() && data.JanusToken == "" {
+		return nil, jsonrpc.ErrInvalidRequest("room is frozen")
+	}
+
 	liveMeta := s.janusProxy.GetRoomLiveMeta(roomID)
 	if liveMeta == nil || liveMeta.Status != constants.RoomStatusOnAir {
 		return nil, jsonrpc.ErrInvalidRequest("room does not exist or not allowed to join")
 	}
 
-	if roomMeta.GetPin() != "" && data.Pin != roomMeta.GetPin() {
-		return nil, jsonrpc.ErrInvalidRequest("invalid room pin")
+	if roomMeta.GetPin() != "" {
+		if err := s.checkPin(rtcCtx, roomID, data.Pin, roomMeta.GetPin()); err != nil {
+			return nil, err
+		}
+	}
+	// anchors reconnecting with their Janus token were admitted already, checked once their
+	// session is resumed
+	if roomMeta.GetJoinQueue() > 0 && data.JanusToken == "" {
+		if parked, err := s.parkJoin(mctx, room); parked != nil || err != nil {
+			return parked, err
+		}
 	}
 
 	janusAPI := s.janusProxy.GetJanusAPI(roomID)
@@ -147,22 +433,36 @@ func (s *Server) handleJoin(mctx jsonrpc.MethodContext[rtcContext], params *json
 
 	// sessionID and handleID are encoded into janus token, such that we can restore janus instance
 	// when connection drops and reconnects without re-creating janus session/handle to interrupt ongoing RTC session
-	var sessionID, handleID int64
-	var err error
+	var apiInst janus.Anchor
 	if data.JanusToken != "" {
-		sessionID, handleID, err = s.janusTokenCodec.Decode(liveMeta.Nonce, data.JanusToken)
+		sessionID, handleID, err := s.janusTokenCodec.Decode(liveMeta.Nonce, data.JanusToken)
 		if err != nil {
 			s.logger.Error("Failed to decode janus token", log.Error(err))
-			sessionID, handleID = 0, 0
+		} else if apiInst, err = s.resumeJanusInstance(rtcCtx, janusAPI, sessionID, handleID); err != nil {
+			return nil, err
 		}
 	}
+	// resumed session no need to negotiate RTC again
+	resume := apiInst != nil
 
-	apiInst, err := s.restoreJanusInstance(rtcCtx, janusAPI, sessionID, handleID)
-	if err != nil {
-		return nil, err
+	// tokens that do not resume their session join as new anchors, frozen and queued rooms
+	// take none
+	if !resume && data.JanusToken != "" {
+		if roomMeta.IsFrozen() {
+			return nil, jsonrpc.ErrInvalidRequest("room is frozen")
+		}
+		if roomMeta.GetJoinQueue() > 0 {
+			if parked, err := s.parkJoin(mctx, room); parked != nil || err != nil {
+				return parked, err
+			}
+		}
+	}
+	if !resume {
+		var err error
+		if apiInst, err = s.createJanusInstance(rtcCtx, janusAPI); err != nil {
+			return nil, err
+		}
 	}
-	// resumed session no need to negotiate RTC again
-	resume := (sessionID == apiInst.GetSessionID() && handleID == apiInst.GetHandleID())
 
 	janusToken, err := s.janusTokenCodec.Encode(liveMeta.Nonce, apiInst.GetSessionID(), apiInst.GetHandleID())
 	if err != nil {
@@ -170,24 +470,111 @@ func (s *Server) handleJoin(mctx jsonrpc.MethodContext[rtcContext], params *json
 		return nil, jsonrpc.ErrInternal("fail to create janus token")
 	}
 
-	rtcCtx.janus = apiInst
-	rtcCtx.joined = true
+	room.janus = newTimedAnchor(apiInst, rtcCtx)
+	room.joined = true
+	room.frozen = roomMeta.IsFrozen()
+	rtcCtx.addRoom(room)
+	// the connection is in the token room from connect on
+	if roomID != rtcCtx.roomID {
+		s.clientManager.AddClient(rtcCtx.connID, roomID, mctx.Peer())
+	}
+	joinsActive.Add(ctx, 1)
 
 	s.updateUserStatus(ctx, roomID, rtcCtx.userID, constants.AnchorStatusIdle)
 
-	// pass janus token back to client for future reconnect
+	// pass janus token back to client for future reconnect, route keeps reconnects of the room
+	// together behind load balancers hashing on it
 	return map[string]any{
 		"jtoken": janusToken,
 		"resume": resume,
+		"route":  redisstream.RouteKey(roomID),
 	}, nil
 }
 
-func (s *Server) handleLeave(mctx jsonrpc.MethodContext[rtcContext], _ *json.RawMessage) (any, error) {
+// roomOfToken verifies the token of a room other than the one of the connection token, it must
+// be issued to the same user, the role it carries applies in that room
+func (s *Server) roomOfToken(rtcCtx *rtcContext, roomID, token string) (*roomContext, error) {
+	if token == "" {
+		return nil, jsonrpc.ErrInvalidParams("token of the room is required")
+	}
+	rtcCtx.roomsMu.RLock()
+	rooms := len(rtcCtx.rooms)
+	rtcCtx.roomsMu.RUnlock()
+	if rooms >= maxConnRooms {
+		return nil, jsonrpc.ErrInvalidRequest("too many rooms on the connection")
+	}
+
+	payload, err := s.jwtAuth.Verify(token)
+	if err != nil {
+		return nil, jsonrpc.ErrInvalidRequest("invalid room token")
+	}
+	if payload.RoomID != roomID || payload.UserID != rtcCtx.userID {
+		return nil, jsonrpc.ErrInvalidRequest("room token does not match the room or user")
+	}
+	if !payload.HasRole(constants.UserRoleHost, constants.UserRoleAnchor) {
+		return nil, jsonrpc.ErrInvalidRequest("token role not allowed")
+	}
+	return &roomContext{roomID: roomID, role: payload.Role}, nil
+}
+
+// checkPin verifies the room PIN, failed attempts are throttled per connection and per user
+func (s *Server) checkPin(rtcCtx *rtcContext, roomID, given, expected string) error {
+	ctx := rtcCtx.reqCtx
+
+	lockout, err := s.pinGuard.Locked(ctx, rtcCtx.connID, roomID, rtcCtx.userID)
+	if err != nil {
+		s.logger.Error("Failed to check pin lockout", log.Error(err))
+		return jsonrpc.ErrInternal("failed to check room pin")
+	}
+	if lockout > 0 {
+		return jsonrpc.ErrCustom(codePinLocked,
+			fmt.Sprintf("too many pin attempts, retry in %ds", int64(lockout.Seconds())))
+	}
+
+	if pin.Equal(given, expected) {
+		if err := s.pinGuard.Reset(ctx, rtcCtx.connID, roomID, rtcCtx.userID); err != nil {
+			s.logger.Error("Failed to reset pin attempts", log.Error(err))
+		}
+		return nil
+	}
+
+	pinFailures.Add(ctx, 1)
+	lockout, err = s.pinGuard.Fail(ctx, rtcCtx.connID, roomID, rtcCtx.userID)
+	if err != nil {
+		s.logger.Error("Failed to count pin attempt", log.Error(err))
+	} else if lockout > 0 {
+		pinLockouts.Add(ctx, 1)
+		if err := s.clientManager.NotifyModerators(ctx, roomID, "pin_attempts_exceeded", &pinAttemptsExceeded{
+			RoomID:      roomID,
+			UserID:      rtcCtx.userID,
+			LockoutSecs: int64(lockout.Seconds()),
+		}); err != nil {
+			s.logger.Error("Failed to notify moderators", log.Error(err))
+		}
+	}
+	return jsonrpc.ErrInvalidRequest("invalid room pin")
+}
+
+func (s *Server) handleLeave(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
 	rtcCtx := mctx.Get()
-	if !rtcCtx.joined {
+	room := rtcCtx.joinedRoom(params)
+	if room == nil {
+		if queued := rtcCtx.queuedRoom(params); queued != nil {
+			s.leaveQueue(rtcCtx, queued)
+			//nolint:nilnil
+			return nil, nil
+		}
 		return nil, jsonrpc.ErrInvalidRequest("not joined yet")
 	}
 
+	// the connection stays open for the other rooms it joined
+	if len(rtcCtx.joinedRooms()) > 1 {
+		s.leaveRoom(rtcCtx, room)
+		s.updateUserStatus(rtcCtx.reqCtx, room.roomID, rtcCtx.userID, constants.AnchorStatusLeft)
+		//nolint:nilnil
+		return nil, nil
+	}
+
 	// remove in advanced
 	s.clientManager.RemoveClient(rtcCtx.connID)
 	if err := mctx.Peer().Close(); err != nil {
@@ -197,56 +584,143 @@ func (s *Server) handleLeave(mctx jsonrpc.MethodContext[rtcContext], _ *json.Raw
 	}
 
 	ctx := rtcCtx.reqCtx
-	s.updateUserStatus(ctx, rtcCtx.roomID, rtcCtx.userID, constants.AnchorStatusLeft)
+	s.updateUserStatus(ctx, room.roomID, rtcCtx.userID, constants.AnchorStatusLeft)
 
 	//nolint:nilnil
 	return nil, nil
 }
 
+// leaveRoom releases the Janus handle of the room, the connection leaves rooms other than the one
+// of its token
+func (s *Server) leaveRoom(rtcCtx *rtcContext, room *roomContext) {
+	ctx := rtcCtx.reqCtx
+	if room.janus != nil {
+		if err := room.janus.Destroy(ctx); err != nil {
+			s.logger.Error("Failed to release Janus handle",
+				log.String("roomId", room.roomID),
+				log.String("userId", rtcCtx.userID),
+				log.Error(err))
+		}
+	}
+	room.janus = nil
+	room.joined = false
+	room.group = ""
+	joinsActive.Add(ctx, -1)
+
+	if room.roomID != rtcCtx.roomID {
+		rtcCtx.removeRoom(room.roomID)
+		s.clientManager.RemoveClientRoom(rtcCtx.connID, room.roomID)
+	}
+}
+
 func (s *Server) handleOffer(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
 	rtcCtx := mctx.Get()
-	if !rtcCtx.joined {
+	room := rtcCtx.joinedRoom(params)
+	if room == nil {
 		return nil, jsonrpc.ErrInvalidRequest("not joined yet")
 	}
 
 	// TODO: check room exists and is ONAIR
-	var data struct {
-		SDP *janus.JSEP `json:"sdp" validate:"required"`
-	}
+	var data offerParams
 	if err := jsonrpc.ShouldBindParams(params, &data); err != nil {
 		return nil, jsonrpc.ErrInvalidParams("invalid offer parameters")
 	}
-	// TODO: validate
 	if data.SDP == nil {
 		return nil, jsonrpc.ErrInvalidParams("missing SDP")
 	}
+	if err := sanitizeOffer(data.SDP); err != nil {
+		return nil, err
+	}
 
-	janusRoomID := s.janusProxy.GetJanusRoomID(rtcCtx.roomID)
+	janusRoomID := s.janusProxy.GetJanusRoomID(room.roomID)
 	if janusRoomID == 0 {
-		s.logger.Error("No Janus room found for this room", log.String("roomId", rtcCtx.roomID))
+		s.logger.Error("No Janus room found for this room", log.String("roomId", room.roomID))
 		return nil, jsonrpc.ErrInternal("no janus room found")
 	}
 
-	roomMeta := s.janusProxy.GetRoomMeta(rtcCtx.roomID)
+	roomMeta := s.janusProxy.GetRoomMeta(room.roomID)
 	if roomMeta == nil {
 		return nil, jsonrpc.ErrInvalidRequest("no room found")
 	}
 
 	ctx := rtcCtx.reqCtx
-	displayName := fmt.Sprintf("user-%s", rtcCtx.userID)
+	if err := s.checkPublish(ctx, rtcCtx, room, roomMeta); err != nil {
+		return nil, err
+	}
+	displayName := janus.DisplayName(rtcCtx.userID)
+
+	group := s.janusProxy.GetLinkGroup(room.roomID, rtcCtx.userID)
 
-	_, err := rtcCtx.janus.Join(ctx, janusRoomID, roomMeta.GetPin(), displayName, data.SDP)
+	_, err := room.janus.Join(ctx, janusRoomID, roomMeta.GetPin(), displayName, roomMeta.GetMaxBitrate(), group, data.SDP)
 	if err != nil {
 		s.logger.Error("Failed to join Janus room", log.Error(err))
-		return nil, jsonrpc.ErrInternal("failed to join janus room")
+		return nil, janusCallError(err, "failed to join janus room")
 	}
+	room.group = group
 
 	// 	Wait for Janus answer
-	jsep, err := s.eventLoop(ctx, rtcCtx.janus)
+	jsep, err := s.eventLoop(ctx, room.janus)
 	if err != nil {
 		s.logger.Error("Failed get janus events", log.Error(err))
 		return nil, jsonrpc.ErrInternal("fail to get janus events")
 	}
+	jsep, err = prepareAnswer(jsep, roomMeta.GetAudio())
+	if err != nil {
+		s.logger.Error("Invalid Janus answer", log.Error(err))
+		return nil, jsonrpc.ErrInternal("invalid janus answer")
+	}
+	if room.frozen {
+		if err := room.janus.SetMuted(ctx, true); err != nil {
+			s.logger.Error("Failed to mute anchor of frozen room", log.String("roomId", room.roomID), log.Error(err))
+		}
+	}
+
+	return map[string]any{
+		"sdp": jsep,
+	}, nil
+}
+
+// handleIceRestart renegotiates the PeerConnection of the anchor with an ICE restart offer,
+// recovering media in a round trip instead of joining the room again
+func (s *Server) handleIceRestart(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+	room := rtcCtx.joinedRoom(params)
+	if room == nil {
+		return nil, jsonrpc.ErrInvalidRequest("not joined yet")
+	}
+	// nothing to restart before the first offer, or once the handle was released
+	if room.janus == nil || room.group == "" {
+		return nil, jsonrpc.ErrInvalidRequest("no media session, send an offer")
+	}
+
+	var data offerParams
+	if err := jsonrpc.ShouldBindParams(params, &data); err != nil {
+		return nil, jsonrpc.ErrInvalidParams("invalid offer parameters")
+	}
+	if data.SDP == nil {
+		return nil, jsonrpc.ErrInvalidParams("missing SDP")
+	}
+	if err := sanitizeOffer(data.SDP); err != nil {
+		return nil, err
+	}
+
+	ctx := rtcCtx.reqCtx
+	iceRestarts.Add(ctx, 1)
+	if _, err := room.janus.IceRestart(ctx, data.SDP); err != nil {
+		iceRestartsFailed.Add(ctx, 1)
+		s.logger.Error("Failed to restart ICE", log.String("roomId", room.roomID), log.Error(err))
+		return nil, janusCallError(err, "failed to restart ice")
+	}
+
+	jsep, err := s.eventLoop(ctx, room.janus)
+	if err == nil {
+		jsep, err = prepareAnswer(jsep, s.janusProxy.GetRoomMeta(room.roomID).GetAudio())
+	}
+	if err != nil {
+		iceRestartsFailed.Add(ctx, 1)
+		s.logger.Error("Failed to get ICE restart answer", log.String("roomId", room.roomID), log.Error(err))
+		return nil, jsonrpc.ErrInternal("failed to get ice restart answer")
+	}
 
 	return map[string]any{
 		"sdp": jsep,
@@ -270,13 +744,12 @@ func (s *Server) eventLoop(ctx context.Context, apiInst janus.Anchor) (json.RawM
 func (s *Server) handleIceCandidate(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
 	// ice candidate might called several times before answered
 	rtcCtx := mctx.Get()
-	if !rtcCtx.joined {
+	room := rtcCtx.joinedRoom(params)
+	if room == nil {
 		return nil, jsonrpc.ErrInvalidRequest("not joined yet")
 	}
 
-	var data struct {
-		Candidate *janus.ICECandidate `json:"candidate" validate:"required"`
-	}
+	var data iceCandidateParams
 	if err := jsonrpc.ShouldBindParams(params, &data); err != nil {
 		return nil, jsonrpc.ErrInvalidParams("invalid ice candidate parameters")
 	}
@@ -286,13 +759,13 @@ func (s *Server) handleIceCandidate(mctx jsonrpc.MethodContext[rtcContext], para
 	}
 
 	ctx := rtcCtx.reqCtx
-	if _, err := rtcCtx.janus.IceCandidate(ctx, *data.Candidate); err != nil {
+	if _, err := room.janus.IceCandidate(ctx, *data.Candidate); err != nil {
 		s.logger.Error("Failed exhange ice candidate", log.Error(err))
 		return nil, jsonrpc.ErrInternal("failed to exchange ice candidate")
 	}
 
 	// cause too many status updates, we skip updating status here
-	// s.updateUserStatus(ctx, rtcCtx.roomID, rtcCtx.userID, constants.AnchorStatusOnAir)
+	// s.updateUserStatus(ctx, room.roomID, rtcCtx.userID, constants.AnchorStatusOnAir)
 
 	//nolint:nilnil
 	return nil, nil
@@ -300,53 +773,93 @@ func (s *Server) handleIceCandidate(mctx jsonrpc.MethodContext[rtcContext], para
 
 func (s *Server) handleKeepAlive(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
 	rtcCtx := mctx.Get()
-	if !rtcCtx.joined {
+	room := rtcCtx.joinedRoom(params)
+	if room == nil {
+		if queued := rtcCtx.queuedRoom(params); queued != nil {
+			return s.keepQueued(mctx, queued)
+		}
 		return nil, fmt.Errorf("not joined yet")
 	}
 
-	var data struct {
-		Status constants.AnchorStatus `json:"status"`
-	}
+	var data keepAliveParams
 	if err := jsonrpc.ShouldBindParams(params, &data); err == nil && data.Status == "" {
 		data.Status = constants.AnchorStatusIdle
 	}
 	// TODO: data.status validation
 
 	ctx := rtcCtx.reqCtx
-	if err := rtcCtx.janus.KeepAlive(ctx); err != nil {
+	if err := room.janus.KeepAlive(ctx); err != nil {
 		return nil, fmt.Errorf("failed to keep Janus session alive: %w", err)
 	}
 
 	s.mustHoldLock(mctx)
-	s.updateUserStatus(ctx, rtcCtx.roomID, rtcCtx.userID, data.Status)
+	s.updateUserStatus(ctx, room.roomID, rtcCtx.userID, data.Status)
 
 	//nolint:nilnil
 	return nil, nil
 }
 
-func (*Server) restoreJanusInstance(
+func (s *Server) handleStatsReport(mctx jsonrpc.MethodContext[rtcContext], params *json.RawMessage) (any, error) {
+	rtcCtx := mctx.Get()
+	room := rtcCtx.joinedRoom(params)
+	if room == nil {
+		return nil, fmt.Errorf("not joined yet")
+	}
+
+	var data users.NetworkStats
+	if err := jsonrpc.ShouldBindParams(params, &data); err != nil {
+		return nil, jsonrpc.ErrInvalidParams("invalid stats parameters")
+	}
+
+	quality := users.QualityScore(&data)
+	if err := s.userService.SetUserQuality(rtcCtx.reqCtx, room.roomID, rtcCtx.userID, quality); err != nil {
+		s.logger.Error("Failed to update user quality",
+			log.String("roomId", room.roomID),
+			log.String("userId", rtcCtx.userID),
+			log.Error(err))
+		return nil, jsonrpc.ErrInternal("failed to report stats")
+	}
+
+	return map[string]any{"quality": quality}, nil
+}
+
+// resumeJanusInstance restores the Janus session and handle of a Janus token, nil when they
+// expired
+func (*Server) resumeJanusInstance(
 	rtcCtx *rtcContext,
 	janusAPI janus.API,
 	sessionID, handleID int64,
 ) (janus.Anchor, error) {
 	ctx := rtcCtx.reqCtx
 
+	start := time.Now()
 	apiInst, err := janusAPI.CreateAnchorInstance(ctx, rtcCtx.connID, sessionID, handleID)
+	rtcCtx.trackJanus("create", start)
 	if err != nil {
-		return nil, jsonrpc.ErrInternal("fail to create janus instance")
-	}
-	// newly created instance, no need to check
-	if sessionID == 0 {
-		return apiInst, nil
+		return nil, janusCallError(err, "fail to create janus instance")
 	}
 
 	// check existing instance
-	if ok, err := apiInst.Check(ctx); err == nil && ok {
+	start = time.Now()
+	ok, err := apiInst.Check(ctx)
+	rtcCtx.trackJanus("check", start)
+	if err == nil && ok {
 		// call successful, session is valid
 		return apiInst, nil
 	} else if errors.Is(err, janus.ErrNoneSuccessResponse) {
 		// api not success, session expired
-		return janusAPI.CreateAnchorInstance(ctx, rtcCtx.connID, 0, 0)
+		//nolint:nilnil
+		return nil, nil
+	}
+	return nil, janusCallError(err, "fail to check janus instance")
+}
+
+// createJanusInstance creates a new Janus session and handle
+func (*Server) createJanusInstance(rtcCtx *rtcContext, janusAPI janus.API) (janus.Anchor, error) {
+	defer rtcCtx.trackJanus("create", time.Now())
+	apiInst, err := janusAPI.CreateAnchorInstance(rtcCtx.reqCtx, rtcCtx.connID, 0, 0)
+	if err != nil {
+		return nil, janusCallError(err, "fail to create janus instance")
 	}
-	return nil, jsonrpc.ErrInternal("fail to check janus instance")
+	return apiInst, nil
 }
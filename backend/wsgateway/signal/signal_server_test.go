@@ -7,21 +7,36 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jonboulle/clockwork"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/mock/gomock"
 
 	"github.com/imtaco/audio-rtc-exp/internal/constants"
+	"github.com/imtaco/audio-rtc-exp/internal/errors"
 	"github.com/imtaco/audio-rtc-exp/internal/etcdstate"
 	"github.com/imtaco/audio-rtc-exp/internal/janus"
 	janusapimocks "github.com/imtaco/audio-rtc-exp/internal/janus/mocks"
 	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
 	jsonrpcmocks "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/mocks"
+	"github.com/imtaco/audio-rtc-exp/internal/jwt"
+	jwtmocks "github.com/imtaco/audio-rtc-exp/internal/jwt/mocks"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
+	redisstream "github.com/imtaco/audio-rtc-exp/internal/stream/redis"
 	usersmocks "github.com/imtaco/audio-rtc-exp/users/mocks"
 	wsgymocks "github.com/imtaco/audio-rtc-exp/wsgateway/mocks"
 )
 
+const (
+	testOfferSDP = "v=0\r\no=- 1 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+		"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\na=mid:0\r\na=rtpmap:111 opus/48000/2\r\n"
+	testAnswerSDP = "v=0\r\no=- 3 4 IN IP4 127.0.0.1\r\ns=Janus\r\nt=0 0\r\n" +
+		"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\na=mid:0\r\na=rtpmap:111 opus/48000/2\r\n"
+)
+
 type mockMethodCtx struct {
 	rtcCtx *rtcContext
 	peer   jsonrpc.Conn[rtcContext]
@@ -39,6 +54,18 @@ func (m *mockMethodCtx) Peer() jsonrpc.Conn[rtcContext] {
 	return m.peer
 }
 
+// inRoom sets the state of the connection in its token room, as OnVerify and join do
+func inRoom(rtcCtx *rtcContext, room *roomContext) *rtcContext {
+	room.roomID = rtcCtx.roomID
+	rtcCtx.addRoom(room)
+	return rtcCtx
+}
+
+// tokenRoom returns the state of the connection in its token room
+func (c *rtcContext) tokenRoom() *roomContext {
+	return c.room(c.roomID)
+}
+
 type mockPeer struct {
 	closeFunc   func() error
 	notifyFunc  func(ctx context.Context, method string, params any) error
@@ -60,6 +87,10 @@ func (m *mockPeer) Notify(ctx context.Context, method string, params any) error
 	return nil
 }
 
+func (m *mockPeer) Dispatch(_ context.Context, _ string, _ any) error {
+	return nil
+}
+
 func (m *mockPeer) Close() error {
 	if m.closeFunc != nil {
 		return m.closeFunc()
@@ -82,6 +113,8 @@ type ServerSuite struct {
 	janusTokenCodec *wsgymocks.MockJanusTokenCodec
 	userService     *usersmocks.MockUserService
 	connGuard       *MockConnectionGuard
+	miniRedis       *miniredis.Miniredis
+	pinGuard        PinGuard
 	core            *jsonrpcmocks.MockCore[rtcContext]
 	clientManager   *WSConnManager
 	server          *Server
@@ -107,9 +140,20 @@ func (s *ServerSuite) SetupTest() {
 	s.connGuard = NewMockConnectionGuard(s.ctrl)
 	s.core = jsonrpcmocks.NewMockCore[rtcContext](s.ctrl)
 
+	mr, err := miniredis.Run()
+	s.Require().NoError(err)
+	s.miniRedis = mr
+	s.pinGuard = NewPinGuard(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "test", &PinThrottleConfig{
+		ConnAttempts: 2,
+		UserAttempts: 5,
+		Window:       time.Minute,
+		Lockout:      time.Minute,
+	}, s.logger)
+
 	s.clientManager = &WSConnManager{
 		room2clients: make(map[string]map[string]jsonrpc.Conn[rtcContext]),
-		client2room:  make(map[string]string),
+		client2rooms: make(map[string]map[string]struct{}),
+		joins:        newJoinRate(clockwork.NewRealClock()),
 		logger:       s.logger,
 	}
 
@@ -120,7 +164,15 @@ func (s *ServerSuite) SetupTest() {
 		s.clientManager,
 		s.userService,
 		s.connGuard,
+		s.pinGuard,
+		nil,
+		nil,
+		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		&ReconnectConfig{BaseBackoff: time.Second, MaxBackoff: 4 * time.Second, Attempts: 4, DrainSpread: 5 * time.Second},
 		s.logger,
 	)
 
@@ -209,7 +261,7 @@ func (s *ServerSuite) SetupTest() {
 					"janus": "event",
 					"jsep": map[string]any{
 						"type": "answer",
-						"sdp":  "mock-sdp",
+						"sdp":  testAnswerSDP,
 					},
 				},
 			})
@@ -227,15 +279,15 @@ func (s *ServerSuite) SetupTest() {
 
 func (s *ServerSuite) TearDownTest() {
 	s.janusServer.Close()
+	s.miniRedis.Close()
 	s.ctrl.Finish()
 }
 
 func (s *ServerSuite) TestHandleJoin_AlreadyJoined() {
 	ctx := context.Background()
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
-		joined: true,
-	}
+	}, &roomContext{joined: true})
 
 	mctx := &mockMethodCtx{
 		rtcCtx: rtcCtx,
@@ -246,15 +298,28 @@ func (s *ServerSuite) TestHandleJoin_AlreadyJoined() {
 	s.Nil(result)
 }
 
+func (s *ServerSuite) TestHandleJoin_DuplicateLogin() {
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx:    context.Background(),
+		duplicate: &closeHint{Reason: CloseReasonReplaced, Policy: DuplicateKickOld},
+	}, &roomContext{})
+
+	result, err := s.server.handleJoin(&mockMethodCtx{rtcCtx: rtcCtx}, nil)
+	s.Nil(result)
+	rpcErr, ok := errors.As[*jsonrpc.Error](err)
+	s.Require().True(ok)
+	s.Equal(int64(codeDuplicateLogin), rpcErr.Code)
+	s.JSONEq(`{"reason":"replaced","reconnect":false,"policy":"kick_old"}`, string(*rpcErr.Data))
+}
+
 func (s *ServerSuite) TestHandleJoin_InvalidPin() {
 	ctx := context.Background()
 	roomID := "room1"
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{
 		rtcCtx: rtcCtx,
@@ -278,16 +343,65 @@ func (s *ServerSuite) TestHandleJoin_InvalidPin() {
 	s.Contains(err.Error(), "invalid room pin")
 }
 
-func (s *ServerSuite) TestHandleJoin_RoomNotOnAir() {
+func (s *ServerSuite) TestHandleJoin_PinLockout() {
 	ctx := context.Background()
 	roomID := "room1"
 
-	rtcCtx := &rtcContext{
+	peer2ws := jsonrpcmocks.NewMockPeer[any](s.ctrl)
+	s.clientManager.peer2ws = peer2ws
+
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
+		connID: "conn1",
+		userID: "user1",
 		roomID: roomID,
-		joined: false,
+	}, &roomContext{})
+	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
+
+	join := func(pin string) error {
+		params, _ := json.Marshal(map[string]string{
+			"pin":      pin,
+			"clientId": "550e8400-e29b-41d4-a716-446655440000",
+		})
+		rawParams := json.RawMessage(params)
+		_, err := s.server.handleJoin(mctx, &rawParams)
+		return err
 	}
 
+	s.janusProxy.EXPECT().GetRoomMeta(roomID).Return(&etcdstate.Meta{Pin: "123456"}).Times(3)
+	s.janusProxy.EXPECT().GetRoomLiveMeta(roomID).Return(&etcdstate.LiveMeta{
+		Status: constants.RoomStatusOnAir,
+	}).Times(3)
+
+	s.Require().ErrorContains(join("000000"), "invalid room pin")
+
+	// second failure reaches the connection limit and notifies the hosts
+	peer2ws.EXPECT().
+		Notify(gomock.Any(), "notifyModerators", &moderatorNotify{
+			RoomID: roomID,
+			Method: "pin_attempts_exceeded",
+			Params: &pinAttemptsExceeded{RoomID: roomID, UserID: "user1", LockoutSecs: 60},
+		}).
+		Return(nil)
+	s.Require().ErrorContains(join("111111"), "invalid room pin")
+
+	// even the right PIN is rejected during the lockout
+	err := join("123456")
+	rpcErr, ok := errors.As[*jsonrpc.Error](err)
+	s.Require().True(ok)
+	s.Equal(int64(codePinLocked), rpcErr.Code)
+	s.False(rtcCtx.tokenRoom().joined)
+}
+
+func (s *ServerSuite) TestHandleJoin_RoomNotOnAir() {
+	ctx := context.Background()
+	roomID := "room1"
+
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: ctx,
+		roomID: roomID,
+	}, &roomContext{})
+
 	mctx := &mockMethodCtx{
 		rtcCtx: rtcCtx,
 	}
@@ -312,11 +426,10 @@ func (s *ServerSuite) TestHandleJoin_NoJanusAPI() {
 	ctx := context.Background()
 	roomID := "room1"
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{
 		rtcCtx: rtcCtx,
@@ -341,10 +454,9 @@ func (s *ServerSuite) TestHandleJoin_NoJanusAPI() {
 
 func (s *ServerSuite) TestHandleLeave_NotJoined() {
 	ctx := context.Background()
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{
 		rtcCtx: rtcCtx,
@@ -361,13 +473,12 @@ func (s *ServerSuite) TestHandleLeave_Success() {
 	userID := "user1"
 	connID := "conn1"
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
 		userID: userID,
 		connID: connID,
-		joined: true,
-	}
+	}, &roomContext{joined: true})
 
 	peerClosed := false
 	peer := &mockPeer{
@@ -391,16 +502,49 @@ func (s *ServerSuite) TestHandleLeave_Success() {
 	s.Nil(result)
 	s.True(peerClosed)
 
-	_, exists := s.clientManager.client2room[connID]
+	_, exists := s.clientManager.client2rooms[connID]
 	s.False(exists)
 }
 
+func (s *ServerSuite) TestHandleLeave_OneOfRooms() {
+	ctx := context.Background()
+	anchor := janusapimocks.NewMockAnchor(s.ctrl)
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: ctx,
+		roomID: "room1",
+		userID: "user1",
+		connID: "conn1",
+	}, &roomContext{joined: true})
+	rtcCtx.addRoom(&roomContext{roomID: "room2", role: constants.UserRoleHost, joined: true, janus: anchor})
+
+	peer := &mockPeer{closeFunc: func() error {
+		s.Fail("connection closed")
+		return nil
+	}}
+	mctx := &mockMethodCtx{rtcCtx: rtcCtx, peer: peer}
+	s.clientManager.AddClient("conn1", "room1", peer)
+	s.clientManager.AddClient("conn1", "room2", peer)
+
+	anchor.EXPECT().Destroy(gomock.Any()).Return(nil)
+	s.userService.EXPECT().SetUserStatus(gomock.Any(), "room2", "user1", constants.AnchorStatusLeft, int32(GEN)).Return(nil)
+
+	params := json.RawMessage(`{"roomId":"room2"}`)
+	_, err := s.server.handleLeave(mctx, &params)
+	s.Require().NoError(err)
+
+	s.Nil(rtcCtx.room("room2"))
+	s.True(rtcCtx.tokenRoom().joined)
+	s.Equal(map[string]struct{}{"room1": {}}, s.clientManager.client2rooms["conn1"])
+
+	_, err = s.server.handleLeave(mctx, &params)
+	s.Require().Error(err)
+}
+
 func (s *ServerSuite) TestHandleIceCandidate_NotJoined() {
 	ctx := context.Background()
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{
 		rtcCtx: rtcCtx,
@@ -462,12 +606,35 @@ func (s *ServerSuite) TestUpdateUserStatus() {
 func (s *ServerSuite) TestOpen() {
 	ctx := context.Background()
 
+	s.core.EXPECT().Use(gomock.Any(), gomock.Any(), gomock.Any())
 	s.core.EXPECT().Def("join", gomock.Any())
 	s.core.EXPECT().Def("leave", gomock.Any())
 	s.core.EXPECT().Def("offer", gomock.Any())
+	s.core.EXPECT().Def("iceRestart", gomock.Any())
 	s.core.EXPECT().Def("icecandidate", gomock.Any())
 	s.core.EXPECT().Def("keepalive", gomock.Any())
 	s.core.EXPECT().Def("status", gomock.Any())
+	s.core.EXPECT().Def("stats.report", gomock.Any())
+	s.core.EXPECT().Def("clientError", gomock.Any())
+	s.core.EXPECT().Def("raiseHand", gomock.Any())
+	s.core.EXPECT().Def("lowerHand", gomock.Any())
+	s.core.EXPECT().Def("grantFloor", gomock.Any())
+	s.core.EXPECT().Def("requestPublish", gomock.Any())
+	s.core.EXPECT().Def("grantPublish", gomock.Any())
+	s.core.EXPECT().Def("revokePublish", gomock.Any())
+	s.core.EXPECT().Def("endRoom", gomock.Any())
+	s.core.EXPECT().Def("freezeRoom", gomock.Any())
+	s.core.EXPECT().Def("unfreezeRoom", gomock.Any())
+	s.core.EXPECT().DefLocal("floor.unmute", gomock.Any())
+	s.core.EXPECT().DefLocal("publish.change", gomock.Any())
+	s.core.EXPECT().DefLocal("queue.change", gomock.Any())
+	s.core.EXPECT().DefLocal("link.regroup", gomock.Any())
+	s.core.EXPECT().DefLocal("user.evicted", gomock.Any())
+	s.core.EXPECT().DefLocal("conn.replaced", gomock.Any())
+	s.core.EXPECT().DefLocal("room.ending", gomock.Any())
+	s.core.EXPECT().DefLocal("room.freeze", gomock.Any())
+	s.core.EXPECT().EnableReplay(nil, "join", "leave", "offer", "iceRestart", "grantFloor", "grantPublish", "endRoom", "freezeRoom", "unfreezeRoom")
+	s.janusProxy.EXPECT().OnRoomChange(gomock.Any())
 	s.connGuard.EXPECT().Start(gomock.Any()).Return(nil)
 
 	err := s.server.Open(ctx)
@@ -479,13 +646,12 @@ func (s *ServerSuite) TestHandleJoin_Success() {
 	roomID := "room1"
 	nonce := "test-nonce"
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
 		userID: "user1",
 		connID: "conn1",
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -521,8 +687,8 @@ func (s *ServerSuite) TestHandleJoin_Success() {
 	res, err := s.server.handleJoin(mctx, &rawParams)
 	s.Require().NoError(err)
 	s.NotNil(res)
-	s.True(rtcCtx.joined)
-	s.NotNil(rtcCtx.janus)
+	s.True(rtcCtx.tokenRoom().joined)
+	s.NotNil(rtcCtx.tokenRoom().janus)
 
 	// Verify response contains jtoken and resume flag
 	resMap, ok := res.(map[string]any)
@@ -531,6 +697,84 @@ func (s *ServerSuite) TestHandleJoin_Success() {
 	s.Contains(resMap, "resume")
 	s.Equal("encoded-token", resMap["jtoken"])
 	s.Equal(false, resMap["resume"]) // New session, so resume should be false
+	s.Equal(redisstream.RouteKey(roomID), resMap["route"])
+}
+
+func (s *ServerSuite) TestHandleJoin_OtherRoom() {
+	ctx := context.Background()
+	jwtAuth := jwtmocks.NewMockAuth(s.ctrl)
+	s.server.jwtAuth = jwtAuth
+
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: ctx,
+		roomID: "room1",
+		userID: "user1",
+		connID: "conn1",
+	}, &roomContext{role: constants.UserRoleAnchor})
+	peer := &mockPeer{}
+	mctx := &mockMethodCtx{rtcCtx: rtcCtx, peer: peer}
+	s.clientManager.AddClient("conn1", "room1", peer)
+
+	join := func(token string) error {
+		params, _ := json.Marshal(map[string]any{
+			"roomId":   "room2",
+			"token":    token,
+			"clientId": "550e8400-e29b-41d4-a716-446655440000",
+		})
+		rawParams := json.RawMessage(params)
+		_, err := s.server.handleJoin(mctx, &rawParams)
+		return err
+	}
+
+	s.Run("needs a token of the room", func() {
+		s.Require().Error(join(""))
+
+		jwtAuth.EXPECT().Verify("other-user").Return(&jwt.Payload{
+			UserID: "user2",
+			RoomID: "room2",
+			Role:   constants.UserRoleHost,
+		}, nil)
+		s.Require().Error(join("other-user"))
+
+		jwtAuth.EXPECT().Verify("other-room").Return(&jwt.Payload{
+			UserID: "user1",
+			RoomID: "room3",
+			Role:   constants.UserRoleHost,
+		}, nil)
+		s.Require().Error(join("other-room"))
+		s.Nil(rtcCtx.room("room2"))
+	})
+
+	s.Run("joins with its own anchor and role", func() {
+		jwtAuth.EXPECT().Verify("room2-token").Return(&jwt.Payload{
+			UserID: "user1",
+			RoomID: "room2",
+			Role:   constants.UserRoleHost,
+		}, nil)
+		s.janusProxy.EXPECT().GetRoomMeta("room2").Return(&etcdstate.Meta{})
+		s.janusProxy.EXPECT().GetRoomLiveMeta("room2").Return(&etcdstate.LiveMeta{
+			Status: constants.RoomStatusOnAir,
+			Nonce:  "nonce2",
+		})
+		s.janusProxy.EXPECT().GetJanusAPI("room2").Return(s.janusAPI)
+		anchor := janusapimocks.NewMockAnchor(s.ctrl)
+		anchor.EXPECT().GetSessionID().Return(int64(123)).AnyTimes()
+		anchor.EXPECT().GetHandleID().Return(int64(456)).AnyTimes()
+		s.janusAPI.EXPECT().CreateAnchorInstance(gomock.Any(), "conn1", int64(0), int64(0)).Return(anchor, nil)
+		s.janusTokenCodec.EXPECT().Encode("nonce2", int64(123), int64(456)).Return("encoded-token", nil)
+		s.userService.EXPECT().SetUserStatus(gomock.Any(), "room2", "user1", constants.AnchorStatusIdle, gomock.Any()).Return(nil)
+
+		s.Require().NoError(join("room2-token"))
+
+		room := rtcCtx.room("room2")
+		s.Require().NotNil(room)
+		s.True(room.joined)
+		s.NotNil(room.janus)
+		s.Equal(constants.UserRoleHost, rtcCtx.roleIn("room2"))
+		s.Equal(constants.UserRoleAnchor, rtcCtx.roleIn("room1"))
+		s.False(rtcCtx.tokenRoom().joined)
+		s.Len(s.clientManager.client2rooms["conn1"], 2)
+	})
 }
 
 func (s *ServerSuite) TestHandleJoin_WithInvalidToken() {
@@ -538,13 +782,12 @@ func (s *ServerSuite) TestHandleJoin_WithInvalidToken() {
 	roomID := "room1"
 	nonce := "test-nonce"
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
 		userID: "user1",
 		connID: "conn1",
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -582,7 +825,7 @@ func (s *ServerSuite) TestHandleJoin_WithInvalidToken() {
 	res, err := s.server.handleJoin(mctx, &rawParams)
 	s.Require().NoError(err)
 	s.NotNil(res)
-	s.True(rtcCtx.joined)
+	s.True(rtcCtx.tokenRoom().joined)
 
 	resMap, ok := res.(map[string]any)
 	s.True(ok)
@@ -595,13 +838,12 @@ func (s *ServerSuite) TestHandleJoin_WithValidTokenButExpiredSession() {
 	roomID := "room1"
 	nonce := "test-nonce"
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
 		userID: "user1",
 		connID: "conn1",
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -692,13 +934,12 @@ func (s *ServerSuite) TestHandleJoin_WithValidTokenAndActiveSession() {
 	validSessionID := int64(123)
 	validHandleID := int64(456)
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
 		userID: "user1",
 		connID: "conn1",
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -751,13 +992,12 @@ func (s *ServerSuite) TestHandleJoin_CheckFailsWithHTTPError() {
 	roomID := "room1"
 	nonce := "test-nonce"
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
 		userID: "user1",
 		connID: "conn1",
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -844,13 +1084,12 @@ func (s *ServerSuite) TestHandleJoin_CheckFailsWithUnexpectedError() {
 	validSessionID := int64(123)
 	validHandleID := int64(456)
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
 		userID: "user1",
 		connID: "conn1",
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -887,16 +1126,47 @@ func (s *ServerSuite) TestHandleJoin_CheckFailsWithUnexpectedError() {
 	res, err := s.server.handleJoin(mctx, &rawParams)
 	s.Require().Error(err) // Should return error
 	s.Nil(res)
-	s.False(rtcCtx.joined) // Should not be joined
+	s.False(rtcCtx.tokenRoom().joined) // Should not be joined
+}
+
+func (s *ServerSuite) TestHandleJoin_JanusCircuitOpen() {
+	roomID := "room1"
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: context.Background(),
+		roomID: roomID,
+		userID: "user1",
+		connID: "conn1",
+	}, &roomContext{})
+	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
+
+	params, _ := json.Marshal(map[string]any{
+		"clientId": "550e8400-e29b-41d4-a716-446655440005",
+	})
+	rawParams := json.RawMessage(params)
+
+	s.janusProxy.EXPECT().GetRoomMeta(roomID).Return(&etcdstate.Meta{MaxAnchors: 5})
+	s.janusProxy.EXPECT().GetRoomLiveMeta(roomID).Return(&etcdstate.LiveMeta{
+		Status: constants.RoomStatusOnAir,
+		Nonce:  "test-nonce",
+	})
+	s.janusProxy.EXPECT().GetJanusAPI(roomID).Return(s.janusAPI)
+	s.janusAPI.EXPECT().CreateAnchorInstance(gomock.Any(), "conn1", int64(0), int64(0)).
+		Return(nil, errors.Newf(janus.ErrCircuitOpen, "createAnchor on janus %s", "janus-1"))
+
+	res, err := s.server.handleJoin(mctx, &rawParams)
+	s.Nil(res)
+	rpcErr, ok := errors.As[*jsonrpc.Error](err)
+	s.Require().True(ok)
+	s.Equal(int64(codeJanusUnavailable), rpcErr.Code)
+	s.False(rtcCtx.tokenRoom().joined)
 }
 
 func (s *ServerSuite) TestHandleJoin_InvalidParams() {
 	ctx := context.Background()
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: "room1",
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -914,13 +1184,12 @@ func (s *ServerSuite) TestHandleJoin_EncryptFailure() {
 	roomID := "room1"
 	nonce := "test-nonce"
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
 		userID: "user1",
 		connID: "conn1",
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -957,23 +1226,21 @@ func (s *ServerSuite) TestHandleJoin_EncryptFailure() {
 func (s *ServerSuite) TestHandleOffer_Success() {
 	// Setup context
 	ctx := context.Background()
-	roomID := "room1"
+	roomID := "room2"
 
 	inst, err := s.realJanusAPI.CreateAnchorInstance(ctx, "client1", 0, 0)
 	s.Require().NoError(err)
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
 		userID: "user1",
-		joined: true,
-		janus:  inst,
-	}
+	}, &roomContext{joined: true, janus: inst})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
 	// Params
-	sdp := janus.JSEP{Type: "offer", SDP: "offer-sdp"}
+	sdp := janus.JSEP{Type: "offer", SDP: testOfferSDP}
 	params, _ := json.Marshal(map[string]any{
 		"sdp": sdp,
 	})
@@ -982,6 +1249,7 @@ func (s *ServerSuite) TestHandleOffer_Success() {
 	// Expectations
 	s.janusProxy.EXPECT().GetJanusRoomID(roomID).Return(int64(1234))
 	s.janusProxy.EXPECT().GetRoomMeta(roomID).Return(&etcdstate.Meta{Pin: "123", MaxAnchors: 5})
+	s.janusProxy.EXPECT().GetLinkGroup(roomID, "user1").Return(janus.GroupRoom)
 
 	// Execute
 	res, err := s.server.handleOffer(mctx, &rawParams)
@@ -993,41 +1261,104 @@ func (s *ServerSuite) TestHandleOffer_Success() {
 	s.Contains(resMap, "sdp")
 }
 
-func (s *ServerSuite) TestHandleOffer_JanusError() {
+func (s *ServerSuite) TestHandleOffer_PassesRoomBitrate() {
+	ctx := context.Background()
+	roomID := "room1"
+
+	mockAnchor := janusapimocks.NewMockAnchor(s.ctrl)
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: ctx,
+		roomID: roomID,
+		userID: "user1",
+	}, &roomContext{joined: true, janus: mockAnchor})
+	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
+
+	sdp := janus.JSEP{Type: "offer", SDP: testOfferSDP}
+	params, _ := json.Marshal(map[string]any{
+		"sdp": sdp,
+	})
+	rawParams := json.RawMessage(params)
+
+	answer, _ := json.Marshal(janus.JSEP{Type: "answer", SDP: testAnswerSDP})
+	s.janusProxy.EXPECT().GetJanusRoomID(roomID).Return(int64(1234))
+	s.janusProxy.EXPECT().GetRoomMeta(roomID).Return(&etcdstate.Meta{Pin: "123", MaxAnchors: 5, MaxBitrate: 32000})
+	s.janusProxy.EXPECT().GetLinkGroup(roomID, "user1").Return(janus.GroupRoom)
+	mockAnchor.EXPECT().Join(ctx, int64(1234), "123", "user-user1", 32000, janus.GroupRoom, &sdp).Return(&janus.Response{Janus: "ack"}, nil)
+	mockAnchor.EXPECT().GetEvents(ctx, 10).Return([]*janus.Response{{Janus: "event", JSEP: (*json.RawMessage)(&answer)}}, nil)
+
+	res, err := s.server.handleOffer(mctx, &rawParams)
+	s.Require().NoError(err)
+	s.Equal(map[string]any{"sdp": json.RawMessage(answer)}, res)
+}
+
+func (s *ServerSuite) TestHandleOffer_AppliesRoomAudio() {
 	ctx := context.Background()
 	roomID := "room1"
 
+	mockAnchor := janusapimocks.NewMockAnchor(s.ctrl)
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: ctx,
+		roomID: roomID,
+		userID: "user1",
+	}, &roomContext{joined: true, janus: mockAnchor})
+	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
+
+	params, _ := json.Marshal(map[string]any{
+		"sdp": janus.JSEP{Type: "offer", SDP: testOfferSDP},
+	})
+	rawParams := json.RawMessage(params)
+
+	on := true
+	answer, _ := json.Marshal(janus.JSEP{Type: "answer", SDP: testAnswerSDP})
+	s.janusProxy.EXPECT().GetJanusRoomID(roomID).Return(int64(1234))
+	s.janusProxy.EXPECT().GetRoomMeta(roomID).Return(&etcdstate.Meta{
+		Pin:   "123",
+		Audio: &etcdstate.AudioParams{FEC: &on, DTX: &on, MaxAverageBitrate: 24000},
+	})
+	s.janusProxy.EXPECT().GetLinkGroup(roomID, "user1").Return(janus.GroupRoom)
+	mockAnchor.EXPECT().Join(ctx, int64(1234), "123", "user-user1", 0, janus.GroupRoom, gomock.Any()).Return(&janus.Response{Janus: "ack"}, nil)
+	mockAnchor.EXPECT().GetEvents(ctx, 10).Return([]*janus.Response{{Janus: "event", JSEP: (*json.RawMessage)(&answer)}}, nil)
+
+	res, err := s.server.handleOffer(mctx, &rawParams)
+	s.Require().NoError(err)
+
+	var jsep janus.JSEP
+	s.Require().NoError(json.Unmarshal(res.(map[string]any)["sdp"].(json.RawMessage), &jsep))
+	s.Equal("answer", jsep.Type)
+	s.Contains(jsep.SDP, "a=fmtp:111 useinbandfec=1;usedtx=1;maxaveragebitrate=24000\r\n")
+}
+
+func (s *ServerSuite) TestHandleOffer_JanusError() {
+	ctx := context.Background()
+	roomID := "room2"
+
 	inst, err := s.realJanusAPI.CreateAnchorInstance(ctx, "client1", 0, 0)
 	s.Require().NoError(err)
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
 		userID: "user1",
-		joined: true,
-		janus:  inst,
-	}
+	}, &roomContext{joined: true, janus: inst})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
-	sdp := janus.JSEP{Type: "offer", SDP: "offer-sdp"}
+	sdp := janus.JSEP{Type: "offer", SDP: testOfferSDP}
 	params, _ := json.Marshal(map[string]any{
 		"sdp": sdp,
 	})
 	rawParams := json.RawMessage(params)
 
 	s.janusProxy.EXPECT().GetJanusRoomID("room2").Return(int64(0))
-	rtcCtx.roomID = "room2"
 	_, err = s.server.handleOffer(mctx, &rawParams)
 	s.Require().Error(err)
 }
 
 func (s *ServerSuite) TestHandleOffer_NotJoined() {
 	ctx := context.Background()
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -1039,10 +1370,9 @@ func (s *ServerSuite) TestHandleOffer_NotJoined() {
 
 func (s *ServerSuite) TestHandleOffer_InvalidParams() {
 	ctx := context.Background()
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
-		joined: true,
-	}
+	}, &roomContext{joined: true})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -1057,10 +1387,9 @@ func (s *ServerSuite) TestHandleOffer_InvalidParams() {
 
 func (s *ServerSuite) TestHandleOffer_MissingSDP() {
 	ctx := context.Background()
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
-		joined: true,
-	}
+	}, &roomContext{joined: true})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -1073,24 +1402,58 @@ func (s *ServerSuite) TestHandleOffer_MissingSDP() {
 	s.Contains(err.Error(), "invalid offer parameters")
 }
 
+func (s *ServerSuite) TestHandleOffer_InvalidSDP() {
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: context.Background(),
+	}, &roomContext{joined: true})
+	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
+
+	params, _ := json.Marshal(map[string]any{
+		"sdp": janus.JSEP{Type: "offer", SDP: "v=0\r\no=- 1 2 IN IP4 127.0.0.1\r\nm=video 9 RTP/SAVPF 96\r\n"},
+	})
+	rawParams := json.RawMessage(params)
+
+	res, err := s.server.handleOffer(mctx, &rawParams)
+	s.Nil(res)
+	rpcErr, ok := errors.As[*jsonrpc.Error](err)
+	s.Require().True(ok)
+	s.Equal(int64(codeInvalidSDP), rpcErr.Code)
+	s.Require().NotNil(rpcErr.Data)
+	s.JSONEq(`{"reason":"no opus audio m= line"}`, string(*rpcErr.Data))
+}
+
+func (s *ServerSuite) TestHandleOffer_NotOfferType() {
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: context.Background(),
+	}, &roomContext{joined: true})
+	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
+
+	params, _ := json.Marshal(map[string]any{
+		"sdp": janus.JSEP{Type: "answer", SDP: testOfferSDP},
+	})
+	rawParams := json.RawMessage(params)
+
+	_, err := s.server.handleOffer(mctx, &rawParams)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "SDP type must be offer")
+}
+
 func (s *ServerSuite) TestHandleOffer_NoRoomMeta() {
 	ctx := context.Background()
-	roomID := "room1"
+	roomID := "room2"
 
 	inst, err := s.realJanusAPI.CreateAnchorInstance(ctx, "client1", 0, 0)
 	s.Require().NoError(err)
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
 		userID: "user1",
-		joined: true,
-		janus:  inst,
-	}
+	}, &roomContext{joined: true, janus: inst})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
-	sdp := janus.JSEP{Type: "offer", SDP: "offer-sdp"}
+	sdp := janus.JSEP{Type: "offer", SDP: testOfferSDP}
 	params, _ := json.Marshal(map[string]any{
 		"sdp": sdp,
 	})
@@ -1105,20 +1468,91 @@ func (s *ServerSuite) TestHandleOffer_NoRoomMeta() {
 	s.Contains(err.Error(), "no room found")
 }
 
+func (s *ServerSuite) TestHandleIceRestart() {
+	offer := janus.JSEP{Type: "offer", SDP: testOfferSDP}
+	params, _ := json.Marshal(map[string]any{"sdp": offer})
+	rawParams := json.RawMessage(params)
+	answer, _ := json.Marshal(janus.JSEP{Type: "answer", SDP: testAnswerSDP})
+
+	newCtx := func(anchor janus.Anchor, group string) *mockMethodCtx {
+		return &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			reqCtx: context.Background(),
+			roomID: "room1",
+			userID: "user1",
+		}, &roomContext{joined: true, janus: anchor, group: group})}
+	}
+
+	s.Run("success", func() {
+		mockAnchor := janusapimocks.NewMockAnchor(s.ctrl)
+		mctx := newCtx(mockAnchor, janus.GroupRoom)
+		ctx := mctx.rtcCtx.reqCtx
+
+		sanitized := offer
+		s.Require().NoError(sanitizeOffer(&sanitized))
+		mockAnchor.EXPECT().IceRestart(ctx, &sanitized).Return(&janus.Response{Janus: "ack"}, nil)
+		mockAnchor.EXPECT().GetEvents(ctx, 10).Return([]*janus.Response{{Janus: "event", JSEP: (*json.RawMessage)(&answer)}}, nil)
+		s.janusProxy.EXPECT().GetRoomMeta("room1").Return(&etcdstate.Meta{})
+
+		res, err := s.server.handleIceRestart(mctx, &rawParams)
+		s.Require().NoError(err)
+		s.Equal(map[string]any{"sdp": json.RawMessage(answer)}, res)
+		s.Equal(janus.GroupRoom, mctx.rtcCtx.tokenRoom().group, "room membership kept")
+	})
+
+	s.Run("not joined", func() {
+		mctx := newCtx(nil, "")
+		mctx.rtcCtx.tokenRoom().joined = false
+		_, err := s.server.handleIceRestart(mctx, &rawParams)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "not joined yet")
+	})
+
+	s.Run("no media session", func() {
+		_, err := s.server.handleIceRestart(newCtx(janusapimocks.NewMockAnchor(s.ctrl), ""), &rawParams)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "no media session")
+	})
+
+	s.Run("invalid SDP", func() {
+		bad, _ := json.Marshal(map[string]any{"sdp": janus.JSEP{Type: "answer", SDP: testOfferSDP}})
+		rawBad := json.RawMessage(bad)
+		_, err := s.server.handleIceRestart(newCtx(janusapimocks.NewMockAnchor(s.ctrl), janus.GroupRoom), &rawBad)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "SDP type must be offer")
+	})
+
+	s.Run("janus error", func() {
+		mockAnchor := janusapimocks.NewMockAnchor(s.ctrl)
+		mockAnchor.EXPECT().IceRestart(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("handle gone"))
+
+		_, err := s.server.handleIceRestart(newCtx(mockAnchor, janus.GroupRoom), &rawParams)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "failed to restart ice")
+	})
+
+	s.Run("no answer", func() {
+		mockAnchor := janusapimocks.NewMockAnchor(s.ctrl)
+		mockAnchor.EXPECT().IceRestart(gomock.Any(), gomock.Any()).Return(&janus.Response{Janus: "ack"}, nil)
+		mockAnchor.EXPECT().GetEvents(gomock.Any(), 10).Return(nil, nil)
+
+		_, err := s.server.handleIceRestart(newCtx(mockAnchor, janus.GroupRoom), &rawParams)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "failed to get ice restart answer")
+	})
+}
+
 func (s *ServerSuite) TestHandleIceCandidate_Success() {
 	ctx := context.Background()
-	roomID := "room1"
+	roomID := "room2"
 
 	inst, err := s.realJanusAPI.CreateAnchorInstance(ctx, "client1", 0, 0)
 	s.Require().NoError(err)
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: roomID,
 		userID: "user1",
-		joined: true,
-		janus:  inst,
-	}
+	}, &roomContext{joined: true, janus: inst})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -1138,12 +1572,10 @@ func (s *ServerSuite) TestHandleIceCandidate_JanusError() {
 	inst, err := s.realJanusAPI.CreateAnchorInstance(ctx, "client1", 0, 0)
 	s.Require().NoError(err)
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: "room1",
-		joined: true,
-		janus:  inst,
-	}
+	}, &roomContext{joined: true, janus: inst})
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
 	candidate := janus.ICECandidate{Candidate: "candidate:..."}
@@ -1160,10 +1592,9 @@ func (s *ServerSuite) TestHandleIceCandidate_JanusError() {
 
 func (s *ServerSuite) TestHandleIceCandidate_InvalidParams() {
 	ctx := context.Background()
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
-		joined: true,
-	}
+	}, &roomContext{joined: true})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -1178,10 +1609,9 @@ func (s *ServerSuite) TestHandleIceCandidate_InvalidParams() {
 
 func (s *ServerSuite) TestHandleIceCandidate_MissingCandidate() {
 	ctx := context.Background()
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
-		joined: true,
-	}
+	}, &roomContext{joined: true})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -1204,15 +1634,13 @@ func (s *ServerSuite) TestHandleKeepAlive_Success() {
 	inst, err := s.realJanusAPI.CreateAnchorInstance(ctx, "client1", 0, 0)
 	s.Require().NoError(err)
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx:   ctx,
 		roomID:   roomID,
 		userID:   userID,
 		connID:   connID,
 		clientID: clientID,
-		joined:   true,
-		janus:    inst,
-	}
+	}, &roomContext{joined: true, janus: inst})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -1235,12 +1663,10 @@ func (s *ServerSuite) TestHandleKeepAlive_JanusError() {
 	inst, err := s.realJanusAPI.CreateAnchorInstance(ctx, "client1", 0, 0)
 	s.Require().NoError(err)
 
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
 		roomID: "room1",
-		joined: true,
-		janus:  inst,
-	}
+	}, &roomContext{joined: true, janus: inst})
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
 	params, _ := json.Marshal(map[string]any{})
@@ -1254,10 +1680,9 @@ func (s *ServerSuite) TestHandleKeepAlive_JanusError() {
 
 func (s *ServerSuite) TestHandleKeepAlive_NotJoined() {
 	ctx := context.Background()
-	rtcCtx := &rtcContext{
+	rtcCtx := inRoom(&rtcContext{
 		reqCtx: ctx,
-		joined: false,
-	}
+	}, &roomContext{})
 
 	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
 
@@ -1267,6 +1692,273 @@ func (s *ServerSuite) TestHandleKeepAlive_NotJoined() {
 	s.Contains(err.Error(), "not joined yet")
 }
 
+func (s *ServerSuite) TestHandleStatsReport_Success() {
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: context.Background(),
+		roomID: "room1",
+		userID: "user1",
+	}, &roomContext{joined: true})
+	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
+
+	params, _ := json.Marshal(map[string]any{
+		"rtt":        650,
+		"jitter":     10,
+		"packetLoss": 0,
+	})
+	rawParams := json.RawMessage(params)
+
+	s.userService.EXPECT().SetUserQuality(gomock.Any(), "room1", "user1", 50).Return(nil)
+
+	res, err := s.server.handleStatsReport(mctx, &rawParams)
+	s.Require().NoError(err)
+	s.Equal(map[string]any{"quality": 50}, res)
+}
+
+func (s *ServerSuite) TestHandleStatsReport_InvalidParams() {
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: context.Background(),
+	}, &roomContext{joined: true})
+	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
+
+	params, _ := json.Marshal(map[string]any{
+		"rtt":        40,
+		"packetLoss": 1.5,
+	})
+	rawParams := json.RawMessage(params)
+
+	_, err := s.server.handleStatsReport(mctx, &rawParams)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "invalid stats parameters")
+}
+
+func (s *ServerSuite) TestHandleStatsReport_NotJoined() {
+	mctx := &mockMethodCtx{rtcCtx: &rtcContext{reqCtx: context.Background()}}
+
+	_, err := s.server.handleStatsReport(mctx, nil)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "not joined yet")
+}
+
+func (s *ServerSuite) TestHandleRaiseHand() {
+	rtcCtx := inRoom(&rtcContext{
+		reqCtx: context.Background(),
+		roomID: "room1",
+		userID: "user1",
+	}, &roomContext{joined: true})
+	mctx := &mockMethodCtx{rtcCtx: rtcCtx}
+
+	s.userService.EXPECT().SetUserHand(gomock.Any(), "room1", "user1", true).Return(nil)
+
+	_, err := s.server.handleRaiseHand(mctx, nil)
+	s.Require().NoError(err)
+}
+
+func (s *ServerSuite) TestHandleRaiseHand_NotJoined() {
+	mctx := &mockMethodCtx{rtcCtx: &rtcContext{reqCtx: context.Background()}}
+
+	_, err := s.server.handleRaiseHand(mctx, nil)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "not joined yet")
+}
+
+func (s *ServerSuite) TestHandleLowerHand() {
+	s.Run("own hand", func() {
+		mctx := &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			reqCtx: context.Background(),
+			roomID: "room1",
+			userID: "user1",
+		}, &roomContext{role: constants.UserRoleAnchor, joined: true})}
+		s.userService.EXPECT().SetUserHand(gomock.Any(), "room1", "user1", false).Return(nil)
+
+		_, err := s.server.handleLowerHand(mctx, nil)
+		s.Require().NoError(err)
+	})
+
+	s.Run("others by anchor", func() {
+		mctx := &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			reqCtx: context.Background(),
+			roomID: "room1",
+			userID: "user1",
+		}, &roomContext{role: constants.UserRoleAnchor, joined: true})}
+		rawParams := json.RawMessage(`{"userId":"user2"}`)
+
+		_, err := s.server.handleLowerHand(mctx, &rawParams)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "only hosts")
+	})
+
+	s.Run("others by host", func() {
+		mctx := &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			reqCtx: context.Background(),
+			roomID: "room1",
+			userID: "host1",
+		}, &roomContext{role: constants.UserRoleHost, joined: true})}
+		rawParams := json.RawMessage(`{"userId":"user2"}`)
+		s.userService.EXPECT().SetUserHand(gomock.Any(), "room1", "user2", false).Return(nil)
+
+		_, err := s.server.handleLowerHand(mctx, &rawParams)
+		s.Require().NoError(err)
+	})
+}
+
+func (s *ServerSuite) TestHandleGrantFloor() {
+	hostCtx := func() *mockMethodCtx {
+		return &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			reqCtx: context.Background(),
+			roomID: "room1",
+			userID: "host1",
+		}, &roomContext{role: constants.UserRoleHost, joined: true})}
+	}
+
+	s.Run("not host", func() {
+		mctx := hostCtx()
+		mctx.rtcCtx.tokenRoom().role = constants.UserRoleAnchor
+
+		_, err := s.server.handleGrantFloor(mctx, nil)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "only hosts")
+	})
+
+	s.Run("next in queue", func() {
+		s.userService.EXPECT().GrantFloor(gomock.Any(), "room1", "", false).Return("user1", nil)
+
+		res, err := s.server.handleGrantFloor(hostCtx(), nil)
+		s.Require().NoError(err)
+		s.Equal(map[string]any{"userId": "user1"}, res)
+	})
+
+	s.Run("named user with unmute", func() {
+		rawParams := json.RawMessage(`{"userId":"user2","unmute":true}`)
+		s.userService.EXPECT().GrantFloor(gomock.Any(), "room1", "user2", true).Return("user2", nil)
+
+		res, err := s.server.handleGrantFloor(hostCtx(), &rawParams)
+		s.Require().NoError(err)
+		s.Equal(map[string]any{"userId": "user2"}, res)
+	})
+
+	s.Run("rejected by users service", func() {
+		s.userService.EXPECT().GrantFloor(gomock.Any(), "room1", "", false).
+			Return("", jsonrpc.ErrInvalidRequest("no raised hand"))
+
+		_, err := s.server.handleGrantFloor(hostCtx(), nil)
+		rpcErr, ok := errors.As[*jsonrpc.Error](err)
+		s.Require().True(ok)
+		s.Equal("no raised hand", rpcErr.Message)
+	})
+
+	s.Run("users service failure", func() {
+		s.userService.EXPECT().GrantFloor(gomock.Any(), "room1", "", false).Return("", fmt.Errorf("timeout"))
+
+		_, err := s.server.handleGrantFloor(hostCtx(), nil)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "failed to update speaking queue")
+	})
+}
+
+func (s *ServerSuite) TestHandleFloorUnmute() {
+	rawParams := json.RawMessage(`{"roomId":"room1","userId":"user1","unmute":true}`)
+
+	s.Run("speaker connection", func() {
+		anchor := janusapimocks.NewMockAnchor(s.ctrl)
+		mctx := &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			roomID: "room1",
+			userID: "user1",
+		}, &roomContext{janus: anchor, joined: true})}
+		anchor.EXPECT().SetMuted(gomock.Any(), false).DoAndReturn(func(ctx context.Context, _ bool) error {
+			s.NoError(ctx.Err())
+			return nil
+		})
+
+		_, err := s.server.handleFloorUnmute(mctx, &rawParams)
+		s.Require().NoError(err)
+	})
+
+	s.Run("other connection", func() {
+		anchor := janusapimocks.NewMockAnchor(s.ctrl)
+		mctx := &mockMethodCtx{rtcCtx: inRoom(&rtcContext{
+			roomID: "room1",
+			userID: "user2",
+		}, &roomContext{janus: anchor, joined: true})}
+
+		_, err := s.server.handleFloorUnmute(mctx, &rawParams)
+		s.Require().NoError(err)
+	})
+}
+
+func (s *ServerSuite) TestHandleUserEvicted() {
+	rawParams := json.RawMessage(`{"roomId":"room1","userId":"user1"}`)
+
+	s.Run("evicted connection", func() {
+		anchor := janusapimocks.NewMockAnchor(s.ctrl)
+		notified := ""
+		var params any
+		mctx := &mockMethodCtx{
+			rtcCtx: inRoom(&rtcContext{roomID: "room1", userID: "user1"}, &roomContext{janus: anchor, joined: true}),
+			peer: &mockPeer{notifyFunc: func(_ context.Context, method string, p any) error {
+				notified = method
+				params = p
+				return nil
+			}},
+		}
+		anchor.EXPECT().Destroy(gomock.Any()).Return(nil)
+
+		_, err := s.server.handleUserEvicted(mctx, &rawParams)
+		s.Require().NoError(err)
+		s.False(mctx.rtcCtx.tokenRoom().joined)
+		s.Nil(mctx.rtcCtx.tokenRoom().janus)
+		s.Equal(evictedNotification, notified)
+		s.Equal(&userEvicted{RoomID: "room1", UserID: "user1", Code: codeUserEvicted}, params)
+	})
+
+	s.Run("other connection", func() {
+		anchor := janusapimocks.NewMockAnchor(s.ctrl)
+		mctx := &mockMethodCtx{rtcCtx: inRoom(&rtcContext{roomID: "room1", userID: "user2"}, &roomContext{janus: anchor, joined: true})}
+
+		_, err := s.server.handleUserEvicted(mctx, &rawParams)
+		s.Require().NoError(err)
+		s.True(mctx.rtcCtx.tokenRoom().joined)
+	})
+}
+
+func (s *ServerSuite) TestHandleLinkRegroup() {
+	s.Run("moves forwarded anchor to link group", func() {
+		anchor := janusapimocks.NewMockAnchor(s.ctrl)
+		rtcCtx := inRoom(&rtcContext{
+			roomID: "room1",
+			userID: "user1",
+		}, &roomContext{janus: anchor, joined: true, group: janus.GroupRoom})
+		s.janusProxy.EXPECT().GetLinkGroup("room1", "user1").Return(janus.GroupLink)
+		anchor.EXPECT().SetGroup(gomock.Any(), janus.GroupLink).Return(nil)
+
+		_, err := s.server.handleLinkRegroup(&mockMethodCtx{rtcCtx: rtcCtx}, nil)
+		s.Require().NoError(err)
+		s.Equal(janus.GroupLink, rtcCtx.tokenRoom().group)
+	})
+
+	s.Run("group unchanged", func() {
+		anchor := janusapimocks.NewMockAnchor(s.ctrl)
+		rtcCtx := inRoom(&rtcContext{
+			roomID: "room1",
+			userID: "user1",
+		}, &roomContext{janus: anchor, joined: true, group: janus.GroupRoom})
+		s.janusProxy.EXPECT().GetLinkGroup("room1", "user1").Return(janus.GroupRoom)
+
+		_, err := s.server.handleLinkRegroup(&mockMethodCtx{rtcCtx: rtcCtx}, nil)
+		s.Require().NoError(err)
+	})
+
+	s.Run("not in janus room yet", func() {
+		anchor := janusapimocks.NewMockAnchor(s.ctrl)
+		rtcCtx := inRoom(&rtcContext{
+			roomID: "room1",
+			userID: "user1",
+		}, &roomContext{janus: anchor, joined: true})
+
+		_, err := s.server.handleLinkRegroup(&mockMethodCtx{rtcCtx: rtcCtx}, nil)
+		s.Require().NoError(err)
+	})
+}
+
 func (s *ServerSuite) TestUpdateUserStatus_Error() {
 	ctx := context.Background()
 
@@ -1274,3 +1966,41 @@ func (s *ServerSuite) TestUpdateUserStatus_Error() {
 
 	s.server.updateUserStatus(ctx, "room1", "user1", constants.AnchorStatusOnAir)
 }
+
+func (s *ServerSuite) TestClose_DrainsConnections() {
+	s.server.reconnect.rand = func() float64 { return 0.5 }
+
+	peer := jsonrpcmocks.NewMockPeer[rtcContext](s.ctrl)
+	s.clientManager.AddClient("conn1", "room1", peer)
+
+	s.connGuard.EXPECT().Stop()
+	s.connGuard.EXPECT().PeerGateway(gomock.Any()).Return("ws://gw2/ws", nil)
+	gomock.InOrder(
+		peer.EXPECT().Notify(gomock.Any(), closingMethod, &closeHint{
+			Reason:       CloseReasonDrain,
+			Reconnect:    true,
+			RetryAfterMs: 2500,
+			BackoffMs:    []int64{1000, 2000, 4000, 4000},
+			Gateway:      "ws://gw2/ws",
+		}).Return(nil),
+		peer.EXPECT().Close().Return(nil),
+	)
+
+	s.Require().NoError(s.server.Close())
+}
+
+func (s *ServerSuite) TestClose_PeerGatewayError() {
+	peer := jsonrpcmocks.NewMockPeer[rtcContext](s.ctrl)
+	s.clientManager.AddClient("conn1", "room1", peer)
+
+	s.connGuard.EXPECT().Stop()
+	s.connGuard.EXPECT().PeerGateway(gomock.Any()).Return("", fmt.Errorf("redis down"))
+	peer.EXPECT().
+		Notify(gomock.Any(), closingMethod, gomock.Cond(func(hint *closeHint) bool {
+			return hint.Reconnect && hint.Gateway == ""
+		})).
+		Return(nil)
+	peer.EXPECT().Close().Return(nil)
+
+	s.Require().NoError(s.server.Close())
+}
@@ -0,0 +1,110 @@
+package signal
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// joinRateWindow is the sliding window joins/sec is averaged over
+const joinRateWindow = 60 * time.Second
+
+// ConnStats is a snapshot of local WS load, used as autoscaling signals
+// (e.g. HPA external metrics adapter / KEDA metrics-api scaler)
+type ConnStats struct {
+	Connections        int            `json:"connections"`
+	Rooms              int            `json:"rooms"`
+	MaxRoomConnections int            `json:"maxRoomConnections"`
+	JoinsPerSec        float64        `json:"joinsPerSec"`
+	TotalJoins         int64          `json:"totalJoins"`
+	RoomConnections    map[string]int `json:"roomConnections"`
+}
+
+// joinRate counts joins in per-second buckets over a sliding window
+type joinRate struct {
+	mu      sync.Mutex
+	clock   clockwork.Clock
+	buckets []int64
+	seconds []int64 // unix second each bucket belongs to
+	total   int64
+	since   time.Time
+}
+
+func newJoinRate(clock clockwork.Clock) *joinRate {
+	n := int(joinRateWindow / time.Second)
+	return &joinRate{
+		clock:   clock,
+		buckets: make([]int64, n),
+		seconds: make([]int64, n),
+		since:   clock.Now(),
+	}
+}
+
+func (r *joinRate) inc() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sec := r.clock.Now().Unix()
+	idx := int(sec % int64(len(r.buckets)))
+	if r.seconds[idx] != sec {
+		r.seconds[idx] = sec
+		r.buckets[idx] = 0
+	}
+	r.buckets[idx]++
+	r.total++
+}
+
+// rate returns joins/sec over the window, or over the uptime when it is shorter
+func (r *joinRate) rate() (float64, int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	oldest := now.Unix() - int64(len(r.buckets)) + 1
+
+	var sum int64
+	for i, sec := range r.seconds {
+		if sec >= oldest {
+			sum += r.buckets[i]
+		}
+	}
+
+	window := joinRateWindow
+	if uptime := now.Sub(r.since); uptime < window {
+		window = max(uptime, time.Second)
+	}
+	return float64(sum) / window.Seconds(), r.total
+}
+
+// Stats returns a snapshot of the connections handled by this gateway instance
+func (m *WSConnManager) Stats() *ConnStats {
+	m.clientsMux.RLock()
+	stats := &ConnStats{
+		Connections:     len(m.client2rooms),
+		Rooms:           len(m.room2clients),
+		RoomConnections: make(map[string]int, len(m.room2clients)),
+	}
+	for roomID, conns := range m.room2clients {
+		stats.RoomConnections[roomID] = len(conns)
+		stats.MaxRoomConnections = max(stats.MaxRoomConnections, len(conns))
+	}
+	m.clientsMux.RUnlock()
+
+	stats.JoinsPerSec, stats.TotalJoins = m.joins.rate()
+	return stats
+}
+
+// HandleStats serves Stats as JSON, it is cheap enough to be polled by autoscalers
+func (m *WSConnManager) HandleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(m.Stats())
+}
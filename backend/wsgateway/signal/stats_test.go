@@ -0,0 +1,111 @@
+package signal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
+)
+
+func newStatsManager(clock clockwork.Clock) *WSConnManager {
+	return &WSConnManager{
+		room2clients: make(map[string]map[string]jsonrpc.Conn[rtcContext]),
+		client2rooms: make(map[string]map[string]struct{}),
+		joins:        newJoinRate(clock),
+		logger:       log.NewNop(),
+	}
+}
+
+func TestJoinRate(t *testing.T) {
+	t.Run("averages over uptime when shorter than window", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		r := newJoinRate(clock)
+
+		for range 10 {
+			r.inc()
+		}
+		clock.Advance(5 * time.Second)
+
+		rate, total := r.rate()
+		assert.InDelta(t, 2.0, rate, 0.001)
+		assert.Equal(t, int64(10), total)
+	})
+
+	t.Run("drops joins older than window", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		r := newJoinRate(clock)
+
+		for range 30 {
+			r.inc()
+		}
+		clock.Advance(joinRateWindow)
+		for range 6 {
+			r.inc()
+		}
+
+		rate, total := r.rate()
+		assert.InDelta(t, 0.1, rate, 0.001)
+		assert.Equal(t, int64(36), total)
+	})
+
+	t.Run("no joins", func(t *testing.T) {
+		r := newJoinRate(clockwork.NewFakeClock())
+
+		rate, total := r.rate()
+		assert.Zero(t, rate)
+		assert.Zero(t, total)
+	})
+}
+
+func TestStats(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	m := newStatsManager(clock)
+
+	m.AddClient("conn1", "room1", &mockConn{})
+	m.AddClient("conn2", "room1", &mockConn{})
+	m.AddClient("conn3", "room2", &mockConn{})
+	m.RemoveClient("conn3")
+	m.AddClient("conn4", "room3", &mockConn{})
+	clock.Advance(2 * time.Second)
+
+	stats := m.Stats()
+	assert.Equal(t, 3, stats.Connections)
+	assert.Equal(t, 2, stats.Rooms)
+	assert.Equal(t, 2, stats.MaxRoomConnections)
+	assert.Equal(t, map[string]int{"room1": 2, "room3": 1}, stats.RoomConnections)
+	assert.Equal(t, int64(4), stats.TotalJoins)
+	assert.InDelta(t, 2.0, stats.JoinsPerSec, 0.001)
+}
+
+func TestHandleStats(t *testing.T) {
+	m := newStatsManager(clockwork.NewFakeClock())
+	m.AddClient("conn1", "room1", &mockConn{})
+
+	t.Run("get", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		m.HandleStats(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var stats ConnStats
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+		assert.Equal(t, 1, stats.Connections)
+		assert.Equal(t, map[string]int{"room1": 1}, stats.RoomConnections)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		m.HandleStats(w, httptest.NewRequest(http.MethodPost, "/stats", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
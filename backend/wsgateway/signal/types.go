@@ -2,26 +2,215 @@ package signal
 
 import (
 	"context"
+	"encoding/json"
+	"sync"
+	"time"
 
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/janus"
 	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
+	"github.com/imtaco/audio-rtc-exp/internal/log"
 )
 
+// maxConnRooms bounds the rooms joined over one connection
+const maxConnRooms = 16
+
 type rtcContext struct {
-	janus    janus.Anchor
 	reqCtx   context.Context // request context
 	connID   string          // connID generated by server on connection establishment
 	clientID string          // clientID generated by client in the same session
 	userID   string
-	roomID   string
-	joined   bool
+	roomID   string // room of the connection token, calls without roomId target it
+	locale   string // locale of the connection token, notifications are localized in it
+	// rooms holds the state of the connection in each room, the token room from connect on and
+	// other rooms once joined. Connection manager handlers read roles, so access goes through roomsMu
+	rooms   map[string]*roomContext
+	roomsMu sync.RWMutex
+	// janusCalls are the Janus round trips of the RPC call being handled, for the slow call log
+	janusCalls []janusCall
+	stats      connStats
+	// lockHeld is set once the connection acquired its connection lock
+	lockHeld bool
+	// replaced is the connection of the user the lock was taken over from under kick_old
+	replaced *connReplaced
+	// duplicate is the hint the connection was closed with by the duplicate login policy
+	duplicate *closeHint
 	// rlimiter *rate.Limiter
 }
 
+// roomContext is the state of the connection in one room, each room has its own Janus anchor
+type roomContext struct {
+	janus  janus.Anchor
+	roomID string
+	role   constants.UserRole
+	joined bool
+	group  string // AudioBridge group of the participant, empty until joined to the Janus room
+	// endingNotified is set once the peer was told its room is ending
+	endingNotified bool
+	// frozen is whether the anchor was muted for the room being frozen
+	frozen bool
+	// publishRevoked is whether the anchor was muted for its publish slot being revoked
+	publishRevoked bool
+	// queuePosition is the place of a parked join in the join queue of the room, 0 when not queued
+	queuePosition int
+}
+
+// logFields identifies the connection in the RPC request log
+func (c *rtcContext) logFields() []log.Field {
+	return []log.Field{
+		log.String("connId", c.connID),
+		log.String("userId", c.userID),
+		log.String("roomId", c.roomID),
+	}
+}
+
+// room returns the state of the connection in the room, nil when it is not in the room
+func (c *rtcContext) room(roomID string) *roomContext {
+	c.roomsMu.RLock()
+	defer c.roomsMu.RUnlock()
+	return c.rooms[roomID]
+}
+
+func (c *rtcContext) addRoom(room *roomContext) {
+	c.roomsMu.Lock()
+	defer c.roomsMu.Unlock()
+	if c.rooms == nil {
+		c.rooms = make(map[string]*roomContext)
+	}
+	c.rooms[room.roomID] = room
+}
+
+func (c *rtcContext) removeRoom(roomID string) {
+	c.roomsMu.Lock()
+	defer c.roomsMu.Unlock()
+	delete(c.rooms, roomID)
+}
+
+// roleIn returns the role of the connection in the room, empty when it is not in the room
+func (c *rtcContext) roleIn(roomID string) constants.UserRole {
+	if room := c.room(roomID); room != nil {
+		return room.role
+	}
+	return ""
+}
+
+// joinedRooms returns the rooms the connection joined
+func (c *rtcContext) joinedRooms() []*roomContext {
+	c.roomsMu.RLock()
+	defer c.roomsMu.RUnlock()
+	rooms := make([]*roomContext, 0, len(c.rooms))
+	for _, room := range c.rooms {
+		if room.joined {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms
+}
+
+// targetRoomID returns the roomId of the call params, the token room when unset
+func (c *rtcContext) targetRoomID(params *json.RawMessage) string {
+	var data roomParams
+	if params != nil && json.Unmarshal(*params, &data) == nil && data.RoomID != "" {
+		return data.RoomID
+	}
+	return c.roomID
+}
+
+// joinedRoom returns the joined room the call targets, nil when it was not joined
+func (c *rtcContext) joinedRoom(params *json.RawMessage) *roomContext {
+	room := c.room(c.targetRoomID(params))
+	if room == nil || !room.joined {
+		return nil
+	}
+	return room
+}
+
 type ConnectionGuard interface {
 	MustHold(mctx jsonrpc.MethodContext[rtcContext]) (bool, error)
 	Release(mctx jsonrpc.MethodContext[rtcContext]) error
 	Start(ctx context.Context) error
 	Stop()
 	GetServerID() string
+	// PeerGateway returns the advertised URL of another live gateway, empty when there is none
+	PeerGateway(ctx context.Context) (string, error)
+	// Gateways returns the server IDs of the live gateways
+	Gateways(ctx context.Context) ([]string, error)
+	// GatewayURLs returns the advertised URLs of the live gateways by server ID
+	GatewayURLs(ctx context.Context) (map[string]string, error)
+}
+
+// PinGuard throttles failed room PIN attempts, state is shared by all gateways
+type PinGuard interface {
+	// Locked returns the remaining lockout of the connection or user, 0 when joins are allowed
+	Locked(ctx context.Context, connID, roomID, userID string) (time.Duration, error)
+	// Fail counts a failed attempt, returns the lockout it started or 0
+	Fail(ctx context.Context, connID, roomID, userID string) (time.Duration, error)
+	// Reset clears the failed attempts after a successful join
+	Reset(ctx context.Context, connID, roomID, userID string) error
+}
+
+// moderatorNotify is relayed through the WS stream to hosts of the room on every gateway
+type moderatorNotify struct {
+	RoomID string `json:"roomId"`
+	Method string `json:"method"`
+	Params any    `json:"params"`
+}
+
+// pinAttemptsExceeded is sent to moderators when a user is locked out of a room
+type pinAttemptsExceeded struct {
+	RoomID      string `json:"roomId"`
+	UserID      string `json:"userId"`
+	LockoutSecs int64  `json:"lockoutSecs"`
+}
+
+// roomParams picks the room of calls on connections joined to several rooms, the room of the
+// connection token when empty
+type roomParams struct {
+	RoomID string `json:"roomId"`
+}
+
+type joinParams struct {
+	roomParams
+	// Token is a JWT of the joined room for the same user, required for rooms other than the
+	// one of the connection token
+	Token      string `json:"token"`
+	Pin        string `json:"pin"`
+	ClientID   string `json:"clientId" validate:"required,uuid4"`
+	JanusToken string `json:"jtoken"`
+}
+
+type offerParams struct {
+	roomParams
+	SDP *janus.JSEP `json:"sdp" validate:"required"`
+}
+
+type iceCandidateParams struct {
+	roomParams
+	Candidate *janus.ICECandidate `json:"candidate" validate:"required"`
+}
+
+type keepAliveParams struct {
+	roomParams
+	Status constants.AnchorStatus `json:"status"`
+}
+
+type lowerHandParams struct {
+	roomParams
+	UserID string `json:"userId"` // hosts only, empty lowers the own hand
+}
+
+type grantPublishParams struct {
+	roomParams
+	UserID string `json:"userId"` // empty grants the head of the speaking queue
+}
+
+type revokePublishParams struct {
+	roomParams
+	UserID string `json:"userId"` // hosts only, empty gives back the own slot
+}
+
+type grantFloorParams struct {
+	roomParams
+	UserID string `json:"userId"` // empty grants the head of the speaking queue
+	Unmute bool   `json:"unmute"` // unmute the speaker in Janus
 }
@@ -1,8 +1,10 @@
 package signal
 
 import (
+	"context"
 	"net/http"
 
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/errors"
 	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
 	wsrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/websocket"
@@ -53,12 +55,21 @@ func (h *wsHookImpl) OnVerify(r *http.Request) (*rtcContext, bool, error) {
 		}
 		return nil, false, err
 	}
+	// guest tokens of hlsserver are signed with the same secret, they must not join as anchor
+	if !payload.HasRole(constants.UserRoleHost, constants.UserRoleAnchor) {
+		h.logger.Warn("Token role not allowed",
+			log.String("userId", payload.UserID),
+			log.String("role", string(payload.Role)))
+		return nil, false, nil
+	}
 	rctCtx := &rtcContext{
 		userID: payload.UserID,
 		roomID: payload.RoomID,
+		locale: payload.Locale,
 		reqCtx: r.Context(),
 		// rlimiter: rate.NewLimiter(1, 1),
 	}
+	rctCtx.addRoom(&roomContext{roomID: payload.RoomID, role: payload.Role})
 
 	return rctCtx, true, nil
 }
@@ -75,6 +86,11 @@ func (h *wsHookImpl) OnConnect(mctx jsonrpc.MethodContext[rtcContext]) {
 	}
 
 	h.connMgr.AddClient(connID, rctCtx.roomID, mctx.Peer())
+	if rctCtx.replaced != nil {
+		if err := h.connMgr.NotifyConnReplaced(rctCtx.reqCtx, rctCtx.replaced); err != nil {
+			h.logger.Error("Failed to notify replaced connection", log.Error(err))
+		}
+	}
 	h.logger.Info("Client connected",
 		log.String("connId", rctCtx.connID),
 		log.String("userId", rctCtx.userID),
@@ -86,10 +102,16 @@ func (h *wsHookImpl) OnDisconnect(mctx jsonrpc.MethodContext[rtcContext], errCod
 	rctCtx := mctx.Get()
 	connID := rctCtx.connID
 	h.connMgr.RemoveClient(connID)
+	if joined := len(rctCtx.joinedRooms()); joined > 0 {
+		joinsActive.Add(context.Background(), -int64(joined))
+	}
 
 	h.logger.Info("Client disconnected",
 		log.String("connId", connID),
 		log.Int("errorCode", errCode),
+		log.Int("rpcCalls", rctCtx.stats.calls),
+		log.Int("rpcFailed", rctCtx.stats.failed),
+		log.Int("rpcSlow", rctCtx.stats.slow),
 	)
 
 	if err := h.connGuard.Release(mctx); err != nil {
@@ -6,9 +6,11 @@ import (
 	"testing"
 
 	"github.com/google/uuid"
+	"github.com/jonboulle/clockwork"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/mock/gomock"
 
+	"github.com/imtaco/audio-rtc-exp/internal/constants"
 	"github.com/imtaco/audio-rtc-exp/internal/errors"
 	"github.com/imtaco/audio-rtc-exp/internal/jsonrpc"
 	wsrpc "github.com/imtaco/audio-rtc-exp/internal/jsonrpc/websocket"
@@ -39,7 +41,8 @@ func (s *WSHookSuite) SetupTest() {
 
 	s.clientManager = &WSConnManager{
 		room2clients: make(map[string]map[string]jsonrpc.Conn[rtcContext]),
-		client2room:  make(map[string]string),
+		client2rooms: make(map[string]map[string]struct{}),
+		joins:        newJoinRate(clockwork.NewRealClock()),
 		logger:       s.logger,
 	}
 
@@ -61,6 +64,8 @@ func (s *WSHookSuite) TestOnVerify_Success() {
 	s.jwtAuth.EXPECT().Verify("valid-token").Return(&jwt.Payload{
 		UserID: "user1",
 		RoomID: "room1",
+		Role:   constants.UserRoleAnchor,
+		Locale: "zh-TW",
 	}, nil)
 
 	ctx, pass, err := s.hook.OnVerify(req)
@@ -68,6 +73,8 @@ func (s *WSHookSuite) TestOnVerify_Success() {
 	s.True(pass)
 	s.Equal("user1", ctx.userID)
 	s.Equal("room1", ctx.roomID)
+	s.Equal("zh-TW", ctx.locale)
+	s.Equal(constants.UserRoleAnchor, ctx.tokenRoom().role)
 }
 
 func (s *WSHookSuite) TestOnVerify_BearerToken() {
@@ -77,6 +84,7 @@ func (s *WSHookSuite) TestOnVerify_BearerToken() {
 	s.jwtAuth.EXPECT().Verify("valid-token").Return(&jwt.Payload{
 		UserID: "user1",
 		RoomID: "room1",
+		Role:   constants.UserRoleAnchor,
 	}, nil)
 
 	ctx, pass, err := s.hook.OnVerify(req)
@@ -98,6 +106,17 @@ func (s *WSHookSuite) TestOnVerify_Failures() {
 	_, pass, err = s.hook.OnVerify(req)
 	s.Require().NoError(err)
 	s.False(pass)
+
+	// Guest token issued for HLS playback
+	req = httptest.NewRequest("GET", "/?token=guest", nil)
+	s.jwtAuth.EXPECT().Verify("guest").Return(&jwt.Payload{
+		UserID: "user1",
+		RoomID: "room1",
+		Role:   constants.UserRoleGuest,
+	}, nil)
+	_, pass, err = s.hook.OnVerify(req)
+	s.Require().NoError(err)
+	s.False(pass)
 }
 
 func (s *WSHookSuite) TestOnConnect_Success() {
@@ -5,8 +5,8 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
+	"github.com/imtaco/audio-rtc-exp/internal/httputil"
 	"github.com/imtaco/audio-rtc-exp/internal/jwt"
 	"github.com/imtaco/audio-rtc-exp/internal/log"
 )
@@ -18,12 +18,7 @@ type Router struct {
 }
 
 func NewRouter(jwtAuth *jwt.Auth, logger *log.Logger) *Router {
-	gin.SetMode(gin.ReleaseMode)
-	engine := gin.New()
-	engine.Use(gin.Recovery())
-
-	// Add OpenTelemetry middleware for automatic HTTP tracing
-	engine.Use(otelgin.Middleware("wsgateway"))
+	engine := httputil.NewEngine("wsgateway", logger)
 
 	r := &Router{
 		jwtAuth: jwtAuth,
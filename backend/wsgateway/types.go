@@ -16,6 +16,10 @@ type JanusProxy interface {
 	GetJanusAPI(roomID string) janus.API
 	GetRoomMeta(roomID string) *etcdstate.Meta
 	GetRoomLiveMeta(roomID string) *etcdstate.LiveMeta
+	// GetLinkGroup returns the AudioBridge group of the user, the link group when a link forwards the user alone
+	GetLinkGroup(roomID, userID string) string
+	// OnRoomChange sets the callback invoked with the ID of a room whose watched state changed
+	OnRoomChange(fn func(roomID string))
 }
 
 // JanusTokenCodec provides methods to encode/decode Janus tokens.
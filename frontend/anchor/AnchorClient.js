@@ -360,6 +360,7 @@ export class AnchorClient {
     if (this.currentStep === 'keepalive') {
       const currentStatus = this.isPlaying() ? ANCHOR_STATUS.ONAIR : ANCHOR_STATUS.IDLE;
       await this.peer.notify('keepalive', { status: currentStatus });
+      await this.reportStats();
       return { nextDelay: KEEPALIVE_INTERVAL_MS, next: true };
     }
 
@@ -371,6 +372,26 @@ export class AnchorClient {
     throw new Error(`Unknown step: ${this.currentStep}`);
   }
 
+  // Report RTT, jitter and packet loss seen by Janus for the published audio
+  async reportStats() {
+    if (!this.pc) {
+      return;
+    }
+    const stats = { rtt: 0, jitter: 0, packetLoss: 0 };
+    (await this.pc.getStats()).forEach((report) => {
+      if (report.type === 'remote-inbound-rtp' && report.kind === 'audio') {
+        stats.rtt = (report.roundTripTime || 0) * 1000;
+        stats.jitter = (report.jitter || 0) * 1000;
+        stats.packetLoss = Math.min(1, Math.max(0, report.fractionLost || 0));
+      }
+    });
+    try {
+      await this.peer.notify('stats.report', stats);
+    } catch (err) {
+      this.log(`Failed to report stats: ${err.message}`);
+    }
+  }
+
   async disconnect() {
     const log = this.log.bind(this);
     const setStatus = this.setStatus.bind(this);